@@ -0,0 +1,204 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/erauner/homelab-shadow/pkg/argocd"
+	"github.com/spf13/cobra"
+)
+
+var argocdOutputFormat string
+
+var argocdCmd = &cobra.Command{
+	Use:   "argocd",
+	Short: "ArgoCD Application discovery commands",
+	Long: `Commands for inspecting the ArgoCD Applications shadow discovers under
+argocd-apps/, including Applications generated by ApplicationSets.
+
+Examples:
+  shadow argocd list
+  shadow argocd list --output json`,
+}
+
+var argocdListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List discovered ArgoCD Applications",
+	Long: `Lists every ArgoCD Application (and every Application generated by an
+ApplicationSet) with:
+- Sources (path, chart, or ref)
+- Destination cluster and namespace
+- Sync policy
+- Whether each source path exists in the repo
+
+Unlike 'shadow helm list', Applications of every source type are included.
+Missing source paths are flagged so typos show up before ArgoCD reports them.
+
+Examples:
+  shadow argocd list
+  shadow argocd list --output json`,
+	RunE: runArgocdList,
+}
+
+func init() {
+	rootCmd.AddCommand(argocdCmd)
+	argocdCmd.AddCommand(argocdListCmd)
+
+	argocdListCmd.Flags().StringVarP(&argocdOutputFormat, "output", "o", "table", "Output format: table, json")
+}
+
+// ArgoAppInfo describes a discovered Application for listing
+type ArgoAppInfo struct {
+	Name           string           `json:"name"`
+	File           string           `json:"file"`
+	ApplicationSet string           `json:"application_set,omitempty"`
+	Cluster        string           `json:"cluster,omitempty"`
+	Namespace      string           `json:"namespace"`
+	SyncPolicy     string           `json:"sync_policy"`
+	SyncOptions    []string         `json:"sync_options,omitempty"`
+	Sources        []ArgoSourceInfo `json:"sources"`
+}
+
+// ArgoSourceInfo describes one source of an Application
+type ArgoSourceInfo struct {
+	Type           string `json:"type"`
+	RepoURL        string `json:"repo_url,omitempty"`
+	TargetRevision string `json:"target_revision,omitempty"`
+	Path           string `json:"path,omitempty"`
+	Chart          string `json:"chart,omitempty"`
+	Ref            string `json:"ref,omitempty"`
+	PathExists     *bool  `json:"path_exists,omitempty"`
+}
+
+func runArgocdList(cmd *cobra.Command, args []string) error {
+	apps, files, err := argocd.LoadApplications(repoDir)
+	if err != nil {
+		return fmt.Errorf("failed to discover Applications: %w", err)
+	}
+	logVerbose("Discovered %d Applications", len(apps))
+
+	infos := []ArgoAppInfo{}
+	missing := 0
+	for i, app := range apps {
+		info := ArgoAppInfo{
+			Name:           app.Name,
+			File:           repoRelPath(files[i]),
+			ApplicationSet: app.ApplicationSet,
+			Cluster:        app.Cluster,
+			Namespace:      app.Namespace,
+			SyncPolicy:     app.SyncPolicy.Summary(),
+			Sources:        []ArgoSourceInfo{},
+		}
+		if app.SyncPolicy != nil {
+			info.SyncOptions = app.SyncPolicy.SyncOptions
+		}
+
+		for _, source := range app.AllSources() {
+			src := ArgoSourceInfo{
+				RepoURL:        source.RepoURL,
+				TargetRevision: source.TargetRevision,
+				Path:           source.Path,
+				Chart:          source.Chart,
+				Ref:            source.Ref,
+			}
+			switch {
+			case source.IsHelmSource():
+				src.Type = "helm"
+			case source.Path != "":
+				src.Type = "path"
+			default:
+				src.Type = "ref"
+			}
+			if source.Path != "" {
+				exists := argocd.SourcePathExists(repoDir, source)
+				src.PathExists = &exists
+				if !exists {
+					missing++
+				}
+			}
+			info.Sources = append(info.Sources, src)
+		}
+
+		infos = append(infos, info)
+	}
+
+	switch argocdOutputFormat {
+	case "json":
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(infos)
+
+	case "table":
+		t := newTable("APP", "APPSET", "CLUSTER", "NAMESPACE", "SYNC", "SOURCE", "EXISTS")
+		t.Wrap = 5 // SOURCE
+
+		for _, app := range infos {
+			if len(app.Sources) == 0 {
+				t.Row(app.Name, dashIfEmpty(app.ApplicationSet), dashIfEmpty(app.Cluster),
+					dashIfEmpty(app.Namespace), app.SyncPolicy, "-", "-")
+				continue
+			}
+			for _, src := range app.Sources {
+				t.Row(app.Name, dashIfEmpty(app.ApplicationSet), dashIfEmpty(app.Cluster),
+					dashIfEmpty(app.Namespace), app.SyncPolicy, describeArgoSource(src), pathExistsMarker(src))
+			}
+		}
+		t.Render(os.Stdout)
+
+		fmt.Printf("\nTotal: %d Applications", len(infos))
+		if missing > 0 {
+			fmt.Printf(" (%d missing source paths)", missing)
+		}
+		fmt.Println()
+		return nil
+
+	default:
+		return fmt.Errorf("unknown output format: %s", argocdOutputFormat)
+	}
+}
+
+// describeArgoSource renders a source as "path", "chart@version", or "$ref"
+func describeArgoSource(src ArgoSourceInfo) string {
+	switch src.Type {
+	case "helm":
+		if src.TargetRevision != "" {
+			return src.Chart + "@" + src.TargetRevision
+		}
+		return src.Chart
+	case "path":
+		return src.Path
+	default:
+		return "$" + src.Ref
+	}
+}
+
+// pathExistsMarker returns ✓/✗ for path sources and - for everything else
+func pathExistsMarker(src ArgoSourceInfo) string {
+	if src.PathExists == nil {
+		return "-"
+	}
+	if *src.PathExists {
+		return "✓"
+	}
+	return "✗"
+}
+
+// repoRelPath returns path relative to the repo, falling back to path itself
+func repoRelPath(path string) string {
+	rel, err := filepath.Rel(repoDir, path)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return path
+	}
+	return filepath.ToSlash(rel)
+}
+
+// dashIfEmpty keeps empty table cells visible
+func dashIfEmpty(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
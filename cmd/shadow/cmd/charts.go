@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/erauner/homelab-shadow/pkg/validate"
+	"github.com/spf13/cobra"
+)
+
+var chartsOutputFormat string
+
+var chartsCmd = &cobra.Command{
+	Use:   "charts",
+	Short: "Helm chart inventory commands",
+	Long: `Commands for inspecting every Helm chart the repo renders, whether through
+Application Helm sources or kustomize helmCharts.
+
+Examples:
+  shadow charts list
+  shadow charts list --output json`,
+}
+
+var chartsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List Helm charts rendered by Applications and kustomize helmCharts",
+	Long: `Lists every Helm chart with its version, the mechanism that renders it
+(application: an ArgoCD Application or Flux HelmRelease Helm source;
+kustomize: a kustomization helmCharts entry), and where it is declared.
+
+Charts with the same name and repository pinned at different versions in
+different places are reported as chart-version-drift warnings (errors with
+--strict).
+
+Examples:
+  shadow charts list
+  shadow charts list --output json
+  shadow charts list --strict`,
+	RunE: runChartsList,
+}
+
+func init() {
+	rootCmd.AddCommand(chartsCmd)
+	chartsCmd.AddCommand(chartsListCmd)
+
+	chartsListCmd.Flags().StringVarP(&chartsOutputFormat, "output", "o", "table", "Output format: table, json")
+	chartsListCmd.Flags().BoolVar(&strict, "strict", false, "Treat version drift warnings as errors")
+}
+
+// ChartsReport is the JSON output of charts list
+type ChartsReport struct {
+	Charts []validate.ChartUsage `json:"charts"`
+	Drift  []validate.Result     `json:"drift"`
+}
+
+func runChartsList(cmd *cobra.Command, args []string) error {
+	usages, err := validate.NewClusterValidator(repoDir, verbose).ChartUsages()
+	if err != nil {
+		return err
+	}
+	drift := validate.ChartVersionDrift(usages)
+	logVerbose("Found %d chart usage(s), %d with version drift", len(usages), len(drift))
+
+	switch chartsOutputFormat {
+	case "json":
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(ChartsReport{Charts: usages, Drift: drift}); err != nil {
+			return fmt.Errorf("failed to encode JSON: %w", err)
+		}
+
+	case "table":
+		t := newTable("CHART", "VERSION", "MECHANISM", "SOURCE", "REPO")
+		for _, u := range usages {
+			t.Row(u.Chart, dashIfEmpty(u.Version), u.Mechanism, u.Source, dashIfEmpty(u.RepoURL))
+		}
+		t.Render(os.Stdout)
+
+		fmt.Printf("\nTotal: %d chart usage(s)\n", len(usages))
+		if len(drift) > 0 {
+			printResultsTable(drift)
+		}
+
+	default:
+		return fmt.Errorf("unknown output format: %s", chartsOutputFormat)
+	}
+
+	return checkExitCode(drift)
+}
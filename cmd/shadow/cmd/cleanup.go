@@ -0,0 +1,125 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/erauner/homelab-shadow/pkg/sync"
+	"github.com/spf13/cobra"
+)
+
+var (
+	cleanupShadowRepo   string
+	cleanupSourceRepo   string
+	cleanupProvider     string
+	cleanupDryRun       bool
+	cleanupMaxAge       string
+	cleanupOutputFormat string
+	cleanupSystemGit    bool
+)
+
+var cleanupCmd = &cobra.Command{
+	Use:   "cleanup",
+	Short: "Delete shadow repo pr-* branches for closed or merged PRs",
+	Long: `Cleanup deletes pr-<number> branches from the shadow repository whose pull
+request (GitLab: merge request) in the source repository is closed, merged, or
+gone. Open PRs keep their branches.
+
+With --max-age, pr-* and local-* branches whose last commit is older than the
+given age (e.g. 30d, 2w, 36h) are deleted too, whatever their PR state. The
+source repo is then optional: without it only the age policy applies.
+
+Unlike sync --cleanup-merged, nothing is rendered or cloned: branches are
+listed with the equivalent of git ls-remote and deleted with git push --delete,
+through the built-in git implementation (no git binary needed) or, with
+--use-system-git, the git binary.
+
+The source repo defaults to the slug of $GIT_URL. A bare slug is assumed to be
+on the same host as the shadow repo.
+
+Examples:
+  # Show which branches would be deleted
+  shadow cleanup --shadow-repo erauner/homelab-k8s-shadow --source-repo erauner/homelab-k8s --dry-run
+
+  # Delete them
+  shadow cleanup --shadow-repo erauner/homelab-k8s-shadow --source-repo erauner/homelab-k8s
+
+  # Also expire branches untouched for 30 days (no source repo needed)
+  shadow cleanup --shadow-repo erauner/homelab-k8s-shadow --max-age 30d
+
+  # JSON output for CI
+  shadow cleanup --shadow-repo erauner/homelab-k8s-shadow --source-repo erauner/homelab-k8s --output json`,
+	RunE: runCleanup,
+}
+
+func init() {
+	rootCmd.AddCommand(cleanupCmd)
+
+	cleanupCmd.Flags().StringVar(&cleanupShadowRepo, "shadow-repo", "", "Shadow repository (owner/repo or git URL)")
+	cleanupCmd.Flags().StringVar(&cleanupSourceRepo, "source-repo", "", "Source repository whose PRs the branches belong to (default: from $GIT_URL)")
+	cleanupCmd.Flags().StringVar(&cleanupProvider, "provider", "", "Git hosting provider: github, gitlab, or gitea (default: detected from --shadow-repo)")
+	cleanupCmd.Flags().BoolVar(&cleanupDryRun, "dry-run", false, "Report branches that would be deleted without deleting them")
+	cleanupCmd.Flags().StringVar(&cleanupMaxAge, "max-age", "", "Also delete pr-* and local-* branches whose last commit is older than this (e.g. 30d, 2w)")
+	cleanupCmd.Flags().BoolVar(&cleanupSystemGit, "use-system-git", false, "Run the git binary instead of the built-in git implementation")
+	cleanupCmd.Flags().StringVar(&cleanupOutputFormat, "output", "text", "Output format: text or json")
+}
+
+func runCleanup(cmd *cobra.Command, args []string) error {
+	if cleanupShadowRepo == "" {
+		return fmt.Errorf("--shadow-repo is required")
+	}
+	var maxAge time.Duration
+	if cleanupMaxAge != "" {
+		var err error
+		if maxAge, err = sync.ParseAge(cleanupMaxAge); err != nil {
+			return fmt.Errorf("invalid --max-age: %w", err)
+		}
+	}
+	sourceRepo := sourceRepoOrEnv(cleanupSourceRepo)
+	if sourceRepo == "" && maxAge == 0 {
+		return fmt.Errorf("--source-repo is required (or set GIT_URL, or use --max-age)")
+	}
+
+	provider, err := sync.ParseProvider(cleanupProvider)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case sourceRepo == "":
+		logInfo("Checking pr-* and local-* branches in %s older than %s...", cleanupShadowRepo, sync.FormatAge(maxAge))
+	case maxAge > 0:
+		logInfo("Checking branches in %s against %s PRs and older than %s...", cleanupShadowRepo, sourceRepo, sync.FormatAge(maxAge))
+	default:
+		logInfo("Checking pr-* branches in %s against %s PRs...", cleanupShadowRepo, sourceRepo)
+	}
+	result, err := sync.CleanupShadowRepo(cmd.Context(), sync.CleanupOptions{
+		ShadowRepo:   cleanupShadowRepo,
+		SourceRepo:   sourceRepo,
+		Provider:     provider,
+		DryRun:       cleanupDryRun,
+		MaxAge:       maxAge,
+		UseSystemGit: cleanupSystemGit,
+		Verbose:      verbose,
+	})
+	if err != nil {
+		return fmt.Errorf("cleanup failed: %w", err)
+	}
+
+	if strings.ToLower(cleanupOutputFormat) == "json" {
+		output, err := json.MarshalIndent(result, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal result: %w", err)
+		}
+		fmt.Println(string(output))
+	} else {
+		printCleanupResult(result, cleanupDryRun)
+	}
+
+	if len(result.Errors) > 0 {
+		return fmt.Errorf("%d branch(es) could not be checked or deleted", len(result.Errors))
+	}
+	return nil
+}
@@ -0,0 +1,182 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/erauner/homelab-shadow/pkg/kustomize"
+	"github.com/erauner/homelab-shadow/pkg/sync"
+	"github.com/erauner/homelab-shadow/pkg/toolcache"
+	"github.com/spf13/cobra"
+)
+
+var (
+	compatKustomize    string
+	compatCluster      string
+	compatOutputFormat string
+	compatToolsDir     string
+)
+
+var compatCmd = &cobra.Command{
+	Use:   "compat [dir...]",
+	Short: "Compare renders across kustomize versions",
+	Long: `Renders overlays with several kustomize versions and diffs the output
+resource by resource, so tool upgrades can be validated before they reach CI
+and ArgoCD.
+
+Versions are downloaded from the kustomize GitHub releases into --tools-dir,
+verified against the release checksums, and reused on later runs. Partial versions (5.3) resolve to the newest patch
+release. The first version is the baseline every other version is compared to.
+
+Pass the critical overlays to check as arguments; with none, every directory
+sync would render is compared. Exits non-zero if any directory fails to build
+or renders differently.
+
+Examples:
+  shadow compat --kustomize 5.3,5.4 apps/coder/overlays/erauner-home/production
+  shadow compat --kustomize 5.3.0,5.4.3 --cluster erauner-home
+  shadow compat --kustomize 5.3,5.4 -o json`,
+	RunE: runCompat,
+}
+
+func init() {
+	rootCmd.AddCommand(compatCmd)
+
+	compatCmd.Flags().StringVar(&compatKustomize, "kustomize", "", "Comma-separated kustomize versions to compare (baseline first)")
+	compatCmd.Flags().StringVarP(&compatCluster, "cluster", "c", "", "Compare only this cluster's directories (when no dirs are given)")
+	compatCmd.Flags().StringVarP(&compatOutputFormat, "output", "o", "table", "Output format: table, json")
+	addFailOnFlag(compatCmd, "error")
+	compatCmd.Flags().StringVar(&compatToolsDir, "tools-dir", toolcache.DefaultDir(), "Directory for downloaded kustomize releases (shared with --download-tools)")
+	compatCmd.MarkFlagRequired("kustomize")
+}
+
+func runCompat(cmd *cobra.Command, args []string) error {
+	var versions []string
+	for _, v := range strings.Split(compatKustomize, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			versions = append(versions, v)
+		}
+	}
+	if len(versions) < 2 {
+		return fmt.Errorf("--kustomize needs at least two versions to compare")
+	}
+
+	cache := toolcache.New(compatToolsDir)
+	binaries := make([]kustomize.VersionBinary, 0, len(versions))
+	for _, v := range versions {
+		resolved, err := kustomize.ResolveVersion(compatToolsDir, v)
+		if err != nil {
+			return err
+		}
+		path, err := cache.Ensure("kustomize", resolved)
+		if err != nil {
+			return err
+		}
+		logVerbose("kustomize %s -> %s", v, path)
+		binaries = append(binaries, kustomize.VersionBinary{Version: resolved, Path: path})
+	}
+
+	dirs := args
+	if len(dirs) == 0 {
+		var clusters []string
+		if compatCluster != "" {
+			clusters = []string{compatCluster}
+		}
+		var err error
+		dirs, err = sync.DiscoverKustomizationsForSync(repoDir, clusters)
+		if err != nil {
+			return fmt.Errorf("failed to discover directories: %w", err)
+		}
+	}
+	logInfo("Comparing %d director(ies) across kustomize %s", len(dirs), strings.Join(versionNames(binaries), ", "))
+
+	results := kustomize.CompareVersions(repoDir, binaries, dirs)
+
+	switch compatOutputFormat {
+	case "json":
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(results); err != nil {
+			return fmt.Errorf("failed to encode JSON: %w", err)
+		}
+	case "table":
+		printCompatTable(binaries, results)
+	default:
+		return fmt.Errorf("unknown output format: %s", compatOutputFormat)
+	}
+
+	inconsistent := 0
+	for _, r := range results {
+		if !r.Consistent() {
+			inconsistent++
+		}
+	}
+	if inconsistent > 0 {
+		return failFindings(fmt.Errorf("%d director(ies) fail or render differently across kustomize versions", inconsistent), nil)
+	}
+	return nil
+}
+
+func versionNames(binaries []kustomize.VersionBinary) []string {
+	names := make([]string, 0, len(binaries))
+	for _, b := range binaries {
+		names = append(names, b.Version)
+	}
+	return names
+}
+
+func printCompatTable(binaries []kustomize.VersionBinary, results []kustomize.CompatResult) {
+	header := []string{"DIRECTORY"}
+	for i, name := range versionNames(binaries) {
+		if i == 0 {
+			name += " (baseline)"
+		}
+		header = append(header, name)
+	}
+	t := newTable(header...)
+
+	for _, r := range results {
+		row := []string{r.Directory}
+		for i, render := range r.Renders {
+			switch {
+			case render.Error != "":
+				row = append(row, "❌ failed")
+			case i == 0 || render.Diff == nil:
+				row = append(row, "✅ ok")
+			case render.Diff.Empty():
+				row = append(row, "✅ same")
+			default:
+				row = append(row, "⚠️ differs "+render.Diff.String())
+			}
+		}
+		t.Row(row...)
+	}
+	t.Render(os.Stdout)
+
+	for _, r := range results {
+		if r.Consistent() {
+			continue
+		}
+		fmt.Printf("\n%s:\n", r.Directory)
+		for _, render := range r.Renders {
+			if render.Error != "" {
+				fmt.Printf("  %s: %s\n", render.Version, strings.ReplaceAll(render.Error, "\n", "\n    "))
+				continue
+			}
+			if render.Diff == nil || render.Diff.Empty() {
+				continue
+			}
+			for _, id := range render.Diff.Added {
+				fmt.Printf("  %s: + %s\n", render.Version, id)
+			}
+			for _, id := range render.Diff.Removed {
+				fmt.Printf("  %s: - %s\n", render.Version, id)
+			}
+			for _, id := range render.Diff.Changed {
+				fmt.Printf("  %s: ~ %s\n", render.Version, id)
+			}
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/erauner/homelab-shadow/pkg/sync"
+	"github.com/erauner/homelab-shadow/pkg/validate"
+	"github.com/spf13/cobra"
+)
+
+var (
+	conflictsCluster      string
+	conflictsOutputFormat string
+	conflictsRendered     string
+	conflictsEngine       string
+)
+
+var conflictsCmd = &cobra.Command{
+	Use:   "conflicts",
+	Short: "Find resources rendered by more than one directory per cluster",
+	Long: `Renders every deployable kustomization (the same set sync publishes),
+indexes the resources per cluster, and reports each group/kind/namespace/name
+produced by two or more directories. ArgoCD Applications deploying those
+directories would fight over ownership of the resource.
+
+A directory that includes another through resources or components (a stack
+wrapping its overlay) is not reported as conflicting with it. Directories
+without a cluster (legacy overlays, Helm renders) are only compared with each
+other.
+
+Use --rendered to check an existing rendered tree (e.g. a shadow repo
+checkout's rendered/ directory) instead of building.
+
+Examples:
+  shadow conflicts --repo /path/to/homelab-k8s
+  shadow conflicts --repo . --cluster erauner-home
+  shadow conflicts --rendered ../homelab-k8s-shadow/rendered --output json`,
+	RunE: runConflicts,
+}
+
+func init() {
+	rootCmd.AddCommand(conflictsCmd)
+
+	conflictsCmd.Flags().StringVarP(&conflictsCluster, "cluster", "c", "", "Check only this cluster")
+	conflictsCmd.Flags().StringVarP(&conflictsOutputFormat, "output", "o", "table", "Output format: table, json, markdown")
+	conflictsCmd.Flags().StringVar(&conflictsRendered, "rendered", "", "Check manifest.yaml files under this directory instead of building")
+	conflictsCmd.Flags().StringVar(&conflictsEngine, "kustomize-engine", "exec", "Kustomize build engine: exec, krusty (in-process), or auto")
+	conflictsCmd.Flags().BoolVar(&strict, "strict", false, "Treat warnings as errors")
+	conflictsCmd.Flags().BoolVar(&showSuppressed, "show-suppressed", false, "Also list findings suppressed by config or shadow:ignore comments")
+}
+
+func runConflicts(cmd *cobra.Command, args []string) error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	var clusters []string
+	if conflictsCluster != "" {
+		clusters = []string{conflictsCluster}
+	}
+
+	allResults := []validate.Result{} // Initialize to empty slice for JSON output
+	var manifests map[string]string
+	if conflictsRendered != "" {
+		manifests, err = readRenderedManifests(conflictsRendered)
+	} else {
+		manifests, allResults, err = buildManifests(clusters, conflictsEngine)
+	}
+	if err != nil {
+		return err
+	}
+
+	if conflictsCluster != "" {
+		for dir := range manifests {
+			if cluster := sync.ClusterForDirectory(dir); cluster != "" && cluster != conflictsCluster {
+				delete(manifests, dir)
+			}
+		}
+	}
+
+	conflicts, err := validate.FindConflicts(manifests, sync.ClusterForDirectory)
+	if err != nil {
+		return err
+	}
+	conflicts = validate.NewClusterValidator(repoDir, verbose).WithoutIncluded(conflicts)
+	logInfo("Indexed %d manifest(s), found %d conflicting resource(s)", len(manifests), len(conflicts))
+
+	for _, c := range conflicts {
+		allResults = append(allResults, c.Result())
+	}
+
+	allResults = validate.ApplySeverities(allResults, cfg)
+	allResults = applyStrictness(allResults, cfg)
+	validate.ApplyInlineSuppressions(allResults, repoDir)
+	validate.AssignOwners(allResults, cfg)
+
+	switch conflictsOutputFormat {
+	case "json":
+		return outputJSON(allResults)
+	case "table":
+		return outputTable(allResults)
+	case "markdown":
+		return outputMarkdown(allResults)
+	default:
+		return fmt.Errorf("unknown output format: %s", conflictsOutputFormat)
+	}
+}
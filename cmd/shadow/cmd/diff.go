@@ -0,0 +1,147 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/erauner/homelab-shadow/pkg/cluster"
+	"github.com/erauner/homelab-shadow/pkg/command"
+	"github.com/erauner/homelab-shadow/pkg/kustomize"
+	"github.com/erauner/homelab-shadow/pkg/sync"
+	"github.com/spf13/cobra"
+)
+
+var (
+	diffLive         bool
+	diffKubeContext  string
+	diffCluster      string
+	diffOutputFormat string
+)
+
+var diffCmd = &cobra.Command{
+	Use:   "diff --live [path...]",
+	Short: "Diff rendered kustomizations against a live cluster",
+	Long: `Diff renders each kustomization directory (or every directory sync would
+publish, optionally only for --cluster) and pipes the manifest through
+"kubectl diff --server-side" against the cluster of --context, reporting each
+directory as changed, unchanged, or error.
+
+Use it as a final sanity check before merging an infrastructure PR: it shows
+what applying the branch would change, as the API server sees it, including
+defaulting and admission mutations. Manifests are diffed unredacted; kubectl
+masks Secret data in its output. --live is required; it is the only mode.
+
+Directories that fail to render or diff are errors (see --fail-on); changed
+directories are warnings, so --fail-on warn exits non-zero on any change.
+
+Examples:
+  shadow diff --live --context erauner-home
+  shadow diff --live --context erauner-home --cluster erauner-home
+  shadow diff --live --context erauner-home apps/coder/overlays/erauner-home/production
+  shadow diff --live --context erauner-home -o json`,
+	RunE: runDiff,
+}
+
+func init() {
+	rootCmd.AddCommand(diffCmd)
+
+	diffCmd.Flags().BoolVar(&diffLive, "live", false, "Diff against the live cluster with kubectl diff --server-side (required)")
+	diffCmd.Flags().StringVar(&diffKubeContext, "context", "", "kubeconfig context of the target cluster (default: current context)")
+	diffCmd.Flags().StringVar(&diffCluster, "cluster", "", "Only diff directories that sync publishes for this cluster")
+	diffCmd.Flags().StringVarP(&diffOutputFormat, "output", "o", "text", "Output format: text, json")
+	addFailOnFlag(diffCmd, "error")
+}
+
+func runDiff(cmd *cobra.Command, args []string) error {
+	if !diffLive {
+		return fmt.Errorf("--live is required")
+	}
+	switch diffOutputFormat {
+	case "text", "json":
+	default:
+		return fmt.Errorf("unknown output format: %s", diffOutputFormat)
+	}
+	if _, err := exec.LookPath("kubectl"); err != nil {
+		return fmt.Errorf("live diff requires kubectl, which is %w", command.ErrNotInstalled)
+	}
+
+	dirs := make([]string, 0, len(args))
+	for _, arg := range args {
+		dirs = append(dirs, strings.TrimSuffix(strings.TrimPrefix(arg, "./"), "/"))
+	}
+	if len(dirs) == 0 {
+		var clusters []string
+		if diffCluster != "" {
+			clusters = []string{diffCluster}
+		}
+		var err error
+		if dirs, err = sync.DiscoverKustomizationsForSync(repoDir, clusters); err != nil {
+			return fmt.Errorf("failed to discover kustomizations: %w", err)
+		}
+	}
+
+	runner := kustomize.NewRunner(repoDir, "", verbose)
+	registry, err := cluster.Load(repoDir)
+	if err != nil {
+		return err
+	}
+
+	var diffs []*sync.LiveDiff
+	changed, failed := 0, 0
+	for _, dir := range dirs {
+		logVerbose("Diffing %s", dir)
+		var diff *sync.LiveDiff
+		if manifest, err := renderKustomization(runner, registry, dir, nil); err != nil {
+			diff = &sync.LiveDiff{Dir: dir, Status: sync.LiveError, Error: err.Error()}
+		} else {
+			diff = sync.KubectlDiff(cmd.Context(), diffKubeContext, dir, manifest)
+		}
+		switch diff.Status {
+		case sync.LiveChanged:
+			changed++
+		case sync.LiveError:
+			failed++
+		}
+		diffs = append(diffs, diff)
+	}
+
+	if diffOutputFormat == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(diffs); err != nil {
+			return fmt.Errorf("failed to encode JSON: %w", err)
+		}
+	} else {
+		for _, d := range diffs {
+			printLiveDiff(d)
+		}
+		fmt.Printf("\n%d directories diffed: %d changed, %d unchanged, %d error(s)\n",
+			len(diffs), changed, len(diffs)-changed-failed, failed)
+	}
+
+	var errs, warns error
+	if failed > 0 {
+		errs = fmt.Errorf("%d directories could not be diffed", failed)
+	}
+	if changed > 0 {
+		warns = fmt.Errorf("%d directories differ from the live cluster", changed)
+	}
+	return failFindings(errs, warns)
+}
+
+func printLiveDiff(d *sync.LiveDiff) {
+	switch d.Status {
+	case sync.LiveUnchanged:
+		fmt.Printf("%s: unchanged\n", d.Dir)
+	case sync.LiveError:
+		fmt.Printf("%s: error: %s\n", d.Dir, d.Error)
+	default:
+		fmt.Printf("%s: changed\n", d.Dir)
+		for _, line := range strings.Split(strings.TrimRight(d.Diff, "\n"), "\n") {
+			fmt.Printf("  %s\n", line)
+		}
+	}
+}
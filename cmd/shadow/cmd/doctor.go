@@ -0,0 +1,121 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/erauner/homelab-shadow/pkg/doctor"
+	"github.com/erauner/homelab-shadow/pkg/sync"
+	"github.com/spf13/cobra"
+)
+
+var (
+	doctorShadowRepo   string
+	doctorProvider     string
+	doctorOffline      bool
+	doctorOutputFormat string
+)
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Check the toolchain, token, and shadow repo access",
+	Long: `Checks the environment shadow runs in and prints what to fix:
+
+  - git, kustomize, helm, kubeconform, and kyverno are on PATH and at least
+    the oldest version shadow supports (git and kustomize are required; the
+    others only warn, since only some commands need them)
+  - tools pinned in .shadow.yaml (tools: {kustomize: ">=5.3.0 <5.5.0"}) are
+    within their pinned ranges
+  - the provider token (GH_TOKEN, GITLAB_TOKEN, or GITEA_TOKEN) is set and
+    accepted by the provider
+  - with --shadow-repo, the token can push to the shadow repository
+
+Exits non-zero if any check fails, so it can run as a CI preflight step.
+
+Examples:
+  shadow doctor
+  shadow doctor --shadow-repo erauner/homelab-k8s-shadow
+  shadow doctor --offline -o json`,
+	RunE: runDoctor,
+}
+
+func init() {
+	rootCmd.AddCommand(doctorCmd)
+
+	doctorCmd.Flags().StringVar(&doctorShadowRepo, "shadow-repo", "", "Shadow repository (owner/repo or git URL) to check push access to")
+	doctorCmd.Flags().StringVar(&doctorProvider, "provider", "", "Git hosting provider: github, gitlab, or gitea (default: detected from --shadow-repo)")
+	doctorCmd.Flags().BoolVar(&doctorOffline, "offline", false, "Only check local tools; skip the provider API")
+	doctorCmd.Flags().StringVarP(&doctorOutputFormat, "output", "o", "table", "Output format: table, json")
+}
+
+func runDoctor(cmd *cobra.Command, args []string) error {
+	provider, err := sync.ParseProvider(doctorProvider)
+	if err != nil {
+		return err
+	}
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	report, err := doctor.Run(doctor.Options{
+		Pins:       cfg.Tools,
+		ShadowRepo: doctorShadowRepo,
+		Provider:   provider,
+		Offline:    doctorOffline,
+	})
+	if err != nil {
+		return err
+	}
+
+	switch doctorOutputFormat {
+	case "json":
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(report); err != nil {
+			return fmt.Errorf("failed to encode JSON: %w", err)
+		}
+	case "table":
+		printDoctorTable(report)
+	default:
+		return fmt.Errorf("unknown output format: %s", doctorOutputFormat)
+	}
+
+	if failed := report.Failed(); failed > 0 {
+		return fmt.Errorf("%d check(s) failed", failed)
+	}
+	return nil
+}
+
+func printDoctorTable(report doctor.Report) {
+	t := newTable("CHECK", "STATUS", "VERSION", "PINNED", "DETAIL")
+	for _, c := range report.Checks {
+		t.Row(c.Name, doctorStatus(c.Status), c.Version, c.Pin, c.Detail)
+	}
+	t.Render(os.Stdout)
+
+	first := true
+	for _, c := range report.Checks {
+		if c.Remediation == "" {
+			continue
+		}
+		if first {
+			fmt.Println()
+			first = false
+		}
+		fmt.Printf("%s: %s\n", c.Name, c.Remediation)
+	}
+}
+
+func doctorStatus(status doctor.Status) string {
+	switch status {
+	case doctor.StatusOK:
+		return "✅ ok"
+	case doctor.StatusWarn:
+		return "⚠️ warn"
+	case doctor.StatusFail:
+		return "❌ fail"
+	default:
+		return "⏭️ skip"
+	}
+}
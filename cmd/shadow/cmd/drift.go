@@ -0,0 +1,215 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/erauner/homelab-shadow/pkg/argocd"
+	"github.com/erauner/homelab-shadow/pkg/cluster"
+	"github.com/erauner/homelab-shadow/pkg/kustomize"
+	"github.com/erauner/homelab-shadow/pkg/sync"
+	"github.com/spf13/cobra"
+)
+
+var (
+	driftServer         string
+	driftInsecure       bool
+	driftFrom           string
+	driftCluster        string
+	driftIgnoreRevision bool
+	driftOutputFormat   string
+	driftHelmCreds      string
+)
+
+var driftCmd = &cobra.Command{
+	Use:   "drift [application...]",
+	Short: "Compare what ArgoCD deploys with what the repo renders",
+	Long: `Drift compares each ArgoCD Application's live desired state with what the
+local repo renders for it at HEAD, and reports Applications that differ:
+
+- Manifests: ArgoCD's target manifests (argocd app manifests) against the
+  local render, resource by resource, ignoring ArgoCD's tracking label and
+  annotation and Secret data (which ArgoCD masks)
+- Overrides: source fields in the live Application that differ from the
+  repo's, such as Helm parameters set with "argocd app set -p" or a
+  targetRevision changed in the UI
+- Revision: the git revision ArgoCD last synced isn't the repo's HEAD
+  (disable with --ignore-revision)
+
+Live state comes from the ArgoCD API at --server (default: $ARGOCD_SERVER),
+authenticated with $ARGOCD_AUTH_TOKEN, or with --from from a directory of saved
+argocd CLI output: <app>.yaml from "argocd app manifests <app>" and, optionally,
+<app>.json from "argocd app get <app> -o json" for overrides and revisions.
+
+Without arguments every Application under argocd-apps/ is compared. Drifted
+Applications exit with code 2 (see --fail-on); Applications ArgoCD doesn't
+have are reported as not-deployed.
+
+Examples:
+  ARGOCD_AUTH_TOKEN=... shadow drift --server argocd.example.com
+  shadow drift coder jenkins --server argocd.example.com
+  shadow drift --from ./argocd-snapshot --output json`,
+	RunE: runDrift,
+}
+
+func init() {
+	rootCmd.AddCommand(driftCmd)
+
+	driftCmd.Flags().StringVar(&driftServer, "server", os.Getenv("ARGOCD_SERVER"), "ArgoCD API server (host[:port] or URL; token from ARGOCD_AUTH_TOKEN)")
+	driftCmd.Flags().BoolVar(&driftInsecure, "insecure", false, "Skip TLS certificate verification for --server")
+	driftCmd.Flags().StringVar(&driftFrom, "from", "", "Read live state from saved argocd CLI output (<app>.yaml manifests, optional <app>.json) instead of the API")
+	driftCmd.Flags().StringVar(&driftCluster, "cluster", "", "Only compare Applications deployed to this cluster")
+	driftCmd.Flags().BoolVar(&driftIgnoreRevision, "ignore-revision", false, "Don't report Applications synced to a revision other than HEAD")
+	driftCmd.Flags().StringVarP(&driftOutputFormat, "output", "o", "text", "Output format: text, json")
+	driftCmd.Flags().StringVar(&driftHelmCreds, "helm-creds-file", "", "YAML file of credentials for private Helm repositories (see shadow helm test --help)")
+	addFailOnFlag(driftCmd, "error")
+}
+
+func runDrift(cmd *cobra.Command, args []string) error {
+	var source argocd.LiveSource
+	switch {
+	case driftFrom != "":
+		source = argocd.DirSource{Dir: driftFrom}
+	case driftServer != "":
+		source = argocd.NewClient(driftServer, os.Getenv("ARGOCD_AUTH_TOKEN"), driftInsecure)
+	default:
+		return fmt.Errorf("--server (or ARGOCD_SERVER) or --from is required")
+	}
+	switch driftOutputFormat {
+	case "text", "json":
+	default:
+		return fmt.Errorf("unknown output format: %s", driftOutputFormat)
+	}
+
+	apps, _, err := argocd.LoadApplications(repoDir)
+	if err != nil {
+		return fmt.Errorf("failed to discover Applications: %w", err)
+	}
+	var targets []*argocd.Application
+	for _, app := range apps {
+		if (len(args) > 0 && !slices.Contains(args, app.Name)) || (driftCluster != "" && app.Cluster != driftCluster) {
+			continue
+		}
+		targets = append(targets, app)
+	}
+	for _, name := range args {
+		if !slices.ContainsFunc(targets, func(app *argocd.Application) bool { return app.Name == name }) {
+			return fmt.Errorf("application not found: %s", name)
+		}
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	normalizer := sync.NewNormalizer(cfg.Normalization)
+	runner := kustomize.NewRunner(repoDir, "", verbose)
+	registry, err := cluster.Load(repoDir)
+	if err != nil {
+		return err
+	}
+	var head string
+	if !driftIgnoreRevision {
+		if head, err = sync.HeadCommit(repoDir); err != nil {
+			return fmt.Errorf("failed to read HEAD (use --ignore-revision outside a git checkout): %w", err)
+		}
+	}
+
+	var drifts []*sync.AppDrift
+	for _, app := range targets {
+		logVerbose("Comparing %s", app.Name)
+		drifts = append(drifts, compareApp(cmd.Context(), source, runner, registry, app, head, normalizer))
+	}
+
+	drifted, failed := 0, 0
+	for _, d := range drifts {
+		switch d.Status {
+		case sync.DriftDrifted:
+			drifted++
+		case sync.DriftError:
+			failed++
+		}
+	}
+
+	if driftOutputFormat == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(drifts); err != nil {
+			return fmt.Errorf("failed to encode JSON: %w", err)
+		}
+	} else {
+		for _, d := range drifts {
+			printAppDrift(d)
+		}
+		fmt.Printf("\n%d application(s) compared, %d drifted\n", len(drifts), drifted)
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d application(s) could not be compared", failed)
+	}
+	if drifted > 0 {
+		return failFindings(fmt.Errorf("%d application(s) drifted from the repo", drifted), nil)
+	}
+	return nil
+}
+
+// compareApp renders app and compares it with its live state; failures are
+// reported on the result rather than stopping the run
+func compareApp(ctx context.Context, source argocd.LiveSource, runner *kustomize.Runner, registry *cluster.Registry, app *argocd.Application, head string, normalizer *sync.Normalizer) *sync.AppDrift {
+	live, err := source.Live(ctx, app.Name)
+	if errors.Is(err, argocd.ErrNotDeployed) {
+		return &sync.AppDrift{App: app.Name, Status: sync.DriftNotDeployed}
+	}
+	if err != nil {
+		return &sync.AppDrift{App: app.Name, Status: sync.DriftError, Error: err.Error()}
+	}
+	manifests, err := renderApplicationSources(runner, registry, app, driftHelmCreds)
+	if err != nil {
+		return &sync.AppDrift{App: app.Name, Status: sync.DriftError, Error: err.Error()}
+	}
+	drift, err := sync.CompareLive(app, sync.JoinManifests(manifests), live, head, normalizer)
+	if err != nil {
+		return &sync.AppDrift{App: app.Name, Status: sync.DriftError, Error: err.Error()}
+	}
+	return drift
+}
+
+func printAppDrift(d *sync.AppDrift) {
+	switch d.Status {
+	case sync.DriftNotDeployed:
+		fmt.Printf("%s: not deployed\n", d.App)
+		return
+	case sync.DriftError:
+		fmt.Printf("%s: error: %s\n", d.App, d.Error)
+		return
+	}
+
+	status := d.Status
+	if d.SyncStatus != "" {
+		status += fmt.Sprintf(" (ArgoCD: %s, %s)", d.SyncStatus, dashIfEmpty(d.Health))
+	}
+	fmt.Printf("%s: %s\n", d.App, status)
+	if d.StaleRevision {
+		fmt.Printf("  revision %s, HEAD is %s\n", strings.Join(d.Revisions, ", "), d.Head)
+	}
+	for _, o := range d.Overrides {
+		fmt.Printf("  override %s\n", o)
+	}
+	for _, id := range d.Diff.Added {
+		fmt.Printf("  + %s (not deployed)\n", id)
+	}
+	for _, id := range d.Diff.Removed {
+		fmt.Printf("  - %s (deployed, not in the repo)\n", id)
+	}
+	for _, id := range d.Diff.Changed {
+		fmt.Printf("  ~ %s\n", id)
+		for _, f := range d.Diff.Fields[id] {
+			fmt.Printf("      %s: %s -> %s\n", f.Path, formatFieldValue(f.Before), formatFieldValue(f.After))
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+
+	"github.com/erauner/homelab-shadow/pkg/command"
+	"github.com/spf13/cobra"
+)
+
+// Exit codes, so pipelines can tell hard failures from advisories
+const (
+	// ExitFailure is shadow failing to do its job: bad flags or config, git,
+	// network, or API errors, a command that crashed or timed out
+	ExitFailure = 1
+
+	// ExitFindings is a completed run that found errors: validation errors,
+	// failed builds, failed policy tests
+	ExitFindings = 2
+
+	// ExitBudgetExceeded is the exit code of a sync that ran out of --budget
+	// and published a partial render
+	ExitBudgetExceeded = 3
+
+	// ExitWarnings is a completed run that found only warnings, with
+	// --fail-on warn (or validate --strict)
+	ExitWarnings = 4
+
+	// ExitToolMissing is a required external tool that isn't installed or
+	// doesn't match the version pinned in .shadow.yaml
+	ExitToolMissing = 5
+)
+
+// exitError makes Execute's caller exit with a specific code
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string { return e.err.Error() }
+func (e *exitError) Unwrap() error { return e.err }
+
+// ExitCode returns the process exit code for an error returned by Execute
+func ExitCode(err error) int {
+	var exit *exitError
+	if errors.As(err, &exit) {
+		return exit.code
+	}
+	if errors.Is(err, command.ErrNotInstalled) || errors.Is(err, exec.ErrNotFound) {
+		return ExitToolMissing
+	}
+	return ExitFailure
+}
+
+// failOn is the --fail-on level of the running command, set by setup:
+// "error" (the default), "warn", or "none"
+var failOn = "error"
+
+// addFailOnFlag registers --fail-on on cmd; each command owns its flag so
+// defaults can differ (sync publishes failures rather than failing on them)
+func addFailOnFlag(cmd *cobra.Command, def string) {
+	cmd.Flags().String("fail-on", def, "Exit non-zero on findings of this severity or worse: error, warn, or none")
+}
+
+// setFailOn reads --fail-on from the running command
+func setFailOn(cmd *cobra.Command) error {
+	failOn = "error"
+	flag := cmd.Flags().Lookup("fail-on")
+	if flag == nil {
+		return nil
+	}
+	switch flag.Value.String() {
+	case "error", "warn", "none":
+		failOn = flag.Value.String()
+		return nil
+	default:
+		return fmt.Errorf("unknown --fail-on level: %s (expected error, warn, or none)", flag.Value.String())
+	}
+}
+
+// failFindings applies --fail-on to a completed run: errs describes its
+// error-severity findings and warns its warnings (nil when there are none).
+// Errors exit ExitFindings unless --fail-on none; warnings exit ExitWarnings
+// only with --fail-on warn (or validate --strict)
+func failFindings(errs, warns error) error {
+	if failOn == "none" {
+		return nil
+	}
+	if errs != nil {
+		return &exitError{code: ExitFindings, err: errs}
+	}
+	if warns != nil && (failOn == "warn" || strict) {
+		return &exitError{code: ExitWarnings, err: warns}
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/erauner/homelab-shadow/pkg/sync"
+	"github.com/erauner/homelab-shadow/pkg/validate"
+	"github.com/spf13/cobra"
+)
+
+var (
+	explainCluster      string
+	explainOutputFormat string
+)
+
+var explainCmd = &cobra.Command{
+	Use:   "explain-path <path>",
+	Short: "Explain how shadow classifies a directory",
+	Long: `Explains how shadow treats a repo-relative directory:
+
+  - which sync discovery pattern matches it
+  - which cluster and environment it maps to
+  - which ArgoCD Applications (including ApplicationSet output) deploy it
+  - which validation rules inspect it
+  - whether sync would render it, and where the manifest would be written
+    (one path per output root configured in .shadow.yaml)
+
+Examples:
+  shadow explain-path apps/coder/overlays/erauner-home/production
+  shadow explain-path infrastructure/cert-manager/overlays/erauner-home --cluster erauner-cloud
+  shadow explain-path apps/coder/base -o json`,
+	Args: cobra.ExactArgs(1),
+	RunE: runExplain,
+}
+
+func init() {
+	rootCmd.AddCommand(explainCmd)
+
+	explainCmd.Flags().StringVarP(&explainCluster, "cluster", "c", "", "Apply the same cluster filter as sync --cluster")
+	explainCmd.Flags().StringVarP(&explainOutputFormat, "output", "o", "text", "Output format: text, json")
+}
+
+// pathExplanation adds the applicable validation rules to the sync view
+type pathExplanation struct {
+	*sync.PathExplanation
+	Rules []string `json:"rules"`
+}
+
+func runExplain(cmd *cobra.Command, args []string) error {
+	var clusters []string
+	if explainCluster != "" {
+		clusters = []string{explainCluster}
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	exp, err := sync.ExplainPath(repoDir, args[0], clusters, cfg.Outputs)
+	if err != nil {
+		return fmt.Errorf("failed to explain path: %w", err)
+	}
+	result := pathExplanation{PathExplanation: exp, Rules: validate.RulesForPath(exp.Path)}
+
+	switch explainOutputFormat {
+	case "json":
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(result)
+	case "text":
+		t := newTable()
+		t.Rowf("Path:\t%s", result.Path)
+		t.Rowf("Kustomization:\t%s", yesNo(result.HasKustomization))
+		t.Rowf("Pattern:\t%s", orNone(result.Pattern, result.PatternGroup))
+		t.Rowf("Cluster:\t%s", orNone(result.Cluster, ""))
+		t.Rowf("Environment:\t%s", orNone(result.Environment, ""))
+		t.Rowf("Applications:\t%s", orNone(strings.Join(result.Applications, ", "), ""))
+		t.Rowf("Rules:\t%s", strings.Join(result.Rules, ", "))
+		t.Rowf("Rendered:\t%s (%s)", yesNo(result.Rendered), result.Reason)
+		for _, output := range result.OutputPaths {
+			t.Rowf("Output:\t%s", output)
+		}
+		return t.Render(os.Stdout)
+	default:
+		return fmt.Errorf("unknown output format: %s", explainOutputFormat)
+	}
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
+
+// orNone returns value (with an optional parenthesized detail) or "(none)"
+func orNone(value, detail string) string {
+	if value == "" {
+		return "(none)"
+	}
+	if detail != "" {
+		return fmt.Sprintf("%s (%s)", value, detail)
+	}
+	return value
+}
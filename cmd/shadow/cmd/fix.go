@@ -0,0 +1,145 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/erauner/homelab-shadow/pkg/shadow"
+	"github.com/erauner/homelab-shadow/pkg/validate"
+	"github.com/spf13/cobra"
+)
+
+var (
+	fixRules        string
+	fixDryRun       bool
+	fixOutputFormat string
+)
+
+var fixCmd = &cobra.Command{
+	Use:   "fix",
+	Short: "Rewrite files to resolve findings that have a mechanical fix",
+	Long: `Runs the validate rules that have an automatic fix and rewrites the
+kustomization.yaml and Application manifests their findings point at, in place,
+then prints a summary of the edits:
+
+  - app-overlay-missing-base, <root>-overlay-base-ref:
+      add the overlay's base (../../base, or ../../../base for a
+      cluster-layered app overlay) to the front of resources
+  - app-overlay-wrong-base-ref:
+      re-point ../base references at the right depth
+  - argocd-app-legacy-path:
+      re-point apps/<app>/overlays/<env> at apps/<app>/overlays/<cluster>/<env>
+      when that overlay exists for exactly one cluster (or for the
+      Application's destination cluster)
+
+Edits keep the rest of each file, comments included, as it was. Findings
+suppressed in .shadow.yaml or with shadow:ignore are left alone, as are
+findings without a safe fix (e.g. no base directory exists, or the cluster
+overlay hasn't been created yet); those are listed with the reason.
+
+Examples:
+  shadow fix --repo . --dry-run
+  shadow fix --repo .
+  shadow fix --repo . --rule argocd-app-legacy-path
+  shadow fix --repo . --rule 'app-overlay-*' --output json`,
+	RunE: runFix,
+}
+
+func init() {
+	rootCmd.AddCommand(fixCmd)
+
+	fixCmd.Flags().StringVar(&fixRules, "rule", "", "Fix only rules matching these comma-separated globs (default: every fixable rule)")
+	fixCmd.Flags().BoolVar(&fixDryRun, "dry-run", false, "Print the edits without writing them")
+	fixCmd.Flags().StringVarP(&fixOutputFormat, "output", "o", "table", "Output format: table, json")
+}
+
+func runFix(cmd *cobra.Command, args []string) error {
+	if fixOutputFormat != "table" && fixOutputFormat != "json" {
+		return fmt.Errorf("unknown output format: %s", fixOutputFormat)
+	}
+	rules, err := fixableRules(splitList(fixRules))
+	if err != nil {
+		return err
+	}
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	result, err := shadow.Validate(cmd.Context(), shadow.ValidateOptions{
+		RepoPath: repoDir,
+		Config:   cfg,
+		Rules:    validate.RuleSelector{Only: rules},
+		Verbose:  verbose,
+	})
+	if err != nil {
+		return err
+	}
+	report, err := validate.Fix(repoDir, result.Findings, validate.FixOptions{
+		Clusters: result.Clusters,
+		DryRun:   fixDryRun,
+	})
+	if err != nil {
+		return err
+	}
+
+	if fixOutputFormat == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(report)
+	}
+	printFixReport(report)
+	return nil
+}
+
+// fixableRules returns the fixable rules matching patterns (all without any)
+func fixableRules(patterns []string) ([]string, error) {
+	fixable := validate.FixableRules()
+	selector := validate.RuleSelector{Only: patterns}
+	if err := selector.Check(fixable); err != nil {
+		return nil, fmt.Errorf("%w (fixable rules: %s)", err, strings.Join(fixable, ", "))
+	}
+	var rules []string
+	for _, rule := range fixable {
+		if selector.Selects(rule) {
+			rules = append(rules, rule)
+		}
+	}
+	return rules, nil
+}
+
+// printFixReport prints the edits and the findings left unfixed
+func printFixReport(report validate.FixReport) {
+	verb := "Made"
+	if fixDryRun {
+		verb = "Would make"
+	}
+	if len(report.Edits) == 0 && len(report.Unfixed) == 0 {
+		fmt.Println("\n✅ Nothing to fix")
+		return
+	}
+
+	if len(report.Edits) > 0 {
+		t := newTable("RULE", "PATH", "CHANGE")
+		for _, e := range report.Edits {
+			t.Row(e.Rule, e.Path, e.Change)
+		}
+		fmt.Println()
+		t.Render(os.Stdout)
+	}
+	if len(report.Unfixed) > 0 {
+		fmt.Printf("\n=== Not fixed (%d finding(s)) ===\n", len(report.Unfixed))
+		t := newTable("RULE", "PATH", "REASON")
+		for _, u := range report.Unfixed {
+			t.Row(u.Rule, u.Path, u.Reason)
+		}
+		t.Render(os.Stdout)
+	}
+
+	fmt.Printf("\n%s %d edit(s) to %d file(s); %d finding(s) not fixed\n", verb, len(report.Edits), report.Files(), len(report.Unfixed))
+	if fixDryRun && len(report.Edits) > 0 {
+		fmt.Println("(dry run: no files were changed; rerun without --dry-run to apply)")
+	}
+}
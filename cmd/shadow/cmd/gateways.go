@@ -0,0 +1,120 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/erauner/homelab-shadow/pkg/cluster"
+	"github.com/erauner/homelab-shadow/pkg/sync"
+	"github.com/erauner/homelab-shadow/pkg/validate"
+	"github.com/spf13/cobra"
+)
+
+var (
+	gatewaysCluster      string
+	gatewaysOutputFormat string
+	gatewaysRendered     string
+	gatewaysEngine       string
+)
+
+var gatewaysCmd = &cobra.Command{
+	Use:   "gateways",
+	Short: "Cross-check HTTPRoutes against the Gateways and Certificates of each cluster",
+	Long: `Renders every deployable kustomization (the same set sync publishes),
+collects the Gateways, HTTPRoutes, and cert-manager Certificates per cluster,
+and checks the routes against them:
+
+  - route-parent-missing:          a parentRef names a Gateway no overlay renders
+                                   for the cluster (error)
+  - route-listener-missing:        a parentRef's sectionName or port matches no
+                                   listener of the Gateway (error)
+  - route-hostname-duplicate:      a hostname is claimed by more than one
+                                   HTTPRoute (warning)
+  - route-hostname-no-certificate: no Certificate dnsName covers a route
+                                   hostname (warning)
+
+Certificate coverage is only checked for clusters that render a Certificate;
+TLS listeners of Gateways annotated with cert-manager.io/issuer or
+cert-manager.io/cluster-issuer count as certificates for their hostname.
+Directories without a cluster are only compared with each other. Change
+severities per rule in .shadow.yaml.
+
+Use --rendered to check an existing rendered tree (e.g. a shadow repo
+checkout's rendered/ directory) instead of building.
+
+Examples:
+  shadow gateways --repo /path/to/homelab-k8s
+  shadow gateways --repo . --cluster erauner-home
+  shadow gateways --rendered ../homelab-k8s-shadow/rendered --output json`,
+	RunE: runGateways,
+}
+
+func init() {
+	rootCmd.AddCommand(gatewaysCmd)
+
+	gatewaysCmd.Flags().StringVarP(&gatewaysCluster, "cluster", "c", "", "Check only this cluster")
+	gatewaysCmd.Flags().StringVarP(&gatewaysOutputFormat, "output", "o", "table", "Output format: table, json, markdown")
+	gatewaysCmd.Flags().StringVar(&gatewaysRendered, "rendered", "", "Check manifest.yaml files under this directory instead of building")
+	gatewaysCmd.Flags().StringVar(&gatewaysEngine, "kustomize-engine", "exec", "Kustomize build engine: exec, krusty (in-process), or auto")
+	gatewaysCmd.Flags().BoolVar(&strict, "strict", false, "Treat warnings as errors")
+	gatewaysCmd.Flags().BoolVar(&showSuppressed, "show-suppressed", false, "Also list findings suppressed by config or shadow:ignore comments")
+}
+
+func runGateways(cmd *cobra.Command, args []string) error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	registry, err := cluster.Load(repoDir)
+	if err != nil {
+		return err
+	}
+
+	var clusters []string
+	if gatewaysCluster != "" {
+		clusters = []string{gatewaysCluster}
+	}
+
+	allResults := []validate.Result{} // Initialize to empty slice for JSON output
+	var manifests map[string]string
+	if gatewaysRendered != "" {
+		manifests, err = readRenderedManifests(gatewaysRendered)
+	} else {
+		manifests, allResults, err = buildManifests(clusters, gatewaysEngine)
+	}
+	if err != nil {
+		return err
+	}
+
+	for dir, manifest := range manifests {
+		name := sync.ClusterForDirectory(dir)
+		if gatewaysCluster != "" && name != "" && name != gatewaysCluster {
+			delete(manifests, dir)
+			continue
+		}
+		// Builds still carry ${VAR} placeholders; unresolved ones are skipped
+		manifests[dir], _ = registry.Substitute(name, manifest)
+	}
+
+	results, err := validate.ValidateGatewayRefs(manifests, sync.ClusterForDirectory)
+	if err != nil {
+		return err
+	}
+	allResults = append(allResults, results...)
+	logInfo("Cross-checked Gateway API resources in %d manifest(s)", len(manifests))
+
+	allResults = validate.ApplySeverities(allResults, cfg)
+	allResults = applyStrictness(allResults, cfg)
+	validate.ApplyInlineSuppressions(allResults, repoDir)
+	validate.AssignOwners(allResults, cfg)
+
+	switch gatewaysOutputFormat {
+	case "json":
+		return outputJSON(allResults)
+	case "table":
+		return outputTable(allResults)
+	case "markdown":
+		return outputMarkdown(allResults)
+	default:
+		return fmt.Errorf("unknown output format: %s", gatewaysOutputFormat)
+	}
+}
@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/erauner/homelab-shadow/pkg/graph"
+	"github.com/spf13/cobra"
+)
+
+var (
+	graphCluster      string
+	graphApp          string
+	graphPath         string
+	graphOutputFormat string
+)
+
+var graphCmd = &cobra.Command{
+	Use:   "graph",
+	Short: "Graph Applications, the directories they deploy, and their Helm charts",
+	Long: `Builds a dependency graph from every ArgoCD Application (including
+ApplicationSet-generated ones) and Flux Kustomization/HelmRelease: each
+Application links to its source paths and charts, each kustomization to the
+local directories in its resources, bases, and components, and to the charts
+in its helmCharts.
+
+Use --path to see the blast radius of a change to a shared base: only the
+Applications and directories depending on it, and what it references itself,
+are kept.
+
+The graph is printed as Graphviz DOT, a Mermaid flowchart (renders in GitHub
+markdown), or JSON.
+
+Examples:
+  shadow graph --repo . | dot -Tsvg > graph.svg
+  shadow graph --cluster erauner-home --output mermaid
+  shadow graph --app coder
+  shadow graph --path apps/coder/base`,
+	RunE: runGraph,
+}
+
+func init() {
+	rootCmd.AddCommand(graphCmd)
+
+	graphCmd.Flags().StringVarP(&graphCluster, "cluster", "c", "", "Only Applications deploying to this cluster")
+	graphCmd.Flags().StringVar(&graphApp, "app", "", "Only this Application, or Applications deploying apps/<app>/")
+	graphCmd.Flags().StringVar(&graphPath, "path", "", "Only what depends on or is referenced by this repo directory")
+	graphCmd.Flags().StringVarP(&graphOutputFormat, "output", "o", "dot", "Output format: dot, mermaid, json")
+}
+
+func runGraph(cmd *cobra.Command, args []string) error {
+	switch graphOutputFormat {
+	case "dot", "mermaid", "json":
+	default:
+		return fmt.Errorf("unknown output format: %s", graphOutputFormat)
+	}
+
+	g, err := graph.Build(repoDir, graph.Options{Cluster: graphCluster, App: graphApp})
+	if err != nil {
+		return err
+	}
+	if graphPath != "" {
+		if g, err = g.Focus(graphPath); err != nil {
+			return err
+		}
+	}
+	logInfo("Graph has %d node(s) and %d edge(s)", len(g.Nodes), len(g.Edges))
+
+	switch graphOutputFormat {
+	case "json":
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(g); err != nil {
+			return fmt.Errorf("failed to encode JSON: %w", err)
+		}
+	case "mermaid":
+		fmt.Print(g.Mermaid())
+	default:
+		fmt.Print(g.DOT())
+	}
+	return nil
+}
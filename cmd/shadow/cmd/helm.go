@@ -4,12 +4,17 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"sort"
+	"strconv"
 	"strings"
-	"text/tabwriter"
 	"time"
 
 	"github.com/erauner/homelab-shadow/pkg/argocd"
+	"github.com/erauner/homelab-shadow/pkg/command"
+	"github.com/erauner/homelab-shadow/pkg/flux"
 	"github.com/erauner/homelab-shadow/pkg/helm"
+	"github.com/erauner/homelab-shadow/pkg/hint"
+	"github.com/erauner/homelab-shadow/pkg/output"
 	"github.com/erauner/homelab-shadow/pkg/sync"
 	"github.com/spf13/cobra"
 )
@@ -18,6 +23,13 @@ var (
 	helmOutputFormat string
 	helmRetries      int
 	helmRetryDelay   time.Duration
+	helmCacheDir     string
+	helmNoChartCache bool
+	helmCredsFile    string
+	helmDiffTo       string
+	helmPrerelease   bool
+	helmOnlyMajor    bool
+	helmOnlyMinor    bool
 )
 
 var helmCmd = &cobra.Command{
@@ -37,7 +49,9 @@ Examples:
   shadow helm list --output json
   shadow helm test
   shadow helm test jenkins
-  shadow helm test --retries 3`,
+  shadow helm test --retries 3
+  shadow helm diff jenkins
+  shadow helm outdated`,
 }
 
 var helmListCmd = &cobra.Command{
@@ -74,23 +88,106 @@ The test:
 
 Supports retries for transient network issues.
 
+Private repositories and OCI registries are authenticated with the most
+specific credential matching the repo URL, from --creds-file, ArgoCD
+repository/repo-creds Secrets committed in plain text, or
+SHADOW_HELM_USERNAME/SHADOW_HELM_PASSWORD (scoped with SHADOW_HELM_REPO_URL).
+A --creds-file lists url, username, and password (or usernameEnv/passwordEnv)
+under "credentials". OCI registries are logged in with a temporary registry
+config, so ~/.config/helm is never touched.
+
 Examples:
   shadow helm test
   shadow helm test jenkins
   shadow helm test --retries 3 --retry-delay 5s
-  shadow helm test envoy-gateway -v`,
+  shadow helm test envoy-gateway -v
+  shadow helm test --no-chart-cache
+  shadow helm test --creds-file ~/.config/shadow/helm-creds.yaml`,
 	RunE: runHelmTest,
 }
 
+var helmDiffCmd = &cobra.Command{
+	Use:   "diff <app-name>",
+	Short: "Diff an app's chart at its pinned version against the latest",
+	Long: `Render an Application's Helm chart at the version pinned in its
+targetRevision and at the latest version in the chart repository (or
+--to), with the same values, and show what changes resource by resource.
+
+Use it to review a chart bump before Renovate opens the PR. The latest
+version comes from the repository's index.yaml, or the registry's tags for
+OCI charts; prereleases are skipped unless --prerelease is set.
+Credentials are found as for shadow helm test.
+
+Examples:
+  shadow helm diff jenkins
+  shadow helm diff jenkins --to 5.8.0
+  shadow helm diff envoy-gateway --prerelease -o json`,
+	Args: cobra.ExactArgs(1),
+	RunE: runHelmDiff,
+}
+
+var helmOutdatedCmd = &cobra.Command{
+	Use:   "outdated",
+	Short: "List Helm apps whose chart version is behind the latest release",
+	Long: `Query each Helm source's chart repository index (or OCI registry tags)
+and list the apps whose targetRevision is behind the latest stable release.
+
+A targetRevision that is a range (1.2.*, ^1.2.0, >=1.0 <2.0) resolves to the
+newest release it allows, and is only reported when the latest release falls
+outside it. Prereleases are never reported as updates.
+
+--only-major lists apps with a new major version; --only-minor lists apps with
+a newer minor version within their current major, whatever the latest major.
+Repositories that can't be queried are reported and make the command fail.
+Credentials are found as for shadow helm test.
+
+Examples:
+  shadow helm outdated
+  shadow helm outdated --only-minor
+  shadow helm outdated -o json`,
+	RunE: runHelmOutdated,
+}
+
 func init() {
 	rootCmd.AddCommand(helmCmd)
 	helmCmd.AddCommand(helmListCmd)
 	helmCmd.AddCommand(helmTestCmd)
+	helmCmd.AddCommand(helmDiffCmd)
+	helmCmd.AddCommand(helmOutdatedCmd)
 
-	helmListCmd.Flags().StringVarP(&helmOutputFormat, "output", "o", "text", "Output format: text, json")
-	helmTestCmd.Flags().StringVarP(&helmOutputFormat, "output", "o", "text", "Output format: text, json")
+	helmListCmd.Flags().StringVarP(&helmOutputFormat, "output", "o", "text", "Output format: text, json, markdown, template='{{...}}', or template-file=<path>")
+	helmTestCmd.Flags().StringVarP(&helmOutputFormat, "output", "o", "text", "Output format: text, json, markdown, template='{{...}}', or template-file=<path>")
 	helmTestCmd.Flags().IntVar(&helmRetries, "retries", 0, "Number of retries for transient failures")
 	helmTestCmd.Flags().DurationVar(&helmRetryDelay, "retry-delay", 2*time.Second, "Delay between retries")
+	helmTestCmd.Flags().StringVar(&helmCacheDir, "cache-dir", helm.DefaultCacheDir(), "Chart cache directory")
+	helmTestCmd.Flags().StringVar(&helmCredsFile, "creds-file", "", "YAML file of credentials for private Helm repositories and OCI registries")
+
+	helmDiffCmd.Flags().StringVarP(&helmOutputFormat, "output", "o", "text", "Output format: text, json")
+	helmDiffCmd.Flags().StringVar(&helmDiffTo, "to", "", "Chart version to compare against (default: latest in the repository)")
+	helmDiffCmd.Flags().BoolVar(&helmPrerelease, "prerelease", false, "Consider prerelease versions when finding the latest")
+	helmDiffCmd.Flags().StringVar(&helmCacheDir, "cache-dir", helm.DefaultCacheDir(), "Chart cache directory")
+	helmDiffCmd.Flags().StringVar(&helmCredsFile, "creds-file", "", "YAML file of credentials for private Helm repositories and OCI registries")
+
+	for _, c := range []*cobra.Command{helmTestCmd, helmDiffCmd} {
+		c.Flags().BoolVar(&helmNoChartCache, "no-chart-cache", false, "Always download charts instead of using the chart cache")
+		// Hidden alias for scripts using the old name, which hook uses for its result cache
+		c.Flags().BoolVar(&helmNoChartCache, "no-cache", false, "Alias for --no-chart-cache")
+		_ = c.Flags().MarkHidden("no-cache")
+	}
+
+	helmOutdatedCmd.Flags().StringVarP(&helmOutputFormat, "output", "o", "text", "Output format: text, json")
+	helmOutdatedCmd.Flags().BoolVar(&helmOnlyMajor, "only-major", false, "Only list apps with a new major version")
+	helmOutdatedCmd.Flags().BoolVar(&helmOnlyMinor, "only-minor", false, "Only list apps with a newer minor version within their major")
+	helmOutdatedCmd.Flags().StringVar(&helmCredsFile, "creds-file", "", "YAML file of credentials for private Helm repositories and OCI registries")
+	helmOutdatedCmd.MarkFlagsMutuallyExclusive("only-major", "only-minor")
+}
+
+// chartCacheDir returns the chart cache directory, or "" when caching is disabled
+func chartCacheDir(dir string, disabled bool) string {
+	if disabled {
+		return ""
+	}
+	return dir
 }
 
 // HelmAppInfo contains information about a Helm application for listing/testing
@@ -122,17 +219,30 @@ type HelmTestResult struct {
 	Duration time.Duration `json:"duration"`
 	Bytes    int           `json:"bytes,omitempty"`
 	Command  string        `json:"command,omitempty"`
+	Creds    string        `json:"credentials,omitempty"` // where the repo credential came from
 	Error    string        `json:"error,omitempty"`
 	Retries  int           `json:"retries,omitempty"`
 }
 
+// HelmTestSummary is the output of helm test
+type HelmTestSummary struct {
+	Passed  int              `json:"passed"`
+	Failed  int              `json:"failed"`
+	Results []HelmTestResult `json:"results"`
+}
+
 func runHelmList(cmd *cobra.Command, args []string) error {
+	format, err := output.Parse(helmOutputFormat, "text", "json", "markdown")
+	if err != nil {
+		return err
+	}
+
 	// Check if helm is installed
 	if !helm.IsHelmInstalled() {
-		return fmt.Errorf("helm CLI is not installed")
+		return fmt.Errorf("helm CLI is %w", command.ErrNotInstalled)
 	}
 
-	helmApps, err := argocd.DiscoverHelmApplications(repoDir)
+	helmApps, err := flux.DiscoverAllHelmApplications(repoDir)
 	if err != nil {
 		return fmt.Errorf("failed to discover Helm applications: %w", err)
 	}
@@ -149,11 +259,11 @@ func runHelmList(cmd *cobra.Command, args []string) error {
 
 		for _, source := range app.GetHelmSources() {
 			sourceInfo := HelmSourceInfo{
-				RepoURL:     source.RepoURL,
-				Chart:       source.Chart,
-				Version:     source.TargetRevision,
-				IsOCI:       sync.IsOCIRegistry(source.RepoURL),
-				InlineValues: source.Helm != nil && source.Helm.Values != "",
+				RepoURL:      source.RepoURL,
+				Chart:        source.Chart,
+				Version:      source.TargetRevision,
+				IsOCI:        sync.IsOCIRegistry(source.RepoURL),
+				InlineValues: source.Helm != nil && (source.Helm.Values != "" || len(source.Helm.ValuesObject) > 0),
 			}
 
 			if source.Helm != nil {
@@ -184,16 +294,32 @@ func runHelmList(cmd *cobra.Command, args []string) error {
 	}
 
 	// Output
-	switch helmOutputFormat {
+	switch format.Name {
 	case "json":
 		encoder := json.NewEncoder(os.Stdout)
 		encoder.SetIndent("", "  ")
 		return encoder.Encode(appInfos)
 
+	case "template":
+		return format.Execute(os.Stdout, appInfos)
+
+	case "markdown":
+		fmt.Printf("## Helm Applications (%d)\n\n", len(appInfos))
+		fmt.Println("| App | Namespace | Chart | Version | OCI |")
+		fmt.Println("|-----|-----------|-------|---------|-----|")
+		for _, app := range appInfos {
+			for _, src := range app.Sources {
+				oci := ""
+				if src.IsOCI {
+					oci = "✓"
+				}
+				fmt.Printf("| %s | %s | %s | %s | %s |\n", app.Name, app.Namespace, src.Chart, src.Version, oci)
+			}
+		}
+		return nil
+
 	case "text":
-		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
-		fmt.Fprintf(w, "APP\tNAMESPACE\tCHART\tVERSION\tOCI\tVALUES\n")
-		fmt.Fprintf(w, "---\t---------\t-----\t-------\t---\t------\n")
+		t := newTable("APP", "NAMESPACE", "CHART", "VERSION", "OCI", "VALUES")
 
 		for _, app := range appInfos {
 			for _, src := range app.Sources {
@@ -207,11 +333,10 @@ func runHelmList(cmd *cobra.Command, args []string) error {
 				if errCount > 0 {
 					valueStatus = fmt.Sprintf("%d files (%d errors)", valueCount, errCount)
 				}
-				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
-					app.Name, app.Namespace, src.Chart, src.Version, ociMarker, valueStatus)
+				t.Row(app.Name, app.Namespace, src.Chart, src.Version, ociMarker, valueStatus)
 			}
 		}
-		w.Flush()
+		t.Render(os.Stdout)
 
 		fmt.Printf("\nTotal: %d Helm applications\n", len(appInfos))
 		return nil
@@ -222,19 +347,30 @@ func runHelmList(cmd *cobra.Command, args []string) error {
 }
 
 func runHelmTest(cmd *cobra.Command, args []string) error {
+	format, err := output.Parse(helmOutputFormat, "text", "json", "markdown")
+	if err != nil {
+		return err
+	}
+
 	// Check if helm is installed
 	if !helm.IsHelmInstalled() {
-		return fmt.Errorf("helm CLI is not installed")
+		return fmt.Errorf("helm CLI is %w", command.ErrNotInstalled)
 	}
 
 	version, _ := helm.HelmVersion()
 	logInfo("Using: helm %s", version)
 
-	helmApps, err := argocd.DiscoverHelmApplications(repoDir)
+	helmApps, err := flux.DiscoverAllHelmApplications(repoDir)
 	if err != nil {
 		return fmt.Errorf("failed to discover Helm applications: %w", err)
 	}
 
+	creds, err := sync.HelmCredentials(repoDir, helmCredsFile)
+	if err != nil {
+		return err
+	}
+	logVerbose("Loaded %d Helm repository credential(s)", creds.Len())
+
 	// Filter to specific app if provided
 	var targetApp string
 	if len(args) > 0 {
@@ -251,7 +387,7 @@ func runHelmTest(cmd *cobra.Command, args []string) error {
 		}
 
 		for _, source := range app.GetHelmSources() {
-			result := testHelmSource(app, &source)
+			result := testHelmSource(app, &source, creds)
 			results = append(results, result)
 
 			if result.Passed {
@@ -268,15 +404,39 @@ func runHelmTest(cmd *cobra.Command, args []string) error {
 	}
 
 	// Output results
-	switch helmOutputFormat {
+	summary := HelmTestSummary{Passed: passed, Failed: failed, Results: results}
+	switch format.Name {
 	case "json":
 		encoder := json.NewEncoder(os.Stdout)
 		encoder.SetIndent("", "  ")
-		return encoder.Encode(map[string]interface{}{
-			"passed":  passed,
-			"failed":  failed,
-			"results": results,
-		})
+		return encoder.Encode(summary)
+
+	case "template":
+		if err := format.Execute(os.Stdout, summary); err != nil {
+			return err
+		}
+		if failed > 0 {
+			return fmt.Errorf("%d Helm chart(s) failed to render", failed)
+		}
+		return nil
+
+	case "markdown":
+		fmt.Printf("## Helm Chart Tests\n\n")
+		fmt.Printf("**Passed:** %d · **Failed:** %d\n\n", passed, failed)
+		fmt.Println("| App | Status | Duration | Error |")
+		fmt.Println("|-----|--------|----------|-------|")
+		for _, r := range results {
+			status := "✅"
+			if !r.Passed {
+				status = "❌"
+			}
+			errMsg := strings.ReplaceAll(strings.ReplaceAll(strings.TrimSpace(r.Error), "\n", " "), "|", "\\|")
+			fmt.Printf("| %s | %s | %s | %s |\n", r.Name, status, r.Duration.Round(time.Millisecond), errMsg)
+		}
+		if failed > 0 {
+			return fmt.Errorf("%d Helm chart(s) failed to render", failed)
+		}
+		return nil
 
 	case "text":
 		for _, r := range results {
@@ -287,6 +447,9 @@ func runHelmTest(cmd *cobra.Command, args []string) error {
 				// Show error details
 				if verbose {
 					fmt.Printf("  Command: %s\n", r.Command)
+					if r.Creds != "" {
+						fmt.Printf("  Credentials: %s\n", r.Creds)
+					}
 				}
 				// Truncate long errors
 				errMsg := r.Error
@@ -311,34 +474,22 @@ func runHelmTest(cmd *cobra.Command, args []string) error {
 	}
 }
 
-func testHelmSource(app *argocd.Application, source *argocd.Source) HelmTestResult {
+func testHelmSource(app *argocd.Application, source *argocd.Source, creds *helm.CredentialStore) HelmTestResult {
 	start := time.Now()
 	result := HelmTestResult{
 		Name: app.Name,
 	}
+	if cred := creds.Lookup(source.RepoURL); cred != nil {
+		result.Creds = cred.Source
+	}
 
-	// Resolve value files
-	var valueFiles []string
+	// Value files that don't resolve fail every attempt, so don't retry them
 	if source.Helm != nil && len(source.Helm.ValueFiles) > 0 {
-		resolved, err := argocd.ResolveValueFiles(source.Helm.ValueFiles, repoDir)
-		if err != nil {
+		if _, err := argocd.ResolveValueFiles(source.Helm.ValueFiles, repoDir); err != nil {
 			result.Duration = time.Since(start)
 			result.Error = fmt.Sprintf("failed to resolve value files: %v", err)
 			return result
 		}
-		valueFiles = resolved
-	}
-
-	// Get inline values
-	var inlineValues string
-	if source.Helm != nil && source.Helm.Values != "" {
-		inlineValues = source.Helm.Values
-	}
-
-	// Get release name
-	releaseName := app.Name
-	if source.Helm != nil && source.Helm.ReleaseName != "" {
-		releaseName = source.Helm.ReleaseName
 	}
 
 	// Attempt rendering with retries
@@ -350,30 +501,12 @@ func testHelmSource(app *argocd.Application, source *argocd.Source) HelmTestResu
 			result.Retries = attempt
 		}
 
-		if sync.IsOCIRegistry(source.RepoURL) {
-			ociURL := sync.NormalizeOCIURL(source.RepoURL)
-			helmResult = helm.Template(helm.TemplateOptions{
-				ReleaseName:  releaseName,
-				Namespace:    app.Namespace,
-				RepoURL:      "",
-				Chart:        ociURL + "/" + source.Chart,
-				Version:      source.TargetRevision,
-				ValueFiles:   valueFiles,
-				InlineValues: inlineValues,
-				Verbose:      verbose,
-			})
-		} else {
-			helmResult = helm.Template(helm.TemplateOptions{
-				ReleaseName:  releaseName,
-				Namespace:    app.Namespace,
-				RepoURL:      source.RepoURL,
-				Chart:        source.Chart,
-				Version:      source.TargetRevision,
-				ValueFiles:   valueFiles,
-				InlineValues: inlineValues,
-				Verbose:      verbose,
-			})
-		}
+		helmResult = sync.RenderHelmSource(app, source, sync.HelmRenderOptions{
+			RepoPath:    repoDir,
+			CacheDir:    chartCacheDir(helmCacheDir, helmNoChartCache),
+			Verbose:     verbose,
+			Credentials: creds,
+		})
 
 		result.Command = helmResult.Command
 
@@ -423,3 +556,207 @@ func isRetryableError(err error) bool {
 	}
 	return false
 }
+
+func runHelmDiff(cmd *cobra.Command, args []string) error {
+	if !helm.IsHelmInstalled() {
+		return fmt.Errorf("helm CLI is %w", command.ErrNotInstalled)
+	}
+
+	helmApps, err := flux.DiscoverAllHelmApplications(repoDir)
+	if err != nil {
+		return fmt.Errorf("failed to discover Helm applications: %w", err)
+	}
+	creds, err := sync.HelmCredentials(repoDir, helmCredsFile)
+	if err != nil {
+		return err
+	}
+
+	var diffs []*sync.HelmVersionDiff
+	for _, app := range helmApps {
+		if app.Name != args[0] {
+			continue
+		}
+		for _, source := range app.GetHelmSources() {
+			version := helmDiffTo
+			if version == "" {
+				if version, err = sync.LatestHelmVersion(&source, creds, helmPrerelease); err != nil {
+					return fmt.Errorf("failed to find the latest version of %s: %w", source.Chart, err)
+				}
+			}
+			logInfo("Diffing %s %s -> %s...", source.Chart, source.TargetRevision, version)
+
+			diff, err := sync.DiffHelmSource(app, &source, version, sync.HelmRenderOptions{
+				RepoPath:    repoDir,
+				CacheDir:    chartCacheDir(helmCacheDir, helmNoChartCache),
+				Verbose:     verbose,
+				Credentials: creds,
+			})
+			if err != nil {
+				return err
+			}
+			diffs = append(diffs, diff)
+		}
+	}
+	if len(diffs) == 0 {
+		return fmt.Errorf("application not found: %s", args[0])
+	}
+
+	switch helmOutputFormat {
+	case "json":
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(diffs)
+	case "text":
+		for _, d := range diffs {
+			printHelmVersionDiff(d)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown output format: %s", helmOutputFormat)
+	}
+}
+
+func printHelmVersionDiff(d *sync.HelmVersionDiff) {
+	fmt.Printf("%s: %s %s -> %s (%s)\n", d.App, d.Chart, d.Pinned, d.Target, d.Diff)
+	if d.Pinned == d.Target {
+		fmt.Printf("  already at %s\n", d.Target)
+		return
+	}
+	if d.Diff.Empty() {
+		fmt.Printf("  no rendered changes\n")
+		return
+	}
+	for _, id := range d.Diff.Added {
+		fmt.Printf("  + %s\n", id)
+	}
+	for _, id := range d.Diff.Removed {
+		fmt.Printf("  - %s\n", id)
+	}
+	for _, id := range d.Diff.Changed {
+		fmt.Printf("  ~ %s\n", id)
+		for _, f := range d.Diff.Fields[id] {
+			fmt.Printf("      %s: %s -> %s\n", f.Path, formatFieldValue(f.Before), formatFieldValue(f.After))
+		}
+	}
+}
+
+// formatFieldValue shows a field value on one line; missing fields are <none>
+func formatFieldValue(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return "<none>"
+	case string:
+		return strconv.Quote(v)
+	case map[string]interface{}, []interface{}:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		return string(data)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// HelmOutdatedResult is one Helm source's chart version status
+type HelmOutdatedResult struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+	RepoURL   string `json:"repo_url"`
+	Chart     string `json:"chart"`
+	helm.VersionStatus
+	Error string `json:"error,omitempty"`
+}
+
+func runHelmOutdated(cmd *cobra.Command, args []string) error {
+	helmApps, err := flux.DiscoverAllHelmApplications(repoDir)
+	if err != nil {
+		return fmt.Errorf("failed to discover Helm applications: %w", err)
+	}
+	creds, err := sync.HelmCredentials(repoDir, helmCredsFile)
+	if err != nil {
+		return err
+	}
+
+	// Apps often share a chart; query each repository once
+	type versionList struct {
+		versions []string
+		err      error
+	}
+	versionCache := make(map[string]versionList)
+
+	outdated := []HelmOutdatedResult{} // Initialize to empty slice for JSON output
+	var failures []HelmOutdatedResult
+	checked := 0
+	for _, app := range helmApps {
+		for _, source := range app.GetHelmSources() {
+			checked++
+			result := HelmOutdatedResult{Name: app.Name, Namespace: app.Namespace, RepoURL: source.RepoURL, Chart: source.Chart}
+
+			key := source.RepoURL + "\n" + source.Chart
+			list, ok := versionCache[key]
+			if !ok {
+				logVerbose("Querying versions of %s in %s", source.Chart, source.RepoURL)
+				list.versions, list.err = sync.HelmChartVersions(&source, creds)
+				versionCache[key] = list
+			}
+			if list.err == nil {
+				result.VersionStatus, err = helm.CheckVersion(source.TargetRevision, list.versions)
+			} else {
+				err = list.err
+			}
+			if err != nil {
+				result.TargetRevision = source.TargetRevision
+				result.Error = err.Error()
+				failures = append(failures, result)
+				continue
+			}
+
+			switch {
+			case helmOnlyMajor && result.Update != helm.UpdateMajor:
+			case helmOnlyMinor && result.UpdateInMajor != helm.UpdateMinor:
+			case !helmOnlyMajor && !helmOnlyMinor && !result.Outdated():
+			default:
+				outdated = append(outdated, result)
+			}
+		}
+	}
+	sort.Slice(outdated, func(i, j int) bool { return outdated[i].Name < outdated[j].Name })
+
+	switch helmOutputFormat {
+	case "json":
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(map[string]interface{}{
+			"checked":  checked,
+			"outdated": outdated,
+			"errors":   failures,
+		}); err != nil {
+			return err
+		}
+
+	case "text":
+		if len(outdated) > 0 {
+			t := newTable("APP", "CHART", "TARGET", "CURRENT", "LATEST IN MAJOR", "LATEST", "UPDATE")
+			for _, r := range outdated {
+				t.Row(r.Name, r.Chart, r.TargetRevision, r.Current, r.LatestInMajor, r.Latest, dashIfEmpty(r.Update))
+			}
+			t.Render(os.Stdout)
+		}
+		for _, f := range failures {
+			fmt.Printf("✗ %s (%s in %s): %s\n", f.Name, f.Chart, f.RepoURL, f.Error)
+			if h := hint.For(f.Error); h != "" {
+				fmt.Printf("  hint: %s\n", h)
+			}
+		}
+		fmt.Printf("\nChecked %d Helm source(s): %d listed, %d failed\n", checked, len(outdated), len(failures))
+
+	default:
+		return fmt.Errorf("unknown output format: %s", helmOutputFormat)
+	}
+
+	if len(failures) > 0 {
+		return fmt.Errorf("%d Helm source(s) could not be checked", len(failures))
+	}
+	return nil
+}
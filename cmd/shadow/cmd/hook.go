@@ -0,0 +1,182 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/erauner/homelab-shadow/pkg/command"
+	"github.com/erauner/homelab-shadow/pkg/kustomize"
+	"github.com/spf13/cobra"
+)
+
+var (
+	hookEngine   string
+	hookHelm     bool
+	hookSchema   bool
+	hookCacheDir string
+	hookNoCache  bool
+)
+
+var hookCmd = &cobra.Command{
+	Use:   "hook [files...]",
+	Short: "Build the kustomizations owning staged files (pre-commit mode)",
+	Long: `Hook is a fast pre-commit check: it maps the given files (default: the files
+staged in --repo) to the nearest kustomization directory owning each, adds every
+discovered overlay that includes one through resources, bases, or components,
+and kustomize builds just those.
+
+Only the changed files' component (apps/<app>, infrastructure/<name>, ...) is
+searched for dependents, so the repo is never fully discovered or rendered.
+Files outside any kustomization are ignored.
+
+By default nothing touches the network: kustomizations that use helmCharts
+(directly or through what they include) are skipped unless --helm is set, and
+kubeconform schema validation only runs with --schema. Passing --schema results
+are cached under --cache-dir, keyed by a hash of every file the build reads, so
+unchanged overlays are not rebuilt.
+
+pre-commit (.pre-commit-config.yaml):
+  - repo: local
+    hooks:
+      - id: shadow
+        name: shadow hook
+        entry: shadow hook
+        language: system
+        pass_filenames: true
+
+lefthook (lefthook.yml):
+  pre-commit:
+    commands:
+      shadow:
+        run: shadow hook {staged_files}
+
+Examples:
+  shadow hook
+  shadow hook apps/coder/base/deployment.yaml
+  shadow hook --helm --schema`,
+	RunE: runHook,
+}
+
+func init() {
+	rootCmd.AddCommand(hookCmd)
+
+	hookCmd.Flags().StringVar(&hookEngine, "kustomize-engine", "exec", "Kustomize build engine: exec, krusty (in-process), or auto")
+	hookCmd.Flags().BoolVar(&hookHelm, "helm", false, "Also build kustomizations that inflate helmCharts (may fetch charts)")
+	hookCmd.Flags().BoolVar(&hookSchema, "schema", false, "Also validate built manifests with kubeconform")
+	hookCmd.Flags().StringVar(&hookCacheDir, "cache-dir", kustomize.DefaultResultCacheDir(), "Directory for cached --schema results")
+	hookCmd.Flags().BoolVar(&hookNoCache, "no-cache", false, "Always build and validate instead of reusing cached results")
+	addFailOnFlag(hookCmd, "error")
+}
+
+func runHook(cmd *cobra.Command, args []string) error {
+	start := time.Now()
+	engine, err := kustomize.ParseEngine(hookEngine)
+	if err != nil {
+		return err
+	}
+	if engine == kustomize.EngineExec && !kustomize.IsKustomizeInstalled() {
+		return fmt.Errorf("kustomize is %w (use --kustomize-engine)", command.ErrNotInstalled)
+	}
+	if hookSchema && !kustomize.IsKubeconformInstalled() {
+		return fmt.Errorf("kubeconform is %w (drop --schema)", command.ErrNotInstalled)
+	}
+
+	files := args
+	if len(files) == 0 {
+		if files, err = stagedFiles(repoDir); err != nil {
+			return err
+		}
+	}
+
+	runner := kustomize.NewRunner(repoDir, "", verbose)
+	runner.Engine = engine
+	affected, err := runner.AffectedDirectories(files)
+	if err != nil {
+		return err
+	}
+	if len(affected) == 0 {
+		logVerbose("No kustomization owns the %d changed file(s)", len(files))
+		return nil
+	}
+
+	if hookSchema && !hookNoCache {
+		runner.Cache = kustomize.NewResultCache(hookCacheDir, verbose)
+	}
+
+	failed, skipped, cached := 0, 0, 0
+	for _, a := range affected {
+		if a.UsesHelm && !hookHelm {
+			logVerbose("Skipping %s: uses helmCharts (use --helm)", a.Directory)
+			skipped++
+			continue
+		}
+
+		result := hookCheck(runner, a.Directory)
+		switch {
+		case result.Skipped:
+			logVerbose("Skipping %s: %s", a.Directory, result.SkipReason)
+			skipped++
+			continue
+		case !result.BuildPassed:
+			fmt.Printf("  ❌ %s: %s\n", a.Directory, buildFailure(result.BuildOutput, result.BuildError))
+			failed++
+			continue
+		case !result.SchemaPassed:
+			fmt.Printf("  ❌ %s: %s\n", a.Directory, kustomize.SummarizeSchemaErrors(result.SchemaOutput))
+			failed++
+			continue
+		}
+		if result.Cached {
+			cached++
+		}
+		fmt.Printf("  ✅ %s\n", a.Directory)
+	}
+
+	logVerbose("Checked %d kustomization(s) (%d cached, %d skipped) in %s", len(affected)-skipped, cached, skipped, time.Since(start).Round(time.Millisecond))
+	if failed > 0 {
+		return failFindings(fmt.Errorf("%d kustomization(s) failed", failed), nil)
+	}
+	return nil
+}
+
+// hookCheck builds dir, validating it with kubeconform (through the runner's
+// cache) only with --schema
+func hookCheck(runner *kustomize.Runner, dir string) kustomize.ValidationResult {
+	if hookSchema {
+		return runner.ValidateDirectory(dir)
+	}
+	build := runner.BuildDirectory(dir)
+	return kustomize.ValidationResult{
+		Directory:    dir,
+		BuildPassed:  build.Passed,
+		BuildOutput:  build.Output,
+		BuildError:   build.Error,
+		SchemaPassed: true,
+		Skipped:      build.Skipped,
+		SkipReason:   build.SkipReason,
+	}
+}
+
+// buildFailure extracts the kustomize error from a failed build's output
+func buildFailure(output string, err error) string {
+	if msg := kustomize.ExtractKustomizeBuildError(output); msg != "" {
+		return msg
+	}
+	return err.Error()
+}
+
+// stagedFiles lists files added, modified, renamed, or deleted in the index
+func stagedFiles(repo string) ([]string, error) {
+	out, err := command.Command("git", "-C", repo, "diff", "--cached", "--name-only", "--diff-filter=ACMRD", "-z").Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list staged files: %w", err)
+	}
+	var files []string
+	for _, f := range strings.Split(string(out), "\x00") {
+		if f != "" {
+			files = append(files, f)
+		}
+	}
+	return files, nil
+}
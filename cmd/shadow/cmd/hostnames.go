@@ -0,0 +1,121 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/erauner/homelab-shadow/pkg/cluster"
+	"github.com/erauner/homelab-shadow/pkg/sync"
+	"github.com/erauner/homelab-shadow/pkg/validate"
+	"github.com/spf13/cobra"
+)
+
+var (
+	hostnamesCluster      string
+	hostnamesOutputFormat string
+	hostnamesRendered     string
+	hostnamesEngine       string
+)
+
+var hostnamesCmd = &cobra.Command{
+	Use:   "hostnames",
+	Short: "Check rendered hostnames against each cluster's domains",
+	Long: `Renders every deployable kustomization (the same set sync publishes) and
+checks the hostnames of HTTPRoutes, GRPCRoutes, TLSRoutes, Gateway listeners,
+Ingresses, and cert-manager Certificates against the domains registered for the
+overlay's cluster in clusters.yaml:
+
+  clusters:
+    - name: erauner-home
+      domains: [home.erauner.dev]
+    - name: erauner-cloud
+      domains: [cloud.erauner.dev, erauner.dev]
+
+  - hostname-foreign-domain:  the hostname is under another cluster's domain,
+                              usually an overlay copied without updating it (error)
+  - hostname-outside-domains: the hostname is under none of the cluster's
+                              domains (warning)
+
+A hostname belongs to the cluster with the longest matching domain. Clusters
+without domains are not checked. Change severities per rule in .shadow.yaml.
+
+Use --rendered to check an existing rendered tree (e.g. a shadow repo
+checkout's rendered/ directory) instead of building.
+
+Examples:
+  shadow hostnames --repo /path/to/homelab-k8s
+  shadow hostnames --repo . --cluster erauner-home
+  shadow hostnames --rendered ../homelab-k8s-shadow/rendered --output json`,
+	RunE: runHostnames,
+}
+
+func init() {
+	rootCmd.AddCommand(hostnamesCmd)
+
+	hostnamesCmd.Flags().StringVarP(&hostnamesCluster, "cluster", "c", "", "Check only this cluster")
+	hostnamesCmd.Flags().StringVarP(&hostnamesOutputFormat, "output", "o", "table", "Output format: table, json, markdown")
+	hostnamesCmd.Flags().StringVar(&hostnamesRendered, "rendered", "", "Check manifest.yaml files under this directory instead of building")
+	hostnamesCmd.Flags().StringVar(&hostnamesEngine, "kustomize-engine", "exec", "Kustomize build engine: exec, krusty (in-process), or auto")
+	hostnamesCmd.Flags().BoolVar(&strict, "strict", false, "Treat warnings as errors")
+	hostnamesCmd.Flags().BoolVar(&showSuppressed, "show-suppressed", false, "Also list findings suppressed by config or shadow:ignore comments")
+}
+
+func runHostnames(cmd *cobra.Command, args []string) error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	registry, err := cluster.Load(repoDir)
+	if err != nil {
+		return err
+	}
+
+	var clusters []string
+	if hostnamesCluster != "" {
+		clusters = []string{hostnamesCluster}
+	}
+
+	allResults := []validate.Result{} // Initialize to empty slice for JSON output
+	var manifests map[string]string
+	if hostnamesRendered != "" {
+		manifests, err = readRenderedManifests(hostnamesRendered)
+	} else {
+		manifests, allResults, err = buildManifests(clusters, hostnamesEngine)
+	}
+	if err != nil {
+		return err
+	}
+
+	dirs := make([]string, 0, len(manifests))
+	for dir := range manifests {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+
+	for _, dir := range dirs {
+		name := sync.ClusterForDirectory(dir)
+		if hostnamesCluster != "" && name != "" && name != hostnamesCluster {
+			continue
+		}
+		// Builds still carry ${VAR} placeholders; unresolved ones are skipped
+		manifest, _ := registry.Substitute(name, manifests[dir])
+		allResults = append(allResults, validate.ValidateHostnames(registry, name, dir, manifest)...)
+	}
+	logInfo("Checked hostnames in %d manifest(s)", len(dirs))
+
+	allResults = validate.ApplySeverities(allResults, cfg)
+	allResults = applyStrictness(allResults, cfg)
+	validate.ApplyInlineSuppressions(allResults, repoDir)
+	validate.AssignOwners(allResults, cfg)
+
+	switch hostnamesOutputFormat {
+	case "json":
+		return outputJSON(allResults)
+	case "table":
+		return outputTable(allResults)
+	case "markdown":
+		return outputMarkdown(allResults)
+	default:
+		return fmt.Errorf("unknown output format: %s", hostnamesOutputFormat)
+	}
+}
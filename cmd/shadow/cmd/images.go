@@ -0,0 +1,215 @@
+package cmd
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/erauner/homelab-shadow/pkg/command"
+	"github.com/erauner/homelab-shadow/pkg/kustomize"
+	"github.com/erauner/homelab-shadow/pkg/log"
+	"github.com/erauner/homelab-shadow/pkg/progress"
+	"github.com/erauner/homelab-shadow/pkg/sync"
+	"github.com/erauner/homelab-shadow/pkg/validate"
+	"github.com/spf13/cobra"
+)
+
+var (
+	imagesCluster      string
+	imagesOutputFormat string
+	imagesRendered     string
+	imagesEngine       string
+)
+
+var imagesCmd = &cobra.Command{
+	Use:   "images",
+	Short: "Check container image tag pinning in rendered manifests",
+	Long: `Renders every deployable kustomization (the same set sync publishes) and
+flags containers whose images are not pinned:
+
+  - image-tag-missing:  no tag at all (implicitly latest)
+  - image-tag-latest:   the :latest tag
+  - image-tag-floating: tags that move between releases (stable, main, nightly, ...)
+
+Digest-pinned images (name@sha256:...) always pass. All findings are warnings
+by default; change severities per rule in .shadow.yaml:
+
+  rules:
+    image-tag-latest: error
+    image-tag-floating: off
+
+Use --rendered to check an existing rendered tree (e.g. a shadow repo
+checkout's rendered/ directory) instead of building.
+
+Examples:
+  shadow images --repo /path/to/homelab-k8s
+  shadow images --repo . --cluster erauner-home
+  shadow images --rendered ../homelab-k8s-shadow/rendered --output json`,
+	RunE: runImages,
+}
+
+func init() {
+	rootCmd.AddCommand(imagesCmd)
+
+	imagesCmd.Flags().StringVarP(&imagesCluster, "cluster", "c", "", "Check only this cluster")
+	imagesCmd.Flags().StringVarP(&imagesOutputFormat, "output", "o", "table", "Output format: table, json, markdown")
+	imagesCmd.Flags().StringVar(&imagesRendered, "rendered", "", "Check manifest.yaml files under this directory instead of building")
+	imagesCmd.Flags().StringVar(&imagesEngine, "kustomize-engine", "exec", "Kustomize build engine: exec, krusty (in-process), or auto")
+	imagesCmd.Flags().BoolVar(&strict, "strict", false, "Treat warnings as errors")
+	imagesCmd.Flags().BoolVar(&showSuppressed, "show-suppressed", false, "Also list findings suppressed by config or shadow:ignore comments")
+}
+
+func runImages(cmd *cobra.Command, args []string) error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	var clusters []string
+	if imagesCluster != "" {
+		clusters = []string{imagesCluster}
+	}
+
+	allResults := []validate.Result{} // Initialize to empty slice for JSON output
+	var manifests map[string]string
+	if imagesRendered != "" {
+		manifests, err = readRenderedManifests(imagesRendered)
+	} else {
+		manifests, allResults, err = buildManifests(clusters, imagesEngine)
+	}
+	if err != nil {
+		return err
+	}
+
+	dirs := make([]string, 0, len(manifests))
+	for dir := range manifests {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+
+	for _, dir := range dirs {
+		cluster := sync.ClusterForDirectory(dir)
+		if imagesCluster != "" && cluster != "" && cluster != imagesCluster {
+			continue
+		}
+		allResults = append(allResults, validate.ValidateImages(cluster, dir, manifests[dir])...)
+	}
+	logInfo("Checked images in %d manifest(s)", len(dirs))
+
+	allResults = validate.ApplySeverities(allResults, cfg)
+	allResults = applyStrictness(allResults, cfg)
+	validate.ApplyInlineSuppressions(allResults, repoDir)
+	validate.AssignOwners(allResults, cfg)
+
+	switch imagesOutputFormat {
+	case "json":
+		return outputJSON(allResults)
+	case "table":
+		return outputTable(allResults)
+	case "markdown":
+		return outputMarkdown(allResults)
+	default:
+		return fmt.Errorf("unknown output format: %s", imagesOutputFormat)
+	}
+}
+
+// buildManifests renders every sync-discovered kustomization
+// Build failures are returned as results so one broken overlay doesn't hide the rest
+func buildManifests(clusters []string, engineName string) (map[string]string, []validate.Result, error) {
+	engine, err := kustomize.ParseEngine(engineName)
+	if err != nil {
+		return nil, nil, err
+	}
+	if engine == kustomize.EngineExec && !kustomize.IsKustomizeInstalled() {
+		return nil, nil, fmt.Errorf("kustomize is %w (use --rendered or --kustomize-engine)", command.ErrNotInstalled)
+	}
+	runner := kustomize.NewRunner(repoDir, "", verbose)
+	runner.Engine = engine
+
+	dirs, err := sync.DiscoverKustomizationsForSync(repoDir, clusters)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to discover kustomizations: %w", err)
+	}
+	logInfo("Rendering %d kustomization(s)...", len(dirs))
+
+	options := sync.KustomizeSourceOptions(repoDir, log.Default())
+	var report *progress.Reporter
+	if showProgress() {
+		report = progress.New("render", len(dirs))
+		defer report.Finish()
+	}
+
+	manifests := make(map[string]string)
+	results := []validate.Result{}
+	for _, dir := range dirs {
+		report.Start(dir)
+		result := runner.BuildSource(dir, options[filepath.ToSlash(dir)])
+		report.Done(result.Skipped || result.Passed)
+		switch {
+		case result.Skipped:
+			logVerbose("Skipping %s: %s", dir, result.SkipReason)
+		case !result.Passed:
+			results = append(results, validate.Result{
+				Cluster:  sync.ClusterForDirectory(dir),
+				Rule:     "kustomize-build-fail",
+				Path:     dir,
+				Message:  fmt.Sprintf("Kustomize build failed: %s", kustomize.ExtractKustomizeBuildError(result.Output)),
+				Severity: "error",
+			})
+		default:
+			manifests[dir] = result.Output
+		}
+	}
+
+	return manifests, results, nil
+}
+
+// readRenderedManifests loads every manifest.yaml under root, keyed by
+// directory; directories written with --output-layout split have their
+// per-resource files joined into one manifest
+func readRenderedManifests(root string) (map[string]string, error) {
+	manifests := make(map[string]string)
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != root && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if filepath.Ext(d.Name()) != ".yaml" {
+			return nil
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		dir, err := filepath.Rel(root, filepath.Dir(path))
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(dir)
+		if existing, ok := manifests[key]; ok {
+			if !strings.HasSuffix(existing, "\n") {
+				existing += "\n"
+			}
+			manifests[key] = existing + "---\n" + string(data)
+		} else {
+			manifests[key] = string(data)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rendered manifests: %w", err)
+	}
+	if len(manifests) == 0 {
+		return nil, fmt.Errorf("no rendered manifests found under %s", root)
+	}
+	return manifests, nil
+}
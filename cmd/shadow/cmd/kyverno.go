@@ -1,17 +1,30 @@
 package cmd
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
+	"strconv"
 	"strings"
-	"text/tabwriter"
 
+	"github.com/erauner/homelab-shadow/pkg/command"
 	"github.com/erauner/homelab-shadow/pkg/kyverno"
+	"github.com/erauner/homelab-shadow/pkg/log"
+	"github.com/erauner/homelab-shadow/pkg/output"
+	"github.com/erauner/homelab-shadow/pkg/validate"
 	"github.com/spf13/cobra"
 )
 
 var (
 	kyvernoCheckCoverage bool
+	kyvernoTestCluster   string
+	kyvernoTestOutput    string
+
+	kyvernoImpactBase     string
+	kyvernoImpactRendered string
+	kyvernoImpactOutput   string
+	kyvernoImpactCluster  string
+	kyvernoImpactEngine   string
 )
 
 var kyvernoCmd = &cobra.Command{
@@ -36,24 +49,65 @@ var kyvernoTestCmd = &cobra.Command{
 Without arguments, runs all discovered policy tests.
 With a policy name, runs only that specific policy's tests.
 
+Tests are discovered under base/tests and overlays/<cluster>/tests of each
+policy root (policies/kyverno, or policies.kyverno in .shadow.yaml). With
+--cluster, only that cluster's overlay runs besides base.
+
 Examples:
   shadow kyverno test
   shadow kyverno test application-multi-source-ordering
+  shadow kyverno test --cluster erauner-home
   shadow kyverno test --coverage`,
 	RunE: runKyvernoTest,
 }
 
+var kyvernoImpactCmd = &cobra.Command{
+	Use:   "impact",
+	Short: "Report resources that newly violate changed Kyverno policies",
+	Long: `Report the blast radius of Kyverno policy changes.
+
+Every policy file under the cluster policy directories that differs between
+--base and the working tree is applied, at both versions, to the rendered
+manifests with kyverno apply. Resources that fail the changed policy only at
+head are new violations; resources that only failed at --base are resolved.
+
+The manifests are read from --rendered (a sync --dry-run output directory), or
+every sync-discovered kustomization is built first. sync --policy-impact
+writes the same report into the shadow repo.
+
+Examples:
+  shadow kyverno impact --base origin/main
+  shadow kyverno impact --base origin/main --rendered ./rendered-local
+  shadow kyverno impact --base origin/main --output markdown`,
+	RunE: runKyvernoImpact,
+}
+
 func init() {
 	rootCmd.AddCommand(kyvernoCmd)
 	kyvernoCmd.AddCommand(kyvernoTestCmd)
+	kyvernoCmd.AddCommand(kyvernoImpactCmd)
 
 	kyvernoTestCmd.Flags().BoolVar(&kyvernoCheckCoverage, "coverage", false, "Check test coverage and fail if policies are missing tests")
+	kyvernoTestCmd.Flags().StringVarP(&kyvernoTestCluster, "cluster", "c", "", "Only test base and this cluster's policy overlay")
+	addFailOnFlag(kyvernoTestCmd, "error")
+	kyvernoTestCmd.Flags().StringVarP(&kyvernoTestOutput, "output", "o", "text", "Output format: text, json, markdown, template='{{...}}', or template-file=<path>")
+
+	kyvernoImpactCmd.Flags().StringVar(&kyvernoImpactBase, "base", "origin/main", "Git ref to compare policies against")
+	kyvernoImpactCmd.Flags().StringVar(&kyvernoImpactRendered, "rendered", "", "Directory of rendered manifests (default: render the repo)")
+	kyvernoImpactCmd.Flags().StringVarP(&kyvernoImpactCluster, "cluster", "c", "", "Only this cluster's overlays and policies (nothing is rendered with --rendered)")
+	kyvernoImpactCmd.Flags().StringVar(&kyvernoImpactEngine, "kustomize-engine", "exec", "Kustomize build engine: exec, krusty (in-process), or auto")
+	kyvernoImpactCmd.Flags().StringVar(&kyvernoImpactOutput, "output", "text", "Output format: text, json, markdown, template='{{...}}', or template-file=<path>")
 }
 
 func runKyvernoTest(cmd *cobra.Command, args []string) error {
+	format, err := output.Parse(kyvernoTestOutput, "text", "json", "markdown")
+	if err != nil {
+		return err
+	}
+
 	// Check if kyverno is installed
 	if !kyverno.IsKyvernoInstalled() {
-		return fmt.Errorf("kyverno CLI is not installed\n  Install: brew install kyverno")
+		return fmt.Errorf("kyverno CLI is %w\n  Install: brew install kyverno", command.ErrNotInstalled)
 	}
 
 	// Print version
@@ -64,7 +118,10 @@ func runKyvernoTest(cmd *cobra.Command, args []string) error {
 		logInfo("Using: %s", version)
 	}
 
-	runner := kyverno.NewTestRunner(repoDir, verbose)
+	runner, err := newKyvernoRunner(kyvernoTestCluster)
+	if err != nil {
+		return err
+	}
 
 	// Check coverage if requested
 	if kyvernoCheckCoverage {
@@ -73,11 +130,11 @@ func runKyvernoTest(cmd *cobra.Command, args []string) error {
 
 	// Run specific test if provided
 	if len(args) > 0 {
-		return runSingleTest(runner, args[0])
+		return runSingleTest(runner, args[0], format)
 	}
 
 	// Run all tests
-	return runAllTests(runner)
+	return runAllTests(runner, format)
 }
 
 func runCoverageCheck(runner *kyverno.TestRunner) error {
@@ -88,55 +145,58 @@ func runCoverageCheck(runner *kyverno.TestRunner) error {
 		return fmt.Errorf("failed to check coverage: %w", err)
 	}
 
-	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
-
 	if len(covered) > 0 {
-		fmt.Fprintln(w, "COVERED:")
+		fmt.Println("COVERED:")
 		for _, p := range covered {
-			fmt.Fprintf(w, "  ✅\t%s\n", p)
+			fmt.Printf("  ✅ %s\n", p)
 		}
 	}
 
 	if len(skipped) > 0 {
-		fmt.Fprintln(w, "\nSKIPPED (cannot test offline):")
+		fmt.Println("\nSKIPPED (cannot test offline):")
 		for _, p := range skipped {
-			fmt.Fprintf(w, "  ⏭️\t%s\n", p)
+			fmt.Printf("  ⏭️ %s\n", p)
 		}
 	}
 
 	if len(missing) > 0 {
-		fmt.Fprintln(w, "\nMISSING TESTS:")
+		fmt.Println("\nMISSING TESTS:")
 		for _, p := range missing {
-			fmt.Fprintf(w, "  ❌\t%s\n", p)
+			fmt.Printf("  ❌ %s\n", p)
 		}
 	}
 
-	w.Flush()
-
 	fmt.Printf("\nSummary: %d covered, %d missing, %d skipped\n",
 		len(covered), len(missing), len(skipped))
 
 	if len(missing) > 0 {
-		return fmt.Errorf("%d policies missing tests", len(missing))
+		return failFindings(fmt.Errorf("%d policies missing tests", len(missing)), nil)
 	}
 
 	return nil
 }
 
-func runSingleTest(runner *kyverno.TestRunner, policyName string) error {
+func runSingleTest(runner *kyverno.TestRunner, policyName string, format output.Format) error {
 	logInfo("\n=== Testing policy: %s ===\n", policyName)
 
 	result := runner.RunTest(policyName)
 
 	if result.Skipped {
 		logInfo("⏭️  Skipped: %s", result.SkipReason)
-		return nil
+		if format.Name == "text" {
+			return nil
+		}
+		return outputKyvernoResults(format, result)
 	}
 
-	// Print output
-	fmt.Println(result.Output)
+	if err := outputKyvernoResults(format, result); err != nil {
+		return err
+	}
 
 	if !result.Passed {
+		if result.Summary.Failed > 0 {
+			return failFindings(fmt.Errorf("policy test failed: %v", result.Error), nil)
+		}
 		return fmt.Errorf("policy test failed: %v", result.Error)
 	}
 
@@ -144,7 +204,7 @@ func runSingleTest(runner *kyverno.TestRunner, policyName string) error {
 	return nil
 }
 
-func runAllTests(runner *kyverno.TestRunner) error {
+func runAllTests(runner *kyverno.TestRunner, format output.Format) error {
 	logInfo("\n=== Kyverno Policy Tests ===\n")
 
 	// First check coverage
@@ -175,18 +235,220 @@ func runAllTests(runner *kyverno.TestRunner) error {
 	// Run all tests
 	result := runner.RunTestsDir()
 
-	// Print output
-	fmt.Println(result.Output)
+	if err := outputKyvernoResults(format, result); err != nil {
+		return err
+	}
 
-	// Check for failures
+	// Check for failures; kyverno failing to run at all isn't a finding
 	if !result.Passed {
-		// Parse detailed results for better error message
-		if strings.Contains(result.Output, "Fail") {
-			return fmt.Errorf("policy tests failed - see output above")
+		if result.Summary.Failed > 0 {
+			return failFindings(fmt.Errorf("policy tests failed - see output above"), nil)
 		}
 		return fmt.Errorf("policy tests failed: %v", result.Error)
 	}
+	if len(missing) > 0 {
+		if err := failFindings(nil, fmt.Errorf("%d policies missing tests", len(missing))); err != nil {
+			return err
+		}
+	}
 
 	logInfo("\n✅ All policy tests passed")
 	return nil
 }
+
+// newKyvernoRunner creates a runner for the policy roots in .shadow.yaml,
+// limited to a cluster's overlay when cluster is set
+func newKyvernoRunner(cluster string) (*kyverno.TestRunner, error) {
+	cfg, err := loadConfig()
+	if err != nil {
+		return nil, err
+	}
+	runner := kyverno.NewTestRunner(repoDir, verbose)
+	runner.Roots = cfg.Policies.Kyverno
+	if cluster != "" {
+		runner.Clusters = []string{cluster}
+	}
+	return runner, nil
+}
+
+// kyvernoTestJSON is the json output of kyverno test
+type kyvernoTestJSON struct {
+	Policy     string                   `json:"policy,omitempty"`
+	Passed     bool                     `json:"passed"`
+	Skipped    bool                     `json:"skipped,omitempty"`
+	SkipReason string                   `json:"skip_reason,omitempty"`
+	Summary    kyverno.TestSummary      `json:"summary"`
+	Results    []kyverno.DetailedResult `json:"results"`
+	Error      string                   `json:"error,omitempty"`
+}
+
+// outputKyvernoResults writes a test result in format; templates are
+// executed over kyverno.TestResult
+func outputKyvernoResults(format output.Format, result kyverno.TestResult) error {
+	switch format.Name {
+	case "json":
+		out := kyvernoTestJSON{
+			Policy:     result.PolicyName,
+			Passed:     result.Passed,
+			Skipped:    result.Skipped,
+			SkipReason: result.SkipReason,
+			Summary:    result.Summary,
+			Results:    result.Results,
+		}
+		if result.Error != nil {
+			out.Error = result.Error.Error()
+		}
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(out)
+	case "markdown":
+		fmt.Print(kyvernoResultsMarkdown(result))
+	case "template":
+		return format.Execute(os.Stdout, result)
+	default:
+		printKyvernoResults(result)
+	}
+	return nil
+}
+
+// kyvernoResultsMarkdown renders a test result as a PR-comment-ready table
+func kyvernoResultsMarkdown(result kyverno.TestResult) string {
+	var b strings.Builder
+	icon := "✅"
+	if !result.Passed {
+		icon = "❌"
+	}
+	fmt.Fprintf(&b, "## %s Kyverno Policy Tests\n\n", icon)
+	if result.Skipped {
+		fmt.Fprintf(&b, "⏭️ Skipped: %s\n", result.SkipReason)
+		return b.String()
+	}
+	fmt.Fprintf(&b, "**Passed:** %d · **Failed:** %d\n", result.Summary.Passed, result.Summary.Failed)
+	if len(result.Results) == 0 {
+		if result.Error != nil {
+			fmt.Fprintf(&b, "\n```\n%s\n```\n", strings.TrimSpace(result.Output))
+		}
+		return b.String()
+	}
+	b.WriteString("\n| Cluster | Policy | Rule | Resource | Result | Reason |\n")
+	b.WriteString("|---------|--------|------|----------|--------|--------|\n")
+	for _, r := range result.Results {
+		cluster := r.Cluster
+		if cluster == "" {
+			cluster = "base"
+		}
+		icon := "✅"
+		if r.Failed() {
+			icon = "❌"
+		}
+		reason := r.Reason
+		if r.Failed() && r.Message != "" {
+			reason = strings.TrimPrefix(reason+": "+r.Message, ": ")
+		}
+		reason = strings.ReplaceAll(strings.ReplaceAll(reason, "\n", " "), "|", "\\|")
+		fmt.Fprintf(&b, "| %s | %s | %s | %s | %s %s | %s |\n", cluster, r.Policy, r.Rule, r.Resource, icon, r.Result, reason)
+	}
+	return b.String()
+}
+
+// printKyvernoResults prints one row per test case, or kyverno's raw output
+// when it printed no results (e.g. a malformed kyverno-test.yaml)
+func printKyvernoResults(result kyverno.TestResult) {
+	if len(result.Results) == 0 {
+		fmt.Println(result.Output)
+		return
+	}
+	t := newTable("ID", "CLUSTER", "POLICY", "RULE", "RESOURCE", "RESULT", "REASON")
+	for _, r := range result.Results {
+		icon := "✅"
+		if r.Failed() {
+			icon = "❌"
+		}
+		reason := r.Reason
+		if r.Failed() && r.Message != "" {
+			reason = strings.TrimPrefix(reason+": "+r.Message, ": ")
+		}
+		cluster := r.Cluster
+		if cluster == "" {
+			cluster = "base"
+		}
+		t.Row(strconv.Itoa(r.ID), cluster, r.Policy, r.Rule, r.Resource, icon+" "+r.Result, reason)
+	}
+	t.Render(os.Stdout)
+	fmt.Printf("\nTest Summary: %d tests passed and %d tests failed\n", result.Summary.Passed, result.Summary.Failed)
+}
+
+func runKyvernoImpact(cmd *cobra.Command, args []string) error {
+	format, err := output.Parse(kyvernoImpactOutput, "text", "json", "markdown")
+	if err != nil {
+		return err
+	}
+
+	if !kyverno.IsKyvernoInstalled() {
+		return fmt.Errorf("kyverno CLI is %w\n  Install: brew install kyverno", command.ErrNotInstalled)
+	}
+
+	var clusters []string
+	if kyvernoImpactCluster != "" {
+		clusters = []string{kyvernoImpactCluster}
+	}
+	var manifests map[string]string
+	if kyvernoImpactRendered != "" {
+		manifests, err = readRenderedManifests(kyvernoImpactRendered)
+	} else {
+		var failures []validate.Result
+		manifests, failures, err = buildManifests(clusters, kyvernoImpactEngine)
+		for _, f := range failures {
+			log.Default().Warnf("%s: %s", f.Path, f.Message)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	runner, err := newKyvernoRunner(kyvernoImpactCluster)
+	if err != nil {
+		return err
+	}
+	report, err := runner.Impact(kyvernoImpactBase, manifests)
+	if err != nil {
+		return fmt.Errorf("policy impact analysis failed: %w", err)
+	}
+
+	switch format.Name {
+	case "json":
+		output, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal report: %w", err)
+		}
+		fmt.Println(string(output))
+	case "markdown":
+		fmt.Print(report.Markdown())
+	case "template":
+		return format.Execute(os.Stdout, report)
+	default:
+		printKyvernoImpact(report)
+	}
+	return nil
+}
+
+func printKyvernoImpact(report kyverno.ImpactReport) {
+	if len(report.Policies) == 0 {
+		fmt.Printf("No Kyverno policies changed since %s\n", report.Base)
+		return
+	}
+	fmt.Printf("%d changed policy file(s) since %s, %d rendered resource(s)\n", len(report.Policies), report.Base, report.Resources)
+	for _, p := range report.Policies {
+		fmt.Printf("\n%s (%s)\n", p.Path, p.Change)
+		if len(p.NewViolations) == 0 && len(p.Resolved) == 0 {
+			fmt.Printf("  no rendered resource changes outcome\n")
+		}
+		for _, v := range p.NewViolations {
+			fmt.Printf("  ❌ %s %s/%s (%s): %s\n", v.Resource(), v.Policy, v.Rule, strings.Join(v.Dirs, ", "), v.Message)
+		}
+		for _, v := range p.Resolved {
+			fmt.Printf("  ✅ %s %s/%s (%s)\n", v.Resource(), v.Policy, v.Rule, strings.Join(v.Dirs, ", "))
+		}
+	}
+	fmt.Printf("\nSummary: %d new violation(s), %d resolved\n", report.NewViolations(), report.Resolved())
+}
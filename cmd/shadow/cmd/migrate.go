@@ -0,0 +1,187 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/erauner/homelab-shadow/pkg/log"
+	"github.com/erauner/homelab-shadow/pkg/validate"
+	"github.com/spf13/cobra"
+)
+
+var (
+	migrateCluster      string
+	migrateEnvs         string
+	migrateDryRun       bool
+	migrateOutputFormat string
+)
+
+var migrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Migrate repo structure to current conventions",
+	Long: `Commands that rewrite parts of the repo into the structure shadow validate
+expects.
+
+Examples:
+  shadow migrate overlays coder --dry-run
+  shadow migrate overlays coder --cluster erauner-home
+  shadow migrate namespaces --dry-run`,
+}
+
+var migrateOverlaysCmd = &cobra.Command{
+	Use:   "overlays <app>",
+	Short: "Move an app's flat overlays under a cluster (issue #1256)",
+	Long: `Converts an app's legacy flat overlays to the cluster-layered structure:
+
+  apps/<app>/overlays/<env>/  =>  apps/<app>/overlays/<cluster>/<env>/
+
+stack/<env> and db/overlays/<env> directories move the same way. Every
+kustomization of the app has its relative references rewritten for the new
+depth (../../base becomes ../../../base, and references to a moved overlay
+follow it), and Applications under argocd-apps/ whose source path names a
+moved overlay are re-pointed. ApplicationSets that may generate the old paths
+are listed to update by hand.
+
+--cluster defaults to the registered cluster when there is only one.
+--dry-run prints the renames and a diff of the edits without changing
+anything. Run shadow validate afterwards.
+
+Examples:
+  shadow migrate overlays coder --dry-run
+  shadow migrate overlays coder --cluster erauner-home
+  shadow migrate overlays coder --cluster erauner-home --env production,staging`,
+	Args: cobra.ExactArgs(1),
+	RunE: runMigrateOverlays,
+}
+
+var migrateNamespacesCmd = &cobra.Command{
+	Use:   "namespaces",
+	Short: "Move Namespace definitions into security/namespaces/",
+	Long: `Moves Namespace manifests from infrastructure/namespaces/ and from app,
+operator, infrastructure, and security component directories into
+security/namespaces/, where the namespace-location rules expect them:
+
+  - a definition under a registered cluster's directory goes to
+    security/namespaces/overlays/<cluster>/ when that overlay exists
+  - everything else goes to security/namespaces/base/
+
+Definitions of the same namespace are merged into one <name>.yaml (labels and
+annotations; a value already in security/namespaces/ wins) and added to the
+directory's kustomization. The moved documents are dropped from their files,
+files left empty are deleted, and kustomizations listing them are updated.
+
+Anything needing a human is listed for review: conflicting labels or
+annotations, fields other than labels and annotations (not carried over),
+kustomizations left without resources, and Namespaces under clusters/ or
+argocd-apps/, which are not moved since bootstrap often needs them first.
+
+--dry-run prints a diff without changing anything. Run shadow validate
+afterwards.
+
+Examples:
+  shadow migrate namespaces --dry-run
+  shadow migrate namespaces -o json`,
+	Args: cobra.NoArgs,
+	RunE: runMigrateNamespaces,
+}
+
+func init() {
+	rootCmd.AddCommand(migrateCmd)
+	migrateCmd.AddCommand(migrateOverlaysCmd)
+	migrateCmd.AddCommand(migrateNamespacesCmd)
+
+	migrateOverlaysCmd.Flags().StringVarP(&migrateCluster, "cluster", "c", "", "Cluster to move the overlays under (default: the only registered cluster)")
+	migrateOverlaysCmd.Flags().StringVar(&migrateEnvs, "env", "", "Comma-separated environments to migrate (default: every flat overlay)")
+	migrateOverlaysCmd.Flags().BoolVar(&migrateDryRun, "dry-run", false, "Print the renames and a diff of the edits without changing anything")
+	migrateOverlaysCmd.Flags().StringVarP(&migrateOutputFormat, "output", "o", "diff", "Output format: diff, json")
+
+	migrateNamespacesCmd.Flags().BoolVar(&migrateDryRun, "dry-run", false, "Print a diff of the edits without changing anything")
+	migrateNamespacesCmd.Flags().StringVarP(&migrateOutputFormat, "output", "o", "diff", "Output format: diff, json")
+}
+
+func runMigrateOverlays(cmd *cobra.Command, args []string) error {
+	if migrateOutputFormat != "diff" && migrateOutputFormat != "json" {
+		return fmt.Errorf("unknown output format: %s", migrateOutputFormat)
+	}
+	v := validate.NewClusterValidator(repoDir, verbose)
+	clusters, err := v.DiscoverClusters()
+	if err != nil {
+		return fmt.Errorf("failed to discover clusters: %w", err)
+	}
+	cluster := migrateCluster
+	switch {
+	case cluster == "" && len(clusters) == 1:
+		cluster = clusters[0]
+	case cluster == "":
+		return fmt.Errorf("--cluster is required when %d clusters are registered (%s)", len(clusters), strings.Join(clusters, ", "))
+	case !slices.Contains(clusters, cluster):
+		return fmt.Errorf("cluster %q not found (available: %s)", cluster, strings.Join(clusters, ", "))
+	}
+
+	m, err := v.PlanOverlayMigration(args[0], cluster, splitList(migrateEnvs))
+	if err != nil {
+		return err
+	}
+	if len(m.Moves) == 0 {
+		logInfo("apps/%s has no legacy flat overlays to migrate", args[0])
+		return nil
+	}
+	if !migrateDryRun {
+		if err := m.Apply(); err != nil {
+			return err
+		}
+	}
+
+	if migrateOutputFormat == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(m)
+	}
+	fmt.Print(m.Diff())
+	for _, note := range m.Notes {
+		log.Default().Warnf("%s", note)
+	}
+	if migrateDryRun {
+		logInfo("Would move %d overlay(s) under %s and edit %d file(s)", len(m.Moves), cluster, len(m.Edits))
+	} else {
+		logInfo("Moved %d overlay(s) under %s and edited %d file(s)", len(m.Moves), cluster, len(m.Edits))
+	}
+	return nil
+}
+
+func runMigrateNamespaces(cmd *cobra.Command, args []string) error {
+	if migrateOutputFormat != "diff" && migrateOutputFormat != "json" {
+		return fmt.Errorf("unknown output format: %s", migrateOutputFormat)
+	}
+	m, err := validate.NewClusterValidator(repoDir, verbose).PlanNamespaceMigration()
+	if err != nil {
+		return err
+	}
+	if !migrateDryRun {
+		if err := m.Apply(); err != nil {
+			return err
+		}
+	}
+
+	if migrateOutputFormat == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(m)
+	}
+	fmt.Print(m.Diff())
+	for _, item := range m.Review {
+		log.Default().Warnf("review: %s", item)
+	}
+	switch {
+	case len(m.Namespaces) == 0:
+		logInfo("No Namespace definitions to migrate")
+	case migrateDryRun:
+		logInfo("Would move %d namespace(s) into security/namespaces and edit %d file(s)", len(m.Namespaces), len(m.Edits))
+	default:
+		logInfo("Moved %d namespace(s) into security/namespaces and edited %d file(s)", len(m.Namespaces), len(m.Edits))
+	}
+	return nil
+}
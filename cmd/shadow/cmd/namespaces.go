@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/erauner/homelab-shadow/pkg/cluster"
+	"github.com/erauner/homelab-shadow/pkg/sync"
+	"github.com/erauner/homelab-shadow/pkg/validate"
+	"github.com/spf13/cobra"
+)
+
+var (
+	namespacesCluster      string
+	namespacesOutputFormat string
+	namespacesRendered     string
+	namespacesEngine       string
+)
+
+var namespacesCmd = &cobra.Command{
+	Use:   "namespaces",
+	Short: "Check rendered resources target namespaces the platform creates",
+	Long: `Renders every deployable kustomization (the same set sync publishes) and
+checks that each resource's metadata.namespace is a Namespace rendered for the
+same cluster from security/namespaces/ (or the legacy infrastructure/namespaces/).
+default, kube-system, kube-public, and kube-node-lease always exist.
+
+  - namespace-undefined: resources target a namespace no namespace directory
+                         creates for the cluster (error); the message names
+                         the overlay creating it instead, if any
+
+This complements the namespace-location rules of shadow validate, which check
+where Namespace manifests live. Clusters without a rendered namespace directory
+are not checked. Change the severity in .shadow.yaml.
+
+Use --rendered to check an existing rendered tree (e.g. a shadow repo
+checkout's rendered/ directory) instead of building.
+
+Examples:
+  shadow namespaces --repo /path/to/homelab-k8s
+  shadow namespaces --repo . --cluster erauner-home
+  shadow namespaces --rendered ../homelab-k8s-shadow/rendered --output json`,
+	RunE: runNamespaces,
+}
+
+func init() {
+	rootCmd.AddCommand(namespacesCmd)
+
+	namespacesCmd.Flags().StringVarP(&namespacesCluster, "cluster", "c", "", "Check only this cluster")
+	namespacesCmd.Flags().StringVarP(&namespacesOutputFormat, "output", "o", "table", "Output format: table, json, markdown")
+	namespacesCmd.Flags().StringVar(&namespacesRendered, "rendered", "", "Check manifest.yaml files under this directory instead of building")
+	namespacesCmd.Flags().StringVar(&namespacesEngine, "kustomize-engine", "exec", "Kustomize build engine: exec, krusty (in-process), or auto")
+	namespacesCmd.Flags().BoolVar(&strict, "strict", false, "Treat warnings as errors")
+	namespacesCmd.Flags().BoolVar(&showSuppressed, "show-suppressed", false, "Also list findings suppressed by config or shadow:ignore comments")
+}
+
+func runNamespaces(cmd *cobra.Command, args []string) error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	registry, err := cluster.Load(repoDir)
+	if err != nil {
+		return err
+	}
+
+	var clusters []string
+	if namespacesCluster != "" {
+		clusters = []string{namespacesCluster}
+	}
+
+	allResults := []validate.Result{} // Initialize to empty slice for JSON output
+	var manifests map[string]string
+	if namespacesRendered != "" {
+		manifests, err = readRenderedManifests(namespacesRendered)
+	} else {
+		manifests, allResults, err = buildManifests(clusters, namespacesEngine)
+	}
+	if err != nil {
+		return err
+	}
+
+	for dir, manifest := range manifests {
+		name := sync.ClusterForDirectory(dir)
+		if namespacesCluster != "" && name != "" && name != namespacesCluster {
+			delete(manifests, dir)
+			continue
+		}
+		// Builds still carry ${VAR} placeholders; unresolved ones are skipped
+		manifests[dir], _ = registry.Substitute(name, manifest)
+	}
+
+	results, err := validate.ValidateNamespaceRefs(manifests, sync.ClusterForDirectory)
+	if err != nil {
+		return err
+	}
+	allResults = append(allResults, results...)
+	logInfo("Checked namespace references in %d manifest(s)", len(manifests))
+
+	allResults = validate.ApplySeverities(allResults, cfg)
+	allResults = applyStrictness(allResults, cfg)
+	validate.ApplyInlineSuppressions(allResults, repoDir)
+	validate.AssignOwners(allResults, cfg)
+
+	switch namespacesOutputFormat {
+	case "json":
+		return outputJSON(allResults)
+	case "table":
+		return outputTable(allResults)
+	case "markdown":
+		return outputMarkdown(allResults)
+	default:
+		return fmt.Errorf("unknown output format: %s", namespacesOutputFormat)
+	}
+}
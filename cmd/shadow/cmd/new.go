@@ -0,0 +1,179 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/erauner/homelab-shadow/pkg/scaffold"
+	"github.com/erauner/homelab-shadow/pkg/validate"
+	"github.com/spf13/cobra"
+)
+
+var (
+	newClusters    string
+	newEnvs        string
+	newNamespace   string
+	newImage       string
+	newPort        int
+	newApplication bool
+	newRepoURL     string
+	newProject     string
+	newDryRun      bool
+)
+
+var newCmd = &cobra.Command{
+	Use:   "new",
+	Short: "Generate a structure-compliant app or component",
+	Long: `Generates the directory structure shadow validate expects for a new
+service, so it starts out compliant: a base plus an overlay per cluster that
+references it.
+
+Examples:
+  shadow new app web --clusters erauner-home,erauner-cloud --envs production,staging
+  shadow new app web --image nginx:1.27.3 --port 80 --application
+  shadow new component operators/cert-manager --namespace cert-manager`,
+}
+
+var newAppCmd = &cobra.Command{
+	Use:   "app <name>",
+	Short: "Generate apps/<name>/base and overlays/<cluster>/<env>",
+	Long: `Generates a new app:
+
+  apps/<name>/base/kustomization.yaml
+  apps/<name>/overlays/<cluster>/<env>/kustomization.yaml   (resources: ../../../base)
+
+With --image the base also gets a Deployment and a Service running it.
+With --application, argocd-apps/applications/<name>.yaml gets an Application
+named <name>-<cluster>-<env> for each overlay, deploying it to the cluster
+(destination.name) and namespace; its repoURL is the one existing Applications
+use (or the origin remote) unless --repo-url is set.
+
+--clusters defaults to every registered cluster (clusters.yaml or clusters/).
+Existing files are never overwritten. The namespace itself is not created;
+define it under security/namespaces/ like any other.
+
+Examples:
+  shadow new app web --clusters erauner-home --envs production,staging
+  shadow new app web --image nginx:1.27.3 --port 80 --application
+  shadow new app web --application --dry-run`,
+	Args: cobra.ExactArgs(1),
+	RunE: runNewApp,
+}
+
+var newComponentCmd = &cobra.Command{
+	Use:   "component <root>/<name>",
+	Short: "Generate <root>/<name>/base and overlays/<cluster>",
+	Long: `Generates a new infrastructure, operators, or security component:
+
+  <root>/<name>/base/kustomization.yaml
+  <root>/<name>/overlays/<cluster>/kustomization.yaml   (resources: ../../base)
+
+--clusters defaults to every registered cluster. Existing files are never
+overwritten.
+
+Examples:
+  shadow new component operators/cert-manager --namespace cert-manager
+  shadow new component infrastructure/external-dns --clusters erauner-home`,
+	Args: cobra.ExactArgs(1),
+	RunE: runNewComponent,
+}
+
+func init() {
+	rootCmd.AddCommand(newCmd)
+	newCmd.AddCommand(newAppCmd)
+	newCmd.AddCommand(newComponentCmd)
+
+	for _, c := range []*cobra.Command{newAppCmd, newComponentCmd} {
+		c.Flags().StringVar(&newClusters, "clusters", "", "Comma-separated clusters to add overlays for (default: every registered cluster)")
+		c.Flags().StringVar(&newNamespace, "namespace", "", "Namespace the overlays deploy to (app default: the app name)")
+		c.Flags().BoolVar(&newDryRun, "dry-run", false, "Print the files instead of writing them")
+	}
+	newAppCmd.Flags().StringVar(&newEnvs, "envs", "production", "Comma-separated environments to add overlays for")
+	newAppCmd.Flags().StringVar(&newImage, "image", "", "Add a Deployment and Service running this image to the base")
+	newAppCmd.Flags().IntVar(&newPort, "port", 8080, "Container and Service port (with --image)")
+	newAppCmd.Flags().BoolVar(&newApplication, "application", false, "Also generate an ArgoCD Application per cluster and env")
+	newAppCmd.Flags().StringVar(&newRepoURL, "repo-url", "", "Application source repoURL (default: the one existing Applications use)")
+	newAppCmd.Flags().StringVar(&newProject, "project", "default", "Application project")
+}
+
+func runNewApp(cmd *cobra.Command, args []string) error {
+	clusters, err := newClusterList()
+	if err != nil {
+		return err
+	}
+	opts := scaffold.AppOptions{
+		Name:        args[0],
+		Clusters:    clusters,
+		Envs:        splitList(newEnvs),
+		Namespace:   newNamespace,
+		Image:       newImage,
+		Port:        newPort,
+		Application: newApplication,
+		RepoURL:     newRepoURL,
+		Project:     newProject,
+	}
+	if opts.Application && opts.RepoURL == "" {
+		if opts.RepoURL = scaffold.RepoURL(repoDir); opts.RepoURL == "" {
+			return fmt.Errorf("cannot tell the repoURL for the Application: no Application or origin remote to copy it from; set --repo-url")
+		}
+	}
+	files, err := scaffold.App(opts)
+	if err != nil {
+		return err
+	}
+	return writeScaffold(files)
+}
+
+func runNewComponent(cmd *cobra.Command, args []string) error {
+	root, name, ok := strings.Cut(args[0], "/")
+	if !ok {
+		return fmt.Errorf("component must be <root>/<name>, e.g. operators/cert-manager (roots: %s)", strings.Join(scaffold.ComponentRoots, ", "))
+	}
+	clusters, err := newClusterList()
+	if err != nil {
+		return err
+	}
+	files, err := scaffold.Component(scaffold.ComponentOptions{
+		Root:      root,
+		Name:      name,
+		Clusters:  clusters,
+		Namespace: newNamespace,
+	})
+	if err != nil {
+		return err
+	}
+	return writeScaffold(files)
+}
+
+// newClusterList returns --clusters, or every registered cluster
+func newClusterList() ([]string, error) {
+	if clusters := splitList(newClusters); len(clusters) > 0 {
+		return clusters, nil
+	}
+	clusters, err := validate.NewClusterValidator(repoDir, verbose).DiscoverClusters()
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover clusters: %w", err)
+	}
+	if len(clusters) == 0 {
+		return nil, fmt.Errorf("no clusters registered in %s; set --clusters", repoDir)
+	}
+	return clusters, nil
+}
+
+// writeScaffold writes generated files, or prints them with --dry-run
+func writeScaffold(files []scaffold.File) error {
+	if newDryRun {
+		for _, f := range files {
+			fmt.Printf("# %s\n%s\n", f.Path, f.Content)
+		}
+		return nil
+	}
+	if err := scaffold.Write(repoDir, files); err != nil {
+		return err
+	}
+	for _, f := range files {
+		fmt.Printf("  created %s\n", f.Path)
+	}
+	logInfo("Generated %d file(s); run shadow validate to check them", len(files))
+	return nil
+}
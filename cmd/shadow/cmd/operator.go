@@ -0,0 +1,150 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/erauner/homelab-shadow/pkg/helm"
+	"github.com/erauner/homelab-shadow/pkg/operator"
+	"github.com/erauner/homelab-shadow/pkg/sync"
+	"github.com/spf13/cobra"
+)
+
+var (
+	operatorSourceRepo    string
+	operatorBranch        string
+	operatorProvider      string
+	operatorCluster       string
+	operatorWorkDir       string
+	operatorInterval      time.Duration
+	operatorListen        string
+	operatorWebhookSecret string
+	operatorNamespace     string
+	operatorConfigMap     string
+	operatorChartCacheDir string
+	operatorNoChartCache  bool
+	operatorGitRetries    int
+	operatorGitRetryDelay time.Duration
+)
+
+var operatorCmd = &cobra.Command{
+	Use:   "operator",
+	Short: "Continuously validate and render the source repo's main branch",
+	Long: `Operator runs shadow as a long-lived controller, usually as a Deployment in
+the cluster. It keeps a checkout of the source repo's branch, and whenever the
+branch moves (polled every --interval, or on a push webhook) it runs the same
+checks as validate and renders everything sync would publish, then publishes
+the outcome:
+
+  - a ConfigMap (--configmap, in --namespace or the pod's namespace) with
+    status.json, healthy, and revision keys, applied with the pod's service
+    account (needs get, create, and patch on configmaps)
+  - Prometheus metrics on --listen at /metrics (shadow_repo_healthy,
+    shadow_validation_findings, shadow_render_dirs, shadow_runs_total, ...)
+  - the last status as JSON at /status
+
+Push webhooks from GitHub, GitLab, or Gitea can be pointed at /webhook to
+check new commits immediately. With --webhook-secret (or
+$SHADOW_WEBHOOK_SECRET), unsigned webhooks are rejected.
+
+Outside a cluster, set --configmap "" to only serve metrics.
+
+Examples:
+  # In-cluster: poll every 5 minutes, publish to ConfigMap shadow-status
+  shadow operator --source-repo erauner/homelab-k8s
+
+  # Locally, metrics only, webhook-driven
+  shadow operator --source-repo erauner/homelab-k8s --configmap "" --interval 0 --listen :9090`,
+	RunE: runOperator,
+}
+
+func init() {
+	rootCmd.AddCommand(operatorCmd)
+
+	operatorCmd.Flags().StringVar(&operatorSourceRepo, "source-repo", "", "Source repository to watch (owner/repo or git URL; default: from $GIT_URL)")
+	operatorCmd.Flags().StringVar(&operatorBranch, "branch", "main", "Branch to validate and render")
+	operatorCmd.Flags().StringVar(&operatorProvider, "provider", "", "Git hosting provider: github, gitlab, or gitea (default: detected from --source-repo)")
+	operatorCmd.Flags().StringVar(&operatorCluster, "cluster", "", "Validate and render only this cluster (default: all)")
+	operatorCmd.Flags().StringVar(&operatorWorkDir, "work-dir", "", "Directory for the source checkout, reused between runs (default: a temp directory)")
+	operatorCmd.Flags().DurationVar(&operatorInterval, "interval", 5*time.Minute, "How often to poll the branch for new commits (0 = webhooks only)")
+	operatorCmd.Flags().StringVar(&operatorListen, "listen", ":8080", "Address for /metrics, /status, /healthz, and /webhook")
+	operatorCmd.Flags().StringVar(&operatorWebhookSecret, "webhook-secret", os.Getenv("SHADOW_WEBHOOK_SECRET"), "Secret webhooks must be signed with (default: $SHADOW_WEBHOOK_SECRET)")
+	operatorCmd.Flags().StringVar(&operatorNamespace, "namespace", "", "Namespace of the status ConfigMap (default: the pod's namespace)")
+	operatorCmd.Flags().StringVar(&operatorConfigMap, "configmap", "shadow-status", "Name of the status ConfigMap (empty = do not publish one)")
+	operatorCmd.Flags().StringVar(&operatorChartCacheDir, "chart-cache-dir", helm.DefaultCacheDir(), "Helm chart cache directory")
+	operatorCmd.Flags().BoolVar(&operatorNoChartCache, "no-chart-cache", false, "Always download Helm charts instead of using the chart cache")
+	operatorCmd.Flags().IntVar(&operatorGitRetries, "git-retries", 2, "Retries for fetch after transient network failures")
+	operatorCmd.Flags().DurationVar(&operatorGitRetryDelay, "git-retry-delay", 2*time.Second, "Delay before the first git retry (doubles after each)")
+}
+
+func runOperator(cmd *cobra.Command, args []string) error {
+	sourceRepo := sourceRepoOrEnv(operatorSourceRepo)
+	if sourceRepo == "" {
+		return fmt.Errorf("--source-repo is required (or set GIT_URL)")
+	}
+	provider, err := sync.ParseProvider(operatorProvider)
+	if err != nil {
+		return err
+	}
+
+	var publishers []operator.Publisher
+	if operatorConfigMap != "" {
+		publisher, err := operator.InClusterConfigMapPublisher(operatorNamespace, operatorConfigMap)
+		if err != nil {
+			return fmt.Errorf("%w (use --configmap \"\" to run without publishing a ConfigMap)", err)
+		}
+		publishers = append(publishers, publisher)
+	}
+
+	op, err := operator.New(operator.Options{
+		SourceRepo:    sourceRepo,
+		Provider:      provider,
+		Branch:        operatorBranch,
+		WorkDir:       operatorWorkDir,
+		Interval:      operatorInterval,
+		Cluster:       operatorCluster,
+		HelmCacheDir:  chartCacheDir(operatorChartCacheDir, operatorNoChartCache),
+		GitRetries:    operatorGitRetries,
+		GitRetryDelay: operatorGitRetryDelay,
+		Publishers:    publishers,
+		Verbose:       verbose,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to initialize operator: %w", err)
+	}
+
+	ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	server := &http.Server{Addr: operatorListen, Handler: op.Handler(operatorWebhookSecret), ReadHeaderTimeout: 10 * time.Second}
+	serverErr := make(chan error, 1)
+	go func() {
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			serverErr <- err
+		}
+		close(serverErr)
+	}()
+	logInfo("Watching %s of %s (interval %s), serving on %s", operatorBranch, sourceRepo, operatorInterval, operatorListen)
+
+	runErr := make(chan error, 1)
+	go func() { runErr <- op.Run(ctx) }()
+
+	select {
+	case err = <-runErr:
+	case err = <-serverErr:
+		stop()
+		<-runErr
+		err = fmt.Errorf("failed to serve on %s: %w", operatorListen, err)
+	}
+
+	shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	server.Shutdown(shutdown)
+	return err
+}
@@ -0,0 +1,238 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/erauner/homelab-shadow/pkg/command"
+	"github.com/erauner/homelab-shadow/pkg/kyverno"
+	"github.com/erauner/homelab-shadow/pkg/log"
+	"github.com/erauner/homelab-shadow/pkg/opa"
+	"github.com/erauner/homelab-shadow/pkg/sync"
+	"github.com/erauner/homelab-shadow/pkg/validate"
+	"github.com/spf13/cobra"
+)
+
+var (
+	policyScanRendered string
+	policyScanCluster  string
+	policyScanEngine   string
+	policyScanOutput   string
+
+	policyConftestRendered string
+	policyConftestCluster  string
+	policyConftestEngine   string
+	policyConftestOutput   string
+)
+
+var policyCmd = &cobra.Command{
+	Use:   "policy",
+	Short: "Check rendered manifests against cluster policies",
+}
+
+var policyScanCmd = &cobra.Command{
+	Use:   "scan",
+	Short: "Apply every Kyverno cluster policy to the rendered manifests",
+	Long: `Apply every Kyverno policy under the cluster policy directories to the
+manifests sync renders, with kyverno apply, and report the failing resources
+per overlay.
+
+kyverno test only exercises the fixtures under the policy tests directories;
+scan checks what would actually be deployed. Policies that cannot run offline
+(see shadow kyverno test) are skipped.
+
+The manifests are read from --rendered (a sync --dry-run output directory), or
+every sync-discovered kustomization is built first. Exits non-zero when any
+resource violates a policy.
+
+Examples:
+  shadow policy scan
+  shadow policy scan --cluster erauner-home
+  shadow policy scan --rendered ./rendered-local --output sarif > policy.sarif`,
+	RunE: runPolicyScan,
+}
+
+var policyConftestCmd = &cobra.Command{
+	Use:   "conftest",
+	Short: "Evaluate Rego policies against the rendered manifests with conftest",
+	Long: `Evaluate the Rego policies under policies/opa (or policies.opa in
+.shadow.yaml) against every rendered resource with conftest, in all Rego
+packages. Gatekeeper-style violation rules and conftest deny rules are errors
+(opa-deny); warn rules are warnings (opa-warn). *_test.rego files are not
+loaded as policies.
+
+Findings use the validate result format, so rules.opa-deny / rules.opa-warn
+severities, ignore paths, owners, and shadow:ignore comments apply.
+
+The manifests are read from --rendered (a sync --dry-run output directory), or
+every sync-discovered kustomization is built first.
+
+Examples:
+  shadow policy conftest
+  shadow policy conftest --cluster erauner-home --output markdown
+  shadow policy conftest --rendered ./rendered-local --output json`,
+	RunE: runPolicyConftest,
+}
+
+func init() {
+	rootCmd.AddCommand(policyCmd)
+	policyCmd.AddCommand(policyScanCmd)
+	policyCmd.AddCommand(policyConftestCmd)
+
+	policyConftestCmd.Flags().StringVar(&policyConftestRendered, "rendered", "", "Directory of rendered manifests (default: render the repo)")
+	policyConftestCmd.Flags().StringVarP(&policyConftestCluster, "cluster", "c", "", "Check only this cluster")
+	policyConftestCmd.Flags().StringVar(&policyConftestEngine, "kustomize-engine", "exec", "Kustomize build engine: exec, krusty (in-process), or auto")
+	policyConftestCmd.Flags().StringVarP(&policyConftestOutput, "output", "o", "table", "Output format: table, json, markdown")
+	policyConftestCmd.Flags().BoolVar(&strict, "strict", false, "Treat warnings as errors")
+	policyConftestCmd.Flags().BoolVar(&showSuppressed, "show-suppressed", false, "Also list findings suppressed by config or shadow:ignore comments")
+
+	policyScanCmd.Flags().StringVar(&policyScanRendered, "rendered", "", "Directory of rendered manifests (default: render the repo)")
+	policyScanCmd.Flags().StringVarP(&policyScanCluster, "cluster", "c", "", "Only this cluster's overlays and policies (nothing is rendered with --rendered)")
+	policyScanCmd.Flags().StringVar(&policyScanEngine, "kustomize-engine", "exec", "Kustomize build engine: exec, krusty (in-process), or auto")
+	policyScanCmd.Flags().StringVarP(&policyScanOutput, "output", "o", "table", "Output format: table, json, or sarif")
+}
+
+func runPolicyScan(cmd *cobra.Command, args []string) error {
+	switch policyScanOutput {
+	case "table", "json", "sarif":
+	default:
+		return fmt.Errorf("unknown output format: %s", policyScanOutput)
+	}
+	if !kyverno.IsKyvernoInstalled() {
+		return fmt.Errorf("kyverno CLI is %w\n  Install: brew install kyverno", command.ErrNotInstalled)
+	}
+
+	var clusters []string
+	if policyScanCluster != "" {
+		clusters = []string{policyScanCluster}
+	}
+	var manifests map[string]string
+	var err error
+	if policyScanRendered != "" {
+		manifests, err = readRenderedManifests(policyScanRendered)
+	} else {
+		var failures []validate.Result
+		manifests, failures, err = buildManifests(clusters, policyScanEngine)
+		for _, f := range failures {
+			log.Default().Warnf("%s: %s", f.Path, f.Message)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	runner, err := newKyvernoRunner(policyScanCluster)
+	if err != nil {
+		return err
+	}
+	report, err := runner.Scan(manifests)
+	if err != nil {
+		return fmt.Errorf("policy scan failed: %w", err)
+	}
+	logInfo("Applied %d policies to %d rendered resource(s)", len(report.Policies), report.Resources)
+	for _, name := range report.Skipped {
+		logVerbose("Skipped %s: %s", name, kyverno.SkipPolicies[name])
+	}
+
+	switch policyScanOutput {
+	case "json":
+		if report.Violations == nil {
+			report.Violations = []kyverno.Violation{}
+		}
+		output, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal report: %w", err)
+		}
+		fmt.Println(string(output))
+	case "sarif":
+		output, err := report.SARIF(Version)
+		if err != nil {
+			return fmt.Errorf("failed to marshal SARIF: %w", err)
+		}
+		fmt.Println(string(output))
+	default:
+		printPolicyScan(report)
+	}
+
+	if len(report.Violations) > 0 {
+		return fmt.Errorf("%d resource(s) violate Kyverno policies", len(report.Violations))
+	}
+	return nil
+}
+
+func printPolicyScan(report kyverno.ScanReport) {
+	if len(report.Violations) == 0 {
+		fmt.Println("✅ No policy violations")
+		return
+	}
+	t := newTable("DIRECTORY", "RESOURCE", "POLICY", "RULE", "MESSAGE")
+	for _, overlay := range report.Overlays() {
+		for _, v := range overlay.Violations {
+			t.Row(overlay.Dir, v.Resource(), v.Policy, v.Rule, strings.Join(strings.Fields(v.Message), " "))
+		}
+	}
+	t.Render(os.Stdout)
+	fmt.Printf("\nSummary: %d violation(s) in %d overlay(s)\n", len(report.Violations), len(report.Overlays()))
+}
+
+func runPolicyConftest(cmd *cobra.Command, args []string) error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	if !opa.IsConftestInstalled() {
+		return fmt.Errorf("conftest CLI is %w\n  Install: brew install conftest", command.ErrNotInstalled)
+	}
+
+	var clusters []string
+	if policyConftestCluster != "" {
+		clusters = []string{policyConftestCluster}
+	}
+	allResults := []validate.Result{}
+	var manifests map[string]string
+	if policyConftestRendered != "" {
+		manifests, err = readRenderedManifests(policyConftestRendered)
+	} else {
+		manifests, allResults, err = buildManifests(clusters, policyConftestEngine)
+	}
+	if err != nil {
+		return err
+	}
+	if policyConftestCluster != "" {
+		for dir := range manifests {
+			if cluster := sync.ClusterForDirectory(dir); cluster != "" && cluster != policyConftestCluster {
+				delete(manifests, dir)
+			}
+		}
+	}
+
+	runner := opa.NewRunner(repoDir, verbose)
+	runner.Roots = cfg.Policies.OPA
+	results, err := runner.Test(manifests)
+	if err != nil {
+		return fmt.Errorf("conftest failed: %w", err)
+	}
+	for i := range results {
+		results[i].Cluster = sync.ClusterForDirectory(results[i].Path)
+	}
+	allResults = append(allResults, results...)
+	logInfo("Evaluated Rego policies against %d manifest(s)", len(manifests))
+
+	allResults = validate.ApplySeverities(allResults, cfg)
+	allResults = applyStrictness(allResults, cfg)
+	validate.ApplyInlineSuppressions(allResults, repoDir)
+	validate.AssignOwners(allResults, cfg)
+
+	switch policyConftestOutput {
+	case "json":
+		return outputJSON(allResults)
+	case "table":
+		return outputTable(allResults)
+	case "markdown":
+		return outputMarkdown(allResults)
+	default:
+		return fmt.Errorf("unknown output format: %s", policyConftestOutput)
+	}
+}
@@ -0,0 +1,139 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/erauner/homelab-shadow/pkg/log"
+	"github.com/erauner/homelab-shadow/pkg/refs"
+	"github.com/erauner/homelab-shadow/pkg/sync"
+	"github.com/erauner/homelab-shadow/pkg/validate"
+	"github.com/spf13/cobra"
+)
+
+var (
+	refsCluster      string
+	refsOutputFormat string
+	refsRendered     string
+	refsEngine       string
+	refsNoSource     bool
+	refsSourceOnly   bool
+)
+
+var refsCmd = &cobra.Command{
+	Use:   "refs <kind> <[namespace/]name>",
+	Short: "Find references to a resource in rendered manifests and source files",
+	Long: `Finds everything that references a resource, to assess the impact of
+renaming or deleting it.
+
+Rendered manifests (the same set sync publishes, or --rendered) are searched
+structurally: envFrom, volumes and projected volumes, secretKeyRef and
+configMapKeyRef, imagePullSecrets, Ingress tls and backends, Gateway API
+backendRefs, serviceAccountName, claimName, ExternalSecret targets, and any
+object reference naming the kind (scaleTargetRef, roleRef, ...). The resource's
+own definition is listed too. With a namespace, references resolving in other
+namespaces are skipped.
+
+Source files under --repo are searched for the name as a whole word, which
+also finds Helm values files (existingSecret: ...), generators, and overlays
+that are not rendered.
+
+Examples:
+  shadow refs Secret my-ns/my-secret
+  shadow refs ConfigMap grafana-dashboards --cluster erauner-home
+  shadow refs Service media/jellyfin --rendered ../homelab-k8s-shadow/rendered
+  shadow refs PersistentVolumeClaim data --source-only --output json`,
+	Args: cobra.ExactArgs(2),
+	RunE: runRefs,
+}
+
+func init() {
+	rootCmd.AddCommand(refsCmd)
+
+	refsCmd.Flags().StringVarP(&refsCluster, "cluster", "c", "", "Search only this cluster's rendered manifests")
+	refsCmd.Flags().StringVarP(&refsOutputFormat, "output", "o", "table", "Output format: table, json")
+	refsCmd.Flags().StringVar(&refsRendered, "rendered", "", "Search manifest.yaml files under this directory instead of building")
+	refsCmd.Flags().StringVar(&refsEngine, "kustomize-engine", "exec", "Kustomize build engine: exec, krusty (in-process), or auto")
+	refsCmd.Flags().BoolVar(&refsNoSource, "no-source", false, "Skip searching source files")
+	refsCmd.Flags().BoolVar(&refsSourceOnly, "source-only", false, "Only search source files (no rendering)")
+}
+
+func runRefs(cmd *cobra.Command, args []string) error {
+	target, err := refs.ParseTarget(args[0], args[1])
+	if err != nil {
+		return err
+	}
+	if refsNoSource && refsSourceOnly {
+		return fmt.Errorf("--no-source and --source-only are mutually exclusive")
+	}
+
+	found := []refs.Reference{} // Initialize to empty slice for JSON output
+	if !refsSourceOnly {
+		rendered, err := findRenderedRefs(target)
+		if err != nil {
+			return err
+		}
+		found = append(found, rendered...)
+	}
+	if !refsNoSource {
+		sources, err := refs.Sources(repoDir, target)
+		if err != nil {
+			return err
+		}
+		found = append(found, sources...)
+	}
+	logInfo("Found %d reference(s) to %s", len(found), target)
+
+	switch refsOutputFormat {
+	case "json":
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(found); err != nil {
+			return fmt.Errorf("failed to encode JSON: %w", err)
+		}
+	case "table":
+		if len(found) == 0 {
+			fmt.Printf("No references to %s\n", target)
+			return nil
+		}
+		t := newTable("SOURCE", "LOCATION", "RESOURCE", "FIELD")
+		for _, r := range found {
+			t.Row(r.Source, fmt.Sprintf("%s:%d", r.File, r.Line), dashIfEmpty(r.Resource), r.Field)
+		}
+		t.Render(os.Stdout)
+	default:
+		return fmt.Errorf("unknown output format: %s", refsOutputFormat)
+	}
+	return nil
+}
+
+// findRenderedRefs searches the rendered tree, or every sync-discovered kustomization
+func findRenderedRefs(target refs.Target) ([]refs.Reference, error) {
+	var clusters []string
+	if refsCluster != "" {
+		clusters = []string{refsCluster}
+	}
+
+	var manifests map[string]string
+	var err error
+	if refsRendered != "" {
+		manifests, err = readRenderedManifests(refsRendered)
+	} else {
+		var failures []validate.Result
+		manifests, failures, err = buildManifests(clusters, refsEngine)
+		for _, f := range failures {
+			log.Default().Warnf("%s not searched: %s", f.Path, f.Message)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for dir := range manifests {
+		if name := sync.ClusterForDirectory(dir); refsCluster != "" && name != "" && name != refsCluster {
+			delete(manifests, dir)
+		}
+	}
+	return refs.Rendered(manifests, target)
+}
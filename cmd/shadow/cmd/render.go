@@ -0,0 +1,188 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/erauner/homelab-shadow/pkg/argocd"
+	"github.com/erauner/homelab-shadow/pkg/cluster"
+	"github.com/erauner/homelab-shadow/pkg/command"
+	"github.com/erauner/homelab-shadow/pkg/flux"
+	"github.com/erauner/homelab-shadow/pkg/helm"
+	"github.com/erauner/homelab-shadow/pkg/kustomize"
+	"github.com/erauner/homelab-shadow/pkg/sync"
+	"github.com/spf13/cobra"
+)
+
+var (
+	renderOut           string
+	renderRedactSecrets bool
+	renderEngine        string
+	renderNormalize     bool
+	renderHelmCreds     string
+)
+
+var renderCmd = &cobra.Command{
+	Use:   "render <path|application>",
+	Short: "Render a single kustomization or Application to stdout",
+	Long: `Render builds a single kustomization directory (or every source of an ArgoCD
+Application, looked up by name) exactly as sync would publish it, including
+secret redaction and normalization, and prints the manifest.
+
+If the argument is an existing directory under --repo it is treated as a
+kustomization path; otherwise it is treated as an Application name.
+
+Examples:
+  shadow render apps/coder/overlays/erauner-home/production
+  shadow render coder
+  shadow render jenkins --out /tmp/jenkins.yaml
+  shadow render apps/coder/overlays/erauner-home/production --redact-secrets=false`,
+	Args: cobra.ExactArgs(1),
+	RunE: runRender,
+}
+
+func init() {
+	rootCmd.AddCommand(renderCmd)
+
+	renderCmd.Flags().StringVar(&renderOut, "out", "", "Write manifest to file instead of stdout")
+	renderCmd.Flags().BoolVar(&renderRedactSecrets, "redact-secrets", true, "Redact Secret data (default: true)")
+	renderCmd.Flags().BoolVar(&renderNormalize, "normalize", true, "Sort resources and strip volatile annotations")
+	renderCmd.Flags().StringVar(&renderHelmCreds, "helm-creds-file", "", "YAML file of credentials for private Helm repositories (see shadow helm test --help)")
+	renderCmd.Flags().StringVar(&renderEngine, "kustomize-engine", "exec", "Kustomize build engine: exec, krusty (in-process), or auto")
+}
+
+func runRender(cmd *cobra.Command, args []string) error {
+	engine, err := kustomize.ParseEngine(renderEngine)
+	if err != nil {
+		return err
+	}
+
+	runner := kustomize.NewRunner(repoDir, "", verbose)
+	runner.Engine = engine
+
+	registry, err := cluster.Load(repoDir)
+	if err != nil {
+		return err
+	}
+
+	target := strings.TrimSuffix(strings.TrimPrefix(args[0], "./"), "/")
+
+	var manifests []string
+	if info, err := os.Stat(filepath.Join(repoDir, target)); err == nil && info.IsDir() {
+		logVerbose("Rendering kustomization %s", target)
+		manifest, err := renderKustomization(runner, registry, target, nil)
+		if err != nil {
+			return err
+		}
+		manifests = append(manifests, manifest)
+	} else {
+		manifests, err = renderApplication(runner, registry, target)
+		if err != nil {
+			return err
+		}
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	manifest := sync.JoinManifests(manifests)
+	if renderRedactSecrets {
+		manifest = sync.NewRedactor(cfg.Redaction).Redact(manifest)
+	}
+	if renderNormalize {
+		manifest = sync.NewNormalizer(cfg.Normalization).Normalize(manifest)
+	}
+
+	if renderOut == "" {
+		fmt.Print(manifest)
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(renderOut), 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+	if err := os.WriteFile(renderOut, []byte(manifest), 0644); err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+	logInfo("Wrote %d bytes to %s", len(manifest), renderOut)
+	return nil
+}
+
+// renderKustomization builds one kustomization directory, with an Application's
+// kustomize options when set, and resolves its cluster variables
+func renderKustomization(runner *kustomize.Runner, registry *cluster.Registry, dir string, options *argocd.KustomizeConfig) (string, error) {
+	result := runner.BuildSource(dir, options)
+	if result.Skipped {
+		return "", fmt.Errorf("cannot render %s: %s", dir, result.SkipReason)
+	}
+	if !result.Passed {
+		return "", fmt.Errorf("%v\n%s", result.Error, kustomize.ExtractKustomizeBuildError(result.Output))
+	}
+	manifest, err := sync.SubstituteVars(registry, dir, sync.Manifest(result.Output))
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", dir, err)
+	}
+	return string(manifest), nil
+}
+
+// renderApplication renders every kustomize and Helm source of an Application
+func renderApplication(runner *kustomize.Runner, registry *cluster.Registry, name string) ([]string, error) {
+	app, path, err := flux.FindApplication(repoDir, name)
+	if err != nil {
+		return nil, fmt.Errorf("%q is neither a directory in %s nor a known Application: %w", name, repoDir, err)
+	}
+	logVerbose("Rendering Application %s from %s", app.Name, path)
+	return renderApplicationSources(runner, registry, app, renderHelmCreds)
+}
+
+// renderApplicationSources renders every kustomize and Helm source of app
+func renderApplicationSources(runner *kustomize.Runner, registry *cluster.Registry, app *argocd.Application, helmCredsFile string) ([]string, error) {
+	var manifests []string
+	for _, source := range app.GetKustomizeSources() {
+		logVerbose("  kustomize source: %s", source.Path)
+		manifest, err := renderKustomization(runner, registry, source.Path, source.Kustomize)
+		if err != nil {
+			return nil, err
+		}
+		manifests = append(manifests, manifest)
+	}
+
+	helmSources := app.GetHelmSources()
+	if len(helmSources) > 0 && !helm.IsHelmInstalled() {
+		return nil, fmt.Errorf("application %s has Helm sources but helm CLI is %w", app.Name, command.ErrNotInstalled)
+	}
+	var creds *helm.CredentialStore
+	if len(helmSources) > 0 {
+		var err error
+		if creds, err = sync.HelmCredentials(repoDir, helmCredsFile); err != nil {
+			return nil, err
+		}
+	}
+	for _, source := range helmSources {
+		logVerbose("  helm source: %s/%s@%s", source.RepoURL, source.Chart, source.TargetRevision)
+		result := sync.RenderHelmSource(app, &source, sync.HelmRenderOptions{
+			RepoPath:    repoDir,
+			CacheDir:    helm.DefaultCacheDir(),
+			Verbose:     verbose,
+			Kustomize:   runner,
+			Credentials: creds,
+		})
+		if !result.Passed {
+			return nil, result.Error
+		}
+		dir := fmt.Sprintf("apps/%s/helm", app.Name)
+		manifest, err := sync.SubstituteVars(registry, dir, sync.Manifest(result.Output))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", dir, err)
+		}
+		manifests = append(manifests, string(manifest))
+	}
+
+	if len(manifests) == 0 {
+		return nil, fmt.Errorf("application %s has no kustomize or Helm sources to render", app.Name)
+	}
+	return manifests, nil
+}
@@ -0,0 +1,149 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/erauner/homelab-shadow/pkg/sync"
+	"github.com/spf13/cobra"
+)
+
+var (
+	replayKeep         string
+	replayOutputFormat string
+)
+
+var replayCmd = &cobra.Command{
+	Use:   "replay <bundle>",
+	Short: "Re-render a bundle written by sync --record and compare the outcome",
+	Long: `Replay extracts a bundle written by shadow sync --record, renders the
+recorded repo again with the recorded options and environment (the local
+helm, kustomize, and PATH are used), and compares every target with what the
+recorded run produced.
+
+Tool versions that differ from the recording are reported first, since they
+are the usual reason a CI render cannot be reproduced. Targets whose status or
+manifest changed are then listed; failed targets are compared by status only.
+Manifest differences show the first line that changed, and --output json
+includes both manifests in full. Targets the recorded run skipped over its
+--budget are not compared. Environment variables recorded as <redacted> are not replayed: set
+them locally if the render needs them.
+
+Nothing is cloned or pushed. The extracted repo and replayed output are removed
+afterwards unless --keep names a directory for them.
+
+The command exits non-zero when the run was not reproduced.
+
+Examples:
+  # Reproduce a CI run
+  shadow replay shadow-run.tar.zst
+
+  # Keep the extracted repo and output to dig further
+  shadow replay shadow-run.tar.zst --keep ./replay
+
+  # JSON output
+  shadow replay shadow-run.tar.zst --output json`,
+	Args: cobra.ExactArgs(1),
+	RunE: runReplay,
+}
+
+func init() {
+	rootCmd.AddCommand(replayCmd)
+
+	replayCmd.Flags().StringVar(&replayKeep, "keep", "", "Extract and render into this directory and keep it")
+	replayCmd.Flags().StringVar(&replayOutputFormat, "output", "text", "Output format: text or json")
+}
+
+func runReplay(cmd *cobra.Command, args []string) error {
+	logInfo("Replaying %s...", args[0])
+	result, err := sync.Replay(context.Background(), args[0], sync.ReplayOptions{
+		WorkDir: replayKeep,
+		Verbose: verbose,
+	})
+	if err != nil {
+		return err
+	}
+
+	if strings.ToLower(replayOutputFormat) == "json" {
+		output, err := json.MarshalIndent(result, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal result: %w", err)
+		}
+		fmt.Println(string(output))
+	} else {
+		printReplayResult(result)
+	}
+
+	if !result.Reproduced() {
+		return fmt.Errorf("%d target(s) did not render as recorded", len(result.Diffs))
+	}
+	return nil
+}
+
+func printReplayResult(result sync.ReplayResult) {
+	rec := result.Recorded
+	fmt.Printf("Recorded:  %s", rec.RecordedAt)
+	if rec.Options.SourceCommit != "" {
+		fmt.Printf(" (%s@%s)", rec.Options.SourceRepo, rec.Options.SourceCommit)
+	}
+	fmt.Println()
+	fmt.Printf("Targets:   %d\n", len(rec.Targets))
+
+	if len(result.ToolChanges) > 0 {
+		fmt.Printf("\nTool versions differ from the recording:\n")
+		for _, change := range result.ToolChanges {
+			fmt.Printf("  %-12s %s -> %s\n", change.Tool, change.Recorded, change.Replayed)
+		}
+	}
+
+	if result.Reproduced() {
+		fmt.Printf("\n✅ Every target rendered as recorded\n")
+		return
+	}
+
+	fmt.Printf("\n❌ %d target(s) did not render as recorded:\n", len(result.Diffs))
+	for _, diff := range result.Diffs {
+		name := diff.Dir
+		if diff.Name != "" {
+			name = diff.Name + " (" + diff.Dir + ")"
+		}
+		switch {
+		case diff.Recorded == nil:
+			fmt.Printf("\n  %s: not in the recording, %s on replay\n", name, diff.Replayed.Status)
+		case diff.Replayed == nil:
+			fmt.Printf("\n  %s: %s in the recording, not found on replay\n", name, diff.Recorded.Status)
+		case diff.Recorded.Status != diff.Replayed.Status:
+			fmt.Printf("\n  %s: %s in the recording, %s on replay\n", name, diff.Recorded.Status, diff.Replayed.Status)
+			if diff.Recorded.Error != "" {
+				fmt.Printf("    recorded error: %s\n", diff.Recorded.Error)
+			}
+			if diff.Replayed.Error != "" {
+				fmt.Printf("    replayed error: %s\n", diff.Replayed.Error)
+			}
+		default:
+			line, recorded, replayed := firstDifference(diff.Recorded.Manifest, diff.Replayed.Manifest)
+			fmt.Printf("\n  %s: manifest differs from line %d\n", name, line)
+			fmt.Printf("    - %s\n    + %s\n", recorded, replayed)
+		}
+	}
+}
+
+// firstDifference returns the first line (1-based) at which two manifests
+// differ, with that line from each; a missing line is shown as <end of manifest>
+func firstDifference(a, b string) (int, string, string) {
+	as, bs := strings.Split(a, "\n"), strings.Split(b, "\n")
+	for i := 0; ; i++ {
+		x, y := "<end of manifest>", "<end of manifest>"
+		if i < len(as) {
+			x = as[i]
+		}
+		if i < len(bs) {
+			y = bs[i]
+		}
+		if x != y || (i >= len(as) && i >= len(bs)) {
+			return i + 1, x, y
+		}
+	}
+}
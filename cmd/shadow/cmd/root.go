@@ -2,15 +2,41 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"strings"
+	"time"
 
+	"github.com/erauner/homelab-shadow/pkg/argocd"
+	"github.com/erauner/homelab-shadow/pkg/command"
+	"github.com/erauner/homelab-shadow/pkg/config"
+	"github.com/erauner/homelab-shadow/pkg/doctor"
+	"github.com/erauner/homelab-shadow/pkg/hint"
+	"github.com/erauner/homelab-shadow/pkg/log"
+	"github.com/erauner/homelab-shadow/pkg/table"
+	"github.com/erauner/homelab-shadow/pkg/toolcache"
+	"github.com/erauner/homelab-shadow/pkg/trace"
+	"github.com/erauner/homelab-shadow/pkg/validate"
 	"github.com/spf13/cobra"
 )
 
 var (
-	verbose bool
-	repoDir string
+	verbose    bool
+	repoDir    string
+	configPath string
+	logLevel   string
+	logFormat  string
+
+	appIndexDir string
+	noAppIndex  bool
+
+	wide       bool
+	noProgress bool
+	cmdTimeout time.Duration
+
+	downloadTools bool
+	toolCacheDir  string
 )
 
 var rootCmd = &cobra.Command{
@@ -24,27 +50,174 @@ Example usage:
   shadow validate --repo /path/to/homelab-k8s
   shadow validate --repo . --cluster home
   shadow validate --repo . --strict`,
+	PersistentPreRunE: setup,
+	SilenceErrors:     true, // printed by Execute along with a remediation hint
 }
 
 // Execute runs the root command
 func Execute() error {
-	return rootCmd.Execute()
+	err := rootCmd.Execute()
+	runSpan.End(err)
+	if ferr := trace.Flush(context.Background()); ferr != nil {
+		log.Default().Warnf("tracing: %v", ferr)
+	}
+	if err != nil {
+		rootCmd.PrintErrln("Error:", err)
+		if h := hint.ForError(err); h != "" {
+			rootCmd.PrintErrln("Hint:", h)
+		}
+	}
+	return err
 }
 
 func init() {
 	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "Enable verbose output")
 	rootCmd.PersistentFlags().StringVar(&repoDir, "repo", ".", "Path to homelab-k8s repository")
+	rootCmd.PersistentFlags().StringVar(&configPath, "config", "", "Path to shadow config (default: <repo>/.shadow.yaml)")
+	rootCmd.PersistentFlags().StringVar(&logLevel, "log-level", "info", "Log level: debug, info, warn, error (--verbose implies debug)")
+	rootCmd.PersistentFlags().StringVar(&logFormat, "log-format", "text", "Log format on stderr: text or json")
+	rootCmd.PersistentFlags().StringVar(&appIndexDir, "app-index-dir", argocd.DefaultIndexDir(), "Directory for the parsed Application index reused between runs")
+	rootCmd.PersistentFlags().BoolVar(&noAppIndex, "no-app-index", false, "Always re-parse Application files instead of using the index")
+	rootCmd.PersistentFlags().BoolVar(&wide, "wide", false, "Don't wrap table output to the terminal width ($COLUMNS, or 120 outside a terminal)")
+	rootCmd.PersistentFlags().BoolVar(&noProgress, "no-progress", false, "Don't report progress of long runs (a live status line on a terminal, a log line every 30s otherwise)")
+	rootCmd.PersistentFlags().BoolVar(&downloadTools, "download-tools", false, "Download the tool versions pinned in .shadow.yaml (checksum-verified) and use them instead of PATH")
+	rootCmd.PersistentFlags().StringVar(&toolCacheDir, "tool-cache-dir", toolcache.DefaultDir(), "Directory for tools downloaded by --download-tools")
+	rootCmd.PersistentFlags().DurationVar(&cmdTimeout, "cmd-timeout", command.DefaultTimeout, "Kill any external command (kustomize, helm, kyverno, git, ...) running longer than this (0 = no limit)")
 
 	rootCmd.SetOut(os.Stdout)
 	rootCmd.SetErr(os.Stderr)
 }
 
-func logVerbose(format string, args ...interface{}) {
+// newTable creates a table wrapped to the terminal width unless --wide is set
+func newTable(header ...string) *table.Table {
+	t := table.New(header...)
+	if !wide {
+		t.Width = table.TerminalWidth()
+	}
+	return t
+}
+
+// showProgress reports whether long runs report progress; --verbose already
+// logs every directory
+func showProgress() bool {
+	return !noProgress && !verbose
+}
+
+// loadConfig loads the shadow config from --config or the repo root
+func loadConfig() (*config.Config, error) {
+	if configPath != "" {
+		return config.LoadFile(configPath)
+	}
+	return config.Load(repoDir)
+}
+
+// useDownloadedTools puts the tool versions pinned in .shadow.yaml, downloaded
+// into --tool-cache-dir, first on PATH
+func useDownloadedTools() error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	if len(cfg.Tools) == 0 {
+		log.Default().Warnf("--download-tools: no tools are pinned in %s; using PATH", config.FileName)
+		return nil
+	}
+	dirs, err := toolcache.New(toolCacheDir).EnsurePins(cfg.Tools)
+	if err != nil {
+		return err
+	}
+	logVerbose("Using downloaded tools from %s", strings.Join(dirs, ", "))
+	return toolcache.PrependPath(dirs)
+}
+
+// skipVersionCheck disables checkToolVersions (--skip-version-check on sync and validate)
+var skipVersionCheck bool
+
+// checkToolVersions fails unless the tools pinned in .shadow.yaml, other than
+// skip, are installed at the pinned versions
+func checkToolVersions(cfg *config.Config, skip ...string) error {
+	if skipVersionCheck || len(cfg.Tools) == 0 {
+		return nil
+	}
+	if err := doctor.CheckPins(cfg.Tools, skip...); err != nil {
+		return &exitError{code: ExitToolMissing, err: err}
+	}
+	return nil
+}
+
+// applyStrictness re-rates findings on paths added since strictness.since,
+// warning rather than failing when git history isn't available
+func applyStrictness(results []validate.Result, cfg *config.Config) []validate.Result {
+	results, err := validate.ApplyStrictness(results, cfg, repoDir)
+	if err != nil {
+		log.Default().Warnf("Progressive strictness not applied: %v", err)
+	}
+	return results
+}
+
+// setup runs before every command: logging, --fail-on, the command timeout,
+// downloaded tools, then the Application index
+func setup(cmd *cobra.Command, args []string) error {
+	if err := setupLogging(cmd, args); err != nil {
+		return err
+	}
+	if err := setFailOn(cmd); err != nil {
+		return err
+	}
+	if cmdTimeout < 0 {
+		return fmt.Errorf("--cmd-timeout must not be negative: %s", cmdTimeout)
+	}
+	command.Timeout = cmdTimeout
+	if err := setupTracing(cmd); err != nil {
+		return err
+	}
+	if downloadTools {
+		if err := useDownloadedTools(); err != nil {
+			return err
+		}
+	}
+	argocd.IndexDir = appIndexDir
+	if noAppIndex {
+		argocd.IndexDir = ""
+	}
+	return nil
+}
+
+// runSpan is the root span of a traced run; every other span nests under it
+var runSpan *trace.Span
+
+// setupTracing starts exporting spans when OTEL_EXPORTER_OTLP_ENDPOINT is set
+func setupTracing(cmd *cobra.Command) error {
+	enabled, err := trace.Setup(Version)
+	if err != nil || !enabled {
+		return err
+	}
+	_, runSpan = trace.Start(context.Background(), cmd.CommandPath())
+	logVerbose("Tracing %s to the OTLP collector", cmd.CommandPath())
+	return nil
+}
+
+// setupLogging configures the shared logger from --log-level, --log-format, and --verbose
+func setupLogging(cmd *cobra.Command, args []string) error {
+	level, err := log.ParseLevel(logLevel)
+	if err != nil {
+		return err
+	}
+	format, err := log.ParseFormat(logFormat)
+	if err != nil {
+		return err
+	}
 	if verbose {
-		fmt.Fprintf(os.Stderr, "[shadow] "+format+"\n", args...)
+		level = min(level, log.LevelDebug)
 	}
+	log.SetDefault(log.New(os.Stderr, level, format))
+	return nil
+}
+
+func logVerbose(format string, args ...interface{}) {
+	log.Default().Named("shadow").Debugf(format, args...)
 }
 
 func logInfo(format string, args ...interface{}) {
-	fmt.Fprintf(os.Stderr, format+"\n", args...)
+	log.Default().Infof(format, args...)
 }
@@ -1,27 +1,68 @@
 package cmd
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"os"
 	"strings"
+	"time"
 
+	"github.com/erauner/homelab-shadow/pkg/config"
+	"github.com/erauner/homelab-shadow/pkg/github"
+	"github.com/erauner/homelab-shadow/pkg/helm"
+	"github.com/erauner/homelab-shadow/pkg/hint"
+	"github.com/erauner/homelab-shadow/pkg/kustomize"
+	"github.com/erauner/homelab-shadow/pkg/kyverno"
+	"github.com/erauner/homelab-shadow/pkg/log"
+	"github.com/erauner/homelab-shadow/pkg/metrics"
+	"github.com/erauner/homelab-shadow/pkg/output"
 	"github.com/erauner/homelab-shadow/pkg/sync"
 	"github.com/spf13/cobra"
 )
 
 var (
 	syncShadowRepo    string
+	syncPublish       string
+	syncPublishTo     string
+	syncSign          string
+	syncSigningKey    string
+	syncProvenance    bool
 	syncBaseBranch    string
 	syncBranch        string
 	syncCluster       string
 	syncOutputFormat  string
 	syncForcePush     bool
 	syncRedactSecrets bool
+	syncAllowFindings bool
 	syncCleanupMerged bool
 	syncPRNumber      string
 	syncSourceCommit  string
 	syncSourceRepo    string
+	syncEngine        string
+	syncChartCacheDir string
+	syncNoChartCache  bool
+	syncHelmCreds     string
+	syncDryRun        bool
+	syncOutDir        string
+	syncValidate      bool
+	syncK8sVersion    string
+	syncRequireAck    bool
+	syncKeepFailed    bool
+	syncNormalize     bool
+	syncOutputLayout  string
+	syncTarget        string
+	syncProvider      string
+	syncGitRetries    int
+	syncGitRetryDelay time.Duration
+	syncBudget        time.Duration
+	syncRecord        string
+	syncPolicyImpact  string
+	syncMetricsFile   string
+	syncPushgateway   string
+	syncMetricsJob    string
+	syncCheck         bool
+	syncSystemGit     bool
 )
 
 var syncCmd = &cobra.Command{
@@ -35,7 +76,126 @@ The shadow repo stores rendered manifests organized by their source path:
   rendered/apps/giraffe/overlays/production/manifest.yaml
   rendered/infrastructure/envoy-gateway/overlays/erauner-home/manifest.yaml
 
+Run with --target base on every merge to the source repo's main branch: the
+merged commit is rendered straight into --base-branch (force-pushed with
+--force), so PR branches are always compared against the current state
+instead of a stale snapshot. No compare URL is printed for base syncs.
+
+Output can be split across several roots with "outputs" in .shadow.yaml, each
+selecting sources (kustomize, helm), an include list, and a layout (mirror or
+by-cluster). Sync compares per file against the shadow branch: unchanged
+manifests are left untouched and files in a root that are no longer rendered
+are pruned, so commits only contain directories that differ. Output paths must
+be subdirectories of the shadow repo (never "." or .git).
+
+To push each cluster's manifests to its own shadow repo (smaller diffs,
+tighter access control), map clusters to repos with "shadowRepos" in
+.shadow.yaml. Sync then clones, renders, commits, and pushes every repo on its
+own, with the same branch naming; directories belonging to no cluster (legacy
+flat overlays, Helm apps) go to repos marked shared. A dry run renders each
+repo into a subdirectory of --out named after it. --shadow-repo overrides the
+mapping and sends everything to one repo.
+
+Where git pushes aren't possible, --publish stores the output elsewhere,
+keyed by branch (pr-<number>): --publish dir --publish-to <dir> keeps it in
+<dir>/<branch> on the local filesystem, and --publish s3 --publish-to
+s3://bucket/prefix (aws CLI) or gs://bucket/prefix (gsutil) in the bucket under
+<prefix>/<branch>. A new branch starts from the --base-branch output, so
+changes and pruning work as with git; there is no commit or compare URL.
+
+With --output-layout split, each rendered directory holds one file per
+resource instead of a single manifest.yaml, named <kind>-<namespace>-<name>.yaml
+(<kind>-<name>.yaml for cluster-scoped resources), so diffs show exactly which
+resources changed:
+  rendered/apps/giraffe/overlays/production/deployment-giraffe-web.yaml
+  rendered/apps/giraffe/overlays/production/service-giraffe-web.yaml
+
+With --budget, sync stops rendering once the time since it started exceeds
+the budget: the directory being rendered finishes, every remaining directory
+keeps its previously published manifest (listed under budget_skipped in
+_meta.json), and the partial result is committed and pushed as usual. The run
+then exits with code 3, so CI can flag the PR instead of timing out mid-push.
+
+With --policy-impact <ref>, the Kyverno policies changed since the git ref are
+applied at both versions to every rendered resource with kyverno apply, and the
+resources that newly violate (or stop violating) a changed policy are written
+to _policy-impact.md in the output root, so the blast radius of a policy change
+shows up in the shadow diff. Requires the kyverno CLI.
+
+With --record, sync also writes a bundle of the run (.tar.gz, or .tar.zst with
+the zstd CLI): the source repo's files, the options that affect rendering, tool
+versions, the SHADOW_/HELM_/GIT_/CI environment (secret-looking values
+redacted), and every target's resolved inputs and published manifest. Archive
+it from CI and run shadow replay on it to reproduce a failure locally.
+
+For supply-chain hygiene, --sign gpg or --sign ssh signs every shadow repo
+commit with --signing-key (a GPG key ID, or an SSH key file or key::<public
+key> for a key held by ssh-agent), and --provenance writes an in-toto
+statement with a SLSA v1 provenance predicate to _provenance.json in each
+output root. It records the source repo and commit, the options that change
+the output, tool versions, and the digest of every directory rendered this
+run (the sha256 _meta.json lists), and is committed alongside the manifests.
+
+With --keep-failed, a directory that fails to render keeps its previous
+manifest instead of being pruned, so a broken build shows up as a failure
+rather than as the deletion of every resource it rendered.
+
+Manifests are normalized before they are written (disable with --normalize=false):
+resources are sorted by group, version, kind, namespace, and name, and volatile
+annotations such as checksum/config are stripped, so reordered or re-hashed
+output doesn't show up as a change. "normalization" in .shadow.yaml adds
+annotations to strip and can stabilize kustomize generator hash suffixes.
+
+With --metrics-file, run metrics (total and per-phase durations, targets by
+result, chart cache hits and misses, changed files) are written in the
+OpenMetrics text format, e.g. as a CI artifact; --pushgateway pushes the same
+metrics to a Prometheus Pushgateway, grouped by --metrics-job and the PR
+number. Metrics are emitted for failed runs too, with shadow_sync_success 0.
+
+With --output markdown, the summary is printed to stdout as a PR-comment-ready
+table (failures, gated changes, deprecated APIs, and the compare link);
+--output template='{{...}}' renders a Go template over the same result the json
+output encodes, e.g. --output template='{{.RenderedDirs}} rendered, {{.FailedDirs}} failed'.
+
+With --check, the result is also reported as a "shadow/diff" GitHub check run
+on --source-commit of --source-repo: the markdown summary, each failure
+annotated on the kustomization.yaml (or values.yaml) of its directory, and the
+compare URL as the details link. Failures fail the check; deprecated APIs or an
+exceeded budget make it neutral, and a sync that errors reports a failed check
+with the error. GitHub only lets GitHub App installation tokens create check
+runs, so GH_TOKEN must be one with the checks write permission, such as the
+GITHUB_TOKEN of a GitHub Actions job with checks: write; personal access
+tokens are rejected.
+
 Security: Secrets are automatically redacted to prevent exposing sensitive data.
+Every rendered manifest is then scanned for credentials that escaped redaction
+through ConfigMaps, env vars, or annotations: AWS access keys, GitHub, GitLab,
+and Slack tokens, private keys (also base64-encoded), and high-entropy
+strings. Findings (file and line) block the commit unless --allow-findings is
+set. Certificates and CA bundles are not reported.
+
+With --validate, every rendered manifest is also checked with kubeconform.
+Schema failures are reported alongside build failures; the manifest is still
+published so the diff shows what was rendered.
+
+Some changes are gated: removing or changing the schema of a CRD (crd-change)
+and removing a Namespace (namespace-deletion). With --require-ack, sync refuses
+to commit gated changes until a reviewer acknowledges them on the source PR,
+either with a "/shadow ack <gate>" comment (owners, members, collaborators) or
+a shadow-ack/<gate> label. Acknowledgments are recorded in _meta.json.
+
+The shadow repo can live on GitHub, GitLab, or Gitea. The provider is detected
+from the --shadow-repo URL (hosts containing gitlab, gitea, codeberg, or
+forgejo; GitHub otherwise) or set with --provider, and decides the compare URL
+and the API --cleanup-merged uses for PR/MR state. Tokens are read from
+GH_TOKEN, GITLAB_TOKEN, or GITEA_TOKEN. A --source-repo slug is assumed to be
+on the same host as the shadow repo.
+
+The shadow repo is cloned, committed to, and pushed with a built-in git
+implementation, so no git binary is needed (HTTPS remotes authenticate with
+the provider token, SSH remotes with ssh-agent). --use-system-git runs the
+git binary instead, using its credential helpers and config; signed commits
+(--sign) always do.
 
 Example usage:
   # Basic usage with PR number
@@ -48,29 +208,98 @@ Example usage:
   shadow sync --shadow-repo erauner/homelab-k8s-shadow --pr 950 --output json
 
   # Sync specific cluster only
-  shadow sync --shadow-repo erauner/homelab-k8s-shadow --cluster erauner-home`,
+  shadow sync --shadow-repo erauner/homelab-k8s-shadow --cluster erauner-home
+
+  # Schema-validate rendered manifests with kubeconform
+  shadow sync --shadow-repo erauner/homelab-k8s-shadow --pr 950 --validate
+
+  # Report the result as a shadow/diff check on the source commit
+  shadow sync --shadow-repo erauner/homelab-k8s-shadow --pr 950 --source-repo erauner/homelab-k8s --source-commit $GIT_COMMIT --check
+
+  # Block CRD changes and namespace deletions until acknowledged on the PR
+  shadow sync --shadow-repo erauner/homelab-k8s-shadow --pr 950 --require-ack
+
+  # Self-hosted GitLab shadow repo
+  shadow sync --shadow-repo https://gitlab.example.com/infra/k8s-shadow.git --pr 950
+
+  # Stay well inside a 10 minute CI timeout
+  shadow sync --shadow-repo erauner/homelab-k8s-shadow --pr 950 --budget 5m
+
+  # Report which rendered resources a PR's policy changes affect
+  shadow sync --shadow-repo erauner/homelab-k8s-shadow --pr 950 --policy-impact origin/main
+
+  # Signed commits with a provenance attestation
+  shadow sync --shadow-repo erauner/homelab-k8s-shadow --pr 950 --sign ssh --signing-key ~/.ssh/shadow_ed25519 --provenance
+
+  # Keep a replayable record of a CI run
+  shadow sync --shadow-repo erauner/homelab-k8s-shadow --pr 950 --record shadow-run.tar.zst
+
+  # Publish to a bucket instead of a shadow repo
+  shadow sync --publish s3 --publish-to s3://shadow-manifests/homelab --pr 950
+
+  # Render locally and show what would be committed (no clone, commit, or push)
+  shadow sync --dry-run --out ./rendered-local`,
 	RunE: runSync,
 }
 
 func init() {
 	rootCmd.AddCommand(syncCmd)
 
-	syncCmd.Flags().StringVar(&syncShadowRepo, "shadow-repo", "", "Shadow repository (owner/repo or git URL) - required")
+	syncCmd.Flags().StringVar(&syncShadowRepo, "shadow-repo", "", "Shadow repository (owner/repo or git URL) - required unless --dry-run or shadowRepos is configured")
+	syncCmd.Flags().StringVar(&syncPublish, "publish", sync.PublishGit, "Where to publish: git (push to the shadow repo), dir, or s3 (s3:// or gs:// bucket)")
+	syncCmd.Flags().StringVar(&syncPublishTo, "publish-to", "", "Directory or bucket URL for --publish dir or s3 (output goes under <branch>)")
 	syncCmd.Flags().StringVar(&syncBaseBranch, "base-branch", "main", "Base branch in shadow repo")
 	syncCmd.Flags().StringVar(&syncBranch, "branch", "", "Target branch (default: pr-<number> or local-<timestamp>)")
+	syncCmd.Flags().StringVar(&syncTarget, "target", sync.SyncTargetPR, "What to update: pr (a branch compared against --base-branch) or base (--base-branch itself, run on merges)")
 	syncCmd.Flags().StringVar(&syncCluster, "cluster", "", "Specific cluster to sync (default: all)")
-	syncCmd.Flags().StringVar(&syncOutputFormat, "output", "text", "Output format: text or json")
+	syncCmd.Flags().StringVar(&syncOutputFormat, "output", "text", "Output format: text, json, markdown, template='{{...}}', or template-file=<path>")
 	syncCmd.Flags().BoolVar(&syncForcePush, "force", true, "Force push to branch (default: true)")
 	syncCmd.Flags().BoolVar(&syncRedactSecrets, "redact-secrets", true, "Redact Secret data (default: true)")
+	syncCmd.Flags().BoolVar(&syncAllowFindings, "allow-findings", false, "Publish manifests the secret scan flags, reporting the findings as warnings")
 	syncCmd.Flags().BoolVar(&syncCleanupMerged, "cleanup-merged", false, "Delete pr-* branches for closed/merged PRs")
 	syncCmd.Flags().StringVar(&syncPRNumber, "pr", "", "PR number (used for branch naming and metadata)")
 	syncCmd.Flags().StringVar(&syncSourceCommit, "source-commit", "", "Source commit SHA (for metadata)")
 	syncCmd.Flags().StringVar(&syncSourceRepo, "source-repo", "", "Source repository (for metadata)")
-
-	syncCmd.MarkFlagRequired("shadow-repo")
+	syncCmd.Flags().StringVar(&syncChartCacheDir, "chart-cache-dir", helm.DefaultCacheDir(), "Helm chart cache directory")
+	syncCmd.Flags().StringVar(&syncHelmCreds, "helm-creds-file", "", "YAML file of credentials for private Helm repositories (see shadow helm test --help)")
+	syncCmd.Flags().BoolVar(&syncNoChartCache, "no-chart-cache", false, "Always download Helm charts instead of using the chart cache")
+	syncCmd.Flags().StringVar(&syncEngine, "kustomize-engine", "exec", "Kustomize build engine: exec, krusty (in-process), or auto")
+	syncCmd.Flags().BoolVar(&syncDryRun, "dry-run", false, "Render into --out and print the would-be commit instead of pushing")
+	syncCmd.Flags().StringVar(&syncOutDir, "out", "", "Local output directory for --dry-run (stale files are pruned, so it must be empty or hold a previous run's _meta.json; holds each configured output root)")
+	syncCmd.Flags().BoolVar(&syncValidate, "validate", false, "Validate rendered manifests with kubeconform and report schema failures")
+	syncCmd.Flags().StringVar(&syncK8sVersion, "kubernetes-version", "", "Kubernetes version for --validate schemas and API deprecation checks (default: 1.31.0)")
+	syncCmd.Flags().BoolVar(&syncKeepFailed, "keep-failed", false, "Keep the previous manifest of directories that fail to render instead of pruning them")
+	addFailOnFlag(syncCmd, "none")
+	syncCmd.Flags().StringVar(&syncMetricsFile, "metrics-file", "", "Write run metrics (durations per phase, target counts, chart cache hits) to this OpenMetrics text file")
+	syncCmd.Flags().StringVar(&syncPushgateway, "pushgateway", "", "Push run metrics to this Prometheus Pushgateway URL, grouped by --metrics-job and PR")
+	syncCmd.Flags().StringVar(&syncMetricsJob, "metrics-job", "shadow_sync", "Pushgateway job name for --pushgateway")
+	syncCmd.Flags().DurationVar(&syncBudget, "budget", 0, "Stop rendering after this long, keep previous manifests for the rest, and exit 3 (0 = unlimited)")
+	syncCmd.Flags().StringVar(&syncPolicyImpact, "policy-impact", "", "Report resources that newly violate Kyverno policies changed since this git ref (requires kyverno)")
+	syncCmd.Flags().StringVar(&syncRecord, "record", "", "Write a bundle of the run's inputs and outputs for shadow replay (.tar.gz or .tar.zst)")
+	syncCmd.Flags().BoolVar(&syncNormalize, "normalize", true, "Sort resources and strip volatile annotations before writing manifests")
+	syncCmd.Flags().StringVar(&syncOutputLayout, "output-layout", sync.OutputLayoutSingle, "Rendered file layout: single (manifest.yaml per directory) or split (one file per resource)")
+	syncCmd.Flags().BoolVar(&syncSystemGit, "use-system-git", false, "Run the git binary for shadow repo clones, commits, and pushes instead of the built-in git implementation")
+	syncCmd.Flags().IntVar(&syncGitRetries, "git-retries", 2, "Retries for clone, fetch, and push after transient network failures")
+	syncCmd.Flags().DurationVar(&syncGitRetryDelay, "git-retry-delay", 2*time.Second, "Delay before the first git retry (doubles after each)")
+	syncCmd.Flags().StringVar(&syncProvider, "provider", "", "Git hosting provider: github, gitlab, or gitea (default: detected from --shadow-repo)")
+	syncCmd.Flags().StringVar(&syncSign, "sign", "", "Sign shadow repo commits: gpg or ssh (default: unsigned)")
+	syncCmd.Flags().StringVar(&syncSigningKey, "signing-key", "", "Key for --sign: GPG key ID (default: the committer's), or SSH key file or key::<public key>")
+	syncCmd.Flags().BoolVar(&syncProvenance, "provenance", false, "Write an in-toto SLSA provenance attestation (_provenance.json) into each output root")
+	syncCmd.Flags().BoolVar(&syncRequireAck, "require-ack", false, "Refuse gated changes (crd-change, namespace-deletion) not acknowledged on the PR (reads comments and labels; uses GH_TOKEN)")
+	syncCmd.Flags().BoolVar(&syncCheck, "check", false, "Report the result as a shadow/diff GitHub check run on --source-commit of --source-repo (GH_TOKEN must be a GitHub App installation token)")
+	syncCmd.Flags().BoolVar(&skipVersionCheck, "skip-version-check", false, "Run even if tools don't match the versions pinned in .shadow.yaml")
 }
 
 func runSync(cmd *cobra.Command, args []string) error {
+	format, err := output.Parse(syncOutputFormat, "text", "json", "markdown")
+	if err != nil {
+		return err
+	}
+
+	if syncDryRun && syncOutDir == "" {
+		return fmt.Errorf("--out is required with --dry-run")
+	}
+
 	// Build clusters list
 	var clusters []string
 	if syncCluster != "" {
@@ -89,55 +318,236 @@ func runSync(cmd *cobra.Command, args []string) error {
 		sourceCommit = os.Getenv("GIT_COMMIT")
 	}
 
-	// Get source repo from environment if not specified
-	sourceRepo := syncSourceRepo
-	if sourceRepo == "" {
-		sourceRepo = os.Getenv("GIT_URL")
-		// Try to extract slug from URL
-		if sourceRepo != "" {
-			if slug, err := sync.ParseRepoSlug(sourceRepo); err == nil {
-				sourceRepo = slug
-			}
+	sourceRepo := sourceRepoOrEnv(syncSourceRepo)
+
+	var checks *github.Client
+	if syncCheck {
+		if checks, err = checkRunClient(sourceRepo, sourceCommit); err != nil {
+			return err
+		}
+	}
+
+	engine, err := kustomize.ParseEngine(syncEngine)
+	if err != nil {
+		return err
+	}
+
+	provider, err := sync.ParseProvider(syncProvider)
+	if err != nil {
+		return err
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	// The krusty engine builds in-process, so the kustomize binary isn't used
+	var unused []string
+	if engine == kustomize.EngineKrusty {
+		unused = append(unused, "kustomize")
+	}
+	if err := checkToolVersions(cfg, unused...); err != nil {
+		return err
+	}
+
+	// --shadow-repo sends everything to one repo, overriding shadowRepos
+	var shadowRepos []config.ShadowRepo
+	if syncShadowRepo == "" && syncPublish == sync.PublishGit {
+		shadowRepos = cfg.ShadowRepos
+		if len(shadowRepos) == 0 && !syncDryRun {
+			return fmt.Errorf("--shadow-repo is required (or shadowRepos in %s, or use --dry-run --out <dir>)", config.FileName)
 		}
 	}
 
 	opts := sync.Options{
-		RepoPath:      repoDir,
-		Clusters:      clusters,
-		ShadowRepo:    syncShadowRepo,
-		BaseBranch:    syncBaseBranch,
-		Branch:        syncBranch,
-		ForcePush:     syncForcePush,
-		RedactSecrets: syncRedactSecrets,
-		CleanupMerged: syncCleanupMerged,
-		PRNumber:      prNumber,
-		SourceCommit:  sourceCommit,
-		SourceRepo:    sourceRepo,
-		Verbose:       verbose,
+		RepoPath:            repoDir,
+		Clusters:            clusters,
+		ShadowRepo:          syncShadowRepo,
+		ShadowRepos:         shadowRepos,
+		Publish:             syncPublish,
+		PublishTo:           syncPublishTo,
+		Provider:            provider,
+		BaseBranch:          syncBaseBranch,
+		Branch:              syncBranch,
+		SyncTarget:          syncTarget,
+		Outputs:             cfg.Outputs,
+		GitRetries:          syncGitRetries,
+		GitRetryDelay:       syncGitRetryDelay,
+		DisableForcePush:    !syncForcePush,
+		DisableRedaction:    !syncRedactSecrets,
+		AllowSecretFindings: syncAllowFindings,
+		Redaction:           cfg.Redaction,
+		CleanupMerged:       syncCleanupMerged,
+		KeepFailed:          syncKeepFailed,
+		Budget:              syncBudget,
+		Record:              syncRecord,
+		PolicyImpactBase:    syncPolicyImpact,
+		KyvernoPolicyRoots:  cfg.Policies.Kyverno,
+		Version:             Version,
+		DisableNormalize:    !syncNormalize,
+		OutputLayout:        syncOutputLayout,
+		Normalization:       cfg.Normalization,
+		KustomizeEngine:     engine,
+		HelmCacheDir:        chartCacheDir(syncChartCacheDir, syncNoChartCache),
+		HelmCredentialsFile: syncHelmCreds,
+		ValidateSchemas:     syncValidate,
+		KubernetesVersion:   syncK8sVersion,
+		PRNumber:            prNumber,
+		SourceCommit:        sourceCommit,
+		SourceRepo:          sourceRepo,
+		RequireAck:          syncRequireAck,
+		UseSystemGit:        syncSystemGit,
+		SignCommits:         syncSign,
+		SigningKey:          syncSigningKey,
+		Provenance:          syncProvenance,
+		DryRun:              syncDryRun,
+		OutDir:              syncOutDir,
+		Progress:            showProgress(),
+		Verbose:             verbose,
 	}
 
 	syncer, err := sync.New(opts)
 	if err != nil {
-		return fmt.Errorf("failed to initialize syncer: %w", err)
+		err = fmt.Errorf("failed to initialize syncer: %w", err)
+		reportCheckFailure(cmd.Context(), checks, err, sourceRepo, sourceCommit)
+		return err
 	}
 
-	logInfo("Starting shadow sync...")
-	logVerbose("Shadow repo: %s", syncShadowRepo)
+	if syncDryRun {
+		logInfo("Starting shadow sync dry run into %s...", syncOutDir)
+	} else {
+		logInfo("Starting shadow sync...")
+	}
+	if len(shadowRepos) > 0 {
+		for _, repo := range shadowRepos {
+			logVerbose("Shadow repo: %s (clusters: %s)", repo.Repo, strings.Join(repo.Clusters, ", "))
+		}
+	} else if syncPublish != sync.PublishGit {
+		logVerbose("Publishing to %s (%s)", syncPublishTo, syncPublish)
+	} else {
+		logVerbose("Shadow repo: %s", syncShadowRepo)
+	}
 	logVerbose("Base branch: %s", syncBaseBranch)
 	if syncBranch != "" {
 		logVerbose("Target branch: %s", syncBranch)
 	}
 
-	result, err := syncer.Run()
+	start := time.Now()
+	result, err := syncer.RunContext(cmd.Context())
+	emitSyncMetrics(result, time.Since(start), err, prNumber)
 	if err != nil {
-		return fmt.Errorf("sync failed: %w", err)
+		err = fmt.Errorf("sync failed: %w", err)
+		reportCheckFailure(cmd.Context(), checks, err, sourceRepo, sourceCommit)
+		return err
 	}
 
 	// Output results
-	if strings.ToLower(syncOutputFormat) == "json" {
-		return outputSyncJSON(result)
+	switch format.Name {
+	case "json":
+		err = outputSyncJSON(result)
+	case "markdown":
+		fmt.Print(result.Markdown())
+	case "template":
+		err = format.Execute(os.Stdout, result)
+	default:
+		err = outputSyncText(result)
+	}
+	if err != nil {
+		return err
+	}
+	if checks != nil {
+		if err := reportCheckRun(cmd.Context(), checks, result.CheckRun(repoDir, sourceCommit), sourceRepo); err != nil {
+			return err
+		}
+	}
+	if result.BudgetExceeded {
+		return &exitError{
+			code: ExitBudgetExceeded,
+			err:  fmt.Errorf("budget of %s exceeded: %d target(s) not rendered", syncBudget, len(result.BudgetSkipped)),
+		}
+	}
+	return syncFindings(result)
+}
+
+// emitSyncMetrics writes --metrics-file and pushes to --pushgateway, warning
+// rather than failing the sync when either can't be written
+func emitSyncMetrics(result sync.Result, took time.Duration, runErr error, prNumber string) {
+	if syncMetricsFile == "" && syncPushgateway == "" {
+		return
+	}
+	set := result.Metrics(took, runErr)
+	var grouping []string
+	if prNumber != "" {
+		set.Labels = map[string]string{"pr": prNumber}
+		grouping = []string{"pr", prNumber}
+	}
+	if syncMetricsFile != "" {
+		if err := set.WriteFile(syncMetricsFile); err != nil {
+			log.Default().Warnf("%v", err)
+		} else {
+			logVerbose("Wrote metrics to %s", syncMetricsFile)
+		}
+	}
+	if syncPushgateway != "" {
+		if err := metrics.Push(syncPushgateway, syncMetricsJob, set, grouping...); err != nil {
+			log.Default().Warnf("%v", err)
+		} else {
+			logVerbose("Pushed metrics to %s", syncPushgateway)
+		}
+	}
+}
+
+// checkRunClient returns the GitHub client --check reports with, failing
+// before anything is rendered when the check has nowhere to go
+func checkRunClient(sourceRepo, sourceCommit string) (*github.Client, error) {
+	if sourceRepo == "" || sourceCommit == "" {
+		return nil, fmt.Errorf("--check requires --source-repo and --source-commit (or GIT_URL and GIT_COMMIT)")
+	}
+	client, err := github.NewClient()
+	if err != nil {
+		return nil, fmt.Errorf("--check requires a GitHub token: %w", err)
+	}
+	return client, nil
+}
+
+// reportCheckRun creates the shadow/diff check run on the source repo
+func reportCheckRun(ctx context.Context, client *github.Client, run github.CheckRun, sourceRepo string) error {
+	slug, err := sync.ParseRepoSlug(sourceRepo)
+	if err != nil {
+		return fmt.Errorf("--check: %w", err)
+	}
+	ref, err := client.CreateCheckRun(ctx, slug, run)
+	if err != nil {
+		return err
+	}
+	logInfo("Reported %s check (%s): %s", run.Name, run.Conclusion, ref.HTMLURL)
+	return nil
+}
+
+// reportCheckFailure reports a sync that failed with runErr as a failed
+// shadow/diff check (with --check), warning rather than masking runErr when
+// the check can't be created
+func reportCheckFailure(ctx context.Context, client *github.Client, runErr error, sourceRepo, sourceCommit string) {
+	if client == nil {
+		return
+	}
+	if err := reportCheckRun(ctx, client, sync.FailedCheckRun(sourceCommit, runErr), sourceRepo); err != nil {
+		log.Default().Warnf("%v", err)
 	}
-	return outputSyncText(result)
+}
+
+// syncFindings applies --fail-on (default none: sync publishes failures
+// rather than failing on them) to failed directories, schema failures, and
+// deprecated APIs
+func syncFindings(result sync.Result) error {
+	var errs, warns error
+	if result.FailedDirs > 0 || result.SchemaFailures > 0 {
+		errs = fmt.Errorf("%d director(ies) failed to render, %d manifest(s) failed schema validation", result.FailedDirs, result.SchemaFailures)
+	}
+	if len(result.DeprecatedAPIs) > 0 {
+		warns = fmt.Errorf("%d rendered resource(s) use deprecated APIs", len(result.DeprecatedAPIs))
+	}
+	return failFindings(errs, warns)
 }
 
 func outputSyncJSON(result sync.Result) error {
@@ -150,48 +560,242 @@ func outputSyncJSON(result sync.Result) error {
 }
 
 func outputSyncText(result sync.Result) error {
+	if len(result.Repos) > 0 {
+		for _, repo := range result.Repos {
+			if err := outputSyncText(repo); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if result.DryRun {
+		return outputSyncDryRunText(result)
+	}
+
 	fmt.Fprintf(os.Stderr, "\n=== Shadow Sync Complete ===\n")
-	fmt.Fprintf(os.Stderr, "Shadow repo: %s\n", result.ShadowRepoSlug)
+	if result.ShadowRepoSlug != "" {
+		fmt.Fprintf(os.Stderr, "Shadow repo: %s\n", result.ShadowRepoSlug)
+	}
 	fmt.Fprintf(os.Stderr, "Branch: %s (base: %s)\n", result.Branch, result.BaseBranch)
 	fmt.Fprintf(os.Stderr, "\n")
 	fmt.Fprintf(os.Stderr, "Rendered: %d directories\n", result.RenderedDirs)
 	fmt.Fprintf(os.Stderr, "Skipped:  %d directories\n", result.SkippedDirs)
 	fmt.Fprintf(os.Stderr, "Failed:   %d directories\n", result.FailedDirs)
+	if result.SchemaFailures > 0 {
+		fmt.Fprintf(os.Stderr, "Schema:   %d manifests failed kubeconform\n", result.SchemaFailures)
+	}
+	if result.PrunedFiles > 0 {
+		fmt.Fprintf(os.Stderr, "Pruned:   %d stale files\n", result.PrunedFiles)
+	}
+	if result.KeptFiles > 0 {
+		fmt.Fprintf(os.Stderr, "Kept:     %d previous manifests of failed or skipped directories\n", result.KeptFiles)
+	}
+	printBudgetSkipped(result)
+	printPolicyImpact(result.PolicyImpact)
+	printDeprecatedAPIs(result.DeprecatedAPIs)
 
 	if len(result.Failures) > 0 {
 		fmt.Fprintf(os.Stderr, "\nFailures:\n")
 		for _, f := range result.Failures {
 			fmt.Fprintf(os.Stderr, "  - %s: %s\n", f.Directory, f.Error)
+			if h := hint.For(f.Error); h != "" {
+				fmt.Fprintf(os.Stderr, "    hint: %s\n", h)
+			}
 		}
 	}
 
+	if result.Changes != nil && len(result.Changes.Resources) > 0 {
+		fmt.Fprintf(os.Stderr, "\nResources: %s\n", result.Changes.Headline())
+	}
+	printSyncGates(result)
+	printSecretFindings(result.SecretFindings)
+
 	if result.CommitSHA != "" {
 		fmt.Fprintf(os.Stderr, "\nCommit: %s\n", result.CommitSHA)
 	}
 
-	fmt.Fprintf(os.Stderr, "\n📋 Compare URL:\n%s\n", result.CompareURL)
+	if result.Location != "" {
+		fmt.Fprintf(os.Stderr, "\nPublished to: %s\n", result.Location)
+	}
+
+	if result.CompareURL != "" {
+		fmt.Fprintf(os.Stderr, "\n📋 Compare URL:\n%s\n", result.CompareURL)
+	}
 
 	// Show cleanup results if present
 	if result.Cleanup != nil {
-		fmt.Fprintf(os.Stderr, "\n=== Branch Cleanup ===\n")
-		fmt.Fprintf(os.Stderr, "Checked:  %d branches\n", len(result.Cleanup.CheckedBranches))
-		fmt.Fprintf(os.Stderr, "Deleted:  %d branches\n", len(result.Cleanup.DeletedBranches))
-		fmt.Fprintf(os.Stderr, "Skipped:  %d branches (PRs still open)\n", len(result.Cleanup.SkippedBranches))
-
-		if len(result.Cleanup.DeletedBranches) > 0 {
-			fmt.Fprintf(os.Stderr, "\nDeleted branches:\n")
-			for _, b := range result.Cleanup.DeletedBranches {
-				fmt.Fprintf(os.Stderr, "  - %s\n", b)
-			}
+		printCleanupResult(*result.Cleanup, false)
+	}
+
+	return nil
+}
+
+// printBudgetSkipped lists directories left unrendered when --budget ran out
+func printBudgetSkipped(result sync.Result) {
+	if !result.BudgetExceeded {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "\n⏱️  Budget exceeded, not rendered (previous manifests kept):\n")
+	for _, dir := range result.BudgetSkipped {
+		fmt.Fprintf(os.Stderr, "  - %s\n", dir)
+	}
+}
+
+// printPolicyImpact summarizes the Kyverno policy impact report
+func printPolicyImpact(report *kyverno.ImpactReport) {
+	if report == nil || len(report.Policies) == 0 {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "\n🛡️  Policy impact since %s: %d new violation(s), %d resolved (see %s)\n",
+		report.Base, report.NewViolations(), report.Resolved(), sync.PolicyImpactFile)
+	for _, p := range report.Policies {
+		for _, v := range p.NewViolations {
+			fmt.Fprintf(os.Stderr, "  + %s %s/%s: %s\n", v.Resource(), v.Policy, v.Rule, strings.Join(v.Dirs, ", "))
 		}
+		for _, v := range p.Resolved {
+			fmt.Fprintf(os.Stderr, "  - %s %s/%s: %s\n", v.Resource(), v.Policy, v.Rule, strings.Join(v.Dirs, ", "))
+		}
+	}
+}
+
+// printSecretFindings lists possible secrets published with --allow-findings
+// printDeprecatedAPIs lists resources using APIs deprecated in the --kubernetes-version
+func printDeprecatedAPIs(findings []sync.DeprecatedAPIFinding) {
+	if len(findings) == 0 {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "\n⚠️  Deprecated APIs (api-deprecated):\n")
+	for _, f := range findings {
+		fmt.Fprintf(os.Stderr, "  - %s\n", f)
+	}
+}
 
-		if len(result.Cleanup.Errors) > 0 {
-			fmt.Fprintf(os.Stderr, "\nCleanup errors:\n")
-			for _, e := range result.Cleanup.Errors {
-				fmt.Fprintf(os.Stderr, "  - %s\n", e)
+func printSecretFindings(findings []sync.SecretFinding) {
+	if len(findings) == 0 {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "\n⚠️  Possible secrets (published with --allow-findings):\n")
+	for _, f := range findings {
+		fmt.Fprintf(os.Stderr, "  - %s\n", f)
+	}
+}
+
+// sourceRepoOrEnv returns the --source-repo flag, or the slug of $GIT_URL
+func sourceRepoOrEnv(flag string) string {
+	if flag != "" {
+		return flag
+	}
+	sourceRepo := os.Getenv("GIT_URL")
+	// Try to extract slug from URL
+	if sourceRepo != "" {
+		if slug, err := sync.ParseRepoSlug(sourceRepo); err == nil {
+			sourceRepo = slug
+		}
+	}
+	return sourceRepo
+}
+
+// printCleanupResult prints branch cleanup results to stderr
+func printCleanupResult(cleanup sync.CleanupResult, dryRun bool) {
+	deleted := "Deleted"
+	if dryRun {
+		deleted = "Would delete"
+	}
+
+	fmt.Fprintf(os.Stderr, "\n=== Branch Cleanup ===\n")
+	fmt.Fprintf(os.Stderr, "Checked:  %d branches\n", len(cleanup.CheckedBranches))
+	fmt.Fprintf(os.Stderr, "%s:  %d branches\n", deleted, len(cleanup.DeletedBranches))
+	if len(cleanup.ExpiredBranches) > 0 {
+		fmt.Fprintf(os.Stderr, "Expired:  %d branches (older than --max-age)\n", len(cleanup.ExpiredBranches))
+	}
+	fmt.Fprintf(os.Stderr, "Skipped:  %d branches (PRs still open or not expired)\n", len(cleanup.SkippedBranches))
+
+	if len(cleanup.DeletedBranches) > 0 {
+		fmt.Fprintf(os.Stderr, "\n%s branches:\n", deleted)
+		for _, b := range cleanup.DeletedBranches {
+			fmt.Fprintf(os.Stderr, "  - %s\n", b)
+		}
+	}
+
+	if len(cleanup.Errors) > 0 {
+		fmt.Fprintf(os.Stderr, "\nCleanup errors:\n")
+		for _, e := range cleanup.Errors {
+			fmt.Fprintf(os.Stderr, "  - %s\n", e)
+		}
+	}
+}
+
+func outputSyncDryRunText(result sync.Result) error {
+	fmt.Fprintf(os.Stderr, "\n=== Shadow Sync Dry Run ===\n")
+	fmt.Fprintf(os.Stderr, "Output: %s\n", result.OutputDir)
+	fmt.Fprintf(os.Stderr, "\n")
+	fmt.Fprintf(os.Stderr, "Rendered: %d directories\n", result.RenderedDirs)
+	fmt.Fprintf(os.Stderr, "Skipped:  %d directories\n", result.SkippedDirs)
+	fmt.Fprintf(os.Stderr, "Failed:   %d directories\n", result.FailedDirs)
+	if result.SchemaFailures > 0 {
+		fmt.Fprintf(os.Stderr, "Schema:   %d manifests failed kubeconform\n", result.SchemaFailures)
+	}
+	if result.PrunedFiles > 0 {
+		fmt.Fprintf(os.Stderr, "Pruned:   %d stale files\n", result.PrunedFiles)
+	}
+	if result.KeptFiles > 0 {
+		fmt.Fprintf(os.Stderr, "Kept:     %d previous manifests of failed or skipped directories\n", result.KeptFiles)
+	}
+	printBudgetSkipped(result)
+	printPolicyImpact(result.PolicyImpact)
+	printDeprecatedAPIs(result.DeprecatedAPIs)
+
+	if len(result.Failures) > 0 {
+		fmt.Fprintf(os.Stderr, "\nFailures:\n")
+		for _, f := range result.Failures {
+			fmt.Fprintf(os.Stderr, "  - %s: %s\n", f.Directory, f.Error)
+			if h := hint.For(f.Error); h != "" {
+				fmt.Fprintf(os.Stderr, "    hint: %s\n", h)
 			}
 		}
 	}
 
+	fmt.Fprintf(os.Stderr, "\nWould commit: %s\n", result.CommitMessage)
+	if result.ShadowRepoSlug != "" {
+		fmt.Fprintf(os.Stderr, "Would push:   %s %s (base: %s)\n", result.ShadowRepoSlug, result.Branch, result.BaseBranch)
+	}
+
+	changes := result.Changes
+	fmt.Fprintf(os.Stderr, "\nChanges vs previous contents of output directory: %d added, %d modified, %d removed\n",
+		len(changes.Added), len(changes.Modified), len(changes.Removed))
+	for _, path := range changes.Added {
+		fmt.Fprintf(os.Stderr, "  A %s\n", path)
+	}
+	for _, path := range changes.Modified {
+		fmt.Fprintf(os.Stderr, "  M %s\n", path)
+	}
+	for _, path := range changes.Removed {
+		fmt.Fprintf(os.Stderr, "  D %s\n", path)
+	}
+
+	if len(changes.Resources) > 0 {
+		fmt.Fprintf(os.Stderr, "\nResources: %s\n", changes.Headline())
+		for _, r := range changes.Resources {
+			fmt.Fprintf(os.Stderr, "  %-24s %s (%s)\n", strings.Join(r.Categories, ","), r.Resource, r.File)
+		}
+	}
+	printSyncGates(result)
+	printSecretFindings(result.SecretFindings)
+
 	return nil
 }
+
+// printSyncGates lists gated changes and whether each was acknowledged
+func printSyncGates(result sync.Result) {
+	if len(result.Gates) == 0 {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "\nGated changes:\n")
+	for _, g := range result.Gates {
+		status := "not acknowledged"
+		if g.Acknowledged {
+			status = "acknowledged"
+		}
+		fmt.Fprintf(os.Stderr, "  %-20s %s (%s)\n", g.Gate, g.Resource, status)
+	}
+}
@@ -0,0 +1,113 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/erauner/homelab-shadow/pkg/sync"
+	"github.com/erauner/homelab-shadow/pkg/validate"
+	"github.com/spf13/cobra"
+)
+
+var (
+	unusedCluster      string
+	unusedOutputFormat string
+	unusedRendered     string
+	unusedEngine       string
+)
+
+var unusedCmd = &cobra.Command{
+	Use:   "unused",
+	Short: "Find ConfigMaps and Secrets nothing in their cluster references",
+	Long: `Renders every deployable kustomization (the same set sync publishes) and
+reports each ConfigMap and Secret that no resource rendered for the same
+cluster references: no workload mounts it (volumes, projected sources), reads
+it (envFrom, configMapKeyRef, secretKeyRef), pulls images with it, and no
+Ingress, Gateway, or ServiceAccount uses it. Findings are config debris that
+can likely be pruned (rule unused-config, a warning).
+
+Consumers rendered manifests don't show can be excluded:
+  - annotate the resource with shadow.erauner.dev/consumed-by: <who>
+  - list labels or annotations marking consumers in .shadow.yaml
+    (unused.consumers, e.g. grafana_dashboard=1 for sidecar-loaded dashboards)
+  - ignore resources by "<namespace>/<name>" or "<name>" glob (unused.ignore)
+
+Service account tokens, Helm release Secrets, and kube-root-ca.crt are never
+reported. Directories without a cluster are only checked against each other.
+
+Use --rendered to check an existing rendered tree (e.g. a shadow repo
+checkout's rendered/ directory) instead of building.
+
+Examples:
+  shadow unused --repo /path/to/homelab-k8s
+  shadow unused --repo . --cluster erauner-home
+  shadow unused --rendered ../homelab-k8s-shadow/rendered --output json`,
+	RunE: runUnused,
+}
+
+func init() {
+	rootCmd.AddCommand(unusedCmd)
+
+	unusedCmd.Flags().StringVarP(&unusedCluster, "cluster", "c", "", "Check only this cluster")
+	unusedCmd.Flags().StringVarP(&unusedOutputFormat, "output", "o", "table", "Output format: table, json, markdown")
+	unusedCmd.Flags().StringVar(&unusedRendered, "rendered", "", "Check manifest.yaml files under this directory instead of building")
+	unusedCmd.Flags().StringVar(&unusedEngine, "kustomize-engine", "exec", "Kustomize build engine: exec, krusty (in-process), or auto")
+	unusedCmd.Flags().BoolVar(&strict, "strict", false, "Treat warnings as errors")
+	unusedCmd.Flags().BoolVar(&showSuppressed, "show-suppressed", false, "Also list findings suppressed by config or shadow:ignore comments")
+}
+
+func runUnused(cmd *cobra.Command, args []string) error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	var clusters []string
+	if unusedCluster != "" {
+		clusters = []string{unusedCluster}
+	}
+
+	allResults := []validate.Result{} // Initialize to empty slice for JSON output
+	var manifests map[string]string
+	if unusedRendered != "" {
+		manifests, err = readRenderedManifests(unusedRendered)
+	} else {
+		manifests, allResults, err = buildManifests(clusters, unusedEngine)
+	}
+	if err != nil {
+		return err
+	}
+
+	if unusedCluster != "" {
+		for dir := range manifests {
+			if cluster := sync.ClusterForDirectory(dir); cluster != "" && cluster != unusedCluster {
+				delete(manifests, dir)
+			}
+		}
+	}
+
+	unused, err := validate.FindUnusedConfig(manifests, sync.ClusterForDirectory, cfg.Unused)
+	if err != nil {
+		return err
+	}
+	logInfo("Indexed %d manifest(s), found %d unused ConfigMap(s)/Secret(s)", len(manifests), len(unused))
+
+	for _, u := range unused {
+		allResults = append(allResults, u.Result())
+	}
+
+	allResults = validate.ApplySeverities(allResults, cfg)
+	allResults = applyStrictness(allResults, cfg)
+	validate.ApplyInlineSuppressions(allResults, repoDir)
+	validate.AssignOwners(allResults, cfg)
+
+	switch unusedOutputFormat {
+	case "json":
+		return outputJSON(allResults)
+	case "table":
+		return outputTable(allResults)
+	case "markdown":
+		return outputMarkdown(allResults)
+	default:
+		return fmt.Errorf("unknown output format: %s", unusedOutputFormat)
+	}
+}
@@ -0,0 +1,300 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/erauner/homelab-shadow/pkg/flux"
+	"github.com/erauner/homelab-shadow/pkg/helm"
+	"github.com/erauner/homelab-shadow/pkg/kustomize"
+	"github.com/erauner/homelab-shadow/pkg/sync"
+	"github.com/erauner/homelab-shadow/pkg/validate"
+	"github.com/spf13/cobra"
+)
+
+// Upgrade-check rules (api-removed lives in pkg/validate)
+const (
+	ruleSchemaInvalid    = "schema-invalid"
+	ruleChartKubeVersion = "chart-kube-version"
+)
+
+var (
+	upgradeTo           string
+	upgradeCluster      string
+	upgradeOutputFormat string
+	upgradeEngine       string
+	upgradeSkipHelm     bool
+	upgradeCacheDir     string
+)
+
+var upgradeCheckCmd = &cobra.Command{
+	Use:   "upgrade-check",
+	Short: "Report what blocks a Kubernetes version upgrade, per app",
+	Long: `Renders every deployable kustomization and Helm Application and reports,
+grouped by app, everything that would break on the target Kubernetes version:
+
+  - api-removed:        resources using an apiVersion the target no longer serves
+  - schema-invalid:     manifests that fail kubeconform against the target's schemas
+                        (skipped when kubeconform is not installed)
+  - chart-kube-version: Helm charts whose Chart.yaml kubeVersion excludes the target
+                        (pinned chart versions only; charts come from the chart cache)
+
+Build and render failures are reported too, since those apps cannot be checked.
+Severities can be changed per rule in .shadow.yaml like any validate rule.
+
+Examples:
+  shadow upgrade-check --to 1.32
+  shadow upgrade-check --to 1.32 --cluster erauner-home --output json
+  shadow upgrade-check --to 1.32 --skip-helm`,
+	RunE: runUpgradeCheck,
+}
+
+func init() {
+	rootCmd.AddCommand(upgradeCheckCmd)
+
+	upgradeCheckCmd.Flags().StringVar(&upgradeTo, "to", "", "Target Kubernetes version, e.g. 1.32 (required)")
+	upgradeCheckCmd.Flags().StringVarP(&upgradeCluster, "cluster", "c", "", "Check only this cluster")
+	upgradeCheckCmd.Flags().StringVarP(&upgradeOutputFormat, "output", "o", "table", "Output format: table, json")
+	upgradeCheckCmd.Flags().StringVar(&upgradeEngine, "kustomize-engine", "exec", "Kustomize build engine: exec, krusty (in-process), or auto")
+	upgradeCheckCmd.Flags().BoolVar(&upgradeSkipHelm, "skip-helm", false, "Skip Helm Applications")
+	upgradeCheckCmd.Flags().StringVar(&upgradeCacheDir, "cache-dir", helm.DefaultCacheDir(), "Chart cache directory")
+	upgradeCheckCmd.Flags().BoolVar(&strict, "strict", false, "Treat warnings as errors")
+	upgradeCheckCmd.MarkFlagRequired("to")
+}
+
+// UpgradeReport is the upgrade-check result, grouped by app
+type UpgradeReport struct {
+	Target   string       `json:"target"`
+	Checks   []string     `json:"checks"`
+	Apps     []UpgradeApp `json:"apps"`
+	Errors   int          `json:"errors"`
+	Warnings int          `json:"warnings"`
+}
+
+// UpgradeApp lists the findings blocking one app
+type UpgradeApp struct {
+	App      string            `json:"app"`
+	Blockers []validate.Result `json:"blockers"`
+}
+
+func runUpgradeCheck(cmd *cobra.Command, args []string) error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	if _, err := validate.KubeMinor(upgradeTo); err != nil {
+		return err
+	}
+	// kubeconform and kubeVersion constraints want a full version
+	target := strings.TrimPrefix(upgradeTo, "v")
+	if strings.Count(target, ".") == 1 {
+		target += ".0"
+	}
+
+	var clusters []string
+	if upgradeCluster != "" {
+		clusters = []string{upgradeCluster}
+	}
+
+	checks := []string{validate.RuleAPIRemoved}
+	schemas := kustomize.IsKubeconformInstalled()
+	if schemas {
+		checks = append(checks, ruleSchemaInvalid)
+	} else {
+		logInfo("kubeconform not installed; skipping schema validation")
+	}
+	schemaRunner := kustomize.NewRunner(repoDir, target, verbose)
+	if schemas {
+		// Validate custom resources against the repo's CRDs instead of skipping them
+		crds, err := kustomize.RepoCRDSchemas(repoDir, kustomize.DefaultSchemaDir())
+		if err != nil {
+			logVerbose("Custom resources not schema-validated: %v", err)
+		} else if crds.Schemas > 0 {
+			schemaRunner.SchemaLocations = []string{crds.Location()}
+		}
+	}
+
+	// checkManifest runs the manifest-level checks against one rendered manifest
+	checkManifest := func(cluster, dir, manifest string) []validate.Result {
+		results, err := validate.ValidateRemovedAPIs(cluster, dir, manifest, target)
+		if err != nil {
+			logVerbose("Skipping API check for %s: %v", dir, err)
+		}
+		if !schemas {
+			return results
+		}
+		if output, err := schemaRunner.ValidateManifest(manifest); err != nil {
+			detail := kustomize.SummarizeSchemaErrors(output)
+			if detail == "" {
+				detail = err.Error()
+			}
+			results = append(results, validate.Result{
+				Cluster:  cluster,
+				Rule:     ruleSchemaInvalid,
+				Path:     dir,
+				Message:  fmt.Sprintf("Schema validation against %s failed: %s", target, detail),
+				Severity: "error",
+			})
+		}
+		return results
+	}
+
+	manifests, allResults, err := buildManifests(clusters, upgradeEngine)
+	if err != nil {
+		return err
+	}
+
+	dirs := make([]string, 0, len(manifests))
+	for dir := range manifests {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+
+	for _, dir := range dirs {
+		allResults = append(allResults, checkManifest(sync.ClusterForDirectory(dir), dir, manifests[dir])...)
+	}
+	logInfo("Checked %d manifest(s) against Kubernetes %s", len(dirs), target)
+
+	if !upgradeSkipHelm {
+		if helm.IsHelmInstalled() {
+			checks = append(checks, ruleChartKubeVersion)
+			helmResults, err := checkHelmUpgrade(target, checkManifest)
+			if err != nil {
+				return err
+			}
+			allResults = append(allResults, helmResults...)
+		} else {
+			logInfo("helm not installed; skipping Helm Applications")
+		}
+	}
+
+	allResults = validate.ApplySeverities(allResults, cfg)
+	allResults = applyStrictness(allResults, cfg)
+	validate.ApplyInlineSuppressions(allResults, repoDir)
+	validate.AssignOwners(allResults, cfg)
+
+	report := UpgradeReport{
+		Target:   target,
+		Checks:   checks,
+		Apps:     groupByApp(validate.Unsuppressed(allResults)),
+		Errors:   validate.CountErrors(allResults),
+		Warnings: validate.CountWarnings(allResults),
+	}
+
+	switch upgradeOutputFormat {
+	case "json":
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(report); err != nil {
+			return fmt.Errorf("failed to encode JSON: %w", err)
+		}
+	case "table":
+		printUpgradeReport(report)
+		printSuppressedNote(validate.CountSuppressed(allResults))
+	default:
+		return fmt.Errorf("unknown output format: %s", upgradeOutputFormat)
+	}
+
+	return checkExitCode(allResults)
+}
+
+// checkHelmUpgrade checks chart kubeVersion constraints and the rendered
+// output of every Helm Application source
+func checkHelmUpgrade(target string, checkManifest func(cluster, dir, manifest string) []validate.Result) ([]validate.Result, error) {
+	helmApps, err := flux.DiscoverAllHelmApplications(repoDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover Helm applications: %w", err)
+	}
+	logInfo("Checking %d Helm application(s)...", len(helmApps))
+
+	cache := helm.NewChartCache(upgradeCacheDir, verbose)
+	var results []validate.Result
+	for _, app := range helmApps {
+		// Same layout sync publishes: apps/<appname>/helm/manifest.yaml
+		helmDir := fmt.Sprintf("apps/%s/helm", app.Name)
+
+		for _, source := range app.GetHelmSources() {
+			repoURL, chart := source.RepoURL, source.Chart
+			if sync.IsOCIRegistry(repoURL) {
+				repoURL, chart = "", sync.NormalizeOCIURL(source.RepoURL)+"/"+source.Chart
+			}
+
+			if archive, err := cache.Fetch(repoURL, chart, source.TargetRevision); err != nil {
+				logVerbose("Skipping kubeVersion check for %s@%s: %v", source.Chart, source.TargetRevision, err)
+			} else if meta, err := helm.ReadChartMetadata(archive); err != nil {
+				logVerbose("Skipping kubeVersion check for %s@%s: %v", source.Chart, source.TargetRevision, err)
+			} else if ok, err := helm.KubeVersionSatisfies(meta.KubeVersion, target); err != nil || !ok {
+				msg := fmt.Sprintf("Chart %s %s requires kubeVersion %q", source.Chart, source.TargetRevision, meta.KubeVersion)
+				if err != nil {
+					msg = fmt.Sprintf("Chart %s %s has an unparseable kubeVersion: %v", source.Chart, source.TargetRevision, err)
+				}
+				results = append(results, validate.Result{
+					Rule:     ruleChartKubeVersion,
+					Path:     helmDir,
+					Message:  msg,
+					Severity: "error",
+				})
+			}
+
+			rendered := sync.RenderHelmSource(app, &source, sync.HelmRenderOptions{
+				RepoPath: repoDir,
+				CacheDir: upgradeCacheDir,
+				Verbose:  verbose,
+			})
+			if !rendered.Passed {
+				results = append(results, validate.Result{
+					Rule:     "helm-render-fail",
+					Path:     helmDir,
+					Message:  fmt.Sprintf("Helm render failed for %s: %v", source.Chart, rendered.Error),
+					Severity: "error",
+				})
+				continue
+			}
+			results = append(results, checkManifest("", helmDir, rendered.Output)...)
+		}
+	}
+
+	return results, nil
+}
+
+// groupByApp groups results by apps/<app>, falling back to the full path
+// for directories outside apps/
+func groupByApp(results []validate.Result) []UpgradeApp {
+	groups := make(map[string][]validate.Result)
+	for _, r := range results {
+		app := r.Path
+		if parts := strings.Split(r.Path, "/"); len(parts) >= 2 && parts[0] == "apps" {
+			app = parts[1]
+		}
+		groups[app] = append(groups[app], r)
+	}
+
+	apps := make([]UpgradeApp, 0, len(groups))
+	for app, blockers := range groups {
+		apps = append(apps, UpgradeApp{App: app, Blockers: blockers})
+	}
+	sort.Slice(apps, func(i, j int) bool { return apps[i].App < apps[j].App })
+	return apps
+}
+
+// printUpgradeReport prints one table per app with blockers
+func printUpgradeReport(report UpgradeReport) {
+	fmt.Printf("Upgrade check for Kubernetes %s (%s)\n", report.Target, strings.Join(report.Checks, ", "))
+
+	if len(report.Apps) == 0 {
+		fmt.Println("\n✅ No upgrade blockers found!")
+		return
+	}
+
+	for _, app := range report.Apps {
+		fmt.Printf("\n=== %s (%d finding(s)) ===", app.App, len(app.Blockers))
+		printResultsTable(app.Blockers)
+	}
+
+	fmt.Printf("\nSummary: %d app(s) affected, %d error(s), %d warning(s)\n",
+		len(report.Apps), report.Errors, report.Warnings)
+}
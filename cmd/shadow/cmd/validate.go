@@ -4,17 +4,31 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
-	"text/tabwriter"
 
+	"github.com/erauner/homelab-shadow/pkg/config"
+	"github.com/erauner/homelab-shadow/pkg/hint"
+	"github.com/erauner/homelab-shadow/pkg/output"
+	"github.com/erauner/homelab-shadow/pkg/shadow"
 	"github.com/erauner/homelab-shadow/pkg/validate"
 	"github.com/spf13/cobra"
 )
 
 var (
-	clusterFilter string
-	outputFormat  string
-	strict        bool
+	clusterFilter  string
+	outputFormat   string
+	strict         bool
+	showSuppressed bool
+	baselineFile   string
+	writeBaseline  string
+	buildAppPaths  bool
+	validateK8sVer string
+	validateOnly   string
+	validateSkip   string
+	listRules      bool
+
+	validatePlugins []string
 )
 
 var validateCmd = &cobra.Command{
@@ -28,17 +42,67 @@ Checks performed:
   - Kustomize builds succeed for all cluster paths
   - Infrastructure/operators/security component structure (base/overlays pattern)
   - App overlay structure uses cluster layer (apps/<app>/overlays/<cluster>/<env>/) - issue #1256
-  - ArgoCD Application paths match expected structure
+  - Overlay directory names don't collide with registered clusters (clusters.yaml or clusters/)
+  - Overlay directories named like a typo of a cluster (eraunerhome) are errors, not legacy envs
+  - ArgoCD Application paths match expected structure (ApplicationSets are expanded)
+  - ArgoCD Application source paths exist and contain a kustomization.yaml
+    (and build, with --build-app-paths)
+  - Rendered Application source paths don't use APIs removed (api-removed) or
+    deprecated (api-deprecated) in --kubernetes-version, when set
+  - Applications only use features supported by each cluster's ArgoCD version
+    (argocdVersion in clusters.yaml)
+  - Every app overlay is deployed by some Application, and every Application
+    apps/ path is an overlay (orphan-overlay, unreferenced-app)
+  - Applications deploying an apps/<app>/overlays/<cluster>/... overlay target
+    that cluster (spec.destination name, or server from clusters.yaml)
+    (overlay-wrong-destination)
+  - Sync-wave annotations are integers, and with --build-app-paths, rendered
+    resources and Applications don't sync before the CRDs and Namespaces they
+    need, or need each other's (sync-wave-*)
+  - When the repo defines AppProjects, Applications name a defined project
+    other than default, and their sources and destinations are permitted by
+    its sourceRepos and destinations (argocd-project-*)
   - Namespace definitions are in approved locations (security/namespaces/ only)
   - Legacy namespaces in infrastructure/namespaces/ (warns for migration)
   - No duplicate namespace definitions across the repo
   - Applications don't use CreateNamespace=true (namespaces should be platform-managed)
 
+Findings can be suppressed with "rules: {<rule>: ignore}" or per-path ignore
+globs in .shadow.yaml, or with an inline "# shadow:ignore <rule>" comment in
+the kustomization.yaml (or file) a finding points at. Suppressed findings never
+fail validation; list them with --show-suppressed.
+
+Every check belongs to a rule registry; --list-rules prints the rules and the
+check that reports each. --only runs just the rules matching its glob patterns
+and --skip drops rules matching its patterns (both comma-separated, e.g.
+--only 'namespace-*,argocd-app-*'). Checks with no selected rule don't run.
+
+--plugin (and "plugins:" in .shadow.yaml) adds validators of your own: an
+executable that reads a JSON request on stdin and prints findings as a JSON
+array, or a Go plugin (.so) in a shadow built with -tags goplugin.
+"celRules:" in .shadow.yaml adds rules written as CEL expressions over rendered
+resources, kustomizations, or Applications (in a shadow built with -tags cel).
+
+For large migrations, --write-baseline snapshots the current findings; later
+runs with --baseline only fail on findings that are not in the snapshot.
+Baselined findings are reported as suppressed.
+
 Examples:
   shadow validate --repo /path/to/homelab-k8s
   shadow validate --repo . --cluster home
   shadow validate --repo . --output json
-  shadow validate --repo . --strict`,
+  shadow validate --repo . --output markdown
+  shadow validate --repo . --output template='{{range .Results}}{{.Path}}: {{.Message}}{{"\n"}}{{end}}'
+  shadow validate --repo . --strict
+  shadow validate --repo . --show-suppressed
+  shadow validate --repo . --build-app-paths
+  shadow validate --repo . --kubernetes-version 1.32
+  shadow validate --repo . --only 'namespace-*,argocd-app-*'
+  shadow validate --repo . --skip kustomize-build-fail
+  shadow validate --list-rules
+  shadow validate --repo . --plugin ./hack/team-labels
+  shadow validate --repo . --write-baseline .shadow-baseline.json
+  shadow validate --repo . --baseline .shadow-baseline.json`,
 	RunE: runValidate,
 }
 
@@ -46,88 +110,168 @@ func init() {
 	rootCmd.AddCommand(validateCmd)
 
 	validateCmd.Flags().StringVarP(&clusterFilter, "cluster", "c", "", "Validate only this cluster")
-	validateCmd.Flags().StringVarP(&outputFormat, "output", "o", "table", "Output format: table, json")
-	validateCmd.Flags().BoolVar(&strict, "strict", false, "Treat warnings as errors")
+	validateCmd.Flags().StringVarP(&outputFormat, "output", "o", "table", "Output format: table, json, markdown, template='{{...}}', or template-file=<path>")
+	validateCmd.Flags().BoolVar(&showSuppressed, "show-suppressed", false, "Also list findings suppressed by config, shadow:ignore comments, or --baseline")
+	validateCmd.Flags().BoolVar(&strict, "strict", false, "Treat warnings as errors (same as --fail-on warn)")
+	addFailOnFlag(validateCmd, "error")
+	validateCmd.MarkFlagsMutuallyExclusive("strict", "fail-on")
+	validateCmd.Flags().StringVar(&baselineFile, "baseline", "", "Only fail on findings not recorded in this baseline file")
+	validateCmd.Flags().BoolVar(&buildAppPaths, "build-app-paths", false, "Also kustomize build every ArgoCD Application source path")
+	validateCmd.Flags().StringVar(&validateK8sVer, "kubernetes-version", "", "Render Application source paths and flag APIs removed or deprecated in this Kubernetes version")
+	validateCmd.Flags().StringVar(&writeBaseline, "write-baseline", "", "Write current findings to this baseline file and exit successfully")
+	validateCmd.Flags().StringVar(&validateOnly, "only", "", "Run only rules matching these comma-separated globs (e.g. 'namespace-*,argocd-app-*')")
+	validateCmd.Flags().StringVar(&validateSkip, "skip", "", "Don't run rules matching these comma-separated globs")
+	validateCmd.Flags().BoolVar(&listRules, "list-rules", false, "List the registered rules and the check reporting each, then exit")
+	validateCmd.Flags().StringArrayVar(&validatePlugins, "plugin", nil, "Also run this validator plugin: an executable printing findings as JSON, or a Go plugin (.so) (repeatable)")
+	validateCmd.MarkFlagsMutuallyExclusive("write-baseline", "only")
+	validateCmd.MarkFlagsMutuallyExclusive("write-baseline", "skip")
+	validateCmd.Flags().BoolVar(&skipVersionCheck, "skip-version-check", false, "Run even if tools don't match the versions pinned in .shadow.yaml")
 }
 
 func runValidate(cmd *cobra.Command, args []string) error {
-	validator := validate.NewClusterValidator(repoDir, verbose)
-
-	// Discover clusters
-	clusters, err := validator.DiscoverClusters()
+	format, err := output.Parse(outputFormat, "table", "json", "markdown")
 	if err != nil {
-		return fmt.Errorf("failed to discover clusters: %w", err)
+		return err
 	}
-
-	if len(clusters) == 0 {
-		return fmt.Errorf("no clusters found in %s/clusters/", repoDir)
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
 	}
-
-	logInfo("Discovered %d cluster(s): %s", len(clusters), strings.Join(clusters, ", "))
-
-	// Filter if requested
-	if clusterFilter != "" {
-		found := false
-		for _, c := range clusters {
-			if c == clusterFilter {
-				found = true
-				break
-			}
-		}
-		if !found {
-			return fmt.Errorf("cluster %q not found (available: %s)", clusterFilter, strings.Join(clusters, ", "))
+	plugins, err := loadPlugins(cfg)
+	if err != nil {
+		return err
+	}
+	if listRules {
+		celRules, err := validate.CELRules(cfg)
+		if err != nil {
+			return err
 		}
-		clusters = []string{clusterFilter}
+		printRules(append(append(validate.Registered(), celRules...), plugins...))
+		return nil
 	}
-
-	// Run validation
-	allResults := []validate.Result{} // Initialize to empty slice for JSON output
-	for _, cluster := range clusters {
-		logInfo("Validating cluster: %s", cluster)
-		results := validator.ValidateCluster(cluster)
-		allResults = append(allResults, results...)
+	if err := checkToolVersions(cfg); err != nil {
+		return err
 	}
 
-	// Run infrastructure validation (new pattern enforcement)
-	logInfo("Validating infrastructure structure...")
-	infraResults := validator.ValidateInfrastructure(clusters)
-	allResults = append(allResults, infraResults...)
-
-	// Run namespace location validation (issue #950)
-	logInfo("Validating namespace locations...")
-	nsResults := validator.ValidateNamespaceLocations()
-	allResults = append(allResults, nsResults...)
+	opts := shadow.ValidateOptions{
+		RepoPath:      repoDir,
+		Cluster:       clusterFilter,
+		BuildAppPaths: buildAppPaths,
+		Config:        cfg,
+		Progress:      showProgress(),
+		Verbose:       verbose,
 
-	// Run CreateNamespace validation (issue #950)
-	logInfo("Validating CreateNamespace usage...")
-	createNsResults := validator.ValidateCreateNamespace()
-	allResults = append(allResults, createNsResults...)
+		KubernetesVersion: validateK8sVer,
 
-	// Run app overlay structure validation (issue #1256)
-	logInfo("Validating app overlay structure...")
-	appOverlayResults := validator.ValidateAppOverlayStructure(clusters)
-	allResults = append(allResults, appOverlayResults...)
+		Plugins: plugins,
+		Rules:   validate.RuleSelector{Only: splitList(validateOnly), Skip: splitList(validateSkip)},
+	}
+	// A new baseline records every finding, including ones an old baseline covers
+	if writeBaseline == "" {
+		opts.Baseline = baselineFile
+	}
+	result, err := shadow.Validate(cmd.Context(), opts)
+	if err != nil {
+		return err
+	}
+	allResults := result.Findings
 
-	// Run ArgoCD app path validation (issue #1256)
-	logInfo("Validating ArgoCD app paths...")
-	argoCDPathResults := validator.ValidateArgoCDAppPaths(clusters)
-	allResults = append(allResults, argoCDPathResults...)
+	if writeBaseline != "" {
+		baseline, err := validate.WriteBaseline(writeBaseline, allResults)
+		if err != nil {
+			return err
+		}
+		logInfo("Wrote baseline with %d finding(s) to %s", len(baseline.Findings), writeBaseline)
+		return nil
+	}
+	if result.BaselineFixed > 0 {
+		logInfo("%d baselined finding(s) no longer occur; rerun with --write-baseline to shrink %s", result.BaselineFixed, baselineFile)
+	}
 
 	// Output results
-	switch outputFormat {
+	switch format.Name {
 	case "json":
 		return outputJSON(allResults)
-	case "table":
-		return outputTable(allResults)
+	case "markdown":
+		return outputMarkdown(allResults)
+	case "template":
+		return outputTemplate(format, allResults)
 	default:
-		return fmt.Errorf("unknown output format: %s", outputFormat)
+		return outputTable(allResults)
+	}
+}
+
+// loadPlugins loads the plugins listed in .shadow.yaml (relative to the repo)
+// and given with --plugin
+func loadPlugins(cfg *config.Config) ([]validate.Rule, error) {
+	paths := append([]string(nil), validatePlugins...)
+	for _, p := range cfg.Plugins {
+		if strings.ContainsRune(p, filepath.Separator) && !filepath.IsAbs(p) {
+			p = filepath.Join(repoDir, p)
+		}
+		paths = append(paths, p)
+	}
+
+	var rules []validate.Rule
+	for _, p := range paths {
+		loaded, err := validate.LoadPlugin(p)
+		if err != nil {
+			return nil, err
+		}
+		logVerbose("Loaded %d rule(s) from plugin %s", len(loaded), p)
+		rules = append(rules, loaded...)
+	}
+	return rules, nil
+}
+
+// printRules lists the rule names of rules with the rule reporting each
+func printRules(rules []validate.Rule) {
+	t := newTable("RULE", "CHECK")
+	for _, rule := range rules {
+		for _, name := range validate.NamesOf(rule) {
+			t.Row(name, rule.Name())
+		}
+	}
+	t.Render(os.Stdout)
+}
+
+// splitList splits a comma-separated flag value, dropping empty items
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
 	}
+	return items
+}
+
+// validateTemplateData is what --output template= renders
+type validateTemplateData struct {
+	Results []validate.Result // unsuppressed findings, or all with --show-suppressed
+	Summary validate.Summary
+}
+
+func outputTemplate(format output.Format, results []validate.Result) error {
+	shown := results
+	if !showSuppressed {
+		shown = validate.Unsuppressed(results)
+	}
+	if err := format.Execute(os.Stdout, validateTemplateData{Results: shown, Summary: validate.Summarize(results)}); err != nil {
+		return err
+	}
+	return checkExitCode(results)
 }
 
 func outputJSON(results []validate.Result) error {
+	shown := results
+	if !showSuppressed {
+		shown = validate.Unsuppressed(results)
+	}
+
 	encoder := json.NewEncoder(os.Stdout)
 	encoder.SetIndent("", "  ")
-	if err := encoder.Encode(results); err != nil {
+	if err := encoder.Encode(shown); err != nil {
 		return fmt.Errorf("failed to encode JSON: %w", err)
 	}
 	return checkExitCode(results)
@@ -136,42 +280,165 @@ func outputJSON(results []validate.Result) error {
 func outputTable(results []validate.Result) error {
 	errors := validate.CountErrors(results)
 	warnings := validate.CountWarnings(results)
+	suppressed := validate.CountSuppressed(results)
+	active := validate.Unsuppressed(results)
 
-	if len(results) == 0 {
+	if len(active) == 0 && (suppressed == 0 || !showSuppressed) {
 		fmt.Println("\n✅ All validations passed!")
+		printSuppressedNote(suppressed)
 		return nil
 	}
 
-	// Print results table
-	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
-	fmt.Fprintln(w, "\nSEVERITY\tCLUSTER\tRULE\tPATH\tMESSAGE")
-	fmt.Fprintln(w, "--------\t-------\t----\t----\t-------")
-
-	for _, r := range results {
-		icon := "⚠️ "
-		if r.Severity == "error" {
-			icon = "❌"
+	// Group by owner when ownership is configured so each team sees their slice
+	if hasOwners(active) {
+		owners, groups := validate.GroupByOwner(active)
+		for _, owner := range owners {
+			fmt.Printf("\n=== Owner: %s (%d finding(s)) ===", owner, len(groups[owner]))
+			printResultsTable(groups[owner])
 		}
-		fmt.Fprintf(w, "%s %s\t%s\t%s\t%s\t%s\n",
-			icon, strings.ToUpper(r.Severity), r.Cluster, r.Rule, r.Path, r.Message)
+	} else if len(active) > 0 {
+		printResultsTable(active)
+	}
+
+	if showSuppressed && suppressed > 0 {
+		fmt.Printf("\n=== Suppressed (%d finding(s)) ===", suppressed)
+		printSuppressedTable(results)
 	}
-	w.Flush()
 
 	// Print summary
 	fmt.Printf("\nSummary: %d error(s), %d warning(s)\n", errors, warnings)
+	printSuppressedNote(suppressed)
 
 	return checkExitCode(results)
 }
 
-func checkExitCode(results []validate.Result) error {
+// printSuppressedNote mentions hidden suppressed findings
+func printSuppressedNote(suppressed int) {
+	if suppressed > 0 && !showSuppressed {
+		fmt.Printf("(%d suppressed finding(s) hidden; use --show-suppressed to list them)\n", suppressed)
+	}
+}
+
+// printSuppressedTable prints suppressed results with what suppressed them
+func printSuppressedTable(results []validate.Result) {
+	t := newTable("SEVERITY", "CLUSTER", "RULE", "PATH", "SUPPRESSED BY")
+	for _, r := range results {
+		if !r.Suppressed {
+			continue
+		}
+		t.Row("🔇 "+strings.ToUpper(r.Severity), r.Cluster, r.Rule, r.Path, r.SuppressedBy)
+	}
+	fmt.Println()
+	t.Render(os.Stdout)
+}
+
+// printResultsTable prints results as an aligned table
+func printResultsTable(results []validate.Result) {
+	t := newTable("SEVERITY", "CLUSTER", "RULE", "PATH", "MESSAGE")
+	for _, r := range results {
+		icon := "⚠️"
+		if r.Severity == "error" {
+			icon = "❌"
+		}
+		t.Row(icon+" "+strings.ToUpper(r.Severity), r.Cluster, r.Rule, r.Path, withHint(r.Message))
+	}
+	fmt.Println()
+	t.Render(os.Stdout)
+}
+
+// withHint appends a remediation hint to a finding's message when one is known
+func withHint(msg string) string {
+	if h := hint.For(msg); h != "" {
+		return msg + " (hint: " + h + ")"
+	}
+	return msg
+}
+
+// outputMarkdown prints results as PR-comment-ready markdown, grouped by owner
+func outputMarkdown(results []validate.Result) error {
 	errors := validate.CountErrors(results)
 	warnings := validate.CountWarnings(results)
+	suppressed := validate.CountSuppressed(results)
+	active := validate.Unsuppressed(results)
 
-	if errors > 0 {
-		return fmt.Errorf("validation failed with %d error(s)", errors)
+	fmt.Println("## Shadow Validation")
+	fmt.Println()
+	if len(active) == 0 {
+		fmt.Println("✅ All validations passed!")
+		printMarkdownSuppressed(results, suppressed)
+		return nil
+	}
+	fmt.Printf("**%d error(s), %d warning(s)**\n", errors, warnings)
+
+	owners, groups := validate.GroupByOwner(active)
+	for _, owner := range owners {
+		if hasOwners(active) {
+			fmt.Printf("\n### %s\n", owner)
+		}
+		fmt.Println()
+		fmt.Println("| Severity | Cluster | Rule | Path | Message |")
+		fmt.Println("|----------|---------|------|------|---------|")
+		for _, r := range groups[owner] {
+			icon := "⚠️"
+			if r.Severity == "error" {
+				icon = "❌"
+			}
+			fmt.Printf("| %s %s | %s | `%s` | `%s` | %s |\n",
+				icon, r.Severity, r.Cluster, r.Rule, r.Path, markdownEscape(withHint(r.Message)))
+		}
+	}
+	printMarkdownSuppressed(results, suppressed)
+
+	return checkExitCode(results)
+}
+
+// printMarkdownSuppressed lists suppressed findings in a collapsed section
+// (or just counts them without --show-suppressed)
+func printMarkdownSuppressed(results []validate.Result, suppressed int) {
+	if suppressed == 0 {
+		return
+	}
+	fmt.Println()
+	if !showSuppressed {
+		fmt.Printf("_%d suppressed finding(s) not shown._\n", suppressed)
+		return
+	}
+
+	fmt.Printf("<details><summary>%d suppressed finding(s)</summary>\n\n", suppressed)
+	fmt.Println("| Severity | Rule | Path | Suppressed by |")
+	fmt.Println("|----------|------|------|---------------|")
+	for _, r := range results {
+		if r.Suppressed {
+			fmt.Printf("| %s | `%s` | `%s` | %s |\n", r.Severity, r.Rule, r.Path, markdownEscape(r.SuppressedBy))
+		}
+	}
+	fmt.Println("\n</details>")
+}
+
+// markdownEscape escapes characters that would break a markdown table cell
+func markdownEscape(s string) string {
+	s = strings.ReplaceAll(s, "|", "\\|")
+	return strings.ReplaceAll(s, "\n", " ")
+}
+
+// hasOwners returns true if any result has an owner assigned
+func hasOwners(results []validate.Result) bool {
+	for _, r := range results {
+		if r.Owner != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// checkExitCode fails on unsuppressed findings as --fail-on (or --strict) says
+func checkExitCode(results []validate.Result) error {
+	var errs, warns error
+	if errors := validate.CountErrors(results); errors > 0 {
+		errs = fmt.Errorf("validation failed with %d error(s)", errors)
 	}
-	if strict && warnings > 0 {
-		return fmt.Errorf("validation failed with %d warning(s) (strict mode)", warnings)
+	if warnings := validate.CountWarnings(results); warnings > 0 {
+		warns = fmt.Errorf("validation failed with %d warning(s)", warnings)
 	}
-	return nil
+	return failFindings(errs, warns)
 }
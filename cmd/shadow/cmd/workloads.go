@@ -0,0 +1,112 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/erauner/homelab-shadow/pkg/sync"
+	"github.com/erauner/homelab-shadow/pkg/validate"
+	"github.com/spf13/cobra"
+)
+
+var (
+	workloadsCluster      string
+	workloadsOutputFormat string
+	workloadsRendered     string
+	workloadsEngine       string
+)
+
+var workloadsCmd = &cobra.Command{
+	Use:   "workloads",
+	Short: "Lint resources, probes, and users of rendered workloads",
+	Long: `Renders every deployable kustomization (the same set sync publishes) and
+checks the containers of Deployments, StatefulSets, DaemonSets, and CronJobs:
+
+  - workload-requests-missing:        no cpu or memory request
+  - workload-limits-missing:          no memory limit (CPU limits are not required)
+  - workload-liveness-probe-missing:  no liveness probe
+  - workload-readiness-probe-missing: no readiness probe
+  - workload-runs-as-root:            runAsUser is 0, or neither runAsNonRoot nor
+                                      a non-zero runAsUser is set
+
+Init containers and CronJobs are not checked for probes. All findings are
+warnings by default; raise or disable each rule in .shadow.yaml:
+
+  rules:
+    workload-runs-as-root: error
+    workload-liveness-probe-missing: off
+
+Use --rendered to check an existing rendered tree (e.g. a shadow repo
+checkout's rendered/ directory) instead of building.
+
+Examples:
+  shadow workloads --repo /path/to/homelab-k8s
+  shadow workloads --repo . --cluster erauner-home
+  shadow workloads --rendered ../homelab-k8s-shadow/rendered --output json`,
+	RunE: runWorkloads,
+}
+
+func init() {
+	rootCmd.AddCommand(workloadsCmd)
+
+	workloadsCmd.Flags().StringVarP(&workloadsCluster, "cluster", "c", "", "Check only this cluster")
+	workloadsCmd.Flags().StringVarP(&workloadsOutputFormat, "output", "o", "table", "Output format: table, json, markdown")
+	workloadsCmd.Flags().StringVar(&workloadsRendered, "rendered", "", "Check manifest.yaml files under this directory instead of building")
+	workloadsCmd.Flags().StringVar(&workloadsEngine, "kustomize-engine", "exec", "Kustomize build engine: exec, krusty (in-process), or auto")
+	workloadsCmd.Flags().BoolVar(&strict, "strict", false, "Treat warnings as errors")
+	workloadsCmd.Flags().BoolVar(&showSuppressed, "show-suppressed", false, "Also list findings suppressed by config or shadow:ignore comments")
+}
+
+func runWorkloads(cmd *cobra.Command, args []string) error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	var clusters []string
+	if workloadsCluster != "" {
+		clusters = []string{workloadsCluster}
+	}
+
+	allResults := []validate.Result{} // Initialize to empty slice for JSON output
+	var manifests map[string]string
+	if workloadsRendered != "" {
+		manifests, err = readRenderedManifests(workloadsRendered)
+	} else {
+		manifests, allResults, err = buildManifests(clusters, workloadsEngine)
+	}
+	if err != nil {
+		return err
+	}
+
+	dirs := make([]string, 0, len(manifests))
+	for dir := range manifests {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+
+	for _, dir := range dirs {
+		cluster := sync.ClusterForDirectory(dir)
+		if workloadsCluster != "" && cluster != "" && cluster != workloadsCluster {
+			continue
+		}
+		allResults = append(allResults, validate.ValidateWorkloads(cluster, dir, manifests[dir])...)
+	}
+	logInfo("Checked workloads in %d manifest(s)", len(dirs))
+
+	allResults = validate.ApplySeverities(allResults, cfg)
+	allResults = applyStrictness(allResults, cfg)
+	validate.ApplyInlineSuppressions(allResults, repoDir)
+	validate.AssignOwners(allResults, cfg)
+
+	switch workloadsOutputFormat {
+	case "json":
+		return outputJSON(allResults)
+	case "table":
+		return outputTable(allResults)
+	case "markdown":
+		return outputMarkdown(allResults)
+	default:
+		return fmt.Errorf("unknown output format: %s", workloadsOutputFormat)
+	}
+}
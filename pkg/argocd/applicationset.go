@@ -0,0 +1,530 @@
+package argocd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/erauner/homelab-shadow/pkg/cluster"
+	"gopkg.in/yaml.v3"
+)
+
+// InClusterServer is the API server URL ArgoCD uses for its own cluster
+const InClusterServer = "https://kubernetes.default.svc"
+
+// ApplicationSet represents an ArgoCD ApplicationSet and its generators
+// Supported generators: list, clusters and git (directories and files)
+type ApplicationSet struct {
+	Name              string
+	GoTemplate        bool
+	GoTemplateOptions []string
+	Generators        []Generator
+	Template          yaml.Node // spec.template, substituted per parameter set
+}
+
+// Generator is a single entry of spec.generators; exactly one field is set
+type Generator struct {
+	List     *ListGenerator     `yaml:"list,omitempty"`
+	Clusters *ClustersGenerator `yaml:"clusters,omitempty"`
+	Git      *GitGenerator      `yaml:"git,omitempty"`
+
+	// Unsupported holds the name of any other generator type (matrix, merge, ...)
+	Unsupported string `yaml:"-"`
+}
+
+// ListGenerator produces one parameter set per element
+type ListGenerator struct {
+	Elements []map[string]interface{} `yaml:"elements"`
+}
+
+// ClustersGenerator produces one parameter set per registered cluster
+type ClustersGenerator struct {
+	Selector struct {
+		MatchLabels map[string]string `yaml:"matchLabels"`
+	} `yaml:"selector"`
+	Values map[string]string `yaml:"values"`
+}
+
+// GitGenerator produces parameter sets from directories or files in a Git repo
+// Shadow always resolves it against the local checkout, ignoring repoURL/revision
+type GitGenerator struct {
+	RepoURL     string `yaml:"repoURL"`
+	Revision    string `yaml:"revision"`
+	Directories []struct {
+		Path    string `yaml:"path"`
+		Exclude bool   `yaml:"exclude"`
+	} `yaml:"directories"`
+	Files []struct {
+		Path string `yaml:"path"`
+	} `yaml:"files"`
+}
+
+// Cluster is a cluster known to the clusters generator
+type Cluster struct {
+	Name   string
+	Server string
+	Labels map[string]string
+}
+
+// ExpandOptions provides the context generators need to run offline
+type ExpandOptions struct {
+	RepoPath string    // local checkout used by git generators
+	Clusters []Cluster // clusters visible to the clusters generator
+}
+
+// applicationSetYAML represents the raw YAML structure of an ApplicationSet
+type applicationSetYAML struct {
+	Kind     string `yaml:"kind"`
+	Metadata struct {
+		Name string `yaml:"name"`
+	} `yaml:"metadata"`
+	Spec struct {
+		GoTemplate        bool        `yaml:"goTemplate"`
+		GoTemplateOptions []string    `yaml:"goTemplateOptions"`
+		Generators        []yaml.Node `yaml:"generators"`
+		Template          yaml.Node   `yaml:"template"`
+	} `yaml:"spec"`
+}
+
+// ParseApplicationSetYAML parses ArgoCD ApplicationSet YAML data
+func ParseApplicationSetYAML(data []byte) (*ApplicationSet, error) {
+	var setYAML applicationSetYAML
+	if err := yaml.Unmarshal(data, &setYAML); err != nil {
+		return nil, fmt.Errorf("failed to parse YAML: %w", err)
+	}
+
+	if setYAML.Kind != "ApplicationSet" {
+		return nil, fmt.Errorf("not an ApplicationSet resource (kind=%s)", setYAML.Kind)
+	}
+
+	set := &ApplicationSet{
+		Name:              setYAML.Metadata.Name,
+		GoTemplate:        setYAML.Spec.GoTemplate,
+		GoTemplateOptions: setYAML.Spec.GoTemplateOptions,
+		Template:          setYAML.Spec.Template,
+	}
+
+	for i := range setYAML.Spec.Generators {
+		node := &setYAML.Spec.Generators[i]
+		var gen Generator
+		if err := node.Decode(&gen); err != nil {
+			return nil, fmt.Errorf("generator %d: %w", i, err)
+		}
+		if gen.List == nil && gen.Clusters == nil && gen.Git == nil {
+			gen.Unsupported = generatorType(node)
+		}
+		set.Generators = append(set.Generators, gen)
+	}
+
+	return set, nil
+}
+
+// generatorType returns the generator key of a generator mapping,
+// skipping the per-generator selector and template overrides
+func generatorType(node *yaml.Node) string {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if key := node.Content[i].Value; key != "selector" && key != "template" {
+			return key
+		}
+	}
+	return "unknown"
+}
+
+// Expand renders the Applications the ApplicationSet controller would create
+func (s *ApplicationSet) Expand(opts ExpandOptions) ([]*Application, error) {
+	var apps []*Application
+	for i, gen := range s.Generators {
+		params, err := gen.params(s.GoTemplate, opts)
+		if err != nil {
+			return nil, fmt.Errorf("applicationset %s generator %d: %w", s.Name, i, err)
+		}
+
+		for _, p := range params {
+			app, err := s.render(p)
+			if err != nil {
+				return nil, fmt.Errorf("applicationset %s: %w", s.Name, err)
+			}
+			apps = append(apps, app)
+		}
+	}
+	return apps, nil
+}
+
+// render substitutes one parameter set into the template
+func (s *ApplicationSet) render(params map[string]interface{}) (*Application, error) {
+	// Work on a deep copy so every parameter set starts from the raw template
+	var tmpl yaml.Node
+	raw, err := yaml.Marshal(&s.Template)
+	if err != nil {
+		return nil, fmt.Errorf("failed to copy template: %w", err)
+	}
+	if err := yaml.Unmarshal(raw, &tmpl); err != nil {
+		return nil, fmt.Errorf("failed to copy template: %w", err)
+	}
+
+	if err := substituteNode(&tmpl, func(value string) (string, error) {
+		if s.GoTemplate {
+			return renderGoTemplate(value, params, s.GoTemplateOptions)
+		}
+		return renderFastTemplate(value, params), nil
+	}); err != nil {
+		return nil, err
+	}
+
+	var appYAML applicationYAML
+	if err := tmpl.Decode(&appYAML); err != nil {
+		return nil, fmt.Errorf("failed to decode rendered template: %w", err)
+	}
+
+	app := newApplication(&appYAML)
+	app.ApplicationSet = s.Name
+	return app, nil
+}
+
+// substituteNode applies fn to every scalar in a node tree
+func substituteNode(node *yaml.Node, fn func(string) (string, error)) error {
+	if node.Kind == yaml.ScalarNode && strings.Contains(node.Value, "{{") {
+		value, err := fn(node.Value)
+		if err != nil {
+			return err
+		}
+		node.Value = value
+	}
+	for _, child := range node.Content {
+		if err := substituteNode(child, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fastTemplateTag matches the default (non-Go) ApplicationSet {{param}} syntax
+var fastTemplateTag = regexp.MustCompile(`\{\{\s*([^{}]+?)\s*\}\}`)
+
+// renderFastTemplate replaces {{param}} tags; unknown tags are left untouched,
+// matching the controller's behaviour
+func renderFastTemplate(value string, params map[string]interface{}) string {
+	return fastTemplateTag.ReplaceAllStringFunc(value, func(tag string) string {
+		key := fastTemplateTag.FindStringSubmatch(tag)[1]
+		if v, ok := params[key]; ok {
+			return fmt.Sprint(v)
+		}
+		return tag
+	})
+}
+
+// goTemplateFuncs is the subset of sprig functions commonly used in ApplicationSets
+var goTemplateFuncs = template.FuncMap{
+	"lower":      strings.ToLower,
+	"upper":      strings.ToUpper,
+	"trim":       strings.TrimSpace,
+	"trimPrefix": func(prefix, s string) string { return strings.TrimPrefix(s, prefix) },
+	"trimSuffix": func(suffix, s string) string { return strings.TrimSuffix(s, suffix) },
+	"replace":    func(old, new, s string) string { return strings.ReplaceAll(s, old, new) },
+	"normalize":  normalizeName,
+	"default": func(def interface{}, v interface{}) interface{} {
+		if v == nil || v == "" {
+			return def
+		}
+		return v
+	},
+}
+
+// renderGoTemplate renders a value with goTemplate: true semantics
+func renderGoTemplate(value string, params map[string]interface{}, options []string) (string, error) {
+	tmpl, err := template.New("").Funcs(goTemplateFuncs).Option(options...).Parse(value)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse template %q: %w", value, err)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, params); err != nil {
+		return "", fmt.Errorf("failed to render template %q: %w", value, err)
+	}
+	return buf.String(), nil
+}
+
+// params returns the parameter sets produced by a generator
+func (g Generator) params(goTemplate bool, opts ExpandOptions) ([]map[string]interface{}, error) {
+	switch {
+	case g.List != nil:
+		return g.List.params(goTemplate), nil
+	case g.Clusters != nil:
+		return g.Clusters.params(goTemplate, opts.Clusters), nil
+	case g.Git != nil:
+		return g.Git.params(goTemplate, opts.RepoPath)
+	case g.Unsupported != "":
+		return nil, fmt.Errorf("unsupported generator %q", g.Unsupported)
+	default:
+		return nil, fmt.Errorf("empty generator")
+	}
+}
+
+func (g *ListGenerator) params(goTemplate bool) []map[string]interface{} {
+	var sets []map[string]interface{}
+	for _, element := range g.Elements {
+		if goTemplate {
+			sets = append(sets, element)
+		} else {
+			sets = append(sets, flattenParams("", element))
+		}
+	}
+	return sets
+}
+
+func (g *ClustersGenerator) params(goTemplate bool, clusters []Cluster) []map[string]interface{} {
+	var sets []map[string]interface{}
+	for _, c := range clusters {
+		if !matchesLabels(c.Labels, g.Selector.MatchLabels) {
+			continue
+		}
+
+		labels := make(map[string]interface{}, len(c.Labels))
+		for k, v := range c.Labels {
+			labels[k] = v
+		}
+		values := make(map[string]interface{}, len(g.Values))
+		for k, v := range g.Values {
+			values[k] = v
+		}
+
+		p := map[string]interface{}{
+			"name":           c.Name,
+			"nameNormalized": normalizeName(c.Name),
+			"server":         c.Server,
+			"metadata":       map[string]interface{}{"labels": labels},
+			"values":         values,
+		}
+		if !goTemplate {
+			p = flattenParams("", p)
+		}
+		sets = append(sets, p)
+	}
+	return sets
+}
+
+func (g *GitGenerator) params(goTemplate bool, repoPath string) ([]map[string]interface{}, error) {
+	if repoPath == "" {
+		return nil, fmt.Errorf("git generator requires a local repository path")
+	}
+
+	var sets []map[string]interface{}
+
+	if len(g.Directories) > 0 {
+		dirs, err := matchRepoPaths(repoPath, true, func(rel string) bool {
+			matched := false
+			for _, d := range g.Directories {
+				if ok, _ := path.Match(d.Path, rel); ok {
+					if d.Exclude {
+						return false
+					}
+					matched = true
+				}
+			}
+			return matched
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, dir := range dirs {
+			sets = append(sets, pathParams(dir, "", goTemplate))
+		}
+	}
+
+	for _, f := range g.Files {
+		files, err := matchRepoPaths(repoPath, false, func(rel string) bool {
+			ok, _ := path.Match(f.Path, rel)
+			return ok
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			elements, err := readGeneratorFile(filepath.Join(repoPath, file))
+			if err != nil {
+				return nil, err
+			}
+			for _, element := range elements {
+				p := pathParams(path.Dir(file), path.Base(file), goTemplate)
+				if goTemplate {
+					for k, v := range element {
+						p[k] = v
+					}
+				} else {
+					for k, v := range flattenParams("", element) {
+						p[k] = v
+					}
+				}
+				sets = append(sets, p)
+			}
+		}
+	}
+
+	return sets, nil
+}
+
+// pathParams builds the path.* parameters git generators expose
+func pathParams(dir, filename string, goTemplate bool) map[string]interface{} {
+	segments := strings.Split(dir, "/")
+	base := path.Base(dir)
+
+	if goTemplate {
+		p := map[string]interface{}{
+			"path":               dir,
+			"basename":           base,
+			"basenameNormalized": normalizeName(base),
+			"segments":           segments,
+		}
+		if filename != "" {
+			p["filename"] = filename
+			p["filenameNormalized"] = normalizeName(filename)
+		}
+		return map[string]interface{}{"path": p}
+	}
+
+	p := map[string]interface{}{
+		"path":                    dir,
+		"path.basename":           base,
+		"path.basenameNormalized": normalizeName(base),
+	}
+	if filename != "" {
+		p["path.filename"] = filename
+		p["path.filenameNormalized"] = normalizeName(filename)
+	}
+	for i, segment := range segments {
+		p["path["+strconv.Itoa(i)+"]"] = segment
+	}
+	return p
+}
+
+// matchRepoPaths walks the repository and returns slash-separated relative
+// paths of directories (or files) accepted by match, sorted
+func matchRepoPaths(repoPath string, dirs bool, match func(rel string) bool) ([]string, error) {
+	var matches []string
+	err := filepath.WalkDir(repoPath, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && strings.HasPrefix(d.Name(), ".") && p != repoPath {
+			return filepath.SkipDir
+		}
+		if d.IsDir() != dirs {
+			return nil
+		}
+		rel, err := filepath.Rel(repoPath, p)
+		if err != nil || rel == "." {
+			return nil
+		}
+		rel = filepath.ToSlash(rel)
+		if match(rel) {
+			matches = append(matches, rel)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to walk %s: %w", repoPath, err)
+	}
+	sort.Strings(matches)
+	return matches, nil
+}
+
+// readGeneratorFile parses a git files generator file (JSON or YAML)
+// A file may hold a single object or a list of objects
+func readGeneratorFile(p string) ([]map[string]interface{}, error) {
+	data, err := os.ReadFile(p)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", p, err)
+	}
+
+	var doc interface{}
+	if strings.HasSuffix(p, ".json") {
+		err = json.Unmarshal(data, &doc)
+	} else {
+		err = yaml.Unmarshal(data, &doc)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", p, err)
+	}
+
+	switch v := doc.(type) {
+	case map[string]interface{}:
+		return []map[string]interface{}{v}, nil
+	case []interface{}:
+		var elements []map[string]interface{}
+		for _, item := range v {
+			if m, ok := item.(map[string]interface{}); ok {
+				elements = append(elements, m)
+			}
+		}
+		return elements, nil
+	default:
+		return nil, fmt.Errorf("%s: expected an object or a list of objects", p)
+	}
+}
+
+// flattenParams converts nested maps into dotted keys for {{a.b}} templates
+func flattenParams(prefix string, params map[string]interface{}) map[string]interface{} {
+	flat := make(map[string]interface{})
+	for k, v := range params {
+		key := k
+		if prefix != "" {
+			key = prefix + "." + k
+		}
+		if nested, ok := v.(map[string]interface{}); ok {
+			for nk, nv := range flattenParams(key, nested) {
+				flat[nk] = nv
+			}
+			continue
+		}
+		flat[key] = v
+	}
+	return flat
+}
+
+// matchesLabels reports whether labels satisfy a matchLabels selector
+func matchesLabels(labels, selector map[string]string) bool {
+	for k, v := range selector {
+		if labels[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// invalidNameChars matches characters not allowed in Kubernetes resource names
+var invalidNameChars = regexp.MustCompile(`[^a-z0-9.-]`)
+
+// normalizeName mirrors the controller's *Normalized parameters:
+// lowercase, with anything other than [a-z0-9.-] replaced by a hyphen
+func normalizeName(s string) string {
+	return invalidNameChars.ReplaceAllString(strings.ToLower(s), "-")
+}
+
+// LocalClusters returns the registered clusters (clusters.yaml or clusters/)
+// for the clusters generator, carrying any labels from clusters.yaml
+// Each cluster runs its own ArgoCD, so clusters without a server in
+// clusters.yaml are addressed as in-cluster
+func LocalClusters(repoPath string) []Cluster {
+	registry, err := cluster.Load(repoPath)
+	if err != nil {
+		return nil
+	}
+
+	var clusters []Cluster
+	for _, c := range registry.Clusters() {
+		server := c.Server
+		if server == "" {
+			server = InClusterServer
+		}
+		clusters = append(clusters, Cluster{Name: c.Name, Server: server, Labels: c.Labels})
+	}
+	return clusters
+}
@@ -0,0 +1,289 @@
+package argocd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("failed to create dir: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write %s: %v", path, err)
+	}
+}
+
+func appNames(apps []*Application) []string {
+	var names []string
+	for _, app := range apps {
+		names = append(names, app.Name)
+	}
+	return names
+}
+
+func TestApplicationSet_ListGenerator(t *testing.T) {
+	set, err := ParseApplicationSetYAML([]byte(`
+apiVersion: argoproj.io/v1alpha1
+kind: ApplicationSet
+metadata:
+  name: guestbook
+spec:
+  generators:
+    - list:
+        elements:
+          - cluster: erauner-home
+            env: production
+          - cluster: erauner-cloud
+            env: staging
+  template:
+    metadata:
+      name: 'guestbook-{{cluster}}'
+    spec:
+      destination:
+        namespace: guestbook
+      source:
+        repoURL: git@github.com:erauner/homelab-k8s.git
+        path: 'apps/guestbook/overlays/{{cluster}}/{{ env }}'
+`))
+	if err != nil {
+		t.Fatalf("ParseApplicationSetYAML failed: %v", err)
+	}
+
+	apps, err := set.Expand(ExpandOptions{})
+	if err != nil {
+		t.Fatalf("Expand failed: %v", err)
+	}
+
+	if len(apps) != 2 {
+		t.Fatalf("expected 2 apps, got %d", len(apps))
+	}
+	if apps[0].Name != "guestbook-erauner-home" {
+		t.Errorf("expected name 'guestbook-erauner-home', got %q", apps[0].Name)
+	}
+	if apps[1].Source.Path != "apps/guestbook/overlays/erauner-cloud/staging" {
+		t.Errorf("unexpected path %q", apps[1].Source.Path)
+	}
+	if apps[0].ApplicationSet != "guestbook" {
+		t.Errorf("expected ApplicationSet 'guestbook', got %q", apps[0].ApplicationSet)
+	}
+	if apps[0].Namespace != "guestbook" {
+		t.Errorf("expected namespace 'guestbook', got %q", apps[0].Namespace)
+	}
+}
+
+func TestApplicationSet_ClustersGenerator(t *testing.T) {
+	set, err := ParseApplicationSetYAML([]byte(`
+kind: ApplicationSet
+metadata:
+  name: monitoring
+spec:
+  generators:
+    - clusters:
+        selector:
+          matchLabels:
+            tier: prod
+        values:
+          revision: v2
+  template:
+    metadata:
+      name: 'monitoring-{{nameNormalized}}'
+    spec:
+      source:
+        path: 'infrastructure/monitoring/overlays/{{name}}'
+        targetRevision: '{{values.revision}}'
+`))
+	if err != nil {
+		t.Fatalf("ParseApplicationSetYAML failed: %v", err)
+	}
+
+	apps, err := set.Expand(ExpandOptions{Clusters: []Cluster{
+		{Name: "Erauner-Home", Server: InClusterServer, Labels: map[string]string{"tier": "prod"}},
+		{Name: "lab", Server: InClusterServer, Labels: map[string]string{"tier": "dev"}},
+	}})
+	if err != nil {
+		t.Fatalf("Expand failed: %v", err)
+	}
+
+	if len(apps) != 1 {
+		t.Fatalf("expected 1 app (selector), got %v", appNames(apps))
+	}
+	if apps[0].Name != "monitoring-erauner-home" {
+		t.Errorf("expected normalized name, got %q", apps[0].Name)
+	}
+	if apps[0].Source.Path != "infrastructure/monitoring/overlays/Erauner-Home" {
+		t.Errorf("unexpected path %q", apps[0].Source.Path)
+	}
+	if apps[0].Source.TargetRevision != "v2" {
+		t.Errorf("expected targetRevision from values, got %q", apps[0].Source.TargetRevision)
+	}
+}
+
+func TestApplicationSet_GitDirectoriesGoTemplate(t *testing.T) {
+	repo := t.TempDir()
+	for _, dir := range []string{
+		"apps/coder/overlays/erauner-home/production",
+		"apps/jenkins/overlays/erauner-home/production",
+		"apps/legacy/overlays/erauner-home/production",
+	} {
+		if err := os.MkdirAll(filepath.Join(repo, dir), 0755); err != nil {
+			t.Fatalf("failed to create dir: %v", err)
+		}
+	}
+
+	set, err := ParseApplicationSetYAML([]byte(`
+kind: ApplicationSet
+metadata:
+  name: apps
+spec:
+  goTemplate: true
+  goTemplateOptions: ["missingkey=error"]
+  generators:
+    - git:
+        repoURL: git@github.com:erauner/homelab-k8s.git
+        revision: HEAD
+        directories:
+          - path: apps/*/overlays/erauner-home/production
+          - path: apps/legacy/overlays/erauner-home/production
+            exclude: true
+  template:
+    metadata:
+      name: '{{ index .path.segments 1 }}'
+    spec:
+      destination:
+        namespace: '{{ index .path.segments 1 | upper | lower }}'
+      source:
+        path: '{{ .path.path }}'
+`))
+	if err != nil {
+		t.Fatalf("ParseApplicationSetYAML failed: %v", err)
+	}
+
+	apps, err := set.Expand(ExpandOptions{RepoPath: repo})
+	if err != nil {
+		t.Fatalf("Expand failed: %v", err)
+	}
+
+	names := appNames(apps)
+	if len(names) != 2 || names[0] != "coder" || names[1] != "jenkins" {
+		t.Fatalf("expected [coder jenkins], got %v", names)
+	}
+	if apps[0].Source.Path != "apps/coder/overlays/erauner-home/production" {
+		t.Errorf("unexpected path %q", apps[0].Source.Path)
+	}
+	if apps[1].Namespace != "jenkins" {
+		t.Errorf("expected namespace 'jenkins', got %q", apps[1].Namespace)
+	}
+}
+
+func TestApplicationSet_GitFiles(t *testing.T) {
+	repo := t.TempDir()
+	writeFile(t, filepath.Join(repo, "clusters/erauner-home/config.json"), `{"cluster": {"name": "erauner-home", "env": "production"}}`)
+	writeFile(t, filepath.Join(repo, "clusters/erauner-cloud/config.json"), `{"cluster": {"name": "erauner-cloud", "env": "staging"}}`)
+
+	set, err := ParseApplicationSetYAML([]byte(`
+kind: ApplicationSet
+metadata:
+  name: per-cluster
+spec:
+  generators:
+    - git:
+        files:
+          - path: clusters/*/config.json
+  template:
+    metadata:
+      name: 'krr-{{cluster.name}}'
+    spec:
+      source:
+        path: 'apps/krr/overlays/{{path.basename}}/{{cluster.env}}'
+`))
+	if err != nil {
+		t.Fatalf("ParseApplicationSetYAML failed: %v", err)
+	}
+
+	apps, err := set.Expand(ExpandOptions{RepoPath: repo})
+	if err != nil {
+		t.Fatalf("Expand failed: %v", err)
+	}
+
+	if len(apps) != 2 {
+		t.Fatalf("expected 2 apps, got %v", appNames(apps))
+	}
+	if apps[0].Name != "krr-erauner-cloud" || apps[0].Source.Path != "apps/krr/overlays/erauner-cloud/staging" {
+		t.Errorf("unexpected app %s at %q", apps[0].Name, apps[0].Source.Path)
+	}
+}
+
+func TestApplicationSet_UnsupportedGenerator(t *testing.T) {
+	set, err := ParseApplicationSetYAML([]byte(`
+kind: ApplicationSet
+metadata:
+  name: matrix
+spec:
+  generators:
+    - matrix:
+        generators: []
+  template:
+    metadata:
+      name: x
+`))
+	if err != nil {
+		t.Fatalf("ParseApplicationSetYAML failed: %v", err)
+	}
+	if _, err := set.Expand(ExpandOptions{}); err == nil {
+		t.Error("expected error for unsupported matrix generator")
+	}
+}
+
+func TestDiscoverHelmApplications_ExpandsApplicationSets(t *testing.T) {
+	repo := t.TempDir()
+	writeFile(t, filepath.Join(repo, "clusters/erauner-home/bootstrap/kustomization.yaml"), "resources: []\n")
+	writeFile(t, filepath.Join(repo, "argocd-apps/applications/charts.yaml"), `apiVersion: argoproj.io/v1alpha1
+kind: ApplicationSet
+metadata:
+  name: charts
+spec:
+  generators:
+    - clusters: {}
+  template:
+    metadata:
+      name: 'krr-{{name}}'
+    spec:
+      destination:
+        namespace: krr
+      sources:
+        - repoURL: https://charts.example.com
+          chart: krr
+          targetRevision: 1.0.0
+---
+apiVersion: argoproj.io/v1alpha1
+kind: Application
+metadata:
+  name: plain
+spec:
+  source:
+    repoURL: https://charts.example.com
+    chart: plain
+    targetRevision: 2.0.0
+`)
+
+	apps, err := DiscoverHelmApplications(repo)
+	if err != nil {
+		t.Fatalf("DiscoverHelmApplications failed: %v", err)
+	}
+
+	names := appNames(apps)
+	if len(names) != 2 || names[0] != "krr-erauner-home" || names[1] != "plain" {
+		t.Fatalf("expected [krr-erauner-home plain], got %v", names)
+	}
+
+	found, path, err := FindApplication(repo, "krr-erauner-home")
+	if err != nil {
+		t.Fatalf("FindApplication failed: %v", err)
+	}
+	if found.ApplicationSet != "charts" || filepath.Base(path) != "charts.yaml" {
+		t.Errorf("unexpected result %+v from %s", found, path)
+	}
+}
@@ -0,0 +1,62 @@
+package argocd
+
+import (
+	"path"
+	"strings"
+
+	"github.com/erauner/homelab-shadow/pkg/cluster"
+)
+
+// InClusterName is the destination name of the cluster ArgoCD itself runs in
+const InClusterName = "in-cluster"
+
+// Destination is where an Application deploys, resolved against the cluster
+// registry
+type Destination struct {
+	App       string `json:"app"`
+	Cluster   string `json:"cluster,omitempty"` // registered cluster; "" when unresolved
+	Server    string `json:"server,omitempty"`  // API server, from the Application or the registry
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// Destination resolves the Application's spec.destination to a registered
+// cluster: by name, or by server for destinations without one (see
+// cluster.Cluster.Server). An in-cluster destination is the cluster whose
+// ArgoCD manages the Application, taken from the registered cluster directory
+// its manifest is under (argocd-apps/<cluster>/...), since every cluster's
+// ArgoCD addresses itself by the same server. Destinations matching none of
+// these are unresolved; the cluster isn't guessed from source paths
+func (a *Application) Destination(registry *cluster.Registry) Destination {
+	d := Destination{App: a.Name, Server: a.Server, Namespace: a.Namespace}
+	if c, ok := registry.Get(a.Cluster); ok {
+		d.Cluster = c.Name
+		if d.Server == "" {
+			d.Server = c.Server
+		}
+	} else if name := a.managingCluster(registry); name != "" && a.inCluster() {
+		d.Cluster = name
+	} else if c, ok := registry.ByServer(a.Server); ok && a.Cluster == a.Server {
+		d.Cluster = c.Name
+	}
+	return d
+}
+
+// inCluster reports whether the Application deploys to the cluster its
+// ArgoCD runs in
+func (a *Application) inCluster() bool {
+	return a.Cluster == InClusterName || strings.TrimSuffix(a.Server, "/") == InClusterServer
+}
+
+// managingCluster returns the first directory of the Application's manifest
+// path that is a registered cluster, or ""
+func (a *Application) managingCluster(registry *cluster.Registry) string {
+	if a.Manifest == "" {
+		return ""
+	}
+	for _, dir := range strings.Split(path.Dir(a.Manifest), "/") {
+		if c, ok := registry.Get(dir); ok {
+			return c.Name
+		}
+	}
+	return ""
+}
@@ -0,0 +1,78 @@
+package argocd
+
+import (
+	"testing"
+
+	"github.com/erauner/homelab-shadow/pkg/cluster"
+)
+
+func TestApplication_Destination(t *testing.T) {
+	registry := cluster.NewRegistry(cluster.SourceFile, []cluster.Cluster{
+		{Name: "erauner-home", Server: "https://kubernetes.default.svc"},
+		{Name: "erauner-cloud"},
+	})
+
+	tests := []struct {
+		name        string
+		manifest    string
+		destination string
+		want        Destination
+	}{
+		{
+			name:        "by name",
+			destination: "name: erauner-cloud\n    namespace: coder",
+			want:        Destination{App: "coder", Cluster: "erauner-cloud", Namespace: "coder"},
+		},
+		{
+			name:        "by name, server from the registry",
+			destination: "name: erauner-home",
+			want:        Destination{App: "coder", Cluster: "erauner-home", Server: "https://kubernetes.default.svc"},
+		},
+		{
+			name:        "by server",
+			destination: "server: https://kubernetes.default.svc/",
+			want:        Destination{App: "coder", Cluster: "erauner-home", Server: "https://kubernetes.default.svc/"},
+		},
+		{
+			name:        "unresolved",
+			destination: "server: https://edge.example.com:6443",
+			want:        Destination{App: "coder", Server: "https://edge.example.com:6443"},
+		},
+		{
+			name:        "unregistered name",
+			destination: "name: edge\n    server: https://kubernetes.default.svc",
+			want:        Destination{App: "coder", Server: "https://kubernetes.default.svc"},
+		},
+		{
+			name:        "in-cluster, from the manifest's cluster directory",
+			manifest:    "argocd-apps/erauner-cloud/coder.yaml",
+			destination: "server: https://kubernetes.default.svc",
+			want:        Destination{App: "coder", Cluster: "erauner-cloud", Server: "https://kubernetes.default.svc"},
+		},
+		{
+			name:        "in-cluster by name",
+			manifest:    "argocd-apps/apps/erauner-cloud/coder.yaml",
+			destination: "name: in-cluster",
+			want:        Destination{App: "coder", Cluster: "erauner-cloud"},
+		},
+		{
+			name:        "remote server under a cluster directory",
+			manifest:    "argocd-apps/erauner-cloud/coder.yaml",
+			destination: "server: https://edge.example.com:6443",
+			want:        Destination{App: "coder", Server: "https://edge.example.com:6443"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app, err := ParseApplicationYAML([]byte("kind: Application\nmetadata:\n  name: coder\nspec:\n  destination:\n    " + tt.destination + "\n"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			app.Manifest = tt.manifest
+			if got := app.Destination(registry); got != tt.want {
+				t.Errorf("Destination() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
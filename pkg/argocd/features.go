@@ -0,0 +1,163 @@
+package argocd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Feature is an Application spec feature that requires a minimum ArgoCD version
+type Feature struct {
+	Name  string // short identifier, e.g. "helm.valuesObject"
+	Since string // first ArgoCD minor that supports it, e.g. "2.8"
+
+	// Detect reports whether an Application spec (as decoded YAML) uses the feature
+	Detect func(spec map[string]interface{}) bool
+}
+
+// Features lists version-gated Application spec features, oldest first
+var Features = []Feature{
+	{
+		Name:  "syncPolicy.managedNamespaceMetadata",
+		Since: "2.5",
+		Detect: func(spec map[string]interface{}) bool {
+			return lookup(spec, "syncPolicy", "managedNamespaceMetadata") != nil
+		},
+	},
+	{
+		Name:  "syncOptions ServerSideApply=true",
+		Since: "2.5",
+		Detect: func(spec map[string]interface{}) bool {
+			return hasSyncOption(spec, "ServerSideApply=true")
+		},
+	},
+	{
+		Name:  "multiple sources (spec.sources)",
+		Since: "2.6",
+		Detect: func(spec map[string]interface{}) bool {
+			sources, _ := spec["sources"].([]interface{})
+			return len(sources) > 0
+		},
+	},
+	{
+		Name:  "ref sources ($values)",
+		Since: "2.6",
+		Detect: func(spec map[string]interface{}) bool {
+			for _, source := range specSources(spec) {
+				if source["ref"] != nil {
+					return true
+				}
+			}
+			return false
+		},
+	},
+	{
+		Name:  "helm.valuesObject",
+		Since: "2.8",
+		Detect: func(spec map[string]interface{}) bool {
+			for _, source := range specSources(spec) {
+				if lookup(source, "helm", "valuesObject") != nil {
+					return true
+				}
+			}
+			return false
+		},
+	},
+	{
+		Name:  "kustomize.patches",
+		Since: "2.9",
+		Detect: func(spec map[string]interface{}) bool {
+			for _, source := range specSources(spec) {
+				if lookup(source, "kustomize", "patches") != nil {
+					return true
+				}
+			}
+			return false
+		},
+	},
+}
+
+// UsedFeatures returns the version-gated features an Application spec uses
+func UsedFeatures(spec map[string]interface{}) []Feature {
+	var used []Feature
+	for _, f := range Features {
+		if f.Detect(spec) {
+			used = append(used, f)
+		}
+	}
+	return used
+}
+
+// VersionSupports reports whether ArgoCD version installed (e.g. "v2.7.14" or
+// "2.7") is at least since
+func VersionSupports(installed, since string) (bool, error) {
+	have, err := ParseMinorVersion(installed)
+	if err != nil {
+		return false, err
+	}
+	want, err := ParseMinorVersion(since)
+	if err != nil {
+		return false, err
+	}
+	if have[0] != want[0] {
+		return have[0] > want[0], nil
+	}
+	return have[1] >= want[1], nil
+}
+
+// ParseMinorVersion parses the major and minor parts of "v2.8.3" or "2.8"
+func ParseMinorVersion(version string) ([2]int, error) {
+	var v [2]int
+	parts := strings.Split(strings.TrimPrefix(strings.TrimSpace(version), "v"), ".")
+	if len(parts) < 2 {
+		return v, fmt.Errorf("invalid ArgoCD version %q (expected <major>.<minor>[.<patch>])", version)
+	}
+	for i := range v {
+		n, err := strconv.Atoi(parts[i])
+		if err != nil {
+			return v, fmt.Errorf("invalid ArgoCD version %q (expected <major>.<minor>[.<patch>])", version)
+		}
+		v[i] = n
+	}
+	return v, nil
+}
+
+// specSources returns spec.source and spec.sources[] as maps
+func specSources(spec map[string]interface{}) []map[string]interface{} {
+	var sources []map[string]interface{}
+	if source, ok := spec["source"].(map[string]interface{}); ok {
+		sources = append(sources, source)
+	}
+	if list, ok := spec["sources"].([]interface{}); ok {
+		for _, item := range list {
+			if source, ok := item.(map[string]interface{}); ok {
+				sources = append(sources, source)
+			}
+		}
+	}
+	return sources
+}
+
+// hasSyncOption reports whether spec.syncPolicy.syncOptions contains option
+func hasSyncOption(spec map[string]interface{}, option string) bool {
+	options, _ := lookup(spec, "syncPolicy", "syncOptions").([]interface{})
+	for _, o := range options {
+		if s, ok := o.(string); ok && strings.EqualFold(s, option) {
+			return true
+		}
+	}
+	return false
+}
+
+// lookup walks nested maps by key, returning nil if any key is missing
+func lookup(m map[string]interface{}, keys ...string) interface{} {
+	var current interface{} = m
+	for _, key := range keys {
+		next, ok := current.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		current = next[key]
+	}
+	return current
+}
@@ -0,0 +1,76 @@
+package argocd
+
+import (
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestUsedFeatures(t *testing.T) {
+	var spec map[string]interface{}
+	err := yaml.Unmarshal([]byte(`
+sources:
+  - repoURL: https://charts.example.com
+    chart: app
+    helm:
+      valuesObject: {replicas: 1}
+  - repoURL: git@github.com:erauner/homelab-k8s.git
+    ref: values
+syncPolicy:
+  syncOptions:
+    - CreateNamespace=true
+    - ServerSideApply=true
+`), &spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, f := range UsedFeatures(spec) {
+		names = append(names, f.Name)
+	}
+	want := []string{"syncOptions ServerSideApply=true", "multiple sources (spec.sources)", "ref sources ($values)", "helm.valuesObject"}
+	if len(names) != len(want) {
+		t.Fatalf("UsedFeatures() = %v, want %v", names, want)
+	}
+	for i := range want {
+		if names[i] != want[i] {
+			t.Errorf("UsedFeatures()[%d] = %q, want %q", i, names[i], want[i])
+		}
+	}
+
+	var plain map[string]interface{}
+	if err := yaml.Unmarshal([]byte("source:\n  path: apps/coder\n"), &plain); err != nil {
+		t.Fatal(err)
+	}
+	if used := UsedFeatures(plain); len(used) != 0 {
+		t.Errorf("expected no gated features for a plain source, got %v", used)
+	}
+}
+
+func TestVersionSupports(t *testing.T) {
+	tests := []struct {
+		installed, since string
+		want             bool
+	}{
+		{"v2.8.4", "2.8", true},
+		{"2.7.14", "2.8", false},
+		{"2.10", "2.9", true},
+		{"3.0.0", "2.9", true},
+		{"1.8", "2.5", false},
+	}
+
+	for _, tt := range tests {
+		got, err := VersionSupports(tt.installed, tt.since)
+		if err != nil {
+			t.Fatalf("VersionSupports(%q, %q) error = %v", tt.installed, tt.since, err)
+		}
+		if got != tt.want {
+			t.Errorf("VersionSupports(%q, %q) = %v, want %v", tt.installed, tt.since, got, tt.want)
+		}
+	}
+
+	if _, err := VersionSupports("latest", "2.8"); err == nil {
+		t.Error("expected error for invalid version")
+	}
+}
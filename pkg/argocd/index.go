@@ -0,0 +1,166 @@
+package argocd
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	"github.com/erauner/homelab-shadow/pkg/cachedir"
+)
+
+// IndexDir holds the parsed Application index between runs (empty disables it)
+// The shadow CLI sets it from --app-index-dir
+var IndexDir string
+
+// indexVersion invalidates index files written by an older format
+const indexVersion = 6
+
+// DefaultIndexDir returns the default Application index directory
+func DefaultIndexDir() string {
+	return cachedir.Path("argocd")
+}
+
+// appIndex caches the Applications parsed from each file under argocd-apps/,
+// keyed by path and validated by mtime/size, then content hash
+//
+// Files containing ApplicationSets are never indexed: their expansion depends
+// on the cluster list and (for git generators) other files in the repo
+type appIndex struct {
+	Version int                   `json:"version"`
+	Files   map[string]indexEntry `json:"files"`
+
+	path  string
+	seen  map[string]bool
+	dirty bool
+}
+
+// indexEntry is the cached parse of one file
+type indexEntry struct {
+	ModTime int64          `json:"mtime"`
+	Size    int64          `json:"size"`
+	Hash    string         `json:"sha256"`
+	Apps    []*Application `json:"apps"`
+}
+
+// loadIndex reads the index for rootPath from IndexDir
+// A missing, unreadable, or outdated index starts empty; nil means indexing is disabled
+func loadIndex(rootPath string) *appIndex {
+	if IndexDir == "" {
+		return nil
+	}
+	abs, err := filepath.Abs(rootPath)
+	if err != nil {
+		return nil
+	}
+	sum := sha256.Sum256([]byte(abs))
+	index := &appIndex{
+		path: filepath.Join(IndexDir, "apps-"+hex.EncodeToString(sum[:8])+".json"),
+		seen: make(map[string]bool),
+	}
+
+	if data, err := os.ReadFile(index.path); err == nil {
+		if json.Unmarshal(data, index) != nil || index.Version != indexVersion {
+			index.Files = nil
+		}
+	}
+	if index.Files == nil {
+		index.Files = make(map[string]indexEntry)
+		index.dirty = true
+	}
+	index.Version = indexVersion
+	return index
+}
+
+// lookup returns the cached Applications for file, or false when it changed
+// data is the file content when lookup had to read it (nil on an mtime hit)
+func (idx *appIndex) lookup(rel, path string) (apps []*Application, data []byte, ok bool) {
+	if idx == nil {
+		return nil, nil, false
+	}
+	idx.seen[rel] = true
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, nil, false
+	}
+	entry, cached := idx.Files[rel]
+	if cached && entry.ModTime == info.ModTime().UnixNano() && entry.Size == info.Size() {
+		return entry.Apps, nil, true
+	}
+
+	data, err = os.ReadFile(path)
+	if err != nil {
+		return nil, nil, false
+	}
+	if cached && entry.Hash == hashBytes(data) {
+		// Touched but unchanged (e.g. a fresh checkout): refresh the mtime
+		entry.ModTime = info.ModTime().UnixNano()
+		entry.Size = info.Size()
+		idx.Files[rel] = entry
+		idx.dirty = true
+		return entry.Apps, data, true
+	}
+	return nil, data, false
+}
+
+// store caches the Applications parsed from file unless it holds ApplicationSets
+func (idx *appIndex) store(rel, path string, data []byte, apps []*Application) {
+	if idx == nil || bytes.Contains(data, []byte("ApplicationSet")) {
+		return
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return
+	}
+	idx.Files[rel] = indexEntry{
+		ModTime: info.ModTime().UnixNano(),
+		Size:    info.Size(),
+		Hash:    hashBytes(data),
+		Apps:    apps,
+	}
+	idx.dirty = true
+}
+
+// save drops entries for files that no longer exist and writes the index
+// Errors are ignored: the index is only an optimization
+func (idx *appIndex) save() {
+	if idx == nil {
+		return
+	}
+	for rel := range idx.Files {
+		if !idx.seen[rel] {
+			delete(idx.Files, rel)
+			idx.dirty = true
+		}
+	}
+	if !idx.dirty {
+		return
+	}
+
+	data, err := json.Marshal(idx)
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(idx.path), 0755); err != nil {
+		return
+	}
+	// Write then rename so concurrent runs never read a partial index
+	tmp, err := os.CreateTemp(filepath.Dir(idx.path), ".apps-*.json")
+	if err != nil {
+		return
+	}
+	_, writeErr := tmp.Write(data)
+	closeErr := tmp.Close()
+	if writeErr != nil || closeErr != nil || os.Rename(tmp.Name(), idx.path) != nil {
+		os.Remove(tmp.Name())
+	}
+}
+
+// hashBytes returns the hex SHA-256 of data
+func hashBytes(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,120 @@
+package argocd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeApp(t *testing.T, path, name string, mtime time.Time) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(fmtApp(name)), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func fmtApp(name string) string {
+	return "apiVersion: argoproj.io/v1alpha1\nkind: Application\nmetadata:\n  name: " + name +
+		"\nspec:\n  sources:\n    - repoURL: https://charts.example.com\n      chart: web\n      targetRevision: 1.0.0\n      helm:\n        valueFiles: [$values/apps/web/values.yaml]\n"
+}
+
+func TestLoadApplications_Index(t *testing.T) {
+	root := t.TempDir()
+	IndexDir = t.TempDir()
+	defer func() { IndexDir = "" }()
+
+	appFile := filepath.Join(root, "argocd-apps", "web.yaml")
+	mtime := time.Now().Add(-time.Hour).Truncate(time.Second)
+	writeApp(t, appFile, "web", mtime)
+
+	apps, _, err := LoadApplications(root)
+	if err != nil || len(apps) != 1 {
+		t.Fatalf("first load = %v, %v", apps, err)
+	}
+	entries, _ := filepath.Glob(filepath.Join(IndexDir, "apps-*.json"))
+	if len(entries) != 1 {
+		t.Fatalf("expected one index file, got %v", entries)
+	}
+
+	// Same mtime and size: served from the index, so a same-length edit that
+	// keeps the mtime is not seen
+	writeApp(t, appFile, "wex", mtime)
+	apps, _, _ = LoadApplications(root)
+	if len(apps) != 1 || apps[0].Name != "web" {
+		t.Errorf("expected index hit, got %+v", apps)
+	}
+	if len(apps[0].GetHelmSources()) != 1 || apps[0].GetHelmSources()[0].Helm.ValueFiles[0] != "$values/apps/web/values.yaml" {
+		t.Errorf("indexed Application lost its sources: %+v", apps[0])
+	}
+
+	// A new mtime forces a hash check, which sees the change
+	writeApp(t, appFile, "wex", mtime.Add(time.Minute))
+	apps, _, _ = LoadApplications(root)
+	if len(apps) != 1 || apps[0].Name != "wex" {
+		t.Errorf("expected re-parse, got %+v", apps)
+	}
+
+	// Removed files drop out of the index
+	os.Remove(appFile)
+	if apps, _, _ := LoadApplications(root); len(apps) != 0 {
+		t.Errorf("expected no Applications, got %+v", apps)
+	}
+	index := loadIndex(root)
+	if len(index.Files) != 0 {
+		t.Errorf("stale index entries: %v", index.Files)
+	}
+}
+
+func TestLoadApplications_IndexSkipsApplicationSets(t *testing.T) {
+	root := t.TempDir()
+	IndexDir = t.TempDir()
+	defer func() { IndexDir = "" }()
+
+	set := `apiVersion: argoproj.io/v1alpha1
+kind: ApplicationSet
+metadata:
+  name: web
+spec:
+  generators:
+    - list:
+        elements:
+          - env: production
+  template:
+    metadata:
+      name: web-{{env}}
+    spec:
+      source:
+        path: apps/web/overlays/{{env}}
+`
+	path := filepath.Join(root, "argocd-apps", "web-set.yaml")
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(set), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	apps, _, err := LoadApplications(root)
+	if err != nil || len(apps) != 1 || apps[0].Name != "web-production" {
+		t.Fatalf("LoadApplications = %v, %v", apps, err)
+	}
+	if index := loadIndex(root); len(index.Files) != 0 {
+		t.Errorf("ApplicationSet file was indexed: %v", index.Files)
+	}
+}
+
+func TestLoadApplications_NoIndex(t *testing.T) {
+	if IndexDir != "" {
+		t.Fatalf("IndexDir should default to disabled, got %q", IndexDir)
+	}
+	if loadIndex(t.TempDir()) != nil {
+		t.Error("expected nil index when disabled")
+	}
+}
@@ -0,0 +1,200 @@
+package argocd
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ErrNotDeployed is an Application the live source doesn't know
+var ErrNotDeployed = errors.New("application not deployed")
+
+// LiveApplication is an Application as ArgoCD runs it: the sources in its
+// live spec (including parameter overrides set outside git), the revision it
+// last synced, and the manifests it wants applied
+type LiveApplication struct {
+	Name    string
+	Sources []Source // spec.sources, or spec.source
+
+	// Revisions are status.sync.revisions (multi-source) or status.sync.revision
+	Revisions []string
+
+	SyncStatus string // status.sync.status: Synced, OutOfSync, Unknown
+	Health     string // status.health.status
+
+	// Manifests are the target manifests of the synced revision (argocd app
+	// manifests), one resource per entry, in YAML or JSON
+	Manifests []string
+}
+
+// LiveSource looks up Applications as ArgoCD runs them
+type LiveSource interface {
+	// Live returns the named Application, or an error wrapping
+	// ErrNotDeployed when ArgoCD doesn't have it
+	Live(ctx context.Context, name string) (*LiveApplication, error)
+}
+
+// liveApplication is the subset of the Application resource the ArgoCD API
+// (and argocd app get -o json) returns that drift needs
+type liveApplication struct {
+	Metadata struct {
+		Name string `yaml:"name"`
+	} `yaml:"metadata"`
+	Spec struct {
+		Source  *Source  `yaml:"source"`
+		Sources []Source `yaml:"sources"`
+	} `yaml:"spec"`
+	Status struct {
+		Sync struct {
+			Status    string   `yaml:"status"`
+			Revision  string   `yaml:"revision"`
+			Revisions []string `yaml:"revisions"`
+		} `yaml:"sync"`
+		Health struct {
+			Status string `yaml:"status"`
+		} `yaml:"health"`
+	} `yaml:"status"`
+}
+
+// parseLiveApplication decodes an Application resource (JSON is YAML)
+func parseLiveApplication(data []byte) (*LiveApplication, error) {
+	var raw liveApplication
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("invalid Application: %w", err)
+	}
+	app := &LiveApplication{
+		Name:       raw.Metadata.Name,
+		Sources:    raw.Spec.Sources,
+		Revisions:  raw.Status.Sync.Revisions,
+		SyncStatus: raw.Status.Sync.Status,
+		Health:     raw.Status.Health.Status,
+	}
+	if raw.Spec.Source != nil {
+		app.Sources = []Source{*raw.Spec.Source}
+	}
+	if len(app.Revisions) == 0 && raw.Status.Sync.Revision != "" {
+		app.Revisions = []string{raw.Status.Sync.Revision}
+	}
+	return app, nil
+}
+
+// Client reads Applications from the ArgoCD API server
+type Client struct {
+	Server string // host[:port] or https:// URL
+	Token  string // bearer token (ARGOCD_AUTH_TOKEN)
+	HTTP   *http.Client
+}
+
+// NewClient returns a client for server, skipping TLS verification when
+// insecure is set (argocd --insecure)
+func NewClient(server, token string, insecure bool) *Client {
+	c := &Client{Server: server, Token: token, HTTP: http.DefaultClient}
+	if insecure {
+		c.HTTP = &http.Client{Transport: &http.Transport{
+			Proxy:           http.ProxyFromEnvironment,
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		}}
+	}
+	return c
+}
+
+// Live fetches the Application and its target manifests
+func (c *Client) Live(ctx context.Context, name string) (*LiveApplication, error) {
+	path := "/api/v1/applications/" + url.PathEscape(name)
+	data, err := c.get(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	app, err := parseLiveApplication(data)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err = c.get(ctx, path+"/manifests")
+	if err != nil {
+		return nil, err
+	}
+	var manifests struct {
+		Manifests []string `json:"manifests"`
+	}
+	if err := json.Unmarshal(data, &manifests); err != nil {
+		return nil, fmt.Errorf("invalid manifests response for %s: %w", name, err)
+	}
+	app.Manifests = manifests.Manifests
+	return app, nil
+}
+
+// get returns the body of an API GET, failing on any status but 200
+func (c *Client) get(ctx context.Context, path string) ([]byte, error) {
+	base := strings.TrimSuffix(c.Server, "/")
+	if !strings.Contains(base, "://") {
+		base = "https://" + base
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", base+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "shadow-drift")
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return body, nil
+	case http.StatusNotFound:
+		return nil, fmt.Errorf("%s: %w", path, ErrNotDeployed)
+	case http.StatusForbidden:
+		// ArgoCD answers 403 for Applications the token can't see, including missing ones
+		return nil, fmt.Errorf("ArgoCD API returned 403 for %s (missing, or not visible to the token): %w", path, ErrNotDeployed)
+	default:
+		return nil, fmt.Errorf("ArgoCD API returned %d for %s: %s", resp.StatusCode, path, strings.TrimSpace(string(body)))
+	}
+}
+
+// DirSource reads Applications saved from the argocd CLI into Dir:
+// <name>.yaml is argocd app manifests <name> output and the optional
+// <name>.json is argocd app get <name> -o json output (spec and status)
+type DirSource struct {
+	Dir string
+}
+
+// Live reads the saved manifests and, when saved, the Application
+func (d DirSource) Live(ctx context.Context, name string) (*LiveApplication, error) {
+	manifests, err := os.ReadFile(filepath.Join(d.Dir, name+".yaml"))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("no %s.yaml in %s: %w", name, d.Dir, ErrNotDeployed)
+	}
+	if err != nil {
+		return nil, err
+	}
+	app := &LiveApplication{Name: name}
+	if data, err := os.ReadFile(filepath.Join(d.Dir, name+".json")); err == nil {
+		if app, err = parseLiveApplication(data); err != nil {
+			return nil, fmt.Errorf("%s.json: %w", name, err)
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+	app.Name = name
+	app.Manifests = []string{string(manifests)}
+	return app, nil
+}
@@ -0,0 +1,89 @@
+package argocd
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const liveCoderJSON = `{
+  "metadata": {"name": "coder"},
+  "spec": {"source": {"repoURL": "https://github.com/erauner/homelab-k8s.git", "path": "apps/coder/overlays/production", "targetRevision": "HEAD"}},
+  "status": {"sync": {"status": "OutOfSync", "revision": "abc1234"}, "health": {"status": "Healthy"}}
+}`
+
+func TestClient_Live(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/api/v1/applications/coder":
+			w.Write([]byte(liveCoderJSON))
+		case "/api/v1/applications/coder/manifests":
+			w.Write([]byte(`{"manifests": ["{\"apiVersion\":\"v1\",\"kind\":\"Service\",\"metadata\":{\"name\":\"coder\"}}"], "revision": "abc1234"}`))
+		default:
+			w.WriteHeader(http.StatusForbidden)
+		}
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "secret", false)
+	app, err := client.Live(context.Background(), "coder")
+	if err != nil {
+		t.Fatalf("Live() error = %v", err)
+	}
+	if app.Name != "coder" || app.SyncStatus != "OutOfSync" || app.Health != "Healthy" {
+		t.Errorf("unexpected app %+v", app)
+	}
+	if len(app.Revisions) != 1 || app.Revisions[0] != "abc1234" {
+		t.Errorf("Revisions = %v", app.Revisions)
+	}
+	if len(app.Sources) != 1 || app.Sources[0].Path != "apps/coder/overlays/production" {
+		t.Errorf("Sources = %+v", app.Sources)
+	}
+	if len(app.Manifests) != 1 {
+		t.Errorf("Manifests = %v", app.Manifests)
+	}
+
+	if _, err := client.Live(context.Background(), "missing"); !errors.Is(err, ErrNotDeployed) {
+		t.Errorf("missing app: got %v, want ErrNotDeployed", err)
+	}
+	if _, err := NewClient(server.URL, "", false).Live(context.Background(), "coder"); err == nil || errors.Is(err, ErrNotDeployed) {
+		t.Errorf("unauthenticated: got %v, want an API error", err)
+	}
+}
+
+func TestDirSource_Live(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "coder.yaml"), []byte("kind: Service\nmetadata:\n  name: coder\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "coder.json"), []byte(liveCoderJSON), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "web.yaml"), []byte("kind: Service\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	app, err := DirSource{Dir: dir}.Live(context.Background(), "coder")
+	if err != nil {
+		t.Fatalf("Live() error = %v", err)
+	}
+	if app.SyncStatus != "OutOfSync" || len(app.Manifests) != 1 || len(app.Sources) != 1 {
+		t.Errorf("unexpected app %+v", app)
+	}
+
+	app, err = DirSource{Dir: dir}.Live(context.Background(), "web")
+	if err != nil || app.Name != "web" || len(app.Sources) != 0 {
+		t.Errorf("manifests only: %+v, %v", app, err)
+	}
+	if _, err := (DirSource{Dir: dir}).Live(context.Background(), "missing"); !errors.Is(err, ErrNotDeployed) {
+		t.Errorf("missing app: got %v, want ErrNotDeployed", err)
+	}
+}
@@ -1,7 +1,10 @@
 package argocd
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
@@ -13,14 +16,19 @@ import (
 type applicationYAML struct {
 	Kind     string `yaml:"kind"`
 	Metadata struct {
-		Name string `yaml:"name"`
+		Name        string            `yaml:"name"`
+		Annotations map[string]string `yaml:"annotations"`
 	} `yaml:"metadata"`
 	Spec struct {
+		Project     string `yaml:"project"`
 		Destination struct {
 			Namespace string `yaml:"namespace"`
+			Server    string `yaml:"server"`
+			Name      string `yaml:"name"`
 		} `yaml:"destination"`
-		Source  *Source  `yaml:"source,omitempty"`
-		Sources []Source `yaml:"sources,omitempty"`
+		Source     *Source     `yaml:"source,omitempty"`
+		Sources    []Source    `yaml:"sources,omitempty"`
+		SyncPolicy *SyncPolicy `yaml:"syncPolicy,omitempty"`
 	} `yaml:"spec"`
 }
 
@@ -46,14 +54,124 @@ func ParseApplicationYAML(data []byte) (*Application, error) {
 		return nil, fmt.Errorf("not an Application resource (kind=%s)", appYAML.Kind)
 	}
 
-	app := &Application{
-		Name:      appYAML.Metadata.Name,
-		Namespace: appYAML.Spec.Destination.Namespace,
-		Sources:   appYAML.Spec.Sources,
-		Source:    appYAML.Spec.Source,
+	return newApplication(&appYAML), nil
+}
+
+// newApplication builds an Application from its raw YAML structure
+func newApplication(appYAML *applicationYAML) *Application {
+	cluster := appYAML.Spec.Destination.Name
+	if cluster == "" {
+		cluster = appYAML.Spec.Destination.Server
+	}
+	return &Application{
+		Name:       appYAML.Metadata.Name,
+		Namespace:  appYAML.Spec.Destination.Namespace,
+		Sources:    appYAML.Spec.Sources,
+		Source:     appYAML.Spec.Source,
+		Cluster:    cluster,
+		Server:     appYAML.Spec.Destination.Server,
+		SyncPolicy: appYAML.Spec.SyncPolicy,
+		PostRender: strings.Trim(appYAML.Metadata.Annotations[AnnotationPostRender], "/"),
+		SyncWave:   appYAML.Metadata.Annotations[AnnotationSyncWave],
+		Project:    appYAML.Spec.Project,
+	}
+}
+
+// ParseApplications parses every Application in a multi-document file and
+// expands any ApplicationSets into the Applications they generate
+// Documents of other kinds are ignored
+func ParseApplications(data []byte, opts ExpandOptions) ([]*Application, error) {
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+
+	var apps []*Application
+	for {
+		var node yaml.Node
+		if err := decoder.Decode(&node); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, fmt.Errorf("failed to parse YAML: %w", err)
+		}
+
+		var meta struct {
+			Kind string `yaml:"kind"`
+		}
+		if err := node.Decode(&meta); err != nil {
+			continue
+		}
+
+		switch meta.Kind {
+		case "Application":
+			var appYAML applicationYAML
+			if err := node.Decode(&appYAML); err != nil {
+				return nil, fmt.Errorf("failed to parse Application: %w", err)
+			}
+			apps = append(apps, newApplication(&appYAML))
+		case "ApplicationSet":
+			doc, err := yaml.Marshal(&node)
+			if err != nil {
+				return nil, err
+			}
+			set, err := ParseApplicationSetYAML(doc)
+			if err != nil {
+				return nil, err
+			}
+			generated, err := set.Expand(opts)
+			if err != nil {
+				return nil, err
+			}
+			apps = append(apps, generated...)
+		}
+	}
+
+	return apps, nil
+}
+
+// LoadApplications parses every Application under argocd-apps/, including
+// those generated by ApplicationSets, and returns them with their source files
+// Files that fail to parse or expand are skipped. With IndexDir set, files
+// unchanged since the last run are served from the index instead of re-parsed
+func LoadApplications(rootPath string) ([]*Application, []string, error) {
+	appFiles, err := DiscoverApplications(rootPath)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	return app, nil
+	index := loadIndex(rootPath)
+	defer index.save()
+
+	var opts *ExpandOptions // the cluster list is only read when a file must be parsed
+
+	var apps []*Application
+	var files []string
+	for _, path := range appFiles {
+		rel, err := filepath.Rel(rootPath, path)
+		if err != nil {
+			rel = path
+		}
+		parsed, data, ok := index.lookup(filepath.ToSlash(rel), path)
+		if !ok {
+			if data == nil {
+				if data, err = os.ReadFile(path); err != nil {
+					continue
+				}
+			}
+			if opts == nil {
+				opts = &ExpandOptions{RepoPath: rootPath, Clusters: LocalClusters(rootPath)}
+			}
+			if parsed, err = ParseApplications(data, *opts); err != nil {
+				continue
+			}
+			index.store(filepath.ToSlash(rel), path, data, parsed)
+		}
+		for _, app := range parsed {
+			app.Manifest = filepath.ToSlash(rel)
+			apps = append(apps, app)
+			files = append(files, path)
+		}
+	}
+
+	return apps, files, nil
 }
 
 // DiscoverApplications finds all ArgoCD Application files in a directory tree
@@ -96,20 +214,15 @@ func DiscoverApplications(rootPath string) ([]string, error) {
 }
 
 // DiscoverHelmApplications finds all Applications that use Helm charts
+// Applications generated by ApplicationSets are included
 func DiscoverHelmApplications(rootPath string) ([]*Application, error) {
-	appFiles, err := DiscoverApplications(rootPath)
+	apps, _, err := LoadApplications(rootPath)
 	if err != nil {
 		return nil, err
 	}
 
 	var helmApps []*Application
-	for _, path := range appFiles {
-		app, err := ParseApplicationFile(path)
-		if err != nil {
-			// Skip files that aren't valid Applications
-			continue
-		}
-
+	for _, app := range apps {
 		// Check if this app has Helm sources
 		if len(app.GetHelmSources()) > 0 {
 			helmApps = append(helmApps, app)
@@ -119,6 +232,23 @@ func DiscoverHelmApplications(rootPath string) ([]*Application, error) {
 	return helmApps, nil
 }
 
+// FindApplication locates an Application by metadata.name
+// Returns the parsed Application and the file it was found in
+func FindApplication(rootPath, name string) (*Application, string, error) {
+	apps, files, err := LoadApplications(rootPath)
+	if err != nil {
+		return nil, "", err
+	}
+
+	for i, app := range apps {
+		if app.Name == name {
+			return app, files[i], nil
+		}
+	}
+
+	return nil, "", fmt.Errorf("application not found: %s", name)
+}
+
 // ResolveValueFiles resolves $values/ references in valueFiles to local paths
 // Example: $values/apps/krr/base/values.yaml -> apps/krr/base/values.yaml
 func ResolveValueFiles(valueFiles []string, repoPath string) ([]string, error) {
@@ -145,6 +275,16 @@ func ResolveValueFiles(valueFiles []string, repoPath string) ([]string, error) {
 	return resolved, nil
 }
 
+// SourcePathExists reports whether a source's path is a directory in the repo
+// Sources without a path (charts, refs) always report false
+func SourcePathExists(repoPath string, source Source) bool {
+	if source.Path == "" {
+		return false
+	}
+	info, err := os.Stat(filepath.Join(repoPath, filepath.FromSlash(source.Path)))
+	return err == nil && info.IsDir()
+}
+
 // GetKustomizePathsFromApp extracts kustomize paths from an Application
 // Returns paths relative to repo root
 func GetKustomizePathsFromApp(app *Application) []string {
@@ -288,3 +288,208 @@ func TestSourceHelpers(t *testing.T) {
 		})
 	}
 }
+
+func TestFindApplication(t *testing.T) {
+	tmpDir := t.TempDir()
+	appsDir := filepath.Join(tmpDir, "argocd-apps", "applications")
+	if err := os.MkdirAll(appsDir, 0755); err != nil {
+		t.Fatalf("failed to create apps dir: %v", err)
+	}
+
+	app := `apiVersion: argoproj.io/v1alpha1
+kind: Application
+metadata:
+  name: coder
+spec:
+  destination:
+    namespace: coder
+  source:
+    path: apps/coder/overlays/erauner-home/production
+`
+	if err := os.WriteFile(filepath.Join(appsDir, "coder.yaml"), []byte(app), 0644); err != nil {
+		t.Fatalf("failed to write app: %v", err)
+	}
+
+	found, path, err := FindApplication(tmpDir, "coder")
+	if err != nil {
+		t.Fatalf("FindApplication failed: %v", err)
+	}
+	if found.Namespace != "coder" {
+		t.Errorf("expected namespace 'coder', got %q", found.Namespace)
+	}
+	if filepath.Base(path) != "coder.yaml" {
+		t.Errorf("expected path to coder.yaml, got %q", path)
+	}
+
+	if _, _, err := FindApplication(tmpDir, "missing"); err == nil {
+		t.Error("expected error for missing application")
+	}
+}
+
+func TestParseApplicationYAML_DestinationAndSyncPolicy(t *testing.T) {
+	data := `apiVersion: argoproj.io/v1alpha1
+kind: Application
+metadata:
+  name: coder
+spec:
+  destination:
+    server: https://kubernetes.default.svc
+    namespace: coder
+  source:
+    path: apps/coder/overlays/erauner-home/production
+  syncPolicy:
+    automated:
+      prune: true
+      selfHeal: true
+    syncOptions:
+      - CreateNamespace=true
+`
+	app, err := ParseApplicationYAML([]byte(data))
+	if err != nil {
+		t.Fatalf("ParseApplicationYAML failed: %v", err)
+	}
+	if app.Cluster != "https://kubernetes.default.svc" {
+		t.Errorf("expected server as cluster, got %q", app.Cluster)
+	}
+	if got := app.SyncPolicy.Summary(); got != "auto (prune, self-heal)" {
+		t.Errorf("Summary() = %q", got)
+	}
+	if len(app.SyncPolicy.SyncOptions) != 1 {
+		t.Errorf("expected 1 sync option, got %v", app.SyncPolicy.SyncOptions)
+	}
+
+	named, err := ParseApplicationYAML([]byte(`kind: Application
+metadata:
+  name: x
+spec:
+  destination:
+    name: erauner-cloud
+    server: https://10.0.0.1
+`))
+	if err != nil {
+		t.Fatalf("ParseApplicationYAML failed: %v", err)
+	}
+	if named.Cluster != "erauner-cloud" {
+		t.Errorf("expected destination name to win, got %q", named.Cluster)
+	}
+	if got := named.SyncPolicy.Summary(); got != "manual" {
+		t.Errorf("Summary() = %q, want manual", got)
+	}
+}
+
+func TestSourcePathExists(t *testing.T) {
+	tmpDir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(tmpDir, "apps", "coder"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if !SourcePathExists(tmpDir, Source{Path: "apps/coder"}) {
+		t.Error("expected apps/coder to exist")
+	}
+	if SourcePathExists(tmpDir, Source{Path: "apps/missing"}) {
+		t.Error("expected apps/missing not to exist")
+	}
+	if SourcePathExists(tmpDir, Source{Chart: "app-template"}) {
+		t.Error("expected chart source to report false")
+	}
+}
+
+func TestParseApplicationYAML_SourceOptions(t *testing.T) {
+	app, err := ParseApplicationYAML([]byte(`apiVersion: argoproj.io/v1alpha1
+kind: Application
+metadata:
+  name: demo
+spec:
+  source:
+    repoURL: https://bjw-s-labs.github.io/helm-charts
+    chart: app-template
+    targetRevision: 4.5.0
+    helm:
+      skipCrds: true
+      values: |
+        replicas: 1
+      valuesObject:
+        replicas: 3
+      parameters:
+        - name: image.tag
+          value: "1.27"
+          forceString: true
+  destination:
+    name: erauner-home
+`))
+	if err != nil {
+		t.Fatalf("ParseApplicationYAML failed: %v", err)
+	}
+	helm := app.Source.Helm
+	if helm == nil || !helm.SkipCrds || len(helm.Parameters) != 1 || !helm.Parameters[0].ForceString {
+		t.Fatalf("unexpected helm config: %+v", helm)
+	}
+	values, err := helm.InlineValues()
+	if err != nil {
+		t.Fatalf("InlineValues failed: %v", err)
+	}
+	if values != "replicas: 3\n" {
+		t.Errorf("expected valuesObject to win over values, got %q", values)
+	}
+
+	app, err = ParseApplicationYAML([]byte(`apiVersion: argoproj.io/v1alpha1
+kind: Application
+metadata:
+  name: demo
+spec:
+  source:
+    repoURL: https://github.com/erauner/homelab-k8s
+    path: apps/demo/overlays/production
+    kustomize:
+      namePrefix: prod-
+      images:
+        - nginx=ghcr.io/org/nginx:1.27
+      replicas:
+        - name: web
+          count: 3
+      patches:
+        - target:
+            kind: Deployment
+          patch: |-
+            - op: replace
+              path: /spec/replicas
+              value: 2
+`))
+	if err != nil {
+		t.Fatalf("ParseApplicationYAML failed: %v", err)
+	}
+	k := app.Source.Kustomize
+	if k.IsEmpty() {
+		t.Fatal("expected kustomize options to be parsed")
+	}
+	if k.NamePrefix != "prod-" || len(k.Images) != 1 || k.Replicas[0].Count != "3" {
+		t.Errorf("unexpected kustomize config: %+v", k)
+	}
+	if len(k.Patches) != 1 || k.Patches[0].Target == nil || k.Patches[0].Target.Kind != "Deployment" {
+		t.Errorf("unexpected kustomize patches: %+v", k.Patches)
+	}
+	if !(*KustomizeConfig)(nil).IsEmpty() {
+		t.Error("expected a nil config to be empty")
+	}
+}
+
+func TestParseApplicationYAML_PostRender(t *testing.T) {
+	app, err := ParseApplicationYAML([]byte(`apiVersion: argoproj.io/v1alpha1
+kind: Application
+metadata:
+  name: demo
+  annotations:
+    shadow.erauner.dev/post-render: /apps/demo/post-render/
+spec:
+  source:
+    repoURL: https://bjw-s-labs.github.io/helm-charts
+    chart: app-template
+    targetRevision: 4.5.0
+`))
+	if err != nil {
+		t.Fatalf("ParseApplicationYAML failed: %v", err)
+	}
+	if app.PostRender != "apps/demo/post-render" {
+		t.Errorf("PostRender = %q, want apps/demo/post-render", app.PostRender)
+	}
+}
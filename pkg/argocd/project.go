@@ -0,0 +1,196 @@
+package argocd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultProject is the project ArgoCD creates, which permits everything
+// unless the repo redefines it
+const DefaultProject = "default"
+
+// ProjectDirs are the directories searched for AppProjects
+var ProjectDirs = []string{"argocd-apps", "clusters"}
+
+// AppProject is the subset of an ArgoCD AppProject that restricts which
+// repositories and destinations its Applications may use
+type AppProject struct {
+	Name         string
+	SourceRepos  []string             // spec.sourceRepos globs; "!" negates
+	Destinations []ProjectDestination // spec.destinations
+}
+
+// ProjectDestination is a spec.destinations entry; fields are globs and
+// namespace and server (or name) must both match
+type ProjectDestination struct {
+	Server    string `yaml:"server"`
+	Name      string `yaml:"name"`
+	Namespace string `yaml:"namespace"`
+}
+
+// appProjectYAML is the raw YAML structure of an AppProject
+type appProjectYAML struct {
+	Kind     string `yaml:"kind"`
+	Metadata struct {
+		Name string `yaml:"name"`
+	} `yaml:"metadata"`
+	Spec struct {
+		SourceRepos  []string             `yaml:"sourceRepos"`
+		Destinations []ProjectDestination `yaml:"destinations"`
+	} `yaml:"spec"`
+}
+
+// ParseProjects parses every AppProject in a multi-document file; documents
+// of other kinds are ignored
+func ParseProjects(data []byte) ([]*AppProject, error) {
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+
+	var projects []*AppProject
+	for {
+		var doc appProjectYAML
+		if err := decoder.Decode(&doc); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, fmt.Errorf("failed to parse YAML: %w", err)
+		}
+		if doc.Kind != "AppProject" {
+			continue
+		}
+		projects = append(projects, &AppProject{
+			Name:         doc.Metadata.Name,
+			SourceRepos:  doc.Spec.SourceRepos,
+			Destinations: doc.Spec.Destinations,
+		})
+	}
+	return projects, nil
+}
+
+// LoadProjects parses every AppProject under ProjectDirs and returns them
+// with their source files. Files that fail to parse are skipped
+func LoadProjects(rootPath string) ([]*AppProject, []string, error) {
+	var projects []*AppProject
+	var files []string
+	for _, dir := range ProjectDirs {
+		root := filepath.Join(rootPath, dir)
+		if _, err := os.Stat(root); os.IsNotExist(err) {
+			continue
+		}
+		err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.IsDir() || (!strings.HasSuffix(path, ".yaml") && !strings.HasSuffix(path, ".yml")) {
+				return nil
+			}
+			data, err := os.ReadFile(path)
+			if err != nil || !bytes.Contains(data, []byte("AppProject")) {
+				return nil
+			}
+			parsed, err := ParseProjects(data)
+			if err != nil {
+				return nil
+			}
+			for _, p := range parsed {
+				projects = append(projects, p)
+				files = append(files, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to walk %s: %w", root, err)
+		}
+	}
+	return projects, files, nil
+}
+
+// PermitsSource reports whether repoURL matches a sourceRepos glob and no
+// negated one. URLs are compared with and without a trailing .git
+func (p *AppProject) PermitsSource(repoURL string) bool {
+	candidates := []string{repoURL, strings.TrimSuffix(repoURL, ".git")}
+	return globsPermit(p.SourceRepos, func(pattern string) bool {
+		for _, c := range candidates {
+			if globMatch(pattern, c) || globMatch(strings.TrimSuffix(pattern, ".git"), c) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// PermitsDestination reports whether a destination (server and name as the
+// Application or the cluster registry gives them, either may be "") is
+// permitted: its namespace and its server or name match a destinations entry
+// and no negated one
+func (p *AppProject) PermitsDestination(server, name, namespace string) bool {
+	matches := func(d ProjectDestination) (bool, bool) {
+		serverPattern, serverNegated := strings.CutPrefix(d.Server, "!")
+		namePattern, nameNegated := strings.CutPrefix(d.Name, "!")
+		nsPattern, nsNegated := strings.CutPrefix(d.Namespace, "!")
+		cluster := serverPattern == "*" || namePattern == "*" ||
+			(server != "" && serverPattern != "" && globMatch(serverPattern, server)) ||
+			(name != "" && namePattern != "" && globMatch(namePattern, name))
+		return cluster && globMatch(nsPattern, namespace), serverNegated || nameNegated || nsNegated
+	}
+
+	allowed := false
+	for _, d := range p.Destinations {
+		match, negated := matches(d)
+		if negated {
+			// A negated entry denies what its patterns match, ignoring the "!"
+			if match {
+				return false
+			}
+			continue
+		}
+		allowed = allowed || match
+	}
+	return allowed
+}
+
+// globsPermit applies ArgoCD's allow/deny glob lists: at least one plain
+// pattern must match and no "!" pattern may
+func globsPermit(patterns []string, match func(pattern string) bool) bool {
+	allowed := false
+	for _, pattern := range patterns {
+		if negated, ok := strings.CutPrefix(pattern, "!"); ok {
+			if match(negated) {
+				return false
+			}
+			continue
+		}
+		allowed = allowed || match(pattern)
+	}
+	return allowed
+}
+
+// globMatch matches s against an ArgoCD glob, where * and ? match any
+// characters including "/"
+func globMatch(pattern, s string) bool {
+	if pattern == "*" {
+		return true
+	}
+	var re strings.Builder
+	re.WriteString("^")
+	for _, r := range pattern {
+		switch r {
+		case '*':
+			re.WriteString(".*")
+		case '?':
+			re.WriteString(".")
+		default:
+			re.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	re.WriteString("$")
+	matched, err := regexp.MatchString(re.String(), s)
+	return err == nil && matched
+}
@@ -0,0 +1,96 @@
+package argocd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const teamProjectYAML = `apiVersion: argoproj.io/v1alpha1
+kind: AppProject
+metadata:
+  name: team
+spec:
+  sourceRepos:
+    - https://github.com/erauner/*
+    - '!https://github.com/erauner/secrets'
+  destinations:
+    - server: https://kubernetes.default.svc
+      namespace: team-*
+    - name: erauner-cloud
+      namespace: '*'
+    - server: https://edge.example.com
+      namespace: '*'
+    - server: '*'
+      namespace: '!kube-system'
+`
+
+func TestParseProjects(t *testing.T) {
+	projects, err := ParseProjects([]byte(teamProjectYAML + "---\nkind: Application\nmetadata:\n  name: app\n"))
+	if err != nil {
+		t.Fatalf("ParseProjects() error = %v", err)
+	}
+	if len(projects) != 1 || projects[0].Name != "team" || len(projects[0].SourceRepos) != 2 || len(projects[0].Destinations) != 4 {
+		t.Fatalf("unexpected projects %+v", projects)
+	}
+}
+
+func TestAppProject_Permits(t *testing.T) {
+	projects, err := ParseProjects([]byte(teamProjectYAML))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := projects[0]
+
+	for repo, want := range map[string]bool{
+		"https://github.com/erauner/homelab-k8s.git": true,
+		"https://github.com/erauner/homelab-k8s":     true,
+		"https://github.com/erauner/secrets.git":     false,
+		"https://github.com/other/homelab-k8s.git":   false,
+		"https://charts.example.com":                 false,
+	} {
+		if got := p.PermitsSource(repo); got != want {
+			t.Errorf("PermitsSource(%s) = %v, want %v", repo, got, want)
+		}
+	}
+
+	tests := []struct {
+		server, name, namespace string
+		want                    bool
+	}{
+		{"https://kubernetes.default.svc", "", "team-a", true},
+		{"", "erauner-cloud", "anything", true},
+		{"https://edge.example.com", "", "apps", true},
+		{"https://edge.example.com", "", "kube-system", false}, // denied on every server
+		{"https://other.example.com", "", "apps", false},
+	}
+	for _, tt := range tests {
+		if got := p.PermitsDestination(tt.server, tt.name, tt.namespace); got != tt.want {
+			t.Errorf("PermitsDestination(%q, %q, %q) = %v, want %v", tt.server, tt.name, tt.namespace, got, tt.want)
+		}
+	}
+
+	strict := &AppProject{Name: "strict", SourceRepos: []string{"https://github.com/erauner/homelab-k8s.git"},
+		Destinations: []ProjectDestination{{Server: "https://kubernetes.default.svc", Namespace: "strict"}}}
+	if strict.PermitsDestination("https://kubernetes.default.svc", "", "other") || strict.PermitsDestination("", "erauner-home", "strict") {
+		t.Error("strict project should only permit its own namespace on its server")
+	}
+}
+
+func TestLoadProjects(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "clusters", "erauner-home", "argocd", "projects")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "team.yaml"), []byte(teamProjectYAML), 0644); err != nil {
+		t.Fatal(err)
+	}
+	projects, files, err := LoadProjects(root)
+	if err != nil {
+		t.Fatalf("LoadProjects() error = %v", err)
+	}
+	if len(projects) != 1 || files[0] != filepath.Join(dir, "team.yaml") {
+		t.Errorf("LoadProjects() = %+v, %v", projects, files)
+	}
+}
@@ -0,0 +1,139 @@
+package argocd
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// LabelSecretType marks a Secret as ArgoCD repository configuration
+const LabelSecretType = "argocd.argoproj.io/secret-type"
+
+// Repository secret types: a repository matches its URL exactly, repo-creds
+// is a credential template for every repository under its URL prefix
+const (
+	SecretTypeRepository = "repository"
+	SecretTypeRepoCreds  = "repo-creds"
+)
+
+// RepoSecret is the connection info of an ArgoCD repository or repo-creds Secret
+type RepoSecret struct {
+	Name      string
+	Namespace string
+	Path      string // repo-relative file it was read from
+	Kind      string // SecretTypeRepository or SecretTypeRepoCreds
+
+	URL       string
+	Type      string // git, helm, or oci
+	EnableOCI bool
+	Username  string
+	Password  string
+}
+
+// String identifies the Secret for logs, e.g. "Secret argocd/private-charts (clusters/home/argocd/repos.yaml)"
+func (s RepoSecret) String() string {
+	return fmt.Sprintf("Secret %s/%s (%s)", s.Namespace, s.Name, s.Path)
+}
+
+// LoadRepoSecrets reads every ArgoCD repository and repo-creds Secret committed
+// in plain text under rootPath (data or stringData). Encrypted Secrets
+// (SealedSecrets, SOPS, ExternalSecrets) are other kinds or unreadable values
+// and are not returned; neither are Secrets without a url. Files that fail to
+// parse are skipped, as LoadApplications does
+func LoadRepoSecrets(rootPath string) ([]RepoSecret, error) {
+	var secrets []RepoSecret
+	err := filepath.WalkDir(rootPath, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != rootPath && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if ext := filepath.Ext(path); ext != ".yaml" && ext != ".yml" {
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil || !bytes.Contains(data, []byte(LabelSecretType)) {
+			return nil
+		}
+		rel, err := filepath.Rel(rootPath, path)
+		if err != nil {
+			rel = path
+		}
+		found, err := parseRepoSecrets(data, filepath.ToSlash(rel))
+		if err != nil {
+			return nil
+		}
+		secrets = append(secrets, found...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return secrets, nil
+}
+
+// parseRepoSecrets returns the repository Secrets in a multi-document file
+func parseRepoSecrets(data []byte, path string) ([]RepoSecret, error) {
+	var secrets []RepoSecret
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	for {
+		var doc struct {
+			Kind     string `yaml:"kind"`
+			Metadata struct {
+				Name      string            `yaml:"name"`
+				Namespace string            `yaml:"namespace"`
+				Labels    map[string]string `yaml:"labels"`
+			} `yaml:"metadata"`
+			Data       map[string]string `yaml:"data"`
+			StringData map[string]string `yaml:"stringData"`
+		}
+		if err := decoder.Decode(&doc); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, fmt.Errorf("failed to parse YAML: %w", err)
+		}
+		kind := doc.Metadata.Labels[LabelSecretType]
+		if doc.Kind != "Secret" || (kind != SecretTypeRepository && kind != SecretTypeRepoCreds) {
+			continue
+		}
+
+		// stringData wins over data, as it does when the API server merges them
+		values := make(map[string]string)
+		for key, value := range doc.Data {
+			if decoded, err := base64.StdEncoding.DecodeString(value); err == nil {
+				values[key] = string(decoded)
+			}
+		}
+		for key, value := range doc.StringData {
+			values[key] = value
+		}
+		if values["url"] == "" {
+			continue
+		}
+		secrets = append(secrets, RepoSecret{
+			Name:      doc.Metadata.Name,
+			Namespace: doc.Metadata.Namespace,
+			Path:      path,
+			Kind:      kind,
+			URL:       values["url"],
+			Type:      values["type"],
+			EnableOCI: values["enableOCI"] == "true",
+			Username:  values["username"],
+			Password:  values["password"],
+		})
+	}
+	return secrets, nil
+}
@@ -0,0 +1,75 @@
+package argocd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadRepoSecrets(t *testing.T) {
+	repo := t.TempDir()
+	files := map[string]string{
+		"clusters/home/argocd/repos.yaml": `apiVersion: v1
+kind: Secret
+metadata:
+  name: private-charts
+  namespace: argocd
+  labels:
+    argocd.argoproj.io/secret-type: repository
+stringData:
+  url: https://charts.example.com
+  type: helm
+  username: ci
+  password: s3cret
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: ghcr
+  namespace: argocd
+  labels:
+    argocd.argoproj.io/secret-type: repo-creds
+data:
+  url: Z2hjci5pby9lcmF1bmVy
+  enableOCI: dHJ1ZQ==
+  username: Ym90
+  password: dG9rZW4=
+---
+apiVersion: bitnami.com/v1alpha1
+kind: SealedSecret
+metadata:
+  name: sealed
+  labels:
+    argocd.argoproj.io/secret-type: repository
+`,
+		".git/repos.yaml":  "kind: Secret\nmetadata:\n  labels:\n    argocd.argoproj.io/secret-type: repository\nstringData:\n  url: https://hidden.example.com\n",
+		"broken/repos.yaml": "argocd.argoproj.io/secret-type: [",
+	}
+	for name, content := range files {
+		path := filepath.Join(repo, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	secrets, err := LoadRepoSecrets(repo)
+	if err != nil {
+		t.Fatalf("LoadRepoSecrets() error = %v", err)
+	}
+	if len(secrets) != 2 {
+		t.Fatalf("expected 2 repository secrets, got %+v", secrets)
+	}
+	charts, ghcr := secrets[0], secrets[1]
+	if charts.Kind != SecretTypeRepository || charts.Type != "helm" || charts.Username != "ci" || charts.Password != "s3cret" {
+		t.Errorf("unexpected stringData secret %+v", charts)
+	}
+	if ghcr.Kind != SecretTypeRepoCreds || !ghcr.EnableOCI || ghcr.URL != "ghcr.io/erauner" || ghcr.Password != "token" {
+		t.Errorf("unexpected data secret %+v", ghcr)
+	}
+	if got := charts.String(); got != "Secret argocd/private-charts (clusters/home/argocd/repos.yaml)" {
+		t.Errorf("String() = %q", got)
+	}
+}
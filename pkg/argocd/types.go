@@ -1,12 +1,66 @@
 // Package argocd provides ArgoCD Application parsing for shadow sync
 package argocd
 
+import (
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
 // Application represents an ArgoCD Application with its source configuration
 type Application struct {
 	Name      string    `yaml:"-"` // Extracted from metadata.name
 	Namespace string    // Destination namespace
 	Sources   []Source  // Multi-source configuration
 	Source    *Source   // Single-source configuration (legacy)
+
+	// Cluster is the destination cluster (spec.destination.name, or server when unnamed)
+	Cluster string `yaml:"-"`
+
+	// Server is spec.destination.server, when set
+	Server string `yaml:"-"`
+
+	// Project is spec.project ("" when unset)
+	Project string `yaml:"-"`
+
+	// SyncPolicy is spec.syncPolicy; nil means manual sync
+	SyncPolicy *SyncPolicy `yaml:"-"`
+
+	// ApplicationSet is the name of the generating ApplicationSet, if any
+	ApplicationSet string `yaml:"-"`
+
+	// Kind is the resource the Application was converted from ("" for ArgoCD
+	// Applications; Kustomization or HelmRelease for Flux)
+	Kind string `yaml:"-"`
+
+	// PostRender is the repo-relative kustomize Component its Helm output is
+	// piped through (AnnotationPostRender), or ""
+	PostRender string `yaml:"-"`
+
+	// SyncWave is the Application's AnnotationSyncWave as written, ordering it
+	// among the Applications of its app-of-apps ("" is wave 0)
+	SyncWave string `yaml:"-"`
+
+	// Manifest is the repo-relative path of the file the Application was
+	// loaded from ("" when parsed from bytes)
+	Manifest string `yaml:"-" json:"-"`
+}
+
+// AnnotationPostRender names a kustomize Component, relative to the repo root,
+// that patches an Application's rendered Helm output before it is published
+const AnnotationPostRender = "shadow.erauner.dev/post-render"
+
+// AnnotationSyncWave orders resources (and the Applications of an app-of-apps)
+// within a sync: lower waves are applied and healthy before higher ones
+const AnnotationSyncWave = "argocd.argoproj.io/sync-wave"
+
+// KindName returns "Application <name>", or the Flux kind and name
+func (a *Application) KindName() string {
+	if a.Kind == "" {
+		return "Application " + a.Name
+	}
+	return a.Kind + " " + a.Name
 }
 
 // Source represents a single source in an ArgoCD Application
@@ -16,7 +70,8 @@ type Source struct {
 	TargetRevision string `yaml:"targetRevision"`
 
 	// For Kustomize sources
-	Path string `yaml:"path"`
+	Path      string           `yaml:"path"`
+	Kustomize *KustomizeConfig `yaml:"kustomize,omitempty"`
 
 	// For Helm sources
 	Chart string      `yaml:"chart"`
@@ -26,11 +81,124 @@ type Source struct {
 	Ref string `yaml:"ref"`
 }
 
+// SyncPolicy describes how ArgoCD syncs an Application
+type SyncPolicy struct {
+	Automated   *AutomatedSync `yaml:"automated,omitempty" json:"automated,omitempty"`
+	SyncOptions []string       `yaml:"syncOptions,omitempty" json:"syncOptions,omitempty"`
+}
+
+// AutomatedSync is the automated sync configuration
+type AutomatedSync struct {
+	Prune    bool `yaml:"prune" json:"prune"`
+	SelfHeal bool `yaml:"selfHeal" json:"selfHeal"`
+}
+
+// Summary returns a short description such as "auto (prune, self-heal)"
+func (p *SyncPolicy) Summary() string {
+	if p == nil || p.Automated == nil {
+		return "manual"
+	}
+	var flags []string
+	if p.Automated.Prune {
+		flags = append(flags, "prune")
+	}
+	if p.Automated.SelfHeal {
+		flags = append(flags, "self-heal")
+	}
+	if len(flags) == 0 {
+		return "auto"
+	}
+	return "auto (" + strings.Join(flags, ", ") + ")"
+}
+
 // HelmConfig contains Helm-specific configuration
 type HelmConfig struct {
 	ReleaseName string   `yaml:"releaseName"`
 	ValueFiles  []string `yaml:"valueFiles"`  // e.g., [$values/apps/krr/base/values.yaml]
 	Values      string   `yaml:"values"`      // Inline values YAML
+
+	// ValuesObject is inline values as a structured object; it takes
+	// precedence over Values
+	ValuesObject map[string]interface{} `yaml:"valuesObject,omitempty"`
+
+	// Parameters override values (helm template --set / --set-string)
+	Parameters []HelmParameter `yaml:"parameters,omitempty"`
+
+	// SkipCrds leaves the chart's crds/ directory out of the render
+	SkipCrds bool `yaml:"skipCrds,omitempty"`
+}
+
+// HelmParameter is a single Helm value override
+type HelmParameter struct {
+	Name        string `yaml:"name"`
+	Value       string `yaml:"value"`
+	ForceString bool   `yaml:"forceString,omitempty"`
+}
+
+// InlineValues returns the inline values YAML ArgoCD passes to helm:
+// valuesObject when set, otherwise values
+func (h *HelmConfig) InlineValues() (string, error) {
+	if h == nil {
+		return "", nil
+	}
+	if len(h.ValuesObject) > 0 {
+		data, err := yaml.Marshal(h.ValuesObject)
+		if err != nil {
+			return "", fmt.Errorf("invalid valuesObject: %w", err)
+		}
+		return string(data), nil
+	}
+	return h.Values, nil
+}
+
+// KustomizeConfig is spec.source.kustomize: overrides ArgoCD applies on top of
+// the kustomization at Path before building it
+type KustomizeConfig struct {
+	NamePrefix        string            `yaml:"namePrefix,omitempty"`
+	NameSuffix        string            `yaml:"nameSuffix,omitempty"`
+	Namespace         string            `yaml:"namespace,omitempty"`
+	CommonLabels      map[string]string `yaml:"commonLabels,omitempty"`
+	CommonAnnotations map[string]string `yaml:"commonAnnotations,omitempty"`
+
+	// Images use kustomize edit set image syntax: nginx:1.27,
+	// nginx=ghcr.io/org/nginx:1.27, or nginx@sha256:...
+	Images []string `yaml:"images,omitempty"`
+
+	Replicas []KustomizeReplica `yaml:"replicas,omitempty"`
+	Patches  []KustomizePatch   `yaml:"patches,omitempty"`
+}
+
+// KustomizeReplica overrides the replica count of a workload by name
+type KustomizeReplica struct {
+	Name  string `yaml:"name"`
+	Count string `yaml:"count"` // int or string in ArgoCD
+}
+
+// KustomizePatch is a strategic merge or JSON 6902 patch, inline or from a
+// file relative to the source path
+type KustomizePatch struct {
+	Path    string           `yaml:"path,omitempty"`
+	Patch   string           `yaml:"patch,omitempty"`
+	Target  *KustomizeTarget `yaml:"target,omitempty"`
+	Options map[string]bool  `yaml:"options,omitempty"`
+}
+
+// KustomizeTarget selects the resources a patch applies to
+type KustomizeTarget struct {
+	Group              string `yaml:"group,omitempty"`
+	Version            string `yaml:"version,omitempty"`
+	Kind               string `yaml:"kind,omitempty"`
+	Name               string `yaml:"name,omitempty"`
+	Namespace          string `yaml:"namespace,omitempty"`
+	LabelSelector      string `yaml:"labelSelector,omitempty"`
+	AnnotationSelector string `yaml:"annotationSelector,omitempty"`
+}
+
+// IsEmpty reports whether the config changes nothing
+func (k *KustomizeConfig) IsEmpty() bool {
+	return k == nil || (k.NamePrefix == "" && k.NameSuffix == "" && k.Namespace == "" &&
+		len(k.CommonLabels) == 0 && len(k.CommonAnnotations) == 0 && len(k.Images) == 0 &&
+		len(k.Replicas) == 0 && len(k.Patches) == 0)
 }
 
 // IsHelmSource returns true if this source is a Helm chart
@@ -53,6 +221,15 @@ func (a *Application) HasMultipleSources() bool {
 	return len(a.Sources) > 0
 }
 
+// AllSources returns every source of an Application, multi-source first
+func (a *Application) AllSources() []Source {
+	sources := append([]Source(nil), a.Sources...)
+	if a.Source != nil {
+		sources = append(sources, *a.Source)
+	}
+	return sources
+}
+
 // GetHelmSources returns all Helm chart sources
 func (a *Application) GetHelmSources() []Source {
 	var helmSources []Source
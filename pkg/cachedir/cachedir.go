@@ -0,0 +1,18 @@
+// Package cachedir locates the directories shadow caches downloads and
+// results in, which are shared between runs and commands
+package cachedir
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// Path returns the shadow cache directory joined with sub
+// Honors XDG_CACHE_HOME (via os.UserCacheDir), falling back to the temp dir
+func Path(sub ...string) string {
+	base, err := os.UserCacheDir()
+	if err != nil {
+		base = os.TempDir()
+	}
+	return filepath.Join(append([]string{base, "shadow"}, sub...)...)
+}
@@ -0,0 +1,28 @@
+package cachedir
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestPath(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("XDG_CACHE_HOME is only honored on Linux")
+	}
+	cache := t.TempDir()
+	t.Setenv("XDG_CACHE_HOME", cache)
+	if got, want := Path("tools"), filepath.Join(cache, "shadow", "tools"); got != want {
+		t.Errorf("Path(tools) = %s, want %s", got, want)
+	}
+	if got, want := Path(), filepath.Join(cache, "shadow"); got != want {
+		t.Errorf("Path() = %s, want %s", got, want)
+	}
+
+	t.Setenv("XDG_CACHE_HOME", "")
+	t.Setenv("HOME", "")
+	if got, want := Path("charts"), filepath.Join(os.TempDir(), "shadow", "charts"); got != want {
+		t.Errorf("Path(charts) without a cache dir = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,315 @@
+// Package cluster provides the cluster registry shared by validation, sync
+// discovery, and path parsing
+package cluster
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// RegistryFile is the optional explicit cluster list at the repository root
+// When absent, clusters are the directories under clusters/
+const RegistryFile = "clusters.yaml"
+
+// Sources reported by Registry.Source
+const (
+	SourceFile      = RegistryFile
+	SourceDirectory = "clusters/"
+	SourceNone      = "none"
+)
+
+// Cluster is a single registered cluster
+type Cluster struct {
+	Name   string            `yaml:"name"`
+	Labels map[string]string `yaml:"labels,omitempty"`
+
+	// Server is the cluster's API server URL as Applications address it in
+	// spec.destination.server, mapping server-only destinations to the cluster
+	Server string `yaml:"server,omitempty"`
+
+	// ArgoCDVersion is the ArgoCD release managing the cluster (e.g. "2.8"),
+	// used to flag Application features it doesn't support yet
+	ArgoCDVersion string `yaml:"argocdVersion,omitempty"`
+
+	// Domains are the DNS suffixes the cluster serves, e.g. home.erauner.dev;
+	// rendered hostnames are checked against them
+	Domains []string `yaml:"domains,omitempty"`
+
+	// Vars are substituted for ${NAME} placeholders in manifests rendered for
+	// the cluster (see Registry.Substitute)
+	Vars map[string]string `yaml:"vars,omitempty"`
+}
+
+// UnmarshalYAML accepts both `- erauner-home` and `- name: erauner-home`
+func (c *Cluster) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		c.Name = node.Value
+		return nil
+	}
+	type plain Cluster
+	return node.Decode((*plain)(c))
+}
+
+// registryYAML is the clusters.yaml file format
+type registryYAML struct {
+	Clusters []Cluster `yaml:"clusters"`
+}
+
+// Registry is the set of known cluster names
+// An empty registry means no clusters could be found; callers fall back to
+// inferring clusters from directory layout
+type Registry struct {
+	Source   string
+	clusters []Cluster
+	byName   map[string]Cluster
+	byServer map[string]Cluster
+}
+
+// NewRegistry builds a registry from an explicit cluster list
+func NewRegistry(source string, clusters []Cluster) *Registry {
+	r := &Registry{Source: source, byName: make(map[string]Cluster), byServer: make(map[string]Cluster)}
+	for _, c := range clusters {
+		if _, dup := r.byName[c.Name]; dup || c.Name == "" {
+			continue
+		}
+		r.byName[c.Name] = c
+		if server := normalizeServer(c.Server); server != "" {
+			if _, dup := r.byServer[server]; !dup {
+				r.byServer[server] = c
+			}
+		}
+		r.clusters = append(r.clusters, c)
+	}
+	sort.Slice(r.clusters, func(i, j int) bool { return r.clusters[i].Name < r.clusters[j].Name })
+	return r
+}
+
+// Load reads <repoPath>/clusters.yaml if present, otherwise lists clusters/
+// A repo with neither yields an empty registry, not an error
+func Load(repoPath string) (*Registry, error) {
+	path := filepath.Join(repoPath, RegistryFile)
+	if data, err := os.ReadFile(path); err == nil {
+		return Parse(data)
+	} else if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read %s: %w", RegistryFile, err)
+	}
+
+	entries, err := os.ReadDir(filepath.Join(repoPath, "clusters"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return NewRegistry(SourceNone, nil), nil
+		}
+		return nil, fmt.Errorf("failed to read clusters directory: %w", err)
+	}
+
+	var clusters []Cluster
+	for _, entry := range entries {
+		if entry.IsDir() && !strings.HasPrefix(entry.Name(), ".") {
+			clusters = append(clusters, Cluster{Name: entry.Name()})
+		}
+	}
+	return NewRegistry(SourceDirectory, clusters), nil
+}
+
+// Parse parses clusters.yaml data
+func Parse(data []byte) (*Registry, error) {
+	var file registryYAML
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", RegistryFile, err)
+	}
+	servers := make(map[string]string)
+	for i, c := range file.Clusters {
+		if c.Name == "" {
+			return nil, fmt.Errorf("%s: cluster %d has no name", RegistryFile, i)
+		}
+		if server := normalizeServer(c.Server); server != "" {
+			if other, dup := servers[server]; dup && other != c.Name {
+				return nil, fmt.Errorf("%s: clusters %s and %s have the same server %s", RegistryFile, other, c.Name, c.Server)
+			}
+			servers[server] = c.Name
+		}
+		for name := range c.Vars {
+			if !varName.MatchString(name) {
+				return nil, fmt.Errorf("%s: cluster %s: invalid variable name %q", RegistryFile, c.Name, name)
+			}
+			if name == VarClusterName {
+				return nil, fmt.Errorf("%s: cluster %s: %s is set automatically", RegistryFile, c.Name, VarClusterName)
+			}
+		}
+	}
+	return NewRegistry(SourceFile, file.Clusters), nil
+}
+
+// Empty reports whether no clusters are registered
+func (r *Registry) Empty() bool {
+	return r == nil || len(r.clusters) == 0
+}
+
+// Has reports whether name is a registered cluster
+func (r *Registry) Has(name string) bool {
+	if r == nil {
+		return false
+	}
+	_, ok := r.byName[name]
+	return ok
+}
+
+// Get returns the registered cluster with the given name
+func (r *Registry) Get(name string) (Cluster, bool) {
+	if r == nil {
+		return Cluster{}, false
+	}
+	c, ok := r.byName[name]
+	return c, ok
+}
+
+// ByServer returns the registered cluster whose API server is server
+func (r *Registry) ByServer(server string) (Cluster, bool) {
+	if r == nil || normalizeServer(server) == "" {
+		return Cluster{}, false
+	}
+	c, ok := r.byServer[normalizeServer(server)]
+	return c, ok
+}
+
+// normalizeServer drops the trailing slash ArgoCD ignores when matching
+// spec.destination.server
+func normalizeServer(server string) string {
+	return strings.TrimSuffix(strings.TrimSpace(server), "/")
+}
+
+// Names returns the registered cluster names, sorted
+func (r *Registry) Names() []string {
+	if r == nil {
+		return nil
+	}
+	names := make([]string, 0, len(r.clusters))
+	for _, c := range r.clusters {
+		names = append(names, c.Name)
+	}
+	return names
+}
+
+// Clusters returns the registered clusters, sorted by name
+func (r *Registry) Clusters() []Cluster {
+	if r == nil {
+		return nil
+	}
+	return append([]Cluster(nil), r.clusters...)
+}
+
+// AppPath is an app overlay path split into its parts
+type AppPath struct {
+	App         string
+	OverlayRoot string // "overlays", "stack", or "db/overlays"
+	Cluster     string // empty for legacy flat overlays
+	Environment string
+}
+
+// ParseAppPath splits apps/<app>/<overlay-root>/... into its parts
+//
+//	apps/<app>/overlays/<cluster>/<env>      cluster-aware (issue #1256)
+//	apps/<app>/overlays/<env>                legacy flat
+//
+// (likewise for stack/ and db/overlays/). A two-segment tail is only
+// cluster-aware when its first segment is a registered cluster; with an empty
+// registry any two-segment tail is assumed to be cluster-aware. Paths deeper
+// than that, or outside apps/, return ok=false.
+func (r *Registry) ParseAppPath(relDir string) (AppPath, bool) {
+	parts := strings.Split(filepath.ToSlash(filepath.Clean(relDir)), "/")
+	if len(parts) < 4 || parts[0] != "apps" {
+		return AppPath{}, false
+	}
+
+	p := AppPath{App: parts[1]}
+	var tail []string
+	switch {
+	case parts[2] == "overlays" || parts[2] == "stack":
+		p.OverlayRoot = parts[2]
+		tail = parts[3:]
+	case len(parts) >= 5 && parts[2] == "db" && parts[3] == "overlays":
+		p.OverlayRoot = "db/overlays"
+		tail = parts[4:]
+	default:
+		return AppPath{}, false
+	}
+
+	switch len(tail) {
+	case 1:
+		p.Environment = tail[0]
+		return p, true
+	case 2:
+		if !r.Empty() && !r.Has(tail[0]) {
+			return AppPath{}, false
+		}
+		p.Cluster = tail[0]
+		p.Environment = tail[1]
+		return p, true
+	default:
+		return AppPath{}, false
+	}
+}
+
+// maxSuggestDistance is the largest edit distance Suggest treats as a typo
+const maxSuggestDistance = 2
+
+// Suggest returns the registered cluster that name is most likely a typo of, or ""
+// Names that differ only in case or separators (eraunerhome, Erauner_Home) or
+// within a small edit distance match; registered names never match themselves
+func (r *Registry) Suggest(name string) string {
+	if r.Empty() || r.Has(name) {
+		return ""
+	}
+
+	best, bestDistance := "", maxSuggestDistance+1
+	for _, c := range r.clusters {
+		if squash(c.Name) == squash(name) {
+			return c.Name
+		}
+		// Short names are too easy to confuse with unrelated env names
+		if len(name) < 5 {
+			continue
+		}
+		if d := editDistance(c.Name, name); d < bestDistance {
+			best, bestDistance = c.Name, d
+		}
+	}
+	return best
+}
+
+// squash lowercases and drops separators so erauner-home == EraunerHome
+func squash(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '-', '_', '.':
+			return -1
+		}
+		return r
+	}, strings.ToLower(s))
+}
+
+// editDistance is the Levenshtein distance between a and b
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
@@ -0,0 +1,207 @@
+package cluster
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoad(t *testing.T) {
+	t.Run("clusters.yaml takes precedence", func(t *testing.T) {
+		repo := t.TempDir()
+		if err := os.MkdirAll(filepath.Join(repo, "clusters", "stale"), 0755); err != nil {
+			t.Fatalf("failed to create dir: %v", err)
+		}
+		content := "clusters:\n  - erauner-home\n  - name: erauner-cloud\n    labels:\n      tier: cloud\n"
+		if err := os.WriteFile(filepath.Join(repo, RegistryFile), []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write registry: %v", err)
+		}
+
+		registry, err := Load(repo)
+		if err != nil {
+			t.Fatalf("Load() error = %v", err)
+		}
+		if registry.Source != SourceFile {
+			t.Errorf("Source = %q, want %q", registry.Source, SourceFile)
+		}
+		if got := strings.Join(registry.Names(), ","); got != "erauner-cloud,erauner-home" {
+			t.Errorf("Names() = %q", got)
+		}
+		if registry.Has("stale") {
+			t.Error("clusters/ directories should be ignored when clusters.yaml exists")
+		}
+		if registry.Clusters()[0].Labels["tier"] != "cloud" {
+			t.Errorf("expected labels from clusters.yaml, got %+v", registry.Clusters()[0])
+		}
+	})
+
+	t.Run("clusters directory", func(t *testing.T) {
+		repo := t.TempDir()
+		for _, dir := range []string{"clusters/erauner-home", "clusters/.git"} {
+			if err := os.MkdirAll(filepath.Join(repo, dir), 0755); err != nil {
+				t.Fatalf("failed to create dir: %v", err)
+			}
+		}
+
+		registry, err := Load(repo)
+		if err != nil {
+			t.Fatalf("Load() error = %v", err)
+		}
+		if registry.Source != SourceDirectory || strings.Join(registry.Names(), ",") != "erauner-home" {
+			t.Errorf("unexpected registry %s: %v", registry.Source, registry.Names())
+		}
+	})
+
+	t.Run("neither", func(t *testing.T) {
+		registry, err := Load(t.TempDir())
+		if err != nil {
+			t.Fatalf("Load() error = %v", err)
+		}
+		if !registry.Empty() || registry.Source != SourceNone {
+			t.Errorf("expected empty registry, got %s: %v", registry.Source, registry.Names())
+		}
+	})
+}
+
+func TestParse_ArgoCDVersion(t *testing.T) {
+	registry, err := Parse([]byte("clusters:\n  - name: erauner-home\n    argocdVersion: v2.8.4\n  - erauner-cloud\n"))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if c, ok := registry.Get("erauner-home"); !ok || c.ArgoCDVersion != "v2.8.4" {
+		t.Errorf("Get(erauner-home) = %+v, %v", c, ok)
+	}
+	if c, _ := registry.Get("erauner-cloud"); c.ArgoCDVersion != "" {
+		t.Errorf("expected no ArgoCD version for erauner-cloud, got %q", c.ArgoCDVersion)
+	}
+	if _, ok := registry.Get("missing"); ok {
+		t.Error("Get(missing) should report false")
+	}
+}
+
+func TestRegistry_ByServer(t *testing.T) {
+	registry, err := Parse([]byte("clusters:\n  - name: erauner-home\n    server: https://kubernetes.default.svc\n  - name: erauner-cloud\n    server: https://cloud.example.com:6443/\n  - edge\n"))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	for server, want := range map[string]string{
+		"https://kubernetes.default.svc":   "erauner-home",
+		"https://kubernetes.default.svc/":  "erauner-home",
+		"https://cloud.example.com:6443":   "erauner-cloud",
+		"https://unknown.example.com:6443": "",
+		"":                                 "",
+	} {
+		c, ok := registry.ByServer(server)
+		if c.Name != want || ok != (want != "") {
+			t.Errorf("ByServer(%q) = %q, %v; want %q", server, c.Name, ok, want)
+		}
+	}
+}
+
+func TestParse_Invalid(t *testing.T) {
+	for _, data := range []string{
+		"clusters: [",
+		"clusters:\n  - labels: {a: b}\n",
+		"clusters:\n  - name: erauner-home\n    vars: {CLUSTER-DOMAIN: x}\n",
+		"clusters:\n  - name: erauner-home\n    vars: {CLUSTER_NAME: x}\n",
+		"clusters:\n  - name: a\n    server: https://k8s.example.com\n  - name: b\n    server: https://k8s.example.com/\n",
+	} {
+		if _, err := Parse([]byte(data)); err == nil {
+			t.Errorf("Parse(%q) expected error", data)
+		}
+	}
+}
+
+func TestParseAppPath(t *testing.T) {
+	registry := NewRegistry(SourceFile, []Cluster{{Name: "erauner-home"}})
+
+	tests := []struct {
+		name     string
+		registry *Registry
+		path     string
+		want     AppPath
+		wantOK   bool
+	}{
+		{
+			name:     "cluster-aware overlay",
+			registry: registry,
+			path:     "apps/coder/overlays/erauner-home/production",
+			want:     AppPath{App: "coder", OverlayRoot: "overlays", Cluster: "erauner-home", Environment: "production"},
+			wantOK:   true,
+		},
+		{
+			name:     "db overlay",
+			registry: registry,
+			path:     "apps/coder/db/overlays/erauner-home/production",
+			want:     AppPath{App: "coder", OverlayRoot: "db/overlays", Cluster: "erauner-home", Environment: "production"},
+			wantOK:   true,
+		},
+		{
+			name:     "legacy flat overlay",
+			registry: registry,
+			path:     "apps/coder/stack/production",
+			want:     AppPath{App: "coder", OverlayRoot: "stack", Environment: "production"},
+			wantOK:   true,
+		},
+		{
+			name:     "nested kustomization under legacy env",
+			registry: registry,
+			path:     "apps/coder/overlays/production/patches",
+		},
+		{
+			name:     "empty registry assumes cluster-aware",
+			registry: NewRegistry(SourceNone, nil),
+			path:     "apps/coder/overlays/production/patches",
+			want:     AppPath{App: "coder", OverlayRoot: "overlays", Cluster: "production", Environment: "patches"},
+			wantOK:   true,
+		},
+		{
+			name:     "not an overlay",
+			registry: registry,
+			path:     "apps/coder/base",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := tt.registry.ParseAppPath(tt.path)
+			if ok != tt.wantOK {
+				t.Fatalf("ParseAppPath(%q) ok = %v, want %v", tt.path, ok, tt.wantOK)
+			}
+			if got != tt.want {
+				t.Errorf("ParseAppPath(%q) = %+v, want %+v", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSuggest(t *testing.T) {
+	registry := NewRegistry(SourceFile, []Cluster{{Name: "erauner-home"}, {Name: "erauner-cloud"}})
+
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"eraunerhome", "erauner-home"},
+		{"Erauner_Cloud", "erauner-cloud"},
+		{"erauner-hom", "erauner-home"},
+		{"erauner-clod", "erauner-cloud"},
+		{"erauner-home", ""}, // registered
+		{"production", ""},
+		{"staging", ""},
+		{"prod", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := registry.Suggest(tt.name); got != tt.want {
+				t.Errorf("Suggest(%q) = %q, want %q", tt.name, got, tt.want)
+			}
+		})
+	}
+
+	if got := NewRegistry(SourceNone, nil).Suggest("eraunerhome"); got != "" {
+		t.Errorf("empty registry Suggest() = %q, want \"\"", got)
+	}
+}
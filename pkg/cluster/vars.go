@@ -0,0 +1,129 @@
+package cluster
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// VarClusterName is set to the cluster's name for every cluster with variables
+const VarClusterName = "CLUSTER_NAME"
+
+// varName is the syntax of a variable name in clusters.yaml
+var varName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// placeholder matches ${NAME} and its escaped form $${NAME}
+var placeholder = regexp.MustCompile(`\$?\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// Variables returns the cluster's vars plus CLUSTER_NAME
+func (c Cluster) Variables() map[string]string {
+	vars := make(map[string]string, len(c.Vars)+1)
+	for name, value := range c.Vars {
+		vars[name] = value
+	}
+	vars[VarClusterName] = c.Name
+	return vars
+}
+
+// HasVars reports whether any cluster declares vars, which enables substitution
+func (r *Registry) HasVars() bool {
+	for _, c := range r.Clusters() {
+		if len(c.Vars) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// VarNames returns every variable name declared by some cluster, plus
+// CLUSTER_NAME, sorted; empty when no cluster declares vars
+func (r *Registry) VarNames() []string {
+	if !r.HasVars() {
+		return nil
+	}
+	seen := map[string]bool{VarClusterName: true}
+	names := []string{VarClusterName}
+	for _, c := range r.Clusters() {
+		for name := range c.Vars {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Placeholder is a ${NAME} reference in a file
+type Placeholder struct {
+	Name string
+	Line int // 1-based
+}
+
+// Placeholders returns the ${NAME} references in text whose name is one of
+// names; escaped $${NAME} references are skipped
+func Placeholders(text string, names []string) []Placeholder {
+	known := make(map[string]bool, len(names))
+	for _, name := range names {
+		known[name] = true
+	}
+
+	var found []Placeholder
+	for i, line := range strings.Split(text, "\n") {
+		for _, m := range placeholder.FindAllStringSubmatch(line, -1) {
+			if !strings.HasPrefix(m[0], "$$") && known[m[1]] {
+				found = append(found, Placeholder{Name: m[1], Line: i + 1})
+			}
+		}
+	}
+	return found
+}
+
+// Substitute replaces ${NAME} placeholders in a manifest rendered for a
+// cluster with its variables ("" for manifests without a cluster) and returns
+// the sorted names it could not resolve.
+//
+// Only names declared by some cluster (see VarNames) are placeholders, so
+// shell snippets and Flux postBuild variables pass through untouched; $${NAME}
+// escapes a placeholder and renders as ${NAME}.
+func (r *Registry) Substitute(clusterName, manifest string) (string, []string) {
+	names := r.VarNames()
+	if len(names) == 0 {
+		return manifest, nil
+	}
+	known := make(map[string]bool, len(names))
+	for _, name := range names {
+		known[name] = true
+	}
+
+	var vars map[string]string
+	if c, ok := r.Get(clusterName); ok {
+		vars = c.Variables()
+	}
+
+	unresolved := make(map[string]bool)
+	out := placeholder.ReplaceAllStringFunc(manifest, func(m string) string {
+		escaped := strings.HasPrefix(m, "$$")
+		name := strings.TrimSuffix(strings.TrimLeft(m, "${"), "}")
+		switch {
+		case !known[name]:
+			return m
+		case escaped:
+			return m[1:]
+		}
+		value, ok := vars[name]
+		if !ok {
+			unresolved[name] = true
+			return m
+		}
+		return value
+	})
+
+	var missing []string
+	for name := range unresolved {
+		missing = append(missing, name)
+	}
+	sort.Strings(missing)
+	return out, missing
+}
@@ -0,0 +1,81 @@
+package cluster
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSubstitute(t *testing.T) {
+	registry, err := Parse([]byte(`clusters:
+  - name: erauner-home
+    vars:
+      CLUSTER_DOMAIN: home.erauner.dev
+  - name: erauner-cloud
+    vars:
+      CLUSTER_DOMAIN: cloud.erauner.dev
+      STORAGE_CLASS: longhorn
+`))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	manifest := `host: app.${CLUSTER_DOMAIN}
+cluster: ${CLUSTER_NAME}
+storageClassName: ${STORAGE_CLASS}
+script: echo ${HOME} $${CLUSTER_DOMAIN} $${FLUX_VAR}
+`
+	tests := []struct {
+		cluster        string
+		want           string
+		wantUnresolved []string
+	}{
+		{
+			cluster: "erauner-cloud",
+			want: `host: app.cloud.erauner.dev
+cluster: erauner-cloud
+storageClassName: longhorn
+script: echo ${HOME} ${CLUSTER_DOMAIN} $${FLUX_VAR}
+`,
+		},
+		{
+			cluster: "erauner-home",
+			want: `host: app.home.erauner.dev
+cluster: erauner-home
+storageClassName: ${STORAGE_CLASS}
+script: echo ${HOME} ${CLUSTER_DOMAIN} $${FLUX_VAR}
+`,
+			wantUnresolved: []string{"STORAGE_CLASS"},
+		},
+		{
+			cluster:        "",
+			want:           "host: app.${CLUSTER_DOMAIN}\ncluster: ${CLUSTER_NAME}\nstorageClassName: ${STORAGE_CLASS}\nscript: echo ${HOME} ${CLUSTER_DOMAIN} $${FLUX_VAR}\n",
+			wantUnresolved: []string{"CLUSTER_DOMAIN", "CLUSTER_NAME", "STORAGE_CLASS"},
+		},
+	}
+	for _, tt := range tests {
+		got, unresolved := registry.Substitute(tt.cluster, manifest)
+		if got != tt.want {
+			t.Errorf("Substitute(%q) =\n%s\nwant:\n%s", tt.cluster, got, tt.want)
+		}
+		if !reflect.DeepEqual(unresolved, tt.wantUnresolved) {
+			t.Errorf("Substitute(%q) unresolved = %v, want %v", tt.cluster, unresolved, tt.wantUnresolved)
+		}
+	}
+}
+
+func TestSubstitute_NoVars(t *testing.T) {
+	registry := NewRegistry(SourceFile, []Cluster{{Name: "erauner-home"}})
+	manifest := "cluster: ${CLUSTER_NAME}\n"
+	if got, unresolved := registry.Substitute("erauner-home", manifest); got != manifest || len(unresolved) > 0 {
+		t.Errorf("Substitute() without vars = %q, %v; want manifest unchanged", got, unresolved)
+	}
+}
+
+func TestPlaceholders(t *testing.T) {
+	text := "a: ${CLUSTER_DOMAIN}\nb: $${CLUSTER_DOMAIN} ${HOME}\nc: ${CLUSTER_NAME}-${CLUSTER_DOMAIN}\n"
+	got := Placeholders(text, []string{"CLUSTER_DOMAIN", "CLUSTER_NAME"})
+	want := []Placeholder{{"CLUSTER_DOMAIN", 1}, {"CLUSTER_NAME", 3}, {"CLUSTER_DOMAIN", 3}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Placeholders() = %v, want %v", got, want)
+	}
+}
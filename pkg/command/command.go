@@ -0,0 +1,144 @@
+// Package command runs external tools (kustomize, helm, kyverno, git, ...)
+// bounded by a context and a per-command timeout
+//
+// A command that runs past Timeout is killed and its error is a *TimeoutError,
+// so a hung chart fetch fails one render instead of stalling the pipeline. A
+// command whose context is canceled fails with the context's error.
+package command
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/erauner/homelab-shadow/pkg/trace"
+)
+
+// DefaultTimeout is the per-command timeout unless the CLI sets Timeout
+const DefaultTimeout = 5 * time.Minute
+
+// Timeout bounds every command started by this package (0 disables it)
+// The shadow CLI sets it from --cmd-timeout
+var Timeout = DefaultTimeout
+
+// waitDelay bounds how long Wait waits for a killed command's output pipes,
+// which children it started (helm plugins, git-remote-https) may hold open
+const waitDelay = 5 * time.Second
+
+// ErrNotInstalled is wrapped by errors reporting that a required tool isn't
+// on PATH ("kyverno CLI is %w"), so callers can tell them from failures
+var ErrNotInstalled = errors.New("not installed")
+
+// TimeoutError is a command killed after running for Timeout
+type TimeoutError struct {
+	Command string // the tool and subcommand, e.g. "helm pull"
+	Timeout time.Duration
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("%s timed out after %s (raise --cmd-timeout)", e.Command, e.Timeout)
+}
+
+// IsTimeout reports whether err is, or wraps, a *TimeoutError
+func IsTimeout(err error) bool {
+	var timeout *TimeoutError
+	return errors.As(err, &timeout)
+}
+
+// Cmd is an exec.Cmd whose Run, Output, and CombinedOutput report timeouts
+// and cancellation distinctly
+type Cmd struct {
+	*exec.Cmd
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	parent context.Context
+}
+
+// Command returns a Cmd for name bounded only by Timeout
+func Command(name string, args ...string) *Cmd {
+	return CommandContext(context.Background(), name, args...)
+}
+
+// CommandContext returns a Cmd for name that is killed when ctx is done or
+// Timeout has passed
+func CommandContext(ctx context.Context, name string, args ...string) *Cmd {
+	parent := ctx
+	cancel := context.CancelFunc(func() {})
+	if Timeout > 0 {
+		ctx, cancel = context.WithTimeoutCause(ctx, Timeout, &TimeoutError{Command: describe(name, args), Timeout: Timeout})
+	}
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.WaitDelay = waitDelay
+	return &Cmd{Cmd: cmd, ctx: ctx, cancel: cancel, parent: parent}
+}
+
+// Run starts the command and waits for it to finish
+func (c *Cmd) Run() error {
+	defer c.cancel()
+	span := c.span()
+	err := c.wrap(c.Cmd.Run())
+	span.End(err)
+	return err
+}
+
+// Output runs the command and returns its stdout
+func (c *Cmd) Output() ([]byte, error) {
+	defer c.cancel()
+	span := c.span()
+	out, err := c.Cmd.Output()
+	err = c.wrap(err)
+	span.End(err)
+	return out, err
+}
+
+// CombinedOutput runs the command and returns its stdout and stderr
+func (c *Cmd) CombinedOutput() ([]byte, error) {
+	defer c.cancel()
+	span := c.span()
+	out, err := c.Cmd.CombinedOutput()
+	err = c.wrap(err)
+	span.End(err)
+	return out, err
+}
+
+// span starts a client span for the command under the span in its context;
+// it is named like timeout errors, so arguments never reach the collector
+func (c *Cmd) span() *trace.Span {
+	_, span := trace.StartKind(c.parent, describe(c.Args[0], c.Args[1:]), trace.KindClient,
+		"process.executable.name", c.Args[0])
+	return span
+}
+
+// wrap replaces the kill signal error of a command stopped by its context
+// with the reason it was stopped
+func (c *Cmd) wrap(err error) error {
+	if err == nil || c.ctx.Err() == nil {
+		return err
+	}
+	if perr := c.parent.Err(); perr != nil {
+		return fmt.Errorf("%s: %w", describe(c.Args[0], c.Args[1:]), perr)
+	}
+	if cause := context.Cause(c.ctx); cause != nil {
+		return cause
+	}
+	return err
+}
+
+// describe names a command by its tool and first non-flag argument
+// ("helm pull", "git clone"), never its values, which may hold credentials
+func describe(name string, args []string) string {
+	for i := 0; i < len(args); i++ {
+		switch {
+		case args[i] == "-C" || args[i] == "-c":
+			i++ // git -C <dir>, -c <key=value>
+		case strings.HasPrefix(args[i], "-"):
+		default:
+			return name + " " + args[i]
+		}
+	}
+	return name
+}
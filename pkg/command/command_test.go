@@ -0,0 +1,77 @@
+package command
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCommand_Timeout(t *testing.T) {
+	if _, err := exec.LookPath("sleep"); err != nil {
+		t.Skip("sleep not available")
+	}
+	defer func(old time.Duration) { Timeout = old }(Timeout)
+	Timeout = 50 * time.Millisecond
+
+	start := time.Now()
+	_, err := Command("sleep", "10").CombinedOutput()
+	if !IsTimeout(err) {
+		t.Fatalf("error = %v, want a timeout", err)
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Errorf("command ran for %s after timing out", time.Since(start))
+	}
+	if !strings.Contains(err.Error(), "sleep 10 timed out after 50ms") {
+		t.Errorf("error = %q", err)
+	}
+}
+
+func TestCommand_Canceled(t *testing.T) {
+	if _, err := exec.LookPath("sleep"); err != nil {
+		t.Skip("sleep not available")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	err := CommandContext(ctx, "sleep", "10").Run()
+	if !errors.Is(err, context.Canceled) || IsTimeout(err) {
+		t.Errorf("error = %v, want context.Canceled", err)
+	}
+}
+
+func TestCommand_Passthrough(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not available")
+	}
+	out, err := Command("sh", "-c", "echo ok").Output()
+	if err != nil || strings.TrimSpace(string(out)) != "ok" {
+		t.Errorf("Output() = %q, %v", out, err)
+	}
+
+	err = Command("sh", "-c", "exit 3").Run()
+	var exit *exec.ExitError
+	if !errors.As(err, &exit) || exit.ExitCode() != 3 {
+		t.Errorf("Run() error = %v, want exit status 3", err)
+	}
+}
+
+func TestDescribe(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{"helm", []string{"pull", "chart", "--password", "secret"}, "helm pull"},
+		{"git", []string{"-C", "/repo", "push", "origin"}, "git push"},
+		{"git", []string{"-c", "user.name=x", "commit"}, "git commit"},
+		{"kubeconform", []string{"-strict", "-summary"}, "kubeconform"},
+	}
+	for _, tt := range tests {
+		if got := describe(tt.name, tt.args); got != tt.want {
+			t.Errorf("describe(%q, %v) = %q, want %q", tt.name, tt.args, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// CEL rule targets: what a rule's expression is evaluated against
+const (
+	CELTargetResource      = "resource"      // rendered resources of Application source paths
+	CELTargetKustomization = "kustomization" // every kustomization.yaml in the repo
+	CELTargetApplication   = "application"   // ArgoCD Application and ApplicationSet manifests
+)
+
+// CELRule is a user-defined validation rule: a CEL expression that must hold
+// for every object of its target
+//
+// e.g. {name: media-team-label, match: {kinds: [Deployment], namespaces: [media]},
+// expression: 'has(object.metadata.labels) && "team" in object.metadata.labels',
+// message: "must have a team label"}
+type CELRule struct {
+	// Name is the rule name of its findings
+	Name string `yaml:"name"`
+
+	// Target is resource (default), kustomization, or application
+	Target string `yaml:"target"`
+
+	// Match limits the objects the expression is evaluated for
+	Match CELMatch `yaml:"match"`
+
+	// Expression must evaluate to true for each matching object; it sees
+	// object (the parsed YAML) and path (the repo-relative file or source path)
+	Expression string `yaml:"expression"`
+
+	// Message describes a violation (default: the expression)
+	Message string `yaml:"message"`
+
+	// Severity is error (default) or warn
+	Severity string `yaml:"severity"`
+}
+
+// CELMatch selects objects by kind and namespace (empty matches any)
+type CELMatch struct {
+	Kinds      []string `yaml:"kinds"`
+	Namespaces []string `yaml:"namespaces"`
+}
+
+// celRuleName is a rule name like the built-in ones
+var celRuleName = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// validateCELRules checks rule names, targets, and severities; expressions
+// are compiled by validate
+func validateCELRules(rules []CELRule) error {
+	seen := make(map[string]bool)
+	for i, r := range rules {
+		if !celRuleName.MatchString(r.Name) {
+			return fmt.Errorf("celRules[%d]: invalid name %q (expected lowercase words joined by -)", i, r.Name)
+		}
+		if seen[r.Name] {
+			return fmt.Errorf("celRules: duplicate rule %s", r.Name)
+		}
+		seen[r.Name] = true
+		switch r.Target {
+		case "", CELTargetResource, CELTargetKustomization, CELTargetApplication:
+		default:
+			return fmt.Errorf("celRules %s: unknown target %q (expected resource, kustomization, or application)", r.Name, r.Target)
+		}
+		if r.Expression == "" {
+			return fmt.Errorf("celRules %s: expression is required", r.Name)
+		}
+		switch r.Severity {
+		case "", SeverityError, SeverityWarn:
+		default:
+			return fmt.Errorf("celRules %s: unknown severity %q (expected error or warn)", r.Name, r.Severity)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,240 @@
+// Package config loads the optional .shadow.yaml repository configuration
+package config
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FileName is the config file shadow looks for at the repository root
+const FileName = ".shadow.yaml"
+
+// Config is the parsed .shadow.yaml configuration
+// Every section is optional; a missing file yields an empty Config
+type Config struct {
+	// Owners maps repo path prefixes to owning teams (longest prefix wins)
+	// e.g. {"apps/coder": "dev-tools", "infrastructure": "platform"}
+	Owners map[string]string `yaml:"owners"`
+
+	// Rules overrides individual validation rules by rule ID
+	// e.g. {"image-tag-latest": "error", "image-tag-floating": {severity: off},
+	//       "app-overlay-legacy-flat": {ignore: ["apps/legacy/**"]}}
+	Rules map[string]RuleConfig `yaml:"rules"`
+
+	// Outputs splits sync output across several roots in the shadow repo
+	// When empty, sync renders everything into a single "rendered/" root
+	Outputs []Output `yaml:"outputs"`
+
+	// ShadowRepos maps clusters to shadow repos, so sync pushes each
+	// cluster's renders to its own repo; when empty, everything goes to
+	// --shadow-repo
+	ShadowRepos []ShadowRepo `yaml:"shadowRepos"`
+
+	// Redaction adds kinds and field paths to redact and allow-lists resources
+	// that may be published as-is
+	Redaction Redaction `yaml:"redaction"`
+
+	// Normalization tunes the pass that makes rendered manifests deterministic
+	Normalization Normalization `yaml:"normalization"`
+
+	// Unused excludes ConfigMaps and Secrets from the unused config analysis
+	Unused Unused `yaml:"unused"`
+
+	// Strictness applies stricter rule severities to paths added since a git ref or date
+	Strictness Strictness `yaml:"strictness"`
+
+	// Policies overrides where policy trees are discovered
+	Policies Policies `yaml:"policies"`
+
+	// Tools pins the versions of external tools sync and validate run with,
+	// since kustomize and helm releases render differently
+	// e.g. {kustomize: ">=5.3.0 <5.5.0", helm: "3.14.x"}
+	Tools map[string]string `yaml:"tools"`
+
+	// Plugins are validator plugins validate runs after the built-in rules:
+	// executables printing findings as JSON, or Go plugins (.so); paths are
+	// relative to the repo root
+	// e.g. ["hack/shadow-checks/ingress-annotations"]
+	Plugins []string `yaml:"plugins"`
+
+	// CELRules are user-defined rules written as CEL expressions over
+	// rendered resources, kustomizations, or Applications
+	CELRules []CELRule `yaml:"celRules"`
+}
+
+// Rule severities accepted in .shadow.yaml
+const (
+	SeverityError  = "error"
+	SeverityWarn   = "warn"
+	SeverityOff    = "off"    // drop findings for the rule entirely
+	SeverityIgnore = "ignore" // keep findings but mark them suppressed (see --show-suppressed)
+)
+
+// RuleConfig configures a single validation rule
+// It may be written as a bare severity string or as a mapping
+type RuleConfig struct {
+	Severity string `yaml:"severity"`
+
+	// Ignore suppresses findings whose path matches one of these globs
+	// ("**" matches any number of segments) or path prefixes
+	Ignore []string `yaml:"ignore"`
+}
+
+// UnmarshalYAML accepts both `rule: warn` and `rule: {severity: warn}`
+func (r *RuleConfig) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		r.Severity = node.Value
+		return nil
+	}
+	type plain RuleConfig
+	return node.Decode((*plain)(r))
+}
+
+// Load reads <repoPath>/.shadow.yaml, returning an empty Config if it doesn't exist
+func Load(repoPath string) (*Config, error) {
+	path := filepath.Join(repoPath, FileName)
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return &Config{}, nil
+	}
+	return LoadFile(path)
+}
+
+// LoadFile reads and parses a config file at an explicit path
+func LoadFile(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+	return Parse(data)
+}
+
+// Parse parses config YAML data
+func Parse(data []byte) (*Config, error) {
+	cfg := &Config{}
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", FileName, err)
+	}
+	for rule, rc := range cfg.Rules {
+		switch rc.Severity {
+		case "", SeverityError, SeverityWarn, SeverityOff, SeverityIgnore:
+		default:
+			return nil, fmt.Errorf("rule %s: unknown severity %q (expected error, warn, ignore, or off)", rule, rc.Severity)
+		}
+		for _, pattern := range rc.Ignore {
+			if _, err := path.Match(strings.ReplaceAll(pattern, "**", "*"), ""); err != nil {
+				return nil, fmt.Errorf("rule %s: invalid ignore pattern %q: %w", rule, pattern, err)
+			}
+		}
+	}
+	if err := validateOutputs(cfg.Outputs); err != nil {
+		return nil, err
+	}
+	if err := validateShadowRepos(cfg.ShadowRepos); err != nil {
+		return nil, err
+	}
+	if err := validateRedaction(cfg.Redaction); err != nil {
+		return nil, err
+	}
+	if err := validateNormalization(cfg.Normalization); err != nil {
+		return nil, err
+	}
+	if err := validateUnused(cfg.Unused); err != nil {
+		return nil, err
+	}
+	if err := validateStrictness(cfg.Strictness); err != nil {
+		return nil, err
+	}
+	if err := validatePolicies(cfg.Policies); err != nil {
+		return nil, err
+	}
+	if err := validateTools(cfg.Tools); err != nil {
+		return nil, err
+	}
+	if err := validateCELRules(cfg.CELRules); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// SeverityFor returns the configured severity for a rule, or def if not overridden
+func (c *Config) SeverityFor(rule, def string) string {
+	if rc, ok := c.Rules[rule]; ok && rc.Severity != "" {
+		return rc.Severity
+	}
+	return def
+}
+
+// IgnoredBy returns the rules.<rule>.ignore pattern matching path, or ""
+func (c *Config) IgnoredBy(rule, p string) string {
+	rc, ok := c.Rules[rule]
+	if !ok {
+		return ""
+	}
+	p = strings.TrimPrefix(filepath.ToSlash(p), "./")
+	for _, pattern := range rc.Ignore {
+		if MatchPath(strings.TrimPrefix(pattern, "./"), p) {
+			return pattern
+		}
+	}
+	return ""
+}
+
+// MatchPath matches a slash-separated path against a glob where "**" spans
+// segments; a pattern without wildcards also matches everything beneath it
+func MatchPath(pattern, p string) bool {
+	if !strings.ContainsAny(pattern, "*?[") {
+		prefix := strings.TrimSuffix(pattern, "/")
+		return p == prefix || strings.HasPrefix(p, prefix+"/")
+	}
+
+	patternParts := strings.Split(pattern, "/")
+	pathParts := strings.Split(p, "/")
+	return matchSegments(patternParts, pathParts)
+}
+
+// matchSegments matches path segments, letting "**" consume zero or more of them
+func matchSegments(pattern, parts []string) bool {
+	if len(pattern) == 0 {
+		return len(parts) == 0
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(parts); i++ {
+			if matchSegments(pattern[1:], parts[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(parts) == 0 {
+		return false
+	}
+	if ok, _ := path.Match(pattern[0], parts[0]); !ok {
+		return false
+	}
+	return matchSegments(pattern[1:], parts[1:])
+}
+
+// OwnerFor returns the owner of a repo-relative path, or "" if unowned
+// Prefixes match on path segment boundaries, so "apps/code" does not own "apps/coder"
+func (c *Config) OwnerFor(path string) string {
+	path = strings.TrimPrefix(filepath.ToSlash(path), "./")
+
+	owner := ""
+	longest := -1
+	for prefix, o := range c.Owners {
+		p := strings.TrimSuffix(strings.TrimPrefix(filepath.ToSlash(prefix), "./"), "/")
+		if p == "" || (path != p && !strings.HasPrefix(path, p+"/")) {
+			continue
+		}
+		if len(p) > longest {
+			longest = len(p)
+			owner = o
+		}
+	}
+	return owner
+}
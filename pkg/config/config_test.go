@@ -0,0 +1,448 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoad_MissingFile(t *testing.T) {
+	cfg, err := Load(t.TempDir())
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg == nil {
+		t.Fatal("Load() returned nil config for missing file")
+	}
+	if len(cfg.Owners) != 0 {
+		t.Errorf("expected no owners, got %v", cfg.Owners)
+	}
+}
+
+func TestLoad_ParsesFile(t *testing.T) {
+	tmpDir := t.TempDir()
+	content := `owners:
+  apps/coder: dev-tools
+  infrastructure: platform
+`
+	if err := os.WriteFile(filepath.Join(tmpDir, FileName), []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+
+	cfg, err := Load(tmpDir)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.Owners["apps/coder"] != "dev-tools" {
+		t.Errorf("expected apps/coder owner dev-tools, got %q", cfg.Owners["apps/coder"])
+	}
+}
+
+func TestLoad_InvalidYAML(t *testing.T) {
+	tmpDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tmpDir, FileName), []byte("owners: [[["), 0644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+	if _, err := Load(tmpDir); err == nil {
+		t.Error("expected error for invalid YAML")
+	}
+}
+
+func TestOwnerFor(t *testing.T) {
+	cfg := &Config{Owners: map[string]string{
+		"apps":           "apps-team",
+		"apps/coder/":    "dev-tools",
+		"infrastructure": "platform",
+		"./security":     "security",
+	}}
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"apps/coder/overlays/erauner-home/production", "dev-tools"},
+		{"apps/coder", "dev-tools"},
+		{"apps/coderx/base", "apps-team"},
+		{"apps/giraffe/base/kustomization.yaml", "apps-team"},
+		{"infrastructure/argocd/overlays/erauner-home", "platform"},
+		{"security/namespaces/coder.yaml", "security"},
+		{"operators/cert-manager", ""},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if got := cfg.OwnerFor(tt.path); got != tt.want {
+				t.Errorf("OwnerFor(%q) = %q, want %q", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParse_Rules(t *testing.T) {
+	cfg, err := Parse([]byte(`rules:
+  image-tag-latest: error
+  image-tag-floating:
+    severity: off
+`))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	tests := []struct {
+		rule string
+		want string
+	}{
+		{"image-tag-latest", SeverityError},
+		{"image-tag-floating", SeverityOff},
+		{"image-tag-missing", SeverityWarn},
+	}
+	for _, tt := range tests {
+		if got := cfg.SeverityFor(tt.rule, SeverityWarn); got != tt.want {
+			t.Errorf("SeverityFor(%q) = %q, want %q", tt.rule, got, tt.want)
+		}
+	}
+}
+
+func TestParse_RulesUnknownSeverity(t *testing.T) {
+	if _, err := Parse([]byte("rules:\n  image-tag-latest: fatal\n")); err == nil {
+		t.Error("expected error for unknown severity")
+	}
+}
+
+func TestParse_RulesIgnore(t *testing.T) {
+	cfg, err := Parse([]byte(`rules:
+  app-overlay-legacy-flat: ignore
+  image-tag-latest:
+    ignore:
+      - apps/legacy/**
+      - infrastructure/vendor
+`))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if got := cfg.SeverityFor("app-overlay-legacy-flat", SeverityWarn); got != SeverityIgnore {
+		t.Errorf("SeverityFor() = %q, want %q", got, SeverityIgnore)
+	}
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"apps/legacy/overlays/production", "apps/legacy/**"},
+		{"apps/legacy", "apps/legacy/**"},
+		{"infrastructure/vendor/overlays/home", "infrastructure/vendor"},
+		{"infrastructure/vendored", ""},
+		{"apps/coder/overlays/production", ""},
+	}
+	for _, tt := range tests {
+		if got := cfg.IgnoredBy("image-tag-latest", tt.path); got != tt.want {
+			t.Errorf("IgnoredBy(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+	if got := cfg.IgnoredBy("image-tag-floating", "apps/legacy/base"); got != "" {
+		t.Errorf("IgnoredBy() for unconfigured rule = %q, want empty", got)
+	}
+}
+
+func TestParse_RulesInvalidIgnorePattern(t *testing.T) {
+	if _, err := Parse([]byte("rules:\n  image-tag-latest:\n    ignore: [\"apps/[\"]\n")); err == nil {
+		t.Error("expected error for invalid ignore pattern")
+	}
+}
+
+func TestParse_Outputs(t *testing.T) {
+	cfg, err := Parse([]byte(`outputs:
+  - path: rendered/
+    sources: [kustomize]
+  - path: helm
+    sources: [helm]
+  - path: previews
+    include: ["apps/*/overlays/**/preview"]
+    layout: by-cluster
+`))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if len(cfg.Outputs) != 3 {
+		t.Fatalf("expected 3 outputs, got %+v", cfg.Outputs)
+	}
+	if cfg.Outputs[0].Path != "rendered" {
+		t.Errorf("expected cleaned path, got %q", cfg.Outputs[0].Path)
+	}
+
+	rendered, helm, previews := cfg.Outputs[0], cfg.Outputs[1], cfg.Outputs[2]
+	if !rendered.HasSource(OutputSourceKustomize) || rendered.HasSource(OutputSourceHelm) {
+		t.Error("rendered should only render kustomize")
+	}
+	if !previews.HasSource(OutputSourceKustomize) || !previews.HasSource(OutputSourceHelm) {
+		t.Error("previews should default to all sources")
+	}
+	if !helm.Includes("apps/coder/helm") {
+		t.Error("outputs without include should include everything")
+	}
+	if !previews.Includes("apps/coder/overlays/erauner-home/preview") || previews.Includes("apps/coder/overlays/erauner-home/production") {
+		t.Error("previews include patterns did not match as expected")
+	}
+}
+
+func TestParse_OutputsInvalid(t *testing.T) {
+	tests := map[string]string{
+		"missing path":   "outputs:\n  - sources: [helm]\n",
+		"escapes repo":   "outputs:\n  - path: ../elsewhere\n",
+		"repo root":      "outputs:\n  - path: .\n",
+		"git dir":        "outputs:\n  - path: .git/rendered\n",
+		"unknown source": "outputs:\n  - path: rendered\n    sources: [jsonnet]\n",
+		"unknown layout": "outputs:\n  - path: rendered\n    layout: flat\n",
+		"nested roots":   "outputs:\n  - path: rendered\n  - path: rendered/helm\n",
+		"duplicate root": "outputs:\n  - path: rendered\n  - path: ./rendered\n",
+	}
+	for name, data := range tests {
+		if _, err := Parse([]byte(data)); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestParse_ShadowRepos(t *testing.T) {
+	cfg, err := Parse([]byte(`shadowRepos:
+  - repo: erauner/homelab-k8s-shadow-home
+    clusters: [erauner-home]
+    shared: true
+  - repo: https://gitea.example.com/erauner/shadow-cloud.git
+    clusters: [erauner-cloud]
+    baseBranch: master
+`))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if len(cfg.ShadowRepos) != 2 || cfg.ShadowRepos[1].BaseBranch != "master" {
+		t.Fatalf("unexpected shadowRepos: %+v", cfg.ShadowRepos)
+	}
+	home, cloud := cfg.ShadowRepos[0], cfg.ShadowRepos[1]
+	if !home.Covers("erauner-home") || home.Covers("erauner-cloud") || !home.Covers("") {
+		t.Errorf("home repo covers the wrong clusters: %+v", home)
+	}
+	if !cloud.Covers("erauner-cloud") || cloud.Covers("") {
+		t.Errorf("cloud repo covers the wrong clusters: %+v", cloud)
+	}
+
+	for name, data := range map[string]string{
+		"missing repo":     "shadowRepos:\n  - clusters: [a]\n",
+		"missing clusters": "shadowRepos:\n  - repo: o/r\n",
+		"duplicate repo":   "shadowRepos:\n  - {repo: o/r, clusters: [a]}\n  - {repo: o/r.git, clusters: [b]}\n",
+		"cluster twice":    "shadowRepos:\n  - {repo: o/a, clusters: [a]}\n  - {repo: o/b, clusters: [a]}\n",
+	} {
+		if _, err := Parse([]byte(data)); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestCleanOutputPath(t *testing.T) {
+	valid := map[string]string{
+		"rendered":         "rendered",
+		"./rendered/":      "rendered",
+		"shadow/previews":  "shadow/previews",
+		"a/../rendered":    "rendered",
+		".github-rendered": ".github-rendered",
+	}
+	for in, want := range valid {
+		if got, err := CleanOutputPath(in); err != nil || got != want {
+			t.Errorf("CleanOutputPath(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+
+	for _, in := range []string{"", ".", "./", "..", "../rendered", "rendered/../..", "/rendered", ".git", ".git/objects"} {
+		if _, err := CleanOutputPath(in); err == nil {
+			t.Errorf("CleanOutputPath(%q) should fail", in)
+		}
+	}
+}
+
+func TestParse_Redaction(t *testing.T) {
+	data := `
+redaction:
+  kinds:
+    - kind: SealedSecret
+      apiVersion: bitnami.com/v1alpha1
+      fields: [spec.encryptedData]
+    - kind: ExternalSecret
+      fields: [status]
+  allow:
+    - monitoring/grafana-*
+    - public-ca
+`
+	cfg, err := Parse([]byte(data))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if len(cfg.Redaction.Kinds) != 2 {
+		t.Fatalf("expected 2 redaction kinds, got %+v", cfg.Redaction.Kinds)
+	}
+	sealed := cfg.Redaction.Kinds[0]
+	if sealed.Kind != "SealedSecret" || sealed.APIVersion != "bitnami.com/v1alpha1" || len(sealed.Fields) != 1 {
+		t.Errorf("unexpected kind: %+v", sealed)
+	}
+
+	allowed := map[[2]string]bool{
+		{"monitoring", "grafana-admin"}: true,
+		{"default", "grafana-admin"}:    false,
+		{"cert-manager", "public-ca"}:   true,
+		{"", "public-ca"}:               true,
+		{"monitoring", "prometheus"}:    false,
+	}
+	for res, want := range allowed {
+		if got := cfg.Redaction.AllowsResource(res[0], res[1]); got != want {
+			t.Errorf("AllowsResource(%q, %q) = %v, want %v", res[0], res[1], got, want)
+		}
+	}
+}
+
+func TestParse_RedactionInvalid(t *testing.T) {
+	tests := map[string]string{
+		"missing kind":   "redaction:\n  kinds:\n    - fields: [spec]\n",
+		"missing fields": "redaction:\n  kinds:\n    - kind: SealedSecret\n",
+		"empty segment":  "redaction:\n  kinds:\n    - kind: SealedSecret\n      fields: [spec..data]\n",
+		"whole resource": "redaction:\n  kinds:\n    - kind: SealedSecret\n      fields: ['*']\n",
+		"bad pattern":    "redaction:\n  allow: ['[']\n",
+	}
+	for name, data := range tests {
+		if _, err := Parse([]byte(data)); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestParse_Normalization(t *testing.T) {
+	cfg, err := Parse([]byte("normalization:\n  stripAnnotations: [\"example.com/*\"]\n  stabilizeHashes: true\n"))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if len(cfg.Normalization.StripAnnotations) != 1 || !cfg.Normalization.StabilizeHashes {
+		t.Errorf("unexpected normalization: %+v", cfg.Normalization)
+	}
+
+	if _, err := Parse([]byte("normalization:\n  stripAnnotations: [\"[\"]\n")); err == nil {
+		t.Error("expected error for invalid annotation pattern")
+	}
+}
+
+func TestParse_Unused(t *testing.T) {
+	cfg, err := Parse([]byte("unused:\n  ignore: [\"monitoring/*\", kube-root-ca.crt]\n  consumers: [grafana_dashboard=1, sidecar-target]\n"))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	u := cfg.Unused
+	if !u.IgnoresResource("monitoring", "dashboards") || !u.IgnoresResource("media", "kube-root-ca.crt") || u.IgnoresResource("media", "dashboards") {
+		t.Errorf("unexpected ignore matching for %+v", u.Ignore)
+	}
+	if !u.Consumed(map[string]string{"grafana_dashboard": "1"}, nil) || u.Consumed(map[string]string{"grafana_dashboard": "0"}, nil) {
+		t.Error("expected key=value consumers to match the value")
+	}
+	if !u.Consumed(nil, map[string]string{"sidecar-target": "/dashboards"}) {
+		t.Error("expected key consumers to match annotations with any value")
+	}
+
+	for _, data := range []string{"unused:\n  ignore: [\"[\"]\n", "unused:\n  consumers: [\"=1\"]\n"} {
+		if _, err := Parse([]byte(data)); err == nil {
+			t.Errorf("expected error for %q", data)
+		}
+	}
+}
+
+func TestParse_Strictness(t *testing.T) {
+	cfg, err := Parse([]byte("strictness:\n  since: origin/main\n  rules:\n    \"*\": error\n    image-tag-floating: warn\n"))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	s := cfg.Strictness
+	if !s.Enabled() || s.Since != "origin/main" {
+		t.Fatalf("unexpected strictness config: %+v", s)
+	}
+	if got := s.SeverityFor("app-overlay-missing-base", SeverityWarn); got != SeverityError {
+		t.Errorf("expected * to raise warn to error, got %q", got)
+	}
+	if got := s.SeverityFor("image-tag-floating", SeverityWarn); got != SeverityWarn {
+		t.Errorf("expected a named rule to win over *, got %q", got)
+	}
+	if got := (Strictness{}).SeverityFor("image-tag-latest", SeverityWarn); got != SeverityWarn {
+		t.Errorf("expected no change without rules, got %q", got)
+	}
+
+	for _, data := range []string{
+		"strictness:\n  rules:\n    image-tag-latest: error\n",
+		"strictness:\n  since: origin/main\n  rules:\n    image-tag-latest: fatal\n",
+		"strictness:\n  since: origin/main\n  rules:\n    \"*\": warn\n",
+	} {
+		if _, err := Parse([]byte(data)); err == nil {
+			t.Errorf("expected error for %q", data)
+		}
+	}
+}
+
+func TestParse_Policies(t *testing.T) {
+	cfg, err := Parse([]byte("policies:\n  kyverno: [policies/kyverno, ./platform/policies]\n  opa: [policies/rego]\n"))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if len(cfg.Policies.Kyverno) != 2 || len(cfg.Policies.OPA) != 1 {
+		t.Errorf("unexpected policies: %+v", cfg.Policies)
+	}
+	if _, err := Parse([]byte("policies:\n  opa: [../rego]\n")); err == nil {
+		t.Error("expected error for an opa root outside the repository")
+	}
+
+	for _, root := range []string{`""`, "/etc/policies", "..", "../other/policies", "."} {
+		data := "policies:\n  kyverno: [" + root + "]\n"
+		if _, err := Parse([]byte(data)); err == nil {
+			t.Errorf("expected error for %q", data)
+		}
+	}
+}
+
+func TestParse_Tools(t *testing.T) {
+	cfg, err := Parse([]byte("tools:\n  kustomize: \">=5.3.0 <5.5.0\"\n  helm: 3.14.x\n"))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if cfg.Tools["kustomize"] != ">=5.3.0 <5.5.0" || cfg.Tools["helm"] != "3.14.x" {
+		t.Errorf("unexpected tools: %+v", cfg.Tools)
+	}
+	for _, data := range []string{"tools:\n  kubectl: 1.30.x\n", "tools:\n  helm: latest\n", "tools:\n  helm: \"\"\n"} {
+		if _, err := Parse([]byte(data)); err == nil {
+			t.Errorf("Parse(%q) should fail", data)
+		}
+	}
+}
+
+func TestParse_CELRules(t *testing.T) {
+	cfg, err := Parse([]byte(`celRules:
+  - name: media-team-label
+    match: {kinds: [Deployment], namespaces: [media]}
+    expression: 'has(object.metadata.labels) && "team" in object.metadata.labels'
+    message: must have a team label
+  - name: apps-auto-sync
+    target: application
+    expression: has(object.spec.syncPolicy)
+    severity: warn
+`))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if len(cfg.CELRules) != 2 || cfg.CELRules[0].Match.Kinds[0] != "Deployment" || cfg.CELRules[1].Target != CELTargetApplication {
+		t.Errorf("unexpected celRules: %+v", cfg.CELRules)
+	}
+
+	for _, data := range []string{
+		"celRules:\n  - {name: Bad_Name, expression: 'true'}\n",
+		"celRules:\n  - {name: a, expression: 'true'}\n  - {name: a, expression: 'true'}\n",
+		"celRules:\n  - {name: a, target: secrets, expression: 'true'}\n",
+		"celRules:\n  - {name: a}\n",
+		"celRules:\n  - {name: a, expression: 'true', severity: ignore}\n",
+	} {
+		if _, err := Parse([]byte(data)); err == nil {
+			t.Errorf("Parse(%q) should fail", data)
+		}
+	}
+}
@@ -0,0 +1,29 @@
+package config
+
+import (
+	"fmt"
+	"path"
+)
+
+// Normalization tunes how sync normalizes rendered manifests before writing
+// them: resources are always sorted and checksum/* annotations stripped
+type Normalization struct {
+	// StripAnnotations are additional annotation key globs to remove,
+	// e.g. ["deployment.kubernetes.io/*", "example.com/build-id"]
+	StripAnnotations []string `yaml:"stripAnnotations"`
+
+	// StabilizeHashes replaces the hash suffix kustomize generators append to
+	// ConfigMap and Secret names (app-config-5g7h9m2k4t) with a fixed one,
+	// so a data change doesn't rename every reference
+	StabilizeHashes bool `yaml:"stabilizeHashes"`
+}
+
+// validateNormalization checks annotation globs are well-formed
+func validateNormalization(n Normalization) error {
+	for _, pattern := range n.StripAnnotations {
+		if _, err := path.Match(pattern, ""); err != nil || pattern == "" {
+			return fmt.Errorf("normalization.stripAnnotations: invalid pattern %q", pattern)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package config
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Output sources selectable per output root
+const (
+	OutputSourceKustomize = "kustomize"
+	OutputSourceHelm      = "helm"
+)
+
+// Output root layouts
+const (
+	// LayoutMirror mirrors the source path: <root>/<dir>/manifest.yaml
+	LayoutMirror = "mirror"
+	// LayoutByCluster groups by cluster first: <root>/<cluster>/<dir>/manifest.yaml
+	LayoutByCluster = "by-cluster"
+)
+
+// Output is a directory in the shadow repo that sync owns and rewrites
+type Output struct {
+	// Path is relative to the shadow repo root, e.g. "rendered" or "previews"
+	Path string `yaml:"path"`
+
+	// Sources selects what is rendered into the root: kustomize, helm (default: both)
+	Sources []string `yaml:"sources"`
+
+	// Include limits the root to source directories matching these globs or
+	// prefixes; Helm renders match as apps/<app>/helm (default: everything)
+	Include []string `yaml:"include"`
+
+	// Layout is mirror (default) or by-cluster
+	Layout string `yaml:"layout"`
+}
+
+// HasSource reports whether the root renders the given source
+func (o Output) HasSource(source string) bool {
+	if len(o.Sources) == 0 {
+		return true
+	}
+	for _, s := range o.Sources {
+		if s == source {
+			return true
+		}
+	}
+	return false
+}
+
+// Includes reports whether the root renders the repo-relative directory dir
+func (o Output) Includes(dir string) bool {
+	if len(o.Include) == 0 {
+		return true
+	}
+	dir = strings.TrimPrefix(path.Clean(dir), "./")
+	for _, pattern := range o.Include {
+		if MatchPath(strings.TrimPrefix(pattern, "./"), dir) {
+			return true
+		}
+	}
+	return false
+}
+
+// CleanOutputPath cleans an output root path and rejects anything that is not a
+// subdirectory of the shadow repo; sync deletes files under the root, so "",
+// ".", absolute paths, parent escapes, and .git are never allowed
+func CleanOutputPath(p string) (string, error) {
+	clean := path.Clean(strings.ReplaceAll(p, "\\", "/"))
+	switch {
+	case p == "":
+		return "", fmt.Errorf("output path is required")
+	case path.IsAbs(clean) || filepath.IsAbs(p) || filepath.VolumeName(p) != "":
+		return "", fmt.Errorf("output path %q must be relative to the shadow repo", p)
+	case clean == "." || clean == ".." || strings.HasPrefix(clean, "../"):
+		return "", fmt.Errorf("output path %q must be a subdirectory of the shadow repo", p)
+	case clean == ".git" || strings.HasPrefix(clean, ".git/"):
+		return "", fmt.Errorf("output path %q is inside .git", p)
+	}
+	return clean, nil
+}
+
+// validateOutputs checks output roots are well-formed and never overlap,
+// since each root is cleared before sync writes into it
+func validateOutputs(outputs []Output) error {
+	for i := range outputs {
+		o := &outputs[i]
+		clean, err := CleanOutputPath(o.Path)
+		if err != nil {
+			return fmt.Errorf("outputs[%d]: %w", i, err)
+		}
+		o.Path = clean
+
+		for _, source := range o.Sources {
+			if source != OutputSourceKustomize && source != OutputSourceHelm {
+				return fmt.Errorf("outputs %s: unknown source %q (expected kustomize or helm)", o.Path, source)
+			}
+		}
+		switch o.Layout {
+		case "", LayoutMirror, LayoutByCluster:
+		default:
+			return fmt.Errorf("outputs %s: unknown layout %q (expected mirror or by-cluster)", o.Path, o.Layout)
+		}
+		for _, pattern := range o.Include {
+			if _, err := path.Match(strings.ReplaceAll(pattern, "**", "*"), ""); err != nil {
+				return fmt.Errorf("outputs %s: invalid include pattern %q: %w", o.Path, pattern, err)
+			}
+		}
+	}
+
+	for i, a := range outputs {
+		for _, b := range outputs[i+1:] {
+			if a.Path == b.Path || strings.HasPrefix(b.Path, a.Path+"/") || strings.HasPrefix(a.Path, b.Path+"/") {
+				return fmt.Errorf("outputs %s and %s overlap", a.Path, b.Path)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,35 @@
+package config
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Policies locates the policy trees shadow tests and applies
+type Policies struct {
+	// Kyverno lists repo-relative roots of Kyverno policy trees, each holding
+	// base/{cluster,tests} and overlays/<cluster>/{cluster,tests}
+	// (default: policies/kyverno)
+	Kyverno []string `yaml:"kyverno"`
+
+	// OPA lists repo-relative directories searched for Rego policies that
+	// shadow policy conftest evaluates (default: policies/opa)
+	OPA []string `yaml:"opa"`
+}
+
+// validatePolicies checks policy roots are relative paths inside the repo
+func validatePolicies(p Policies) error {
+	for _, field := range []struct {
+		key   string
+		roots []string
+	}{{"kyverno", p.Kyverno}, {"opa", p.OPA}} {
+		for _, root := range field.roots {
+			clean := path.Clean(strings.TrimPrefix(root, "./"))
+			if root == "" || path.IsAbs(root) || clean == "." || clean == ".." || strings.HasPrefix(clean, "../") {
+				return fmt.Errorf("policies.%s: invalid root %q (expected a path inside the repository)", field.key, root)
+			}
+		}
+	}
+	return nil
+}
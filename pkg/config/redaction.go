@@ -0,0 +1,82 @@
+package config
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Redaction tunes how sync redacts rendered manifests, on top of the built-in
+// redaction of Secret data, stringData, and binaryData
+type Redaction struct {
+	// Kinds redacts fields of additional resource kinds
+	// e.g. [{kind: SealedSecret, fields: [spec.encryptedData]}, {kind: ExternalSecret, fields: [status]}]
+	Kinds []RedactKind `yaml:"kinds"`
+
+	// Allow publishes matching resources unredacted: "<namespace>/<name>" or
+	// "<name>" globs, e.g. "monitoring/grafana-*" (Secrets and Kinds alike)
+	Allow []string `yaml:"allow"`
+}
+
+// RedactKind selects fields to redact in every resource of one kind
+type RedactKind struct {
+	Kind       string `yaml:"kind"`       // resource kind, or "*" for every kind
+	APIVersion string `yaml:"apiVersion"` // optional, e.g. bitnami.com/v1alpha1
+
+	// Fields are dotted paths from the resource root, e.g. spec.encryptedData;
+	// a "*" segment matches any key or list item (spec.template.*.password)
+	Fields []string `yaml:"fields"`
+}
+
+// FieldPath splits a dotted field path into segments
+func FieldPath(field string) []string {
+	return strings.Split(field, ".")
+}
+
+// AllowsResource reports whether a resource is allowed to be published unredacted
+func (r Redaction) AllowsResource(namespace, name string) bool {
+	return matchResource(r.Allow, namespace, name)
+}
+
+// matchResource reports whether a resource matches one of the "<namespace>/<name>"
+// or "<name>" globs
+func matchResource(patterns []string, namespace, name string) bool {
+	for _, pattern := range patterns {
+		target := name
+		if strings.Contains(pattern, "/") {
+			target = namespace + "/" + name
+		}
+		if ok, _ := path.Match(pattern, target); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// validateRedaction checks redaction kinds and allow patterns are well-formed
+func validateRedaction(r Redaction) error {
+	for i, k := range r.Kinds {
+		if k.Kind == "" {
+			return fmt.Errorf("redaction.kinds[%d]: kind is required", i)
+		}
+		if len(k.Fields) == 0 {
+			return fmt.Errorf("redaction.kinds %s: fields is required", k.Kind)
+		}
+		for _, field := range k.Fields {
+			for _, segment := range FieldPath(field) {
+				if segment == "" {
+					return fmt.Errorf("redaction.kinds %s: invalid field path %q", k.Kind, field)
+				}
+			}
+			if strings.HasSuffix(field, "*") && !strings.Contains(field, ".") {
+				return fmt.Errorf("redaction.kinds %s: field path %q would redact the whole resource", k.Kind, field)
+			}
+		}
+	}
+	for _, pattern := range r.Allow {
+		if _, err := path.Match(pattern, ""); err != nil || pattern == "" {
+			return fmt.Errorf("redaction.allow: invalid pattern %q", pattern)
+		}
+	}
+	return nil
+}
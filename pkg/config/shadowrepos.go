@@ -0,0 +1,65 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ShadowRepo is a shadow repo that receives the rendered manifests of some clusters
+type ShadowRepo struct {
+	// Repo is the shadow repo: an owner/repo slug or git URL
+	Repo string `yaml:"repo"`
+
+	// Clusters whose directories are rendered into Repo
+	Clusters []string `yaml:"clusters"`
+
+	// Shared also renders directories that belong to no cluster (legacy flat
+	// overlays, Helm apps) into Repo
+	Shared bool `yaml:"shared"`
+
+	// BaseBranch overrides sync's --base-branch for Repo
+	BaseBranch string `yaml:"baseBranch"`
+}
+
+// Covers reports whether the repo receives directories of cluster ("" for
+// directories without one)
+func (t ShadowRepo) Covers(cluster string) bool {
+	if cluster == "" {
+		return t.Shared
+	}
+	for _, c := range t.Clusters {
+		if c == cluster {
+			return true
+		}
+	}
+	return false
+}
+
+// validateShadowRepos checks every entry names a repo and clusters, and that
+// no repo or cluster appears twice; two entries pushing the same branch would
+// overwrite each other
+func validateShadowRepos(repos []ShadowRepo) error {
+	seen := make(map[string]bool)
+	owner := make(map[string]string)
+	for i, t := range repos {
+		repo := strings.TrimSuffix(strings.TrimSpace(t.Repo), ".git")
+		if repo == "" {
+			return fmt.Errorf("shadowRepos[%d]: repo is required", i)
+		}
+		if len(t.Clusters) == 0 && !t.Shared {
+			return fmt.Errorf("shadowRepos %s: clusters is required (or shared: true)", t.Repo)
+		}
+		key := repo + "@" + t.BaseBranch
+		if seen[key] {
+			return fmt.Errorf("shadowRepos %s: repo is listed twice", t.Repo)
+		}
+		seen[key] = true
+		for _, c := range t.Clusters {
+			if other, ok := owner[c]; ok {
+				return fmt.Errorf("shadowRepos: cluster %s is mapped to both %s and %s", c, other, t.Repo)
+			}
+			owner[c] = t.Repo
+		}
+	}
+	return nil
+}
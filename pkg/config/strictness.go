@@ -0,0 +1,54 @@
+package config
+
+import "fmt"
+
+// StrictnessAll in strictness.rules applies to every rule not listed by name
+const StrictnessAll = "*"
+
+// Strictness holds new paths to stricter severities than legacy ones: any
+// finding path that did not exist at Since (a git ref or a date) uses these
+// rule severities instead of the ones under rules
+type Strictness struct {
+	// Since is the git ref (origin/main, a tag, a SHA) or date (2026-01-02,
+	// RFC 3339) before which paths count as legacy
+	Since string `yaml:"since"`
+
+	// Rules maps rule IDs to the severity for new paths, e.g. {"*": error}
+	// "*" only raises warn findings to error; named rules apply as given
+	Rules map[string]string `yaml:"rules"`
+}
+
+// Enabled reports whether any strictness rules are configured
+func (s Strictness) Enabled() bool {
+	return len(s.Rules) > 0
+}
+
+// SeverityFor returns the severity for a finding on a new path, or current if
+// strictness doesn't change it
+func (s Strictness) SeverityFor(rule, current string) string {
+	if severity, ok := s.Rules[rule]; ok {
+		return severity
+	}
+	if s.Rules[StrictnessAll] == SeverityError && current == SeverityWarn {
+		return SeverityError
+	}
+	return current
+}
+
+// validateStrictness checks severities and that rules have a Since to compare against
+func validateStrictness(s Strictness) error {
+	if s.Enabled() && s.Since == "" {
+		return fmt.Errorf("strictness.since is required when strictness.rules is set")
+	}
+	for rule, severity := range s.Rules {
+		switch severity {
+		case SeverityError, SeverityWarn, SeverityOff, SeverityIgnore:
+		default:
+			return fmt.Errorf("strictness.rules.%s: unknown severity %q (expected error, warn, ignore, or off)", rule, severity)
+		}
+	}
+	if severity, ok := s.Rules[StrictnessAll]; ok && severity != SeverityError {
+		return fmt.Errorf("strictness.rules.%s: only error is supported for all rules", StrictnessAll)
+	}
+	return nil
+}
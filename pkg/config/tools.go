@@ -0,0 +1,34 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/erauner/homelab-shadow/pkg/semver"
+)
+
+// ToolNames are the external tools whose versions .shadow.yaml can pin
+var ToolNames = []string{"git", "kustomize", "helm", "kubeconform", "kyverno"}
+
+// validateTools checks every pinned tool is known and its constraint parses
+func validateTools(tools map[string]string) error {
+	names := make([]string, 0, len(tools))
+	for name := range tools {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		known := false
+		for _, n := range ToolNames {
+			known = known || n == name
+		}
+		if !known {
+			return fmt.Errorf("tools: unknown tool %q (expected %s)", name, strings.Join(ToolNames, ", "))
+		}
+		if _, err := semver.ParseConstraint(tools[name]); err != nil {
+			return fmt.Errorf("tools.%s: %w", name, err)
+		}
+	}
+	return nil
+}
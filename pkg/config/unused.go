@@ -0,0 +1,52 @@
+package config
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Unused excludes ConfigMaps and Secrets from the unused config analysis,
+// for consumers it can't see in rendered manifests (sidecars loading
+// dashboards by label, controllers reading a Secret by name, ...)
+type Unused struct {
+	// Ignore skips matching resources: "<namespace>/<name>" or "<name>" globs
+	Ignore []string `yaml:"ignore"`
+
+	// Consumers marks resources carrying one of these labels or annotations as
+	// used: "key" or "key=value", e.g. ["grafana_dashboard=1", "k8s-sidecar-target-directory"]
+	Consumers []string `yaml:"consumers"`
+}
+
+// IgnoresResource reports whether a resource is excluded by name
+func (u Unused) IgnoresResource(namespace, name string) bool {
+	return matchResource(u.Ignore, namespace, name)
+}
+
+// Consumed reports whether labels or annotations match a configured consumer
+func (u Unused) Consumed(labels, annotations map[string]string) bool {
+	for _, consumer := range u.Consumers {
+		key, value, hasValue := strings.Cut(consumer, "=")
+		for _, m := range []map[string]string{labels, annotations} {
+			if v, ok := m[key]; ok && (!hasValue || v == value) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// validateUnused checks ignore patterns and consumers are well-formed
+func validateUnused(u Unused) error {
+	for _, pattern := range u.Ignore {
+		if _, err := path.Match(pattern, ""); err != nil || pattern == "" {
+			return fmt.Errorf("unused.ignore: invalid pattern %q", pattern)
+		}
+	}
+	for _, consumer := range u.Consumers {
+		if key, _, _ := strings.Cut(consumer, "="); key == "" {
+			return fmt.Errorf("unused.consumers: invalid consumer %q (expected key or key=value)", consumer)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,302 @@
+// Package doctor checks the environment shadow runs in: the external tools it
+// shells out to and their versions, the provider token, and access to the
+// shadow repository
+//
+// Every check carries a remediation, so a CI preflight (or a new contributor)
+// learns what to install or fix instead of hitting a render failure later.
+package doctor
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+
+	"github.com/erauner/homelab-shadow/pkg/command"
+	"github.com/erauner/homelab-shadow/pkg/helm"
+	"github.com/erauner/homelab-shadow/pkg/kustomize"
+	"github.com/erauner/homelab-shadow/pkg/kyverno"
+	"github.com/erauner/homelab-shadow/pkg/semver"
+	"github.com/erauner/homelab-shadow/pkg/sync"
+)
+
+// Status is the outcome of a check
+type Status string
+
+// Check outcomes; only StatusFail makes a report fail
+const (
+	StatusOK   Status = "ok"
+	StatusWarn Status = "warn"
+	StatusFail Status = "fail"
+	StatusSkip Status = "skip"
+)
+
+// Check is the result of one diagnostic
+type Check struct {
+	Name        string `json:"name"`
+	Status      Status `json:"status"`
+	Version     string `json:"version,omitempty"`
+	Pin         string `json:"pin,omitempty"` // version constraint from .shadow.yaml
+	Detail      string `json:"detail,omitempty"`
+	Remediation string `json:"remediation,omitempty"`
+}
+
+// Report is the result of every diagnostic
+type Report struct {
+	Checks []Check `json:"checks"`
+}
+
+// Failed returns the number of failed checks
+func (r Report) Failed() int {
+	n := 0
+	for _, c := range r.Checks {
+		if c.Status == StatusFail {
+			n++
+		}
+	}
+	return n
+}
+
+// Tool is an external command shadow runs
+type Tool struct {
+	Name       string
+	MinVersion string // oldest version shadow supports
+	Required   bool   // missing is a failure rather than a warning
+	Purpose    string // what needs it, for the remediation of an optional tool
+	Install    string // where to get it
+	Version    func() (string, error)
+}
+
+// Tools are the commands shadow shells out to, with the oldest versions
+// whose flags and output it relies on
+var Tools = []Tool{
+	{
+		Name:       "git",
+		MinVersion: "2.28.0",
+		Purpose:    "source repo diffs, hooks, signed commits, and --use-system-git",
+		Install:    "https://git-scm.com/downloads",
+		Version:    gitVersion,
+	},
+	{
+		Name:       "kustomize",
+		MinVersion: "5.0.0",
+		Required:   true,
+		Install:    "https://kubectl.docs.kubernetes.io/installation/kustomize/",
+		Version:    kustomize.KustomizeVersion,
+	},
+	{
+		Name:       "helm",
+		MinVersion: "3.8.0", // OCI registries without HELM_EXPERIMENTAL_OCI
+		Purpose:    "rendering Helm Applications and kustomize helmCharts",
+		Install:    "https://helm.sh/docs/intro/install/",
+		Version:    helm.HelmVersion,
+	},
+	{
+		Name:       "kubeconform",
+		MinVersion: "0.6.0",
+		Purpose:    "schema validation (--schema, sync --validate-schemas)",
+		Install:    "https://github.com/yannh/kubeconform#installation",
+		Version:    kustomize.KubeconformVersion,
+	},
+	{
+		Name:       "kyverno",
+		MinVersion: "1.10.0",
+		Purpose:    "policy tests and policy impact reports",
+		Install:    "https://kyverno.io/docs/kyverno-cli/install/",
+		Version:    kyverno.KyvernoVersion,
+	},
+}
+
+// gitVersion returns the installed git version
+func gitVersion() (string, error) {
+	output, err := command.Command("git", "version").CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("failed to get git version: %w", err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// CheckTool reports whether tool is on PATH at MinVersion or newer and, when
+// pin is set, within the pinned range
+func CheckTool(tool Tool, pin string) Check {
+	check := Check{Name: tool.Name, Pin: pin}
+	if _, err := exec.LookPath(tool.Name); err != nil {
+		check.Status = StatusWarn
+		check.Detail = "not found on PATH"
+		check.Remediation = fmt.Sprintf("install %s: %s", tool.Name, tool.Install)
+		switch {
+		case pin != "":
+			check.Status = StatusFail
+			check.Remediation = fmt.Sprintf("install %s %s (pinned in .shadow.yaml): %s", tool.Name, pin, tool.Install)
+		case tool.Required:
+			check.Status = StatusFail
+		default:
+			check.Remediation = fmt.Sprintf("install %s for %s: %s", tool.Name, tool.Purpose, tool.Install)
+		}
+		return check
+	}
+
+	output, err := tool.Version()
+	if err != nil {
+		check.Status = StatusFail
+		check.Detail = err.Error()
+		check.Remediation = fmt.Sprintf("check that %s runs; reinstall it from %s", tool.Name, tool.Install)
+		return check
+	}
+	version, ok := semver.Extract(output)
+	if !ok {
+		check.Status = StatusWarn
+		check.Detail = fmt.Sprintf("can't read a version from %q", output)
+		if pin != "" {
+			check.Status = StatusFail
+		}
+		return check
+	}
+	check.Version = version.String()
+	if min, err := semver.Parse(tool.MinVersion); err == nil && version.Compare(min) < 0 {
+		check.Status = StatusFail
+		check.Detail = fmt.Sprintf("%s or newer is required", tool.MinVersion)
+		check.Remediation = fmt.Sprintf("upgrade %s: %s", tool.Name, tool.Install)
+		return check
+	}
+	if pin != "" {
+		constraint, err := semver.ParseConstraint(pin)
+		if err != nil {
+			check.Status = StatusFail
+			check.Detail = err.Error()
+			return check
+		}
+		if !constraint.Allows(version) {
+			check.Status = StatusFail
+			check.Detail = fmt.Sprintf("pinned to %s", pin)
+			check.Remediation = fmt.Sprintf("install %s %s (pinned in .shadow.yaml): %s", tool.Name, pin, tool.Install)
+			return check
+		}
+	}
+	check.Status = StatusOK
+	return check
+}
+
+// CheckPins checks every tool pinned in pins (tool name to version constraint,
+// as in .shadow.yaml tools) except those in skip, and returns an error listing
+// each one that is missing or outside its range
+func CheckPins(pins map[string]string, skip ...string) error {
+	var mismatches []string
+	for _, tool := range Tools {
+		pin := pins[tool.Name]
+		if pin == "" || slices.Contains(skip, tool.Name) {
+			continue
+		}
+		check := CheckTool(tool, pin)
+		if check.Status == StatusOK {
+			continue
+		}
+		found := check.Version
+		if found == "" {
+			found = check.Detail
+		}
+		mismatches = append(mismatches, fmt.Sprintf("%s %s (requires %s)", tool.Name, found, pin))
+	}
+	if len(mismatches) > 0 {
+		return fmt.Errorf("tool versions don't match the tools pinned in .shadow.yaml: %s; install the pinned versions, pass --download-tools to fetch them, or pass --skip-version-check", strings.Join(mismatches, "; "))
+	}
+	return nil
+}
+
+// CheckToken reports whether the provider token for remote is set and valid
+// A missing token is a warning: dry runs and public repos work without one
+func CheckToken(remote sync.Remote) Check {
+	env := remote.TokenEnv()
+	check := Check{Name: env}
+	if os.Getenv(env) == "" {
+		check.Status = StatusWarn
+		check.Detail = "not set"
+		check.Remediation = fmt.Sprintf("export %s to push to the shadow repo, read PR state, and avoid API rate limits", env)
+		return check
+	}
+	user, err := remote.TokenUser(context.Background())
+	if err != nil {
+		check.Status = StatusFail
+		check.Detail = err.Error()
+		check.Remediation = fmt.Sprintf("%s was rejected by %s - it may be expired or revoked; create a new token", env, remote.Host)
+		return check
+	}
+	check.Status = StatusOK
+	check.Detail = fmt.Sprintf("authenticates as %s on %s", user, remote.Host)
+	return check
+}
+
+// CheckShadowRepo reports whether the provider token can push to remote
+func CheckShadowRepo(remote sync.Remote) Check {
+	check := Check{Name: "shadow repo"}
+	push, err := remote.CanPush(context.Background())
+	switch {
+	case err != nil:
+		check.Status = StatusFail
+		check.Detail = fmt.Sprintf("%s: %v", remote.Slug, err)
+		check.Remediation = fmt.Sprintf("check --shadow-repo and that %s can access %s; private repos a token can't see are reported as not found", remote.TokenEnv(), remote.Slug)
+	case !push:
+		check.Status = StatusFail
+		check.Detail = fmt.Sprintf("%s is read-only for this token", remote.Slug)
+		check.Remediation = fmt.Sprintf("grant %s write (contents) access to %s", remote.TokenEnv(), remote.Slug)
+	default:
+		check.Status = StatusOK
+		check.Detail = fmt.Sprintf("%s is writable", remote.Slug)
+	}
+	return check
+}
+
+// Options configures Run
+type Options struct {
+	Tools []Tool            // default: Tools
+	Pins  map[string]string // tool version constraints, from .shadow.yaml tools
+
+	// ShadowRepo (owner/repo or git URL) enables the token and repo access
+	// checks; without it only the token of Provider (default GitHub) is checked
+	ShadowRepo string
+	Provider   sync.Provider
+
+	// Offline skips the checks that call the provider API
+	Offline bool
+}
+
+// Run performs every check
+func Run(opts Options) (Report, error) {
+	var report Report
+	tools := opts.Tools
+	if tools == nil {
+		tools = Tools
+	}
+	for _, tool := range tools {
+		report.Checks = append(report.Checks, CheckTool(tool, opts.Pins[tool.Name]))
+	}
+	if opts.Offline {
+		return report, nil
+	}
+
+	repo := opts.ShadowRepo
+	if repo == "" {
+		repo = "owner/repo" // only the provider and host are used
+	}
+	remote, err := sync.ParseRemote(repo, opts.Provider)
+	if err != nil {
+		return report, fmt.Errorf("invalid shadow repo: %w", err)
+	}
+	token := CheckToken(remote)
+	report.Checks = append(report.Checks, token)
+	if opts.ShadowRepo == "" {
+		return report, nil
+	}
+	if token.Status != StatusOK {
+		report.Checks = append(report.Checks, Check{
+			Name:   "shadow repo",
+			Status: StatusSkip,
+			Detail: fmt.Sprintf("needs a valid %s", remote.TokenEnv()),
+		})
+		return report, nil
+	}
+	report.Checks = append(report.Checks, CheckShadowRepo(remote))
+	return report, nil
+}
@@ -0,0 +1,144 @@
+package doctor
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/erauner/homelab-shadow/pkg/config"
+)
+
+// fakeTool puts an executable named name on PATH in dir
+func fakeTool(t *testing.T, dir, name string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\nexit 0\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCheckTool(t *testing.T) {
+	bin := t.TempDir()
+	t.Setenv("PATH", bin)
+	fakeTool(t, bin, "kustomize")
+	fakeTool(t, bin, "helm")
+	fakeTool(t, bin, "broken")
+
+	version := func(out string) func() (string, error) {
+		return func() (string, error) { return out, nil }
+	}
+	tests := []struct {
+		tool    Tool
+		pin     string
+		status  Status
+		version string
+		detail  string
+	}{
+		{Tool{Name: "kustomize", MinVersion: "5.0.0", Version: version("v5.4.3")}, "", StatusOK, "5.4.3", ""},
+		{Tool{Name: "kustomize", MinVersion: "5.0.0", Version: version("v5.4.3")}, ">=5.3.0 <5.5.0", StatusOK, "5.4.3", ""},
+		{Tool{Name: "kustomize", MinVersion: "5.0.0", Version: version("v5.4.3")}, "5.3.x", StatusFail, "5.4.3", "pinned to 5.3.x"},
+		{Tool{Name: "helm", MinVersion: "3.8.0", Version: version("v3.7.2+g663a896")}, "", StatusFail, "3.7.2", "3.8.0 or newer"},
+		{Tool{Name: "kyverno", Purpose: "policy tests"}, "", StatusWarn, "", "not found"},
+		{Tool{Name: "kyverno", Purpose: "policy tests"}, "1.12.x", StatusFail, "", "not found"},
+		{Tool{Name: "git", Required: true}, "", StatusFail, "", "not found"},
+		{Tool{Name: "broken", Version: func() (string, error) { return "", errors.New("exit status 1") }}, "", StatusFail, "", "exit status 1"},
+	}
+	for _, tt := range tests {
+		got := CheckTool(tt.tool, tt.pin)
+		if got.Status != tt.status || got.Version != tt.version || !strings.Contains(got.Detail, tt.detail) {
+			t.Errorf("CheckTool(%s) = %+v, want %s %q %q", tt.tool.Name, got, tt.status, tt.version, tt.detail)
+		}
+		if got.Status != StatusOK && got.Remediation == "" {
+			t.Errorf("CheckTool(%s) has no remediation", tt.tool.Name)
+		}
+	}
+}
+
+func TestCheckPins(t *testing.T) {
+	bin := t.TempDir()
+	t.Setenv("PATH", bin)
+	script := "#!/bin/sh\necho v5.4.3\n"
+	if err := os.WriteFile(filepath.Join(bin, "kustomize"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := CheckPins(map[string]string{"kustomize": "5.4.x"}); err != nil {
+		t.Errorf("CheckPins() error = %v", err)
+	}
+	err := CheckPins(map[string]string{"kustomize": ">=5.5.0", "helm": "3.14.x"})
+	if err == nil {
+		t.Fatal("CheckPins() should fail")
+	}
+	for _, want := range []string{"kustomize 5.4.3 (requires >=5.5.0)", "helm not found on PATH (requires 3.14.x)", "--skip-version-check"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q should contain %q", err, want)
+		}
+	}
+	if err := CheckPins(map[string]string{"kustomize": ">=5.5.0"}, "kustomize"); err != nil {
+		t.Errorf("CheckPins() skipping kustomize error = %v", err)
+	}
+}
+
+func TestTools_CoverConfig(t *testing.T) {
+	for _, name := range config.ToolNames {
+		if !slices.ContainsFunc(Tools, func(tool Tool) bool { return tool.Name == name }) {
+			t.Errorf("config.ToolNames has %s, which doctor.Tools doesn't check", name)
+		}
+	}
+}
+
+func TestRun_Remote(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "token good" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/api/v3/user":
+			w.Write([]byte(`{"login": "shadow-bot"}`))
+		case "/api/v3/repos/owner/shadow":
+			w.Write([]byte(`{"permissions": {"push": true}}`))
+		case "/api/v3/repos/owner/readonly":
+			w.Write([]byte(`{"permissions": {"push": false}}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		token string
+		repo  string
+		want  []Status // token, shadow repo
+	}{
+		{"good", "owner/shadow", []Status{StatusOK, StatusOK}},
+		{"good", "owner/readonly", []Status{StatusOK, StatusFail}},
+		{"good", "owner/missing", []Status{StatusOK, StatusFail}},
+		{"expired", "owner/shadow", []Status{StatusFail, StatusSkip}},
+		{"", "owner/shadow", []Status{StatusWarn, StatusSkip}},
+	}
+	for _, tt := range tests {
+		t.Setenv("GH_TOKEN", tt.token)
+		report, err := Run(Options{Tools: []Tool{}, ShadowRepo: server.URL + "/" + tt.repo + ".git"})
+		if err != nil {
+			t.Fatalf("Run() error = %v", err)
+		}
+		var got []Status
+		for _, c := range report.Checks {
+			got = append(got, c.Status)
+		}
+		if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("token %q, repo %s: statuses = %v, want %v (%+v)", tt.token, tt.repo, got, tt.want, report.Checks)
+		}
+	}
+
+	report, err := Run(Options{Tools: []Tool{}, Offline: true})
+	if err != nil || len(report.Checks) != 0 {
+		t.Errorf("offline Run() = %+v, %v; want no checks", report, err)
+	}
+}
@@ -0,0 +1,343 @@
+// Package flux discovers Flux Kustomizations and HelmReleases and converts
+// them to ArgoCD Applications so they share the render and validate pipeline
+package flux
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/erauner/homelab-shadow/pkg/argocd"
+	"gopkg.in/yaml.v3"
+)
+
+// Flux API groups recognized by the parser
+const (
+	kustomizeGroup = "kustomize.toolkit.fluxcd.io/"
+	helmGroup      = "helm.toolkit.fluxcd.io/"
+	sourceGroup    = "source.toolkit.fluxcd.io/"
+)
+
+// Kinds of Flux resources converted to Applications
+const (
+	KindKustomization = "Kustomization"
+	KindHelmRelease   = "HelmRelease"
+)
+
+// SourceRef references a Flux source (GitRepository, HelmRepository, ...)
+type SourceRef struct {
+	Kind      string `yaml:"kind"`
+	Name      string `yaml:"name"`
+	Namespace string `yaml:"namespace"`
+}
+
+// Kustomization is a kustomize.toolkit.fluxcd.io Kustomization
+type Kustomization struct {
+	Name            string
+	Namespace       string
+	Path            string // spec.path, relative to the source root
+	TargetNamespace string
+	SourceRef       SourceRef
+	File            string // file the resource was found in
+}
+
+// HelmRelease is a helm.toolkit.fluxcd.io HelmRelease
+// valuesFrom references (ConfigMaps, Secrets) are not resolved
+type HelmRelease struct {
+	Name            string
+	Namespace       string
+	ReleaseName     string
+	TargetNamespace string
+	Chart           string
+	Version         string
+	SourceRef       SourceRef
+	Values          map[string]interface{}
+	File            string
+}
+
+// Source is a GitRepository, HelmRepository, or OCIRepository
+type Source struct {
+	Kind      string
+	Name      string
+	Namespace string
+	URL       string
+}
+
+// Resources are the Flux resources found in a repository
+type Resources struct {
+	Kustomizations []Kustomization
+	HelmReleases   []HelmRelease
+	Sources        []Source
+}
+
+// resourceYAML is the subset of Flux resource fields the parser reads
+type resourceYAML struct {
+	APIVersion string `yaml:"apiVersion"`
+	Kind       string `yaml:"kind"`
+	Metadata   struct {
+		Name      string `yaml:"name"`
+		Namespace string `yaml:"namespace"`
+	} `yaml:"metadata"`
+	Spec struct {
+		// Kustomization
+		Path            string    `yaml:"path"`
+		SourceRef       SourceRef `yaml:"sourceRef"`
+		TargetNamespace string    `yaml:"targetNamespace"`
+
+		// HelmRelease
+		ReleaseName string `yaml:"releaseName"`
+		Chart       struct {
+			Spec struct {
+				Chart     string    `yaml:"chart"`
+				Version   string    `yaml:"version"`
+				SourceRef SourceRef `yaml:"sourceRef"`
+			} `yaml:"spec"`
+		} `yaml:"chart"`
+		Values map[string]interface{} `yaml:"values"`
+
+		// Sources
+		URL string `yaml:"url"`
+	} `yaml:"spec"`
+}
+
+// Discover walks the repository for Flux resources
+// Hidden directories are skipped, as are files that fail to parse
+func Discover(rootPath string) (*Resources, error) {
+	resources := &Resources{}
+
+	err := filepath.WalkDir(rootPath, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != rootPath && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasSuffix(path, ".yaml") && !strings.HasSuffix(path, ".yml") {
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil || !bytes.Contains(data, []byte("toolkit.fluxcd.io/")) {
+			return nil
+		}
+		resources.parse(data, path) // malformed files are reported by other checks
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to walk %s: %w", rootPath, err)
+	}
+
+	return resources, nil
+}
+
+// parse adds the Flux resources in a multi-document file
+func (r *Resources) parse(data []byte, file string) error {
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	for {
+		var doc resourceYAML
+		if err := decoder.Decode(&doc); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return fmt.Errorf("failed to parse YAML: %w", err)
+		}
+		namespace := doc.Metadata.Namespace
+		if namespace == "" {
+			namespace = "flux-system"
+		}
+
+		switch {
+		case strings.HasPrefix(doc.APIVersion, kustomizeGroup) && doc.Kind == KindKustomization:
+			r.Kustomizations = append(r.Kustomizations, Kustomization{
+				Name:            doc.Metadata.Name,
+				Namespace:       namespace,
+				Path:            doc.Spec.Path,
+				TargetNamespace: doc.Spec.TargetNamespace,
+				SourceRef:       doc.Spec.SourceRef,
+				File:            file,
+			})
+		case strings.HasPrefix(doc.APIVersion, helmGroup) && doc.Kind == KindHelmRelease:
+			chart := doc.Spec.Chart.Spec
+			r.HelmReleases = append(r.HelmReleases, HelmRelease{
+				Name:            doc.Metadata.Name,
+				Namespace:       namespace,
+				ReleaseName:     doc.Spec.ReleaseName,
+				TargetNamespace: doc.Spec.TargetNamespace,
+				Chart:           chart.Chart,
+				Version:         chart.Version,
+				SourceRef:       chart.SourceRef,
+				Values:          doc.Spec.Values,
+				File:            file,
+			})
+		case strings.HasPrefix(doc.APIVersion, sourceGroup) && doc.Spec.URL != "":
+			r.Sources = append(r.Sources, Source{
+				Kind:      doc.Kind,
+				Name:      doc.Metadata.Name,
+				Namespace: namespace,
+				URL:       doc.Spec.URL,
+			})
+		}
+	}
+}
+
+// source resolves a sourceRef (defaulting to the referrer's namespace)
+func (r *Resources) source(ref SourceRef, namespace string) (Source, bool) {
+	if ref.Namespace != "" {
+		namespace = ref.Namespace
+	}
+	for _, s := range r.Sources {
+		if s.Kind == ref.Kind && s.Name == ref.Name && s.Namespace == namespace {
+			return s, true
+		}
+	}
+	return Source{}, false
+}
+
+// Applications converts Kustomizations and HelmReleases to Applications,
+// returned with the files they were found in
+//
+// A Kustomization becomes a path source; a HelmRelease with a HelmRepository
+// or OCIRepository chart becomes a Helm source with spec.values inlined.
+// HelmReleases whose chart comes from a GitRepository are skipped
+func (r *Resources) Applications(rootPath string) ([]*argocd.Application, []string) {
+	var apps []*argocd.Application
+	var files []string
+
+	for _, k := range r.Kustomizations {
+		source := argocd.Source{Path: cleanPath(k.Path)}
+		if s, ok := r.source(k.SourceRef, k.Namespace); ok {
+			source.RepoURL = s.URL
+		}
+		apps = append(apps, &argocd.Application{
+			Name:      k.Name,
+			Kind:      KindKustomization,
+			Namespace: k.TargetNamespace,
+			Source:    &source,
+			Cluster:   clusterFromFile(rootPath, k.File),
+		})
+		files = append(files, k.File)
+	}
+
+	for _, h := range r.HelmReleases {
+		if h.SourceRef.Kind == "GitRepository" {
+			continue
+		}
+		s, ok := r.source(h.SourceRef, h.Namespace)
+		if !ok {
+			continue
+		}
+
+		helmConfig := &argocd.HelmConfig{ReleaseName: h.ReleaseName}
+		if len(h.Values) > 0 {
+			values, err := yaml.Marshal(h.Values)
+			if err != nil {
+				continue
+			}
+			helmConfig.Values = string(values)
+		}
+		namespace := h.TargetNamespace
+		if namespace == "" {
+			namespace = h.Namespace
+		}
+
+		apps = append(apps, &argocd.Application{
+			Name:      h.Name,
+			Kind:      KindHelmRelease,
+			Namespace: namespace,
+			Source: &argocd.Source{
+				RepoURL:        s.URL,
+				Chart:          h.Chart,
+				TargetRevision: h.Version,
+				Helm:           helmConfig,
+			},
+			Cluster: clusterFromFile(rootPath, h.File),
+		})
+		files = append(files, h.File)
+	}
+
+	return apps, files
+}
+
+// LoadApplications discovers Flux resources and converts them to Applications
+func LoadApplications(rootPath string) ([]*argocd.Application, []string, error) {
+	resources, err := Discover(rootPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	apps, files := resources.Applications(rootPath)
+	return apps, files, nil
+}
+
+// LoadAllApplications returns ArgoCD Applications (including ApplicationSet
+// expansions) followed by Flux-derived ones, with their source files
+func LoadAllApplications(rootPath string) ([]*argocd.Application, []string, error) {
+	apps, files, err := argocd.LoadApplications(rootPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	fluxApps, fluxFiles, err := LoadApplications(rootPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	return append(apps, fluxApps...), append(files, fluxFiles...), nil
+}
+
+// DiscoverAllHelmApplications returns every ArgoCD and Flux Application with a Helm source
+func DiscoverAllHelmApplications(rootPath string) ([]*argocd.Application, error) {
+	apps, _, err := LoadAllApplications(rootPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var helmApps []*argocd.Application
+	for _, app := range apps {
+		if len(app.GetHelmSources()) > 0 {
+			helmApps = append(helmApps, app)
+		}
+	}
+	return helmApps, nil
+}
+
+// clusterFromFile returns <cluster> for files under clusters/<cluster>/ (the
+// Flux bootstrap layout), or ""
+func clusterFromFile(rootPath, file string) string {
+	rel, err := filepath.Rel(rootPath, file)
+	if err != nil {
+		return ""
+	}
+	parts := strings.Split(filepath.ToSlash(rel), "/")
+	if len(parts) > 2 && parts[0] == "clusters" {
+		return parts[1]
+	}
+	return ""
+}
+
+// cleanPath normalizes a Flux spec.path ("./apps/x/" -> "apps/x")
+func cleanPath(p string) string {
+	if p == "" {
+		return "."
+	}
+	return filepath.ToSlash(filepath.Clean(strings.TrimPrefix(p, "./")))
+}
+
+// FindApplication locates an ArgoCD Application or Flux Kustomization/HelmRelease
+// by name, ArgoCD first
+func FindApplication(rootPath, name string) (*argocd.Application, string, error) {
+	apps, files, err := LoadAllApplications(rootPath)
+	if err != nil {
+		return nil, "", err
+	}
+	for i, app := range apps {
+		if app.Name == name {
+			return app, files[i], nil
+		}
+	}
+	return nil, "", fmt.Errorf("application not found: %s", name)
+}
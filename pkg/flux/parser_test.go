@@ -0,0 +1,157 @@
+package flux
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const fluxSync = `apiVersion: source.toolkit.fluxcd.io/v1
+kind: GitRepository
+metadata:
+  name: flux-system
+  namespace: flux-system
+spec:
+  url: ssh://git@github.com/erauner/homelab-k8s
+---
+apiVersion: kustomize.toolkit.fluxcd.io/v1
+kind: Kustomization
+metadata:
+  name: apps
+  namespace: flux-system
+spec:
+  path: ./apps/coder/overlays/erauner-home/production
+  targetNamespace: coder
+  sourceRef:
+    kind: GitRepository
+    name: flux-system
+`
+
+const fluxHelm = `apiVersion: source.toolkit.fluxcd.io/v1
+kind: HelmRepository
+metadata:
+  name: podinfo
+  namespace: flux-system
+spec:
+  url: https://stefanprodan.github.io/podinfo
+---
+apiVersion: helm.toolkit.fluxcd.io/v2
+kind: HelmRelease
+metadata:
+  name: podinfo
+  namespace: apps
+spec:
+  releaseName: web
+  chart:
+    spec:
+      chart: podinfo
+      version: 6.5.0
+      sourceRef:
+        kind: HelmRepository
+        name: podinfo
+        namespace: flux-system
+  values:
+    replicaCount: 2
+---
+apiVersion: helm.toolkit.fluxcd.io/v2
+kind: HelmRelease
+metadata:
+  name: local-chart
+  namespace: apps
+spec:
+  chart:
+    spec:
+      chart: ./charts/local
+      sourceRef:
+        kind: GitRepository
+        name: flux-system
+        namespace: flux-system
+`
+
+func writeFile(t *testing.T, root, rel, content string) {
+	t.Helper()
+	path := filepath.Join(root, filepath.FromSlash(rel))
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadApplications(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, root, "clusters/erauner-home/flux-system/gotk-sync.yaml", fluxSync)
+	writeFile(t, root, "infrastructure/podinfo/release.yaml", fluxHelm)
+	writeFile(t, root, "apps/coder/base/deployment.yaml", "apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: coder\n")
+	writeFile(t, root, ".git/flux.yaml", fluxSync)
+
+	apps, files, err := LoadApplications(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(apps) != 2 || len(files) != 2 {
+		t.Fatalf("got %d apps, want 2 (GitRepository charts and hidden dirs skipped)", len(apps))
+	}
+
+	k := apps[0]
+	if k.KindName() != "Kustomization apps" || k.Namespace != "coder" || k.Cluster != "erauner-home" {
+		t.Errorf("Kustomization = %+v", k)
+	}
+	if k.Source.Path != "apps/coder/overlays/erauner-home/production" || k.Source.RepoURL != "ssh://git@github.com/erauner/homelab-k8s" {
+		t.Errorf("Kustomization source = %+v", k.Source)
+	}
+	if len(k.GetKustomizeSources()) != 1 {
+		t.Errorf("expected a kustomize source")
+	}
+
+	h := apps[1]
+	if h.KindName() != "HelmRelease podinfo" || h.Namespace != "apps" || h.Cluster != "" {
+		t.Errorf("HelmRelease = %+v", h)
+	}
+	helmSources := h.GetHelmSources()
+	if len(helmSources) != 1 {
+		t.Fatalf("expected a Helm source, got %+v", h.Source)
+	}
+	s := helmSources[0]
+	if s.RepoURL != "https://stefanprodan.github.io/podinfo" || s.Chart != "podinfo" || s.TargetRevision != "6.5.0" {
+		t.Errorf("Helm source = %+v", s)
+	}
+	if s.Helm.ReleaseName != "web" || !strings.Contains(s.Helm.Values, "replicaCount: 2") {
+		t.Errorf("Helm config = %+v", s.Helm)
+	}
+	if !strings.HasSuffix(filepath.ToSlash(files[1]), "infrastructure/podinfo/release.yaml") {
+		t.Errorf("file = %s", files[1])
+	}
+}
+
+func TestFindApplication(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, root, "argocd-apps/applications/coder.yaml", `apiVersion: argoproj.io/v1alpha1
+kind: Application
+metadata:
+  name: coder
+spec:
+  source:
+    path: apps/coder/overlays/erauner-home/production
+`)
+	writeFile(t, root, "clusters/erauner-home/apps.yaml", fluxHelm)
+
+	app, _, err := FindApplication(root, "coder")
+	if err != nil || app.Kind != "" {
+		t.Errorf("FindApplication(coder) = %+v, %v", app, err)
+	}
+	app, _, err = FindApplication(root, "podinfo")
+	if err != nil || app.Kind != KindHelmRelease {
+		t.Errorf("FindApplication(podinfo) = %+v, %v", app, err)
+	}
+	if _, _, err := FindApplication(root, "missing"); err == nil {
+		t.Error("expected error for unknown name")
+	}
+
+	helmApps, err := DiscoverAllHelmApplications(root)
+	if err != nil || len(helmApps) != 1 || helmApps[0].Name != "podinfo" {
+		t.Errorf("DiscoverAllHelmApplications = %v, %v", helmApps, err)
+	}
+}
@@ -0,0 +1,203 @@
+// Package github is a minimal client for the parts of the GitHub REST API
+// shadow reports to: check runs on the source repository's commits
+package github
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// DefaultAPIURL is the GitHub REST API base URL
+const DefaultAPIURL = "https://api.github.com"
+
+// TokenEnv is the environment variable the token is read from
+const TokenEnv = "GH_TOKEN"
+
+// MaxAnnotations is the number of annotations GitHub accepts per request;
+// CreateCheckRun sends the rest in follow-up updates
+const MaxAnnotations = 50
+
+// MaxSummary is the longest output summary GitHub accepts
+const MaxSummary = 65535
+
+// Check run conclusions
+const (
+	ConclusionSuccess = "success"
+	ConclusionFailure = "failure"
+	ConclusionNeutral = "neutral"
+)
+
+// Annotation levels
+const (
+	AnnotationNotice  = "notice"
+	AnnotationWarning = "warning"
+	AnnotationFailure = "failure"
+)
+
+// Client calls the GitHub REST API
+type Client struct {
+	APIURL     string // default: DefaultAPIURL
+	Token      string
+	HTTPClient *http.Client
+}
+
+// NewClient returns a client authenticated with the token in GH_TOKEN
+func NewClient() (*Client, error) {
+	token := os.Getenv(TokenEnv)
+	if token == "" {
+		return nil, fmt.Errorf("%s is not set", TokenEnv)
+	}
+	return &Client{
+		APIURL:     DefaultAPIURL,
+		Token:      token,
+		HTTPClient: &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+// CheckRun is a check run to create on a commit. Status defaults to
+// completed, which requires a Conclusion
+type CheckRun struct {
+	Name       string       `json:"name"`
+	HeadSHA    string       `json:"head_sha"`
+	Status     string       `json:"status,omitempty"`
+	Conclusion string       `json:"conclusion,omitempty"`
+	DetailsURL string       `json:"details_url,omitempty"`
+	Output     *CheckOutput `json:"output,omitempty"`
+}
+
+// CheckOutput is the title, markdown summary, and file annotations of a check run
+type CheckOutput struct {
+	Title       string       `json:"title"`
+	Summary     string       `json:"summary"`
+	Annotations []Annotation `json:"annotations,omitempty"`
+}
+
+// Annotation marks lines of a file in the commit
+type Annotation struct {
+	Path            string `json:"path"`
+	StartLine       int    `json:"start_line"`
+	EndLine         int    `json:"end_line"`
+	AnnotationLevel string `json:"annotation_level"`
+	Title           string `json:"title,omitempty"`
+	Message         string `json:"message"`
+}
+
+// APIError is a non-2xx GitHub API response
+type APIError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("GitHub API returned %d: %s", e.StatusCode, e.Message)
+}
+
+// CheckRunRef identifies a created check run
+type CheckRunRef struct {
+	ID      int64  `json:"id"`
+	HTMLURL string `json:"html_url"`
+}
+
+// CreateCheckRun creates a check run on repo (owner/repo). Annotations beyond
+// MaxAnnotations are added by updating the run, and a summary over MaxSummary
+// is truncated. Only GitHub App installation tokens (such as a GitHub Actions
+// GITHUB_TOKEN with checks: write) may create check runs
+func (c *Client) CreateCheckRun(ctx context.Context, repo string, run CheckRun) (*CheckRunRef, error) {
+	if run.Status == "" {
+		run.Status = "completed"
+	}
+	var rest []Annotation
+	if run.Output != nil {
+		output := *run.Output
+		output.Summary = truncateSummary(output.Summary)
+		if len(output.Annotations) > MaxAnnotations {
+			output.Annotations, rest = output.Annotations[:MaxAnnotations], output.Annotations[MaxAnnotations:]
+		}
+		run.Output = &output
+	}
+
+	var ref CheckRunRef
+	if err := c.do(ctx, http.MethodPost, fmt.Sprintf("/repos/%s/check-runs", repo), run, &ref); err != nil {
+		var apiErr *APIError
+		if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusForbidden {
+			return nil, fmt.Errorf("failed to create check run: %w (check runs can only be created with a GitHub App installation token, such as a GitHub Actions GITHUB_TOKEN with checks: write; personal access tokens are rejected)", err)
+		}
+		return nil, fmt.Errorf("failed to create check run: %w", err)
+	}
+
+	for len(rest) > 0 {
+		batch := rest
+		if len(batch) > MaxAnnotations {
+			batch = batch[:MaxAnnotations]
+		}
+		rest = rest[len(batch):]
+		update := struct {
+			Output CheckOutput `json:"output"`
+		}{CheckOutput{Title: run.Output.Title, Summary: run.Output.Summary, Annotations: batch}}
+		if err := c.do(ctx, http.MethodPatch, fmt.Sprintf("/repos/%s/check-runs/%d", repo, ref.ID), update, nil); err != nil {
+			return &ref, fmt.Errorf("failed to add annotations to check run %d: %w", ref.ID, err)
+		}
+	}
+	return &ref, nil
+}
+
+// do sends body as JSON and decodes a 2xx response into v (when non-nil)
+func (c *Client) do(ctx context.Context, method, path string, body, v interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	apiURL := c.APIURL
+	if apiURL == "" {
+		apiURL = DefaultAPIURL
+	}
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimSuffix(apiURL, "/")+path, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "shadow-sync")
+	if c.Token != "" {
+		req.Header.Set("Authorization", "token "+c.Token)
+	}
+
+	client := c.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return &APIError{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(msg))}
+	}
+	if v == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// truncateSummary cuts a summary to MaxSummary bytes, on a line boundary
+func truncateSummary(summary string) string {
+	const note = "\n\n_Summary truncated._\n"
+	if len(summary) <= MaxSummary {
+		return summary
+	}
+	cut := summary[:MaxSummary-len(note)]
+	if i := strings.LastIndex(cut, "\n"); i > 0 {
+		cut = cut[:i]
+	}
+	return cut + note
+}
@@ -0,0 +1,99 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCreateCheckRun(t *testing.T) {
+	type request struct {
+		method, path, auth string
+		body               map[string]interface{}
+	}
+	var requests []request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("invalid body: %v", err)
+		}
+		requests = append(requests, request{r.Method, r.URL.Path, r.Header.Get("Authorization"), body})
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprint(w, `{"id": 42, "html_url": "https://github.com/erauner/homelab-k8s/runs/42"}`)
+	}))
+	defer server.Close()
+
+	var annotations []Annotation
+	for i := 0; i < MaxAnnotations+3; i++ {
+		annotations = append(annotations, Annotation{Path: "apps/a/kustomization.yaml", StartLine: 1, EndLine: 1, AnnotationLevel: AnnotationFailure, Message: "broken"})
+	}
+	client := &Client{APIURL: server.URL, Token: "secret"}
+	ref, err := client.CreateCheckRun(context.Background(), "erauner/homelab-k8s", CheckRun{
+		Name:       "shadow/diff",
+		HeadSHA:    "abc123",
+		Conclusion: ConclusionFailure,
+		DetailsURL: "https://github.com/erauner/homelab-k8s-shadow/compare/main...pr-1",
+		Output:     &CheckOutput{Title: "1 failed", Summary: "## Shadow Sync", Annotations: annotations},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ref.ID != 42 {
+		t.Errorf("ID = %d, want 42", ref.ID)
+	}
+
+	if len(requests) != 2 {
+		t.Fatalf("got %d requests, want create and one update", len(requests))
+	}
+	create, update := requests[0], requests[1]
+	if create.method != http.MethodPost || create.path != "/repos/erauner/homelab-k8s/check-runs" {
+		t.Errorf("create = %s %s", create.method, create.path)
+	}
+	if create.auth != "token secret" {
+		t.Errorf("Authorization = %q", create.auth)
+	}
+	if create.body["status"] != "completed" || create.body["head_sha"] != "abc123" || create.body["details_url"] == nil {
+		t.Errorf("unexpected create body: %v", create.body)
+	}
+	if n := len(create.body["output"].(map[string]interface{})["annotations"].([]interface{})); n != MaxAnnotations {
+		t.Errorf("create sent %d annotations, want %d", n, MaxAnnotations)
+	}
+	if update.method != http.MethodPatch || update.path != "/repos/erauner/homelab-k8s/check-runs/42" {
+		t.Errorf("update = %s %s", update.method, update.path)
+	}
+	if n := len(update.body["output"].(map[string]interface{})["annotations"].([]interface{})); n != 3 {
+		t.Errorf("update sent %d annotations, want 3", n)
+	}
+}
+
+func TestCreateCheckRun_Error(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		fmt.Fprint(w, `{"message": "Resource not accessible by integration"}`)
+	}))
+	defer server.Close()
+
+	client := &Client{APIURL: server.URL}
+	_, err := client.CreateCheckRun(context.Background(), "erauner/homelab-k8s", CheckRun{Name: "shadow/diff", HeadSHA: "abc123", Conclusion: ConclusionSuccess})
+	if err == nil || !strings.Contains(err.Error(), "403") || !strings.Contains(err.Error(), "not accessible") {
+		t.Errorf("err = %v, want the status and message", err)
+	}
+	if err == nil || !strings.Contains(err.Error(), "GitHub App installation token") {
+		t.Errorf("err = %v, want the installation token hint", err)
+	}
+}
+
+func TestTruncateSummary(t *testing.T) {
+	if got := truncateSummary("short"); got != "short" {
+		t.Errorf("short summary changed: %q", got)
+	}
+	long := strings.Repeat("| row |\n", MaxSummary/8+10)
+	got := truncateSummary(long)
+	if len(got) > MaxSummary || !strings.HasSuffix(got, "_Summary truncated._\n") {
+		t.Errorf("truncated to %d bytes, suffix %q", len(got), got[len(got)-30:])
+	}
+}
@@ -0,0 +1,56 @@
+package graph
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// DOT renders the graph in Graphviz DOT format, left to right: Applications
+// are ellipses, directories boxes, and charts components
+func (g *Graph) DOT() string {
+	var b strings.Builder
+	b.WriteString("digraph shadow {\n")
+	b.WriteString("  rankdir=LR;\n")
+	b.WriteString("  node [shape=box];\n")
+	for _, n := range g.Nodes {
+		attrs := "label=" + strconv.Quote(n.Label)
+		switch n.Kind {
+		case KindApplication:
+			attrs += ", shape=ellipse"
+		case KindChart:
+			attrs += ", shape=component"
+		}
+		fmt.Fprintf(&b, "  %s [%s];\n", strconv.Quote(n.ID), attrs)
+	}
+	for _, e := range g.Edges {
+		fmt.Fprintf(&b, "  %s -> %s [label=%s];\n", strconv.Quote(e.From), strconv.Quote(e.To), strconv.Quote(e.Label))
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// Mermaid renders the graph as a Mermaid flowchart, left to right, with the
+// same shapes as DOT (stadium, rectangle, subroutine)
+func (g *Graph) Mermaid() string {
+	ids := make(map[string]string, len(g.Nodes))
+	var b strings.Builder
+	b.WriteString("flowchart LR\n")
+	for i, n := range g.Nodes {
+		id := fmt.Sprintf("n%d", i)
+		ids[n.ID] = id
+		label := `"` + strings.ReplaceAll(n.Label, `"`, "#quot;") + `"`
+		switch n.Kind {
+		case KindApplication:
+			fmt.Fprintf(&b, "  %s([%s])\n", id, label)
+		case KindChart:
+			fmt.Fprintf(&b, "  %s[[%s]]\n", id, label)
+		default:
+			fmt.Fprintf(&b, "  %s[%s]\n", id, label)
+		}
+	}
+	for _, e := range g.Edges {
+		fmt.Fprintf(&b, "  %s -->|%s| %s\n", ids[e.From], e.Label, ids[e.To])
+	}
+	return b.String()
+}
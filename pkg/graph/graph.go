@@ -0,0 +1,319 @@
+// Package graph builds the dependency graph from Applications through
+// kustomization references to the Helm charts they render
+package graph
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/erauner/homelab-shadow/pkg/argocd"
+	"github.com/erauner/homelab-shadow/pkg/flux"
+	"github.com/erauner/homelab-shadow/pkg/sync"
+	"gopkg.in/yaml.v3"
+)
+
+// Node kinds
+const (
+	KindApplication = "application"
+	KindPath        = "path"
+	KindChart       = "chart"
+)
+
+// Edge labels
+const (
+	EdgeSource     = "source"     // Application source path or chart
+	EdgeResources  = "resources"  // kustomization resources (and deprecated bases)
+	EdgeComponents = "components" // kustomization components
+	EdgeHelmChart  = "helmCharts" // kustomization helmCharts entry
+)
+
+// Node is an Application, a repo directory, or a Helm chart
+type Node struct {
+	ID    string `json:"id"`
+	Kind  string `json:"kind"`
+	Label string `json:"label"`
+}
+
+// Edge is a reference from one node to another
+type Edge struct {
+	From  string `json:"from"`
+	To    string `json:"to"`
+	Label string `json:"label"`
+}
+
+// Graph is a dependency graph with nodes and edges sorted by ID
+type Graph struct {
+	Nodes []Node `json:"nodes"`
+	Edges []Edge `json:"edges"`
+}
+
+// Options filters the Applications a graph starts from
+type Options struct {
+	Cluster string // destination cluster, or the cluster of a source path
+	App     string // Application name, or the app of an apps/<app>/ source path
+}
+
+// kustomization holds the references of a kustomization file
+type kustomization struct {
+	Resources  []string `yaml:"resources"`
+	Bases      []string `yaml:"bases"`
+	Components []string `yaml:"components"`
+	HelmCharts []struct {
+		Name    string `yaml:"name"`
+		Repo    string `yaml:"repo"`
+		Version string `yaml:"version"`
+	} `yaml:"helmCharts"`
+}
+
+// builder accumulates nodes and edges, deduplicated
+type builder struct {
+	repoPath string
+	nodes    map[string]Node
+	edges    map[Edge]bool
+	visited  map[string]bool
+}
+
+// Build walks every Application (ArgoCD, ApplicationSet-generated, and Flux)
+// matching opts, through its source paths and the local directories their
+// kustomizations reference, to the Helm charts rendered along the way.
+// Remote kustomize resources and source paths outside the repo are not followed
+func Build(repoPath string, opts Options) (*Graph, error) {
+	apps, _, err := flux.LoadAllApplications(repoPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load Applications: %w", err)
+	}
+
+	b := &builder{
+		repoPath: repoPath,
+		nodes:    make(map[string]Node),
+		edges:    make(map[Edge]bool),
+		visited:  make(map[string]bool),
+	}
+	for _, app := range apps {
+		if !matches(app, opts) {
+			continue
+		}
+		label := app.KindName()
+		if app.Cluster != "" {
+			label += " (" + app.Cluster + ")"
+		}
+		id := "app:" + app.KindName() + "@" + app.Cluster
+		b.addNode(Node{ID: id, Kind: KindApplication, Label: label})
+
+		for _, source := range app.AllSources() {
+			switch {
+			case source.IsHelmSource():
+				b.addEdge(id, b.chartNode(source.RepoURL, source.Chart, source.TargetRevision), EdgeSource)
+			case source.Path != "":
+				dir := cleanPath(source.Path)
+				if !b.isDir(dir) {
+					continue
+				}
+				b.addEdge(id, b.pathNode(dir), EdgeSource)
+				if err := b.walk(dir); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	return b.graph(), nil
+}
+
+// matches reports whether an Application passes the filters
+func matches(app *argocd.Application, opts Options) bool {
+	var paths []string
+	for _, source := range app.AllSources() {
+		if source.Path != "" {
+			paths = append(paths, cleanPath(source.Path))
+		}
+	}
+
+	if opts.Cluster != "" && app.Cluster != opts.Cluster {
+		found := false
+		for _, p := range paths {
+			if sync.ClusterForDirectory(p) == opts.Cluster {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if opts.App != "" && app.Name != opts.App {
+		for _, p := range paths {
+			if strings.HasPrefix(p+"/", "apps/"+opts.App+"/") {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// walk adds the references of the kustomization in dir, recursively
+func (b *builder) walk(dir string) error {
+	if b.visited[dir] {
+		return nil
+	}
+	b.visited[dir] = true
+
+	data, err := readKustomization(filepath.Join(b.repoPath, filepath.FromSlash(dir)))
+	if err != nil {
+		return nil // plain manifests directory
+	}
+	var k kustomization
+	if err := yaml.Unmarshal(data, &k); err != nil {
+		return fmt.Errorf("failed to parse kustomization in %s: %w", dir, err)
+	}
+
+	from := "path:" + dir
+	for _, group := range []struct {
+		label string
+		refs  []string
+	}{
+		{EdgeResources, append(append([]string(nil), k.Resources...), k.Bases...)},
+		{EdgeComponents, k.Components},
+	} {
+		for _, ref := range group.refs {
+			if strings.Contains(ref, "://") || strings.HasPrefix(ref, "github.com/") {
+				continue // remote resource
+			}
+			target := cleanPath(filepath.ToSlash(filepath.Join(dir, ref)))
+			if strings.HasPrefix(target, "../") || !b.isDir(target) {
+				continue // files and paths outside the repo
+			}
+			b.addEdge(from, b.pathNode(target), group.label)
+			if err := b.walk(target); err != nil {
+				return err
+			}
+		}
+	}
+	for _, chart := range k.HelmCharts {
+		b.addEdge(from, b.chartNode(chart.Repo, chart.Name, chart.Version), EdgeHelmChart)
+	}
+	return nil
+}
+
+func (b *builder) pathNode(dir string) string {
+	id := "path:" + dir
+	b.addNode(Node{ID: id, Kind: KindPath, Label: dir})
+	return id
+}
+
+func (b *builder) chartNode(repo, chart, version string) string {
+	id := "chart:" + strings.TrimSuffix(repo, "/") + "/" + chart + "@" + version
+	label := chart
+	if version != "" {
+		label += "@" + version
+	}
+	b.addNode(Node{ID: id, Kind: KindChart, Label: label})
+	return id
+}
+
+func (b *builder) addNode(n Node) {
+	b.nodes[n.ID] = n
+}
+
+func (b *builder) addEdge(from, to, label string) {
+	b.edges[Edge{From: from, To: to, Label: label}] = true
+}
+
+func (b *builder) isDir(dir string) bool {
+	info, err := os.Stat(filepath.Join(b.repoPath, filepath.FromSlash(dir)))
+	return err == nil && info.IsDir()
+}
+
+// graph returns the accumulated nodes and edges in a stable order
+func (b *builder) graph() *Graph {
+	g := &Graph{Nodes: []Node{}, Edges: []Edge{}}
+	for _, n := range b.nodes {
+		g.Nodes = append(g.Nodes, n)
+	}
+	for e := range b.edges {
+		g.Edges = append(g.Edges, e)
+	}
+	g.sort()
+	return g
+}
+
+func (g *Graph) sort() {
+	sort.Slice(g.Nodes, func(i, j int) bool { return g.Nodes[i].ID < g.Nodes[j].ID })
+	sort.Slice(g.Edges, func(i, j int) bool {
+		if g.Edges[i].From != g.Edges[j].From {
+			return g.Edges[i].From < g.Edges[j].From
+		}
+		if g.Edges[i].To != g.Edges[j].To {
+			return g.Edges[i].To < g.Edges[j].To
+		}
+		return g.Edges[i].Label < g.Edges[j].Label
+	})
+}
+
+// Focus returns the subgraph of everything that depends on dir (its blast
+// radius, up to the Applications deploying it) and everything dir references
+func (g *Graph) Focus(dir string) (*Graph, error) {
+	id := "path:" + cleanPath(dir)
+	found := false
+	for _, n := range g.Nodes {
+		if n.ID == id {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("%s is not referenced by any matching Application", dir)
+	}
+
+	forward := make(map[string][]string)
+	reverse := make(map[string][]string)
+	for _, e := range g.Edges {
+		forward[e.From] = append(forward[e.From], e.To)
+		reverse[e.To] = append(reverse[e.To], e.From)
+	}
+	keep := make(map[string]bool)
+	for _, adjacency := range []map[string][]string{forward, reverse} {
+		queue := []string{id}
+		for len(queue) > 0 {
+			n := queue[0]
+			queue = queue[1:]
+			keep[n] = true
+			for _, next := range adjacency[n] {
+				if !keep[next] {
+					queue = append(queue, next)
+				}
+			}
+		}
+	}
+
+	sub := &Graph{Nodes: []Node{}, Edges: []Edge{}}
+	for _, n := range g.Nodes {
+		if keep[n.ID] {
+			sub.Nodes = append(sub.Nodes, n)
+		}
+	}
+	for _, e := range g.Edges {
+		if keep[e.From] && keep[e.To] {
+			sub.Edges = append(sub.Edges, e)
+		}
+	}
+	return sub, nil
+}
+
+// readKustomization reads the kustomization file in dir
+func readKustomization(dir string) ([]byte, error) {
+	for _, name := range []string{"kustomization.yaml", "kustomization.yml", "Kustomization"} {
+		if data, err := os.ReadFile(filepath.Join(dir, name)); err == nil {
+			return data, nil
+		}
+	}
+	return nil, fmt.Errorf("no kustomization in %s", dir)
+}
+
+// cleanPath normalizes a repo-relative path ("./apps/x/" -> "apps/x")
+func cleanPath(p string) string {
+	return filepath.ToSlash(filepath.Clean(strings.TrimPrefix(p, "./")))
+}
@@ -0,0 +1,182 @@
+package graph
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func writeRepo(t *testing.T, files map[string]string) string {
+	t.Helper()
+	repo := t.TempDir()
+	for rel, content := range files {
+		path := filepath.Join(repo, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return repo
+}
+
+func testRepo(t *testing.T) string {
+	return writeRepo(t, map[string]string{
+		"argocd-apps/applications/coder.yaml": `apiVersion: argoproj.io/v1alpha1
+kind: Application
+metadata:
+  name: coder
+spec:
+  destination:
+    name: erauner-home
+  source:
+    repoURL: https://github.com/erauner/homelab-k8s
+    path: apps/coder/overlays/erauner-home/production
+`,
+		"argocd-apps/applications/docs.yaml": `apiVersion: argoproj.io/v1alpha1
+kind: Application
+metadata:
+  name: docs
+spec:
+  destination:
+    name: erauner-cloud
+  sources:
+  - repoURL: https://github.com/erauner/homelab-k8s
+    path: ./apps/docs/overlays/erauner-cloud/production/
+  - repoURL: https://charts.bitnami.com/bitnami
+    chart: redis
+    targetRevision: 19.0.0
+`,
+		"apps/coder/overlays/erauner-home/production/kustomization.yaml": "resources:\n- ../../../base\n- https://github.com/example/remote//deploy\ncomponents:\n- ../../../../../kustomize/components/labels\n",
+		"apps/coder/base/kustomization.yaml":                             "resources:\n- deployment.yaml\nhelmCharts:\n- name: coder\n  repo: https://helm.coder.com/v2\n  version: 2.16.0\n",
+		"apps/coder/base/deployment.yaml":                                "kind: Deployment\n",
+		"apps/docs/overlays/erauner-cloud/production/kustomization.yaml": "components:\n- ../../../../../kustomize/components/labels\n",
+		"kustomize/components/labels/kustomization.yaml":                 "kind: Component\n",
+	})
+}
+
+func TestBuild(t *testing.T) {
+	g, err := Build(testRepo(t), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var edges []string
+	for _, e := range g.Edges {
+		edges = append(edges, e.From+" -"+e.Label+"-> "+e.To)
+	}
+	want := []string{
+		"app:Application coder@erauner-home -source-> path:apps/coder/overlays/erauner-home/production",
+		"app:Application docs@erauner-cloud -source-> chart:https://charts.bitnami.com/bitnami/redis@19.0.0",
+		"app:Application docs@erauner-cloud -source-> path:apps/docs/overlays/erauner-cloud/production",
+		"path:apps/coder/base -helmCharts-> chart:https://helm.coder.com/v2/coder@2.16.0",
+		"path:apps/coder/overlays/erauner-home/production -resources-> path:apps/coder/base",
+		"path:apps/coder/overlays/erauner-home/production -components-> path:kustomize/components/labels",
+		"path:apps/docs/overlays/erauner-cloud/production -components-> path:kustomize/components/labels",
+	}
+	if !reflect.DeepEqual(edges, want) {
+		t.Errorf("edges:\n%s\nwant:\n%s", strings.Join(edges, "\n"), strings.Join(want, "\n"))
+	}
+	if len(g.Nodes) != 8 {
+		t.Errorf("expected 8 nodes, got %+v", g.Nodes)
+	}
+}
+
+func TestBuild_Filters(t *testing.T) {
+	repo := testRepo(t)
+	for _, opts := range []Options{{Cluster: "erauner-cloud"}, {App: "docs"}} {
+		g, err := Build(repo, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, n := range g.Nodes {
+			if strings.Contains(n.ID, "coder") {
+				t.Errorf("%+v: unexpected node %s", opts, n.ID)
+			}
+		}
+		if len(g.Nodes) != 4 {
+			t.Errorf("%+v: expected 4 nodes, got %+v", opts, g.Nodes)
+		}
+	}
+}
+
+func TestFocus(t *testing.T) {
+	g, err := Build(testRepo(t), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sub, err := g.Focus("./apps/coder/base/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, n := range sub.Nodes {
+		ids = append(ids, n.ID)
+	}
+	want := []string{
+		"app:Application coder@erauner-home",
+		"chart:https://helm.coder.com/v2/coder@2.16.0",
+		"path:apps/coder/base",
+		"path:apps/coder/overlays/erauner-home/production",
+	}
+	if !reflect.DeepEqual(ids, want) {
+		t.Errorf("nodes = %v, want %v", ids, want)
+	}
+	if len(sub.Edges) != 3 {
+		t.Errorf("expected 3 edges, got %+v", sub.Edges)
+	}
+
+	// A shared component's blast radius spans both Applications
+	sub, err = g.Focus("kustomize/components/labels")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if apps := strings.Count(sub.Mermaid(), "(["); apps != 2 {
+		t.Errorf("expected 2 Applications depending on the component, got %d:\n%s", apps, sub.Mermaid())
+	}
+
+	if _, err := g.Focus("apps/missing"); err == nil {
+		t.Error("expected an error for an unreferenced path")
+	}
+}
+
+func TestFormats(t *testing.T) {
+	g := &Graph{
+		Nodes: []Node{
+			{ID: "app:Application web@home", Kind: KindApplication, Label: "Application web (home)"},
+			{ID: "chart:repo/web@1.0", Kind: KindChart, Label: `web "1.0"`},
+			{ID: "path:apps/web", Kind: KindPath, Label: "apps/web"},
+		},
+		Edges: []Edge{
+			{From: "app:Application web@home", To: "path:apps/web", Label: EdgeSource},
+			{From: "path:apps/web", To: "chart:repo/web@1.0", Label: EdgeHelmChart},
+		},
+	}
+
+	dot := g.DOT()
+	for _, line := range []string{
+		`"app:Application web@home" [label="Application web (home)", shape=ellipse];`,
+		`"chart:repo/web@1.0" [label="web \"1.0\"", shape=component];`,
+		`"path:apps/web" [label="apps/web"];`,
+		`"path:apps/web" -> "chart:repo/web@1.0" [label="helmCharts"];`,
+	} {
+		if !strings.Contains(dot, line) {
+			t.Errorf("DOT missing %s:\n%s", line, dot)
+		}
+	}
+
+	want := `flowchart LR
+  n0(["Application web (home)"])
+  n1[["web #quot;1.0#quot;"]]
+  n2["apps/web"]
+  n0 -->|source| n2
+  n2 -->|helmCharts| n1
+`
+	if got := g.Mermaid(); got != want {
+		t.Errorf("Mermaid:\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -0,0 +1,131 @@
+package helm
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/erauner/homelab-shadow/pkg/cachedir"
+	"github.com/erauner/homelab-shadow/pkg/command"
+	"github.com/erauner/homelab-shadow/pkg/log"
+)
+
+// exactVersionPattern matches pinned chart versions (1.2.3, v1.2.3, 1.2.3-rc.1)
+// Ranges like "1.x", "^1.2", or "*" resolve differently over time and are never cached
+var exactVersionPattern = regexp.MustCompile(`^v?\d+\.\d+\.\d+([-+][0-9A-Za-z.+-]+)?$`)
+
+// ChartCache stores downloaded chart archives keyed by repo/chart/version
+// so repeated helm template calls don't re-download the tarball
+type ChartCache struct {
+	Dir string
+	Log *log.Logger // cache hits and misses are logged at debug level
+
+	// Credential authenticates pulls from private repositories (nil: anonymous)
+	Credential *Credential
+}
+
+// NewChartCache creates a chart cache rooted at dir
+func NewChartCache(dir string, verbose bool) *ChartCache {
+	return &ChartCache{
+		Dir: dir,
+		Log: log.Default().Named("helm").Verbose(verbose),
+	}
+}
+
+// DefaultCacheDir returns the default chart cache directory
+func DefaultCacheDir() string {
+	return cachedir.Path("charts")
+}
+
+// IsCacheableVersion returns true if version is an exact (pinned) chart version
+func IsCacheableVersion(version string) bool {
+	return exactVersionPattern.MatchString(version)
+}
+
+// chartDir returns the cache directory for a repo/chart/version tuple
+// The repo URL is hashed to keep paths short and filesystem-safe
+func (c *ChartCache) chartDir(repoURL, chart, version string) string {
+	sum := sha256.Sum256([]byte(repoURL + "\n" + chart))
+	key := hex.EncodeToString(sum[:])[:16]
+	name := filepath.Base(strings.TrimSuffix(chart, "/"))
+	return filepath.Join(c.Dir, key, name, version)
+}
+
+// Lookup returns the cached chart archive path if present
+func (c *ChartCache) Lookup(repoURL, chart, version string) (string, bool) {
+	matches, err := filepath.Glob(filepath.Join(c.chartDir(repoURL, chart, version), "*.tgz"))
+	if err != nil || len(matches) == 0 {
+		return "", false
+	}
+	return matches[0], true
+}
+
+// Fetch returns the cached chart archive, pulling it with helm pull on a miss
+// chart may be a plain chart name (with repoURL) or a full oci:// reference (without repoURL)
+func (c *ChartCache) Fetch(repoURL, chart, version string) (string, error) {
+	return c.FetchContext(context.Background(), repoURL, chart, version)
+}
+
+// FetchContext is Fetch, killing helm pull when ctx is done
+func (c *ChartCache) FetchContext(ctx context.Context, repoURL, chart, version string) (string, error) {
+	if !IsCacheableVersion(version) {
+		return "", fmt.Errorf("version %q is not an exact version", version)
+	}
+
+	if path, ok := c.Lookup(repoURL, chart, version); ok {
+		c.Log.Debugf("cache hit: %s@%s", chart, version)
+		return path, nil
+	}
+
+	dest := c.chartDir(repoURL, chart, version)
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return "", fmt.Errorf("failed to create cache directory: %w", err)
+	}
+
+	// Pull into a temp dir and rename so concurrent runs never see partial downloads
+	tmpDir, err := os.MkdirTemp(filepath.Dir(dest), ".pull-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	args := []string{"pull", chart, "--version", version, "--destination", tmpDir}
+	if repoURL != "" {
+		args = append(args, "--repo", repoURL)
+	}
+	auth, cleanup, err := authArgs(ctx, c.Credential, repoURL, chart)
+	if err != nil {
+		return "", err
+	}
+	defer cleanup()
+	args = append(args, auth...)
+
+	c.Log.Debugf("cache miss: %s", redactCommand("helm "+strings.Join(args, " "), c.Credential))
+
+	cmd := command.CommandContext(ctx, "helm", args...)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		if command.IsTimeout(err) {
+			return "", err
+		}
+		return "", fmt.Errorf("helm pull failed: %w\nOutput: %s", err, string(output))
+	}
+
+	if err := os.Rename(tmpDir, dest); err != nil {
+		// Another process may have populated the cache first
+		if path, ok := c.Lookup(repoURL, chart, version); ok {
+			return path, nil
+		}
+		return "", fmt.Errorf("failed to store chart in cache: %w", err)
+	}
+
+	path, ok := c.Lookup(repoURL, chart, version)
+	if !ok {
+		return "", fmt.Errorf("helm pull produced no chart archive for %s@%s", chart, version)
+	}
+	return path, nil
+}
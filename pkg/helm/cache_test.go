@@ -0,0 +1,96 @@
+package helm
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestIsCacheableVersion(t *testing.T) {
+	tests := []struct {
+		version string
+		want    bool
+	}{
+		{"4.5.0", true},
+		{"v1.2.3", true},
+		{"1.2.3-rc.1", true},
+		{"1.2.3+build.5", true},
+		{"", false},
+		{"1.x", false},
+		{"^1.2.0", false},
+		{"~1.2", false},
+		{"*", false},
+		{">=1.0.0 <2.0.0", false},
+		{"main", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.version, func(t *testing.T) {
+			if got := IsCacheableVersion(tt.version); got != tt.want {
+				t.Errorf("IsCacheableVersion(%q) = %v, want %v", tt.version, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestChartCache_ChartDir(t *testing.T) {
+	cache := NewChartCache("/cache", false)
+
+	a := cache.chartDir("https://charts.example.com", "app", "1.0.0")
+	b := cache.chartDir("https://charts.example.com", "app", "1.0.0")
+	if a != b {
+		t.Errorf("chartDir not deterministic: %q vs %q", a, b)
+	}
+
+	other := cache.chartDir("https://other.example.com", "app", "1.0.0")
+	if a == other {
+		t.Errorf("expected different repos to map to different dirs, both got %q", a)
+	}
+
+	oci := cache.chartDir("", "oci://ghcr.io/org/charts/app", "1.0.0")
+	if !strings.HasSuffix(oci, filepath.Join("app", "1.0.0")) {
+		t.Errorf("expected OCI chart dir to end with app/1.0.0, got %q", oci)
+	}
+}
+
+func TestChartCache_Lookup(t *testing.T) {
+	cache := NewChartCache(t.TempDir(), false)
+
+	if _, ok := cache.Lookup("https://charts.example.com", "app", "1.0.0"); ok {
+		t.Fatal("expected cache miss on empty cache")
+	}
+
+	dir := cache.chartDir("https://charts.example.com", "app", "1.0.0")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatalf("failed to create cache dir: %v", err)
+	}
+	archive := filepath.Join(dir, "app-1.0.0.tgz")
+	if err := os.WriteFile(archive, []byte("fake"), 0644); err != nil {
+		t.Fatalf("failed to write archive: %v", err)
+	}
+
+	path, ok := cache.Lookup("https://charts.example.com", "app", "1.0.0")
+	if !ok {
+		t.Fatal("expected cache hit after populating")
+	}
+	if path != archive {
+		t.Errorf("Lookup() = %q, want %q", path, archive)
+	}
+
+	// Fetch returns the cached archive without invoking helm
+	fetched, err := cache.Fetch("https://charts.example.com", "app", "1.0.0")
+	if err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+	if fetched != archive {
+		t.Errorf("Fetch() = %q, want %q", fetched, archive)
+	}
+}
+
+func TestChartCache_FetchRejectsRanges(t *testing.T) {
+	cache := NewChartCache(t.TempDir(), false)
+	if _, err := cache.Fetch("https://charts.example.com", "app", "1.x"); err == nil {
+		t.Error("expected Fetch to reject non-exact versions")
+	}
+}
@@ -0,0 +1,173 @@
+package helm
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/erauner/homelab-shadow/pkg/command"
+	"gopkg.in/yaml.v3"
+)
+
+// Environment variables holding credentials for every repository without a
+// more specific match (HelmRepoURLEnv narrows them to one URL prefix)
+const (
+	HelmUsernameEnv = "SHADOW_HELM_USERNAME"
+	HelmPasswordEnv = "SHADOW_HELM_PASSWORD"
+	HelmRepoURLEnv  = "SHADOW_HELM_REPO_URL"
+)
+
+// Credential authenticates to Helm repositories and OCI registries under URL
+type Credential struct {
+	// URL is a repository URL or prefix: https://charts.example.com,
+	// oci://ghcr.io/org, or ghcr.io/org ("" matches every repository)
+	URL      string `yaml:"url"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+
+	// UsernameEnv and PasswordEnv read the values from environment variables,
+	// keeping secrets out of the credentials file
+	UsernameEnv string `yaml:"usernameEnv"`
+	PasswordEnv string `yaml:"passwordEnv"`
+
+	// Source describes where the credential came from, for logs
+	Source string `yaml:"-"`
+}
+
+// credentialsFile is the format of a --creds-file
+type credentialsFile struct {
+	Credentials []Credential `yaml:"credentials"`
+}
+
+// LoadCredentialsFile reads credentials from a YAML file:
+//
+//	credentials:
+//	  - url: https://charts.example.com
+//	    username: ci
+//	    passwordEnv: CHARTS_TOKEN
+func LoadCredentialsFile(path string) ([]Credential, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read credentials file: %w", err)
+	}
+	var file credentialsFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse credentials file %s: %w", path, err)
+	}
+	for i := range file.Credentials {
+		c := &file.Credentials[i]
+		if c.UsernameEnv != "" {
+			c.Username = os.Getenv(c.UsernameEnv)
+		}
+		if c.PasswordEnv != "" {
+			c.Password = os.Getenv(c.PasswordEnv)
+		}
+		c.Source = path
+	}
+	return file.Credentials, nil
+}
+
+// CredentialsFromEnv returns the credential set by SHADOW_HELM_USERNAME and
+// SHADOW_HELM_PASSWORD, if any
+func CredentialsFromEnv() []Credential {
+	username, password := os.Getenv(HelmUsernameEnv), os.Getenv(HelmPasswordEnv)
+	if username == "" && password == "" {
+		return nil
+	}
+	return []Credential{{URL: os.Getenv(HelmRepoURLEnv), Username: username, Password: password, Source: "environment"}}
+}
+
+// CredentialStore resolves the credential for a repository URL the way ArgoCD
+// matches repo-creds: the longest URL prefix wins, ties going to the
+// credential added first
+type CredentialStore struct {
+	creds []Credential
+}
+
+// Add appends credentials; ones without a username or password are ignored
+func (s *CredentialStore) Add(creds ...Credential) {
+	for _, c := range creds {
+		if c.Username != "" || c.Password != "" {
+			s.creds = append(s.creds, c)
+		}
+	}
+}
+
+// Len returns the number of credentials in the store
+func (s *CredentialStore) Len() int {
+	if s == nil {
+		return 0
+	}
+	return len(s.creds)
+}
+
+// Lookup returns the credential for repoURL, or nil. A nil store has none
+func (s *CredentialStore) Lookup(repoURL string) *Credential {
+	if s == nil {
+		return nil
+	}
+	target := normalizeCredentialURL(repoURL)
+	var best *Credential
+	longest := -1
+	for i := range s.creds {
+		prefix := normalizeCredentialURL(s.creds[i].URL)
+		if prefix != "" && target != prefix && !strings.HasPrefix(target, prefix+"/") {
+			continue
+		}
+		if len(prefix) > longest {
+			best, longest = &s.creds[i], len(prefix)
+		}
+	}
+	return best
+}
+
+// normalizeCredentialURL drops the scheme and trailing slashes so
+// oci://ghcr.io/org, https://ghcr.io/org/, and ghcr.io/org compare equal
+func normalizeCredentialURL(url string) string {
+	url = strings.TrimSpace(url)
+	if _, rest, ok := strings.Cut(url, "://"); ok {
+		url = rest
+	}
+	return strings.ToLower(strings.TrimSuffix(url, "/"))
+}
+
+// authArgs returns the helm flags that authenticate a pull of chart: --username
+// and --password for a chart repository, or a temporary --registry-config
+// logged in to the chart's OCI registry. cleanup must be called when done
+func authArgs(ctx context.Context, cred *Credential, repoURL, chart string) (args []string, cleanup func(), err error) {
+	cleanup = func() {}
+	if cred == nil {
+		return nil, cleanup, nil
+	}
+	if repoURL != "" || !strings.HasPrefix(chart, "oci://") {
+		return []string{"--username", cred.Username, "--password", cred.Password}, cleanup, nil
+	}
+
+	// OCI registries need a login; keep it out of the user's registry config
+	dir, err := os.MkdirTemp("", "shadow-helm-registry-*")
+	if err != nil {
+		return nil, cleanup, fmt.Errorf("failed to create registry config: %w", err)
+	}
+	cleanup = func() { os.RemoveAll(dir) }
+	config := filepath.Join(dir, "config.json")
+
+	host, _, _ := strings.Cut(strings.TrimPrefix(chart, "oci://"), "/")
+	login := command.CommandContext(ctx, "helm", "registry", "login", host,
+		"--username", cred.Username, "--password-stdin", "--registry-config", config)
+	login.Stdin = strings.NewReader(cred.Password)
+	if output, err := login.CombinedOutput(); err != nil {
+		cleanup()
+		return nil, func() {}, fmt.Errorf("helm registry login %s failed: %w\nOutput: %s", host, err, string(output))
+	}
+	return []string{"--registry-config", config}, cleanup, nil
+}
+
+// redactCommand hides a credential's password in a command line shown to users
+func redactCommand(command string, cred *Credential) string {
+	if cred == nil || cred.Password == "" {
+		return command
+	}
+	return strings.ReplaceAll(command, cred.Password, "<redacted>")
+}
@@ -0,0 +1,116 @@
+package helm
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCredentialStore_Lookup(t *testing.T) {
+	store := &CredentialStore{}
+	store.Add(
+		Credential{URL: "oci://ghcr.io/erauner", Username: "org", Password: "org-token", Source: "file"},
+		Credential{URL: "https://ghcr.io/erauner/charts/", Username: "charts", Password: "charts-token"},
+		Credential{URL: "oci://ghcr.io/erauner", Username: "shadowed", Password: "x"},
+		Credential{URL: "https://charts.example.com", Username: "anonymous"},
+		Credential{Username: "fallback", Password: "env"},
+		Credential{URL: "https://empty.example.com"},
+	)
+	if store.Len() != 5 {
+		t.Errorf("expected credentials without username or password to be dropped, got %d", store.Len())
+	}
+
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"ghcr.io/erauner", "org"},                  // implicit OCI, first of two equal prefixes
+		{"oci://ghcr.io/erauner/charts", "charts"},  // longest prefix
+		{"oci://ghcr.io/erauner-other", "fallback"}, // prefixes match whole segments
+		{"https://charts.example.com/", "anonymous"},
+		{"https://empty.example.com", "fallback"},
+	}
+	for _, tt := range tests {
+		got := store.Lookup(tt.url)
+		if got == nil || got.Username != tt.want {
+			t.Errorf("Lookup(%q) = %+v, want %s", tt.url, got, tt.want)
+		}
+	}
+	if (*CredentialStore)(nil).Lookup("https://charts.example.com") != nil {
+		t.Error("expected a nil store to have no credentials")
+	}
+}
+
+func TestLoadCredentialsFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "creds.yaml")
+	data := "credentials:\n  - url: https://charts.example.com\n    username: ci\n    passwordEnv: TEST_CHARTS_TOKEN\n"
+	if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("TEST_CHARTS_TOKEN", "s3cret")
+
+	creds, err := LoadCredentialsFile(path)
+	if err != nil {
+		t.Fatalf("LoadCredentialsFile() error = %v", err)
+	}
+	if len(creds) != 1 || creds[0].Username != "ci" || creds[0].Password != "s3cret" || creds[0].Source != path {
+		t.Errorf("unexpected credentials %+v", creds)
+	}
+
+	t.Setenv(HelmUsernameEnv, "env-user")
+	t.Setenv(HelmPasswordEnv, "env-pass")
+	t.Setenv(HelmRepoURLEnv, "")
+	if env := CredentialsFromEnv(); len(env) != 1 || env[0].URL != "" || env[0].Password != "env-pass" {
+		t.Errorf("unexpected environment credentials %+v", env)
+	}
+}
+
+func TestTemplate_Credentials(t *testing.T) {
+	// A fake helm that logs its arguments (and stdin for registry login)
+	bin := t.TempDir()
+	logFile := filepath.Join(t.TempDir(), "helm.log")
+	script := "#!/bin/sh\necho \"$@\" | tee -a " + logFile + "\nif [ \"$1\" = registry ]; then cat >> " + logFile + "; echo >> " + logFile + "; fi\n"
+	if err := os.WriteFile(filepath.Join(bin, "helm"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+	cred := &Credential{Username: "ci", Password: "s3cret"}
+
+	result := Template(TemplateOptions{
+		ReleaseName: "private",
+		RepoURL:     "https://charts.example.com",
+		Chart:       "private",
+		Version:     "1.0.0",
+		Credential:  cred,
+	})
+	if !result.Passed {
+		t.Fatalf("Template failed: %v", result.Error)
+	}
+	if !strings.Contains(result.Output, "--username ci --password s3cret") {
+		t.Errorf("expected repository credentials in arguments, got: %s", result.Output)
+	}
+	if strings.Contains(result.Command, "s3cret") || !strings.Contains(result.Command, "--password <redacted>") {
+		t.Errorf("expected the password to be redacted from the command, got: %s", result.Command)
+	}
+
+	result = Template(TemplateOptions{
+		ReleaseName: "private",
+		Chart:       "oci://ghcr.io/erauner/charts/private",
+		Version:     "1.0.0",
+		Credential:  cred,
+	})
+	if !result.Passed {
+		t.Fatalf("Template failed: %v", result.Error)
+	}
+	data, err := os.ReadFile(logFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "registry login ghcr.io --username ci --password-stdin --registry-config") || !strings.Contains(string(data), "\ns3cret\n") {
+		t.Errorf("expected a registry login with the password on stdin, got:\n%s", data)
+	}
+	if !strings.Contains(result.Output, "--registry-config") || strings.Contains(result.Output, "--password") {
+		t.Errorf("expected OCI templates to use the registry config, got: %s", result.Output)
+	}
+}
@@ -0,0 +1,119 @@
+package helm
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// indexTimeout bounds a chart repository index download
+const indexTimeout = 30 * time.Second
+
+// repoIndex is the part of a chart repository's index.yaml listing versions
+type repoIndex struct {
+	Entries map[string][]struct {
+		Version string `yaml:"version"`
+	} `yaml:"entries"`
+}
+
+// ChartVersions lists the versions of chart, newest first: from a chart
+// repository's index.yaml, or the semver tags of an oci:// chart reference
+// (with an empty repoURL)
+func ChartVersions(repoURL, chart string, cred *Credential) ([]string, error) {
+	if repoURL == "" && strings.HasPrefix(chart, "oci://") {
+		return ociVersions(chart, cred)
+	}
+
+	url := strings.TrimSuffix(repoURL, "/") + "/index.yaml"
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid repository URL %s: %w", repoURL, err)
+	}
+	if cred != nil {
+		req.SetBasicAuth(cred.Username, cred.Password)
+	}
+	resp, err := (&http.Client{Timeout: indexTimeout}).Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch %s: %s", url, resp.Status)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", url, err)
+	}
+
+	var index repoIndex
+	if err := yaml.Unmarshal(data, &index); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", url, err)
+	}
+	entries, ok := index.Entries[chart]
+	if !ok {
+		return nil, fmt.Errorf("chart %s not found in %s", chart, url)
+	}
+	versions := make([]string, 0, len(entries))
+	for _, e := range entries {
+		versions = append(versions, e.Version)
+	}
+	sort.SliceStable(versions, func(i, j int) bool { return CompareVersions(versions[i], versions[j]) > 0 })
+	return versions, nil
+}
+
+// LatestVersion returns the newest release of chart in its chart repository
+// or, for an oci:// chart reference (with an empty repoURL), its registry.
+// Prereleases are skipped unless prerelease is set or there is nothing else
+func LatestVersion(repoURL, chart string, cred *Credential, prerelease bool) (string, error) {
+	versions, err := ChartVersions(repoURL, chart, cred)
+	if err != nil {
+		return "", err
+	}
+	if len(versions) == 0 {
+		return "", fmt.Errorf("no versions of %s found", chart)
+	}
+	if !prerelease {
+		for _, v := range versions {
+			if !isPrerelease(v) {
+				return v, nil
+			}
+		}
+	}
+	return versions[0], nil
+}
+
+// isPrerelease reports whether a version has a prerelease suffix (1.2.3-rc.1)
+func isPrerelease(version string) bool {
+	core, _, _ := strings.Cut(strings.TrimPrefix(version, "v"), "+")
+	return strings.Contains(core, "-")
+}
+
+// CompareVersions orders chart versions by semver, returning -1, 0, or 1;
+// a prerelease sorts before its release, and unparsable versions before all others
+func CompareVersions(a, b string) int {
+	va, _, errA := parseSemver(a)
+	vb, _, errB := parseSemver(b)
+	switch {
+	case errA != nil && errB != nil:
+		return strings.Compare(a, b)
+	case errA != nil:
+		return -1
+	case errB != nil:
+		return 1
+	}
+	if c := compareSemver(va, vb); c != 0 {
+		return c
+	}
+	switch pa, pb := isPrerelease(a), isPrerelease(b); {
+	case pa && !pb:
+		return -1
+	case !pa && pb:
+		return 1
+	}
+	return strings.Compare(a, b)
+}
@@ -0,0 +1,114 @@
+package helm
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testIndex = `apiVersion: v1
+entries:
+  web:
+  - version: 1.9.0
+  - version: 1.10.0
+  - version: 2.0.0-rc.1
+  - version: 1.2.0
+  other:
+  - version: 9.0.0
+`
+
+func TestChartVersions(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "bot" || pass != "hunter2" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Path != "/charts/index.yaml" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(testIndex))
+	}))
+	defer server.Close()
+
+	cred := &Credential{Username: "bot", Password: "hunter2"}
+	versions, err := ChartVersions(server.URL+"/charts/", "web", cred)
+	if err != nil {
+		t.Fatalf("ChartVersions() error = %v", err)
+	}
+	if want := []string{"2.0.0-rc.1", "1.10.0", "1.9.0", "1.2.0"}; !reflect.DeepEqual(versions, want) {
+		t.Errorf("ChartVersions() = %v, want %v", versions, want)
+	}
+
+	if v, err := LatestVersion(server.URL+"/charts", "web", cred, false); err != nil || v != "1.10.0" {
+		t.Errorf("LatestVersion() = %q, %v; want 1.10.0", v, err)
+	}
+	if v, err := LatestVersion(server.URL+"/charts", "web", cred, true); err != nil || v != "2.0.0-rc.1" {
+		t.Errorf("LatestVersion(prerelease) = %q, %v; want 2.0.0-rc.1", v, err)
+	}
+	if _, err := ChartVersions(server.URL+"/charts", "missing", cred); err == nil {
+		t.Error("expected an error for a chart missing from the index")
+	}
+	if _, err := ChartVersions(server.URL+"/charts", "web", nil); err == nil {
+		t.Error("expected an error without credentials")
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"1.10.0", "1.9.0", 1},
+		{"2.0.0-rc.1", "2.0.0", -1},
+		{"2.0.0-rc.1", "1.9.9", 1},
+		{"not-a-version", "0.0.1", -1},
+		{"1.2.3", "1.2.3", 0},
+	}
+	for _, tt := range tests {
+		if got := CompareVersions(tt.a, tt.b); got != tt.want {
+			t.Errorf("CompareVersions(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestChartVersions_OCI(t *testing.T) {
+	// The registry asks for a bearer token from its token endpoint, like ghcr.io
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/token":
+			if r.URL.Query().Get("scope") != "repository:charts/web:pull" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			w.Write([]byte(`{"token":"anonymous"}`))
+		case "/v2/charts/web/tags/list":
+			if r.Header.Get("Authorization") != "Bearer anonymous" {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="`+server.URL+`/token",service="test",scope="repository:charts/web:pull"`)
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.Write([]byte(`{"name":"charts/web","tags":["latest","1.0.0","1.1.0_build.2","sha256-abc.sig","2.0.0-rc.1"]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	ociScheme = "http"
+	t.Cleanup(func() { ociScheme = "https" })
+
+	chart := "oci://" + strings.TrimPrefix(server.URL, "http://") + "/charts/web"
+	versions, err := ChartVersions("", chart, nil)
+	if err != nil {
+		t.Fatalf("ChartVersions() error = %v", err)
+	}
+	if want := []string{"2.0.0-rc.1", "1.1.0+build.2", "1.0.0"}; !reflect.DeepEqual(versions, want) {
+		t.Errorf("ChartVersions() = %v, want %v", versions, want)
+	}
+	if v, err := LatestVersion("", chart, nil, false); err != nil || v != "1.1.0+build.2" {
+		t.Errorf("LatestVersion() = %q, %v", v, err)
+	}
+}
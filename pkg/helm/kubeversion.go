@@ -0,0 +1,220 @@
+package helm
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ChartMetadata is the subset of Chart.yaml shadow inspects
+type ChartMetadata struct {
+	Name        string `yaml:"name"`
+	Version     string `yaml:"version"`
+	KubeVersion string `yaml:"kubeVersion"`
+}
+
+// ReadChartMetadata reads the top-level Chart.yaml from a chart archive
+func ReadChartMetadata(archive string) (*ChartMetadata, error) {
+	f, err := os.Open(archive)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open chart archive: %w", err)
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read chart archive: %w", err)
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("no Chart.yaml in %s", archive)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read chart archive: %w", err)
+		}
+
+		// Charts are packaged as <name>/Chart.yaml; subcharts live deeper
+		parts := strings.Split(strings.TrimPrefix(hdr.Name, "./"), "/")
+		if len(parts) != 2 || parts[1] != "Chart.yaml" {
+			continue
+		}
+
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read Chart.yaml: %w", err)
+		}
+		var meta ChartMetadata
+		if err := yaml.Unmarshal(data, &meta); err != nil {
+			return nil, fmt.Errorf("failed to parse Chart.yaml: %w", err)
+		}
+		return &meta, nil
+	}
+}
+
+// KubeVersionSatisfies reports whether a Kubernetes version meets a chart's
+// kubeVersion constraint (e.g. ">=1.21.0-0 <1.30.0", "^1.25", "~1.28 || >=1.30")
+// An empty constraint accepts every version
+func KubeVersionSatisfies(constraint, version string) (bool, error) {
+	v, _, err := parseSemver(version)
+	if err != nil {
+		return false, fmt.Errorf("invalid Kubernetes version %q: %w", version, err)
+	}
+	if strings.TrimSpace(constraint) == "" {
+		return true, nil
+	}
+
+	ok, err := satisfiesConstraint(constraint, v)
+	if err != nil {
+		return false, fmt.Errorf("invalid kubeVersion constraint %q: %w", constraint, err)
+	}
+	return ok, nil
+}
+
+// satisfiesConstraint checks v against "||"-separated comparator groups
+func satisfiesConstraint(constraint string, v [3]int) (bool, error) {
+	for _, alt := range strings.Split(constraint, "||") {
+		ok, err := satisfiesAll(strings.TrimSpace(alt), v)
+		if err != nil {
+			return false, err
+		}
+		if ok {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// satisfiesAll checks v against space or comma separated comparators
+func satisfiesAll(group string, v [3]int) (bool, error) {
+	// Hyphen ranges: "1.20 - 1.28" means >=1.20 <=1.28
+	if lo, hi, ok := strings.Cut(group, " - "); ok {
+		group = ">=" + strings.TrimSpace(lo) + " <=" + strings.TrimSpace(hi)
+	}
+
+	// Join operators separated from their version (">= 1.21")
+	fields := strings.FieldsFunc(group, func(r rune) bool { return r == ' ' || r == ',' })
+	var comparators []string
+	for i := 0; i < len(fields); i++ {
+		field := fields[i]
+		if strings.Trim(field, "<>=!~^") == "" && i+1 < len(fields) {
+			field += fields[i+1]
+			i++
+		}
+		comparators = append(comparators, field)
+	}
+
+	for _, c := range comparators {
+		ok, err := satisfies(c, v)
+		if err != nil || !ok {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// satisfies checks v against a single comparator such as ">=1.21.0-0"
+func satisfies(comparator string, v [3]int) (bool, error) {
+	rest := strings.TrimLeft(comparator, "<>=!~^")
+	op := comparator[:len(comparator)-len(rest)]
+	bound, parts, err := parseSemver(rest)
+	if err != nil {
+		return false, err
+	}
+
+	cmp := compareSemver(v, bound)
+	switch op {
+	case "", "=":
+		if parts == 3 {
+			return cmp == 0, nil
+		}
+		// Partial versions and wildcards match the whole range: 1.28 = 1.28.x
+		return cmp >= 0 && compareSemver(v, bumpSemver(bound, parts)) < 0, nil
+	case "!=":
+		return cmp != 0, nil
+	case ">":
+		return cmp > 0, nil
+	case ">=":
+		return cmp >= 0, nil
+	case "<":
+		return cmp < 0, nil
+	case "<=":
+		return cmp <= 0, nil
+	case "~":
+		// ~1.2.3 and ~1.2 allow patch updates; ~1 allows minor updates
+		return cmp >= 0 && compareSemver(v, bumpSemver(bound, min(parts, 2))) < 0, nil
+	case "^":
+		// ^1.2.3 allows minor updates; ^0.2.3 only patch updates
+		upper := bumpSemver(bound, 1)
+		if bound[0] == 0 {
+			upper = bumpSemver(bound, 2)
+		}
+		return cmp >= 0 && compareSemver(v, upper) < 0, nil
+	default:
+		return false, fmt.Errorf("unknown operator %q", op)
+	}
+}
+
+// parseSemver parses "v1.28.3-0" into [1 28 3], ignoring prerelease and build
+// suffixes, and returns how many leading parts were given (wildcards end the count)
+func parseSemver(s string) ([3]int, int, error) {
+	var v [3]int
+	s = strings.TrimPrefix(strings.TrimSpace(s), "v")
+	if i := strings.IndexAny(s, "-+"); i >= 0 {
+		s = s[:i]
+	}
+	if s == "" || s == "*" || s == "x" || s == "X" {
+		return v, 0, nil
+	}
+
+	fields := strings.Split(s, ".")
+	if len(fields) > 3 {
+		return v, 0, fmt.Errorf("too many version parts in %q", s)
+	}
+	for i, field := range fields {
+		if field == "x" || field == "X" || field == "*" {
+			return v, i, nil
+		}
+		n, err := strconv.Atoi(field)
+		if err != nil {
+			return v, 0, fmt.Errorf("invalid version %q", s)
+		}
+		v[i] = n
+	}
+	return v, len(fields), nil
+}
+
+// bumpSemver returns the smallest version above every version sharing the
+// first parts components of v (bumpSemver(1.28.0, 2) = 1.29.0)
+func bumpSemver(v [3]int, parts int) [3]int {
+	if parts == 0 {
+		return [3]int{1 << 30, 0, 0}
+	}
+	var out [3]int
+	copy(out[:parts], v[:parts])
+	out[parts-1]++
+	return out
+}
+
+// compareSemver returns -1, 0, or 1
+func compareSemver(a, b [3]int) int {
+	for i := range a {
+		if a[i] != b[i] {
+			if a[i] < b[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
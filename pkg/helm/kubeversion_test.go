@@ -0,0 +1,90 @@
+package helm
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestKubeVersionSatisfies(t *testing.T) {
+	tests := []struct {
+		constraint string
+		version    string
+		want       bool
+	}{
+		{"", "1.32.0", true},
+		{">=1.21.0-0", "1.32.0", true},
+		{">=1.21.0-0 <1.30.0-0", "1.32.0", false},
+		{">=1.21.0-0 <1.30.0-0", "1.29.4", true},
+		{">= 1.25, < 1.32", "1.31.0", true},
+		{">= 1.25, < 1.32", "1.32.0", false},
+		{"^1.25", "1.32.0", true},
+		{"~1.28", "1.29.0", false},
+		{"~1.28", "1.28.9", true},
+		{"~1", "1.32.0", true},
+		{"1.28.x", "1.28.3", true},
+		{"1.28.x", "1.29.0", false},
+		{"<1.25.0 || >=1.30.0", "1.32.0", true},
+		{"<1.25.0 || >=1.30.0", "1.27.0", false},
+		{"1.20 - 1.28", "1.28.0", true},
+		{"1.20 - 1.28", "1.30.0", false},
+		{"v1.32.0", "1.32.0", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.constraint+"@"+tt.version, func(t *testing.T) {
+			got, err := KubeVersionSatisfies(tt.constraint, tt.version)
+			if err != nil {
+				t.Fatalf("KubeVersionSatisfies failed: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("KubeVersionSatisfies(%q, %q) = %v, want %v", tt.constraint, tt.version, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestKubeVersionSatisfies_Invalid(t *testing.T) {
+	if _, err := KubeVersionSatisfies(">=abc", "1.32.0"); err == nil {
+		t.Error("expected error for invalid constraint")
+	}
+	if _, err := KubeVersionSatisfies(">=1.20", "latest"); err == nil {
+		t.Error("expected error for invalid version")
+	}
+}
+
+func TestReadChartMetadata(t *testing.T) {
+	archive := filepath.Join(t.TempDir(), "app-1.0.0.tgz")
+	f, err := os.Create(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+	files := map[string]string{
+		"app/charts/sub/Chart.yaml": "name: sub\nkubeVersion: '>=1.10'\n",
+		"app/Chart.yaml":            "name: app\nversion: 1.0.0\nkubeVersion: '>=1.25.0-0'\n",
+	}
+	for _, name := range []string{"app/charts/sub/Chart.yaml", "app/Chart.yaml"} {
+		body := files[name]
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(body))}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tw.Close()
+	gz.Close()
+	f.Close()
+
+	meta, err := ReadChartMetadata(archive)
+	if err != nil {
+		t.Fatalf("ReadChartMetadata failed: %v", err)
+	}
+	if meta.Name != "app" || meta.KubeVersion != ">=1.25.0-0" {
+		t.Errorf("unexpected metadata: %+v", meta)
+	}
+}
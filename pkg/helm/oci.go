@@ -0,0 +1,134 @@
+package helm
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// challengeParam matches key="value" pairs of a WWW-Authenticate challenge
+var challengeParam = regexp.MustCompile(`(\w+)="([^"]*)"`)
+
+// ociScheme is the registry URL scheme; tests point it at plain HTTP servers
+var ociScheme = "https"
+
+// ociVersions lists the semver tags of an oci:// chart, newest first. Helm
+// stores "+" in chart versions as "_" in tags, which is reversed here
+func ociVersions(chart string, cred *Credential) ([]string, error) {
+	host, repo, ok := strings.Cut(strings.TrimPrefix(chart, "oci://"), "/")
+	if !ok || repo == "" {
+		return nil, fmt.Errorf("invalid OCI chart reference %s", chart)
+	}
+	tags, err := ociTags(host, repo, cred)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tags of %s: %w", chart, err)
+	}
+
+	var versions []string
+	for _, tag := range tags {
+		version := strings.ReplaceAll(tag, "_", "+")
+		if _, _, err := parseSemver(version); err == nil && strings.Count(version, ".") >= 2 {
+			versions = append(versions, version)
+		}
+	}
+	sort.SliceStable(versions, func(i, j int) bool { return CompareVersions(versions[i], versions[j]) > 0 })
+	return versions, nil
+}
+
+// ociTags lists a repository's tags with the registry API, answering a bearer
+// token challenge (anonymously, or with cred) when the registry sends one
+func ociTags(host, repo string, cred *Credential) ([]string, error) {
+	client := &http.Client{Timeout: indexTimeout}
+	endpoint := fmt.Sprintf("%s://%s/v2/%s/tags/list", ociScheme, host, repo)
+
+	req, err := http.NewRequest(http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	if cred != nil {
+		req.SetBasicAuth(cred.Username, cred.Password)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusUnauthorized {
+		token, err := ociToken(client, resp.Header.Get("WWW-Authenticate"), cred)
+		if err != nil {
+			return nil, err
+		}
+		req, _ = http.NewRequest(http.MethodGet, endpoint, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		if resp, err = client.Do(req); err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: %s", endpoint, resp.Status)
+	}
+
+	var list struct {
+		Tags []string `json:"tags"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		return nil, fmt.Errorf("failed to parse tag list: %w", err)
+	}
+	return list.Tags, nil
+}
+
+// ociToken fetches a bearer token for a challenge such as
+// Bearer realm="https://ghcr.io/token",service="ghcr.io",scope="repository:x:pull"
+func ociToken(client *http.Client, challenge string, cred *Credential) (string, error) {
+	scheme, params, _ := strings.Cut(challenge, " ")
+	if !strings.EqualFold(scheme, "Bearer") {
+		return "", fmt.Errorf("unauthorized (unsupported challenge %q)", challenge)
+	}
+	fields := make(map[string]string)
+	for _, m := range challengeParam.FindAllStringSubmatch(params, -1) {
+		fields[m[1]] = m[2]
+	}
+	if fields["realm"] == "" {
+		return "", fmt.Errorf("unauthorized (no token realm in %q)", challenge)
+	}
+
+	query := url.Values{}
+	for _, key := range []string{"service", "scope"} {
+		if fields[key] != "" {
+			query.Set(key, fields[key])
+		}
+	}
+	req, err := http.NewRequest(http.MethodGet, fields["realm"]+"?"+query.Encode(), nil)
+	if err != nil {
+		return "", err
+	}
+	if cred != nil {
+		req.SetBasicAuth(cred.Username, cred.Password)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("token request failed: %s", resp.Status)
+	}
+
+	var token struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", fmt.Errorf("failed to parse token: %w", err)
+	}
+	if token.Token != "" {
+		return token.Token, nil
+	}
+	return token.AccessToken, nil
+}
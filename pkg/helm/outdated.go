@@ -0,0 +1,119 @@
+package helm
+
+import (
+	"fmt"
+)
+
+// Update kinds, by the most significant version part that changes
+const (
+	UpdateMajor = "major"
+	UpdateMinor = "minor"
+	UpdatePatch = "patch"
+)
+
+// VersionStatus is where a chart's targetRevision stands among its released versions
+type VersionStatus struct {
+	TargetRevision string `json:"target_revision"`
+
+	// Range is set when TargetRevision is a constraint (1.2.*, ^1.2.0,
+	// >=1.0 <2.0) rather than an exact version
+	Range bool `json:"range,omitempty"`
+
+	// Current is the pinned version, or the newest release a range resolves
+	// to ("" when no release satisfies it)
+	Current string `json:"current"`
+
+	// Latest is the newest release; LatestInMajor the newest sharing
+	// Current's major version
+	Latest        string `json:"latest"`
+	LatestInMajor string `json:"latest_in_major,omitempty"`
+
+	// Update is the kind of update from Current to Latest, and UpdateInMajor
+	// from Current to LatestInMajor ("" when up to date)
+	Update        string `json:"update,omitempty"`
+	UpdateInMajor string `json:"update_in_major,omitempty"`
+}
+
+// Outdated reports whether a newer release than Current exists
+func (s VersionStatus) Outdated() bool {
+	return s.Update != ""
+}
+
+// CheckVersion compares targetRevision with a chart's versions (see
+// ChartVersions). Prereleases never count as newer releases, and a range is
+// only outdated when the latest release falls outside it
+func CheckVersion(targetRevision string, versions []string) (VersionStatus, error) {
+	status := VersionStatus{TargetRevision: targetRevision, Range: !IsCacheableVersion(targetRevision)}
+
+	var releases []string
+	for _, v := range versions {
+		if !isPrerelease(v) {
+			releases = append(releases, v)
+		}
+	}
+	if len(releases) == 0 {
+		return status, fmt.Errorf("no released versions")
+	}
+	status.Latest = releases[0]
+	for _, v := range releases[1:] {
+		if CompareVersions(v, status.Latest) > 0 {
+			status.Latest = v
+		}
+	}
+
+	status.Current = targetRevision
+	if status.Range {
+		status.Current = ""
+		for _, v := range releases {
+			parsed, _, err := parseSemver(v)
+			if err != nil {
+				continue
+			}
+			ok, err := satisfiesConstraint(targetRevision, parsed)
+			if err != nil {
+				return status, fmt.Errorf("invalid version constraint %q: %w", targetRevision, err)
+			}
+			if ok && (status.Current == "" || CompareVersions(v, status.Current) > 0) {
+				status.Current = v
+			}
+		}
+		if status.Current == "" {
+			return status, fmt.Errorf("no release satisfies %q", targetRevision)
+		}
+	}
+
+	current, _, err := parseSemver(status.Current)
+	if err != nil {
+		return status, fmt.Errorf("invalid version %q: %w", status.Current, err)
+	}
+	for _, v := range releases {
+		parsed, _, err := parseSemver(v)
+		if err != nil || parsed[0] != current[0] {
+			continue
+		}
+		if status.LatestInMajor == "" || CompareVersions(v, status.LatestInMajor) > 0 {
+			status.LatestInMajor = v
+		}
+	}
+
+	if CompareVersions(status.Latest, status.Current) > 0 {
+		status.Update = updateKind(current, status.Latest)
+	}
+	if CompareVersions(status.LatestInMajor, status.Current) > 0 {
+		status.UpdateInMajor = updateKind(current, status.LatestInMajor)
+	}
+	return status, nil
+}
+
+// updateKind classifies the update from current to version
+func updateKind(current [3]int, version string) string {
+	v, _, _ := parseSemver(version)
+	switch {
+	case v[0] != current[0]:
+		return UpdateMajor
+	case v[1] != current[1]:
+		return UpdateMinor
+	default:
+		return UpdatePatch
+	}
+}
@@ -0,0 +1,41 @@
+package helm
+
+import "testing"
+
+func TestCheckVersion(t *testing.T) {
+	versions := []string{"3.0.0-rc.1", "2.1.0", "2.0.1", "1.4.2", "1.4.0", "1.3.0"}
+	tests := []struct {
+		target                 string
+		current, latestInMajor string
+		update, updateInMajor  string
+		wantErr                bool
+	}{
+		{target: "2.1.0", current: "2.1.0", latestInMajor: "2.1.0"},
+		{target: "1.3.0", current: "1.3.0", latestInMajor: "1.4.2", update: UpdateMajor, updateInMajor: UpdateMinor},
+		{target: "2.0.1", current: "2.0.1", latestInMajor: "2.1.0", update: UpdateMinor, updateInMajor: UpdateMinor},
+		{target: "1.4.0", current: "1.4.0", latestInMajor: "1.4.2", update: UpdateMajor, updateInMajor: UpdatePatch},
+		// Ranges resolve to their newest release and are only behind when Latest is outside them
+		{target: "1.4.*", current: "1.4.2", latestInMajor: "1.4.2", update: UpdateMajor},
+		{target: "^2.0.0", current: "2.1.0", latestInMajor: "2.1.0"},
+		{target: ">=1.0 <2.0", current: "1.4.2", latestInMajor: "1.4.2", update: UpdateMajor},
+		{target: "^5.0.0", wantErr: true},
+	}
+	for _, tt := range tests {
+		status, err := CheckVersion(tt.target, versions)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("CheckVersion(%q) error = %v, wantErr %v", tt.target, err, tt.wantErr)
+			continue
+		}
+		if tt.wantErr {
+			continue
+		}
+		if status.Latest != "2.1.0" || status.Current != tt.current || status.LatestInMajor != tt.latestInMajor ||
+			status.Update != tt.update || status.UpdateInMajor != tt.updateInMajor || status.Outdated() != (tt.update != "") {
+			t.Errorf("CheckVersion(%q) = %+v", tt.target, status)
+		}
+	}
+
+	if _, err := CheckVersion("1.0.0", []string{"2.0.0-beta.1"}); err == nil {
+		t.Error("expected an error when there are only prereleases")
+	}
+}
@@ -2,10 +2,14 @@
 package helm
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"os/exec"
 	"strings"
+
+	"github.com/erauner/homelab-shadow/pkg/command"
+	"github.com/erauner/homelab-shadow/pkg/log"
 )
 
 // TemplateOptions configures a helm template operation
@@ -31,8 +35,33 @@ type TemplateOptions struct {
 	// InlineValues is inline YAML values
 	InlineValues string
 
+	// Parameters override values, after value files and inline values
+	Parameters []Parameter
+
+	// SkipCRDs leaves the chart's crds/ directory out (default: included)
+	SkipCRDs bool
+
+	// Credential authenticates to the chart repository or OCI registry (see
+	// CredentialStore); nil pulls anonymously
+	Credential *Credential
+
+	// CacheDir enables the local chart cache when set (see ChartCache)
+	// Only exact versions are cached; ranges always go to the repository
+	CacheDir string
+
 	// Verbose enables verbose output
 	Verbose bool
+
+	// Log receives progress messages (default: the shared logger)
+	Log *log.Logger
+}
+
+// Parameter is a single value override: --set name=value, or --set-string
+// with ForceString
+type Parameter struct {
+	Name        string
+	Value       string
+	ForceString bool
 }
 
 // TemplateResult contains the result of helm template
@@ -41,12 +70,26 @@ type TemplateResult struct {
 	Passed  bool
 	Error   error
 	Command string // The command that was run (for debugging)
+
+	// ChartCache is "hit" or "miss" when the chart cache was consulted
+	ChartCache string
 }
 
 // Template runs helm template with the given options
 func Template(opts TemplateOptions) TemplateResult {
+	return TemplateContext(context.Background(), opts)
+}
+
+// TemplateContext is Template, killing helm pull and helm template when ctx is done
+func TemplateContext(ctx context.Context, opts TemplateOptions) TemplateResult {
 	result := TemplateResult{}
 
+	logger := opts.Log
+	if logger == nil {
+		logger = log.Default()
+	}
+	logger = logger.Named("helm").Verbose(opts.Verbose)
+
 	// Build command arguments
 	args := []string{"template"}
 
@@ -57,17 +100,49 @@ func Template(opts TemplateOptions) TemplateResult {
 	}
 	args = append(args, releaseName)
 
-	// Chart reference (chart name when using --repo)
-	args = append(args, opts.Chart)
-
-	// Repository URL
-	if opts.RepoURL != "" {
-		args = append(args, "--repo", opts.RepoURL)
+	// Prefer a cached chart archive over --repo/--version downloads
+	chartPath := ""
+	if opts.CacheDir != "" && IsCacheableVersion(opts.Version) {
+		cache := NewChartCache(opts.CacheDir, opts.Verbose)
+		cache.Log = logger
+		cache.Credential = opts.Credential
+		result.ChartCache = "miss"
+		if _, ok := cache.Lookup(opts.RepoURL, opts.Chart, opts.Version); ok {
+			result.ChartCache = "hit"
+		}
+		path, err := cache.FetchContext(ctx, opts.RepoURL, opts.Chart, opts.Version)
+		if err != nil {
+			logger.Debugf("chart cache unavailable, rendering from repo: %v", err)
+		} else {
+			chartPath = path
+		}
 	}
 
-	// Version
-	if opts.Version != "" {
-		args = append(args, "--version", opts.Version)
+	if chartPath != "" {
+		// Local archive already pins the version
+		args = append(args, chartPath)
+	} else {
+		// Chart reference (chart name when using --repo)
+		args = append(args, opts.Chart)
+
+		// Repository URL
+		if opts.RepoURL != "" {
+			args = append(args, "--repo", opts.RepoURL)
+		}
+
+		// Version
+		if opts.Version != "" {
+			args = append(args, "--version", opts.Version)
+		}
+
+		// Credentials for private repositories and registries
+		auth, cleanup, err := authArgs(ctx, opts.Credential, opts.RepoURL, opts.Chart)
+		if err != nil {
+			result.Error = err
+			return result
+		}
+		defer cleanup()
+		args = append(args, auth...)
 	}
 
 	// Namespace
@@ -98,19 +173,36 @@ func Template(opts TemplateOptions) TemplateResult {
 		args = append(args, "--values", tmpFile.Name())
 	}
 
+	// Parameters take precedence over every values file
+	for _, p := range opts.Parameters {
+		flag := "--set"
+		if p.ForceString {
+			flag = "--set-string"
+		}
+		args = append(args, flag, p.Name+"="+p.Value)
+	}
+
 	// Include CRDs in output
-	args = append(args, "--include-crds")
+	if opts.SkipCRDs {
+		args = append(args, "--skip-crds")
+	} else {
+		args = append(args, "--include-crds")
+	}
 
 	// Build command string for debugging
-	result.Command = "helm " + strings.Join(args, " ")
+	result.Command = redactCommand("helm "+strings.Join(args, " "), opts.Credential)
 
 	// Execute helm template
-	cmd := exec.Command("helm", args...)
+	cmd := command.CommandContext(ctx, "helm", args...)
 	output, err := cmd.CombinedOutput()
 	result.Output = string(output)
 
 	if err != nil {
 		result.Passed = false
+		if command.IsTimeout(err) {
+			result.Error = err
+			return result
+		}
 		result.Error = fmt.Errorf("helm template failed: %w\nOutput: %s", err, string(output))
 		return result
 	}
@@ -127,7 +219,7 @@ func IsHelmInstalled() bool {
 
 // HelmVersion returns the installed helm version
 func HelmVersion() (string, error) {
-	cmd := exec.Command("helm", "version", "--short")
+	cmd := command.Command("helm", "version", "--short")
 	output, err := cmd.CombinedOutput()
 	if err != nil {
 		return "", fmt.Errorf("failed to get helm version: %w", err)
@@ -139,13 +231,13 @@ func HelmVersion() (string, error) {
 // This is needed for charts from custom repositories
 func UpdateRepo(name, url string) error {
 	// Add repo (will update if already exists)
-	addCmd := exec.Command("helm", "repo", "add", name, url, "--force-update")
+	addCmd := command.Command("helm", "repo", "add", name, url, "--force-update")
 	if output, err := addCmd.CombinedOutput(); err != nil {
 		return fmt.Errorf("failed to add helm repo %s: %w\nOutput: %s", name, err, string(output))
 	}
 
 	// Update repo
-	updateCmd := exec.Command("helm", "repo", "update", name)
+	updateCmd := command.Command("helm", "repo", "update", name)
 	if output, err := updateCmd.CombinedOutput(); err != nil {
 		return fmt.Errorf("failed to update helm repo %s: %w\nOutput: %s", name, err, string(output))
 	}
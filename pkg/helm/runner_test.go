@@ -2,6 +2,7 @@ package helm
 
 import (
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 )
@@ -157,3 +158,35 @@ func TestTemplateOptions_Defaults(t *testing.T) {
 		t.Errorf("expected command to include chart name, got: %s", result.Command)
 	}
 }
+
+func TestTemplate_ParametersAndSkipCRDs(t *testing.T) {
+	// A fake helm that echoes its arguments keeps this test offline
+	bin := t.TempDir()
+	if err := os.WriteFile(filepath.Join(bin, "helm"), []byte("#!/bin/sh\necho \"$@\"\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	result := Template(TemplateOptions{
+		ReleaseName: "params",
+		Chart:       "app-template",
+		RepoURL:     "https://bjw-s-labs.github.io/helm-charts",
+		Version:     "4.5.0",
+		Parameters: []Parameter{
+			{Name: "replicas", Value: "2"},
+			{Name: "image.tag", Value: "1.27", ForceString: true},
+		},
+		SkipCRDs: true,
+	})
+	if !result.Passed {
+		t.Fatalf("Template failed: %v\nOutput: %s", result.Error, result.Output)
+	}
+	for _, want := range []string{"--set replicas=2", "--set-string image.tag=1.27", "--skip-crds"} {
+		if !strings.Contains(result.Output, want) {
+			t.Errorf("expected %q in arguments, got: %s", want, result.Output)
+		}
+	}
+	if strings.Contains(result.Output, "--include-crds") {
+		t.Errorf("expected --include-crds to be dropped, got: %s", result.Output)
+	}
+}
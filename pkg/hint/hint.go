@@ -0,0 +1,58 @@
+// Package hint maps common failure signatures to one-line remediation
+// suggestions, so surfaced errors say what to do next and not just what broke
+package hint
+
+import "regexp"
+
+// signature pairs a failure pattern with its remediation
+type signature struct {
+	pattern *regexp.Regexp
+	hint    string
+}
+
+// signatures are checked in order; the first match wins, so more specific
+// patterns (e.g. a Helm index 404) come before generic ones (any GitHub 404)
+var signatures = []signature{
+	{
+		regexp.MustCompile(`timed out after \S+ \(raise --cmd-timeout\)`),
+		"an external command ran past --cmd-timeout and was killed - a slow chart registry or git remote usually recovers on retry; raise --cmd-timeout (e.g. --cmd-timeout 10m) for charts that are just slow to pull",
+	},
+	{
+		regexp.MustCompile(`(?i)no repo named|repo "?\S+"? not found|no cached repo found|could not find protocol handler|index\.yaml: (401|403|404)`),
+		"the Helm repository is unknown or unreachable - check the source's repoURL and chart, run `helm repo add <name> <url>` for local renders, and set SHADOW_HELM_USERNAME/SHADOW_HELM_PASSWORD for private repos",
+	},
+	{
+		regexp.MustCompile(`(?i)is not in or below|LoadRestrictionsRootOnly`),
+		"kustomize (like ArgoCD) only loads files under the kustomization root - move the file into the directory, or include the directory holding it as a resource instead of a ../ file path",
+	},
+	{
+		regexp.MustCompile(`(?is)accumulating resources.*(no such file or directory|must resolve to a file|must build at directory|not a valid directory|evalsymlink failure)`),
+		"a resources/bases entry points at a path that doesn't exist - check it for typos or a moved or deleted directory (`shadow validate` names dangling argocd/ includes)",
+	},
+	{
+		regexp.MustCompile(`(?i)could not find schema for|failed downloading schema|Skipped: [1-9]`),
+		"kubeconform has no schema for these custom resources, so they are skipped - CRDs are not in the default Kubernetes schema set; add their schemas (e.g. from datreeio/CRDs-catalog) to validate them",
+	},
+	{
+		regexp.MustCompile(`(?i)repository not found|remote: not found|\(HTTP 404\)|returned error: 404`),
+		"GitHub returned 404 for the repository - check --shadow-repo (owner/name) and that GH_TOKEN can access it; GitHub reports private repos a token can't see as not found",
+	},
+}
+
+// For returns a remediation for msg, or "" when no known signature matches
+func For(msg string) string {
+	for _, s := range signatures {
+		if s.pattern.MatchString(msg) {
+			return s.hint
+		}
+	}
+	return ""
+}
+
+// ForError returns a remediation for err, or "" when err is nil or unrecognized
+func ForError(err error) string {
+	if err == nil {
+		return ""
+	}
+	return For(err.Error())
+}
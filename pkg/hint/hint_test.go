@@ -0,0 +1,54 @@
+package hint
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestFor(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  string
+		want string // substring of the hint; "" for no hint
+	}{
+		{"command timeout", "failed to render apps/web: helm pull timed out after 5m0s (raise --cmd-timeout)", "--cmd-timeout 10m"},
+		{"helm repo add", `Error: no repo named "bitnami" found`, "helm repo add"},
+		{"helm index 404", "failed to fetch https://charts.example.com/index.yaml: 404 Not Found", "helm repo add"},
+		{"load restriction", "accumulating resources: security; file '/repo/shared/cm.yaml' is not in or below '/repo/apps/x'", "kustomization root"},
+		{"accumulating typo", "accumulating resources: accumulation err='accumulating resources from '../bse': open /repo/apps/bse: no such file or directory'", "typos"},
+		{"accumulating multiline", "Error: accumulating resources\n  'deleted.yaml': must resolve to a file", "typos"},
+		{"kubeconform missing schema", "Certificate cert: could not find schema for Certificate", "CRDs-catalog"},
+		{"kubeconform skipped", "Summary: 4 resources found in 1 file - Valid: 2, Invalid: 0, Errors: 0, Skipped: 2", "CRDs-catalog"},
+		{"git repo not found", "git clone failed: exit status 128: remote: Repository not found.", "--shadow-repo"},
+		{"gh 404", "gh: Not Found (HTTP 404)", "--shadow-repo"},
+		{"nothing skipped", "Summary: 4 resources found in 1 file - Valid: 4, Invalid: 0, Errors: 0, Skipped: 0", ""},
+		{"unrecognized", "something else went wrong", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := For(tt.msg)
+			if tt.want == "" {
+				if got != "" {
+					t.Errorf("For(%q) = %q, want no hint", tt.msg, got)
+				}
+				return
+			}
+			if !strings.Contains(got, tt.want) {
+				t.Errorf("For(%q) = %q, want it to mention %q", tt.msg, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestForError(t *testing.T) {
+	if got := ForError(nil); got != "" {
+		t.Errorf("ForError(nil) = %q, want empty", got)
+	}
+	err := fmt.Errorf("sync failed: %w", errors.New("remote: Repository not found."))
+	if got := ForError(err); !strings.Contains(got, "--shadow-repo") {
+		t.Errorf("ForError(wrapped) = %q, want the shadow repo hint", got)
+	}
+}
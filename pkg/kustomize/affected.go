@@ -0,0 +1,242 @@
+package kustomize
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// kustomizationRefs holds the references of a kustomization file
+type kustomizationRefs struct {
+	Kind       string   `yaml:"kind"`
+	Resources  []string `yaml:"resources"`
+	Bases      []string `yaml:"bases"`
+	Components []string `yaml:"components"`
+	HelmCharts []struct {
+		Name                  string   `yaml:"name"`
+		ValuesFile            string   `yaml:"valuesFile"`
+		AdditionalValuesFiles []string `yaml:"additionalValuesFiles"`
+	} `yaml:"helmCharts"`
+
+	// File inputs, hashed by ResultCache when they live outside the directory
+	Patches []struct {
+		Path string `yaml:"path"`
+	} `yaml:"patches"`
+	PatchesStrategicMerge []string          `yaml:"patchesStrategicMerge"`
+	ConfigMapGenerator    []generatorInputs `yaml:"configMapGenerator"`
+	SecretGenerator       []generatorInputs `yaml:"secretGenerator"`
+}
+
+// generatorInputs are the files a configMapGenerator or secretGenerator reads
+type generatorInputs struct {
+	Files []string `yaml:"files"` // "path" or "key=path"
+	Envs  []string `yaml:"envs"`
+	Env   string   `yaml:"env"`
+}
+
+// files returns the paths of files k reads besides resources, relative to its directory
+func (k kustomizationRefs) files() []string {
+	var files []string
+	for _, chart := range k.HelmCharts {
+		if chart.ValuesFile != "" {
+			files = append(files, chart.ValuesFile)
+		}
+		files = append(files, chart.AdditionalValuesFiles...)
+	}
+	for _, p := range k.Patches {
+		if p.Path != "" {
+			files = append(files, p.Path)
+		}
+	}
+	for _, p := range k.PatchesStrategicMerge {
+		if !strings.Contains(p, "\n") { // inline patches aren't paths
+			files = append(files, p)
+		}
+	}
+	for _, g := range append(append([]generatorInputs(nil), k.ConfigMapGenerator...), k.SecretGenerator...) {
+		for _, f := range g.Files {
+			if _, path, ok := strings.Cut(f, "="); ok {
+				f = path
+			}
+			files = append(files, f)
+		}
+		files = append(files, g.Envs...)
+		if g.Env != "" {
+			files = append(files, g.Env)
+		}
+	}
+	return append(files, k.Resources...)
+}
+
+// Affected is a kustomization directory to rebuild for a set of changed files
+type Affected struct {
+	Directory string
+	UsesHelm  bool // it or a kustomization it references has helmCharts
+}
+
+// OwningKustomization returns the nearest directory at or above a repo-relative
+// file (or directory) that holds a kustomization, or false when none does
+// below the repository root. Deleted files resolve through their parents
+func OwningKustomization(repoPath, file string) (string, bool) {
+	dir := filepath.ToSlash(filepath.Clean(file))
+	if info, err := os.Stat(filepath.Join(repoPath, filepath.FromSlash(dir))); err != nil || !info.IsDir() {
+		dir = filepath.ToSlash(filepath.Dir(dir))
+	}
+	for dir != "." && dir != "/" && !strings.HasPrefix(dir, "../") && dir != ".." {
+		if hasKustomization(filepath.Join(repoPath, filepath.FromSlash(dir))) {
+			return dir, true
+		}
+		dir = filepath.ToSlash(filepath.Dir(dir))
+	}
+	return "", false
+}
+
+// AffectedDirectories maps changed repo-relative files to the discovered
+// kustomization directories (DiscoveryPatterns) that include them, directly or
+// through resources, bases, and components, sorted by directory
+//
+// Only the changed files' component (apps/<app>, infrastructure/<name>, ...)
+// is searched for dependents, so a change never triggers full repo discovery
+// beyond globbing; files in shared directories (kustomize/components, ...) are
+// checked against every discovered directory. An owning kustomization nothing
+// discovered includes is returned itself, unless it is a Component
+func (r *Runner) AffectedDirectories(files []string) ([]Affected, error) {
+	owners := make(map[string]bool)
+	for _, file := range files {
+		if owner, ok := OwningKustomization(r.RepoPath, file); ok {
+			owners[owner] = true
+		}
+	}
+	if len(owners) == 0 {
+		return nil, nil
+	}
+
+	discovered, err := r.DiscoverDirectories()
+	if err != nil {
+		return nil, err
+	}
+
+	refs := newRefCache(r.RepoPath)
+	affected := make(map[string]bool)
+	for owner := range owners {
+		root := componentRoot(owner)
+		covered := false
+		for _, dir := range discovered {
+			dir = filepath.ToSlash(dir)
+			if root != "" && dir != root && !strings.HasPrefix(dir, root+"/") {
+				continue
+			}
+			if refs.reachable(dir)[owner] {
+				affected[dir] = true
+				covered = true
+			}
+		}
+		if !covered && refs.get(owner).Kind != "Component" {
+			affected[owner] = true
+		}
+	}
+
+	result := make([]Affected, 0, len(affected))
+	for dir := range affected {
+		usesHelm := false
+		for reached := range refs.reachable(dir) {
+			if len(refs.get(reached).HelmCharts) > 0 {
+				usesHelm = true
+				break
+			}
+		}
+		result = append(result, Affected{Directory: dir, UsesHelm: usesHelm})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Directory < result[j].Directory })
+	return result, nil
+}
+
+// componentRoot returns the first two segments of paths under apps/,
+// infrastructure/, operators/, and security/, or "" for shared directories
+func componentRoot(dir string) string {
+	parts := strings.Split(dir, "/")
+	if len(parts) < 2 {
+		return ""
+	}
+	switch parts[0] {
+	case "apps", "infrastructure", "operators", "security":
+		return parts[0] + "/" + parts[1]
+	}
+	return ""
+}
+
+// refCache parses each kustomization once
+type refCache struct {
+	repoPath string
+	parsed   map[string]kustomizationRefs
+	closures map[string]map[string]bool
+}
+
+func newRefCache(repoPath string) *refCache {
+	return &refCache{
+		repoPath: repoPath,
+		parsed:   make(map[string]kustomizationRefs),
+		closures: make(map[string]map[string]bool),
+	}
+}
+
+// get returns the parsed kustomization in dir (zero when missing or invalid)
+func (c *refCache) get(dir string) kustomizationRefs {
+	if k, ok := c.parsed[dir]; ok {
+		return k
+	}
+	var k kustomizationRefs
+	for _, name := range []string{"kustomization.yaml", "kustomization.yml", "Kustomization"} {
+		if data, err := os.ReadFile(filepath.Join(c.repoPath, filepath.FromSlash(dir), name)); err == nil {
+			_ = yaml.Unmarshal(data, &k) // build errors are reported by kustomize
+			break
+		}
+	}
+	c.parsed[dir] = k
+	return k
+}
+
+// reachable returns dir and every local directory its kustomization
+// references, transitively
+func (c *refCache) reachable(dir string) map[string]bool {
+	if seen, ok := c.closures[dir]; ok {
+		return seen
+	}
+	seen := make(map[string]bool)
+	queue := []string{dir}
+	for len(queue) > 0 {
+		d := queue[0]
+		queue = queue[1:]
+		if seen[d] {
+			continue
+		}
+		seen[d] = true
+
+		k := c.get(d)
+		for _, ref := range append(append(append([]string(nil), k.Resources...), k.Bases...), k.Components...) {
+			if strings.Contains(ref, "://") || strings.HasPrefix(ref, "github.com/") {
+				continue // remote resource
+			}
+			target := filepath.ToSlash(filepath.Clean(filepath.Join(d, ref)))
+			if strings.HasPrefix(target, "../") || !hasKustomization(filepath.Join(c.repoPath, filepath.FromSlash(target))) {
+				continue
+			}
+			queue = append(queue, target)
+		}
+	}
+	c.closures[dir] = seen
+	return seen
+}
+
+// hasKustomization reports whether dir holds a kustomization file
+func hasKustomization(dir string) bool {
+	for _, name := range []string{"kustomization.yaml", "kustomization.yml", "Kustomization"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,131 @@
+package kustomize
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// writeRepo writes repo-relative files under a temp repository
+func writeRepo(t *testing.T, files map[string]string) string {
+	t.Helper()
+	repo := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(repo, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return repo
+}
+
+func TestOwningKustomization(t *testing.T) {
+	repo := writeRepo(t, map[string]string{
+		"apps/web/base/kustomization.yaml":       "resources:\n  - deployment.yaml\n",
+		"apps/web/base/deployment.yaml":          "kind: Deployment\n",
+		"apps/web/base/config/nested/values.txt": "x\n",
+		"README.md":                              "readme\n",
+	})
+
+	tests := []struct {
+		file string
+		want string
+		ok   bool
+	}{
+		{"apps/web/base/deployment.yaml", "apps/web/base", true},
+		{"apps/web/base/config/nested/values.txt", "apps/web/base", true},
+		{"apps/web/base", "apps/web/base", true},
+		{"apps/web/base/deleted.yaml", "apps/web/base", true},
+		{"README.md", "", false},
+		{"docs/gone.md", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := OwningKustomization(repo, tt.file)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("OwningKustomization(%q) = %q, %v, want %q, %v", tt.file, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestAffectedDirectories(t *testing.T) {
+	repo := writeRepo(t, map[string]string{
+		"apps/web/base/kustomization.yaml":                               "resources:\n  - deployment.yaml\n",
+		"apps/web/base/deployment.yaml":                                  "kind: Deployment\n",
+		"apps/web/overlays/erauner-home/production/kustomization.yaml":   "resources:\n  - ../../../base\ncomponents:\n  - ../../../../../kustomize/components/monitoring\n",
+		"apps/web/overlays/erauner-home/staging/kustomization.yaml":      "resources:\n  - ../../../base\n",
+		"apps/chart/base/kustomization.yaml":                             "helmCharts:\n  - name: chart\n",
+		"apps/chart/overlays/erauner-home/production/kustomization.yaml": "resources:\n  - ../../../base\n",
+		"apps/other/base/kustomization.yaml":                             "components:\n  - ../../../kustomize/components/monitoring\n",
+		"apps/web/extra/kustomization.yaml":                              "resources:\n  - cm.yaml\n",
+		"kustomize/components/monitoring/kustomization.yaml":             "kind: Component\n",
+		"kustomize/components/unused/kustomization.yaml":                 "kind: Component\n",
+	})
+	runner := NewRunner(repo, "", false)
+
+	tests := []struct {
+		name  string
+		files []string
+		want  []Affected
+	}{
+		{
+			name:  "base change rebuilds the base and its overlays",
+			files: []string{"apps/web/base/deployment.yaml"},
+			want: []Affected{
+				{Directory: "apps/web/base"},
+				{Directory: "apps/web/overlays/erauner-home/production"},
+				{Directory: "apps/web/overlays/erauner-home/staging"},
+			},
+		},
+		{
+			name:  "overlay change rebuilds only the overlay",
+			files: []string{"apps/web/overlays/erauner-home/staging/kustomization.yaml"},
+			want:  []Affected{{Directory: "apps/web/overlays/erauner-home/staging"}},
+		},
+		{
+			name:  "helm charts propagate to including overlays",
+			files: []string{"apps/chart/base/kustomization.yaml"},
+			want: []Affected{
+				{Directory: "apps/chart/base", UsesHelm: true},
+				{Directory: "apps/chart/overlays/erauner-home/production", UsesHelm: true},
+			},
+		},
+		{
+			name:  "shared component rebuilds every includer",
+			files: []string{"kustomize/components/monitoring/kustomization.yaml"},
+			want: []Affected{
+				{Directory: "apps/other/base"},
+				{Directory: "apps/web/overlays/erauner-home/production"},
+			},
+		},
+		{
+			name:  "undiscovered kustomization is returned itself",
+			files: []string{"apps/web/extra/cm.yaml"},
+			want:  []Affected{{Directory: "apps/web/extra"}},
+		},
+		{
+			name:  "unreferenced component is not built",
+			files: []string{"kustomize/components/unused/kustomization.yaml"},
+			want:  []Affected{},
+		},
+		{
+			name:  "files outside kustomizations",
+			files: []string{"README.md", "cmd/main.go"},
+			want:  nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := runner.AffectedDirectories(tt.files)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("AffectedDirectories(%v) = %+v, want %+v", tt.files, got, tt.want)
+			}
+		})
+	}
+}
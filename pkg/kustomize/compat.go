@@ -0,0 +1,233 @@
+package kustomize
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// VersionBinary is a kustomize binary for a specific version
+type VersionBinary struct {
+	Version string
+	Path    string
+}
+
+// CompatResult compares one directory's render across kustomize versions
+// The first render is the baseline every other version is diffed against
+type CompatResult struct {
+	Directory string          `json:"directory"`
+	Renders   []VersionRender `json:"renders"`
+}
+
+// VersionRender is a single version's render of a directory
+type VersionRender struct {
+	Version string        `json:"version"`
+	Error   string        `json:"error,omitempty"`
+	Diff    *ManifestDiff `json:"diff,omitempty"` // vs the baseline; nil for the baseline itself
+}
+
+// Consistent reports whether every version rendered and matched the baseline
+func (c CompatResult) Consistent() bool {
+	for _, r := range c.Renders {
+		if r.Error != "" || (r.Diff != nil && !r.Diff.Empty()) {
+			return false
+		}
+	}
+	return true
+}
+
+// ManifestDiff lists resources (Kind/namespace/name) that differ between two renders
+type ManifestDiff struct {
+	Added   []string `json:"added,omitempty"`
+	Removed []string `json:"removed,omitempty"`
+	Changed []string `json:"changed,omitempty"`
+
+	// Fields lists the field changes of each Changed resource
+	Fields map[string][]FieldChange `json:"fields,omitempty"`
+}
+
+// FieldChange is a field whose value differs between two renders of a
+// resource; Before or After is nil when the field is missing on that side
+type FieldChange struct {
+	Path   string      `json:"path"` // e.g. spec.template.spec.containers[0].image
+	Before interface{} `json:"before,omitempty"`
+	After  interface{} `json:"after,omitempty"`
+}
+
+// Empty reports whether the renders are equivalent
+func (d *ManifestDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// String summarizes the diff as "+added -removed ~changed"
+func (d *ManifestDiff) String() string {
+	return fmt.Sprintf("+%d -%d ~%d", len(d.Added), len(d.Removed), len(d.Changed))
+}
+
+// CompareVersions builds each directory with every binary and diffs the
+// output against the first binary's render
+func CompareVersions(repoPath string, binaries []VersionBinary, dirs []string) []CompatResult {
+	runners := make([]*Runner, len(binaries))
+	for i, b := range binaries {
+		runners[i] = NewRunner(repoPath, "", false)
+		runners[i].Binary = b.Path
+	}
+
+	results := make([]CompatResult, 0, len(dirs))
+	for _, dir := range dirs {
+		result := CompatResult{Directory: dir}
+		var baseline string
+		baselineOK := false
+
+		for i, runner := range runners {
+			render := VersionRender{Version: binaries[i].Version}
+			build := runner.BuildDirectory(dir)
+			switch {
+			case build.Skipped:
+				render.Error = build.SkipReason
+			case !build.Passed:
+				render.Error = ExtractKustomizeBuildError(build.Output)
+				if render.Error == "" {
+					render.Error = build.Error.Error()
+				}
+			case i == 0:
+				baseline, baselineOK = build.Output, true
+			case baselineOK:
+				diff, err := DiffManifests(baseline, build.Output)
+				if err != nil {
+					render.Error = err.Error()
+				} else {
+					render.Diff = diff
+				}
+			}
+			result.Renders = append(result.Renders, render)
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// DiffManifests compares two multi-document renders resource by resource
+// Document order and YAML formatting are ignored
+func DiffManifests(a, b string) (*ManifestDiff, error) {
+	before, err := resourcesByID(a)
+	if err != nil {
+		return nil, err
+	}
+	after, err := resourcesByID(b)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := &ManifestDiff{}
+	for id, doc := range after {
+		old, ok := before[id]
+		switch {
+		case !ok:
+			diff.Added = append(diff.Added, id)
+		case !reflect.DeepEqual(old, doc):
+			diff.Changed = append(diff.Changed, id)
+			if diff.Fields == nil {
+				diff.Fields = make(map[string][]FieldChange)
+			}
+			diff.Fields[id] = diffFields("", old, doc)
+		}
+	}
+	for id := range before {
+		if _, ok := after[id]; !ok {
+			diff.Removed = append(diff.Removed, id)
+		}
+	}
+	sort.Strings(diff.Added)
+	sort.Strings(diff.Removed)
+	sort.Strings(diff.Changed)
+	return diff, nil
+}
+
+// resourcesByID decodes a manifest into documents keyed by Kind/namespace/name
+func resourcesByID(manifest string) (map[string]interface{}, error) {
+	resources := make(map[string]interface{})
+	dec := yaml.NewDecoder(strings.NewReader(manifest))
+	for {
+		var doc map[string]interface{}
+		err := dec.Decode(&doc)
+		if errors.Is(err, io.EOF) {
+			return resources, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse rendered manifest: %w", err)
+		}
+		if doc == nil {
+			continue
+		}
+		resources[resourceID(doc)] = doc
+	}
+}
+
+// resourceID returns Kind/name or Kind/namespace/name for a decoded document
+func resourceID(doc map[string]interface{}) string {
+	kind, _ := doc["kind"].(string)
+	meta, _ := doc["metadata"].(map[string]interface{})
+	name, _ := meta["name"].(string)
+	if namespace, _ := meta["namespace"].(string); namespace != "" {
+		return kind + "/" + namespace + "/" + name
+	}
+	return kind + "/" + name
+}
+
+// diffFields lists the leaf fields that differ between two decoded values;
+// maps are compared key by key and lists element by element
+func diffFields(path string, a, b interface{}) []FieldChange {
+	if reflect.DeepEqual(a, b) {
+		return nil
+	}
+	switch av := a.(type) {
+	case map[string]interface{}:
+		bv, ok := b.(map[string]interface{})
+		if !ok {
+			break
+		}
+		keys := make([]string, 0, len(av)+len(bv))
+		for k := range av {
+			keys = append(keys, k)
+		}
+		for k := range bv {
+			if _, ok := av[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		var changes []FieldChange
+		for _, k := range keys {
+			child := k
+			if path != "" {
+				child = path + "." + k
+			}
+			changes = append(changes, diffFields(child, av[k], bv[k])...)
+		}
+		return changes
+	case []interface{}:
+		bv, ok := b.([]interface{})
+		if !ok {
+			break
+		}
+		var changes []FieldChange
+		for i := 0; i < len(av) || i < len(bv); i++ {
+			var x, y interface{}
+			if i < len(av) {
+				x = av[i]
+			}
+			if i < len(bv) {
+				y = bv[i]
+			}
+			changes = append(changes, diffFields(fmt.Sprintf("%s[%d]", path, i), x, y)...)
+		}
+		return changes
+	}
+	return []FieldChange{{Path: path, Before: a, After: b}}
+}
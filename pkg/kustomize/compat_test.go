@@ -0,0 +1,155 @@
+package kustomize
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestDiffManifests(t *testing.T) {
+	before := `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+  namespace: web
+data:
+  mode: fast
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+  namespace: web
+---
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: old
+`
+	// Reordered documents and keys, one change, one addition, one removal
+	after := `kind: Service
+apiVersion: v1
+metadata:
+  namespace: web
+  name: web
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+  namespace: web
+data:
+  mode: slow
+---
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: new
+`
+
+	diff, err := DiffManifests(before, after)
+	if err != nil {
+		t.Fatalf("DiffManifests() error = %v", err)
+	}
+	if got := strings.Join(diff.Changed, ","); got != "ConfigMap/web/config" {
+		t.Errorf("Changed = %q", got)
+	}
+	if got := strings.Join(diff.Added, ","); got != "Namespace/new" {
+		t.Errorf("Added = %q", got)
+	}
+	if got := strings.Join(diff.Removed, ","); got != "Namespace/old" {
+		t.Errorf("Removed = %q", got)
+	}
+	if fields := diff.Fields["ConfigMap/web/config"]; !reflect.DeepEqual(fields, []FieldChange{{Path: "data.mode", Before: "fast", After: "slow"}}) {
+		t.Errorf("Fields = %+v", fields)
+	}
+	if diff.String() != "+1 -1 ~1" {
+		t.Errorf("String() = %q", diff.String())
+	}
+
+	same, err := DiffManifests(before, before)
+	if err != nil || !same.Empty() {
+		t.Errorf("expected identical renders to be empty, got %+v, %v", same, err)
+	}
+}
+
+// fakeKustomize writes a kustomize stub that prints manifest for any build
+func fakeKustomize(t *testing.T, name, manifest string, exitCode int) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	script := "#!/bin/sh\ncat <<'EOF'\n" + manifest + "EOF\nexit " + strconv.Itoa(exitCode) + "\n"
+	if err := os.WriteFile(path, []byte(script), 0755); err != nil {
+		t.Fatalf("failed to write kustomize stub: %v", err)
+	}
+	return path
+}
+
+func TestCompareVersions(t *testing.T) {
+	repo := t.TempDir()
+	dir := filepath.Join(repo, "apps", "web", "overlays", "production")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "kustomization.yaml"), []byte("resources: []\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	base := "kind: ConfigMap\nmetadata:\n  name: web\ndata:\n  a: \"1\"\n"
+	binaries := []VersionBinary{
+		{Version: "5.3.0", Path: fakeKustomize(t, "kustomize-5.3", base, 0)},
+		{Version: "5.4.0", Path: fakeKustomize(t, "kustomize-5.4", base, 0)},
+		{Version: "5.5.0", Path: fakeKustomize(t, "kustomize-5.5", "kind: ConfigMap\nmetadata:\n  name: web\ndata:\n  a: \"2\"\n", 0)},
+		{Version: "5.6.0", Path: fakeKustomize(t, "kustomize-5.6", "Error: unknown field\n", 1)},
+	}
+
+	results := CompareVersions(repo, binaries, []string{"apps/web/overlays/production", "apps/missing"})
+	if len(results) != 2 {
+		t.Fatalf("expected 2 results, got %d", len(results))
+	}
+
+	renders := results[0].Renders
+	if renders[0].Error != "" || renders[0].Diff != nil {
+		t.Errorf("baseline render = %+v", renders[0])
+	}
+	if renders[1].Diff == nil || !renders[1].Diff.Empty() {
+		t.Errorf("expected 5.4.0 to match baseline, got %+v", renders[1])
+	}
+	if renders[2].Diff == nil || strings.Join(renders[2].Diff.Changed, ",") != "ConfigMap/web" {
+		t.Errorf("expected 5.5.0 to change ConfigMap/web, got %+v", renders[2])
+	}
+	if renders[3].Error != "Error: unknown field" {
+		t.Errorf("expected 5.6.0 build error, got %+v", renders[3])
+	}
+	if results[0].Consistent() {
+		t.Error("expected differing renders to be inconsistent")
+	}
+
+	if results[1].Consistent() || results[1].Renders[0].Error == "" {
+		t.Errorf("expected missing directory to fail, got %+v", results[1])
+	}
+}
+
+func TestDiffFields(t *testing.T) {
+	a := map[string]interface{}{
+		"spec": map[string]interface{}{
+			"replicas":   1,
+			"containers": []interface{}{map[string]interface{}{"image": "web:1"}},
+		},
+	}
+	b := map[string]interface{}{
+		"spec": map[string]interface{}{
+			"containers": []interface{}{map[string]interface{}{"image": "web:2"}, map[string]interface{}{"image": "sidecar:1"}},
+		},
+	}
+	want := []FieldChange{
+		{Path: "spec.containers[0].image", Before: "web:1", After: "web:2"},
+		{Path: "spec.containers[1]", After: map[string]interface{}{"image": "sidecar:1"}},
+		{Path: "spec.replicas", Before: 1},
+	}
+	if got := diffFields("", a, b); !reflect.DeepEqual(got, want) {
+		t.Errorf("diffFields() = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,284 @@
+package kustomize
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/erauner/homelab-shadow/pkg/cachedir"
+	"gopkg.in/yaml.v3"
+)
+
+// CRDSchemaDirs are repo directories whose CustomResourceDefinitions are
+// converted into kubeconform schemas
+var CRDSchemaDirs = []string{"infrastructure/crds"}
+
+// crdSchemaTemplate is the kubeconform -schema-location layout of generated
+// schemas, the same as the datreeio CRDs-catalog
+const crdSchemaTemplate = "{{.Group}}/{{.ResourceKind}}_{{.ResourceAPIVersion}}.json"
+
+// DefaultSchemaDir returns where generated CRD schemas are cached
+func DefaultSchemaDir() string {
+	return cachedir.Path("crd-schemas")
+}
+
+// CRDSchemas are kubeconform JSON schemas generated from CustomResourceDefinitions
+type CRDSchemas struct {
+	Dir     string // holds <group>/<kind>_<version>.json ("" when there are none)
+	Schemas int    // number of group/kind/version schemas
+}
+
+// Location returns the kubeconform -schema-location for the schemas, or ""
+// when none were generated
+func (s CRDSchemas) Location() string {
+	if s.Dir == "" {
+		return ""
+	}
+	return filepath.Join(s.Dir, crdSchemaTemplate)
+}
+
+// RepoCRDManifests reads the YAML files under CRDSchemaDirs in repoPath
+// Missing directories are skipped
+func RepoCRDManifests(repoPath string) ([]string, error) {
+	var manifests []string
+	for _, dir := range CRDSchemaDirs {
+		root := filepath.Join(repoPath, dir)
+		if _, err := os.Stat(root); os.IsNotExist(err) {
+			continue
+		}
+		err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() || (filepath.Ext(path) != ".yaml" && filepath.Ext(path) != ".yml") {
+				return nil
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			manifests = append(manifests, string(data))
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CRDs in %s: %w", dir, err)
+		}
+	}
+	return manifests, nil
+}
+
+// RepoCRDSchemas generates schemas from the CRDs under CRDSchemaDirs in
+// repoPath plus those in extra (e.g. rendered operator manifests)
+func RepoCRDSchemas(repoPath, cacheDir string, extra ...string) (CRDSchemas, error) {
+	manifests, err := RepoCRDManifests(repoPath)
+	if err != nil {
+		return CRDSchemas{}, err
+	}
+	return GenerateCRDSchemas(cacheDir, append(manifests, extra...))
+}
+
+// GenerateCRDSchemas converts every CustomResourceDefinition in manifests
+// into kubeconform JSON schemas under cacheDir. Schemas are keyed by a hash of
+// the CRDs, so an unchanged set is reused between runs
+func GenerateCRDSchemas(cacheDir string, manifests []string) (CRDSchemas, error) {
+	schemas := make(map[string][]byte) // <group>/<kind>_<version>.json -> schema
+	for _, manifest := range manifests {
+		if !strings.Contains(manifest, "CustomResourceDefinition") {
+			continue
+		}
+		if err := crdSchemas(manifest, schemas); err != nil {
+			return CRDSchemas{}, err
+		}
+	}
+	if len(schemas) == 0 {
+		return CRDSchemas{}, nil
+	}
+
+	names := make([]string, 0, len(schemas))
+	for name := range schemas {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	hash := sha256.New()
+	for _, name := range names {
+		fmt.Fprintf(hash, "%s\n%s\n", name, schemas[name])
+	}
+
+	result := CRDSchemas{
+		Dir:     filepath.Join(cacheDir, hex.EncodeToString(hash.Sum(nil))[:16]),
+		Schemas: len(schemas),
+	}
+	if _, err := os.Stat(result.Dir); err == nil {
+		return result, nil
+	}
+
+	// Write into a temp dir and rename, so a concurrent or interrupted run
+	// never leaves a partial schema set behind
+	if err := os.MkdirAll(cacheDir, 0755); err != nil {
+		return CRDSchemas{}, fmt.Errorf("failed to create schema cache: %w", err)
+	}
+	tmp, err := os.MkdirTemp(cacheDir, ".tmp-")
+	if err != nil {
+		return CRDSchemas{}, fmt.Errorf("failed to create schema cache: %w", err)
+	}
+	defer os.RemoveAll(tmp)
+	for _, name := range names {
+		path := filepath.Join(tmp, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return CRDSchemas{}, fmt.Errorf("failed to write schema %s: %w", name, err)
+		}
+		if err := os.WriteFile(path, schemas[name], 0644); err != nil {
+			return CRDSchemas{}, fmt.Errorf("failed to write schema %s: %w", name, err)
+		}
+	}
+	if err := os.Rename(tmp, result.Dir); err != nil {
+		// Another run may have renamed an identical set into place first
+		if _, statErr := os.Stat(result.Dir); statErr != nil {
+			return CRDSchemas{}, fmt.Errorf("failed to write schema cache: %w", err)
+		}
+	}
+	return result, nil
+}
+
+// crdSchemas adds the schema of every CRD version in manifest to schemas
+func crdSchemas(manifest string, schemas map[string][]byte) error {
+	decoder := yaml.NewDecoder(strings.NewReader(manifest))
+	for {
+		var crd struct {
+			Kind string `yaml:"kind"`
+			Spec struct {
+				Group string `yaml:"group"`
+				Names struct {
+					Kind string `yaml:"kind"`
+				} `yaml:"names"`
+				Versions []struct {
+					Name   string `yaml:"name"`
+					Schema struct {
+						OpenAPIV3Schema map[string]interface{} `yaml:"openAPIV3Schema"`
+					} `yaml:"schema"`
+				} `yaml:"versions"`
+				// apiextensions.k8s.io/v1beta1: one schema for all versions
+				Version    string `yaml:"version"`
+				Validation struct {
+					OpenAPIV3Schema map[string]interface{} `yaml:"openAPIV3Schema"`
+				} `yaml:"validation"`
+			} `yaml:"spec"`
+		}
+		if err := decoder.Decode(&crd); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return fmt.Errorf("failed to parse CRD manifest: %w", err)
+		}
+		if crd.Kind != "CustomResourceDefinition" || crd.Spec.Group == "" || crd.Spec.Names.Kind == "" {
+			continue
+		}
+
+		versions := make(map[string]map[string]interface{})
+		for _, v := range crd.Spec.Versions {
+			schema := v.Schema.OpenAPIV3Schema
+			if schema == nil {
+				schema = crd.Spec.Validation.OpenAPIV3Schema
+			}
+			versions[v.Name] = schema
+		}
+		if crd.Spec.Version != "" && versions[crd.Spec.Version] == nil {
+			versions[crd.Spec.Version] = crd.Spec.Validation.OpenAPIV3Schema
+		}
+
+		for version, schema := range versions {
+			if schema == nil {
+				continue
+			}
+			data, err := json.MarshalIndent(jsonSchema(schema), "", "  ")
+			if err != nil {
+				return fmt.Errorf("failed to convert %s/%s schema: %w", crd.Spec.Group, crd.Spec.Names.Kind, err)
+			}
+			name := fmt.Sprintf("%s/%s_%s.json", crd.Spec.Group, strings.ToLower(crd.Spec.Names.Kind), version)
+			schemas[name] = data
+		}
+	}
+}
+
+// jsonSchema converts a CRD's openAPIV3Schema into a strict JSON schema the
+// way openapi2jsonschema does: objects with properties reject unknown fields
+// unless they preserve them, nullable fields accept null, and int-or-string
+// fields accept either. apiVersion, kind, and metadata are always allowed at
+// the root
+func jsonSchema(openAPI map[string]interface{}) map[string]interface{} {
+	schema := convertSchema(openAPI).(map[string]interface{})
+	if props, ok := schema["properties"].(map[string]interface{}); ok {
+		for name, typ := range map[string]string{"apiVersion": "string", "kind": "string", "metadata": "object"} {
+			if _, ok := props[name]; !ok {
+				props[name] = map[string]interface{}{"type": typ}
+			}
+		}
+	}
+	return schema
+}
+
+// convertSchema converts one node of an openAPIV3Schema
+func convertSchema(node interface{}) interface{} {
+	switch n := node.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(n))
+		for key, value := range n {
+			switch key {
+			case "properties", "patternProperties", "definitions":
+				props := make(map[string]interface{})
+				if m, ok := value.(map[string]interface{}); ok {
+					for name, prop := range m {
+						props[name] = convertSchema(prop)
+					}
+				}
+				out[key] = props
+			case "items", "additionalProperties", "not", "allOf", "anyOf", "oneOf":
+				out[key] = convertSchema(value)
+			case "nullable":
+				// handled below
+			default:
+				if strings.HasPrefix(key, "x-kubernetes-") {
+					continue
+				}
+				out[key] = value
+			}
+		}
+
+		if n["x-kubernetes-int-or-string"] == true {
+			delete(out, "type")
+			if _, ok := out["anyOf"]; !ok {
+				out["oneOf"] = []interface{}{
+					map[string]interface{}{"type": "string"},
+					map[string]interface{}{"type": "integer"},
+				}
+			}
+		}
+		if n["nullable"] == true {
+			if typ, ok := out["type"].(string); ok {
+				out["type"] = []interface{}{typ, "null"}
+			}
+		}
+		if _, hasProps := out["properties"]; hasProps && n["x-kubernetes-preserve-unknown-fields"] != true {
+			if _, ok := out["additionalProperties"]; !ok {
+				out["additionalProperties"] = false
+			}
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(n))
+		for i, item := range n {
+			out[i] = convertSchema(item)
+		}
+		return out
+	default:
+		return node
+	}
+}
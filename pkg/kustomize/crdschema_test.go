@@ -0,0 +1,172 @@
+package kustomize
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testCRD = `apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: httproutes.gateway.networking.k8s.io
+spec:
+  group: gateway.networking.k8s.io
+  names:
+    kind: HTTPRoute
+  versions:
+    - name: v1
+      schema:
+        openAPIV3Schema:
+          type: object
+          properties:
+            spec:
+              type: object
+              properties:
+                port:
+                  x-kubernetes-int-or-string: true
+                hostnames:
+                  type: array
+                  nullable: true
+                  items:
+                    type: string
+                extra:
+                  type: object
+                  x-kubernetes-preserve-unknown-fields: true
+                  properties:
+                    known:
+                      type: string
+    - name: v1beta1
+      schema:
+        openAPIV3Schema:
+          type: object
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: not-a-crd
+`
+
+func TestGenerateCRDSchemas(t *testing.T) {
+	cache := t.TempDir()
+
+	schemas, err := GenerateCRDSchemas(cache, []string{testCRD, "kind: Deployment\n"})
+	if err != nil {
+		t.Fatalf("GenerateCRDSchemas() error = %v", err)
+	}
+	if schemas.Schemas != 2 {
+		t.Fatalf("Schemas = %d, want 2", schemas.Schemas)
+	}
+	if want := filepath.Join(schemas.Dir, "{{.Group}}/{{.ResourceKind}}_{{.ResourceAPIVersion}}.json"); schemas.Location() != want {
+		t.Errorf("Location() = %q, want %q", schemas.Location(), want)
+	}
+
+	data, err := os.ReadFile(filepath.Join(schemas.Dir, "gateway.networking.k8s.io", "httproute_v1.json"))
+	if err != nil {
+		t.Fatalf("schema not written: %v", err)
+	}
+	var schema map[string]interface{}
+	if err := json.Unmarshal(data, &schema); err != nil {
+		t.Fatalf("invalid schema JSON: %v", err)
+	}
+
+	if schema["additionalProperties"] != false {
+		t.Errorf("root should reject unknown fields: %v", schema["additionalProperties"])
+	}
+	rootProps := schema["properties"].(map[string]interface{})
+	for _, name := range []string{"apiVersion", "kind", "metadata"} {
+		if _, ok := rootProps[name]; !ok {
+			t.Errorf("root is missing %s", name)
+		}
+	}
+	specProps := rootProps["spec"].(map[string]interface{})["properties"].(map[string]interface{})
+	port := specProps["port"].(map[string]interface{})
+	if _, ok := port["oneOf"]; !ok || port["x-kubernetes-int-or-string"] != nil {
+		t.Errorf("int-or-string not converted: %v", port)
+	}
+	hostnames := specProps["hostnames"].(map[string]interface{})
+	if !reflect.DeepEqual(hostnames["type"], []interface{}{"array", "null"}) {
+		t.Errorf("nullable not converted: %v", hostnames["type"])
+	}
+	if _, ok := specProps["extra"].(map[string]interface{})["additionalProperties"]; ok {
+		t.Error("preserve-unknown-fields objects should accept unknown fields")
+	}
+
+	// The same CRDs reuse the cached set
+	again, err := GenerateCRDSchemas(cache, []string{testCRD})
+	if err != nil {
+		t.Fatalf("GenerateCRDSchemas() error = %v", err)
+	}
+	if again.Dir != schemas.Dir {
+		t.Errorf("cache not reused: %s != %s", again.Dir, schemas.Dir)
+	}
+	entries, _ := os.ReadDir(cache)
+	if len(entries) != 1 {
+		t.Errorf("expected one cached schema set, got %d", len(entries))
+	}
+
+	// A changed CRD gets a new set
+	changed, err := GenerateCRDSchemas(cache, []string{strings.Replace(testCRD, "known:", "renamed:", 1)})
+	if err != nil {
+		t.Fatalf("GenerateCRDSchemas() error = %v", err)
+	}
+	if changed.Dir == schemas.Dir {
+		t.Error("expected a changed CRD to produce a new schema set")
+	}
+}
+
+func TestGenerateCRDSchemas_None(t *testing.T) {
+	schemas, err := GenerateCRDSchemas(t.TempDir(), []string{"apiVersion: v1\nkind: ConfigMap\n"})
+	if err != nil {
+		t.Fatalf("GenerateCRDSchemas() error = %v", err)
+	}
+	if schemas.Schemas != 0 || schemas.Location() != "" {
+		t.Errorf("expected no schemas, got %+v", schemas)
+	}
+}
+
+func TestRepoCRDSchemas(t *testing.T) {
+	repo := t.TempDir()
+	dir := filepath.Join(repo, "infrastructure", "crds", "gateway")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "httproute.yaml"), []byte(testCRD), 0644); err != nil {
+		t.Fatal(err)
+	}
+	rendered := strings.NewReplacer("httproutes.gateway", "grpcroutes.gateway", "HTTPRoute", "GRPCRoute").Replace(testCRD)
+
+	schemas, err := RepoCRDSchemas(repo, t.TempDir(), rendered)
+	if err != nil {
+		t.Fatalf("RepoCRDSchemas() error = %v", err)
+	}
+	if schemas.Schemas != 4 {
+		t.Errorf("Schemas = %d, want 4 (2 versions each of HTTPRoute and GRPCRoute)", schemas.Schemas)
+	}
+	if _, err := os.Stat(filepath.Join(schemas.Dir, "gateway.networking.k8s.io", "grpcroute_v1beta1.json")); err != nil {
+		t.Errorf("rendered CRD schema not written: %v", err)
+	}
+}
+
+func TestValidateManifest_SchemaLocations(t *testing.T) {
+	bin := t.TempDir()
+	argsFile := filepath.Join(t.TempDir(), "args")
+	script := "#!/bin/sh\necho \"$@\" > " + argsFile + "\n"
+	if err := os.WriteFile(filepath.Join(bin, "kubeconform"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	runner := NewRunner(".", "", false)
+	runner.SchemaLocations = []string{"/cache/abc/{{.Group}}.json"}
+	if _, err := runner.ValidateManifest("kind: Deployment\n"); err != nil {
+		t.Fatalf("ValidateManifest() error = %v", err)
+	}
+	args, _ := os.ReadFile(argsFile)
+	if !strings.Contains(string(args), "-schema-location default -schema-location /cache/abc/{{.Group}}.json") {
+		t.Errorf("unexpected kubeconform args: %s", args)
+	}
+}
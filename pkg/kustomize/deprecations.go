@@ -0,0 +1,165 @@
+package kustomize
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// APIDeprecation is a group/version/kind that Kubernetes deprecated and later
+// stopped serving
+type APIDeprecation struct {
+	APIVersion   string
+	Kind         string
+	DeprecatedIn string // first Kubernetes minor that warns on it, e.g. "1.21"
+	RemovedIn    string // first Kubernetes minor that no longer serves it, e.g. "1.25"
+	Replacement  string // apiVersion to migrate to ("" when there is none)
+}
+
+// APIDeprecations lists deprecated Kubernetes APIs since 1.16, from the
+// upstream deprecation guide (the same data kubent checks against)
+var APIDeprecations = []APIDeprecation{
+	{"extensions/v1beta1", "Deployment", "1.9", "1.16", "apps/v1"},
+	{"extensions/v1beta1", "DaemonSet", "1.9", "1.16", "apps/v1"},
+	{"extensions/v1beta1", "ReplicaSet", "1.9", "1.16", "apps/v1"},
+	{"extensions/v1beta1", "NetworkPolicy", "1.9", "1.16", "networking.k8s.io/v1"},
+	{"extensions/v1beta1", "PodSecurityPolicy", "1.10", "1.16", "policy/v1beta1"},
+	{"apps/v1beta1", "Deployment", "1.9", "1.16", "apps/v1"},
+	{"apps/v1beta1", "StatefulSet", "1.9", "1.16", "apps/v1"},
+	{"apps/v1beta2", "Deployment", "1.9", "1.16", "apps/v1"},
+	{"apps/v1beta2", "DaemonSet", "1.9", "1.16", "apps/v1"},
+	{"apps/v1beta2", "StatefulSet", "1.9", "1.16", "apps/v1"},
+	{"apps/v1beta2", "ReplicaSet", "1.9", "1.16", "apps/v1"},
+	{"extensions/v1beta1", "Ingress", "1.14", "1.22", "networking.k8s.io/v1"},
+	{"networking.k8s.io/v1beta1", "Ingress", "1.19", "1.22", "networking.k8s.io/v1"},
+	{"networking.k8s.io/v1beta1", "IngressClass", "1.19", "1.22", "networking.k8s.io/v1"},
+	{"apiextensions.k8s.io/v1beta1", "CustomResourceDefinition", "1.16", "1.22", "apiextensions.k8s.io/v1"},
+	{"admissionregistration.k8s.io/v1beta1", "MutatingWebhookConfiguration", "1.16", "1.22", "admissionregistration.k8s.io/v1"},
+	{"admissionregistration.k8s.io/v1beta1", "ValidatingWebhookConfiguration", "1.16", "1.22", "admissionregistration.k8s.io/v1"},
+	{"apiregistration.k8s.io/v1beta1", "APIService", "1.19", "1.22", "apiregistration.k8s.io/v1"},
+	{"rbac.authorization.k8s.io/v1beta1", "ClusterRole", "1.17", "1.22", "rbac.authorization.k8s.io/v1"},
+	{"rbac.authorization.k8s.io/v1beta1", "ClusterRoleBinding", "1.17", "1.22", "rbac.authorization.k8s.io/v1"},
+	{"rbac.authorization.k8s.io/v1beta1", "Role", "1.17", "1.22", "rbac.authorization.k8s.io/v1"},
+	{"rbac.authorization.k8s.io/v1beta1", "RoleBinding", "1.17", "1.22", "rbac.authorization.k8s.io/v1"},
+	{"scheduling.k8s.io/v1beta1", "PriorityClass", "1.14", "1.22", "scheduling.k8s.io/v1"},
+	{"storage.k8s.io/v1beta1", "CSIDriver", "1.19", "1.22", "storage.k8s.io/v1"},
+	{"storage.k8s.io/v1beta1", "CSINode", "1.17", "1.22", "storage.k8s.io/v1"},
+	{"storage.k8s.io/v1beta1", "StorageClass", "1.19", "1.22", "storage.k8s.io/v1"},
+	{"storage.k8s.io/v1beta1", "VolumeAttachment", "1.19", "1.22", "storage.k8s.io/v1"},
+	{"coordination.k8s.io/v1beta1", "Lease", "1.19", "1.22", "coordination.k8s.io/v1"},
+	{"certificates.k8s.io/v1beta1", "CertificateSigningRequest", "1.19", "1.22", "certificates.k8s.io/v1"},
+	{"batch/v1beta1", "CronJob", "1.21", "1.25", "batch/v1"},
+	{"discovery.k8s.io/v1beta1", "EndpointSlice", "1.21", "1.25", "discovery.k8s.io/v1"},
+	{"events.k8s.io/v1beta1", "Event", "1.19", "1.25", "events.k8s.io/v1"},
+	{"autoscaling/v2beta1", "HorizontalPodAutoscaler", "1.22", "1.25", "autoscaling/v2"},
+	{"policy/v1beta1", "PodDisruptionBudget", "1.21", "1.25", "policy/v1"},
+	{"policy/v1beta1", "PodSecurityPolicy", "1.21", "1.25", ""},
+	{"node.k8s.io/v1beta1", "RuntimeClass", "1.20", "1.25", "node.k8s.io/v1"},
+	{"autoscaling/v2beta2", "HorizontalPodAutoscaler", "1.23", "1.26", "autoscaling/v2"},
+	{"flowcontrol.apiserver.k8s.io/v1beta1", "FlowSchema", "1.23", "1.26", "flowcontrol.apiserver.k8s.io/v1"},
+	{"flowcontrol.apiserver.k8s.io/v1beta1", "PriorityLevelConfiguration", "1.23", "1.26", "flowcontrol.apiserver.k8s.io/v1"},
+	{"storage.k8s.io/v1beta1", "CSIStorageCapacity", "1.24", "1.27", "storage.k8s.io/v1"},
+	{"flowcontrol.apiserver.k8s.io/v1beta2", "FlowSchema", "1.26", "1.29", "flowcontrol.apiserver.k8s.io/v1"},
+	{"flowcontrol.apiserver.k8s.io/v1beta2", "PriorityLevelConfiguration", "1.26", "1.29", "flowcontrol.apiserver.k8s.io/v1"},
+	{"flowcontrol.apiserver.k8s.io/v1beta3", "FlowSchema", "1.29", "1.32", "flowcontrol.apiserver.k8s.io/v1"},
+	{"flowcontrol.apiserver.k8s.io/v1beta3", "PriorityLevelConfiguration", "1.29", "1.32", "flowcontrol.apiserver.k8s.io/v1"},
+}
+
+// DeprecatedAPI is a rendered resource whose apiVersion is deprecated in, or
+// no longer served by, the target Kubernetes version
+type DeprecatedAPI struct {
+	APIVersion   string `json:"apiVersion"`
+	Kind         string `json:"kind"`
+	Name         string `json:"name"`
+	Namespace    string `json:"namespace,omitempty"`
+	DeprecatedIn string `json:"deprecatedIn"`
+	RemovedIn    string `json:"removedIn"`
+	Replacement  string `json:"replacement,omitempty"`
+	Removed      bool   `json:"removed"` // the target version no longer serves it
+}
+
+// String describes the resource and what to migrate it to
+func (d DeprecatedAPI) String() string {
+	var msg string
+	if d.Removed {
+		msg = fmt.Sprintf("%s/%s uses %s, removed in Kubernetes %s", d.Kind, d.Name, d.APIVersion, d.RemovedIn)
+	} else {
+		msg = fmt.Sprintf("%s/%s uses %s, deprecated in Kubernetes %s and removed in %s", d.Kind, d.Name, d.APIVersion, d.DeprecatedIn, d.RemovedIn)
+	}
+	if d.Replacement != "" {
+		msg += fmt.Sprintf(" (use %s)", d.Replacement)
+	}
+	return msg
+}
+
+// FindDeprecatedAPIs returns the resources in a rendered manifest whose
+// apiVersion the target Kubernetes version (e.g. "1.32" or "1.32.1")
+// deprecates or no longer serves
+func FindDeprecatedAPIs(manifest, target string) ([]DeprecatedAPI, error) {
+	targetMinor, err := KubeMinor(target)
+	if err != nil {
+		return nil, err
+	}
+
+	var found []DeprecatedAPI
+	decoder := yaml.NewDecoder(strings.NewReader(manifest))
+	for {
+		var doc struct {
+			APIVersion string `yaml:"apiVersion"`
+			Kind       string `yaml:"kind"`
+			Metadata   struct {
+				Name      string `yaml:"name"`
+				Namespace string `yaml:"namespace"`
+			} `yaml:"metadata"`
+		}
+		if err := decoder.Decode(&doc); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, fmt.Errorf("failed to parse manifest: %w", err)
+		}
+
+		for _, api := range APIDeprecations {
+			if api.APIVersion != doc.APIVersion || api.Kind != doc.Kind {
+				continue
+			}
+			deprecated, _ := KubeMinor(api.DeprecatedIn)
+			if deprecated > targetMinor {
+				continue
+			}
+			removed, _ := KubeMinor(api.RemovedIn)
+			found = append(found, DeprecatedAPI{
+				APIVersion:   api.APIVersion,
+				Kind:         api.Kind,
+				Name:         doc.Metadata.Name,
+				Namespace:    doc.Metadata.Namespace,
+				DeprecatedIn: api.DeprecatedIn,
+				RemovedIn:    api.RemovedIn,
+				Replacement:  api.Replacement,
+				Removed:      removed <= targetMinor,
+			})
+		}
+	}
+
+	return found, nil
+}
+
+// KubeMinor returns the minor version of a 1.x Kubernetes version string
+// Accepts "1.32", "v1.32", and "1.32.1"
+func KubeMinor(version string) (int, error) {
+	parts := strings.Split(strings.TrimPrefix(version, "v"), ".")
+	if len(parts) < 2 || len(parts) > 3 || parts[0] != "1" {
+		return 0, fmt.Errorf("invalid Kubernetes version %q (expected 1.<minor>[.<patch>])", version)
+	}
+	for _, p := range parts[1:] {
+		if _, err := strconv.Atoi(p); err != nil {
+			return 0, fmt.Errorf("invalid Kubernetes version %q (expected 1.<minor>[.<patch>])", version)
+		}
+	}
+	minor, _ := strconv.Atoi(parts[1])
+	return minor, nil
+}
@@ -0,0 +1,73 @@
+package kustomize
+
+import "testing"
+
+func TestFindDeprecatedAPIs(t *testing.T) {
+	manifest := `apiVersion: batch/v1beta1
+kind: CronJob
+metadata:
+  name: backup
+  namespace: ops
+---
+apiVersion: flowcontrol.apiserver.k8s.io/v1beta3
+kind: FlowSchema
+metadata:
+  name: exempt
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+`
+
+	tests := []struct {
+		target  string
+		want    []string // Kind/name of each finding
+		removed []bool
+	}{
+		{"1.20", nil, nil},
+		{"1.21", []string{"CronJob/backup"}, []bool{false}},
+		{"1.29.4", []string{"CronJob/backup", "FlowSchema/exempt"}, []bool{true, false}},
+		{"v1.32", []string{"CronJob/backup", "FlowSchema/exempt"}, []bool{true, true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			found, err := FindDeprecatedAPIs(manifest, tt.target)
+			if err != nil {
+				t.Fatalf("FindDeprecatedAPIs() error = %v", err)
+			}
+			if len(found) != len(tt.want) {
+				t.Fatalf("got %d findings, want %d: %+v", len(found), len(tt.want), found)
+			}
+			for i, f := range found {
+				if got := f.Kind + "/" + f.Name; got != tt.want[i] {
+					t.Errorf("finding %d = %s, want %s", i, got, tt.want[i])
+				}
+				if f.Removed != tt.removed[i] {
+					t.Errorf("%s Removed = %v, want %v", tt.want[i], f.Removed, tt.removed[i])
+				}
+			}
+		})
+	}
+
+	found, _ := FindDeprecatedAPIs(manifest, "1.21")
+	if found[0].Namespace != "ops" || found[0].Replacement != "batch/v1" {
+		t.Errorf("unexpected finding: %+v", found[0])
+	}
+	if _, err := FindDeprecatedAPIs(manifest, "2.0"); err == nil {
+		t.Error("expected an error for an invalid Kubernetes version")
+	}
+}
+
+func TestDeprecatedAPIString(t *testing.T) {
+	d := DeprecatedAPI{APIVersion: "policy/v1beta1", Kind: "PodSecurityPolicy", Name: "restricted", DeprecatedIn: "1.21", RemovedIn: "1.25"}
+	if got, want := d.String(), "PodSecurityPolicy/restricted uses policy/v1beta1, deprecated in Kubernetes 1.21 and removed in 1.25"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+	d.Removed = true
+	d.Replacement = "policy/v1"
+	if got, want := d.String(), "PodSecurityPolicy/restricted uses policy/v1beta1, removed in Kubernetes 1.25 (use policy/v1)"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
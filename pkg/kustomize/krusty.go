@@ -0,0 +1,36 @@
+//go:build krusty
+
+package kustomize
+
+import (
+	"sigs.k8s.io/kustomize/api/krusty"
+	"sigs.k8s.io/kustomize/api/types"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+)
+
+// KrustyAvailable reports whether this binary was built with in-process kustomize support
+const KrustyAvailable = true
+
+// buildKrusty builds a kustomization in-process
+// Options mirror the CLI flags used by BuildDirectory (and ArgoCD's kustomize.buildOptions):
+// --load-restrictor=LoadRestrictionsNone --enable-helm --enable-alpha-plugins --enable-exec
+func buildKrusty(absDir string) (string, error) {
+	opts := krusty.MakeDefaultOptions()
+	opts.LoadRestrictions = types.LoadRestrictionsNone
+	opts.PluginConfig = types.EnabledPluginConfig(types.BploUseStaticallyLinked)
+	opts.PluginConfig.HelmConfig.Enabled = true
+	opts.PluginConfig.HelmConfig.Command = "helm"
+	opts.PluginConfig.FnpLoadingOptions.EnableExec = true
+
+	k := krusty.MakeKustomizer(opts)
+	resMap, err := k.Run(filesys.MakeFsOnDisk(), absDir)
+	if err != nil {
+		return "", err
+	}
+
+	out, err := resMap.AsYaml()
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
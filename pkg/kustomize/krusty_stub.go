@@ -0,0 +1,13 @@
+//go:build !krusty
+
+package kustomize
+
+import "fmt"
+
+// KrustyAvailable reports whether this binary was built with in-process kustomize support
+const KrustyAvailable = false
+
+// buildKrusty is a stub used when the binary is built without -tags krusty
+func buildKrusty(absDir string) (string, error) {
+	return "", fmt.Errorf("in-process kustomize is not available in this build (rebuild with -tags krusty)")
+}
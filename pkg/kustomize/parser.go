@@ -136,3 +136,23 @@ func FormatValidationError(result ValidationResult) string {
 
 	return strings.Join(parts, "\n")
 }
+
+// SummarizeSchemaErrors condenses kubeconform output into a single line
+// Example: "Deployment coder: missing properties 'selector'; Service coder: ..."
+func SummarizeSchemaErrors(output string) string {
+	errors := ParseKubeconformErrors(output)
+	if len(errors) > 0 {
+		parts := make([]string, 0, len(errors))
+		for _, e := range errors {
+			parts = append(parts, fmt.Sprintf("%s: %s", e.Resource, e.Message))
+		}
+		return strings.Join(parts, "; ")
+	}
+
+	summary := ParseKubeconformSummary(output)
+	if summary.Resources > 0 {
+		return fmt.Sprintf("Invalid: %d, Errors: %d", summary.Invalid, summary.Errors)
+	}
+
+	return strings.TrimSpace(output)
+}
@@ -0,0 +1,101 @@
+package kustomize
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// postRenderResource is the file the Helm output is written to in the wrapper
+const postRenderResource = "helm-output.yaml"
+
+// postRenderKustomization is the wrapper PostRender builds around Helm output
+type postRenderKustomization struct {
+	APIVersion string   `yaml:"apiVersion"`
+	Kind       string   `yaml:"kind"`
+	Resources  []string `yaml:"resources"`
+	Components []string `yaml:"components"`
+}
+
+// PostRender pipes rendered manifests (typically helm template output)
+// through the kustomize Component at dir, the way helm --post-renderer with
+// kustomize would. The manifests and a kustomization listing them plus the
+// component are written to a temporary directory, so the repo is never
+// modified and the component's patches resolve relative to dir as usual
+func (r *Runner) PostRender(manifest, dir string) BuildResult {
+	return r.PostRenderContext(context.Background(), manifest, dir)
+}
+
+// PostRenderContext is PostRender, killing an exec build when ctx is done
+func (r *Runner) PostRenderContext(ctx context.Context, manifest, dir string) BuildResult {
+	result := BuildResult{Directory: dir}
+	absDir, err := filepath.Abs(filepath.Join(r.RepoPath, dir))
+	if err != nil {
+		result.Error = err
+		return result
+	}
+	if reason := skipReason(absDir); reason != "" {
+		result.Error = fmt.Errorf("post-render overlay %s: %s", dir, reason)
+		return result
+	}
+	if kind, err := kustomizationKind(filepath.Join(absDir, "kustomization.yaml")); err != nil {
+		result.Error = fmt.Errorf("post-render overlay %s: %w", dir, err)
+		return result
+	} else if kind != "Component" {
+		result.Error = fmt.Errorf("post-render overlay %s must be a kustomize Component (kind: Component), got %s", dir, kind)
+		return result
+	}
+
+	wrapper, err := os.MkdirTemp("", "shadow-post-render-*")
+	if err != nil {
+		result.Error = fmt.Errorf("failed to create post-render kustomization: %w", err)
+		return result
+	}
+	defer os.RemoveAll(wrapper)
+
+	rel, err := filepath.Rel(wrapper, absDir)
+	if err != nil {
+		result.Error = err
+		return result
+	}
+	data, err := yaml.Marshal(postRenderKustomization{
+		APIVersion: "kustomize.config.k8s.io/v1beta1",
+		Kind:       "Kustomization",
+		Resources:  []string{postRenderResource},
+		Components: []string{filepath.ToSlash(rel)},
+	})
+	if err != nil {
+		result.Error = err
+		return result
+	}
+	if err := os.WriteFile(filepath.Join(wrapper, postRenderResource), []byte(manifest), 0644); err != nil {
+		result.Error = fmt.Errorf("failed to write post-render input: %w", err)
+		return result
+	}
+	if err := os.WriteFile(filepath.Join(wrapper, "kustomization.yaml"), data, 0644); err != nil {
+		result.Error = fmt.Errorf("failed to write post-render kustomization: %w", err)
+		return result
+	}
+	return r.build(ctx, wrapper, result)
+}
+
+// kustomizationKind returns the kind of a kustomization file ("Kustomization" when unset)
+func kustomizationKind(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	var k struct {
+		Kind string `yaml:"kind"`
+	}
+	if err := yaml.Unmarshal(data, &k); err != nil {
+		return "", fmt.Errorf("failed to parse %s: %w", filepath.Base(path), err)
+	}
+	if k.Kind == "" {
+		return "Kustomization", nil
+	}
+	return k.Kind, nil
+}
@@ -0,0 +1,48 @@
+package kustomize
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPostRender(t *testing.T) {
+	repo := t.TempDir()
+	writeFile := func(rel, content string) {
+		t.Helper()
+		if err := os.MkdirAll(filepath.Join(repo, filepath.Dir(rel)), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(repo, rel), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeFile("apps/demo/post-render/kustomization.yaml", "apiVersion: kustomize.config.k8s.io/v1alpha1\nkind: Component\npatches:\n  - path: patch.yaml\n")
+	writeFile("apps/demo/plain/kustomization.yaml", "resources: []\n")
+
+	// Prints the wrapper, the Helm output, and the component through its relative path
+	bin := filepath.Join(t.TempDir(), "kustomize")
+	script := "#!/bin/sh\nfor last; do :; done\ncat \"$last/kustomization.yaml\" \"$last/helm-output.yaml\"\ncomp=$(sed -n '/^components:/,$s/^ *- //p' \"$last/kustomization.yaml\")\ncat \"$last/$comp/kustomization.yaml\"\n"
+	if err := os.WriteFile(bin, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	runner := NewRunner(repo, "", false)
+	runner.Binary = bin
+
+	result := runner.PostRender("kind: Deployment\nmetadata:\n  name: demo\n", "apps/demo/post-render")
+	if !result.Passed {
+		t.Fatalf("PostRender() failed: %v\n%s", result.Error, result.Output)
+	}
+	for _, want := range []string{"- helm-output.yaml", "/apps/demo/post-render", "name: demo", "kind: Component"} {
+		if !strings.Contains(result.Output, want) {
+			t.Errorf("expected %q in output:\n%s", want, result.Output)
+		}
+	}
+
+	for _, dir := range []string{"apps/demo/plain", "apps/demo/missing"} {
+		if result := runner.PostRender("kind: Deployment\n", dir); result.Passed || result.Error == nil {
+			t.Errorf("expected PostRender through %s to fail", dir)
+		}
+	}
+}
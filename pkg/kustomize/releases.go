@@ -0,0 +1,142 @@
+package kustomize
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// releasesAPIURL lists kustomize releases (overridden in tests)
+var releasesAPIURL = "https://api.github.com/repos/kubernetes-sigs/kustomize/releases?per_page=100"
+
+// releasesClient bounds the releases listing, which falls back to the cache
+// when GitHub is slow or unreachable
+var releasesClient = &http.Client{Timeout: 30 * time.Second}
+
+// ResolveVersion returns the full kustomize version for version (v5.4.3 or
+// 5.4.3), for toolcache to download. Partial versions (5.3) resolve to the
+// newest matching release; toolsDir is the toolcache directory
+func ResolveVersion(toolsDir, version string) (string, error) {
+	version = strings.TrimPrefix(strings.TrimSpace(version), "v")
+	parts, err := parseVersion(version)
+	if err != nil {
+		return "", err
+	}
+	if len(parts) == 3 {
+		return version, nil
+	}
+	return resolveVersion(toolsDir, version)
+}
+
+// resolveVersion finds the newest release matching a major.minor prefix,
+// falling back to already-downloaded releases when GitHub is unreachable
+func resolveVersion(toolsDir, prefix string) (string, error) {
+	versions, err := listReleases()
+	if err != nil {
+		cached, _ := os.ReadDir(filepath.Join(toolsDir, "kustomize"))
+		for _, entry := range cached {
+			versions = append(versions, entry.Name())
+		}
+		if len(versions) == 0 {
+			return "", fmt.Errorf("failed to list kustomize releases: %w", err)
+		}
+	}
+
+	best := ""
+	for _, v := range versions {
+		if !strings.HasPrefix(v, prefix+".") {
+			continue
+		}
+		if best == "" || compareVersions(v, best) > 0 {
+			best = v
+		}
+	}
+	if best == "" {
+		return "", fmt.Errorf("no kustomize release matches %s", prefix)
+	}
+	return best, nil
+}
+
+// listReleases returns kustomize release versions (without the v prefix)
+// The repo also tags api/ and kyaml/ modules, which are skipped
+func listReleases() ([]string, error) {
+	req, err := http.NewRequest("GET", releasesAPIURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/vnd.github.v3+json")
+	req.Header.Set("User-Agent", "shadow-compat")
+	if token := os.Getenv("GH_TOKEN"); token != "" {
+		req.Header.Set("Authorization", "token "+token)
+	}
+
+	resp, err := releasesClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GitHub API returned %d", resp.StatusCode)
+	}
+
+	var releases []struct {
+		TagName string `json:"tag_name"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&releases); err != nil {
+		return nil, err
+	}
+
+	var versions []string
+	for _, r := range releases {
+		if v, ok := strings.CutPrefix(r.TagName, "kustomize/v"); ok {
+			versions = append(versions, v)
+		}
+	}
+	sort.Slice(versions, func(i, j int) bool { return compareVersions(versions[i], versions[j]) > 0 })
+	return versions, nil
+}
+
+// parseVersion parses 1-3 numeric dot-separated components (5, 5.3, 5.3.0)
+func parseVersion(v string) ([]int, error) {
+	fields := strings.Split(v, ".")
+	if v == "" || len(fields) > 3 {
+		return nil, fmt.Errorf("invalid kustomize version %q", v)
+	}
+	parts := make([]int, len(fields))
+	for i, f := range fields {
+		n, err := strconv.Atoi(f)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid kustomize version %q", v)
+		}
+		parts[i] = n
+	}
+	return parts, nil
+}
+
+// compareVersions compares dotted numeric versions; unparseable versions sort first
+func compareVersions(a, b string) int {
+	pa, _ := parseVersion(a)
+	pb, _ := parseVersion(b)
+	for i := 0; i < max(len(pa), len(pb)); i++ {
+		var x, y int
+		if i < len(pa) {
+			x = pa[i]
+		}
+		if i < len(pb) {
+			y = pb[i]
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
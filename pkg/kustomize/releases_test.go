@@ -0,0 +1,94 @@
+package kustomize
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeReleases serves a releases API listing the versions
+func fakeReleases(t *testing.T, versions ...string) {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var tags []string
+		for _, v := range versions {
+			tags = append(tags, fmt.Sprintf(`{"tag_name": "kustomize/v%s"}`, v))
+		}
+		tags = append(tags, `{"tag_name": "api/v0.17.0"}`)
+		fmt.Fprintf(w, "[%s]", strings.Join(tags, ","))
+	}))
+	t.Cleanup(server.Close)
+
+	oldAPI := releasesAPIURL
+	releasesAPIURL = server.URL
+	t.Cleanup(func() { releasesAPIURL = oldAPI })
+}
+
+func TestResolveVersion(t *testing.T) {
+	fakeReleases(t, "5.4.3", "5.4.2", "5.3.0")
+	tools := t.TempDir()
+
+	if resolved, err := ResolveVersion(tools, "v5.4"); err != nil || resolved != "5.4.3" {
+		t.Errorf("ResolveVersion(v5.4) = %q, %v; want newest 5.4 patch", resolved, err)
+	}
+	if resolved, err := ResolveVersion(tools, " v5.3.0 "); err != nil || resolved != "5.3.0" {
+		t.Errorf("ResolveVersion(5.3.0) = %q, %v", resolved, err)
+	}
+	// Exact versions don't need the releases listing
+	releasesAPIURL = "http://127.0.0.1:0"
+	if resolved, err := ResolveVersion(tools, "5.9.1"); err != nil || resolved != "5.9.1" {
+		t.Errorf("ResolveVersion(5.9.1) = %q, %v", resolved, err)
+	}
+}
+
+func TestResolveVersion_CachedFallback(t *testing.T) {
+	fakeReleases(t)
+	releasesAPIURL = "http://127.0.0.1:0"
+	tools := t.TempDir()
+	for _, v := range []string{"5.4.1", "5.4.2"} {
+		if err := os.MkdirAll(filepath.Join(tools, "kustomize", v), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if resolved, err := ResolveVersion(tools, "5.4"); err != nil || resolved != "5.4.2" {
+		t.Errorf("ResolveVersion(5.4) = %q, %v; want the newest cached release", resolved, err)
+	}
+	if _, err := ResolveVersion(t.TempDir(), "5.4"); err == nil {
+		t.Error("expected error with GitHub unreachable and nothing cached")
+	}
+}
+
+func TestResolveVersion_Errors(t *testing.T) {
+	fakeReleases(t, "5.4.3")
+	tools := t.TempDir()
+
+	for _, v := range []string{"", "five", "5.4.3.1"} {
+		if _, err := ResolveVersion(tools, v); err == nil {
+			t.Errorf("expected error for version %q", v)
+		}
+	}
+	if _, err := ResolveVersion(tools, "4.9"); err == nil {
+		t.Error("expected error when no release matches")
+	}
+}
+
+func TestCompareVersions_Semver(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"5.4.3", "5.4.2", 1},
+		{"5.10.0", "5.9.9", 1},
+		{"5.3", "5.3.0", 0},
+		{"4.5.7", "5.0.0", -1},
+	}
+	for _, tt := range tests {
+		if got := compareVersions(tt.a, tt.b); got != tt.want {
+			t.Errorf("compareVersions(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package kustomize
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/erauner/homelab-shadow/pkg/cachedir"
+	"github.com/erauner/homelab-shadow/pkg/log"
+)
+
+// resultCacheVersion invalidates cache entries written by an older format
+const resultCacheVersion = 1
+
+// ResultCache stores passing ValidateDirectory results keyed by a hash of
+// everything the build reads: the directory and every local kustomization it
+// references (all files under each, recursively), plus patches, generator
+// files, and Helm value files outside them. The runner's Kubernetes version,
+// engine, kustomize binary, and schema locations are part of the key
+//
+// Failures are never cached, so a flaky chart fetch doesn't stick. Remote
+// resources are keyed only by their reference in the kustomization
+type ResultCache struct {
+	Dir string
+	Log *log.Logger // cache hits and misses are logged at debug level
+}
+
+// NewResultCache creates a result cache rooted at dir
+func NewResultCache(dir string, verbose bool) *ResultCache {
+	return &ResultCache{
+		Dir: dir,
+		Log: log.Default().Named("kustomize").Verbose(verbose),
+	}
+}
+
+// DefaultResultCacheDir returns the default validation result cache directory
+func DefaultResultCacheDir() string {
+	return cachedir.Path("results")
+}
+
+// resultEntry is the cached form of a passing ValidationResult
+type resultEntry struct {
+	Version        int             `json:"version"`
+	BuildOutput    string          `json:"buildOutput"`
+	SchemaOutput   string          `json:"schemaOutput"`
+	DeprecatedAPIs []DeprecatedAPI `json:"deprecatedAPIs,omitempty"`
+}
+
+// Key hashes the inputs of validating dir with r
+func (c *ResultCache) Key(r *Runner, dir string) (string, error) {
+	files, err := inputFiles(r.RepoPath, filepath.ToSlash(filepath.Clean(dir)))
+	if err != nil {
+		return "", err
+	}
+
+	h := sha256.New()
+	fmt.Fprintf(h, "v%d\x00%s\x00%s\x00%s\x00%s\x00", resultCacheVersion, r.KubernetesVersion, r.Engine, r.Binary, strings.Join(r.SchemaLocations, "\x00"))
+	for _, file := range files {
+		f, err := os.Open(filepath.Join(r.RepoPath, filepath.FromSlash(file)))
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(h, "%s\x00", file)
+		_, err = io.Copy(h, f)
+		f.Close()
+		if err != nil {
+			return "", err
+		}
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Lookup returns the cached result for key
+func (c *ResultCache) Lookup(key, dir string) (ValidationResult, bool) {
+	data, err := os.ReadFile(c.path(key))
+	if err != nil {
+		c.Log.Debugf("cache miss: %s", dir)
+		return ValidationResult{}, false
+	}
+	var entry resultEntry
+	if json.Unmarshal(data, &entry) != nil || entry.Version != resultCacheVersion {
+		c.Log.Debugf("cache miss: %s (outdated entry)", dir)
+		return ValidationResult{}, false
+	}
+	c.Log.Debugf("cache hit: %s", dir)
+	return ValidationResult{
+		Directory:      dir,
+		BuildPassed:    true,
+		BuildOutput:    entry.BuildOutput,
+		SchemaPassed:   true,
+		SchemaOutput:   entry.SchemaOutput,
+		DeprecatedAPIs: entry.DeprecatedAPIs,
+		Cached:         true,
+	}, true
+}
+
+// Store caches result under key if it passed
+func (c *ResultCache) Store(key string, result ValidationResult) error {
+	if result.Skipped || !result.Passed() {
+		return nil
+	}
+	data, err := json.Marshal(resultEntry{
+		Version:        resultCacheVersion,
+		BuildOutput:    result.BuildOutput,
+		SchemaOutput:   result.SchemaOutput,
+		DeprecatedAPIs: result.DeprecatedAPIs,
+	})
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(c.Dir, 0755); err != nil {
+		return fmt.Errorf("failed to create result cache: %w", err)
+	}
+	// Write then rename so concurrent runs never read a partial entry
+	tmp, err := os.CreateTemp(c.Dir, ".entry-*")
+	if err != nil {
+		return fmt.Errorf("failed to write result cache: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write result cache: %w", err)
+	}
+	tmp.Close()
+	return os.Rename(tmp.Name(), c.path(key))
+}
+
+// path returns the entry file for key
+func (c *ResultCache) path(key string) string {
+	return filepath.Join(c.Dir, key+".json")
+}
+
+// inputFiles returns the sorted repo-relative files a build of dir reads
+func inputFiles(repoPath, dir string) ([]string, error) {
+	refs := newRefCache(repoPath)
+	seen := make(map[string]bool)
+	add := func(file string) {
+		if strings.HasPrefix(file, "../") || seen[file] {
+			return
+		}
+		if info, err := os.Stat(filepath.Join(repoPath, filepath.FromSlash(file))); err == nil && info.Mode().IsRegular() {
+			seen[file] = true
+		}
+	}
+
+	for d := range refs.reachable(dir) {
+		root := filepath.Join(repoPath, filepath.FromSlash(d))
+		err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if path != root && strings.HasPrefix(entry.Name(), ".") {
+				if entry.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if entry.Type().IsRegular() {
+				rel, err := filepath.Rel(repoPath, path)
+				if err != nil {
+					return err
+				}
+				add(filepath.ToSlash(rel))
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to hash %s: %w", d, err)
+		}
+
+		for _, file := range refs.get(d).files() {
+			if strings.Contains(file, "://") {
+				continue
+			}
+			add(filepath.ToSlash(filepath.Clean(filepath.Join(d, file))))
+		}
+	}
+
+	files := make([]string, 0, len(seen))
+	for file := range seen {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+	return files, nil
+}
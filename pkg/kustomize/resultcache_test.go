@@ -0,0 +1,112 @@
+package kustomize
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidateDirectory_Cache(t *testing.T) {
+	repo := writeRepo(t, map[string]string{
+		"apps/web/base/kustomization.yaml":                             "resources:\n  - deployment.yaml\n",
+		"apps/web/base/deployment.yaml":                                "kind: Deployment\n",
+		"apps/web/overlays/erauner-home/production/kustomization.yaml": "resources:\n  - ../../../base\npatches:\n  - path: ../../../../../shared/patch.yaml\n",
+		"shared/patch.yaml":                                            "kind: Deployment\n",
+		"unrelated/file.yaml":                                          "kind: ConfigMap\n",
+	})
+
+	// Stubs log each kustomize build so cache hits are visible
+	bin := t.TempDir()
+	calls := filepath.Join(t.TempDir(), "calls")
+	stubs := map[string]string{
+		"kustomize":   "#!/bin/sh\necho build >> " + calls + "\necho 'kind: Deployment'\n[ ! -f \"$SHADOW_TEST_FAIL\" ]\n",
+		"kubeconform": "#!/bin/sh\necho 'Summary: 1 resource found - Valid: 1'\n",
+	}
+	for name, script := range stubs {
+		if err := os.WriteFile(filepath.Join(bin, name), []byte(script), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+	failFile := filepath.Join(t.TempDir(), "fail")
+	t.Setenv("SHADOW_TEST_FAIL", failFile)
+
+	runner := NewRunner(repo, "", false)
+	runner.Cache = NewResultCache(t.TempDir(), false)
+	dir := "apps/web/overlays/erauner-home/production"
+
+	builds := func() int {
+		data, _ := os.ReadFile(calls)
+		return strings.Count(string(data), "build")
+	}
+	validate := func(step string, wantCached bool, wantBuilds int) ValidationResult {
+		t.Helper()
+		result := runner.ValidateDirectory(dir)
+		if result.Cached != wantCached {
+			t.Errorf("%s: Cached = %v, want %v", step, result.Cached, wantCached)
+		}
+		if got := builds(); got != wantBuilds {
+			t.Errorf("%s: %d kustomize build(s), want %d", step, got, wantBuilds)
+		}
+		return result
+	}
+	write := func(file, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(repo, file), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	validate("first run", false, 1)
+	result := validate("unchanged", true, 1)
+	if !result.Passed() || !strings.Contains(result.BuildOutput, "kind: Deployment") {
+		t.Errorf("cached result = %+v, want passing with build output", result)
+	}
+
+	write("unrelated/file.yaml", "kind: Secret\n")
+	validate("unrelated change", true, 1)
+
+	write("apps/web/base/deployment.yaml", "kind: Deployment\nmetadata:\n  name: web\n")
+	validate("base changed", false, 2)
+
+	write("shared/patch.yaml", "kind: Deployment\nmetadata:\n  name: patched\n")
+	validate("patch changed", false, 3)
+
+	runner.KubernetesVersion = "1.32.0"
+	validate("kubernetes version changed", false, 4)
+
+	// Failures are rebuilt every time
+	write("apps/web/base/deployment.yaml", "kind: Deployment\n# broken\n")
+	if err := os.WriteFile(failFile, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	validate("failing", false, 5)
+	validate("still failing", false, 6)
+}
+
+func TestInputFiles(t *testing.T) {
+	repo := writeRepo(t, map[string]string{
+		"apps/web/base/kustomization.yaml": "resources:\n  - deployment.yaml\n  - https://example.com/remote.yaml\n" +
+			"helmCharts:\n  - name: web\n    valuesFile: ../../../values/web.yaml\n" +
+			"configMapGenerator:\n  - name: cfg\n    files:\n      - app.conf=../../../config/app.conf\n",
+		"apps/web/base/deployment.yaml": "kind: Deployment\n",
+		"apps/web/base/.hidden/x.yaml":  "ignored\n",
+		"values/web.yaml":               "replicas: 1\n",
+		"config/app.conf":               "key=value\n",
+	})
+
+	got, err := inputFiles(repo, "apps/web/base")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"apps/web/base/deployment.yaml",
+		"apps/web/base/kustomization.yaml",
+		"config/app.conf",
+		"values/web.yaml",
+	}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("inputFiles() = %v, want %v", got, want)
+	}
+}
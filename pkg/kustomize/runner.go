@@ -2,12 +2,17 @@
 package kustomize
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"sort"
 	"strings"
+
+	"github.com/erauner/homelab-shadow/pkg/command"
+	"github.com/erauner/homelab-shadow/pkg/log"
+	"github.com/erauner/homelab-shadow/pkg/progress"
 )
 
 // BuildResult represents the result of building a single kustomization directory
@@ -32,6 +37,11 @@ type ValidationResult struct {
 	SchemaError  error
 	Skipped      bool
 	SkipReason   string
+	Cached       bool // returned from the runner's ResultCache without building
+
+	// DeprecatedAPIs are built resources using APIs deprecated or removed
+	// in the runner's KubernetesVersion
+	DeprecatedAPIs []DeprecatedAPI
 }
 
 // Passed returns true if both build and schema validation passed
@@ -39,11 +49,52 @@ func (r *ValidationResult) Passed() bool {
 	return r.Skipped || (r.BuildPassed && r.SchemaPassed)
 }
 
+// Engine selects how kustomize builds are executed
+type Engine string
+
+const (
+	// EngineExec shells out to the kustomize binary on PATH (default)
+	EngineExec Engine = "exec"
+	// EngineKrusty builds in-process with sigs.k8s.io/kustomize/api/krusty
+	// Requires a binary built with -tags krusty
+	EngineKrusty Engine = "krusty"
+	// EngineAuto uses the kustomize binary when installed and falls back to krusty
+	EngineAuto Engine = "auto"
+)
+
+// ParseEngine converts a flag value into an Engine
+func ParseEngine(s string) (Engine, error) {
+	switch Engine(s) {
+	case "", EngineExec:
+		return EngineExec, nil
+	case EngineKrusty, EngineAuto:
+		return Engine(s), nil
+	default:
+		return "", fmt.Errorf("unknown kustomize engine %q (expected exec, krusty, or auto)", s)
+	}
+}
+
 // Runner runs kustomize build and kubeconform validation
 type Runner struct {
 	RepoPath          string
 	KubernetesVersion string
-	Verbose           bool
+	Log               *log.Logger // commands are logged at debug level
+
+	// Engine selects exec vs in-process builds (default: EngineExec)
+	Engine Engine
+
+	// Binary is the kustomize executable for exec builds (default: "kustomize" on PATH)
+	Binary string
+
+	// SchemaLocations are extra kubeconform -schema-location templates (e.g.
+	// CRDSchemas.Location), tried after the default Kubernetes schemas
+	SchemaLocations []string
+
+	// Cache returns passing ValidateDirectory results for unchanged inputs (nil: disabled)
+	Cache *ResultCache
+
+	// Progress reports ValidateAll's directories with an ETA on stderr
+	Progress bool
 }
 
 // NewRunner creates a new kustomize validation runner
@@ -54,46 +105,49 @@ func NewRunner(repoPath string, kubernetesVersion string, verbose bool) *Runner
 	return &Runner{
 		RepoPath:          repoPath,
 		KubernetesVersion: kubernetesVersion,
-		Verbose:           verbose,
+		Log:               log.Default().Named("kustomize").Verbose(verbose),
+		Engine:            EngineExec,
 	}
 }
 
+// DiscoveryPatterns are the directory globs validated by DiscoverDirectories
+// Patterns match the Jenkinsfile discovery logic
+var DiscoveryPatterns = []string{
+	// App base directories
+	"apps/*/base",
+	// App overlays - both old (apps/*/overlays/*) and new cluster-aware patterns
+	"apps/*/overlays/*",
+	"apps/*/overlays/*/*",
+	// App stack directories - cluster-aware (apps/*/stack/erauner-home/production)
+	"apps/*/stack/*",
+	"apps/*/stack/*/*",
+	// App database directories
+	"apps/*/db/base",
+	"apps/*/db/overlays/*",
+	"apps/*/db/overlays/*/*",
+	// Infrastructure
+	"infrastructure/base/*",
+	"infrastructure/*/base",
+	"infrastructure/*/overlays/*",
+	"infrastructure/*/overlays/*/*",
+	// Operators
+	"operators/*/base",
+	"operators/*/overlays/*",
+	"operators/*/overlays/*/*",
+	// Security
+	"security/*/base",
+	"security/*/overlays/*",
+	"security/*/overlays/*/*",
+}
+
 // DiscoverDirectories finds all kustomization directories to validate
 // Patterns match the Jenkinsfile discovery logic
 // Note: After #1256 migration, overlays/stacks are now 2 levels deep:
 // apps/*/stack/erauner-home/production, apps/*/overlays/erauner-home/production
 func (r *Runner) DiscoverDirectories() ([]string, error) {
-	patterns := []string{
-		// App base directories
-		"apps/*/base",
-		// App overlays - both old (apps/*/overlays/*) and new cluster-aware patterns
-		"apps/*/overlays/*",
-		"apps/*/overlays/*/*",
-		// App stack directories - cluster-aware (apps/*/stack/erauner-home/production)
-		"apps/*/stack/*",
-		"apps/*/stack/*/*",
-		// App database directories
-		"apps/*/db/base",
-		"apps/*/db/overlays/*",
-		"apps/*/db/overlays/*/*",
-		// Infrastructure
-		"infrastructure/base/*",
-		"infrastructure/*/base",
-		"infrastructure/*/overlays/*",
-		"infrastructure/*/overlays/*/*",
-		// Operators
-		"operators/*/base",
-		"operators/*/overlays/*",
-		"operators/*/overlays/*/*",
-		// Security
-		"security/*/base",
-		"security/*/overlays/*",
-		"security/*/overlays/*/*",
-	}
-
 	dirSet := make(map[string]bool)
 
-	for _, pattern := range patterns {
+	for _, pattern := range DiscoveryPatterns {
 		fullPattern := filepath.Join(r.RepoPath, pattern, "kustomization.yaml")
 		matches, err := filepath.Glob(fullPattern)
 		if err != nil {
@@ -124,42 +178,75 @@ func (r *Runner) DiscoverDirectories() ([]string, error) {
 // BuildDirectory builds a single kustomization directory without schema validation
 // This is useful for rendering manifests for preview diffs
 func (r *Runner) BuildDirectory(dir string) BuildResult {
+	return r.BuildDirectoryContext(context.Background(), dir)
+}
+
+// BuildDirectoryContext is BuildDirectory, killing an exec build when ctx is done
+func (r *Runner) BuildDirectoryContext(ctx context.Context, dir string) BuildResult {
 	result := BuildResult{
 		Directory: dir,
 	}
 
 	absDir := filepath.Join(r.RepoPath, dir)
-
-	// Check if directory exists
-	if _, err := os.Stat(absDir); os.IsNotExist(err) {
+	if reason := skipReason(absDir); reason != "" {
 		result.Skipped = true
-		result.SkipReason = "directory not found"
+		result.SkipReason = reason
 		return result
 	}
 
-	// Check if kustomization.yaml exists
-	kustomizationFile := filepath.Join(absDir, "kustomization.yaml")
-	if _, err := os.Stat(kustomizationFile); os.IsNotExist(err) {
-		result.Skipped = true
-		result.SkipReason = "no kustomization.yaml"
+	return r.build(ctx, absDir, result)
+}
+
+// skipReason explains why absDir can't be built, or returns ""
+func skipReason(absDir string) string {
+	if _, err := os.Stat(absDir); os.IsNotExist(err) {
+		return "directory not found"
+	}
+	if _, err := os.Stat(filepath.Join(absDir, "kustomization.yaml")); os.IsNotExist(err) {
+		return "no kustomization.yaml"
+	}
+	return ""
+}
+
+// build runs the configured engine on an absolute kustomization directory
+func (r *Runner) build(ctx context.Context, absDir string, result BuildResult) BuildResult {
+	dir := result.Directory
+	if r.useKrusty() {
+		r.Log.Debugf("krusty build %s", dir)
+		output, err := buildKrusty(absDir)
+		result.Output = output
+		if err != nil {
+			result.Passed = false
+			result.Error = fmt.Errorf("kustomize build (krusty) failed: %w", err)
+			return result
+		}
+		result.Passed = true
 		return result
 	}
 
 	// Run kustomize build
 	// Flags match ArgoCD's kustomize.buildOptions
-	buildCmd := exec.Command("kustomize", "build",
+	binary := r.Binary
+	if binary == "" {
+		binary = "kustomize"
+	}
+	buildCmd := command.CommandContext(ctx, binary, "build",
 		"--load-restrictor=LoadRestrictionsNone",
 		"--enable-helm",
 		"--enable-alpha-plugins",
 		"--enable-exec",
 		absDir)
 
+	r.Log.Debugf("%s build %s", binary, dir)
 	buildOutput, err := buildCmd.CombinedOutput()
 	result.Output = string(buildOutput)
 
 	if err != nil {
 		result.Passed = false
 		result.Error = fmt.Errorf("kustomize build failed: %w", err)
+		if command.IsTimeout(err) {
+			result.Output += "\nError: " + err.Error()
+		}
 		return result
 	}
 	result.Passed = true
@@ -167,9 +254,45 @@ func (r *Runner) BuildDirectory(dir string) BuildResult {
 	return result
 }
 
+// useKrusty reports whether builds should run in-process
+// EngineAuto only falls back to krusty when the kustomize binary is missing
+// and this binary was compiled with krusty support
+func (r *Runner) useKrusty() bool {
+	switch r.Engine {
+	case EngineKrusty:
+		return true
+	case EngineAuto:
+		return !IsKustomizeInstalled() && KrustyAvailable
+	default:
+		return false
+	}
+}
+
 // ValidateDirectory validates a single kustomization directory
-// This builds the kustomization and validates it with kubeconform
+// This builds the kustomization and validates it with kubeconform, unless the
+// Cache holds a passing result for the same inputs
 func (r *Runner) ValidateDirectory(dir string) ValidationResult {
+	var key string
+	if r.Cache != nil && skipReason(filepath.Join(r.RepoPath, dir)) == "" {
+		var err error
+		if key, err = r.Cache.Key(r, dir); err != nil {
+			r.Log.Debugf("not caching %s: %v", dir, err)
+		} else if cached, ok := r.Cache.Lookup(key, dir); ok {
+			return cached
+		}
+	}
+
+	result := r.validateDirectory(dir)
+	if key != "" {
+		if err := r.Cache.Store(key, result); err != nil {
+			r.Log.Warnf("Failed to cache result for %s: %v", dir, err)
+		}
+	}
+	return result
+}
+
+// validateDirectory builds and schema-validates dir
+func (r *Runner) validateDirectory(dir string) ValidationResult {
 	result := ValidationResult{
 		Directory: dir,
 	}
@@ -189,41 +312,68 @@ func (r *Runner) ValidateDirectory(dir string) ValidationResult {
 		return result
 	}
 
-	// Write manifests to temp file for kubeconform
-	tmpFile, err := os.CreateTemp("", "manifests-*.yaml")
+	if found, err := FindDeprecatedAPIs(buildResult.Output, r.KubernetesVersion); err == nil {
+		result.DeprecatedAPIs = found
+	}
+
+	output, err := r.ValidateManifest(buildResult.Output)
+	result.SchemaOutput = output
 	if err != nil {
 		result.SchemaPassed = false
-		result.SchemaError = fmt.Errorf("failed to create temp file: %w", err)
+		result.SchemaError = err
 		return result
 	}
+	result.SchemaPassed = true
+
+	return result
+}
+
+// ValidateManifest runs kubeconform against already-rendered manifests
+// Returns the kubeconform output and a non-nil error if validation failed
+func (r *Runner) ValidateManifest(manifest string) (string, error) {
+	return r.ValidateManifestContext(context.Background(), manifest)
+}
+
+// ValidateManifestContext is ValidateManifest, killing kubeconform when ctx is done
+func (r *Runner) ValidateManifestContext(ctx context.Context, manifest string) (string, error) {
+	// Write manifests to temp file for kubeconform
+	tmpFile, err := os.CreateTemp("", "manifests-*.yaml")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp file: %w", err)
+	}
 	defer os.Remove(tmpFile.Name())
 
-	if _, err := tmpFile.WriteString(buildResult.Output); err != nil {
-		result.SchemaPassed = false
-		result.SchemaError = fmt.Errorf("failed to write temp file: %w", err)
-		return result
+	if _, err := tmpFile.WriteString(manifest); err != nil {
+		tmpFile.Close()
+		return "", fmt.Errorf("failed to write temp file: %w", err)
 	}
 	tmpFile.Close()
 
 	// Run kubeconform validation
-	validateCmd := exec.Command("kubeconform",
+	args := []string{
 		"-strict",
 		"-ignore-missing-schemas",
 		"-kubernetes-version", r.KubernetesVersion,
 		"-summary",
-		tmpFile.Name())
+	}
+	if len(r.SchemaLocations) > 0 {
+		// Any -schema-location replaces the default, so list it explicitly
+		args = append(args, "-schema-location", "default")
+		for _, loc := range r.SchemaLocations {
+			args = append(args, "-schema-location", loc)
+		}
+	}
+	validateCmd := command.CommandContext(ctx, "kubeconform", append(args, tmpFile.Name())...)
 
 	validateOutput, err := validateCmd.CombinedOutput()
-	result.SchemaOutput = string(validateOutput)
-
 	if err != nil {
-		result.SchemaPassed = false
-		result.SchemaError = fmt.Errorf("kubeconform validation failed: %w", err)
-		return result
+		if command.IsTimeout(err) {
+			return string(validateOutput), err
+		}
+		return string(validateOutput), fmt.Errorf("kubeconform validation failed: %w", err)
 	}
-	result.SchemaPassed = true
 
-	return result
+	return string(validateOutput), nil
 }
 
 // ValidateAll validates all discovered kustomization directories
@@ -233,9 +383,17 @@ func (r *Runner) ValidateAll() ([]ValidationResult, error) {
 		return nil, err
 	}
 
+	var report *progress.Reporter
+	if r.Progress {
+		report = progress.New("validate", len(dirs))
+		defer report.Finish()
+	}
+
 	results := make([]ValidationResult, 0, len(dirs))
 	for _, dir := range dirs {
+		report.Start(dir)
 		result := r.ValidateDirectory(dir)
+		report.Done(result.Passed())
 		results = append(results, result)
 	}
 
@@ -244,11 +402,11 @@ func (r *Runner) ValidateAll() ([]ValidationResult, error) {
 
 // Summary returns a summary of validation results
 type Summary struct {
-	Total       int
-	Passed      int
-	BuildFailed int
+	Total        int
+	Passed       int
+	BuildFailed  int
 	SchemaFailed int
-	Skipped     int
+	Skipped      int
 }
 
 // Summarize creates a summary from validation results
@@ -302,7 +460,7 @@ func IsHelmInstalled() bool {
 
 // KustomizeVersion returns the installed kustomize version
 func KustomizeVersion() (string, error) {
-	cmd := exec.Command("kustomize", "version")
+	cmd := command.Command("kustomize", "version")
 	output, err := cmd.CombinedOutput()
 	if err != nil {
 		return "", fmt.Errorf("failed to get kustomize version: %w", err)
@@ -312,7 +470,7 @@ func KustomizeVersion() (string, error) {
 
 // KubeconformVersion returns the installed kubeconform version
 func KubeconformVersion() (string, error) {
-	cmd := exec.Command("kubeconform", "-v")
+	cmd := command.Command("kubeconform", "-v")
 	output, err := cmd.CombinedOutput()
 	if err != nil {
 		return "", fmt.Errorf("failed to get kubeconform version: %w", err)
@@ -17,6 +17,7 @@ package kustomize
 // For CI, this isn't an issue because each build starts fresh.
 
 import (
+	"flag"
 	"os"
 	"path/filepath"
 	"runtime"
@@ -24,22 +25,38 @@ import (
 	"testing"
 )
 
-// getRepoRoot returns the path to the homelab-k8s repository root
+// repoFlag points the directory tests at a homelab-k8s checkout:
+//
+//	go test ./pkg/kustomize/ -args -repo /path/to/homelab-k8s
+var repoFlag = flag.String("repo", "", "homelab-k8s checkout to validate (default: the testdata fixture repo)")
+
+// Passing directory results are reused between runs when their inputs are
+// unchanged (see ResultCache):
+//
+//	go test ./pkg/kustomize/ -args -repo /path/to/homelab-k8s -cache-dir /tmp/shadow-results
+var (
+	cacheDirFlag = flag.String("cache-dir", DefaultResultCacheDir(), "directory for cached validation results")
+	noCacheFlag  = flag.Bool("no-cache", false, "always build and validate instead of reusing cached results")
+)
+
+// getRepoRoot returns the repository the directory tests run against: -repo,
+// or the fixture repo under testdata/ at the module root
 func getRepoRoot(t *testing.T) string {
 	t.Helper()
 
-	// Get the directory of this test file
-	_, filename, _, ok := runtime.Caller(0)
-	if !ok {
-		t.Fatal("Failed to get test file path")
+	repoRoot := *repoFlag
+	if repoRoot == "" {
+		// Get the directory of this test file
+		_, filename, _, ok := runtime.Caller(0)
+		if !ok {
+			t.Fatal("Failed to get test file path")
+		}
+		repoRoot = filepath.Join(filepath.Dir(filename), "..", "..", "testdata", "repo")
 	}
 
-	// Walk up from tools/shadow/pkg/kustomize to repo root
-	repoRoot := filepath.Join(filepath.Dir(filename), "..", "..", "..", "..")
-
 	// Verify it's the right directory
 	if _, err := os.Stat(filepath.Join(repoRoot, "apps")); err != nil {
-		t.Fatalf("Failed to find repo root from %s: %v", filename, err)
+		t.Fatalf("Failed to find repo root %s: %v", repoRoot, err)
 	}
 
 	absPath, err := filepath.Abs(repoRoot)
@@ -84,12 +101,8 @@ func TestKubeconformInstalled(t *testing.T) {
 	t.Logf("Kubeconform version: %s", version)
 }
 
-// TestDiscoverDirectories tests directory discovery
+// TestDiscoverDirectories tests directory discovery (no CLI needed)
 func TestDiscoverDirectories(t *testing.T) {
-	if !IsKustomizeInstalled() {
-		t.Skip("kustomize CLI not installed")
-	}
-
 	repoRoot := getRepoRoot(t)
 	runner := NewRunner(repoRoot, getKubernetesVersion(), testing.Verbose())
 
@@ -130,6 +143,9 @@ func TestAllKustomizeDirectories(t *testing.T) {
 
 	repoRoot := getRepoRoot(t)
 	runner := NewRunner(repoRoot, getKubernetesVersion(), testing.Verbose())
+	if !*noCacheFlag {
+		runner.Cache = NewResultCache(*cacheDirFlag, testing.Verbose())
+	}
 
 	dirs, err := runner.DiscoverDirectories()
 	if err != nil {
@@ -304,3 +320,103 @@ func TestHasKubeconformErrors(t *testing.T) {
 		})
 	}
 }
+
+// TestParseEngine tests kustomize engine flag parsing
+func TestParseEngine(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected Engine
+		wantErr  bool
+	}{
+		{input: "", expected: EngineExec},
+		{input: "exec", expected: EngineExec},
+		{input: "krusty", expected: EngineKrusty},
+		{input: "auto", expected: EngineAuto},
+		{input: "kubectl", wantErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.input, func(t *testing.T) {
+			got, err := ParseEngine(tc.input)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("ParseEngine(%q) error = %v, wantErr %v", tc.input, err, tc.wantErr)
+			}
+			if got != tc.expected {
+				t.Errorf("ParseEngine(%q) = %q, want %q", tc.input, got, tc.expected)
+			}
+		})
+	}
+}
+
+// TestBuildDirectory_KrustyEngine verifies the krusty engine builds in-process
+// (or reports a clear error when the binary was built without -tags krusty)
+func TestBuildDirectory_KrustyEngine(t *testing.T) {
+	tmpDir := t.TempDir()
+	dir := filepath.Join(tmpDir, "apps", "demo", "base")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatalf("Failed to create dir: %v", err)
+	}
+	kustomization := "apiVersion: kustomize.config.k8s.io/v1beta1\nkind: Kustomization\nresources:\n  - configmap.yaml\n"
+	configMap := "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: demo\ndata:\n  key: value\n"
+	if err := os.WriteFile(filepath.Join(dir, "kustomization.yaml"), []byte(kustomization), 0644); err != nil {
+		t.Fatalf("Failed to write kustomization.yaml: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "configmap.yaml"), []byte(configMap), 0644); err != nil {
+		t.Fatalf("Failed to write configmap.yaml: %v", err)
+	}
+
+	runner := NewRunner(tmpDir, "", false)
+	runner.Engine = EngineKrusty
+
+	result := runner.BuildDirectory("apps/demo/base")
+
+	if !KrustyAvailable {
+		if result.Passed {
+			t.Fatal("Expected krusty build to fail without -tags krusty")
+		}
+		if !strings.Contains(result.Error.Error(), "-tags krusty") {
+			t.Errorf("Expected rebuild hint in error, got: %v", result.Error)
+		}
+		return
+	}
+
+	if !result.Passed {
+		t.Fatalf("Krusty build failed: %v", result.Error)
+	}
+	if !strings.Contains(result.Output, "name: demo") {
+		t.Errorf("Expected rendered ConfigMap in output, got:\n%s", result.Output)
+	}
+}
+
+// TestSummarizeSchemaErrors tests condensing kubeconform output for sync failures
+func TestSummarizeSchemaErrors(t *testing.T) {
+	testCases := []struct {
+		name     string
+		output   string
+		expected string
+	}{
+		{
+			name:     "error lines",
+			output:   "ERRO - Deployment coder: missing properties 'selector'\nERRO - Service coder: bad port\nSummary: 2 resources found in 1 file - Valid: 0, Invalid: 2, Errors: 0, Skipped: 0",
+			expected: "Deployment coder: missing properties 'selector'; Service coder: bad port",
+		},
+		{
+			name:     "summary only",
+			output:   "Summary: 3 resources found in 1 file - Valid: 2, Invalid: 1, Errors: 0, Skipped: 0",
+			expected: "Invalid: 1, Errors: 0",
+		},
+		{
+			name:     "raw output",
+			output:   "  could not download schema  \n",
+			expected: "could not download schema",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := SummarizeSchemaErrors(tc.output); got != tc.expected {
+				t.Errorf("SummarizeSchemaErrors() = %q, want %q", got, tc.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,144 @@
+package kustomize
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/erauner/homelab-shadow/pkg/argocd"
+	"gopkg.in/yaml.v3"
+)
+
+// overlayKustomization is the wrapper BuildSource builds around a directory
+type overlayKustomization struct {
+	APIVersion        string                  `yaml:"apiVersion"`
+	Kind              string                  `yaml:"kind"`
+	Resources         []string                `yaml:"resources"`
+	NamePrefix        string                  `yaml:"namePrefix,omitempty"`
+	NameSuffix        string                  `yaml:"nameSuffix,omitempty"`
+	Namespace         string                  `yaml:"namespace,omitempty"`
+	CommonLabels      map[string]string       `yaml:"commonLabels,omitempty"`
+	CommonAnnotations map[string]string       `yaml:"commonAnnotations,omitempty"`
+	Images            []overlayImage          `yaml:"images,omitempty"`
+	Replicas          []overlayReplica        `yaml:"replicas,omitempty"`
+	Patches           []argocd.KustomizePatch `yaml:"patches,omitempty"`
+}
+
+type overlayImage struct {
+	Name    string `yaml:"name"`
+	NewName string `yaml:"newName,omitempty"`
+	NewTag  string `yaml:"newTag,omitempty"`
+	Digest  string `yaml:"digest,omitempty"`
+}
+
+type overlayReplica struct {
+	Name  string `yaml:"name"`
+	Count int    `yaml:"count"`
+}
+
+// BuildSource builds dir with an Application's spec.source.kustomize applied,
+// matching what ArgoCD deploys. ArgoCD edits the kustomization in place; the
+// same fields are set here in a temporary kustomization that wraps dir, so
+// the repo is never modified. Without overrides it is BuildDirectory.
+func (r *Runner) BuildSource(dir string, k *argocd.KustomizeConfig) BuildResult {
+	return r.BuildSourceContext(context.Background(), dir, k)
+}
+
+// BuildSourceContext is BuildSource, killing an exec build when ctx is done
+func (r *Runner) BuildSourceContext(ctx context.Context, dir string, k *argocd.KustomizeConfig) BuildResult {
+	if k.IsEmpty() {
+		return r.BuildDirectoryContext(ctx, dir)
+	}
+
+	result := BuildResult{Directory: dir}
+	absDir, err := filepath.Abs(filepath.Join(r.RepoPath, dir))
+	if err != nil {
+		result.Error = err
+		return result
+	}
+	if reason := skipReason(absDir); reason != "" {
+		result.Skipped = true
+		result.SkipReason = reason
+		return result
+	}
+
+	wrapper, err := os.MkdirTemp("", "shadow-kustomize-*")
+	if err != nil {
+		result.Error = fmt.Errorf("failed to create kustomization overlay: %w", err)
+		return result
+	}
+	defer os.RemoveAll(wrapper)
+
+	rel, err := filepath.Rel(wrapper, absDir)
+	if err != nil {
+		result.Error = err
+		return result
+	}
+	data, err := overlay(filepath.ToSlash(rel), k)
+	if err != nil {
+		result.Error = fmt.Errorf("invalid kustomize options for %s: %w", dir, err)
+		return result
+	}
+	if err := os.WriteFile(filepath.Join(wrapper, "kustomization.yaml"), data, 0644); err != nil {
+		result.Error = fmt.Errorf("failed to write kustomization overlay: %w", err)
+		return result
+	}
+	return r.build(ctx, wrapper, result)
+}
+
+// overlay renders the wrapper kustomization for a directory at rel
+func overlay(rel string, k *argocd.KustomizeConfig) ([]byte, error) {
+	o := overlayKustomization{
+		APIVersion:        "kustomize.config.k8s.io/v1beta1",
+		Kind:              "Kustomization",
+		Resources:         []string{rel},
+		NamePrefix:        k.NamePrefix,
+		NameSuffix:        k.NameSuffix,
+		Namespace:         k.Namespace,
+		CommonLabels:      k.CommonLabels,
+		CommonAnnotations: k.CommonAnnotations,
+	}
+	for _, image := range k.Images {
+		o.Images = append(o.Images, parseImage(image))
+	}
+	for _, replica := range k.Replicas {
+		count, err := strconv.Atoi(replica.Count)
+		if err != nil {
+			return nil, fmt.Errorf("replicas %s: invalid count %q", replica.Name, replica.Count)
+		}
+		o.Replicas = append(o.Replicas, overlayReplica{Name: replica.Name, Count: count})
+	}
+	for _, patch := range k.Patches {
+		// Patch files are relative to the source path, not the wrapper
+		if patch.Path != "" {
+			patch.Path = rel + "/" + patch.Path
+		}
+		o.Patches = append(o.Patches, patch)
+	}
+	return yaml.Marshal(o)
+}
+
+// parseImage parses kustomize edit set image syntax: name[:tag|@digest] or
+// name=newName[:tag|@digest]
+func parseImage(s string) overlayImage {
+	name, ref, renamed := strings.Cut(s, "=")
+	if !renamed {
+		ref = s
+	}
+
+	var image overlayImage
+	if i := strings.Index(ref, "@"); i >= 0 {
+		image.Digest, ref = ref[i+1:], ref[:i]
+	} else if i := strings.LastIndex(ref, ":"); i > strings.LastIndex(ref, "/") {
+		image.NewTag, ref = ref[i+1:], ref[:i]
+	}
+	if renamed {
+		image.Name, image.NewName = name, ref
+	} else {
+		image.Name = ref
+	}
+	return image
+}
@@ -0,0 +1,79 @@
+package kustomize
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/erauner/homelab-shadow/pkg/argocd"
+)
+
+func TestParseImage(t *testing.T) {
+	tests := []struct {
+		in   string
+		want overlayImage
+	}{
+		{"nginx:1.27", overlayImage{Name: "nginx", NewTag: "1.27"}},
+		{"nginx=ghcr.io/org/nginx:1.27", overlayImage{Name: "nginx", NewName: "ghcr.io/org/nginx", NewTag: "1.27"}},
+		{"registry:5000/app", overlayImage{Name: "registry:5000/app"}},
+		{"app@sha256:abc", overlayImage{Name: "app", Digest: "sha256:abc"}},
+		{"app=other", overlayImage{Name: "app", NewName: "other"}},
+	}
+	for _, tt := range tests {
+		if got := parseImage(tt.in); got != tt.want {
+			t.Errorf("parseImage(%q) = %+v, want %+v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestBuildSource(t *testing.T) {
+	repo := t.TempDir()
+	dir := filepath.Join(repo, "apps", "demo", "overlays", "production")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range map[string]string{
+		"kustomization.yaml": "resources:\n  - configmap.yaml\n",
+		"configmap.yaml":     "kind: ConfigMap\nmetadata:\n  name: demo\n",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Prints the wrapper kustomization, then the ConfigMap through its resource path
+	bin := filepath.Join(t.TempDir(), "kustomize")
+	script := "#!/bin/sh\nfor last; do :; done\ncat \"$last/kustomization.yaml\"\nres=$(sed -n 's/^ *- //p' \"$last/kustomization.yaml\" | head -1)\ncat \"$last/$res/configmap.yaml\"\n"
+	if err := os.WriteFile(bin, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	runner := NewRunner(repo, "", false)
+	runner.Binary = bin
+
+	result := runner.BuildSource("apps/demo/overlays/production", &argocd.KustomizeConfig{
+		NamePrefix: "prod-",
+		Images:     []string{"nginx=ghcr.io/org/nginx:1.27"},
+		Replicas:   []argocd.KustomizeReplica{{Name: "web", Count: "3"}},
+		Patches:    []argocd.KustomizePatch{{Path: "patch.yaml", Target: &argocd.KustomizeTarget{Kind: "Deployment"}}},
+	})
+	if !result.Passed {
+		t.Fatalf("BuildSource() failed: %v\n%s", result.Error, result.Output)
+	}
+	for _, want := range []string{"namePrefix: prod-", "newName: ghcr.io/org/nginx", "newTag: \"1.27\"", "count: 3", "/apps/demo/overlays/production/patch.yaml", "kind: Deployment", "name: demo"} {
+		if !strings.Contains(result.Output, want) {
+			t.Errorf("expected %q in output:\n%s", want, result.Output)
+		}
+	}
+
+	// Without options the directory is built directly
+	result = runner.BuildSource("apps/demo/overlays/production", &argocd.KustomizeConfig{})
+	if !strings.HasPrefix(result.Output, "resources:\n  - configmap.yaml") {
+		t.Errorf("expected a direct build without options, got:\n%s", result.Output)
+	}
+
+	result = runner.BuildSource("apps/demo/overlays/production", &argocd.KustomizeConfig{Replicas: []argocd.KustomizeReplica{{Name: "web", Count: "three"}}})
+	if result.Passed || result.Error == nil {
+		t.Error("expected an invalid replica count to fail")
+	}
+}
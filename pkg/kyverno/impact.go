@@ -0,0 +1,422 @@
+package kyverno
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/erauner/homelab-shadow/pkg/command"
+)
+
+// Policy change kinds, relative to the base ref
+const (
+	PolicyAdded    = "added"
+	PolicyModified = "modified"
+	PolicyDeleted  = "deleted"
+)
+
+// PolicyChange is a policy file that differs from the base ref
+type PolicyChange struct {
+	Path   string // repo-relative
+	Change string // PolicyAdded, PolicyModified, or PolicyDeleted
+	Base   []byte // content at the base ref (nil when added)
+	Head   []byte // content in the working tree (nil when deleted)
+}
+
+// Violation is a resource failing a policy rule
+type Violation struct {
+	Policy    string   `json:"policy"`
+	Rule      string   `json:"rule"`
+	Kind      string   `json:"kind"`
+	Namespace string   `json:"namespace,omitempty"`
+	Name      string   `json:"name"`
+	Message   string   `json:"message,omitempty"`
+	Dirs      []string `json:"dirs,omitempty"` // rendered directories holding the resource
+}
+
+// key identifies a violation regardless of its message
+func (v Violation) key() string {
+	return strings.Join([]string{v.Policy, v.Rule, v.Kind, v.Namespace, v.Name}, "\x00")
+}
+
+// Resource returns the violating resource as Kind/namespace/name
+func (v Violation) Resource() string {
+	if v.Namespace == "" {
+		return v.Kind + "/" + v.Name
+	}
+	return v.Kind + "/" + v.Namespace + "/" + v.Name
+}
+
+// PolicyImpact is how one changed policy file affects the rendered resources
+type PolicyImpact struct {
+	Path   string `json:"path"`
+	Change string `json:"change"`
+
+	// NewViolations fail the policy at head but not at the base ref;
+	// Resolved failed at the base ref and no longer do
+	NewViolations []Violation `json:"new_violations,omitempty"`
+	Resolved      []Violation `json:"resolved,omitempty"`
+}
+
+// ImpactReport is the blast radius of the policy changes since Base
+type ImpactReport struct {
+	Base      string         `json:"base"`
+	Resources int            `json:"resources"`
+	Policies  []PolicyImpact `json:"policies"`
+}
+
+// NewViolations counts the new violations across every changed policy
+func (r ImpactReport) NewViolations() int {
+	n := 0
+	for _, p := range r.Policies {
+		n += len(p.NewViolations)
+	}
+	return n
+}
+
+// Resolved counts the resolved violations across every changed policy
+func (r ImpactReport) Resolved() int {
+	n := 0
+	for _, p := range r.Policies {
+		n += len(p.Resolved)
+	}
+	return n
+}
+
+// ChangedPolicies lists the policy files under the cluster policy directories
+// that differ between base and the working tree
+func (r *TestRunner) ChangedPolicies(base string) ([]PolicyChange, error) {
+	return r.ChangedPoliciesContext(context.Background(), base)
+}
+
+// ChangedPoliciesContext is ChangedPolicies with git killed once ctx is done
+func (r *TestRunner) ChangedPoliciesContext(ctx context.Context, base string) ([]PolicyChange, error) {
+	var dirs []string
+	for _, dir := range r.clusterDirs() {
+		rel, err := filepath.Rel(r.RepoPath, dir)
+		if err != nil {
+			return nil, err
+		}
+		dirs = append(dirs, filepath.ToSlash(rel))
+	}
+
+	args := append([]string{"-C", r.RepoPath, "diff", "--name-status", "--no-renames", "-z", base, "--"}, dirs...)
+	r.Log.Debugf("git %s", strings.Join(args, " "))
+	out, err := command.CommandContext(ctx, "git", args...).Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to diff policies against %s: %w", base, gitError(err))
+	}
+	untracked, err := command.CommandContext(ctx, "git", append([]string{"-C", r.RepoPath, "ls-files", "-z", "--others", "--exclude-standard", "--"}, dirs...)...).Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list untracked policies: %w", gitError(err))
+	}
+
+	statuses := make(map[string]string)
+	fields := strings.Split(strings.TrimSuffix(string(out), "\x00"), "\x00")
+	for i := 0; i+1 < len(fields); i += 2 {
+		statuses[fields[i+1]] = fields[i]
+	}
+	for _, path := range strings.Split(string(untracked), "\x00") {
+		if path != "" {
+			statuses[path] = "A"
+		}
+	}
+
+	var changes []PolicyChange
+	for path, status := range statuses {
+		if !isPolicyFile(path) {
+			continue
+		}
+		change := PolicyChange{Path: path}
+		if status != "A" {
+			if change.Base, err = command.CommandContext(ctx, "git", "-C", r.RepoPath, "show", base+":"+path).Output(); err != nil {
+				return nil, fmt.Errorf("failed to read %s at %s: %w", path, base, gitError(err))
+			}
+		}
+		if status != "D" {
+			if change.Head, err = os.ReadFile(filepath.Join(r.RepoPath, filepath.FromSlash(path))); err != nil {
+				return nil, err
+			}
+		}
+		switch {
+		case change.Base == nil:
+			change.Change = PolicyAdded
+		case change.Head == nil:
+			change.Change = PolicyDeleted
+		default:
+			change.Change = PolicyModified
+		}
+		changes = append(changes, change)
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return changes, nil
+}
+
+// isPolicyFile reports whether path is a policy file rather than a kustomization
+func isPolicyFile(path string) bool {
+	name := filepath.Base(path)
+	return (strings.HasSuffix(name, ".yaml") || strings.HasSuffix(name, ".yml")) && name != "kustomization.yaml"
+}
+
+// gitError adds git's stderr to a failed command's error
+func gitError(err error) error {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && len(exitErr.Stderr) > 0 {
+		return fmt.Errorf("%w: %s", err, strings.TrimSpace(string(exitErr.Stderr)))
+	}
+	return err
+}
+
+// Impact reports which resources in the rendered manifests newly violate, or
+// stop violating, each policy changed since base. Manifests maps a rendered
+// directory to its manifest; every changed policy is applied to all of them
+// at both versions with kyverno apply
+func (r *TestRunner) Impact(base string, manifests map[string]string) (ImpactReport, error) {
+	return r.ImpactContext(context.Background(), base, manifests)
+}
+
+// ImpactContext is Impact with git and kyverno killed once ctx is done
+func (r *TestRunner) ImpactContext(ctx context.Context, base string, manifests map[string]string) (ImpactReport, error) {
+	report := ImpactReport{Base: base}
+	changes, err := r.ChangedPoliciesContext(ctx, base)
+	if err != nil {
+		return report, err
+	}
+	if len(changes) == 0 {
+		return report, nil
+	}
+
+	tempDir, err := os.MkdirTemp("", "shadow-kyverno-impact-*")
+	if err != nil {
+		return report, fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	resources, dirs, count, err := collectResources(manifests)
+	if err != nil {
+		return report, err
+	}
+	report.Resources = count
+	resourceFile := filepath.Join(tempDir, "resources.yaml")
+	if err := os.WriteFile(resourceFile, []byte(resources), 0644); err != nil {
+		return report, err
+	}
+
+	for i, change := range changes {
+		var before, after []Violation
+		if change.Base != nil {
+			if before, err = r.apply(ctx, filepath.Join(tempDir, fmt.Sprintf("base-%d.yaml", i)), change.Base, resourceFile); err != nil {
+				return report, fmt.Errorf("failed to apply %s at %s: %w", change.Path, base, err)
+			}
+		}
+		if change.Head != nil {
+			if after, err = r.apply(ctx, filepath.Join(tempDir, fmt.Sprintf("head-%d.yaml", i)), change.Head, resourceFile); err != nil {
+				return report, fmt.Errorf("failed to apply %s: %w", change.Path, err)
+			}
+		}
+		impact := PolicyImpact{
+			Path:          change.Path,
+			Change:        change.Change,
+			NewViolations: subtractViolations(after, before),
+			Resolved:      subtractViolations(before, after),
+		}
+		for _, list := range [][]Violation{impact.NewViolations, impact.Resolved} {
+			for j := range list {
+				list[j].Dirs = dirs[resourceKey(list[j].Kind, list[j].Namespace, list[j].Name)]
+			}
+		}
+		report.Policies = append(report.Policies, impact)
+	}
+	return report, nil
+}
+
+// apply writes policy to path and returns the failures of kyverno apply on resourceFile
+func (r *TestRunner) apply(ctx context.Context, path string, policy []byte, resourceFile string) ([]Violation, error) {
+	if err := os.WriteFile(path, policy, 0644); err != nil {
+		return nil, err
+	}
+	r.Log.Debugf("kyverno apply %s --resource %s --policy-report", path, resourceFile)
+	cmd := command.CommandContext(ctx, "kyverno", "apply", path, "--resource", resourceFile, "--policy-report")
+	output, err := cmd.CombinedOutput()
+	violations, found := ParsePolicyReport(string(output))
+	// kyverno apply exits non-zero when a resource fails; only a missing report is an error
+	if !found {
+		if err == nil {
+			err = errors.New("no policy report in output")
+		}
+		return nil, fmt.Errorf("kyverno apply failed: %w\n%s", err, strings.TrimSpace(string(output)))
+	}
+	return violations, nil
+}
+
+// policyReport is the part of a (Cluster)PolicyReport holding results
+type policyReport struct {
+	Kind    string `yaml:"kind"`
+	Results []struct {
+		Policy    string `yaml:"policy"`
+		Rule      string `yaml:"rule"`
+		Result    string `yaml:"result"`
+		Message   string `yaml:"message"`
+		Resources []struct {
+			Kind      string `yaml:"kind"`
+			Namespace string `yaml:"namespace"`
+			Name      string `yaml:"name"`
+		} `yaml:"resources"`
+	} `yaml:"results"`
+}
+
+// ParsePolicyReport extracts the failed results from the policy reports that
+// kyverno apply --policy-report prints after its progress lines; found is
+// false when the output holds no report
+func ParsePolicyReport(output string) (violations []Violation, found bool) {
+	start := strings.Index(output, "apiVersion:")
+	if start < 0 {
+		return nil, false
+	}
+	decoder := yaml.NewDecoder(strings.NewReader(output[start:]))
+	for {
+		var report policyReport
+		if err := decoder.Decode(&report); err != nil {
+			break
+		}
+		if !strings.HasSuffix(report.Kind, "PolicyReport") {
+			continue
+		}
+		found = true
+		for _, result := range report.Results {
+			if result.Result != "fail" {
+				continue
+			}
+			for _, resource := range result.Resources {
+				violations = append(violations, Violation{
+					Policy:    result.Policy,
+					Rule:      result.Rule,
+					Kind:      resource.Kind,
+					Namespace: resource.Namespace,
+					Name:      resource.Name,
+					Message:   result.Message,
+				})
+			}
+		}
+	}
+	sortViolations(violations)
+	return violations, found
+}
+
+// subtractViolations returns the violations in a that are not in b
+func subtractViolations(a, b []Violation) []Violation {
+	seen := make(map[string]bool)
+	for _, v := range b {
+		seen[v.key()] = true
+	}
+	var out []Violation
+	for _, v := range a {
+		if !seen[v.key()] {
+			out = append(out, v)
+			seen[v.key()] = true
+		}
+	}
+	return out
+}
+
+func sortViolations(violations []Violation) {
+	sort.SliceStable(violations, func(i, j int) bool { return violations[i].key() < violations[j].key() })
+}
+
+func resourceKey(kind, namespace, name string) string {
+	return kind + "/" + namespace + "/" + name
+}
+
+// collectResources concatenates the manifests, sorted by directory, into one
+// multi-document stream and maps each resource to the directories holding it
+func collectResources(manifests map[string]string) (string, map[string][]string, int, error) {
+	names := make([]string, 0, len(manifests))
+	for dir := range manifests {
+		names = append(names, dir)
+	}
+	sort.Strings(names)
+
+	var out strings.Builder
+	dirs := make(map[string][]string)
+	count := 0
+	for _, dir := range names {
+		decoder := yaml.NewDecoder(strings.NewReader(manifests[dir]))
+		for {
+			var doc map[string]interface{}
+			err := decoder.Decode(&doc)
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				return "", nil, 0, fmt.Errorf("failed to parse manifest for %s: %w", dir, err)
+			}
+			if doc == nil {
+				continue
+			}
+			kind, _ := doc["kind"].(string)
+			metadata, _ := doc["metadata"].(map[string]interface{})
+			name, _ := metadata["name"].(string)
+			namespace, _ := metadata["namespace"].(string)
+			if kind == "" || name == "" {
+				continue
+			}
+			key := resourceKey(kind, namespace, name)
+			if n := len(dirs[key]); n == 0 || dirs[key][n-1] != dir {
+				dirs[key] = append(dirs[key], dir)
+			}
+
+			var buf bytes.Buffer
+			encoder := yaml.NewEncoder(&buf)
+			encoder.SetIndent(2)
+			if err := encoder.Encode(doc); err != nil {
+				return "", nil, 0, err
+			}
+			out.WriteString("---\n")
+			out.Write(buf.Bytes())
+			count++
+		}
+	}
+	return out.String(), dirs, count, nil
+}
+
+// Markdown renders the report for the shadow repo and PR comments
+func (r ImpactReport) Markdown() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Kyverno policy impact\n\n")
+	fmt.Fprintf(&b, "%d changed policy file(s) since `%s` applied to %d rendered resource(s): ", len(r.Policies), r.Base, r.Resources)
+	fmt.Fprintf(&b, "%d new violation(s), %d resolved.\n", r.NewViolations(), r.Resolved())
+	for _, p := range r.Policies {
+		fmt.Fprintf(&b, "\n## `%s` (%s)\n\n", p.Path, p.Change)
+		if len(p.NewViolations) == 0 && len(p.Resolved) == 0 {
+			b.WriteString("No rendered resource changes outcome.\n")
+			continue
+		}
+		writeViolationTable(&b, "New violations", p.NewViolations)
+		writeViolationTable(&b, "Resolved", p.Resolved)
+	}
+	return b.String()
+}
+
+func writeViolationTable(b *strings.Builder, title string, violations []Violation) {
+	if len(violations) == 0 {
+		return
+	}
+	fmt.Fprintf(b, "**%s** (%d)\n\n", title, len(violations))
+	b.WriteString("| Policy | Rule | Resource | Rendered in | Message |\n")
+	b.WriteString("|--------|------|----------|-------------|---------|\n")
+	for _, v := range violations {
+		message := strings.ReplaceAll(strings.TrimSpace(v.Message), "\n", " ")
+		message = strings.ReplaceAll(message, "|", "\\|")
+		fmt.Fprintf(b, "| %s | %s | %s | %s | %s |\n", v.Policy, v.Rule, v.Resource(), strings.Join(v.Dirs, ", "), message)
+	}
+	b.WriteString("\n")
+}
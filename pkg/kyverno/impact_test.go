@@ -0,0 +1,172 @@
+package kyverno
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// fakeKyverno fails ConfigMap apps/legacy for policies mentioning "legacy"
+// and Deployment apps/web for policies mentioning "strict", like kyverno apply --policy-report
+const fakeKyverno = `#!/bin/sh
+policy="$2"
+echo "Applying 1 policy rule(s) to 2 resource(s)..."
+echo "----------------------------------------------------------------------"
+echo "POLICY REPORT:"
+echo "apiVersion: wgpolicyk8s.io/v1alpha2"
+echo "kind: ClusterPolicyReport"
+echo "results:"
+status=0
+if grep -q legacy "$policy"; then
+  printf -- '- policy: require-labels\n  rule: check-team\n  result: fail\n  message: label team is required\n  resources:\n  - kind: ConfigMap\n    name: legacy\n    namespace: apps\n'
+  status=1
+fi
+if grep -q strict "$policy"; then
+  printf -- '- policy: require-labels\n  rule: check-team\n  result: fail\n  message: label team is required\n  resources:\n  - kind: Deployment\n    name: web\n    namespace: apps\n'
+  status=1
+fi
+printf -- '- policy: require-labels\n  rule: check-team\n  result: pass\n  resources:\n  - kind: Service\n    name: web\n    namespace: apps\n'
+exit $status
+`
+
+// impactRepo commits a policy matching "legacy", tags it, then changes it to match "strict"
+func impactRepo(t *testing.T) string {
+	t.Helper()
+	for _, key := range []string{"GIT_AUTHOR_NAME", "GIT_COMMITTER_NAME"} {
+		t.Setenv(key, "test")
+	}
+	for _, key := range []string{"GIT_AUTHOR_EMAIL", "GIT_COMMITTER_EMAIL"} {
+		t.Setenv(key, "test@example.com")
+	}
+
+	bin := t.TempDir()
+	if err := os.WriteFile(filepath.Join(bin, "kyverno"), []byte(fakeKyverno), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	repo := t.TempDir()
+	git := func(args ...string) {
+		t.Helper()
+		if out, err := exec.Command("git", append([]string{"-C", repo}, args...)...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	write := func(rel, content string) {
+		t.Helper()
+		if err := os.MkdirAll(filepath.Join(repo, filepath.Dir(rel)), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(repo, rel), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	git("init", "-q")
+	write("policies/kyverno/base/cluster/require-labels.yaml", "kind: ClusterPolicy\n# legacy\n")
+	write("policies/kyverno/base/cluster/kustomization.yaml", "resources: []\n")
+	write("policies/kyverno/base/cluster/unchanged.yaml", "kind: ClusterPolicy\n# legacy\n")
+	git("add", "-A")
+	git("commit", "-q", "-m", "policies")
+	git("tag", "policy-base")
+
+	write("policies/kyverno/base/cluster/require-labels.yaml", "kind: ClusterPolicy\n# strict\n")
+	write("policies/kyverno/base/cluster/kustomization.yaml", "resources: [require-labels.yaml]\n")
+	write("policies/kyverno/overlays/erauner-home/cluster/new-policy.yaml", "kind: ClusterPolicy\n")
+	return repo
+}
+
+func TestChangedPolicies(t *testing.T) {
+	repo := impactRepo(t)
+	changes, err := NewTestRunner(repo, false).ChangedPolicies("policy-base")
+	if err != nil {
+		t.Fatalf("ChangedPolicies() error = %v", err)
+	}
+	var got []string
+	for _, c := range changes {
+		got = append(got, c.Path+" "+c.Change)
+	}
+	want := []string{
+		"policies/kyverno/base/cluster/require-labels.yaml modified",
+		"policies/kyverno/overlays/erauner-home/cluster/new-policy.yaml added",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ChangedPolicies() = %v, want %v", got, want)
+	}
+	if !strings.Contains(string(changes[0].Base), "legacy") || !strings.Contains(string(changes[0].Head), "strict") {
+		t.Errorf("unexpected contents %q -> %q", changes[0].Base, changes[0].Head)
+	}
+}
+
+func TestImpact(t *testing.T) {
+	repo := impactRepo(t)
+	manifests := map[string]string{
+		"apps/web/overlays/production": "apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: web\n  namespace: apps\n---\napiVersion: v1\nkind: Service\nmetadata:\n  name: web\n  namespace: apps\n",
+		"apps/old/overlays/production": "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: legacy\n  namespace: apps\n",
+	}
+	report, err := NewTestRunner(repo, false).Impact("policy-base", manifests)
+	if err != nil {
+		t.Fatalf("Impact() error = %v", err)
+	}
+	if report.Resources != 3 || len(report.Policies) != 2 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+
+	modified := report.Policies[0]
+	if len(modified.NewViolations) != 1 || modified.NewViolations[0].Resource() != "Deployment/apps/web" {
+		t.Errorf("NewViolations = %+v", modified.NewViolations)
+	}
+	if !reflect.DeepEqual(modified.NewViolations[0].Dirs, []string{"apps/web/overlays/production"}) {
+		t.Errorf("Dirs = %v", modified.NewViolations[0].Dirs)
+	}
+	if len(modified.Resolved) != 1 || modified.Resolved[0].Resource() != "ConfigMap/apps/legacy" {
+		t.Errorf("Resolved = %+v", modified.Resolved)
+	}
+	if added := report.Policies[1]; added.Change != PolicyAdded || len(added.NewViolations) != 0 || len(added.Resolved) != 0 {
+		t.Errorf("unexpected impact for the added policy %+v", added)
+	}
+	if report.NewViolations() != 1 || report.Resolved() != 1 {
+		t.Errorf("NewViolations() = %d, Resolved() = %d", report.NewViolations(), report.Resolved())
+	}
+
+	md := report.Markdown()
+	for _, want := range []string{"1 new violation(s), 1 resolved", "| require-labels | check-team | Deployment/apps/web | apps/web/overlays/production | label team is required |", "No rendered resource changes outcome"} {
+		if !strings.Contains(md, want) {
+			t.Errorf("Markdown() missing %q:\n%s", want, md)
+		}
+	}
+}
+
+func TestImpact_NoChanges(t *testing.T) {
+	repo := impactRepo(t)
+	for _, args := range [][]string{{"add", "-A"}, {"commit", "-q", "-m", "change policies"}} {
+		if out, err := exec.Command("git", append([]string{"-C", repo}, args...)...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+
+	report, err := NewTestRunner(repo, false).Impact("HEAD", nil)
+	if err != nil {
+		t.Fatalf("Impact() error = %v", err)
+	}
+	if len(report.Policies) != 0 {
+		t.Errorf("expected no changed policies, got %+v", report.Policies)
+	}
+
+	if _, err := NewTestRunner(repo, false).Impact("no-such-ref", nil); err == nil {
+		t.Error("expected an unknown base ref to fail")
+	}
+}
+
+func TestParsePolicyReport(t *testing.T) {
+	if _, found := ParsePolicyReport("Error: failed to load policies\n"); found {
+		t.Error("expected no report in an error message")
+	}
+	violations, found := ParsePolicyReport("Applying...\napiVersion: wgpolicyk8s.io/v1alpha2\nkind: PolicyReport\nresults:\n- policy: p\n  rule: r\n  result: warn\n  resources:\n  - kind: Pod\n    name: a\n")
+	if !found || len(violations) != 0 {
+		t.Errorf("expected warnings not to count as violations, got %+v (found %v)", violations, found)
+	}
+}
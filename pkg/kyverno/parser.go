@@ -1,156 +1,102 @@
 package kyverno
 
 import (
-	"regexp"
-	"strconv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"strings"
 )
 
-// TestSummary represents parsed test results
+// TestSummary counts test case results
 type TestSummary struct {
 	Passed int
 	Failed int
 	Total  int
 }
 
-// ParseSummary parses the "Test Summary: X tests passed and Y tests failed" line
-func ParseSummary(output string) TestSummary {
-	summary := TestSummary{}
-
-	// Pattern: "Test Summary: X tests passed and Y tests failed"
-	re := regexp.MustCompile(`Test Summary:\s*(\d+)\s+tests?\s+passed\s+and\s+(\d+)\s+tests?\s+failed`)
-	matches := re.FindStringSubmatch(output)
-
-	if len(matches) == 3 {
-		summary.Passed, _ = strconv.Atoi(matches[1])
-		summary.Failed, _ = strconv.Atoi(matches[2])
-		summary.Total = summary.Passed + summary.Failed
-	}
-
-	return summary
-}
-
-// DetailedResult represents a single test case result from detailed output
+// DetailedResult is one test case result of kyverno test: a policy rule
+// applied to a resource, with the expected result it did or didn't meet
 type DetailedResult struct {
-	ID       int
-	Policy   string
-	Rule     string
-	Resource string
-	Result   string // "Pass" or "Fail"
-	Reason   string
+	ID        int    `json:"id"`
+	Policy    string `json:"policy"`
+	Rule      string `json:"rule"`
+	Resource  string `json:"resource"`
+	Result    string `json:"result"` // "Pass" or "Fail"
+	Reason    string `json:"reason"`
+	Message   string `json:"message,omitempty"`
+	IsFailure bool   `json:"isFailure,omitempty"`
+
+	// Cluster is the overlay whose tests produced the result ("" for base);
+	// set by RunTestsDir, not kyverno
+	Cluster string `json:"cluster,omitempty"`
 }
 
-// ParseDetailedResults parses the table output from --detailed-results
-func ParseDetailedResults(output string) []DetailedResult {
-	var results []DetailedResult
-
-	lines := strings.Split(output, "\n")
-	inTable := false
+// Failed reports whether the test case failed
+func (r DetailedResult) Failed() bool {
+	return r.IsFailure || strings.EqualFold(r.Result, "fail")
+}
 
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
+// testResultsTable is the kyverno test --output-format json document; the
+// CLI marshals its results table, whose field names carry no JSON tags
+type testResultsTable struct {
+	RawRows []DetailedResult `json:"RawRows"`
+}
 
-		// Detect table start (header row with ID | POLICY | RULE | ...)
-		if strings.HasPrefix(line, "ID") && strings.Contains(line, "POLICY") && strings.Contains(line, "RESULT") {
-			inTable = true
-			continue
+// ParseTestJSON parses the results kyverno test --output-format json prints
+// to stdout after its progress lines. Field names match case-insensitively, and a bare
+// array of results is accepted as well as the results table object
+func ParseTestJSON(output string) ([]DetailedResult, error) {
+	start, offset := -1, 0
+	for _, line := range strings.SplitAfter(output, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "{") || strings.HasPrefix(trimmed, "[") {
+			start = offset
+			break
 		}
+		offset += len(line)
+	}
+	if start < 0 {
+		return nil, errors.New("no JSON results in kyverno test output")
+	}
 
-		// Skip separator lines
-		if strings.HasPrefix(line, "--") || strings.HasPrefix(line, "==") {
-			continue
+	results := []DetailedResult{}
+	decoder := json.NewDecoder(strings.NewReader(output[start:]))
+	for {
+		var raw json.RawMessage
+		if err := decoder.Decode(&raw); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, fmt.Errorf("failed to parse kyverno test results: %w", err)
 		}
-
-		// Skip empty lines
-		if line == "" {
+		if strings.HasPrefix(strings.TrimSpace(string(raw)), "[") {
+			var rows []DetailedResult
+			if err := json.Unmarshal(raw, &rows); err != nil {
+				return nil, fmt.Errorf("failed to parse kyverno test results: %w", err)
+			}
+			results = append(results, rows...)
 			continue
 		}
-
-		// Parse table rows
-		if inTable && strings.Contains(line, "|") {
-			result := parseTableRow(line)
-			if result != nil {
-				results = append(results, *result)
-			}
+		var table testResultsTable
+		if err := json.Unmarshal(raw, &table); err != nil {
+			return nil, fmt.Errorf("failed to parse kyverno test results: %w", err)
 		}
+		results = append(results, table.RawRows...)
 	}
-
-	return results
+	return results, nil
 }
 
-// parseTableRow parses a single row from the kyverno test output table
-func parseTableRow(line string) *DetailedResult {
-	// Split by | and trim whitespace
-	parts := strings.Split(line, "|")
-	if len(parts) < 5 {
-		return nil
-	}
-
-	// Clean up each part
-	cleaned := make([]string, len(parts))
-	for i, p := range parts {
-		cleaned[i] = strings.TrimSpace(p)
-	}
-
-	// Try to parse ID
-	id, err := strconv.Atoi(cleaned[0])
-	if err != nil {
-		// Not a data row
-		return nil
-	}
-
-	result := &DetailedResult{
-		ID: id,
-	}
-
-	// Map remaining fields (order may vary)
-	if len(cleaned) > 1 {
-		result.Policy = cleaned[1]
-	}
-	if len(cleaned) > 2 {
-		result.Rule = cleaned[2]
-	}
-	if len(cleaned) > 3 {
-		result.Resource = cleaned[3]
-	}
-	if len(cleaned) > 4 {
-		result.Result = cleaned[4]
-	}
-	if len(cleaned) > 5 {
-		result.Reason = cleaned[5]
-	}
-
-	return result
-}
-
-// CountResults counts passed and failed results
-func CountResults(results []DetailedResult) (passed, failed int) {
+// Summarize counts passed and failed results
+func Summarize(results []DetailedResult) TestSummary {
+	var summary TestSummary
 	for _, r := range results {
-		switch strings.ToLower(r.Result) {
-		case "pass":
-			passed++
-		case "fail":
-			failed++
+		if r.Failed() {
+			summary.Failed++
+		} else {
+			summary.Passed++
 		}
 	}
-	return
-}
-
-// HasFailures checks if any results failed
-func HasFailures(output string) bool {
-	// Quick check for failure indicators
-	if strings.Contains(output, "| Fail |") || strings.Contains(output, "|Fail|") {
-		return true
-	}
-
-	// Parse summary
-	summary := ParseSummary(output)
-	if summary.Failed > 0 {
-		return true
-	}
-
-	// Parse detailed results
-	results := ParseDetailedResults(output)
-	_, failed := CountResults(results)
-	return failed > 0
+	summary.Total = len(results)
+	return summary
 }
@@ -2,12 +2,15 @@
 package kyverno
 
 import (
+	"bytes"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
 
+	"github.com/erauner/homelab-shadow/pkg/command"
+	"github.com/erauner/homelab-shadow/pkg/log"
 	"gopkg.in/yaml.v3"
 )
 
@@ -26,17 +29,44 @@ type kyvernoTestFile struct {
 	Policies []string `yaml:"policies"`
 }
 
+// DefaultRoots are the repo-relative Kyverno policy trees used when none are configured
+var DefaultRoots = []string{"policies/kyverno"}
+
 // TestRunner runs Kyverno policy tests
 type TestRunner struct {
 	RepoPath string
-	Verbose  bool
+	Log      *log.Logger // commands are logged at debug level
+
+	// Roots are repo-relative policy trees, each with base/ and
+	// overlays/<cluster>/ (default: DefaultRoots)
+	Roots []string
+
+	// Clusters limits overlays to these clusters; base policies always apply
+	Clusters []string
+}
+
+// PolicySet is the cluster policies and tests of one base or cluster overlay
+type PolicySet struct {
+	Cluster    string // "" for base
+	ClusterDir string // <root>/base/cluster or <root>/overlays/<cluster>/cluster
+	TestsDir   string // <root>/base/tests or <root>/overlays/<cluster>/tests
+}
+
+// Name returns the cluster, or "base"
+func (p PolicySet) Name() string {
+	if p.Cluster == "" {
+		return "base"
+	}
+	return p.Cluster
 }
 
 // TestResult represents the result of a single policy test
 type TestResult struct {
 	PolicyName string
 	Passed     bool
-	Output     string
+	Output     string           // raw kyverno test output
+	Results    []DetailedResult // test cases, from kyverno's JSON output
+	Summary    TestSummary
 	Error      error
 	Skipped    bool
 	SkipReason string
@@ -46,24 +76,79 @@ type TestResult struct {
 func NewTestRunner(repoPath string, verbose bool) *TestRunner {
 	return &TestRunner{
 		RepoPath: repoPath,
-		Verbose:  verbose,
+		Log:      log.Default().Named("kyverno").Verbose(verbose),
+	}
+}
+
+// PolicySets returns the base and every cluster overlay under each root,
+// overlays sorted by cluster and limited to Clusters when set. On error the
+// sets discovered so far are returned with it
+func (r *TestRunner) PolicySets() ([]PolicySet, error) {
+	roots := r.Roots
+	if len(roots) == 0 {
+		roots = DefaultRoots
+	}
+	wanted := make(map[string]bool)
+	for _, cluster := range r.Clusters {
+		wanted[cluster] = true
+	}
+
+	var sets []PolicySet
+	for _, root := range roots {
+		root = filepath.Join(r.RepoPath, filepath.FromSlash(root))
+		sets = append(sets, PolicySet{
+			ClusterDir: filepath.Join(root, "base", "cluster"),
+			TestsDir:   filepath.Join(root, "base", "tests"),
+		})
+
+		entries, err := os.ReadDir(filepath.Join(root, "overlays"))
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return sets, fmt.Errorf("failed to read policy overlays: %w", err)
+		}
+		for _, entry := range entries {
+			if !entry.IsDir() || (len(wanted) > 0 && !wanted[entry.Name()]) {
+				continue
+			}
+			overlay := filepath.Join(root, "overlays", entry.Name())
+			sets = append(sets, PolicySet{
+				Cluster:    entry.Name(),
+				ClusterDir: filepath.Join(overlay, "cluster"),
+				TestsDir:   filepath.Join(overlay, "tests"),
+			})
+		}
+	}
+	return sets, nil
+}
+
+// policySets is PolicySets, logging a discovery error so one unreadable
+// overlays directory doesn't hide the policies found before it
+func (r *TestRunner) policySets() []PolicySet {
+	sets, err := r.PolicySets()
+	if err != nil {
+		r.Log.Warnf("%v", err)
 	}
+	return sets
 }
 
 // testsDirs returns all test directories (base + overlays)
 func (r *TestRunner) testsDirs() []string {
-	return []string{
-		filepath.Join(r.RepoPath, "policies", "kyverno", "base", "tests"),
-		filepath.Join(r.RepoPath, "policies", "kyverno", "overlays", "erauner-home", "tests"),
+	var dirs []string
+	for _, set := range r.policySets() {
+		dirs = append(dirs, set.TestsDir)
 	}
+	return dirs
 }
 
 // clusterDirs returns all cluster policy directories (base + overlays)
 func (r *TestRunner) clusterDirs() []string {
-	return []string{
-		filepath.Join(r.RepoPath, "policies", "kyverno", "base", "cluster"),
-		filepath.Join(r.RepoPath, "policies", "kyverno", "overlays", "erauner-home", "cluster"),
+	var dirs []string
+	for _, set := range r.policySets() {
+		dirs = append(dirs, set.ClusterDir)
 	}
+	return dirs
 }
 
 // DiscoverTests finds all Kyverno test directories
@@ -197,18 +282,20 @@ func (r *TestRunner) CheckCoverage() (covered []string, missing []string, skippe
 	return covered, missing, skipped, nil
 }
 
-// findTestDir locates the test directory for a policy across base and overlays
-func (r *TestRunner) findTestDir(policyName string) string {
-	for _, testsDir := range r.testsDirs() {
-		testDir := filepath.Join(testsDir, policyName)
+// findTestDirs locates the test directories for a policy across base and
+// overlays, keyed by the policy set holding them
+func (r *TestRunner) findTestDirs(policyName string) map[PolicySet]string {
+	dirs := make(map[PolicySet]string)
+	for _, set := range r.policySets() {
+		testDir := filepath.Join(set.TestsDir, policyName)
 		if _, err := os.Stat(testDir); err == nil {
-			return testDir
+			dirs[set] = testDir
 		}
 	}
-	return ""
+	return dirs
 }
 
-// RunTest runs a single policy test
+// RunTest runs a policy's tests in base and every overlay that has them
 func (r *TestRunner) RunTest(policyName string) TestResult {
 	// Check if policy should be skipped
 	if reason, ok := SkipPolicies[policyName]; ok {
@@ -220,10 +307,10 @@ func (r *TestRunner) RunTest(policyName string) TestResult {
 		}
 	}
 
-	testDir := r.findTestDir(policyName)
+	testDirs := r.findTestDirs(policyName)
 
 	// Check if test directory exists
-	if testDir == "" {
+	if len(testDirs) == 0 {
 		return TestResult{
 			PolicyName: policyName,
 			Passed:     false,
@@ -231,26 +318,32 @@ func (r *TestRunner) RunTest(policyName string) TestResult {
 		}
 	}
 
-	// Run kyverno test
-	cmd := exec.Command("kyverno", "test", testDir, "--detailed-results")
-	output, err := cmd.CombinedOutput()
-
-	result := TestResult{
-		PolicyName: policyName,
-		Output:     string(output),
-	}
-
-	if err != nil {
-		result.Passed = false
-		result.Error = fmt.Errorf("kyverno test failed: %w", err)
-	} else {
-		// Parse output to determine pass/fail
-		result.Passed = r.parseTestOutput(string(output))
-		if !result.Passed {
-			result.Error = fmt.Errorf("test assertions failed")
+	result := TestResult{PolicyName: policyName, Passed: true}
+	var output strings.Builder
+	for _, set := range r.policySets() {
+		testDir, ok := testDirs[set]
+		if !ok {
+			continue
+		}
+		results, out, err := r.runTests(testDir)
+		if len(testDirs) > 1 {
+			fmt.Fprintf(&output, "=== Tests for %s from %s ===\n", set.Name(), testDir)
+		}
+		output.WriteString(out)
+		for i := range results {
+			results[i].Cluster = set.Cluster
+		}
+		result.Results = append(result.Results, results...)
+		if err != nil && result.Error == nil {
+			result.Passed = false
+			result.Error = err
+			if len(testDirs) > 1 {
+				result.Error = fmt.Errorf("%s: %w", set.Name(), err)
+			}
 		}
 	}
-
+	result.Output = output.String()
+	result.Summary = Summarize(result.Results)
 	return result
 }
 
@@ -273,31 +366,29 @@ func (r *TestRunner) RunAllTests() ([]TestResult, error) {
 // RunTestsDir runs kyverno test on all tests directories (base + overlays)
 func (r *TestRunner) RunTestsDir() TestResult {
 	var allOutput strings.Builder
+	var allResults []DetailedResult
 	allPassed := true
 	var firstError error
 
-	for _, testsDir := range r.testsDirs() {
+	for _, set := range r.policySets() {
 		// Skip if directory doesn't exist
-		if _, err := os.Stat(testsDir); os.IsNotExist(err) {
+		if _, err := os.Stat(set.TestsDir); os.IsNotExist(err) {
 			continue
 		}
 
-		cmd := exec.Command("kyverno", "test", testsDir, "--detailed-results")
-		output, err := cmd.CombinedOutput()
-
-		allOutput.WriteString(fmt.Sprintf("=== Tests from %s ===\n", testsDir))
-		allOutput.Write(output)
+		results, output, err := r.runTests(set.TestsDir)
+		allOutput.WriteString(fmt.Sprintf("=== Tests for %s from %s ===\n", set.Name(), set.TestsDir))
+		allOutput.WriteString(output)
 		allOutput.WriteString("\n")
+		for i := range results {
+			results[i].Cluster = set.Cluster
+		}
+		allResults = append(allResults, results...)
 
 		if err != nil {
 			allPassed = false
 			if firstError == nil {
-				firstError = fmt.Errorf("kyverno test failed in %s: %w", testsDir, err)
-			}
-		} else if !r.parseTestOutput(string(output)) {
-			allPassed = false
-			if firstError == nil {
-				firstError = fmt.Errorf("test assertions failed in %s", testsDir)
+				firstError = fmt.Errorf("%s: %w", set.Name(), err)
 			}
 		}
 	}
@@ -305,6 +396,8 @@ func (r *TestRunner) RunTestsDir() TestResult {
 	result := TestResult{
 		PolicyName: "all",
 		Output:     allOutput.String(),
+		Results:    allResults,
+		Summary:    Summarize(allResults),
 		Passed:     allPassed,
 		Error:      firstError,
 	}
@@ -312,43 +405,38 @@ func (r *TestRunner) RunTestsDir() TestResult {
 	return result
 }
 
-// parseTestOutput parses kyverno test output to determine pass/fail
-func (r *TestRunner) parseTestOutput(output string) bool {
-	// Kyverno outputs a summary line like:
-	// Test Summary: 4 tests passed and 0 tests failed
-	// Or individual test results with Pass/Fail markers
-
-	// Check for explicit failure markers
-	if strings.Contains(output, "tests failed") {
-		// Parse the "X tests failed" part
-		lines := strings.Split(output, "\n")
-		for _, line := range lines {
-			if strings.Contains(line, "Test Summary:") {
-				if strings.Contains(line, "0 tests failed") {
-					return true
-				}
-				return false
-			}
+// runTests runs kyverno test on dir and parses its JSON results
+// The error is set when kyverno fails, prints no results, or a test case fails
+func (r *TestRunner) runTests(dir string) ([]DetailedResult, string, error) {
+	r.Log.Debugf("kyverno test %s --detailed-results --output-format json", dir)
+	var stdout, stderr bytes.Buffer
+	cmd := command.Command("kyverno", "test", dir, "--detailed-results", "--output-format", "json")
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	// Only stdout carries the results; stderr (warnings, klog lines) is kept for display
+	output := stdout.String() + stderr.String()
+
+	results, parseErr := ParseTestJSON(stdout.String())
+	if parseErr != nil {
+		if err != nil {
+			return nil, output, fmt.Errorf("kyverno test failed: %w", err)
 		}
+		// --output-format needs kyverno 1.12 or later
+		return nil, output, fmt.Errorf("%w (kyverno 1.12+ is required)", parseErr)
 	}
-
-	// Check for any "Fail" in the detailed results
-	if strings.Contains(output, "| Fail |") || strings.Contains(output, "|Fail|") {
-		return false
+	if summary := Summarize(results); summary.Failed > 0 {
+		return results, output, fmt.Errorf("%d of %d test case(s) failed", summary.Failed, summary.Total)
 	}
-
-	// If we see the success pattern and no failures, it passed
-	if strings.Contains(output, "tests passed") {
-		return true
+	if err != nil {
+		return results, output, fmt.Errorf("kyverno test failed: %w", err)
 	}
-
-	// Default to checking exit code (already handled by caller)
-	return true
+	return results, output, nil
 }
 
 // KyvernoVersion returns the installed kyverno CLI version
 func KyvernoVersion() (string, error) {
-	cmd := exec.Command("kyverno", "version")
+	cmd := command.Command("kyverno", "version")
 	output, err := cmd.CombinedOutput()
 	if err != nil {
 		return "", fmt.Errorf("failed to get kyverno version: %w", err)
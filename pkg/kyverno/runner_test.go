@@ -17,29 +17,39 @@ package kyverno
 // For CI, this isn't an issue because each build starts fresh.
 
 import (
+	"flag"
 	"os"
 	"path/filepath"
+	"reflect"
 	"runtime"
+	"strings"
 	"testing"
 )
 
-// getRepoRoot returns the path to the homelab-k8s repository root
+// repoFlag points the policy tests at a homelab-k8s checkout:
+//
+//	go test ./pkg/kyverno/ -args -repo /path/to/homelab-k8s
+var repoFlag = flag.String("repo", "", "homelab-k8s checkout to test policies in (default: the testdata fixture repo)")
+
+// getRepoRoot returns the repository the policy tests run against: -repo, or
+// the fixture repo under testdata/ at the module root
 // This allows tests to run from any directory
 func getRepoRoot(t *testing.T) string {
 	t.Helper()
 
-	// Get the directory of this test file
-	_, filename, _, ok := runtime.Caller(0)
-	if !ok {
-		t.Fatal("Failed to get test file path")
+	repoRoot := *repoFlag
+	if repoRoot == "" {
+		// Get the directory of this test file
+		_, filename, _, ok := runtime.Caller(0)
+		if !ok {
+			t.Fatal("Failed to get test file path")
+		}
+		repoRoot = filepath.Join(filepath.Dir(filename), "..", "..", "testdata", "repo")
 	}
 
-	// Walk up from tools/shadow/pkg/kyverno to repo root
-	repoRoot := filepath.Join(filepath.Dir(filename), "..", "..", "..", "..")
-
 	// Verify it's the right directory by checking for base/overlays structure
 	if _, err := os.Stat(filepath.Join(repoRoot, "policies", "kyverno", "base")); err != nil {
-		t.Fatalf("Failed to find repo root from %s: %v", filename, err)
+		t.Fatalf("Failed to find repo root %s: %v", repoRoot, err)
 	}
 
 	absPath, err := filepath.Abs(repoRoot)
@@ -88,12 +98,8 @@ func TestAllKyvernoPolicies(t *testing.T) {
 	}
 }
 
-// TestKyvernoPolicyCoverage checks that all policies have tests
+// TestKyvernoPolicyCoverage checks that all policies have tests (no CLI needed)
 func TestKyvernoPolicyCoverage(t *testing.T) {
-	if !IsKyvernoInstalled() {
-		t.Skip("kyverno CLI not installed")
-	}
-
 	repoRoot := getRepoRoot(t)
 	runner := NewTestRunner(repoRoot, testing.Verbose())
 
@@ -156,6 +162,11 @@ func runSinglePolicyTest(t *testing.T, policyName string) {
 	repoRoot := getRepoRoot(t)
 	runner := NewTestRunner(repoRoot, testing.Verbose())
 
+	// The fixture repo carries only a sample of the policies
+	if *repoFlag == "" && len(runner.findTestDirs(policyName)) == 0 {
+		t.Skipf("Policy %s is not in the fixture repo (run with -repo to test it)", policyName)
+	}
+
 	result := runner.RunTest(policyName)
 
 	if result.Skipped {
@@ -174,76 +185,221 @@ func runSinglePolicyTest(t *testing.T, policyName string) {
 	}
 }
 
-// TestParserSummary tests the output parser
-func TestParserSummary(t *testing.T) {
+// fakeKyvernoTest prints kyverno test --output-format json results after its
+// progress lines, then a warning on stderr; a test directory containing
+// "broken" fails one test case
+const fakeKyvernoTest = `#!/bin/sh
+echo "Loading test ( $2/kyverno-test.yaml ) ..."
+echo "  Loading values/variables ..."
+if [ -e "$2/broken" ]; then
+  echo '{"RawRows":[{"IsFailure":false,"ID":1,"Policy":"require-labels","Rule":"check-team","Resource":"apps/Deployment/default/good","Result":"Pass","Reason":"Ok"},'
+  echo '{"IsFailure":true,"ID":2,"Policy":"require-labels","Rule":"check-team","Resource":"apps/Deployment/default/bad","Result":"Fail","Reason":"Want pass, got fail","Message":"label team is required"}]}'
+  echo "W1016 09:00:00.000000 warnings.go:70] policies.kyverno.io/v1 is deprecated" >&2
+  exit 1
+fi
+echo '{"RawRows":[{"IsFailure":false,"ID":1,"Policy":"require-labels","Rule":"check-team","Resource":"apps/Deployment/default/good","Result":"Pass","Reason":"Ok"}]}'
+echo "W1016 09:00:00.000000 warnings.go:70] policies.kyverno.io/v1 is deprecated" >&2
+`
+
+func fakeTestRepo(t *testing.T, broken bool) *TestRunner {
+	t.Helper()
+	bin := t.TempDir()
+	if err := os.WriteFile(filepath.Join(bin, "kyverno"), []byte(fakeKyvernoTest), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	repo := t.TempDir()
+	testDir := filepath.Join(repo, "policies", "kyverno", "base", "tests", "require-labels")
+	if err := os.MkdirAll(testDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(testDir, "kyverno-test.yaml"), []byte("policies: [../../cluster/require-labels.yaml]\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if broken {
+		if err := os.WriteFile(filepath.Join(testDir, "broken"), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return NewTestRunner(repo, false)
+}
+
+func TestRunTest_JSONResults(t *testing.T) {
+	result := fakeTestRepo(t, false).RunTest("require-labels")
+	if !result.Passed || result.Error != nil {
+		t.Fatalf("result = %+v", result)
+	}
+	want := []DetailedResult{{ID: 1, Policy: "require-labels", Rule: "check-team", Resource: "apps/Deployment/default/good", Result: "Pass", Reason: "Ok"}}
+	if !reflect.DeepEqual(result.Results, want) {
+		t.Errorf("results = %+v, want %+v", result.Results, want)
+	}
+	if result.Summary != (TestSummary{Passed: 1, Total: 1}) {
+		t.Errorf("summary = %+v", result.Summary)
+	}
+}
+
+func TestRunTest_FailedCase(t *testing.T) {
+	result := fakeTestRepo(t, true).RunTest("require-labels")
+	if result.Passed {
+		t.Fatal("expected failure")
+	}
+	if result.Summary != (TestSummary{Passed: 1, Failed: 1, Total: 2}) {
+		t.Errorf("summary = %+v", result.Summary)
+	}
+	if result.Error == nil || result.Error.Error() != "1 of 2 test case(s) failed" {
+		t.Errorf("error = %v", result.Error)
+	}
+	if failed := result.Results[1]; !failed.Failed() || failed.Message != "label team is required" {
+		t.Errorf("failed case = %+v", failed)
+	}
+}
+
+func TestRunTestsDir_NoJSON(t *testing.T) {
+	runner := fakeTestRepo(t, false)
+	bin := t.TempDir()
+	// kyverno before 1.12 rejects --output-format
+	old := "#!/bin/sh\necho 'Error: unknown flag: --output-format'\nexit 1\n"
+	if err := os.WriteFile(filepath.Join(bin, "kyverno"), []byte(old), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	result := runner.RunTestsDir()
+	if result.Passed || result.Error == nil {
+		t.Fatalf("result = %+v, want failure", result)
+	}
+	if !strings.Contains(result.Output, "unknown flag") {
+		t.Errorf("output = %q, want kyverno's output", result.Output)
+	}
+}
+
+func TestParseTestJSON(t *testing.T) {
 	testCases := []struct {
-		name     string
-		output   string
-		expected TestSummary
+		name    string
+		output  string
+		want    TestSummary
+		wantErr bool
 	}{
 		{
-			name:     "all passed",
-			output:   "Test Summary: 4 tests passed and 0 tests failed",
-			expected: TestSummary{Passed: 4, Failed: 0, Total: 4},
+			name:   "results table after progress lines",
+			output: "Loading test ( kyverno-test.yaml ) ...\n{\"RawRows\": [{\"ID\": 1, \"Result\": \"Pass\"}, {\"ID\": 2, \"Result\": \"Fail\"}]}\n",
+			want:   TestSummary{Passed: 1, Failed: 1, Total: 2},
 		},
 		{
-			name:     "some failed",
-			output:   "Test Summary: 3 tests passed and 2 tests failed",
-			expected: TestSummary{Passed: 3, Failed: 2, Total: 5},
+			name:   "bare array, lowercase fields",
+			output: "[{\"id\": 1, \"policy\": \"p\", \"result\": \"pass\"}]",
+			want:   TestSummary{Passed: 1, Total: 1},
 		},
 		{
-			name:     "singular test",
-			output:   "Test Summary: 1 test passed and 0 tests failed",
-			expected: TestSummary{Passed: 1, Failed: 0, Total: 1},
+			name:   "failure flag without result",
+			output: "{\"RawRows\": [{\"ID\": 1, \"IsFailure\": true}]}",
+			want:   TestSummary{Failed: 1, Total: 1},
 		},
-	}
-
-	for _, tc := range testCases {
-		t.Run(tc.name, func(t *testing.T) {
-			result := ParseSummary(tc.output)
-			if result.Passed != tc.expected.Passed {
-				t.Errorf("Passed: got %d, want %d", result.Passed, tc.expected.Passed)
-			}
-			if result.Failed != tc.expected.Failed {
-				t.Errorf("Failed: got %d, want %d", result.Failed, tc.expected.Failed)
-			}
-			if result.Total != tc.expected.Total {
-				t.Errorf("Total: got %d, want %d", result.Total, tc.expected.Total)
-			}
-		})
-	}
-}
-
-// TestHasFailures tests failure detection
-func TestHasFailures(t *testing.T) {
-	testCases := []struct {
-		name     string
-		output   string
-		expected bool
-	}{
 		{
-			name:     "no failures",
-			output:   "Test Summary: 4 tests passed and 0 tests failed",
-			expected: false,
+			name:   "no results",
+			output: "{\"RawRows\": null}",
+			want:   TestSummary{},
 		},
 		{
-			name:     "has failures in summary",
-			output:   "Test Summary: 3 tests passed and 1 tests failed",
-			expected: true,
+			name:    "table output",
+			output:  "Test Summary: 4 tests passed and 0 tests failed",
+			wantErr: true,
 		},
 		{
-			name:     "has Fail in table",
-			output:   "1 | policy | rule | resource | Fail | reason",
-			expected: true,
+			name:    "truncated",
+			output:  "{\"RawRows\": [{\"ID\": 1",
+			wantErr: true,
 		},
 	}
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			result := HasFailures(tc.output)
-			if result != tc.expected {
-				t.Errorf("HasFailures: got %v, want %v", result, tc.expected)
+			results, err := ParseTestJSON(tc.output)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tc.wantErr)
+			}
+			if got := Summarize(results); got != tc.want {
+				t.Errorf("summary = %+v, want %+v", got, tc.want)
 			}
 		})
 	}
 }
+
+func TestPolicySets(t *testing.T) {
+	repo := t.TempDir()
+	for _, dir := range []string{
+		"policies/kyverno/base/cluster",
+		"policies/kyverno/overlays/erauner-home/cluster",
+		"policies/kyverno/overlays/erauner-cloud/tests",
+		"platform/policies/base/cluster",
+	} {
+		if err := os.MkdirAll(filepath.Join(repo, filepath.FromSlash(dir)), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Files next to the overlays are not clusters
+	if err := os.WriteFile(filepath.Join(repo, "policies/kyverno/overlays/README.md"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	names := func(r *TestRunner) []string {
+		t.Helper()
+		sets, err := r.PolicySets()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var out []string
+		for _, set := range sets {
+			rel, _ := filepath.Rel(repo, set.TestsDir)
+			out = append(out, set.Name()+"="+filepath.ToSlash(rel))
+		}
+		return out
+	}
+
+	runner := NewTestRunner(repo, false)
+	want := []string{
+		"base=policies/kyverno/base/tests",
+		"erauner-cloud=policies/kyverno/overlays/erauner-cloud/tests",
+		"erauner-home=policies/kyverno/overlays/erauner-home/tests",
+	}
+	if got := names(runner); !reflect.DeepEqual(got, want) {
+		t.Errorf("sets = %v, want %v", got, want)
+	}
+
+	runner.Clusters = []string{"erauner-home"}
+	want = []string{"base=policies/kyverno/base/tests", "erauner-home=policies/kyverno/overlays/erauner-home/tests"}
+	if got := names(runner); !reflect.DeepEqual(got, want) {
+		t.Errorf("sets with cluster filter = %v, want %v", got, want)
+	}
+
+	runner.Clusters = nil
+	runner.Roots = []string{"platform/policies"}
+	want = []string{"base=platform/policies/base/tests"}
+	if got := names(runner); !reflect.DeepEqual(got, want) {
+		t.Errorf("sets with custom root = %v, want %v", got, want)
+	}
+}
+
+func TestRunTestsDir_KeyedByCluster(t *testing.T) {
+	runner := fakeTestRepo(t, false)
+	overlayTest := filepath.Join(runner.RepoPath, "policies", "kyverno", "overlays", "erauner-cloud", "tests")
+	if err := os.MkdirAll(overlayTest, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	result := runner.RunTestsDir()
+	if !result.Passed {
+		t.Fatalf("result = %+v", result)
+	}
+	var clusters []string
+	for _, r := range result.Results {
+		clusters = append(clusters, r.Cluster)
+	}
+	if want := []string{"", "erauner-cloud"}; !reflect.DeepEqual(clusters, want) {
+		t.Errorf("result clusters = %q, want %q", clusters, want)
+	}
+	if !strings.Contains(result.Output, "=== Tests for erauner-cloud from ") {
+		t.Errorf("output = %q, want a section per cluster", result.Output)
+	}
+}
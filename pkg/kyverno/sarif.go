@@ -0,0 +1,112 @@
+package kyverno
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+)
+
+// SARIF 2.1.0 output, for GitHub code scanning and other SARIF consumers
+const (
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+	sarifVersion = "2.1.0"
+)
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	Version        string      `json:"version,omitempty"`
+	InformationURI string      `json:"informationUri,omitempty"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation  `json:"physicalLocation"`
+	LogicalLocations []sarifLogicalLocation `json:"logicalLocations,omitempty"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifLogicalLocation struct {
+	FullyQualifiedName string `json:"fullyQualifiedName"`
+	Kind               string `json:"kind"`
+}
+
+// SARIF renders the report as a SARIF 2.1.0 log with one rule per
+// policy/rule and one error result per violation and rendered directory
+func (r ScanReport) SARIF(toolVersion string) ([]byte, error) {
+	rules := make(map[string]sarifRule)
+	results := []sarifResult{}
+	for _, overlay := range r.Overlays() {
+		for _, v := range overlay.Violations {
+			id := v.Policy + "/" + v.Rule
+			rules[id] = sarifRule{ID: id, ShortDescription: sarifMessage{Text: "Kyverno policy " + v.Policy + ", rule " + v.Rule}}
+			message := strings.TrimSpace(v.Message)
+			if message == "" {
+				message = "fails " + id
+			}
+			results = append(results, sarifResult{
+				RuleID:  id,
+				Level:   "error",
+				Message: sarifMessage{Text: v.Resource() + ": " + message},
+				Locations: []sarifLocation{{
+					PhysicalLocation: sarifPhysicalLocation{ArtifactLocation: sarifArtifactLocation{URI: overlay.Dir}},
+					LogicalLocations: []sarifLogicalLocation{{FullyQualifiedName: v.Resource(), Kind: "resource"}},
+				}},
+			})
+		}
+	}
+
+	driver := sarifDriver{
+		Name:           "shadow-kyverno",
+		Version:        toolVersion,
+		InformationURI: "https://kyverno.io",
+		Rules:          []sarifRule{},
+	}
+	for _, rule := range rules {
+		driver.Rules = append(driver.Rules, rule)
+	}
+	sort.Slice(driver.Rules, func(i, j int) bool { return driver.Rules[i].ID < driver.Rules[j].ID })
+
+	return json.MarshalIndent(sarifLog{
+		Schema:  sarifSchema,
+		Version: sarifVersion,
+		Runs:    []sarifRun{{Tool: sarifTool{Driver: driver}, Results: results}},
+	}, "", "  ")
+}
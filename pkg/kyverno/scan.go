@@ -0,0 +1,137 @@
+package kyverno
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/erauner/homelab-shadow/pkg/command"
+)
+
+// ScanReport is the result of applying every cluster policy to the rendered manifests
+type ScanReport struct {
+	Policies   []string    `json:"policies"`          // repo-relative policy files applied
+	Skipped    []string    `json:"skipped,omitempty"` // SkipPolicies left out of the scan
+	Resources  int         `json:"resources"`
+	Violations []Violation `json:"violations"`
+}
+
+// OverlayViolations are the violations of resources rendered from one directory
+type OverlayViolations struct {
+	Dir        string      `json:"dir"`
+	Violations []Violation `json:"violations"`
+}
+
+// Overlays groups the violations by rendered directory, sorted by directory
+// A resource rendered by several overlays is listed under each of them
+func (r ScanReport) Overlays() []OverlayViolations {
+	byDir := make(map[string][]Violation)
+	for _, v := range r.Violations {
+		for _, dir := range v.Dirs {
+			byDir[dir] = append(byDir[dir], v)
+		}
+	}
+	overlays := make([]OverlayViolations, 0, len(byDir))
+	for dir, violations := range byDir {
+		overlays = append(overlays, OverlayViolations{Dir: dir, Violations: violations})
+	}
+	sort.Slice(overlays, func(i, j int) bool { return overlays[i].Dir < overlays[j].Dir })
+	return overlays
+}
+
+// ScanPolicies returns the policy files Scan applies: every policy under the
+// cluster policy directories except SkipPolicies, first directory winning when
+// base and overlay define the same name. Skipped lists the SkipPolicies found
+func (r *TestRunner) ScanPolicies() (policies, skipped []string, err error) {
+	seen := make(map[string]bool)
+	for _, dir := range r.clusterDirs() {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, nil, fmt.Errorf("failed to read policies directory %s: %w", dir, err)
+		}
+		for _, entry := range entries {
+			if entry.IsDir() || !isPolicyFile(entry.Name()) {
+				continue
+			}
+			name := strings.TrimSuffix(strings.TrimSuffix(entry.Name(), ".yaml"), ".yml")
+			if seen[name] {
+				continue
+			}
+			seen[name] = true
+			if _, skip := SkipPolicies[name]; skip {
+				skipped = append(skipped, name)
+				continue
+			}
+			policies = append(policies, filepath.Join(dir, entry.Name()))
+		}
+	}
+	return policies, skipped, nil
+}
+
+// Scan applies every cluster policy to the rendered manifests with kyverno
+// apply and reports the failing resources. Manifests maps a rendered
+// directory to its manifest; each violation lists the directories rendering
+// the resource
+func (r *TestRunner) Scan(manifests map[string]string) (ScanReport, error) {
+	var report ScanReport
+	policies, skipped, err := r.ScanPolicies()
+	if err != nil {
+		return report, err
+	}
+	report.Skipped = skipped
+	for _, policy := range policies {
+		rel, err := filepath.Rel(r.RepoPath, policy)
+		if err != nil {
+			rel = policy
+		}
+		report.Policies = append(report.Policies, filepath.ToSlash(rel))
+	}
+	if len(policies) == 0 {
+		return report, nil
+	}
+
+	resources, dirs, count, err := collectResources(manifests)
+	if err != nil {
+		return report, err
+	}
+	report.Resources = count
+	if count == 0 {
+		return report, nil
+	}
+
+	tempDir, err := os.MkdirTemp("", "shadow-kyverno-scan-*")
+	if err != nil {
+		return report, fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	defer os.RemoveAll(tempDir)
+	resourceFile := filepath.Join(tempDir, "resources.yaml")
+	if err := os.WriteFile(resourceFile, []byte(resources), 0644); err != nil {
+		return report, err
+	}
+
+	args := append(append([]string{"apply"}, policies...), "--resource", resourceFile, "--policy-report")
+	r.Log.Debugf("kyverno %s", strings.Join(args, " "))
+	output, err := command.Command("kyverno", args...).CombinedOutput()
+	violations, found := ParsePolicyReport(string(output))
+	// kyverno apply exits non-zero when a resource fails; only a missing report is an error
+	if !found {
+		if err == nil {
+			err = errors.New("no policy report in output")
+		}
+		return report, fmt.Errorf("kyverno apply failed: %w\n%s", err, strings.TrimSpace(string(output)))
+	}
+
+	// kyverno reports a resource rendered by several overlays once per copy
+	report.Violations = subtractViolations(violations, nil)
+	for i := range report.Violations {
+		v := &report.Violations[i]
+		v.Dirs = dirs[resourceKey(v.Kind, v.Namespace, v.Name)]
+	}
+	return report, nil
+}
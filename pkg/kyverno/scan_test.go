@@ -0,0 +1,191 @@
+package kyverno
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// fakeKyvernoScan fails Deployment apps/web for every policy file mentioning
+// "strict", once per copy of the resource like kyverno apply --policy-report
+const fakeKyvernoScan = `#!/bin/sh
+shift
+echo "Applying policies..."
+echo "apiVersion: wgpolicyk8s.io/v1alpha2"
+echo "kind: ClusterPolicyReport"
+echo "results:"
+status=0
+while [ "$1" != "--resource" ]; do
+  if grep -q strict "$1"; then
+    for copy in 1 2; do
+      printf -- '- policy: require-labels\n  rule: check-team\n  result: fail\n  message: |\n    label team\n    is required\n  resources:\n  - kind: Deployment\n    name: web\n    namespace: apps\n'
+    done
+    status=1
+  fi
+  shift
+done
+exit $status
+`
+
+func scanRepo(t *testing.T) string {
+	t.Helper()
+	bin := t.TempDir()
+	if err := os.WriteFile(filepath.Join(bin, "kyverno"), []byte(fakeKyvernoScan), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	repo := t.TempDir()
+	for rel, content := range map[string]string{
+		"policies/kyverno/base/cluster/kustomization.yaml":                   "resources: [require-labels.yaml]\n",
+		"policies/kyverno/base/cluster/require-labels.yaml":                  "kind: ClusterPolicy\n# strict\n",
+		"policies/kyverno/base/cluster/httproute-hostname-uniqueness.yaml":   "kind: ClusterPolicy\n# strict\n",
+		"policies/kyverno/overlays/erauner-home/cluster/require-labels.yaml": "kind: ClusterPolicy\n# strict\n",
+		"policies/kyverno/overlays/erauner-home/cluster/lenient.yaml":        "kind: ClusterPolicy\n",
+	} {
+		path := filepath.Join(repo, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return repo
+}
+
+const scanDeployment = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  namespace: apps
+`
+
+func TestScanPolicies(t *testing.T) {
+	repo := scanRepo(t)
+	runner := NewTestRunner(repo, false)
+
+	policies, skipped, err := runner.ScanPolicies()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rel []string
+	for _, p := range policies {
+		r, _ := filepath.Rel(repo, p)
+		rel = append(rel, filepath.ToSlash(r))
+	}
+	want := []string{
+		"policies/kyverno/base/cluster/require-labels.yaml",
+		"policies/kyverno/overlays/erauner-home/cluster/lenient.yaml",
+	}
+	if !reflect.DeepEqual(rel, want) {
+		t.Errorf("policies = %v, want %v", rel, want)
+	}
+	if !reflect.DeepEqual(skipped, []string{"httproute-hostname-uniqueness"}) {
+		t.Errorf("skipped = %v", skipped)
+	}
+}
+
+func TestScan(t *testing.T) {
+	runner := NewTestRunner(scanRepo(t), false)
+	report, err := runner.Scan(map[string]string{
+		"apps/web/overlays/erauner-home":  scanDeployment,
+		"apps/web/overlays/erauner-cloud": scanDeployment,
+		"apps/db/overlays/erauner-home":   "apiVersion: v1\nkind: Service\nmetadata:\n  name: db\n  namespace: apps\n",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if report.Resources != 3 || len(report.Policies) != 2 {
+		t.Errorf("resources = %d, policies = %v", report.Resources, report.Policies)
+	}
+	if len(report.Violations) != 1 {
+		t.Fatalf("violations = %+v, want one (deduplicated)", report.Violations)
+	}
+	v := report.Violations[0]
+	if v.Resource() != "Deployment/apps/web" || v.Policy != "require-labels" || v.Rule != "check-team" {
+		t.Errorf("violation = %+v", v)
+	}
+
+	overlays := report.Overlays()
+	var dirs []string
+	for _, o := range overlays {
+		dirs = append(dirs, o.Dir)
+	}
+	if want := []string{"apps/web/overlays/erauner-cloud", "apps/web/overlays/erauner-home"}; !reflect.DeepEqual(dirs, want) {
+		t.Errorf("overlays = %v, want %v", dirs, want)
+	}
+}
+
+func TestScan_NoReport(t *testing.T) {
+	repo := scanRepo(t)
+	bin := t.TempDir()
+	if err := os.WriteFile(filepath.Join(bin, "kyverno"), []byte("#!/bin/sh\necho 'Error: invalid policy' >&2\nexit 1\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	_, err := NewTestRunner(repo, false).Scan(map[string]string{"apps/web": scanDeployment})
+	if err == nil || !strings.Contains(err.Error(), "invalid policy") {
+		t.Errorf("err = %v, want kyverno output", err)
+	}
+}
+
+func TestScanReportSARIF(t *testing.T) {
+	report := ScanReport{Violations: []Violation{
+		{Policy: "require-labels", Rule: "check-team", Kind: "Deployment", Namespace: "apps", Name: "web",
+			Message: "label team is required", Dirs: []string{"apps/web/overlays/a", "apps/web/overlays/b"}},
+	}}
+	data, err := report.SARIF("1.2.3")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var log struct {
+		Version string `json:"version"`
+		Runs    []struct {
+			Tool struct {
+				Driver struct {
+					Version string `json:"version"`
+					Rules   []struct {
+						ID string `json:"id"`
+					} `json:"rules"`
+				} `json:"driver"`
+			} `json:"tool"`
+			Results []struct {
+				RuleID    string                `json:"ruleId"`
+				Message   struct{ Text string } `json:"message"`
+				Locations []struct {
+					PhysicalLocation struct {
+						ArtifactLocation struct {
+							URI string `json:"uri"`
+						} `json:"artifactLocation"`
+					} `json:"physicalLocation"`
+				} `json:"locations"`
+			} `json:"results"`
+		} `json:"runs"`
+	}
+	if err := json.Unmarshal(data, &log); err != nil {
+		t.Fatal(err)
+	}
+	if log.Version != "2.1.0" || len(log.Runs) != 1 {
+		t.Fatalf("log = %s", data)
+	}
+	run := log.Runs[0]
+	if run.Tool.Driver.Version != "1.2.3" || len(run.Tool.Driver.Rules) != 1 || run.Tool.Driver.Rules[0].ID != "require-labels/check-team" {
+		t.Errorf("driver = %+v", run.Tool.Driver)
+	}
+	if len(run.Results) != 2 {
+		t.Fatalf("results = %+v, want one per rendered directory", run.Results)
+	}
+	if uri := run.Results[1].Locations[0].PhysicalLocation.ArtifactLocation.URI; uri != "apps/web/overlays/b" {
+		t.Errorf("uri = %q", uri)
+	}
+	if msg := run.Results[0].Message.Text; msg != "Deployment/apps/web: label team is required" {
+		t.Errorf("message = %q", msg)
+	}
+}
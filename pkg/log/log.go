@@ -0,0 +1,208 @@
+// Package log provides the leveled logger shared by the shadow CLI and its packages
+//
+// Text output keeps the familiar "[component] message" lines on stderr; JSON
+// output writes one object per line for CI log ingestion. A nil *Logger
+// discards everything, so components can hold an optional logger.
+package log
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Level is a log severity
+type Level int
+
+// Log levels, least to most severe
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+// String returns the level name used in flags and JSON output
+func (l Level) String() string {
+	switch l {
+	case LevelDebug:
+		return "debug"
+	case LevelInfo:
+		return "info"
+	case LevelWarn:
+		return "warn"
+	case LevelError:
+		return "error"
+	default:
+		return fmt.Sprintf("level(%d)", int(l))
+	}
+}
+
+// ParseLevel parses debug, info, warn, or error
+func ParseLevel(s string) (Level, error) {
+	switch strings.ToLower(s) {
+	case "debug":
+		return LevelDebug, nil
+	case "info", "":
+		return LevelInfo, nil
+	case "warn", "warning":
+		return LevelWarn, nil
+	case "error":
+		return LevelError, nil
+	default:
+		return LevelInfo, fmt.Errorf("unknown log level %q (expected debug, info, warn, or error)", s)
+	}
+}
+
+// Format is a log output format
+type Format string
+
+// Log formats
+const (
+	FormatText Format = "text"
+	FormatJSON Format = "json"
+)
+
+// ParseFormat parses text or json
+func ParseFormat(s string) (Format, error) {
+	switch Format(strings.ToLower(s)) {
+	case FormatText, "":
+		return FormatText, nil
+	case FormatJSON:
+		return FormatJSON, nil
+	default:
+		return FormatText, fmt.Errorf("unknown log format %q (expected text or json)", s)
+	}
+}
+
+// Logger writes leveled messages for a named component
+// Loggers derived with Named or Verbose share the writer and its lock
+type Logger struct {
+	out       *output
+	level     Level
+	format    Format
+	component string
+}
+
+type output struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// New creates a logger writing messages at level and above to w
+func New(w io.Writer, level Level, format Format) *Logger {
+	return &Logger{out: &output{w: w}, level: level, format: format}
+}
+
+var (
+	defaultMu     sync.RWMutex
+	defaultLogger = New(os.Stderr, LevelInfo, FormatText)
+)
+
+// Default returns the process-wide logger configured by the CLI
+func Default() *Logger {
+	defaultMu.RLock()
+	defer defaultMu.RUnlock()
+	return defaultLogger
+}
+
+// SetDefault replaces the process-wide logger
+func SetDefault(l *Logger) {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	defaultLogger = l
+}
+
+// Named returns a logger that tags messages with component
+func (l *Logger) Named(component string) *Logger {
+	if l == nil {
+		return nil
+	}
+	c := *l
+	c.component = component
+	return &c
+}
+
+// Verbose returns a logger that also writes debug messages when verbose is set
+// It keeps per-component --verbose style switches working on top of the shared level
+func (l *Logger) Verbose(verbose bool) *Logger {
+	if l == nil || !verbose || l.level <= LevelDebug {
+		return l
+	}
+	c := *l
+	c.level = LevelDebug
+	return &c
+}
+
+// Enabled reports whether messages at level would be written
+func (l *Logger) Enabled(level Level) bool {
+	return l != nil && level >= l.level
+}
+
+// Debugf logs a debug message (shown with --verbose or --log-level debug)
+func (l *Logger) Debugf(format string, args ...interface{}) {
+	l.logf(LevelDebug, format, args...)
+}
+
+// Infof logs an informational message
+func (l *Logger) Infof(format string, args ...interface{}) {
+	l.logf(LevelInfo, format, args...)
+}
+
+// Warnf logs a warning
+func (l *Logger) Warnf(format string, args ...interface{}) {
+	l.logf(LevelWarn, format, args...)
+}
+
+// Errorf logs an error that does not stop the current operation
+func (l *Logger) Errorf(format string, args ...interface{}) {
+	l.logf(LevelError, format, args...)
+}
+
+// jsonEntry is a single JSON log line
+type jsonEntry struct {
+	Time      string `json:"time"`
+	Level     string `json:"level"`
+	Component string `json:"component,omitempty"`
+	Message   string `json:"msg"`
+}
+
+func (l *Logger) logf(level Level, format string, args ...interface{}) {
+	if !l.Enabled(level) {
+		return
+	}
+	msg := fmt.Sprintf(format, args...)
+
+	var line string
+	if l.format == FormatJSON {
+		data, err := json.Marshal(jsonEntry{
+			Time:      time.Now().UTC().Format(time.RFC3339),
+			Level:     level.String(),
+			Component: l.component,
+			Message:   msg,
+		})
+		if err != nil {
+			return
+		}
+		line = string(data) + "\n"
+	} else {
+		switch level {
+		case LevelWarn:
+			msg = "warning: " + msg
+		case LevelError:
+			msg = "error: " + msg
+		}
+		if l.component != "" {
+			msg = "[" + l.component + "] " + msg
+		}
+		line = msg + "\n"
+	}
+
+	l.out.mu.Lock()
+	defer l.out.mu.Unlock()
+	io.WriteString(l.out.w, line)
+}
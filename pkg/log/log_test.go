@@ -0,0 +1,79 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestLogger_TextLevels(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(&buf, LevelInfo, FormatText).Named("sync")
+
+	logger.Debugf("building %s", "apps/web")
+	logger.Infof("rendered %d", 3)
+	logger.Warnf("cleanup failed")
+	logger.Errorf("unredacted secret")
+
+	want := "[sync] rendered 3\n[sync] warning: cleanup failed\n[sync] error: unredacted secret\n"
+	if buf.String() != want {
+		t.Errorf("output = %q, want %q", buf.String(), want)
+	}
+}
+
+func TestLogger_Verbose(t *testing.T) {
+	var buf bytes.Buffer
+	base := New(&buf, LevelInfo, FormatText)
+
+	base.Named("helm").Verbose(false).Debugf("hidden")
+	base.Named("helm").Verbose(true).Debugf("cache hit")
+	if buf.String() != "[helm] cache hit\n" {
+		t.Errorf("output = %q", buf.String())
+	}
+
+	// Verbose raises any level to debug
+	quiet := New(&buf, LevelError, FormatText)
+	if !quiet.Verbose(true).Enabled(LevelDebug) {
+		t.Error("Verbose(true) should enable debug messages")
+	}
+}
+
+func TestLogger_JSON(t *testing.T) {
+	var buf bytes.Buffer
+	New(&buf, LevelDebug, FormatJSON).Named("kustomize").Debugf("kustomize build %s", "apps/web")
+
+	var entry map[string]string
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("invalid JSON line %q: %v", buf.String(), err)
+	}
+	if entry["level"] != "debug" || entry["component"] != "kustomize" || entry["msg"] != "kustomize build apps/web" || entry["time"] == "" {
+		t.Errorf("unexpected entry %v", entry)
+	}
+	if !strings.HasSuffix(buf.String(), "\n") {
+		t.Error("expected newline-terminated JSON line")
+	}
+}
+
+func TestLogger_Nil(t *testing.T) {
+	var logger *Logger
+	logger.Named("sync").Verbose(true).Infof("discarded")
+	if logger.Enabled(LevelError) {
+		t.Error("nil logger should not be enabled")
+	}
+}
+
+func TestParse(t *testing.T) {
+	if level, err := ParseLevel("WARN"); err != nil || level != LevelWarn {
+		t.Errorf("ParseLevel(WARN) = %v, %v", level, err)
+	}
+	if _, err := ParseLevel("trace"); err == nil {
+		t.Error("expected error for unknown level")
+	}
+	if format, err := ParseFormat("json"); err != nil || format != FormatJSON {
+		t.Errorf("ParseFormat(json) = %v, %v", format, err)
+	}
+	if _, err := ParseFormat("logfmt"); err == nil {
+		t.Error("expected error for unknown format")
+	}
+}
@@ -0,0 +1,170 @@
+// Package metrics writes run metrics in the OpenMetrics text format, to a file
+// CI keeps as an artifact or to a Prometheus Pushgateway
+//
+// Every metric is a gauge: a CI run reports its own values once, so there is
+// nothing to accumulate, and gauges read the same in both exposition formats
+// a Pushgateway accepts.
+package metrics
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Set is an ordered collection of gauges
+type Set struct {
+	// Labels are added to every sample (e.g. the PR number)
+	Labels map[string]string
+
+	families []*family
+}
+
+type family struct {
+	name, help string
+	samples    []sample
+}
+
+type sample struct {
+	labels []string // key, value pairs
+	value  float64
+}
+
+// Gauge records value for the metric name, with labels given as key, value
+// pairs; help is kept from the first sample of a name
+func (s *Set) Gauge(name, help string, value float64, labels ...string) {
+	if len(labels)%2 != 0 {
+		panic(fmt.Sprintf("metrics: odd number of label arguments for %s", name))
+	}
+	var f *family
+	for _, existing := range s.families {
+		if existing.name == name {
+			f = existing
+		}
+	}
+	if f == nil {
+		f = &family{name: name, help: help}
+		s.families = append(s.families, f)
+	}
+	f.samples = append(f.samples, sample{labels: labels, value: value})
+}
+
+// Bool records b as 1 or 0
+func (s *Set) Bool(name, help string, b bool, labels ...string) {
+	value := 0.0
+	if b {
+		value = 1
+	}
+	s.Gauge(name, help, value, labels...)
+}
+
+// WriteTo writes the set in the OpenMetrics text format, ending with # EOF
+func (s *Set) WriteTo(w io.Writer) (int64, error) {
+	var b strings.Builder
+	for _, f := range s.families {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s gauge\n", f.name, escapeHelp(f.help), f.name)
+		for _, smp := range f.samples {
+			b.WriteString(f.name)
+			b.WriteString(s.labelString(smp.labels))
+			b.WriteString(" ")
+			b.WriteString(strconv.FormatFloat(smp.value, 'g', -1, 64))
+			b.WriteString("\n")
+		}
+	}
+	b.WriteString("# EOF\n")
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+// WriteFile writes the set to path
+func (s *Set) WriteFile(path string) error {
+	var buf bytes.Buffer
+	if _, err := s.WriteTo(&buf); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to write metrics: %w", err)
+	}
+	return nil
+}
+
+// labelString renders the set's labels and then the sample's, sorted by key
+// within each group
+func (s *Set) labelString(labels []string) string {
+	keys := make([]string, 0, len(s.Labels))
+	for k := range s.Labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var pairs []string
+	for _, k := range keys {
+		pairs = append(pairs, fmt.Sprintf("%s=%q", k, s.Labels[k]))
+	}
+	for i := 0; i+1 < len(labels); i += 2 {
+		pairs = append(pairs, fmt.Sprintf("%s=%q", labels[i], labels[i+1]))
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// escapeHelp escapes a HELP string
+func escapeHelp(help string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(help)
+}
+
+// PushTimeout bounds a push to the Pushgateway
+var PushTimeout = 30 * time.Second
+
+// Push replaces the metrics of the group job (plus grouping labels, given as
+// key, value pairs) on the Pushgateway at url
+func Push(url, job string, s *Set, grouping ...string) error {
+	if len(grouping)%2 != 0 {
+		return fmt.Errorf("odd number of grouping label arguments")
+	}
+	endpoint := strings.TrimSuffix(url, "/") + "/metrics/" + pathSegment("job", job)
+	for i := 0; i+1 < len(grouping); i += 2 {
+		endpoint += "/" + pathSegment(grouping[i], grouping[i+1])
+	}
+
+	var body bytes.Buffer
+	if _, err := s.WriteTo(&body); err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPut, endpoint, &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	client := &http.Client{Timeout: PushTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to push metrics: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("failed to push metrics: Pushgateway returned %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// pathSegment encodes a grouping label as a Pushgateway URL path segment,
+// base64-encoding values a path can't hold as-is (slashes, empty values)
+func pathSegment(key, value string) string {
+	if value == "" {
+		return key + "@base64/="
+	}
+	if strings.ContainsAny(value, "/%?#") {
+		return key + "@base64/" + base64.RawURLEncoding.EncodeToString([]byte(value))
+	}
+	return key + "/" + value
+}
@@ -0,0 +1,77 @@
+package metrics
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func testSet() *Set {
+	s := &Set{Labels: map[string]string{"pr": "950"}}
+	s.Gauge("shadow_sync_dirs", "Directories by result.", 12, "result", "rendered")
+	s.Gauge("shadow_sync_dirs", "ignored", 1, "result", "failed")
+	s.Gauge("shadow_sync_duration_seconds", "Duration of the run.", 41.5)
+	s.Bool("shadow_sync_success", "1 if the run completed.", true)
+	return s
+}
+
+func TestSet_WriteTo(t *testing.T) {
+	var b strings.Builder
+	if _, err := testSet().WriteTo(&b); err != nil {
+		t.Fatal(err)
+	}
+	want := `# HELP shadow_sync_dirs Directories by result.
+# TYPE shadow_sync_dirs gauge
+shadow_sync_dirs{pr="950",result="rendered"} 12
+shadow_sync_dirs{pr="950",result="failed"} 1
+# HELP shadow_sync_duration_seconds Duration of the run.
+# TYPE shadow_sync_duration_seconds gauge
+shadow_sync_duration_seconds{pr="950"} 41.5
+# HELP shadow_sync_success 1 if the run completed.
+# TYPE shadow_sync_success gauge
+shadow_sync_success{pr="950"} 1
+# EOF
+`
+	if b.String() != want {
+		t.Errorf("WriteTo() =\n%s\nwant\n%s", b.String(), want)
+	}
+
+	file := filepath.Join(t.TempDir(), "metrics.prom")
+	if err := testSet().WriteFile(file); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := os.ReadFile(file); err != nil || string(data) != want {
+		t.Errorf("WriteFile() wrote %q, %v", data, err)
+	}
+}
+
+func TestPush(t *testing.T) {
+	var method, path, body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method, path = r.Method, r.URL.EscapedPath()
+		data, _ := io.ReadAll(r.Body)
+		body = string(data)
+		if strings.Contains(path, "fail") {
+			http.Error(w, "bad metrics", http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+
+	if err := Push(server.URL+"/", "shadow_sync", testSet(), "repo", "erauner/homelab-k8s", "pr", "950"); err != nil {
+		t.Fatalf("Push() error = %v", err)
+	}
+	if method != http.MethodPut || path != "/metrics/job/shadow_sync/repo@base64/ZXJhdW5lci9ob21lbGFiLWs4cw/pr/950" {
+		t.Errorf("pushed %s %s", method, path)
+	}
+	if !strings.Contains(body, `shadow_sync_dirs{pr="950",result="rendered"} 12`) {
+		t.Errorf("unexpected body:\n%s", body)
+	}
+
+	if err := Push(server.URL, "fail", testSet()); err == nil || !strings.Contains(err.Error(), "returned 400: bad metrics") {
+		t.Errorf("Push() error = %v, want the Pushgateway's response", err)
+	}
+}
@@ -0,0 +1,260 @@
+// Package opa evaluates Rego policies (conftest and Gatekeeper-style) against
+// rendered manifests with the conftest CLI
+package opa
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/erauner/homelab-shadow/pkg/command"
+	"github.com/erauner/homelab-shadow/pkg/log"
+	"github.com/erauner/homelab-shadow/pkg/validate"
+	"gopkg.in/yaml.v3"
+)
+
+// Rego policy rules: deny/violation results are errors, warn results warnings
+const (
+	RuleDeny = "opa-deny"
+	RuleWarn = "opa-warn"
+)
+
+// DefaultRoots are the repo-relative Rego policy directories used when none are configured
+var DefaultRoots = []string{"policies/opa"}
+
+// Runner evaluates Rego policies with conftest
+type Runner struct {
+	RepoPath string
+	Log      *log.Logger // commands are logged at debug level
+
+	// Roots are repo-relative directories searched for .rego files
+	// (default: DefaultRoots)
+	Roots []string
+}
+
+// NewRunner creates a conftest runner for a repository
+func NewRunner(repoPath string, verbose bool) *Runner {
+	return &Runner{
+		RepoPath: repoPath,
+		Log:      log.Default().Named("opa").Verbose(verbose),
+	}
+}
+
+// IsConftestInstalled checks if the conftest CLI is installed
+func IsConftestInstalled() bool {
+	_, err := exec.LookPath("conftest")
+	return err == nil
+}
+
+// Policies returns the repo-relative .rego files under the roots, excluding
+// Rego unit tests (*_test.rego); missing roots are skipped
+func (r *Runner) Policies() ([]string, error) {
+	var policies []string
+	for _, root := range r.roots() {
+		dir := filepath.Join(r.RepoPath, filepath.FromSlash(root))
+		if _, err := os.Stat(dir); os.IsNotExist(err) {
+			continue
+		}
+		err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() || filepath.Ext(path) != ".rego" || strings.HasSuffix(path, "_test.rego") {
+				return nil
+			}
+			rel, err := filepath.Rel(r.RepoPath, path)
+			if err != nil {
+				return err
+			}
+			policies = append(policies, filepath.ToSlash(rel))
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to discover Rego policies in %s: %w", root, err)
+		}
+	}
+	sort.Strings(policies)
+	return policies, nil
+}
+
+func (r *Runner) roots() []string {
+	if len(r.Roots) == 0 {
+		return DefaultRoots
+	}
+	return r.Roots
+}
+
+// policyDirs returns the roots holding at least one policy
+func (r *Runner) policyDirs(policies []string) []string {
+	var dirs []string
+	for _, root := range r.roots() {
+		prefix := strings.TrimSuffix(filepath.ToSlash(filepath.Clean(root)), "/") + "/"
+		for _, policy := range policies {
+			if strings.HasPrefix(policy, prefix) {
+				dirs = append(dirs, filepath.Join(r.RepoPath, filepath.FromSlash(root)))
+				break
+			}
+		}
+	}
+	return dirs
+}
+
+// resourceFile is one rendered resource written for conftest
+type resourceFile struct {
+	dir      string // rendered directory
+	resource string // Kind/namespace/name
+}
+
+// checkResult is one entry of conftest test --output json
+type checkResult struct {
+	Filename  string          `json:"filename"`
+	Namespace string          `json:"namespace"`
+	Failures  []conftestEntry `json:"failures"`
+	Warnings  []conftestEntry `json:"warnings"`
+}
+
+type conftestEntry struct {
+	Message string `json:"msg"`
+}
+
+// Test evaluates every policy against the rendered manifests, which map a
+// rendered directory to its manifest. Each resource is checked on its own, so
+// findings name it; Path is the rendered directory and Cluster is left empty
+func (r *Runner) Test(manifests map[string]string) ([]validate.Result, error) {
+	policies, err := r.Policies()
+	if err != nil {
+		return nil, err
+	}
+	if len(policies) == 0 {
+		return nil, fmt.Errorf("no Rego policies found under %s", strings.Join(r.roots(), ", "))
+	}
+
+	tempDir, err := os.MkdirTemp("", "shadow-conftest-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	defer os.RemoveAll(tempDir)
+	files, err := writeResources(tempDir, manifests)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return []validate.Result{}, nil
+	}
+
+	args := []string{"test", "--output", "json", "--all-namespaces", "--no-color"}
+	for _, dir := range r.policyDirs(policies) {
+		args = append(args, "--policy", dir)
+	}
+	args = append(args, tempDir)
+	r.Log.Debugf("conftest %s", strings.Join(args, " "))
+	cmd := command.Command("conftest", args...)
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+
+	var checks []checkResult
+	if jsonErr := json.Unmarshal(output, &checks); jsonErr != nil {
+		// conftest exits non-zero when a policy fails; only unparseable output is an error
+		if err == nil {
+			err = jsonErr
+		}
+		return nil, fmt.Errorf("conftest failed: %w\n%s", err, strings.TrimSpace(stderr.String()+string(output)))
+	}
+
+	results := []validate.Result{}
+	for _, check := range checks {
+		rel, err := filepath.Rel(tempDir, check.Filename)
+		if err != nil {
+			continue
+		}
+		file, ok := files[filepath.ToSlash(rel)]
+		if !ok {
+			continue
+		}
+		add := func(rule, severity string, entries []conftestEntry) {
+			for _, e := range entries {
+				message := strings.TrimSpace(e.Message)
+				if check.Namespace != "" && check.Namespace != "main" {
+					message = "[" + check.Namespace + "] " + message
+				}
+				results = append(results, validate.Result{
+					Rule:     rule,
+					Path:     file.dir,
+					Message:  file.resource + ": " + message,
+					Severity: severity,
+				})
+			}
+		}
+		add(RuleDeny, "error", check.Failures)
+		add(RuleWarn, "warn", check.Warnings)
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Path != results[j].Path {
+			return results[i].Path < results[j].Path
+		}
+		return results[i].Message < results[j].Message
+	})
+	return results, nil
+}
+
+// writeResources writes every resource of the manifests to its own file
+// under root, keyed by the slash-separated path relative to root
+func writeResources(root string, manifests map[string]string) (map[string]resourceFile, error) {
+	dirs := make([]string, 0, len(manifests))
+	for dir := range manifests {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+
+	files := make(map[string]resourceFile)
+	for i, dir := range dirs {
+		decoder := yaml.NewDecoder(strings.NewReader(manifests[dir]))
+		for n := 0; ; n++ {
+			var doc map[string]interface{}
+			err := decoder.Decode(&doc)
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse manifest for %s: %w", dir, err)
+			}
+			if doc == nil {
+				continue
+			}
+			kind, _ := doc["kind"].(string)
+			metadata, _ := doc["metadata"].(map[string]interface{})
+			name, _ := metadata["name"].(string)
+			namespace, _ := metadata["namespace"].(string)
+			if kind == "" || name == "" {
+				continue
+			}
+
+			data, err := yaml.Marshal(doc)
+			if err != nil {
+				return nil, err
+			}
+			rel := fmt.Sprintf("%d/%d.yaml", i, n)
+			path := filepath.Join(root, filepath.FromSlash(rel))
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				return nil, err
+			}
+			if err := os.WriteFile(path, data, 0644); err != nil {
+				return nil, err
+			}
+			resource := kind + "/" + name
+			if namespace != "" {
+				resource = kind + "/" + namespace + "/" + name
+			}
+			files[rel] = resourceFile{dir: dir, resource: resource}
+		}
+	}
+	return files, nil
+}
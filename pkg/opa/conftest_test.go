@@ -0,0 +1,139 @@
+package opa
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/erauner/homelab-shadow/pkg/validate"
+)
+
+// fakeConftest denies every Deployment in package main and warns about every
+// ConfigMap in package k8s.labels, like conftest test --output json
+const fakeConftest = `#!/bin/sh
+for last in "$@"; do :; done
+echo "$@" > "$(dirname "$0")/args"
+sep=""
+printf '['
+for f in $(find "$last" -name '*.yaml' | sort); do
+  if grep -q '^kind: Deployment' "$f"; then
+    printf '%s{"filename":"%s","namespace":"main","successes":0,"failures":[{"msg":"containers must not run as root"}]}' "$sep" "$f"
+  elif grep -q '^kind: ConfigMap' "$f"; then
+    printf '%s{"filename":"%s","namespace":"k8s.labels","successes":0,"warnings":[{"msg":"missing label team"}]}' "$sep" "$f"
+  else
+    printf '%s{"filename":"%s","namespace":"main","successes":1}' "$sep" "$f"
+  fi
+  sep=","
+done
+printf ']\n'
+exit 1
+`
+
+func fakeConftestRepo(t *testing.T, policies ...string) (*Runner, string) {
+	t.Helper()
+	bin := t.TempDir()
+	if err := os.WriteFile(filepath.Join(bin, "conftest"), []byte(fakeConftest), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	repo := t.TempDir()
+	for _, rel := range policies {
+		path := filepath.Join(repo, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("package main\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return NewRunner(repo, false), bin
+}
+
+func TestPolicies(t *testing.T) {
+	runner, _ := fakeConftestRepo(t,
+		"policies/opa/security/root.rego",
+		"policies/opa/security/root_test.rego",
+		"policies/opa/labels.rego",
+		"policies/opa/README.md",
+		"gatekeeper/templates/limits.rego",
+	)
+
+	policies, err := runner.Policies()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"policies/opa/labels.rego", "policies/opa/security/root.rego"}
+	if !reflect.DeepEqual(policies, want) {
+		t.Errorf("policies = %v, want %v", policies, want)
+	}
+
+	runner.Roots = []string{"gatekeeper", "missing"}
+	policies, err = runner.Policies()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"gatekeeper/templates/limits.rego"}; !reflect.DeepEqual(policies, want) {
+		t.Errorf("policies with custom roots = %v, want %v", policies, want)
+	}
+}
+
+func TestTest(t *testing.T) {
+	runner, bin := fakeConftestRepo(t, "policies/opa/root.rego")
+	results, err := runner.Test(map[string]string{
+		"apps/web/overlays/erauner-home": `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  namespace: apps
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+  namespace: apps
+`,
+		"infrastructure/dns/overlays/erauner-home": "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: coredns\n  namespace: kube-system\n",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []validate.Result{
+		{Rule: RuleDeny, Path: "apps/web/overlays/erauner-home", Message: "Deployment/apps/web: containers must not run as root", Severity: "error"},
+		{Rule: RuleWarn, Path: "infrastructure/dns/overlays/erauner-home", Message: "ConfigMap/kube-system/coredns: [k8s.labels] missing label team", Severity: "warn"},
+	}
+	if !reflect.DeepEqual(results, want) {
+		t.Errorf("results = %+v, want %+v", results, want)
+	}
+
+	args, err := os.ReadFile(filepath.Join(bin, "args"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(args), "--all-namespaces") || !strings.Contains(string(args), "--policy "+filepath.Join(runner.RepoPath, "policies", "opa")) {
+		t.Errorf("conftest args = %s", args)
+	}
+}
+
+func TestTest_NoPolicies(t *testing.T) {
+	runner, _ := fakeConftestRepo(t)
+	if _, err := runner.Test(map[string]string{"apps/web": "kind: Service\nmetadata:\n  name: web\n"}); err == nil || !strings.Contains(err.Error(), "no Rego policies") {
+		t.Errorf("err = %v, want no policies error", err)
+	}
+}
+
+func TestTest_ConftestError(t *testing.T) {
+	runner, bin := fakeConftestRepo(t, "policies/opa/root.rego")
+	broken := "#!/bin/sh\necho 'Error: load: 1 error occurred: root.rego:1: rego_parse_error' >&2\nexit 1\n"
+	if err := os.WriteFile(filepath.Join(bin, "conftest"), []byte(broken), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := runner.Test(map[string]string{"apps/web": "kind: Service\nmetadata:\n  name: web\n"})
+	if err == nil || !strings.Contains(err.Error(), "rego_parse_error") {
+		t.Errorf("err = %v, want conftest's stderr", err)
+	}
+}
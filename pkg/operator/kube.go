@@ -0,0 +1,129 @@
+package operator
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// serviceAccountDir holds the in-cluster token, CA, and namespace
+const serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+// fieldManager identifies the operator in server-side apply
+const fieldManager = "shadow-operator"
+
+// ConfigMapPublisher publishes the status into a ConfigMap with server-side
+// apply: status.json holds the full status, and healthy and revision are
+// exposed as their own keys for kubectl and alerting
+//
+// The service account needs get, create, and patch on configmaps in Namespace.
+type ConfigMapPublisher struct {
+	Server    string // API server URL, e.g. https://10.0.0.1:443
+	Token     string
+	Namespace string
+	Name      string
+	Client    *http.Client
+}
+
+// InClusterConfigMapPublisher configures a ConfigMapPublisher from the pod's
+// service account; namespace defaults to the pod's namespace
+func InClusterConfigMapPublisher(namespace, name string) (*ConfigMapPublisher, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, fmt.Errorf("not running in a cluster (KUBERNETES_SERVICE_HOST is not set)")
+	}
+	token, err := os.ReadFile(serviceAccountDir + "/token")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read service account token: %w", err)
+	}
+	ca, err := os.ReadFile(serviceAccountDir + "/ca.crt")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cluster CA: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, fmt.Errorf("no certificates in %s/ca.crt", serviceAccountDir)
+	}
+	if namespace == "" {
+		ns, err := os.ReadFile(serviceAccountDir + "/namespace")
+		if err != nil {
+			return nil, fmt.Errorf("failed to read pod namespace (set a namespace explicitly): %w", err)
+		}
+		namespace = strings.TrimSpace(string(ns))
+	}
+
+	return &ConfigMapPublisher{
+		Server:    "https://" + net.JoinHostPort(host, port),
+		Token:     strings.TrimSpace(string(token)),
+		Namespace: namespace,
+		Name:      name,
+		Client: &http.Client{
+			Timeout:   30 * time.Second,
+			Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}},
+		},
+	}, nil
+}
+
+// Publish applies the status to the ConfigMap, creating it if needed
+func (p *ConfigMapPublisher) Publish(ctx context.Context, status Status) error {
+	data, err := json.MarshalIndent(status, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal status: %w", err)
+	}
+	configMap := map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata": map[string]interface{}{
+			"name":      p.Name,
+			"namespace": p.Namespace,
+			"labels":    map[string]string{"app.kubernetes.io/managed-by": fieldManager},
+		},
+		"data": map[string]string{
+			"status.json": string(data),
+			"healthy":     strconv.FormatBool(status.Healthy),
+			"revision":    status.Revision,
+		},
+	}
+	body, err := json.Marshal(configMap)
+	if err != nil {
+		return fmt.Errorf("failed to marshal ConfigMap: %w", err)
+	}
+
+	endpoint := fmt.Sprintf("%s/api/v1/namespaces/%s/configmaps/%s?fieldManager=%s&force=true",
+		strings.TrimSuffix(p.Server, "/"), url.PathEscape(p.Namespace), url.PathEscape(p.Name), fieldManager)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPatch, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/apply-patch+yaml") // JSON is valid YAML
+	req.Header.Set("Accept", "application/json")
+	if p.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+p.Token)
+	}
+
+	client := p.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to apply ConfigMap %s/%s: %w", p.Namespace, p.Name, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("failed to apply ConfigMap %s/%s: %s: %s", p.Namespace, p.Name, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package operator
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestConfigMapPublisher(t *testing.T) {
+	var got struct {
+		Data map[string]string `json:"data"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPatch || r.URL.Path != "/api/v1/namespaces/shadow/configmaps/shadow-status" {
+			t.Errorf("request = %s %s", r.Method, r.URL.Path)
+		}
+		if r.URL.Query().Get("fieldManager") != fieldManager || r.URL.Query().Get("force") != "true" {
+			t.Errorf("query = %s, want server-side apply", r.URL.RawQuery)
+		}
+		if r.Header.Get("Content-Type") != "application/apply-patch+yaml" || r.Header.Get("Authorization") != "Bearer t0ken" {
+			t.Errorf("headers = %v", r.Header)
+		}
+		body, _ := io.ReadAll(r.Body)
+		if err := json.Unmarshal(body, &got); err != nil {
+			t.Errorf("body is not JSON: %v", err)
+		}
+		w.Write(body)
+	}))
+	defer server.Close()
+
+	publisher := &ConfigMapPublisher{Server: server.URL, Token: "t0ken", Namespace: "shadow", Name: "shadow-status"}
+	if err := publisher.Publish(context.Background(), Status{Revision: "abc123", Healthy: true, Errors: 0}); err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+	if got.Data["healthy"] != "true" || got.Data["revision"] != "abc123" || !strings.Contains(got.Data["status.json"], `"revision": "abc123"`) {
+		t.Errorf("data = %v", got.Data)
+	}
+}
+
+func TestConfigMapPublisher_Forbidden(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `configmaps "shadow-status" is forbidden`, http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	publisher := &ConfigMapPublisher{Server: server.URL, Namespace: "shadow", Name: "shadow-status"}
+	err := publisher.Publish(context.Background(), Status{})
+	if err == nil || !strings.Contains(err.Error(), "forbidden") {
+		t.Errorf("Publish() error = %v, want forbidden", err)
+	}
+}
+
+func TestInClusterConfigMapPublisher_OutsideCluster(t *testing.T) {
+	t.Setenv("KUBERNETES_SERVICE_HOST", "")
+	if _, err := InClusterConfigMapPublisher("", "shadow-status"); err == nil {
+		t.Error("expected error outside a cluster")
+	}
+}
@@ -0,0 +1,278 @@
+// Package operator runs shadow as a long-lived controller: it keeps a checkout
+// of the source repo's main branch, validates and renders every new commit,
+// and publishes the outcome as a ConfigMap and Prometheus metrics, giving a
+// continuously updated repo health signal between PRs
+package operator
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	stdsync "sync"
+	"time"
+
+	"github.com/erauner/homelab-shadow/pkg/command"
+	"github.com/erauner/homelab-shadow/pkg/config"
+	"github.com/erauner/homelab-shadow/pkg/log"
+	"github.com/erauner/homelab-shadow/pkg/shadow"
+	"github.com/erauner/homelab-shadow/pkg/sync"
+)
+
+// Options configures an Operator
+type Options struct {
+	// SourceRepo is the GitOps repo to watch (slug or git URL)
+	SourceRepo string
+	Provider   sync.Provider // default: detected from SourceRepo's host
+	Branch     string        // default: "main"
+
+	// WorkDir holds the source checkout between runs (default: a temp directory
+	// created by Run; Reconcile alone requires it)
+	WorkDir string
+
+	// Interval polls the branch for new commits (0 = only on webhook triggers)
+	Interval time.Duration
+
+	// Cluster limits validation and rendering to one cluster (default: all)
+	Cluster string
+
+	// HelmCacheDir caches downloaded charts between renders (empty = disabled)
+	HelmCacheDir string
+
+	// GitRetries and GitRetryDelay retry transient fetch failures (see sync.GitRetry)
+	GitRetries    int
+	GitRetryDelay time.Duration
+
+	// Publishers receive every status (e.g. a ConfigMapPublisher)
+	Publishers []Publisher
+
+	// Renderers replaces the registered sync renderers, e.g. with fakes in tests
+	Renderers []sync.Renderer
+
+	Verbose bool
+	Log     *log.Logger // default: the shared logger
+}
+
+// Publisher publishes a status somewhere outside the operator
+type Publisher interface {
+	Publish(ctx context.Context, status Status) error
+}
+
+// Operator validates and renders the source repo whenever its branch moves
+type Operator struct {
+	opts    Options
+	source  sync.Remote
+	trigger chan struct{}
+	log     *log.Logger
+
+	mu      stdsync.Mutex
+	status  Status
+	runs    map[string]int // completed runs by outcome ("success" or "error")
+	hasRun  bool
+	workDir string
+}
+
+// New creates an Operator
+func New(opts Options) (*Operator, error) {
+	if opts.SourceRepo == "" {
+		return nil, fmt.Errorf("SourceRepo is required")
+	}
+	if !sync.IsGitInstalled() {
+		return nil, fmt.Errorf("operator requires git, which is %w", command.ErrNotInstalled)
+	}
+	if opts.Branch == "" {
+		opts.Branch = "main"
+	}
+	source, err := sync.ParseRemote(opts.SourceRepo, opts.Provider)
+	if err != nil {
+		return nil, fmt.Errorf("invalid source repo: %w", err)
+	}
+
+	logger := opts.Log
+	if logger == nil {
+		logger = log.Default()
+	}
+	return &Operator{
+		opts:    opts,
+		source:  source,
+		trigger: make(chan struct{}, 1),
+		log:     logger.Named("operator").Verbose(opts.Verbose),
+		runs:    map[string]int{},
+		workDir: opts.WorkDir,
+	}, nil
+}
+
+// Trigger requests a run as soon as the current one (if any) finishes
+// Triggers received while one is already pending are coalesced
+func (o *Operator) Trigger() {
+	select {
+	case o.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// Status returns the most recent status
+func (o *Operator) Status() Status {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.status
+}
+
+// Run reconciles once immediately, then on every poll interval and trigger,
+// until ctx is done
+func (o *Operator) Run(ctx context.Context) error {
+	if o.workDir == "" {
+		dir, err := os.MkdirTemp("", "shadow-operator-*")
+		if err != nil {
+			return fmt.Errorf("failed to create work directory: %w", err)
+		}
+		defer os.RemoveAll(dir)
+		o.workDir = dir
+	}
+
+	var tick <-chan time.Time
+	if o.opts.Interval > 0 {
+		ticker := time.NewTicker(o.opts.Interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	o.Trigger()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-tick:
+		case <-o.trigger:
+		}
+		o.Reconcile(ctx)
+	}
+}
+
+// Reconcile fetches the branch and, when it moved (or the last run failed),
+// validates and renders it and publishes the new status
+func (o *Operator) Reconcile(ctx context.Context) Status {
+	start := time.Now()
+	status := Status{
+		Repo:      o.source.GitURL(),
+		Branch:    o.opts.Branch,
+		CheckedAt: start.UTC(),
+	}
+
+	revision, err := o.fetch(ctx)
+	if err == nil {
+		status.Revision = revision
+		o.mu.Lock()
+		unchanged := o.hasRun && o.status.Revision == revision && o.status.Error == ""
+		o.mu.Unlock()
+		if unchanged {
+			o.log.Debugf("%s is still at %s, nothing to do", o.opts.Branch, shortSHA(revision))
+			return o.Status()
+		}
+		o.log.Infof("Checking %s at %s", o.opts.Branch, shortSHA(revision))
+		err = o.check(ctx, filepath.Join(o.workDir, "source"), &status)
+		if ctx.Err() != nil {
+			// Shutting down: an interrupted run is not a result worth publishing
+			return o.Status()
+		}
+	}
+	if err != nil {
+		status.Error = err.Error()
+		o.log.Errorf("run failed: %v", err)
+	}
+	status.Healthy = status.Error == "" && status.Errors == 0 && status.FailedDirs == 0 && len(status.SecretFindings) == 0
+	status.DurationSeconds = time.Since(start).Seconds()
+
+	o.mu.Lock()
+	o.status = status
+	o.hasRun = true
+	if status.Error == "" {
+		o.runs["success"]++
+	} else {
+		o.runs["error"]++
+	}
+	o.mu.Unlock()
+
+	for _, p := range o.opts.Publishers {
+		if err := p.Publish(ctx, status); err != nil {
+			o.log.Warnf("failed to publish status: %v", err)
+		}
+	}
+	return status
+}
+
+// fetch updates the source checkout in the work directory
+func (o *Operator) fetch(ctx context.Context) (string, error) {
+	if o.workDir == "" {
+		return "", fmt.Errorf("WorkDir is required")
+	}
+	retry := sync.GitRetry{Retries: o.opts.GitRetries, Delay: o.opts.GitRetryDelay, Log: o.log}
+	revision, err := sync.FetchBranch(ctx, o.source, filepath.Join(o.workDir, "source"), o.opts.Branch, retry)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch %s: %w", o.opts.Branch, err)
+	}
+	return revision, nil
+}
+
+// check validates and renders the checkout into status
+func (o *Operator) check(ctx context.Context, repoPath string, status *Status) error {
+	cfg, err := config.Load(repoPath)
+	if err != nil {
+		return err
+	}
+
+	validation, err := shadow.Validate(ctx, shadow.ValidateOptions{
+		RepoPath: repoPath,
+		Cluster:  o.opts.Cluster,
+		Config:   cfg,
+		Verbose:  o.opts.Verbose,
+		Log:      o.log,
+	})
+	if err != nil {
+		return fmt.Errorf("validation failed: %w", err)
+	}
+	status.addFindings(validation.Findings)
+
+	out, err := os.MkdirTemp(o.workDir, "rendered-*")
+	if err != nil {
+		return fmt.Errorf("failed to create render directory: %w", err)
+	}
+	defer os.RemoveAll(out)
+
+	var clusters []string
+	if o.opts.Cluster != "" {
+		clusters = []string{o.opts.Cluster}
+	}
+	rendered, err := shadow.Sync(ctx, shadow.SyncOptions{
+		RepoPath: repoPath,
+		Clusters: clusters,
+		Outputs:  cfg.Outputs,
+		// Leaked credentials make the repo unhealthy rather than failing the run
+		AllowSecretFindings: true,
+		Redaction:           cfg.Redaction,
+		Normalization:       cfg.Normalization,
+		HelmCacheDir:        o.opts.HelmCacheDir,
+		Renderers:           o.opts.Renderers,
+		DryRun:              true,
+		OutDir:              out,
+		Verbose:             o.opts.Verbose,
+		Log:                 o.log,
+	})
+	if err != nil {
+		return fmt.Errorf("render failed: %w", err)
+	}
+	status.RenderedDirs = rendered.RenderedDirs
+	status.SkippedDirs = rendered.SkippedDirs
+	status.FailedDirs = rendered.FailedDirs
+	status.RenderFailures = rendered.Failures
+	status.SecretFindings = rendered.SecretFindings
+	return nil
+}
+
+// shortSHA abbreviates a commit SHA for logs
+func shortSHA(sha string) string {
+	if len(sha) > 7 {
+		return sha[:7]
+	}
+	return sha
+}
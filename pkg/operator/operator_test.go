@@ -0,0 +1,147 @@
+package operator
+
+import (
+	"context"
+	"io"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/erauner/homelab-shadow/pkg/config"
+	"github.com/erauner/homelab-shadow/pkg/log"
+	"github.com/erauner/homelab-shadow/pkg/sync"
+	"github.com/erauner/homelab-shadow/pkg/validate/validatetest"
+)
+
+// quiet discards progress logging
+var quiet = log.New(io.Discard, log.LevelError, log.FormatText)
+
+// fixedRenderer renders one manifest per target directory
+type fixedRenderer map[string]string
+
+func (r fixedRenderer) Name() string   { return "fixed" }
+func (r fixedRenderer) Source() string { return config.OutputSourceKustomize }
+
+func (r fixedRenderer) Discover() ([]sync.Target, error) {
+	var targets []sync.Target
+	for dir := range r {
+		targets = append(targets, sync.Target{Dir: dir})
+	}
+	return targets, nil
+}
+
+func (r fixedRenderer) Render(ctx context.Context, target sync.Target) (sync.Manifest, sync.Meta, error) {
+	return sync.Manifest(r[target.Dir]), sync.Meta{}, nil
+}
+
+// recordingPublisher keeps every published status
+type recordingPublisher struct {
+	statuses []Status
+}
+
+func (p *recordingPublisher) Publish(ctx context.Context, status Status) error {
+	p.statuses = append(p.statuses, status)
+	return nil
+}
+
+// newSourceRepo creates a git repo from a fixture and returns its path
+func newSourceRepo(t *testing.T) string {
+	t.Helper()
+	if !sync.IsGitInstalled() {
+		t.Skip("git not installed")
+	}
+	// git does not track empty directories, so give the cluster a file
+	repo := validatetest.Build(t, validatetest.Repo{
+		Clusters: []string{"erauner-home"},
+		Files:    map[string]string{"clusters/erauner-home/README.md": "home\n"},
+	})
+	gitCommit(t, repo, "init", "--quiet", "-b", "main")
+	gitCommit(t, repo, "add", "-A")
+	gitCommit(t, repo, "commit", "--quiet", "--allow-empty", "-m", "init")
+	return repo
+}
+
+func gitCommit(t *testing.T, dir string, args ...string) {
+	t.Helper()
+	cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+	cmd.Dir = dir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, out)
+	}
+}
+
+func TestReconcile(t *testing.T) {
+	repo := newSourceRepo(t)
+	publisher := &recordingPublisher{}
+	op, err := New(Options{
+		SourceRepo: "file://" + repo,
+		WorkDir:    t.TempDir(),
+		Publishers: []Publisher{publisher},
+		Renderers:  []sync.Renderer{fixedRenderer{"apps/web/overlays/production": "kind: Deployment\n"}},
+		Log:        quiet,
+	})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	status := op.Reconcile(context.Background())
+	if status.Error != "" {
+		t.Fatalf("Reconcile() error = %s", status.Error)
+	}
+	if len(status.Revision) != 40 || status.RenderedDirs != 1 {
+		t.Errorf("status = %+v, want a revision and 1 rendered dir", status)
+	}
+	// The fixture cluster has none of the required directories
+	if status.Healthy || status.Errors == 0 || status.Rules["cluster-missing-dir"] == 0 {
+		t.Errorf("status = %+v, want unhealthy with cluster-missing-dir errors", status)
+	}
+
+	// An unchanged branch is not checked or published again
+	op.Reconcile(context.Background())
+	if len(publisher.statuses) != 1 {
+		t.Errorf("published %d statuses for an unchanged branch, want 1", len(publisher.statuses))
+	}
+
+	gitCommit(t, repo, "commit", "--quiet", "--allow-empty", "-m", "next")
+	next := op.Reconcile(context.Background())
+	if next.Revision == status.Revision || len(publisher.statuses) != 2 {
+		t.Errorf("new commit: revision %s (was %s), %d statuses published", next.Revision, status.Revision, len(publisher.statuses))
+	}
+
+	var metrics strings.Builder
+	if err := op.WriteMetrics(&metrics); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`shadow_runs_total{result="success"} 2`,
+		"shadow_repo_healthy 0",
+		`shadow_validation_rule_findings{rule="cluster-missing-dir"}`,
+		`shadow_render_dirs{result="rendered"} 1`,
+		`shadow_revision_info{branch="main",revision="` + next.Revision + `"} 1`,
+	} {
+		if !strings.Contains(metrics.String(), want) {
+			t.Errorf("metrics missing %q:\n%s", want, metrics.String())
+		}
+	}
+}
+
+func TestReconcile_FetchError(t *testing.T) {
+	if !sync.IsGitInstalled() {
+		t.Skip("git not installed")
+	}
+	op, err := New(Options{SourceRepo: "file://" + t.TempDir() + "/missing.git", WorkDir: t.TempDir(), Log: quiet})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	status := op.Reconcile(context.Background())
+	if status.Error == "" || status.Healthy {
+		t.Errorf("status = %+v, want a fetch error", status)
+	}
+
+	var metrics strings.Builder
+	op.WriteMetrics(&metrics)
+	if !strings.Contains(metrics.String(), `shadow_runs_total{result="error"} 1`) {
+		t.Errorf("metrics missing failed run:\n%s", metrics.String())
+	}
+}
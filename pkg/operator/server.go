@@ -0,0 +1,79 @@
+package operator
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// maxWebhookBody bounds webhook payloads read for signature checks
+const maxWebhookBody = 10 << 20
+
+// Handler serves the operator's HTTP endpoints:
+//
+//	/metrics  Prometheus metrics for the last run
+//	/status   the last status as JSON
+//	/healthz  liveness
+//	/webhook  push webhook (GitHub, GitLab, or Gitea) that triggers a run
+//
+// With secret set, webhooks must carry a valid X-Hub-Signature-256 (GitHub),
+// X-Gitea-Signature (Gitea), or X-Gitlab-Token (GitLab)
+func (o *Operator) Handler(secret string) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		if err := o.WriteMetrics(w); err != nil {
+			o.log.Warnf("failed to write metrics: %v", err)
+		}
+	})
+	mux.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(o.Status())
+	})
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok\n")
+	})
+	mux.HandleFunc("/webhook", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxWebhookBody))
+		if err != nil {
+			http.Error(w, "failed to read body", http.StatusBadRequest)
+			return
+		}
+		if secret != "" && !validWebhook(r.Header, body, secret) {
+			http.Error(w, "invalid signature", http.StatusUnauthorized)
+			return
+		}
+		o.log.Debugf("webhook received, triggering a run")
+		o.Trigger()
+		w.WriteHeader(http.StatusAccepted)
+	})
+	return mux
+}
+
+// validWebhook checks a webhook's provider signature or token against secret
+func validWebhook(header http.Header, body []byte, secret string) bool {
+	if token := header.Get("X-Gitlab-Token"); token != "" {
+		return subtle.ConstantTimeCompare([]byte(token), []byte(secret)) == 1
+	}
+
+	signature := strings.TrimPrefix(header.Get("X-Hub-Signature-256"), "sha256=")
+	if signature == "" {
+		signature = header.Get("X-Gitea-Signature")
+	}
+	got, err := hex.DecodeString(signature)
+	if err != nil || len(got) == 0 {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(got, mac.Sum(nil))
+}
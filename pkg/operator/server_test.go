@@ -0,0 +1,73 @@
+package operator
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandler_Webhook(t *testing.T) {
+	body := `{"ref": "refs/heads/main"}`
+	mac := hmac.New(sha256.New, []byte("s3cret"))
+	mac.Write([]byte(body))
+	signature := hex.EncodeToString(mac.Sum(nil))
+
+	tests := []struct {
+		name    string
+		secret  string
+		header  map[string]string
+		method  string
+		want    int
+		trigger bool
+	}{
+		{name: "no secret", want: http.StatusAccepted, trigger: true},
+		{name: "github signature", secret: "s3cret", header: map[string]string{"X-Hub-Signature-256": "sha256=" + signature}, want: http.StatusAccepted, trigger: true},
+		{name: "gitea signature", secret: "s3cret", header: map[string]string{"X-Gitea-Signature": signature}, want: http.StatusAccepted, trigger: true},
+		{name: "gitlab token", secret: "s3cret", header: map[string]string{"X-Gitlab-Token": "s3cret"}, want: http.StatusAccepted, trigger: true},
+		{name: "wrong signature", secret: "other", header: map[string]string{"X-Hub-Signature-256": "sha256=" + signature}, want: http.StatusUnauthorized},
+		{name: "wrong gitlab token", secret: "s3cret", header: map[string]string{"X-Gitlab-Token": "nope"}, want: http.StatusUnauthorized},
+		{name: "unsigned", secret: "s3cret", want: http.StatusUnauthorized},
+		{name: "GET", method: http.MethodGet, want: http.StatusMethodNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			op := &Operator{trigger: make(chan struct{}, 1), log: quiet}
+			method := tt.method
+			if method == "" {
+				method = http.MethodPost
+			}
+			req := httptest.NewRequest(method, "/webhook", strings.NewReader(body))
+			for k, v := range tt.header {
+				req.Header.Set(k, v)
+			}
+			rec := httptest.NewRecorder()
+			op.Handler(tt.secret).ServeHTTP(rec, req)
+
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+			if triggered := len(op.trigger) == 1; triggered != tt.trigger {
+				t.Errorf("triggered = %v, want %v", triggered, tt.trigger)
+			}
+		})
+	}
+}
+
+func TestHandler_Metrics(t *testing.T) {
+	op := &Operator{trigger: make(chan struct{}, 1), runs: map[string]int{}, log: quiet}
+	rec := httptest.NewRecorder()
+	op.Handler("").ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d", rec.Code)
+	}
+	// Before the first run only the run counter is exported
+	if got := rec.Body.String(); !strings.Contains(got, `shadow_runs_total{result="success"} 0`) || strings.Contains(got, "shadow_repo_healthy") {
+		t.Errorf("metrics before first run:\n%s", got)
+	}
+}
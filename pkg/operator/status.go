@@ -0,0 +1,121 @@
+package operator
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/erauner/homelab-shadow/pkg/sync"
+	"github.com/erauner/homelab-shadow/pkg/validate"
+)
+
+// Status is the outcome of one operator run, published as JSON
+type Status struct {
+	Repo            string    `json:"repo"`
+	Branch          string    `json:"branch"`
+	Revision        string    `json:"revision,omitempty"`
+	CheckedAt       time.Time `json:"checked_at"`
+	DurationSeconds float64   `json:"duration_seconds"`
+
+	// Healthy is true when the run completed with no validation errors, render
+	// failures, or secret findings
+	Healthy bool `json:"healthy"`
+
+	// Error is why the run could not complete (fetch, validation, or render setup)
+	Error string `json:"error,omitempty"`
+
+	// Unsuppressed validation findings, in total and per rule
+	Errors   int            `json:"errors"`
+	Warnings int            `json:"warnings"`
+	Rules    map[string]int `json:"rules,omitempty"`
+
+	RenderedDirs   int               `json:"rendered_dirs"`
+	SkippedDirs    int               `json:"skipped_dirs"`
+	FailedDirs     int               `json:"failed_dirs"`
+	RenderFailures []sync.DirFailure `json:"render_failures,omitempty"`
+
+	// SecretFindings are possible credentials in the rendered manifests
+	SecretFindings []sync.SecretFinding `json:"secret_findings,omitempty"`
+}
+
+// addFindings counts unsuppressed findings
+func (s *Status) addFindings(findings []validate.Result) {
+	s.Errors += validate.CountErrors(findings)
+	s.Warnings += validate.CountWarnings(findings)
+	for _, f := range validate.Unsuppressed(findings) {
+		if s.Rules == nil {
+			s.Rules = map[string]int{}
+		}
+		s.Rules[f.Rule]++
+	}
+}
+
+// WriteMetrics writes the latest status in the Prometheus text exposition format
+func (o *Operator) WriteMetrics(w io.Writer) error {
+	o.mu.Lock()
+	status, hasRun := o.status, o.hasRun
+	runs := map[string]int{"success": o.runs["success"], "error": o.runs["error"]}
+	o.mu.Unlock()
+
+	var b strings.Builder
+	metric := func(name, help, kind string) {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+	}
+
+	metric("shadow_runs_total", "Completed operator runs by result.", "counter")
+	for _, result := range []string{"error", "success"} {
+		fmt.Fprintf(&b, "shadow_runs_total{result=%q} %d\n", result, runs[result])
+	}
+	if !hasRun {
+		_, err := io.WriteString(w, b.String())
+		return err
+	}
+
+	metric("shadow_repo_healthy", "1 if the last run had no validation errors, render failures, or secret findings.", "gauge")
+	fmt.Fprintf(&b, "shadow_repo_healthy %d\n", boolValue(status.Healthy))
+	metric("shadow_last_run_success", "1 if the last run completed.", "gauge")
+	fmt.Fprintf(&b, "shadow_last_run_success %d\n", boolValue(status.Error == ""))
+	metric("shadow_last_run_timestamp_seconds", "Unix time the last run started.", "gauge")
+	fmt.Fprintf(&b, "shadow_last_run_timestamp_seconds %d\n", status.CheckedAt.Unix())
+	metric("shadow_last_run_duration_seconds", "Duration of the last run.", "gauge")
+	fmt.Fprintf(&b, "shadow_last_run_duration_seconds %g\n", status.DurationSeconds)
+	metric("shadow_revision_info", "Source commit checked by the last run.", "gauge")
+	fmt.Fprintf(&b, "shadow_revision_info{branch=%q,revision=%q} 1\n", status.Branch, status.Revision)
+
+	metric("shadow_validation_findings", "Unsuppressed validation findings in the last run by severity.", "gauge")
+	fmt.Fprintf(&b, "shadow_validation_findings{severity=\"error\"} %d\n", status.Errors)
+	fmt.Fprintf(&b, "shadow_validation_findings{severity=\"warn\"} %d\n", status.Warnings)
+
+	if len(status.Rules) > 0 {
+		metric("shadow_validation_rule_findings", "Unsuppressed validation findings in the last run by rule.", "gauge")
+		rules := make([]string, 0, len(status.Rules))
+		for rule := range status.Rules {
+			rules = append(rules, rule)
+		}
+		sort.Strings(rules)
+		for _, rule := range rules {
+			fmt.Fprintf(&b, "shadow_validation_rule_findings{rule=%q} %d\n", rule, status.Rules[rule])
+		}
+	}
+
+	metric("shadow_render_dirs", "Directories in the last render by result.", "gauge")
+	fmt.Fprintf(&b, "shadow_render_dirs{result=\"rendered\"} %d\n", status.RenderedDirs)
+	fmt.Fprintf(&b, "shadow_render_dirs{result=\"skipped\"} %d\n", status.SkippedDirs)
+	fmt.Fprintf(&b, "shadow_render_dirs{result=\"failed\"} %d\n", status.FailedDirs)
+
+	metric("shadow_secret_findings", "Possible secrets in the last render.", "gauge")
+	fmt.Fprintf(&b, "shadow_secret_findings %d\n", len(status.SecretFindings))
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// boolValue converts a bool to a 0/1 metric value
+func boolValue(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,92 @@
+// Package output parses --output values, including Go templates over a
+// command's result
+//
+// Besides a command's fixed formats (table, json, markdown, ...), every
+// command accepts template='{{...}}' and template-file=<path>, executed with
+// text/template over the same data the json format encodes. Field names are
+// the Go struct fields (.Results, .Summary.Errors), not the json keys.
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/template"
+)
+
+// Format is a parsed --output value
+type Format struct {
+	Name     string             // a fixed format, or "template"
+	Template *template.Template // set for template and template-file
+}
+
+// IsTemplate reports whether f renders a template
+func (f Format) IsTemplate() bool {
+	return f.Template != nil
+}
+
+// Parse parses value as one of formats (compared case-insensitively), or as
+// template=<text> or template-file=<path>
+func Parse(value string, formats ...string) (Format, error) {
+	if text, ok := strings.CutPrefix(value, "template="); ok {
+		return parseTemplate("template", text)
+	}
+	if file, ok := strings.CutPrefix(value, "template-file="); ok {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return Format{}, fmt.Errorf("failed to read output template: %w", err)
+		}
+		return parseTemplate(file, string(data))
+	}
+	for _, f := range formats {
+		if strings.EqualFold(value, f) {
+			return Format{Name: f}, nil
+		}
+	}
+	return Format{}, fmt.Errorf("unknown output format: %s (expected %s, template=..., or template-file=...)", value, strings.Join(formats, ", "))
+}
+
+// parseTemplate parses text with Funcs
+func parseTemplate(name, text string) (Format, error) {
+	tmpl, err := template.New(name).Funcs(Funcs).Parse(text)
+	if err != nil {
+		return Format{}, fmt.Errorf("invalid output template: %w", err)
+	}
+	return Format{Name: "template", Template: tmpl}, nil
+}
+
+// Execute renders data with f's template, ending the output with a newline
+func (f Format) Execute(w io.Writer, data interface{}) error {
+	var b strings.Builder
+	if err := f.Template.Execute(&b, data); err != nil {
+		return fmt.Errorf("failed to render output template: %w", err)
+	}
+	out := b.String()
+	if out != "" && !strings.HasSuffix(out, "\n") {
+		out += "\n"
+	}
+	_, err := io.WriteString(w, out)
+	return err
+}
+
+// Funcs are the functions available to output templates
+var Funcs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+	"join":  strings.Join,
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+	"replace": func(old, new, s string) string {
+		return strings.ReplaceAll(s, old, new)
+	},
+	"trunc": func(n int, s string) string {
+		if len(s) <= n {
+			return s
+		}
+		return s[:n] + "..."
+	},
+}
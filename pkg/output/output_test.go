@@ -0,0 +1,69 @@
+package output
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	f, err := Parse("JSON", "table", "json")
+	if err != nil || f.Name != "json" || f.IsTemplate() {
+		t.Errorf("Parse(JSON) = %+v, %v", f, err)
+	}
+	if _, err := Parse("yaml", "table", "json"); err == nil || !strings.Contains(err.Error(), "expected table, json, template=") {
+		t.Errorf("Parse(yaml) error = %v", err)
+	}
+	if _, err := Parse("template={{.Missing", "table"); err == nil {
+		t.Error("Parse() of a malformed template should fail")
+	}
+}
+
+func TestFormat_Execute(t *testing.T) {
+	data := struct {
+		Name    string
+		Tags    []string
+		Summary struct{ Errors int }
+	}{Name: "apps/web|base", Tags: []string{"a", "b"}}
+	data.Summary.Errors = 2
+
+	tests := map[string]string{
+		`template={{.Name}}: {{.Summary.Errors}} error(s)`:         "apps/web|base: 2 error(s)\n",
+		`template={{join .Tags ","}} {{upper "x"}} {{json .Tags}}`: "a,b X [\"a\",\"b\"]\n",
+		`template={{replace "|" "/" .Name}} {{trunc 4 .Name}}`:     "apps/web/base apps...\n",
+		"template={{range .Tags}}{{.}}\n{{end}}":                   "a\nb\n",
+	}
+	for value, want := range tests {
+		f, err := Parse(value)
+		if err != nil {
+			t.Fatalf("Parse(%q) error = %v", value, err)
+		}
+		var b strings.Builder
+		if err := f.Execute(&b, data); err != nil {
+			t.Fatalf("Execute(%q) error = %v", value, err)
+		}
+		if b.String() != want {
+			t.Errorf("Execute(%q) = %q, want %q", value, b.String(), want)
+		}
+	}
+
+	f, _ := Parse("template={{.Nope}}")
+	if err := f.Execute(&strings.Builder{}, data); err == nil {
+		t.Error("Execute() of a missing field should fail")
+	}
+}
+
+func TestParse_TemplateFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "summary.tmpl")
+	if err := os.WriteFile(file, []byte("{{.}}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	f, err := Parse("template-file=" + file)
+	if err != nil || !f.IsTemplate() {
+		t.Fatalf("Parse(template-file) = %+v, %v", f, err)
+	}
+	if _, err := Parse("template-file=" + file + ".missing"); err == nil {
+		t.Error("Parse() of a missing template file should fail")
+	}
+}
@@ -0,0 +1,165 @@
+// Package progress reports how far a run over many directories has got
+//
+// On a terminal a Reporter redraws a single status line with the item being
+// worked on, a pass/fail tally, and an ETA. Elsewhere (CI logs) it writes the
+// same status as a log line every Interval instead. A nil *Reporter reports
+// nothing, so runs can hold an optional reporter.
+package progress
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/erauner/homelab-shadow/pkg/log"
+)
+
+// DefaultInterval is how often a Reporter without a terminal logs its status
+const DefaultInterval = 30 * time.Second
+
+// Reporter tracks items of a run as they start and finish
+type Reporter struct {
+	Label string // what is counted, e.g. "render"
+	Total int
+
+	// Out is redrawn in place when TTY is set; otherwise status lines go to
+	// Log every Interval
+	Out      io.Writer
+	TTY      bool
+	Interval time.Duration
+	Log      *log.Logger
+
+	mu       sync.Mutex
+	start    time.Time
+	lastLog  time.Time
+	current  string
+	done     int
+	failed   int
+	finished bool
+	now      func() time.Time
+}
+
+// New creates a reporter for total items on stderr, redrawn in place when
+// stderr is a terminal
+func New(label string, total int) *Reporter {
+	return &Reporter{
+		Label:    label,
+		Total:    total,
+		Out:      os.Stderr,
+		TTY:      IsTerminal(os.Stderr),
+		Interval: DefaultInterval,
+		Log:      log.Default().Named("progress"),
+	}
+}
+
+// IsTerminal reports whether f is a character device (a terminal)
+func IsTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// Start marks item as the one being worked on
+func (r *Reporter) Start(item string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.init()
+	r.current = item
+	r.report(false)
+}
+
+// Done counts the current item as finished, failed unless passed
+func (r *Reporter) Done(passed bool) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.init()
+	r.done++
+	r.current = ""
+	if !passed {
+		r.failed++
+	}
+	r.report(false)
+}
+
+// Finish reports the final tally and ends the status line
+func (r *Reporter) Finish() {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.finished {
+		return
+	}
+	r.init()
+	r.finished = true
+	r.current = ""
+	r.report(true)
+}
+
+// init starts the clock on first use
+func (r *Reporter) init() {
+	if r.now == nil {
+		r.now = time.Now
+	}
+	if r.start.IsZero() {
+		r.start = r.now()
+		r.lastLog = r.start
+	}
+}
+
+// report draws the status line, or logs it when Interval has passed (or final)
+func (r *Reporter) report(final bool) {
+	now := r.now()
+	if r.TTY {
+		line := r.status(now)
+		if final {
+			fmt.Fprintf(r.Out, "\r\033[K%s\n", line)
+		} else {
+			fmt.Fprintf(r.Out, "\r\033[K%s", line)
+		}
+		return
+	}
+	if final || now.Sub(r.lastLog) >= r.interval() {
+		r.lastLog = now
+		r.Log.Infof("%s", r.status(now))
+	}
+}
+
+// interval returns Interval, or DefaultInterval when unset
+func (r *Reporter) interval() time.Duration {
+	if r.Interval <= 0 {
+		return DefaultInterval
+	}
+	return r.Interval
+}
+
+// status formats "render 12/200 (6%) ✓10 ✗2 ETA 1m20s apps/web/base"
+func (r *Reporter) status(now time.Time) string {
+	var b strings.Builder
+	elapsed := now.Sub(r.start)
+	fmt.Fprintf(&b, "%s %d/%d", r.Label, r.done, r.Total)
+	if r.Total > 0 {
+		fmt.Fprintf(&b, " (%d%%)", r.done*100/r.Total)
+	}
+	fmt.Fprintf(&b, " ✓%d ✗%d", r.done-r.failed, r.failed)
+	switch {
+	case r.finished:
+		fmt.Fprintf(&b, " in %s", elapsed.Round(time.Second))
+	case r.done > 0 && r.done < r.Total:
+		eta := elapsed / time.Duration(r.done) * time.Duration(r.Total-r.done)
+		fmt.Fprintf(&b, " ETA %s", eta.Round(time.Second))
+	}
+	if r.current != "" {
+		b.WriteString(" " + r.current)
+	}
+	return b.String()
+}
@@ -0,0 +1,82 @@
+package progress
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/erauner/homelab-shadow/pkg/log"
+)
+
+// fakeClock returns a clock reading *tick, which the test advances
+func fakeClock(tick *time.Time) func() time.Time {
+	return func() time.Time { return *tick }
+}
+
+func TestReporter_TTY(t *testing.T) {
+	var out bytes.Buffer
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	r := &Reporter{Label: "render", Total: 4, Out: &out, TTY: true, now: fakeClock(&now)}
+
+	r.Start("apps/web/base")
+	now = now.Add(10 * time.Second)
+	r.Done(true)
+	r.Start("apps/api/base")
+	now = now.Add(10 * time.Second)
+	r.Done(false)
+
+	lines := strings.Split(out.String(), "\r\033[K")
+	if got := lines[len(lines)-2]; got != "render 1/4 (25%) ✓1 ✗0 ETA 30s apps/api/base" {
+		t.Errorf("status line = %q", got)
+	}
+	if got := lines[len(lines)-1]; got != "render 2/4 (50%) ✓1 ✗1 ETA 20s" {
+		t.Errorf("status line = %q", got)
+	}
+
+	r.Finish()
+	r.Finish()
+	if !strings.HasSuffix(out.String(), "\r\033[Krender 2/4 (50%) ✓1 ✗1 in 20s\n") {
+		t.Errorf("final line = %q", out.String())
+	}
+	if strings.Count(out.String(), "\n") != 1 {
+		t.Error("Finish should end the status line once")
+	}
+}
+
+func TestReporter_Log(t *testing.T) {
+	var buf bytes.Buffer
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	r := &Reporter{
+		Label:    "validate",
+		Total:    3,
+		Interval: time.Minute,
+		Log:      log.New(&buf, log.LevelInfo, log.FormatText).Named("progress"),
+		now:      fakeClock(&now),
+	}
+
+	r.Start("a")
+	now = now.Add(30 * time.Second)
+	r.Done(true)
+	if buf.Len() != 0 {
+		t.Errorf("logged before Interval: %q", buf.String())
+	}
+
+	r.Start("b")
+	now = now.Add(40 * time.Second)
+	r.Done(true)
+	r.Finish()
+
+	want := "[progress] validate 2/3 (66%) ✓2 ✗0 ETA 35s\n" +
+		"[progress] validate 2/3 (66%) ✓2 ✗0 in 1m10s\n"
+	if buf.String() != want {
+		t.Errorf("output = %q, want %q", buf.String(), want)
+	}
+}
+
+func TestReporter_Nil(t *testing.T) {
+	var r *Reporter
+	r.Start("x")
+	r.Done(true)
+	r.Finish()
+}
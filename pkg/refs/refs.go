@@ -0,0 +1,263 @@
+// Package refs finds references to a Kubernetes resource in rendered
+// manifests and source files, to assess the impact of renaming or deleting it
+package refs
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Reference sources
+const (
+	SourceRendered = "rendered"
+	SourceFile     = "source"
+)
+
+// Target is the resource references are searched for
+type Target struct {
+	Kind      string
+	Namespace string // empty matches every namespace
+	Name      string
+}
+
+// ParseTarget parses a kind and a "[namespace/]name" reference
+func ParseTarget(kind, ref string) (Target, error) {
+	if kind == "" {
+		return Target{}, fmt.Errorf("kind is required")
+	}
+	namespace, name, found := strings.Cut(ref, "/")
+	if !found {
+		namespace, name = "", ref
+	}
+	if name == "" || strings.Contains(name, "/") {
+		return Target{}, fmt.Errorf("invalid resource %q (want [namespace/]name)", ref)
+	}
+	return Target{Kind: kind, Namespace: namespace, Name: name}, nil
+}
+
+func (t Target) String() string {
+	if t.Namespace == "" {
+		return t.Kind + "/" + t.Name
+	}
+	return t.Kind + "/" + t.Namespace + "/" + t.Name
+}
+
+// Reference is one place the target is referenced or defined
+type Reference struct {
+	Source   string `json:"source"` // rendered or source
+	File     string `json:"file"`   // rendered directory or source file, relative to the searched root
+	Line     int    `json:"line"`
+	Resource string `json:"resource,omitempty"` // referencing resource as Kind/namespace/name (rendered only)
+	Field    string `json:"field"`              // field path of the reference, or the matching line for source files
+}
+
+// Fields reported for resources that are the target rather than reference it
+const (
+	FieldDefinition = "(definition)"
+	FieldGenerates  = "(generates)"
+)
+
+// Rendered finds references to target in rendered manifests keyed by
+// directory. Besides kind-specific fields (envFrom, volumes, secretKeyRef,
+// imagePullSecrets, Ingress tls, serviceAccountName, claimName, backendRefs,
+// ...), any object reference with matching kind and name is found. A
+// reference in another namespace than the target's is skipped; resources
+// without a namespace match any.
+func Rendered(manifests map[string]string, target Target) ([]Reference, error) {
+	dirs := make([]string, 0, len(manifests))
+	for dir := range manifests {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+
+	var refs []Reference
+	for _, dir := range dirs {
+		found, err := findInManifest(manifests[dir], target)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", dir, err)
+		}
+		for _, ref := range found {
+			ref.File = dir
+			refs = append(refs, ref)
+		}
+	}
+	return refs, nil
+}
+
+// resource is the document a reference is found in
+type resource struct {
+	kind, namespace, name string
+}
+
+func (r resource) String() string {
+	if r.namespace == "" {
+		return r.kind + "/" + r.name
+	}
+	return r.kind + "/" + r.namespace + "/" + r.name
+}
+
+// findInManifest searches every document of one manifest
+func findInManifest(manifest string, target Target) ([]Reference, error) {
+	dec := yaml.NewDecoder(strings.NewReader(manifest))
+
+	var refs []Reference
+	for {
+		var doc yaml.Node
+		err := dec.Decode(&doc)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse manifest: %w", err)
+		}
+		if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+			continue
+		}
+		root := doc.Content[0]
+
+		metadata := field(root, "metadata")
+		res := resource{kind: scalar(field(root, "kind")), namespace: scalar(field(metadata, "namespace")), name: scalar(field(metadata, "name"))}
+		add := func(node *yaml.Node, path string) {
+			refs = append(refs, Reference{Source: SourceRendered, Line: node.Line, Resource: res.String(), Field: path})
+		}
+
+		if res.name == target.Name && namespaceMatches(target, res.namespace) {
+			switch {
+			case res.kind == target.Kind:
+				add(field(metadata, "name"), FieldDefinition)
+				continue
+			case target.Kind == "Secret" && res.kind == "SealedSecret":
+				add(field(metadata, "name"), FieldGenerates)
+				continue
+			}
+		}
+
+		walk(root, "", "", func(parentKey, path string, m *yaml.Node) {
+			// Object references that name their kind: backendRefs, scaleTargetRef, roleRef, ...
+			if path != "" && scalar(field(m, "kind")) == target.Kind {
+				if name := field(m, "name"); scalar(name) == target.Name && namespaceMatches(target, refNamespace(m, res)) {
+					add(name, path+".name")
+				}
+				return
+			}
+
+			for i := 0; i+1 < len(m.Content); i += 2 {
+				key, value := m.Content[i].Value, m.Content[i+1]
+				if value.Kind != yaml.ScalarNode || value.Value != target.Name {
+					continue
+				}
+				if !refersTo(target.Kind, res.kind, parentKey, path, key, m) {
+					continue
+				}
+				if namespaceMatches(target, refNamespace(m, res)) {
+					add(value, join(path, key))
+				}
+			}
+		})
+	}
+	return refs, nil
+}
+
+// scalarRefs are fields that hold the name of a resource of a kind anywhere they appear
+var scalarRefs = map[string][]string{
+	"Secret":                {"secretName"},
+	"ServiceAccount":        {"serviceAccountName", "serviceAccount"},
+	"PersistentVolumeClaim": {"claimName"},
+	"StorageClass":          {"storageClassName"},
+	"PriorityClass":         {"priorityClassName"},
+	"IngressClass":          {"ingressClassName"},
+}
+
+// refersTo reports whether key in mapping m (at path, under parentKey) names
+// a resource of kind
+func refersTo(kind, resourceKind, parentKey, path, key string, m *yaml.Node) bool {
+	for _, k := range scalarRefs[kind] {
+		if key == k {
+			return true
+		}
+	}
+	if key != "name" {
+		return false
+	}
+
+	parent := strings.ToLower(parentKey)
+	switch kind {
+	case "Secret":
+		// envFrom.secretRef, secretKeyRef, *SecretRef in CRDs, projected sources, image pull secrets
+		return strings.HasSuffix(parent, "secretref") || parent == "secretkeyref" || parent == "secret" ||
+			parentKey == "imagePullSecrets" || parentKey == "secrets" ||
+			(resourceKind == "ExternalSecret" && path == "spec.target")
+	case "ConfigMap":
+		return strings.HasSuffix(parent, "configmapref") || parent == "configmapkeyref" || parent == "configmap"
+	case "Service":
+		// Ingress backends, and Gateway API backendRefs (Service unless kind says otherwise)
+		return parentKey == "service" || (parentKey == "backendRefs" && field(m, "kind") == nil)
+	}
+	return false
+}
+
+// refNamespace is the namespace a reference resolves in: its own namespace
+// field, or the referencing resource's
+func refNamespace(m *yaml.Node, res resource) string {
+	if ns := scalar(field(m, "namespace")); ns != "" {
+		return ns
+	}
+	return res.namespace
+}
+
+// namespaceMatches reports whether a reference in namespace can resolve to target
+func namespaceMatches(target Target, namespace string) bool {
+	return target.Namespace == "" || namespace == "" || namespace == target.Namespace
+}
+
+// walk calls fn for every mapping under node with the key it is stored under
+// (for list items, the list's key) and its field path
+func walk(node *yaml.Node, parentKey, path string, fn func(parentKey, path string, m *yaml.Node)) {
+	switch node.Kind {
+	case yaml.MappingNode:
+		fn(parentKey, path, node)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := node.Content[i].Value
+			walk(node.Content[i+1], key, join(path, key), fn)
+		}
+	case yaml.SequenceNode:
+		for i, item := range node.Content {
+			walk(item, parentKey, path+"["+strconv.Itoa(i)+"]", fn)
+		}
+	}
+}
+
+// field returns the value of key in a mapping, or nil
+func field(m *yaml.Node, key string) *yaml.Node {
+	if m == nil || m.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			return m.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// scalar returns a scalar node's value, or ""
+func scalar(node *yaml.Node) string {
+	if node == nil || node.Kind != yaml.ScalarNode {
+		return ""
+	}
+	return node.Value
+}
+
+// join appends key to a dotted field path
+func join(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
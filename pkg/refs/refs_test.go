@@ -0,0 +1,183 @@
+package refs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseTarget(t *testing.T) {
+	target, err := ParseTarget("Secret", "media/db-creds")
+	if err != nil || target != (Target{Kind: "Secret", Namespace: "media", Name: "db-creds"}) {
+		t.Errorf("ParseTarget() = %+v, %v", target, err)
+	}
+	target, err = ParseTarget("ConfigMap", "dashboards")
+	if err != nil || target != (Target{Kind: "ConfigMap", Name: "dashboards"}) {
+		t.Errorf("ParseTarget() = %+v, %v", target, err)
+	}
+	for _, ref := range []string{"", "media/", "a/b/c"} {
+		if _, err := ParseTarget("Secret", ref); err == nil {
+			t.Errorf("ParseTarget(%q) expected error", ref)
+		}
+	}
+}
+
+const renderedApp = `apiVersion: v1
+kind: Secret
+metadata:
+  name: db-creds
+  namespace: media
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: jellyfin
+  namespace: media
+spec:
+  template:
+    spec:
+      serviceAccountName: jellyfin
+      imagePullSecrets:
+        - name: db-creds
+      containers:
+        - name: app
+          envFrom:
+            - secretRef:
+                name: db-creds
+          env:
+            - name: PASSWORD
+              valueFrom:
+                secretKeyRef:
+                  name: db-creds
+                  key: password
+            - name: db-creds
+              value: not-a-reference
+      volumes:
+        - name: creds
+          secret:
+            secretName: db-creds
+        - name: config
+          configMap:
+            name: db-creds
+---
+apiVersion: external-secrets.io/v1beta1
+kind: ExternalSecret
+metadata:
+  name: db
+  namespace: media
+spec:
+  target:
+    name: db-creds
+---
+apiVersion: v1
+kind: Pod
+metadata:
+  name: other
+  namespace: default
+spec:
+  volumes:
+    - name: creds
+      secret:
+        secretName: db-creds
+`
+
+func TestRendered_Secret(t *testing.T) {
+	target := Target{Kind: "Secret", Namespace: "media", Name: "db-creds"}
+	found, err := Rendered(map[string]string{"apps/media/overlays/erauner-home/production": renderedApp}, target)
+	if err != nil {
+		t.Fatalf("Rendered() error = %v", err)
+	}
+
+	want := []Reference{
+		{Line: 4, Resource: "Secret/media/db-creds", Field: FieldDefinition},
+		{Line: 17, Resource: "Deployment/media/jellyfin", Field: "spec.template.spec.imagePullSecrets[0].name"},
+		{Line: 22, Resource: "Deployment/media/jellyfin", Field: "spec.template.spec.containers[0].envFrom[0].secretRef.name"},
+		{Line: 27, Resource: "Deployment/media/jellyfin", Field: "spec.template.spec.containers[0].env[0].valueFrom.secretKeyRef.name"},
+		{Line: 34, Resource: "Deployment/media/jellyfin", Field: "spec.template.spec.volumes[0].secret.secretName"},
+		{Line: 46, Resource: "ExternalSecret/media/db", Field: "spec.target.name"},
+	}
+	if len(found) != len(want) {
+		t.Fatalf("got %d references, want %d:\n%+v", len(found), len(want), found)
+	}
+	for i, w := range want {
+		w.Source, w.File = SourceRendered, "apps/media/overlays/erauner-home/production"
+		if found[i] != w {
+			t.Errorf("found[%d] = %+v, want %+v", i, found[i], w)
+		}
+	}
+}
+
+func TestRendered_TypedReferences(t *testing.T) {
+	manifest := `apiVersion: gateway.networking.k8s.io/v1
+kind: HTTPRoute
+metadata:
+  name: web
+  namespace: media
+spec:
+  rules:
+    - backendRefs:
+        - name: jellyfin
+          port: 8096
+---
+apiVersion: autoscaling/v2
+kind: HorizontalPodAutoscaler
+metadata:
+  name: jellyfin
+  namespace: media
+spec:
+  scaleTargetRef:
+    kind: Deployment
+    name: jellyfin
+`
+	services, err := Rendered(map[string]string{"apps/media": manifest}, Target{Kind: "Service", Name: "jellyfin"})
+	if err != nil {
+		t.Fatalf("Rendered() error = %v", err)
+	}
+	if len(services) != 1 || services[0].Field != "spec.rules[0].backendRefs[0].name" {
+		t.Errorf("Service references = %+v", services)
+	}
+
+	deployments, err := Rendered(map[string]string{"apps/media": manifest}, Target{Kind: "Deployment", Namespace: "media", Name: "jellyfin"})
+	if err != nil {
+		t.Fatalf("Rendered() error = %v", err)
+	}
+	if len(deployments) != 1 || deployments[0].Field != "spec.scaleTargetRef.name" {
+		t.Errorf("Deployment references = %+v", deployments)
+	}
+}
+
+func TestSources(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		"apps/media/helm/values.yaml":  "persistence:\n  existingSecret: db-creds\n  other: db-creds-v2\n",
+		"apps/media/base/kustom.yaml":  "secretGenerator:\n  - name: db-creds\n",
+		"apps/media/README.md":         "db-creds is documented here\n",
+		".git/objects/info/packs.yaml": "db-creds\n",
+	}
+	for path, content := range files {
+		full := filepath.Join(root, path)
+		if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(full, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	found, err := Sources(root, Target{Kind: "Secret", Name: "db-creds"})
+	if err != nil {
+		t.Fatalf("Sources() error = %v", err)
+	}
+	want := []Reference{
+		{Source: SourceFile, File: "apps/media/base/kustom.yaml", Line: 2, Field: "- name: db-creds"},
+		{Source: SourceFile, File: "apps/media/helm/values.yaml", Line: 2, Field: "existingSecret: db-creds"},
+	}
+	if len(found) != len(want) {
+		t.Fatalf("got %+v, want %+v", found, want)
+	}
+	for i := range want {
+		if found[i] != want[i] {
+			t.Errorf("found[%d] = %+v, want %+v", i, found[i], want[i])
+		}
+	}
+}
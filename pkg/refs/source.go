@@ -0,0 +1,109 @@
+package refs
+
+import (
+	"bufio"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// sourceExtensions are the source files searched for references
+var sourceExtensions = map[string]bool{
+	".yaml": true,
+	".yml":  true,
+	".json": true,
+	".tpl":  true,
+}
+
+// maxSourceLine truncates matching lines in source references
+const maxSourceLine = 120
+
+// Sources searches the YAML, JSON, and template files under root for the
+// target's name as a whole word, catching references rendering can't show:
+// Helm values files (existingSecret: ...), ExternalSecret and SealedSecret
+// sources, generators, and overlays that are not rendered. Hidden
+// directories (.git, ...) are skipped.
+func Sources(root string, target Target) ([]Reference, error) {
+	var refs []Reference
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != root && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !sourceExtensions[filepath.Ext(path)] {
+			return nil
+		}
+
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			rel = path
+		}
+		found, err := searchFile(path, target.Name)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", rel, err)
+		}
+		for _, ref := range found {
+			ref.File = filepath.ToSlash(rel)
+			refs = append(refs, ref)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return refs, nil
+}
+
+// searchFile returns the lines of a file that contain name as a whole word
+func searchFile(path, name string) ([]Reference, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var refs []Reference
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		if !containsWord(text, name) {
+			continue
+		}
+		text = strings.TrimSpace(text)
+		if len(text) > maxSourceLine {
+			text = text[:maxSourceLine-3] + "..."
+		}
+		refs = append(refs, Reference{Source: SourceFile, Line: line, Field: text})
+	}
+	return refs, scanner.Err()
+}
+
+// containsWord reports whether s contains name not surrounded by other
+// resource name characters, so my-secret doesn't match my-secret-v2
+func containsWord(s, name string) bool {
+	for start := 0; ; {
+		i := strings.Index(s[start:], name)
+		if i < 0 {
+			return false
+		}
+		i += start
+		end := i + len(name)
+		if (i == 0 || !isNameChar(s[i-1])) && (end == len(s) || !isNameChar(s[end])) {
+			return true
+		}
+		start = i + 1
+	}
+}
+
+// isNameChar reports whether b can be part of a Kubernetes resource name
+func isNameChar(b byte) bool {
+	return b == '-' || b == '.' || b == '_' || (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z') || (b >= '0' && b <= '9')
+}
@@ -0,0 +1,355 @@
+// Package scaffold generates the files of a new app or component laid out
+// the way shadow validate expects: a base plus one overlay per cluster
+// (apps/<app>/overlays/<cluster>/<env>/, <root>/<component>/overlays/<cluster>/),
+// each overlay referencing the base
+package scaffold
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+
+	"github.com/erauner/homelab-shadow/pkg/argocd"
+	"github.com/erauner/homelab-shadow/pkg/command"
+)
+
+// File is a generated file, relative to the repo root
+type File struct {
+	Path    string
+	Content string
+}
+
+// ComponentRoots are the directories components can be generated under
+var ComponentRoots = []string{"infrastructure", "operators", "security"}
+
+// namePattern is a DNS label, as app, cluster, and env names become
+// directory, namespace, and Application names
+var namePattern = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+
+// AppOptions configures App
+type AppOptions struct {
+	Name     string
+	Clusters []string // at least one
+	Envs     []string // default: production
+
+	// Namespace the overlays deploy to (default: Name)
+	Namespace string
+
+	// Image, when set, adds a Deployment and Service running it to the base
+	Image string
+	Port  int // container and Service port (default: 8080)
+
+	// Application also generates argocd-apps/applications/<name>.yaml with
+	// an Application per cluster and env
+	Application bool
+	RepoURL     string // Application source repoURL (required with Application)
+	Project     string // default: default
+}
+
+// App returns the files of a new app
+func App(opts AppOptions) ([]File, error) {
+	if opts.Namespace == "" {
+		opts.Namespace = opts.Name
+	}
+	if len(opts.Envs) == 0 {
+		opts.Envs = []string{"production"}
+	}
+	if opts.Port == 0 {
+		opts.Port = 8080
+	}
+	if opts.Project == "" {
+		opts.Project = "default"
+	}
+	if err := checkNames("app", opts.Name); err != nil {
+		return nil, err
+	}
+	if err := checkNames("namespace", opts.Namespace); err != nil {
+		return nil, err
+	}
+	if err := checkClusters(opts.Clusters); err != nil {
+		return nil, err
+	}
+	if err := checkNames("env", opts.Envs...); err != nil {
+		return nil, err
+	}
+	if opts.Application && opts.RepoURL == "" {
+		return nil, fmt.Errorf("a repoURL is required to generate an Application")
+	}
+
+	dir := "apps/" + opts.Name
+	var files []File
+	add := func(path, tmpl string, data interface{}) error {
+		content, err := render(tmpl, data)
+		if err != nil {
+			return err
+		}
+		files = append(files, File{Path: path, Content: content})
+		return nil
+	}
+
+	var resources []string
+	if opts.Image != "" {
+		resources = []string{"deployment.yaml", "service.yaml"}
+		if err := add(dir+"/base/deployment.yaml", deploymentTemplate, opts); err != nil {
+			return nil, err
+		}
+		if err := add(dir+"/base/service.yaml", serviceTemplate, opts); err != nil {
+			return nil, err
+		}
+	}
+	if err := add(dir+"/base/kustomization.yaml", baseTemplate, resources); err != nil {
+		return nil, err
+	}
+
+	var apps []applicationData
+	for _, cluster := range opts.Clusters {
+		for _, env := range opts.Envs {
+			overlay := fmt.Sprintf("%s/overlays/%s/%s", dir, cluster, env)
+			data := overlayData{Namespace: opts.Namespace, Base: "../../../base"}
+			if err := add(overlay+"/kustomization.yaml", overlayTemplate, data); err != nil {
+				return nil, err
+			}
+			apps = append(apps, applicationData{
+				Name:      fmt.Sprintf("%s-%s-%s", opts.Name, cluster, env),
+				Project:   opts.Project,
+				RepoURL:   opts.RepoURL,
+				Path:      overlay,
+				Cluster:   cluster,
+				Namespace: opts.Namespace,
+			})
+		}
+	}
+
+	if opts.Application {
+		if err := add("argocd-apps/applications/"+opts.Name+".yaml", applicationTemplate, apps); err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+// ComponentOptions configures Component
+type ComponentOptions struct {
+	Root     string // one of ComponentRoots
+	Name     string
+	Clusters []string // at least one
+
+	// Namespace, when set, is set on every overlay
+	Namespace string
+}
+
+// Component returns the files of a new infrastructure, operators, or
+// security component
+func Component(opts ComponentOptions) ([]File, error) {
+	known := false
+	for _, root := range ComponentRoots {
+		known = known || root == opts.Root
+	}
+	if !known {
+		return nil, fmt.Errorf("unknown component root %q (want one of %v)", opts.Root, ComponentRoots)
+	}
+	if err := checkNames("component", opts.Name); err != nil {
+		return nil, err
+	}
+	if opts.Namespace != "" {
+		if err := checkNames("namespace", opts.Namespace); err != nil {
+			return nil, err
+		}
+	}
+	if err := checkClusters(opts.Clusters); err != nil {
+		return nil, err
+	}
+
+	dir := opts.Root + "/" + opts.Name
+	base, err := render(baseTemplate, []string(nil))
+	if err != nil {
+		return nil, err
+	}
+	files := []File{{Path: dir + "/base/kustomization.yaml", Content: base}}
+	for _, cluster := range opts.Clusters {
+		overlay, err := render(overlayTemplate, overlayData{Namespace: opts.Namespace, Base: "../../base"})
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, File{Path: fmt.Sprintf("%s/overlays/%s/kustomization.yaml", dir, cluster), Content: overlay})
+	}
+	return files, nil
+}
+
+// Write creates files under repoPath; it refuses to overwrite any existing
+// file, checking them all before writing any
+func Write(repoPath string, files []File) error {
+	for _, f := range files {
+		if _, err := os.Stat(filepath.Join(repoPath, filepath.FromSlash(f.Path))); err == nil {
+			return fmt.Errorf("%s already exists", f.Path)
+		}
+	}
+	for _, f := range files {
+		path := filepath.Join(repoPath, filepath.FromSlash(f.Path))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, []byte(f.Content), 0644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", f.Path, err)
+		}
+	}
+	return nil
+}
+
+func checkClusters(clusters []string) error {
+	if len(clusters) == 0 {
+		return fmt.Errorf("at least one cluster is required")
+	}
+	return checkNames("cluster", clusters...)
+}
+
+// checkNames rejects names that aren't DNS labels, and duplicates
+func checkNames(what string, names ...string) error {
+	seen := make(map[string]bool)
+	for _, name := range names {
+		if !namePattern.MatchString(name) {
+			return fmt.Errorf("invalid %s name %q: must be lowercase letters, digits, and dashes", what, name)
+		}
+		if seen[name] {
+			return fmt.Errorf("duplicate %s %q", what, name)
+		}
+		seen[name] = true
+	}
+	return nil
+}
+
+func render(tmpl string, data interface{}) (string, error) {
+	t, err := template.New("").Parse(tmpl)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+type overlayData struct {
+	Namespace string
+	Base      string
+}
+
+type applicationData struct {
+	Name, Project, RepoURL, Path, Cluster, Namespace string
+}
+
+const baseTemplate = `apiVersion: kustomize.config.k8s.io/v1beta1
+kind: Kustomization
+{{- if .}}
+resources:
+{{- range .}}
+  - {{.}}
+{{- end}}
+{{- else}}
+resources: []
+{{- end}}
+`
+
+const overlayTemplate = `apiVersion: kustomize.config.k8s.io/v1beta1
+kind: Kustomization
+{{- with .Namespace}}
+namespace: {{.}}
+{{- end}}
+resources:
+  - {{.Base}}
+`
+
+const deploymentTemplate = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: {{.Name}}
+  labels:
+    app: {{.Name}}
+spec:
+  replicas: 1
+  selector:
+    matchLabels:
+      app: {{.Name}}
+  template:
+    metadata:
+      labels:
+        app: {{.Name}}
+    spec:
+      containers:
+        - name: {{.Name}}
+          image: {{.Image}}
+          ports:
+            - containerPort: {{.Port}}
+          resources:
+            requests:
+              cpu: 10m
+              memory: 64Mi
+            limits:
+              memory: 128Mi
+`
+
+const serviceTemplate = `apiVersion: v1
+kind: Service
+metadata:
+  name: {{.Name}}
+spec:
+  selector:
+    app: {{.Name}}
+  ports:
+    - port: {{.Port}}
+      targetPort: {{.Port}}
+`
+
+const applicationTemplate = `{{- range $i, $app := .}}{{if $i}}---
+{{end}}apiVersion: argoproj.io/v1alpha1
+kind: Application
+metadata:
+  name: {{$app.Name}}
+  namespace: argocd
+spec:
+  project: {{$app.Project}}
+  source:
+    repoURL: {{$app.RepoURL}}
+    targetRevision: HEAD
+    path: {{$app.Path}}
+  destination:
+    name: {{$app.Cluster}}
+    namespace: {{$app.Namespace}}
+  syncPolicy:
+    automated:
+      prune: true
+      selfHeal: true
+{{end}}`
+
+// RepoURL guesses the repoURL for a new Application: the one most existing
+// Applications deploying apps/ paths use, else the checkout's origin remote
+func RepoURL(repoPath string) string {
+	counts := make(map[string]int)
+	best := ""
+	if apps, _, err := argocd.LoadApplications(repoPath); err == nil {
+		for _, app := range apps {
+			for _, source := range app.AllSources() {
+				if source.RepoURL == "" || !strings.HasPrefix(strings.TrimPrefix(source.Path, "./"), "apps/") {
+					continue
+				}
+				counts[source.RepoURL]++
+				if counts[source.RepoURL] > counts[best] || (counts[source.RepoURL] == counts[best] && source.RepoURL < best) {
+					best = source.RepoURL
+				}
+			}
+		}
+	}
+	if best != "" {
+		return best
+	}
+	out, err := command.Command("git", "-C", repoPath, "config", "--get", "remote.origin.url").Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
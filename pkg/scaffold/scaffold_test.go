@@ -0,0 +1,137 @@
+package scaffold_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/erauner/homelab-shadow/pkg/scaffold"
+	"github.com/erauner/homelab-shadow/pkg/validate"
+)
+
+func newRepo(t *testing.T) string {
+	t.Helper()
+	repo := t.TempDir()
+	if err := os.WriteFile(filepath.Join(repo, "clusters.yaml"), []byte("clusters: [erauner-home, erauner-cloud]\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return repo
+}
+
+func TestApp_PassesValidation(t *testing.T) {
+	repo := newRepo(t)
+	files, err := scaffold.App(scaffold.AppOptions{
+		Name:        "web",
+		Clusters:    []string{"erauner-home", "erauner-cloud"},
+		Envs:        []string{"production", "staging"},
+		Image:       "nginx:1.27.3",
+		Application: true,
+		RepoURL:     "https://github.com/erauner/homelab-k8s.git",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 3+4+1 {
+		t.Errorf("got %d files, want base (3), 4 overlays, and the Applications", len(files))
+	}
+	if err := scaffold.Write(repo, files); err != nil {
+		t.Fatal(err)
+	}
+
+	clusters := []string{"erauner-home", "erauner-cloud"}
+	v := validate.NewClusterValidator(repo, false)
+	var results []validate.Result
+	results = append(results, v.ValidateAppOverlayStructure(clusters)...)
+	results = append(results, v.ValidateArgoCDAppPaths(clusters)...)
+	results = append(results, v.ValidateArgoCDSourcePaths(false)...)
+	results = append(results, v.ValidateCreateNamespace()...)
+	results = append(results, v.ValidateOrphans()...)
+	for _, r := range results {
+		t.Errorf("generated app has finding: %s %s: %s", r.Rule, r.Path, r.Message)
+	}
+
+	overlay, _ := os.ReadFile(filepath.Join(repo, "apps/web/overlays/erauner-cloud/staging/kustomization.yaml"))
+	if !strings.Contains(string(overlay), "namespace: web\nresources:\n  - ../../../base\n") {
+		t.Errorf("overlay =\n%s", overlay)
+	}
+	apps, _ := os.ReadFile(filepath.Join(repo, "argocd-apps/applications/web.yaml"))
+	if n := strings.Count(string(apps), "kind: Application\n"); n != 4 {
+		t.Errorf("generated %d Applications, want 4:\n%s", n, apps)
+	}
+	if !strings.Contains(string(apps), "name: web-erauner-cloud-staging\n") || !strings.Contains(string(apps), "    name: erauner-cloud\n") {
+		t.Errorf("Applications =\n%s", apps)
+	}
+
+	// A second run would overwrite the app
+	if err := scaffold.Write(repo, files); err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Errorf("Write() over an existing app = %v", err)
+	}
+}
+
+func TestApp_Defaults(t *testing.T) {
+	files, err := scaffold.App(scaffold.AppOptions{Name: "api", Clusters: []string{"erauner-home"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var paths []string
+	for _, f := range files {
+		paths = append(paths, f.Path)
+	}
+	want := "apps/api/base/kustomization.yaml apps/api/overlays/erauner-home/production/kustomization.yaml"
+	if got := strings.Join(paths, " "); got != want {
+		t.Errorf("files = %s, want %s", got, want)
+	}
+	if files[0].Content != "apiVersion: kustomize.config.k8s.io/v1beta1\nkind: Kustomization\nresources: []\n" {
+		t.Errorf("empty base =\n%s", files[0].Content)
+	}
+}
+
+func TestApp_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		opts scaffold.AppOptions
+		want string
+	}{
+		{"bad name", scaffold.AppOptions{Name: "My_App", Clusters: []string{"home"}}, "invalid app name"},
+		{"no clusters", scaffold.AppOptions{Name: "web"}, "at least one cluster"},
+		{"duplicate env", scaffold.AppOptions{Name: "web", Clusters: []string{"home"}, Envs: []string{"prod", "prod"}}, `duplicate env "prod"`},
+		{"application without repoURL", scaffold.AppOptions{Name: "web", Clusters: []string{"home"}, Application: true}, "repoURL is required"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := scaffold.App(tt.opts); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("App() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestComponent_PassesValidation(t *testing.T) {
+	repo := newRepo(t)
+	files, err := scaffold.Component(scaffold.ComponentOptions{
+		Root:      "operators",
+		Name:      "cert-manager",
+		Clusters:  []string{"erauner-home", "erauner-cloud"},
+		Namespace: "cert-manager",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := scaffold.Write(repo, files); err != nil {
+		t.Fatal(err)
+	}
+
+	v := validate.NewClusterValidator(repo, false)
+	for _, r := range v.ValidateInfrastructure([]string{"erauner-home", "erauner-cloud"}) {
+		t.Errorf("generated component has finding: %s %s: %s", r.Rule, r.Path, r.Message)
+	}
+	overlay, _ := os.ReadFile(filepath.Join(repo, "operators/cert-manager/overlays/erauner-home/kustomization.yaml"))
+	if !strings.Contains(string(overlay), "resources:\n  - ../../base\n") {
+		t.Errorf("overlay =\n%s", overlay)
+	}
+
+	if _, err := scaffold.Component(scaffold.ComponentOptions{Root: "apps", Name: "x", Clusters: []string{"home"}}); err == nil {
+		t.Error("Component() under an unknown root should fail")
+	}
+}
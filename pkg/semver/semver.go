@@ -0,0 +1,179 @@
+// Package semver parses tool versions and the version constraints .shadow.yaml
+// pins tools to
+//
+// Only major.minor.patch is compared; pre-release and build suffixes
+// (v3.14.0+g3fc9f4b) are ignored, since tools report them inconsistently.
+package semver
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Version is a major.minor.patch version
+type Version [3]int
+
+// String formats v as major.minor.patch
+func (v Version) String() string {
+	return fmt.Sprintf("%d.%d.%d", v[0], v[1], v[2])
+}
+
+// Compare returns -1, 0, or 1 as v is older than, equal to, or newer than o
+func (v Version) Compare(o Version) int {
+	for i := range v {
+		if v[i] != o[i] {
+			if v[i] < o[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+// Parse parses "5.4.3", "v5.4.3", or a partial "5.4" (5.4.0)
+func Parse(s string) (Version, error) {
+	v, n, err := parsePrefix(s)
+	if err != nil {
+		return Version{}, err
+	}
+	if n == 0 {
+		return Version{}, fmt.Errorf("invalid version %q", s)
+	}
+	return v, nil
+}
+
+// parsePrefix parses up to three numeric components, stopping at an "x" or
+// "*" wildcard, and returns how many components were given
+func parsePrefix(s string) (Version, int, error) {
+	var v Version
+	fields := strings.Split(strings.TrimPrefix(strings.TrimSpace(s), "v"), ".")
+	if len(fields) > 3 {
+		return v, 0, fmt.Errorf("invalid version %q", s)
+	}
+	for i, f := range fields {
+		if f == "x" || f == "X" || f == "*" {
+			if i < len(fields)-1 {
+				return v, 0, fmt.Errorf("invalid version %q (wildcards must come last)", s)
+			}
+			return v, i, nil
+		}
+		n, err := strconv.Atoi(f)
+		if err != nil || n < 0 {
+			return v, 0, fmt.Errorf("invalid version %q", s)
+		}
+		v[i] = n
+	}
+	return v, len(fields), nil
+}
+
+// versionPattern matches the first dotted version in a tool's version output
+var versionPattern = regexp.MustCompile(`(\d+)\.(\d+)(?:\.(\d+))?`)
+
+// Extract finds the version in a tool's version output, such as "v5.4.3",
+// "v3.14.0+g3fc9f4b", "git version 2.43.0", or "Version: 1.12.0"
+func Extract(output string) (Version, bool) {
+	m := versionPattern.FindString(output)
+	if m == "" {
+		return Version{}, false
+	}
+	v, err := Parse(m)
+	return v, err == nil
+}
+
+// Constraint is a version range: space- or comma-separated terms that must
+// all hold, each an operator (=, !=, >, >=, <, <=) and a version, or a bare
+// version. Partial versions and wildcards compare only the components given:
+// "5.4", "5.4.x", and "=5.4" all allow any 5.4 release
+type Constraint struct {
+	raw   string
+	terms []term
+}
+
+// term is one comparison of a Constraint
+type term struct {
+	op    string
+	v     Version
+	exact int // components given; fewer than 3 compares a prefix
+}
+
+// ParseConstraint parses a constraint such as ">=5.3.0 <5.5.0" or "3.14.x"
+func ParseConstraint(s string) (Constraint, error) {
+	c := Constraint{raw: strings.TrimSpace(s)}
+	fields := strings.FieldsFunc(s, func(r rune) bool { return r == ' ' || r == ',' })
+	if len(fields) == 0 {
+		return c, fmt.Errorf("empty version constraint")
+	}
+	for _, f := range fields {
+		op := "="
+		for _, candidate := range []string{">=", "<=", "!=", ">", "<", "="} {
+			if strings.HasPrefix(f, candidate) {
+				op = candidate
+				f = f[len(candidate):]
+				break
+			}
+		}
+		v, n, err := parsePrefix(f)
+		if err != nil {
+			return c, fmt.Errorf("invalid version constraint %q: %w", s, err)
+		}
+		if n == 0 && op != "=" {
+			return c, fmt.Errorf("invalid version constraint %q: %s needs a version", s, op)
+		}
+		c.terms = append(c.terms, term{op: op, v: v, exact: n})
+	}
+	return c, nil
+}
+
+// Allows reports whether v satisfies every term of c
+func (c Constraint) Allows(v Version) bool {
+	for _, t := range c.terms {
+		if !t.allows(v) {
+			return false
+		}
+	}
+	return true
+}
+
+// allows compares v with the term's version on the components given, so
+// "=5.4" matches 5.4.2 and "<=5.4" allows every 5.4 release
+func (t term) allows(v Version) bool {
+	cmp := 0
+	for i := 0; i < t.exact && cmp == 0; i++ {
+		switch {
+		case v[i] < t.v[i]:
+			cmp = -1
+		case v[i] > t.v[i]:
+			cmp = 1
+		}
+	}
+	switch t.op {
+	case "=":
+		return cmp == 0
+	case "!=":
+		return cmp != 0
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	case "<":
+		return cmp < 0
+	default: // <=
+		return cmp <= 0
+	}
+}
+
+// String returns the constraint as written
+func (c Constraint) String() string {
+	return c.raw
+}
+
+// Exact returns the single version c allows, when it pins one ("5.4.3" or "=5.4.3")
+func (c Constraint) Exact() (Version, bool) {
+	if len(c.terms) != 1 || c.terms[0].op != "=" || c.terms[0].exact != 3 {
+		return Version{}, false
+	}
+	return c.terms[0].v, true
+}
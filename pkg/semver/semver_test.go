@@ -0,0 +1,102 @@
+package semver
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	tests := map[string]string{
+		"5.4.3":  "5.4.3",
+		"v5.4.3": "5.4.3",
+		"5.4":    "5.4.0",
+		"2":      "2.0.0",
+	}
+	for in, want := range tests {
+		v, err := Parse(in)
+		if err != nil || v.String() != want {
+			t.Errorf("Parse(%q) = %s, %v; want %s", in, v, err, want)
+		}
+	}
+	for _, in := range []string{"", "latest", "5.4.3.1", "5.x.3", "-1.0"} {
+		if _, err := Parse(in); err == nil {
+			t.Errorf("Parse(%q) should fail", in)
+		}
+	}
+}
+
+func TestExtract(t *testing.T) {
+	tests := map[string]string{
+		"v5.4.3":                          "5.4.3",
+		"v3.14.0+g3fc9f4b":                "3.14.0",
+		"git version 2.43.0":              "2.43.0",
+		"Version: 1.12.0\nTime: 2024-...": "1.12.0",
+		"v0.6":                            "0.6.0",
+		"{Version:kustomize/v5.0.1 GitCommit:...}": "5.0.1",
+	}
+	for in, want := range tests {
+		if v, ok := Extract(in); !ok || v.String() != want {
+			t.Errorf("Extract(%q) = %s, %v; want %s", in, v, ok, want)
+		}
+	}
+	if _, ok := Extract("unknown"); ok {
+		t.Error("Extract(unknown) should fail")
+	}
+}
+
+func TestConstraint_Allows(t *testing.T) {
+	tests := []struct {
+		constraint string
+		version    string
+		want       bool
+	}{
+		{"5.4.3", "5.4.3", true},
+		{"5.4.3", "5.4.4", false},
+		{"5.4", "5.4.9", true},
+		{"5.4.x", "5.5.0", false},
+		{"=5.4", "5.4.0", true},
+		{"!=5.4.2", "5.4.2", false},
+		{"!=5.4.2", "5.4.3", true},
+		{">=5.3.0 <5.5.0", "5.4.3", true},
+		{">=5.3.0 <5.5.0", "5.5.0", false},
+		{">=5.3.0, <5.5.0", "5.2.9", false},
+		{"<=5.4", "5.4.9", true},
+		{">5.4", "5.4.9", false},
+		{">5.4", "5.5.0", true},
+		{"*", "1.2.3", true},
+	}
+	for _, tt := range tests {
+		c, err := ParseConstraint(tt.constraint)
+		if err != nil {
+			t.Fatalf("ParseConstraint(%q) error = %v", tt.constraint, err)
+		}
+		v, _ := Parse(tt.version)
+		if got := c.Allows(v); got != tt.want {
+			t.Errorf("%q.Allows(%s) = %v, want %v", tt.constraint, tt.version, got, tt.want)
+		}
+	}
+
+	for _, bad := range []string{"", ">=", ">=five", "~5.4", "5.*.1"} {
+		if _, err := ParseConstraint(bad); err == nil {
+			t.Errorf("ParseConstraint(%q) should fail", bad)
+		}
+	}
+}
+
+func TestConstraint_Exact(t *testing.T) {
+	tests := map[string]string{
+		"5.4.3":          "5.4.3",
+		"=v3.14.4":       "3.14.4",
+		"5.4":            "",
+		"5.4.x":          "",
+		">=5.4.3":        "",
+		">=5.3.0 <5.5.0": "",
+	}
+	for in, want := range tests {
+		c, err := ParseConstraint(in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		v, ok := c.Exact()
+		if got := map[bool]string{true: v.String()}[ok]; got != want {
+			t.Errorf("%q.Exact() = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,12 @@
+// Package shadow is the programmatic API of the shadow CLI
+//
+// Tools that embed shadow (an operator, PR bots) should call Validate, Sync,
+// and Cleanup instead of running the CLI and parsing its output. The functions
+// and types in this package follow the module's semantic version: within a
+// major version, options and result fields are only added, never removed or
+// changed in meaning, and zero-valued options keep their current defaults.
+//
+// Result types are shared with pkg/validate and pkg/sync and marshal to the
+// same JSON as the CLI's --output json. Progress is logged to Options.Log (or
+// the shared logger in pkg/log); nothing is written to stdout.
+package shadow
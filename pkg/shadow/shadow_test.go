@@ -0,0 +1,174 @@
+package shadow
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/erauner/homelab-shadow/pkg/config"
+	"github.com/erauner/homelab-shadow/pkg/log"
+	"github.com/erauner/homelab-shadow/pkg/sync"
+	"github.com/erauner/homelab-shadow/pkg/validate"
+	"github.com/erauner/homelab-shadow/pkg/validate/validatetest"
+)
+
+// quiet discards progress logging
+var quiet = log.New(io.Discard, log.LevelError, log.FormatText)
+
+func TestValidate(t *testing.T) {
+	repo := validatetest.Build(t, validatetest.Repo{Clusters: []string{"erauner-home", "erauner-cloud"}})
+
+	result, err := Validate(context.Background(), ValidateOptions{RepoPath: repo, Cluster: "erauner-home", Log: quiet})
+	if err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	if want := []string{"erauner-home"}; !reflect.DeepEqual(result.Clusters, want) {
+		t.Errorf("Clusters = %v, want %v", result.Clusters, want)
+	}
+	validatetest.AssertContains(t, result.Findings,
+		validatetest.Finding{Cluster: "erauner-home", Rule: "cluster-missing-dir", Path: "bootstrap"})
+	if !result.Failed(false) || result.Errors() == 0 {
+		t.Errorf("Failed() = false with %d error(s), want a failed run", result.Errors())
+	}
+}
+
+func TestValidate_Rules(t *testing.T) {
+	repo := validatetest.Build(t, validatetest.Repo{
+		Clusters: []string{"erauner-home"},
+		Kustomizations: map[string]validatetest.Kustomization{
+			"apps/coder/overlays/production": {Resources: []string{"../../base"}},
+		},
+	})
+
+	result, err := Validate(context.Background(), ValidateOptions{
+		RepoPath: repo,
+		Rules:    validate.RuleSelector{Only: []string{"app-overlay-*", "cluster-*"}, Skip: []string{"cluster-missing-bootstrap-file"}},
+		Log:      quiet,
+	})
+	if err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	validatetest.AssertContains(t, result.Findings,
+		validatetest.Finding{Rule: "app-overlay-legacy-flat", Path: "apps/coder/overlays/production"},
+		validatetest.Finding{Cluster: "erauner-home", Rule: "cluster-missing-dir", Path: "bootstrap"})
+	validatetest.AssertNoRule(t, result.Findings, "cluster-missing-bootstrap-file", validate.RuleOrphanOverlay)
+
+	if _, err := Validate(context.Background(), ValidateOptions{RepoPath: repo, Rules: validate.RuleSelector{Only: []string{"no-such-*"}}, Log: quiet}); err == nil {
+		t.Error("expected error for a pattern matching no rule")
+	}
+}
+
+// labelRule is a plugin rule reporting one finding
+type labelRule struct{}
+
+func (labelRule) Name() string     { return "homelab-labels" }
+func (labelRule) Severity() string { return "warn" }
+func (labelRule) Run(ctx validate.RepoContext) []validate.Result {
+	return []validate.Result{{Path: "apps/coder", Message: "missing team label in " + ctx.Clusters[0]}}
+}
+
+func TestValidate_Plugins(t *testing.T) {
+	repo := validatetest.Build(t, validatetest.Repo{Clusters: []string{"erauner-home"}})
+
+	result, err := Validate(context.Background(), ValidateOptions{
+		RepoPath: repo,
+		Plugins:  []validate.Rule{labelRule{}},
+		Rules:    validate.RuleSelector{Only: []string{"homelab-*"}},
+		Log:      quiet,
+	})
+	if err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	validatetest.AssertFindings(t, result.Findings,
+		validatetest.Finding{Cluster: "global", Rule: "homelab-labels", Path: "apps/coder", Severity: "warn"})
+}
+
+func TestValidate_Errors(t *testing.T) {
+	repo := validatetest.Build(t, validatetest.Repo{Clusters: []string{"erauner-home"}})
+
+	if _, err := Validate(context.Background(), ValidateOptions{}); err == nil {
+		t.Error("expected error without RepoPath")
+	}
+	if _, err := Validate(context.Background(), ValidateOptions{RepoPath: repo, Cluster: "nope", Log: quiet}); err == nil {
+		t.Error("expected error for unknown cluster")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := Validate(ctx, ValidateOptions{RepoPath: repo, Log: quiet}); !errors.Is(err, context.Canceled) {
+		t.Errorf("Validate(canceled) error = %v, want context.Canceled", err)
+	}
+}
+
+// manifestRenderer renders one canned manifest per target directory
+type manifestRenderer map[string]string
+
+func (r manifestRenderer) Name() string   { return "fixed" }
+func (r manifestRenderer) Source() string { return config.OutputSourceKustomize }
+
+func (r manifestRenderer) Discover() ([]sync.Target, error) {
+	var targets []sync.Target
+	for dir := range r {
+		targets = append(targets, sync.Target{Dir: dir})
+	}
+	return targets, nil
+}
+
+func (r manifestRenderer) Render(ctx context.Context, target sync.Target) (sync.Manifest, sync.Meta, error) {
+	return sync.Manifest(r[target.Dir]), sync.Meta{}, nil
+}
+
+func TestSync_DryRun(t *testing.T) {
+	out := t.TempDir()
+	opts := SyncOptions{
+		RepoPath:  t.TempDir(),
+		DryRun:    true,
+		OutDir:    out,
+		Renderers: []sync.Renderer{manifestRenderer{"apps/web/overlays/production": "kind: Deployment\n"}},
+		Log:       quiet,
+	}
+
+	result, err := Sync(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("Sync() error = %v", err)
+	}
+	if result.RenderedDirs != 1 {
+		t.Errorf("RenderedDirs = %d, want 1", result.RenderedDirs)
+	}
+	if _, err := os.Stat(filepath.Join(out, "apps/web/overlays/production/manifest.yaml")); err != nil {
+		t.Errorf("manifest not written: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := Sync(ctx, opts); !errors.Is(err, context.Canceled) {
+		t.Errorf("Sync(canceled) error = %v, want context.Canceled", err)
+	}
+}
+
+func TestSync_ZeroOptionsRedact(t *testing.T) {
+	out := t.TempDir()
+	secret := "apiVersion: v1\nkind: Secret\nmetadata:\n  name: db\ndata:\n  password: aHVudGVyMg==\n"
+	_, err := Sync(context.Background(), SyncOptions{
+		RepoPath:  t.TempDir(),
+		DryRun:    true,
+		OutDir:    out,
+		Renderers: []sync.Renderer{manifestRenderer{"apps/db/overlays/production": secret}},
+		Log:       quiet,
+	})
+	if err != nil {
+		t.Fatalf("Sync() error = %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(out, "apps/db/overlays/production/manifest.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "aHVudGVyMg==") || !strings.Contains(string(data), "REDACTED") {
+		t.Errorf("zero-valued options published Secret data:\n%s", data)
+	}
+}
@@ -0,0 +1,39 @@
+package shadow
+
+import (
+	"context"
+
+	"github.com/erauner/homelab-shadow/pkg/sync"
+)
+
+// SyncOptions configures Sync (see sync.Options)
+type SyncOptions = sync.Options
+
+// SyncResult contains the outcome of Sync (see sync.Result)
+type SyncResult = sync.Result
+
+// CleanupOptions configures Cleanup (see sync.CleanupOptions)
+type CleanupOptions = sync.CleanupOptions
+
+// CleanupResult contains the outcome of Cleanup (see sync.CleanupResult)
+type CleanupResult = sync.CleanupResult
+
+// Sync renders the repository and publishes the manifests to the shadow repo
+// branch (or, with DryRun, into OutDir), like shadow sync. It stops before the
+// next render, commit, or push once ctx is done.
+func Sync(ctx context.Context, opts SyncOptions) (SyncResult, error) {
+	syncer, err := sync.New(opts)
+	if err != nil {
+		return SyncResult{}, err
+	}
+	return syncer.RunContext(ctx)
+}
+
+// Cleanup deletes stale pr-* (and, with MaxAge, local-*) branches from a
+// shadow repo without cloning it, like shadow cleanup
+func Cleanup(ctx context.Context, opts CleanupOptions) (CleanupResult, error) {
+	if err := ctx.Err(); err != nil {
+		return CleanupResult{}, err
+	}
+	return sync.CleanupShadowRepo(ctx, opts)
+}
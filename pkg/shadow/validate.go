@@ -0,0 +1,210 @@
+package shadow
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/erauner/homelab-shadow/pkg/config"
+	"github.com/erauner/homelab-shadow/pkg/log"
+	"github.com/erauner/homelab-shadow/pkg/progress"
+	"github.com/erauner/homelab-shadow/pkg/validate"
+)
+
+// Finding is a single validation result
+type Finding = validate.Result
+
+// ValidateOptions configures Validate
+type ValidateOptions struct {
+	// RepoPath is the homelab-k8s checkout to validate (required)
+	RepoPath string
+
+	// Cluster validates only this registered cluster (default: all)
+	Cluster string
+
+	// BuildAppPaths also kustomize builds every ArgoCD Application source path
+	BuildAppPaths bool
+
+	// KubernetesVersion, when set, renders every Application source path and
+	// flags APIs removed (api-removed) or deprecated (api-deprecated) in it
+	KubernetesVersion string
+
+	// Config supplies severity overrides, ignores, and owners (default: <RepoPath>/.shadow.yaml)
+	Config *config.Config
+
+	// Plugins are rules loaded with validate.LoadPlugin, run after the
+	// registered rules
+	Plugins []validate.Rule
+
+	// Rules selects the rules to run (default: all); checks none of whose
+	// rules are selected are skipped
+	Rules validate.RuleSelector
+
+	// Baseline is a baseline file written by validate --write-baseline; findings
+	// recorded in it are reported as suppressed
+	Baseline string
+
+	// Progress reports each cluster and check with an ETA on stderr instead
+	// of logging them (see progress.Reporter)
+	Progress bool
+
+	Verbose bool
+	Log     *log.Logger // default: the shared logger
+}
+
+// ValidateResult contains the findings of a validation run
+type ValidateResult struct {
+	// Clusters are the clusters that were validated
+	Clusters []string `json:"clusters"`
+
+	// Findings includes suppressed findings (see Finding.Suppressed)
+	Findings []Finding `json:"findings"`
+
+	// BaselineFixed counts baselined findings that no longer occur
+	BaselineFixed int `json:"baseline_fixed,omitempty"`
+}
+
+// Errors counts unsuppressed error findings
+func (r ValidateResult) Errors() int {
+	return validate.CountErrors(r.Findings)
+}
+
+// Warnings counts unsuppressed warning findings
+func (r ValidateResult) Warnings() int {
+	return validate.CountWarnings(r.Findings)
+}
+
+// Failed reports whether the run fails: any error, or with strict any warning
+func (r ValidateResult) Failed(strict bool) bool {
+	return r.Errors() > 0 || (strict && r.Warnings() > 0)
+}
+
+// Validate checks a repository's multi-cluster GitOps layout, the same checks
+// as shadow validate, then applies severity overrides, suppressions, owners,
+// and the baseline. ctx is checked between checks.
+func Validate(ctx context.Context, opts ValidateOptions) (ValidateResult, error) {
+	result := ValidateResult{Findings: []Finding{}}
+	if opts.RepoPath == "" {
+		return result, fmt.Errorf("RepoPath is required")
+	}
+	if opts.KubernetesVersion != "" {
+		if _, err := validate.KubeMinor(opts.KubernetesVersion); err != nil {
+			return result, err
+		}
+	}
+
+	cfg := opts.Config
+	if cfg == nil {
+		var err error
+		if cfg, err = config.Load(opts.RepoPath); err != nil {
+			return result, err
+		}
+	}
+
+	// Built-in rules, then CEL rules from the config, then plugins
+	celRules, err := validate.CELRules(cfg)
+	if err != nil {
+		return result, err
+	}
+	rules := append(append(validate.Registered(), celRules...), opts.Plugins...)
+	if err := opts.Rules.Check(validate.RuleNames(rules)); err != nil {
+		return result, err
+	}
+
+	logger := opts.Log
+	if logger == nil {
+		logger = log.Default()
+	}
+	validator := validate.NewClusterValidator(opts.RepoPath, opts.Verbose)
+	validator.Log = logger.Named("shadow").Verbose(opts.Verbose)
+
+	clusters, err := validator.DiscoverClusters()
+	if err != nil {
+		return result, fmt.Errorf("failed to discover clusters: %w", err)
+	}
+	if len(clusters) == 0 {
+		return result, fmt.Errorf("no clusters found in %s/clusters.yaml or %s/clusters/", opts.RepoPath, opts.RepoPath)
+	}
+	logger.Infof("Discovered %d cluster(s): %s", len(clusters), strings.Join(clusters, ", "))
+
+	if opts.Cluster != "" {
+		if !slices.Contains(clusters, opts.Cluster) {
+			return result, fmt.Errorf("cluster %q not found (available: %s)", opts.Cluster, strings.Join(clusters, ", "))
+		}
+		clusters = []string{opts.Cluster}
+	}
+	result.Clusters = clusters
+
+	// Each run of a rule: per-cluster rules once for each cluster
+	type ruleRun struct {
+		name string
+		rule validate.Rule
+		ctx  validate.RepoContext
+	}
+	repo := validate.RepoContext{
+		Validator:         validator,
+		RepoPath:          opts.RepoPath,
+		Clusters:          clusters,
+		BuildAppPaths:     opts.BuildAppPaths,
+		KubernetesVersion: opts.KubernetesVersion,
+	}
+	var runs []ruleRun
+	for _, rule := range rules {
+		if !opts.Rules.SelectsAny(validate.NamesOf(rule)) {
+			logger.Debugf("Skipping %s (no selected rules)", rule.Name())
+			continue
+		}
+		if !validate.PerCluster(rule) {
+			runs = append(runs, ruleRun{rule.Name(), rule, repo})
+			continue
+		}
+		for _, cluster := range clusters {
+			clusterRepo := repo
+			clusterRepo.Clusters = []string{cluster}
+			runs = append(runs, ruleRun{rule.Name() + " " + cluster, rule, clusterRepo})
+		}
+	}
+
+	logStep := logger.Infof
+	var report *progress.Reporter
+	if opts.Progress {
+		logStep = logger.Debugf
+		report = progress.New("validate", len(runs))
+		defer report.Finish()
+	}
+
+	for _, run := range runs {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+		logStep("Validating %s...", run.name)
+		report.Start(run.name)
+		findings := opts.Rules.Filter(validate.RunRule(run.rule, run.ctx))
+		report.Done(validate.CountErrors(findings) == 0)
+		result.Findings = append(result.Findings, findings...)
+	}
+	report.Finish()
+
+	// Apply per-rule severity overrides and suppressions, then route findings to owning teams
+	result.Findings = validate.ApplySeverities(result.Findings, cfg)
+	if result.Findings, err = validate.ApplyStrictness(result.Findings, cfg, opts.RepoPath); err != nil {
+		logger.Warnf("Progressive strictness not applied: %v", err)
+	}
+	validate.ApplyInlineSuppressions(result.Findings, opts.RepoPath)
+	validate.AssignOwners(result.Findings, cfg)
+
+	if opts.Baseline != "" {
+		baseline, err := validate.LoadBaseline(opts.Baseline)
+		if err != nil {
+			return result, err
+		}
+		// Entries of rules that didn't run aren't fixed
+		baseline.Findings = slices.DeleteFunc(baseline.Findings, func(e validate.BaselineEntry) bool {
+			return !opts.Rules.Selects(e.Rule)
+		})
+		result.BaselineFixed = baseline.Apply(result.Findings, opts.Baseline)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,221 @@
+package sync
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Gates for changes that must be acknowledged on the PR before sync publishes them
+const (
+	GateCRDChange         = "crd-change"         // a CustomResourceDefinition is removed or its schema changes
+	GateNamespaceDeletion = "namespace-deletion" // a Namespace is removed
+)
+
+// AckLabelPrefix prefixes PR labels that acknowledge a gate (shadow-ack/crd-change)
+const AckLabelPrefix = "shadow-ack/"
+
+// ackCommand starts PR comment lines that acknowledge gates (/shadow ack crd-change)
+const ackCommand = "/shadow ack"
+
+// githubAPIURL is the GitHub REST API base URL (overridden in tests)
+var githubAPIURL = "https://api.github.com"
+
+// apiClient calls provider APIs; the timeout keeps a hung API from stalling sync
+var apiClient = &http.Client{Timeout: 30 * time.Second}
+
+// trustedAssociations are comment author associations allowed to acknowledge gates
+var trustedAssociations = map[string]bool{
+	"OWNER":        true,
+	"MEMBER":       true,
+	"COLLABORATOR": true,
+}
+
+// Ack is an acknowledgment of a gate read from the source PR
+type Ack struct {
+	Gate   string `json:"gate"`
+	Source string `json:"source"` // "comment" or "label"
+	By     string `json:"by,omitempty"`
+}
+
+// GatedChange is a changed resource that needs an acknowledgment
+type GatedChange struct {
+	Gate         string `json:"gate"`
+	Resource     string `json:"resource"`
+	File         string `json:"file"`
+	Acknowledged bool   `json:"acknowledged"`
+}
+
+// GatedChanges returns the gated resource changes in a change summary, marking
+// those whose gate was acknowledged
+func GatedChanges(changes *ChangeSummary, acks []Ack) []GatedChange {
+	if changes == nil {
+		return nil
+	}
+	acked := make(map[string]bool)
+	for _, a := range acks {
+		acked[a.Gate] = true
+	}
+
+	var gated []GatedChange
+	for _, r := range changes.Resources {
+		gate := gateFor(r)
+		if gate == "" {
+			continue
+		}
+		gated = append(gated, GatedChange{Gate: gate, Resource: r.Resource, File: r.File, Acknowledged: acked[gate]})
+	}
+	return gated
+}
+
+// gateFor returns the gate a resource change falls under, or ""
+func gateFor(r ResourceChange) string {
+	kind, _, _ := strings.Cut(r.Resource, "/")
+	for _, category := range r.Categories {
+		switch {
+		case kind == "CustomResourceDefinition" && (category == CategoryRemoved || category == CategoryCRDSchema):
+			return GateCRDChange
+		case kind == "Namespace" && category == CategoryRemoved:
+			return GateNamespaceDeletion
+		}
+	}
+	return ""
+}
+
+// ParseAckComment returns the gates acknowledged by "/shadow ack <gate>..." lines in a comment
+func ParseAckComment(body string) []string {
+	var gates []string
+	for _, line := range strings.Split(body, "\n") {
+		rest, ok := strings.CutPrefix(strings.TrimSpace(line), ackCommand+" ")
+		if !ok {
+			continue
+		}
+		gates = append(gates, strings.Fields(rest)...)
+	}
+	return gates
+}
+
+// FetchAcks reads gate acknowledgments from a PR's comments and labels via the
+// GitHub API. Only comments by owners, members, and collaborators count
+func FetchAcks(ctx context.Context, repo, prNumber string) ([]Ack, error) {
+	var comments []struct {
+		Body              string `json:"body"`
+		AuthorAssociation string `json:"author_association"`
+		User              struct {
+			Login string `json:"login"`
+		} `json:"user"`
+	}
+	if err := githubGetAll(ctx, fmt.Sprintf("/repos/%s/issues/%s/comments?per_page=100", repo, prNumber), &comments); err != nil {
+		return nil, fmt.Errorf("failed to read PR comments: %w", err)
+	}
+
+	var labels []struct {
+		Name string `json:"name"`
+	}
+	if err := githubGetAll(ctx, fmt.Sprintf("/repos/%s/issues/%s/labels?per_page=100", repo, prNumber), &labels); err != nil {
+		return nil, fmt.Errorf("failed to read PR labels: %w", err)
+	}
+
+	var acks []Ack
+	for _, c := range comments {
+		if !trustedAssociations[c.AuthorAssociation] {
+			continue
+		}
+		for _, gate := range ParseAckComment(c.Body) {
+			acks = append(acks, Ack{Gate: gate, Source: "comment", By: c.User.Login})
+		}
+	}
+	for _, l := range labels {
+		if gate, ok := strings.CutPrefix(l.Name, AckLabelPrefix); ok && gate != "" {
+			acks = append(acks, Ack{Gate: gate, Source: "label"})
+		}
+	}
+	sort.SliceStable(acks, func(i, j int) bool { return acks[i].Gate < acks[j].Gate })
+	return acks, nil
+}
+
+// githubGetAll decodes every page of a GitHub API list into v (a pointer to
+// a slice), following the Link rel="next" URLs
+func githubGetAll(ctx context.Context, path string, v interface{}) error {
+	var items []json.RawMessage
+	for next := githubAPIURL + path; next != ""; {
+		var page []json.RawMessage
+		var err error
+		if next, err = githubGet(ctx, next, &page); err != nil {
+			return err
+		}
+		items = append(items, page...)
+	}
+	data, err := json.Marshal(items)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// githubGet decodes the GitHub API response for url, authenticating with
+// GH_TOKEN when set, and returns the URL of the next page ("" on the last)
+func githubGet(ctx context.Context, url string, v interface{}) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Accept", "application/vnd.github.v3+json")
+	req.Header.Set("User-Agent", "shadow-sync")
+	if token := os.Getenv("GH_TOKEN"); token != "" {
+		req.Header.Set("Authorization", "token "+token)
+	}
+
+	resp, err := apiClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return "", fmt.Errorf("GitHub API returned %d", resp.StatusCode)
+	}
+	return nextPageURL(resp.Header.Get("Link")), json.NewDecoder(resp.Body).Decode(v)
+}
+
+// nextPageURL returns the rel="next" URL of a Link header, or ""
+func nextPageURL(link string) string {
+	for _, part := range strings.Split(link, ",") {
+		target, params, ok := strings.Cut(part, ";")
+		if ok && strings.Contains(params, `rel="next"`) {
+			return strings.Trim(strings.TrimSpace(target), "<>")
+		}
+	}
+	return ""
+}
+
+// unacknowledgedError describes gated changes still missing an acknowledgment
+func unacknowledgedError(gated []GatedChange) error {
+	byGate := make(map[string][]string)
+	var gates []string
+	for _, g := range gated {
+		if g.Acknowledged {
+			continue
+		}
+		if _, ok := byGate[g.Gate]; !ok {
+			gates = append(gates, g.Gate)
+		}
+		byGate[g.Gate] = append(byGate[g.Gate], g.Resource)
+	}
+	if len(gates) == 0 {
+		return nil
+	}
+	sort.Strings(gates)
+
+	var parts []string
+	for _, gate := range gates {
+		parts = append(parts, fmt.Sprintf("%s (%s)", gate, strings.Join(byGate[gate], ", ")))
+	}
+	return fmt.Errorf("unacknowledged gated changes: %s; comment %q on the PR or add the %s%s label",
+		strings.Join(parts, "; "), ackCommand+" "+gates[0], AckLabelPrefix, gates[0])
+}
@@ -0,0 +1,125 @@
+package sync
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseAckComment(t *testing.T) {
+	body := "Looks good.\n/shadow ack crd-change\n  /shadow ack namespace-deletion other\n/shadow acknowledge nope\n"
+	got := ParseAckComment(body)
+	want := []string{"crd-change", "namespace-deletion", "other"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseAckComment = %v, want %v", got, want)
+	}
+}
+
+func TestGatedChanges(t *testing.T) {
+	changes := &ChangeSummary{Resources: []ResourceChange{
+		{File: "a.yaml", Resource: "CustomResourceDefinition/widgets.example.com", Categories: []string{CategoryRemoved}},
+		{File: "a.yaml", Resource: "CustomResourceDefinition/gadgets.example.com", Categories: []string{CategoryCRDSchema}},
+		{File: "a.yaml", Resource: "CustomResourceDefinition/labels.example.com", Categories: []string{CategoryOther}},
+		{File: "b.yaml", Resource: "Namespace/old", Categories: []string{CategoryRemoved}},
+		{File: "b.yaml", Resource: "Namespace/new", Categories: []string{CategoryAdded}},
+		{File: "c.yaml", Resource: "Deployment/apps/web", Categories: []string{CategoryRemoved}},
+	}}
+
+	gated := GatedChanges(changes, []Ack{{Gate: GateCRDChange, Source: "label"}})
+	if len(gated) != 3 {
+		t.Fatalf("gated = %+v, want 3", gated)
+	}
+	for _, g := range gated {
+		if want := g.Gate == GateCRDChange; g.Acknowledged != want {
+			t.Errorf("%s: Acknowledged = %v, want %v", g.Resource, g.Acknowledged, want)
+		}
+	}
+
+	err := unacknowledgedError(gated)
+	if err == nil || !strings.Contains(err.Error(), "namespace-deletion (Namespace/old)") {
+		t.Errorf("unacknowledgedError = %v", err)
+	}
+	if strings.Contains(err.Error(), "widgets") {
+		t.Errorf("acknowledged change reported: %v", err)
+	}
+
+	if err := unacknowledgedError(GatedChanges(changes, []Ack{{Gate: GateCRDChange}, {Gate: GateNamespaceDeletion}})); err != nil {
+		t.Errorf("all acknowledged: %v", err)
+	}
+}
+
+func TestFetchAcks(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/repos/erauner/homelab-k8s/issues/42/comments", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[
+			{"body": "/shadow ack crd-change", "author_association": "MEMBER", "user": {"login": "reviewer"}},
+			{"body": "/shadow ack namespace-deletion", "author_association": "NONE", "user": {"login": "drive-by"}}
+		]`))
+	})
+	mux.HandleFunc("/repos/erauner/homelab-k8s/issues/42/labels", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[{"name": "shadow-ack/namespace-deletion"}, {"name": "bug"}]`))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	orig := githubAPIURL
+	githubAPIURL = server.URL
+	defer func() { githubAPIURL = orig }()
+
+	acks, err := FetchAcks(context.Background(), "erauner/homelab-k8s", "42")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Ack{
+		{Gate: GateCRDChange, Source: "comment", By: "reviewer"},
+		{Gate: GateNamespaceDeletion, Source: "label"},
+	}
+	if !reflect.DeepEqual(acks, want) {
+		t.Errorf("acks = %+v, want %+v", acks, want)
+	}
+
+	if _, err := FetchAcks(context.Background(), "erauner/homelab-k8s", "7"); err == nil {
+		t.Error("expected error for missing PR")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := FetchAcks(ctx, "erauner/homelab-k8s", "42"); err == nil {
+		t.Error("expected error for canceled context")
+	}
+}
+
+// An ack posted after the first page of comments still counts
+func TestFetchAcks_Pages(t *testing.T) {
+	var server *httptest.Server
+	mux := http.NewServeMux()
+	mux.HandleFunc("/repos/erauner/homelab-k8s/issues/42/comments", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("page") != "2" {
+			w.Header().Set("Link", fmt.Sprintf(`<%s/repos/erauner/homelab-k8s/issues/42/comments?per_page=100&page=2>; rel="next", <%s/repos/erauner/homelab-k8s/issues/42/comments?per_page=100&page=2>; rel="last"`, server.URL, server.URL))
+			w.Write([]byte(`[{"body": "looks risky", "author_association": "MEMBER", "user": {"login": "reviewer"}}]`))
+			return
+		}
+		w.Write([]byte(`[{"body": "/shadow ack crd-change", "author_association": "MEMBER", "user": {"login": "reviewer"}}]`))
+	})
+	mux.HandleFunc("/repos/erauner/homelab-k8s/issues/42/labels", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[]`))
+	})
+	server = httptest.NewServer(mux)
+	defer server.Close()
+
+	orig := githubAPIURL
+	githubAPIURL = server.URL
+	defer func() { githubAPIURL = orig }()
+
+	acks, err := FetchAcks(context.Background(), "erauner/homelab-k8s", "42")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []Ack{{Gate: GateCRDChange, Source: "comment", By: "reviewer"}}; !reflect.DeepEqual(acks, want) {
+		t.Errorf("acks = %+v, want %+v", acks, want)
+	}
+}
@@ -0,0 +1,110 @@
+package sync
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/erauner/homelab-shadow/pkg/github"
+)
+
+// CheckRunName is the GitHub check run sync reports on the source commit
+const CheckRunName = "shadow/diff"
+
+// annotatedFiles are the files of a rendered directory failures are pinned
+// to, in order of preference
+var annotatedFiles = []string{"kustomization.yaml", "kustomization.yml", "Kustomization", "values.yaml", "Chart.yaml"}
+
+// CheckRun builds the check run reporting the result on the source commit
+// headSHA: the markdown summary, failures (and deprecated APIs, as warnings)
+// annotated on the kustomization or values file of their directory in
+// repoPath, and the compare URL as details. Any failure fails the check; an
+// exceeded budget or deprecated APIs make it neutral
+func (r Result) CheckRun(repoPath, headSHA string) github.CheckRun {
+	failed := len(r.Failures)
+	conclusion := github.ConclusionSuccess
+	switch {
+	case failed > 0:
+		conclusion = github.ConclusionFailure
+	case r.BudgetExceeded || len(r.DeprecatedAPIs) > 0:
+		conclusion = github.ConclusionNeutral
+	}
+
+	title := fmt.Sprintf("%d rendered, %d failed", r.RenderedDirs+r.HelmAppsRendered, failed)
+	if r.Changes != nil && len(r.Changes.Resources) > 0 {
+		title += ": " + r.Changes.Headline()
+	}
+
+	var annotations []github.Annotation
+	for _, f := range r.Failures {
+		if file := annotatedFile(repoPath, f.Directory); file != "" {
+			annotations = append(annotations, github.Annotation{
+				Path: file, StartLine: 1, EndLine: 1,
+				AnnotationLevel: github.AnnotationFailure,
+				Title:           "Render failed: " + f.Directory,
+				Message:         f.Error,
+			})
+		}
+	}
+	for _, f := range r.DeprecatedAPIs {
+		if file := annotatedFile(repoPath, f.Directory); file != "" {
+			annotations = append(annotations, github.Annotation{
+				Path: file, StartLine: 1, EndLine: 1,
+				AnnotationLevel: github.AnnotationWarning,
+				Title:           "Deprecated API: " + f.Directory,
+				Message:         f.DeprecatedAPI.String(),
+			})
+		}
+	}
+
+	return github.CheckRun{
+		Name:       CheckRunName,
+		HeadSHA:    headSHA,
+		Conclusion: conclusion,
+		DetailsURL: r.compareURL(),
+		Output: &github.CheckOutput{
+			Title:       title,
+			Summary:     r.Markdown(),
+			Annotations: annotations,
+		},
+	}
+}
+
+// FailedCheckRun builds the check run reporting a sync that failed with err
+// on the source commit headSHA
+func FailedCheckRun(headSHA string, err error) github.CheckRun {
+	return github.CheckRun{
+		Name:       CheckRunName,
+		HeadSHA:    headSHA,
+		Conclusion: github.ConclusionFailure,
+		Output: &github.CheckOutput{
+			Title:   "Sync failed",
+			Summary: fmt.Sprintf("## Shadow Sync\n\nSync failed:\n\n```\n%s\n```\n", err),
+		},
+	}
+}
+
+// compareURL is the result's compare URL, or the first shadow repo's
+func (r Result) compareURL() string {
+	if r.CompareURL != "" {
+		return r.CompareURL
+	}
+	for _, repo := range r.Repos {
+		if repo.CompareURL != "" {
+			return repo.CompareURL
+		}
+	}
+	return ""
+}
+
+// annotatedFile returns the repo-relative file a directory's findings are
+// annotated on, or "" when it has none of annotatedFiles
+func annotatedFile(repoPath, dir string) string {
+	for _, name := range annotatedFiles {
+		if _, err := os.Stat(filepath.Join(repoPath, dir, name)); err == nil {
+			return path.Join(filepath.ToSlash(dir), name)
+		}
+	}
+	return ""
+}
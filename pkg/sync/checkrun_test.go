@@ -0,0 +1,77 @@
+package sync
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/erauner/homelab-shadow/pkg/github"
+	"github.com/erauner/homelab-shadow/pkg/kustomize"
+)
+
+func TestResult_CheckRun(t *testing.T) {
+	repo := t.TempDir()
+	for _, file := range []string{"apps/web/overlays/production/kustomization.yaml", "apps/db/helm/values.yaml"} {
+		if err := os.MkdirAll(filepath.Join(repo, filepath.Dir(file)), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(repo, file), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	result := Result{
+		RenderedDirs: 2,
+		FailedDirs:   1,
+		Failures: []DirFailure{
+			{Directory: "apps/web/overlays/production", Error: "kustomize build failed"},
+			{Directory: "apps/gone", Error: "no such directory"},
+		},
+		DeprecatedAPIs: []DeprecatedAPIFinding{{Directory: "apps/db/helm", DeprecatedAPI: kustomize.DeprecatedAPI{APIVersion: "policy/v1beta1", Kind: "PodDisruptionBudget", Name: "db"}}},
+		Repos:          []Result{{}, {CompareURL: "https://github.com/owner/shadow/compare/main...pr-7"}},
+	}
+	run := result.CheckRun(repo, "abc123")
+
+	if run.Name != CheckRunName || run.HeadSHA != "abc123" || run.Conclusion != github.ConclusionFailure {
+		t.Errorf("unexpected run: %+v", run)
+	}
+	if run.DetailsURL != "https://github.com/owner/shadow/compare/main...pr-7" {
+		t.Errorf("DetailsURL = %q", run.DetailsURL)
+	}
+	if run.Output.Title != "2 rendered, 2 failed" || run.Output.Summary != result.Markdown() {
+		t.Errorf("unexpected output: %+v", run.Output)
+	}
+
+	want := []github.Annotation{
+		{Path: "apps/web/overlays/production/kustomization.yaml", AnnotationLevel: github.AnnotationFailure, Message: "kustomize build failed"},
+		{Path: "apps/db/helm/values.yaml", AnnotationLevel: github.AnnotationWarning},
+	}
+	if len(run.Output.Annotations) != len(want) {
+		t.Fatalf("got %d annotations, want %d: %+v", len(run.Output.Annotations), len(want), run.Output.Annotations)
+	}
+	for i, w := range want {
+		got := run.Output.Annotations[i]
+		if got.Path != w.Path || got.AnnotationLevel != w.AnnotationLevel || (w.Message != "" && got.Message != w.Message) || got.StartLine != 1 {
+			t.Errorf("annotation %d = %+v, want %+v", i, got, w)
+		}
+	}
+
+	if c := (Result{DeprecatedAPIs: result.DeprecatedAPIs}).CheckRun(repo, "abc123").Conclusion; c != github.ConclusionNeutral {
+		t.Errorf("deprecated APIs only: conclusion = %s, want neutral", c)
+	}
+	if c := (Result{RenderedDirs: 1}).CheckRun(repo, "abc123").Conclusion; c != github.ConclusionSuccess {
+		t.Errorf("clean run: conclusion = %s, want success", c)
+	}
+}
+
+func TestFailedCheckRun(t *testing.T) {
+	run := FailedCheckRun("abc123", errors.New("failed to clone shadow repo: exit status 128"))
+	if run.Name != CheckRunName || run.HeadSHA != "abc123" || run.Conclusion != github.ConclusionFailure {
+		t.Errorf("unexpected run: %+v", run)
+	}
+	if run.Output.Title != "Sync failed" || !strings.Contains(run.Output.Summary, "failed to clone shadow repo: exit status 128") {
+		t.Errorf("unexpected output: %+v", run.Output)
+	}
+}
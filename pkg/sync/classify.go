@@ -0,0 +1,286 @@
+package sync
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"reflect"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Change categories assigned to changed resources, in display order
+const (
+	CategoryImage     = "image"
+	CategoryConfig    = "config"
+	CategoryRBAC      = "rbac"
+	CategoryCRDSchema = "crd-schema"
+	CategoryScale     = "scale"
+	CategoryOther     = "other"
+	CategoryAdded     = "added"
+	CategoryRemoved   = "removed"
+)
+
+// categoryOrder is the display order of categories
+var categoryOrder = []string{
+	CategoryImage, CategoryConfig, CategoryRBAC, CategoryCRDSchema,
+	CategoryScale, CategoryOther, CategoryAdded, CategoryRemoved,
+}
+
+// categoryNouns are the singular and plural headline phrases per category
+var categoryNouns = map[string][2]string{
+	CategoryImage:     {"image bump", "image bumps"},
+	CategoryConfig:    {"env/config change", "env/config changes"},
+	CategoryRBAC:      {"RBAC change", "RBAC changes"},
+	CategoryCRDSchema: {"CRD schema change", "CRD schema changes"},
+	CategoryScale:     {"replica/scale change", "replica/scale changes"},
+	CategoryOther:     {"other change", "other changes"},
+	CategoryAdded:     {"resource added", "resources added"},
+	CategoryRemoved:   {"resource removed", "resources removed"},
+}
+
+// rbacKinds are resources where any change is an RBAC change
+var rbacKinds = map[string]bool{
+	"Role":               true,
+	"ClusterRole":        true,
+	"RoleBinding":        true,
+	"ClusterRoleBinding": true,
+	"ServiceAccount":     true,
+}
+
+// ResourceChange is one resource that differs between two renders
+type ResourceChange struct {
+	File       string   `json:"file"`
+	Resource   string   `json:"resource"` // Kind/name or Kind/namespace/name
+	Categories []string `json:"categories"`
+}
+
+// ClassifyManifestChange compares two renders of a manifest file resource by
+// resource and buckets each added, removed, or modified resource
+func ClassifyManifestChange(file, before, after string) ([]ResourceChange, error) {
+	old, err := decodeResources(before)
+	if err != nil {
+		return nil, err
+	}
+	cur, err := decodeResources(after)
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []ResourceChange
+	for id, doc := range cur {
+		prev, ok := old[id]
+		switch {
+		case !ok:
+			changes = append(changes, ResourceChange{File: file, Resource: id, Categories: []string{CategoryAdded}})
+		case !reflect.DeepEqual(prev, doc):
+			changes = append(changes, ResourceChange{File: file, Resource: id, Categories: classifyResource(prev, doc)})
+		}
+	}
+	for id := range old {
+		if _, ok := cur[id]; !ok {
+			changes = append(changes, ResourceChange{File: file, Resource: id, Categories: []string{CategoryRemoved}})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Resource < changes[j].Resource })
+	return changes, nil
+}
+
+// CountCategories counts resources per category
+func CountCategories(changes []ResourceChange) map[string]int {
+	counts := make(map[string]int)
+	for _, c := range changes {
+		for _, category := range c.Categories {
+			counts[category]++
+		}
+	}
+	return counts
+}
+
+// Headline summarizes category counts, e.g. "3 image bumps, 1 RBAC change"
+func Headline(counts map[string]int) string {
+	var parts []string
+	for _, category := range categoryOrder {
+		n := counts[category]
+		if n == 0 {
+			continue
+		}
+		noun := categoryNouns[category][1]
+		if n == 1 {
+			noun = categoryNouns[category][0]
+		}
+		parts = append(parts, fmt.Sprintf("%d %s", n, noun))
+	}
+	if len(parts) == 0 {
+		return "no resource changes"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// classifyResource buckets a modified resource by the paths that changed
+func classifyResource(before, after map[string]interface{}) []string {
+	kind, _ := after["kind"].(string)
+	if rbacKinds[kind] {
+		return []string{CategoryRBAC}
+	}
+
+	found := make(map[string]bool)
+	for _, p := range changedPaths("", before, after) {
+		found[classifyPath(kind, p)] = true
+	}
+
+	var categories []string
+	for _, category := range categoryOrder {
+		if found[category] {
+			categories = append(categories, category)
+		}
+	}
+	return categories
+}
+
+// classifyPath maps one changed field path (e.g. spec.template.spec.containers[].image)
+// to a category
+func classifyPath(kind, p string) string {
+	segments := strings.Split(p, ".")
+	last := segments[len(segments)-1]
+
+	switch {
+	case kind == "CustomResourceDefinition":
+		if containsSegment(segments, "schema") {
+			return CategoryCRDSchema
+		}
+		return CategoryOther
+	case (kind == "ConfigMap" || kind == "Secret") &&
+		(segments[0] == "data" || segments[0] == "stringData" || segments[0] == "binaryData"):
+		return CategoryConfig
+	case last == "image" && (containsSegment(segments, "containers[]") || containsSegment(segments, "initContainers[]")):
+		return CategoryImage
+	case containsSegment(segments, "env[]") || containsSegment(segments, "envFrom[]"):
+		return CategoryConfig
+	case strings.HasPrefix(p, "metadata.annotations.checksum/") ||
+		strings.HasPrefix(p, "spec.template.metadata.annotations.checksum/"):
+		// Helm charts roll pods on config changes through checksum annotations
+		return CategoryConfig
+	case p == "spec.replicas" || (kind == "HorizontalPodAutoscaler" && (p == "spec.minReplicas" || p == "spec.maxReplicas")):
+		return CategoryScale
+	default:
+		return CategoryOther
+	}
+}
+
+// changedPaths returns the dotted paths of every differing leaf; list
+// elements are compared by index and shown as "[]"
+func changedPaths(prefix string, a, b interface{}) []string {
+	if reflect.DeepEqual(a, b) {
+		return nil
+	}
+	// A field added or removed compares against an empty value of its type,
+	// so the path reaches the leaves (e.g. a new env list yields env[].name)
+	a, b = emptyIfNil(a, b), emptyIfNil(b, a)
+
+	switch av := a.(type) {
+	case map[string]interface{}:
+		bv, ok := b.(map[string]interface{})
+		if !ok {
+			return []string{prefix}
+		}
+		keys := make(map[string]bool)
+		for k := range av {
+			keys[k] = true
+		}
+		for k := range bv {
+			keys[k] = true
+		}
+		var paths []string
+		for k := range keys {
+			paths = append(paths, changedPaths(joinPath(prefix, k), av[k], bv[k])...)
+		}
+		return paths
+	case []interface{}:
+		bv, ok := b.([]interface{})
+		if !ok {
+			return []string{prefix}
+		}
+		var paths []string
+		for i := 0; i < max(len(av), len(bv)); i++ {
+			var x, y interface{}
+			if i < len(av) {
+				x = av[i]
+			}
+			if i < len(bv) {
+				y = bv[i]
+			}
+			paths = append(paths, changedPaths(prefix+"[]", x, y)...)
+		}
+		return paths
+	default:
+		return []string{prefix}
+	}
+}
+
+// emptyIfNil returns an empty map or list matching other when v is nil
+func emptyIfNil(v, other interface{}) interface{} {
+	if v != nil {
+		return v
+	}
+	switch other.(type) {
+	case map[string]interface{}:
+		return map[string]interface{}{}
+	case []interface{}:
+		return []interface{}{}
+	}
+	return v
+}
+
+// joinPath appends a map key to a dotted path
+func joinPath(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+	return prefix + "." + key
+}
+
+// containsSegment reports whether segments includes s
+func containsSegment(segments []string, s string) bool {
+	for _, seg := range segments {
+		if seg == s {
+			return true
+		}
+	}
+	return false
+}
+
+// decodeResources decodes a multi-document manifest keyed by Kind/namespace/name
+func decodeResources(manifest string) (map[string]map[string]interface{}, error) {
+	resources := make(map[string]map[string]interface{})
+	decoder := yaml.NewDecoder(strings.NewReader(manifest))
+	for {
+		var doc map[string]interface{}
+		if err := decoder.Decode(&doc); err != nil {
+			if errors.Is(err, io.EOF) {
+				return resources, nil
+			}
+			return nil, fmt.Errorf("failed to parse manifest: %w", err)
+		}
+		if doc == nil {
+			continue
+		}
+		kind, _ := doc["kind"].(string)
+		meta, _ := doc["metadata"].(map[string]interface{})
+		name, _ := meta["name"].(string)
+		id := kind + "/" + name
+		if namespace, _ := meta["namespace"].(string); namespace != "" {
+			id = kind + "/" + namespace + "/" + name
+		}
+		resources[id] = doc
+	}
+}
+
+// isManifestFile reports whether a rendered file holds Kubernetes resources
+func isManifestFile(file string) bool {
+	ext := path.Ext(file)
+	return ext == ".yaml" || ext == ".yml"
+}
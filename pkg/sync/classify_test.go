@@ -0,0 +1,180 @@
+package sync
+
+import (
+	"strings"
+	"testing"
+)
+
+const classifyBefore = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  namespace: apps
+spec:
+  replicas: 1
+  template:
+    spec:
+      containers:
+        - name: web
+          image: nginx:1.25
+          env:
+            - name: MODE
+              value: a
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: web-config
+  namespace: apps
+data:
+  key: one
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: Role
+metadata:
+  name: web
+  namespace: apps
+rules: []
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: old
+  namespace: apps
+`
+
+const classifyAfter = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  namespace: apps
+spec:
+  replicas: 3
+  template:
+    spec:
+      containers:
+        - name: web
+          image: nginx:1.27
+          env:
+            - name: MODE
+              value: a
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: web-config
+  namespace: apps
+data:
+  key: two
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: Role
+metadata:
+  name: web
+  namespace: apps
+rules:
+  - apiGroups: [""]
+    resources: [pods]
+    verbs: [get]
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: new
+  namespace: apps
+`
+
+func TestClassifyManifestChange(t *testing.T) {
+	changes, err := ClassifyManifestChange("apps/web/manifest.yaml", classifyBefore, classifyAfter)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := make(map[string]string)
+	for _, c := range changes {
+		got[c.Resource] = strings.Join(c.Categories, ",")
+		if c.File != "apps/web/manifest.yaml" {
+			t.Errorf("%s: File = %q", c.Resource, c.File)
+		}
+	}
+	want := map[string]string{
+		"Deployment/apps/web":       "image,scale",
+		"ConfigMap/apps/web-config": "config",
+		"Role/apps/web":             "rbac",
+		"Service/apps/new":          "added",
+		"Service/apps/old":          "removed",
+	}
+	if len(got) != len(want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	for id, categories := range want {
+		if got[id] != categories {
+			t.Errorf("%s: categories = %q, want %q", id, got[id], categories)
+		}
+	}
+}
+
+func TestClassifyPath(t *testing.T) {
+	tests := []struct {
+		kind string
+		path string
+		want string
+	}{
+		{"Deployment", "spec.template.spec.containers[].image", CategoryImage},
+		{"CronJob", "spec.jobTemplate.spec.template.spec.initContainers[].image", CategoryImage},
+		{"Deployment", "spec.template.spec.containers[].env[].value", CategoryConfig},
+		{"Deployment", "spec.template.spec.containers[].envFrom[].secretRef.name", CategoryConfig},
+		{"Deployment", "spec.template.metadata.annotations.checksum/config", CategoryConfig},
+		{"Secret", "stringData.password", CategoryConfig},
+		{"StatefulSet", "spec.replicas", CategoryScale},
+		{"HorizontalPodAutoscaler", "spec.maxReplicas", CategoryScale},
+		{"CustomResourceDefinition", "spec.versions[].schema.openAPIV3Schema.properties.spec.type", CategoryCRDSchema},
+		{"CustomResourceDefinition", "metadata.labels.app", CategoryOther},
+		{"Deployment", "spec.template.spec.containers[].resources.limits.memory", CategoryOther},
+		{"Deployment", "metadata.labels.version", CategoryOther},
+	}
+	for _, tt := range tests {
+		if got := classifyPath(tt.kind, tt.path); got != tt.want {
+			t.Errorf("classifyPath(%s, %s) = %q, want %q", tt.kind, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestClassifyAddedField(t *testing.T) {
+	before := "kind: Deployment\nmetadata:\n  name: web\nspec:\n  template:\n    spec:\n      containers:\n        - name: web\n"
+	after := "kind: Deployment\nmetadata:\n  name: web\nspec:\n  template:\n    spec:\n      containers:\n        - name: web\n          env:\n            - name: A\n              value: b\n"
+
+	changes, err := ClassifyManifestChange("m.yaml", before, after)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 1 || strings.Join(changes[0].Categories, ",") != CategoryConfig {
+		t.Errorf("changes = %+v, want one config change", changes)
+	}
+}
+
+func TestHeadline(t *testing.T) {
+	counts := map[string]int{CategoryImage: 3, CategoryRBAC: 1, CategoryAdded: 2}
+	if got := Headline(counts); got != "3 image bumps, 1 RBAC change, 2 resources added" {
+		t.Errorf("Headline = %q", got)
+	}
+	if got := Headline(nil); got != "no resource changes" {
+		t.Errorf("Headline(nil) = %q", got)
+	}
+}
+
+func TestDiffTreesClassifiesManifests(t *testing.T) {
+	before := map[string]string{"apps/web/manifest.yaml": classifyBefore, "notes.txt": "a"}
+	after := map[string]string{"apps/web/manifest.yaml": classifyAfter, "notes.txt": "b"}
+
+	changes := diffTrees(before, after)
+	if len(changes.Resources) != 5 {
+		t.Fatalf("Resources = %+v, want 5", changes.Resources)
+	}
+	if changes.Categories[CategoryImage] != 1 || changes.Categories[CategoryRBAC] != 1 {
+		t.Errorf("Categories = %v", changes.Categories)
+	}
+	if !strings.HasPrefix(changes.Headline(), "1 image bump, 1 env/config change, 1 RBAC change") {
+		t.Errorf("Headline = %q", changes.Headline())
+	}
+}
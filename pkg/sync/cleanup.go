@@ -1,13 +1,16 @@
 package sync
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
-	"net/http"
 	"os"
-	"os/exec"
 	"regexp"
+	"strconv"
 	"strings"
+	"time"
+
+	"github.com/erauner/homelab-shadow/pkg/command"
+	"github.com/erauner/homelab-shadow/pkg/log"
 )
 
 // CleanupResult contains the results of branch cleanup
@@ -15,6 +18,7 @@ type CleanupResult struct {
 	CheckedBranches []string `json:"checked_branches"`
 	DeletedBranches []string `json:"deleted_branches"`
 	SkippedBranches []string `json:"skipped_branches"`
+	ExpiredBranches []string `json:"expired_branches,omitempty"` // deleted (or would be) for age alone
 	Errors          []string `json:"errors,omitempty"`
 }
 
@@ -26,7 +30,89 @@ type PRState struct {
 
 // CleanupStaleBranches removes pr-* branches from the shadow repo
 // where the corresponding PR in the source repo is closed/merged
-func CleanupStaleBranches(shadowRepoPath, sourceRepo string, dryRun bool, verbose bool) (CleanupResult, error) {
+// The source repo's provider is detected from its URL (GitHub for bare slugs)
+// useSystemGit runs the git binary instead of the built-in go-git
+// implementation (see CleanupOptions.UseSystemGit)
+func CleanupStaleBranches(ctx context.Context, shadowRepoPath, sourceRepo string, dryRun, verbose, useSystemGit bool) (CleanupResult, error) {
+	if useSystemGit && !IsGitInstalled() {
+		return CleanupResult{}, fmt.Errorf("cleanup with the system git requires git, which is %w", command.ErrNotInstalled)
+	}
+	source, err := ParseRemote(sourceRepo, "")
+	if err != nil {
+		return CleanupResult{}, err
+	}
+	branches, err := newGitBackend(useSystemGit).Branches(shadowRepoPath)
+	if err != nil {
+		return CleanupResult{}, err
+	}
+	return cleanupStaleBranches(ctx, branches, source, dryRun, 0, log.Default().Named("cleanup").Verbose(verbose))
+}
+
+// CleanupOptions configures CleanupShadowRepo
+type CleanupOptions struct {
+	ShadowRepo string   // Slug (owner/repo) or git URL
+	SourceRepo string   // Repo the PRs belong to; a bare slug is assumed to share the shadow repo's host
+	Provider   Provider // github, gitlab, or gitea (default: detected from ShadowRepo's host)
+	DryRun     bool     // Report branches that would be deleted without deleting them
+	Verbose    bool
+
+	// MaxAge also deletes pr-* and local-* branches whose tip commit is older
+	// than MaxAge, whatever their PR state (0 = PR state only)
+	MaxAge time.Duration
+
+	// UseSystemGit runs the git binary instead of the built-in go-git
+	// implementation, which needs no git installed
+	UseSystemGit bool
+}
+
+// CleanupShadowRepo removes pr-* branches for closed/merged PRs (and, with
+// MaxAge, expired pr-* and local-* branches) from a shadow repo without cloning
+// it: branches are listed with ls-remote and deleted with push --delete from an
+// empty scratch repository
+func CleanupShadowRepo(ctx context.Context, opts CleanupOptions) (CleanupResult, error) {
+	if opts.ShadowRepo == "" {
+		return CleanupResult{}, fmt.Errorf("ShadowRepo is required")
+	}
+	if opts.SourceRepo == "" && opts.MaxAge <= 0 {
+		return CleanupResult{}, fmt.Errorf("SourceRepo or MaxAge is required")
+	}
+	if opts.UseSystemGit && !IsGitInstalled() {
+		return CleanupResult{}, fmt.Errorf("cleanup with the system git requires git, which is %w", command.ErrNotInstalled)
+	}
+	shadow, err := ParseRemote(opts.ShadowRepo, opts.Provider)
+	if err != nil {
+		return CleanupResult{}, fmt.Errorf("invalid shadow repo: %w", err)
+	}
+	var source Remote
+	if opts.SourceRepo != "" {
+		if source, err = sourceRemote(opts.SourceRepo, opts.Provider, shadow); err != nil {
+			return CleanupResult{}, fmt.Errorf("invalid source repo: %w", err)
+		}
+	}
+
+	scratch, err := os.MkdirTemp("", "shadow-cleanup-*")
+	if err != nil {
+		return CleanupResult{}, fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	defer os.RemoveAll(scratch)
+
+	backend := newGitBackend(opts.UseSystemGit)
+	if err := backend.InitRemote(scratch, shadow.authURL()); err != nil {
+		return CleanupResult{}, err
+	}
+	branches, err := backend.Branches(scratch)
+	if err != nil {
+		return CleanupResult{}, err
+	}
+
+	return cleanupStaleBranches(ctx, branches, source, opts.DryRun, opts.MaxAge, log.Default().Named("cleanup").Verbose(opts.Verbose))
+}
+
+// cleanupStaleBranches implements CleanupStaleBranches, logging progress at debug level
+// With maxAge > 0, pr-* and local-* branches whose tip commit is older than
+// maxAge are deleted regardless of PR state; source may then be empty, in
+// which case pr-* branches are only checked for age
+func cleanupStaleBranches(ctx context.Context, remote branchRemote, source Remote, dryRun bool, maxAge time.Duration, logger *log.Logger) (CleanupResult, error) {
 	result := CleanupResult{
 		CheckedBranches: []string{},
 		DeletedBranches: []string{},
@@ -35,57 +121,67 @@ func CleanupStaleBranches(shadowRepoPath, sourceRepo string, dryRun bool, verbos
 	}
 
 	// List all remote branches in shadow repo
-	branches, err := listRemotePRBranches(shadowRepoPath)
+	patterns := []string{"refs/heads/pr-*"}
+	if maxAge > 0 {
+		patterns = append(patterns, "refs/heads/local-*")
+	}
+	branches, err := remote.List(patterns...)
 	if err != nil {
 		return result, fmt.Errorf("failed to list branches: %w", err)
 	}
 
-	if verbose {
-		fmt.Fprintf(os.Stderr, "Found %d pr-* branches to check\n", len(branches))
+	logger.Debugf("Found %d branches to check", len(branches))
+
+	var tips map[string]time.Time
+	if maxAge > 0 && len(branches) > 0 {
+		if tips, err = remote.TipDates(patterns); err != nil {
+			return result, fmt.Errorf("failed to read branch dates: %w", err)
+		}
 	}
+	now := time.Now()
 
-	// Check each PR branch
+	// Check each branch
 	prPattern := regexp.MustCompile(`^pr-(\d+)$`)
 	for _, branch := range branches {
 		result.CheckedBranches = append(result.CheckedBranches, branch)
 
-		matches := prPattern.FindStringSubmatch(branch)
-		if matches == nil {
-			continue
-		}
-		prNumber := matches[1]
+		var reason string
+		if tip, ok := tips[branch]; ok && now.Sub(tip) > maxAge {
+			reason = fmt.Sprintf("last commit %s, older than %s", tip.Format("2006-01-02"), FormatAge(maxAge))
+			result.ExpiredBranches = append(result.ExpiredBranches, branch)
+		} else if matches := prPattern.FindStringSubmatch(branch); matches != nil && source.Slug != "" {
+			prNumber := matches[1]
 
-		// Check PR state via GitHub API
-		state, err := getPRState(sourceRepo, prNumber)
-		if err != nil {
-			errMsg := fmt.Sprintf("failed to check PR #%s: %v", prNumber, err)
-			result.Errors = append(result.Errors, errMsg)
-			if verbose {
-				fmt.Fprintf(os.Stderr, "  %s: error - %v\n", branch, err)
+			// Check PR (or merge request) state via the provider API
+			state, err := source.PRState(ctx, prNumber)
+			if err != nil {
+				errMsg := fmt.Sprintf("failed to check PR #%s: %v", prNumber, err)
+				result.Errors = append(result.Errors, errMsg)
+				logger.Debugf("  %s: error - %v", branch, err)
+				continue
 			}
-			continue
-		}
 
-		if state == "open" {
-			result.SkippedBranches = append(result.SkippedBranches, branch)
-			if verbose {
-				fmt.Fprintf(os.Stderr, "  %s: PR still open, skipping\n", branch)
+			if state == "open" {
+				result.SkippedBranches = append(result.SkippedBranches, branch)
+				logger.Debugf("  %s: PR still open, skipping", branch)
+				continue
 			}
+			reason = "PR " + state
+		} else {
+			result.SkippedBranches = append(result.SkippedBranches, branch)
+			logger.Debugf("  %s: not stale, skipping", branch)
 			continue
 		}
 
-		// PR is closed/merged - delete the branch
-		if verbose {
-			fmt.Fprintf(os.Stderr, "  %s: PR %s, ", branch, state)
-			if dryRun {
-				fmt.Fprintf(os.Stderr, "would delete (dry-run)\n")
-			} else {
-				fmt.Fprintf(os.Stderr, "deleting...\n")
-			}
+		// Branch is stale - delete it
+		if dryRun {
+			logger.Debugf("  %s: %s, would delete (dry-run)", branch, reason)
+		} else {
+			logger.Debugf("  %s: %s, deleting...", branch, reason)
 		}
 
 		if !dryRun {
-			if err := deleteRemoteBranch(shadowRepoPath, branch); err != nil {
+			if err := remote.Delete(branch); err != nil {
 				errMsg := fmt.Sprintf("failed to delete %s: %v", branch, err)
 				result.Errors = append(result.Errors, errMsg)
 				continue
@@ -97,21 +193,20 @@ func CleanupStaleBranches(shadowRepoPath, sourceRepo string, dryRun bool, verbos
 	return result, nil
 }
 
-// listRemotePRBranches lists all pr-* branches from origin
-func listRemotePRBranches(repoPath string) ([]string, error) {
+// listRemoteBranches lists the branches on origin matching ref patterns (refs/heads/pr-*)
+func listRemoteBranches(repoPath string, patterns ...string) ([]string, error) {
 	// Use git ls-remote to query the remote directly
 	// This works after a fresh clone without needing to set up tracking
 	// git branch -r only shows tracked branches, which doesn't include pr-* after clone
 	// See: https://github.com/erauner/homelab-k8s/issues/1272
-	cmd := exec.Command("git", "ls-remote", "--heads", "origin", "refs/heads/pr-*")
-	cmd.Dir = repoPath
-	output, err := cmd.Output()
+	args := append([]string{"ls-remote", "--heads", "origin"}, patterns...)
+	output, err := runGit(repoPath, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list remote branches: %w", err)
 	}
 
 	var branches []string
-	for _, line := range strings.Split(string(output), "\n") {
+	for _, line := range strings.Split(output, "\n") {
 		line = strings.TrimSpace(line)
 		if line == "" {
 			continue
@@ -130,60 +225,68 @@ func listRemotePRBranches(repoPath string) ([]string, error) {
 	return branches, nil
 }
 
-// getPRState checks if a PR is open, closed, or merged via GitHub API
-func getPRState(repo, prNumber string) (string, error) {
-	url := fmt.Sprintf("https://api.github.com/repos/%s/pulls/%s", repo, prNumber)
-
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return "", err
+// branchTipDates fetches the tip commits of origin branches matching ref
+// patterns (shallow) and returns each branch's committer date
+func branchTipDates(repoPath string, patterns []string) (map[string]time.Time, error) {
+	args := []string{"fetch", "--quiet", "--depth=1", "origin"}
+	for _, p := range patterns {
+		branch := strings.TrimPrefix(p, "refs/heads/")
+		args = append(args, fmt.Sprintf("+%s:refs/remotes/origin/%s", p, branch))
 	}
-	req.Header.Set("Accept", "application/vnd.github.v3+json")
-	req.Header.Set("User-Agent", "shadow-sync")
-
-	// Use GH_TOKEN if available for higher rate limits and private repo access
-	// Fix for https://github.com/erauner/homelab-k8s/issues/1272
-	if token := os.Getenv("GH_TOKEN"); token != "" {
-		req.Header.Set("Authorization", "token "+token)
+	if _, err := runGit(repoPath, args...); err != nil {
+		return nil, err
 	}
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	output, err := runGit(repoPath, "for-each-ref", "--format=%(refname) %(committerdate:unix)", "refs/remotes/origin/")
 	if err != nil {
-		return "", err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode == 404 {
-		// PR doesn't exist - treat as closed
-		return "not_found", nil
+		return nil, err
 	}
 
-	if resp.StatusCode != 200 {
-		return "", fmt.Errorf("GitHub API returned %d", resp.StatusCode)
+	tips := make(map[string]time.Time)
+	for _, line := range strings.Split(output, "\n") {
+		ref, unix, ok := strings.Cut(strings.TrimSpace(line), " ")
+		if !ok {
+			continue
+		}
+		seconds, err := strconv.ParseInt(unix, 10, 64)
+		if err != nil {
+			continue
+		}
+		tips[strings.TrimPrefix(ref, "refs/remotes/origin/")] = time.Unix(seconds, 0)
 	}
+	return tips, nil
+}
 
-	var pr struct {
-		State    string `json:"state"`
-		MergedAt string `json:"merged_at"`
+// ParseAge parses a branch age such as "30d", "2w", or any Go duration ("36h")
+func ParseAge(s string) (time.Duration, error) {
+	units := map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour}
+	for suffix, unit := range units {
+		if n, ok := strings.CutSuffix(s, suffix); ok {
+			count, err := strconv.Atoi(n)
+			if err != nil || count <= 0 {
+				return 0, fmt.Errorf("invalid age %q", s)
+			}
+			return time.Duration(count) * unit, nil
+		}
 	}
-	if err := json.NewDecoder(resp.Body).Decode(&pr); err != nil {
-		return "", err
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid age %q (expected e.g. 30d, 2w, or 36h)", s)
 	}
+	return d, nil
+}
 
-	if pr.MergedAt != "" {
-		return "merged", nil
+// FormatAge formats an age as whole days when it is one ("30d"), else as a Go duration
+func FormatAge(d time.Duration) string {
+	day := 24 * time.Hour
+	if d >= day && d%day == 0 {
+		return fmt.Sprintf("%dd", d/day)
 	}
-	return pr.State, nil
+	return d.String()
 }
 
 // deleteRemoteBranch deletes a branch from origin
 func deleteRemoteBranch(repoPath, branch string) error {
-	cmd := exec.Command("git", "push", "origin", "--delete", branch)
-	cmd.Dir = repoPath
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return fmt.Errorf("%w: %s", err, string(output))
-	}
-	return nil
+	_, err := runGit(repoPath, "push", "origin", "--delete", branch)
+	return err
 }
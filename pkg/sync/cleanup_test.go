@@ -0,0 +1,207 @@
+package sync
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newShadowRemote creates a bare repo with main and the given branches
+func newShadowRemote(t *testing.T, branches ...string) string {
+	t.Helper()
+	ages := make(map[string]time.Duration)
+	for _, b := range branches {
+		ages[b] = 0
+	}
+	return newAgedShadowRemote(t, ages)
+}
+
+// newAgedShadowRemote creates a bare repo with main and branches whose tip
+// commits are the given age
+func newAgedShadowRemote(t *testing.T, branches map[string]time.Duration) string {
+	t.Helper()
+	if !IsGitInstalled() {
+		t.Skip("git not installed")
+	}
+
+	base := t.TempDir()
+	remote := filepath.Join(base, "shadow.git")
+	work := filepath.Join(base, "work")
+	git := func(dir string, age time.Duration, args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		cmd.Dir = dir
+		date := time.Now().Add(-age).Format(time.RFC3339)
+		cmd.Env = append(os.Environ(), "GIT_COMMITTER_DATE="+date, "GIT_AUTHOR_DATE="+date)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, out)
+		}
+	}
+
+	git(base, 0, "init", "--bare", "--quiet", remote)
+	git(base, 0, "init", "--quiet", "-b", "main", work)
+	git(work, 0, "commit", "--allow-empty", "--quiet", "-m", "init")
+	git(work, 0, "push", "--quiet", remote, "main")
+	for b, age := range branches {
+		git(work, 0, "checkout", "--quiet", "-B", b, "main")
+		git(work, age, "commit", "--allow-empty", "--quiet", "-m", b)
+		git(work, 0, "push", "--quiet", remote, b)
+	}
+	return remote
+}
+
+// remoteBranches lists the branches of a bare repo
+func remoteBranches(t *testing.T, remote string) []string {
+	t.Helper()
+	out, err := exec.Command("git", "-C", remote, "for-each-ref", "--format=%(refname:short)", "refs/heads").Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Fields(string(out))
+}
+
+func TestCleanupShadowRepo(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/repos/erauner/homelab-k8s/pulls/1":
+			w.Write([]byte(`{"state": "open"}`))
+		case "/api/v1/repos/erauner/homelab-k8s/pulls/2":
+			w.Write([]byte(`{"state": "closed", "merged": true}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	for _, dryRun := range []bool{true, false} {
+		remote := newShadowRemote(t, "pr-1", "pr-2", "pr-3")
+
+		result, err := CleanupShadowRepo(context.Background(), CleanupOptions{
+			ShadowRepo: "file://" + remote,
+			SourceRepo: server.URL + "/erauner/homelab-k8s",
+			Provider:   ProviderGitea,
+			DryRun:     dryRun,
+		})
+		if err != nil {
+			t.Fatalf("CleanupShadowRepo(dryRun=%v) error = %v", dryRun, err)
+		}
+
+		// pr-3 has no PR (404), so it is treated as closed
+		if want := []string{"pr-2", "pr-3"}; !reflect.DeepEqual(result.DeletedBranches, want) {
+			t.Errorf("dryRun=%v: DeletedBranches = %v, want %v", dryRun, result.DeletedBranches, want)
+		}
+		if want := []string{"pr-1"}; !reflect.DeepEqual(result.SkippedBranches, want) {
+			t.Errorf("dryRun=%v: SkippedBranches = %v, want %v", dryRun, result.SkippedBranches, want)
+		}
+
+		want := []string{"main", "pr-1"}
+		if dryRun {
+			want = []string{"main", "pr-1", "pr-2", "pr-3"}
+		}
+		if got := remoteBranches(t, remote); !reflect.DeepEqual(got, want) {
+			t.Errorf("dryRun=%v: remote branches = %v, want %v", dryRun, got, want)
+		}
+	}
+}
+
+func TestCleanupShadowRepo_RequiresRepos(t *testing.T) {
+	if _, err := CleanupShadowRepo(context.Background(), CleanupOptions{ShadowRepo: "owner/shadow"}); err == nil {
+		t.Error("expected error without SourceRepo or MaxAge")
+	}
+}
+
+func TestCleanupShadowRepo_MaxAge(t *testing.T) {
+	day := 24 * time.Hour
+	remote := newAgedShadowRemote(t, map[string]time.Duration{
+		"local-20250101-old": 90 * day,
+		"local-recent":       2 * day,
+		"pr-7":               45 * day,
+		"pr-8":               day,
+		"feature":            90 * day, // not a shadow branch, never touched
+	})
+
+	result, err := CleanupShadowRepo(context.Background(), CleanupOptions{
+		ShadowRepo: "file://" + remote,
+		MaxAge:     30 * day,
+	})
+	if err != nil {
+		t.Fatalf("CleanupShadowRepo() error = %v", err)
+	}
+
+	if want := []string{"local-20250101-old", "pr-7"}; !reflect.DeepEqual(result.DeletedBranches, want) {
+		t.Errorf("DeletedBranches = %v, want %v", result.DeletedBranches, want)
+	}
+	if !reflect.DeepEqual(result.ExpiredBranches, result.DeletedBranches) {
+		t.Errorf("ExpiredBranches = %v, want %v", result.ExpiredBranches, result.DeletedBranches)
+	}
+	if want := []string{"feature", "local-recent", "main", "pr-8"}; !reflect.DeepEqual(remoteBranches(t, remote), want) {
+		t.Errorf("remote branches = %v, want %v", remoteBranches(t, remote), want)
+	}
+}
+
+func TestCleanupStaleBranches(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/repos/erauner/homelab-k8s/pulls/1" {
+			w.Write([]byte(`{"state": "open"}`))
+			return
+		}
+		w.Write([]byte(`{"state": "closed", "merged_at": "2025-01-01T00:00:00Z"}`))
+	}))
+	defer server.Close()
+	orig := githubAPIURL
+	githubAPIURL = server.URL
+	defer func() { githubAPIURL = orig }()
+
+	for _, useSystemGit := range []bool{false, true} {
+		remote := newShadowRemote(t, "pr-1", "pr-2")
+		clone := filepath.Join(t.TempDir(), "shadow")
+		if out, err := exec.Command("git", "clone", "--quiet", remote, clone).CombinedOutput(); err != nil {
+			t.Fatalf("git clone: %v\n%s", err, out)
+		}
+
+		result, err := CleanupStaleBranches(context.Background(), clone, "erauner/homelab-k8s", false, false, useSystemGit)
+		if err != nil {
+			t.Fatalf("useSystemGit=%v: CleanupStaleBranches() error = %v", useSystemGit, err)
+		}
+		if want := []string{"pr-2"}; !reflect.DeepEqual(result.DeletedBranches, want) {
+			t.Errorf("useSystemGit=%v: DeletedBranches = %v, want %v", useSystemGit, result.DeletedBranches, want)
+		}
+		if want := []string{"main", "pr-1"}; !reflect.DeepEqual(remoteBranches(t, remote), want) {
+			t.Errorf("useSystemGit=%v: remote branches = %v, want %v", useSystemGit, remoteBranches(t, remote), want)
+		}
+	}
+
+	t.Setenv("PATH", "")
+	if _, err := CleanupStaleBranches(context.Background(), t.TempDir(), "erauner/homelab-k8s", true, false, true); err == nil {
+		t.Error("expected an error for the system git without git installed")
+	}
+}
+
+func TestParseAge(t *testing.T) {
+	tests := map[string]time.Duration{
+		"30d": 30 * 24 * time.Hour,
+		"2w":  14 * 24 * time.Hour,
+		"36h": 36 * time.Hour,
+	}
+	for input, want := range tests {
+		got, err := ParseAge(input)
+		if err != nil || got != want {
+			t.Errorf("ParseAge(%q) = %v, %v; want %v", input, got, err, want)
+		}
+	}
+	for _, input := range []string{"", "d", "-3d", "0", "soon"} {
+		if _, err := ParseAge(input); err == nil {
+			t.Errorf("ParseAge(%q): expected error", input)
+		}
+	}
+	if got := FormatAge(30 * 24 * time.Hour); got != "30d" {
+		t.Errorf("FormatAge(30d) = %q", got)
+	}
+}
@@ -0,0 +1,40 @@
+package sync
+
+import (
+	"github.com/erauner/homelab-shadow/pkg/argocd"
+	"github.com/erauner/homelab-shadow/pkg/helm"
+)
+
+// HelmCredentials collects credentials for private Helm repositories and OCI
+// registries from, in order of precedence on equally specific URLs: credsFile
+// (if set), ArgoCD repository and repo-creds Secrets of type helm or oci
+// committed in plain text under repoPath, and SHADOW_HELM_USERNAME/PASSWORD
+func HelmCredentials(repoPath, credsFile string) (*helm.CredentialStore, error) {
+	store := &helm.CredentialStore{}
+	if credsFile != "" {
+		creds, err := helm.LoadCredentialsFile(credsFile)
+		if err != nil {
+			return nil, err
+		}
+		store.Add(creds...)
+	}
+
+	secrets, err := argocd.LoadRepoSecrets(repoPath)
+	if err != nil {
+		return nil, err
+	}
+	for _, secret := range secrets {
+		if secret.Type != "helm" && secret.Type != "oci" && !secret.EnableOCI {
+			continue
+		}
+		store.Add(helm.Credential{
+			URL:      secret.URL,
+			Username: secret.Username,
+			Password: secret.Password,
+			Source:   secret.String(),
+		})
+	}
+
+	store.Add(helm.CredentialsFromEnv()...)
+	return store, nil
+}
@@ -0,0 +1,69 @@
+package sync
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/erauner/homelab-shadow/pkg/helm"
+)
+
+func TestHelmCredentials(t *testing.T) {
+	repo := t.TempDir()
+	secrets := `kind: Secret
+metadata:
+  name: charts
+  namespace: argocd
+  labels:
+    argocd.argoproj.io/secret-type: repository
+stringData:
+  url: https://charts.example.com
+  type: helm
+  username: from-secret
+  password: secret-token
+---
+kind: Secret
+metadata:
+  name: git
+  namespace: argocd
+  labels:
+    argocd.argoproj.io/secret-type: repo-creds
+stringData:
+  url: https://github.com/erauner
+  username: git
+  password: git-token
+`
+	if err := os.WriteFile(filepath.Join(repo, "repos.yaml"), []byte(secrets), 0644); err != nil {
+		t.Fatal(err)
+	}
+	credsFile := filepath.Join(t.TempDir(), "creds.yaml")
+	if err := os.WriteFile(credsFile, []byte("credentials:\n  - url: https://charts.example.com\n    username: from-file\n    password: file-token\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(helm.HelmUsernameEnv, "from-env")
+	t.Setenv(helm.HelmPasswordEnv, "env-token")
+	t.Setenv(helm.HelmRepoURLEnv, "")
+
+	store, err := HelmCredentials(repo, "")
+	if err != nil {
+		t.Fatalf("HelmCredentials() error = %v", err)
+	}
+	if got := store.Lookup("https://charts.example.com"); got == nil || got.Username != "from-secret" {
+		t.Errorf("expected the ArgoCD Secret to match, got %+v", got)
+	}
+	if got := store.Lookup("https://github.com/erauner"); got == nil || got.Username != "from-env" {
+		t.Errorf("expected git repo-creds to be ignored, got %+v", got)
+	}
+
+	store, err = HelmCredentials(repo, credsFile)
+	if err != nil {
+		t.Fatalf("HelmCredentials() error = %v", err)
+	}
+	if got := store.Lookup("https://charts.example.com"); got == nil || got.Username != "from-file" {
+		t.Errorf("expected the credentials file to win, got %+v", got)
+	}
+
+	if _, err := HelmCredentials(repo, filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Error("expected an error for a missing credentials file")
+	}
+}
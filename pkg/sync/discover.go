@@ -4,8 +4,31 @@ import (
 	"path/filepath"
 	"sort"
 	"strings"
+
+	"github.com/erauner/homelab-shadow/pkg/cluster"
 )
 
+// NewAppPatterns are the cluster-aware app overlay patterns (issue #1256)
+var NewAppPatterns = []string{
+	"apps/*/overlays/*/*",
+	"apps/*/stack/*/*",
+	"apps/*/db/overlays/*/*",
+}
+
+// LegacyAppPatterns are the pre-#1256 app overlay patterns, kept for backward compatibility
+var LegacyAppPatterns = []string{
+	"apps/*/overlays/*",
+	"apps/*/stack/*",
+	"apps/*/db/overlays/*",
+}
+
+// InfraPatterns are the infrastructure/operators/security overlay patterns (already cluster-aware)
+var InfraPatterns = []string{
+	"infrastructure/*/overlays/*",
+	"operators/*/overlays/*",
+	"security/*/overlays/*",
+}
+
 // DiscoverKustomizationsForSync finds kustomization directories suitable for sync
 // These are deployment-relevant overlays, not base directories
 //
@@ -31,33 +54,21 @@ import (
 //   - For new app patterns: filters by the cluster segment (apps/*/overlays/<cluster>/*)
 //   - For legacy app patterns: no filtering (legacy patterns don't have cluster layer)
 //   - For infrastructure/operators/security: filters by overlay name
+//
+// Clusters come from the cluster registry (clusters.yaml or clusters/). When it
+// is non-empty, a new-pattern match only counts if its cluster segment is a
+// registered cluster, so kustomizations nested inside a legacy environment
+// directory aren't mistaken for <cluster>/<env> overlays.
 func DiscoverKustomizationsForSync(repoPath string, clusters []string) ([]string, error) {
-	// Patterns to discover - ordered from most specific to least specific
-	// New cluster-aware app patterns (issue #1256)
-	newAppPatterns := []string{
-		"apps/*/overlays/*/*",
-		"apps/*/stack/*/*",
-		"apps/*/db/overlays/*/*",
-	}
-
-	// Legacy app patterns (for backward compatibility)
-	legacyAppPatterns := []string{
-		"apps/*/overlays/*",
-		"apps/*/stack/*",
-		"apps/*/db/overlays/*",
-	}
-
-	// Infrastructure/Operators/Security patterns (already cluster-aware)
-	infraPatterns := []string{
-		"infrastructure/*/overlays/*",
-		"operators/*/overlays/*",
-		"security/*/overlays/*",
+	registry, err := cluster.Load(repoPath)
+	if err != nil {
+		return nil, err
 	}
 
 	dirSet := make(map[string]bool)
 
 	// Process new cluster-aware app patterns first
-	for _, pattern := range newAppPatterns {
+	for _, pattern := range NewAppPatterns {
 		fullPattern := filepath.Join(repoPath, pattern, "kustomization.yaml")
 		matches, err := filepath.Glob(fullPattern)
 		if err != nil {
@@ -71,6 +82,11 @@ func DiscoverKustomizationsForSync(repoPath string, clusters []string) ([]string
 				relDir = dir
 			}
 
+			// Nested kustomization under a legacy env dir, not <cluster>/<env>
+			if _, ok := registry.ParseAppPath(relDir); !ok {
+				continue
+			}
+
 			// Apply cluster filter for new app patterns
 			if len(clusters) > 0 {
 				clusterName, ok := extractClusterFromAppPath(relDir)
@@ -84,7 +100,7 @@ func DiscoverKustomizationsForSync(repoPath string, clusters []string) ([]string
 	}
 
 	// Process legacy app patterns (for backward compatibility during migration)
-	for _, pattern := range legacyAppPatterns {
+	for _, pattern := range LegacyAppPatterns {
 		fullPattern := filepath.Join(repoPath, pattern, "kustomization.yaml")
 		matches, err := filepath.Glob(fullPattern)
 		if err != nil {
@@ -106,7 +122,7 @@ func DiscoverKustomizationsForSync(repoPath string, clusters []string) ([]string
 
 			// Check if this is actually a cluster directory that contains environment subdirs
 			// If so, skip it - the environment subdirs will be discovered by new patterns
-			if isClusterDirectory(repoPath, relDir) {
+			if isClusterDirectory(registry, repoPath, relDir) {
 				continue
 			}
 
@@ -116,7 +132,7 @@ func DiscoverKustomizationsForSync(repoPath string, clusters []string) ([]string
 	}
 
 	// Process infrastructure/operators/security patterns
-	for _, pattern := range infraPatterns {
+	for _, pattern := range InfraPatterns {
 		fullPattern := filepath.Join(repoPath, pattern, "kustomization.yaml")
 		matches, err := filepath.Glob(fullPattern)
 		if err != nil {
@@ -180,10 +196,32 @@ func extractClusterFromAppPath(relDir string) (string, bool) {
 	return "", false
 }
 
+// ClusterForDirectory returns the cluster a discovered kustomization belongs to
+// App overlays carry the cluster as a path segment; infrastructure, operators and
+// security overlays are named after the cluster. Legacy app paths return "".
+func ClusterForDirectory(relDir string) string {
+	if cluster, ok := extractClusterFromAppPath(relDir); ok {
+		return cluster
+	}
+
+	parts := strings.Split(filepath.ToSlash(relDir), "/")
+	if len(parts) == 4 && parts[2] == "overlays" &&
+		(parts[0] == "infrastructure" || parts[0] == "operators" || parts[0] == "security") {
+		return parts[3]
+	}
+
+	return ""
+}
+
 // isClusterDirectory checks if a legacy-pattern-matched directory is actually
 // a cluster directory (contains environment subdirs with kustomization.yaml)
 // This helps avoid discovering cluster directories when we should discover their children
-func isClusterDirectory(repoPath, relDir string) bool {
+// A non-empty cluster registry is authoritative; the layout heuristic is only a fallback
+func isClusterDirectory(registry *cluster.Registry, repoPath, relDir string) bool {
+	if !registry.Empty() {
+		return registry.Has(filepath.Base(relDir))
+	}
+
 	dirPath := filepath.Join(repoPath, relDir)
 
 	// Check if this directory contains subdirectories with kustomization.yaml
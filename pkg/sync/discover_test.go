@@ -422,3 +422,63 @@ func TestDiscoverKustomizationsForSync_NoKustomizationYaml(t *testing.T) {
 		t.Errorf("Expected 0 discoveries without kustomization.yaml, got %d", len(discovered))
 	}
 }
+
+func TestClusterForDirectory(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"apps/coder/overlays/erauner-home/production", "erauner-home"},
+		{"apps/coder/db/overlays/erauner-cloud/production", "erauner-cloud"},
+		{"infrastructure/argocd/overlays/erauner-home", "erauner-home"},
+		{"security/namespaces/overlays/erauner-cloud", "erauner-cloud"},
+		{"apps/coder/overlays/production", ""},
+		{"clusters/erauner-home/bootstrap", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if got := ClusterForDirectory(tt.path); got != tt.want {
+				t.Errorf("ClusterForDirectory(%q) = %q, want %q", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiscoverKustomizationsForSync_ClusterRegistry(t *testing.T) {
+	tempDir := t.TempDir()
+
+	for _, dir := range []string{
+		"clusters/erauner-home",
+		"apps/coder/overlays/erauner-home/production",
+		// Legacy env with a nested kustomization - must not be read as <cluster>/<env>
+		"apps/giraffe/overlays/production",
+		"apps/giraffe/overlays/production/patches",
+	} {
+		fullPath := filepath.Join(tempDir, dir)
+		if err := os.MkdirAll(fullPath, 0755); err != nil {
+			t.Fatalf("Failed to create dir %s: %v", dir, err)
+		}
+		if dir == "clusters/erauner-home" {
+			continue
+		}
+		if err := os.WriteFile(filepath.Join(fullPath, "kustomization.yaml"), []byte("resources: []\n"), 0644); err != nil {
+			t.Fatalf("Failed to create kustomization.yaml: %v", err)
+		}
+	}
+
+	dirs, err := DiscoverKustomizationsForSync(tempDir, nil)
+	if err != nil {
+		t.Fatalf("DiscoverKustomizationsForSync failed: %v", err)
+	}
+
+	want := []string{"apps/coder/overlays/erauner-home/production", "apps/giraffe/overlays/production"}
+	if len(dirs) != len(want) {
+		t.Fatalf("got %v, want %v", dirs, want)
+	}
+	for i := range want {
+		if dirs[i] != want[i] {
+			t.Errorf("dirs[%d] = %q, want %q", i, dirs[i], want[i])
+		}
+	}
+}
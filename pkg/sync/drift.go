@@ -0,0 +1,188 @@
+package sync
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/erauner/homelab-shadow/pkg/argocd"
+	"github.com/erauner/homelab-shadow/pkg/kustomize"
+	"gopkg.in/yaml.v3"
+)
+
+// Drift statuses of an Application
+const (
+	DriftInSync      = "in-sync"
+	DriftDrifted     = "drifted"
+	DriftNotDeployed = "not-deployed"
+	DriftError       = "error"
+)
+
+// ArgoCD tracking metadata added to every resource it manages
+const (
+	argoInstanceLabel      = "app.kubernetes.io/instance"
+	argoTrackingAnnotation = "argocd.argoproj.io/tracking-id"
+)
+
+// AppDrift compares what ArgoCD deploys for an Application with what the
+// repo renders for it at HEAD
+type AppDrift struct {
+	App        string `json:"app"`
+	Status     string `json:"status"`
+	SyncStatus string `json:"sync_status,omitempty"`
+	Health     string `json:"health,omitempty"`
+
+	// Revisions are the git revisions ArgoCD last synced; StaleRevision is
+	// set when a git source's revision isn't Head
+	Revisions     []string `json:"revisions,omitempty"`
+	Head          string   `json:"head,omitempty"`
+	StaleRevision bool     `json:"stale_revision,omitempty"`
+
+	// Overrides are source fields whose live value differs from the repo's
+	// Application, e.g. sources[].helm.parameters[].value after an
+	// "argocd app set -p"
+	Overrides []string `json:"overrides,omitempty"`
+
+	// Diff is the deployed manifests (before) against the local render (after)
+	Diff *kustomize.ManifestDiff `json:"diff,omitempty"`
+
+	Error string `json:"error,omitempty"`
+}
+
+// CompareLive compares an Application's live state with rendered, the
+// manifest the repo renders for it, and head, the repo's HEAD commit ("" to
+// skip the revision check). Tracking labels and Secret data, which ArgoCD
+// adds or masks, are ignored; both sides go through normalizer when set
+func CompareLive(app *argocd.Application, rendered string, live *argocd.LiveApplication, head string, normalizer *Normalizer) (*AppDrift, error) {
+	drift := &AppDrift{
+		App:        app.Name,
+		SyncStatus: live.SyncStatus,
+		Health:     live.Health,
+		Revisions:  live.Revisions,
+		Head:       head,
+	}
+
+	sources := app.Sources
+	if app.Source != nil {
+		sources = []argocd.Source{*app.Source}
+	}
+	if head != "" {
+		for i, source := range sources {
+			if source.Chart == "" && i < len(live.Revisions) && !strings.HasPrefix(live.Revisions[i], head) && !strings.HasPrefix(head, live.Revisions[i]) {
+				drift.StaleRevision = true
+			}
+		}
+	}
+	if len(live.Sources) > 0 {
+		overrides, err := sourceOverrides(sources, live.Sources)
+		if err != nil {
+			return drift, err
+		}
+		drift.Overrides = overrides
+	}
+
+	deployed, err := stripArgoTracking(strings.Join(live.Manifests, "\n---\n"))
+	if err != nil {
+		return drift, fmt.Errorf("deployed manifests: %w", err)
+	}
+	local, err := stripArgoTracking(rendered)
+	if err != nil {
+		return drift, fmt.Errorf("rendered manifests: %w", err)
+	}
+	if normalizer != nil {
+		deployed, local = normalizer.Normalize(deployed), normalizer.Normalize(local)
+	}
+	if drift.Diff, err = kustomize.DiffManifests(deployed, local); err != nil {
+		return drift, err
+	}
+
+	drift.Status = DriftInSync
+	if drift.StaleRevision || len(drift.Overrides) > 0 || !drift.Diff.Empty() {
+		drift.Status = DriftDrifted
+	}
+	return drift, nil
+}
+
+// sourceOverrides lists the source fields whose live value differs from the
+// repo's, as dotted paths
+func sourceOverrides(local, live []argocd.Source) ([]string, error) {
+	a, err := genericSources(local)
+	if err != nil {
+		return nil, err
+	}
+	b, err := genericSources(live)
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	var paths []string
+	for _, p := range changedPaths("sources", a, b) {
+		if !seen[p] {
+			seen[p] = true
+			paths = append(paths, p)
+		}
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// genericSources converts sources to plain maps and lists for changedPaths
+func genericSources(sources []argocd.Source) (interface{}, error) {
+	data, err := yaml.Marshal(sources)
+	if err != nil {
+		return nil, err
+	}
+	var v interface{}
+	if err := yaml.Unmarshal(data, &v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// stripArgoTracking removes ArgoCD's tracking label and annotation and
+// Secret data (masked in manifests ArgoCD returns) from every resource
+func stripArgoTracking(manifest string) (string, error) {
+	var docs []string
+	decoder := yaml.NewDecoder(strings.NewReader(manifest))
+	for {
+		var doc map[string]interface{}
+		if err := decoder.Decode(&doc); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return "", fmt.Errorf("failed to parse manifest: %w", err)
+		}
+		if doc == nil {
+			continue
+		}
+		if meta, ok := doc["metadata"].(map[string]interface{}); ok {
+			deleteEmptying(meta, "labels", argoInstanceLabel)
+			deleteEmptying(meta, "annotations", argoTrackingAnnotation)
+		}
+		if doc["kind"] == "Secret" {
+			delete(doc, "data")
+			delete(doc, "stringData")
+		}
+		data, err := yaml.Marshal(doc)
+		if err != nil {
+			return "", err
+		}
+		docs = append(docs, string(data))
+	}
+	return strings.Join(docs, "---\n"), nil
+}
+
+// deleteEmptying deletes key from the map at m[field], and the map itself
+// once it is empty
+func deleteEmptying(m map[string]interface{}, field, key string) {
+	inner, ok := m[field].(map[string]interface{})
+	if !ok {
+		return
+	}
+	delete(inner, key)
+	if len(inner) == 0 {
+		delete(m, field)
+	}
+}
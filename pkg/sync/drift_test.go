@@ -0,0 +1,74 @@
+package sync
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/erauner/homelab-shadow/pkg/argocd"
+	"github.com/erauner/homelab-shadow/pkg/config"
+)
+
+func TestCompareLive(t *testing.T) {
+	app := &argocd.Application{
+		Name: "coder",
+		Sources: []argocd.Source{
+			{RepoURL: "https://github.com/erauner/homelab-k8s.git", Path: "apps/coder/overlays/production", TargetRevision: "HEAD"},
+			{RepoURL: "https://helm.coder.com/v2", Chart: "coder", TargetRevision: "2.15.0", Helm: &argocd.HelmConfig{ReleaseName: "coder"}},
+		},
+	}
+	rendered := "apiVersion: v1\nkind: Service\nmetadata:\n  name: coder\nspec:\n  ports:\n  - port: 80\n" +
+		"---\napiVersion: v1\nkind: Secret\nmetadata:\n  name: coder-db\ndata:\n  password: c2VjcmV0\n"
+	deployed := []string{
+		`{"apiVersion":"v1","kind":"Service","metadata":{"name":"coder","labels":{"app.kubernetes.io/instance":"coder"}},"spec":{"ports":[{"port":80}]}}`,
+		`{"apiVersion":"v1","kind":"Secret","metadata":{"name":"coder-db"},"data":{"password":"++++++++"}}`,
+	}
+
+	t.Run("in sync", func(t *testing.T) {
+		live := &argocd.LiveApplication{Name: "coder", Sources: app.Sources, Revisions: []string{"abc1234def", "2.15.0"}, Manifests: deployed}
+		drift, err := CompareLive(app, rendered, live, "abc1234def", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if drift.Status != DriftInSync || drift.StaleRevision || len(drift.Overrides) != 0 || !drift.Diff.Empty() {
+			t.Errorf("expected in-sync, got %+v (diff %+v)", drift, drift.Diff)
+		}
+	})
+
+	t.Run("override and stale revision", func(t *testing.T) {
+		overridden := []argocd.Source{app.Sources[0], app.Sources[1]}
+		overridden[1].Helm = &argocd.HelmConfig{ReleaseName: "coder", Parameters: []argocd.HelmParameter{{Name: "replicaCount", Value: "3"}}}
+		live := &argocd.LiveApplication{
+			Name:      "coder",
+			Sources:   overridden,
+			Revisions: []string{"0ld", "2.15.0"},
+			Manifests: []string{strings.Replace(deployed[0], `"port":80`, `"port":8080`, 1), deployed[1]},
+		}
+		drift, err := CompareLive(app, rendered, live, "abc1234def", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if drift.Status != DriftDrifted || !drift.StaleRevision {
+			t.Errorf("expected a drifted stale revision, got %+v", drift)
+		}
+		if got := strings.Join(drift.Overrides, ","); got != "sources[].helm.parameters[].name,sources[].helm.parameters[].value" {
+			t.Errorf("Overrides = %s", got)
+		}
+		if len(drift.Diff.Changed) != 1 || drift.Diff.Changed[0] != "Service/coder" {
+			t.Errorf("Diff = %+v", drift.Diff)
+		}
+	})
+
+	t.Run("normalized", func(t *testing.T) {
+		live := &argocd.LiveApplication{Name: "coder", Manifests: []string{
+			`{"apiVersion":"v1","kind":"Service","metadata":{"name":"coder","annotations":{"checksum/config":"abc"}},"spec":{"ports":[{"port":80}]}}`,
+			deployed[1],
+		}}
+		drift, err := CompareLive(app, rendered, live, "", NewNormalizer(config.Normalization{}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if drift.Status != DriftInSync {
+			t.Errorf("volatile annotations should be ignored, got %+v", drift.Diff)
+		}
+	})
+}
@@ -0,0 +1,146 @@
+package sync
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/erauner/homelab-shadow/pkg/argocd"
+	"github.com/erauner/homelab-shadow/pkg/cluster"
+	"github.com/erauner/homelab-shadow/pkg/config"
+	"github.com/erauner/homelab-shadow/pkg/flux"
+)
+
+// Pattern groups reported by ExplainPath
+const (
+	PatternGroupApp       = "app"
+	PatternGroupLegacyApp = "legacy-app"
+	PatternGroupInfra     = "infrastructure"
+)
+
+// PathExplanation describes how sync classifies a single directory
+type PathExplanation struct {
+	Path             string   `json:"path"`
+	HasKustomization bool     `json:"hasKustomization"`
+	Pattern          string   `json:"pattern,omitempty"`      // discovery glob that matched
+	PatternGroup     string   `json:"patternGroup,omitempty"` // app, legacy-app, infrastructure
+	Cluster          string   `json:"cluster,omitempty"`
+	Environment      string   `json:"environment,omitempty"`
+	Applications     []string `json:"applications,omitempty"` // Applications whose kustomize source is this path
+	Rendered         bool     `json:"rendered"`
+	OutputPaths      []string `json:"outputPaths,omitempty"` // relative to the shadow repo, one per output root
+	Reason           string   `json:"reason"`
+}
+
+// ExplainPath reports which discovery pattern matches relDir, the cluster and
+// environment it maps to, the Applications that deploy it, and whether
+// DiscoverKustomizationsForSync would render it with the given cluster filter
+// and output roots (nil outputs means the default single "rendered" root)
+func ExplainPath(repoPath, relDir string, clusters []string, outputs []config.Output) (*PathExplanation, error) {
+	relDir = filepath.Clean(strings.TrimPrefix(filepath.ToSlash(relDir), "./"))
+	registry, err := cluster.Load(repoPath)
+	if err != nil {
+		return nil, err
+	}
+	exp := &PathExplanation{Path: filepath.ToSlash(relDir)}
+
+	if _, err := os.Stat(filepath.Join(repoPath, relDir, "kustomization.yaml")); err == nil {
+		exp.HasKustomization = true
+	}
+
+	groups := []struct {
+		name     string
+		patterns []string
+	}{
+		{PatternGroupApp, NewAppPatterns},
+		{PatternGroupLegacyApp, LegacyAppPatterns},
+		{PatternGroupInfra, InfraPatterns},
+	}
+	for _, group := range groups {
+		for _, pattern := range group.patterns {
+			if ok, _ := filepath.Match(pattern, relDir); ok {
+				exp.Pattern = pattern
+				exp.PatternGroup = group.name
+				break
+			}
+		}
+		if exp.Pattern != "" {
+			break
+		}
+	}
+
+	unregistered := false
+	if exp.Pattern != "" {
+		exp.Cluster = ClusterForDirectory(relDir)
+		if exp.PatternGroup != PatternGroupInfra {
+			exp.Environment = filepath.Base(relDir)
+		}
+		if exp.PatternGroup == PatternGroupApp {
+			if _, ok := registry.ParseAppPath(relDir); !ok {
+				unregistered = true
+			}
+		}
+	}
+
+	apps, _, err := flux.LoadAllApplications(repoPath)
+	if err != nil {
+		return nil, err
+	}
+	for _, app := range apps {
+		for _, path := range argocd.GetKustomizePathsFromApp(app) {
+			if filepath.Clean(strings.TrimPrefix(path, "./")) == relDir {
+				exp.Applications = append(exp.Applications, app.Name)
+				break
+			}
+		}
+	}
+
+	switch {
+	case !exp.HasKustomization:
+		exp.Reason = "no kustomization.yaml in directory"
+		return exp, nil
+	case exp.Pattern == "":
+		exp.Reason = "does not match any sync discovery pattern (bases and components are not rendered)"
+		return exp, nil
+	}
+
+	dirs, err := DiscoverKustomizationsForSync(repoPath, clusters)
+	if err != nil {
+		return nil, err
+	}
+	for _, dir := range dirs {
+		if filepath.ToSlash(dir) != exp.Path {
+			continue
+		}
+		if len(outputs) == 0 {
+			outputs = []config.Output{{Path: "rendered"}}
+		}
+		for _, out := range outputs {
+			if out.HasSource(config.OutputSourceKustomize) && out.Includes(exp.Path) {
+				exp.OutputPaths = append(exp.OutputPaths, path.Join(out.Path, ManifestPath(out, exp.Path)))
+			}
+		}
+		if len(exp.OutputPaths) == 0 {
+			exp.Reason = "discovered by sync, but no configured output root includes it"
+			return exp, nil
+		}
+		exp.Rendered = true
+		exp.Reason = "discovered by sync"
+		return exp, nil
+	}
+
+	switch {
+	case unregistered:
+		exp.Reason = fmt.Sprintf("%s is not a registered cluster (%s); nested kustomizations are not rendered", exp.Cluster, registry.Source)
+	case exp.PatternGroup == PatternGroupLegacyApp && isClusterDirectory(registry, repoPath, relDir):
+		exp.Reason = "cluster directory; its environment subdirectories are rendered instead"
+	case len(clusters) > 0:
+		exp.Reason = "excluded by cluster filter " + strings.Join(clusters, ",")
+	default:
+		exp.Reason = "not discovered by sync"
+	}
+
+	return exp, nil
+}
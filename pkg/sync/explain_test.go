@@ -0,0 +1,121 @@
+package sync
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExplainPath(t *testing.T) {
+	repo := t.TempDir()
+	for _, dir := range []string{
+		"apps/coder/base",
+		"apps/coder/overlays/erauner-home/production",
+		"apps/coder/overlays/erauner-cloud/production",
+		"apps/giraffe/overlays/production",
+		"infrastructure/argocd/overlays/erauner-home",
+	} {
+		if err := os.MkdirAll(filepath.Join(repo, dir), 0755); err != nil {
+			t.Fatalf("failed to create dir: %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(repo, dir, "kustomization.yaml"), []byte("resources: []\n"), 0644); err != nil {
+			t.Fatalf("failed to write kustomization: %v", err)
+		}
+	}
+	if err := os.MkdirAll(filepath.Join(repo, "argocd-apps/applications"), 0755); err != nil {
+		t.Fatalf("failed to create dir: %v", err)
+	}
+	app := "apiVersion: argoproj.io/v1alpha1\nkind: Application\nmetadata:\n  name: coder\nspec:\n  source:\n    path: ./apps/coder/overlays/erauner-home/production\n"
+	if err := os.WriteFile(filepath.Join(repo, "argocd-apps/applications/coder.yaml"), []byte(app), 0644); err != nil {
+		t.Fatalf("failed to write app: %v", err)
+	}
+
+	tests := []struct {
+		name         string
+		path         string
+		clusters     []string
+		wantGroup    string
+		wantCluster  string
+		wantEnv      string
+		wantRendered bool
+		wantApps     string
+		wantReason   string
+	}{
+		{
+			name:         "cluster-aware app overlay",
+			path:         "./apps/coder/overlays/erauner-home/production",
+			wantGroup:    PatternGroupApp,
+			wantCluster:  "erauner-home",
+			wantEnv:      "production",
+			wantRendered: true,
+			wantApps:     "coder",
+		},
+		{
+			name:        "filtered by cluster",
+			path:        "apps/coder/overlays/erauner-cloud/production",
+			clusters:    []string{"erauner-home"},
+			wantGroup:   PatternGroupApp,
+			wantCluster: "erauner-cloud",
+			wantEnv:     "production",
+			wantReason:  "cluster filter",
+		},
+		{
+			name:         "legacy app overlay",
+			path:         "apps/giraffe/overlays/production",
+			wantGroup:    PatternGroupLegacyApp,
+			wantEnv:      "production",
+			wantRendered: true,
+		},
+		{
+			name:        "cluster directory",
+			path:        "apps/coder/overlays/erauner-home",
+			wantGroup:   PatternGroupLegacyApp,
+			wantCluster: "",
+			wantEnv:     "erauner-home",
+			wantReason:  "no kustomization.yaml",
+		},
+		{
+			name:         "infrastructure overlay",
+			path:         "infrastructure/argocd/overlays/erauner-home",
+			wantGroup:    PatternGroupInfra,
+			wantCluster:  "erauner-home",
+			wantRendered: true,
+		},
+		{
+			name:       "base is not rendered",
+			path:       "apps/coder/base",
+			wantReason: "does not match",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exp, err := ExplainPath(repo, tt.path, tt.clusters, nil)
+			if err != nil {
+				t.Fatalf("ExplainPath() error = %v", err)
+			}
+			if exp.PatternGroup != tt.wantGroup {
+				t.Errorf("PatternGroup = %q, want %q", exp.PatternGroup, tt.wantGroup)
+			}
+			if exp.Cluster != tt.wantCluster {
+				t.Errorf("Cluster = %q, want %q", exp.Cluster, tt.wantCluster)
+			}
+			if exp.Environment != tt.wantEnv {
+				t.Errorf("Environment = %q, want %q", exp.Environment, tt.wantEnv)
+			}
+			if exp.Rendered != tt.wantRendered {
+				t.Errorf("Rendered = %v (%s), want %v", exp.Rendered, exp.Reason, tt.wantRendered)
+			}
+			if got := strings.Join(exp.Applications, ","); got != tt.wantApps {
+				t.Errorf("Applications = %q, want %q", got, tt.wantApps)
+			}
+			if !strings.Contains(exp.Reason, tt.wantReason) {
+				t.Errorf("Reason = %q, want it to contain %q", exp.Reason, tt.wantReason)
+			}
+			if tt.wantRendered && (len(exp.OutputPaths) != 1 || exp.OutputPaths[0] != "rendered/"+strings.TrimPrefix(tt.path, "./")+"/manifest.yaml") {
+				t.Errorf("unexpected OutputPaths %q", exp.OutputPaths)
+			}
+		})
+	}
+}
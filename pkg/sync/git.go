@@ -1,50 +1,166 @@
 package sync
 
 import (
+	"bytes"
+	"context"
 	"fmt"
 	"net/url"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"regexp"
 	"strings"
+	"time"
+
+	"github.com/erauner/homelab-shadow/pkg/command"
+	"github.com/erauner/homelab-shadow/pkg/log"
 )
 
-// Clone clones a git repository to the specified directory
+// GitError is a failed git command, carrying the subcommand and its stderr
+// Credentials injected into remote URLs are redacted from the output
+type GitError struct {
+	Op     string // git subcommand, e.g. "clone" or "push"
+	Stderr string
+	Err    error
+}
+
+func (e *GitError) Error() string {
+	if e.Stderr == "" {
+		return fmt.Sprintf("git %s failed: %v", e.Op, e.Err)
+	}
+	return fmt.Sprintf("git %s failed: %v: %s", e.Op, e.Err, e.Stderr)
+}
+
+func (e *GitError) Unwrap() error {
+	return e.Err
+}
+
+// IsGitInstalled reports whether the git binary is on PATH
+func IsGitInstalled() bool {
+	_, err := exec.LookPath("git")
+	return err == nil
+}
+
+// runGit runs git in dir (ignored when empty) and returns its stdout
+// Failures are returned as *GitError
+func runGit(dir string, args ...string) (string, error) {
+	return runGitContext(context.Background(), dir, args...)
+}
+
+// runGitContext is runGit, killing git when ctx is done
+func runGitContext(ctx context.Context, dir string, args ...string) (string, error) {
+	if dir != "" {
+		args = append([]string{"-C", dir}, args...)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd := command.CommandContext(ctx, "git", args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		op := args[0]
+		if dir != "" {
+			op = args[2]
+		}
+		return stdout.String(), &GitError{Op: op, Stderr: redactToken(strings.TrimSpace(stderr.String())), Err: err}
+	}
+	return stdout.String(), nil
+}
+
+// redactToken removes provider tokens from git output (they appear in remote URLs)
+func redactToken(output string) string {
+	for _, env := range tokenEnv {
+		if token := os.Getenv(env); token != "" {
+			output = strings.ReplaceAll(output, token, "***")
+		}
+	}
+	return output
+}
+
+// Clone clones a git repository to the specified directory, retrying
+// transient network failures per retry
 // If GH_TOKEN environment variable is set, it will be used for authentication
-func Clone(repoURL, dest string) error {
+func Clone(ctx context.Context, repoURL, dest string, retry GitRetry) error {
 	// Inject GH_TOKEN into HTTPS URLs for authentication
 	cloneURL := injectAuthToken(repoURL)
 
-	cmd := exec.Command("git", "clone", "--depth=1", cloneURL, dest)
-	cmd.Stderr = os.Stderr
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("git clone failed: %w", err)
+	err := retry.do("clone", func() error {
+		_, err := runGitContext(ctx, "", "clone", "--depth=1", cloneURL, dest)
+		return err
+	}, func() {
+		os.RemoveAll(dest) // git refuses to clone into a partial checkout
+	})
+	if err != nil {
+		return err
 	}
 
 	// Fetch all branches (shallow clone only gets default branch)
-	fetchCmd := exec.Command("git", "-C", dest, "fetch", "--all", "--depth=1")
-	fetchCmd.Stderr = os.Stderr
-	if err := fetchCmd.Run(); err != nil {
+	err = retry.do("fetch", func() error {
+		_, err := runGitContext(ctx, dest, "fetch", "--all", "--depth=1")
+		return err
+	}, nil)
+	if err != nil {
 		// Non-fatal, continue
-		fmt.Fprintf(os.Stderr, "[sync] warning: git fetch --all failed: %v\n", err)
+		log.Default().Named("sync").Warnf("git fetch --all failed: %v", err)
 	}
 
 	return nil
 }
 
+// FetchBranch updates dir to the latest commit of branch on repo, initializing
+// the checkout on first use, and returns the commit SHA
+// Local changes and untracked files in dir are discarded
+func FetchBranch(ctx context.Context, repo Remote, dir, branch string, retry GitRetry) (string, error) {
+	if _, err := os.Stat(filepath.Join(dir, ".git")); err != nil {
+		if _, err := runGit("", "init", "--quiet", dir); err != nil {
+			return "", err
+		}
+		if _, err := runGit(dir, "remote", "add", "origin", repo.authURL()); err != nil {
+			return "", err
+		}
+	} else if _, err := runGit(dir, "remote", "set-url", "origin", repo.authURL()); err != nil {
+		return "", err
+	}
+
+	err := retry.do("fetch", func() error {
+		_, err := runGitContext(ctx, dir, "fetch", "--quiet", "--depth=1", "origin", branch)
+		return err
+	}, nil)
+	if err != nil {
+		return "", err
+	}
+	if _, err := runGit(dir, "checkout", "--quiet", "--force", "-B", branch, "FETCH_HEAD"); err != nil {
+		return "", err
+	}
+	if _, err := runGit(dir, "clean", "-fdxq"); err != nil {
+		return "", err
+	}
+
+	sha, err := runGit(dir, "rev-parse", "HEAD")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(sha), nil
+}
+
+// HeadCommit returns the full SHA of HEAD in dir
+func HeadCommit(dir string) (string, error) {
+	sha, err := runGit(dir, "rev-parse", "HEAD")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(sha), nil
+}
+
 // CheckoutBranch checks out a branch, creating it from baseBranch if it doesn't exist
 // Handles empty repositories by creating an initial commit first
 func CheckoutBranch(repoDir, baseBranch, branch string) error {
 	// Check if repo is empty (no commits)
-	revParseCmd := exec.Command("git", "-C", repoDir, "rev-parse", "HEAD")
-	if err := revParseCmd.Run(); err != nil {
+	if _, err := runGit(repoDir, "rev-parse", "HEAD"); err != nil {
 		// Empty repo - create initial commit on base branch
-		fmt.Fprintf(os.Stderr, "[sync] Empty repository detected, initializing with first commit\n")
+		log.Default().Named("sync").Infof("Empty repository detected, initializing with first commit")
 
 		// Create and checkout the base branch
-		checkoutOrphan := exec.Command("git", "-C", repoDir, "checkout", "--orphan", baseBranch)
-		checkoutOrphan.Stderr = os.Stderr
-		if err := checkoutOrphan.Run(); err != nil {
+		if _, err := runGit(repoDir, "checkout", "--orphan", baseBranch); err != nil {
 			return fmt.Errorf("failed to create orphan branch %s: %w", baseBranch, err)
 		}
 
@@ -56,45 +172,32 @@ func CheckoutBranch(repoDir, baseBranch, branch string) error {
 		}
 
 		// Stage and commit
-		addCmd := exec.Command("git", "-C", repoDir, "add", "README.md")
-		addCmd.Stderr = os.Stderr
-		if err := addCmd.Run(); err != nil {
+		if _, err := runGit(repoDir, "add", "README.md"); err != nil {
 			return fmt.Errorf("failed to stage README: %w", err)
 		}
-
-		commitCmd := exec.Command("git", "-C", repoDir, "commit", "-m", "Initial commit: Shadow repository setup")
-		commitCmd.Stderr = os.Stderr
-		if err := commitCmd.Run(); err != nil {
+		if _, err := runGit(repoDir, "commit", "-m", "Initial commit: Shadow repository setup"); err != nil {
 			return fmt.Errorf("failed to create initial commit: %w", err)
 		}
 
 		// Push initial commit to establish the base branch
-		pushCmd := exec.Command("git", "-C", repoDir, "push", "-u", "origin", baseBranch)
-		pushCmd.Stderr = os.Stderr
-		if err := pushCmd.Run(); err != nil {
+		if _, err := runGit(repoDir, "push", "-u", "origin", baseBranch); err != nil {
 			return fmt.Errorf("failed to push initial commit: %w", err)
 		}
 
-		fmt.Fprintf(os.Stderr, "[sync] Initialized %s branch with initial commit\n", baseBranch)
+		log.Default().Named("sync").Infof("Initialized %s branch with initial commit", baseBranch)
 	} else {
 		// Normal case: checkout the base branch
-		checkoutBase := exec.Command("git", "-C", repoDir, "checkout", baseBranch)
-		checkoutBase.Stderr = os.Stderr
-		if err := checkoutBase.Run(); err != nil {
+		if _, err := runGit(repoDir, "checkout", baseBranch); err != nil {
 			return fmt.Errorf("failed to checkout base branch %s: %w", baseBranch, err)
 		}
 
 		// Pull latest from base (ignore errors for new repos)
-		pullCmd := exec.Command("git", "-C", repoDir, "pull", "--ff-only", "origin", baseBranch)
-		pullCmd.Stderr = os.Stderr
-		pullCmd.Run() // Ignore errors
+		runGit(repoDir, "pull", "--ff-only", "origin", baseBranch)
 	}
 
 	// Create/reset the target branch from base
 	// Using -B creates or resets the branch
-	checkoutCmd := exec.Command("git", "-C", repoDir, "checkout", "-B", branch)
-	checkoutCmd.Stderr = os.Stderr
-	if err := checkoutCmd.Run(); err != nil {
+	if _, err := runGit(repoDir, "checkout", "-B", branch); err != nil {
 		return fmt.Errorf("failed to checkout branch %s: %w", branch, err)
 	}
 
@@ -105,39 +208,33 @@ func CheckoutBranch(repoDir, baseBranch, branch string) error {
 // Returns (changed, sha, error) where changed indicates if there were changes to commit
 func CommitAll(repoDir, message string) (bool, string, error) {
 	// Stage all changes
-	addCmd := exec.Command("git", "-C", repoDir, "add", "-A")
-	addCmd.Stderr = os.Stderr
-	if err := addCmd.Run(); err != nil {
-		return false, "", fmt.Errorf("git add failed: %w", err)
+	if _, err := runGit(repoDir, "add", "-A"); err != nil {
+		return false, "", err
 	}
 
 	// Check if there are changes to commit
-	statusCmd := exec.Command("git", "-C", repoDir, "status", "--porcelain")
-	statusOutput, err := statusCmd.Output()
+	status, err := runGit(repoDir, "status", "--porcelain")
 	if err != nil {
-		return false, "", fmt.Errorf("git status failed: %w", err)
+		return false, "", err
 	}
 
-	if len(strings.TrimSpace(string(statusOutput))) == 0 {
+	if len(strings.TrimSpace(status)) == 0 {
 		// No changes to commit
 		return false, "", nil
 	}
 
 	// Commit changes
-	commitCmd := exec.Command("git", "-C", repoDir, "commit", "-m", message)
-	commitCmd.Stderr = os.Stderr
-	if err := commitCmd.Run(); err != nil {
-		return false, "", fmt.Errorf("git commit failed: %w", err)
+	if _, err := runGit(repoDir, "commit", "-m", message); err != nil {
+		return false, "", err
 	}
 
 	// Get the commit SHA
-	shaCmd := exec.Command("git", "-C", repoDir, "rev-parse", "HEAD")
-	shaOutput, err := shaCmd.Output()
+	shaOutput, err := runGit(repoDir, "rev-parse", "HEAD")
 	if err != nil {
 		return true, "", fmt.Errorf("failed to get commit SHA: %w", err)
 	}
 
-	sha := strings.TrimSpace(string(shaOutput))
+	sha := strings.TrimSpace(shaOutput)
 	if len(sha) > 7 {
 		sha = sha[:7]
 	}
@@ -145,22 +242,125 @@ func CommitAll(repoDir, message string) (bool, string, error) {
 	return true, sha, nil
 }
 
+// Commit signing formats (see ConfigureSigning)
+const (
+	SignGPG = "gpg"
+	SignSSH = "ssh"
+)
+
+// ConfigureSigning makes git sign every commit in repoDir with format (SignGPG
+// or SignSSH) and key: a GPG key ID (empty: the committer's default key) or
+// an SSH key file or "key::<public key>"
+func ConfigureSigning(repoDir, format, key string) error {
+	gpgFormat := "openpgp"
+	if format == SignSSH {
+		gpgFormat = "ssh"
+	}
+	settings := [][2]string{{"gpg.format", gpgFormat}, {"commit.gpgsign", "true"}}
+	if key != "" {
+		settings = append(settings, [2]string{"user.signingkey", key})
+	}
+	for _, kv := range settings {
+		if _, err := runGit(repoDir, "config", kv[0], kv[1]); err != nil {
+			return fmt.Errorf("failed to configure commit signing: %w", err)
+		}
+	}
+	return nil
+}
+
+// validateSigning checks a signing format and key before anything is cloned
+func validateSigning(format, key string) error {
+	switch format {
+	case "":
+		if key != "" {
+			return fmt.Errorf("a signing key needs a signing format (gpg or ssh)")
+		}
+		return nil
+	case SignGPG:
+		if _, err := exec.LookPath("gpg"); err != nil {
+			return fmt.Errorf("GPG commit signing requires gpg, which is %w", command.ErrNotInstalled)
+		}
+	case SignSSH:
+		if key == "" {
+			return fmt.Errorf("SSH commit signing requires a signing key (a key file or key::<public key>)")
+		}
+		if _, err := exec.LookPath("ssh-keygen"); err != nil {
+			return fmt.Errorf("SSH commit signing requires ssh-keygen, which is %w", command.ErrNotInstalled)
+		}
+		if !strings.HasPrefix(key, "key::") {
+			if _, err := os.Stat(key); err != nil {
+				return fmt.Errorf("SSH signing key: %w", err)
+			}
+		}
+	default:
+		return fmt.Errorf("unknown commit signing format %q (expected gpg or ssh)", format)
+	}
+	return nil
+}
+
 // Push pushes the branch to the remote
 // For shadow repos (generated content), we use --force since --force-with-lease
 // requires having a local ref to compare against, which we don't have after a fresh clone
-func Push(repoDir, remote, branch string, force bool) error {
-	args := []string{"-C", repoDir, "push", remote, branch}
+// Transient network failures are retried per retry
+func Push(ctx context.Context, repoDir, remote, branch string, force bool, retry GitRetry) error {
+	args := []string{"push", remote, branch}
 	if force {
 		args = append(args, "--force")
 	}
 
-	cmd := exec.Command("git", args...)
-	cmd.Stderr = os.Stderr
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("git push failed: %w", err)
+	return retry.do("push", func() error {
+		_, err := runGitContext(ctx, repoDir, args...)
+		return err
+	}, nil)
+}
+
+// execGit implements gitBackend with the git binary
+type execGit struct{}
+
+func (execGit) Clone(ctx context.Context, repoURL, dest string, retry GitRetry) error {
+	return Clone(ctx, repoURL, dest, retry)
+}
+
+func (execGit) CheckoutBranch(repoDir, baseBranch, branch string) error {
+	return CheckoutBranch(repoDir, baseBranch, branch)
+}
+
+func (execGit) CommitAll(repoDir, message string) (bool, string, error) {
+	return CommitAll(repoDir, message)
+}
+
+func (execGit) Push(ctx context.Context, repoDir, branch string, force bool, retry GitRetry) error {
+	return Push(ctx, repoDir, "origin", branch, force, retry)
+}
+
+func (execGit) InitRemote(dir, repoURL string) error {
+	if _, err := runGit(dir, "init", "--bare", "--quiet"); err != nil {
+		return err
 	}
+	_, err := runGit(dir, "remote", "add", "origin", repoURL)
+	return err
+}
 
-	return nil
+func (execGit) Branches(repoDir string) (branchRemote, error) {
+	return execGitBranches{repoDir}, nil
+}
+
+// execGitBranches manages the branches of origin with ls-remote, fetch, and
+// push --delete in a local repository
+type execGitBranches struct {
+	repoPath string
+}
+
+func (b execGitBranches) List(patterns ...string) ([]string, error) {
+	return listRemoteBranches(b.repoPath, patterns...)
+}
+
+func (b execGitBranches) TipDates(patterns []string) (map[string]time.Time, error) {
+	return branchTipDates(b.repoPath, patterns)
+}
+
+func (b execGitBranches) Delete(branch string) error {
+	return deleteRemoteBranch(b.repoPath, branch)
 }
 
 // GitURLFromSlug converts a GitHub slug (owner/repo) to a git URL
@@ -0,0 +1,371 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	gitconfig "github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+	"github.com/go-git/go-git/v5/storage/memory"
+
+	"github.com/erauner/homelab-shadow/pkg/log"
+)
+
+// gitBackend runs the shadow repo operations sync and cleanup need
+type gitBackend interface {
+	Clone(ctx context.Context, repoURL, dest string, retry GitRetry) error
+	CheckoutBranch(repoDir, baseBranch, branch string) error
+	CommitAll(repoDir, message string) (bool, string, error)
+	Push(ctx context.Context, repoDir, branch string, force bool, retry GitRetry) error
+	// InitRemote creates an empty bare repository in dir whose origin is repoURL
+	InitRemote(dir, repoURL string) error
+	// Branches manages the branches of origin of the repository in repoDir
+	Branches(repoDir string) (branchRemote, error)
+}
+
+// branchRemote lists, dates, and deletes the branches of a remote repository
+type branchRemote interface {
+	List(patterns ...string) ([]string, error)
+	TipDates(patterns []string) (map[string]time.Time, error)
+	Delete(branch string) error
+}
+
+// newGitBackend returns the git binary backend with useSystemGit, else the
+// built-in go-git one, which needs no git binary
+func newGitBackend(useSystemGit bool) gitBackend {
+	if useSystemGit {
+		return execGit{}
+	}
+	return goGit{}
+}
+
+// goGit implements gitBackend in-process with go-git. Credentials come from
+// origin's URL userinfo (see Remote.authURL), as with the git binary, and are
+// sent as HTTP basic auth; SSH URLs use ssh-agent
+type goGit struct{}
+
+// goGitAuthor is the commit identity when neither GIT_AUTHOR_* nor the git
+// config name one
+var goGitAuthor = object.Signature{Name: "shadow", Email: "shadow@localhost"}
+
+// goGitEndpoint splits credentials off an HTTPS URL
+func goGitEndpoint(repoURL string) (string, transport.AuthMethod) {
+	u, err := url.Parse(repoURL)
+	if err != nil || u.User == nil || (u.Scheme != "https" && u.Scheme != "http") {
+		return repoURL, nil
+	}
+	password, _ := u.User.Password()
+	auth := &githttp.BasicAuth{Username: u.User.Username(), Password: password}
+	u.User = nil
+	return u.String(), auth
+}
+
+// errGoGit is the Err of go-git failures, whose message becomes the Stderr
+var errGoGit = errors.New("go-git")
+
+// goGitError wraps a go-git failure as a *GitError, with tokens redacted
+func goGitError(op string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &GitError{Op: op, Stderr: redactToken(err.Error()), Err: errGoGit}
+}
+
+// Clone makes a shallow clone of every branch. An empty remote is
+// initialized with origin so CheckoutBranch can create the base branch
+func (goGit) Clone(ctx context.Context, repoURL, dest string, retry GitRetry) error {
+	_, auth := goGitEndpoint(repoURL)
+	return retry.do("clone", func() error {
+		_, err := git.PlainCloneContext(ctx, dest, false, &git.CloneOptions{
+			URL:   repoURL,
+			Auth:  auth,
+			Depth: 1,
+		})
+		if errors.Is(err, transport.ErrEmptyRemoteRepository) {
+			os.RemoveAll(dest)
+			repo, err := git.PlainInit(dest, false)
+			if err != nil {
+				return goGitError("init", err)
+			}
+			_, err = repo.CreateRemote(&gitconfig.RemoteConfig{Name: "origin", URLs: []string{repoURL}})
+			return goGitError("remote", err)
+		}
+		if err != nil {
+			return goGitError("clone", err)
+		}
+
+		// The clone only has the default branch
+		repo, err := git.PlainOpen(dest)
+		if err != nil {
+			return goGitError("open", err)
+		}
+		err = repo.FetchContext(ctx, &git.FetchOptions{
+			RefSpecs: []gitconfig.RefSpec{"+refs/heads/*:refs/remotes/origin/*"},
+			Auth:     auth,
+			Depth:    1,
+		})
+		if err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
+			// Non-fatal, continue
+			log.Default().Named("sync").Warnf("git fetch of all branches failed: %v", goGitError("fetch", err))
+		}
+		return nil
+	}, func() {
+		os.RemoveAll(dest)
+	})
+}
+
+// CheckoutBranch checks out branch, created (or reset) from baseBranch, which
+// is created with an initial commit in an empty repository
+func (g goGit) CheckoutBranch(repoDir, baseBranch, branch string) error {
+	repo, err := git.PlainOpen(repoDir)
+	if err != nil {
+		return goGitError("open", err)
+	}
+	worktree, err := repo.Worktree()
+	if err != nil {
+		return goGitError("checkout", err)
+	}
+
+	var head plumbing.Hash
+	if remote, err := repo.Reference(plumbing.NewRemoteReferenceName("origin", baseBranch), true); err == nil {
+		head = remote.Hash()
+	} else if local, err := repo.Reference(plumbing.NewBranchReferenceName(baseBranch), true); err == nil {
+		head = local.Hash()
+	} else if _, err := repo.Head(); err == nil {
+		return fmt.Errorf("failed to checkout base branch %s: %w", baseBranch, &GitError{Op: "checkout", Err: fmt.Errorf("branch %s not found on origin", baseBranch)})
+	} else {
+		if head, err = g.initBaseBranch(repo, repoDir, baseBranch); err != nil {
+			return err
+		}
+	}
+
+	ref := plumbing.NewHashReference(plumbing.NewBranchReferenceName(branch), head)
+	if err := repo.Storer.SetReference(ref); err != nil {
+		return fmt.Errorf("failed to checkout branch %s: %w", branch, goGitError("checkout", err))
+	}
+	if err := worktree.Checkout(&git.CheckoutOptions{Branch: ref.Name(), Force: true}); err != nil {
+		return fmt.Errorf("failed to checkout branch %s: %w", branch, goGitError("checkout", err))
+	}
+	return nil
+}
+
+// initBaseBranch commits a README to baseBranch of an empty repository and
+// pushes it, returning the commit
+func (g goGit) initBaseBranch(repo *git.Repository, repoDir, baseBranch string) (plumbing.Hash, error) {
+	logger := log.Default().Named("sync")
+	logger.Infof("Empty repository detected, initializing with first commit")
+
+	base := plumbing.NewBranchReferenceName(baseBranch)
+	if err := repo.Storer.SetReference(plumbing.NewSymbolicReference(plumbing.HEAD, base)); err != nil {
+		return plumbing.ZeroHash, fmt.Errorf("failed to create orphan branch %s: %w", baseBranch, goGitError("checkout", err))
+	}
+	readme := "# Shadow Manifests\n\nThis repository contains rendered Kubernetes manifests for PR review.\n"
+	if err := os.WriteFile(filepath.Join(repoDir, "README.md"), []byte(readme), 0644); err != nil {
+		return plumbing.ZeroHash, fmt.Errorf("failed to write README: %w", err)
+	}
+	changed, _, err := g.CommitAll(repoDir, "Initial commit: Shadow repository setup")
+	if err != nil || !changed {
+		return plumbing.ZeroHash, fmt.Errorf("failed to create initial commit: %w", err)
+	}
+	if err := g.Push(context.Background(), repoDir, baseBranch, false, GitRetry{}); err != nil {
+		return plumbing.ZeroHash, fmt.Errorf("failed to push initial commit: %w", err)
+	}
+	logger.Infof("Initialized %s branch with initial commit", baseBranch)
+
+	ref, err := repo.Reference(base, true)
+	if err != nil {
+		return plumbing.ZeroHash, goGitError("rev-parse", err)
+	}
+	return ref.Hash(), nil
+}
+
+// CommitAll stages all changes and commits them, returning whether there
+// were changes and the short SHA
+func (goGit) CommitAll(repoDir, message string) (bool, string, error) {
+	repo, err := git.PlainOpen(repoDir)
+	if err != nil {
+		return false, "", goGitError("open", err)
+	}
+	worktree, err := repo.Worktree()
+	if err != nil {
+		return false, "", goGitError("add", err)
+	}
+	if err := worktree.AddWithOptions(&git.AddOptions{All: true}); err != nil {
+		return false, "", goGitError("add", err)
+	}
+	status, err := worktree.Status()
+	if err != nil {
+		return false, "", goGitError("status", err)
+	}
+	if status.IsClean() {
+		return false, "", nil
+	}
+
+	author, committer := goGitSignatures(repo)
+	hash, err := worktree.Commit(message, &git.CommitOptions{Author: author, Committer: committer})
+	if err != nil {
+		return false, "", goGitError("commit", err)
+	}
+	return true, hash.String()[:7], nil
+}
+
+// goGitSignatures reads the commit identity like git: GIT_AUTHOR_* and
+// GIT_COMMITTER_*, then user.name and user.email from the git config, then
+// goGitAuthor
+func goGitSignatures(repo *git.Repository) (*object.Signature, *object.Signature) {
+	fallback := goGitAuthor
+	if cfg, err := repo.ConfigScoped(gitconfig.SystemScope); err == nil && cfg.User.Name != "" && cfg.User.Email != "" {
+		fallback = object.Signature{Name: cfg.User.Name, Email: cfg.User.Email}
+	}
+	now := time.Now()
+	signature := func(role string) *object.Signature {
+		s := fallback
+		s.When = now
+		if name := os.Getenv("GIT_" + role + "_NAME"); name != "" {
+			s.Name = name
+		}
+		if email := os.Getenv("GIT_" + role + "_EMAIL"); email != "" {
+			s.Email = email
+		}
+		return &s
+	}
+	return signature("AUTHOR"), signature("COMMITTER")
+}
+
+// Push pushes branch to origin, retrying transient failures
+func (goGit) Push(ctx context.Context, repoDir, branch string, force bool, retry GitRetry) error {
+	repo, err := git.PlainOpen(repoDir)
+	if err != nil {
+		return goGitError("open", err)
+	}
+	remote, err := repo.Remote("origin")
+	if err != nil {
+		return goGitError("push", err)
+	}
+	_, auth := goGitEndpoint(remote.Config().URLs[0])
+	spec := gitconfig.RefSpec(fmt.Sprintf("refs/heads/%s:refs/heads/%s", branch, branch))
+	if force {
+		spec = "+" + spec
+	}
+	return retry.do("push", func() error {
+		err := repo.PushContext(ctx, &git.PushOptions{RemoteName: "origin", RefSpecs: []gitconfig.RefSpec{spec}, Auth: auth})
+		if errors.Is(err, git.NoErrAlreadyUpToDate) {
+			return nil
+		}
+		return goGitError("push", err)
+	}, nil)
+}
+
+// InitRemote creates a bare repository with origin set to repoURL
+func (goGit) InitRemote(dir, repoURL string) error {
+	repo, err := git.PlainInit(dir, true)
+	if err != nil {
+		return goGitError("init", err)
+	}
+	_, err = repo.CreateRemote(&gitconfig.RemoteConfig{Name: "origin", URLs: []string{repoURL}})
+	return goGitError("remote", err)
+}
+
+// Branches returns the branches of origin in repoDir, queried through an
+// in-memory remote so nothing is fetched into the repository
+func (goGit) Branches(repoDir string) (branchRemote, error) {
+	repo, err := git.PlainOpen(repoDir)
+	if err != nil {
+		return nil, goGitError("open", err)
+	}
+	origin, err := repo.Remote("origin")
+	if err != nil {
+		return nil, goGitError("remote", err)
+	}
+	endpoint, auth := goGitEndpoint(origin.Config().URLs[0])
+	return goGitBranches{
+		remote: git.NewRemote(memory.NewStorage(), &gitconfig.RemoteConfig{Name: "origin", URLs: []string{endpoint}}),
+		auth:   auth,
+	}, nil
+}
+
+// goGitBranches manages remote branches through an in-memory remote
+type goGitBranches struct {
+	remote *git.Remote
+	auth   transport.AuthMethod
+}
+
+// List returns the branches matching ref patterns (refs/heads/pr-*)
+func (b goGitBranches) List(patterns ...string) ([]string, error) {
+	refs, err := b.remote.List(&git.ListOptions{Auth: b.auth})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list remote branches: %w", goGitError("ls-remote", err))
+	}
+	var branches []string
+	for _, ref := range refs {
+		if ref.Name().IsBranch() && matchesRefPattern(ref.Name().String(), patterns) {
+			branches = append(branches, ref.Name().Short())
+		}
+	}
+	sort.Strings(branches) // as ls-remote lists them
+	return branches, nil
+}
+
+// TipDates fetches the tip commits of the branches matching ref patterns
+// (shallow, into memory) and returns each branch's committer date
+func (b goGitBranches) TipDates(patterns []string) (map[string]time.Time, error) {
+	var specs []gitconfig.RefSpec
+	for _, p := range patterns {
+		specs = append(specs, gitconfig.RefSpec(fmt.Sprintf("+%s:refs/remotes/origin/%s", p, strings.TrimPrefix(p, "refs/heads/"))))
+	}
+	storage := memory.NewStorage()
+	remote := git.NewRemote(storage, b.remote.Config())
+	err := remote.Fetch(&git.FetchOptions{RefSpecs: specs, Depth: 1, Auth: b.auth})
+	if err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
+		return nil, goGitError("fetch", err)
+	}
+
+	refs, err := storage.IterReferences()
+	if err != nil {
+		return nil, goGitError("for-each-ref", err)
+	}
+	tips := make(map[string]time.Time)
+	err = refs.ForEach(func(ref *plumbing.Reference) error {
+		if !ref.Name().IsRemote() {
+			return nil
+		}
+		commit, err := object.GetCommit(storage, ref.Hash())
+		if err != nil {
+			return nil
+		}
+		tips[strings.TrimPrefix(ref.Name().String(), "refs/remotes/origin/")] = commit.Committer.When
+		return nil
+	})
+	return tips, err
+}
+
+// Delete deletes branch from the remote
+func (b goGitBranches) Delete(branch string) error {
+	spec := gitconfig.RefSpec(":refs/heads/" + branch)
+	return goGitError("push", b.remote.Push(&git.PushOptions{RefSpecs: []gitconfig.RefSpec{spec}, Auth: b.auth}))
+}
+
+// matchesRefPattern reports whether ref matches any git ls-remote style glob
+func matchesRefPattern(ref string, patterns []string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, ref); ok {
+			return true
+		}
+	}
+	return false
+}
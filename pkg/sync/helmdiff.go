@@ -0,0 +1,73 @@
+package sync
+
+import (
+	"fmt"
+
+	"github.com/erauner/homelab-shadow/pkg/argocd"
+	"github.com/erauner/homelab-shadow/pkg/helm"
+	"github.com/erauner/homelab-shadow/pkg/kustomize"
+)
+
+// HelmVersionDiff compares a Helm source rendered at the version pinned in its
+// Application with the same source rendered at another chart version
+type HelmVersionDiff struct {
+	App     string                  `json:"app"`
+	RepoURL string                  `json:"repo_url"`
+	Chart   string                  `json:"chart"`
+	Pinned  string                  `json:"pinned"`
+	Target  string                  `json:"target"`
+	Diff    *kustomize.ManifestDiff `json:"diff"`
+}
+
+// HelmChartVersions lists the versions of a Helm source's chart in its
+// repository or OCI registry, newest first (see helm.ChartVersions)
+func HelmChartVersions(source *argocd.Source, creds *helm.CredentialStore) ([]string, error) {
+	repoURL, chart := helmChartRef(source)
+	return helm.ChartVersions(repoURL, chart, creds.Lookup(source.RepoURL))
+}
+
+// LatestHelmVersion returns the newest version of a Helm source's chart in its
+// repository or OCI registry (see helm.LatestVersion)
+func LatestHelmVersion(source *argocd.Source, creds *helm.CredentialStore, prerelease bool) (string, error) {
+	repoURL, chart := helmChartRef(source)
+	return helm.LatestVersion(repoURL, chart, creds.Lookup(source.RepoURL), prerelease)
+}
+
+// helmChartRef returns the repo URL and chart helm commands take for a source:
+// OCI charts are a full oci:// reference without a repo URL
+func helmChartRef(source *argocd.Source) (repoURL, chart string) {
+	if IsOCIRegistry(source.RepoURL) {
+		return "", NormalizeOCIURL(source.RepoURL) + "/" + source.Chart
+	}
+	return source.RepoURL, source.Chart
+}
+
+// DiffHelmSource renders source at its pinned targetRevision and at version,
+// with the same values, and diffs the two renders resource by resource
+func DiffHelmSource(app *argocd.Application, source *argocd.Source, version string, opts HelmRenderOptions) (*HelmVersionDiff, error) {
+	result := &HelmVersionDiff{
+		App:     app.Name,
+		RepoURL: source.RepoURL,
+		Chart:   source.Chart,
+		Pinned:  source.TargetRevision,
+		Target:  version,
+	}
+
+	pinned := RenderHelmSource(app, source, opts)
+	if !pinned.Passed {
+		return result, fmt.Errorf("failed to render %s@%s: %w", source.Chart, source.TargetRevision, pinned.Error)
+	}
+	bumped := *source
+	bumped.TargetRevision = version
+	target := RenderHelmSource(app, &bumped, opts)
+	if !target.Passed {
+		return result, fmt.Errorf("failed to render %s@%s: %w", source.Chart, version, target.Error)
+	}
+
+	diff, err := kustomize.DiffManifests(pinned.Output, target.Output)
+	if err != nil {
+		return result, err
+	}
+	result.Diff = diff
+	return result, nil
+}
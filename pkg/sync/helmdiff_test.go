@@ -0,0 +1,49 @@
+package sync
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/erauner/homelab-shadow/pkg/argocd"
+	"github.com/erauner/homelab-shadow/pkg/kustomize"
+)
+
+func TestDiffHelmSource(t *testing.T) {
+	// Fake helm renders a Deployment whose image follows --version, plus a
+	// ServiceMonitor from 2.0.0 on
+	script := `#!/bin/sh
+version=
+while [ $# -gt 0 ]; do
+  if [ "$1" = "--version" ]; then version="$2"; fi
+  shift
+done
+printf 'apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: web\n  namespace: web\nspec:\n  image: web:%s\n' "$version"
+if [ "$version" = "2.0.0" ]; then
+  printf -- '---\napiVersion: monitoring.coreos.com/v1\nkind: ServiceMonitor\nmetadata:\n  name: web\n  namespace: web\n'
+fi
+`
+	bin := t.TempDir()
+	if err := os.WriteFile(filepath.Join(bin, "helm"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	app := &argocd.Application{Name: "web", Namespace: "web"}
+	source := &argocd.Source{RepoURL: "https://charts.example.com", Chart: "web", TargetRevision: "1.0.0"}
+	diff, err := DiffHelmSource(app, source, "2.0.0", HelmRenderOptions{RepoPath: t.TempDir()})
+	if err != nil {
+		t.Fatalf("DiffHelmSource() error = %v", err)
+	}
+	if diff.Pinned != "1.0.0" || diff.Target != "2.0.0" || source.TargetRevision != "1.0.0" {
+		t.Errorf("unexpected versions %+v (source %s)", diff, source.TargetRevision)
+	}
+	if !reflect.DeepEqual(diff.Diff.Added, []string{"ServiceMonitor/web/web"}) || !reflect.DeepEqual(diff.Diff.Changed, []string{"Deployment/web/web"}) {
+		t.Errorf("unexpected diff %+v", diff.Diff)
+	}
+	want := []kustomize.FieldChange{{Path: "spec.image", Before: "web:1.0.0", After: "web:2.0.0"}}
+	if got := diff.Diff.Fields["Deployment/web/web"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("Fields = %+v", got)
+	}
+}
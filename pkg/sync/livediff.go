@@ -0,0 +1,57 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"strings"
+
+	"github.com/erauner/homelab-shadow/pkg/command"
+)
+
+// Live diff statuses of a rendered directory
+const (
+	LiveUnchanged = "unchanged"
+	LiveChanged   = "changed"
+	LiveError     = "error"
+)
+
+// LiveDiff is what applying a directory's render would change in a cluster,
+// as kubectl diff --server-side reports it
+type LiveDiff struct {
+	Dir    string `json:"dir"`
+	Status string `json:"status"`
+	Diff   string `json:"diff,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// KubectlDiff pipes manifest through kubectl diff --server-side against
+// kubeContext ("" for the current context). kubectl masks Secret data in
+// its output, so the manifest must be the unredacted render
+func KubectlDiff(ctx context.Context, kubeContext, dir, manifest string) *LiveDiff {
+	result := &LiveDiff{Dir: dir}
+	args := []string{"diff", "--server-side", "-f", "-"}
+	if kubeContext != "" {
+		args = append(args, "--context", kubeContext)
+	}
+	cmd := command.CommandContext(ctx, "kubectl", args...)
+	cmd.Stdin = strings.NewReader(manifest)
+	out, err := cmd.Output()
+
+	// kubectl diff exits 1 when there are differences and above 1 on errors
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+		result.Status = LiveUnchanged
+	case errors.As(err, &exitErr) && exitErr.ExitCode() == 1:
+		result.Status = LiveChanged
+		result.Diff = string(out)
+	default:
+		result.Status = LiveError
+		result.Error = err.Error()
+		if errors.As(err, &exitErr) && len(exitErr.Stderr) > 0 {
+			result.Error = strings.TrimSpace(string(exitErr.Stderr))
+		}
+	}
+	return result
+}
@@ -0,0 +1,58 @@
+package sync
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeKubectl puts a kubectl stub on PATH that reports a diff for manifests
+// containing "changed" and fails for manifests containing "broken"
+func fakeKubectl(t *testing.T) {
+	t.Helper()
+	bin := t.TempDir()
+	script := `#!/bin/sh
+[ "$1 $2" = "diff --server-side" ] || exit 3
+input=$(cat)
+case "$input" in
+  *broken*) echo "error: unable to recognize STDIN: no matches for kind Broken" >&2; exit 2 ;;
+esac
+case "$input" in
+  *changed*) echo "-  replicas: 1"; echo "+  replicas: 2 ($*)"; exit 1 ;;
+esac
+exit 0
+`
+	if err := os.WriteFile(filepath.Join(bin, "kubectl"), []byte(script), 0755); err != nil {
+		t.Fatalf("failed to write kubectl stub: %v", err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+func TestKubectlDiff(t *testing.T) {
+	fakeKubectl(t)
+	ctx := context.Background()
+
+	if got := KubectlDiff(ctx, "", "apps/web", "kind: Deployment\n"); got.Status != LiveUnchanged || got.Diff != "" {
+		t.Errorf("unchanged: got %+v", got)
+	}
+
+	got := KubectlDiff(ctx, "home", "apps/web", "kind: Deployment # changed\n")
+	if got.Status != LiveChanged || !strings.Contains(got.Diff, "+  replicas: 2") {
+		t.Errorf("changed: got %+v", got)
+	}
+	if !strings.Contains(got.Diff, "--context home") {
+		t.Errorf("expected --context to be passed, got %q", got.Diff)
+	}
+
+	got = KubectlDiff(ctx, "", "apps/web", "kind: Widget # broken\n")
+	if got.Status != LiveError || !strings.Contains(got.Error, "no matches for kind") {
+		t.Errorf("error: got %+v", got)
+	}
+
+	t.Setenv("PATH", t.TempDir())
+	if got := KubectlDiff(ctx, "", "apps/web", "kind: Deployment\n"); got.Status != LiveError || !strings.Contains(got.Error, "not found") {
+		t.Errorf("missing kubectl: got %+v", got)
+	}
+}
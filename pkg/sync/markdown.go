@@ -0,0 +1,88 @@
+package sync
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Markdown renders a sync result as a PR-comment-ready summary
+func (r Result) Markdown() string {
+	var b strings.Builder
+	if r.DryRun {
+		b.WriteString("## Shadow Sync (dry run)\n\n")
+	} else {
+		b.WriteString("## Shadow Sync\n\n")
+	}
+
+	b.WriteString("| Rendered | Helm apps | Skipped | Failed | Schema failures | Pruned |\n")
+	b.WriteString("|----------|-----------|---------|--------|-----------------|--------|\n")
+	fmt.Fprintf(&b, "| %d | %d | %d | %d | %d | %d |\n", r.RenderedDirs, r.HelmAppsRendered, r.SkippedDirs, r.FailedDirs, r.SchemaFailures, r.PrunedFiles)
+
+	if r.Changes != nil && len(r.Changes.Resources) > 0 {
+		fmt.Fprintf(&b, "\n**Resources:** %s\n", r.Changes.Headline())
+	}
+	if r.CompareURL != "" {
+		fmt.Fprintf(&b, "\n[Compare rendered manifests](%s)\n", r.CompareURL)
+	}
+	if r.Location != "" {
+		fmt.Fprintf(&b, "\nRendered manifests: `%s`\n", r.Location)
+	}
+	if len(r.Repos) > 0 {
+		b.WriteString("\n| Shadow repo | Rendered | Failed | Compare |\n")
+		b.WriteString("|-------------|----------|--------|---------|\n")
+		for _, repo := range r.Repos {
+			compare := "-"
+			if repo.CompareURL != "" {
+				compare = fmt.Sprintf("[%s](%s)", repo.Branch, repo.CompareURL)
+			}
+			fmt.Fprintf(&b, "| %s | %d | %d | %s |\n", markdownCell(repo.ShadowRepoSlug), repo.RenderedDirs+repo.HelmAppsRendered, repo.FailedDirs+repo.HelmAppsFailed, compare)
+		}
+	}
+	if r.BudgetExceeded {
+		fmt.Fprintf(&b, "\n⚠️ Budget exceeded: %d target(s) not rendered; their previous manifests were kept.\n", len(r.BudgetSkipped))
+	}
+
+	if len(r.Failures) > 0 {
+		fmt.Fprintf(&b, "\n### ❌ Failures (%d)\n\n", len(r.Failures))
+		b.WriteString("| Directory | Error |\n")
+		b.WriteString("|-----------|-------|\n")
+		for _, f := range r.Failures {
+			fmt.Fprintf(&b, "| `%s` | %s |\n", f.Directory, markdownCell(f.Error))
+		}
+	}
+
+	if len(r.Gates) > 0 {
+		fmt.Fprintf(&b, "\n### Gated changes (%d)\n\n", len(r.Gates))
+		b.WriteString("| Gate | Resource | Acknowledged |\n")
+		b.WriteString("|------|----------|--------------|\n")
+		for _, g := range r.Gates {
+			ack := "❌"
+			if g.Acknowledged {
+				ack = "✅"
+			}
+			fmt.Fprintf(&b, "| %s | %s | %s |\n", g.Gate, markdownCell(g.Resource), ack)
+		}
+	}
+
+	if len(r.DeprecatedAPIs) > 0 {
+		fmt.Fprintf(&b, "\n### ⚠️ Deprecated APIs (%d)\n\n", len(r.DeprecatedAPIs))
+		for _, f := range r.DeprecatedAPIs {
+			fmt.Fprintf(&b, "- %s\n", markdownCell(f.String()))
+		}
+	}
+
+	if r.PolicyImpact != nil && len(r.PolicyImpact.Policies) > 0 {
+		fmt.Fprintf(&b, "\n**Kyverno policy impact:** %d changed policy file(s), %d new violation(s), %d resolved\n",
+			len(r.PolicyImpact.Policies), r.PolicyImpact.NewViolations(), r.PolicyImpact.Resolved())
+	}
+	if len(r.SecretFindings) > 0 {
+		fmt.Fprintf(&b, "\n⚠️ %d value(s) that look like credentials were found outside Secrets.\n", len(r.SecretFindings))
+	}
+	return b.String()
+}
+
+// markdownCell flattens s onto one line and escapes table separators
+func markdownCell(s string) string {
+	s = strings.ReplaceAll(strings.TrimSpace(s), "\n", " ")
+	return strings.ReplaceAll(s, "|", "\\|")
+}
@@ -0,0 +1,35 @@
+package sync
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestResult_Markdown(t *testing.T) {
+	result := Result{
+		RenderedDirs:   3,
+		FailedDirs:     1,
+		SchemaFailures: 1,
+		CompareURL:     "https://github.com/owner/shadow/compare/main...pr-7",
+		Failures:       []DirFailure{{Directory: "apps/web/overlays/production", Error: "kustomize build failed:\nbad | path"}},
+		Gates:          []GatedChange{{Gate: "crd-change", Resource: "CustomResourceDefinition/widgets.example.com", Acknowledged: true}},
+		Changes:        &ChangeSummary{Resources: []ResourceChange{{}}, Categories: map[string]int{CategoryImage: 2}},
+	}
+	md := result.Markdown()
+	for _, want := range []string{
+		"## Shadow Sync\n",
+		"| 3 | 0 | 0 | 1 | 1 | 0 |",
+		"**Resources:** 2 image bumps",
+		"[Compare rendered manifests](https://github.com/owner/shadow/compare/main...pr-7)",
+		"| `apps/web/overlays/production` | kustomize build failed: bad \\| path |",
+		"| crd-change | CustomResourceDefinition/widgets.example.com | ✅ |",
+	} {
+		if !strings.Contains(md, want) {
+			t.Errorf("Markdown() missing %q:\n%s", want, md)
+		}
+	}
+
+	if md := (Result{DryRun: true}).Markdown(); !strings.HasPrefix(md, "## Shadow Sync (dry run)") || strings.Contains(md, "Failures") {
+		t.Errorf("dry run Markdown() = %q", md)
+	}
+}
@@ -0,0 +1,49 @@
+package sync
+
+import (
+	"sort"
+	"time"
+
+	"github.com/erauner/homelab-shadow/pkg/metrics"
+)
+
+// Metrics describes a sync run for --metrics-file and --pushgateway: took is
+// how long the whole run took and err why it failed (nil if it completed)
+func (r Result) Metrics(took time.Duration, err error) *metrics.Set {
+	set := &metrics.Set{}
+	set.Bool("shadow_sync_success", "1 if the sync completed.", err == nil)
+	set.Gauge("shadow_sync_last_run_timestamp_seconds", "Unix time the sync finished.", float64(time.Now().Unix()))
+	set.Gauge("shadow_sync_duration_seconds", "Duration of the sync.", took.Round(time.Millisecond).Seconds())
+
+	phases := make([]string, 0, len(r.PhaseSeconds))
+	for phase := range r.PhaseSeconds {
+		phases = append(phases, phase)
+	}
+	sort.Strings(phases)
+	for _, phase := range phases {
+		set.Gauge("shadow_sync_phase_duration_seconds", "Duration of each sync phase.", r.PhaseSeconds[phase], "phase", phase)
+	}
+
+	dirs := "Rendered targets by result."
+	set.Gauge("shadow_sync_targets", dirs, float64(r.RenderedDirs), "result", "rendered")
+	set.Gauge("shadow_sync_targets", dirs, float64(r.HelmAppsRendered), "result", "helm_rendered")
+	set.Gauge("shadow_sync_targets", dirs, float64(r.SkippedDirs), "result", "skipped")
+	set.Gauge("shadow_sync_targets", dirs, float64(r.FailedDirs), "result", "failed")
+	set.Gauge("shadow_sync_targets", dirs, float64(len(r.BudgetSkipped)), "result", "budget_skipped")
+	set.Gauge("shadow_sync_schema_failures", "Rendered manifests that failed kubeconform.", float64(r.SchemaFailures))
+
+	cache := "Helm chart cache lookups by result."
+	set.Gauge("shadow_sync_chart_cache_lookups", cache, float64(r.ChartCacheHits), "result", "hit")
+	set.Gauge("shadow_sync_chart_cache_lookups", cache, float64(r.ChartCacheMisses), "result", "miss")
+	if lookups := r.ChartCacheHits + r.ChartCacheMisses; lookups > 0 {
+		set.Gauge("shadow_sync_chart_cache_hit_ratio", "Share of chart cache lookups that hit.", float64(r.ChartCacheHits)/float64(lookups))
+	}
+
+	if r.Changes != nil {
+		files := "Files the sync changed in the shadow repo."
+		set.Gauge("shadow_sync_changed_files", files, float64(len(r.Changes.Added)), "change", "added")
+		set.Gauge("shadow_sync_changed_files", files, float64(len(r.Changes.Modified)), "change", "modified")
+		set.Gauge("shadow_sync_changed_files", files, float64(len(r.Changes.Removed)), "change", "removed")
+	}
+	return set
+}
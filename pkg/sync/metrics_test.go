@@ -0,0 +1,43 @@
+package sync
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestResult_Metrics(t *testing.T) {
+	result := Result{
+		RenderedDirs:     10,
+		FailedDirs:       2,
+		ChartCacheHits:   3,
+		ChartCacheMisses: 1,
+		PhaseSeconds:     map[string]float64{"render": 12.5, "clone": 1.25},
+		Changes:          &ChangeSummary{Modified: []string{"a", "b"}},
+	}
+	var b strings.Builder
+	if _, err := result.Metrics(42*time.Second, nil).WriteTo(&b); err != nil {
+		t.Fatal(err)
+	}
+	got := b.String()
+	for _, want := range []string{
+		"shadow_sync_success 1\n",
+		"shadow_sync_duration_seconds 42\n",
+		"shadow_sync_phase_duration_seconds{phase=\"clone\"} 1.25\nshadow_sync_phase_duration_seconds{phase=\"render\"} 12.5\n",
+		"shadow_sync_targets{result=\"rendered\"} 10\n",
+		"shadow_sync_targets{result=\"failed\"} 2\n",
+		"shadow_sync_chart_cache_hit_ratio 0.75\n",
+		"shadow_sync_changed_files{change=\"modified\"} 2\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("metrics missing %q:\n%s", want, got)
+		}
+	}
+
+	b.Reset()
+	Result{}.Metrics(time.Second, errors.New("push failed")).WriteTo(&b)
+	if !strings.Contains(b.String(), "shadow_sync_success 0\n") || strings.Contains(b.String(), "hit_ratio") {
+		t.Errorf("unexpected metrics for a failed run:\n%s", b.String())
+	}
+}
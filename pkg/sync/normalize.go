@@ -0,0 +1,266 @@
+package sync
+
+import (
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/erauner/homelab-shadow/pkg/config"
+	"gopkg.in/yaml.v3"
+)
+
+// volatileAnnotations change between renders without a real change, e.g.
+// Helm's checksum/config on pod templates
+var volatileAnnotations = []string{"checksum/*"}
+
+// generatorHash matches the suffix kustomize configMapGenerator and
+// secretGenerator append to names: ten characters of its hash alphabet
+var generatorHash = regexp.MustCompile(`^(.+)-[2456789bcdfghkmt]{10}$`)
+
+// StableHashSuffix replaces generator hash suffixes with StabilizeHashes
+const StableHashSuffix = "-<hash>"
+
+// Normalizer makes rendered manifests deterministic so shadow diffs only show
+// real changes: resources are sorted, volatile annotations are stripped, and
+// generator hash suffixes are optionally stabilized
+type Normalizer struct {
+	annotations     []string
+	stabilizeHashes bool
+}
+
+// NewNormalizer creates a Normalizer from the .shadow.yaml normalization settings
+func NewNormalizer(opts config.Normalization) *Normalizer {
+	return &Normalizer{
+		annotations:     append(append([]string(nil), volatileAnnotations...), opts.StripAnnotations...),
+		stabilizeHashes: opts.StabilizeHashes,
+	}
+}
+
+// Normalize sorts the documents of a manifest by group, version, kind,
+// namespace, and name, and strips volatile annotations.
+//
+// Like redaction, edits are applied to the original text rather than
+// re-encoding it, so formatting stays exactly as the renderer produced it.
+// Documents that don't parse are kept as-is and sort first.
+func (n *Normalizer) Normalize(manifest string) string {
+	if n.stabilizeHashes {
+		manifest = stabilizeHashes(manifest)
+	}
+
+	type document struct {
+		text string
+		key  [5]string
+	}
+	var docs []document
+	for _, doc := range splitYAMLDocuments(manifest) {
+		doc = trimDocumentSeparator(doc)
+		if strings.TrimSpace(doc) == "" {
+			continue
+		}
+		if !strings.HasSuffix(doc, "\n") {
+			doc += "\n"
+		}
+
+		nodes, err := decodeYAMLDocuments(doc)
+		if err != nil || len(nodes) != 1 {
+			docs = append(docs, document{text: doc})
+			continue
+		}
+		root := documentRoot(nodes[0])
+		docs = append(docs, document{text: n.stripAnnotations(doc, root), key: resourceKey(root)})
+	}
+
+	sort.SliceStable(docs, func(i, j int) bool {
+		for k := range docs[i].key {
+			if docs[i].key[k] != docs[j].key[k] {
+				return docs[i].key[k] < docs[j].key[k]
+			}
+		}
+		return false
+	})
+
+	texts := make([]string, len(docs))
+	for i, doc := range docs {
+		texts[i] = doc.text
+	}
+	return joinYAMLDocuments(texts)
+}
+
+// trimDocumentSeparator drops a leading bare "---" line so moved documents join cleanly
+func trimDocumentSeparator(doc string) string {
+	line, rest, _ := strings.Cut(doc, "\n")
+	if isDocumentSeparator(line) && strings.TrimSpace(line[3:]) == "" {
+		return rest
+	}
+	return doc
+}
+
+// documentRoot returns the top-level node of a decoded document
+func documentRoot(node *yaml.Node) *yaml.Node {
+	if node.Kind == yaml.DocumentNode && len(node.Content) > 0 {
+		return node.Content[0]
+	}
+	return node
+}
+
+// resourceKey is the sort key of a document: group, version, kind, namespace, name
+func resourceKey(node *yaml.Node) [5]string {
+	if node.Kind != yaml.MappingNode {
+		return [5]string{}
+	}
+	group, version, found := strings.Cut(scalarField(node, "apiVersion"), "/")
+	if !found {
+		group, version = "", group // core API group
+	}
+	var namespace, name string
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if resolveAlias(node.Content[i]).Value == "metadata" {
+			metadata := resolveAlias(node.Content[i+1])
+			namespace, name = scalarField(metadata, "namespace"), scalarField(metadata, "name")
+		}
+	}
+	return [5]string{group, version, scalarField(node, "kind"), namespace, name}
+}
+
+// stripAnnotations removes volatile keys from every metadata.annotations
+// mapping in a document (including pod templates), dropping annotations
+// entirely when nothing else is left
+func (n *Normalizer) stripAnnotations(doc string, node *yaml.Node) string {
+	var remove []*yaml.Node // keys whose lines are removed
+	var walk func(node *yaml.Node)
+	walk = func(node *yaml.Node) {
+		if node.Kind == yaml.MappingNode {
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				if node.Content[i].Value != "metadata" || node.Content[i+1].Kind != yaml.MappingNode {
+					continue
+				}
+				remove = append(remove, n.volatileKeys(node.Content[i+1])...)
+			}
+		}
+		for _, child := range node.Content {
+			walk(child)
+		}
+	}
+	walk(node)
+	if len(remove) == 0 {
+		return doc
+	}
+
+	lines := strings.SplitAfter(doc, "\n")
+	drop := make([]bool, len(lines))
+	for _, key := range remove {
+		start := key.Line - 1
+		end := start + 1
+		for end < len(lines) {
+			line := strings.TrimRight(lines[end], "\r\n")
+			if strings.TrimSpace(line) != "" && countIndent(line) <= key.Column-1 {
+				break
+			}
+			end++
+		}
+		for i := start; i < end; i++ {
+			drop[i] = true
+		}
+	}
+
+	var out strings.Builder
+	for i, line := range lines {
+		if !drop[i] {
+			out.WriteString(line)
+		}
+	}
+	return out.String()
+}
+
+// volatileKeys returns the annotation keys of a metadata mapping to remove,
+// or the annotations key itself when every annotation is volatile
+// Flow-style mappings can't be edited line by line and are left alone.
+func (n *Normalizer) volatileKeys(metadata *yaml.Node) []*yaml.Node {
+	for i := 0; i+1 < len(metadata.Content); i += 2 {
+		key, annotations := metadata.Content[i], metadata.Content[i+1]
+		if key.Value != "annotations" || annotations.Kind != yaml.MappingNode || annotations.Style&yaml.FlowStyle != 0 {
+			continue
+		}
+		if metadata.Style&yaml.FlowStyle != 0 {
+			return nil
+		}
+
+		var keys []*yaml.Node
+		for j := 0; j+1 < len(annotations.Content); j += 2 {
+			if n.isVolatile(annotations.Content[j].Value) {
+				keys = append(keys, annotations.Content[j])
+			}
+		}
+		if len(keys) > 0 && len(keys) == len(annotations.Content)/2 {
+			return []*yaml.Node{key}
+		}
+		return keys
+	}
+	return nil
+}
+
+// isVolatile reports whether an annotation key matches a stripped pattern
+func (n *Normalizer) isVolatile(annotation string) bool {
+	for _, pattern := range n.annotations {
+		if ok, _ := path.Match(pattern, annotation); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// stabilizeHashes replaces the generator hash suffix of every ConfigMap and
+// Secret name, and each reference to it, with StableHashSuffix
+func stabilizeHashes(manifest string) string {
+	nodes, err := decodeYAMLDocuments(manifest)
+	if err != nil {
+		return manifest
+	}
+
+	renames := make(map[string]string)
+	for _, node := range nodes {
+		node = documentRoot(node)
+		if node.Kind != yaml.MappingNode {
+			continue
+		}
+		if kind := scalarField(node, "kind"); kind != "ConfigMap" && kind != "Secret" {
+			continue
+		}
+		name := resourceKey(node)[4]
+		if m := generatorHash.FindStringSubmatch(name); m != nil {
+			renames[name] = m[1] + StableHashSuffix
+		}
+	}
+
+	for old, stable := range renames {
+		manifest = replaceName(manifest, old, stable)
+	}
+	return manifest
+}
+
+// replaceName replaces whole occurrences of a resource name, leaving names
+// it is only a part of (e.g. app-config-5g7h9m2k4t-extra) untouched
+func replaceName(text, old, replacement string) string {
+	isNameChar := func(b byte) bool {
+		return b == '-' || b == '.' || (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z') || (b >= '0' && b <= '9')
+	}
+
+	var out strings.Builder
+	for {
+		i := strings.Index(text, old)
+		if i < 0 {
+			out.WriteString(text)
+			return out.String()
+		}
+		end := i + len(old)
+		whole := (i == 0 || !isNameChar(text[i-1])) && (end == len(text) || !isNameChar(text[end]))
+		out.WriteString(text[:i])
+		if whole {
+			out.WriteString(replacement)
+		} else {
+			out.WriteString(old)
+		}
+		text = text[end:]
+	}
+}
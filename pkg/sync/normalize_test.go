@@ -0,0 +1,164 @@
+package sync
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/erauner/homelab-shadow/pkg/config"
+)
+
+func TestNormalize_SortsResources(t *testing.T) {
+	manifest := `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  namespace: prod
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+  namespace: prod
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: web
+  namespace: prod
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: api
+  namespace: prod
+`
+	want := `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: web
+  namespace: prod
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+  namespace: prod
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: api
+  namespace: prod
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  namespace: prod
+`
+	n := NewNormalizer(config.Normalization{})
+	if got := n.Normalize(manifest); got != want {
+		t.Errorf("Normalize() =\n%s\nwant:\n%s", got, want)
+	}
+
+	// Output doesn't depend on input order
+	docs := splitYAMLDocuments(manifest)
+	reversed := make([]string, len(docs))
+	for i, doc := range docs {
+		reversed[len(docs)-1-i] = trimDocumentSeparator(doc)
+	}
+	if got := n.Normalize(joinYAMLDocuments(reversed)); got != want {
+		t.Errorf("Normalize(reversed) =\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestNormalize_StripsVolatileAnnotations(t *testing.T) {
+	manifest := `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  annotations:
+    example.com/build-id: "1234"
+    owner: platform
+spec:
+  template:
+    metadata:
+      annotations:
+        checksum/config: 3f1a9c
+        checksum/secret: |
+          multi
+          line
+      labels:
+        app: web
+    spec:
+      containers:
+      - name: web
+        image: nginx
+`
+	want := `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  annotations:
+    owner: platform
+spec:
+  template:
+    metadata:
+      labels:
+        app: web
+    spec:
+      containers:
+      - name: web
+        image: nginx
+`
+	n := NewNormalizer(config.Normalization{StripAnnotations: []string{"example.com/*"}})
+	if got := n.Normalize(manifest); got != want {
+		t.Errorf("Normalize() =\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestNormalize_StabilizeHashes(t *testing.T) {
+	manifest := `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: app-config-5g7h9m2k4t
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app
+spec:
+  template:
+    spec:
+      volumes:
+      - name: config
+        configMap:
+          name: app-config-5g7h9m2k4t
+      - name: other
+        configMap:
+          name: app-config-5g7h9m2k4t-extra
+`
+	got := NewNormalizer(config.Normalization{StabilizeHashes: true}).Normalize(manifest)
+	if strings.Contains(got, "app-config-5g7h9m2k4t\n") {
+		t.Errorf("hash suffix not stabilized:\n%s", got)
+	}
+	if strings.Count(got, "app-config"+StableHashSuffix+"\n") != 2 {
+		t.Errorf("expected name and reference to be stabilized:\n%s", got)
+	}
+	if !strings.Contains(got, "app-config-5g7h9m2k4t-extra") {
+		t.Errorf("longer name containing the hashed name was changed:\n%s", got)
+	}
+
+	// Off by default
+	if got := NewNormalizer(config.Normalization{}).Normalize(manifest); !strings.Contains(got, "name: app-config-5g7h9m2k4t\n") {
+		t.Errorf("hash suffix changed without StabilizeHashes:\n%s", got)
+	}
+}
+
+func TestNormalize_KeepsUnparseableDocuments(t *testing.T) {
+	manifest := "kind: Service\nmetadata:\n  name: b\n---\nkind: [broken\n"
+	got := NewNormalizer(config.Normalization{}).Normalize(manifest)
+	if got != "kind: [broken\n---\nkind: Service\nmetadata:\n  name: b\n" {
+		t.Errorf("Normalize() = %q", got)
+	}
+}
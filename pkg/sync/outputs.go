@@ -0,0 +1,304 @@
+package sync
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/erauner/homelab-shadow/pkg/config"
+)
+
+// SharedClusterDir holds manifests without a cluster in by-cluster output roots
+const SharedClusterDir = "_shared"
+
+// Output layouts: how each rendered manifest is written within its directory
+const (
+	// OutputLayoutSingle writes one manifest.yaml per rendered directory
+	OutputLayoutSingle = "single"
+	// OutputLayoutSplit writes one <kind>-<namespace>-<name>.yaml per resource
+	OutputLayoutSplit = "split"
+)
+
+// outputRoot is an output root resolved to a local directory
+// written tracks the files rendered this run so prune can remove the rest
+type outputRoot struct {
+	config.Output
+	dir     string
+	split   bool
+	written map[string]bool
+}
+
+func newOutputRoot(out config.Output, dir string) outputRoot {
+	return outputRoot{Output: out, dir: dir, written: make(map[string]bool)}
+}
+
+// outputRoots resolves the configured output roots under base (the shadow repo checkout)
+func (s *Syncer) outputRoots(base string) []outputRoot {
+	roots := make([]outputRoot, 0, len(s.opts.Outputs))
+	for _, out := range s.opts.Outputs {
+		root := newOutputRoot(out, filepath.Join(base, filepath.FromSlash(out.Path)))
+		root.split = s.opts.OutputLayout == OutputLayoutSplit
+		roots = append(roots, root)
+	}
+	return roots
+}
+
+// write writes data to path under the root, leaving unchanged files untouched
+func (r outputRoot) write(path string, data []byte) error {
+	r.written[path] = true
+	if existing, err := os.ReadFile(path); err == nil && bytes.Equal(existing, data) {
+		return nil
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// prune removes files under the root that were not written this run, then any
+// directories left empty; it never follows symlinks out of the root
+func (r outputRoot) prune() (int, error) {
+	pruned := 0
+	var dirs []string
+	err := filepath.WalkDir(r.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != r.dir {
+				dirs = append(dirs, path)
+			}
+			return nil
+		}
+		if r.written[path] {
+			return nil
+		}
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+		pruned++
+		return nil
+	})
+	if err != nil {
+		return pruned, err
+	}
+
+	// Deepest first so parents empty out after their children
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if entries, err := os.ReadDir(dirs[i]); err == nil && len(entries) == 0 {
+			os.Remove(dirs[i])
+		}
+	}
+	return pruned, nil
+}
+
+// checkPrunable refuses a root that holds files but no _meta.json from a
+// previous run, so a mistyped --out never has unrelated files pruned from it
+func (r outputRoot) checkPrunable() error {
+	entries, err := os.ReadDir(r.dir)
+	if os.IsNotExist(err) || (err == nil && len(entries) == 0) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(filepath.Join(r.dir, "_meta.json")); err == nil {
+		return nil
+	}
+	return fmt.Errorf("output directory %s is not empty and has no _meta.json from a previous sync, refusing to prune it", r.dir)
+}
+
+// keep marks an existing file under the root as written so prune leaves it
+func (r outputRoot) keep(path string) bool {
+	if _, err := os.Stat(path); err != nil {
+		return false
+	}
+	r.written[path] = true
+	return true
+}
+
+// keepSplit keeps the per-resource files previously written to dir and
+// returns how many were kept
+func (r outputRoot) keepSplit(dir string) int {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return 0
+	}
+	kept := 0
+	for _, entry := range entries {
+		if entry.Type().IsRegular() && filepath.Ext(entry.Name()) == ".yaml" && r.keep(filepath.Join(dir, entry.Name())) {
+			kept++
+		}
+	}
+	return kept
+}
+
+// keepManifest keeps the previously rendered manifest for dir in every target
+// root and returns how many files were kept
+func keepManifest(targets []outputRoot, dir string) int {
+	kept := 0
+	for _, root := range targets {
+		manifestPath := filepath.Join(root.dir, filepath.FromSlash(ManifestPath(root.Output, dir)))
+		if root.split {
+			kept += root.keepSplit(filepath.Dir(manifestPath))
+		} else if root.keep(manifestPath) {
+			kept++
+		}
+	}
+	return kept
+}
+
+// rootsFor returns the roots that render source for the repo-relative directory dir
+func rootsFor(roots []outputRoot, source, dir string) []outputRoot {
+	var matched []outputRoot
+	for _, root := range roots {
+		if root.HasSource(source) && root.Includes(dir) {
+			matched = append(matched, root)
+		}
+	}
+	return matched
+}
+
+// anyRootHas reports whether any root renders source
+func anyRootHas(roots []outputRoot, source string) bool {
+	for _, root := range roots {
+		if root.HasSource(source) {
+			return true
+		}
+	}
+	return false
+}
+
+// ManifestPath returns where an output root stores the manifest rendered from
+// dir, relative to the root and slash-separated
+//
+//	mirror:     <dir>/manifest.yaml
+//	by-cluster: <cluster>/<dir>/manifest.yaml (_shared/<dir> without a cluster)
+func ManifestPath(out config.Output, dir string) string {
+	dir = filepath.ToSlash(dir)
+	if out.Layout == config.LayoutByCluster {
+		cluster := ClusterForDirectory(dir)
+		if cluster == "" {
+			cluster = SharedClusterDir
+		}
+		return path.Join(cluster, dir, "manifest.yaml")
+	}
+	return path.Join(dir, "manifest.yaml")
+}
+
+// writeManifest writes manifest into every target root at the path its layout
+// assigns to dir, or as one file per resource next to it in split roots
+func writeManifest(targets []outputRoot, dir, manifest string) error {
+	for _, root := range targets {
+		manifestPath := filepath.Join(root.dir, filepath.FromSlash(ManifestPath(root.Output, dir)))
+		if err := os.MkdirAll(filepath.Dir(manifestPath), 0755); err != nil {
+			return fmt.Errorf("failed to create directory: %v", err)
+		}
+		if !root.split {
+			if err := root.write(manifestPath, []byte(manifest)); err != nil {
+				return fmt.Errorf("failed to write manifest: %v", err)
+			}
+			continue
+		}
+		for _, file := range SplitManifest(manifest) {
+			if err := root.write(filepath.Join(filepath.Dir(manifestPath), file.Name), []byte(file.Content)); err != nil {
+				return fmt.Errorf("failed to write manifest: %v", err)
+			}
+		}
+	}
+	return nil
+}
+
+// ResourceFile is one resource of a manifest written by the split layout
+type ResourceFile struct {
+	Name    string
+	Content string
+}
+
+// SplitManifest splits a manifest into one file per resource, named
+// <kind>-<namespace>-<name>.yaml (<kind>-<name>.yaml for cluster-scoped
+// resources) in lowercase. Documents without a kind and name are written as
+// document-<n>.yaml, and repeated names get a -2, -3, ... suffix.
+func SplitManifest(manifest string) []ResourceFile {
+	var files []ResourceFile
+	used := make(map[string]bool)
+	for i, doc := range splitYAMLDocuments(manifest) {
+		doc = trimDocumentSeparator(doc)
+		if strings.TrimSpace(doc) == "" {
+			continue
+		}
+		if !strings.HasSuffix(doc, "\n") {
+			doc += "\n"
+		}
+
+		base := fmt.Sprintf("document-%d", i+1)
+		if nodes, err := decodeYAMLDocuments(doc); err == nil && len(nodes) == 1 {
+			if key := resourceKey(documentRoot(nodes[0])); key[2] != "" && key[4] != "" {
+				base = resourceFileName(key[2], key[3], key[4])
+			}
+		}
+		name := base + ".yaml"
+		for n := 2; used[name]; n++ {
+			name = fmt.Sprintf("%s-%d.yaml", base, n)
+		}
+		used[name] = true
+		files = append(files, ResourceFile{Name: name, Content: doc})
+	}
+	return files
+}
+
+// resourceFileName joins the non-empty parts of a resource identity into a
+// lowercase file name, replacing characters that don't belong in one (e.g.
+// the colons of system:* ClusterRoles)
+func resourceFileName(parts ...string) string {
+	var kept []string
+	for _, part := range parts {
+		if part != "" {
+			kept = append(kept, part)
+		}
+	}
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '-', r == '.', r == '_':
+			return r
+		}
+		return '_'
+	}, strings.ToLower(strings.Join(kept, "-")))
+}
+
+// newDirMetadata describes manifest, rendered from dir and published in root
+func newDirMetadata(root outputRoot, renderer, dir, manifest string, took time.Duration) DirMetadata {
+	p := ManifestPath(root.Output, dir)
+	if root.split {
+		p = path.Dir(p)
+	}
+	return DirMetadata{
+		Source:          filepath.ToSlash(dir),
+		Renderer:        renderer,
+		Path:            p,
+		SHA256:          fmt.Sprintf("%x", sha256.Sum256([]byte(manifest))),
+		Resources:       countKinds(manifest),
+		DurationSeconds: took.Round(time.Millisecond).Seconds(),
+	}
+}
+
+// countKinds counts the resources of a manifest by kind; documents that
+// don't parse or have no kind aren't counted
+func countKinds(manifest string) map[string]int {
+	counts := make(map[string]int)
+	for _, doc := range splitYAMLDocuments(manifest) {
+		nodes, err := decodeYAMLDocuments(doc)
+		if err != nil {
+			continue
+		}
+		for _, node := range nodes {
+			if kind := resourceKey(documentRoot(node))[2]; kind != "" {
+				counts[kind]++
+			}
+		}
+	}
+	return counts
+}
@@ -0,0 +1,352 @@
+package sync
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/erauner/homelab-shadow/pkg/config"
+)
+
+func TestManifestPath(t *testing.T) {
+	mirror := config.Output{Path: "rendered"}
+	byCluster := config.Output{Path: "previews", Layout: config.LayoutByCluster}
+
+	tests := []struct {
+		out  config.Output
+		dir  string
+		want string
+	}{
+		{mirror, "apps/coder/overlays/erauner-home/production", "apps/coder/overlays/erauner-home/production/manifest.yaml"},
+		{byCluster, "apps/coder/overlays/erauner-home/production", "erauner-home/apps/coder/overlays/erauner-home/production/manifest.yaml"},
+		{byCluster, "infrastructure/cert-manager/overlays/erauner-cloud", "erauner-cloud/infrastructure/cert-manager/overlays/erauner-cloud/manifest.yaml"},
+		{byCluster, "apps/coder/helm", "_shared/apps/coder/helm/manifest.yaml"},
+	}
+	for _, tt := range tests {
+		if got := ManifestPath(tt.out, tt.dir); got != tt.want {
+			t.Errorf("ManifestPath(%s, %q) = %q, want %q", tt.out.Layout, tt.dir, got, tt.want)
+		}
+	}
+}
+
+func TestRootsFor(t *testing.T) {
+	roots := []outputRoot{
+		newOutputRoot(config.Output{Path: "rendered", Sources: []string{config.OutputSourceKustomize}}, ""),
+		newOutputRoot(config.Output{Path: "helm", Sources: []string{config.OutputSourceHelm}}, ""),
+		newOutputRoot(config.Output{Path: "previews", Include: []string{"apps/*/overlays/**/preview"}}, ""),
+	}
+
+	paths := func(matched []outputRoot) string {
+		var names []string
+		for _, root := range matched {
+			names = append(names, root.Path)
+		}
+		return strings.Join(names, ",")
+	}
+
+	if got := paths(rootsFor(roots, config.OutputSourceKustomize, "apps/coder/overlays/erauner-home/production")); got != "rendered" {
+		t.Errorf("production overlay roots = %q", got)
+	}
+	if got := paths(rootsFor(roots, config.OutputSourceKustomize, "apps/coder/overlays/erauner-home/preview")); got != "rendered,previews" {
+		t.Errorf("preview overlay roots = %q", got)
+	}
+	if got := paths(rootsFor(roots, config.OutputSourceHelm, "apps/coder/helm")); got != "helm" {
+		t.Errorf("helm roots = %q", got)
+	}
+	if !anyRootHas(roots, config.OutputSourceHelm) || anyRootHas(roots[:1], config.OutputSourceHelm) {
+		t.Error("anyRootHas() did not match root sources")
+	}
+}
+
+func TestRun_DryRunMultipleOutputs(t *testing.T) {
+	repo := t.TempDir()
+	out := t.TempDir()
+
+	stale := filepath.Join(out, "helm", "apps", "old", "helm", "manifest.yaml")
+	unowned := filepath.Join(out, "README.md")
+	for _, path := range []string{stale, unowned} {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("failed to create dir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte("old\n"), 0644); err != nil {
+			t.Fatalf("failed to write %s: %v", path, err)
+		}
+	}
+	previousRun(t, filepath.Join(out, "helm"))
+
+	syncer, err := New(Options{
+		RepoPath: repo,
+		DryRun:   true,
+		OutDir:   out,
+		Outputs: []config.Output{
+			{Path: "rendered", Sources: []string{config.OutputSourceKustomize}},
+			{Path: "helm", Sources: []string{config.OutputSourceHelm}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	result, err := syncer.Run()
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	for _, root := range []string{"rendered", "helm"} {
+		if _, err := os.Stat(filepath.Join(out, root, "_meta.json")); err != nil {
+			t.Errorf("expected _meta.json in %s: %v", root, err)
+		}
+	}
+	if _, err := os.Stat(unowned); err != nil {
+		t.Errorf("files outside output roots should be left alone: %v", err)
+	}
+	if got := strings.Join(result.Changes.Removed, ","); got != "helm/apps/old/helm/manifest.yaml" {
+		t.Errorf("Removed = %q", got)
+	}
+}
+
+func TestNew_RejectsUnsafeOutputRoots(t *testing.T) {
+	for _, root := range []string{".", "./", "/tmp/rendered", "../rendered", ".git", ".git/rendered"} {
+		if _, err := New(Options{RepoPath: ".", ShadowRepo: "erauner/shadow", OutputRoot: root}); err == nil {
+			t.Errorf("expected error for OutputRoot %q", root)
+		}
+	}
+	if _, err := New(Options{RepoPath: ".", ShadowRepo: "erauner/shadow", Outputs: []config.Output{{Path: "."}}}); err == nil {
+		t.Error("expected error for Outputs path \".\"")
+	}
+	if _, err := New(Options{RepoPath: ".", ShadowRepo: "erauner/shadow", OutputRoot: "rendered/"}); err != nil {
+		t.Errorf("unexpected error for rendered/: %v", err)
+	}
+}
+
+func TestOutputRoot_Prune(t *testing.T) {
+	dir := t.TempDir()
+	root := newOutputRoot(config.Output{Path: "rendered"}, dir)
+
+	kept := filepath.Join(dir, "apps", "web", "overlays", "production", "manifest.yaml")
+	stale := filepath.Join(dir, "apps", "old", "overlays", "production", "manifest.yaml")
+	for _, path := range []string{kept, stale} {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("kind: ConfigMap\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	before, err := os.Stat(kept)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := root.write(kept, []byte("kind: ConfigMap\n")); err != nil {
+		t.Fatalf("write() error = %v", err)
+	}
+	pruned, err := root.prune()
+	if err != nil {
+		t.Fatalf("prune() error = %v", err)
+	}
+
+	if pruned != 1 {
+		t.Errorf("pruned = %d, want 1", pruned)
+	}
+	after, err := os.Stat(kept)
+	if err != nil {
+		t.Fatalf("rendered manifest was removed: %v", err)
+	}
+	if !after.ModTime().Equal(before.ModTime()) {
+		t.Error("unchanged manifest should not be rewritten")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "apps", "old")); !os.IsNotExist(err) {
+		t.Errorf("expected empty stale directories to be removed, got %v", err)
+	}
+	if _, err := os.Stat(dir); err != nil {
+		t.Errorf("root itself should be kept: %v", err)
+	}
+}
+
+// previousRun marks dir as the output of an earlier sync, which a dry run
+// requires before pruning a non-empty directory
+func previousRun(t *testing.T, dir string) {
+	t.Helper()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "_meta.json"), []byte("{}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRun_DryRunRefusesUnmarkedDir(t *testing.T) {
+	out := t.TempDir()
+	notes := filepath.Join(out, "notes.txt")
+	if err := os.WriteFile(notes, []byte("keep me\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	syncer, err := New(Options{RepoPath: t.TempDir(), DryRun: true, OutDir: out})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if _, err := syncer.Run(); err == nil || !strings.Contains(err.Error(), "no _meta.json") {
+		t.Errorf("Run() error = %v, want a refusal to prune", err)
+	}
+	if _, err := os.Stat(notes); err != nil {
+		t.Errorf("unrelated file was removed: %v", err)
+	}
+}
+
+func TestRun_DryRunRefusesFilesystemRoot(t *testing.T) {
+	syncer, err := New(Options{RepoPath: t.TempDir(), DryRun: true, OutDir: "/"})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if _, err := syncer.Run(); err == nil {
+		t.Error("expected error for filesystem root output directory")
+	}
+}
+
+func TestRun_DryRunKeepFailed(t *testing.T) {
+	bin := t.TempDir()
+	if err := os.WriteFile(filepath.Join(bin, "kustomize"), []byte("#!/bin/sh\necho 'Error: accumulating resources' >&2\nexit 1\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	for _, keepFailed := range []bool{false, true} {
+		repo := t.TempDir()
+		dir := "apps/web/overlays/erauner-home/production"
+		for _, p := range []string{"clusters/erauner-home", dir} {
+			if err := os.MkdirAll(filepath.Join(repo, p), 0755); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := os.WriteFile(filepath.Join(repo, dir, "kustomization.yaml"), []byte("resources: []\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		out := t.TempDir()
+		previous := filepath.Join(out, dir, "manifest.yaml")
+		if err := os.MkdirAll(filepath.Dir(previous), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(previous, []byte("kind: ConfigMap\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		previousRun(t, out)
+
+		syncer, err := New(Options{RepoPath: repo, DryRun: true, OutDir: out, KeepFailed: keepFailed})
+		if err != nil {
+			t.Fatalf("New() error = %v", err)
+		}
+		result, err := syncer.Run()
+		if err != nil {
+			t.Fatalf("Run() error = %v", err)
+		}
+		if result.FailedDirs != 1 {
+			t.Fatalf("FailedDirs = %d, want 1 (failures: %+v)", result.FailedDirs, result.Failures)
+		}
+
+		_, statErr := os.Stat(previous)
+		if keepFailed {
+			if statErr != nil || result.KeptFiles != 1 || len(result.Changes.Removed) != 0 {
+				t.Errorf("keep-failed: stat = %v, KeptFiles = %d, Removed = %v", statErr, result.KeptFiles, result.Changes.Removed)
+			}
+		} else if statErr == nil || result.KeptFiles != 0 {
+			t.Errorf("without keep-failed the failed manifest should be pruned (KeptFiles = %d)", result.KeptFiles)
+		}
+	}
+}
+
+func TestSplitManifest(t *testing.T) {
+	manifest := `apiVersion: v1
+kind: Service
+metadata:
+  name: web
+  namespace: Media
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRole
+metadata:
+  name: system:web
+---
+# comment only
+---
+kind: [broken
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+  namespace: media
+`
+	files := SplitManifest(manifest)
+	want := []string{"service-media-web.yaml", "clusterrole-system_web.yaml", "document-3.yaml", "document-4.yaml", "service-media-web-2.yaml"}
+	if len(files) != len(want) {
+		t.Fatalf("got %d files, want %d: %+v", len(files), len(want), files)
+	}
+	for i, name := range want {
+		if files[i].Name != name {
+			t.Errorf("files[%d].Name = %q, want %q", i, files[i].Name, name)
+		}
+	}
+	if files[1].Content != "apiVersion: rbac.authorization.k8s.io/v1\nkind: ClusterRole\nmetadata:\n  name: system:web\n" {
+		t.Errorf("files[1].Content = %q", files[1].Content)
+	}
+}
+
+func TestRun_DryRunSplitLayout(t *testing.T) {
+	out := t.TempDir()
+	web, failing := "apps/web/overlays/erauner-home/production", "apps/api/overlays/erauner-home/production"
+	previous := map[string]string{
+		filepath.Join(out, web, "manifest.yaml"):                "kind: ConfigMap\n",
+		filepath.Join(out, failing, "deployment-prod-api.yaml"): "kind: Deployment\n",
+	}
+	for path, content := range previous {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	previousRun(t, out)
+
+	syncer, err := New(Options{
+		RepoPath:     t.TempDir(),
+		DryRun:       true,
+		OutDir:       out,
+		OutputLayout: OutputLayoutSplit,
+		KeepFailed:   true,
+		Renderers: []Renderer{&fakeRenderer{
+			source: config.OutputSourceKustomize,
+			manifests: map[string]string{
+				web: "apiVersion: v1\nkind: Service\nmetadata:\n  name: web\n  namespace: prod\n---\napiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: web\n  namespace: prod\n",
+			},
+			failures: map[string]error{failing: errors.New("build failed")},
+		}},
+	})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	result, err := syncer.Run()
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	for _, name := range []string{"service-prod-web.yaml", "deployment-prod-web.yaml"} {
+		if _, err := os.Stat(filepath.Join(out, web, name)); err != nil {
+			t.Errorf("expected %s: %v", name, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(out, web, "manifest.yaml")); !os.IsNotExist(err) {
+		t.Errorf("expected manifest.yaml to be pruned in split layout, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(out, failing, "deployment-prod-api.yaml")); err != nil || result.KeptFiles != 1 {
+		t.Errorf("keep-failed: stat = %v, KeptFiles = %d", err, result.KeptFiles)
+	}
+
+	if _, err := New(Options{RepoPath: ".", DryRun: true, OutDir: out, OutputLayout: "tree"}); err == nil {
+		t.Error("expected error for unknown output layout")
+	}
+}
@@ -0,0 +1,175 @@
+package sync
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/erauner/homelab-shadow/pkg/config"
+)
+
+// ProvenanceFile is the in-toto provenance attestation in each output root
+// (with Provenance)
+const ProvenanceFile = "_provenance.json"
+
+// In-toto statement and SLSA provenance identifiers
+const (
+	inTotoStatementType = "https://in-toto.io/Statement/v1"
+	slsaProvenanceType  = "https://slsa.dev/provenance/v1"
+	shadowBuildType     = "https://github.com/erauner/homelab-shadow/sync/v1"
+	shadowBuilderID     = "https://github.com/erauner/homelab-shadow"
+)
+
+// Statement is an in-toto v1 statement: its subjects are the manifests
+// rendered into an output root and its predicate how they were rendered
+type Statement struct {
+	Type          string         `json:"_type"`
+	Subject       []Subject      `json:"subject"`
+	PredicateType string         `json:"predicateType"`
+	Predicate     SLSAProvenance `json:"predicate"`
+}
+
+// Subject is one rendered directory: its manifest path in the root (its
+// directory with the split layout) and the digest _meta.json records for it
+type Subject struct {
+	Name   string            `json:"name"`
+	Digest map[string]string `json:"digest"`
+}
+
+// SLSAProvenance is a SLSA v1 provenance predicate
+type SLSAProvenance struct {
+	BuildDefinition BuildDefinition `json:"buildDefinition"`
+	RunDetails      RunDetails      `json:"runDetails"`
+}
+
+// BuildDefinition records what was rendered: the source commit and the
+// options that change the output
+type BuildDefinition struct {
+	BuildType            string                 `json:"buildType"`
+	ExternalParameters   ProvenanceParameters   `json:"externalParameters"`
+	ResolvedDependencies []ResourceDescriptor   `json:"resolvedDependencies,omitempty"`
+	InternalParameters   map[string]interface{} `json:"internalParameters,omitempty"`
+}
+
+// ProvenanceParameters are the sync inputs a reviewer controls
+type ProvenanceParameters struct {
+	SourceRepo   string   `json:"sourceRepo,omitempty"`
+	SourceCommit string   `json:"sourceCommit,omitempty"`
+	PRNumber     string   `json:"pr,omitempty"`
+	Branch       string   `json:"branch"`
+	Clusters     []string `json:"clusters,omitempty"`
+	Output       string   `json:"output"` // the output root's path
+}
+
+// ResourceDescriptor identifies an input artifact
+type ResourceDescriptor struct {
+	URI    string            `json:"uri"`
+	Digest map[string]string `json:"digest,omitempty"`
+}
+
+// RunDetails records who rendered it, with which tool versions, and when
+type RunDetails struct {
+	Builder  Builder       `json:"builder"`
+	Metadata BuildMetadata `json:"metadata"`
+}
+
+// Builder is shadow, with the versions of it and the tools it ran
+type Builder struct {
+	ID      string            `json:"id"`
+	Version map[string]string `json:"version"`
+}
+
+// BuildMetadata is when the sync started and finished rendering
+type BuildMetadata struct {
+	StartedOn  string `json:"startedOn"`
+	FinishedOn string `json:"finishedOn"`
+}
+
+// provenance returns the statement for an output root given its _meta.json
+// directory entries; directories left over from earlier syncs (kept failed or
+// skipped over budget) aren't subjects
+func (s *Syncer) provenance(root outputRoot, dirs []DirMetadata, tools map[string]string) Statement {
+	subjects := make([]Subject, 0, len(dirs))
+	for _, d := range dirs {
+		subjects = append(subjects, Subject{Name: d.Path, Digest: map[string]string{"sha256": d.SHA256}})
+	}
+
+	var deps []ResourceDescriptor
+	if s.opts.SourceCommit != "" {
+		uri := "git+" + s.opts.SourceRepo
+		if s.source.Slug != "" {
+			uri = "git+" + s.source.GitURL()
+		}
+		deps = append(deps, ResourceDescriptor{
+			URI:    uri + "@" + s.opts.SourceCommit,
+			Digest: map[string]string{"gitCommit": s.opts.SourceCommit},
+		})
+	}
+
+	layout, fileLayout := root.Layout, OutputLayoutSingle
+	if layout == "" {
+		layout = config.LayoutMirror
+	}
+	if root.split {
+		fileLayout = OutputLayoutSplit
+	}
+	internal := map[string]interface{}{
+		"layout":       layout,
+		"outputLayout": fileLayout,
+		"normalize":    !s.opts.DisableNormalize,
+		"redact":       !s.opts.DisableRedaction,
+	}
+	if s.opts.KustomizeEngine != "" {
+		internal["kustomizeEngine"] = string(s.opts.KustomizeEngine)
+	}
+	if s.opts.KubernetesVersion != "" {
+		internal["kubernetesVersion"] = s.opts.KubernetesVersion
+	}
+
+	return Statement{
+		Type:          inTotoStatementType,
+		Subject:       subjects,
+		PredicateType: slsaProvenanceType,
+		Predicate: SLSAProvenance{
+			BuildDefinition: BuildDefinition{
+				BuildType: shadowBuildType,
+				ExternalParameters: ProvenanceParameters{
+					SourceRepo:   s.opts.SourceRepo,
+					SourceCommit: s.opts.SourceCommit,
+					PRNumber:     s.opts.PRNumber,
+					Branch:       s.opts.Branch,
+					Clusters:     s.opts.Clusters,
+					Output:       root.Path,
+				},
+				ResolvedDependencies: deps,
+				InternalParameters:   internal,
+			},
+			RunDetails: RunDetails{
+				Builder: Builder{ID: shadowBuilderID, Version: tools},
+				Metadata: BuildMetadata{
+					StartedOn:  s.started.UTC().Format(time.RFC3339),
+					FinishedOn: time.Now().UTC().Format(time.RFC3339),
+				},
+			},
+		},
+	}
+}
+
+// writeProvenance writes _provenance.json into each output root
+func (s *Syncer) writeProvenance(roots []outputRoot, dirMeta map[string][]DirMetadata) error {
+	tools := toolVersions()
+	if s.opts.Version != "" {
+		tools["shadow"] = s.opts.Version
+	}
+	for _, root := range roots {
+		data, err := json.MarshalIndent(s.provenance(root, dirMeta[root.dir], tools), "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal provenance: %w", err)
+		}
+		if err := root.write(filepath.Join(root.dir, ProvenanceFile), data); err != nil {
+			return fmt.Errorf("failed to write provenance: %w", err)
+		}
+	}
+	return nil
+}
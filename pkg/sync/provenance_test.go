@@ -0,0 +1,127 @@
+package sync
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRun_Provenance(t *testing.T) {
+	out := t.TempDir()
+	syncer, err := New(Options{
+		RepoPath:     t.TempDir(),
+		DryRun:       true,
+		OutDir:       out,
+		Provenance:   true,
+		SourceRepo:   "erauner/homelab-k8s",
+		SourceCommit: "abc1234",
+		PRNumber:     "5",
+		Version:      "v1.2.3",
+		Renderers:    []Renderer{publishRenderer("web")},
+	})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	result, err := syncer.Run()
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if result.Changes == nil || len(result.Changes.Added) != 1 {
+		t.Errorf("_provenance.json shouldn't count as a change, got %+v", result.Changes)
+	}
+
+	data, err := os.ReadFile(filepath.Join(out, ProvenanceFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var statement Statement
+	if err := json.Unmarshal(data, &statement); err != nil {
+		t.Fatalf("invalid provenance: %v", err)
+	}
+	if statement.Type != inTotoStatementType || statement.PredicateType != slsaProvenanceType {
+		t.Errorf("unexpected statement types %q, %q", statement.Type, statement.PredicateType)
+	}
+	manifest, err := os.ReadFile(filepath.Join(out, "apps/web/overlays/production/manifest.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Subject{Name: "apps/web/overlays/production/manifest.yaml", Digest: map[string]string{"sha256": fmt.Sprintf("%x", sha256.Sum256(manifest))}}
+	if len(statement.Subject) != 1 || statement.Subject[0].Name != want.Name || statement.Subject[0].Digest["sha256"] != want.Digest["sha256"] {
+		t.Errorf("subjects = %+v, want %+v", statement.Subject, want)
+	}
+	build := statement.Predicate.BuildDefinition
+	if build.ExternalParameters.SourceCommit != "abc1234" || build.ExternalParameters.Branch != "pr-5" {
+		t.Errorf("external parameters = %+v", build.ExternalParameters)
+	}
+	if len(build.ResolvedDependencies) != 1 || build.ResolvedDependencies[0].Digest["gitCommit"] != "abc1234" {
+		t.Errorf("resolved dependencies = %+v", build.ResolvedDependencies)
+	}
+	if v := statement.Predicate.RunDetails.Builder.Version; v["shadow"] != "v1.2.3" || v["go"] == "" {
+		t.Errorf("builder versions = %v", v)
+	}
+}
+
+func TestRun_SignedCommits(t *testing.T) {
+	if _, err := exec.LookPath("ssh-keygen"); err != nil {
+		t.Skip("ssh-keygen not installed")
+	}
+	remote := newShadowRemote(t)
+	if out, err := exec.Command("git", "-C", remote, "symbolic-ref", "HEAD", "refs/heads/main").CombinedOutput(); err != nil {
+		t.Fatalf("git symbolic-ref: %v\n%s", err, out)
+	}
+	for _, key := range []string{"GIT_AUTHOR_NAME", "GIT_COMMITTER_NAME"} {
+		t.Setenv(key, "test")
+	}
+	for _, key := range []string{"GIT_AUTHOR_EMAIL", "GIT_COMMITTER_EMAIL"} {
+		t.Setenv(key, "test@example.com")
+	}
+	key := filepath.Join(t.TempDir(), "id_ed25519")
+	if out, err := exec.Command("ssh-keygen", "-q", "-t", "ed25519", "-N", "", "-f", key).CombinedOutput(); err != nil {
+		t.Fatalf("ssh-keygen: %v\n%s", err, out)
+	}
+
+	syncer, err := New(Options{
+		RepoPath:    t.TempDir(),
+		ShadowRepo:  "file://" + remote,
+		PRNumber:    "6",
+		SignCommits: SignSSH,
+		SigningKey:  key,
+		Provenance:  true,
+		Renderers:   []Renderer{publishRenderer("web")},
+	})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if _, err := syncer.Run(); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	commit, err := exec.Command("git", "-C", remote, "cat-file", "-p", "pr-6").CombinedOutput()
+	if err != nil || !strings.Contains(string(commit), "-----BEGIN SSH SIGNATURE-----") {
+		t.Errorf("pr-6 commit should be SSH-signed:\n%s", commit)
+	}
+	if out, err := exec.Command("git", "-C", remote, "show", "pr-6:rendered/"+ProvenanceFile).CombinedOutput(); err != nil || !strings.Contains(string(out), slsaProvenanceType) {
+		t.Errorf("provenance should be committed alongside the manifests:\n%s", out)
+	}
+}
+
+func TestNew_Signing(t *testing.T) {
+	for name, opts := range map[string]Options{
+		"unknown format":  {SignCommits: "x509"},
+		"key only":        {SigningKey: "ABCD"},
+		"ssh without key": {SignCommits: SignSSH},
+		"ssh key missing": {SignCommits: SignSSH, SigningKey: filepath.Join(t.TempDir(), "missing")},
+		"dir publisher":   {SignCommits: SignSSH, SigningKey: "key::ssh-ed25519 AAAA", Publish: PublishDir, PublishTo: "out"},
+	} {
+		opts.RepoPath = "."
+		opts.DryRun, opts.OutDir = opts.Publish == "", "out"
+		if _, err := New(opts); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
@@ -0,0 +1,329 @@
+package sync
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// Provider is a git hosting provider
+type Provider string
+
+// Supported providers
+const (
+	ProviderGitHub Provider = "github"
+	ProviderGitLab Provider = "gitlab"
+	ProviderGitea  Provider = "gitea"
+)
+
+// ParseProvider converts a flag value into a Provider ("" detects it from the repo URL)
+func ParseProvider(s string) (Provider, error) {
+	switch Provider(s) {
+	case "", ProviderGitHub, ProviderGitLab, ProviderGitea:
+		return Provider(s), nil
+	default:
+		return "", fmt.Errorf("unknown provider %q (expected github, gitlab, or gitea)", s)
+	}
+}
+
+// defaultHosts are the hosts bare owner/repo slugs resolve to per provider
+var defaultHosts = map[Provider]string{
+	ProviderGitHub: "github.com",
+	ProviderGitLab: "gitlab.com",
+	ProviderGitea:  "gitea.com",
+}
+
+// tokenEnv names the environment variable holding each provider's API and push token
+var tokenEnv = map[Provider]string{
+	ProviderGitHub: "GH_TOKEN",
+	ProviderGitLab: "GITLAB_TOKEN",
+	ProviderGitea:  "GITEA_TOKEN",
+}
+
+// detectProvider guesses the provider from a host, defaulting to GitHub
+func detectProvider(host string) Provider {
+	host = strings.ToLower(host)
+	switch {
+	case strings.Contains(host, "gitlab"):
+		return ProviderGitLab
+	case strings.Contains(host, "gitea"), strings.Contains(host, "codeberg"), strings.Contains(host, "forgejo"):
+		return ProviderGitea
+	default:
+		return ProviderGitHub
+	}
+}
+
+// Remote is a repository on a git hosting provider
+type Remote struct {
+	Provider Provider
+	Host     string // e.g. github.com or gitlab.example.com
+	Slug     string // owner/repo (GitLab: group/subgroup/repo)
+
+	url    string // the git URL given, if any (kept so SSH remotes stay SSH)
+	scheme string // web and API scheme (http only when the URL given used it)
+}
+
+// sshRemote matches git@host:owner/repo(.git)
+var sshRemote = regexp.MustCompile(`^git@([^:]+):(.+?)(?:\.git)?$`)
+
+// ParseRemote parses a slug (owner/repo), HTTPS URL, SSH URL, or file:// URL
+// An empty provider is detected from the host; a bare slug uses the provider's public host
+func ParseRemote(repo string, provider Provider) (Remote, error) {
+	remote := Remote{Provider: provider, scheme: "https"}
+
+	switch {
+	case strings.HasPrefix(repo, "https://") || strings.HasPrefix(repo, "http://") || strings.HasPrefix(repo, "file://"):
+		u, err := url.Parse(repo)
+		if err != nil {
+			return Remote{}, fmt.Errorf("invalid URL: %w", err)
+		}
+		remote.Host = u.Host
+		remote.scheme = u.Scheme
+		remote.Slug = strings.TrimSuffix(strings.Trim(u.Path, "/"), ".git")
+		remote.url = repo
+	case strings.HasPrefix(repo, "git@"):
+		matches := sshRemote.FindStringSubmatch(repo)
+		if matches == nil {
+			return Remote{}, fmt.Errorf("cannot parse repo slug from: %s", repo)
+		}
+		remote.Host = matches[1]
+		remote.Slug = matches[2]
+		remote.url = repo
+	default:
+		if remote.Provider == "" {
+			remote.Provider = ProviderGitHub
+		}
+		remote.Host = defaultHosts[remote.Provider]
+		remote.Slug = strings.TrimSuffix(strings.Trim(repo, "/"), ".git")
+	}
+
+	if !strings.Contains(remote.Slug, "/") {
+		return Remote{}, fmt.Errorf("cannot parse repo slug from: %s", repo)
+	}
+	if remote.Provider == "" {
+		remote.Provider = detectProvider(remote.Host)
+	}
+	return remote, nil
+}
+
+// GitURL returns the URL to clone, the original URL when one was given
+func (r Remote) GitURL() string {
+	if r.url != "" {
+		return r.url
+	}
+	return fmt.Sprintf("%s://%s/%s.git", r.scheme, r.Host, r.Slug)
+}
+
+// TokenEnv names the environment variable holding the provider's API and push token
+func (r Remote) TokenEnv() string {
+	return tokenEnv[r.Provider]
+}
+
+// authURL returns GitURL with the provider token injected into HTTPS URLs
+func (r Remote) authURL() string {
+	gitURL := r.GitURL()
+	token := os.Getenv(tokenEnv[r.Provider])
+	prefix := r.scheme + "://" + r.Host + "/"
+	if token == "" || r.Host == "" || !strings.HasPrefix(gitURL, prefix) {
+		return gitURL
+	}
+
+	var userinfo string
+	switch r.Provider {
+	case ProviderGitLab:
+		userinfo = "oauth2:" + token
+	case ProviderGitea:
+		userinfo = token
+	default:
+		userinfo = "x-access-token:" + token
+	}
+	return r.scheme + "://" + userinfo + "@" + strings.TrimPrefix(gitURL, r.scheme+"://")
+}
+
+// sourceRemote parses a source repo, giving a bare slug the shadow repo's
+// provider and host (they are usually hosted side by side)
+func sourceRemote(repo string, provider Provider, shadow Remote) (Remote, error) {
+	source, err := ParseRemote(repo, provider)
+	if err != nil {
+		return Remote{}, err
+	}
+	if source.url == "" && shadow.Host != "" {
+		source.Provider = shadow.Provider
+		source.Host = shadow.Host
+		source.scheme = shadow.scheme
+	}
+	return source, nil
+}
+
+// CompareURL returns the provider's web page comparing head against base
+func (r Remote) CompareURL(baseBranch, headBranch string) string {
+	if r.Provider == ProviderGitLab {
+		return fmt.Sprintf("%s://%s/%s/-/compare/%s...%s", r.scheme, r.Host, r.Slug, baseBranch, headBranch)
+	}
+	return fmt.Sprintf("%s://%s/%s/compare/%s...%s", r.scheme, r.Host, r.Slug, baseBranch, headBranch)
+}
+
+// apiURL returns the provider's REST API base URL
+func (r Remote) apiURL() string {
+	base := r.scheme + "://" + r.Host
+	switch r.Provider {
+	case ProviderGitLab:
+		return base + "/api/v4"
+	case ProviderGitea:
+		return base + "/api/v1"
+	default:
+		if r.Host == "github.com" {
+			return githubAPIURL
+		}
+		return base + "/api/v3" // GitHub Enterprise Server
+	}
+}
+
+// PRState returns "open", "closed", "merged", or "not_found" for a pull
+// request (GitLab: merge request) in the repo
+func (r Remote) PRState(ctx context.Context, number string) (string, error) {
+	var path string
+	switch r.Provider {
+	case ProviderGitLab:
+		path = fmt.Sprintf("/projects/%s/merge_requests/%s", url.PathEscape(r.Slug), number)
+	default:
+		path = fmt.Sprintf("/repos/%s/pulls/%s", r.Slug, number)
+	}
+
+	req, err := r.apiRequest(ctx, path)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := apiClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == 404 {
+		// PR doesn't exist - treat as closed
+		return "not_found", nil
+	}
+	if resp.StatusCode != 200 {
+		return "", fmt.Errorf("%s API returned %d", r.Provider, resp.StatusCode)
+	}
+
+	var pr struct {
+		State    string `json:"state"`
+		MergedAt string `json:"merged_at"`
+		Merged   bool   `json:"merged"` // Gitea
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&pr); err != nil {
+		return "", err
+	}
+
+	switch {
+	case pr.MergedAt != "" || pr.Merged || pr.State == "merged":
+		return "merged", nil
+	case pr.State == "opened", pr.State == "locked": // GitLab (locked while merging)
+		return "open", nil
+	}
+	return pr.State, nil
+}
+
+// apiRequest builds a GET request for an API path, authenticated with the
+// provider token when set
+func (r Remote) apiRequest(ctx context.Context, path string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", r.apiURL()+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "shadow-sync")
+	// Use the token if available for higher rate limits and private repo access
+	// Fix for https://github.com/erauner/homelab-k8s/issues/1272
+	if token := os.Getenv(tokenEnv[r.Provider]); token != "" {
+		switch r.Provider {
+		case ProviderGitLab:
+			req.Header.Set("PRIVATE-TOKEN", token)
+		default:
+			req.Header.Set("Authorization", "token "+token)
+		}
+	}
+	if r.Provider == ProviderGitHub {
+		req.Header.Set("Accept", "application/vnd.github.v3+json")
+	}
+	return req, nil
+}
+
+// apiGet decodes an API response into v, failing on any status but 200
+func (r Remote) apiGet(ctx context.Context, path string, v interface{}) error {
+	req, err := r.apiRequest(ctx, path)
+	if err != nil {
+		return err
+	}
+	resp, err := apiClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("%s API returned %d for %s", r.Provider, resp.StatusCode, path)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// TokenUser returns the login the provider token authenticates as
+// It fails when the token is unset, expired, or revoked
+func (r Remote) TokenUser(ctx context.Context) (string, error) {
+	if os.Getenv(tokenEnv[r.Provider]) == "" {
+		return "", fmt.Errorf("%s is not set", tokenEnv[r.Provider])
+	}
+	var user struct {
+		Login    string `json:"login"`
+		Username string `json:"username"` // GitLab
+	}
+	if err := r.apiGet(ctx, "/user", &user); err != nil {
+		return "", err
+	}
+	if user.Username != "" {
+		return user.Username, nil
+	}
+	return user.Login, nil
+}
+
+// gitLabDeveloper is the lowest GitLab access level allowed to push
+const gitLabDeveloper = 30
+
+// CanPush reports whether the provider token may push to the repo
+// It fails when the repo doesn't exist or the token can't see it
+func (r Remote) CanPush(ctx context.Context) (bool, error) {
+	if r.Provider == ProviderGitLab {
+		var project struct {
+			Permissions struct {
+				ProjectAccess *struct {
+					AccessLevel int `json:"access_level"`
+				} `json:"project_access"`
+				GroupAccess *struct {
+					AccessLevel int `json:"access_level"`
+				} `json:"group_access"`
+			} `json:"permissions"`
+		}
+		if err := r.apiGet(ctx, "/projects/"+url.PathEscape(r.Slug), &project); err != nil {
+			return false, err
+		}
+		p := project.Permissions
+		return (p.ProjectAccess != nil && p.ProjectAccess.AccessLevel >= gitLabDeveloper) ||
+			(p.GroupAccess != nil && p.GroupAccess.AccessLevel >= gitLabDeveloper), nil
+	}
+
+	var repo struct {
+		Permissions struct {
+			Push bool `json:"push"`
+		} `json:"permissions"`
+	}
+	if err := r.apiGet(ctx, "/repos/"+r.Slug, &repo); err != nil {
+		return false, err
+	}
+	return repo.Permissions.Push, nil
+}
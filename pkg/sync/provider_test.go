@@ -0,0 +1,217 @@
+package sync
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestParseRemote(t *testing.T) {
+	tests := []struct {
+		repo     string
+		provider Provider
+		want     Remote
+		compare  string
+	}{
+		{
+			repo:    "owner/repo",
+			want:    Remote{Provider: ProviderGitHub, Host: "github.com", Slug: "owner/repo"},
+			compare: "https://github.com/owner/repo/compare/main...pr-1",
+		},
+		{
+			repo:     "group/sub/repo",
+			provider: ProviderGitLab,
+			want:     Remote{Provider: ProviderGitLab, Host: "gitlab.com", Slug: "group/sub/repo"},
+			compare:  "https://gitlab.com/group/sub/repo/-/compare/main...pr-1",
+		},
+		{
+			repo:    "https://gitlab.example.com/infra/k8s-shadow.git",
+			want:    Remote{Provider: ProviderGitLab, Host: "gitlab.example.com", Slug: "infra/k8s-shadow"},
+			compare: "https://gitlab.example.com/infra/k8s-shadow/-/compare/main...pr-1",
+		},
+		{
+			repo:    "git@codeberg.org:owner/repo.git",
+			want:    Remote{Provider: ProviderGitea, Host: "codeberg.org", Slug: "owner/repo"},
+			compare: "https://codeberg.org/owner/repo/compare/main...pr-1",
+		},
+		{
+			repo:     "https://git.home.lan/owner/repo",
+			provider: ProviderGitea,
+			want:     Remote{Provider: ProviderGitea, Host: "git.home.lan", Slug: "owner/repo"},
+			compare:  "https://git.home.lan/owner/repo/compare/main...pr-1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.repo, func(t *testing.T) {
+			got, err := ParseRemote(tt.repo, tt.provider)
+			if err != nil {
+				t.Fatalf("ParseRemote() error = %v", err)
+			}
+			if got.Provider != tt.want.Provider || got.Host != tt.want.Host || got.Slug != tt.want.Slug {
+				t.Errorf("ParseRemote() = %+v, want %+v", got, tt.want)
+			}
+			if compare := got.CompareURL("main", "pr-1"); compare != tt.compare {
+				t.Errorf("CompareURL() = %q, want %q", compare, tt.compare)
+			}
+		})
+	}
+
+	if _, err := ParseRemote("repo", ""); err == nil {
+		t.Error("expected error for slug without owner")
+	}
+}
+
+func TestParseProvider(t *testing.T) {
+	for _, s := range []string{"", "github", "gitlab", "gitea"} {
+		if _, err := ParseProvider(s); err != nil {
+			t.Errorf("ParseProvider(%q) error = %v", s, err)
+		}
+	}
+	if _, err := ParseProvider("bitbucket"); err == nil {
+		t.Error("expected error for unknown provider")
+	}
+}
+
+func TestRemote_AuthURL(t *testing.T) {
+	t.Setenv("GH_TOKEN", "gh")
+	t.Setenv("GITLAB_TOKEN", "gl")
+	t.Setenv("GITEA_TOKEN", "gt")
+
+	tests := []struct {
+		repo     string
+		provider Provider
+		want     string
+	}{
+		{"owner/repo", "", "https://x-access-token:gh@github.com/owner/repo.git"},
+		{"https://gitlab.example.com/infra/shadow.git", "", "https://oauth2:gl@gitlab.example.com/infra/shadow.git"},
+		{"owner/repo", ProviderGitea, "https://gt@gitea.com/owner/repo.git"},
+		{"git@gitlab.com:infra/shadow.git", "", "git@gitlab.com:infra/shadow.git"},
+	}
+	for _, tt := range tests {
+		remote, err := ParseRemote(tt.repo, tt.provider)
+		if err != nil {
+			t.Fatalf("ParseRemote(%q) error = %v", tt.repo, err)
+		}
+		if got := remote.authURL(); got != tt.want {
+			t.Errorf("authURL(%q) = %q, want %q", tt.repo, got, tt.want)
+		}
+	}
+}
+
+func TestRemote_PRState(t *testing.T) {
+	responses := map[string]string{
+		"/api/v4/projects/infra%2Fk8s/merge_requests/1": `{"state": "opened"}`,
+		"/api/v4/projects/infra%2Fk8s/merge_requests/2": `{"state": "merged", "merged_at": "2026-01-02T00:00:00Z"}`,
+		"/api/v4/projects/infra%2Fk8s/merge_requests/3": `{"state": "closed"}`,
+		"/api/v1/repos/infra/k8s/pulls/1":               `{"state": "open", "merged": false}`,
+		"/api/v1/repos/infra/k8s/pulls/2":               `{"state": "closed", "merged": true}`,
+		"/api/v3/repos/infra/k8s/pulls/2":               `{"state": "closed", "merged_at": "2026-01-02T00:00:00Z"}`,
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, ok := responses[r.URL.EscapedPath()]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		provider Provider
+		number   string
+		want     string
+	}{
+		{ProviderGitLab, "1", "open"},
+		{ProviderGitLab, "2", "merged"},
+		{ProviderGitLab, "3", "closed"},
+		{ProviderGitLab, "4", "not_found"},
+		{ProviderGitea, "1", "open"},
+		{ProviderGitea, "2", "merged"},
+		{ProviderGitHub, "2", "merged"}, // GitHub Enterprise Server (/api/v3)
+	}
+	for _, tt := range tests {
+		remote, err := ParseRemote(server.URL+"/infra/k8s.git", tt.provider)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := remote.PRState(context.Background(), tt.number)
+		if err != nil {
+			t.Fatalf("%s PRState(%s) error = %v", tt.provider, tt.number, err)
+		}
+		if got != tt.want {
+			t.Errorf("%s PRState(%s) = %q, want %q", tt.provider, tt.number, got, tt.want)
+		}
+	}
+}
+
+func TestSyncer_SourceRepoInheritsShadowHost(t *testing.T) {
+	syncer, err := New(Options{
+		RepoPath:   ".",
+		ShadowRepo: "https://gitlab.example.com/infra/k8s-shadow.git",
+		SourceRepo: "infra/k8s",
+	})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if syncer.source.Provider != ProviderGitLab || syncer.source.Host != "gitlab.example.com" {
+		t.Errorf("source = %+v, want gitlab.example.com on GitLab", syncer.source)
+	}
+}
+
+func TestRemote_TokenUserAndCanPush(t *testing.T) {
+	responses := map[string]string{
+		"/api/v3/user":                      `{"login": "shadow-bot"}`,
+		"/api/v3/repos/infra/k8s":           `{"permissions": {"push": true}}`,
+		"/api/v3/repos/infra/readonly":      `{"permissions": {"push": false}}`,
+		"/api/v4/user":                      `{"username": "shadow-bot"}`,
+		"/api/v4/projects/infra%2Fk8s":      `{"permissions": {"project_access": null, "group_access": {"access_level": 30}}}`,
+		"/api/v4/projects/infra%2Freporter": `{"permissions": {"project_access": {"access_level": 20}}}`,
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, ok := responses[r.URL.EscapedPath()]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(body))
+	}))
+	defer server.Close()
+	t.Setenv("GH_TOKEN", "gh-token")
+	t.Setenv("GITLAB_TOKEN", "gl-token")
+
+	tests := []struct {
+		provider Provider
+		slug     string
+		push     bool
+		wantErr  bool
+	}{
+		{ProviderGitHub, "infra/k8s", true, false},
+		{ProviderGitHub, "infra/readonly", false, false},
+		{ProviderGitHub, "infra/missing", false, true},
+		{ProviderGitLab, "infra/k8s", true, false},
+		{ProviderGitLab, "infra/reporter", false, false},
+	}
+	for _, tt := range tests {
+		remote, err := ParseRemote(server.URL+"/"+tt.slug+".git", tt.provider)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if user, err := remote.TokenUser(context.Background()); err != nil || user != "shadow-bot" {
+			t.Errorf("%s TokenUser() = %q, %v", tt.provider, user, err)
+		}
+		push, err := remote.CanPush(context.Background())
+		if (err != nil) != tt.wantErr || push != tt.push {
+			t.Errorf("%s CanPush(%s) = %v, %v; want %v (error: %v)", tt.provider, tt.slug, push, err, tt.push, tt.wantErr)
+		}
+	}
+
+	t.Setenv("GH_TOKEN", "")
+	remote, _ := ParseRemote(server.URL+"/infra/k8s.git", ProviderGitHub)
+	if _, err := remote.TokenUser(context.Background()); err == nil || !strings.Contains(err.Error(), "GH_TOKEN is not set") {
+		t.Errorf("TokenUser() without a token error = %v", err)
+	}
+}
@@ -0,0 +1,359 @@
+package sync
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/erauner/homelab-shadow/pkg/command"
+)
+
+// Publish backends: where a sync stores its rendered output
+const (
+	// PublishGit commits and pushes to a shadow repo branch (default)
+	PublishGit = "git"
+	// PublishDir copies into <PublishTo>/<branch> on the local filesystem
+	PublishDir = "dir"
+	// PublishS3 syncs to <PublishTo>/<branch> in an s3:// bucket (aws CLI)
+	// or a gs:// bucket (gsutil)
+	PublishS3 = "s3"
+)
+
+// Publisher stores rendered output where reviewers compare it
+type Publisher interface {
+	// Name identifies the backend in logs
+	Name() string
+
+	// Checkout returns a local working directory holding the base branch's
+	// output, whatever was published for the branch before; sync renders
+	// into it, pruning and computing changes against it, so every backend
+	// measures a PR against base
+	Checkout(ctx context.Context) (string, error)
+
+	// Publish stores the working directory, recording the commit, compare
+	// URL, or location in result
+	Publish(ctx context.Context, dir string, result *Result) error
+
+	// Close removes the working directory and anything else temporary
+	Close() error
+}
+
+// newPublisher returns the Publisher for the Publish option
+func (s *Syncer) newPublisher() (Publisher, error) {
+	switch s.opts.Publish {
+	case "", PublishGit:
+		return &gitPublisher{s: s}, nil
+	case PublishDir:
+		return &dirPublisher{root: s.opts.PublishTo, base: s.opts.BaseBranch, branch: s.opts.Branch}, nil
+	case PublishS3:
+		return newObjectPublisher(s.opts.PublishTo, s.opts.BaseBranch, s.opts.Branch)
+	}
+	return nil, fmt.Errorf("unknown publish backend %q (expected git, dir, or s3)", s.opts.Publish)
+}
+
+// validatePublish checks the Publish options New is given
+func validatePublish(opts Options) error {
+	switch opts.Publish {
+	case "", PublishGit:
+		if opts.PublishTo != "" {
+			return fmt.Errorf("PublishTo is only used with the dir and s3 backends (git publishes to ShadowRepo)")
+		}
+		return nil
+	case PublishDir, PublishS3:
+		if opts.PublishTo == "" {
+			return fmt.Errorf("PublishTo is required with the %s backend", opts.Publish)
+		}
+		if opts.ShadowRepo != "" || len(opts.ShadowRepos) > 0 {
+			return fmt.Errorf("shadow repos are only used with the git backend, not %s", opts.Publish)
+		}
+		if opts.CleanupMerged {
+			return fmt.Errorf("cleaning up merged PR branches is only supported with the git backend")
+		}
+		if opts.SignCommits != "" {
+			return fmt.Errorf("commit signing is only supported with the git backend")
+		}
+	default:
+		return fmt.Errorf("unknown publish backend %q (expected git, dir, or s3)", opts.Publish)
+	}
+	// A dry run renders into OutDir without the bucket's CLI
+	if opts.Publish == PublishS3 && !opts.DryRun {
+		_, err := newObjectPublisher(opts.PublishTo, opts.BaseBranch, opts.Branch)
+		return err
+	}
+	return nil
+}
+
+// gitPublisher commits the output to a shadow repo branch and pushes it
+type gitPublisher struct {
+	s       *Syncer
+	tempDir string
+}
+
+func (p *gitPublisher) Name() string { return PublishGit }
+
+// Checkout clones the shadow repo and checks out the branch, reset to the
+// base branch (a force push then replaces what it held)
+func (p *gitPublisher) Checkout(ctx context.Context) (string, error) {
+	tempDir, err := os.MkdirTemp("", "shadow-sync-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	p.tempDir = tempDir
+
+	s := p.s
+	shadowDir := filepath.Join(tempDir, "shadow")
+	s.log.Debugf("Cloning shadow repo %s to %s", s.shadow.GitURL(), shadowDir)
+	if err := s.git.Clone(ctx, s.shadow.authURL(), shadowDir, s.gitRetry()); err != nil {
+		return "", fmt.Errorf("failed to clone shadow repo: %w", err)
+	}
+	if s.opts.SignCommits != "" {
+		if err := ConfigureSigning(shadowDir, s.opts.SignCommits, s.opts.SigningKey); err != nil {
+			return "", err
+		}
+	}
+	s.log.Debugf("Checking out branch %s (base: %s)", s.opts.Branch, s.opts.BaseBranch)
+	if err := s.git.CheckoutBranch(shadowDir, s.opts.BaseBranch, s.opts.Branch); err != nil {
+		return "", fmt.Errorf("failed to checkout branch: %w", err)
+	}
+	return shadowDir, nil
+}
+
+// Publish commits, pushes, and cleans up merged PR branches if requested
+func (p *gitPublisher) Publish(ctx context.Context, shadowDir string, result *Result) error {
+	s := p.s
+	start := time.Now()
+	changed, sha, err := s.git.CommitAll(shadowDir, s.buildCommitMessage())
+	if err != nil {
+		return fmt.Errorf("failed to commit changes: %w", err)
+	}
+	result.timePhase("commit", start)
+
+	if !changed {
+		s.log.Debugf("No changes to commit")
+	} else {
+		result.CommitSHA = sha
+		s.log.Debugf("Committed changes: %s", sha)
+	}
+
+	s.log.Debugf("Pushing to origin/%s (force=%v)", s.opts.Branch, !s.opts.DisableForcePush)
+	start = time.Now()
+	if err := s.git.Push(ctx, shadowDir, s.opts.Branch, !s.opts.DisableForcePush, s.gitRetry()); err != nil {
+		return fmt.Errorf("failed to push: %w", err)
+	}
+	result.timePhase("push", start)
+
+	// The base branch has nothing to compare against
+	if s.opts.SyncTarget != SyncTargetBase {
+		result.CompareURL = s.shadow.CompareURL(s.opts.BaseBranch, s.opts.Branch)
+	}
+
+	if s.opts.CleanupMerged && s.source.Slug != "" {
+		s.log.Debugf("Running cleanup for merged PR branches...")
+		start = time.Now()
+		var cleanupResult CleanupResult
+		branches, err := s.git.Branches(shadowDir)
+		if err == nil {
+			cleanupResult, err = cleanupStaleBranches(ctx, branches, s.source, false, 0, s.log.Named("cleanup"))
+		}
+		result.timePhase("cleanup", start)
+		if err != nil {
+			// Log but don't fail the sync for cleanup errors
+			s.log.Warnf("cleanup failed: %v", err)
+		} else {
+			result.Cleanup = &cleanupResult
+			if len(cleanupResult.DeletedBranches) > 0 {
+				s.log.Debugf("Deleted %d stale branches", len(cleanupResult.DeletedBranches))
+			}
+		}
+	}
+	return nil
+}
+
+func (p *gitPublisher) Close() error {
+	if p.tempDir == "" {
+		return nil
+	}
+	return os.RemoveAll(p.tempDir)
+}
+
+// dirPublisher copies the output into <root>/<branch>, replacing what was
+// there only once the sync succeeded
+type dirPublisher struct {
+	root, base, branch string
+	work               string
+}
+
+func (p *dirPublisher) Name() string { return PublishDir }
+
+// Checkout copies <root>/<base> into a working directory next to it
+func (p *dirPublisher) Checkout(ctx context.Context) (string, error) {
+	if err := os.MkdirAll(p.root, 0755); err != nil {
+		return "", fmt.Errorf("failed to create %s: %w", p.root, err)
+	}
+	work, err := os.MkdirTemp(p.root, ".shadow-sync-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create working directory: %w", err)
+	}
+	p.work = work
+
+	from := filepath.Join(p.root, filepath.FromSlash(p.base))
+	if err := copyTree(from, work); err != nil {
+		return "", fmt.Errorf("failed to copy %s: %w", from, err)
+	}
+	return work, nil
+}
+
+// Publish swaps the working directory in for <root>/<branch>
+func (p *dirPublisher) Publish(ctx context.Context, dir string, result *Result) error {
+	dest := filepath.Join(p.root, filepath.FromSlash(p.branch))
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+	old := dir + ".old"
+	if err := os.Rename(dest, old); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to replace %s: %w", dest, err)
+	}
+	if err := os.Rename(dir, dest); err != nil {
+		os.Rename(old, dest)
+		return fmt.Errorf("failed to publish to %s: %w", dest, err)
+	}
+	os.RemoveAll(old)
+	p.work = ""
+	result.Location = dest
+	return nil
+}
+
+func (p *dirPublisher) Close() error {
+	if p.work == "" {
+		return nil
+	}
+	return os.RemoveAll(p.work)
+}
+
+// objectPublisher syncs the output to <url>/<branch> in an object store with
+// its CLI: aws s3 sync for s3:// and gsutil rsync for gs:// URLs
+type objectPublisher struct {
+	url, base, branch string
+	tool              string
+	work              string
+}
+
+func newObjectPublisher(url, base, branch string) (*objectPublisher, error) {
+	p := &objectPublisher{url: strings.TrimSuffix(url, "/"), base: base, branch: branch}
+	switch {
+	case strings.HasPrefix(url, "s3://"):
+		p.tool = "aws"
+	case strings.HasPrefix(url, "gs://"):
+		p.tool = "gsutil"
+	default:
+		return nil, fmt.Errorf("bucket URL %q must start with s3:// or gs://", url)
+	}
+	if _, err := exec.LookPath(p.tool); err != nil {
+		return nil, fmt.Errorf("publishing to %s requires the %s CLI, which is %w", url, p.tool, command.ErrNotInstalled)
+	}
+	return p, nil
+}
+
+func (p *objectPublisher) Name() string { return PublishS3 }
+
+// Checkout downloads <url>/<base>
+func (p *objectPublisher) Checkout(ctx context.Context) (string, error) {
+	work, err := os.MkdirTemp("", "shadow-sync-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	p.work = work
+	if err := p.sync(ctx, p.key(p.base), work, false); err != nil {
+		return "", err
+	}
+	return work, nil
+}
+
+// Publish uploads the working directory, deleting objects no longer rendered
+func (p *objectPublisher) Publish(ctx context.Context, dir string, result *Result) error {
+	start := time.Now()
+	if err := p.sync(ctx, dir, p.key(p.branch), true); err != nil {
+		return err
+	}
+	result.timePhase("push", start)
+	result.Location = p.key(p.branch)
+	return nil
+}
+
+func (p *objectPublisher) key(branch string) string {
+	return p.url + "/" + branch
+}
+
+// sync mirrors from into to, deleting extra files at to when prune is set
+func (p *objectPublisher) sync(ctx context.Context, from, to string, prune bool) error {
+	args := []string{"s3", "sync", "--only-show-errors", from, to}
+	if prune {
+		args = append(args, "--delete")
+	}
+	if p.tool == "gsutil" {
+		args = []string{"-m", "-q", "rsync", "-r"}
+		if prune {
+			args = append(args, "-d")
+		}
+		args = append(args, from, to)
+	}
+	var stderr bytes.Buffer
+	cmd := command.CommandContext(ctx, p.tool, args...)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s %s failed: %w: %s", p.tool, strings.Join(args[:2], " "), err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+func (p *objectPublisher) Close() error {
+	if p.work == "" {
+		return nil
+	}
+	return os.RemoveAll(p.work)
+}
+
+// copyTree copies the regular files under from into to; a missing from is
+// an empty tree
+func copyTree(from, to string) error {
+	if _, err := os.Stat(from); os.IsNotExist(err) {
+		return nil
+	}
+	return filepath.WalkDir(from, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(from, path)
+		if err != nil {
+			return err
+		}
+		dest := filepath.Join(to, rel)
+		if d.IsDir() {
+			return os.MkdirAll(dest, 0755)
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		src, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer src.Close()
+		dst, err := os.Create(dest)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(dst, src); err != nil {
+			dst.Close()
+			return err
+		}
+		return dst.Close()
+	})
+}
@@ -0,0 +1,224 @@
+package sync
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/erauner/homelab-shadow/pkg/command"
+	"github.com/erauner/homelab-shadow/pkg/config"
+)
+
+func publishRenderer(name string) Renderer {
+	return &fakeRenderer{
+		source: config.OutputSourceKustomize,
+		manifests: map[string]string{
+			"apps/web/overlays/production": "kind: ConfigMap\nmetadata:\n  name: " + name + "\n",
+		},
+	}
+}
+
+func TestRun_PublishDir(t *testing.T) {
+	root := t.TempDir()
+	stale := filepath.Join(root, "main", "rendered", "apps/old/overlays/production", "manifest.yaml")
+	if err := os.MkdirAll(filepath.Dir(stale), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(stale, []byte("kind: ConfigMap\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	run := func(name string) Result {
+		t.Helper()
+		syncer, err := New(Options{
+			RepoPath:  t.TempDir(),
+			Publish:   PublishDir,
+			PublishTo: root,
+			PRNumber:  "7",
+			Renderers: []Renderer{publishRenderer(name)},
+		})
+		if err != nil {
+			t.Fatalf("New() error = %v", err)
+		}
+		result, err := syncer.Run()
+		if err != nil {
+			t.Fatalf("Run() error = %v", err)
+		}
+		return result
+	}
+
+	result := run("web")
+	if result.Location != filepath.Join(root, "pr-7") || result.CommitSHA != "" || result.CompareURL != "" {
+		t.Errorf("unexpected result %+v", result)
+	}
+	if result.PrunedFiles != 1 {
+		t.Errorf("PrunedFiles = %d, want the base branch's stale manifest pruned", result.PrunedFiles)
+	}
+	manifest := filepath.Join(root, "pr-7", "rendered", "apps/web/overlays/production", "manifest.yaml")
+	if data, err := os.ReadFile(manifest); err != nil || !strings.Contains(string(data), "name: web") {
+		t.Errorf("manifest = %q, %v", data, err)
+	}
+	if _, err := os.Stat(stale); err != nil {
+		t.Errorf("the base branch's output should be left alone: %v", err)
+	}
+
+	result = run("web-v2")
+	if result.Changes == nil || len(result.Changes.Added) != 1 || len(result.Changes.Modified) != 0 || result.PrunedFiles != 1 {
+		t.Errorf("second run should start from the base branch again, got %+v (pruned %d)", result.Changes, result.PrunedFiles)
+	}
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	if got := strings.Join(names, ","); got != "main,pr-7" {
+		t.Errorf("publish root holds %s, want main,pr-7 (no working directories left)", got)
+	}
+}
+
+// Re-publishing a branch computes changes against the base branch, as a git
+// shadow branch reset to base does, not against the branch's last output
+func TestRun_PublishDir_AgainstBase(t *testing.T) {
+	root := t.TempDir()
+	base := filepath.Join(root, "main", "rendered", "apps/web/overlays/production", "manifest.yaml")
+	if err := os.MkdirAll(filepath.Dir(base), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(base, []byte("kind: ConfigMap\nmetadata:\n  name: web\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	run := func(name string) Result {
+		t.Helper()
+		syncer, err := New(Options{
+			RepoPath:  t.TempDir(),
+			Publish:   PublishDir,
+			PublishTo: root,
+			PRNumber:  "7",
+			Renderers: []Renderer{publishRenderer(name)},
+		})
+		if err != nil {
+			t.Fatalf("New() error = %v", err)
+		}
+		result, err := syncer.Run()
+		if err != nil {
+			t.Fatalf("Run() error = %v", err)
+		}
+		return result
+	}
+
+	if result := run("web-v2"); result.Changes == nil || len(result.Changes.Modified) != 1 {
+		t.Fatalf("first run should modify the base manifest, got %+v", result.Changes)
+	}
+	// Reverting to base's manifest is no change against base, though it
+	// modifies what the first run published
+	result := run("web")
+	if result.Changes != nil && len(result.Changes.Added)+len(result.Changes.Modified)+len(result.Changes.Removed) != 0 {
+		t.Errorf("second run should match base, got %+v", result.Changes)
+	}
+	manifest := filepath.Join(root, "pr-7", "rendered", "apps/web/overlays/production", "manifest.yaml")
+	if data, err := os.ReadFile(manifest); err != nil || !strings.Contains(string(data), "name: web\n") {
+		t.Errorf("manifest = %q, %v", data, err)
+	}
+}
+
+// fakeAWS puts an aws stub on PATH that serves s3://bucket/... from the
+// returned directory
+func fakeAWS(t *testing.T) string {
+	t.Helper()
+	bin, bucket := t.TempDir(), t.TempDir()
+	script := `#!/bin/sh
+[ "$1 $2" = "s3 sync" ] || exit 2
+shift 2
+src= dst= prune=
+for arg; do
+  case "$arg" in
+    --delete) prune=1 ;;
+    --*) ;;
+    *) if [ -z "$src" ]; then src=$arg; else dst=$arg; fi ;;
+  esac
+done
+src=$(echo "$src" | sed "s|^s3://|$FAKE_S3/|")
+dst=$(echo "$dst" | sed "s|^s3://|$FAKE_S3/|")
+[ -n "$prune" ] && rm -rf "$dst"
+mkdir -p "$dst"
+[ -d "$src" ] && cp -R "$src/." "$dst/"
+exit 0
+`
+	if err := os.WriteFile(filepath.Join(bin, "aws"), []byte(script), 0755); err != nil {
+		t.Fatalf("failed to write aws stub: %v", err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+	t.Setenv("FAKE_S3", bucket)
+	return bucket
+}
+
+func TestRun_PublishS3(t *testing.T) {
+	bucket := fakeAWS(t)
+	base := filepath.Join(bucket, "shadow", "homelab", "main", "rendered", "apps/web/overlays/production", "manifest.yaml")
+	if err := os.MkdirAll(filepath.Dir(base), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(base, []byte("kind: ConfigMap\nmetadata:\n  name: web\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	syncer, err := New(Options{
+		RepoPath:  t.TempDir(),
+		Publish:   PublishS3,
+		PublishTo: "s3://shadow/homelab/",
+		Branch:    "pr-8",
+		Renderers: []Renderer{publishRenderer("web-v2")},
+	})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	result, err := syncer.Run()
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if result.Location != "s3://shadow/homelab/pr-8" {
+		t.Errorf("Location = %q", result.Location)
+	}
+	if result.Changes == nil || len(result.Changes.Modified) != 1 {
+		t.Errorf("changes should be against the base branch's output, got %+v", result.Changes)
+	}
+	published := filepath.Join(bucket, "shadow", "homelab", "pr-8", "rendered", "apps/web/overlays/production", "manifest.yaml")
+	if data, err := os.ReadFile(published); err != nil || !strings.Contains(string(data), "name: web-v2") {
+		t.Errorf("published manifest = %q, %v", data, err)
+	}
+}
+
+func TestNew_Publish(t *testing.T) {
+	fakeAWS(t)
+	for name, opts := range map[string]Options{
+		"unknown backend":      {Publish: "ftp", PublishTo: "x"},
+		"dir without location": {Publish: PublishDir},
+		"dir with shadow repo": {Publish: PublishDir, PublishTo: "out", ShadowRepo: "erauner/shadow"},
+		"dir with cleanup":     {Publish: PublishDir, PublishTo: "out", CleanupMerged: true},
+		"s3 without a bucket":  {Publish: PublishS3, PublishTo: "/tmp/out"},
+		"git with location":    {ShadowRepo: "erauner/shadow", PublishTo: "out"},
+	} {
+		opts.RepoPath = "."
+		if _, err := New(opts); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+	if _, err := New(Options{RepoPath: ".", Publish: PublishS3, PublishTo: "s3://shadow"}); err != nil {
+		t.Errorf("s3: %v", err)
+	}
+
+	t.Setenv("PATH", t.TempDir())
+	_, err := New(Options{RepoPath: ".", Publish: PublishS3, PublishTo: "gs://shadow"})
+	if !errors.Is(err, command.ErrNotInstalled) {
+		t.Errorf("gs without gsutil: got %v, want ErrNotInstalled", err)
+	}
+	if _, err := New(Options{RepoPath: ".", Publish: PublishS3, PublishTo: "gs://shadow", DryRun: true, OutDir: "out"}); err != nil {
+		t.Errorf("a dry run shouldn't need gsutil: %v", err)
+	}
+}
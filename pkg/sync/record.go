@@ -0,0 +1,449 @@
+package sync
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/erauner/homelab-shadow/pkg/argocd"
+	"github.com/erauner/homelab-shadow/pkg/command"
+	"github.com/erauner/homelab-shadow/pkg/config"
+	"github.com/erauner/homelab-shadow/pkg/helm"
+	"github.com/erauner/homelab-shadow/pkg/kustomize"
+)
+
+// recordingVersion invalidates bundles written by an incompatible format
+const recordingVersion = 1
+
+// Files in a record bundle: the recording itself and the source repo under repo/
+const (
+	bundleRecording = "recording.json"
+	bundleRepo      = "repo/"
+)
+
+// Recorded target statuses
+const (
+	TargetRendered      = "rendered"
+	TargetSkipped       = "skipped"
+	TargetFailed        = "failed"
+	TargetBudgetSkipped = "budget-skipped"
+)
+
+// Recording captures what a sync rendered and everything needed to render it
+// again elsewhere; with the source repo it makes up a record bundle (see Replay)
+type Recording struct {
+	Version    int               `json:"version"`
+	RecordedAt string            `json:"recorded_at"`
+	Options    RecordedOptions   `json:"options"`
+	Tools      map[string]string `json:"tools"` // tool -> version, or "not installed"
+	Env        map[string]string `json:"env"`   // see recordedEnv
+	Targets    []RecordedTarget  `json:"targets"`
+}
+
+// RecordedOptions are the sync options that change what is rendered
+type RecordedOptions struct {
+	Clusters          []string             `json:"clusters,omitempty"`
+	Outputs           []config.Output      `json:"outputs,omitempty"` // empty: the default single root
+	OutputLayout      string               `json:"output_layout,omitempty"`
+	KustomizeEngine   kustomize.Engine     `json:"kustomize_engine,omitempty"`
+	KubernetesVersion string               `json:"kubernetes_version,omitempty"`
+	RedactSecrets     bool                 `json:"redact_secrets"`
+	Redaction         config.Redaction     `json:"redaction"`
+	Normalize         bool                 `json:"normalize"`
+	Normalization     config.Normalization `json:"normalization"`
+	SourceRepo        string               `json:"source_repo,omitempty"`
+	SourceCommit      string               `json:"source_commit,omitempty"`
+	PRNumber          string               `json:"pr,omitempty"`
+}
+
+// RecordedTarget is one rendered target: its inputs and what it produced
+type RecordedTarget struct {
+	Renderer string `json:"renderer"`
+	Dir      string `json:"dir"`
+	Name     string `json:"name,omitempty"`
+	Status   string `json:"status"`
+	Error    string `json:"error,omitempty"`
+
+	// Chart and ValueFiles (resolved, repo-relative) describe Helm targets
+	Chart      string   `json:"chart,omitempty"`
+	ValueFiles []string `json:"value_files,omitempty"`
+
+	// Manifest is what was published: substituted, redacted, and normalized
+	Manifest string `json:"manifest,omitempty"`
+}
+
+// key identifies a target across a recording and its replay
+func (t RecordedTarget) key() string {
+	return t.Renderer + "\x00" + t.Dir + "\x00" + t.Name
+}
+
+// recordedEnvPrefixes select the environment variables that can change a
+// render or identify the CI run
+var recordedEnvPrefixes = []string{"SHADOW_", "HELM_", "KUSTOMIZE_", "KUBECONFORM_", "GIT_", "CHANGE_", "BRANCH_NAME", "BUILD_", "JENKINS_URL", "XDG_CACHE_HOME", "PATH"}
+
+// secretEnvWords mark variables whose values are replaced with redactedEnv
+var secretEnvWords = []string{"TOKEN", "PASSWORD", "SECRET", "KEY", "CREDENTIAL", "AUTH"}
+
+// redactedEnv replaces recorded secret values; such variables are never replayed
+const redactedEnv = "<redacted>"
+
+// newRecording starts a recording of a sync with the given options
+func (s *Syncer) newRecording() *Recording {
+	options := RecordedOptions{
+		Clusters:          s.opts.Clusters,
+		OutputLayout:      s.opts.OutputLayout,
+		KustomizeEngine:   s.opts.KustomizeEngine,
+		KubernetesVersion: s.opts.KubernetesVersion,
+		RedactSecrets:     !s.opts.DisableRedaction,
+		Redaction:         s.opts.Redaction,
+		Normalize:         !s.opts.DisableNormalize,
+		Normalization:     s.opts.Normalization,
+		SourceRepo:        s.opts.SourceRepo,
+		SourceCommit:      s.opts.SourceCommit,
+		PRNumber:          s.opts.PRNumber,
+	}
+	if !s.defaultOutputs {
+		options.Outputs = s.opts.Outputs
+	}
+	tools := toolVersions()
+	if s.opts.Version != "" {
+		tools["shadow"] = s.opts.Version
+	}
+	return &Recording{
+		Version:    recordingVersion,
+		RecordedAt: time.Now().UTC().Format(time.RFC3339),
+		Options:    options,
+		Tools:      tools,
+		Env:        recordedEnv(),
+	}
+}
+
+// record adds a target's outcome to the recording, if one is being made
+func (s *Syncer) record(renderer Renderer, target Target, status string, manifest Manifest, err error) {
+	if s.recording == nil {
+		return
+	}
+	t := RecordedTarget{
+		Renderer: renderer.Name(),
+		Dir:      target.Dir,
+		Name:     target.Name,
+		Status:   status,
+		Manifest: string(manifest),
+	}
+	if err != nil {
+		t.Error = err.Error()
+	}
+	if h, ok := target.Data.(helmTarget); ok {
+		t.Chart = fmt.Sprintf("%s/%s@%s", h.source.RepoURL, h.source.Chart, h.source.TargetRevision)
+		if h.source.Helm != nil {
+			resolved, _ := argocd.ResolveValueFiles(h.source.Helm.ValueFiles, s.opts.RepoPath)
+			for _, file := range resolved {
+				if rel, err := filepath.Rel(s.opts.RepoPath, file); err == nil && !strings.HasPrefix(rel, "..") {
+					file = filepath.ToSlash(rel)
+				}
+				t.ValueFiles = append(t.ValueFiles, file)
+			}
+		}
+	}
+	s.recording.Targets = append(s.recording.Targets, t)
+}
+
+// saveRecording writes the recording and the source repo to opts.Record
+func (s *Syncer) saveRecording() {
+	if err := WriteBundle(s.opts.Record, s.recording, s.opts.RepoPath); err != nil {
+		s.log.Warnf("failed to write record bundle: %v", err)
+		return
+	}
+	s.log.Infof("Recorded %d target(s) to %s", len(s.recording.Targets), s.opts.Record)
+}
+
+// toolVersions reports the versions of the external tools a render uses
+func toolVersions() map[string]string {
+	tools := map[string]string{"go": runtime.Version(), "os": runtime.GOOS + "/" + runtime.GOARCH}
+	for name, version := range map[string]func() (string, error){
+		"helm":        helm.HelmVersion,
+		"kustomize":   kustomize.KustomizeVersion,
+		"kubeconform": kustomize.KubeconformVersion,
+	} {
+		v, err := version()
+		if err != nil {
+			v = "not installed"
+		}
+		tools[name] = v
+	}
+	return tools
+}
+
+// recordedEnv returns the environment variables matching recordedEnvPrefixes,
+// with secret-looking values and URL credentials redacted
+func recordedEnv() map[string]string {
+	env := make(map[string]string)
+	for _, kv := range os.Environ() {
+		name, value, _ := strings.Cut(kv, "=")
+		if !hasAnyPrefix(name, recordedEnvPrefixes) {
+			continue
+		}
+		if isSecretEnv(name) {
+			value = redactedEnv
+		} else if scheme, rest, ok := strings.Cut(value, "://"); ok {
+			if at := strings.Index(rest, "@"); at >= 0 && !strings.Contains(rest[:at], "/") {
+				value = scheme + "://" + rest[at+1:]
+			}
+		}
+		env[name] = value
+	}
+	return env
+}
+
+func hasAnyPrefix(s string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(s, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func isSecretEnv(name string) bool {
+	for _, word := range secretEnvWords {
+		if strings.Contains(name, word) {
+			return true
+		}
+	}
+	return false
+}
+
+// WriteBundle writes a record bundle: a tar archive holding recording.json and
+// the source repo's files (those git tracks or would track; every non-hidden
+// file outside a git repo). Bundles ending in .zst or .tzst are compressed
+// with the zstd CLI, anything else with gzip
+func WriteBundle(path string, rec *Recording, repoPath string) error {
+	files, err := repoFiles(repoPath)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(rec, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal recording: %w", err)
+	}
+
+	out, wait, err := bundleWriter(path)
+	if err != nil {
+		return err
+	}
+	tw := tar.NewWriter(out)
+	writeErr := func() error {
+		if err := tw.WriteHeader(&tar.Header{Name: bundleRecording, Mode: 0644, Size: int64(len(data))}); err != nil {
+			return err
+		}
+		if _, err := tw.Write(data); err != nil {
+			return err
+		}
+		for _, rel := range files {
+			if err := addBundleFile(tw, filepath.Join(repoPath, rel), bundleRepo+rel); err != nil {
+				return err
+			}
+		}
+		return tw.Close()
+	}()
+	closeErr := out.Close()
+	waitErr := wait()
+	if err := errors.Join(writeErr, closeErr, waitErr); err != nil {
+		return fmt.Errorf("failed to write bundle %s: %w", path, err)
+	}
+	return nil
+}
+
+// addBundleFile adds a regular file to the bundle; anything else is skipped
+func addBundleFile(tw *tar.Writer, path, name string) error {
+	info, err := os.Lstat(path)
+	if err != nil || !info.Mode().IsRegular() {
+		return nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := tw.WriteHeader(&tar.Header{Name: name, Mode: int64(info.Mode().Perm()), Size: info.Size()}); err != nil {
+		return err
+	}
+	_, err = io.Copy(tw, f)
+	return err
+}
+
+// repoFiles lists the repo-relative, slash-separated files to bundle
+func repoFiles(repoPath string) ([]string, error) {
+	out, err := command.Command("git", "-C", repoPath, "ls-files", "-z", "--cached", "--others", "--exclude-standard").Output()
+	if err == nil {
+		var files []string
+		for _, f := range strings.Split(string(out), "\x00") {
+			if f != "" {
+				files = append(files, f)
+			}
+		}
+		return files, nil
+	}
+
+	var files []string
+	err = filepath.WalkDir(repoPath, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path != repoPath && strings.HasPrefix(d.Name(), ".") && d.Name() != config.FileName {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.Type().IsRegular() {
+			rel, err := filepath.Rel(repoPath, path)
+			if err != nil {
+				return err
+			}
+			files = append(files, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list repo files: %w", err)
+	}
+	return files, nil
+}
+
+// isZstdBundle reports whether a bundle path asks for zstd compression
+func isZstdBundle(path string) bool {
+	return strings.HasSuffix(path, ".zst") || strings.HasSuffix(path, ".tzst")
+}
+
+// bundleWriter opens a compressed stream to path; wait returns once the
+// compressor has finished writing
+func bundleWriter(path string) (io.WriteCloser, func() error, error) {
+	if isZstdBundle(path) {
+		// Not a command.Cmd: the compressor runs for as long as the caller
+		// writes, which command.Timeout shouldn't cut short
+		cmd := exec.Command("zstd", "-q", "-f", "-o", path)
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		in, err := cmd.StdinPipe()
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := cmd.Start(); err != nil {
+			return nil, nil, fmt.Errorf("zstd compression requires the zstd CLI (or use a .tar.gz bundle): %w", err)
+		}
+		return in, func() error {
+			if err := cmd.Wait(); err != nil {
+				return fmt.Errorf("zstd failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+			}
+			return nil
+		}, nil
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	gz := gzip.NewWriter(f)
+	return gz, f.Close, nil
+}
+
+// bundleReader opens a decompressed stream of the bundle at path
+func bundleReader(path string) (io.ReadCloser, error) {
+	if isZstdBundle(path) {
+		data, err := command.Command("zstd", "-q", "-d", "-c", path).Output()
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress %s with the zstd CLI: %w", path, err)
+		}
+		return io.NopCloser(bytes.NewReader(data)), nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{gz, f}, nil
+}
+
+// ReadBundle extracts a record bundle's repo into dir/repo and returns its recording
+func ReadBundle(path, dir string) (*Recording, error) {
+	r, err := bundleReader(path)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	var rec *Recording
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read bundle %s: %w", path, err)
+		}
+
+		if hdr.Name == bundleRecording {
+			rec = &Recording{}
+			if err := json.NewDecoder(tr).Decode(rec); err != nil {
+				return nil, fmt.Errorf("failed to parse %s: %w", bundleRecording, err)
+			}
+			continue
+		}
+		if !strings.HasPrefix(hdr.Name, bundleRepo) || hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		// Never follow names out of the extraction directory
+		target := filepath.Join(dir, filepath.FromSlash(filepath.Clean("/"+hdr.Name)))
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return nil, err
+		}
+		f, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.FileMode(hdr.Mode).Perm()|0600)
+		if err != nil {
+			return nil, err
+		}
+		_, err = io.Copy(f, tr)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if rec == nil {
+		return nil, fmt.Errorf("%s is not a record bundle (no %s)", path, bundleRecording)
+	}
+	if rec.Version != recordingVersion {
+		return nil, fmt.Errorf("bundle %s has recording version %d, this shadow reads version %d", path, rec.Version, recordingVersion)
+	}
+	return rec, nil
+}
+
+// sortedTargets returns targets ordered by key, for stable comparisons
+func sortedTargets(targets []RecordedTarget) []RecordedTarget {
+	sorted := append([]RecordedTarget(nil), targets...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].key() < sorted[j].key() })
+	return sorted
+}
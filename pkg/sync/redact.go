@@ -1,47 +1,108 @@
 package sync
 
 import (
-	"regexp"
+	"bytes"
+	"errors"
+	"io"
+	"sort"
 	"strings"
+
+	"github.com/erauner/homelab-shadow/pkg/config"
+	"gopkg.in/yaml.v3"
 )
 
+// redactedPlaceholder replaces the value of every redacted Secret field
+const redactedPlaceholder = "# REDACTED - secrets are not included in shadow diffs"
+
+// unparseablePlaceholder replaces documents that may hold a Secret but could not be parsed
+const unparseablePlaceholder = "# REDACTED - document could not be parsed and may contain a Secret"
+
+// redactRule lists the field paths redacted in every resource of a kind
+type redactRule struct {
+	kind       string // "*" matches every kind
+	apiVersion string // empty matches every apiVersion
+	fields     [][]string
+}
+
+// secretRule is built in: Secret data never leaves the cluster repo
+var secretRule = redactRule{kind: "Secret", fields: [][]string{{"data"}, {"stringData"}, {"binaryData"}}}
+
+// Redactor redacts Secrets plus the kinds and fields of a redaction policy
+type Redactor struct {
+	rules  []redactRule
+	policy config.Redaction
+}
+
+// defaultRedactor only applies the built-in Secret rule
+var defaultRedactor = NewRedactor(config.Redaction{})
+
+// NewRedactor creates a Redactor for a redaction policy from .shadow.yaml
+// (validated by config.Parse); the Secret rule always applies
+func NewRedactor(policy config.Redaction) *Redactor {
+	r := &Redactor{rules: []redactRule{secretRule}, policy: policy}
+	for _, k := range policy.Kinds {
+		rule := redactRule{kind: k.Kind, apiVersion: k.APIVersion}
+		for _, field := range k.Fields {
+			rule.fields = append(rule.fields, config.FieldPath(field))
+		}
+		r.rules = append(r.rules, rule)
+	}
+	return r
+}
+
 // RedactSecrets removes sensitive data from Kubernetes Secret resources
 // while preserving the rest of the manifest structure for stable diffs.
-//
-// This uses text-based processing to avoid YAML re-serialization which
-// would cause key reordering and diff noise.
+// It is the Redact of a Redactor without a policy.
 func RedactSecrets(manifest string) string {
-	// Split into YAML documents
-	docs := splitYAMLDocuments(manifest)
+	return defaultRedactor.Redact(manifest)
+}
 
-	var result []string
-	for _, doc := range docs {
-		if isSecretDocument(doc) {
-			doc = redactSecretDocument(doc)
-		}
-		result = append(result, doc)
+// Redact removes the data of Secrets and the policy's fields from a manifest.
+//
+// Resources are located with a real YAML parser, but the redaction itself is
+// applied to the original text to avoid re-serialization, which would cause
+// key reordering and diff noise. Every redacted document is parsed again and
+// checked; layouts the text rewrite cannot handle fall back to re-encoding,
+// and anything that still fails the check is dropped entirely.
+func (r *Redactor) Redact(manifest string) string {
+	docs := splitYAMLDocuments(manifest)
+	for i, doc := range docs {
+		docs[i] = r.redactDocument(doc)
 	}
 
-	return joinYAMLDocuments(result)
+	return joinYAMLDocuments(docs)
 }
 
-// splitYAMLDocuments splits a multi-document YAML string on --- boundaries
+// splitYAMLDocuments splits a multi-document YAML string on --- boundaries.
+// Each document keeps its trailing newline and every document after the
+// first starts with its separator line, so joinYAMLDocuments round-trips.
 func splitYAMLDocuments(manifest string) []string {
-	// Split on document separator
-	parts := strings.Split(manifest, "\n---")
-
 	var docs []string
-	for i, part := range parts {
-		if i == 0 {
-			// First part doesn't have leading ---
-			docs = append(docs, part)
-		} else {
-			// Restore the separator for consistency
-			docs = append(docs, "---"+part)
+	var current strings.Builder
+
+	for _, line := range strings.SplitAfter(manifest, "\n") {
+		if isDocumentSeparator(line) && current.Len() > 0 {
+			docs = append(docs, current.String())
+			current.Reset()
 		}
+		current.WriteString(line)
 	}
 
-	return docs
+	return append(docs, current.String())
+}
+
+// isDocumentSeparator reports whether a line is a YAML document start marker
+func isDocumentSeparator(line string) bool {
+	if !strings.HasPrefix(line, "---") {
+		return false
+	}
+	rest := line[3:]
+	return rest == "" || strings.ContainsAny(rest[:1], " \t\r\n")
+}
+
+// JoinManifests concatenates rendered manifests into a single multi-document YAML stream
+func JoinManifests(manifests []string) string {
+	return joinYAMLDocuments(manifests)
 }
 
 // joinYAMLDocuments joins YAML documents back together
@@ -68,84 +129,358 @@ func joinYAMLDocuments(docs []string) string {
 	return result.String()
 }
 
-// isSecretDocument checks if a YAML document is a Kubernetes Secret
-func isSecretDocument(doc string) bool {
-	// Match "kind: Secret" at the beginning of a line
-	kindPattern := regexp.MustCompile(`(?m)^kind:\s*Secret\s*$`)
-	return kindPattern.MatchString(doc)
+// redactDocument redacts every rule's fields from the matching resources in a document
+func (r *Redactor) redactDocument(doc string) string {
+	// Double-quoted escapes can spell a kind without the literal text
+	if !r.mayMatch(doc) && !strings.Contains(doc, `\`) {
+		return doc
+	}
+
+	nodes, err := decodeYAMLDocuments(doc)
+	if err != nil {
+		if r.mayMatch(doc) {
+			return redactWholeDocument(doc)
+		}
+		return doc
+	}
+
+	fields := r.findFields(nodes)
+	if len(fields) == 0 {
+		// A resource can still carry data through merge keys or aliases
+		if len(r.dataValues(nodes)) > 0 {
+			return redactWholeDocument(doc)
+		}
+		return doc
+	}
+
+	if redacted, ok := redactLines(doc, fields); ok && r.isRedacted(redacted) {
+		return redacted
+	}
+	if redacted, err := reencodeRedacted(doc, nodes, fields); err == nil && r.isRedacted(redacted) {
+		return redacted
+	}
+
+	return redactWholeDocument(doc)
+}
+
+// mayMatch reports whether a document's text mentions a redacted kind
+func (r *Redactor) mayMatch(doc string) bool {
+	for _, rule := range r.rules {
+		if rule.kind == "*" || strings.Contains(doc, rule.kind) {
+			return true
+		}
+	}
+	return false
+}
+
+// secretField is a redacted field that still carries values
+type secretField struct {
+	parent *yaml.Node // mapping holding the field
+	key    *yaml.Node
+	value  *yaml.Node
+}
+
+// findFields returns the redacted fields of every matching resource that hold values
+func (r *Redactor) findFields(nodes []*yaml.Node) []secretField {
+	var fields []secretField
+	for _, node := range nodes {
+		r.walk(node, func(resource *yaml.Node, rule redactRule) {
+			for _, path := range rule.fields {
+				resolveField(resource, path, false, func(parent, key, value *yaml.Node) {
+					if len(scalarValues(value)) > 0 {
+						fields = append(fields, secretField{parent: parent, key: key, value: value})
+					}
+				})
+			}
+		})
+	}
+	return fields
 }
 
-// redactSecretDocument redacts data/stringData/binaryData from a Secret document
-func redactSecretDocument(doc string) string {
-	// Patterns for secret data fields
-	// These match the field and its entire block (indented content below)
-	dataPatterns := []string{
-		`data:`,
-		`stringData:`,
-		`binaryData:`,
+// resolveField calls fn for every key/value pair a field path selects under
+// node; "*" matches any key or list item. With merges, merge keys ("<<") along
+// the path are selected too, since they can pull values into the field.
+func resolveField(node *yaml.Node, path []string, merges bool, fn func(parent, key, value *yaml.Node)) {
+	node = resolveAlias(node)
+	segment := path[0]
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			name := resolveAlias(key).Value
+			if merges && name == "<<" {
+				fn(node, key, value)
+				continue
+			}
+			if segment != "*" && name != segment {
+				continue
+			}
+			if len(path) == 1 {
+				fn(node, key, value)
+			} else {
+				resolveField(value, path[1:], merges, fn)
+			}
+		}
+	case yaml.SequenceNode:
+		// List items have no key to redact, so "*" only descends into them
+		if segment != "*" || len(path) == 1 {
+			return
+		}
+		for _, item := range node.Content {
+			resolveField(item, path[1:], merges, fn)
+		}
 	}
+}
 
+// redactLines rewrites the text of block-style Secret fields in place.
+// It reports false when a field's layout cannot be rewritten line by line.
+func redactLines(doc string, fields []secretField) (string, bool) {
 	lines := strings.Split(doc, "\n")
-	var result []string
 
-	skipUntilIndent := -1 // -1 means not skipping
-	redactedField := ""
+	// Rewrite from the bottom up so earlier line numbers stay valid
+	sorted := append([]secretField(nil), fields...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].key.Line > sorted[j].key.Line })
+
+	for _, field := range sorted {
+		if field.parent.Style&yaml.FlowStyle != 0 || field.key.Kind != yaml.ScalarNode {
+			return "", false
+		}
 
-	for i, line := range lines {
-		// Check if we're currently skipping a block
-		if skipUntilIndent >= 0 {
-			// Calculate current line's indentation
-			currentIndent := countIndent(line)
+		start := field.key.Line - 1
+		col := field.key.Column - 1
+		if start < 0 || start >= len(lines) || col > len(lines[start]) {
+			return "", false
+		}
+
+		line := lines[start]
+		colon := strings.Index(line[col:], ":")
+		if colon < 0 {
+			return "", false
+		}
 
-			// Empty lines or lines with greater indentation are part of the block
-			if line == "" || currentIndent > skipUntilIndent {
-				continue // Skip this line
+		eol := ""
+		if strings.HasSuffix(line, "\r") {
+			eol = "\r"
+		}
+
+		// The value spans every following line indented deeper than the key;
+		// blank and comment lines only count when more value lines follow
+		end := start
+		for j := start + 1; j < len(lines); j++ {
+			trimmed := strings.TrimSpace(lines[j])
+			if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+				continue
+			}
+			if countIndent(lines[j]) <= col {
+				break
 			}
+			end = j
+		}
 
-			// We've reached a line with equal or less indentation - stop skipping
-			skipUntilIndent = -1
+		replacement := []string{
+			line[:col+colon+1] + eol,
+			strings.Repeat(" ", col+2) + redactedPlaceholder + eol,
 		}
+		lines = append(lines[:start], append(replacement, lines[end+1:]...)...)
+	}
 
-		// Check if this line starts a data block to redact
-		trimmed := strings.TrimSpace(line)
-		for _, pattern := range dataPatterns {
-			if trimmed == pattern || strings.HasPrefix(trimmed, pattern+" ") {
-				indent := countIndent(line)
+	return strings.Join(lines, "\n"), true
+}
 
-				// Add the redacted field header
-				result = append(result, line)
+// reencodeRedacted nulls every Secret field and re-serializes the document.
+// Used for flow-style Secrets where the text cannot be rewritten in place.
+func reencodeRedacted(doc string, nodes []*yaml.Node, fields []secretField) (string, error) {
+	for _, field := range fields {
+		*field.value = yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null", Value: "null"}
+	}
 
-				// Add a REDACTED placeholder at the next indentation level
-				nextIndent := strings.Repeat(" ", indent+2)
-				result = append(result, nextIndent+"# REDACTED - secrets are not included in shadow diffs")
+	var buf bytes.Buffer
+	if isDocumentSeparator(doc) {
+		buf.WriteString("---\n")
+	}
+	buf.WriteString(redactedPlaceholder + "\n")
 
-				redactedField = pattern
-				skipUntilIndent = indent
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	for _, node := range nodes {
+		if err := enc.Encode(node); err != nil {
+			return "", err
+		}
+	}
+	if err := enc.Close(); err != nil {
+		return "", err
+	}
 
-				// Check if this is an inline empty value like "data: {}"
-				if strings.Contains(trimmed, "{}") || strings.Contains(trimmed, "{ }") {
-					skipUntilIndent = -1 // Don't skip, it's inline
-				}
+	return buf.String(), nil
+}
 
-				break
+// redactWholeDocument replaces a document with a placeholder, keeping its separator.
+// This is the fail-closed path for Secrets that cannot be redacted safely.
+func redactWholeDocument(doc string) string {
+	separator := ""
+	if isDocumentSeparator(doc) {
+		separator = "---\n"
+	}
+	return separator + unparseablePlaceholder + "\n"
+}
+
+// isRedacted reports whether a manifest parses and no redacted field in it still carries data
+func (r *Redactor) isRedacted(manifest string) bool {
+	nodes, err := decodeYAMLDocuments(manifest)
+	return err == nil && len(r.dataValues(nodes)) == 0
+}
+
+// decodeYAMLDocuments parses every document in a YAML stream
+func decodeYAMLDocuments(manifest string) ([]*yaml.Node, error) {
+	dec := yaml.NewDecoder(strings.NewReader(manifest))
+
+	var nodes []*yaml.Node
+	for {
+		var node yaml.Node
+		err := dec.Decode(&node)
+		if errors.Is(err, io.EOF) {
+			return nodes, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, &node)
+	}
+}
+
+// secretDataValues returns every scalar still reachable under a Secret's
+// data fields, including values pulled in through merge keys
+func secretDataValues(nodes []*yaml.Node) []string {
+	return defaultRedactor.dataValues(nodes)
+}
+
+// dataValues returns every scalar still reachable under a redacted field,
+// including values pulled in through merge keys
+func (r *Redactor) dataValues(nodes []*yaml.Node) []string {
+	var values []string
+	for _, node := range nodes {
+		r.walk(node, func(resource *yaml.Node, rule redactRule) {
+			for _, path := range rule.fields {
+				resolveField(resource, path, true, func(_, _, value *yaml.Node) {
+					values = append(values, scalarValues(value)...)
+				})
+			}
+		})
+	}
+	return values
+}
+
+// walkSecrets calls fn for every mapping with kind: Secret, at any depth,
+// so Secrets nested in List items are covered too
+func walkSecrets(node *yaml.Node, fn func(secret *yaml.Node)) {
+	defaultRedactor.walk(node, func(secret *yaml.Node, _ redactRule) { fn(secret) })
+}
+
+// walk calls fn for every mapping (at any depth, so List items are covered)
+// that a rule applies to, unless the policy allows the resource
+func (r *Redactor) walk(node *yaml.Node, fn func(resource *yaml.Node, rule redactRule)) {
+	if node.Kind == yaml.MappingNode {
+		var matched []redactRule
+		for _, rule := range r.rules {
+			if matchesRule(node, rule) {
+				matched = append(matched, rule)
+			}
+		}
+		if len(matched) > 0 && !r.allowed(node) {
+			for _, rule := range matched {
+				fn(node, rule)
 			}
 		}
+	}
+	for _, child := range node.Content {
+		r.walk(child, fn)
+	}
+}
+
+// matchesRule checks a mapping's kind (and apiVersion) against a rule
+// A "*" rule only matches mappings that have a kind
+func matchesRule(node *yaml.Node, rule redactRule) bool {
+	kind, apiVersion := scalarField(node, "kind"), scalarField(node, "apiVersion")
+	if kind == "" || (rule.kind != "*" && kind != rule.kind) {
+		return false
+	}
+	return rule.apiVersion == "" || apiVersion == rule.apiVersion
+}
+
+// allowed reports whether the policy allows a resource to be published as-is
+func (r *Redactor) allowed(node *yaml.Node) bool {
+	if len(r.policy.Allow) == 0 {
+		return false
+	}
+	var metadata *yaml.Node
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if resolveAlias(node.Content[i]).Value == "metadata" {
+			metadata = resolveAlias(node.Content[i+1])
+		}
+	}
+	if metadata == nil || metadata.Kind != yaml.MappingNode {
+		return false
+	}
+	return r.policy.AllowsResource(scalarField(metadata, "namespace"), scalarField(metadata, "name"))
+}
 
-		// If we started skipping, continue to next line
-		if skipUntilIndent >= 0 && redactedField != "" {
-			redactedField = ""
-			continue
+// scalarField returns the scalar value of key in a mapping, or ""
+func scalarField(node *yaml.Node, key string) string {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		k, v := resolveAlias(node.Content[i]), resolveAlias(node.Content[i+1])
+		if k.Value == key && v.Kind == yaml.ScalarNode {
+			return v.Value
 		}
+	}
+	return ""
+}
 
-		// Check if this is the next line after we started a block
-		if i > 0 && skipUntilIndent >= 0 {
-			continue
+// scalarValues collects the non-null scalars under a node, following aliases
+func scalarValues(node *yaml.Node) []string {
+	budget := maxScalarNodes
+	return collectScalars(node, &budget)
+}
+
+// maxScalarNodes bounds how many nodes scalarValues visits, so alias
+// expansion ("billion laughs") cannot stall redaction
+const maxScalarNodes = 10000
+
+func collectScalars(node *yaml.Node, budget *int) []string {
+	*budget--
+	if *budget < 0 {
+		// Too large to inspect: report it as unredacted
+		return []string{"<too many nodes>"}
+	}
+
+	switch node.Kind {
+	case yaml.AliasNode:
+		if node.Alias == nil {
+			return nil
+		}
+		return collectScalars(node.Alias, budget)
+	case yaml.ScalarNode:
+		if node.Tag == "!!null" {
+			return nil
 		}
+		return []string{node.Value}
+	}
 
-		result = append(result, line)
+	var values []string
+	for _, child := range node.Content {
+		values = append(values, collectScalars(child, budget)...)
+		if *budget < 0 {
+			break
+		}
 	}
+	return values
+}
 
-	return strings.Join(result, "\n")
+// resolveAlias returns the node an alias points to, or the node itself
+func resolveAlias(node *yaml.Node) *yaml.Node {
+	if node.Kind == yaml.AliasNode && node.Alias != nil {
+		return node.Alias
+	}
+	return node
 }
 
 // countIndent returns the number of leading spaces in a line
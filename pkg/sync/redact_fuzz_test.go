@@ -0,0 +1,168 @@
+package sync
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+// redactSeeds are hand-picked inputs covering the layouts kustomize, helm and
+// humans produce, plus the edge cases that previously slipped through
+var redactSeeds = []string{
+	"",
+	"apiVersion: v1\nkind: Secret\nmetadata:\n  name: s\ndata:\n  password: c2VjcmV0\n",
+	"apiVersion: v1\nkind: Secret\nstringData:\n  token: plain\n---\nkind: ConfigMap\ndata:\n  a: b\n",
+	"kind: \"Secret\"\ndata:\n  password: c2VjcmV0\n",
+	"kind: 'Secret'\ndata:\n  password: c2VjcmV0\n",
+	"kind: Secret # managed\ndata:\n  password: c2VjcmV0\n",
+	"kind: Secret\ndata: {password: c2VjcmV0}\n",
+	"kind: Secret\ndata: {}\n",
+	"{kind: Secret, data: {password: c2VjcmV0}}\n",
+	"kind: Secret\n\"data\":\n  password: c2VjcmV0\n",
+	"kind: Secret\ndata:\n    password: c2VjcmV0\n    other: |\n      bXVsdGk=\n      bGluZQ==\n",
+	"kind: Secret\ndata:\n- c2VjcmV0\n",
+	"apiVersion: v1\nkind: List\nitems:\n- kind: Secret\n  data:\n    password: c2VjcmV0\n",
+	"kind: Secret\nmetadata:\n  annotations:\n    a: &leak c2VjcmV0\ndata:\n  password: *leak\n",
+	"kind: Secret\r\ndata:\r\n  password: c2VjcmV0\r\n",
+	"---\nkind: Secret\ndata:\n  password: c2VjcmV0\n---\n",
+	"--- # first\nkind: Secret\ndata:\n  password: c2VjcmV0\n",
+	"kind: ConfigMap\ndata:\n  script: |\n    echo ---\n    ---not-a-separator\n",
+	"kind: Secret\ndata:\n  password: c2VjcmV0\n...\n---\nkind: ConfigMap\n",
+}
+
+func FuzzRedactSecrets(f *testing.F) {
+	for _, seed := range redactSeeds {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, input string) {
+		output := RedactSecrets(input)
+
+		inputNodes, inputErr := decodeYAMLDocuments(input)
+		if inputErr != nil {
+			// Unparseable input: the only guarantee is no panic and no leak of
+			// anything the parser could still see as a Secret
+			return
+		}
+
+		outputNodes, err := decodeYAMLDocuments(output)
+		if err != nil {
+			t.Fatalf("output does not parse although input does: %v\ninput:\n%s\noutput:\n%s", err, input, output)
+		}
+
+		if leaks := secretDataValues(outputNodes); len(leaks) > 0 {
+			t.Fatalf("secret values survived redaction: %q\ninput:\n%s\noutput:\n%s", leaks, input, output)
+		}
+
+		if secretDocs(inputNodes) == 0 && output != input {
+			t.Fatalf("input without Secrets was modified\ninput:\n%q\noutput:\n%q", input, output)
+		}
+
+		if again := RedactSecrets(output); again != output {
+			t.Fatalf("redaction is not idempotent\nfirst:\n%q\nsecond:\n%q", output, again)
+		}
+	})
+}
+
+func FuzzSplitYAMLDocuments(f *testing.F) {
+	for _, seed := range redactSeeds {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, input string) {
+		docs := splitYAMLDocuments(input)
+		if got := joinYAMLDocuments(docs); got != input {
+			t.Fatalf("split/join round trip changed input\ninput:\n%q\noutput:\n%q", input, got)
+		}
+
+		inputNodes, err := decodeYAMLDocuments(input)
+		if err != nil {
+			return
+		}
+		var split int
+		for _, doc := range docs {
+			nodes, err := decodeYAMLDocuments(doc)
+			if err != nil {
+				t.Fatalf("document split from valid input does not parse: %v\ndocument:\n%q", err, doc)
+			}
+			split += len(nodes)
+		}
+		if split != len(inputNodes) {
+			t.Fatalf("split produced %d documents, parser sees %d\ninput:\n%q", split, len(inputNodes), input)
+		}
+	})
+}
+
+// TestRedactSecrets_Property generates Secrets in many layouts with random
+// values and checks that none of the values survive and the output still parses
+func TestRedactSecrets_Property(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	kinds := []string{"Secret", `"Secret"`, `'Secret'`, "Secret # comment", "!!str Secret"}
+	fields := []string{"data", "stringData", "binaryData", `"data"`}
+	indents := []string{" ", "  ", "    "}
+
+	for i := 0; i < 500; i++ {
+		value := randomBase64(rng)
+		kind := kinds[rng.Intn(len(kinds))]
+		field := fields[rng.Intn(len(fields))]
+		indent := indents[rng.Intn(len(indents))]
+
+		var body string
+		switch rng.Intn(4) {
+		case 0:
+			body = fmt.Sprintf("%s:\n%skey: %s\n", field, indent, value)
+		case 1:
+			body = fmt.Sprintf("%s: {key: %s}\n", field, value)
+		case 2:
+			body = fmt.Sprintf("%s:\n%skey: |\n%s%s%s\n", field, indent, indent, indent, value)
+		case 3:
+			body = fmt.Sprintf("%s:\n%s# note\n%skey: %q\n\n%sother: x\n", field, indent, indent, value, indent)
+		}
+
+		doc := fmt.Sprintf("apiVersion: v1\nkind: %s\nmetadata:\n  name: s%d\n%stype: Opaque\n", kind, i, body)
+		if rng.Intn(2) == 0 {
+			doc = "kind: ConfigMap\ndata:\n  a: b\n---\n" + doc
+		}
+
+		if _, err := decodeYAMLDocuments(doc); err != nil {
+			t.Fatalf("generator produced invalid YAML: %v\n%s", err, doc)
+		}
+
+		output := RedactSecrets(doc)
+		if strings.Contains(output, value) {
+			t.Fatalf("value %q survived redaction\ninput:\n%s\noutput:\n%s", value, doc, output)
+		}
+		nodes, err := decodeYAMLDocuments(output)
+		if err != nil {
+			t.Fatalf("output does not parse: %v\ninput:\n%s\noutput:\n%s", err, doc, output)
+		}
+		if leaks := secretDataValues(nodes); len(leaks) > 0 {
+			t.Fatalf("secret values survived redaction: %q\noutput:\n%s", leaks, output)
+		}
+		if !strings.Contains(output, "type: Opaque") {
+			t.Fatalf("fields after the data block were dropped\noutput:\n%s", output)
+		}
+	}
+}
+
+func randomBase64(rng *rand.Rand) string {
+	const alphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/"
+	b := make([]byte, 12+rng.Intn(40))
+	for i := range b {
+		b[i] = alphabet[rng.Intn(len(alphabet))]
+	}
+	return string(b) + "=="
+}
+
+// secretDocs counts the Secret mappings found in parsed documents
+func secretDocs(nodes []*yaml.Node) int {
+	count := 0
+	for _, node := range nodes {
+		walkSecrets(node, func(*yaml.Node) { count++ })
+	}
+	return count
+}
@@ -3,6 +3,8 @@ package sync
 import (
 	"strings"
 	"testing"
+
+	"github.com/erauner/homelab-shadow/pkg/config"
 )
 
 func TestRedactSecrets(t *testing.T) {
@@ -291,3 +293,144 @@ data:
 		})
 	}
 }
+
+func TestRedactor_Policy(t *testing.T) {
+	r := NewRedactor(config.Redaction{
+		Kinds: []config.RedactKind{
+			{Kind: "SealedSecret", APIVersion: "bitnami.com/v1alpha1", Fields: []string{"spec.encryptedData"}},
+			{Kind: "ExternalSecret", Fields: []string{"status"}},
+			{Kind: "Deployment", Fields: []string{"spec.template.spec.containers.*.env"}},
+		},
+		Allow: []string{"monitoring/grafana-*"},
+	})
+
+	tests := []struct {
+		name         string
+		input        string
+		wantPreserve []string
+		wantRemove   []string
+	}{
+		{
+			name: "sealed secret",
+			input: `apiVersion: bitnami.com/v1alpha1
+kind: SealedSecret
+metadata:
+  name: db
+spec:
+  encryptedData:
+    password: AgBy3i4OJSWK+PiTySYZZA9rO43cGDEq
+  template:
+    type: Opaque
+`,
+			wantPreserve: []string{"name: db", "type: Opaque"},
+			wantRemove:   []string{"AgBy3i4OJSWK+PiTySYZZA9rO43cGDEq"},
+		},
+		{
+			name: "other apiVersion is not redacted",
+			input: `apiVersion: example.com/v1
+kind: SealedSecret
+metadata:
+  name: db
+spec:
+  encryptedData:
+    password: kept-value
+`,
+			wantPreserve: []string{"kept-value"},
+		},
+		{
+			name: "external secret status",
+			input: `apiVersion: external-secrets.io/v1beta1
+kind: ExternalSecret
+metadata:
+  name: db
+spec:
+  refreshInterval: 1h
+status:
+  binding:
+    name: db-synced-token
+`,
+			wantPreserve: []string{"refreshInterval: 1h"},
+			wantRemove:   []string{"db-synced-token"},
+		},
+		{
+			name: "wildcard field path",
+			input: `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  template:
+    spec:
+      containers:
+        - name: web
+          image: nginx
+          env:
+            - name: API_KEY
+              value: first-secret
+        - name: sidecar
+          env:
+            - name: TOKEN
+              value: second-secret
+`,
+			wantPreserve: []string{"image: nginx", "name: sidecar"},
+			wantRemove:   []string{"first-secret", "second-secret", "API_KEY"},
+		},
+		{
+			name: "allowed secret",
+			input: `apiVersion: v1
+kind: Secret
+metadata:
+  name: grafana-admin
+  namespace: monitoring
+data:
+  password: YWRtaW4=
+`,
+			wantPreserve: []string{"YWRtaW4="},
+		},
+		{
+			name: "allow pattern needs the namespace",
+			input: `apiVersion: v1
+kind: Secret
+metadata:
+  name: grafana-admin
+  namespace: default
+data:
+  password: YWRtaW4=
+`,
+			wantRemove: []string{"YWRtaW4="},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := r.Redact(tt.input)
+			for _, s := range tt.wantPreserve {
+				if !strings.Contains(got, s) {
+					t.Errorf("expected %q to be preserved, got:\n%s", s, got)
+				}
+			}
+			for _, s := range tt.wantRemove {
+				if strings.Contains(got, s) {
+					t.Errorf("expected %q to be removed, got:\n%s", s, got)
+				}
+			}
+			if violations, err := r.Verify(got); err != nil || len(violations) > 0 {
+				t.Errorf("Verify() = %v, %v", violations, err)
+			}
+		})
+	}
+}
+
+func TestRedactor_DefaultIgnoresOtherKinds(t *testing.T) {
+	input := `apiVersion: bitnami.com/v1alpha1
+kind: SealedSecret
+metadata:
+  name: db
+spec:
+  encryptedData:
+    password: AgBy3i4OJSWK
+`
+	if got := RedactSecrets(input); got != input {
+		t.Errorf("RedactSecrets() changed a SealedSecret without a policy:\n%s", got)
+	}
+}
@@ -0,0 +1,327 @@
+package sync
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/erauner/homelab-shadow/pkg/argocd"
+	"github.com/erauner/homelab-shadow/pkg/cluster"
+	"github.com/erauner/homelab-shadow/pkg/config"
+	"github.com/erauner/homelab-shadow/pkg/flux"
+	"github.com/erauner/homelab-shadow/pkg/helm"
+	"github.com/erauner/homelab-shadow/pkg/kustomize"
+	"github.com/erauner/homelab-shadow/pkg/log"
+)
+
+// Renderer produces manifests for one source format (kustomize, helm, ...)
+type Renderer interface {
+	// Name identifies the renderer in logs and errors
+	Name() string
+
+	// Source is the output source its manifests are routed by (config.OutputSource*)
+	Source() string
+
+	// Discover lists the targets to render
+	Discover() ([]Target, error)
+
+	// Render renders one target
+	Render(ctx context.Context, target Target) (Manifest, Meta, error)
+}
+
+// Target is one unit a Renderer renders, written to <Dir>/manifest.yaml in each
+// output root that includes Dir
+type Target struct {
+	Dir  string      // repo-relative output directory
+	Name string      // description for logs (defaults to Dir)
+	Data interface{} // renderer-specific state, e.g. the Helm source
+}
+
+// Manifest is rendered multi-document YAML
+type Manifest string
+
+// Meta describes a render beyond its manifest
+type Meta struct {
+	// Skipped reports the target had nothing to render; it is counted as
+	// skipped rather than rendered or failed
+	Skipped bool
+
+	// ChartCache is "hit" or "miss" when a Helm chart cache was consulted
+	ChartCache string
+}
+
+// RendererFactory builds a Renderer for a sync run
+type RendererFactory func(opts Options, logger *log.Logger) Renderer
+
+// registeredRenderer is a RendererFactory under its name
+type registeredRenderer struct {
+	name    string
+	factory RendererFactory
+}
+
+// renderers are the registered renderer factories, run in registration order
+var renderers []registeredRenderer
+
+// RegisterRenderer adds a renderer to every sync run, replacing one of the same name
+func RegisterRenderer(name string, factory RendererFactory) {
+	for i, r := range renderers {
+		if r.name == name {
+			renderers[i].factory = factory
+			return
+		}
+	}
+	renderers = append(renderers, registeredRenderer{name: name, factory: factory})
+}
+
+func init() {
+	RegisterRenderer(config.OutputSourceKustomize, newKustomizeRenderer)
+	RegisterRenderer(config.OutputSourceHelm, newHelmRenderer)
+}
+
+// renderers returns Options.Renderers, or one of each registered renderer
+func (s *Syncer) renderers() []Renderer {
+	if s.opts.Renderers != nil {
+		return s.opts.Renderers
+	}
+	built := make([]Renderer, 0, len(renderers))
+	for _, r := range renderers {
+		built = append(built, r.factory(s.opts, s.log))
+	}
+	return built
+}
+
+// discovered holds the targets one renderer found
+type discovered struct {
+	renderer Renderer
+	targets  []Target
+}
+
+// discover lists every renderer's targets, skipping renderers whose source no
+// configured output root renders
+func (s *Syncer) discover() ([]discovered, error) {
+	var found []discovered
+	for _, r := range s.renderers() {
+		if !s.rendersSource(r.Source()) {
+			s.log.Debugf("No output root renders %s, skipping %s rendering", r.Source(), r.Name())
+			continue
+		}
+		targets, err := r.Discover()
+		if err != nil {
+			return nil, fmt.Errorf("failed to discover %s targets: %w", r.Name(), err)
+		}
+		s.log.Debugf("Discovered %d %s target(s)", len(targets), r.Name())
+		found = append(found, discovered{renderer: r, targets: targets})
+	}
+	return found, nil
+}
+
+// rendersSource reports whether any configured output renders source
+func (s *Syncer) rendersSource(source string) bool {
+	for _, out := range s.opts.Outputs {
+		if out.HasSource(source) {
+			return true
+		}
+	}
+	return false
+}
+
+// kustomizeRenderer builds kustomization directories
+type kustomizeRenderer struct {
+	repoPath string
+	clusters []string
+	runner   *kustomize.Runner
+	log      *log.Logger
+}
+
+func newKustomizeRenderer(opts Options, logger *log.Logger) Renderer {
+	runner := kustomize.NewRunner(opts.RepoPath, opts.KubernetesVersion, opts.Verbose)
+	runner.Log = logger.Named("kustomize")
+	if opts.KustomizeEngine != "" {
+		runner.Engine = opts.KustomizeEngine
+	}
+	return &kustomizeRenderer{repoPath: opts.RepoPath, clusters: opts.Clusters, runner: runner, log: logger}
+}
+
+func (r *kustomizeRenderer) Name() string   { return "kustomize" }
+func (r *kustomizeRenderer) Source() string { return config.OutputSourceKustomize }
+
+// Discover finds kustomization directories for the selected clusters, with
+// the spec.source.kustomize options of the Application deploying each
+func (r *kustomizeRenderer) Discover() ([]Target, error) {
+	dirs, err := DiscoverKustomizationsForSync(r.repoPath, r.clusters)
+	if err != nil {
+		return nil, err
+	}
+	options := KustomizeSourceOptions(r.repoPath, r.log)
+	targets := make([]Target, 0, len(dirs))
+	for _, dir := range dirs {
+		target := Target{Dir: dir}
+		if k, ok := options[filepath.ToSlash(dir)]; ok {
+			target.Data = k
+		}
+		targets = append(targets, target)
+	}
+	return targets, nil
+}
+
+// KustomizeSourceOptions maps the source paths of ArgoCD Applications to
+// their spec.source.kustomize options, for building each directory the way
+// ArgoCD does (see kustomize.Runner.BuildSource). When Applications set
+// different options for one path, the first wins and a warning is logged.
+func KustomizeSourceOptions(repoPath string, logger *log.Logger) map[string]*argocd.KustomizeConfig {
+	apps, _, err := argocd.LoadApplications(repoPath)
+	if err != nil {
+		logger.Debugf("No Applications loaded, building kustomizations without source options: %v", err)
+		return nil
+	}
+
+	options := make(map[string]*argocd.KustomizeConfig)
+	owners := make(map[string]string)
+	for _, app := range apps {
+		for _, source := range app.GetKustomizeSources() {
+			if source.Kustomize.IsEmpty() {
+				continue
+			}
+			dir := path.Clean(strings.TrimPrefix(filepath.ToSlash(source.Path), "./"))
+			if owner, ok := owners[dir]; ok {
+				if !reflect.DeepEqual(options[dir], source.Kustomize) {
+					logger.Warnf("Applications %s and %s set different kustomize options for %s, rendering with %s's", owner, app.Name, dir, owner)
+				}
+				continue
+			}
+			options[dir], owners[dir] = source.Kustomize, app.Name
+		}
+	}
+	return options
+}
+
+// appDestinations maps each directory Applications deploy (kustomize source
+// paths, and apps/<app>/helm for Helm sources) to their destinations
+func appDestinations(repoPath string, registry *cluster.Registry, logger *log.Logger) map[string][]argocd.Destination {
+	apps, _, err := flux.LoadAllApplications(repoPath)
+	if err != nil {
+		logger.Debugf("No Applications loaded, recording no destinations: %v", err)
+		return nil
+	}
+	destinations := make(map[string][]argocd.Destination)
+	add := func(dir string, d argocd.Destination) {
+		if !slices.Contains(destinations[dir], d) {
+			destinations[dir] = append(destinations[dir], d)
+		}
+	}
+	for _, app := range apps {
+		d := app.Destination(registry)
+		for _, source := range app.GetKustomizeSources() {
+			add(path.Clean(strings.TrimPrefix(filepath.ToSlash(source.Path), "./")), d)
+		}
+		if len(app.GetHelmSources()) > 0 {
+			add(fmt.Sprintf("apps/%s/helm", app.Name), d)
+		}
+	}
+	return destinations
+}
+
+func (r *kustomizeRenderer) Render(ctx context.Context, target Target) (Manifest, Meta, error) {
+	if err := ctx.Err(); err != nil {
+		return "", Meta{}, err
+	}
+	k, _ := target.Data.(*argocd.KustomizeConfig)
+	build := r.runner.BuildSourceContext(ctx, target.Dir, k)
+	if build.Skipped {
+		return "", Meta{Skipped: true}, nil
+	}
+	if !build.Passed {
+		return "", Meta{}, build.Error
+	}
+	return Manifest(build.Output), Meta{}, nil
+}
+
+// helmRenderer renders the Helm sources of ArgoCD Applications and Flux HelmReleases
+type helmRenderer struct {
+	repoPath  string
+	credsFile string
+	opts      HelmRenderOptions
+	log       *log.Logger
+}
+
+// helmTarget is the Data of a Helm Target
+type helmTarget struct {
+	app    *argocd.Application
+	source argocd.Source
+}
+
+func newHelmRenderer(opts Options, logger *log.Logger) Renderer {
+	runner := kustomize.NewRunner(opts.RepoPath, opts.KubernetesVersion, opts.Verbose)
+	runner.Log = logger.Named("kustomize")
+	if opts.KustomizeEngine != "" {
+		runner.Engine = opts.KustomizeEngine
+	}
+	return &helmRenderer{
+		repoPath:  opts.RepoPath,
+		credsFile: opts.HelmCredentialsFile,
+		opts: HelmRenderOptions{
+			RepoPath:  opts.RepoPath,
+			CacheDir:  opts.HelmCacheDir,
+			Verbose:   opts.Verbose,
+			Log:       logger,
+			Kustomize: runner,
+		},
+		log: logger,
+	}
+}
+
+func (r *helmRenderer) Name() string   { return "helm" }
+func (r *helmRenderer) Source() string { return config.OutputSourceHelm }
+
+// Discover lists one target per Helm source, under apps/<app>/helm
+// Without helm installed, or when Applications fail to load, there is nothing to render
+// Credentials for private repositories are loaded here too (see HelmCredentials)
+func (r *helmRenderer) Discover() ([]Target, error) {
+	if !helm.IsHelmInstalled() {
+		r.log.Debugf("Helm not installed, skipping Helm chart rendering")
+		return nil, nil
+	}
+	apps, err := flux.DiscoverAllHelmApplications(r.repoPath)
+	if err != nil {
+		r.log.Warnf("failed to discover Helm applications: %v", err)
+		return nil, nil
+	}
+
+	creds, err := HelmCredentials(r.repoPath, r.credsFile)
+	if err != nil {
+		return nil, err
+	}
+	r.opts.Credentials = creds
+	r.log.Debugf("Loaded %d Helm repository credential(s)", creds.Len())
+
+	var targets []Target
+	for _, app := range apps {
+		for _, source := range app.GetHelmSources() {
+			targets = append(targets, Target{
+				Dir:  fmt.Sprintf("apps/%s/helm", app.Name),
+				Name: fmt.Sprintf("%s: %s/%s@%s", app.Name, source.RepoURL, source.Chart, source.TargetRevision),
+				Data: helmTarget{app: app, source: source},
+			})
+		}
+	}
+	return targets, nil
+}
+
+func (r *helmRenderer) Render(ctx context.Context, target Target) (Manifest, Meta, error) {
+	if err := ctx.Err(); err != nil {
+		return "", Meta{}, err
+	}
+	t, ok := target.Data.(helmTarget)
+	if !ok {
+		return "", Meta{}, fmt.Errorf("not a Helm target: %s", target.Dir)
+	}
+	result := RenderHelmSourceContext(ctx, t.app, &t.source, r.opts)
+	if !result.Passed {
+		return "", Meta{ChartCache: result.ChartCache}, result.Error
+	}
+	return Manifest(result.Output), Meta{ChartCache: result.ChartCache}, nil
+}
@@ -0,0 +1,258 @@
+package sync
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/erauner/homelab-shadow/pkg/argocd"
+	"github.com/erauner/homelab-shadow/pkg/config"
+	"github.com/erauner/homelab-shadow/pkg/kustomize"
+	"github.com/erauner/homelab-shadow/pkg/log"
+)
+
+// fakeRenderer renders canned manifests keyed by target directory
+type fakeRenderer struct {
+	source    string
+	manifests map[string]string // "" marks a skipped target
+	failures  map[string]error
+}
+
+func (f *fakeRenderer) Name() string   { return "fake-" + f.source }
+func (f *fakeRenderer) Source() string { return f.source }
+
+func (f *fakeRenderer) Discover() ([]Target, error) {
+	var targets []Target
+	for dir := range f.manifests {
+		targets = append(targets, Target{Dir: dir})
+	}
+	for dir := range f.failures {
+		targets = append(targets, Target{Dir: dir})
+	}
+	return targets, nil
+}
+
+func (f *fakeRenderer) Render(ctx context.Context, target Target) (Manifest, Meta, error) {
+	if err, ok := f.failures[target.Dir]; ok {
+		return "", Meta{}, err
+	}
+	manifest := f.manifests[target.Dir]
+	if manifest == "" {
+		return "", Meta{Skipped: true}, nil
+	}
+	return Manifest(manifest), Meta{}, nil
+}
+
+func TestRun_DryRunFakeRenderers(t *testing.T) {
+	out := t.TempDir()
+	secret := "apiVersion: v1\nkind: Secret\nmetadata:\n  name: db\ndata:\n  password: aHVudGVyMg==\n"
+
+	syncer, err := New(Options{
+		RepoPath: t.TempDir(),
+		DryRun:   true,
+		OutDir:   out,
+		Renderers: []Renderer{
+			&fakeRenderer{
+				source: config.OutputSourceKustomize,
+				manifests: map[string]string{
+					"apps/web/overlays/production": secret,
+					"apps/empty/base":              "",
+				},
+				failures: map[string]error{"apps/broken/base": errors.New("accumulating resources")},
+			},
+			&fakeRenderer{
+				source:    config.OutputSourceHelm,
+				manifests: map[string]string{"apps/redis/helm": "kind: StatefulSet\n"},
+				failures:  map[string]error{"apps/bad/helm": errors.New("chart not found")},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	result, err := syncer.Run()
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if result.RenderedDirs != 1 || result.SkippedDirs != 1 || result.FailedDirs != 1 {
+		t.Errorf("dirs rendered/skipped/failed = %d/%d/%d, want 1/1/1",
+			result.RenderedDirs, result.SkippedDirs, result.FailedDirs)
+	}
+	if result.HelmAppsRendered != 1 || result.HelmAppsFailed != 1 {
+		t.Errorf("helm rendered/failed = %d/%d, want 1/1", result.HelmAppsRendered, result.HelmAppsFailed)
+	}
+
+	data, err := os.ReadFile(filepath.Join(out, "apps/web/overlays/production/manifest.yaml"))
+	if err != nil {
+		t.Fatalf("expected rendered manifest: %v", err)
+	}
+	if strings.Contains(string(data), "aHVudGVyMg==") {
+		t.Error("fake renderer output was not redacted")
+	}
+	if _, err := os.Stat(filepath.Join(out, "apps/redis/helm/manifest.yaml")); err != nil {
+		t.Errorf("expected Helm manifest: %v", err)
+	}
+}
+
+func TestRun_SkipsRenderersWithoutOutputRoot(t *testing.T) {
+	out := t.TempDir()
+	helmOnly := &fakeRenderer{source: config.OutputSourceHelm, manifests: map[string]string{"apps/redis/helm": "kind: StatefulSet\n"}}
+
+	syncer, err := New(Options{
+		RepoPath:  t.TempDir(),
+		DryRun:    true,
+		OutDir:    out,
+		Outputs:   []config.Output{{Path: "rendered", Sources: []string{config.OutputSourceKustomize}}},
+		Renderers: []Renderer{helmOnly},
+	})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	result, err := syncer.Run()
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if result.HelmAppsRendered != 0 {
+		t.Errorf("HelmAppsRendered = %d, want 0 when no output root renders Helm", result.HelmAppsRendered)
+	}
+}
+
+func TestRegisterRenderer(t *testing.T) {
+	saved := renderers
+	t.Cleanup(func() { renderers = saved })
+	renderers = append([]registeredRenderer(nil), saved...)
+
+	fake := &fakeRenderer{source: config.OutputSourceKustomize}
+	RegisterRenderer("kustomize", func(Options, *log.Logger) Renderer { return fake })
+	RegisterRenderer("jsonnet", func(Options, *log.Logger) Renderer { return fake })
+
+	syncer, err := New(Options{RepoPath: ".", DryRun: true, OutDir: "out"})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	var names []string
+	for _, r := range syncer.renderers() {
+		names = append(names, r.Name())
+	}
+	if got := strings.Join(names, ","); got != "fake-kustomize,helm,fake-kustomize" {
+		t.Errorf("renderers = %s", got)
+	}
+}
+
+func TestRenderHelmSource_PostRender(t *testing.T) {
+	// Fake helm and kustomize keep this offline: helm prints a Deployment, and
+	// kustomize prints the Helm output it was handed plus a marker
+	bin := t.TempDir()
+	if err := os.WriteFile(filepath.Join(bin, "helm"), []byte("#!/bin/sh\nprintf 'kind: Deployment\\nmetadata:\\n  name: web\\n'\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	kustomizeBin := filepath.Join(bin, "kustomize")
+	if err := os.WriteFile(kustomizeBin, []byte("#!/bin/sh\nfor last; do :; done\ncat \"$last/helm-output.yaml\"\necho '# post-rendered'\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	repo := t.TempDir()
+	component := filepath.Join(repo, "apps", "web", "post-render")
+	if err := os.MkdirAll(component, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(component, "kustomization.yaml"), []byte("kind: Component\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	runner := kustomize.NewRunner(repo, "", false)
+	runner.Binary = kustomizeBin
+
+	app := &argocd.Application{Name: "web", Namespace: "web", PostRender: "apps/web/post-render"}
+	source := &argocd.Source{RepoURL: "https://charts.example.com", Chart: "web", TargetRevision: "1.0.0"}
+	result := RenderHelmSource(app, source, HelmRenderOptions{RepoPath: repo, Kustomize: runner})
+	if !result.Passed {
+		t.Fatalf("RenderHelmSource() failed: %v\n%s", result.Error, result.Output)
+	}
+	if !strings.Contains(result.Output, "name: web") || !strings.Contains(result.Output, "# post-rendered") {
+		t.Errorf("expected post-rendered Helm output, got:\n%s", result.Output)
+	}
+
+	app.PostRender = "apps/web/missing"
+	if result := RenderHelmSource(app, source, HelmRenderOptions{RepoPath: repo, Kustomize: runner}); result.Passed {
+		t.Error("expected a missing post-render overlay to fail the render")
+	}
+}
+
+func TestRun_DryRunDirectoryMetadata(t *testing.T) {
+	out := t.TempDir()
+	manifest := "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: a\n---\napiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: b\n---\napiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: web\n"
+
+	repo := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(repo, "argocd-apps"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(repo, "clusters.yaml"), []byte("clusters:\n  - name: erauner-home\n    server: https://kubernetes.default.svc\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	app := "apiVersion: argoproj.io/v1alpha1\nkind: Application\nmetadata:\n  name: web\nspec:\n" +
+		"  destination:\n    server: https://kubernetes.default.svc\n    namespace: web\n" +
+		"  source:\n    repoURL: https://github.com/erauner/homelab-k8s.git\n    path: ./apps/web/overlays/production\n"
+	if err := os.WriteFile(filepath.Join(repo, "argocd-apps", "web.yaml"), []byte(app), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	syncer, err := New(Options{
+		RepoPath: repo,
+		DryRun:   true,
+		OutDir:   out,
+		Renderers: []Renderer{&fakeRenderer{
+			source:    config.OutputSourceKustomize,
+			manifests: map[string]string{"apps/web/overlays/production": manifest},
+			failures:  map[string]error{"apps/broken/overlays/production": errors.New("boom")},
+		}},
+	})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	result, err := syncer.Run()
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if _, ok := result.PhaseSeconds["render"]; !ok {
+		t.Errorf("PhaseSeconds = %v, want the render phase timed", result.PhaseSeconds)
+	}
+
+	data, err := os.ReadFile(filepath.Join(out, "_meta.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var meta Metadata
+	if err := json.Unmarshal(data, &meta); err != nil {
+		t.Fatal(err)
+	}
+	if len(meta.Directories) != 1 {
+		t.Fatalf("Directories = %+v, want only the rendered directory", meta.Directories)
+	}
+	dir := meta.Directories[0]
+	published, err := os.ReadFile(filepath.Join(out, filepath.FromSlash(dir.Path)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dir.Source != "apps/web/overlays/production" || dir.Renderer != "fake-kustomize" || dir.Path != "apps/web/overlays/production/manifest.yaml" {
+		t.Errorf("unexpected directory entry %+v", dir)
+	}
+	if dir.SHA256 != fmt.Sprintf("%x", sha256.Sum256(published)) {
+		t.Errorf("SHA256 = %s, want the digest of the published manifest", dir.SHA256)
+	}
+	if !reflect.DeepEqual(dir.Resources, map[string]int{"ConfigMap": 2, "Deployment": 1}) {
+		t.Errorf("Resources = %v", dir.Resources)
+	}
+	want := []argocd.Destination{{App: "web", Cluster: "erauner-home", Server: "https://kubernetes.default.svc", Namespace: "web"}}
+	if !reflect.DeepEqual(dir.Destinations, want) {
+		t.Errorf("Destinations = %+v, want %+v", dir.Destinations, want)
+	}
+}
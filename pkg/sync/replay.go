@@ -0,0 +1,187 @@
+package sync
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/erauner/homelab-shadow/pkg/log"
+)
+
+// ReplayOptions configures Replay
+type ReplayOptions struct {
+	// WorkDir receives the extracted repo and replayed output (default: a
+	// temporary directory, removed afterwards)
+	WorkDir string
+
+	// Renderers replaces the registered renderers, as in Options
+	Renderers []Renderer
+
+	Verbose bool
+	Log     *log.Logger
+}
+
+// ReplayResult compares a recorded run with its replay
+type ReplayResult struct {
+	Recorded *Recording `json:"recorded"`
+	Replayed *Recording `json:"replayed"`
+
+	// ToolChanges lists tools whose version differs from the recording
+	ToolChanges []ToolChange `json:"tool_changes,omitempty"`
+
+	// Diffs lists the targets that did not render as recorded
+	Diffs []TargetDiff `json:"diffs,omitempty"`
+}
+
+// ToolChange is a tool whose version differs between recording and replay
+type ToolChange struct {
+	Tool     string `json:"tool"`
+	Recorded string `json:"recorded"`
+	Replayed string `json:"replayed"`
+}
+
+// TargetDiff is a target whose status or manifest changed on replay; a nil
+// side means the target was absent from that run
+type TargetDiff struct {
+	Renderer string          `json:"renderer"`
+	Dir      string          `json:"dir"`
+	Name     string          `json:"name,omitempty"`
+	Recorded *RecordedTarget `json:"recorded,omitempty"`
+	Replayed *RecordedTarget `json:"replayed,omitempty"`
+}
+
+// Reproduced reports whether every target rendered as recorded
+func (r ReplayResult) Reproduced() bool {
+	return len(r.Diffs) == 0
+}
+
+// Replay re-renders a record bundle (see Options.Record) locally with the
+// recorded options and environment, and compares the outcome with the
+// recording. Targets the recorded run skipped over its budget are not compared;
+// failed targets are compared by status only, since errors embed local paths
+func Replay(ctx context.Context, bundle string, opts ReplayOptions) (ReplayResult, error) {
+	workDir := opts.WorkDir
+	if workDir == "" {
+		dir, err := os.MkdirTemp("", "shadow-replay-*")
+		if err != nil {
+			return ReplayResult{}, fmt.Errorf("failed to create temp directory: %w", err)
+		}
+		defer os.RemoveAll(dir)
+		workDir = dir
+	}
+
+	rec, err := ReadBundle(bundle, workDir)
+	if err != nil {
+		return ReplayResult{}, err
+	}
+	result := ReplayResult{Recorded: rec}
+
+	restore := applyRecordedEnv(rec.Env)
+	defer restore()
+
+	o := rec.Options
+	syncer, err := New(Options{
+		RepoPath:          filepath.Join(workDir, filepath.FromSlash(bundleRepo)),
+		Clusters:          o.Clusters,
+		Outputs:           o.Outputs,
+		OutputLayout:      o.OutputLayout,
+		KustomizeEngine:   o.KustomizeEngine,
+		KubernetesVersion: o.KubernetesVersion,
+		DisableRedaction:  !o.RedactSecrets,
+		Redaction:         o.Redaction,
+		DisableNormalize:  !o.Normalize,
+		Normalization:     o.Normalization,
+		SourceRepo:        o.SourceRepo,
+		SourceCommit:      o.SourceCommit,
+		PRNumber:          o.PRNumber,
+		Renderers:         opts.Renderers,
+		DryRun:            true,
+		OutDir:            filepath.Join(workDir, "out"),
+		Verbose:           opts.Verbose,
+		Log:               opts.Log,
+	})
+	if err != nil {
+		return result, err
+	}
+	syncer.recording = syncer.newRecording()
+	if _, err := syncer.RunContext(ctx); err != nil {
+		return result, fmt.Errorf("replay failed: %w", err)
+	}
+	result.Replayed = syncer.recording
+	result.Replayed.Tools["shadow"] = rec.Tools["shadow"]
+
+	result.ToolChanges = compareTools(rec.Tools, result.Replayed.Tools)
+	result.Diffs = compareTargets(rec.Targets, result.Replayed.Targets)
+	return result, nil
+}
+
+// applyRecordedEnv sets the recorded environment, except PATH and redacted
+// values, and returns a function restoring the previous values
+func applyRecordedEnv(env map[string]string) func() {
+	type saved struct {
+		value string
+		set   bool
+	}
+	previous := make(map[string]saved)
+	for name, value := range env {
+		if name == "PATH" || value == redactedEnv {
+			continue
+		}
+		old, set := os.LookupEnv(name)
+		previous[name] = saved{old, set}
+		os.Setenv(name, value)
+	}
+	return func() {
+		for name, p := range previous {
+			if p.set {
+				os.Setenv(name, p.value)
+			} else {
+				os.Unsetenv(name)
+			}
+		}
+	}
+}
+
+// compareTools lists the tools whose versions differ, sorted by name
+func compareTools(recorded, replayed map[string]string) []ToolChange {
+	var changes []ToolChange
+	for tool, version := range recorded {
+		if replayed[tool] != version {
+			changes = append(changes, ToolChange{Tool: tool, Recorded: version, Replayed: replayed[tool]})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Tool < changes[j].Tool })
+	return changes
+}
+
+// compareTargets lists the targets whose outcome differs between two runs
+func compareTargets(recorded, replayed []RecordedTarget) []TargetDiff {
+	byKey := make(map[string]*RecordedTarget)
+	for i := range replayed {
+		byKey[replayed[i].key()] = &replayed[i]
+	}
+
+	var diffs []TargetDiff
+	for _, r := range sortedTargets(recorded) {
+		r := r
+		p := byKey[r.key()]
+		delete(byKey, r.key())
+		if r.Status == TargetBudgetSkipped {
+			continue
+		}
+		if p != nil && p.Status == r.Status && (r.Status == TargetFailed || p.Manifest == r.Manifest) {
+			continue
+		}
+		diffs = append(diffs, TargetDiff{Renderer: r.Renderer, Dir: r.Dir, Name: r.Name, Recorded: &r, Replayed: p})
+	}
+
+	for _, p := range sortedTargets(replayed) {
+		if _, ok := byKey[p.key()]; ok {
+			p := p
+			diffs = append(diffs, TargetDiff{Renderer: p.Renderer, Dir: p.Dir, Name: p.Name, Replayed: &p})
+		}
+	}
+	return diffs
+}
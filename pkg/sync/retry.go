@@ -0,0 +1,90 @@
+package sync
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/erauner/homelab-shadow/pkg/command"
+	"github.com/erauner/homelab-shadow/pkg/log"
+)
+
+// GitRetry retries git network operations (clone, fetch, push) that fail with
+// transient errors, doubling the delay after each attempt
+type GitRetry struct {
+	Retries int           // attempts after the first (0 = no retries)
+	Delay   time.Duration // wait before the first retry
+	Log     *log.Logger   // default: the shared logger
+}
+
+// retryableGitPatterns are stderr fragments of transient network failures
+var retryableGitPatterns = []string{
+	"could not resolve host",
+	"temporary failure in name resolution",
+	"connection timed out",
+	"operation timed out",
+	"connection refused",
+	"connection reset",
+	"network is unreachable",
+	"tls handshake timeout",
+	"the remote end hung up unexpectedly",
+	"early eof",
+	"unexpected disconnect",
+	"rpc failed",
+	"returned error: 429",
+	"returned error: 500",
+	"returned error: 502",
+	"returned error: 503",
+	"returned error: 504",
+	// go-git
+	"no such host",
+	"i/o timeout",
+	"unexpected eof",
+	"status code: 429",
+	"status code: 500",
+	"status code: 502",
+	"status code: 503",
+	"status code: 504",
+}
+
+// isRetryableGitError reports whether a git failure looks transient
+// Authentication, missing refs, and rejected pushes are not retried; a git
+// command killed after --cmd-timeout is
+func isRetryableGitError(err error) bool {
+	var gitErr *GitError
+	if !errors.As(err, &gitErr) {
+		return false
+	}
+	if command.IsTimeout(err) {
+		return true
+	}
+	stderr := strings.ToLower(gitErr.Stderr)
+	for _, pattern := range retryableGitPatterns {
+		if strings.Contains(stderr, pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+// do runs fn, retrying transient git errors; before runs ahead of each retry
+// (e.g. to remove a partial clone) and may be nil
+func (r GitRetry) do(op string, fn func() error, before func()) error {
+	logger := r.Log
+	if logger == nil {
+		logger = log.Default().Named("sync")
+	}
+
+	delay := r.Delay
+	err := fn()
+	for attempt := 1; attempt <= r.Retries && isRetryableGitError(err); attempt++ {
+		logger.Warnf("git %s failed, retry %d/%d in %s: %v", op, attempt, r.Retries, delay, err)
+		time.Sleep(delay)
+		delay *= 2
+		if before != nil {
+			before()
+		}
+		err = fn()
+	}
+	return err
+}
@@ -0,0 +1,69 @@
+package sync
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestIsRetryableGitError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{&GitError{Op: "clone", Stderr: "fatal: unable to access 'https://github.com/a/b.git/': Could not resolve host: github.com"}, true},
+		{&GitError{Op: "push", Stderr: "error: RPC failed; HTTP 502 curl 22 The requested URL returned error: 502"}, true},
+		{&GitError{Op: "fetch", Stderr: "fatal: the remote end hung up unexpectedly"}, true},
+		{fmt.Errorf("failed to push: %w", &GitError{Op: "push", Stderr: "Connection reset by peer"}), true},
+		{goGitError("push", errors.New(`unexpected requesting "https://github.com/a/b.git/git-receive-pack" status code: 503`)), true},
+		{goGitError("clone", errors.New("Get \"https://github.com/a/b.git/info/refs\": dial tcp: lookup github.com: no such host")), true},
+		{&GitError{Op: "clone", Stderr: "fatal: Authentication failed for 'https://github.com/a/b.git/'"}, false},
+		{goGitError("push", errors.New("authentication required")), false},
+		{&GitError{Op: "push", Stderr: "! [rejected] pr-1 -> pr-1 (non-fast-forward)"}, false},
+		{errors.New("connection timed out"), false}, // not a git failure
+		{nil, false},
+	}
+	for _, tt := range tests {
+		if got := isRetryableGitError(tt.err); got != tt.want {
+			t.Errorf("isRetryableGitError(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestGitRetry(t *testing.T) {
+	transient := &GitError{Op: "push", Stderr: "fatal: the remote end hung up unexpectedly"}
+	permanent := &GitError{Op: "push", Stderr: "fatal: Authentication failed"}
+
+	tests := []struct {
+		name      string
+		retries   int
+		failures  []error // returned by successive attempts, then nil
+		wantCalls int
+		wantErr   bool
+	}{
+		{name: "succeeds after transient failures", retries: 2, failures: []error{transient, transient}, wantCalls: 3},
+		{name: "gives up after retries", retries: 1, failures: []error{transient, transient}, wantCalls: 2, wantErr: true},
+		{name: "permanent failure not retried", retries: 3, failures: []error{permanent}, wantCalls: 1, wantErr: true},
+		{name: "no retries configured", retries: 0, failures: []error{transient}, wantCalls: 1, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls, befores := 0, 0
+			err := GitRetry{Retries: tt.retries}.do("push", func() error {
+				calls++
+				if calls <= len(tt.failures) {
+					return tt.failures[calls-1]
+				}
+				return nil
+			}, func() { befores++ })
+
+			if (err != nil) != tt.wantErr {
+				t.Errorf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if calls != tt.wantCalls || befores != calls-1 {
+				t.Errorf("calls = %d, befores = %d, want %d calls", calls, befores, tt.wantCalls)
+			}
+		})
+	}
+}
@@ -0,0 +1,247 @@
+package sync
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// SecretFinding is a value in a rendered manifest that looks like a credential
+type SecretFinding struct {
+	File  string `json:"file"` // relative to the scanned root
+	Line  int    `json:"line"`
+	Rule  string `json:"rule"`
+	Match string `json:"match"` // the matched value, masked
+}
+
+func (f SecretFinding) String() string {
+	return fmt.Sprintf("%s:%d: %s (%s)", f.File, f.Line, f.Rule, f.Match)
+}
+
+// secretPattern is a credential format recognized by its shape alone
+type secretPattern struct {
+	rule string
+	re   *regexp.Regexp
+}
+
+// secretPatterns are checked against every line of every manifest
+var secretPatterns = []secretPattern{
+	{"private-key", regexp.MustCompile(`-----BEGIN (?:[A-Z0-9]+ )*PRIVATE KEY(?: BLOCK)?-----`)},
+	{"aws-access-key", regexp.MustCompile(`\b(?:AKIA|ASIA|AGPA|AIDA|AROA|ANPA)[0-9A-Z]{16}\b`)},
+	{"github-token", regexp.MustCompile(`\b(?:gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{22,})\b`)},
+	{"gitlab-token", regexp.MustCompile(`\bglpat-[A-Za-z0-9_-]{20,}\b`)},
+	{"slack-token", regexp.MustCompile(`\bxox[abposr]-[A-Za-z0-9-]{10,}\b`)},
+}
+
+// publicKeys hold public material (CA bundles, certificates) that is expected to be high-entropy
+var publicKeys = map[string]bool{
+	"caBundle":      true,
+	"ca.crt":        true,
+	"tls.crt":       true,
+	"ca-bundle.crt": true,
+}
+
+// Entropy detection applies to base64-like tokens at least this long
+const (
+	minEntropyTokenLength = 32
+	minEntropyBits        = 4.5 // Shannon entropy per character; hex digests stay below 4
+)
+
+// entropyToken matches candidate tokens for entropy detection
+var entropyToken = regexp.MustCompile(`[A-Za-z0-9+/_=-]{32,}`)
+
+// pemCertificate is the base64 encoding of "-----BEGIN CERTIFICATE"
+const pemCertificate = "LS0tLS1CRUdJTiBDRVJUSUZJQ0FUR"
+
+// ScanSecrets looks for credentials in a rendered manifest: known token
+// formats (AWS keys, GitHub and GitLab tokens, private key headers, also
+// inside base64 values) and high-entropy base64 strings. It complements
+// RedactSecrets, which only covers kind: Secret, by catching credentials
+// that leak through ConfigMaps, env vars, and annotations.
+//
+// Certificates and CA bundles are public and not reported. Comment lines
+// are checked for token formats only, since commented-out values still ship.
+// Findings carry the 1-based line; File is left empty.
+func ScanSecrets(manifest string) []SecretFinding {
+	var findings []SecretFinding
+	inCertificate, inPrivateKey := false, false
+
+	for i, line := range strings.Split(manifest, "\n") {
+		trimmed := strings.TrimSpace(line)
+		// An unterminated PEM block ends with its document
+		if trimmed == "---" || strings.HasPrefix(trimmed, "--- ") {
+			inCertificate, inPrivateKey = false, false
+		}
+		comment := strings.HasPrefix(trimmed, "#")
+
+		// Certificate bodies are public, but a private key next to one is not
+		if strings.Contains(line, "-----BEGIN CERTIFICATE-----") {
+			inCertificate = true
+		}
+		certificate := inCertificate
+		if strings.Contains(line, "-----END CERTIFICATE-----") {
+			inCertificate = false
+			if isPEMMarker(trimmed) {
+				continue
+			}
+		}
+		// A private key is reported once, at its header
+		if inPrivateKey {
+			if strings.Contains(line, "PRIVATE KEY") && strings.Contains(line, "-----END ") {
+				inPrivateKey = false
+			}
+			continue
+		}
+
+		add := func(rule, match string) {
+			findings = append(findings, SecretFinding{Line: i + 1, Rule: rule, Match: maskSecret(match)})
+		}
+
+		matched := false
+		for _, p := range secretPatterns {
+			if m := p.re.FindString(line); m != "" {
+				add(p.rule, m)
+				matched = true
+				inPrivateKey = inPrivateKey || (p.rule == "private-key" && !strings.Contains(line, "-----END "))
+			}
+		}
+		if matched || comment || certificate || publicKeys[yamlKey(trimmed)] {
+			continue
+		}
+
+		for _, token := range entropyToken.FindAllString(line, -1) {
+			if strings.HasPrefix(token, pemCertificate) {
+				continue
+			}
+			if decoded, ok := decodeBase64(token); ok {
+				if m := secretPatterns[0].re.FindString(decoded); m != "" {
+					add("private-key", token)
+					break
+				}
+			}
+			if isHighEntropy(token) {
+				add("high-entropy", token)
+				break
+			}
+		}
+	}
+
+	return findings
+}
+
+// ScanSecretsTree runs ScanSecrets over every YAML file under root
+func ScanSecretsTree(root string) ([]SecretFinding, error) {
+	var findings []SecretFinding
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if d.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasSuffix(path, ".yaml") && !strings.HasSuffix(path, ".yml") {
+			return nil
+		}
+
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			rel = path
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", rel, err)
+		}
+		for _, f := range ScanSecrets(string(data)) {
+			f.File = filepath.ToSlash(rel)
+			findings = append(findings, f)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(findings, func(i, j int) bool { return findings[i].File < findings[j].File })
+	return findings, nil
+}
+
+// isPEMMarker reports whether a line is only a PEM BEGIN or END marker,
+// possibly quoted or a list item
+func isPEMMarker(line string) bool {
+	line = strings.Trim(strings.TrimPrefix(line, "- "), `"'`)
+	return strings.HasPrefix(line, "-----BEGIN ") || strings.HasPrefix(line, "-----END ")
+}
+
+// yamlKey returns the mapping key of a "key: value" line, or ""
+func yamlKey(line string) string {
+	line = strings.TrimPrefix(line, "- ")
+	key, _, ok := strings.Cut(line, ":")
+	if !ok {
+		return ""
+	}
+	return strings.Trim(key, `"' `)
+}
+
+// isHighEntropy reports whether a token looks like random key material:
+// mixed-case letters and digits with high Shannon entropy
+func isHighEntropy(token string) bool {
+	token = strings.TrimRight(token, "=")
+	if len(token) < minEntropyTokenLength {
+		return false
+	}
+	var upper, lower, digit bool
+	for _, r := range token {
+		switch {
+		case r >= 'A' && r <= 'Z':
+			upper = true
+		case r >= 'a' && r <= 'z':
+			lower = true
+		case r >= '0' && r <= '9':
+			digit = true
+		}
+	}
+	return upper && lower && digit && shannonEntropy(token) >= minEntropyBits
+}
+
+// shannonEntropy returns the entropy of s in bits per character
+func shannonEntropy(s string) float64 {
+	counts := make(map[rune]int)
+	for _, r := range s {
+		counts[r]++
+	}
+	var entropy float64
+	n := float64(len(s))
+	for _, c := range counts {
+		p := float64(c) / n
+		entropy -= p * math.Log2(p)
+	}
+	return entropy
+}
+
+// decodeBase64 decodes standard or URL-safe base64
+func decodeBase64(s string) (string, bool) {
+	for _, enc := range []*base64.Encoding{base64.StdEncoding, base64.RawStdEncoding, base64.URLEncoding, base64.RawURLEncoding} {
+		if b, err := enc.DecodeString(s); err == nil {
+			return string(b), true
+		}
+	}
+	return "", false
+}
+
+// maskSecret keeps the first four characters of a matched value so findings
+// can be located without republishing the credential
+func maskSecret(s string) string {
+	if len(s) <= 8 {
+		return strings.Repeat("*", len(s))
+	}
+	return s[:4] + strings.Repeat("*", min(len(s)-4, 12))
+}
@@ -0,0 +1,155 @@
+package sync
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/erauner/homelab-shadow/pkg/config"
+)
+
+// runShadowRepos publishes each configured shadow repo's share of found on
+// its own: the directories of its clusters (and, for a shared repo, those
+// belonging to no cluster) are rendered into a fresh clone, committed, and
+// pushed to its branch. A dry run renders each repo into its own
+// subdirectory of OutDir. Repos receiving none of the selected Clusters are
+// left alone, rather than having everything pruned. The first repo that fails
+// stops the run.
+func (s *Syncer) runShadowRepos(ctx context.Context, found []discovered, result Result) (Result, error) {
+	s.warnUnmapped(found)
+	for _, repo := range s.opts.ShadowRepos {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+		child, err := s.forShadowRepo(repo)
+		if err != nil {
+			return result, err
+		}
+		if len(s.opts.Clusters) > 0 && len(child.opts.Clusters) == 0 {
+			s.log.Debugf("Skipping %s (none of the selected clusters)", repo.Repo)
+			continue
+		}
+		child.log.Debugf("Syncing clusters %s to %s", strings.Join(repo.Clusters, ", "), repo.Repo)
+		repoResult, err := child.publish(ctx, shareOf(found, repo, child.opts.Clusters), Result{
+			ShadowRepoSlug: repo.Repo,
+			BaseBranch:     child.opts.BaseBranch,
+			Branch:         child.opts.Branch,
+			Acks:           s.acks,
+		})
+		result.add(repoResult)
+		if err != nil {
+			return result, fmt.Errorf("%s: %w", repo.Repo, err)
+		}
+	}
+	if s.opts.DryRun {
+		result.DryRun = true
+		result.OutputDir, _ = filepath.Abs(s.opts.OutDir)
+		result.CommitMessage = s.buildCommitMessage()
+	}
+	return result, nil
+}
+
+// forShadowRepo returns a copy of the syncer that publishes to repo
+func (s *Syncer) forShadowRepo(repo config.ShadowRepo) (*Syncer, error) {
+	shadow, err := ParseRemote(repo.Repo, s.opts.Provider)
+	if err != nil {
+		return nil, fmt.Errorf("invalid shadow repo %s: %w", repo.Repo, err)
+	}
+	child := *s
+	child.shadow = shadow
+	child.opts.ShadowRepos = nil
+	child.opts.ShadowRepo = repo.Repo
+	child.opts.Clusters = repoClusters(s.opts.Clusters, repo)
+	if repo.BaseBranch != "" {
+		if s.opts.SyncTarget == SyncTargetBase {
+			child.opts.Branch = repo.BaseBranch
+		}
+		child.opts.BaseBranch = repo.BaseBranch
+	}
+	if s.opts.DryRun {
+		child.opts.OutDir = filepath.Join(s.opts.OutDir, strings.ReplaceAll(shadow.Slug, "/", "_"))
+	}
+	child.log = s.log.Named(shadow.Slug)
+	return &child, nil
+}
+
+// repoClusters is the clusters a shadow repo receives, limited to the
+// selected clusters when there are any (noted in its _meta.json)
+func repoClusters(selected []string, repo config.ShadowRepo) []string {
+	if len(selected) == 0 {
+		return repo.Clusters
+	}
+	var clusters []string
+	for _, c := range selected {
+		if repo.Covers(c) {
+			clusters = append(clusters, c)
+		}
+	}
+	return clusters
+}
+
+// shareOf returns the discovered targets whose directories repo receives:
+// those of clusters, plus those without a cluster for a shared repo
+func shareOf(found []discovered, repo config.ShadowRepo, clusters []string) []discovered {
+	share := make([]discovered, 0, len(found))
+	for _, d := range found {
+		var targets []Target
+		for _, target := range d.targets {
+			cluster := ClusterForDirectory(target.Dir)
+			if (cluster == "" && repo.Shared) || (cluster != "" && containsString(clusters, cluster)) {
+				targets = append(targets, target)
+			}
+		}
+		share = append(share, discovered{renderer: d.renderer, targets: targets})
+	}
+	return share
+}
+
+// warnUnmapped warns about discovered directories no shadow repo receives
+func (s *Syncer) warnUnmapped(found []discovered) {
+	unmapped := 0
+	for _, d := range found {
+		for _, target := range d.targets {
+			cluster := ClusterForDirectory(target.Dir)
+			covered := false
+			for _, repo := range s.opts.ShadowRepos {
+				covered = covered || repo.Covers(cluster)
+			}
+			if !covered {
+				s.log.Debugf("Skipping %s (no shadow repo receives cluster %q)", target.Dir, cluster)
+				unmapped++
+			}
+		}
+	}
+	if unmapped > 0 {
+		s.log.Warnf("%d director(ies) belong to no shadow repo in shadowRepos and were not rendered (see --verbose)", unmapped)
+	}
+}
+
+// add folds the result of one shadow repo into the overall result
+func (r *Result) add(repo Result) {
+	r.RenderedDirs += repo.RenderedDirs
+	r.SkippedDirs += repo.SkippedDirs
+	r.FailedDirs += repo.FailedDirs
+	r.HelmAppsRendered += repo.HelmAppsRendered
+	r.HelmAppsFailed += repo.HelmAppsFailed
+	r.SchemaFailures += repo.SchemaFailures
+	r.PrunedFiles += repo.PrunedFiles
+	r.KeptFiles += repo.KeptFiles
+	r.ChartCacheHits += repo.ChartCacheHits
+	r.ChartCacheMisses += repo.ChartCacheMisses
+	r.BudgetExceeded = r.BudgetExceeded || repo.BudgetExceeded
+	r.BudgetSkipped = append(r.BudgetSkipped, repo.BudgetSkipped...)
+	r.DeprecatedAPIs = append(r.DeprecatedAPIs, repo.DeprecatedAPIs...)
+	r.Failures = append(r.Failures, repo.Failures...)
+	r.SecretFindings = append(r.SecretFindings, repo.SecretFindings...)
+	r.Gates = append(r.Gates, repo.Gates...)
+	for phase, seconds := range repo.PhaseSeconds {
+		if r.PhaseSeconds == nil {
+			r.PhaseSeconds = make(map[string]float64)
+		}
+		r.PhaseSeconds[phase] += seconds
+	}
+	r.Repos = append(r.Repos, repo)
+}
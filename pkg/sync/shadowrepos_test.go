@@ -0,0 +1,126 @@
+package sync
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/erauner/homelab-shadow/pkg/config"
+)
+
+func shadowReposRenderer() Renderer {
+	return &fakeRenderer{
+		source: config.OutputSourceKustomize,
+		manifests: map[string]string{
+			"apps/web/overlays/erauner-home/production":  "kind: ConfigMap\nmetadata:\n  name: home\n",
+			"apps/web/overlays/erauner-cloud/production": "kind: ConfigMap\nmetadata:\n  name: cloud\n",
+			"apps/legacy/overlays/production":            "kind: ConfigMap\nmetadata:\n  name: legacy\n",
+			"operators/vault/overlays/erauner-lab":       "kind: ConfigMap\nmetadata:\n  name: lab\n",
+		},
+	}
+}
+
+func TestRun_ShadowRepos(t *testing.T) {
+	home, cloud := newShadowRemote(t), newShadowRemote(t)
+	for _, remote := range []string{home, cloud} {
+		if out, err := exec.Command("git", "-C", remote, "symbolic-ref", "HEAD", "refs/heads/main").CombinedOutput(); err != nil {
+			t.Fatalf("git symbolic-ref: %v\n%s", err, out)
+		}
+	}
+	for _, key := range []string{"GIT_AUTHOR_NAME", "GIT_COMMITTER_NAME"} {
+		t.Setenv(key, "test")
+	}
+	for _, key := range []string{"GIT_AUTHOR_EMAIL", "GIT_COMMITTER_EMAIL"} {
+		t.Setenv(key, "test@example.com")
+	}
+
+	syncer, err := New(Options{
+		RepoPath: t.TempDir(),
+		ShadowRepos: []config.ShadowRepo{
+			{Repo: "file://" + home, Clusters: []string{"erauner-home"}, Shared: true},
+			{Repo: "file://" + cloud, Clusters: []string{"erauner-cloud"}},
+		},
+		PRNumber:  "12",
+		Renderers: []Renderer{shadowReposRenderer()},
+	})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	result, err := syncer.Run()
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if len(result.Repos) != 2 || result.RenderedDirs != 3 {
+		t.Fatalf("repos = %d, rendered = %d; want 2 repos and 3 dirs (erauner-lab has no repo)", len(result.Repos), result.RenderedDirs)
+	}
+	if r := result.Repos[1]; r.Branch != "pr-12" || r.RenderedDirs != 1 || r.CommitSHA == "" {
+		t.Errorf("cloud result = %+v", r)
+	}
+
+	show := func(remote, file string) string {
+		out, _ := exec.Command("git", "-C", remote, "show", "pr-12:rendered/"+file).CombinedOutput()
+		return string(out)
+	}
+	for remote, want := range map[string][]string{
+		home:  {"apps/web/overlays/erauner-home/production/manifest.yaml", "apps/legacy/overlays/production/manifest.yaml"},
+		cloud: {"apps/web/overlays/erauner-cloud/production/manifest.yaml"},
+	} {
+		for _, file := range want {
+			if !strings.Contains(show(remote, file), "kind: ConfigMap") {
+				t.Errorf("%s: missing %s", remote, file)
+			}
+		}
+	}
+	if strings.Contains(show(cloud, "apps/web/overlays/erauner-home/production/manifest.yaml"), "kind:") {
+		t.Error("erauner-home manifest pushed to the cloud shadow repo")
+	}
+	if !strings.Contains(show(cloud, "_meta.json"), `"erauner-cloud"`) {
+		t.Errorf("cloud _meta.json should list its cluster:\n%s", show(cloud, "_meta.json"))
+	}
+}
+
+func TestRun_ShadowReposDryRun(t *testing.T) {
+	out := t.TempDir()
+	syncer, err := New(Options{
+		RepoPath: t.TempDir(),
+		DryRun:   true,
+		OutDir:   out,
+		Clusters: []string{"erauner-cloud"},
+		ShadowRepos: []config.ShadowRepo{
+			{Repo: "erauner/shadow-home", Clusters: []string{"erauner-home"}},
+			{Repo: "erauner/shadow-cloud", Clusters: []string{"erauner-cloud"}, BaseBranch: "master"},
+		},
+		Renderers: []Renderer{shadowReposRenderer()},
+	})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	result, err := syncer.Run()
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if !result.DryRun || len(result.Repos) != 1 || result.Repos[0].BaseBranch != "master" {
+		t.Fatalf("unexpected result %+v", result)
+	}
+	if md := result.Markdown(); !strings.Contains(md, "| erauner/shadow-cloud | 1 | 0 | - |") || strings.Contains(md, "shadow-home") {
+		t.Errorf("Markdown() should list the synced repo only:\n%s", md)
+	}
+	if _, err := os.Stat(filepath.Join(out, "erauner_shadow-cloud", "apps/web/overlays/erauner-cloud/production/manifest.yaml")); err != nil {
+		t.Errorf("expected the cloud manifest under its repo's directory: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(out, "erauner_shadow-home", "apps/web/overlays/erauner-home/production/manifest.yaml")); err == nil {
+		t.Error("home synced although --cluster selected erauner-cloud only")
+	}
+}
+
+func TestNew_ShadowRepos(t *testing.T) {
+	repos := []config.ShadowRepo{{Repo: "erauner/shadow-home", Clusters: []string{"erauner-home"}}}
+	if _, err := New(Options{RepoPath: ".", ShadowRepo: "erauner/shadow", ShadowRepos: repos}); err == nil {
+		t.Error("expected error for ShadowRepo together with ShadowRepos")
+	}
+	if _, err := New(Options{RepoPath: ".", ShadowRepos: []config.ShadowRepo{{Repo: "::"}}}); err == nil {
+		t.Error("expected error for an unparseable shadow repo")
+	}
+}
@@ -2,16 +2,36 @@
 package sync
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"io/fs"
 	"os"
+	"path"
 	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 
 	"github.com/erauner/homelab-shadow/pkg/argocd"
+	"github.com/erauner/homelab-shadow/pkg/cluster"
+	"github.com/erauner/homelab-shadow/pkg/command"
+	"github.com/erauner/homelab-shadow/pkg/config"
 	"github.com/erauner/homelab-shadow/pkg/helm"
 	"github.com/erauner/homelab-shadow/pkg/kustomize"
+	"github.com/erauner/homelab-shadow/pkg/kyverno"
+	"github.com/erauner/homelab-shadow/pkg/log"
+	"github.com/erauner/homelab-shadow/pkg/progress"
+	"github.com/erauner/homelab-shadow/pkg/trace"
+)
+
+// Sync targets: the shadow branch a sync updates
+const (
+	// SyncTargetPR renders into a PR branch (Branch) compared against BaseBranch
+	SyncTargetPR = "pr"
+	// SyncTargetBase renders into BaseBranch itself, refreshing the snapshot PR
+	// branches are compared against (run on merges to the source repo's main branch)
+	SyncTargetBase = "base"
 )
 
 // Options configures the sync operation
@@ -23,23 +43,143 @@ type Options struct {
 	Clusters []string
 
 	// Shadow repo configuration
-	ShadowRepo string // GitHub slug (owner/repo) or git URL
-	BaseBranch string // Default: "main"
-	Branch     string // Default: "pr-<id>" or "local-<timestamp>"
-	OutputRoot string // Default: "rendered"
+	ShadowRepo string   // Slug (owner/repo) or git URL
+	Provider   Provider // github, gitlab, or gitea (default: detected from ShadowRepo's host)
+	BaseBranch string   // Default: "main"
+	Branch     string   // Default: "pr-<id>" or "local-<timestamp>"; BaseBranch with SyncTargetBase
+	OutputRoot string   // Default: "rendered"
+
+	// SyncTarget is SyncTargetPR (default) or SyncTargetBase
+	SyncTarget string
+
+	// ShadowRepos fans renders out to one shadow repo per group of clusters
+	// instead of ShadowRepo: each repo is cloned, rendered into, committed,
+	// and pushed on its own (see config.ShadowRepo)
+	ShadowRepos []config.ShadowRepo
+
+	// Publish selects where output goes: PublishGit (default) commits and
+	// pushes to the shadow repo, PublishDir and PublishS3 store it under
+	// <PublishTo>/<Branch> in a local directory or an s3:// or gs:// bucket
+	Publish   string
+	PublishTo string
+
+	// Outputs splits rendering across several shadow repo roots (default: everything in OutputRoot)
+	Outputs []config.Output
+
+	// OutputLayout writes each rendered directory as one manifest.yaml
+	// (OutputLayoutSingle, default) or one file per resource (OutputLayoutSplit)
+	OutputLayout string
+
+	// GitRetries retries clone, fetch, and push after transient network failures,
+	// waiting GitRetryDelay before the first retry and doubling it after each
+	GitRetries    int
+	GitRetryDelay time.Duration
 
 	// Behavior options
-	ForcePush     bool // Default: true for PR branches
-	RedactSecrets bool // Default: true
-	CleanupMerged bool // Delete pr-* branches for closed PRs
+	// Force pushes, Secret redaction, and normalization are on unless
+	// disabled, so zero-valued Options publish safely
+	DisableForcePush bool // Push without --force
+	DisableRedaction bool // Publish Secret data as-is and skip the secret scan (see ScanSecrets)
+	CleanupMerged    bool // Delete pr-* branches for closed PRs
+
+	// AllowSecretFindings publishes manifests the secret scan flagged, reporting
+	// the findings instead of refusing to commit
+	AllowSecretFindings bool
+
+	// Redaction adds kinds and field paths to redact besides Secret data, and
+	// resources to publish as-is (from .shadow.yaml)
+	Redaction config.Redaction
+
+	// DisableNormalize writes manifests as rendered instead of sorting
+	// resources and stripping volatile annotations (see Normalizer)
+	DisableNormalize bool
+	Normalization    config.Normalization
+
+	// KeepFailed leaves the previously rendered manifest of a directory that fails
+	// to render in place instead of pruning it, so a broken build is not shown as a deletion
+	KeepFailed bool
+
+	// Budget bounds the run's wall-clock time (0 = unlimited). Once it is
+	// spent, the target being rendered finishes, the rest keep their previously
+	// published manifests, and the partial result is still committed and pushed
+	Budget time.Duration
+
+	// PolicyImpactBase reports which rendered resources newly violate, or stop
+	// violating, the Kyverno policies changed since this git ref, written to
+	// _policy-impact.md in each output root (requires kyverno)
+	PolicyImpactBase string
+
+	// KyvernoPolicyRoots are the repo-relative Kyverno policy trees the policy
+	// impact analysis reads (default: kyverno.DefaultRoots)
+	KyvernoPolicyRoots []string
+
+	// Record writes a bundle of the run's inputs and outputs to this path
+	// (.tar.gz, or .tar.zst with the zstd CLI) for Replay
+	Record string
+
+	// Version is the shadow version noted in recordings
+	Version string
+
+	// Renderers replaces the registered renderers (see RegisterRenderer), e.g. with fakes in tests
+	Renderers []Renderer
+
+	// KustomizeEngine selects exec (default), krusty, or auto builds
+	KustomizeEngine kustomize.Engine
+
+	// HelmCacheDir caches downloaded charts between renders (empty = disabled)
+	HelmCacheDir string
+
+	// HelmCredentialsFile adds credentials for private Helm repositories to
+	// those found in the repo and environment (see HelmCredentials)
+	HelmCredentialsFile string
+
+	// ValidateSchemas runs kubeconform on each rendered manifest (requires kubeconform)
+	ValidateSchemas   bool
+	KubernetesVersion string // kubeconform -kubernetes-version (default: kustomize runner default)
+
+	// CRDSchemaDir caches kubeconform schemas generated from the repo's CRD
+	// directories and rendered CRDs (default: kustomize.DefaultSchemaDir)
+	CRDSchemaDir string
 
 	// Source metadata (for commit messages and _meta.json)
 	SourceCommit string
 	SourceRepo   string
 	PRNumber     string
 
+	// SignCommits signs shadow repo commits with git: "gpg" (SigningKey is a
+	// key ID, default: the committer's) or "ssh" (SigningKey is a key file or
+	// "key::<public key>" for an agent key); empty leaves commits unsigned
+	SignCommits string
+	SigningKey  string
+
+	// Provenance writes an in-toto SLSA provenance attestation (_provenance.json)
+	// into each output root: source commit, tool versions, and the digest of
+	// every directory rendered this run
+	Provenance bool
+
+	// RequireAck refuses to publish gated changes (CRD changes, namespace deletions)
+	// unless acknowledged on the source PR with "/shadow ack <gate>" or a shadow-ack/<gate> label
+	RequireAck bool
+
+	// DryRun renders into OutDir instead of cloning, committing, and pushing
+	DryRun bool
+	OutDir string // Local output directory for DryRun (replaces <shadow>/<OutputRoot>, or the shadow repo root with Outputs)
+
+	// UseSystemGit runs the git binary for shadow repo clones, commits, and
+	// pushes instead of the built-in go-git implementation, which needs no git
+	// installed. SignCommits always uses the git binary
+	UseSystemGit bool
+
 	// Runtime
-	Verbose bool
+	Verbose  bool
+	Progress bool        // report rendering progress and an ETA on stderr (see progress.Reporter)
+	Log      *log.Logger // default: the shared logger
+}
+
+// systemGit reports whether shadow repo operations run the git binary: with
+// UseSystemGit, and for signed commits, which go-git can't sign as git does
+func (o Options) systemGit() bool {
+	return o.UseSystemGit || o.SignCommits != ""
 }
 
 // Result contains the outcome of a sync operation
@@ -50,6 +190,9 @@ type Result struct {
 	CompareURL     string `json:"compare_url"`
 	CommitSHA      string `json:"commit_sha,omitempty"`
 
+	// Location is where the dir and s3 publishers stored the output
+	Location string `json:"location,omitempty"`
+
 	RenderedDirs int `json:"rendered_dirs"`
 	SkippedDirs  int `json:"skipped_dirs"`
 	FailedDirs   int `json:"failed_dirs"`
@@ -58,10 +201,96 @@ type Result struct {
 	HelmAppsRendered int `json:"helm_apps_rendered,omitempty"`
 	HelmAppsFailed   int `json:"helm_apps_failed,omitempty"`
 
+	// SchemaFailures counts rendered manifests that failed kubeconform (with ValidateSchemas)
+	SchemaFailures int `json:"schema_failures,omitempty"`
+
+	// DeprecatedAPIs are rendered resources using APIs deprecated in
+	// KubernetesVersion (with ValidateSchemas); removed APIs are Failures
+	DeprecatedAPIs []DeprecatedAPIFinding `json:"deprecated_apis,omitempty"`
+
+	// PrunedFiles counts files removed from output roots because they were no longer rendered
+	PrunedFiles int `json:"pruned_files,omitempty"`
+
+	// KeptFiles counts previously rendered manifests kept for failed directories
+	// (with KeepFailed) and for directories skipped over Budget
+	KeptFiles int `json:"kept_files,omitempty"`
+
+	// BudgetExceeded reports that Budget ran out before every target was
+	// rendered; BudgetSkipped lists the directories left unrendered
+	BudgetExceeded bool     `json:"budget_exceeded,omitempty"`
+	BudgetSkipped  []string `json:"budget_skipped,omitempty"`
+
+	// PolicyImpact is the Kyverno policy impact report (with PolicyImpactBase)
+	PolicyImpact *kyverno.ImpactReport `json:"policy_impact,omitempty"`
+
 	Failures []DirFailure `json:"failures,omitempty"`
 
+	// SecretFindings are values that look like credentials outside Secrets (unless DisableRedaction)
+	SecretFindings []SecretFinding `json:"secret_findings,omitempty"`
+
 	// Cleanup results (populated if cleanup was performed)
 	Cleanup *CleanupResult `json:"cleanup,omitempty"`
+
+	// Dry-run results (populated instead of CommitSHA/CompareURL)
+	DryRun        bool   `json:"dry_run,omitempty"`
+	OutputDir     string `json:"output_dir,omitempty"`
+	CommitMessage string `json:"commit_message,omitempty"`
+
+	// Changes is what the sync changed (vs the output directory or base branch)
+	Changes *ChangeSummary `json:"changes,omitempty"`
+
+	// Gated changes found in Changes and the PR acknowledgments read for them (with RequireAck)
+	Gates []GatedChange `json:"gates,omitempty"`
+	Acks  []Ack         `json:"acks,omitempty"`
+
+	// ChartCacheHits and ChartCacheMisses count Helm renders whose chart was
+	// found in, or downloaded into, the chart cache (with HelmCacheDir)
+	ChartCacheHits   int `json:"chart_cache_hits,omitempty"`
+	ChartCacheMisses int `json:"chart_cache_misses,omitempty"`
+
+	// PhaseSeconds is how long each phase of the run took: discover, clone,
+	// render, schema, policy_impact, commit, push, and cleanup
+	PhaseSeconds map[string]float64 `json:"phase_seconds,omitempty"`
+
+	// Repos are the results of each shadow repo with ShadowRepos; the
+	// counts above are their totals
+	Repos []Result `json:"repos,omitempty"`
+}
+
+// countChartCache counts a render's chart cache outcome ("hit", "miss", or "")
+func (r *Result) countChartCache(outcome string) {
+	switch outcome {
+	case "hit":
+		r.ChartCacheHits++
+	case "miss":
+		r.ChartCacheMisses++
+	}
+}
+
+// timePhase records the time since start as the duration of phase
+func (r *Result) timePhase(phase string, start time.Time) {
+	if r.PhaseSeconds == nil {
+		r.PhaseSeconds = make(map[string]float64)
+	}
+	r.PhaseSeconds[phase] += time.Since(start).Round(time.Millisecond).Seconds()
+}
+
+// ChangeSummary lists files a sync changes, relative to the output directory for a
+// dry run (against whatever it held before) or the shadow repo root (against the base branch)
+type ChangeSummary struct {
+	Added    []string `json:"added"`
+	Modified []string `json:"modified"`
+	Removed  []string `json:"removed"`
+
+	// Resources buckets each changed resource in rendered manifests; Categories
+	// counts resources per bucket (see Headline)
+	Resources  []ResourceChange `json:"resources,omitempty"`
+	Categories map[string]int   `json:"categories,omitempty"`
+}
+
+// Headline summarizes changed resources at a glance, e.g. "3 image bumps, 1 RBAC change"
+func (c *ChangeSummary) Headline() string {
+	return Headline(c.Categories)
 }
 
 // DirFailure represents a failed directory render
@@ -70,6 +299,27 @@ type DirFailure struct {
 	Error     string `json:"error"`
 }
 
+// dirManifest is a rendered manifest and the directory it was rendered from
+type dirManifest struct {
+	dir      string
+	manifest string
+}
+
+// DeprecatedAPIFinding is a resource in a rendered directory whose apiVersion
+// is deprecated in the target Kubernetes version
+type DeprecatedAPIFinding struct {
+	Directory string `json:"directory"`
+	kustomize.DeprecatedAPI
+}
+
+// String describes the finding, prefixed with its directory
+func (f DeprecatedAPIFinding) String() string {
+	return fmt.Sprintf("%s: %s", f.Directory, f.DeprecatedAPI)
+}
+
+// PolicyImpactFile is the Kyverno policy impact report in each output root
+const PolicyImpactFile = "_policy-impact.md"
+
 // Metadata stored in _meta.json in shadow repo
 type Metadata struct {
 	SourceRepo  string   `json:"source_repo"`
@@ -77,11 +327,66 @@ type Metadata struct {
 	PRNumber    string   `json:"pr,omitempty"`
 	Clusters    []string `json:"clusters"`
 	GeneratedAt string   `json:"generated_at"`
+	Acks        []Ack    `json:"acks,omitempty"` // gate acknowledgments read from the PR
+
+	// BudgetSkipped lists directories whose manifests are left over from an
+	// earlier sync because the render budget ran out
+	BudgetSkipped []string `json:"budget_skipped,omitempty"`
+
+	// Directories describes each directory rendered into the root this run
+	Directories []DirMetadata `json:"directories,omitempty"`
+}
+
+// DirMetadata describes one rendered directory in _meta.json, so tooling can
+// spot no-op renders and report statistics without parsing manifests
+type DirMetadata struct {
+	Source   string `json:"source"`   // repo-relative directory (apps/<app>/helm for Helm sources)
+	Renderer string `json:"renderer"` // kustomize or helm
+	Path     string `json:"path"`     // manifest path in the root (its directory with the split layout)
+
+	// SHA256 is the digest of the published manifest, after redaction and normalization
+	SHA256          string         `json:"sha256"`
+	Resources       map[string]int `json:"resources"` // resource count by kind
+	DurationSeconds float64        `json:"duration_seconds"`
+
+	// Destinations are the Applications deploying the directory and where
+	// they deploy (see argocd.Application.Destination)
+	Destinations []argocd.Destination `json:"destinations,omitempty"`
 }
 
 // Syncer manages the shadow repo sync process
 type Syncer struct {
 	opts Options
+
+	// defaultOutputs is set when Outputs was not configured and holds just OutputRoot
+	defaultOutputs bool
+
+	// acks are the gate acknowledgments read from the source PR
+	acks []Ack
+
+	// shadow and source are the shadow and source repos on their provider
+	// A bare source slug inherits the shadow repo's provider and host
+	shadow Remote
+	source Remote
+
+	// git runs shadow repo operations (see Options.UseSystemGit)
+	git gitBackend
+
+	// redactor applies the Secret rule and the Redaction policy
+	redactor *Redactor
+
+	// normalizer applies the Normalization settings
+	normalizer *Normalizer
+
+	// started is when the run began; deadline is when Budget runs out (zero
+	// without a Budget)
+	started  time.Time
+	deadline time.Time
+
+	// recording collects rendered targets when Record is set
+	recording *Recording
+
+	log *log.Logger
 }
 
 // New creates a new Syncer with the given options
@@ -93,6 +398,19 @@ func New(opts Options) (*Syncer, error) {
 	if opts.OutputRoot == "" {
 		opts.OutputRoot = "rendered"
 	}
+	switch opts.SyncTarget {
+	case "", SyncTargetPR:
+	case SyncTargetBase:
+		if opts.Branch != "" && opts.Branch != opts.BaseBranch {
+			return nil, fmt.Errorf("branch %s can't be set when syncing the base branch %s", opts.Branch, opts.BaseBranch)
+		}
+		if opts.RequireAck {
+			return nil, fmt.Errorf("acknowledgments are only required on PR branches, not when syncing the base branch")
+		}
+		opts.Branch = opts.BaseBranch
+	default:
+		return nil, fmt.Errorf("unknown sync target %q (expected pr or base)", opts.SyncTarget)
+	}
 	if opts.Branch == "" {
 		if opts.PRNumber != "" {
 			opts.Branch = fmt.Sprintf("pr-%s", opts.PRNumber)
@@ -105,231 +423,794 @@ func New(opts Options) (*Syncer, error) {
 	if opts.RepoPath == "" {
 		return nil, fmt.Errorf("RepoPath is required")
 	}
-	if opts.ShadowRepo == "" {
+	if opts.Budget < 0 {
+		return nil, fmt.Errorf("budget must not be negative, got %s", opts.Budget)
+	}
+	if opts.ValidateSchemas && !kustomize.IsKubeconformInstalled() {
+		return nil, fmt.Errorf("schema validation requires kubeconform, which is %w", command.ErrNotInstalled)
+	}
+	if opts.PolicyImpactBase != "" && !kyverno.IsKyvernoInstalled() {
+		return nil, fmt.Errorf("policy impact analysis requires kyverno, which is %w", command.ErrNotInstalled)
+	}
+	if opts.DryRun {
+		if opts.OutDir == "" {
+			return nil, fmt.Errorf("OutDir is required for dry run")
+		}
+	} else if opts.Publish != "" && opts.Publish != PublishGit {
+		// Other backends need neither a shadow repo nor git
+	} else if opts.ShadowRepo == "" && len(opts.ShadowRepos) == 0 {
 		return nil, fmt.Errorf("ShadowRepo is required")
+	} else if opts.systemGit() && !IsGitInstalled() {
+		return nil, fmt.Errorf("sync with the system git requires git, which is %w (signed commits always use it)", command.ErrNotInstalled)
+	}
+	if err := validatePublish(opts); err != nil {
+		return nil, err
+	}
+	if err := validateSigning(opts.SignCommits, opts.SigningKey); err != nil {
+		return nil, err
+	}
+
+	if opts.ShadowRepo != "" && len(opts.ShadowRepos) > 0 {
+		return nil, fmt.Errorf("ShadowRepo and ShadowRepos are mutually exclusive")
 	}
 
-	return &Syncer{opts: opts}, nil
+	switch opts.OutputLayout {
+	case "", OutputLayoutSingle, OutputLayoutSplit:
+	default:
+		return nil, fmt.Errorf("unknown output layout %q (expected single or split)", opts.OutputLayout)
+	}
+
+	defaultOutputs := len(opts.Outputs) == 0
+	if defaultOutputs {
+		opts.Outputs = []config.Output{{Path: opts.OutputRoot}}
+	}
+	// Output roots are pruned on every run, so never let one cover the repo root or .git
+	for i := range opts.Outputs {
+		clean, err := config.CleanOutputPath(opts.Outputs[i].Path)
+		if err != nil {
+			return nil, err
+		}
+		opts.Outputs[i].Path = clean
+	}
+
+	logger := opts.Log
+	if logger == nil {
+		logger = log.Default()
+	}
+
+	s := &Syncer{
+		opts:           opts,
+		defaultOutputs: defaultOutputs,
+		git:            newGitBackend(opts.systemGit()),
+		redactor:       NewRedactor(opts.Redaction),
+		normalizer:     NewNormalizer(opts.Normalization),
+		log:            logger.Named("sync").Verbose(opts.Verbose),
+	}
+	if opts.ShadowRepo != "" {
+		shadow, err := ParseRemote(opts.ShadowRepo, opts.Provider)
+		if err != nil {
+			return nil, fmt.Errorf("invalid shadow repo: %w", err)
+		}
+		s.shadow = shadow
+	}
+	for _, repo := range opts.ShadowRepos {
+		shadow, err := ParseRemote(repo.Repo, opts.Provider)
+		if err != nil {
+			return nil, fmt.Errorf("invalid shadow repo %s: %w", repo.Repo, err)
+		}
+		if s.shadow.Slug == "" {
+			s.shadow = shadow // a bare source slug inherits the first repo's host
+		}
+	}
+	if opts.SourceRepo != "" {
+		// The source repo is only needed as a remote for cleanup and acknowledgments
+		if source, err := sourceRemote(opts.SourceRepo, opts.Provider, s.shadow); err == nil {
+			s.source = source
+		}
+	}
+	return s, nil
 }
 
 // Run executes the sync operation
 func (s *Syncer) Run() (Result, error) {
+	return s.RunContext(context.Background())
+}
+
+// RunContext executes the sync operation, stopping before the next render,
+// commit, or push once ctx is done
+func (s *Syncer) RunContext(ctx context.Context) (Result, error) {
+	ctx, span := trace.Start(ctx, "sync",
+		"shadow.branch", s.opts.Branch,
+		"shadow.pr", s.opts.PRNumber,
+		"shadow.dry_run", s.opts.DryRun)
+	result, err := s.run(ctx)
+	span.SetAttr("shadow.rendered_dirs", result.RenderedDirs+result.HelmAppsRendered)
+	span.SetAttr("shadow.failed_dirs", result.FailedDirs)
+	span.End(err)
+	return result, err
+}
+
+// run is RunContext under its span
+func (s *Syncer) run(ctx context.Context) (Result, error) {
+	s.started = time.Now()
+	if s.opts.Budget > 0 {
+		s.deadline = s.started.Add(s.opts.Budget)
+	}
+	if s.opts.Record != "" {
+		s.recording = s.newRecording()
+		defer s.saveRecording()
+	}
 	result := Result{
 		ShadowRepoSlug: s.opts.ShadowRepo,
 		BaseBranch:     s.opts.BaseBranch,
 		Branch:         s.opts.Branch,
 	}
 
-	// 1. Discover directories to render
-	dirs, err := s.discoverDirectories()
+	// 1. Discover what each renderer will render
+	start := time.Now()
+	found, err := s.discover()
 	if err != nil {
-		return result, fmt.Errorf("failed to discover directories: %w", err)
+		return result, err
 	}
+	result.timePhase("discover", start)
 
-	s.logVerbose("Discovered %d directories to render", len(dirs))
+	if err := s.loadAcks(ctx); err != nil {
+		return result, err
+	}
+	result.Acks = s.acks
 
-	// 2. Clone shadow repo to temp directory
-	tempDir, err := os.MkdirTemp("", "shadow-sync-*")
-	if err != nil {
-		return result, fmt.Errorf("failed to create temp directory: %w", err)
+	if len(s.opts.ShadowRepos) > 0 {
+		return s.runShadowRepos(ctx, found, result)
 	}
-	defer os.RemoveAll(tempDir)
+	return s.publish(ctx, found, result)
+}
 
-	shadowDir := filepath.Join(tempDir, "shadow")
-	repoURL := GitURLFromSlug(s.opts.ShadowRepo)
+// publish renders found into the Publisher's checkout and publishes it
+// (commit and push by default), or renders into OutDir for a dry run
+func (s *Syncer) publish(ctx context.Context, found []discovered, result Result) (Result, error) {
+	if s.opts.DryRun {
+		return s.runDryRun(ctx, found, result)
+	}
 
-	s.logVerbose("Cloning shadow repo %s to %s", repoURL, shadowDir)
-	if err := Clone(repoURL, shadowDir); err != nil {
-		return result, fmt.Errorf("failed to clone shadow repo: %w", err)
+	publisher, err := s.newPublisher()
+	if err != nil {
+		return result, err
 	}
+	defer publisher.Close()
 
-	// 3. Checkout branch (create from base if new)
-	s.logVerbose("Checking out branch %s (base: %s)", s.opts.Branch, s.opts.BaseBranch)
-	if err := CheckoutBranch(shadowDir, s.opts.BaseBranch, s.opts.Branch); err != nil {
-		return result, fmt.Errorf("failed to checkout branch: %w", err)
+	// 2. Check out the base branch's output to render the branch over
+	start := time.Now()
+	shadowDir, err := publisher.Checkout(ctx)
+	if err != nil {
+		return result, err
 	}
+	result.timePhase("clone", start)
 
-	// 4. Clear and recreate output directory
-	outputDir := filepath.Join(shadowDir, s.opts.OutputRoot)
-	if err := os.RemoveAll(outputDir); err != nil {
-		return result, fmt.Errorf("failed to clear output directory: %w", err)
+	// 3. Render, redact, and verify manifests into the shadow repo's output roots
+	roots := s.outputRoots(shadowDir)
+	before, err := snapshotRoots(shadowDir, roots)
+	if err != nil {
+		return result, fmt.Errorf("failed to read shadow repo: %w", err)
 	}
-	if err := os.MkdirAll(outputDir, 0755); err != nil {
-		return result, fmt.Errorf("failed to create output directory: %w", err)
+	if err := s.render(ctx, found, roots, &result); err != nil {
+		return result, err
+	}
+	after, err := snapshotRoots(shadowDir, roots)
+	if err != nil {
+		return result, fmt.Errorf("failed to read shadow repo: %w", err)
+	}
+	result.Changes = diffTrees(before, after)
+	if err := s.checkGates(&result); err != nil {
+		return result, err
 	}
 
-	// 5. Build and write manifests for each directory
-	runner := kustomize.NewRunner(s.opts.RepoPath, "", s.opts.Verbose)
+	// 4. Publish: commit and push, or copy to the directory or bucket
+	if err := ctx.Err(); err != nil {
+		return result, err
+	}
+	s.log.Debugf("Publishing to %s", publisher.Name())
+	if err := publisher.Publish(ctx, shadowDir, &result); err != nil {
+		return result, err
+	}
 
-	for _, dir := range dirs {
-		s.logVerbose("Building %s", dir)
+	return result, nil
+}
 
-		buildResult := runner.BuildDirectory(dir)
+// render renders every discovered target into the output roots that include
+// it, writes _meta.json, prunes files that are no longer rendered, and verifies
+// redaction
+func (s *Syncer) render(ctx context.Context, found []discovered, roots []outputRoot, result *Result) (err error) {
+	ctx, span := trace.Start(ctx, "render")
+	defer func() { span.End(err) }()
 
-		if buildResult.Skipped {
-			result.SkippedDirs++
-			continue
+	for _, root := range roots {
+		if err := os.MkdirAll(root.dir, 0755); err != nil {
+			return fmt.Errorf("failed to create output directory: %w", err)
 		}
+	}
 
-		if !buildResult.Passed {
-			result.FailedDirs++
-			result.Failures = append(result.Failures, DirFailure{
-				Directory: dir,
-				Error:     buildResult.Error.Error(),
-			})
-			continue
-		}
+	// kubeconform runs through the kustomize runner whichever renderer produced the manifest
+	runner := kustomize.NewRunner(s.opts.RepoPath, s.opts.KubernetesVersion, s.opts.Verbose)
+	runner.Log = s.log.Named("kustomize")
 
-		// Redact secrets if enabled
-		manifest := buildResult.Output
-		if s.opts.RedactSecrets {
-			manifest = RedactSecrets(manifest)
-		}
+	registry, err := cluster.Load(s.opts.RepoPath)
+	if err != nil {
+		return err
+	}
 
-		// Write manifest to shadow repo
-		manifestPath := filepath.Join(outputDir, dir, "manifest.yaml")
-		if err := os.MkdirAll(filepath.Dir(manifestPath), 0755); err != nil {
-			result.FailedDirs++
-			result.Failures = append(result.Failures, DirFailure{
-				Directory: dir,
-				Error:     fmt.Sprintf("failed to create directory: %v", err),
-			})
-			continue
-		}
+	destinations := appDestinations(s.opts.RepoPath, registry, s.log)
 
-		if err := os.WriteFile(manifestPath, []byte(manifest), 0644); err != nil {
-			result.FailedDirs++
-			result.Failures = append(result.Failures, DirFailure{
-				Directory: dir,
-				Error:     fmt.Sprintf("failed to write manifest: %v", err),
-			})
-			continue
-		}
+	// rendered collects each directory's manifest for policy impact analysis
+	rendered := make(map[string]string)
+
+	// dirMeta collects the _meta.json directory entries of each root
+	dirMeta := make(map[string][]DirMetadata)
+
+	// Schema validation waits until every target is rendered, so custom
+	// resources are checked against CRDs from operators rendered after them
+	var pendingSchemas []dirManifest
+	var renderedCRDs []string
 
-		result.RenderedDirs++
+	var report *progress.Reporter
+	if s.opts.Progress {
+		total := 0
+		for _, d := range found {
+			total += len(d.targets)
+		}
+		report = progress.New("render", total)
+		defer report.Finish()
 	}
+	phaseStart := time.Now()
 
-	// 5b. Render Helm charts from multi-source Applications (issue #1089)
-	if helm.IsHelmInstalled() {
-		helmApps, err := argocd.DiscoverHelmApplications(s.opts.RepoPath)
-		if err != nil {
-			s.logVerbose("Warning: failed to discover Helm applications: %v", err)
-		} else {
-			s.logVerbose("Discovered %d Applications with Helm sources", len(helmApps))
-
-			for _, app := range helmApps {
-				for _, source := range app.GetHelmSources() {
-					s.logVerbose("Rendering Helm chart for %s: %s/%s@%s",
-						app.Name, source.RepoURL, source.Chart, source.TargetRevision)
-
-					helmResult := s.renderHelmSource(app, &source)
-
-					if !helmResult.Passed {
-						result.HelmAppsFailed++
-						result.Failures = append(result.Failures, DirFailure{
-							Directory: fmt.Sprintf("apps/%s/helm", app.Name),
-							Error:     helmResult.Error.Error(),
-						})
-						continue
-					}
-
-					// Redact secrets if enabled
-					manifest := helmResult.Output
-					if s.opts.RedactSecrets {
-						manifest = RedactSecrets(manifest)
-					}
-
-					// Write Helm manifest to shadow repo
-					// Structure: apps/<appname>/helm/manifest.yaml
-					manifestPath := filepath.Join(outputDir, "apps", app.Name, "helm", "manifest.yaml")
-					if err := os.MkdirAll(filepath.Dir(manifestPath), 0755); err != nil {
-						result.HelmAppsFailed++
-						result.Failures = append(result.Failures, DirFailure{
-							Directory: fmt.Sprintf("apps/%s/helm", app.Name),
-							Error:     fmt.Sprintf("failed to create directory: %v", err),
-						})
-						continue
-					}
-
-					if err := os.WriteFile(manifestPath, []byte(manifest), 0644); err != nil {
-						result.HelmAppsFailed++
-						result.Failures = append(result.Failures, DirFailure{
-							Directory: fmt.Sprintf("apps/%s/helm", app.Name),
-							Error:     fmt.Sprintf("failed to write manifest: %v", err),
-						})
-						continue
-					}
-
-					result.HelmAppsRendered++
+	for _, d := range found {
+		source := d.renderer.Source()
+		for _, target := range d.targets {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			report.Start(target.Dir)
+			targets := rootsFor(roots, source, target.Dir)
+			if len(targets) == 0 {
+				s.log.Debugf("Skipping %s (no output root includes it)", target.Dir)
+				report.Done(true)
+				continue
+			}
+
+			// Commands the renderer runs (kustomize, helm, git) nest under the target's span
+			targetCtx, span := trace.Start(ctx, "render "+target.Dir,
+				"shadow.dir", target.Dir,
+				"shadow.renderer", d.renderer.Name())
+
+			// finish records the target's outcome and counts it as done
+			finish := func(status string, manifest Manifest, err error) {
+				s.record(d.renderer, target, status, manifest, err)
+				report.Done(status != TargetFailed)
+				span.SetAttr("shadow.status", status)
+				span.End(err)
+			}
+			if s.overBudget() {
+				s.skipOverBudget(targets, target.Dir, result)
+				finish(TargetBudgetSkipped, "", nil)
+				continue
+			}
+
+			name := target.Name
+			if name == "" {
+				name = target.Dir
+			}
+			s.log.Debugf("Rendering %s with %s", name, d.renderer.Name())
+			start := time.Now()
+
+			manifest, meta, err := d.renderer.Render(targetCtx, target)
+			result.countChartCache(meta.ChartCache)
+			if err != nil {
+				result.recordFailure(source, target.Dir, err)
+				s.keepFailed(targets, target.Dir, result)
+				finish(TargetFailed, "", err)
+				continue
+			}
+			if meta.Skipped {
+				result.SkippedDirs++
+				finish(TargetSkipped, "", nil)
+				continue
+			}
+
+			manifest, err = SubstituteVars(registry, target.Dir, manifest)
+			if err != nil {
+				result.recordFailure(source, target.Dir, err)
+				s.keepFailed(targets, target.Dir, result)
+				finish(TargetFailed, "", err)
+				continue
+			}
+
+			// Schema-validate what ArgoCD would apply; failures are recorded but the manifest is still published
+			if s.opts.ValidateSchemas {
+				pendingSchemas = append(pendingSchemas, dirManifest{dir: target.Dir, manifest: string(manifest)})
+				if strings.Contains(string(manifest), "CustomResourceDefinition") {
+					renderedCRDs = append(renderedCRDs, string(manifest))
 				}
 			}
+
+			// Redact secrets if enabled
+			if !s.opts.DisableRedaction {
+				manifest = Manifest(s.redactor.Redact(string(manifest)))
+			}
+			if !s.opts.DisableNormalize {
+				manifest = Manifest(s.normalizer.Normalize(string(manifest)))
+			}
+
+			// Write manifest to each output root
+			if err := writeManifest(targets, target.Dir, string(manifest)); err != nil {
+				result.recordFailure(source, target.Dir, err)
+				finish(TargetFailed, manifest, err)
+				continue
+			}
+			finish(TargetRendered, manifest, nil)
+			for _, root := range targets {
+				m := newDirMetadata(root, d.renderer.Name(), target.Dir, string(manifest), time.Since(start))
+				m.Destinations = destinations[target.Dir]
+				dirMeta[root.dir] = append(dirMeta[root.dir], m)
+			}
+			if s.opts.PolicyImpactBase != "" {
+				rendered[target.Dir] += string(manifest) + "\n---\n"
+			}
+
+			if source == config.OutputSourceHelm {
+				result.HelmAppsRendered++
+			} else {
+				result.RenderedDirs++
+			}
 		}
-	} else {
-		s.logVerbose("Helm not installed, skipping Helm chart rendering")
 	}
 
-	// 6. Write metadata file
+	result.timePhase("render", phaseStart)
+
+	if len(pendingSchemas) > 0 {
+		phaseStart = time.Now()
+		s.useCRDSchemas(runner, renderedCRDs)
+		for _, p := range pendingSchemas {
+			s.validateSchema(ctx, runner, p.dir, p.manifest, result)
+		}
+		result.timePhase("schema", phaseStart)
+	}
+
+	// Write metadata file into each root
 	meta := Metadata{
 		SourceRepo:  s.opts.SourceRepo,
 		SourceSHA:   s.opts.SourceCommit,
 		PRNumber:    s.opts.PRNumber,
 		Clusters:    s.opts.Clusters,
 		GeneratedAt: time.Now().UTC().Format(time.RFC3339),
+		Acks:        s.acks,
+
+		BudgetSkipped: result.BudgetSkipped,
 	}
 
-	metaPath := filepath.Join(outputDir, "_meta.json")
-	metaJSON, err := json.MarshalIndent(meta, "", "  ")
-	if err != nil {
-		return result, fmt.Errorf("failed to marshal metadata: %w", err)
+	for _, root := range roots {
+		meta.Directories = dirMeta[root.dir]
+		metaJSON, err := json.MarshalIndent(meta, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal metadata: %w", err)
+		}
+		if err := root.write(filepath.Join(root.dir, "_meta.json"), metaJSON); err != nil {
+			return fmt.Errorf("failed to write metadata: %w", err)
+		}
 	}
-	if err := os.WriteFile(metaPath, metaJSON, 0644); err != nil {
-		return result, fmt.Errorf("failed to write metadata: %w", err)
+	if s.opts.Provenance {
+		if err := s.writeProvenance(roots, dirMeta); err != nil {
+			return err
+		}
 	}
 
-	// 7. Commit changes
-	commitMsg := s.buildCommitMessage()
-	changed, sha, err := CommitAll(shadowDir, commitMsg)
-	if err != nil {
-		return result, fmt.Errorf("failed to commit changes: %w", err)
+	if s.opts.PolicyImpactBase != "" {
+		phaseStart = time.Now()
+		if err := s.policyImpact(ctx, roots, rendered, result); err != nil {
+			return err
+		}
+		result.timePhase("policy_impact", phaseStart)
 	}
 
-	if !changed {
-		s.logVerbose("No changes to commit")
-	} else {
-		result.CommitSHA = sha
-		s.logVerbose("Committed changes: %s", sha)
+	// Remove manifests for directories that were not rendered this time
+	for _, root := range roots {
+		pruned, err := root.prune()
+		result.PrunedFiles += pruned
+		if err != nil {
+			return fmt.Errorf("failed to prune output directory: %w", err)
+		}
+	}
+	if result.PrunedFiles > 0 {
+		s.log.Debugf("Pruned %d stale file(s)", result.PrunedFiles)
 	}
 
-	// 8. Push to remote
-	s.logVerbose("Pushing to origin/%s (force=%v)", s.opts.Branch, s.opts.ForcePush)
-	if err := Push(shadowDir, "origin", s.opts.Branch, s.opts.ForcePush); err != nil {
-		return result, fmt.Errorf("failed to push: %w", err)
+	// Verify redaction before anything is committed (defense in depth)
+	if !s.opts.DisableRedaction {
+		var violations []RedactionViolation
+		for _, root := range roots {
+			found, err := s.redactor.VerifyTree(root.dir)
+			if err != nil {
+				return fmt.Errorf("redaction verification failed, refusing to commit: %w", err)
+			}
+			violations = append(violations, found...)
+		}
+		if len(violations) > 0 {
+			for _, v := range violations {
+				s.log.Errorf("unredacted data: %s", v)
+			}
+			return fmt.Errorf("redaction verification failed, refusing to commit: %d resource(s) still contain data", len(violations))
+		}
+		s.log.Debugf("Redaction verified")
+
+		if err := s.scanSecrets(roots, result); err != nil {
+			return err
+		}
 	}
 
-	// 9. Generate compare URL
-	result.CompareURL = CompareURL(s.opts.ShadowRepo, s.opts.BaseBranch, s.opts.Branch)
+	return nil
+}
+
+// policyImpact analyzes the Kyverno policies changed since PolicyImpactBase
+// against the rendered manifests and writes the report into each output root;
+// nothing is written when no policy changed, so a stale report is pruned
+func (s *Syncer) policyImpact(ctx context.Context, roots []outputRoot, rendered map[string]string, result *Result) error {
+	runner := kyverno.NewTestRunner(s.opts.RepoPath, s.opts.Verbose)
+	runner.Log = s.log.Named("kyverno")
+	runner.Roots = s.opts.KyvernoPolicyRoots
+	runner.Clusters = s.opts.Clusters
+	report, err := runner.ImpactContext(ctx, s.opts.PolicyImpactBase, rendered)
+	if err != nil {
+		// The report is advisory; a broken policy must not block publishing manifests
+		s.log.Warnf("policy impact analysis failed: %v", err)
+		return nil
+	}
+	result.PolicyImpact = &report
+	if len(report.Policies) == 0 {
+		s.log.Debugf("No Kyverno policies changed since %s", s.opts.PolicyImpactBase)
+		return nil
+	}
+	for _, root := range roots {
+		if err := root.write(filepath.Join(root.dir, PolicyImpactFile), []byte(report.Markdown())); err != nil {
+			return fmt.Errorf("failed to write policy impact report: %w", err)
+		}
+	}
+	return nil
+}
 
-	// 10. Cleanup merged PR branches if requested
-	if s.opts.CleanupMerged && s.opts.SourceRepo != "" {
-		s.logVerbose("Running cleanup for merged PR branches...")
-		cleanupResult, err := CleanupStaleBranches(shadowDir, s.opts.SourceRepo, false, s.opts.Verbose)
+// scanSecrets scans the output roots for credentials that escaped redaction and,
+// unless AllowSecretFindings is set, refuses to commit when any are found
+func (s *Syncer) scanSecrets(roots []outputRoot, result *Result) error {
+	for _, root := range roots {
+		found, err := ScanSecretsTree(root.dir)
 		if err != nil {
-			// Log but don't fail the sync for cleanup errors
-			s.logVerbose("Warning: cleanup failed: %v", err)
-		} else {
-			result.Cleanup = &cleanupResult
-			if len(cleanupResult.DeletedBranches) > 0 {
-				s.logVerbose("Deleted %d stale branches", len(cleanupResult.DeletedBranches))
+			return fmt.Errorf("secret scan failed, refusing to commit: %w", err)
+		}
+		for _, f := range found {
+			// Paths are relative to the shadow repo, or to OutDir for a dry run
+			if !s.opts.DryRun || !s.defaultOutputs {
+				f.File = path.Join(root.Path, f.File)
 			}
+			result.SecretFindings = append(result.SecretFindings, f)
+		}
+	}
+	if len(result.SecretFindings) == 0 {
+		s.log.Debugf("Secret scan found nothing")
+		return nil
+	}
+
+	for _, f := range result.SecretFindings {
+		if s.opts.AllowSecretFindings {
+			s.log.Warnf("possible secret: %s", f)
+		} else {
+			s.log.Errorf("possible secret: %s", f)
+		}
+	}
+	if s.opts.AllowSecretFindings {
+		return nil
+	}
+	return fmt.Errorf("secret scan found %d possible secret(s), refusing to commit (use --allow-findings to publish anyway)", len(result.SecretFindings))
+}
+
+// recordFailure counts a failed render of dir, as a Helm app for Helm targets
+func (r *Result) recordFailure(source, dir string, err error) {
+	if source == config.OutputSourceHelm {
+		r.HelmAppsFailed++
+	} else {
+		r.FailedDirs++
+	}
+	r.Failures = append(r.Failures, DirFailure{Directory: dir, Error: err.Error()})
+}
+
+// SubstituteVars resolves clusters.yaml vars in a manifest rendered for dir;
+// placeholders the directory's cluster doesn't define are an error
+func SubstituteVars(registry *cluster.Registry, dir string, manifest Manifest) (Manifest, error) {
+	name := ClusterForDirectory(dir)
+	out, unresolved := registry.Substitute(name, string(manifest))
+	if len(unresolved) == 0 {
+		return Manifest(out), nil
+	}
+
+	vars := "${" + strings.Join(unresolved, "}, ${") + "}"
+	if name == "" {
+		return "", fmt.Errorf("unresolved cluster variable(s) %s: %s does not belong to a cluster", vars, dir)
+	}
+	return "", fmt.Errorf("unresolved cluster variable(s) %s: not set in %s vars for %s", vars, cluster.RegistryFile, name)
+}
+
+// gitRetry returns the retry policy for shadow repo network operations
+func (s *Syncer) gitRetry() GitRetry {
+	return GitRetry{Retries: s.opts.GitRetries, Delay: s.opts.GitRetryDelay, Log: s.log}
+}
+
+// keepFailed keeps the previous manifest of a failed directory when KeepFailed is set
+func (s *Syncer) keepFailed(targets []outputRoot, dir string, result *Result) {
+	if !s.opts.KeepFailed {
+		return
+	}
+	if kept := keepManifest(targets, dir); kept > 0 {
+		s.log.Debugf("Keeping previous manifest for failed %s", dir)
+		result.KeptFiles += kept
+	}
+}
+
+// overBudget reports whether the Budget has run out
+func (s *Syncer) overBudget() bool {
+	return !s.deadline.IsZero() && time.Now().After(s.deadline)
+}
+
+// skipOverBudget leaves a directory unrendered once the Budget has run out,
+// keeping its previous manifest so it is not pruned as a deletion
+func (s *Syncer) skipOverBudget(targets []outputRoot, dir string, result *Result) {
+	if !result.BudgetExceeded {
+		s.log.Warnf("Budget of %s exceeded, skipping remaining targets", s.opts.Budget)
+	}
+	result.BudgetExceeded = true
+	result.BudgetSkipped = append(result.BudgetSkipped, dir)
+	result.KeptFiles += keepManifest(targets, dir)
+}
+
+// validateSchema runs kubeconform on a rendered manifest when ValidateSchemas is set
+// Failures are appended to result.Failures under the manifest's directory
+func (s *Syncer) validateSchema(ctx context.Context, runner *kustomize.Runner, dir, manifest string, result *Result) {
+	if !s.opts.ValidateSchemas {
+		return
+	}
+	s.checkAPIVersions(runner, dir, manifest, result)
+
+	output, err := runner.ValidateManifestContext(ctx, manifest)
+	if err == nil {
+		return
+	}
+
+	detail := kustomize.SummarizeSchemaErrors(output)
+	if detail == "" {
+		detail = err.Error()
+	}
+
+	s.log.Debugf("Schema validation failed for %s", dir)
+	result.SchemaFailures++
+	result.Failures = append(result.Failures, DirFailure{
+		Directory: dir,
+		Error:     fmt.Sprintf("schema validation failed: %s", detail),
+	})
+}
+
+// useCRDSchemas points kubeconform at schemas generated from the repo's CRD
+// directories and the rendered CRDs; without them custom resources are skipped
+func (s *Syncer) useCRDSchemas(runner *kustomize.Runner, renderedCRDs []string) {
+	dir := s.opts.CRDSchemaDir
+	if dir == "" {
+		dir = kustomize.DefaultSchemaDir()
+	}
+	schemas, err := kustomize.RepoCRDSchemas(s.opts.RepoPath, dir, renderedCRDs...)
+	if err != nil {
+		s.log.Warnf("Custom resources not schema-validated: %v", err)
+		return
+	}
+	if schemas.Schemas == 0 {
+		return
+	}
+	s.log.Debugf("Validating custom resources against %d CRD schema(s) in %s", schemas.Schemas, schemas.Dir)
+	runner.SchemaLocations = append(runner.SchemaLocations, schemas.Location())
+}
+
+// checkAPIVersions records resources using APIs deprecated in the runner's
+// Kubernetes version. Removed APIs are failures: kubeconform has no schema for
+// them and silently skips them
+func (s *Syncer) checkAPIVersions(runner *kustomize.Runner, dir, manifest string, result *Result) {
+	found, err := kustomize.FindDeprecatedAPIs(manifest, runner.KubernetesVersion)
+	if err != nil {
+		s.log.Debugf("API version check skipped for %s: %v", dir, err)
+		return
+	}
+	for _, api := range found {
+		if api.Removed {
+			result.Failures = append(result.Failures, DirFailure{
+				Directory: dir,
+				Error:     fmt.Sprintf("removed API: %s", api),
+			})
+			continue
+		}
+		result.DeprecatedAPIs = append(result.DeprecatedAPIs, DeprecatedAPIFinding{Directory: dir, DeprecatedAPI: api})
+	}
+}
+
+// runDryRun renders into OutDir and reports the commit sync would make
+// Nothing is cloned, committed, or pushed
+func (s *Syncer) runDryRun(ctx context.Context, found []discovered, result Result) (Result, error) {
+	outputDir, err := filepath.Abs(s.opts.OutDir)
+	if err != nil {
+		return result, fmt.Errorf("failed to resolve output directory: %w", err)
+	}
+	repoPath, err := filepath.Abs(s.opts.RepoPath)
+	if err != nil {
+		return result, fmt.Errorf("failed to resolve repo path: %w", err)
+	}
+	// Stale files in the output directory are pruned, so never let it cover the source repo
+	if filepath.Dir(outputDir) == outputDir {
+		return result, fmt.Errorf("output directory %s is a filesystem root, refusing to prune it", outputDir)
+	}
+	if rel, err := filepath.Rel(outputDir, repoPath); err == nil && !strings.HasPrefix(rel, "..") {
+		return result, fmt.Errorf("output directory %s contains the source repo, refusing to prune it", outputDir)
+	}
+
+	result.DryRun = true
+	result.OutputDir = outputDir
+	result.CommitMessage = s.buildCommitMessage()
+
+	before, err := snapshotTree(outputDir)
+	if err != nil {
+		return result, fmt.Errorf("failed to read output directory: %w", err)
+	}
+
+	roots := s.outputRoots(outputDir)
+	if s.defaultOutputs {
+		// Without configured outputs, OutDir stands in for the single output root
+		roots = []outputRoot{newOutputRoot(s.opts.Outputs[0], outputDir)}
+		roots[0].split = s.opts.OutputLayout == OutputLayoutSplit
+	}
+	for _, root := range roots {
+		if err := root.checkPrunable(); err != nil {
+			return result, err
 		}
 	}
+	if err := s.render(ctx, found, roots, &result); err != nil {
+		return result, err
+	}
+
+	after, err := snapshotTree(outputDir)
+	if err != nil {
+		return result, fmt.Errorf("failed to read output directory: %w", err)
+	}
+	result.Changes = diffTrees(before, after)
+	if err := s.checkGates(&result); err != nil {
+		return result, err
+	}
 
 	return result, nil
 }
 
-// discoverDirectories finds kustomization directories to render
-func (s *Syncer) discoverDirectories() ([]string, error) {
-	return DiscoverKustomizationsForSync(s.opts.RepoPath, s.opts.Clusters)
+// loadAcks reads gate acknowledgments from the source PR when RequireAck is set
+func (s *Syncer) loadAcks(ctx context.Context) error {
+	if !s.opts.RequireAck || s.opts.PRNumber == "" || s.opts.SourceRepo == "" {
+		return nil
+	}
+	if s.source.Slug == "" {
+		return fmt.Errorf("cannot read acknowledgments: cannot parse source repo %q", s.opts.SourceRepo)
+	}
+	if s.source.Provider != ProviderGitHub {
+		return fmt.Errorf("PR acknowledgments are only supported for GitHub source repos (got %s)", s.source.Provider)
+	}
+	acks, err := FetchAcks(ctx, s.source.Slug, s.opts.PRNumber)
+	if err != nil {
+		return fmt.Errorf("failed to read acknowledgments from PR #%s: %w", s.opts.PRNumber, err)
+	}
+	s.acks = acks
+	s.log.Debugf("Read %d acknowledgment(s) from PR #%s", len(acks), s.opts.PRNumber)
+	return nil
+}
+
+// checkGates records gated changes and, with RequireAck, fails on unacknowledged ones
+func (s *Syncer) checkGates(result *Result) error {
+	result.Gates = GatedChanges(result.Changes, s.acks)
+	if !s.opts.RequireAck {
+		return nil
+	}
+	return unacknowledgedError(result.Gates)
+}
+
+// snapshotTree maps every file under root (relative, slash-separated) to its contents
+// A missing root is an empty tree; _meta.json and _provenance.json files are skipped since their timestamps always change
+func snapshotTree(root string) (map[string]string, error) {
+	files := make(map[string]string)
+	if _, err := os.Stat(root); os.IsNotExist(err) {
+		return files, nil
+	}
+
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if d.Name() == "_meta.json" || d.Name() == ProvenanceFile {
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		files[rel] = string(data)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return files, nil
+}
+
+// snapshotRoots snapshots every output root, keyed relative to base
+func snapshotRoots(base string, roots []outputRoot) (map[string]string, error) {
+	files := make(map[string]string)
+	for _, root := range roots {
+		snapshot, err := snapshotTree(root.dir)
+		if err != nil {
+			return nil, err
+		}
+		rel, err := filepath.Rel(base, root.dir)
+		if err != nil {
+			return nil, err
+		}
+		for path, content := range snapshot {
+			files[filepath.ToSlash(filepath.Join(rel, path))] = content
+		}
+	}
+	return files, nil
+}
+
+// diffTrees compares two snapshotTree snapshots and classifies the changed
+// resources in manifest files (files that fail to parse are listed but not classified)
+func diffTrees(before, after map[string]string) *ChangeSummary {
+	changes := &ChangeSummary{Added: []string{}, Modified: []string{}, Removed: []string{}}
+	for path, content := range after {
+		old, ok := before[path]
+		switch {
+		case !ok:
+			changes.Added = append(changes.Added, path)
+		case old != content:
+			changes.Modified = append(changes.Modified, path)
+		}
+	}
+	for path := range before {
+		if _, ok := after[path]; !ok {
+			changes.Removed = append(changes.Removed, path)
+		}
+	}
+	sort.Strings(changes.Added)
+	sort.Strings(changes.Modified)
+	sort.Strings(changes.Removed)
+
+	for _, paths := range [][]string{changes.Added, changes.Modified, changes.Removed} {
+		for _, path := range paths {
+			if !isManifestFile(path) {
+				continue
+			}
+			resources, err := ClassifyManifestChange(path, before[path], after[path])
+			if err != nil {
+				continue
+			}
+			changes.Resources = append(changes.Resources, resources...)
+		}
+	}
+	if len(changes.Resources) > 0 {
+		changes.Categories = CountCategories(changes.Resources)
+	}
+	return changes
 }
 
 // buildCommitMessage creates the commit message with source metadata
@@ -351,18 +1232,36 @@ func (s *Syncer) buildCommitMessage() string {
 	return msg
 }
 
-func (s *Syncer) logVerbose(format string, args ...interface{}) {
-	if s.opts.Verbose {
-		fmt.Fprintf(os.Stderr, "[sync] "+format+"\n", args...)
-	}
+// HelmRenderOptions configures RenderHelmSource
+type HelmRenderOptions struct {
+	RepoPath string // Used to resolve $values/ references
+	CacheDir string // Chart cache directory (empty = disabled)
+	Verbose  bool
+	Log      *log.Logger // default: the shared logger
+
+	// Kustomize builds the post-render Component of Applications annotated
+	// with argocd.AnnotationPostRender (default: kustomize exec in RepoPath)
+	Kustomize *kustomize.Runner
+
+	// Credentials authenticate to private repositories (see HelmCredentials)
+	Credentials *helm.CredentialStore
 }
 
-// renderHelmSource renders a Helm chart source from an ArgoCD Application
-func (s *Syncer) renderHelmSource(app *argocd.Application, source *argocd.Source) helm.TemplateResult {
+// RenderHelmSource renders a Helm chart source from an ArgoCD Application
+// the way ArgoCD does: $values/ resolution, inline values (values or valuesObject),
+// parameters, skipCrds, release naming, and OCI normalization. The output is
+// then piped through the Application's post-render Component, if any
+func RenderHelmSource(app *argocd.Application, source *argocd.Source, opts HelmRenderOptions) helm.TemplateResult {
+	return RenderHelmSourceContext(context.Background(), app, source, opts)
+}
+
+// RenderHelmSourceContext is RenderHelmSource with helm and kustomize killed
+// once ctx is done
+func RenderHelmSourceContext(ctx context.Context, app *argocd.Application, source *argocd.Source, opts HelmRenderOptions) helm.TemplateResult {
 	// Resolve value files from $values/ references
 	var valueFiles []string
 	if source.Helm != nil && len(source.Helm.ValueFiles) > 0 {
-		resolved, err := argocd.ResolveValueFiles(source.Helm.ValueFiles, s.opts.RepoPath)
+		resolved, err := argocd.ResolveValueFiles(source.Helm.ValueFiles, opts.RepoPath)
 		if err != nil {
 			return helm.TemplateResult{
 				Passed: false,
@@ -372,10 +1271,10 @@ func (s *Syncer) renderHelmSource(app *argocd.Application, source *argocd.Source
 		valueFiles = resolved
 	}
 
-	// Get inline values if present
-	var inlineValues string
-	if source.Helm != nil && source.Helm.Values != "" {
-		inlineValues = source.Helm.Values
+	// Inline values: valuesObject, or values
+	inlineValues, err := source.Helm.InlineValues()
+	if err != nil {
+		return helm.TemplateResult{Passed: false, Error: err}
 	}
 
 	// Get release name
@@ -384,38 +1283,53 @@ func (s *Syncer) renderHelmSource(app *argocd.Application, source *argocd.Source
 		releaseName = source.Helm.ReleaseName
 	}
 
-	// Normalize repo URL for helm template --repo flag
-	// Some URLs may need adjustment (e.g., OCI registries)
-	repoURL := source.RepoURL
-
-	// Check if this is an OCI registry URL (explicit or implicit)
-	if IsOCIRegistry(repoURL) {
-		// Normalize to oci:// format for helm template
-		ociURL := NormalizeOCIURL(repoURL)
-		// For OCI registries, we need to use the full chart reference
-		// helm template RELEASE oci://registry/chart --version VERSION
-		return helm.Template(helm.TemplateOptions{
-			ReleaseName:  releaseName,
-			Namespace:    app.Namespace,
-			RepoURL:      "", // OCI doesn't use --repo
-			Chart:        ociURL + "/" + source.Chart,
-			Version:      source.TargetRevision,
-			ValueFiles:   valueFiles,
-			InlineValues: inlineValues,
-			Verbose:      s.opts.Verbose,
-		})
-	}
-
-	return helm.Template(helm.TemplateOptions{
+	templateOpts := helm.TemplateOptions{
 		ReleaseName:  releaseName,
 		Namespace:    app.Namespace,
-		RepoURL:      repoURL,
+		RepoURL:      source.RepoURL,
 		Chart:        source.Chart,
 		Version:      source.TargetRevision,
 		ValueFiles:   valueFiles,
 		InlineValues: inlineValues,
-		Verbose:      s.opts.Verbose,
-	})
+		Credential:   opts.Credentials.Lookup(source.RepoURL),
+		CacheDir:     opts.CacheDir,
+		Verbose:      opts.Verbose,
+		Log:          opts.Log,
+	}
+	if source.Helm != nil {
+		for _, p := range source.Helm.Parameters {
+			templateOpts.Parameters = append(templateOpts.Parameters, helm.Parameter{Name: p.Name, Value: p.Value, ForceString: p.ForceString})
+		}
+		templateOpts.SkipCRDs = source.Helm.SkipCrds
+	}
+
+	// Check if this is an OCI registry URL (explicit or implicit)
+	if IsOCIRegistry(source.RepoURL) {
+		// For OCI registries, we need to use the full chart reference
+		// helm template RELEASE oci://registry/chart --version VERSION
+		templateOpts.RepoURL = "" // OCI doesn't use --repo
+		templateOpts.Chart = NormalizeOCIURL(source.RepoURL) + "/" + source.Chart
+	}
+
+	result := helm.TemplateContext(ctx, templateOpts)
+	if !result.Passed || app.PostRender == "" {
+		return result
+	}
+
+	runner := opts.Kustomize
+	if runner == nil {
+		runner = kustomize.NewRunner(opts.RepoPath, "", opts.Verbose)
+	}
+	build := runner.PostRenderContext(ctx, result.Output, app.PostRender)
+	if !build.Passed {
+		result.Passed = false
+		result.Output = build.Output
+		result.Error = build.Error
+		return result
+	}
+	result.Output = build.Output
+	result.Command += " | kustomize post-render " + app.PostRender
+	return result
 }
 
 // ociRegistryPrefixes lists common OCI registry hostnames that ArgoCD may use
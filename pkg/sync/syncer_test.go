@@ -0,0 +1,498 @@
+package sync
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/erauner/homelab-shadow/pkg/command"
+	"github.com/erauner/homelab-shadow/pkg/config"
+	"github.com/erauner/homelab-shadow/pkg/kustomize"
+)
+
+func TestNew_DryRunRequiresOutDir(t *testing.T) {
+	if _, err := New(Options{RepoPath: ".", DryRun: true}); err == nil {
+		t.Error("expected error when OutDir is missing")
+	}
+	if _, err := New(Options{RepoPath: ".", DryRun: true, OutDir: "out"}); err != nil {
+		t.Errorf("dry run should not require ShadowRepo: %v", err)
+	}
+	if _, err := New(Options{RepoPath: "."}); err == nil {
+		t.Error("expected error when ShadowRepo is missing")
+	}
+}
+
+func TestRun_DryRun(t *testing.T) {
+	repo := t.TempDir()
+	out := filepath.Join(t.TempDir(), "rendered-local")
+
+	stale := filepath.Join(out, "apps", "old", "overlays", "production", "manifest.yaml")
+	if err := os.MkdirAll(filepath.Dir(stale), 0755); err != nil {
+		t.Fatalf("failed to create dir: %v", err)
+	}
+	if err := os.WriteFile(stale, []byte("kind: ConfigMap\n"), 0644); err != nil {
+		t.Fatalf("failed to write stale manifest: %v", err)
+	}
+	previousRun(t, out)
+
+	syncer, err := New(Options{
+		RepoPath:     repo,
+		DryRun:       true,
+		OutDir:       out,
+		SourceRepo:   "erauner/homelab-k8s",
+		SourceCommit: "0123456789abcdef",
+		PRNumber:     "950",
+	})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	result, err := syncer.Run()
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	if !result.DryRun || result.CommitSHA != "" || result.CompareURL != "" {
+		t.Errorf("unexpected dry run result %+v", result)
+	}
+	if result.CommitMessage != "shadow sync: erauner/homelab-k8s@0123456 PR #950" {
+		t.Errorf("unexpected commit message %q", result.CommitMessage)
+	}
+	if _, err := os.Stat(filepath.Join(out, "_meta.json")); err != nil {
+		t.Errorf("expected _meta.json in output: %v", err)
+	}
+	if got := strings.Join(result.Changes.Removed, ","); got != "apps/old/overlays/production/manifest.yaml" {
+		t.Errorf("Removed = %q", got)
+	}
+	if len(result.Changes.Added) != 0 || len(result.Changes.Modified) != 0 {
+		t.Errorf("unexpected changes %+v", result.Changes)
+	}
+}
+
+func TestRun_DryRunRefusesRepoAsOutput(t *testing.T) {
+	repo := t.TempDir()
+	marker := filepath.Join(repo, "keep.txt")
+	if err := os.WriteFile(marker, []byte("keep"), 0644); err != nil {
+		t.Fatalf("failed to write marker: %v", err)
+	}
+
+	for _, out := range []string{repo, filepath.Dir(repo)} {
+		syncer, err := New(Options{RepoPath: repo, DryRun: true, OutDir: out})
+		if err != nil {
+			t.Fatalf("New() error = %v", err)
+		}
+		if _, err := syncer.Run(); err == nil {
+			t.Errorf("expected error for output directory %s", out)
+		}
+	}
+
+	if _, err := os.Stat(marker); err != nil {
+		t.Errorf("source repo was modified: %v", err)
+	}
+}
+
+func TestDiffTrees(t *testing.T) {
+	before := map[string]string{"a": "1", "b": "2", "c": "3"}
+	after := map[string]string{"a": "1", "b": "x", "d": "4"}
+
+	changes := diffTrees(before, after)
+	if strings.Join(changes.Added, ",") != "d" {
+		t.Errorf("Added = %v", changes.Added)
+	}
+	if strings.Join(changes.Modified, ",") != "b" {
+		t.Errorf("Modified = %v", changes.Modified)
+	}
+	if strings.Join(changes.Removed, ",") != "c" {
+		t.Errorf("Removed = %v", changes.Removed)
+	}
+}
+
+// fakeKubeconform puts a kubeconform stub on PATH that rejects any manifest containing "invalid"
+func fakeKubeconform(t *testing.T) {
+	t.Helper()
+	bin := t.TempDir()
+	script := "#!/bin/sh\nfor f; do :; done\nif grep -q invalid \"$f\"; then echo 'ERRO - Deployment bad: missing properties'; exit 1; fi\necho 'Summary: 1 resource found in 1 file - Valid: 1, Invalid: 0, Errors: 0, Skipped: 0'\n"
+	if err := os.WriteFile(filepath.Join(bin, "kubeconform"), []byte(script), 0755); err != nil {
+		t.Fatalf("failed to write kubeconform stub: %v", err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+func TestValidateSchema(t *testing.T) {
+	fakeKubeconform(t)
+
+	syncer, err := New(Options{RepoPath: ".", DryRun: true, OutDir: "out", ValidateSchemas: true})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	runner := kustomize.NewRunner(".", "", false)
+
+	var result Result
+	syncer.validateSchema(context.Background(), runner, "apps/good/overlays/production", "kind: Deployment\n", &result)
+	syncer.validateSchema(context.Background(), runner, "apps/bad/overlays/production", "kind: Deployment # invalid\n", &result)
+
+	if result.SchemaFailures != 1 || len(result.Failures) != 1 {
+		t.Fatalf("expected one schema failure, got %+v", result)
+	}
+	if result.Failures[0].Directory != "apps/bad/overlays/production" {
+		t.Errorf("unexpected directory %q", result.Failures[0].Directory)
+	}
+	if result.Failures[0].Error != "schema validation failed: Deployment bad: missing properties" {
+		t.Errorf("unexpected error %q", result.Failures[0].Error)
+	}
+}
+
+func TestValidateSchema_DeprecatedAPIs(t *testing.T) {
+	fakeKubeconform(t)
+
+	syncer, err := New(Options{RepoPath: ".", DryRun: true, OutDir: "out", ValidateSchemas: true})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	manifest := "apiVersion: batch/v1beta1\nkind: CronJob\nmetadata:\n  name: backup\n---\n" +
+		"apiVersion: flowcontrol.apiserver.k8s.io/v1beta3\nkind: FlowSchema\nmetadata:\n  name: exempt\n"
+
+	var result Result
+	syncer.validateSchema(context.Background(), kustomize.NewRunner(".", "1.30.0", false), "apps/backup/overlays/production", manifest, &result)
+
+	if len(result.Failures) != 1 || !strings.HasPrefix(result.Failures[0].Error, "removed API: CronJob/backup uses batch/v1beta1") {
+		t.Errorf("expected the removed CronJob API as a failure, got %+v", result.Failures)
+	}
+	if len(result.DeprecatedAPIs) != 1 {
+		t.Fatalf("expected one deprecated API, got %+v", result.DeprecatedAPIs)
+	}
+	want := "apps/backup/overlays/production: FlowSchema/exempt uses flowcontrol.apiserver.k8s.io/v1beta3, deprecated in Kubernetes 1.29 and removed in 1.32 (use flowcontrol.apiserver.k8s.io/v1)"
+	if got := result.DeprecatedAPIs[0].String(); got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
+
+func TestUseCRDSchemas(t *testing.T) {
+	fakeKubeconform(t)
+
+	cacheDir := t.TempDir()
+	syncer, err := New(Options{RepoPath: t.TempDir(), DryRun: true, OutDir: "out", ValidateSchemas: true, CRDSchemaDir: cacheDir})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	runner := kustomize.NewRunner(".", "", false)
+
+	// No CRDs in the repo or rendered: kubeconform keeps its defaults
+	syncer.useCRDSchemas(runner, nil)
+	if len(runner.SchemaLocations) != 0 {
+		t.Fatalf("expected no schema locations, got %v", runner.SchemaLocations)
+	}
+
+	crd := "apiVersion: apiextensions.k8s.io/v1\nkind: CustomResourceDefinition\nspec:\n  group: example.com\n  names:\n    kind: Widget\n" +
+		"  versions:\n    - name: v1\n      schema:\n        openAPIV3Schema:\n          type: object\n"
+	syncer.useCRDSchemas(runner, []string{crd})
+	if len(runner.SchemaLocations) != 1 || !strings.HasPrefix(runner.SchemaLocations[0], cacheDir) {
+		t.Fatalf("expected a schema location in %s, got %v", cacheDir, runner.SchemaLocations)
+	}
+}
+
+func TestValidateSchema_Disabled(t *testing.T) {
+	syncer, err := New(Options{RepoPath: ".", DryRun: true, OutDir: "out"})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	var result Result
+	syncer.validateSchema(context.Background(), kustomize.NewRunner(".", "", false), "apps/bad", "invalid", &result)
+	if len(result.Failures) != 0 {
+		t.Errorf("expected no validation without ValidateSchemas, got %+v", result.Failures)
+	}
+}
+
+func TestNew_ValidateSchemasRequiresKubeconform(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+	_, err := New(Options{RepoPath: ".", DryRun: true, OutDir: "out", ValidateSchemas: true})
+	if !errors.Is(err, command.ErrNotInstalled) {
+		t.Errorf("New() error = %v, want command.ErrNotInstalled when kubeconform is not installed", err)
+	}
+}
+
+func TestRun_DryRunRequireAck(t *testing.T) {
+	for _, requireAck := range []bool{false, true} {
+		repo := t.TempDir()
+		out := filepath.Join(t.TempDir(), "rendered-local")
+
+		stale := filepath.Join(out, "infrastructure", "old", "manifest.yaml")
+		if err := os.MkdirAll(filepath.Dir(stale), 0755); err != nil {
+			t.Fatalf("failed to create dir: %v", err)
+		}
+		if err := os.WriteFile(stale, []byte("apiVersion: v1\nkind: Namespace\nmetadata:\n  name: old\n"), 0644); err != nil {
+			t.Fatalf("failed to write stale manifest: %v", err)
+		}
+		previousRun(t, out)
+
+		syncer, err := New(Options{RepoPath: repo, DryRun: true, OutDir: out, RequireAck: requireAck})
+		if err != nil {
+			t.Fatalf("New() error = %v", err)
+		}
+
+		result, err := syncer.Run()
+		if requireAck {
+			if err == nil || !strings.Contains(err.Error(), "namespace-deletion (Namespace/old)") {
+				t.Errorf("Run() error = %v, want unacknowledged namespace-deletion", err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("Run() error = %v", err)
+		}
+		if len(result.Gates) != 1 || result.Gates[0].Gate != GateNamespaceDeletion || result.Gates[0].Acknowledged {
+			t.Errorf("Gates = %+v", result.Gates)
+		}
+	}
+}
+
+func TestRun_DryRunSubstitutesClusterVars(t *testing.T) {
+	repo := t.TempDir()
+	registry := "clusters:\n  - name: erauner-home\n    vars:\n      CLUSTER_DOMAIN: home.erauner.dev\n  - erauner-cloud\n"
+	if err := os.WriteFile(filepath.Join(repo, "clusters.yaml"), []byte(registry), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ingress := "kind: Ingress\nspec:\n  rules:\n    - host: web.${CLUSTER_DOMAIN}\n"
+	out := t.TempDir()
+	syncer, err := New(Options{
+		RepoPath: repo,
+		DryRun:   true,
+		OutDir:   out,
+		Renderers: []Renderer{&fakeRenderer{
+			source: config.OutputSourceKustomize,
+			manifests: map[string]string{
+				"apps/web/overlays/erauner-home/production":  ingress,
+				"apps/web/overlays/erauner-cloud/production": ingress,
+			},
+		}},
+	})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	result, err := syncer.Run()
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if result.RenderedDirs != 1 || result.FailedDirs != 1 {
+		t.Fatalf("dirs rendered/failed = %d/%d, want 1/1", result.RenderedDirs, result.FailedDirs)
+	}
+	if f := result.Failures[0]; f.Directory != "apps/web/overlays/erauner-cloud/production" || !strings.Contains(f.Error, "${CLUSTER_DOMAIN}") {
+		t.Errorf("unexpected failure: %+v", f)
+	}
+
+	data, err := os.ReadFile(filepath.Join(out, "apps/web/overlays/erauner-home/production/manifest.yaml"))
+	if err != nil {
+		t.Fatalf("expected rendered manifest: %v", err)
+	}
+	if !strings.Contains(string(data), "host: web.home.erauner.dev") {
+		t.Errorf("CLUSTER_DOMAIN not substituted:\n%s", data)
+	}
+}
+
+func TestRun_TargetBase(t *testing.T) {
+	remote := newShadowRemote(t, "pr-1")
+	// Clone checks out the remote's default branch, as a hosted shadow repo would have
+	if out, err := exec.Command("git", "-C", remote, "symbolic-ref", "HEAD", "refs/heads/main").CombinedOutput(); err != nil {
+		t.Fatalf("git symbolic-ref: %v\n%s", err, out)
+	}
+	for _, key := range []string{"GIT_AUTHOR_NAME", "GIT_COMMITTER_NAME"} {
+		t.Setenv(key, "test")
+	}
+	for _, key := range []string{"GIT_AUTHOR_EMAIL", "GIT_COMMITTER_EMAIL"} {
+		t.Setenv(key, "test@example.com")
+	}
+
+	syncer, err := New(Options{
+		RepoPath:   t.TempDir(),
+		ShadowRepo: "file://" + remote,
+		SyncTarget: SyncTargetBase,
+		PRNumber:   "7", // ignored for branch naming
+		Renderers: []Renderer{&fakeRenderer{
+			source:    config.OutputSourceKustomize,
+			manifests: map[string]string{"apps/web/overlays/erauner-home/production": "kind: ConfigMap\nmetadata:\n  name: web\n"},
+		}},
+	})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	result, err := syncer.Run()
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if result.Branch != "main" || result.CompareURL != "" || result.CommitSHA == "" {
+		t.Errorf("Branch = %q, CompareURL = %q, CommitSHA = %q; want a commit on main and no compare URL", result.Branch, result.CompareURL, result.CommitSHA)
+	}
+
+	out, err := exec.Command("git", "-C", remote, "show", "main:rendered/apps/web/overlays/erauner-home/production/manifest.yaml").CombinedOutput()
+	if err != nil || !strings.Contains(string(out), "name: web") {
+		t.Errorf("expected manifest on main: %v\n%s", err, out)
+	}
+	if got := remoteBranches(t, remote); !reflect.DeepEqual(got, []string{"main", "pr-1"}) {
+		t.Errorf("remote branches = %v, want [main pr-1]", got)
+	}
+}
+
+func TestNew_TargetBase(t *testing.T) {
+	base := Options{RepoPath: ".", DryRun: true, OutDir: t.TempDir(), SyncTarget: SyncTargetBase}
+
+	opts := base
+	opts.Branch = "pr-1"
+	if _, err := New(opts); err == nil {
+		t.Error("expected error for a branch other than the base branch")
+	}
+	opts = base
+	opts.RequireAck = true
+	if _, err := New(opts); err == nil {
+		t.Error("expected error for RequireAck with target base")
+	}
+	opts = base
+	opts.SyncTarget = "main"
+	if _, err := New(opts); err == nil {
+		t.Error("expected error for unknown sync target")
+	}
+}
+
+// slowRenderer renders its targets in order, taking delay for each
+type slowRenderer struct {
+	dirs  []string
+	delay time.Duration
+}
+
+func (r *slowRenderer) Name() string   { return "slow" }
+func (r *slowRenderer) Source() string { return config.OutputSourceKustomize }
+
+func (r *slowRenderer) Discover() ([]Target, error) {
+	var targets []Target
+	for _, dir := range r.dirs {
+		targets = append(targets, Target{Dir: dir})
+	}
+	return targets, nil
+}
+
+func (r *slowRenderer) Render(ctx context.Context, target Target) (Manifest, Meta, error) {
+	time.Sleep(r.delay)
+	return Manifest("kind: ConfigMap\nmetadata:\n  name: rendered\n"), Meta{}, nil
+}
+
+func TestRun_DryRunBudget(t *testing.T) {
+	out := t.TempDir()
+	previous := filepath.Join(out, "apps", "b", "overlays", "production", "manifest.yaml")
+	if err := os.MkdirAll(filepath.Dir(previous), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(previous, []byte("kind: ConfigMap\nmetadata:\n  name: previous\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	previousRun(t, out)
+
+	syncer, err := New(Options{
+		RepoPath:  t.TempDir(),
+		DryRun:    true,
+		OutDir:    out,
+		Budget:    100 * time.Millisecond,
+		Renderers: []Renderer{&slowRenderer{dirs: []string{"apps/a/overlays/production", "apps/b/overlays/production"}, delay: 150 * time.Millisecond}},
+	})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	result, err := syncer.Run()
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	// The in-flight target finishes; the next one keeps its previous manifest
+	if !result.BudgetExceeded || result.RenderedDirs != 1 || result.KeptFiles != 1 {
+		t.Errorf("unexpected result %+v", result)
+	}
+	if !reflect.DeepEqual(result.BudgetSkipped, []string{"apps/b/overlays/production"}) {
+		t.Errorf("BudgetSkipped = %v", result.BudgetSkipped)
+	}
+	if data, err := os.ReadFile(previous); err != nil || !strings.Contains(string(data), "name: previous") {
+		t.Errorf("expected the skipped directory's previous manifest to be kept: %v\n%s", err, data)
+	}
+
+	var meta Metadata
+	data, err := os.ReadFile(filepath.Join(out, "_meta.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, &meta); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(meta.BudgetSkipped, result.BudgetSkipped) {
+		t.Errorf("_meta.json budget_skipped = %v", meta.BudgetSkipped)
+	}
+
+	if _, err := New(Options{RepoPath: t.TempDir(), DryRun: true, OutDir: out, Budget: -time.Second}); err == nil {
+		t.Error("expected a negative budget to be rejected")
+	}
+}
+
+func TestRun_DryRunPolicyImpact(t *testing.T) {
+	for _, key := range []string{"GIT_AUTHOR_NAME", "GIT_COMMITTER_NAME"} {
+		t.Setenv(key, "test")
+	}
+	for _, key := range []string{"GIT_AUTHOR_EMAIL", "GIT_COMMITTER_EMAIL"} {
+		t.Setenv(key, "test@example.com")
+	}
+	// Policies mentioning "strict" fail every Deployment named web
+	bin := t.TempDir()
+	script := "#!/bin/sh\necho 'apiVersion: wgpolicyk8s.io/v1alpha2'\necho 'kind: ClusterPolicyReport'\necho 'results:'\n" +
+		"if grep -q strict \"$2\"; then printf -- '- policy: require-labels\\n  rule: team\\n  result: fail\\n  resources:\\n  - kind: Deployment\\n    name: web\\n'; fi\n"
+	if err := os.WriteFile(filepath.Join(bin, "kyverno"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	repo := t.TempDir()
+	policy := filepath.Join(repo, "policies", "kyverno", "base", "cluster", "require-labels.yaml")
+	if err := os.MkdirAll(filepath.Dir(policy), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(policy, []byte("kind: ClusterPolicy\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, args := range [][]string{{"init", "-q"}, {"add", "-A"}, {"commit", "-q", "-m", "policy"}} {
+		if out, err := exec.Command("git", append([]string{"-C", repo}, args...)...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	if err := os.WriteFile(policy, []byte("kind: ClusterPolicy\n# strict\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	out := t.TempDir()
+	syncer, err := New(Options{
+		RepoPath:         repo,
+		DryRun:           true,
+		OutDir:           out,
+		PolicyImpactBase: "HEAD",
+		Renderers: []Renderer{&fakeRenderer{source: "kustomize", manifests: map[string]string{
+			"apps/web/overlays/production": "apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: web\n",
+		}}},
+	})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	result, err := syncer.Run()
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if result.PolicyImpact == nil || result.PolicyImpact.NewViolations() != 1 {
+		t.Fatalf("PolicyImpact = %+v", result.PolicyImpact)
+	}
+	data, err := os.ReadFile(filepath.Join(out, PolicyImpactFile))
+	if err != nil || !strings.Contains(string(data), "Deployment/web | apps/web/overlays/production") {
+		t.Errorf("expected the report in the output root: %v\n%s", err, data)
+	}
+}
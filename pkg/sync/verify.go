@@ -0,0 +1,212 @@
+package sync
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// RedactionViolation is a resource that still carries data after redaction
+type RedactionViolation struct {
+	File   string   // file the resource was found in (relative to the verified root)
+	Kind   string   // kind of the resource, e.g. Secret
+	Secret string   // namespace/name of the resource
+	Fields []string // field paths that still hold values
+}
+
+func (v RedactionViolation) String() string {
+	kind := v.Kind
+	if kind == "" {
+		kind = "Secret"
+	}
+	return fmt.Sprintf("%s: %s %s has unredacted %s", v.File, kind, v.Secret, strings.Join(v.Fields, ", "))
+}
+
+// VerifyRedacted checks that no Secret in a manifest still holds data.
+// It is the Verify of a Redactor without a policy.
+func VerifyRedacted(manifest string) ([]RedactionViolation, error) {
+	return defaultRedactor.Verify(manifest)
+}
+
+// VerifyRedactedTree runs VerifyRedacted over every YAML file under root
+func VerifyRedactedTree(root string) ([]RedactionViolation, error) {
+	return defaultRedactor.VerifyTree(root)
+}
+
+// Verify checks that no Secret, and no field of the policy, still holds data.
+//
+// This is deliberately independent of Redact: documents are decoded into
+// plain values (resolving aliases and merge keys) and searched for resources
+// at any depth, so a regression in the redactor cannot also hide itself from
+// the check. A manifest that cannot be parsed cannot be verified and is
+// reported as an error.
+func (r *Redactor) Verify(manifest string) ([]RedactionViolation, error) {
+	dec := yaml.NewDecoder(strings.NewReader(manifest))
+
+	var violations []RedactionViolation
+	for {
+		var doc interface{}
+		err := dec.Decode(&doc)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("cannot verify redaction: %w", err)
+		}
+		r.findUnredacted(doc, &violations)
+	}
+
+	return violations, nil
+}
+
+// VerifyTree runs Verify over every YAML file under root
+func (r *Redactor) VerifyTree(root string) ([]RedactionViolation, error) {
+	var violations []RedactionViolation
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || (!strings.HasSuffix(path, ".yaml") && !strings.HasSuffix(path, ".yml")) {
+			return nil
+		}
+
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			rel = path
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", rel, err)
+		}
+		found, err := r.Verify(string(data))
+		if err != nil {
+			return fmt.Errorf("%s: %w", rel, err)
+		}
+		for _, v := range found {
+			v.File = rel
+			violations = append(violations, v)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return violations, nil
+}
+
+// findUnredacted walks a decoded document collecting resources with data
+func (r *Redactor) findUnredacted(node interface{}, violations *[]RedactionViolation) {
+	switch v := node.(type) {
+	case map[string]interface{}:
+		if kind, _ := v["kind"].(string); kind != "" && !r.allowedValue(v) {
+			apiVersion, _ := v["apiVersion"].(string)
+			seen := make(map[string]bool)
+			var fields []string
+			for _, rule := range r.rules {
+				if (rule.kind != "*" && rule.kind != kind) || (rule.apiVersion != "" && rule.apiVersion != apiVersion) {
+					continue
+				}
+				for _, path := range rule.fields {
+					lookupField(v, path, "", func(field string, value interface{}) {
+						if hasValue(value) && !seen[field] {
+							seen[field] = true
+							fields = append(fields, field)
+						}
+					})
+				}
+			}
+			if len(fields) > 0 {
+				sort.Strings(fields)
+				*violations = append(*violations, RedactionViolation{Kind: kind, Secret: secretName(v), Fields: fields})
+			}
+		}
+		for _, child := range v {
+			r.findUnredacted(child, violations)
+		}
+	case []interface{}:
+		for _, child := range v {
+			r.findUnredacted(child, violations)
+		}
+	}
+}
+
+// lookupField calls fn with the dotted path and value of every field a path
+// selects in a decoded value; "*" matches any key or list item
+func lookupField(node interface{}, path []string, prefix string, fn func(field string, value interface{})) {
+	visit := func(key string, value interface{}) {
+		field := key
+		if prefix != "" {
+			field = prefix + "." + key
+		}
+		if len(path) == 1 {
+			fn(field, value)
+		} else {
+			lookupField(value, path[1:], field, fn)
+		}
+	}
+
+	switch v := node.(type) {
+	case map[string]interface{}:
+		if path[0] != "*" {
+			if value, ok := v[path[0]]; ok {
+				visit(path[0], value)
+			}
+			return
+		}
+		for key, value := range v {
+			visit(key, value)
+		}
+	case []interface{}:
+		if path[0] != "*" {
+			return
+		}
+		for i, value := range v {
+			visit(fmt.Sprint(i), value)
+		}
+	}
+}
+
+// allowedValue reports whether the policy allows a decoded resource to be published as-is
+func (r *Redactor) allowedValue(resource map[string]interface{}) bool {
+	metadata, _ := resource["metadata"].(map[string]interface{})
+	name, _ := metadata["name"].(string)
+	namespace, _ := metadata["namespace"].(string)
+	return len(r.policy.Allow) > 0 && r.policy.AllowsResource(namespace, name)
+}
+
+// hasValue reports whether a decoded field carries anything beyond null or empty
+func hasValue(v interface{}) bool {
+	switch value := v.(type) {
+	case nil:
+		return false
+	case map[string]interface{}:
+		return len(value) > 0
+	case []interface{}:
+		return len(value) > 0
+	default:
+		return true
+	}
+}
+
+// secretName returns namespace/name for a decoded Secret
+func secretName(secret map[string]interface{}) string {
+	metadata, _ := secret["metadata"].(map[string]interface{})
+	name, _ := metadata["name"].(string)
+	namespace, _ := metadata["namespace"].(string)
+	if name == "" {
+		name = "<unnamed>"
+	}
+	if namespace == "" {
+		return name
+	}
+	return namespace + "/" + name
+}
@@ -0,0 +1,197 @@
+package sync
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/erauner/homelab-shadow/pkg/config"
+)
+
+func TestVerifyRedacted(t *testing.T) {
+	tests := []struct {
+		name       string
+		manifest   string
+		wantSecret string // empty means no violation expected
+		wantFields string
+	}{
+		{
+			name:     "redacted secret passes",
+			manifest: "apiVersion: v1\nkind: Secret\nmetadata:\n  name: s\ndata:\n  # REDACTED\n",
+		},
+		{
+			name:     "empty data passes",
+			manifest: "kind: Secret\nmetadata:\n  name: s\ndata: {}\n",
+		},
+		{
+			name:     "configmap data passes",
+			manifest: "kind: ConfigMap\nmetadata:\n  name: c\ndata:\n  key: value\n",
+		},
+		{
+			name:       "unredacted data fails",
+			manifest:   "kind: Secret\nmetadata:\n  name: s\n  namespace: apps\ndata:\n  password: c2VjcmV0\n",
+			wantSecret: "apps/s",
+			wantFields: "data",
+		},
+		{
+			name:       "stringData and binaryData fail",
+			manifest:   "kind: Secret\nmetadata:\n  name: s\nstringData:\n  a: b\nbinaryData:\n  c: ZA==\n",
+			wantSecret: "s",
+			wantFields: "binaryData, stringData",
+		},
+		{
+			name:       "secret in later document fails",
+			manifest:   "kind: ConfigMap\nmetadata:\n  name: c\n---\nkind: \"Secret\"\nmetadata:\n  name: s\ndata: {a: b}\n",
+			wantSecret: "s",
+			wantFields: "data",
+		},
+		{
+			name:       "secret nested in list fails",
+			manifest:   "kind: List\nitems:\n- kind: Secret\n  metadata:\n    name: nested\n  data:\n    a: b\n",
+			wantSecret: "nested",
+			wantFields: "data",
+		},
+		{
+			name:       "data pulled in by merge key fails",
+			manifest:   "defaults: &d\n  data:\n    a: b\nkind: Secret\nmetadata:\n  name: merged\n<<: *d\n",
+			wantSecret: "merged",
+			wantFields: "data",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			violations, err := VerifyRedacted(tt.manifest)
+			if err != nil {
+				t.Fatalf("VerifyRedacted() error = %v", err)
+			}
+
+			if tt.wantSecret == "" {
+				if len(violations) != 0 {
+					t.Errorf("expected no violations, got %v", violations)
+				}
+				return
+			}
+
+			if len(violations) != 1 {
+				t.Fatalf("expected 1 violation, got %v", violations)
+			}
+			if violations[0].Secret != tt.wantSecret {
+				t.Errorf("Secret = %q, want %q", violations[0].Secret, tt.wantSecret)
+			}
+			if got := strings.Join(violations[0].Fields, ", "); got != tt.wantFields {
+				t.Errorf("Fields = %q, want %q", got, tt.wantFields)
+			}
+		})
+	}
+}
+
+func TestVerifyRedacted_Unparseable(t *testing.T) {
+	if _, err := VerifyRedacted("kind: [Secret"); err == nil {
+		t.Error("expected error for unparseable manifest")
+	}
+}
+
+func TestVerifyRedacted_AcceptsRedactSecretsOutput(t *testing.T) {
+	for _, seed := range redactSeeds {
+		if _, err := decodeYAMLDocuments(seed); err != nil {
+			continue
+		}
+		violations, err := VerifyRedacted(RedactSecrets(seed))
+		if err != nil {
+			t.Errorf("VerifyRedacted(RedactSecrets(%q)) error = %v", seed, err)
+		}
+		if len(violations) > 0 {
+			t.Errorf("RedactSecrets(%q) left %v", seed, violations)
+		}
+	}
+}
+
+func TestVerifyRedactedTree(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		"apps/coder/overlays/erauner-home/production/manifest.yaml": "kind: Secret\nmetadata:\n  name: ok\ndata:\n  # REDACTED\n",
+		"apps/krr/helm/manifest.yaml":                               "kind: Secret\nmetadata:\n  name: leaked\ndata:\n  token: dG9rZW4=\n",
+		"_meta.json":                                                `{"kind": "Secret", "data": {"ignored": "json files are not manifests"}}`,
+	}
+	for path, content := range files {
+		full := filepath.Join(root, path)
+		if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+			t.Fatalf("failed to create dir: %v", err)
+		}
+		if err := os.WriteFile(full, []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write %s: %v", path, err)
+		}
+	}
+
+	violations, err := VerifyRedactedTree(root)
+	if err != nil {
+		t.Fatalf("VerifyRedactedTree() error = %v", err)
+	}
+	if len(violations) != 1 {
+		t.Fatalf("expected 1 violation, got %v", violations)
+	}
+	if violations[0].File != filepath.Join("apps", "krr", "helm", "manifest.yaml") {
+		t.Errorf("unexpected file %q", violations[0].File)
+	}
+	if !strings.Contains(violations[0].String(), "Secret leaked has unredacted data") {
+		t.Errorf("unexpected message %q", violations[0].String())
+	}
+}
+
+func TestRedactor_VerifyPolicy(t *testing.T) {
+	r := NewRedactor(config.Redaction{
+		Kinds: []config.RedactKind{
+			{Kind: "SealedSecret", Fields: []string{"spec.encryptedData"}},
+			{Kind: "*", Fields: []string{"spec.*.*.token"}},
+		},
+		Allow: []string{"public-*"},
+	})
+
+	manifest := `apiVersion: bitnami.com/v1alpha1
+kind: SealedSecret
+metadata:
+  name: db
+  namespace: apps
+spec:
+  encryptedData:
+    password: AgBy3i4OJSWK
+---
+apiVersion: example.com/v1
+kind: Webhook
+metadata:
+  name: hook
+spec:
+  endpoints:
+    - url: https://example.com
+      token: abc123
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: public-ca
+data:
+  ca.crt: Y2VydA==
+`
+	violations, err := r.Verify(manifest)
+	if err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	if len(violations) != 2 {
+		t.Fatalf("expected 2 violations, got %v", violations)
+	}
+	if v := violations[0]; v.Kind != "SealedSecret" || v.Secret != "apps/db" || strings.Join(v.Fields, ",") != "spec.encryptedData" {
+		t.Errorf("unexpected violation: %+v", v)
+	}
+	if v := violations[1]; v.Kind != "Webhook" || strings.Join(v.Fields, ",") != "spec.endpoints.0.token" {
+		t.Errorf("unexpected violation: %+v", v)
+	}
+	if got := violations[0].String(); !strings.Contains(got, "SealedSecret apps/db has unredacted spec.encryptedData") {
+		t.Errorf("String() = %q", got)
+	}
+
+	if violations, err := r.Verify(r.Redact(manifest)); err != nil || len(violations) > 0 {
+		t.Errorf("Verify(Redact()) = %v, %v", violations, err)
+	}
+}
@@ -0,0 +1,215 @@
+// Package table renders aligned text tables for terminal and CI log output
+//
+// Unlike text/tabwriter, columns are aligned by display width, so emoji and
+// East Asian text line up, and one column (by default the last) wraps to fit
+// a maximum line width instead of pushing every row off screen.
+package table
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// DefaultWidth is the line width used when output isn't a terminal (e.g. CI
+// logs) and COLUMNS is unset
+const DefaultWidth = 120
+
+// minWrapWidth keeps the wrapped column readable when the other columns
+// already fill the line; the table overflows instead of wrapping narrower
+const minWrapWidth = 20
+
+// gap separates columns
+const gap = "  "
+
+// Table is a text table with an optional header row
+type Table struct {
+	// Width is the maximum line width; 0 never wraps
+	Width int
+
+	// Wrap is the index of the column that wraps when a row is wider than
+	// Width (default: the last column)
+	Wrap int
+
+	header []string
+	rows   [][]string
+}
+
+// New creates a table; a non-empty header is printed underlined with dashes
+func New(header ...string) *Table {
+	return &Table{header: header, Wrap: len(header) - 1}
+}
+
+// Row appends a row; missing trailing cells are empty
+func (t *Table) Row(cells ...string) {
+	t.rows = append(t.rows, cells)
+}
+
+// Rowf appends a row from a tab-separated format, like a tabwriter line
+func (t *Table) Rowf(format string, args ...interface{}) {
+	t.Row(strings.Split(fmt.Sprintf(format, args...), "\t")...)
+}
+
+// Len returns the number of rows, excluding the header
+func (t *Table) Len() int {
+	return len(t.rows)
+}
+
+// Render writes the table to w
+func (t *Table) Render(w io.Writer) error {
+	rows := t.rows
+	if len(t.header) > 0 {
+		underline := make([]string, len(t.header))
+		for i, h := range t.header {
+			underline[i] = strings.Repeat("-", StringWidth(h))
+		}
+		rows = append([][]string{t.header, underline}, rows...)
+	}
+
+	columns := 0
+	for _, row := range rows {
+		columns = max(columns, len(row))
+	}
+	if columns == 0 {
+		return nil
+	}
+
+	widths := make([]int, columns)
+	for _, row := range rows {
+		for i, cell := range row {
+			widths[i] = max(widths[i], StringWidth(cell))
+		}
+	}
+
+	wrap := t.Wrap
+	if wrap < 0 || wrap >= columns {
+		wrap = columns - 1
+	}
+	if t.Width > 0 {
+		total := len(gap) * (columns - 1)
+		for _, width := range widths {
+			total += width
+		}
+		if total > t.Width {
+			widths[wrap] = max(widths[wrap]-(total-t.Width), minWrapWidth)
+		}
+	}
+
+	var b strings.Builder
+	for _, row := range rows {
+		lines := make([][]string, columns)
+		height := 1
+		for i := range lines {
+			cell := ""
+			if i < len(row) {
+				cell = row[i]
+			}
+			if i == wrap && t.Width > 0 {
+				lines[i] = WrapText(cell, widths[i])
+			} else {
+				lines[i] = []string{cell}
+			}
+			height = max(height, len(lines[i]))
+		}
+
+		for l := 0; l < height; l++ {
+			var line strings.Builder
+			for i := range lines {
+				cell := ""
+				if l < len(lines[i]) {
+					cell = lines[i][l]
+				}
+				if i > 0 {
+					line.WriteString(gap)
+				}
+				line.WriteString(cell)
+				if i < columns-1 {
+					line.WriteString(strings.Repeat(" ", max(widths[i]-StringWidth(cell), 0)))
+				}
+			}
+			b.WriteString(strings.TrimRight(line.String(), " "))
+			b.WriteByte('\n')
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// WrapText breaks s into lines of at most width display columns, at spaces
+// where possible; words longer than width are split
+func WrapText(s string, width int) []string {
+	if width <= 0 || StringWidth(s) <= width {
+		return []string{s}
+	}
+
+	var lines []string
+	var line strings.Builder
+	lineWidth := 0
+	flush := func() {
+		lines = append(lines, line.String())
+		line.Reset()
+		lineWidth = 0
+	}
+
+	for _, word := range strings.Fields(s) {
+		wordWidth := StringWidth(word)
+		if lineWidth > 0 && lineWidth+1+wordWidth > width {
+			flush()
+		}
+		if lineWidth > 0 {
+			line.WriteByte(' ')
+			lineWidth++
+		}
+		for wordWidth > width-lineWidth {
+			// Split an overlong word at the line boundary
+			head, rest := splitAtWidth(word, width-lineWidth)
+			if head == "" {
+				if lineWidth > 0 {
+					flush()
+					continue
+				}
+				// Not even one rune fits; overflow rather than loop
+				_, size := utf8.DecodeRuneInString(word)
+				head, rest = word[:size], word[size:]
+			}
+			line.WriteString(head)
+			flush()
+			word, wordWidth = rest, StringWidth(rest)
+		}
+		line.WriteString(word)
+		lineWidth += wordWidth
+	}
+	if lineWidth > 0 || len(lines) == 0 {
+		flush()
+	}
+	return lines
+}
+
+// splitAtWidth splits s after at most width display columns
+func splitAtWidth(s string, width int) (string, string) {
+	used := 0
+	for i, r := range s {
+		w := runeWidth(r)
+		if used+w > width {
+			return s[:i], s[i:]
+		}
+		used += w
+	}
+	return s, ""
+}
+
+// TerminalWidth returns the width tables should fit: $COLUMNS when set, else
+// the width of the terminal on stdout, else DefaultWidth
+func TerminalWidth() int {
+	if n, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && n > 0 {
+		return n
+	}
+	if n := terminalWidth(os.Stdout.Fd()); n > 0 {
+		return n
+	}
+	return DefaultWidth
+}
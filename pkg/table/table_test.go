@@ -0,0 +1,151 @@
+package table
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestStringWidth(t *testing.T) {
+	tests := []struct {
+		s    string
+		want int
+	}{
+		{"", 0},
+		{"error", 5},
+		{"✅", 2},
+		{"❌ failed", 9},
+		{"⚠", 1},
+		{"⚠️", 2},
+		{"⏭️", 2},
+		{"✓", 1},
+		{"日本語", 6},
+		{"👩‍💻", 2},
+		{"é", 1},
+	}
+	for _, tt := range tests {
+		if got := StringWidth(tt.s); got != tt.want {
+			t.Errorf("StringWidth(%q) = %d, want %d", tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestRender_AlignsWideCharacters(t *testing.T) {
+	tbl := New("SEVERITY", "RULE", "MESSAGE")
+	tbl.Row("❌ ERROR", "a", "first")
+	tbl.Row("⚠️ WARN", "longer-rule", "second")
+	tbl.Row("日本", "b", "third")
+
+	var b strings.Builder
+	if err := tbl.Render(&b); err != nil {
+		t.Fatal(err)
+	}
+	want := `SEVERITY  RULE         MESSAGE
+--------  ----         -------
+❌ ERROR  a            first
+⚠️ WARN   longer-rule  second
+日本      b            third
+`
+	if b.String() != want {
+		t.Errorf("Render() =\n%s\nwant\n%s", b.String(), want)
+	}
+}
+
+func TestRender_WrapsLastColumn(t *testing.T) {
+	tbl := New("RULE", "MESSAGE")
+	tbl.Width = 44
+	tbl.Row("kustomize-build-fail", "accumulating resources from ../base failed")
+	tbl.Row("ok", "short")
+
+	var b strings.Builder
+	if err := tbl.Render(&b); err != nil {
+		t.Fatal(err)
+	}
+	want := `RULE                  MESSAGE
+----                  -------
+kustomize-build-fail  accumulating resources
+                      from ../base failed
+ok                    short
+`
+	if b.String() != want {
+		t.Errorf("Render() =\n%s\nwant\n%s", b.String(), want)
+	}
+	for _, line := range strings.Split(strings.TrimSpace(b.String()), "\n") {
+		if StringWidth(line) > 44 {
+			t.Errorf("line wider than 44 columns: %q", line)
+		}
+	}
+
+	// Width 0 (--wide) keeps every message on one line
+	tbl.Width = 0
+	b.Reset()
+	if err := tbl.Render(&b); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(b.String(), "accumulating resources from ../base failed") {
+		t.Errorf("expected the full message without wrapping:\n%s", b.String())
+	}
+}
+
+func TestRender_WrapColumn(t *testing.T) {
+	tbl := New("PATH", "STATUS")
+	tbl.Width = 26
+	tbl.Wrap = 0
+	tbl.Row("apps/very/long/path/to/an/overlay", "ok")
+
+	var b strings.Builder
+	if err := tbl.Render(&b); err != nil {
+		t.Fatal(err)
+	}
+	want := `PATH                  STATUS
+----                  ------
+apps/very/long/path/  ok
+to/an/overlay
+`
+	if b.String() != want {
+		t.Errorf("Render() =\n%s\nwant\n%s", b.String(), want)
+	}
+}
+
+func TestRender_NoHeader(t *testing.T) {
+	tbl := New()
+	tbl.Rowf("Path:\t%s", "apps/coder")
+	tbl.Rowf("Kustomization:\t%s", "yes")
+
+	var b strings.Builder
+	if err := tbl.Render(&b); err != nil {
+		t.Fatal(err)
+	}
+	want := "Path:           apps/coder\nKustomization:  yes\n"
+	if b.String() != want {
+		t.Errorf("Render() = %q, want %q", b.String(), want)
+	}
+}
+
+func TestWrapText(t *testing.T) {
+	tests := []struct {
+		s     string
+		width int
+		want  []string
+	}{
+		{"short", 10, []string{"short"}},
+		{"one two three", 7, []string{"one two", "three"}},
+		{"abcdefghij", 4, []string{"abcd", "efgh", "ij"}},
+		{"ok abcdefghij", 5, []string{"ok", "abcde", "fghij"}},
+		{"日本語テキスト", 5, []string{"日本", "語テ", "キス", "ト"}},
+		{"日本", 1, []string{"日", "本"}},
+		{"", 5, []string{""}},
+	}
+	for _, tt := range tests {
+		if got := WrapText(tt.s, tt.width); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("WrapText(%q, %d) = %q, want %q", tt.s, tt.width, got, tt.want)
+		}
+	}
+}
+
+func TestTerminalWidth_Columns(t *testing.T) {
+	t.Setenv("COLUMNS", "87")
+	if got := TerminalWidth(); got != 87 {
+		t.Errorf("TerminalWidth() = %d, want 87", got)
+	}
+}
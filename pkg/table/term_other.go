@@ -0,0 +1,8 @@
+//go:build !linux && !darwin
+
+package table
+
+// terminalWidth is unknown on this platform; tables use COLUMNS or DefaultWidth
+func terminalWidth(fd uintptr) int {
+	return 0
+}
@@ -0,0 +1,21 @@
+//go:build linux || darwin
+
+package table
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// terminalWidth returns the column count of the terminal on fd, or 0 when fd
+// isn't a terminal
+func terminalWidth(fd uintptr) int {
+	var size struct {
+		Rows, Cols, XPixel, YPixel uint16
+	}
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, uintptr(syscall.TIOCGWINSZ), uintptr(unsafe.Pointer(&size)))
+	if errno != 0 {
+		return 0
+	}
+	return int(size.Cols)
+}
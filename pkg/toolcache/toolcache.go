@@ -0,0 +1,343 @@
+// Package toolcache downloads pinned releases of the external tools shadow
+// runs (kustomize, helm, kubeconform, kyverno) and verifies them against the
+// checksums their projects publish
+//
+// Binaries are kept in <dir>/<tool>/<version>/<tool>, the layout `shadow
+// compat` also uses, so each release is downloaded once per machine. Next to
+// each is <tool>.sha256, the digest of the binary extracted from the verified
+// release; a binary that doesn't match it is downloaded again. Putting
+// those directories first on PATH makes CI and laptops render with the same
+// tools whether or not any are installed.
+package toolcache
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/erauner/homelab-shadow/pkg/cachedir"
+	"github.com/erauner/homelab-shadow/pkg/log"
+	"github.com/erauner/homelab-shadow/pkg/semver"
+)
+
+// Release hosts (overridden in tests)
+var (
+	githubURL = "https://github.com"
+	helmURL   = "https://get.helm.sh"
+)
+
+// digestSuffix names the file next to a binary recording its sha256
+const digestSuffix = ".sha256"
+
+// httpClient bounds a release download, so a stalled host fails the run
+var httpClient = &http.Client{Timeout: 10 * time.Minute}
+
+// Tool describes where a tool's release archives and checksums are published
+type Tool struct {
+	Name string
+
+	// Archive returns the URL of the .tar.gz release for a version and platform
+	Archive func(version, goos, goarch string) string
+
+	// Checksums returns the URL of the file listing the archive's sha256
+	// ("<sha256>  <archive name>" lines)
+	Checksums func(version, goos, goarch string) string
+}
+
+// Tools are the tools toolcache can download, by name
+var Tools = map[string]Tool{
+	"kustomize": {
+		Name: "kustomize",
+		Archive: func(v, goos, goarch string) string {
+			return fmt.Sprintf("%s/kubernetes-sigs/kustomize/releases/download/kustomize%%2Fv%s/kustomize_v%s_%s_%s.tar.gz", githubURL, v, v, goos, goarch)
+		},
+		Checksums: func(v, goos, goarch string) string {
+			return fmt.Sprintf("%s/kubernetes-sigs/kustomize/releases/download/kustomize%%2Fv%s/checksums.txt", githubURL, v)
+		},
+	},
+	"helm": {
+		Name: "helm",
+		Archive: func(v, goos, goarch string) string {
+			return fmt.Sprintf("%s/helm-v%s-%s-%s.tar.gz", helmURL, v, goos, goarch)
+		},
+		Checksums: func(v, goos, goarch string) string {
+			return fmt.Sprintf("%s/helm-v%s-%s-%s.tar.gz.sha256sum", helmURL, v, goos, goarch)
+		},
+	},
+	"kubeconform": {
+		Name: "kubeconform",
+		Archive: func(v, goos, goarch string) string {
+			return fmt.Sprintf("%s/yannh/kubeconform/releases/download/v%s/kubeconform-%s-%s.tar.gz", githubURL, v, goos, goarch)
+		},
+		Checksums: func(v, goos, goarch string) string {
+			return fmt.Sprintf("%s/yannh/kubeconform/releases/download/v%s/CHECKSUMS", githubURL, v)
+		},
+	},
+	"kyverno": {
+		Name: "kyverno",
+		Archive: func(v, goos, goarch string) string {
+			if goarch == "amd64" {
+				goarch = "x86_64"
+			}
+			return fmt.Sprintf("%s/kyverno/kyverno/releases/download/v%s/kyverno-cli_v%s_%s_%s.tar.gz", githubURL, v, v, goos, goarch)
+		},
+		Checksums: func(v, goos, goarch string) string {
+			return fmt.Sprintf("%s/kyverno/kyverno/releases/download/v%s/checksums.txt", githubURL, v)
+		},
+	},
+}
+
+// DefaultDir returns where downloaded tools are kept
+func DefaultDir() string {
+	return cachedir.Path("tools")
+}
+
+// Cache downloads tools into Dir
+type Cache struct {
+	Dir string
+	Log *log.Logger // default: the shared logger
+
+	goos, goarch string // default: runtime.GOOS, runtime.GOARCH
+}
+
+// New creates a cache of tools in dir
+func New(dir string) *Cache {
+	return &Cache{Dir: dir, Log: log.Default().Named("toolcache")}
+}
+
+// Path returns where version of tool is kept, downloaded or not
+func (c *Cache) Path(tool, version string) string {
+	return filepath.Join(c.Dir, tool, version, tool)
+}
+
+// Ensure returns the path to version of tool, downloading and verifying the
+// release if it isn't cached yet or the cached binary doesn't match the
+// digest recorded when it was downloaded
+func (c *Cache) Ensure(name, version string) (string, error) {
+	tool, ok := Tools[name]
+	if !ok {
+		return "", fmt.Errorf("%s can't be downloaded (toolcache supports %s)", name, strings.Join(Names(), ", "))
+	}
+	version = strings.TrimPrefix(version, "v")
+	dest := c.Path(name, version)
+	if verified(dest) {
+		return dest, nil
+	}
+	if _, err := os.Stat(dest); err == nil {
+		c.Log.Warnf("%s %s in %s doesn't match a verified download, downloading it again", name, version, c.Dir)
+	}
+
+	goos, goarch := c.platform()
+	c.Log.Infof("Downloading %s %s (%s/%s)", name, version, goos, goarch)
+	if err := download(tool, version, goos, goarch, dest); err != nil {
+		return "", fmt.Errorf("failed to download %s %s: %w", name, version, err)
+	}
+	return dest, nil
+}
+
+// EnsurePins downloads every tool pinned to an exact version in pins (tool
+// name to version constraint, as in .shadow.yaml tools) and returns the
+// directories holding them. Tools toolcache can't download (git) are left to
+// PATH; a downloadable tool pinned to a range is an error, since a range
+// doesn't say which release to fetch
+func (c *Cache) EnsurePins(pins map[string]string) ([]string, error) {
+	var dirs []string
+	for _, name := range Names() {
+		pin := pins[name]
+		if pin == "" {
+			continue
+		}
+		constraint, err := semver.ParseConstraint(pin)
+		if err != nil {
+			return nil, fmt.Errorf("tools.%s: %w", name, err)
+		}
+		version, ok := constraint.Exact()
+		if !ok {
+			return nil, fmt.Errorf("tools.%s: %q is a range; pin an exact version (e.g. 1.2.3) to download it", name, pin)
+		}
+		binary, err := c.Ensure(name, version.String())
+		if err != nil {
+			return nil, err
+		}
+		dirs = append(dirs, filepath.Dir(binary))
+	}
+	return dirs, nil
+}
+
+// Names returns the tools toolcache can download, sorted
+func Names() []string {
+	names := make([]string, 0, len(Tools))
+	for name := range Tools {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// PrependPath puts dirs before the existing PATH, so the tools in them are
+// found first by every command shadow runs
+func PrependPath(dirs []string) error {
+	if len(dirs) == 0 {
+		return nil
+	}
+	return os.Setenv("PATH", strings.Join(append(dirs, os.Getenv("PATH")), string(os.PathListSeparator)))
+}
+
+// platform returns the OS and architecture to download for
+func (c *Cache) platform() (string, string) {
+	goos, goarch := c.goos, c.goarch
+	if goos == "" {
+		goos = runtime.GOOS
+	}
+	if goarch == "" {
+		goarch = runtime.GOARCH
+	}
+	return goos, goarch
+}
+
+// verified reports whether the binary at path matches its recorded digest
+func verified(path string) bool {
+	want, err := os.ReadFile(path + digestSuffix)
+	if err != nil {
+		return false
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return false
+	}
+	return hex.EncodeToString(h.Sum(nil)) == strings.TrimSpace(string(want))
+}
+
+// download fetches a release archive, checks its sha256 against the published
+// checksums, and extracts the tool's binary to dest, renaming into place so
+// partial or unverified downloads are never used. The binary's digest is
+// recorded last, so an interrupted download is never trusted
+func download(tool Tool, version, goos, goarch, dest string) error {
+	archiveURL := tool.Archive(version, goos, goarch)
+	want, err := checksum(tool.Checksums(version, goos, goarch), path.Base(archiveURL))
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+	archive, err := os.CreateTemp(filepath.Dir(dest), "."+tool.Name+"-*.tar.gz")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(archive.Name())
+	defer archive.Close()
+
+	body, err := get(archiveURL)
+	if err != nil {
+		return err
+	}
+	h := sha256.New()
+	_, err = io.Copy(io.MultiWriter(archive, h), body)
+	body.Close()
+	if err != nil {
+		return err
+	}
+	if got := hex.EncodeToString(h.Sum(nil)); got != want {
+		return fmt.Errorf("checksum mismatch for %s: got %s, want %s", path.Base(archiveURL), got, want)
+	}
+
+	if _, err := archive.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(dest), "."+tool.Name+"-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	bh := sha256.New()
+	if err := extractBinary(archive, tool.Name, io.MultiWriter(tmp, bh)); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0755); err != nil {
+		return err
+	}
+	os.Remove(dest + digestSuffix)
+	if err := os.Rename(tmp.Name(), dest); err != nil {
+		return err
+	}
+	return os.WriteFile(dest+digestSuffix, []byte(hex.EncodeToString(bh.Sum(nil))+"\n"), 0644)
+}
+
+// checksum returns the sha256 listed for name in the checksum file at url
+func checksum(url, name string) (string, error) {
+	body, err := get(url)
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return "", err
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && strings.TrimPrefix(fields[1], "*") == name {
+			return strings.ToLower(fields[0]), nil
+		}
+	}
+	return "", fmt.Errorf("no checksum for %s in %s", name, url)
+}
+
+// get returns the body of a successful GET
+func get(url string) (io.ReadCloser, error) {
+	resp, err := httpClient.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("GET %s returned %d", url, resp.StatusCode)
+	}
+	return resp.Body, nil
+}
+
+// extractBinary copies the file named name from a .tar.gz stream to w
+func extractBinary(r io.Reader, name string, w io.Writer) error {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return fmt.Errorf("%s not found in archive", name)
+		}
+		if err != nil {
+			return err
+		}
+		if hdr.Typeflag == tar.TypeReg && filepath.Base(hdr.Name) == name {
+			_, err := io.Copy(w, tr)
+			return err
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package toolcache
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/erauner/homelab-shadow/pkg/log"
+)
+
+// fakeReleases serves helm and kyverno release archives and checksum files
+// for linux/amd64; each archive's binary is a script that prints its version.
+// corrupt lists archives whose published checksum doesn't match
+func fakeReleases(t *testing.T, corrupt ...string) *int {
+	t.Helper()
+	downloads := 0
+	files := map[string][]byte{}
+	sums := map[string]string{}
+	add := func(dir, archive, binary, version, sumFile string) {
+		data := tarball(t, binary, "#!/bin/sh\necho v"+version+"\n")
+		files[dir+"/"+archive] = data
+		sum := fmt.Sprintf("%x", sha256.Sum256(data))
+		for _, c := range corrupt {
+			if c == archive {
+				sum = strings.Repeat("0", 64)
+			}
+		}
+		sums[dir+"/"+sumFile] += fmt.Sprintf("%s  %s\n", sum, archive)
+	}
+	add("/helm", "helm-v3.14.4-linux-amd64.tar.gz", "helm", "3.14.4", "helm-v3.14.4-linux-amd64.tar.gz.sha256sum")
+	add("/github/kyverno/kyverno/releases/download/v1.12.5", "kyverno-cli_v1.12.5_linux_x86_64.tar.gz", "kyverno", "1.12.5", "checksums.txt")
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if data, ok := files[r.URL.Path]; ok {
+			downloads++
+			w.Write(data)
+			return
+		}
+		if sum, ok := sums[r.URL.Path]; ok {
+			w.Write([]byte(sum))
+			return
+		}
+		http.NotFound(w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	oldGitHub, oldHelm := githubURL, helmURL
+	githubURL, helmURL = server.URL+"/github", server.URL+"/helm"
+	t.Cleanup(func() { githubURL, helmURL = oldGitHub, oldHelm })
+	return &downloads
+}
+
+func tarball(t *testing.T, name, content string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, f := range []struct{ name, content string }{{"linux-amd64/README.md", "readme"}, {"linux-amd64/" + name, content}} {
+		if err := tw.WriteHeader(&tar.Header{Name: f.name, Mode: 0755, Size: int64(len(f.content)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		tw.Write([]byte(f.content))
+	}
+	tw.Close()
+	gz.Close()
+	return buf.Bytes()
+}
+
+func newCache(t *testing.T) *Cache {
+	return &Cache{Dir: t.TempDir(), Log: log.New(&bytes.Buffer{}, log.LevelInfo, log.FormatText), goos: "linux", goarch: "amd64"}
+}
+
+func TestCache_Ensure(t *testing.T) {
+	downloads := fakeReleases(t)
+	cache := newCache(t)
+
+	binary, err := cache.Ensure("helm", "v3.14.4")
+	if err != nil {
+		t.Fatalf("Ensure() error = %v", err)
+	}
+	if binary != filepath.Join(cache.Dir, "helm", "3.14.4", "helm") {
+		t.Errorf("binary = %s", binary)
+	}
+	data, err := os.ReadFile(binary)
+	if err != nil || !strings.Contains(string(data), "echo v3.14.4") {
+		t.Errorf("binary content = %q, %v", data, err)
+	}
+
+	if _, err := cache.Ensure("helm", "3.14.4"); err != nil {
+		t.Fatal(err)
+	}
+	if *downloads != 1 {
+		t.Errorf("downloads = %d, want a cached second Ensure", *downloads)
+	}
+
+	if _, err := cache.Ensure("git", "2.43.0"); err == nil || !strings.Contains(err.Error(), "can't be downloaded") {
+		t.Errorf("Ensure(git) error = %v", err)
+	}
+	if _, err := cache.Ensure("helm", "3.99.0"); err == nil {
+		t.Error("Ensure() of a missing release should fail")
+	}
+}
+
+func TestCache_EnsureUnverifiedHit(t *testing.T) {
+	downloads := fakeReleases(t)
+	cache := newCache(t)
+	binary := cache.Path("helm", "3.14.4")
+	if err := os.MkdirAll(filepath.Dir(binary), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	// A binary some other tool left there, with no digest, and one changed
+	// after its verified download are both replaced
+	for _, digest := range []bool{false, true} {
+		if err := os.WriteFile(binary, []byte("#!/bin/sh\necho tampered\n"), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if !digest {
+			os.Remove(binary + digestSuffix)
+		}
+		if _, err := cache.Ensure("helm", "3.14.4"); err != nil {
+			t.Fatalf("Ensure() error = %v", err)
+		}
+		data, err := os.ReadFile(binary)
+		if err != nil || !strings.Contains(string(data), "echo v3.14.4") {
+			t.Errorf("digest=%v: binary content = %q, %v; want the verified release", digest, data, err)
+		}
+	}
+	if *downloads != 2 {
+		t.Errorf("downloads = %d, want 2", *downloads)
+	}
+}
+
+func TestCache_EnsureChecksumMismatch(t *testing.T) {
+	fakeReleases(t, "kyverno-cli_v1.12.5_linux_x86_64.tar.gz")
+	cache := newCache(t)
+
+	_, err := cache.Ensure("kyverno", "1.12.5")
+	if err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Fatalf("Ensure() error = %v, want a checksum mismatch", err)
+	}
+	if _, err := os.Stat(cache.Path("kyverno", "1.12.5")); !os.IsNotExist(err) {
+		t.Error("an unverified binary was left in the cache")
+	}
+}
+
+func TestCache_EnsurePins(t *testing.T) {
+	fakeReleases(t)
+	cache := newCache(t)
+
+	dirs, err := cache.EnsurePins(map[string]string{"helm": "3.14.4", "kyverno": "=1.12.5", "git": ">=2.28"})
+	if err != nil {
+		t.Fatalf("EnsurePins() error = %v", err)
+	}
+	want := []string{filepath.Join(cache.Dir, "helm", "3.14.4"), filepath.Join(cache.Dir, "kyverno", "1.12.5")}
+	if fmt.Sprint(dirs) != fmt.Sprint(want) {
+		t.Errorf("dirs = %v, want %v", dirs, want)
+	}
+
+	if _, err := cache.EnsurePins(map[string]string{"helm": "3.14.x"}); err == nil || !strings.Contains(err.Error(), "pin an exact version") {
+		t.Errorf("EnsurePins() of a range error = %v", err)
+	}
+}
+
+func TestPrependPath(t *testing.T) {
+	fakeReleases(t)
+	cache := newCache(t)
+	dirs, err := cache.EnsurePins(map[string]string{"helm": "3.14.4"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("PATH", os.Getenv("PATH"))
+	if err := PrependPath(dirs); err != nil {
+		t.Fatal(err)
+	}
+	out, err := exec.Command("helm").Output()
+	if err != nil || strings.TrimSpace(string(out)) != "v3.14.4" {
+		t.Errorf("helm on PATH printed %q, %v; want the cached release", out, err)
+	}
+}
@@ -0,0 +1,248 @@
+// Package trace records spans of a run and exports them to an OpenTelemetry
+// collector over OTLP/HTTP (JSON encoding) when OTEL_EXPORTER_OTLP_ENDPOINT
+// is set
+//
+// Spans are kept in memory and sent in one request when the run ends (Flush),
+// which suits a CLI run of minutes with a few thousand spans. Without Setup,
+// or when no endpoint is configured, every function here is a no-op.
+//
+// Environment (the standard OTLP exporter variables):
+//
+//	OTEL_EXPORTER_OTLP_ENDPOINT         collector base URL; spans go to <url>/v1/traces
+//	OTEL_EXPORTER_OTLP_TRACES_ENDPOINT  full traces URL, overriding the above
+//	OTEL_EXPORTER_OTLP_HEADERS          extra headers, "key=value,key2=value2"
+//	OTEL_SERVICE_NAME                   service.name (default: shadow)
+//	OTEL_RESOURCE_ATTRIBUTES            extra resource attributes, "key=value,..."
+//	TRACEPARENT                         W3C trace context to continue (e.g. from CI)
+package trace
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Tracer collects finished spans until Flush
+type Tracer struct {
+	Endpoint string            // OTLP/HTTP traces URL
+	Headers  map[string]string // sent with the export request
+	Resource map[string]string // resource attributes, including service.name
+	Scope    string            // instrumentation scope version (the shadow version)
+
+	// remote is the span context continued from TRACEPARENT, if any
+	remote spanContext
+
+	mu    sync.Mutex
+	spans []*Span
+	root  *Span // the first span started; parents spans started without one
+}
+
+// spanContext identifies a span within a trace
+type spanContext struct {
+	traceID [16]byte
+	spanID  [8]byte
+}
+
+func (c spanContext) valid() bool {
+	return c.traceID != [16]byte{}
+}
+
+// Span is one timed operation; a nil *Span (tracing disabled) ignores every call
+type Span struct {
+	tracer *Tracer
+	name   string
+	kind   int
+	ctx    spanContext
+	parent [8]byte
+	start  time.Time
+	end    time.Time
+	attrs  []attribute
+	err    error
+	ended  bool
+}
+
+type attribute struct {
+	key   string
+	value interface{}
+}
+
+// Span kinds (OTLP SpanKind)
+const (
+	KindInternal = 1
+	KindClient   = 3
+)
+
+var (
+	mu      sync.Mutex
+	current *Tracer
+)
+
+// Setup enables tracing from the OTLP environment variables; it returns
+// false, leaving tracing disabled, when no endpoint is configured
+func Setup(version string) (bool, error) {
+	endpoint := os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT")
+	if endpoint == "" {
+		base := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT")
+		if base == "" {
+			return false, nil
+		}
+		endpoint = strings.TrimSuffix(base, "/") + "/v1/traces"
+	}
+	headers, err := parsePairs(os.Getenv("OTEL_EXPORTER_OTLP_HEADERS"))
+	if err != nil {
+		return false, fmt.Errorf("invalid OTEL_EXPORTER_OTLP_HEADERS: %w", err)
+	}
+	resource, err := parsePairs(os.Getenv("OTEL_RESOURCE_ATTRIBUTES"))
+	if err != nil {
+		return false, fmt.Errorf("invalid OTEL_RESOURCE_ATTRIBUTES: %w", err)
+	}
+	resource["service.name"] = "shadow"
+	if name := os.Getenv("OTEL_SERVICE_NAME"); name != "" {
+		resource["service.name"] = name
+	}
+	resource["service.version"] = version
+
+	t := &Tracer{Endpoint: endpoint, Headers: headers, Resource: resource, Scope: version}
+	t.remote, _ = parseTraceparent(os.Getenv("TRACEPARENT"))
+	SetTracer(t)
+	return true, nil
+}
+
+// SetTracer makes t the tracer Start records to (nil disables tracing)
+func SetTracer(t *Tracer) {
+	mu.Lock()
+	defer mu.Unlock()
+	current = t
+}
+
+func tracer() *Tracer {
+	mu.Lock()
+	defer mu.Unlock()
+	return current
+}
+
+type spanKey struct{}
+
+// Start starts a span named name as a child of the span in ctx (or of
+// TRACEPARENT), returning a context carrying it
+func Start(ctx context.Context, name string, attrs ...interface{}) (context.Context, *Span) {
+	return StartKind(ctx, name, KindInternal, attrs...)
+}
+
+// StartKind is Start with a span kind; attrs are key, value pairs whose
+// values are strings, bools, ints, or float64s
+func StartKind(ctx context.Context, name string, kind int, attrs ...interface{}) (context.Context, *Span) {
+	t := tracer()
+	if t == nil {
+		return ctx, nil
+	}
+	s := &Span{tracer: t, name: name, kind: kind, start: time.Now()}
+	rand.Read(s.ctx.spanID[:])
+
+	// Work started without a span in its context (commands run without one)
+	// still belongs to the run's trace
+	parent := FromContext(ctx)
+	t.mu.Lock()
+	if parent == nil {
+		parent = t.root
+	}
+	if t.root == nil {
+		t.root = s
+	}
+	t.mu.Unlock()
+
+	switch {
+	case parent != nil:
+		s.ctx.traceID, s.parent = parent.ctx.traceID, parent.ctx.spanID
+	case t.remote.valid():
+		s.ctx.traceID, s.parent = t.remote.traceID, t.remote.spanID
+	default:
+		rand.Read(s.ctx.traceID[:])
+	}
+	for i := 0; i+1 < len(attrs); i += 2 {
+		s.SetAttr(fmt.Sprint(attrs[i]), attrs[i+1])
+	}
+	return context.WithValue(ctx, spanKey{}, s), s
+}
+
+// FromContext returns the span carried by ctx, or nil
+func FromContext(ctx context.Context) *Span {
+	if ctx == nil {
+		return nil
+	}
+	s, _ := ctx.Value(spanKey{}).(*Span)
+	return s
+}
+
+// SetAttr sets an attribute on the span
+func (s *Span) SetAttr(key string, value interface{}) {
+	if s == nil {
+		return
+	}
+	s.tracer.mu.Lock()
+	defer s.tracer.mu.Unlock()
+	s.attrs = append(s.attrs, attribute{key: key, value: value})
+}
+
+// End finishes the span, marking it failed when err is non-nil; only the
+// first End counts
+func (s *Span) End(err error) {
+	if s == nil {
+		return
+	}
+	s.tracer.mu.Lock()
+	defer s.tracer.mu.Unlock()
+	if s.ended {
+		return
+	}
+	s.ended, s.end, s.err = true, time.Now(), err
+	s.tracer.spans = append(s.tracer.spans, s)
+}
+
+// Flush exports the finished spans of the current tracer, if any
+func Flush(ctx context.Context) error {
+	t := tracer()
+	if t == nil {
+		return nil
+	}
+	return t.Flush(ctx)
+}
+
+// parsePairs parses "key=value,key2=value2" (values may be percent-encoded)
+func parsePairs(s string) (map[string]string, error) {
+	pairs := make(map[string]string)
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(item, "=")
+		if !ok || strings.TrimSpace(key) == "" {
+			return nil, fmt.Errorf("%q is not key=value", item)
+		}
+		pairs[strings.TrimSpace(key)] = unescape(strings.TrimSpace(value))
+	}
+	return pairs, nil
+}
+
+// parseTraceparent parses a W3C traceparent header ("00-<trace>-<span>-<flags>")
+func parseTraceparent(s string) (spanContext, bool) {
+	var c spanContext
+	parts := strings.Split(strings.TrimSpace(s), "-")
+	if len(parts) != 4 || len(parts[1]) != 32 || len(parts[2]) != 16 {
+		return c, false
+	}
+	trace, err1 := hex.DecodeString(parts[1])
+	span, err2 := hex.DecodeString(parts[2])
+	if err1 != nil || err2 != nil {
+		return c, false
+	}
+	copy(c.traceID[:], trace)
+	copy(c.spanID[:], span)
+	return c, c.valid()
+}
@@ -0,0 +1,221 @@
+package trace
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// collector records the export requests it receives
+func collector(t *testing.T) (*httptest.Server, *[]exportRequest, *http.Header) {
+	t.Helper()
+	var got []exportRequest
+	var header http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/traces" || r.Method != http.MethodPost {
+			http.Error(w, "unexpected "+r.Method+" "+r.URL.Path, http.StatusNotFound)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		var req exportRequest
+		if err := json.Unmarshal(body, &req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		got = append(got, req)
+		header = r.Header.Clone()
+	}))
+	t.Cleanup(srv.Close)
+	t.Cleanup(func() { SetTracer(nil) })
+	return srv, &got, &header
+}
+
+func TestSetup_Export(t *testing.T) {
+	srv, got, header := collector(t)
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", srv.URL+"/")
+	t.Setenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "")
+	t.Setenv("OTEL_EXPORTER_OTLP_HEADERS", "Authorization=Bearer%20token, X-Scope=homelab")
+	t.Setenv("OTEL_SERVICE_NAME", "")
+	t.Setenv("OTEL_RESOURCE_ATTRIBUTES", "deployment.environment=ci")
+	t.Setenv("TRACEPARENT", "")
+
+	enabled, err := Setup("1.2.3")
+	if err != nil || !enabled {
+		t.Fatalf("Setup() = %v, %v", enabled, err)
+	}
+
+	ctx, root := Start(context.Background(), "sync", "shadow.pr", 42, "shadow.dry_run", true)
+	_, child := StartKind(ctx, "helm template", KindClient)
+	child.End(errors.New("exit status 1"))
+	_, orphan := Start(context.Background(), "git commit")
+	orphan.End(nil)
+	root.End(nil)
+	root.End(errors.New("ignored"))
+
+	if err := Flush(context.Background()); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+	if len(*got) != 1 {
+		t.Fatalf("collector got %d request(s), want 1", len(*got))
+	}
+	if h := (*header).Get("Authorization"); h != "Bearer token" {
+		t.Errorf("Authorization header = %q", h)
+	}
+	if h := (*header).Get("X-Scope"); h != "homelab" {
+		t.Errorf("X-Scope header = %q", h)
+	}
+
+	rs := (*got)[0].ResourceSpans[0]
+	resource := make(map[string]string)
+	for _, a := range rs.Resource.Attributes {
+		resource[a.Key] = *a.Value.StringValue
+	}
+	if resource["service.name"] != "shadow" || resource["service.version"] != "1.2.3" || resource["deployment.environment"] != "ci" {
+		t.Errorf("resource = %v", resource)
+	}
+
+	spans := make(map[string]spanJSON)
+	for _, s := range rs.ScopeSpans[0].Spans {
+		spans[s.Name] = s
+	}
+	if len(spans) != 3 {
+		t.Fatalf("exported %d span(s), want 3: %v", len(spans), spans)
+	}
+	rootSpan, helm, git := spans["sync"], spans["helm template"], spans["git commit"]
+	if len(rootSpan.TraceID) != 32 || len(rootSpan.SpanID) != 16 || rootSpan.ParentSpanID != "" {
+		t.Errorf("root span ids = %+v", rootSpan)
+	}
+	if rootSpan.Status.Code != 1 {
+		t.Errorf("root span status = %+v, want ok (a second End is ignored)", rootSpan.Status)
+	}
+	if helm.TraceID != rootSpan.TraceID || helm.ParentSpanID != rootSpan.SpanID || helm.Kind != KindClient {
+		t.Errorf("helm span = %+v, want a client child of %s", helm, rootSpan.SpanID)
+	}
+	if helm.Status.Code != 2 || helm.Status.Message != "exit status 1" {
+		t.Errorf("helm span status = %+v", helm.Status)
+	}
+	if git.ParentSpanID != rootSpan.SpanID {
+		t.Errorf("span started without a parent has parent %q, want the root %s", git.ParentSpanID, rootSpan.SpanID)
+	}
+
+	attrs := make(map[string]anyValue)
+	for _, a := range rootSpan.Attributes {
+		attrs[a.Key] = a.Value
+	}
+	if v := attrs["shadow.pr"].IntValue; v == nil || *v != "42" {
+		t.Errorf("shadow.pr = %+v", attrs["shadow.pr"])
+	}
+	if v := attrs["shadow.dry_run"].BoolValue; v == nil || !*v {
+		t.Errorf("shadow.dry_run = %+v", attrs["shadow.dry_run"])
+	}
+
+	// Flushed spans are not sent again
+	if err := Flush(context.Background()); err != nil || len(*got) != 1 {
+		t.Errorf("second Flush() = %v with %d request(s)", err, len(*got))
+	}
+}
+
+func TestSetup_Traceparent(t *testing.T) {
+	srv, got, _ := collector(t)
+	t.Setenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", srv.URL+"/v1/traces")
+	t.Setenv("OTEL_EXPORTER_OTLP_HEADERS", "")
+	t.Setenv("OTEL_RESOURCE_ATTRIBUTES", "")
+	t.Setenv("OTEL_SERVICE_NAME", "shadow-ci")
+	t.Setenv("TRACEPARENT", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+
+	if _, err := Setup("dev"); err != nil {
+		t.Fatal(err)
+	}
+	_, span := Start(context.Background(), "shadow sync")
+	span.End(nil)
+	if err := Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	s := (*got)[0].ResourceSpans[0].ScopeSpans[0].Spans[0]
+	if s.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" || s.ParentSpanID != "00f067aa0ba902b7" {
+		t.Errorf("span = %+v, want it to continue TRACEPARENT", s)
+	}
+	if name := (*got)[0].ResourceSpans[0].Resource.Attributes[0]; *name.Value.StringValue != "shadow-ci" {
+		t.Errorf("service.name = %+v", name)
+	}
+}
+
+func TestSetup_Disabled(t *testing.T) {
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "")
+	t.Setenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "")
+	t.Cleanup(func() { SetTracer(nil) })
+
+	enabled, err := Setup("dev")
+	if err != nil || enabled {
+		t.Fatalf("Setup() without an endpoint = %v, %v", enabled, err)
+	}
+	ctx, span := Start(context.Background(), "sync")
+	if span != nil || FromContext(ctx) != nil {
+		t.Error("Start() without a tracer should return a nil span")
+	}
+	span.SetAttr("k", "v")
+	span.End(errors.New("boom"))
+	if err := Flush(context.Background()); err != nil {
+		t.Errorf("Flush() without a tracer = %v", err)
+	}
+}
+
+func TestSetup_InvalidHeaders(t *testing.T) {
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "http://localhost:4318")
+	t.Setenv("OTEL_EXPORTER_OTLP_HEADERS", "no-equals-sign")
+	t.Cleanup(func() { SetTracer(nil) })
+
+	if _, err := Setup("dev"); err == nil {
+		t.Error("Setup() with malformed headers should fail")
+	}
+}
+
+func TestFlush_CollectorError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+	}))
+	defer srv.Close()
+	tr := &Tracer{Endpoint: srv.URL}
+	SetTracer(tr)
+	defer SetTracer(nil)
+
+	_, span := Start(context.Background(), "sync")
+	span.End(nil)
+	if err := tr.Flush(context.Background()); err == nil {
+		t.Error("Flush() should report a collector error")
+	}
+}
+
+func TestFlush_Timeout(t *testing.T) {
+	hung := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-hung
+	}))
+	defer srv.Close()
+	defer close(hung)
+	orig := ExportTimeout
+	ExportTimeout = 50 * time.Millisecond
+	defer func() { ExportTimeout = orig }()
+	tr := &Tracer{Endpoint: srv.URL}
+	SetTracer(tr)
+	defer SetTracer(nil)
+
+	_, span := Start(context.Background(), "sync")
+	span.End(nil)
+	start := time.Now()
+	if err := tr.Flush(context.Background()); err == nil {
+		t.Error("Flush() should fail when the collector hangs")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Flush() took %s, want it bounded by ExportTimeout", elapsed)
+	}
+	if len(tr.spans) != 0 {
+		t.Errorf("%d span(s) kept after a failed export, want them dropped", len(tr.spans))
+	}
+}
@@ -0,0 +1,157 @@
+package validate
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/erauner/homelab-shadow/pkg/command"
+	"github.com/erauner/homelab-shadow/pkg/flux"
+	"github.com/erauner/homelab-shadow/pkg/kustomize"
+)
+
+// API version rules
+const (
+	// RuleAPIRemoved flags resources whose apiVersion is no longer served
+	// by the target Kubernetes version
+	RuleAPIRemoved = "api-removed"
+	// RuleAPIDeprecated flags resources whose apiVersion the target
+	// Kubernetes version still serves but has deprecated
+	RuleAPIDeprecated = "api-deprecated"
+)
+
+// ValidateRemovedAPIs flags rendered resources using APIs that the target
+// Kubernetes version (e.g. "1.32" or "1.32.1") no longer serves
+func ValidateRemovedAPIs(cluster, path, manifest, target string) ([]Result, error) {
+	results, err := ValidateDeprecatedAPIs(cluster, path, manifest, target)
+	if err != nil {
+		return nil, err
+	}
+	var removed []Result
+	for _, r := range results {
+		if r.Rule == RuleAPIRemoved {
+			removed = append(removed, r)
+		}
+	}
+	return removed, nil
+}
+
+// ValidateDeprecatedAPIs flags rendered resources using APIs that the target
+// Kubernetes version no longer serves (errors) or has deprecated (warnings),
+// suggesting the apiVersion to migrate to
+func ValidateDeprecatedAPIs(cluster, path, manifest, target string) ([]Result, error) {
+	found, err := kustomize.FindDeprecatedAPIs(manifest, target)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []Result
+	for _, api := range found {
+		rule, severity := RuleAPIDeprecated, "warn"
+		if api.Removed {
+			rule, severity = RuleAPIRemoved, "error"
+		}
+		results = append(results, Result{
+			Cluster:  cluster,
+			Rule:     rule,
+			Path:     path,
+			Message:  api.String(),
+			Severity: severity,
+		})
+	}
+	return results, nil
+}
+
+// ValidateAPIVersions renders every local ArgoCD Application source path and
+// flags resources using APIs removed or deprecated in the target Kubernetes
+// version. Paths that fail to build are skipped (see --build-app-paths)
+func (v *ClusterValidator) ValidateAPIVersions(target string) []Result {
+	results := []Result{}
+
+	err := v.renderSourcePaths(func(sourcePath, manifest string) {
+		found, err := ValidateDeprecatedAPIs("global", sourcePath, manifest, target)
+		if err != nil {
+			v.Log.Debugf("skipping API version check of %s: %v", sourcePath, err)
+			return
+		}
+		results = append(results, found...)
+	})
+	if err != nil {
+		results = append(results, Result{
+			Cluster:  "global",
+			Rule:     "api-version-validation-error",
+			Path:     "argocd-apps/",
+			Message:  fmt.Sprintf("Failed to load Applications: %v", err),
+			Severity: "error",
+		})
+	}
+	return results
+}
+
+// sourceManifest is the kustomize build output of an Application source path
+type sourceManifest struct {
+	path     string
+	manifest string
+}
+
+// renderSourcePaths passes the manifest of every local ArgoCD Application
+// source path to visit. Each path is built once per validator; paths that
+// fail to build are skipped (see --build-app-paths). It fails only if
+// Applications can't be loaded
+func (v *ClusterValidator) renderSourcePaths(visit func(sourcePath, manifest string)) error {
+	if v.sourceManifests == nil {
+		rendered, err := v.buildSourcePaths()
+		if err != nil {
+			return err
+		}
+		v.sourceManifests = rendered
+	}
+	for _, m := range v.sourceManifests {
+		visit(m.path, m.manifest)
+	}
+	return nil
+}
+
+// buildSourcePaths kustomize builds every local ArgoCD Application source path
+func (v *ClusterValidator) buildSourcePaths() ([]sourceManifest, error) {
+	apps, _, err := flux.LoadAllApplications(v.RepoPath)
+	if err != nil {
+		return nil, err
+	}
+	rendered := []sourceManifest{}
+
+	origin := normalizeRepoURL(gitOrigin(v.RepoPath))
+	seen := make(map[string]bool)
+	for _, app := range apps {
+		for _, source := range app.AllSources() {
+			if source.Path == "" {
+				continue
+			}
+			if origin != "" && source.RepoURL != "" && normalizeRepoURL(source.RepoURL) != origin {
+				continue
+			}
+			sourcePath := cleanRelPath(source.Path)
+			if seen[sourcePath] {
+				continue
+			}
+			seen[sourcePath] = true
+
+			fullPath := filepath.Join(v.RepoPath, filepath.FromSlash(sourcePath))
+			if !hasKustomization(fullPath) {
+				continue
+			}
+			manifest, err := command.Command("kustomize", "build", fullPath).Output()
+			if err != nil {
+				v.Log.Debugf("skipping %s: kustomize build failed: %v", sourcePath, err)
+				continue
+			}
+			rendered = append(rendered, sourceManifest{path: sourcePath, manifest: string(manifest)})
+		}
+	}
+	return rendered, nil
+}
+
+// KubeMinor returns the minor version of a 1.x Kubernetes version string
+// Accepts "1.32", "v1.32", and "1.32.1"
+func KubeMinor(version string) (int, error) {
+	return kustomize.KubeMinor(version)
+}
@@ -0,0 +1,111 @@
+package validate
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateRemovedAPIs(t *testing.T) {
+	manifest := `apiVersion: batch/v1beta1
+kind: CronJob
+metadata:
+  name: backup
+---
+apiVersion: flowcontrol.apiserver.k8s.io/v1beta3
+kind: FlowSchema
+metadata:
+  name: exempt
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+`
+
+	results, err := ValidateRemovedAPIs("erauner-home", "apps/backup", manifest, "1.31")
+	if err != nil {
+		t.Fatalf("ValidateRemovedAPIs failed: %v", err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("expected 1 result for 1.31, got %d: %+v", len(results), results)
+	}
+	r := results[0]
+	if r.Rule != RuleAPIRemoved || r.Severity != "error" || r.Cluster != "erauner-home" {
+		t.Errorf("unexpected result: %+v", r)
+	}
+	if !strings.Contains(r.Message, "CronJob/backup") || !strings.Contains(r.Message, "batch/v1") {
+		t.Errorf("unexpected message: %s", r.Message)
+	}
+
+	results, err = ValidateRemovedAPIs("", "apps/backup", manifest, "v1.32.2")
+	if err != nil {
+		t.Fatalf("ValidateRemovedAPIs failed: %v", err)
+	}
+	if len(results) != 2 {
+		t.Errorf("expected 2 results for 1.32, got %d", len(results))
+	}
+
+	results, err = ValidateRemovedAPIs("", "apps/backup", manifest, "1.24")
+	if err != nil {
+		t.Fatalf("ValidateRemovedAPIs failed: %v", err)
+	}
+	if len(results) != 0 {
+		t.Errorf("expected no results for 1.24, got %+v", results)
+	}
+}
+
+func TestValidateDeprecatedAPIs(t *testing.T) {
+	manifest := `apiVersion: batch/v1beta1
+kind: CronJob
+metadata:
+  name: backup
+---
+apiVersion: flowcontrol.apiserver.k8s.io/v1beta3
+kind: FlowSchema
+metadata:
+  name: exempt
+`
+
+	results, err := ValidateDeprecatedAPIs("global", "apps/backup", manifest, "1.30")
+	if err != nil {
+		t.Fatalf("ValidateDeprecatedAPIs failed: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("expected 2 results for 1.30, got %d: %+v", len(results), results)
+	}
+	if r := results[0]; r.Rule != RuleAPIRemoved || r.Severity != "error" {
+		t.Errorf("expected the CronJob to be removed, got %+v", r)
+	}
+	r := results[1]
+	if r.Rule != RuleAPIDeprecated || r.Severity != "warn" || r.Path != "apps/backup" {
+		t.Errorf("expected the FlowSchema to be deprecated, got %+v", r)
+	}
+	if !strings.Contains(r.Message, "deprecated in Kubernetes 1.29") || !strings.Contains(r.Message, "(use flowcontrol.apiserver.k8s.io/v1)") {
+		t.Errorf("unexpected message: %s", r.Message)
+	}
+}
+
+func TestKubeMinor(t *testing.T) {
+	tests := []struct {
+		version string
+		want    int
+		wantErr bool
+	}{
+		{"1.32", 32, false},
+		{"v1.29.3", 29, false},
+		{"2.0", 0, true},
+		{"1", 0, true},
+		{"1.x", 0, true},
+	}
+
+	for _, tt := range tests {
+		got, err := KubeMinor(tt.version)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("KubeMinor(%q) error = %v, wantErr %v", tt.version, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("KubeMinor(%q) = %d, want %d", tt.version, got, tt.want)
+		}
+	}
+}
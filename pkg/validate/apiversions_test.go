@@ -0,0 +1,48 @@
+package validate_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/erauner/homelab-shadow/pkg/validate"
+	"github.com/erauner/homelab-shadow/pkg/validate/validatetest"
+)
+
+func TestValidateAPIVersions(t *testing.T) {
+	root := validatetest.Build(t, validatetest.Repo{
+		Kustomizations: map[string]validatetest.Kustomization{
+			"apps/backup/overlays/erauner-home/production": {},
+			"apps/flows/overlays/erauner-home/production":  {},
+			"apps/broken/overlays/erauner-home/production": {},
+		},
+		Applications: []validatetest.Application{
+			{Name: "backup", Path: "apps/backup/overlays/erauner-home/production"},
+			{Name: "backup-copy", Path: "./apps/backup/overlays/erauner-home/production/"},
+			{Name: "flows", Path: "apps/flows/overlays/erauner-home/production"},
+			{Name: "broken", Path: "apps/broken/overlays/erauner-home/production"},
+			{Name: "typo", Path: "apps/codr/overlays/erauner-home/production"},
+		},
+	})
+
+	// Stub kustomize to render a removed CronJob for backup and a deprecated FlowSchema for flows
+	bin := t.TempDir()
+	script := `#!/bin/sh
+case "$2" in
+*backup*) printf 'apiVersion: batch/v1beta1\nkind: CronJob\nmetadata:\n  name: backup\n';;
+*flows*) printf 'apiVersion: flowcontrol.apiserver.k8s.io/v1beta3\nkind: FlowSchema\nmetadata:\n  name: exempt\n';;
+*) echo 'Error: accumulating resources' >&2; exit 1;;
+esac
+`
+	if err := os.WriteFile(filepath.Join(bin, "kustomize"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	v := validate.NewClusterValidator(root, false)
+	validatetest.AssertFindings(t, v.ValidateAPIVersions("1.30"),
+		validatetest.Finding{Rule: validate.RuleAPIRemoved, Path: "apps/backup/overlays/erauner-home/production", Cluster: "global", Severity: "error"},
+		validatetest.Finding{Rule: validate.RuleAPIDeprecated, Path: "apps/flows/overlays/erauner-home/production", Cluster: "global", Severity: "warn"},
+	)
+	validatetest.AssertFindings(t, v.ValidateAPIVersions("1.20"))
+}
@@ -0,0 +1,184 @@
+package validate_test
+
+import (
+	"testing"
+
+	"github.com/erauner/homelab-shadow/pkg/validate"
+	"github.com/erauner/homelab-shadow/pkg/validate/validatetest"
+)
+
+func TestValidateAppOverlayStructure(t *testing.T) {
+	clusters := []string{"erauner-home"}
+
+	tests := []struct {
+		name string
+		repo validatetest.Repo
+		want []validatetest.Finding
+	}{
+		{
+			name: "cluster-layered overlay with correct base ref",
+			repo: validatetest.Repo{
+				Dirs: []string{"apps/coder/base"},
+				Kustomizations: map[string]validatetest.Kustomization{
+					"apps/coder/overlays/erauner-home/production": {Resources: []string{"../../../base"}},
+				},
+			},
+		},
+		{
+			name: "legacy flat overlay warns",
+			repo: validatetest.Repo{
+				Kustomizations: map[string]validatetest.Kustomization{
+					"apps/coder/overlays/production": {Resources: []string{"../../base"}},
+				},
+			},
+			want: []validatetest.Finding{
+				{Rule: "app-overlay-legacy-flat", Path: "apps/coder/overlays/production", Severity: "warn"},
+			},
+		},
+		{
+			name: "cluster-layered overlay with legacy base depth",
+			repo: validatetest.Repo{
+				Kustomizations: map[string]validatetest.Kustomization{
+					"apps/coder/overlays/erauner-home/production": {Resources: []string{"../../base"}},
+				},
+			},
+			want: []validatetest.Finding{
+				{Rule: "app-overlay-wrong-base-ref", Path: "apps/coder/overlays/erauner-home/production/kustomization.yaml"},
+			},
+		},
+		{
+			name: "overlay missing base ref",
+			repo: validatetest.Repo{
+				Kustomizations: map[string]validatetest.Kustomization{
+					"apps/coder/overlays/erauner-home/production": {Resources: []string{"../../../other"}},
+				},
+			},
+			want: []validatetest.Finding{
+				{Rule: "app-overlay-missing-base", Severity: "warn"},
+			},
+		},
+		{
+			name: "overlay with own helmCharts is exempt",
+			repo: validatetest.Repo{
+				Kustomizations: map[string]validatetest.Kustomization{
+					"apps/coder/overlays/erauner-home/production": {Resources: []string{"../../../other"}, HelmCharts: []string{"coder"}},
+				},
+			},
+		},
+		{
+			name: "nested kustomization in legacy env is not a cluster layer",
+			repo: validatetest.Repo{
+				Clusters: []string{"erauner-home"},
+				Kustomizations: map[string]validatetest.Kustomization{
+					"apps/coder/overlays/production":         {Resources: []string{"../../base"}},
+					"apps/coder/overlays/production/patches": {Resources: []string{"../other"}},
+				},
+			},
+			want: []validatetest.Finding{
+				{Rule: "app-overlay-legacy-flat", Path: "apps/coder/overlays/production", Severity: "warn"},
+			},
+		},
+		{
+			name: "flat overlay named like a cluster typo",
+			repo: validatetest.Repo{
+				Clusters: []string{"erauner-home"},
+				Kustomizations: map[string]validatetest.Kustomization{
+					"apps/coder/overlays/eraunerhome": {Resources: []string{"../../base"}},
+				},
+			},
+			want: []validatetest.Finding{
+				{Rule: "app-overlay-unknown-cluster", Path: "apps/coder/overlays/eraunerhome", Severity: "error"},
+			},
+		},
+		{
+			name: "cluster-layered overlay under a typo'd cluster",
+			repo: validatetest.Repo{
+				Clusters: []string{"erauner-home"},
+				Kustomizations: map[string]validatetest.Kustomization{
+					"apps/coder/db/overlays/erauner-hom/production": {Resources: []string{"../../../base"}},
+				},
+			},
+			want: []validatetest.Finding{
+				{Rule: "app-overlay-unknown-cluster", Path: "apps/coder/db/overlays/erauner-hom"},
+			},
+		},
+		{
+			name: "stack directories skip base ref checks",
+			repo: validatetest.Repo{
+				Kustomizations: map[string]validatetest.Kustomization{
+					"apps/media/stack/erauner-home/production": {Resources: []string{"../../../overlays/erauner-home/production"}},
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := validatetest.Build(t, tt.repo)
+			v := validate.NewClusterValidator(root, false)
+			validatetest.AssertFindings(t, v.ValidateAppOverlayStructure(clusters), tt.want...)
+		})
+	}
+}
+
+func TestValidateCreateNamespace(t *testing.T) {
+	root := validatetest.Build(t, validatetest.Repo{
+		Applications: []validatetest.Application{
+			{Name: "coder", Path: "apps/coder/overlays/erauner-home/production", SyncOptions: []string{"CreateNamespace=true"}},
+			{Name: "homelab-testapp", Path: "apps/testapp/overlays/erauner-home/production", SyncOptions: []string{"CreateNamespace=true"}},
+			{Name: "giraffe", Path: "apps/giraffe/overlays/erauner-home/production"},
+		},
+	})
+
+	v := validate.NewClusterValidator(root, false)
+	validatetest.AssertFindings(t, v.ValidateCreateNamespace(),
+		validatetest.Finding{Rule: "app-create-namespace", Path: "argocd-apps/applications/coder.yaml"},
+	)
+}
+
+func TestValidateArgoCDAppPaths_ApplicationSet(t *testing.T) {
+	clusters := []string{"erauner-home"}
+
+	root := validatetest.Build(t, validatetest.Repo{
+		Clusters: clusters,
+		Applications: []validatetest.Application{
+			{Name: "coder", Path: "apps/coder/overlays/production"},
+		},
+		Files: map[string]string{
+			"argocd-apps/applications/envs.yaml": `apiVersion: argoproj.io/v1alpha1
+kind: ApplicationSet
+metadata:
+  name: envs
+spec:
+  generators:
+    - list:
+        elements:
+          - env: production
+          - env: staging
+  template:
+    metadata:
+      name: 'media-{{env}}'
+    spec:
+      source:
+        path: 'apps/media/overlays/{{env}}'
+`,
+			"argocd-apps/applications/matrix.yaml": `apiVersion: argoproj.io/v1alpha1
+kind: ApplicationSet
+metadata:
+  name: matrix
+spec:
+  generators:
+    - matrix:
+        generators: []
+`,
+		},
+	})
+
+	v := validate.NewClusterValidator(root, false)
+	validatetest.AssertFindings(t, v.ValidateArgoCDAppPaths(clusters),
+		validatetest.Finding{Rule: "argocd-app-legacy-path", Path: "argocd-apps/applications/coder.yaml"},
+		validatetest.Finding{Rule: "argocd-app-legacy-path", Path: "argocd-apps/applications/envs.yaml"},
+		validatetest.Finding{Rule: "argocd-app-legacy-path", Path: "argocd-apps/applications/envs.yaml"},
+		validatetest.Finding{Rule: "argocd-appset-expand-fail", Path: "argocd-apps/applications/matrix.yaml", Severity: "warn"},
+	)
+}
@@ -0,0 +1,145 @@
+package validate
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/erauner/homelab-shadow/pkg/command"
+	"github.com/erauner/homelab-shadow/pkg/flux"
+)
+
+// ArgoCD source path rules
+const (
+	RuleArgoCDAppPathMissing   = "argocd-app-path-missing"
+	RuleArgoCDAppPathBuildFail = "argocd-app-path-build-fail"
+)
+
+// ValidateArgoCDSourcePaths checks that every Application source path (including
+// ApplicationSet-generated ones) is a directory in the repo with a kustomization,
+// and with build set, that it builds
+//
+// Sources pointing at a different repository than the local checkout's origin
+// are skipped; without an origin every path source is checked
+func (v *ClusterValidator) ValidateArgoCDSourcePaths(build bool) []Result {
+	results := []Result{}
+
+	apps, files, err := flux.LoadAllApplications(v.RepoPath)
+	if err != nil {
+		results = append(results, Result{
+			Cluster:  "global",
+			Rule:     "argocd-app-path-validation-error",
+			Path:     "argocd-apps/",
+			Message:  fmt.Sprintf("Failed to load Applications: %v", err),
+			Severity: "error",
+		})
+		return results
+	}
+
+	origin := normalizeRepoURL(gitOrigin(v.RepoPath))
+	built := make(map[string]error) // each path is built once even when shared by apps
+
+	for i, app := range apps {
+		relFile, _ := filepath.Rel(v.RepoPath, files[i])
+		relFile = filepath.ToSlash(relFile)
+
+		for _, source := range app.AllSources() {
+			if source.Path == "" {
+				continue
+			}
+			if origin != "" && source.RepoURL != "" && normalizeRepoURL(source.RepoURL) != origin {
+				v.Log.Debugf("skipping %s path %s: source repo %s is not the local checkout", app.Name, source.Path, source.RepoURL)
+				continue
+			}
+
+			sourcePath := cleanRelPath(source.Path)
+			fullPath := filepath.Join(v.RepoPath, filepath.FromSlash(sourcePath))
+
+			if info, err := os.Stat(fullPath); err != nil || !info.IsDir() {
+				results = append(results, Result{
+					Cluster:  "global",
+					Rule:     RuleArgoCDAppPathMissing,
+					Path:     relFile,
+					Message:  fmt.Sprintf("%s source path %q does not exist in the repo", app.KindName(), source.Path),
+					Severity: "error",
+				})
+				continue
+			}
+			if !hasKustomization(fullPath) {
+				if app.Kind == flux.KindKustomization {
+					continue // Flux generates a kustomization for plain manifest directories
+				}
+				results = append(results, Result{
+					Cluster:  "global",
+					Rule:     RuleArgoCDAppPathMissing,
+					Path:     relFile,
+					Message:  fmt.Sprintf("%s source path %q has no kustomization.yaml", app.KindName(), source.Path),
+					Severity: "error",
+				})
+				continue
+			}
+
+			if !build {
+				continue
+			}
+			buildErr, ok := built[sourcePath]
+			if !ok {
+				v.Log.Debugf("building %s for Application %s", sourcePath, app.Name)
+				buildErr = v.validateKustomizeBuild(fullPath)
+				built[sourcePath] = buildErr
+			}
+			if buildErr != nil {
+				results = append(results, Result{
+					Cluster:  "global",
+					Rule:     RuleArgoCDAppPathBuildFail,
+					Path:     relFile,
+					Message:  fmt.Sprintf("%s source path %q fails to build: %v", app.KindName(), source.Path, buildErr),
+					Severity: "error",
+				})
+			}
+		}
+	}
+
+	return results
+}
+
+// hasKustomization reports whether dir contains a kustomization file
+func hasKustomization(dir string) bool {
+	for _, name := range []string{"kustomization.yaml", "kustomization.yml", "Kustomization"} {
+		if fileExists(filepath.Join(dir, name)) {
+			return true
+		}
+	}
+	return false
+}
+
+// gitOrigin returns the origin remote URL of the repo, or "" if unknown
+func gitOrigin(repoPath string) string {
+	out, err := command.Command("git", "-C", repoPath, "config", "--get", "remote.origin.url").Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// normalizeRepoURL reduces SSH and HTTPS git URLs to host/owner/repo so
+// git@github.com:erauner/homelab-k8s.git matches https://github.com/erauner/homelab-k8s
+func normalizeRepoURL(url string) string {
+	url = strings.ToLower(strings.TrimSpace(url))
+	for _, prefix := range []string{"https://", "http://", "ssh://", "git://"} {
+		url = strings.TrimPrefix(url, prefix)
+	}
+	if at := strings.Index(url, "@"); at >= 0 {
+		url = url[at+1:]
+	}
+	// scp-like syntax (host:owner/repo) and ports (host:22/owner/repo)
+	if colon := strings.Index(url, ":"); colon >= 0 && !strings.Contains(url[:colon], "/") {
+		rest := url[colon+1:]
+		if slash := strings.Index(rest, "/"); slash > 0 && strings.Trim(rest[:slash], "0123456789") == "" {
+			rest = rest[slash+1:]
+		}
+		url = url[:colon] + "/" + rest
+	}
+	return strings.TrimSuffix(strings.TrimSuffix(url, "/"), ".git")
+}
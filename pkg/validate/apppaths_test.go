@@ -0,0 +1,110 @@
+package validate_test
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/erauner/homelab-shadow/pkg/validate"
+	"github.com/erauner/homelab-shadow/pkg/validate/validatetest"
+)
+
+func TestValidateArgoCDSourcePaths(t *testing.T) {
+	root := validatetest.Build(t, validatetest.Repo{
+		Dirs: []string{"apps/plain/overlays/erauner-home/production"},
+		Kustomizations: map[string]validatetest.Kustomization{
+			"apps/coder/overlays/erauner-home/production":  {},
+			"apps/broken/overlays/erauner-home/production": {},
+		},
+		Applications: []validatetest.Application{
+			{Name: "coder", Path: "apps/coder/overlays/erauner-home/production"},
+			{Name: "typo", Path: "apps/codr/overlays/erauner-home/production"},
+			{Name: "plain", Paths: []string{"apps/plain/overlays/erauner-home/production"}},
+			{Name: "broken", Path: "apps/broken/overlays/erauner-home/production"},
+		},
+	})
+
+	v := validate.NewClusterValidator(root, false)
+	validatetest.AssertFindings(t, v.ValidateArgoCDSourcePaths(false),
+		validatetest.Finding{Rule: validate.RuleArgoCDAppPathMissing, Path: "argocd-apps/applications/typo.yaml", Severity: "error"},
+		validatetest.Finding{Rule: validate.RuleArgoCDAppPathMissing, Path: "argocd-apps/applications/plain.yaml", Severity: "error"},
+	)
+
+	// Stub kustomize so only the broken overlay fails to build
+	bin := t.TempDir()
+	script := "#!/bin/sh\ncase \"$2\" in *broken*) echo 'Error: accumulating resources' >&2; exit 1;; esac\n"
+	if err := os.WriteFile(filepath.Join(bin, "kustomize"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	validatetest.AssertFindings(t, v.ValidateArgoCDSourcePaths(true),
+		validatetest.Finding{Rule: validate.RuleArgoCDAppPathMissing, Path: "argocd-apps/applications/typo.yaml"},
+		validatetest.Finding{Rule: validate.RuleArgoCDAppPathMissing, Path: "argocd-apps/applications/plain.yaml"},
+		validatetest.Finding{Rule: validate.RuleArgoCDAppPathBuildFail, Path: "argocd-apps/applications/broken.yaml", Severity: "error"},
+	)
+}
+
+func TestValidateArgoCDSourcePaths_SkipsOtherRepos(t *testing.T) {
+	root := validatetest.Build(t, validatetest.Repo{
+		Files: map[string]string{
+			"argocd-apps/applications/mixed.yaml": `apiVersion: argoproj.io/v1alpha1
+kind: Application
+metadata:
+  name: mixed
+spec:
+  sources:
+    - repoURL: https://github.com/erauner/homelab-k8s
+      path: apps/missing/overlays/erauner-home/production
+    - repoURL: https://github.com/example/charts.git
+      path: charts/app
+`,
+		},
+	})
+
+	cmd := exec.Command("sh", "-c", "git init -q && git remote add origin git@github.com:erauner/homelab-k8s.git")
+	cmd.Dir = root
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Skipf("git unavailable: %v: %s", err, out)
+	}
+
+	v := validate.NewClusterValidator(root, false)
+	validatetest.AssertFindings(t, v.ValidateArgoCDSourcePaths(false),
+		validatetest.Finding{Rule: validate.RuleArgoCDAppPathMissing, Path: "argocd-apps/applications/mixed.yaml"},
+	)
+}
+
+func TestValidateArgoCDSourcePaths_Flux(t *testing.T) {
+	root := validatetest.Build(t, validatetest.Repo{
+		Dirs: []string{"clusters/erauner-home/infrastructure"},
+		Files: map[string]string{
+			"clusters/erauner-home/flux-system/kustomizations.yaml": `apiVersion: kustomize.toolkit.fluxcd.io/v1
+kind: Kustomization
+metadata:
+  name: infrastructure
+spec:
+  path: ./clusters/erauner-home/infrastructure
+  sourceRef:
+    kind: GitRepository
+    name: flux-system
+---
+apiVersion: kustomize.toolkit.fluxcd.io/v1
+kind: Kustomization
+metadata:
+  name: typo
+spec:
+  path: ./clusters/erauner-home/infrastucture
+  sourceRef:
+    kind: GitRepository
+    name: flux-system
+`,
+		},
+	})
+
+	// Flux generates a kustomization for plain directories, so only the missing path is reported
+	v := validate.NewClusterValidator(root, false)
+	validatetest.AssertFindings(t, v.ValidateArgoCDSourcePaths(false),
+		validatetest.Finding{Rule: validate.RuleArgoCDAppPathMissing, Path: "clusters/erauner-home/flux-system/kustomizations.yaml", Severity: "error"},
+	)
+}
@@ -0,0 +1,162 @@
+package validate
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	RuleArgoCDIncludeMissing = "argocd-include-missing"
+	RuleArgoCDIncludeInvalid = "argocd-include-invalid"
+)
+
+// ArgoCDIncludePaths are the per-cluster kustomizations that assemble Applications
+var ArgoCDIncludePaths = []string{
+	"argocd/apps",
+	"argocd/operators",
+	"argocd/security",
+	"argocd/infrastructure",
+}
+
+// argoCDKinds are the kinds an argocd/ kustomization may include
+var argoCDKinds = map[string]bool{
+	"Application":    true,
+	"ApplicationSet": true,
+	"AppProject":     true,
+}
+
+// ValidateArgoCDIncludes checks that every file included by a cluster's
+// argocd/ kustomizations exists and holds ArgoCD Applications
+// Dangling includes are left behind by app deletions and otherwise only
+// surface as a kustomize build failure
+func (v *ClusterValidator) ValidateArgoCDIncludes(cluster string) []Result {
+	results := []Result{}
+	clusterPath := filepath.Join(v.RepoPath, "clusters", cluster)
+	for _, kpath := range ArgoCDIncludePaths {
+		if !hasKustomization(filepath.Join(clusterPath, kpath)) {
+			continue
+		}
+		results = append(results, v.validateIncludes(cluster, clusterPath, kpath, map[string]bool{})...)
+	}
+	return results
+}
+
+// validateIncludes checks the includes of the kustomization in dir (relative
+// to clusterPath), descending into included directories
+func (v *ClusterValidator) validateIncludes(cluster, clusterPath, dir string, seen map[string]bool) []Result {
+	if seen[dir] {
+		return nil
+	}
+	seen[dir] = true
+
+	data, err := readKustomization(filepath.Join(clusterPath, filepath.FromSlash(dir)))
+	if err != nil {
+		return nil
+	}
+	var k KustomizationFile
+	if err := yaml.Unmarshal(data, &k); err != nil {
+		return []Result{{
+			Cluster:  cluster,
+			Rule:     RuleArgoCDIncludeInvalid,
+			Path:     dir,
+			Message:  fmt.Sprintf("Invalid kustomization: %v", err),
+			Severity: "error",
+		}}
+	}
+
+	var results []Result
+	refs := append(append([]string(nil), k.Resources...), k.Bases...)
+	for _, ref := range refs {
+		if strings.Contains(ref, "://") || strings.HasPrefix(ref, "github.com/") {
+			continue // remote resource
+		}
+		target := cleanRelPath(filepath.ToSlash(filepath.Join(dir, ref)))
+		fullPath := filepath.Join(clusterPath, filepath.FromSlash(target))
+
+		info, err := os.Stat(fullPath)
+		if err != nil {
+			results = append(results, Result{
+				Cluster:  cluster,
+				Rule:     RuleArgoCDIncludeMissing,
+				Path:     target,
+				Message:  fmt.Sprintf("%s includes %q, which does not exist - remove it from the kustomization if the app was deleted", dir, ref),
+				Severity: "error",
+			})
+			continue
+		}
+
+		if info.IsDir() {
+			if !hasKustomization(fullPath) {
+				results = append(results, Result{
+					Cluster:  cluster,
+					Rule:     RuleArgoCDIncludeMissing,
+					Path:     target,
+					Message:  fmt.Sprintf("%s includes directory %q, which has no kustomization", dir, ref),
+					Severity: "error",
+				})
+				continue
+			}
+			results = append(results, v.validateIncludes(cluster, clusterPath, target, seen)...)
+			continue
+		}
+
+		if err := checkApplicationFile(fullPath); err != nil {
+			results = append(results, Result{
+				Cluster:  cluster,
+				Rule:     RuleArgoCDIncludeInvalid,
+				Path:     target,
+				Message:  fmt.Sprintf("%s includes %q: %v", dir, ref, err),
+				Severity: "error",
+			})
+		}
+	}
+	return results
+}
+
+// checkApplicationFile verifies that path parses as YAML and every document
+// in it is an ArgoCD Application, ApplicationSet, or AppProject
+func checkApplicationFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	docs := 0
+	for {
+		var doc struct {
+			APIVersion string `yaml:"apiVersion"`
+			Kind       string `yaml:"kind"`
+			Metadata   struct {
+				Name string `yaml:"name"`
+			} `yaml:"metadata"`
+		}
+		if err := dec.Decode(&doc); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return fmt.Errorf("invalid YAML: %w", err)
+		}
+		if doc.APIVersion == "" && doc.Kind == "" {
+			continue // empty document
+		}
+		docs++
+		if !strings.HasPrefix(doc.APIVersion, "argoproj.io/") || !argoCDKinds[doc.Kind] {
+			return fmt.Errorf("expected an ArgoCD Application, got kind %q (apiVersion %q)", doc.Kind, doc.APIVersion)
+		}
+		if doc.Metadata.Name == "" {
+			return fmt.Errorf("%s has no metadata.name", doc.Kind)
+		}
+	}
+	if docs == 0 {
+		return fmt.Errorf("file contains no resources")
+	}
+	return nil
+}
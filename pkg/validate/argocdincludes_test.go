@@ -0,0 +1,48 @@
+package validate_test
+
+import (
+	"testing"
+
+	"github.com/erauner/homelab-shadow/pkg/validate"
+	"github.com/erauner/homelab-shadow/pkg/validate/validatetest"
+)
+
+const includeApp = `apiVersion: argoproj.io/v1alpha1
+kind: Application
+metadata:
+  name: coder
+`
+
+func TestValidateArgoCDIncludes(t *testing.T) {
+	root := validatetest.Build(t, validatetest.Repo{
+		Clusters: []string{"erauner-home"},
+		Kustomizations: map[string]validatetest.Kustomization{
+			"clusters/erauner-home/argocd/apps": {Resources: []string{
+				"coder.yaml",
+				"deleted.yaml",
+				"media",
+				"empty",
+				"configmap.yaml",
+				"https://example.com/remote.yaml",
+			}},
+			"clusters/erauner-home/argocd/apps/media": {Resources: []string{"media.yaml", "gone.yaml"}},
+			"clusters/erauner-home/argocd/security":   {Resources: []string{"broken.yaml"}},
+		},
+		Dirs: []string{"clusters/erauner-home/argocd/apps/empty"},
+		Files: map[string]string{
+			"clusters/erauner-home/argocd/apps/coder.yaml":       includeApp,
+			"clusters/erauner-home/argocd/apps/media/media.yaml": includeApp + "---\n" + includeApp,
+			"clusters/erauner-home/argocd/apps/configmap.yaml":   "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: x\n",
+			"clusters/erauner-home/argocd/security/broken.yaml":  "kind: [Application\n",
+		},
+	})
+
+	v := validate.NewClusterValidator(root, false)
+	validatetest.AssertFindings(t, v.ValidateArgoCDIncludes("erauner-home"),
+		validatetest.Finding{Rule: validate.RuleArgoCDIncludeMissing, Path: "argocd/apps/deleted.yaml", Cluster: "erauner-home"},
+		validatetest.Finding{Rule: validate.RuleArgoCDIncludeMissing, Path: "argocd/apps/media/gone.yaml", Cluster: "erauner-home"},
+		validatetest.Finding{Rule: validate.RuleArgoCDIncludeMissing, Path: "argocd/apps/empty", Cluster: "erauner-home"},
+		validatetest.Finding{Rule: validate.RuleArgoCDIncludeInvalid, Path: "argocd/apps/configmap.yaml", Cluster: "erauner-home"},
+		validatetest.Finding{Rule: validate.RuleArgoCDIncludeInvalid, Path: "argocd/security/broken.yaml", Cluster: "erauner-home"},
+	)
+}
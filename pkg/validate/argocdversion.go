@@ -0,0 +1,169 @@
+package validate
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/erauner/homelab-shadow/pkg/argocd"
+	"github.com/erauner/homelab-shadow/pkg/cluster"
+	"gopkg.in/yaml.v3"
+)
+
+// RuleArgoCDFeatureUnsupported flags Application spec features newer than the
+// ArgoCD version configured for the target cluster
+const RuleArgoCDFeatureUnsupported = "argocd-feature-unsupported"
+
+// ValidateArgoCDVersions checks Applications (and ApplicationSet templates)
+// against the argocdVersion configured per cluster in clusters.yaml
+//
+// The target cluster is spec.destination.name when it is registered (or the
+// cluster whose server is spec.destination.server), otherwise the <cluster> segment of the app's source paths; Applications matching
+// neither are checked against every cluster with a configured version
+func (v *ClusterValidator) ValidateArgoCDVersions() []Result {
+	results := []Result{}
+
+	registry, err := v.clusterRegistry()
+	if err != nil {
+		return results // reported by ValidateClusterNames
+	}
+
+	versioned := make(map[string]string)
+	var versionedNames []string
+	for _, c := range registry.Clusters() {
+		if c.ArgoCDVersion == "" {
+			continue
+		}
+		if _, err := argocd.ParseMinorVersion(c.ArgoCDVersion); err != nil {
+			results = append(results, Result{
+				Cluster:  c.Name,
+				Rule:     "argocd-version-invalid",
+				Path:     cluster.RegistryFile,
+				Message:  err.Error(),
+				Severity: "error",
+			})
+			continue
+		}
+		versioned[c.Name] = c.ArgoCDVersion
+		versionedNames = append(versionedNames, c.Name)
+	}
+	if len(versioned) == 0 {
+		return results
+	}
+
+	files, err := argocd.DiscoverApplications(v.RepoPath)
+	if err != nil {
+		return results // reported by ValidateArgoCDSourcePaths
+	}
+
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			continue
+		}
+		relPath, _ := filepath.Rel(v.RepoPath, file)
+		relPath = filepath.ToSlash(relPath)
+
+		decoder := yaml.NewDecoder(strings.NewReader(string(data)))
+		for {
+			var doc map[string]interface{}
+			if err := decoder.Decode(&doc); err != nil {
+				break // end of documents; malformed files are reported elsewhere
+			}
+
+			name, spec := applicationSpec(doc)
+			if spec == nil {
+				continue
+			}
+			used := argocd.UsedFeatures(spec)
+			if len(used) == 0 {
+				continue
+			}
+
+			targets := targetClusters(registry, spec)
+			if len(targets) == 0 {
+				targets = versionedNames
+			}
+			for _, c := range targets {
+				version, ok := versioned[c]
+				if !ok {
+					continue
+				}
+				for _, f := range used {
+					if supported, _ := argocd.VersionSupports(version, f.Since); supported {
+						continue
+					}
+					results = append(results, Result{
+						Cluster:  c,
+						Rule:     RuleArgoCDFeatureUnsupported,
+						Path:     relPath,
+						Message:  fmt.Sprintf("%s uses %s (ArgoCD %s+), but %s runs ArgoCD %s", name, f.Name, f.Since, c, version),
+						Severity: "error",
+					})
+				}
+			}
+		}
+	}
+
+	return results
+}
+
+// applicationSpec returns the name and Application spec of a document:
+// spec for Applications, spec.template.spec for ApplicationSets
+func applicationSpec(doc map[string]interface{}) (string, map[string]interface{}) {
+	kind, _ := doc["kind"].(string)
+	metadata, _ := doc["metadata"].(map[string]interface{})
+	name, _ := metadata["name"].(string)
+	spec, _ := doc["spec"].(map[string]interface{})
+
+	switch kind {
+	case "Application":
+		return "Application " + name, spec
+	case "ApplicationSet":
+		template, _ := spec["template"].(map[string]interface{})
+		templateSpec, _ := template["spec"].(map[string]interface{})
+		return "ApplicationSet " + name, templateSpec
+	default:
+		return "", nil
+	}
+}
+
+// targetClusters returns the registered clusters an Application spec deploys to
+func targetClusters(registry *cluster.Registry, spec map[string]interface{}) []string {
+	destination, _ := spec["destination"].(map[string]interface{})
+	if name, _ := destination["name"].(string); registry.Has(name) {
+		return []string{name}
+	}
+	if server, _ := destination["server"].(string); destination["name"] == nil {
+		if c, ok := registry.ByServer(server); ok {
+			return []string{c.Name}
+		}
+	}
+
+	var paths []string
+	if source, ok := spec["source"].(map[string]interface{}); ok {
+		if path, _ := source["path"].(string); path != "" {
+			paths = append(paths, path)
+		}
+	}
+	sources, _ := spec["sources"].([]interface{})
+	for _, item := range sources {
+		source, _ := item.(map[string]interface{})
+		if path, _ := source["path"].(string); path != "" {
+			paths = append(paths, path)
+		}
+	}
+
+	var clusters []string
+	seen := make(map[string]bool)
+	for _, path := range paths {
+		p, ok := registry.ParseAppPath(strings.TrimPrefix(path, "./"))
+		if !ok || p.Cluster == "" || !registry.Has(p.Cluster) || seen[p.Cluster] {
+			continue
+		}
+		seen[p.Cluster] = true
+		clusters = append(clusters, p.Cluster)
+	}
+	return clusters
+}
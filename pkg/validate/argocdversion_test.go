@@ -0,0 +1,70 @@
+package validate_test
+
+import (
+	"testing"
+
+	"github.com/erauner/homelab-shadow/pkg/validate"
+	"github.com/erauner/homelab-shadow/pkg/validate/validatetest"
+)
+
+func TestValidateArgoCDVersions(t *testing.T) {
+	root := validatetest.Build(t, validatetest.Repo{
+		Files: map[string]string{
+			"clusters.yaml": `clusters:
+  - name: erauner-home
+    argocdVersion: v2.7.14
+  - name: erauner-cloud
+    argocdVersion: "2.10"
+  - name: legacy
+    argocdVersion: latest
+`,
+			"argocd-apps/applications/krr.yaml": `apiVersion: argoproj.io/v1alpha1
+kind: Application
+metadata:
+  name: krr
+spec:
+  sources:
+    - repoURL: https://bjw-s-labs.github.io/helm-charts
+      chart: app-template
+      helm:
+        valuesObject:
+          replicas: 1
+    - repoURL: git@github.com:erauner/homelab-k8s.git
+      path: apps/krr/overlays/erauner-home/production
+`,
+			"argocd-apps/applications/cloud.yaml": `apiVersion: argoproj.io/v1alpha1
+kind: Application
+metadata:
+  name: cloud
+spec:
+  destination:
+    name: erauner-cloud
+  source:
+    path: apps/cloud/overlays/erauner-cloud/production
+    kustomize:
+      patches: []
+`,
+			"argocd-apps/applications/shared.yaml": `apiVersion: argoproj.io/v1alpha1
+kind: ApplicationSet
+metadata:
+  name: shared
+spec:
+  generators: []
+  template:
+    spec:
+      source:
+        path: apps/shared/overlays/{{cluster}}/production
+      syncPolicy:
+        syncOptions:
+          - ServerSideApply=true
+`,
+		},
+	})
+
+	v := validate.NewClusterValidator(root, false)
+	validatetest.AssertFindings(t, v.ValidateArgoCDVersions(),
+		validatetest.Finding{Rule: "argocd-version-invalid", Cluster: "legacy", Path: "clusters.yaml"},
+		// valuesObject is 2.8+; multi-source and ref sources are fine on 2.7
+		validatetest.Finding{Rule: validate.RuleArgoCDFeatureUnsupported, Cluster: "erauner-home", Path: "argocd-apps/applications/krr.yaml"},
+	)
+}
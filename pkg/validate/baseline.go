@@ -0,0 +1,113 @@
+package validate
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+)
+
+// BaselineVersion is the current baseline file format version
+const BaselineVersion = 1
+
+// Baseline is a snapshot of known findings that should not fail validation
+// Findings match on rule, cluster, path, and message; severity and owner are ignored
+// so overrides and ownership changes don't invalidate the baseline
+type Baseline struct {
+	Version  int             `json:"version"`
+	Findings []BaselineEntry `json:"findings"`
+}
+
+// BaselineEntry is a single grandfathered finding
+type BaselineEntry struct {
+	Rule    string `json:"rule"`
+	Cluster string `json:"cluster,omitempty"`
+	Path    string `json:"path,omitempty"`
+	Message string `json:"message"`
+}
+
+func baselineKey(r Result) BaselineEntry {
+	return BaselineEntry{Rule: r.Rule, Cluster: r.Cluster, Path: r.Path, Message: r.Message}
+}
+
+// NewBaseline snapshots the unsuppressed findings in results
+func NewBaseline(results []Result) *Baseline {
+	b := &Baseline{Version: BaselineVersion, Findings: []BaselineEntry{}}
+	for _, r := range results {
+		if !r.Suppressed {
+			b.Findings = append(b.Findings, baselineKey(r))
+		}
+	}
+	sort.Slice(b.Findings, func(i, j int) bool {
+		a, c := b.Findings[i], b.Findings[j]
+		if a.Rule != c.Rule {
+			return a.Rule < c.Rule
+		}
+		if a.Path != c.Path {
+			return a.Path < c.Path
+		}
+		if a.Cluster != c.Cluster {
+			return a.Cluster < c.Cluster
+		}
+		return a.Message < c.Message
+	})
+	return b
+}
+
+// WriteBaseline writes a baseline of results to path
+func WriteBaseline(path string, results []Result) (*Baseline, error) {
+	b := NewBaseline(results)
+	data, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal baseline: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return nil, fmt.Errorf("failed to write baseline: %w", err)
+	}
+	return b, nil
+}
+
+// LoadBaseline reads a baseline file written by WriteBaseline
+func LoadBaseline(path string) (*Baseline, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read baseline: %w", err)
+	}
+	var b Baseline
+	if err := json.Unmarshal(data, &b); err != nil {
+		return nil, fmt.Errorf("failed to parse baseline %s: %w", path, err)
+	}
+	if b.Version != BaselineVersion {
+		return nil, fmt.Errorf("baseline %s: unsupported version %d (expected %d)", path, b.Version, BaselineVersion)
+	}
+	return &b, nil
+}
+
+// Apply marks findings present in the baseline as suppressed by source and
+// returns the number of baseline entries that no longer occur (fixed findings)
+// Each entry grandfathers one finding, so a new duplicate of a known finding still fails
+func (b *Baseline) Apply(results []Result, source string) int {
+	remaining := make(map[BaselineEntry]int)
+	for _, e := range b.Findings {
+		remaining[e]++
+	}
+
+	for i := range results {
+		r := &results[i]
+		if r.Suppressed {
+			continue
+		}
+		key := baselineKey(*r)
+		if remaining[key] > 0 {
+			remaining[key]--
+			r.Suppressed = true
+			r.SuppressedBy = "baseline: " + source
+		}
+	}
+
+	stale := 0
+	for _, n := range remaining {
+		stale += n
+	}
+	return stale
+}
@@ -0,0 +1,79 @@
+package validate
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBaseline_RoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "baseline.json")
+	existing := []Result{
+		{Rule: "app-overlay-legacy-flat", Cluster: "home", Path: "apps/web/overlays/production", Message: "legacy flat overlay", Severity: "warn"},
+		{Rule: "app-overlay-legacy-flat", Cluster: "home", Path: "apps/api/overlays/production", Message: "legacy flat overlay", Severity: "warn"},
+		{Rule: RuleImageTagLatest, Path: "apps/web", Message: "suppressed already", Severity: "warn", Suppressed: true, SuppressedBy: "config: rules.image-tag-latest"},
+	}
+
+	written, err := WriteBaseline(path, existing)
+	if err != nil {
+		t.Fatalf("WriteBaseline() error = %v", err)
+	}
+	if len(written.Findings) != 2 {
+		t.Fatalf("expected suppressed findings to be left out of the baseline, got %+v", written.Findings)
+	}
+	if written.Findings[0].Path != "apps/api/overlays/production" {
+		t.Errorf("expected findings sorted by path, got %+v", written.Findings)
+	}
+
+	baseline, err := LoadBaseline(path)
+	if err != nil {
+		t.Fatalf("LoadBaseline() error = %v", err)
+	}
+
+	// apps/api was fixed; apps/web persists (now an error); apps/new is a new finding
+	current := []Result{
+		{Rule: "app-overlay-legacy-flat", Cluster: "home", Path: "apps/web/overlays/production", Message: "legacy flat overlay", Severity: "error"},
+		{Rule: "app-overlay-legacy-flat", Cluster: "home", Path: "apps/new/overlays/production", Message: "legacy flat overlay", Severity: "warn"},
+	}
+	stale := baseline.Apply(current, "baseline.json")
+
+	if stale != 1 {
+		t.Errorf("expected 1 fixed baseline entry, got %d", stale)
+	}
+	if !current[0].Suppressed || current[0].SuppressedBy != "baseline: baseline.json" {
+		t.Errorf("expected known finding to be baselined, got %+v", current[0])
+	}
+	if current[1].Suppressed {
+		t.Errorf("expected new finding to stay active, got %+v", current[1])
+	}
+	if CountErrors(current) != 0 || CountWarnings(current) != 1 {
+		t.Errorf("expected only the new finding to count, got %d error(s), %d warning(s)", CountErrors(current), CountWarnings(current))
+	}
+}
+
+func TestBaseline_DuplicatesAreCounted(t *testing.T) {
+	finding := Result{Rule: "namespace-duplicate", Path: "apps/web", Message: "duplicate namespace web"}
+	baseline := NewBaseline([]Result{finding})
+
+	current := []Result{finding, finding}
+	baseline.Apply(current, "baseline.json")
+
+	if CountSuppressed(current) != 1 {
+		t.Errorf("expected one baseline entry to grandfather one finding, got %d suppressed", CountSuppressed(current))
+	}
+}
+
+func TestLoadBaseline_Errors(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := LoadBaseline(filepath.Join(dir, "missing.json")); err == nil {
+		t.Error("expected error for missing baseline")
+	}
+
+	path := filepath.Join(dir, "future.json")
+	if err := os.WriteFile(path, []byte(`{"version": 99, "findings": []}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadBaseline(path); err == nil {
+		t.Error("expected error for unsupported version")
+	}
+}
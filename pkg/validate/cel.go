@@ -0,0 +1,54 @@
+//go:build cel
+
+package validate
+
+import (
+	"fmt"
+
+	"github.com/google/cel-go/cel"
+)
+
+// CELAvailable reports whether this binary was built with CEL rule support
+const CELAvailable = true
+
+// celEnv declares the variables rule expressions see
+var celEnv, celEnvErr = cel.NewEnv(
+	cel.Variable("object", cel.DynType),
+	cel.Variable("path", cel.StringType),
+)
+
+// compileCEL compiles a rule expression, which must evaluate to a bool
+func compileCEL(expression string) (celProgram, error) {
+	if celEnvErr != nil {
+		return nil, celEnvErr
+	}
+	ast, issues := celEnv.Compile(expression)
+	if issues.Err() != nil {
+		return nil, fmt.Errorf("invalid expression: %w", issues.Err())
+	}
+	if t := ast.OutputType(); t != cel.BoolType && t != cel.DynType {
+		return nil, fmt.Errorf("expression returns %s, want bool", t)
+	}
+	program, err := celEnv.Program(ast)
+	if err != nil {
+		return nil, fmt.Errorf("invalid expression: %w", err)
+	}
+	return celExpression{program}, nil
+}
+
+// celExpression is a compiled rule expression
+type celExpression struct {
+	program cel.Program
+}
+
+func (e celExpression) Eval(object map[string]interface{}, path string) (bool, error) {
+	out, _, err := e.program.Eval(map[string]interface{}{"object": object, "path": path})
+	if err != nil {
+		return false, err
+	}
+	ok, isBool := out.Value().(bool)
+	if !isBool {
+		return false, fmt.Errorf("expression returned %v, want bool", out.Value())
+	}
+	return ok, nil
+}
@@ -0,0 +1,13 @@
+//go:build !cel
+
+package validate
+
+import "fmt"
+
+// CELAvailable reports whether this binary was built with CEL rule support
+const CELAvailable = false
+
+// compileCEL is a stub used when the binary is built without -tags cel
+func compileCEL(expression string) (celProgram, error) {
+	return nil, fmt.Errorf("CEL rules are not available in this build (rebuild with -tags cel)")
+}
@@ -0,0 +1,47 @@
+//go:build cel
+
+package validate
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCompileCEL(t *testing.T) {
+	deployment := map[string]interface{}{
+		"kind": "Deployment",
+		"metadata": map[string]interface{}{
+			"name":   "jellyfin",
+			"labels": map[string]interface{}{"team": "media"},
+		},
+		"spec": map[string]interface{}{"replicas": 2},
+	}
+
+	tests := map[string]bool{
+		`has(object.metadata.labels) && "team" in object.metadata.labels`: true,
+		`object.spec.replicas >= 3`:                                       false,
+		`path.startsWith("apps/")`:                                        true,
+		`object.metadata.labels.team == "media"`:                          true,
+	}
+	for expression, want := range tests {
+		program, err := compileCEL(expression)
+		if err != nil {
+			t.Fatalf("compileCEL(%q) error = %v", expression, err)
+		}
+		got, err := program.Eval(deployment, "apps/jellyfin/overlays/erauner-home/production")
+		if err != nil || got != want {
+			t.Errorf("Eval(%q) = %v, %v, want %v", expression, got, err, want)
+		}
+	}
+
+	program, _ := compileCEL(`object.metadata.annotations.owner == "x"`)
+	if _, err := program.Eval(deployment, ""); err == nil {
+		t.Error("Eval() of a missing key should fail")
+	}
+	if _, err := compileCEL(`object.metadata.name +`); err == nil || !strings.Contains(err.Error(), "invalid expression") {
+		t.Errorf("compileCEL() of a syntax error = %v", err)
+	}
+	if _, err := compileCEL(`"a string"`); err == nil {
+		t.Error("compileCEL() of a non-bool expression should fail")
+	}
+}
@@ -0,0 +1,223 @@
+package validate
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/erauner/homelab-shadow/pkg/argocd"
+	"github.com/erauner/homelab-shadow/pkg/config"
+	"gopkg.in/yaml.v3"
+)
+
+// celProgram is a compiled CEL rule expression
+type celProgram interface {
+	// Eval evaluates the expression with object and path bound
+	Eval(object map[string]interface{}, path string) (bool, error)
+}
+
+// celRule evaluates a CEL rule from .shadow.yaml against every object of
+// its target; each object the expression is false for is a finding
+type celRule struct {
+	cfg     config.CELRule
+	program celProgram
+}
+
+// CELRules compiles the CEL rules declared in cfg
+func CELRules(cfg *config.Config) ([]Rule, error) {
+	var rules []Rule
+	for _, r := range cfg.CELRules {
+		program, err := compileCEL(r.Expression)
+		if err != nil {
+			return nil, fmt.Errorf("celRules %s: %w", r.Name, err)
+		}
+		rules = append(rules, celRule{cfg: r, program: program})
+	}
+	return rules, nil
+}
+
+func (r celRule) Name() string { return r.cfg.Name }
+
+func (r celRule) Severity() string {
+	if r.cfg.Severity != "" {
+		return r.cfg.Severity
+	}
+	return config.SeverityError
+}
+
+func (r celRule) Run(ctx RepoContext) []Result {
+	results := []Result{}
+	objects, err := r.objects(ctx)
+	if err != nil {
+		return append(results, Result{Path: ".", Message: fmt.Sprintf("Failed to load %s objects: %v", r.target(), err)})
+	}
+
+	registry, _ := ctx.Validator.clusterRegistry()
+	for _, obj := range objects {
+		if !r.matches(obj.doc) {
+			continue
+		}
+		ok, err := r.program.Eval(obj.doc, obj.path)
+		if ok {
+			continue
+		}
+		message := r.cfg.Message
+		if message == "" {
+			message = "expression is false: " + r.cfg.Expression
+		}
+		if err != nil {
+			message = fmt.Sprintf("failed to evaluate: %v", err)
+		}
+		finding := Result{Path: obj.path, Message: fmt.Sprintf("%s: %s", describeObject(obj.doc), message)}
+		if registry != nil {
+			finding.Cluster = fileCluster(registry, obj.path)
+		}
+		results = append(results, finding)
+	}
+	return results
+}
+
+func (r celRule) target() string {
+	if r.cfg.Target == "" {
+		return config.CELTargetResource
+	}
+	return r.cfg.Target
+}
+
+// matches reports whether doc is of a kind and namespace the rule matches
+func (r celRule) matches(doc map[string]interface{}) bool {
+	kind, _ := doc["kind"].(string)
+	if len(r.cfg.Match.Kinds) > 0 && !slices.Contains(r.cfg.Match.Kinds, kind) {
+		return false
+	}
+	if len(r.cfg.Match.Namespaces) > 0 {
+		metadata, _ := doc["metadata"].(map[string]interface{})
+		namespace, _ := metadata["namespace"].(string)
+		return slices.Contains(r.cfg.Match.Namespaces, namespace)
+	}
+	return true
+}
+
+// celObject is a parsed YAML document and the repo-relative path it came from
+type celObject struct {
+	path string
+	doc  map[string]interface{}
+}
+
+// objects loads the documents of the rule's target
+func (r celRule) objects(ctx RepoContext) ([]celObject, error) {
+	var objects []celObject
+	switch r.target() {
+	case config.CELTargetKustomization:
+		err := filepath.WalkDir(ctx.RepoPath, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				if path != ctx.RepoPath && strings.HasPrefix(d.Name(), ".") {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if !slices.Contains([]string{"kustomization.yaml", "kustomization.yml", "Kustomization"}, d.Name()) {
+				return nil
+			}
+			return appendDocuments(&objects, ctx.RepoPath, path, func(doc map[string]interface{}) bool { return true })
+		})
+		return objects, err
+
+	case config.CELTargetApplication:
+		files, err := argocd.DiscoverApplications(ctx.RepoPath)
+		if err != nil {
+			return nil, err
+		}
+		for _, path := range files {
+			err := appendDocuments(&objects, ctx.RepoPath, path, func(doc map[string]interface{}) bool {
+				return doc["kind"] == "Application" || doc["kind"] == "ApplicationSet"
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+		return objects, nil
+
+	default:
+		var parseErr error
+		err := ctx.Validator.renderSourcePaths(func(sourcePath, manifest string) {
+			docs, err := decodeDocuments(manifest)
+			if err != nil && parseErr == nil {
+				parseErr = fmt.Errorf("%s: %w", sourcePath, err)
+			}
+			for _, doc := range docs {
+				objects = append(objects, celObject{path: sourcePath, doc: doc})
+			}
+		})
+		if err == nil {
+			err = parseErr
+		}
+		return objects, err
+	}
+}
+
+// appendDocuments appends the documents of the file at path that keep accepts
+func appendDocuments(objects *[]celObject, repoPath, path string, keep func(map[string]interface{}) bool) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	rel, err := filepath.Rel(repoPath, path)
+	if err != nil {
+		return err
+	}
+	docs, err := decodeDocuments(string(data))
+	if err != nil {
+		return fmt.Errorf("%s: %w", filepath.ToSlash(rel), err)
+	}
+	for _, doc := range docs {
+		if keep(doc) {
+			*objects = append(*objects, celObject{path: filepath.ToSlash(rel), doc: doc})
+		}
+	}
+	return nil
+}
+
+// decodeDocuments parses every non-empty document of a multi-document YAML stream
+func decodeDocuments(data string) ([]map[string]interface{}, error) {
+	var docs []map[string]interface{}
+	dec := yaml.NewDecoder(strings.NewReader(data))
+	for {
+		var doc map[string]interface{}
+		err := dec.Decode(&doc)
+		if errors.Is(err, io.EOF) {
+			return docs, nil
+		}
+		if err != nil {
+			return docs, err
+		}
+		if doc != nil {
+			docs = append(docs, doc)
+		}
+	}
+}
+
+// describeObject names a document "Kind namespace/name" (or "Kind name")
+func describeObject(doc map[string]interface{}) string {
+	kind, _ := doc["kind"].(string)
+	metadata, _ := doc["metadata"].(map[string]interface{})
+	name, _ := metadata["name"].(string)
+	if namespace, _ := metadata["namespace"].(string); namespace != "" {
+		name = namespace + "/" + name
+	}
+	if kind == "" {
+		return name
+	}
+	if name == "" {
+		return kind
+	}
+	return kind + " " + name
+}
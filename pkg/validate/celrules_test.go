@@ -0,0 +1,106 @@
+package validate
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/erauner/homelab-shadow/pkg/config"
+)
+
+// labelProgram passes objects that have a team label
+type labelProgram struct{}
+
+func (labelProgram) Eval(object map[string]interface{}, path string) (bool, error) {
+	metadata, _ := object["metadata"].(map[string]interface{})
+	labels, _ := metadata["labels"].(map[string]interface{})
+	_, ok := labels["team"]
+	return ok, nil
+}
+
+func writeRepoFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	repo := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(repo, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return repo
+}
+
+func TestCELRule_Targets(t *testing.T) {
+	repo := writeRepoFiles(t, map[string]string{
+		"clusters.yaml": "clusters: [erauner-home]\n",
+		"apps/coder/overlays/erauner-home/production/kustomization.yaml": "apiVersion: kustomize.config.k8s.io/v1beta1\nkind: Kustomization\nresources: [../../../base]\n",
+		"apps/web/base/kustomization.yaml":                               "kind: Kustomization\nmetadata:\n  labels:\n    team: web\n",
+		".git/kustomization.yaml":                                        "kind: Kustomization\n",
+		"argocd-apps/applications/coder.yaml": `apiVersion: argoproj.io/v1alpha1
+kind: Application
+metadata:
+  name: coder
+  namespace: argocd
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: not-an-app
+`,
+	})
+	ctx := RepoContext{Validator: NewClusterValidator(repo, false), RepoPath: repo}
+
+	kustomizations := celRule{cfg: config.CELRule{Name: "team-label", Target: config.CELTargetKustomization, Message: "needs a team label"}, program: labelProgram{}}
+	got := RunRule(kustomizations, ctx)
+	if len(got) != 1 {
+		t.Fatalf("Run() = %v, want one finding", got)
+	}
+	want := Result{Cluster: "erauner-home", Rule: "team-label", Path: "apps/coder/overlays/erauner-home/production/kustomization.yaml",
+		Message: "Kustomization: needs a team label", Severity: "error"}
+	if got[0] != want {
+		t.Errorf("finding = %+v, want %+v", got[0], want)
+	}
+
+	apps := celRule{cfg: config.CELRule{Name: "app-team", Target: config.CELTargetApplication, Expression: "x", Severity: "warn"}, program: labelProgram{}}
+	got = RunRule(apps, ctx)
+	if len(got) != 1 || got[0].Path != "argocd-apps/applications/coder.yaml" || got[0].Severity != "warn" || got[0].Cluster != "global" {
+		t.Fatalf("Run() = %+v, want one warning for the Application", got)
+	}
+	if got[0].Message != "Application argocd/coder: expression is false: x" {
+		t.Errorf("message = %q", got[0].Message)
+	}
+}
+
+func TestCELRule_Matches(t *testing.T) {
+	r := celRule{cfg: config.CELRule{Match: config.CELMatch{Kinds: []string{"Deployment"}, Namespaces: []string{"media"}}}}
+	doc := func(kind, namespace string) map[string]interface{} {
+		return map[string]interface{}{"kind": kind, "metadata": map[string]interface{}{"namespace": namespace}}
+	}
+	if !r.matches(doc("Deployment", "media")) {
+		t.Error("matches() = false for a Deployment in media")
+	}
+	if r.matches(doc("StatefulSet", "media")) || r.matches(doc("Deployment", "default")) || r.matches(map[string]interface{}{"kind": "Deployment"}) {
+		t.Error("matches() = true for an object of another kind or namespace")
+	}
+	if !(celRule{}).matches(doc("Service", "")) {
+		t.Error("a rule without match should match every object")
+	}
+}
+
+func TestCELRules_Compile(t *testing.T) {
+	cfg := &config.Config{CELRules: []config.CELRule{{Name: "team-label", Expression: `"team" in object.metadata.labels`}}}
+	rules, err := CELRules(cfg)
+	if !CELAvailable {
+		if err == nil || !strings.Contains(err.Error(), "-tags cel") {
+			t.Errorf("CELRules() without CEL support error = %v", err)
+		}
+		return
+	}
+	if err != nil || len(rules) != 1 || rules[0].Name() != "team-label" {
+		t.Errorf("CELRules() = %v, %v", rules, err)
+	}
+}
@@ -0,0 +1,171 @@
+package validate
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/erauner/homelab-shadow/pkg/flux"
+	"gopkg.in/yaml.v3"
+)
+
+// RuleChartVersionDrift flags a chart pinned at different versions in different places
+const RuleChartVersionDrift = "chart-version-drift"
+
+// Chart rendering mechanisms
+const (
+	ChartMechanismApplication = "application" // ArgoCD Application (or Flux HelmRelease) Helm source
+	ChartMechanismKustomize   = "kustomize"   // kustomization helmCharts entry
+)
+
+// ChartUsage is one place a Helm chart is rendered from
+type ChartUsage struct {
+	Chart     string `json:"chart"`
+	RepoURL   string `json:"repo_url"`
+	Version   string `json:"version"`
+	Mechanism string `json:"mechanism"`
+	Source    string `json:"source"` // Application name or kustomization directory
+	Path      string `json:"path"`   // file declaring the chart
+}
+
+// key identifies the chart regardless of how its repo URL is spelled
+func (u ChartUsage) key() string {
+	repo := strings.ToLower(strings.TrimSuffix(strings.TrimPrefix(u.RepoURL, "oci://"), "/"))
+	return repo + "|" + u.Chart
+}
+
+// ChartUsages lists every Helm chart rendered by Application sources and by
+// kustomization helmCharts, sorted by chart then path
+func (v *ClusterValidator) ChartUsages() ([]ChartUsage, error) {
+	usages := []ChartUsage{}
+
+	apps, files, err := flux.LoadAllApplications(v.RepoPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load Applications: %w", err)
+	}
+	for i, app := range apps {
+		for _, source := range app.GetHelmSources() {
+			usages = append(usages, ChartUsage{
+				Chart:     source.Chart,
+				RepoURL:   source.RepoURL,
+				Version:   source.TargetRevision,
+				Mechanism: ChartMechanismApplication,
+				Source:    app.KindName(),
+				Path:      v.relPath(files[i]),
+			})
+		}
+	}
+
+	err = filepath.WalkDir(v.RepoPath, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != v.RepoPath && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.Name() != "kustomization.yaml" && d.Name() != "kustomization.yml" && d.Name() != "Kustomization" {
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil
+		}
+		var k struct {
+			HelmCharts []struct {
+				Name    string `yaml:"name"`
+				Repo    string `yaml:"repo"`
+				Version string `yaml:"version"`
+			} `yaml:"helmCharts"`
+		}
+		if yaml.Unmarshal(data, &k) != nil {
+			return nil // reported by kustomization checks
+		}
+		for _, chart := range k.HelmCharts {
+			usages = append(usages, ChartUsage{
+				Chart:     chart.Name,
+				RepoURL:   chart.Repo,
+				Version:   chart.Version,
+				Mechanism: ChartMechanismKustomize,
+				Source:    v.relPath(filepath.Dir(path)),
+				Path:      v.relPath(path),
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to walk %s: %w", v.RepoPath, err)
+	}
+
+	sort.SliceStable(usages, func(i, j int) bool {
+		if usages[i].Chart != usages[j].Chart {
+			return usages[i].Chart < usages[j].Chart
+		}
+		return usages[i].Path < usages[j].Path
+	})
+	return usages, nil
+}
+
+// ChartVersionDrift reports charts (same chart name and repo) pinned at more
+// than one version, one warning per chart on its first usage
+func ChartVersionDrift(usages []ChartUsage) []Result {
+	results := []Result{}
+
+	groups := make(map[string][]ChartUsage)
+	var keys []string
+	for _, u := range usages {
+		k := u.key()
+		if _, ok := groups[k]; !ok {
+			keys = append(keys, k)
+		}
+		groups[k] = append(groups[k], u)
+	}
+
+	for _, k := range keys {
+		group := groups[k]
+		byVersion := make(map[string][]string)
+		var versions []string
+		for _, u := range group {
+			version := u.Version
+			if version == "" {
+				version = "unpinned"
+			}
+			if _, ok := byVersion[version]; !ok {
+				versions = append(versions, version)
+			}
+			byVersion[version] = append(byVersion[version], fmt.Sprintf("%s via %s", u.Source, u.Mechanism))
+		}
+		if len(versions) < 2 {
+			continue
+		}
+		sort.Strings(versions)
+
+		var parts []string
+		for _, version := range versions {
+			parts = append(parts, fmt.Sprintf("%s (%s)", version, strings.Join(byVersion[version], ", ")))
+		}
+		results = append(results, Result{
+			Cluster:  "global",
+			Rule:     RuleChartVersionDrift,
+			Path:     group[0].Path,
+			Message:  fmt.Sprintf("chart %s is pinned at %d versions: %s", group[0].Chart, len(versions), strings.Join(parts, "; ")),
+			Severity: "warn",
+		})
+	}
+
+	return results
+}
+
+// relPath returns path relative to the repo, slash-separated
+func (v *ClusterValidator) relPath(path string) string {
+	rel, err := filepath.Rel(v.RepoPath, path)
+	if err != nil {
+		return path
+	}
+	return filepath.ToSlash(rel)
+}
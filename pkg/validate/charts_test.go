@@ -0,0 +1,69 @@
+package validate_test
+
+import (
+	"testing"
+
+	"github.com/erauner/homelab-shadow/pkg/validate"
+	"github.com/erauner/homelab-shadow/pkg/validate/validatetest"
+)
+
+func TestChartUsages(t *testing.T) {
+	root := validatetest.Build(t, validatetest.Repo{
+		Files: map[string]string{
+			"argocd-apps/applications/grafana.yaml": `apiVersion: argoproj.io/v1alpha1
+kind: Application
+metadata:
+  name: grafana
+spec:
+  sources:
+    - repoURL: https://grafana.github.io/helm-charts/
+      chart: grafana
+      targetRevision: 8.5.0
+`,
+			"apps/monitoring/base/kustomization.yaml": `helmCharts:
+  - name: grafana
+    repo: https://grafana.github.io/helm-charts
+    version: 8.4.1
+  - name: loki
+    repo: https://grafana.github.io/helm-charts
+    version: 6.0.0
+`,
+			"apps/logging/base/kustomization.yaml": `helmCharts:
+  - name: loki
+    repo: https://grafana.github.io/helm-charts
+    version: 6.0.0
+`,
+		},
+	})
+
+	usages, err := validate.NewClusterValidator(root, false).ChartUsages()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(usages) != 4 {
+		t.Fatalf("usages = %+v, want 4", usages)
+	}
+	first := usages[0]
+	if first.Chart != "grafana" || first.Mechanism != validate.ChartMechanismKustomize || first.Source != "apps/monitoring/base" || first.Version != "8.4.1" {
+		t.Errorf("usages[0] = %+v", first)
+	}
+	if second := usages[1]; second.Mechanism != validate.ChartMechanismApplication || second.Source != "Application grafana" || second.Path != "argocd-apps/applications/grafana.yaml" {
+		t.Errorf("usages[1] = %+v", second)
+	}
+
+	// loki is pinned consistently; grafana drifts despite the trailing slash in one repo URL
+	validatetest.AssertFindings(t, validate.ChartVersionDrift(usages),
+		validatetest.Finding{Rule: validate.RuleChartVersionDrift, Path: "apps/monitoring/base/kustomization.yaml", Severity: "warn"},
+	)
+}
+
+func TestChartVersionDrift_DifferentRepos(t *testing.T) {
+	usages := []validate.ChartUsage{
+		{Chart: "redis", RepoURL: "https://charts.bitnami.com/bitnami", Version: "18.0.0", Path: "a"},
+		{Chart: "redis", RepoURL: "oci://ghcr.io/example/charts", Version: "1.0.0", Path: "b"},
+		{Chart: "redis", RepoURL: "ghcr.io/example/charts", Version: "1.0.0", Path: "c"},
+	}
+	if results := validate.ChartVersionDrift(usages); len(results) != 0 {
+		t.Errorf("charts from different repos should not drift: %+v", results)
+	}
+}
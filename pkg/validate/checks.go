@@ -0,0 +1,148 @@
+package validate
+
+import "fmt"
+
+// check is a built-in rule: a group of rules evaluated together
+type check struct {
+	name       string
+	rules      []string
+	perCluster bool
+	run        func(v *ClusterValidator, ctx RepoContext) []Result
+}
+
+func (c check) Name() string        { return c.name }
+func (c check) Severity() string    { return "error" }
+func (c check) RuleNames() []string { return c.rules }
+func (c check) PerCluster() bool    { return c.perCluster }
+
+func (c check) Run(ctx RepoContext) []Result {
+	return c.run(ctx.Validator, ctx)
+}
+
+// componentRootRules are the rules ValidateComponentRoots reports for each root
+func componentRootRules() []string {
+	var rules []string
+	for _, root := range []string{"infrastructure", "operators", "security"} {
+		rules = append(rules,
+			fmt.Sprintf("%s-discovery-error", root),
+			fmt.Sprintf("%s-component-structure", root),
+			fmt.Sprintf("%s-overlay-base-ref", root))
+	}
+	return append(rules,
+		"argocd-app-validation-error", "argocd-app-no-flat-infra-base",
+		"argocd-app-no-clusters-infra", "argocd-app-no-clusters-operators")
+}
+
+func init() {
+	Register(check{
+		name: "cluster",
+		rules: []string{"cluster-missing-dir", "cluster-missing-bootstrap-file", "kustomize-build-fail",
+			RuleArgoCDIncludeMissing, RuleArgoCDIncludeInvalid},
+		perCluster: true,
+		run: func(v *ClusterValidator, ctx RepoContext) []Result {
+			return v.ValidateCluster(ctx.Clusters[0])
+		},
+	})
+	Register(check{
+		name:  "infrastructure structure",
+		rules: componentRootRules(),
+		run: func(v *ClusterValidator, ctx RepoContext) []Result {
+			return v.ValidateInfrastructure(ctx.Clusters)
+		},
+	})
+	Register(check{
+		name:  "namespace locations",
+		rules: []string{"namespace-discovery-error", "namespace-legacy-location", "namespace-wrong-location", "namespace-duplicate"},
+		run: func(v *ClusterValidator, _ RepoContext) []Result {
+			return v.ValidateNamespaceLocations()
+		},
+	})
+	Register(check{
+		name:  "CreateNamespace usage",
+		rules: []string{"create-namespace-validation-error", "app-create-namespace"},
+		run: func(v *ClusterValidator, _ RepoContext) []Result {
+			return v.ValidateCreateNamespace()
+		},
+	})
+	Register(check{
+		name: "app overlay structure",
+		rules: []string{"app-discovery-error", "app-overlay-legacy-flat", "app-overlay-unknown-cluster",
+			"app-overlay-missing-base", "app-overlay-wrong-base-ref"},
+		run: func(v *ClusterValidator, ctx RepoContext) []Result {
+			return v.ValidateAppOverlayStructure(ctx.Clusters)
+		},
+	})
+	Register(check{
+		name:  "cluster names",
+		rules: []string{"cluster-registry-error", "cluster-name-collision", "cluster-unregistered-dir"},
+		run: func(v *ClusterValidator, _ RepoContext) []Result {
+			return v.ValidateClusterNames()
+		},
+	})
+	Register(check{
+		name:  "ArgoCD app paths",
+		rules: []string{"argocd-app-path-validation-error", "argocd-appset-expand-fail", "argocd-app-legacy-path"},
+		run: func(v *ClusterValidator, ctx RepoContext) []Result {
+			return v.ValidateArgoCDAppPaths(ctx.Clusters)
+		},
+	})
+	Register(check{
+		name:  "ArgoCD source paths",
+		rules: []string{"argocd-app-path-validation-error", RuleArgoCDAppPathMissing, RuleArgoCDAppPathBuildFail},
+		run: func(v *ClusterValidator, ctx RepoContext) []Result {
+			return v.ValidateArgoCDSourcePaths(ctx.BuildAppPaths)
+		},
+	})
+	Register(check{
+		name:  "ArgoCD version compatibility",
+		rules: []string{"argocd-version-invalid", RuleArgoCDFeatureUnsupported},
+		run: func(v *ClusterValidator, _ RepoContext) []Result {
+			return v.ValidateArgoCDVersions()
+		},
+	})
+	Register(check{
+		name:  "API versions",
+		rules: []string{"api-version-validation-error", RuleAPIRemoved, RuleAPIDeprecated},
+		run: func(v *ClusterValidator, ctx RepoContext) []Result {
+			if ctx.KubernetesVersion == "" {
+				return nil
+			}
+			return v.ValidateAPIVersions(ctx.KubernetesVersion)
+		},
+	})
+	Register(check{
+		name:  "cluster variables",
+		rules: []string{RuleClusterVarUndefined},
+		run: func(v *ClusterValidator, ctx RepoContext) []Result {
+			return v.ValidateClusterVars(ctx.Clusters)
+		},
+	})
+	Register(check{
+		name:  "Application destinations",
+		rules: []string{RuleOverlayWrongDestination},
+		run: func(v *ClusterValidator, _ RepoContext) []Result {
+			return v.ValidateDestinations()
+		},
+	})
+	Register(check{
+		name:  "AppProjects",
+		rules: ProjectRules,
+		run: func(v *ClusterValidator, _ RepoContext) []Result {
+			return v.ValidateProjects()
+		},
+	})
+	Register(check{
+		name:  "sync waves",
+		rules: SyncWaveRules,
+		run: func(v *ClusterValidator, ctx RepoContext) []Result {
+			return v.ValidateSyncWaves(ctx.BuildAppPaths)
+		},
+	})
+	Register(check{
+		name:  "overlay references",
+		rules: []string{"app-discovery-error", RuleOrphanOverlay, RuleUnreferencedApp},
+		run: func(v *ClusterValidator, _ RepoContext) []Result {
+			return v.ValidateOrphans()
+		},
+	})
+}
@@ -4,10 +4,13 @@ package validate
 import (
 	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strings"
 
+	"github.com/erauner/homelab-shadow/pkg/argocd"
+	"github.com/erauner/homelab-shadow/pkg/cluster"
+	"github.com/erauner/homelab-shadow/pkg/command"
+	"github.com/erauner/homelab-shadow/pkg/log"
 	"gopkg.in/yaml.v3"
 )
 
@@ -44,12 +47,21 @@ type Result struct {
 	Path     string `json:"path"`
 	Message  string `json:"message"`
 	Severity string `json:"severity"` // "error" or "warn"
+	Owner    string `json:"owner,omitempty"`
+
+	// Suppressed findings are listed only with --show-suppressed and never fail a run
+	Suppressed   bool   `json:"suppressed,omitempty"`
+	SuppressedBy string `json:"suppressedBy,omitempty"` // "config: ..." or "inline: <file>"
 }
 
 // ClusterValidator validates the multi-cluster directory structure
 type ClusterValidator struct {
 	RepoPath string
-	Verbose  bool
+	Log      *log.Logger // per-check progress is logged at debug level
+
+	registry *cluster.Registry // loaded lazily by clusterRegistry
+
+	sourceManifests []sourceManifest // built lazily by renderSourcePaths
 }
 
 // RequiredDirs defines the required directories for each cluster
@@ -83,30 +95,22 @@ var KustomizePaths = []string{
 func NewClusterValidator(repoPath string, verbose bool) *ClusterValidator {
 	return &ClusterValidator{
 		RepoPath: repoPath,
-		Verbose:  verbose,
+		Log:      log.Default().Named("shadow").Verbose(verbose),
 	}
 }
 
-// DiscoverClusters finds all cluster directories
+// DiscoverClusters returns the registered clusters
+// clusters.yaml takes precedence; otherwise every directory under clusters/ is a cluster
 func (v *ClusterValidator) DiscoverClusters() ([]string, error) {
-	clustersDir := filepath.Join(v.RepoPath, "clusters")
-
-	entries, err := os.ReadDir(clustersDir)
+	registry, err := v.clusterRegistry()
 	if err != nil {
-		if os.IsNotExist(err) {
-			return nil, fmt.Errorf("clusters directory not found at %s", clustersDir)
-		}
-		return nil, fmt.Errorf("failed to read clusters directory: %w", err)
+		return nil, err
 	}
-
-	var clusters []string
-	for _, entry := range entries {
-		if entry.IsDir() && !strings.HasPrefix(entry.Name(), ".") {
-			clusters = append(clusters, entry.Name())
-		}
+	if registry.Source == cluster.SourceNone {
+		return nil, fmt.Errorf("clusters directory not found at %s", filepath.Join(v.RepoPath, "clusters"))
 	}
 
-	return clusters, nil
+	return registry.Names(), nil
 }
 
 // ValidateAll validates all discovered clusters
@@ -158,8 +162,23 @@ func (v *ClusterValidator) ValidateCluster(cluster string) []Result {
 		}
 	}
 
+	// Check argocd/ includes statically; a dangling include makes the
+	// kustomize build fail with a less helpful message, so skip it
+	dangling := make(map[string]bool)
+	for _, r := range v.ValidateArgoCDIncludes(cluster) {
+		results = append(results, r)
+		for _, kpath := range ArgoCDIncludePaths {
+			if r.Path == kpath || strings.HasPrefix(r.Path, kpath+"/") {
+				dangling[kpath] = true
+			}
+		}
+	}
+
 	// Validate kustomize builds
 	for _, kpath := range KustomizePaths {
+		if dangling[kpath] {
+			continue
+		}
 		fullPath := filepath.Join(clusterPath, kpath)
 		if _, err := os.Stat(filepath.Join(fullPath, "kustomization.yaml")); err == nil {
 			if err := v.validateKustomizeBuild(fullPath); err != nil {
@@ -170,8 +189,8 @@ func (v *ClusterValidator) ValidateCluster(cluster string) []Result {
 					Message:  fmt.Sprintf("Kustomize build failed: %v", err),
 					Severity: "error",
 				})
-			} else if v.Verbose {
-				fmt.Fprintf(os.Stderr, "[shadow] %s/%s: kustomize build OK\n", cluster, kpath)
+			} else {
+				v.Log.Debugf("%s/%s: kustomize build OK", cluster, kpath)
 			}
 		}
 	}
@@ -181,7 +200,7 @@ func (v *ClusterValidator) ValidateCluster(cluster string) []Result {
 
 // validateKustomizeBuild runs kustomize build and checks for errors
 func (v *ClusterValidator) validateKustomizeBuild(path string) error {
-	cmd := exec.Command("kustomize", "build", path)
+	cmd := command.Command("kustomize", "build", path)
 	output, err := cmd.CombinedOutput()
 	if err != nil {
 		// Extract first line of error for cleaner message
@@ -194,22 +213,22 @@ func (v *ClusterValidator) validateKustomizeBuild(path string) error {
 	return nil
 }
 
-// CountErrors returns the number of error-severity results
+// CountErrors returns the number of error-severity results, excluding suppressed ones
 func CountErrors(results []Result) int {
 	count := 0
 	for _, r := range results {
-		if r.Severity == "error" {
+		if r.Severity == "error" && !r.Suppressed {
 			count++
 		}
 	}
 	return count
 }
 
-// CountWarnings returns the number of warn-severity results
+// CountWarnings returns the number of warn-severity results, excluding suppressed ones
 func CountWarnings(results []Result) int {
 	count := 0
 	for _, r := range results {
-		if r.Severity == "warn" {
+		if r.Severity == "warn" && !r.Suppressed {
 			count++
 		}
 	}
@@ -363,9 +382,7 @@ func (v *ClusterValidator) validateComponentStructure(root ComponentRoot, compon
 			})
 		}
 
-		if v.Verbose {
-			fmt.Fprintf(os.Stderr, "[shadow] %s/%s: structure OK\n", root.RelPath, component)
-		}
+		v.Log.Debugf("%s/%s: structure OK", root.RelPath, component)
 	}
 
 	return results
@@ -446,12 +463,10 @@ func (v *ClusterValidator) validateOverlayBaseRefs(root ComponentRoot, component
 					Message:  "Overlay must include ../../base in resources (or define its own helmCharts)",
 					Severity: "error",
 				})
-			} else if v.Verbose {
-				if hasOwnHelmCharts && !hasBaseRef {
-					fmt.Fprintf(os.Stderr, "[shadow] %s/%s/overlays/%s: has own helmCharts (base ref skipped)\n", root.RelPath, component, cluster)
-				} else {
-					fmt.Fprintf(os.Stderr, "[shadow] %s/%s/overlays/%s: base ref OK\n", root.RelPath, component, cluster)
-				}
+			} else if hasOwnHelmCharts && !hasBaseRef {
+				v.Log.Debugf("%s/%s/overlays/%s: has own helmCharts (base ref skipped)", root.RelPath, component, cluster)
+			} else {
+				v.Log.Debugf("%s/%s/overlays/%s: base ref OK", root.RelPath, component, cluster)
 			}
 		}
 	}
@@ -1061,25 +1076,19 @@ func (v *ClusterValidator) validateSingleAppOverlayStructure(app string, cluster
 			childName := entry.Name()
 			childPath := filepath.Join(overlaysPath, childName)
 
+			// A near-miss of a registered cluster is a typo, not a legacy env
+			if finding, ok := v.unknownClusterOverlay(app, overlayRoot, childName); ok {
+				results = append(results, finding)
+				continue
+			}
+
 			// Check if this child is a cluster directory (contains environment subdirs)
 			// or a legacy environment directory (contains kustomization.yaml directly)
 			kustomizationPath := filepath.Join(childPath, "kustomization.yaml")
 			if _, err := os.Stat(kustomizationPath); err == nil {
 				// This is a legacy flat structure: apps/<app>/overlays/<env>/
 				// Check if it's NOT actually a cluster directory with environments below
-				subEntries, _ := os.ReadDir(childPath)
-				hasEnvSubdirs := false
-				for _, sub := range subEntries {
-					if sub.IsDir() && !strings.HasPrefix(sub.Name(), ".") {
-						subKustomization := filepath.Join(childPath, sub.Name(), "kustomization.yaml")
-						if _, err := os.Stat(subKustomization); err == nil {
-							hasEnvSubdirs = true
-							break
-						}
-					}
-				}
-
-				if !hasEnvSubdirs {
+				if !v.isClusterDir(childName, childPath) {
 					// This is a legacy flat overlay - emit warning
 					relPath := fmt.Sprintf("apps/%s/%s/%s", app, overlayRoot, childName)
 					results = append(results, Result{
@@ -1108,7 +1117,7 @@ func (v *ClusterValidator) validateSingleAppOverlayStructure(app string, cluster
 				}
 			}
 
-			if isClusterDir || v.looksLikeClusterDir(childPath) {
+			if isClusterDir || v.isClusterDir(childName, childPath) {
 				// This is a cluster directory - validate its environment subdirs
 				envEntries, _ := os.ReadDir(childPath)
 				for _, envEntry := range envEntries {
@@ -1126,9 +1135,7 @@ func (v *ClusterValidator) validateSingleAppOverlayStructure(app string, cluster
 							results = append(results, baseRefResults...)
 						}
 
-						if v.Verbose {
-							fmt.Fprintf(os.Stderr, "[shadow] apps/%s/%s/%s/%s: cluster-layered structure OK\n", app, overlayRoot, childName, envName)
-						}
+						v.Log.Debugf("apps/%s/%s/%s/%s: cluster-layered structure OK", app, overlayRoot, childName, envName)
 					}
 				}
 			}
@@ -1138,6 +1145,16 @@ func (v *ClusterValidator) validateSingleAppOverlayStructure(app string, cluster
 	return results
 }
 
+// isClusterDir reports whether an app overlay child is a cluster directory
+// Registered clusters are authoritative; looksLikeClusterDir is only used when
+// no clusters are registered
+func (v *ClusterValidator) isClusterDir(name, dirPath string) bool {
+	if registry, err := v.clusterRegistry(); err == nil && !registry.Empty() {
+		return registry.Has(name)
+	}
+	return v.looksLikeClusterDir(dirPath)
+}
+
 // looksLikeClusterDir checks if a directory looks like a cluster directory
 // (contains subdirectories with kustomization.yaml files)
 func (v *ClusterValidator) looksLikeClusterDir(dirPath string) bool {
@@ -1344,18 +1361,29 @@ func (v *ClusterValidator) validateArgoCDAppPathsInFile(filePath string, cluster
 		return results
 	}
 
+	relPath, _ := filepath.Rel(v.RepoPath, filePath)
+
 	decoder := yaml.NewDecoder(strings.NewReader(string(data)))
 	for {
-		var doc ArgoCDApplication
-		if err := decoder.Decode(&doc); err != nil {
+		var node yaml.Node
+		if err := decoder.Decode(&node); err != nil {
 			break
 		}
 
-		if doc.Kind != "Application" {
+		var doc ArgoCDApplication
+		if err := node.Decode(&doc); err != nil {
 			continue
 		}
 
-		relPath, _ := filepath.Rel(v.RepoPath, filePath)
+		// ApplicationSets are expanded so generated paths get the same checks
+		if doc.Kind == "ApplicationSet" {
+			results = append(results, v.validateApplicationSetPaths(&node, relPath, clusters)...)
+			continue
+		}
+
+		if doc.Kind != "Application" {
+			continue
+		}
 
 		// Check spec.source.path
 		if doc.Spec.Source.Path != "" {
@@ -1373,6 +1401,41 @@ func (v *ClusterValidator) validateArgoCDAppPathsInFile(filePath string, cluster
 	return results
 }
 
+// validateApplicationSetPaths expands an ApplicationSet and validates each generated app's paths
+func (v *ClusterValidator) validateApplicationSetPaths(node *yaml.Node, relPath string, clusters []string) []Result {
+	results := []Result{}
+
+	data, err := yaml.Marshal(node)
+	if err != nil {
+		return results
+	}
+
+	set, err := argocd.ParseApplicationSetYAML(data)
+	if err == nil {
+		var apps []*argocd.Application
+		apps, err = set.Expand(argocd.ExpandOptions{
+			RepoPath: v.RepoPath,
+			Clusters: argocd.LocalClusters(v.RepoPath),
+		})
+		for _, app := range apps {
+			for _, path := range argocd.GetKustomizePathsFromApp(app) {
+				results = append(results, v.validateAppSourcePath(path, relPath, clusters)...)
+			}
+		}
+	}
+	if err != nil {
+		results = append(results, Result{
+			Cluster:  "global",
+			Rule:     "argocd-appset-expand-fail",
+			Path:     relPath,
+			Message:  fmt.Sprintf("Cannot expand ApplicationSet, generated paths not validated: %v", err),
+			Severity: "warn",
+		})
+	}
+
+	return results
+}
+
 // validateAppSourcePath checks if an ArgoCD app source path uses the correct structure
 func (v *ClusterValidator) validateAppSourcePath(sourcePath, filePath string, clusters []string) []Result {
 	results := []Result{}
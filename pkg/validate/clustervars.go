@@ -0,0 +1,115 @@
+package validate
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/erauner/homelab-shadow/pkg/cluster"
+)
+
+// RuleClusterVarUndefined flags ${NAME} placeholders that sync cannot resolve
+// for a cluster the file is rendered for
+const RuleClusterVarUndefined = "cluster-var-undefined"
+
+// clusterVarRoots are the directories scanned for cluster variable placeholders
+var clusterVarRoots = []string{"apps", "infrastructure", "operators", "security", "clusters"}
+
+// ValidateClusterVars checks ${NAME} placeholders for clusters.yaml vars
+//
+// A file under a cluster's overlay (or clusters/<cluster>/) must only use
+// variables set for that cluster; shared files such as bases must only use
+// variables set for every validated cluster. Nothing is checked unless some
+// cluster declares vars, since substitution is off until then.
+func (v *ClusterValidator) ValidateClusterVars(clusters []string) []Result {
+	results := []Result{}
+
+	registry, err := v.clusterRegistry()
+	if err != nil {
+		return results // reported by ValidateClusterNames
+	}
+	names := registry.VarNames()
+	if len(names) == 0 {
+		return results
+	}
+
+	for _, root := range clusterVarRoots {
+		dir := filepath.Join(v.RepoPath, root)
+		if _, err := os.Stat(dir); err != nil {
+			continue
+		}
+		filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return nil
+			}
+			if d.IsDir() {
+				if strings.HasPrefix(d.Name(), ".") {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if !strings.HasSuffix(path, ".yaml") && !strings.HasSuffix(path, ".yml") {
+				return nil
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return nil
+			}
+			relPath, _ := filepath.Rel(v.RepoPath, path)
+			relPath = filepath.ToSlash(relPath)
+
+			results = append(results, checkClusterVars(registry, relPath, string(data), clusters)...)
+			return nil
+		})
+	}
+
+	return results
+}
+
+// checkClusterVars reports the placeholders in one file that a target cluster doesn't set
+func checkClusterVars(registry *cluster.Registry, relPath, data string, clusters []string) []Result {
+	targets := clusters
+	if name := fileCluster(registry, relPath); name != "" {
+		targets = []string{name}
+	}
+
+	var results []Result
+	reported := make(map[string]bool)
+	for _, p := range cluster.Placeholders(data, registry.VarNames()) {
+		for _, name := range targets {
+			c, ok := registry.Get(name)
+			if !ok {
+				continue
+			}
+			if _, set := c.Variables()[p.Name]; set || reported[name+"/"+p.Name] {
+				continue
+			}
+			reported[name+"/"+p.Name] = true
+			results = append(results, Result{
+				Cluster:  name,
+				Rule:     RuleClusterVarUndefined,
+				Path:     relPath,
+				Message:  fmt.Sprintf("${%s} (line %d) is not set in %s vars for %s", p.Name, p.Line, cluster.RegistryFile, name),
+				Severity: "error",
+			})
+		}
+	}
+	return results
+}
+
+// fileCluster returns the registered cluster a file is specific to: the segment
+// after overlays/ or stack/, or clusters/<cluster>/; "" for shared files
+func fileCluster(registry *cluster.Registry, relPath string) string {
+	parts := strings.Split(relPath, "/")
+	if len(parts) > 2 && parts[0] == "clusters" && registry.Has(parts[1]) {
+		return parts[1]
+	}
+	for i := 1; i < len(parts)-1; i++ {
+		if (parts[i-1] == "overlays" || parts[i-1] == "stack") && registry.Has(parts[i]) {
+			return parts[i]
+		}
+	}
+	return ""
+}
@@ -0,0 +1,50 @@
+package validate_test
+
+import (
+	"testing"
+
+	"github.com/erauner/homelab-shadow/pkg/validate"
+	"github.com/erauner/homelab-shadow/pkg/validate/validatetest"
+)
+
+func TestValidateClusterVars(t *testing.T) {
+	root := validatetest.Build(t, validatetest.Repo{
+		Files: map[string]string{
+			"clusters.yaml": `clusters:
+  - name: erauner-home
+    vars:
+      CLUSTER_DOMAIN: home.erauner.dev
+  - name: erauner-cloud
+    vars:
+      CLUSTER_DOMAIN: cloud.erauner.dev
+      STORAGE_CLASS: longhorn
+`,
+			// Shared: CLUSTER_DOMAIN is set everywhere, STORAGE_CLASS only on erauner-cloud
+			"apps/web/base/ingress.yaml": "host: web.${CLUSTER_DOMAIN}\nstorageClassName: ${STORAGE_CLASS}\n",
+			// Cluster-specific files are only checked against their cluster
+			"apps/web/overlays/erauner-cloud/production/pvc.yaml": "storageClassName: ${STORAGE_CLASS}\n",
+			"apps/web/overlays/erauner-home/production/pvc.yaml":  "storageClassName: ${STORAGE_CLASS}\n",
+			// Escaped and unknown placeholders are left alone
+			"apps/web/base/script.yaml":                            "run: echo $${STORAGE_CLASS} ${HOME}\n",
+			"clusters/erauner-home/bootstrap/cluster-config.yaml": "name: ${CLUSTER_NAME}\n",
+		},
+	})
+
+	v := validate.NewClusterValidator(root, false)
+	validatetest.AssertFindings(t, v.ValidateClusterVars([]string{"erauner-cloud", "erauner-home"}),
+		validatetest.Finding{Rule: validate.RuleClusterVarUndefined, Cluster: "erauner-home", Path: "apps/web/base/ingress.yaml"},
+		validatetest.Finding{Rule: validate.RuleClusterVarUndefined, Cluster: "erauner-home", Path: "apps/web/overlays/erauner-home/production/pvc.yaml"},
+	)
+}
+
+func TestValidateClusterVars_NoVars(t *testing.T) {
+	root := validatetest.Build(t, validatetest.Repo{
+		Clusters: []string{"erauner-home"},
+		Files: map[string]string{
+			"apps/web/base/ingress.yaml": "host: web.${CLUSTER_DOMAIN}\n",
+		},
+	})
+
+	v := validate.NewClusterValidator(root, false)
+	validatetest.AssertFindings(t, v.ValidateClusterVars([]string{"erauner-home"}))
+}
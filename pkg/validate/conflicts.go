@@ -0,0 +1,182 @@
+package validate
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// RuleResourceConflict flags a resource rendered by more than one directory
+// for the same cluster, which makes the owning Applications fight over it
+const RuleResourceConflict = "resource-conflict"
+
+// ResourceID identifies a rendered resource within a cluster
+// The API version is dropped so apps/v1 and apps/v1beta2 Deployments collide
+type ResourceID struct {
+	Group     string `json:"group,omitempty"`
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+}
+
+// String returns Kind.group namespace/name
+func (id ResourceID) String() string {
+	kind := id.Kind
+	if id.Group != "" {
+		kind += "." + id.Group
+	}
+	if id.Namespace == "" {
+		return kind + " " + id.Name
+	}
+	return kind + " " + id.Namespace + "/" + id.Name
+}
+
+// Conflict is a resource defined by several directories for one cluster
+type Conflict struct {
+	Cluster     string     `json:"cluster"`
+	Resource    ResourceID `json:"resource"`
+	Directories []string   `json:"directories"`
+}
+
+// Result converts the conflict into a validation finding on its first directory
+func (c Conflict) Result() Result {
+	clusterName := c.Cluster
+	if clusterName == "" {
+		clusterName = "global"
+	}
+	return Result{
+		Cluster:  clusterName,
+		Rule:     RuleResourceConflict,
+		Path:     c.Directories[0],
+		Message:  fmt.Sprintf("%s is also rendered by %s", c.Resource, strings.Join(c.Directories[1:], ", ")),
+		Severity: "error",
+	}
+}
+
+// FindConflicts indexes rendered manifests (keyed by directory) per cluster and
+// returns every resource defined by more than one directory, sorted by cluster
+// then resource. clusterOf maps a directory to its cluster ("" when unknown;
+// such directories are only compared with each other)
+func FindConflicts(manifests map[string]string, clusterOf func(dir string) string) ([]Conflict, error) {
+	type key struct {
+		cluster string
+		id      ResourceID
+	}
+	index := make(map[key][]string)
+
+	dirs := make([]string, 0, len(manifests))
+	for dir := range manifests {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+
+	for _, dir := range dirs {
+		ids, err := resourceIDs(manifests[dir])
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", dir, err)
+		}
+		clusterName := clusterOf(dir)
+		seen := make(map[ResourceID]bool)
+		for _, id := range ids {
+			if seen[id] {
+				continue // duplicates within one build are kustomize's problem
+			}
+			seen[id] = true
+			k := key{clusterName, id}
+			index[k] = append(index[k], dir)
+		}
+	}
+
+	var conflicts []Conflict
+	for k, dirs := range index {
+		if len(dirs) > 1 {
+			conflicts = append(conflicts, Conflict{Cluster: k.cluster, Resource: k.id, Directories: dirs})
+		}
+	}
+	sort.Slice(conflicts, func(i, j int) bool {
+		if conflicts[i].Cluster != conflicts[j].Cluster {
+			return conflicts[i].Cluster < conflicts[j].Cluster
+		}
+		return conflicts[i].Resource.String() < conflicts[j].Resource.String()
+	})
+	return conflicts, nil
+}
+
+// WithoutIncluded drops directories that another conflicting directory includes
+// through resources/components (a stack rendering its own overlay is not a
+// conflict), and conflicts left with a single directory
+func (v *ClusterValidator) WithoutIncluded(conflicts []Conflict) []Conflict {
+	includes := make(map[string]map[string]bool)
+	reachable := func(dir string) map[string]bool {
+		if r, ok := includes[dir]; ok {
+			return r
+		}
+		r := v.reachableKustomizations([]string{dir})
+		delete(r, dir)
+		includes[dir] = r
+		return r
+	}
+
+	var kept []Conflict
+	for _, c := range conflicts {
+		var dirs []string
+		for _, dir := range c.Directories {
+			included := false
+			for _, other := range c.Directories {
+				if other != dir && reachable(other)[dir] {
+					included = true
+					break
+				}
+			}
+			if !included {
+				dirs = append(dirs, dir)
+			}
+		}
+		if len(dirs) > 1 {
+			c.Directories = dirs
+			kept = append(kept, c)
+		}
+	}
+	return kept
+}
+
+// resourceIDs lists the resources in a multi-document manifest
+func resourceIDs(manifest string) ([]ResourceID, error) {
+	var ids []ResourceID
+	decoder := yaml.NewDecoder(strings.NewReader(manifest))
+	for {
+		var doc struct {
+			APIVersion string `yaml:"apiVersion"`
+			Kind       string `yaml:"kind"`
+			Metadata   struct {
+				Name      string `yaml:"name"`
+				Namespace string `yaml:"namespace"`
+			} `yaml:"metadata"`
+		}
+		if err := decoder.Decode(&doc); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, fmt.Errorf("failed to parse manifest: %w", err)
+		}
+		if doc.Kind == "" || doc.Metadata.Name == "" {
+			continue
+		}
+
+		group := ""
+		if i := strings.LastIndex(doc.APIVersion, "/"); i >= 0 {
+			group = doc.APIVersion[:i]
+		}
+		ids = append(ids, ResourceID{
+			Group:     group,
+			Kind:      doc.Kind,
+			Namespace: doc.Metadata.Namespace,
+			Name:      doc.Metadata.Name,
+		})
+	}
+	return ids, nil
+}
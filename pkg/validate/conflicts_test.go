@@ -0,0 +1,111 @@
+package validate_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/erauner/homelab-shadow/pkg/validate"
+	"github.com/erauner/homelab-shadow/pkg/validate/validatetest"
+)
+
+func clusterOfTestDir(dir string) string {
+	parts := strings.Split(dir, "/")
+	if len(parts) == 5 {
+		return parts[3]
+	}
+	return ""
+}
+
+func TestFindConflicts(t *testing.T) {
+	manifests := map[string]string{
+		"apps/coder/overlays/erauner-home/production": `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  namespace: shared
+---
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: shared
+`,
+		"apps/other/overlays/erauner-home/production": `apiVersion: apps/v1beta2
+kind: Deployment
+metadata:
+  name: web
+  namespace: shared
+---
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: shared
+`,
+		// Same resource on a different cluster is fine
+		"apps/other/overlays/erauner-cloud/production": `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  namespace: shared
+`,
+	}
+
+	conflicts, err := validate.FindConflicts(manifests, clusterOfTestDir)
+	if err != nil {
+		t.Fatalf("FindConflicts failed: %v", err)
+	}
+	if len(conflicts) != 2 {
+		t.Fatalf("expected 2 conflicts, got %+v", conflicts)
+	}
+
+	deploy := conflicts[0]
+	if deploy.Cluster != "erauner-home" || deploy.Resource.String() != "Deployment.apps shared/web" {
+		t.Errorf("unexpected conflict: %+v", deploy)
+	}
+	if len(deploy.Directories) != 2 || deploy.Directories[0] != "apps/coder/overlays/erauner-home/production" {
+		t.Errorf("unexpected directories: %v", deploy.Directories)
+	}
+	if conflicts[1].Resource.String() != "Namespace shared" {
+		t.Errorf("expected Namespace conflict, got %s", conflicts[1].Resource)
+	}
+
+	r := deploy.Result()
+	if r.Rule != validate.RuleResourceConflict || r.Severity != "error" || !strings.Contains(r.Message, "apps/other/overlays/erauner-home/production") {
+		t.Errorf("unexpected result: %+v", r)
+	}
+}
+
+func TestWithoutIncluded(t *testing.T) {
+	root := validatetest.Build(t, validatetest.Repo{
+		Kustomizations: map[string]validatetest.Kustomization{
+			"apps/media/overlays/erauner-home/production": {},
+			"apps/media/stack/erauner-home/production":    {Resources: []string{"../../../overlays/erauner-home/production"}},
+			"apps/dup/overlays/erauner-home/production":   {},
+		},
+	})
+
+	conflicts := []validate.Conflict{
+		{
+			Cluster:     "erauner-home",
+			Resource:    validate.ResourceID{Kind: "Service", Namespace: "media", Name: "jellyfin"},
+			Directories: []string{"apps/media/overlays/erauner-home/production", "apps/media/stack/erauner-home/production"},
+		},
+		{
+			Cluster:  "erauner-home",
+			Resource: validate.ResourceID{Kind: "Service", Namespace: "media", Name: "web"},
+			Directories: []string{
+				"apps/dup/overlays/erauner-home/production",
+				"apps/media/overlays/erauner-home/production",
+				"apps/media/stack/erauner-home/production",
+			},
+		},
+	}
+
+	kept := validate.NewClusterValidator(root, false).WithoutIncluded(conflicts)
+	if len(kept) != 1 {
+		t.Fatalf("expected 1 conflict after filtering, got %+v", kept)
+	}
+	want := []string{"apps/dup/overlays/erauner-home/production", "apps/media/stack/erauner-home/production"}
+	if strings.Join(kept[0].Directories, ",") != strings.Join(want, ",") {
+		t.Errorf("Directories = %v, want %v", kept[0].Directories, want)
+	}
+}
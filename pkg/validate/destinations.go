@@ -0,0 +1,84 @@
+package validate
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/erauner/homelab-shadow/pkg/flux"
+)
+
+// RuleOverlayWrongDestination flags a cluster overlay that Applications deploy
+// only to other clusters
+const RuleOverlayWrongDestination = "overlay-wrong-destination"
+
+// ValidateDestinations cross-references the <cluster> segment of the app
+// overlays Applications deploy with where those Applications actually deploy
+// (spec.destination, resolved by name, by the server in clusters.yaml, or
+// for in-cluster destinations by the cluster directory of the manifest)
+//
+// An overlay is flagged when every Application deploying it resolves to a
+// cluster other than the one it is rendered for. Overlays deployed by any
+// Application whose destination doesn't resolve are skipped, as are
+// unreferenced overlays (orphan-overlay)
+func (v *ClusterValidator) ValidateDestinations() []Result {
+	results := []Result{}
+
+	registry, err := v.clusterRegistry()
+	if err != nil {
+		return results // reported by ValidateClusterNames
+	}
+	apps, _, err := flux.LoadAllApplications(v.RepoPath)
+	if err != nil {
+		return results // reported by ValidateArgoCDSourcePaths
+	}
+
+	// deployers maps each cluster overlay to "<app> → <cluster>" for the
+	// Applications deploying it; unresolved overlays can't be judged
+	deployers := make(map[string][]string)
+	targets := make(map[string]map[string]bool)
+	unresolved := make(map[string]bool)
+	for _, app := range apps {
+		dest := app.Destination(registry)
+		for _, source := range app.AllSources() {
+			if source.Path == "" {
+				continue
+			}
+			dir := cleanRelPath(source.Path)
+			if p, ok := registry.ParseAppPath(dir); !ok || p.Cluster == "" {
+				continue
+			}
+			if dest.Cluster == "" {
+				unresolved[dir] = true
+				continue
+			}
+			if targets[dir] == nil {
+				targets[dir] = make(map[string]bool)
+			}
+			targets[dir][dest.Cluster] = true
+			deployers[dir] = append(deployers[dir], fmt.Sprintf("%s → %s", app.Name, dest.Cluster))
+		}
+	}
+
+	dirs := make([]string, 0, len(targets))
+	for dir := range targets {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+
+	for _, dir := range dirs {
+		p, _ := registry.ParseAppPath(dir)
+		if unresolved[dir] || targets[dir][p.Cluster] {
+			continue
+		}
+		results = append(results, Result{
+			Cluster:  p.Cluster,
+			Rule:     RuleOverlayWrongDestination,
+			Path:     dir,
+			Message:  fmt.Sprintf("Overlay is rendered for %s, but no Application deploys it there (%s)", p.Cluster, strings.Join(deployers[dir], ", ")),
+			Severity: "warn",
+		})
+	}
+
+	return results
+}
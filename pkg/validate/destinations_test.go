@@ -0,0 +1,66 @@
+package validate_test
+
+import (
+	"testing"
+
+	"github.com/erauner/homelab-shadow/pkg/validate"
+	"github.com/erauner/homelab-shadow/pkg/validate/validatetest"
+)
+
+func TestValidateDestinations(t *testing.T) {
+	root := validatetest.Build(t, validatetest.Repo{
+		Kustomizations: map[string]validatetest.Kustomization{
+			"apps/coder/overlays/erauner-home/production":  {},
+			"apps/coder/overlays/erauner-cloud/production": {},
+			"apps/media/overlays/erauner-home/production":  {},
+			"apps/wiki/overlays/erauner-cloud/production":  {},
+			"apps/blog/overlays/erauner-cloud/production":  {},
+		},
+		Applications: []validatetest.Application{
+			{Name: "coder-home", Path: "apps/coder/overlays/erauner-home/production", Destination: "erauner-home"},
+			{Name: "coder-cloud", Path: "apps/coder/overlays/erauner-cloud/production", Server: "https://cloud.example.com:6443"},
+			// Deployed to home by server, but rendered for cloud
+			{Name: "wiki", Path: "apps/wiki/overlays/erauner-cloud/production", Server: "https://kubernetes.default.svc"},
+			// A second, unresolved Application keeps the overlay from being judged
+			{Name: "media", Path: "apps/media/overlays/erauner-home/production", Destination: "erauner-cloud"},
+			{Name: "media-edge", Path: "apps/media/overlays/erauner-home/production", Server: "https://edge.example.com"},
+			{Name: "blog", Path: "apps/blog/overlays/erauner-cloud/production", Destination: "erauner-home"},
+		},
+		Files: map[string]string{
+			"clusters.yaml": "clusters:\n  - name: erauner-home\n    server: https://kubernetes.default.svc\n  - name: erauner-cloud\n    server: https://cloud.example.com:6443\n",
+		},
+	})
+
+	v := validate.NewClusterValidator(root, false)
+	validatetest.AssertFindings(t, v.ValidateDestinations(),
+		validatetest.Finding{Rule: validate.RuleOverlayWrongDestination, Path: "apps/blog/overlays/erauner-cloud/production", Cluster: "erauner-cloud", Severity: "warn"},
+		validatetest.Finding{Rule: validate.RuleOverlayWrongDestination, Path: "apps/wiki/overlays/erauner-cloud/production", Cluster: "erauner-cloud", Severity: "warn"},
+	)
+}
+
+// Each cluster's ArgoCD deploys its own Applications to the in-cluster server,
+// so clusters.yaml can't map servers; destinations come from the cluster
+// directory under argocd-apps/
+func TestValidateDestinations_InCluster(t *testing.T) {
+	root := validatetest.Build(t, validatetest.Repo{
+		Kustomizations: map[string]validatetest.Kustomization{
+			"apps/coder/overlays/erauner-home/production":  {},
+			"apps/coder/overlays/erauner-cloud/production": {},
+			"apps/wiki/overlays/erauner-cloud/production":  {},
+		},
+		Applications: []validatetest.Application{
+			{Name: "coder", Dir: "erauner-home", Path: "apps/coder/overlays/erauner-home/production", Server: "https://kubernetes.default.svc"},
+			{Name: "coder", Dir: "erauner-cloud", Path: "apps/coder/overlays/erauner-cloud/production", Server: "https://kubernetes.default.svc"},
+			// The home ArgoCD deploys the cloud overlay to home
+			{Name: "wiki", Dir: "erauner-home", Path: "apps/wiki/overlays/erauner-cloud/production", Server: "https://kubernetes.default.svc"},
+		},
+		Files: map[string]string{
+			"clusters.yaml": "clusters:\n  - name: erauner-home\n  - name: erauner-cloud\n",
+		},
+	})
+
+	v := validate.NewClusterValidator(root, false)
+	validatetest.AssertFindings(t, v.ValidateDestinations(),
+		validatetest.Finding{Rule: validate.RuleOverlayWrongDestination, Path: "apps/wiki/overlays/erauner-cloud/production", Cluster: "erauner-cloud", Severity: "warn"},
+	)
+}
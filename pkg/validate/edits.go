@@ -0,0 +1,279 @@
+package validate
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// fileSet tracks the content of files a migration reads and changes, so
+// several edits to one file compose; nothing is written until the edits are
+type fileSet struct {
+	repoPath string
+	original map[string]*string // nil: the file didn't exist
+	current  map[string]*string // nil: the file doesn't exist (anymore)
+}
+
+func newFileSet(repoPath string) *fileSet {
+	return &fileSet{repoPath: repoPath, original: make(map[string]*string), current: make(map[string]*string)}
+}
+
+// read returns a file's current content and whether it exists
+func (f *fileSet) read(path string) (string, bool, error) {
+	if _, ok := f.current[path]; !ok {
+		data, err := os.ReadFile(filepath.Join(f.repoPath, filepath.FromSlash(path)))
+		switch {
+		case os.IsNotExist(err):
+			f.original[path], f.current[path] = nil, nil
+		case err != nil:
+			return "", false, err
+		default:
+			s := string(data)
+			f.original[path], f.current[path] = &s, &s
+		}
+	}
+	if c := f.current[path]; c != nil {
+		return *c, true, nil
+	}
+	return "", false, nil
+}
+
+func (f *fileSet) write(path, content string) {
+	if _, ok := f.current[path]; !ok {
+		f.read(path)
+	}
+	f.current[path] = &content
+}
+
+func (f *fileSet) remove(path string) {
+	if _, ok := f.current[path]; !ok {
+		f.read(path)
+	}
+	f.current[path] = nil
+}
+
+// edits returns the changed files, by path
+func (f *fileSet) edits() []FileEdit {
+	edits := []FileEdit{}
+	for path, after := range f.current {
+		before := f.original[path]
+		switch {
+		case before == nil && after == nil:
+		case before == nil:
+			edits = append(edits, FileEdit{Path: path, After: *after, Created: true})
+		case after == nil:
+			edits = append(edits, FileEdit{Path: path, OldPath: path, Before: *before, Deleted: true})
+		case *before != *after:
+			edits = append(edits, FileEdit{Path: path, OldPath: path, Before: *before, After: *after})
+		}
+	}
+	sort.Slice(edits, func(i, j int) bool { return edits[i].Path < edits[j].Path })
+	return edits
+}
+
+// writeEdits applies file edits under repoPath
+func writeEdits(repoPath string, edits []FileEdit) error {
+	for _, e := range edits {
+		file := filepath.Join(repoPath, filepath.FromSlash(e.Path))
+		if e.Deleted {
+			if err := os.Remove(file); err != nil {
+				return fmt.Errorf("failed to remove %s: %w", e.Path, err)
+			}
+			continue
+		}
+		mode := os.FileMode(0644)
+		if info, err := os.Stat(file); err == nil {
+			mode = info.Mode().Perm()
+		}
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(file, []byte(e.After), mode); err != nil {
+			return fmt.Errorf("failed to write %s: %w", e.Path, err)
+		}
+	}
+	return nil
+}
+
+// diffEdits renders edits as a unified diff without context lines
+func diffEdits(edits []FileEdit) string {
+	var b strings.Builder
+	for _, e := range edits {
+		from, to := "a/"+e.OldPath, "b/"+e.Path
+		if e.Created {
+			from = "/dev/null"
+		}
+		if e.Deleted {
+			to = "/dev/null"
+		}
+		fmt.Fprintf(&b, "--- %s\n+++ %s\n", from, to)
+		b.WriteString(lineDiff(diffLines(e.Before), diffLines(e.After)))
+	}
+	return b.String()
+}
+
+// diffLines splits content into lines, without a trailing empty one
+func diffLines(content string) []string {
+	if content == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+}
+
+// lineDiff renders the hunks turning a into b (longest common subsequence;
+// the files migrations edit are small)
+func lineDiff(a, b []string) string {
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var out strings.Builder
+	var removed, added []string
+	hunkA, hunkB := 0, 0
+	flush := func() {
+		if len(removed) == 0 && len(added) == 0 {
+			return
+		}
+		fmt.Fprintf(&out, "@@ -%s +%s @@\n", hunkRange(hunkA, len(removed)), hunkRange(hunkB, len(added)))
+		for _, l := range removed {
+			out.WriteString("-" + l + "\n")
+		}
+		for _, l := range added {
+			out.WriteString("+" + l + "\n")
+		}
+		removed, added = nil, nil
+	}
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			flush()
+			i, j = i+1, j+1
+		case j < len(b) && (i == len(a) || lcs[i][j+1] >= lcs[i+1][j]):
+			if len(removed) == 0 && len(added) == 0 {
+				hunkA, hunkB = i, j
+			}
+			added = append(added, b[j])
+			j++
+		default:
+			if len(removed) == 0 && len(added) == 0 {
+				hunkA, hunkB = i, j
+			}
+			removed = append(removed, a[i])
+			i++
+		}
+	}
+	flush()
+	return out.String()
+}
+
+// hunkRange formats a hunk's start line and length as unified diff does
+func hunkRange(start, n int) string {
+	if n == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	if n == 1 {
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, n)
+}
+
+// addResource adds ref to the resources list of a kustomization (root is its
+// parsed mapping), first or last, keeping the list's style and indentation;
+// a missing list is added at the end of the file
+func addResource(data []byte, root *yaml.Node, ref string, first bool) ([]byte, error) {
+	lines := splitLines(data)
+	key, value := mappingEntry(root, "resources")
+	switch {
+	case key == nil:
+		text := string(data)
+		if text != "" && !strings.HasSuffix(text, "\n") {
+			text += "\n"
+		}
+		return []byte(text + "resources:\n  - " + ref + "\n"), nil
+	case value.Kind == yaml.SequenceNode && value.Style&yaml.FlowStyle != 0:
+		line := lines[value.Line-1]
+		open := value.Column - 1
+		if open >= len(line) || line[open] != '[' {
+			return nil, fmt.Errorf("cannot edit resources at line %d", value.Line)
+		}
+		if first || len(value.Content) == 0 {
+			insert := ref
+			if len(value.Content) > 0 {
+				insert += ", "
+			}
+			lines[value.Line-1] = line[:open+1] + insert + line[open+1:]
+			break
+		}
+		end := strings.LastIndex(line, "]")
+		if end < open {
+			return nil, fmt.Errorf("cannot edit resources at line %d (a list over several lines)", value.Line)
+		}
+		lines[value.Line-1] = line[:end] + ", " + ref + line[end:]
+	case value.Kind == yaml.SequenceNode && len(value.Content) > 0:
+		item := value.Content[0]
+		at := item.Line - 1
+		if !first {
+			item = value.Content[len(value.Content)-1]
+			at = item.Line
+		}
+		line := lines[item.Line-1]
+		dash := strings.Index(line, "-")
+		if dash < 0 || strings.TrimSpace(line[:dash]) != "" || (!first && item.Kind != yaml.ScalarNode) {
+			return nil, fmt.Errorf("cannot edit resources at line %d", item.Line)
+		}
+		lines = insertLine(lines, at, line[:dash]+"- "+ref)
+	case value.Tag == "!!null":
+		lines = insertLine(lines, key.Line, "  - "+ref)
+	default:
+		return nil, fmt.Errorf("resources at line %d is not a list", key.Line)
+	}
+	return joinLines(lines), nil
+}
+
+// removeListItem removes a scalar item from the list seq, deleting its line
+// in a block list or the item and its comma in a flow list on one line
+func removeListItem(data []byte, seq, item *yaml.Node) ([]byte, error) {
+	lines := splitLines(data)
+	line := lines[item.Line-1]
+	if seq.Style&yaml.FlowStyle == 0 {
+		dash := strings.Index(line, "-")
+		if dash < 0 || strings.TrimSpace(line[:dash]) != "" {
+			return nil, fmt.Errorf("cannot remove %q at line %d", item.Value, item.Line)
+		}
+		return joinLines(append(lines[:item.Line-1:item.Line-1], lines[item.Line:]...)), nil
+	}
+
+	if seq.Line != item.Line || !strings.Contains(line[seq.Column-1:], "]") {
+		return nil, fmt.Errorf("cannot remove %q at line %d (a list over several lines)", item.Value, item.Line)
+	}
+	start := item.Column - 1
+	end := start + len(item.Value)
+	if q := line[start]; q == '"' || q == '\'' {
+		if close := strings.IndexByte(line[start+1:], q); close >= 0 {
+			end = start + 1 + close + 1
+		}
+	}
+	rest := strings.TrimLeft(line[end:], " ")
+	if strings.HasPrefix(rest, ",") {
+		end = len(line) - len(strings.TrimLeft(rest[1:], " "))
+	} else if before := strings.TrimRight(line[:start], " "); strings.HasSuffix(before, ",") {
+		start = len(before) - 1
+	}
+	lines[item.Line-1] = line[:start] + line[end:]
+	return joinLines(lines), nil
+}
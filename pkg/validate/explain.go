@@ -0,0 +1,69 @@
+package validate
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// RulesForPath returns the validation rules that inspect a repo-relative path
+// The list mirrors where each check in ClusterValidator looks; it does not
+// evaluate the rules. Image and workload rules run on rendered output of deployable overlays.
+func RulesForPath(relPath string) []string {
+	parts := strings.Split(filepath.ToSlash(filepath.Clean(relPath)), "/")
+
+	var rules []string
+	switch parts[0] {
+	case "clusters":
+		rules = append(rules, "cluster-missing-dir", "cluster-missing-bootstrap-file", "kustomize-build-fail")
+	case "apps":
+		if overlay := appOverlayRoot(parts); overlay != "" {
+			rules = append(rules, "app-overlay-legacy-flat", "app-overlay-unknown-cluster", "app-overlay-missing-base", "cluster-name-collision", "cluster-unregistered-dir")
+			// Stacks aggregate app + db and intentionally don't reference base
+			if overlay != "stack" {
+				rules = append(rules, "app-overlay-wrong-base-ref")
+			}
+			rules = append(rules, RuleImageTagMissing, RuleImageTagLatest, RuleImageTagFloating)
+			rules = append(rules, WorkloadRules...)
+		}
+	case "infrastructure", "operators", "security":
+		if len(parts) >= 2 {
+			rules = append(rules, parts[0]+"-component-structure")
+		}
+		if len(parts) >= 3 && parts[2] == "overlays" {
+			rules = append(rules, parts[0]+"-overlay-base-ref")
+			rules = append(rules, RuleImageTagMissing, RuleImageTagLatest, RuleImageTagFloating)
+			rules = append(rules, WorkloadRules...)
+		}
+	case "argocd-apps":
+		if len(parts) >= 2 && parts[1] == "applications" {
+			rules = append(rules, "argocd-app-legacy-path", "argocd-appset-expand-fail", "app-create-namespace")
+		}
+		if len(parts) >= 2 && parts[1] == "infrastructure" {
+			rules = append(rules, "argocd-app-no-flat-infra-base", "argocd-app-no-clusters-infra", "argocd-app-no-clusters-operators")
+		}
+	}
+
+	// Namespace manifests are checked wherever they live; the trailing slash
+	// lets a directory match its own location prefix
+	switch (&ClusterValidator{}).classifyNamespaceLocation(strings.Join(parts, "/") + "/") {
+	case "legacy":
+		rules = append(rules, "namespace-legacy-location")
+	case "wrong":
+		rules = append(rules, "namespace-wrong-location")
+	}
+	rules = append(rules, "namespace-duplicate")
+
+	return rules
+}
+
+// appOverlayRoot returns "overlays", "stack" or "db/overlays" for paths inside
+// an app's overlay directories, or "" for anything else under apps/
+func appOverlayRoot(parts []string) string {
+	if len(parts) >= 3 && (parts[2] == "overlays" || parts[2] == "stack") {
+		return parts[2]
+	}
+	if len(parts) >= 4 && parts[2] == "db" && parts[3] == "overlays" {
+		return "db/overlays"
+	}
+	return ""
+}
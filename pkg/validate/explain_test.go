@@ -0,0 +1,67 @@
+package validate
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRulesForPath(t *testing.T) {
+	tests := []struct {
+		path    string
+		want    []string
+		notWant []string
+	}{
+		{
+			path:    "apps/coder/overlays/erauner-home/production",
+			want:    []string{"app-overlay-wrong-base-ref", RuleImageTagLatest, "namespace-wrong-location"},
+			notWant: []string{"cluster-missing-dir"},
+		},
+		{
+			path:    "apps/coder/stack/erauner-home/production",
+			want:    []string{"app-overlay-missing-base"},
+			notWant: []string{"app-overlay-wrong-base-ref"},
+		},
+		{
+			path:    "apps/coder/base",
+			notWant: []string{"app-overlay-missing-base", RuleImageTagLatest},
+		},
+		{
+			path: "operators/cert-manager/overlays/erauner-home",
+			want: []string{"operators-component-structure", "operators-overlay-base-ref", RuleImageTagFloating},
+		},
+		{
+			path: "clusters/erauner-home/bootstrap",
+			want: []string{"cluster-missing-bootstrap-file", "kustomize-build-fail"},
+		},
+		{
+			path: "argocd-apps/applications",
+			want: []string{"argocd-app-legacy-path", "argocd-appset-expand-fail"},
+		},
+		{
+			path:    "security/namespaces",
+			want:    []string{"namespace-duplicate"},
+			notWant: []string{"namespace-wrong-location", "namespace-legacy-location"},
+		},
+		{
+			path: "infrastructure/namespaces/apps",
+			want: []string{"namespace-legacy-location"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			rules := RulesForPath(tt.path)
+			joined := "," + strings.Join(rules, ",") + ","
+			for _, rule := range tt.want {
+				if !strings.Contains(joined, ","+rule+",") {
+					t.Errorf("expected %s in %v", rule, rules)
+				}
+			}
+			for _, rule := range tt.notWant {
+				if strings.Contains(joined, ","+rule+",") {
+					t.Errorf("did not expect %s in %v", rule, rules)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,437 @@
+package validate
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Edit is one change Fix made (or, with DryRun, would make) to a file
+type Edit struct {
+	Rule   string `json:"rule"`
+	Path   string `json:"path"`
+	Change string `json:"change"`
+}
+
+// Unfixed is a finding of a fixable rule that Fix left alone, and why
+type Unfixed struct {
+	Result
+	Reason string `json:"reason"`
+}
+
+// FixReport lists what Fix changed and what it could not
+type FixReport struct {
+	Edits   []Edit    `json:"edits"`
+	Unfixed []Unfixed `json:"unfixed,omitempty"`
+}
+
+// Files counts the distinct files edited
+func (r FixReport) Files() int {
+	files := make(map[string]bool)
+	for _, e := range r.Edits {
+		files[e.Path] = true
+	}
+	return len(files)
+}
+
+// FixOptions configures Fix
+type FixOptions struct {
+	// Clusters are the registered clusters; a legacy Application path is
+	// re-pointed at the one cluster overlay that exists for its environment
+	Clusters []string
+
+	// DryRun reports the edits without writing them
+	DryRun bool
+}
+
+// fixFunc rewrites data (the file a finding points at) to resolve it,
+// returning the changes made, or a reason when it can't
+type fixFunc func(repoPath, relPath string, data []byte, opts FixOptions) ([]byte, []string, error)
+
+// fixers are the automatic fixes, by rule
+var fixers = map[string]fixFunc{
+	"app-overlay-missing-base":        fixMissingBase,
+	"app-overlay-wrong-base-ref":      fixWrongBaseRef,
+	"infrastructure-overlay-base-ref": fixMissingBase,
+	"operators-overlay-base-ref":      fixMissingBase,
+	"security-overlay-base-ref":       fixMissingBase,
+	"argocd-app-legacy-path":          fixLegacyAppPaths,
+}
+
+// FixableRules lists the rules Fix has an automatic fix for
+func FixableRules() []string {
+	rules := make([]string, 0, len(fixers))
+	for rule := range fixers {
+		rules = append(rules, rule)
+	}
+	sort.Strings(rules)
+	return rules
+}
+
+// Fixable reports whether Fix has an automatic fix for rule
+func Fixable(rule string) bool {
+	return fixers[rule] != nil
+}
+
+// Fix rewrites the kustomization.yaml and Application files that unsuppressed
+// findings of fixable rules point at, in place. Each file is edited once per
+// rule however many findings point at it; findings that can't be fixed
+// mechanically are reported as Unfixed. Only a failed write is an error.
+func Fix(repoPath string, results []Result, opts FixOptions) (FixReport, error) {
+	report := FixReport{Edits: []Edit{}}
+	type target struct{ rule, path string }
+	done := make(map[target]bool)
+	edited := make(map[string][]byte)
+
+	for _, r := range results {
+		fix := fixers[r.Rule]
+		t := target{r.Rule, r.Path}
+		if fix == nil || r.Suppressed || done[t] {
+			continue
+		}
+		done[t] = true
+
+		data, ok := edited[r.Path]
+		if !ok {
+			var err error
+			if data, err = os.ReadFile(filepath.Join(repoPath, r.Path)); err != nil {
+				report.Unfixed = append(report.Unfixed, Unfixed{Result: r, Reason: fmt.Sprintf("cannot read %s: %v", r.Path, err)})
+				continue
+			}
+		}
+		fixed, changes, err := fix(repoPath, r.Path, data, opts)
+		if err == nil {
+			err = checkYAML(fixed)
+		}
+		if err != nil {
+			report.Unfixed = append(report.Unfixed, Unfixed{Result: r, Reason: err.Error()})
+			continue
+		}
+		edited[r.Path] = fixed
+		for _, change := range changes {
+			report.Edits = append(report.Edits, Edit{Rule: r.Rule, Path: r.Path, Change: change})
+		}
+	}
+
+	if opts.DryRun {
+		return report, nil
+	}
+	paths := make([]string, 0, len(edited))
+	for path := range edited {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		file := filepath.Join(repoPath, path)
+		info, err := os.Stat(file)
+		if err != nil {
+			return report, err
+		}
+		if err := os.WriteFile(file, edited[path], info.Mode().Perm()); err != nil {
+			return report, fmt.Errorf("failed to write %s: %w", path, err)
+		}
+	}
+	return report, nil
+}
+
+// checkYAML guards against an edit leaving a file unparseable
+func checkYAML(data []byte) error {
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	for {
+		var node yaml.Node
+		if err := decoder.Decode(&node); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return fmt.Errorf("edit would leave invalid YAML: %v", err)
+		}
+	}
+}
+
+// expectedBaseRef is the reference from an overlay's kustomization.yaml to the
+// base next to its overlays/ directory, e.g. ../../../base from
+// apps/<app>/overlays/<cluster>/<env>/kustomization.yaml
+func expectedBaseRef(repoPath, relPath string) (string, error) {
+	dir := filepath.Dir(filepath.FromSlash(relPath))
+	parts := strings.Split(dir, string(filepath.Separator))
+	i := len(parts) - 1
+	for i >= 0 && parts[i] != "overlays" {
+		i--
+	}
+	if i <= 0 || filepath.Base(relPath) != "kustomization.yaml" {
+		return "", fmt.Errorf("%s is not an overlay kustomization.yaml", relPath)
+	}
+	base := filepath.Join(append(parts[:i:i], "base")...)
+	if info, err := os.Stat(filepath.Join(repoPath, base)); err != nil || !info.IsDir() {
+		return "", fmt.Errorf("%s does not exist", filepath.ToSlash(base))
+	}
+	ref, err := filepath.Rel(dir, base)
+	if err != nil {
+		return "", err
+	}
+	return filepath.ToSlash(ref), nil
+}
+
+// fixMissingBase adds the overlay's base to the front of resources
+func fixMissingBase(repoPath, relPath string, data []byte, _ FixOptions) ([]byte, []string, error) {
+	ref, err := expectedBaseRef(repoPath, relPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	root, err := parseDocument(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, item := range sequenceItems(root, "resources", "bases") {
+		if strings.TrimSuffix(item.Value, "/") == ref {
+			return data, nil, nil // fixed by an earlier finding
+		}
+	}
+
+	fixed, err := addResource(data, root, ref, true)
+	if err != nil {
+		return nil, nil, err
+	}
+	return fixed, []string{"add " + ref + " to resources"}, nil
+}
+
+// baseRefPattern matches a reference to a base directory (or a file in it)
+// made only of ../ steps, the only kind fixWrongBaseRef re-points
+var baseRefPattern = regexp.MustCompile(`^(\.\./)+base(/.*)?$`)
+
+// fixWrongBaseRef re-points ../base references at the overlay's base
+func fixWrongBaseRef(repoPath, relPath string, data []byte, _ FixOptions) ([]byte, []string, error) {
+	ref, err := expectedBaseRef(repoPath, relPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	root, err := parseDocument(data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	lines := splitLines(data)
+	var changes []string
+	for _, item := range sequenceItems(root, "resources", "bases") {
+		value := strings.TrimSuffix(item.Value, "/")
+		if !baseRefPattern.MatchString(value) || value == ref || strings.HasPrefix(value, ref+"/") {
+			continue
+		}
+		rest := strings.TrimPrefix(value[strings.Index(value, "base"):], "base")
+		if err := replaceScalar(lines, item, ref+rest); err != nil {
+			return nil, nil, err
+		}
+		changes = append(changes, fmt.Sprintf("replace %s with %s", item.Value, ref+rest))
+	}
+	if len(changes) == 0 {
+		return nil, nil, fmt.Errorf("no ../base reference to re-point at %s", ref)
+	}
+	return joinLines(lines), changes, nil
+}
+
+// fixLegacyAppPaths re-points the legacy flat overlay paths of the
+// Applications in a file at apps/<app>/<overlays>/<cluster>/<env>, when
+// exactly one registered cluster has that overlay
+func fixLegacyAppPaths(repoPath, relPath string, data []byte, opts FixOptions) ([]byte, []string, error) {
+	lines := splitLines(data)
+	var changes, problems []string
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	for {
+		var node yaml.Node
+		if err := decoder.Decode(&node); err != nil {
+			break
+		}
+		if len(node.Content) == 0 {
+			continue
+		}
+		doc := node.Content[0]
+		if _, kind := mappingEntry(doc, "kind"); kind == nil || kind.Value != "Application" {
+			continue
+		}
+		_, spec := mappingEntry(doc, "spec")
+		destination := ""
+		if _, dest := mappingEntry(spec, "destination"); dest != nil {
+			if _, name := mappingEntry(dest, "name"); name != nil {
+				destination = name.Value
+			}
+		}
+
+		for _, p := range sourcePathNodes(doc) {
+			prefix, dir, env, ok := legacyAppPath(p.Value, opts.Clusters)
+			if !ok {
+				continue
+			}
+			cluster, err := overlayCluster(repoPath, dir, env, destination, opts.Clusters)
+			if err != nil {
+				problems = append(problems, err.Error())
+				continue
+			}
+			newPath := prefix + dir + "/" + cluster + "/" + env
+			if err := replaceScalar(lines, p, newPath); err != nil {
+				return nil, nil, err
+			}
+			changes = append(changes, fmt.Sprintf("replace path %s with %s", p.Value, newPath))
+		}
+	}
+
+	if len(changes) == 0 {
+		if len(problems) > 0 {
+			return nil, nil, fmt.Errorf("%s", strings.Join(problems, "; "))
+		}
+		return nil, nil, fmt.Errorf("no legacy path in an Application in %s (ApplicationSet-generated paths are not rewritten)", relPath)
+	}
+	return joinLines(lines), changes, nil
+}
+
+// legacyAppPath splits a legacy flat Application path (see
+// validateAppSourcePath) into its "./" prefix, overlay directory, and env
+func legacyAppPath(sourcePath string, clusters []string) (prefix, dir, env string, ok bool) {
+	normalized := strings.TrimPrefix(sourcePath, "./")
+	prefix = strings.TrimSuffix(sourcePath, normalized)
+	parts := strings.Split(strings.TrimSuffix(normalized, "/"), "/")
+	if len(parts) < 4 || parts[0] != "apps" {
+		return "", "", "", false
+	}
+	switch {
+	case len(parts) == 4 && (parts[2] == "overlays" || parts[2] == "stack"):
+		for _, c := range clusters {
+			if parts[3] == c {
+				return "", "", "", false
+			}
+		}
+		return prefix, strings.Join(parts[:3], "/"), parts[3], true
+	case len(parts) == 5 && parts[2] == "db" && parts[3] == "overlays":
+		return prefix, strings.Join(parts[:4], "/"), parts[4], true
+	}
+	return "", "", "", false
+}
+
+// overlayCluster picks the cluster whose <dir>/<cluster>/<env> overlay exists,
+// preferring the Application's destination when several do
+func overlayCluster(repoPath, dir, env, destination string, clusters []string) (string, error) {
+	var found []string
+	for _, c := range clusters {
+		if _, err := os.Stat(filepath.Join(repoPath, dir, c, env, "kustomization.yaml")); err == nil {
+			found = append(found, c)
+		}
+	}
+	switch {
+	case len(found) == 1:
+		return found[0], nil
+	case len(found) == 0:
+		return "", fmt.Errorf("no %s/<cluster>/%s overlay exists yet; move the overlay first", dir, env)
+	}
+	for _, c := range found {
+		if c == destination {
+			return c, nil
+		}
+	}
+	return "", fmt.Errorf("%s/%s exists for several clusters (%s); set spec.destination.name", dir, env, strings.Join(found, ", "))
+}
+
+// sourcePathNodes returns the source path nodes of an Application document
+// (spec.source.path and spec.sources[].path)
+func sourcePathNodes(doc *yaml.Node) []*yaml.Node {
+	_, spec := mappingEntry(doc, "spec")
+	var paths []*yaml.Node
+	if _, source := mappingEntry(spec, "source"); source != nil {
+		if _, p := mappingEntry(source, "path"); p != nil {
+			paths = append(paths, p)
+		}
+	}
+	if _, sources := mappingEntry(spec, "sources"); sources != nil && sources.Kind == yaml.SequenceNode {
+		for _, source := range sources.Content {
+			if _, p := mappingEntry(source, "path"); p != nil {
+				paths = append(paths, p)
+			}
+		}
+	}
+	return paths
+}
+
+// parseDocument parses a single-document YAML file to its root mapping
+func parseDocument(data []byte) (*yaml.Node, error) {
+	var node yaml.Node
+	if err := yaml.Unmarshal(data, &node); err != nil {
+		return nil, fmt.Errorf("failed to parse: %v", err)
+	}
+	if len(node.Content) == 0 || node.Content[0].Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("not a YAML mapping")
+	}
+	return node.Content[0], nil
+}
+
+// mappingEntry returns the key and value nodes of key in a mapping node
+func mappingEntry(mapping *yaml.Node, key string) (*yaml.Node, *yaml.Node) {
+	if mapping == nil || mapping.Kind != yaml.MappingNode {
+		return nil, nil
+	}
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return mapping.Content[i], mapping.Content[i+1]
+		}
+	}
+	return nil, nil
+}
+
+// sequenceItems returns the scalar items of the lists under keys
+func sequenceItems(mapping *yaml.Node, keys ...string) []*yaml.Node {
+	var items []*yaml.Node
+	for _, key := range keys {
+		_, value := mappingEntry(mapping, key)
+		if value == nil || value.Kind != yaml.SequenceNode {
+			continue
+		}
+		for _, item := range value.Content {
+			if item.Kind == yaml.ScalarNode {
+				items = append(items, item)
+			}
+		}
+	}
+	return items
+}
+
+// replaceScalar replaces a scalar's value in place, keeping its quoting
+// and the rest of its line
+func replaceScalar(lines []string, node *yaml.Node, value string) error {
+	if node.Line < 1 || node.Line > len(lines) {
+		return fmt.Errorf("cannot locate %q", node.Value)
+	}
+	line := lines[node.Line-1]
+	start := node.Column - 1
+	if start < 0 || start > len(line) {
+		return fmt.Errorf("cannot locate %q at line %d", node.Value, node.Line)
+	}
+	i := strings.Index(line[start:], node.Value)
+	if i < 0 || i > 1 { // the value, or its opening quote, starts the node
+		return fmt.Errorf("cannot locate %q at line %d", node.Value, node.Line)
+	}
+	at := start + i
+	lines[node.Line-1] = line[:at] + value + line[at+len(node.Value):]
+	return nil
+}
+
+func splitLines(data []byte) []string {
+	return strings.Split(string(data), "\n")
+}
+
+func joinLines(lines []string) []byte {
+	return []byte(strings.Join(lines, "\n"))
+}
+
+// insertLine inserts line before index i
+func insertLine(lines []string, i int, line string) []string {
+	lines = append(lines, "")
+	copy(lines[i+1:], lines[i:])
+	lines[i] = line
+	return lines
+}
@@ -0,0 +1,168 @@
+package validate
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFix_OverlayBaseRefs(t *testing.T) {
+	repo := writeRepoFiles(t, map[string]string{
+		"clusters.yaml":                      "clusters: [erauner-home]\n",
+		"apps/coder/base/kustomization.yaml": "resources: [deployment.yaml]\n",
+		// Block list: base goes first, at the list's indentation
+		"apps/coder/overlays/erauner-home/production/kustomization.yaml": "kind: Kustomization\nresources:\n    - ../../../other # keep\n    - route.yaml\n",
+		// Flow list, and a legacy flat overlay (../../base)
+		"apps/coder/overlays/staging/kustomization.yaml": "kind: Kustomization\nresources: [../../other]\n",
+		// Wrong depth, quoted, with a file in base
+		"apps/coder/overlays/erauner-home/dev/kustomization.yaml": "resources:\n  - \"../../base\"\n  - ../../base/secret.yaml\n",
+		// No base to point at
+		"apps/web/overlays/erauner-home/production/kustomization.yaml": "resources:\n  - ../../../other\n",
+	})
+
+	v := NewClusterValidator(repo, false)
+	clusters := []string{"erauner-home"}
+	results := v.ValidateAppOverlayStructure(clusters)
+
+	dry, err := Fix(repo, results, FixOptions{Clusters: clusters, DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data := readRepoFile(t, repo, "apps/coder/overlays/staging/kustomization.yaml"); data != "kind: Kustomization\nresources: [../../other]\n" {
+		t.Errorf("dry run wrote %q", data)
+	}
+
+	report, err := Fix(repo, results, FixOptions{Clusters: clusters})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Edits) != len(dry.Edits) || report.Files() != 3 {
+		t.Errorf("edits = %+v, want the dry run's %d edits to 3 files", report.Edits, len(dry.Edits))
+	}
+	if len(report.Unfixed) != 1 || report.Unfixed[0].Path != "apps/web/overlays/erauner-home/production/kustomization.yaml" ||
+		!strings.Contains(report.Unfixed[0].Reason, "apps/web/base does not exist") {
+		t.Errorf("unfixed = %+v", report.Unfixed)
+	}
+
+	want := map[string]string{
+		"apps/coder/overlays/erauner-home/production/kustomization.yaml": "kind: Kustomization\nresources:\n    - ../../../base\n    - ../../../other # keep\n    - route.yaml\n",
+		"apps/coder/overlays/staging/kustomization.yaml":                 "kind: Kustomization\nresources: [../../base, ../../other]\n",
+		"apps/coder/overlays/erauner-home/dev/kustomization.yaml":        "resources:\n  - \"../../../base\"\n  - ../../../base/secret.yaml\n",
+	}
+	for path, content := range want {
+		if got := readRepoFile(t, repo, path); got != content {
+			t.Errorf("%s =\n%s\nwant\n%s", path, got, content)
+		}
+	}
+
+	for _, r := range v.ValidateAppOverlayStructure(clusters) {
+		if r.Rule == "app-overlay-missing-base" || r.Rule == "app-overlay-wrong-base-ref" {
+			if !strings.HasPrefix(r.Path, "apps/web/") {
+				t.Errorf("finding after fix: %+v", r)
+			}
+		}
+	}
+}
+
+func TestFix_MissingResources(t *testing.T) {
+	repo := writeRepoFiles(t, map[string]string{
+		"infrastructure/dns/base/kustomization.yaml":                   "resources: []\n",
+		"infrastructure/dns/overlays/erauner-home/kustomization.yaml":  "patches:\n  - path: patch.yaml",
+		"infrastructure/dns/overlays/erauner-other/kustomization.yaml": "resources:\n",
+	})
+	results := []Result{
+		{Rule: "infrastructure-overlay-base-ref", Path: "infrastructure/dns/overlays/erauner-home/kustomization.yaml"},
+		{Rule: "infrastructure-overlay-base-ref", Path: "infrastructure/dns/overlays/erauner-other/kustomization.yaml"},
+		{Rule: "infrastructure-overlay-base-ref", Path: "infrastructure/dns/overlays/erauner-other/kustomization.yaml"},
+		{Rule: "infrastructure-overlay-base-ref", Path: "infrastructure/dns/overlays/erauner-lab", Suppressed: true},
+		{Rule: "namespace-duplicate", Path: "infrastructure/dns/base/kustomization.yaml"},
+	}
+	report, err := Fix(repo, results, FixOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Edits) != 2 || len(report.Unfixed) != 0 {
+		t.Errorf("report = %+v, want one edit per file", report)
+	}
+	if got := readRepoFile(t, repo, "infrastructure/dns/overlays/erauner-home/kustomization.yaml"); got != "patches:\n  - path: patch.yaml\nresources:\n  - ../../base\n" {
+		t.Errorf("appended resources = %q", got)
+	}
+	if got := readRepoFile(t, repo, "infrastructure/dns/overlays/erauner-other/kustomization.yaml"); got != "resources:\n  - ../../base\n" {
+		t.Errorf("empty resources = %q", got)
+	}
+}
+
+func TestFix_LegacyAppPaths(t *testing.T) {
+	app := func(dest, path string) string {
+		return "apiVersion: argoproj.io/v1alpha1\nkind: Application\nmetadata:\n  name: coder\nspec:\n  destination:\n    name: " + dest +
+			"\n  source:\n    repoURL: https://example.com/homelab-k8s.git\n    path: " + path + " # overlay\n"
+	}
+	repo := writeRepoFiles(t, map[string]string{
+		"apps/coder/overlays/erauner-home/production/kustomization.yaml": "resources: [../../../base]\n",
+		"apps/coder/overlays/erauner-lab/staging/kustomization.yaml":     "resources: [../../../base]\n",
+		"apps/coder/overlays/erauner-home/staging/kustomization.yaml":    "resources: [../../../base]\n",
+		"argocd-apps/applications/coder.yaml": app("in-cluster", "apps/coder/overlays/production") + "---\n" +
+			app("erauner-lab", "./apps/coder/overlays/staging") + "---\n" +
+			app("erauner-home", "apps/coder/overlays/erauner-home/production"),
+		"argocd-apps/applications/db.yaml":  app("in-cluster", "apps/db/db/overlays/production"),
+		"argocd-apps/applications/web.yaml": app("in-cluster", "apps/web/overlays/staging"),
+	})
+	clusters := []string{"erauner-home", "erauner-lab"}
+	v := NewClusterValidator(repo, false)
+	results := v.ValidateArgoCDAppPaths(clusters)
+
+	report, err := Fix(repo, results, FixOptions{Clusters: clusters})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Edits) != 2 {
+		t.Errorf("edits = %+v, want 2", report.Edits)
+	}
+	reasons := make(map[string]string)
+	for _, u := range report.Unfixed {
+		reasons[u.Path] = u.Reason
+	}
+	if !strings.Contains(reasons["argocd-apps/applications/db.yaml"], "no apps/db/db/overlays/<cluster>/production overlay") ||
+		!strings.Contains(reasons["argocd-apps/applications/web.yaml"], "no apps/web/overlays/<cluster>/staging overlay") {
+		t.Errorf("unfixed = %+v", report.Unfixed)
+	}
+
+	want := app("in-cluster", "apps/coder/overlays/erauner-home/production") + "---\n" +
+		app("erauner-lab", "./apps/coder/overlays/erauner-lab/staging") + "---\n" +
+		app("erauner-home", "apps/coder/overlays/erauner-home/production")
+	if got := readRepoFile(t, repo, "argocd-apps/applications/coder.yaml"); got != want {
+		t.Errorf("coder.yaml =\n%s\nwant\n%s", got, want)
+	}
+	for _, r := range v.ValidateArgoCDAppPaths(clusters) {
+		if r.Path == "argocd-apps/applications/coder.yaml" {
+			t.Errorf("finding after fix: %+v", r)
+		}
+	}
+}
+
+func TestFixableRules(t *testing.T) {
+	known := make(map[string]bool)
+	for _, rule := range Registered() {
+		for _, name := range NamesOf(rule) {
+			known[name] = true
+		}
+	}
+	for _, rule := range FixableRules() {
+		if !known[rule] {
+			t.Errorf("fixable rule %q is not a registered rule", rule)
+		}
+		if !Fixable(rule) {
+			t.Errorf("Fixable(%q) = false", rule)
+		}
+	}
+}
+
+func readRepoFile(t *testing.T, repo, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(repo, filepath.FromSlash(path)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
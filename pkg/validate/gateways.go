@@ -0,0 +1,315 @@
+package validate
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Gateway API rules, evaluated across every rendered directory of a cluster
+const (
+	// RuleRouteParentMissing flags an HTTPRoute parentRef naming a Gateway no
+	// directory renders for the cluster
+	RuleRouteParentMissing = "route-parent-missing"
+	// RuleRouteListenerMissing flags a parentRef whose sectionName or port
+	// matches none of the Gateway's listeners
+	RuleRouteListenerMissing = "route-listener-missing"
+	// RuleRouteHostnameDuplicate flags a hostname claimed by several HTTPRoutes
+	RuleRouteHostnameDuplicate = "route-hostname-duplicate"
+	// RuleRouteHostnameNoCertificate flags a route hostname no Certificate covers
+	RuleRouteHostnameNoCertificate = "route-hostname-no-certificate"
+)
+
+// GatewayRules lists every Gateway API rule
+var GatewayRules = []string{
+	RuleRouteParentMissing,
+	RuleRouteListenerMissing,
+	RuleRouteHostnameDuplicate,
+	RuleRouteHostnameNoCertificate,
+}
+
+// gatewayGroup is the API group of Gateway API resources
+const gatewayGroup = "gateway.networking.k8s.io"
+
+// gatewayListener is one listener of a rendered Gateway
+type gatewayListener struct {
+	Name     string
+	Port     int
+	Hostname string
+}
+
+// routeParent is one parentRef of an HTTPRoute, with defaults applied
+type routeParent struct {
+	Namespace   string
+	Name        string
+	SectionName string
+	Port        int
+}
+
+// httpRoute is a rendered HTTPRoute
+type httpRoute struct {
+	Dir       string
+	Namespace string
+	Name      string
+	Parents   []routeParent
+	Hostnames []string
+}
+
+func (r httpRoute) resource() string {
+	return "HTTPRoute/" + qualifiedName(r.Namespace, r.Name)
+}
+
+// clusterGateways indexes the Gateway API resources of one cluster
+type clusterGateways struct {
+	gateways map[string][]gatewayListener // namespace/name -> listeners
+	routes   []httpRoute
+	certs    []string // hostnames covered by Certificates or cert-manager annotated Gateways
+}
+
+// ValidateGatewayRefs cross-checks the Gateways, HTTPRoutes, and cert-manager
+// Certificates rendered for each cluster (manifests are keyed by directory;
+// clusterOf maps a directory to its cluster, "" when unknown, and such
+// directories are only compared with each other). It reports:
+//   - parentRefs to Gateways that are not rendered, or to listeners (by
+//     sectionName or port) the Gateway does not have
+//   - hostnames claimed by more than one HTTPRoute
+//   - route hostnames no Certificate dnsName covers, for clusters that render
+//     any Certificate (a listener of a Gateway annotated for cert-manager
+//     counts as a Certificate for its hostname)
+//
+// Hostnames with unresolved ${VAR} placeholders are not checked
+func ValidateGatewayRefs(manifests map[string]string, clusterOf func(dir string) string) ([]Result, error) {
+	dirs := make([]string, 0, len(manifests))
+	for dir := range manifests {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+
+	index := make(map[string]*clusterGateways)
+	var clusterNames []string
+	for _, dir := range dirs {
+		name := clusterOf(dir)
+		cg, ok := index[name]
+		if !ok {
+			cg = &clusterGateways{gateways: make(map[string][]gatewayListener)}
+			index[name] = cg
+			clusterNames = append(clusterNames, name)
+		}
+		if err := cg.add(dir, manifests[dir]); err != nil {
+			return nil, fmt.Errorf("%s: %w", dir, err)
+		}
+	}
+	sort.Strings(clusterNames)
+
+	results := []Result{} // Initialize to empty slice for consistent JSON output
+	for _, name := range clusterNames {
+		results = append(results, index[name].validate(name)...)
+	}
+	return results, nil
+}
+
+// add indexes the Gateway API resources of one rendered directory
+func (cg *clusterGateways) add(dir, manifest string) error {
+	dec := yaml.NewDecoder(strings.NewReader(manifest))
+	for {
+		var doc map[string]interface{}
+		err := dec.Decode(&doc)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to parse manifest: %w", err)
+		}
+		if doc == nil {
+			continue
+		}
+
+		kind, _ := doc["kind"].(string)
+		metadata, _ := doc["metadata"].(map[string]interface{})
+		name, _ := metadata["name"].(string)
+		namespace, _ := metadata["namespace"].(string)
+		spec, _ := doc["spec"].(map[string]interface{})
+
+		switch kind {
+		case "Gateway":
+			annotations, _ := metadata["annotations"].(map[string]interface{})
+			_, issuer := annotations["cert-manager.io/issuer"]
+			_, clusterIssuer := annotations["cert-manager.io/cluster-issuer"]
+			var listeners []gatewayListener
+			for _, l := range sequence(spec["listeners"]) {
+				listener, _ := l.(map[string]interface{})
+				lname, _ := listener["name"].(string)
+				port, _ := listener["port"].(int)
+				hostname, _ := listener["hostname"].(string)
+				listeners = append(listeners, gatewayListener{Name: lname, Port: port, Hostname: hostname})
+				if (issuer || clusterIssuer) && hostname != "" && listener["tls"] != nil {
+					cg.certs = append(cg.certs, hostname)
+				}
+			}
+			cg.gateways[qualifiedName(namespace, name)] = listeners
+		case "HTTPRoute":
+			route := httpRoute{Dir: dir, Namespace: namespace, Name: name}
+			for _, p := range sequence(spec["parentRefs"]) {
+				ref, _ := p.(map[string]interface{})
+				group, ok := ref["group"].(string)
+				if !ok {
+					group = gatewayGroup
+				}
+				refKind, ok := ref["kind"].(string)
+				if !ok {
+					refKind = "Gateway"
+				}
+				if group != gatewayGroup || refKind != "Gateway" {
+					continue // e.g. a Service parent for mesh routing
+				}
+				parent := routeParent{Namespace: namespace}
+				if ns, ok := ref["namespace"].(string); ok {
+					parent.Namespace = ns
+				}
+				parent.Name, _ = ref["name"].(string)
+				parent.SectionName, _ = ref["sectionName"].(string)
+				parent.Port, _ = ref["port"].(int)
+				route.Parents = append(route.Parents, parent)
+			}
+			for _, h := range sequence(spec["hostnames"]) {
+				if host, ok := h.(string); ok && host != "" {
+					route.Hostnames = append(route.Hostnames, host)
+				}
+			}
+			cg.routes = append(cg.routes, route)
+		case "Certificate":
+			if cn, ok := spec["commonName"].(string); ok && cn != "" {
+				cg.certs = append(cg.certs, cn)
+			}
+			for _, h := range sequence(spec["dnsNames"]) {
+				if host, ok := h.(string); ok && host != "" {
+					cg.certs = append(cg.certs, host)
+				}
+			}
+		}
+	}
+}
+
+// validate checks the indexed routes of one cluster
+func (cg *clusterGateways) validate(clusterName string) []Result {
+	resultCluster := clusterName
+	if resultCluster == "" {
+		resultCluster = "global"
+	}
+	var results []Result
+	add := func(rule, path, severity, message string) {
+		results = append(results, Result{
+			Cluster:  resultCluster,
+			Rule:     rule,
+			Path:     path,
+			Message:  message,
+			Severity: severity,
+		})
+	}
+
+	claims := make(map[string][]httpRoute)
+	var hosts []string
+	for _, route := range cg.routes {
+		for _, parent := range route.Parents {
+			gateway := qualifiedName(parent.Namespace, parent.Name)
+			listeners, ok := cg.gateways[gateway]
+			if !ok {
+				add(RuleRouteParentMissing, route.Dir, "error",
+					fmt.Sprintf("%s parentRef Gateway/%s is not rendered for the cluster", route.resource(), gateway))
+				continue
+			}
+			if problem := missingListener(listeners, parent); problem != "" {
+				add(RuleRouteListenerMissing, route.Dir, "error",
+					fmt.Sprintf("%s parentRef Gateway/%s has no listener %s", route.resource(), gateway, problem))
+			}
+		}
+
+		seen := make(map[string]bool)
+		for _, host := range route.Hostnames {
+			host = normalizeHost(host)
+			if seen[host] || strings.Contains(host, "${") {
+				continue
+			}
+			seen[host] = true
+			if _, ok := claims[host]; !ok {
+				hosts = append(hosts, host)
+			}
+			claims[host] = append(claims[host], route)
+		}
+	}
+
+	sort.Strings(hosts)
+	for _, host := range hosts {
+		routes := claims[host]
+		if len(routes) > 1 {
+			others := make([]string, 0, len(routes)-1)
+			for _, other := range routes[1:] {
+				others = append(others, fmt.Sprintf("%s (%s)", other.resource(), other.Dir))
+			}
+			add(RuleRouteHostnameDuplicate, routes[0].Dir, "warn",
+				fmt.Sprintf("%s hostname %s is also claimed by %s", routes[0].resource(), host, strings.Join(others, ", ")))
+		}
+		if len(cg.certs) > 0 && !certificateCovers(cg.certs, host) {
+			for _, route := range routes {
+				add(RuleRouteHostnameNoCertificate, route.Dir, "warn",
+					fmt.Sprintf("%s hostname %s is not covered by any Certificate", route.resource(), host))
+			}
+		}
+	}
+	return results
+}
+
+// missingListener describes the sectionName/port a parentRef selects when no
+// listener of the Gateway matches it, or returns ""
+func missingListener(listeners []gatewayListener, parent routeParent) string {
+	if parent.SectionName == "" && parent.Port == 0 {
+		return ""
+	}
+	for _, l := range listeners {
+		if (parent.SectionName == "" || l.Name == parent.SectionName) && (parent.Port == 0 || l.Port == parent.Port) {
+			return ""
+		}
+	}
+	switch {
+	case parent.SectionName != "" && parent.Port != 0:
+		return fmt.Sprintf("%q on port %d", parent.SectionName, parent.Port)
+	case parent.SectionName != "":
+		return fmt.Sprintf("%q", parent.SectionName)
+	default:
+		return fmt.Sprintf("on port %d", parent.Port)
+	}
+}
+
+// certificateCovers reports whether a certificate hostname matches host; a
+// wildcard covers exactly one label (*.example.com covers a.example.com only)
+func certificateCovers(certs []string, host string) bool {
+	for _, cert := range certs {
+		cert = normalizeHost(cert)
+		if cert == host {
+			return true
+		}
+		if suffix, ok := strings.CutPrefix(cert, "*."); ok && !strings.HasPrefix(host, "*.") {
+			if label, rest, found := strings.Cut(host, "."); found && label != "" && rest == suffix {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// normalizeHost lowercases a hostname and drops a trailing dot
+func normalizeHost(host string) string {
+	return strings.ToLower(strings.TrimSuffix(host, "."))
+}
+
+// qualifiedName returns namespace/name, or name for unnamespaced resources
+func qualifiedName(namespace, name string) string {
+	if namespace == "" {
+		return name
+	}
+	return namespace + "/" + name
+}
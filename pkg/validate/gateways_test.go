@@ -0,0 +1,155 @@
+package validate_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/erauner/homelab-shadow/pkg/validate"
+)
+
+const testGateway = `apiVersion: gateway.networking.k8s.io/v1
+kind: Gateway
+metadata:
+  name: external
+  namespace: envoy-gateway
+  annotations:
+    cert-manager.io/cluster-issuer: letsencrypt
+spec:
+  listeners:
+  - name: http
+    port: 80
+    protocol: HTTP
+  - name: https
+    port: 443
+    protocol: HTTPS
+    hostname: "*.home.erauner.dev"
+    tls:
+      certificateRefs:
+      - name: wildcard-tls
+---
+apiVersion: cert-manager.io/v1
+kind: Certificate
+metadata:
+  name: apex
+  namespace: envoy-gateway
+spec:
+  dnsNames:
+  - home.erauner.dev
+`
+
+func TestValidateGatewayRefs(t *testing.T) {
+	manifests := map[string]string{
+		"infrastructure/envoy-gateway/overlays/erauner-home/production": testGateway,
+		"apps/coder/overlays/erauner-home/production": `apiVersion: gateway.networking.k8s.io/v1
+kind: HTTPRoute
+metadata:
+  name: coder
+  namespace: coder
+spec:
+  parentRefs:
+  - name: external
+    namespace: envoy-gateway
+    sectionName: https
+  - name: mesh
+    kind: Service
+    group: ""
+  hostnames:
+  - coder.home.erauner.dev
+  - home.erauner.dev
+`,
+		"apps/docs/overlays/erauner-home/production": `apiVersion: gateway.networking.k8s.io/v1
+kind: HTTPRoute
+metadata:
+  name: docs
+  namespace: docs
+spec:
+  parentRefs:
+  - name: external
+    namespace: envoy-gateway
+    sectionName: grpc
+  - name: internal
+    namespace: envoy-gateway
+  - name: external
+    namespace: envoy-gateway
+    port: 8443
+  hostnames:
+  - Coder.home.erauner.dev
+  - a.b.home.erauner.dev
+  - ${APP_HOST}
+`,
+		// Gateways rendered for another cluster don't satisfy refs
+		"apps/wiki/overlays/erauner-cloud/production": `apiVersion: gateway.networking.k8s.io/v1
+kind: HTTPRoute
+metadata:
+  name: wiki
+  namespace: wiki
+spec:
+  parentRefs:
+  - name: external
+    namespace: envoy-gateway
+  hostnames:
+  - wiki.cloud.erauner.dev
+`,
+	}
+
+	results, err := validate.ValidateGatewayRefs(manifests, clusterOfTestDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, r := range results {
+		got = append(got, r.Cluster+" "+r.Rule+" "+r.Severity+" "+r.Path+": "+r.Message)
+	}
+	want := []string{
+		"erauner-cloud route-parent-missing error apps/wiki/overlays/erauner-cloud/production: HTTPRoute/wiki/wiki parentRef Gateway/envoy-gateway/external is not rendered for the cluster",
+		`erauner-home route-listener-missing error apps/docs/overlays/erauner-home/production: HTTPRoute/docs/docs parentRef Gateway/envoy-gateway/external has no listener "grpc"`,
+		"erauner-home route-parent-missing error apps/docs/overlays/erauner-home/production: HTTPRoute/docs/docs parentRef Gateway/envoy-gateway/internal is not rendered for the cluster",
+		"erauner-home route-listener-missing error apps/docs/overlays/erauner-home/production: HTTPRoute/docs/docs parentRef Gateway/envoy-gateway/external has no listener on port 8443",
+		"erauner-home route-hostname-no-certificate warn apps/docs/overlays/erauner-home/production: HTTPRoute/docs/docs hostname a.b.home.erauner.dev is not covered by any Certificate",
+		"erauner-home route-hostname-duplicate warn apps/coder/overlays/erauner-home/production: HTTPRoute/coder/coder hostname coder.home.erauner.dev is also claimed by HTTPRoute/docs/docs (apps/docs/overlays/erauner-home/production)",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("results:\n%v\nwant:\n%v", got, want)
+	}
+}
+
+func TestValidateGatewayRefs_NoCertificates(t *testing.T) {
+	manifests := map[string]string{
+		"apps/coder/overlays/erauner-home/production": `apiVersion: gateway.networking.k8s.io/v1
+kind: Gateway
+metadata:
+  name: internal
+spec:
+  listeners:
+  - name: http
+    port: 80
+---
+apiVersion: gateway.networking.k8s.io/v1
+kind: HTTPRoute
+metadata:
+  name: coder
+spec:
+  parentRefs:
+  - name: internal
+    port: 80
+  hostnames:
+  - coder.lan
+`,
+	}
+
+	results, err := validate.ValidateGatewayRefs(manifests, clusterOfTestDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 0 {
+		t.Errorf("expected no findings without Certificates, got %+v", results)
+	}
+}
+
+func TestValidateGatewayRefs_InvalidManifest(t *testing.T) {
+	_, err := validate.ValidateGatewayRefs(map[string]string{"apps/web": "kind: ["}, clusterOfTestDir)
+	if err == nil {
+		t.Error("expected a parse error")
+	}
+}
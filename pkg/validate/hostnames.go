@@ -0,0 +1,178 @@
+package validate
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/erauner/homelab-shadow/pkg/cluster"
+	"gopkg.in/yaml.v3"
+)
+
+// Hostname rules, evaluated against rendered manifests
+const (
+	// RuleHostnameForeignDomain flags a hostname under another cluster's domain,
+	// usually an overlay copied between clusters without updating its hosts
+	RuleHostnameForeignDomain = "hostname-foreign-domain"
+	// RuleHostnameOutsideDomains flags a hostname under none of the cluster's domains
+	RuleHostnameOutsideDomains = "hostname-outside-domains"
+)
+
+// HostnameRef is a hostname a rendered resource serves or requests a certificate for
+type HostnameRef struct {
+	Kind  string // owning resource kind, e.g. HTTPRoute
+	Name  string // owning resource name
+	Field string // where the hostname was found, e.g. spec.hostnames
+	Host  string
+}
+
+// Resource returns the owning resource as Kind/name
+func (r HostnameRef) Resource() string {
+	return r.Kind + "/" + r.Name
+}
+
+// ExtractHostnames returns the hostnames of HTTPRoutes (and GRPC/TLS routes),
+// Gateway listeners, Ingresses, and cert-manager Certificates in a manifest
+func ExtractHostnames(manifest string) ([]HostnameRef, error) {
+	dec := yaml.NewDecoder(strings.NewReader(manifest))
+
+	var refs []HostnameRef
+	for {
+		var doc map[string]interface{}
+		err := dec.Decode(&doc)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse manifest: %w", err)
+		}
+		if doc == nil {
+			continue
+		}
+
+		kind, _ := doc["kind"].(string)
+		name := ""
+		if metadata, ok := doc["metadata"].(map[string]interface{}); ok {
+			name, _ = metadata["name"].(string)
+		}
+		spec, _ := doc["spec"].(map[string]interface{})
+		add := func(field string, host interface{}) {
+			if h, ok := host.(string); ok && h != "" {
+				refs = append(refs, HostnameRef{Kind: kind, Name: name, Field: field, Host: h})
+			}
+		}
+
+		switch kind {
+		case "HTTPRoute", "GRPCRoute", "TLSRoute":
+			for _, h := range sequence(spec["hostnames"]) {
+				add("spec.hostnames", h)
+			}
+		case "Gateway":
+			for _, l := range sequence(spec["listeners"]) {
+				listener, _ := l.(map[string]interface{})
+				add("spec.listeners.hostname", listener["hostname"])
+			}
+		case "Ingress":
+			for _, r := range sequence(spec["rules"]) {
+				rule, _ := r.(map[string]interface{})
+				add("spec.rules.host", rule["host"])
+			}
+			for _, t := range sequence(spec["tls"]) {
+				tls, _ := t.(map[string]interface{})
+				for _, h := range sequence(tls["hosts"]) {
+					add("spec.tls.hosts", h)
+				}
+			}
+		case "Certificate":
+			add("spec.commonName", spec["commonName"])
+			for _, h := range sequence(spec["dnsNames"]) {
+				add("spec.dnsNames", h)
+			}
+		}
+	}
+
+	return refs, nil
+}
+
+// sequence returns a decoded YAML sequence, or nil
+func sequence(v interface{}) []interface{} {
+	items, _ := v.([]interface{})
+	return items
+}
+
+// ValidateHostnames checks the hostnames in a manifest rendered for a cluster
+// against the domains registered in clusters.yaml
+//
+// A hostname is attributed to the cluster with the longest matching domain,
+// so erauner.dev on one cluster and cloud.erauner.dev on another can coexist.
+// Manifests without a cluster, clusters without domains, and hostnames with
+// unresolved ${VAR} placeholders are not checked.
+func ValidateHostnames(registry *cluster.Registry, clusterName, path, manifest string) []Result {
+	results := []Result{} // Initialize to empty slice for consistent JSON output
+
+	own, ok := registry.Get(clusterName)
+	if !ok || len(own.Domains) == 0 {
+		return results
+	}
+
+	refs, err := ExtractHostnames(manifest)
+	if err != nil {
+		return append(results, Result{
+			Cluster:  clusterName,
+			Rule:     "manifest-parse-fail",
+			Path:     path,
+			Message:  err.Error(),
+			Severity: "error",
+		})
+	}
+
+	for _, ref := range refs {
+		if strings.Contains(ref.Host, "${") {
+			continue
+		}
+		owner, domain := domainOwner(registry, ref.Host)
+		switch {
+		case owner == clusterName:
+			continue
+		case owner != "":
+			results = append(results, Result{
+				Cluster:  clusterName,
+				Rule:     RuleHostnameForeignDomain,
+				Path:     path,
+				Message:  fmt.Sprintf("%s %s %s is under %s's domain %s, not %s", ref.Resource(), ref.Field, ref.Host, owner, domain, strings.Join(own.Domains, ", ")),
+				Severity: "error",
+			})
+		default:
+			results = append(results, Result{
+				Cluster:  clusterName,
+				Rule:     RuleHostnameOutsideDomains,
+				Path:     path,
+				Message:  fmt.Sprintf("%s %s %s is not under %s's domains (%s)", ref.Resource(), ref.Field, ref.Host, clusterName, strings.Join(own.Domains, ", ")),
+				Severity: "warn",
+			})
+		}
+	}
+
+	return results
+}
+
+// domainOwner returns the cluster whose domain is the longest suffix of host,
+// and that domain; a wildcard host (*.example.com) matches like example.com
+func domainOwner(registry *cluster.Registry, host string) (string, string) {
+	host = strings.ToLower(strings.TrimSuffix(strings.TrimPrefix(host, "*."), "."))
+
+	owner, best := "", ""
+	for _, c := range registry.Clusters() {
+		for _, domain := range c.Domains {
+			domain = strings.ToLower(strings.TrimSuffix(strings.TrimPrefix(domain, "."), "."))
+			if host != domain && !strings.HasSuffix(host, "."+domain) {
+				continue
+			}
+			if len(domain) > len(best) {
+				owner, best = c.Name, domain
+			}
+		}
+	}
+	return owner, best
+}
@@ -0,0 +1,106 @@
+package validate
+
+import (
+	"testing"
+
+	"github.com/erauner/homelab-shadow/pkg/cluster"
+)
+
+func TestExtractHostnames(t *testing.T) {
+	manifest := `apiVersion: gateway.networking.k8s.io/v1
+kind: HTTPRoute
+metadata:
+  name: web
+spec:
+  hostnames: [web.home.erauner.dev, www.home.erauner.dev]
+---
+apiVersion: networking.k8s.io/v1
+kind: Ingress
+metadata:
+  name: legacy
+spec:
+  rules:
+    - host: legacy.home.erauner.dev
+  tls:
+    - hosts: [legacy.home.erauner.dev]
+---
+apiVersion: cert-manager.io/v1
+kind: Certificate
+metadata:
+  name: wildcard
+spec:
+  commonName: "*.home.erauner.dev"
+  dnsNames: ["*.home.erauner.dev"]
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+`
+	refs, err := ExtractHostnames(manifest)
+	if err != nil {
+		t.Fatalf("ExtractHostnames() error = %v", err)
+	}
+	want := []HostnameRef{
+		{"HTTPRoute", "web", "spec.hostnames", "web.home.erauner.dev"},
+		{"HTTPRoute", "web", "spec.hostnames", "www.home.erauner.dev"},
+		{"Ingress", "legacy", "spec.rules.host", "legacy.home.erauner.dev"},
+		{"Ingress", "legacy", "spec.tls.hosts", "legacy.home.erauner.dev"},
+		{"Certificate", "wildcard", "spec.commonName", "*.home.erauner.dev"},
+		{"Certificate", "wildcard", "spec.dnsNames", "*.home.erauner.dev"},
+	}
+	if len(refs) != len(want) {
+		t.Fatalf("got %d hostnames, want %d: %v", len(refs), len(want), refs)
+	}
+	for i := range want {
+		if refs[i] != want[i] {
+			t.Errorf("refs[%d] = %+v, want %+v", i, refs[i], want[i])
+		}
+	}
+}
+
+func TestValidateHostnames(t *testing.T) {
+	registry := cluster.NewRegistry(cluster.SourceFile, []cluster.Cluster{
+		{Name: "erauner-home", Domains: []string{"home.erauner.dev"}},
+		{Name: "erauner-cloud", Domains: []string{"cloud.erauner.dev", "erauner.dev"}},
+		{Name: "lab"},
+	})
+
+	route := func(hosts string) string {
+		return "kind: HTTPRoute\nmetadata:\n  name: web\nspec:\n  hostnames: [" + hosts + "]\n"
+	}
+
+	tests := []struct {
+		name     string
+		cluster  string
+		manifest string
+		want     []string
+	}{
+		{"own domain", "erauner-home", route("web.home.erauner.dev, '*.home.erauner.dev'"), nil},
+		{"copied from cloud", "erauner-home", route("web.cloud.erauner.dev"), []string{RuleHostnameForeignDomain}},
+		{"parent domain belongs to cloud", "erauner-home", route("erauner.dev"), []string{RuleHostnameForeignDomain}},
+		{"longest domain wins", "erauner-cloud", route("web.home.erauner.dev, api.erauner.dev"), []string{RuleHostnameForeignDomain}},
+		{"unknown domain", "erauner-home", route("web.example.com"), []string{RuleHostnameOutsideDomains}},
+		{"unresolved placeholder", "erauner-home", route("'web.${CLUSTER_DOMAIN}'"), nil},
+		{"cluster without domains", "lab", route("web.cloud.erauner.dev"), nil},
+		{"no cluster", "", route("web.cloud.erauner.dev"), nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results := ValidateHostnames(registry, tt.cluster, "apps/web/overlays/x/production", tt.manifest)
+			var rules []string
+			for _, r := range results {
+				rules = append(rules, r.Rule)
+			}
+			if len(rules) != len(tt.want) {
+				t.Fatalf("got %v, want %v", results, tt.want)
+			}
+			for i := range rules {
+				if rules[i] != tt.want[i] {
+					t.Errorf("rule %d = %s, want %s", i, rules[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
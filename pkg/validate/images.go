@@ -0,0 +1,183 @@
+package validate
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Image tag rules, evaluated against rendered manifests
+const (
+	RuleImageTagLatest   = "image-tag-latest"
+	RuleImageTagMissing  = "image-tag-missing"
+	RuleImageTagFloating = "image-tag-floating"
+)
+
+// FloatingTags are tags that commonly move between releases
+// Images using them render identically while running different code
+var FloatingTags = map[string]bool{
+	"stable":  true,
+	"edge":    true,
+	"main":    true,
+	"master":  true,
+	"develop": true,
+	"dev":     true,
+	"nightly": true,
+	"release": true,
+	"lts":     true,
+}
+
+// containerListKeys are the pod spec fields that hold containers
+var containerListKeys = map[string]bool{
+	"containers":          true,
+	"initContainers":      true,
+	"ephemeralContainers": true,
+}
+
+// ImageRef is a container image referenced by a rendered resource
+type ImageRef struct {
+	Kind      string // owning resource kind, e.g. Deployment
+	Name      string // owning resource name
+	Container string
+	Image     string
+}
+
+// Resource returns the owning resource as Kind/name
+func (r ImageRef) Resource() string {
+	return r.Kind + "/" + r.Name
+}
+
+// SplitImage splits an image reference into repository, tag and digest
+// The registry port in "host:5000/app" is not mistaken for a tag
+func SplitImage(image string) (repository, tag, digest string) {
+	repository = image
+	if i := strings.Index(repository, "@"); i >= 0 {
+		repository, digest = repository[:i], repository[i+1:]
+	}
+	if i := strings.LastIndex(repository, ":"); i > strings.LastIndex(repository, "/") {
+		repository, tag = repository[:i], repository[i+1:]
+	}
+	return repository, tag, digest
+}
+
+// ExtractImages returns every container image in a multi-document manifest
+// Containers are found wherever a pod spec appears, so workloads, CronJobs
+// and CRDs embedding pod templates are all covered
+func ExtractImages(manifest string) ([]ImageRef, error) {
+	dec := yaml.NewDecoder(strings.NewReader(manifest))
+
+	var refs []ImageRef
+	for {
+		var doc map[string]interface{}
+		err := dec.Decode(&doc)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse manifest: %w", err)
+		}
+		if doc == nil {
+			continue
+		}
+
+		kind, _ := doc["kind"].(string)
+		name := ""
+		if metadata, ok := doc["metadata"].(map[string]interface{}); ok {
+			name, _ = metadata["name"].(string)
+		}
+
+		collectImages(doc, func(container, image string) {
+			refs = append(refs, ImageRef{Kind: kind, Name: name, Container: container, Image: image})
+		})
+	}
+
+	return refs, nil
+}
+
+// collectImages walks a decoded document calling fn for each container image
+func collectImages(node interface{}, fn func(container, image string)) {
+	switch v := node.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys) // deterministic output
+
+		for _, key := range keys {
+			if containerListKeys[key] {
+				if containers, ok := v[key].([]interface{}); ok {
+					for _, c := range containers {
+						container, ok := c.(map[string]interface{})
+						if !ok {
+							continue
+						}
+						image, ok := container["image"].(string)
+						if !ok {
+							continue
+						}
+						name, _ := container["name"].(string)
+						fn(name, image)
+					}
+					continue
+				}
+			}
+			collectImages(v[key], fn)
+		}
+	case []interface{}:
+		for _, item := range v {
+			collectImages(item, fn)
+		}
+	}
+}
+
+// ValidateImages checks that every image in a rendered manifest is pinned
+// Digest-pinned images always pass; otherwise missing, latest and floating
+// tags are reported as warnings (use .shadow.yaml rules to change severity)
+func ValidateImages(cluster, path, manifest string) []Result {
+	results := []Result{} // Initialize to empty slice for consistent JSON output
+
+	refs, err := ExtractImages(manifest)
+	if err != nil {
+		return append(results, Result{
+			Cluster:  cluster,
+			Rule:     "manifest-parse-fail",
+			Path:     path,
+			Message:  err.Error(),
+			Severity: "error",
+		})
+	}
+
+	for _, ref := range refs {
+		_, tag, digest := SplitImage(ref.Image)
+		if digest != "" {
+			continue
+		}
+
+		rule, reason := "", ""
+		switch {
+		case tag == "":
+			rule, reason = RuleImageTagMissing, "has no tag (implicitly latest)"
+		case tag == "latest":
+			rule, reason = RuleImageTagLatest, "uses the latest tag"
+		case FloatingTags[strings.ToLower(tag)]:
+			rule, reason = RuleImageTagFloating, fmt.Sprintf("uses floating tag %q", tag)
+		default:
+			continue
+		}
+
+		results = append(results, Result{
+			Cluster:  cluster,
+			Rule:     rule,
+			Path:     path,
+			Message:  fmt.Sprintf("%s container %q image %s %s", ref.Resource(), ref.Container, ref.Image, reason),
+			Severity: "warn",
+		})
+	}
+
+	return results
+}
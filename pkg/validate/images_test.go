@@ -0,0 +1,163 @@
+package validate
+
+import (
+	"testing"
+
+	"github.com/erauner/homelab-shadow/pkg/config"
+)
+
+func TestSplitImage(t *testing.T) {
+	tests := []struct {
+		image                   string
+		repository, tag, digest string
+	}{
+		{"nginx", "nginx", "", ""},
+		{"nginx:1.25", "nginx", "1.25", ""},
+		{"ghcr.io/erauner/app:v1.2.3", "ghcr.io/erauner/app", "v1.2.3", ""},
+		{"registry.local:5000/app", "registry.local:5000/app", "", ""},
+		{"registry.local:5000/app:stable", "registry.local:5000/app", "stable", ""},
+		{"nginx@sha256:abc", "nginx", "", "sha256:abc"},
+		{"nginx:1.25@sha256:abc", "nginx", "1.25", "sha256:abc"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.image, func(t *testing.T) {
+			repository, tag, digest := SplitImage(tt.image)
+			if repository != tt.repository || tag != tt.tag || digest != tt.digest {
+				t.Errorf("SplitImage(%q) = (%q, %q, %q), want (%q, %q, %q)",
+					tt.image, repository, tag, digest, tt.repository, tt.tag, tt.digest)
+			}
+		})
+	}
+}
+
+func TestExtractImages(t *testing.T) {
+	manifest := `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  template:
+    spec:
+      initContainers:
+      - name: migrate
+        image: app:v1
+      containers:
+      - name: web
+        image: nginx:1.25
+---
+apiVersion: batch/v1
+kind: CronJob
+metadata:
+  name: backup
+spec:
+  jobTemplate:
+    spec:
+      template:
+        spec:
+          containers:
+          - name: backup
+            image: restic/restic
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+data:
+  image: not-a-container
+`
+
+	refs, err := ExtractImages(manifest)
+	if err != nil {
+		t.Fatalf("ExtractImages() error = %v", err)
+	}
+
+	want := []ImageRef{
+		{Kind: "Deployment", Name: "web", Container: "web", Image: "nginx:1.25"},
+		{Kind: "Deployment", Name: "web", Container: "migrate", Image: "app:v1"},
+		{Kind: "CronJob", Name: "backup", Container: "backup", Image: "restic/restic"},
+	}
+	if len(refs) != len(want) {
+		t.Fatalf("expected %d images, got %d: %+v", len(want), len(refs), refs)
+	}
+	for i := range want {
+		if refs[i] != want[i] {
+			t.Errorf("refs[%d] = %+v, want %+v", i, refs[i], want[i])
+		}
+	}
+}
+
+func TestValidateImages(t *testing.T) {
+	manifest := `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  template:
+    spec:
+      containers:
+      - name: pinned
+        image: nginx:1.25
+      - name: digest
+        image: nginx@sha256:0123
+      - name: untagged
+        image: nginx
+      - name: latest
+        image: nginx:latest
+      - name: floating
+        image: nginx:stable
+`
+
+	results := ValidateImages("home", "apps/web/overlays/home/production", manifest)
+
+	want := map[string]bool{
+		RuleImageTagMissing:  true,
+		RuleImageTagLatest:   true,
+		RuleImageTagFloating: true,
+	}
+	if len(results) != len(want) {
+		t.Fatalf("expected %d results, got %d: %+v", len(want), len(results), results)
+	}
+	for _, r := range results {
+		if !want[r.Rule] {
+			t.Errorf("unexpected rule %s", r.Rule)
+		}
+		if r.Cluster != "home" || r.Severity != "warn" {
+			t.Errorf("unexpected result %+v", r)
+		}
+	}
+}
+
+func TestValidateImages_InvalidManifest(t *testing.T) {
+	results := ValidateImages("home", "apps/web", "kind: [")
+	if len(results) != 1 || results[0].Rule != "manifest-parse-fail" {
+		t.Errorf("expected manifest-parse-fail, got %+v", results)
+	}
+}
+
+func TestApplySeverities(t *testing.T) {
+	cfg := &config.Config{Rules: map[string]config.RuleConfig{
+		RuleImageTagLatest:   {Severity: config.SeverityError},
+		RuleImageTagFloating: {Severity: config.SeverityOff},
+	}}
+	results := []Result{
+		{Rule: RuleImageTagLatest, Severity: "warn"},
+		{Rule: RuleImageTagFloating, Severity: "warn"},
+		{Rule: RuleImageTagMissing, Severity: "warn"},
+	}
+
+	got := ApplySeverities(results, cfg)
+	if len(got) != 2 {
+		t.Fatalf("expected 2 results, got %d: %+v", len(got), got)
+	}
+	if got[0].Severity != "error" {
+		t.Errorf("expected %s upgraded to error, got %s", got[0].Rule, got[0].Severity)
+	}
+	if got[1].Rule != RuleImageTagMissing || got[1].Severity != "warn" {
+		t.Errorf("expected %s unchanged, got %+v", RuleImageTagMissing, got[1])
+	}
+
+	if got := ApplySeverities(results, nil); len(got) != len(results) {
+		t.Error("expected nil config to leave results unchanged")
+	}
+}
@@ -0,0 +1,286 @@
+package validate
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/erauner/homelab-shadow/pkg/argocd"
+	"gopkg.in/yaml.v3"
+)
+
+// OverlayMove is a legacy flat overlay directory and where it moves to
+type OverlayMove struct {
+	From string `json:"from"` // apps/<app>/overlays/<env>
+	To   string `json:"to"`   // apps/<app>/overlays/<cluster>/<env>
+}
+
+// FileEdit is a file a migration changes, creates, or deletes; Path is where
+// the file ends up, OldPath where it is now
+type FileEdit struct {
+	Path    string `json:"path"`
+	OldPath string `json:"old_path,omitempty"`
+	Created bool   `json:"created,omitempty"`
+	Deleted bool   `json:"deleted,omitempty"`
+	Before  string `json:"-"`
+	After   string `json:"-"`
+}
+
+// OverlayMigration converts an app's legacy flat overlays
+// (apps/<app>/overlays/<env>) to the cluster-layered structure
+// (apps/<app>/overlays/<cluster>/<env>), issue #1256
+type OverlayMigration struct {
+	RepoPath string        `json:"-"`
+	Moves    []OverlayMove `json:"moves"`
+	Edits    []FileEdit    `json:"edits"`
+
+	// Notes are things left to do by hand, e.g. ApplicationSets whose
+	// templates generate the moved paths
+	Notes []string `json:"notes,omitempty"`
+}
+
+// PlanOverlayMigration plans moving app's legacy flat overlays (under
+// overlays/, stack/, and db/overlays/) into cluster, limited to envs when
+// given. Relative references that cross a moved directory's boundary are
+// rewritten in every kustomization of the app (../../base becomes
+// ../../../base), as are Application source paths naming a moved overlay.
+// Nothing is changed until Apply.
+func (v *ClusterValidator) PlanOverlayMigration(app, cluster string, envs []string) (*OverlayMigration, error) {
+	appDir := "apps/" + app
+	if info, err := os.Stat(filepath.Join(v.RepoPath, appDir)); err != nil || !info.IsDir() {
+		return nil, fmt.Errorf("app %q not found (no %s/)", app, appDir)
+	}
+	m := &OverlayMigration{RepoPath: v.RepoPath, Moves: []OverlayMove{}, Edits: []FileEdit{}}
+
+	wanted := make(map[string]bool)
+	for _, env := range envs {
+		wanted[env] = true
+	}
+	found := make(map[string]bool)
+	for _, overlayRoot := range []string{"overlays", "stack", "db/overlays"} {
+		root := appDir + "/" + overlayRoot
+		entries, err := os.ReadDir(filepath.Join(v.RepoPath, filepath.FromSlash(root)))
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			env := entry.Name()
+			dir := filepath.Join(v.RepoPath, filepath.FromSlash(root), env)
+			if !entry.IsDir() || strings.HasPrefix(env, ".") || !hasKustomization(dir) || v.isClusterDir(env, dir) {
+				continue
+			}
+			if len(wanted) > 0 && !wanted[env] {
+				continue
+			}
+			found[env] = true
+			move := OverlayMove{From: root + "/" + env, To: root + "/" + cluster + "/" + env}
+			if _, err := os.Stat(filepath.Join(v.RepoPath, filepath.FromSlash(move.To))); err == nil {
+				return nil, fmt.Errorf("cannot move %s: %s already exists", move.From, move.To)
+			}
+			m.Moves = append(m.Moves, move)
+		}
+	}
+	for _, env := range envs {
+		if !found[env] {
+			return nil, fmt.Errorf("%s has no legacy flat overlay %q", appDir, env)
+		}
+	}
+	if len(m.Moves) == 0 {
+		return m, nil
+	}
+
+	if err := m.planKustomizations(appDir); err != nil {
+		return nil, err
+	}
+	if err := m.planApplications(app); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// moved maps a repo-relative path to where it is after the moves
+func (m *OverlayMigration) moved(p string) string {
+	for _, move := range m.Moves {
+		if p == move.From || strings.HasPrefix(p, move.From+"/") {
+			return move.To + strings.TrimPrefix(p, move.From)
+		}
+	}
+	return p
+}
+
+// planKustomizations rewrites the ../ references of every kustomization
+// under appDir whose target, or whose own directory, moves
+func (m *OverlayMigration) planKustomizations(appDir string) error {
+	var files []string
+	err := filepath.WalkDir(filepath.Join(m.RepoPath, filepath.FromSlash(appDir)), func(p string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && strings.HasPrefix(d.Name(), ".") {
+			return filepath.SkipDir
+		}
+		switch d.Name() {
+		case "kustomization.yaml", "kustomization.yml", "Kustomization":
+			rel, _ := filepath.Rel(m.RepoPath, p)
+			files = append(files, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		data, err := os.ReadFile(filepath.Join(m.RepoPath, filepath.FromSlash(file)))
+		if err != nil {
+			return err
+		}
+		var doc yaml.Node
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return fmt.Errorf("failed to parse %s: %v", file, err)
+		}
+
+		dir, newDir := path.Dir(file), path.Dir(m.moved(file))
+		lines := splitLines(data)
+		for _, node := range scalarValues(&doc) {
+			if !strings.HasPrefix(node.Value, "../") {
+				continue
+			}
+			target := path.Join(dir, node.Value)
+			if strings.HasPrefix(target, "../") {
+				continue // outside the repo
+			}
+			if _, err := os.Stat(filepath.Join(m.RepoPath, filepath.FromSlash(target))); err != nil {
+				continue // not a path (or already dangling)
+			}
+			ref, err := filepath.Rel(newDir, m.moved(target))
+			if err != nil {
+				continue
+			}
+			ref = filepath.ToSlash(ref)
+			if strings.HasSuffix(node.Value, "/") {
+				ref += "/"
+			}
+			if ref == node.Value {
+				continue
+			}
+			if err := replaceScalar(lines, node, ref); err != nil {
+				return fmt.Errorf("%s: %v", file, err)
+			}
+		}
+		m.addEdit(file, data, joinLines(lines))
+	}
+	return nil
+}
+
+// planApplications re-points Application source paths at moved overlays
+func (m *OverlayMigration) planApplications(app string) error {
+	files, err := argocd.DiscoverApplications(m.RepoPath)
+	if err != nil {
+		return err
+	}
+	sort.Strings(files)
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(m.RepoPath, file)
+		rel = filepath.ToSlash(rel)
+
+		lines := splitLines(data)
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		for {
+			var node yaml.Node
+			if err := decoder.Decode(&node); err != nil {
+				break
+			}
+			if len(node.Content) == 0 {
+				continue
+			}
+			doc := node.Content[0]
+			_, kind := mappingEntry(doc, "kind")
+			if kind != nil && kind.Value == "ApplicationSet" {
+				for _, s := range scalarValues(doc) {
+					if strings.Contains(s.Value, "apps/"+app+"/") {
+						m.Notes = append(m.Notes, fmt.Sprintf("%s: ApplicationSet may generate paths under apps/%s/; update its template by hand", rel, app))
+						break
+					}
+				}
+				continue
+			}
+			if kind == nil || kind.Value != "Application" {
+				continue
+			}
+
+			for _, p := range sourcePathNodes(doc) {
+				normalized := strings.TrimPrefix(p.Value, "./")
+				prefix := strings.TrimSuffix(p.Value, normalized)
+				clean := strings.TrimSuffix(normalized, "/")
+				to := m.moved(clean)
+				if to == clean {
+					continue
+				}
+				if err := replaceScalar(lines, p, prefix+to+strings.TrimPrefix(normalized, clean)); err != nil {
+					return fmt.Errorf("%s: %v", rel, err)
+				}
+			}
+		}
+		m.addEdit(rel, data, joinLines(lines))
+	}
+	return nil
+}
+
+func (m *OverlayMigration) addEdit(file string, before, after []byte) {
+	if bytes.Equal(before, after) {
+		return
+	}
+	m.Edits = append(m.Edits, FileEdit{Path: m.moved(file), OldPath: file, Before: string(before), After: string(after)})
+}
+
+// Apply moves the overlay directories, then writes the edited files
+func (m *OverlayMigration) Apply() error {
+	for _, move := range m.Moves {
+		from := filepath.Join(m.RepoPath, filepath.FromSlash(move.From))
+		to := filepath.Join(m.RepoPath, filepath.FromSlash(move.To))
+		if err := os.MkdirAll(filepath.Dir(to), 0755); err != nil {
+			return err
+		}
+		if err := os.Rename(from, to); err != nil {
+			return fmt.Errorf("failed to move %s: %w", move.From, err)
+		}
+	}
+	return writeEdits(m.RepoPath, m.Edits)
+}
+
+// Diff renders the migration as renames plus a diff of the edits
+func (m *OverlayMigration) Diff() string {
+	var b strings.Builder
+	for _, move := range m.Moves {
+		fmt.Fprintf(&b, "rename %s => %s\n", move.From, move.To)
+	}
+	b.WriteString(diffEdits(m.Edits))
+	return b.String()
+}
+
+// scalarValues returns the scalar values (not keys) under node
+func scalarValues(node *yaml.Node) []*yaml.Node {
+	var values []*yaml.Node
+	switch node.Kind {
+	case yaml.DocumentNode, yaml.SequenceNode:
+		for _, child := range node.Content {
+			values = append(values, scalarValues(child)...)
+		}
+	case yaml.MappingNode:
+		for i := 1; i < len(node.Content); i += 2 {
+			values = append(values, scalarValues(node.Content[i])...)
+		}
+	case yaml.ScalarNode:
+		values = append(values, node)
+	}
+	return values
+}
@@ -0,0 +1,93 @@
+package validate
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPlanOverlayMigration(t *testing.T) {
+	repo := writeRepoFiles(t, map[string]string{
+		"clusters.yaml":                                           "clusters: [erauner-home]\n",
+		"apps/coder/base/kustomization.yaml":                      "resources: [deployment.yaml]\n",
+		"apps/coder/common/kustomization.yaml":                    "resources: [../base]\n",
+		"apps/coder/overlays/production/kustomization.yaml":       "resources:\n  - ../../base # app\n  - route.yaml\npatches:\n  - path: ../../common/patch.yaml\n",
+		"apps/coder/overlays/production/route.yaml":               "kind: HTTPRoute\n",
+		"apps/coder/common/patch.yaml":                            "kind: Deployment\n",
+		"apps/coder/overlays/staging/kustomization.yaml":          "resources: [../production/, ../../common]\n",
+		"apps/coder/overlays/erauner-home/dev/kustomization.yaml": "resources: [../../../base]\n",
+		"apps/coder/stack/production/kustomization.yaml":          "resources:\n  - ../../overlays/production\n",
+		"argocd-apps/applications/coder.yaml": "apiVersion: argoproj.io/v1alpha1\nkind: Application\nspec:\n  source:\n    path: ./apps/coder/overlays/production/\n" +
+			"---\napiVersion: argoproj.io/v1alpha1\nkind: Application\nspec:\n  sources:\n    - path: apps/coder/stack/production\n    - path: apps/other/overlays/production\n",
+		"argocd-apps/applications/set.yaml": "apiVersion: argoproj.io/v1alpha1\nkind: ApplicationSet\nspec:\n  template:\n    spec:\n      source:\n        path: 'apps/coder/overlays/{{env}}'\n",
+	})
+	v := NewClusterValidator(repo, false)
+
+	m, err := v.PlanOverlayMigration("coder", "erauner-home", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var moves []string
+	for _, move := range m.Moves {
+		moves = append(moves, move.From+" => "+move.To)
+	}
+	wantMoves := "apps/coder/overlays/production => apps/coder/overlays/erauner-home/production, " +
+		"apps/coder/overlays/staging => apps/coder/overlays/erauner-home/staging, " +
+		"apps/coder/stack/production => apps/coder/stack/erauner-home/production"
+	if got := strings.Join(moves, ", "); got != wantMoves {
+		t.Errorf("moves = %s\nwant %s", got, wantMoves)
+	}
+	if len(m.Notes) != 1 || !strings.Contains(m.Notes[0], "set.yaml") {
+		t.Errorf("notes = %v", m.Notes)
+	}
+
+	diff := m.Diff()
+	if !strings.Contains(diff, "-  - ../../base # app\n+  - ../../../base # app\n") {
+		t.Errorf("diff =\n%s", diff)
+	}
+	if err := m.Apply(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		"apps/coder/overlays/erauner-home/production/kustomization.yaml": "resources:\n  - ../../../base # app\n  - route.yaml\npatches:\n  - path: ../../../common/patch.yaml\n",
+		"apps/coder/overlays/erauner-home/production/route.yaml":         "kind: HTTPRoute\n",
+		"apps/coder/overlays/erauner-home/staging/kustomization.yaml":    "resources: [../production/, ../../../common]\n",
+		"apps/coder/stack/erauner-home/production/kustomization.yaml":    "resources:\n  - ../../../overlays/erauner-home/production\n",
+		"apps/coder/overlays/erauner-home/dev/kustomization.yaml":        "resources: [../../../base]\n",
+		"argocd-apps/applications/coder.yaml": "apiVersion: argoproj.io/v1alpha1\nkind: Application\nspec:\n  source:\n    path: ./apps/coder/overlays/erauner-home/production/\n" +
+			"---\napiVersion: argoproj.io/v1alpha1\nkind: Application\nspec:\n  sources:\n    - path: apps/coder/stack/erauner-home/production\n    - path: apps/other/overlays/production\n",
+	}
+	for path, content := range want {
+		if got := readRepoFile(t, repo, path); got != content {
+			t.Errorf("%s =\n%s\nwant\n%s", path, got, content)
+		}
+	}
+
+	for _, r := range v.ValidateAppOverlayStructure([]string{"erauner-home"}) {
+		t.Errorf("finding after migration: %s %s: %s", r.Rule, r.Path, r.Message)
+	}
+}
+
+func TestPlanOverlayMigration_Errors(t *testing.T) {
+	repo := writeRepoFiles(t, map[string]string{
+		"clusters.yaml": "clusters: [erauner-home]\n",
+		"apps/coder/overlays/production/kustomization.yaml":              "resources: [../../base]\n",
+		"apps/coder/overlays/erauner-home/production/kustomization.yaml": "resources: [../../../base]\n",
+		"apps/web/overlays/erauner-home/production/kustomization.yaml":   "resources: [../../../base]\n",
+	})
+	v := NewClusterValidator(repo, false)
+
+	if _, err := v.PlanOverlayMigration("coder", "erauner-home", nil); err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Errorf("moving onto an existing overlay: err = %v", err)
+	}
+	if _, err := v.PlanOverlayMigration("web", "erauner-home", []string{"staging"}); err == nil || !strings.Contains(err.Error(), `no legacy flat overlay "staging"`) {
+		t.Errorf("unknown env: err = %v", err)
+	}
+	if _, err := v.PlanOverlayMigration("nope", "erauner-home", nil); err == nil {
+		t.Error("unknown app should fail")
+	}
+	m, err := v.PlanOverlayMigration("web", "erauner-home", nil)
+	if err != nil || len(m.Moves) != 0 {
+		t.Errorf("already-migrated app: %+v, %v", m, err)
+	}
+}
@@ -0,0 +1,155 @@
+package validate
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// RuleNamespaceUndefined flags rendered resources targeting a namespace that
+// no platform namespace directory creates for the cluster
+const RuleNamespaceUndefined = "namespace-undefined"
+
+// SystemNamespaces exist on every cluster without being defined in the repo
+var SystemNamespaces = []string{"default", "kube-system", "kube-public", "kube-node-lease"}
+
+// ValidateNamespaceRefs checks that the metadata.namespace of every rendered
+// resource is a Namespace rendered for the same cluster by a directory under
+// AllowedNamespaceDirs or LegacyNamespaceDirs (manifests are keyed by
+// directory; clusterOf maps a directory to its cluster). Namespace directories
+// without a cluster count for every cluster. Clusters with no rendered
+// namespace directory are not checked, since a partial render can't tell a
+// missing namespace from one that wasn't built. Namespaces with unresolved
+// ${VAR} placeholders are skipped
+func ValidateNamespaceRefs(manifests map[string]string, clusterOf func(dir string) string) ([]Result, error) {
+	dirs := make([]string, 0, len(manifests))
+	for dir := range manifests {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+
+	type usage struct {
+		namespace string
+		resources []string
+	}
+	defined := make(map[string]map[string]bool) // cluster -> namespace
+	createdElsewhere := make(map[string]map[string]string)
+	used := make(map[string][]usage) // dir -> namespaces in first-seen order
+	for _, dir := range dirs {
+		docs, err := namespacedResources(manifests[dir])
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", dir, err)
+		}
+		clusterName := clusterOf(dir)
+		location := (&ClusterValidator{}).classifyNamespaceLocation(dir + "/")
+		namespaceDir := location == "allowed" || location == "legacy"
+		if namespaceDir && defined[clusterName] == nil {
+			defined[clusterName] = make(map[string]bool)
+		}
+
+		index := make(map[string]int)
+		for _, doc := range docs {
+			if doc.Kind == "Namespace" {
+				if namespaceDir {
+					defined[clusterName][doc.Name] = true
+				} else {
+					if createdElsewhere[clusterName] == nil {
+						createdElsewhere[clusterName] = make(map[string]string)
+					}
+					if _, ok := createdElsewhere[clusterName][doc.Name]; !ok {
+						createdElsewhere[clusterName][doc.Name] = dir
+					}
+				}
+				continue
+			}
+			if doc.Namespace == "" || strings.Contains(doc.Namespace, "${") {
+				continue
+			}
+			i, ok := index[doc.Namespace]
+			if !ok {
+				i = len(used[dir])
+				index[doc.Namespace] = i
+				used[dir] = append(used[dir], usage{namespace: doc.Namespace})
+			}
+			used[dir][i].resources = append(used[dir][i].resources, doc.Kind+"/"+doc.Name)
+		}
+	}
+
+	results := []Result{} // Initialize to empty slice for consistent JSON output
+	for _, dir := range dirs {
+		clusterName := clusterOf(dir)
+		own, shared := defined[clusterName], defined[""]
+		if own == nil && shared == nil {
+			continue
+		}
+		resultCluster := clusterName
+		if resultCluster == "" {
+			resultCluster = "global"
+		}
+		for _, u := range used[dir] {
+			if own[u.namespace] || shared[u.namespace] || slices.Contains(SystemNamespaces, u.namespace) {
+				continue
+			}
+			message := fmt.Sprintf("namespace %q is not defined in %s for %s (used by %s)",
+				u.namespace, strings.Join(AllowedNamespaceDirs, ", "), resultCluster, summarizeResources(u.resources))
+			if other, ok := createdElsewhere[clusterName][u.namespace]; ok {
+				message += fmt.Sprintf("; only %s creates it", other)
+			}
+			results = append(results, Result{
+				Cluster:  resultCluster,
+				Rule:     RuleNamespaceUndefined,
+				Path:     dir,
+				Message:  message,
+				Severity: "error",
+			})
+		}
+	}
+	return results, nil
+}
+
+// namespacedResource is the kind, name, and namespace of a rendered resource
+type namespacedResource struct {
+	Kind      string
+	Name      string
+	Namespace string
+}
+
+// namespacedResources lists the resources of a multi-document manifest
+func namespacedResources(manifest string) ([]namespacedResource, error) {
+	var resources []namespacedResource
+	decoder := yaml.NewDecoder(strings.NewReader(manifest))
+	for {
+		var doc struct {
+			Kind     string `yaml:"kind"`
+			Metadata struct {
+				Name      string `yaml:"name"`
+				Namespace string `yaml:"namespace"`
+			} `yaml:"metadata"`
+		}
+		if err := decoder.Decode(&doc); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, fmt.Errorf("failed to parse manifest: %w", err)
+		}
+		if doc.Kind == "" || doc.Metadata.Name == "" {
+			continue
+		}
+		resources = append(resources, namespacedResource{Kind: doc.Kind, Name: doc.Metadata.Name, Namespace: doc.Metadata.Namespace})
+	}
+	return resources, nil
+}
+
+// summarizeResources lists the first few resources and counts the rest
+func summarizeResources(resources []string) string {
+	const shown = 3
+	if len(resources) <= shown {
+		return strings.Join(resources, ", ")
+	}
+	return fmt.Sprintf("%s and %d more", strings.Join(resources[:shown], ", "), len(resources)-shown)
+}
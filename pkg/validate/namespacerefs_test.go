@@ -0,0 +1,131 @@
+package validate_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/erauner/homelab-shadow/pkg/validate"
+)
+
+// clusterOfNamespaceDir also understands security/<component>/overlays/<cluster>
+func clusterOfNamespaceDir(dir string) string {
+	parts := strings.Split(dir, "/")
+	if len(parts) == 4 && parts[2] == "overlays" {
+		return parts[3]
+	}
+	return clusterOfTestDir(dir)
+}
+
+func TestValidateNamespaceRefs(t *testing.T) {
+	manifests := map[string]string{
+		"security/namespaces/overlays/erauner-home": `apiVersion: v1
+kind: Namespace
+metadata:
+  name: coder
+`,
+		"apps/coder/overlays/erauner-home/production": `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: coder
+  namespace: coder
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: dns
+  namespace: kube-system
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRole
+metadata:
+  name: coder
+`,
+		"apps/docs/overlays/erauner-home/production": `apiVersion: v1
+kind: Namespace
+metadata:
+  name: docs
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: docs
+  namespace: docs
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: docs
+  namespace: docs
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: a
+  namespace: wiki
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: b
+  namespace: wiki
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: c
+  namespace: wiki
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: d
+  namespace: wiki
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: e
+  namespace: ${APP_NAMESPACE}
+`,
+		// No namespace directory rendered for this cluster: not checked
+		"apps/wiki/overlays/erauner-cloud/production": `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: wiki
+  namespace: wiki
+`,
+	}
+
+	results, err := validate.ValidateNamespaceRefs(manifests, clusterOfNamespaceDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, r := range results {
+		got = append(got, r.Cluster+" "+r.Rule+" "+r.Severity+" "+r.Path+": "+r.Message)
+	}
+	want := []string{
+		`erauner-home namespace-undefined error apps/docs/overlays/erauner-home/production: namespace "docs" is not defined in security/namespaces/ for erauner-home (used by Deployment/docs, Service/docs); only apps/docs/overlays/erauner-home/production creates it`,
+		`erauner-home namespace-undefined error apps/docs/overlays/erauner-home/production: namespace "wiki" is not defined in security/namespaces/ for erauner-home (used by ConfigMap/a, ConfigMap/b, ConfigMap/c and 1 more)`,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("results:\n%v\nwant:\n%v", got, want)
+	}
+}
+
+func TestValidateNamespaceRefs_SharedNamespaceDir(t *testing.T) {
+	manifests := map[string]string{
+		"infrastructure/namespaces":                     "apiVersion: v1\nkind: Namespace\nmetadata:\n  name: monitoring\n",
+		"apps/grafana/overlays/erauner-home/production": "apiVersion: v1\nkind: Service\nmetadata:\n  name: grafana\n  namespace: monitoring\n",
+	}
+
+	results, err := validate.ValidateNamespaceRefs(manifests, clusterOfNamespaceDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 0 {
+		t.Errorf("expected legacy cluster-independent namespaces to count, got %+v", results)
+	}
+}
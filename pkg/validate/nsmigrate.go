@@ -0,0 +1,515 @@
+package validate
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// namespaceMigrationRoots are where migrate namespaces moves Namespace
+// definitions from; anywhere else (clusters/ bootstrap, argocd-apps/) is only
+// reported, since those are often applied before security/namespaces exists
+var namespaceMigrationRoots = []string{"infrastructure/", "apps/", "operators/", "security/"}
+
+// NamespaceMove is a Namespace and the files its definitions move from
+type NamespaceMove struct {
+	Name string   `json:"name"`
+	From []string `json:"from"`
+	To   string   `json:"to"`
+}
+
+// NamespaceMigration moves Namespace definitions from infrastructure/namespaces/
+// and app or operator directories into security/namespaces/
+type NamespaceMigration struct {
+	RepoPath   string          `json:"-"`
+	Namespaces []NamespaceMove `json:"namespaces"`
+	Edits      []FileEdit      `json:"edits"`
+
+	// Review lists what needs a human: conflicting labels, fields that
+	// weren't carried over, kustomizations left empty, and the like
+	Review []string `json:"review,omitempty"`
+}
+
+// namespaceDef is one Namespace document in a file
+type namespaceDef struct {
+	file  string
+	chunk int
+	doc   *yaml.Node // the document's mapping
+	name  string
+}
+
+// yamlChunk is one document of a multi-document file: its --- separator line
+// (empty for the first document) and its text
+type yamlChunk struct {
+	sep, body string
+}
+
+// PlanNamespaceMigration plans moving every Namespace defined outside
+// security/namespaces/ into it. A definition under a registered cluster's
+// directory goes to security/namespaces/overlays/<cluster> when that overlay
+// exists; everything else goes to security/namespaces/base. Definitions of the
+// same namespace are merged into one (labels and annotations; an existing
+// definition in security/namespaces wins), the moved documents are dropped
+// from their files, emptied files are deleted, and kustomizations listing
+// them are updated. Nothing is changed until Apply.
+func (v *ClusterValidator) PlanNamespaceMigration() (*NamespaceMigration, error) {
+	m := &NamespaceMigration{RepoPath: v.RepoPath, Namespaces: []NamespaceMove{}, Edits: []FileEdit{}}
+	files := newFileSet(v.RepoPath)
+	registry, err := v.clusterRegistry()
+	if err != nil {
+		return nil, err
+	}
+
+	manifests, err := v.discoverNamespaceManifests()
+	if err != nil {
+		return nil, err
+	}
+	var sourceFiles, allowedFiles []string
+	for _, ns := range manifests {
+		file := filepath.ToSlash(ns.Path)
+		switch v.classifyNamespaceLocation(file) {
+		case "allowed":
+			allowedFiles = append(allowedFiles, file)
+		case "legacy", "wrong":
+			if !hasAnyPrefix(file, namespaceMigrationRoots) {
+				m.Review = append(m.Review, fmt.Sprintf("%s: defines Namespace %q outside %s; not moved automatically", file, ns.Namespace, strings.Join(namespaceMigrationRoots, ", ")))
+				continue
+			}
+			sourceFiles = append(sourceFiles, file)
+		}
+	}
+	sort.Strings(sourceFiles)
+	sort.Strings(allowedFiles)
+
+	chunks := make(map[string][]yamlChunk)
+	load := func(file string) ([]namespaceDef, error) {
+		content, _, err := files.read(file)
+		if err != nil {
+			return nil, err
+		}
+		chunks[file] = splitDocuments(content)
+		return namespaceDefs(file, chunks[file])
+	}
+
+	// existing definitions, by directory and name
+	existing := make(map[string]namespaceDef)
+	for _, file := range allowedFiles {
+		defs, err := load(file)
+		if err != nil {
+			return nil, err
+		}
+		for _, def := range defs {
+			key := path.Dir(file) + "\x00" + def.name
+			if _, ok := existing[key]; !ok {
+				existing[key] = def
+			}
+		}
+	}
+
+	// definitions to move, grouped by target directory and name
+	type group struct {
+		dir, name string
+		defs      []namespaceDef
+	}
+	groups := make(map[string]*group)
+	var order []string
+	for _, file := range sourceFiles {
+		defs, err := load(file)
+		if err != nil {
+			return nil, err
+		}
+		dir := namespaceBaseDir
+		if c := pathCluster(file, registry.Has); c != "" && hasKustomization(filepath.Join(v.RepoPath, "security", "namespaces", "overlays", c)) {
+			dir = "security/namespaces/overlays/" + c
+		}
+		for _, def := range defs {
+			key := dir + "\x00" + def.name
+			if groups[key] == nil {
+				groups[key] = &group{dir: dir, name: def.name}
+				order = append(order, key)
+			}
+			groups[key].defs = append(groups[key].defs, def)
+		}
+	}
+	sort.Strings(order)
+
+	removed := make(map[string]map[int]bool)
+	for _, key := range order {
+		g := groups[key]
+		move := NamespaceMove{Name: g.name}
+		for _, def := range g.defs {
+			move.From = append(move.From, def.file)
+		}
+
+		var target *yaml.Node
+		if def, ok := existing[key]; ok {
+			move.To = def.file
+			target = def.doc
+		} else {
+			move.To = g.dir + "/" + g.name + ".yaml"
+			if _, exists, err := files.read(move.To); err != nil {
+				return nil, err
+			} else if exists {
+				m.Review = append(m.Review, fmt.Sprintf("%s: exists but doesn't define Namespace %q; %s not moved", move.To, g.name, strings.Join(move.From, ", ")))
+				continue
+			}
+			target = newNamespaceDoc(g.name)
+		}
+		changed := false
+		for _, def := range g.defs {
+			review, added := mergeNamespace(target, def)
+			m.Review = append(m.Review, review...)
+			changed = changed || added
+			if removed[def.file] == nil {
+				removed[def.file] = make(map[int]bool)
+			}
+			removed[def.file][def.chunk] = true
+		}
+
+		if def, ok := existing[key]; ok && changed {
+			body, err := encodeDocument(target)
+			if err != nil {
+				return nil, err
+			}
+			chunks[def.file][def.chunk].body = body
+			files.write(def.file, joinDocuments(chunks[def.file]))
+		} else if !ok {
+			body, err := encodeDocument(target)
+			if err != nil {
+				return nil, err
+			}
+			files.write(move.To, body)
+			if err := m.addToKustomization(files, g.dir, g.name+".yaml"); err != nil {
+				return nil, err
+			}
+		}
+		m.Namespaces = append(m.Namespaces, move)
+	}
+
+	// drop the moved documents from their files
+	var deleted []string
+	for _, file := range sourceFiles {
+		if len(removed[file]) == 0 {
+			continue
+		}
+		var kept []yamlChunk
+		empty := true
+		for i, c := range chunks[file] {
+			if removed[file][i] {
+				continue
+			}
+			kept = append(kept, c)
+			if !emptyDocument(c.body) {
+				empty = false
+			}
+		}
+		if empty {
+			files.remove(file)
+			deleted = append(deleted, file)
+			continue
+		}
+		if chunks[file][0].sep == "" {
+			kept[0].sep = ""
+		}
+		files.write(file, joinDocuments(kept))
+	}
+	if err := m.removeReferences(files, deleted); err != nil {
+		return nil, err
+	}
+
+	m.Edits = files.edits()
+	return m, nil
+}
+
+// namespaceBaseDir is where namespaces not tied to one cluster are defined
+const namespaceBaseDir = "security/namespaces/base"
+
+// addToKustomization appends ref to dir's kustomization, creating it when
+// the directory has none
+func (m *NamespaceMigration) addToKustomization(files *fileSet, dir, ref string) error {
+	file := dir + "/kustomization.yaml"
+	content, exists, err := files.read(file)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		m.Review = append(m.Review, fmt.Sprintf("%s: created; include %s from security/namespaces (or each cluster's Application)", file, dir))
+		files.write(file, "apiVersion: kustomize.config.k8s.io/v1beta1\nkind: Kustomization\nresources:\n  - "+ref+"\n")
+		return nil
+	}
+	root, err := parseDocument([]byte(content))
+	if err != nil {
+		return fmt.Errorf("failed to parse %s: %v", file, err)
+	}
+	updated, err := addResource([]byte(content), root, ref, false)
+	if err != nil {
+		m.Review = append(m.Review, fmt.Sprintf("%s: add %s to resources by hand (%v)", file, ref, err))
+		return nil
+	}
+	files.write(file, string(updated))
+	return nil
+}
+
+// removeReferences drops deleted files from the resources and bases of
+// every kustomization in the repo
+func (m *NamespaceMigration) removeReferences(files *fileSet, deleted []string) error {
+	if len(deleted) == 0 {
+		return nil
+	}
+	gone := make(map[string]bool)
+	for _, file := range deleted {
+		gone[file] = true
+	}
+
+	var kustomizations []string
+	err := filepath.WalkDir(m.RepoPath, func(p string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if name := d.Name(); p != m.RepoPath && (strings.HasPrefix(name, ".") || name == "vendor" || name == "node_modules") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		switch d.Name() {
+		case "kustomization.yaml", "kustomization.yml", "Kustomization":
+			rel, _ := filepath.Rel(m.RepoPath, p)
+			kustomizations = append(kustomizations, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, file := range kustomizations {
+		for _, key := range []string{"resources", "bases"} {
+			// reparse after each removal: node positions shift
+			for {
+				content, _, err := files.read(file)
+				if err != nil {
+					return err
+				}
+				root, err := parseDocument([]byte(content))
+				if err != nil {
+					break // not ours to fix
+				}
+				_, seq := mappingEntry(root, key)
+				item := referencedItem(seq, path.Dir(file), gone)
+				if item == nil {
+					break
+				}
+				updated, err := removeListItem([]byte(content), seq, item)
+				if err != nil {
+					m.Review = append(m.Review, fmt.Sprintf("%s: remove %s from %s by hand (%v)", file, item.Value, key, err))
+					break
+				}
+				files.write(file, string(updated))
+				if len(seq.Content) == 1 && key == "resources" {
+					m.Review = append(m.Review, fmt.Sprintf("%s: no resources left; remove it and whatever includes it if it's no longer needed", file))
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// referencedItem returns the first item of seq that names a file in gone,
+// relative to dir
+func referencedItem(seq *yaml.Node, dir string, gone map[string]bool) *yaml.Node {
+	if seq == nil || seq.Kind != yaml.SequenceNode {
+		return nil
+	}
+	for _, item := range seq.Content {
+		if item.Kind == yaml.ScalarNode && gone[path.Join(dir, item.Value)] {
+			return item
+		}
+	}
+	return nil
+}
+
+// Apply writes the edited, created, and deleted files
+func (m *NamespaceMigration) Apply() error {
+	return writeEdits(m.RepoPath, m.Edits)
+}
+
+// Diff renders the migration as a diff of the edits
+func (m *NamespaceMigration) Diff() string {
+	return diffEdits(m.Edits)
+}
+
+// mergeNamespace adds def's labels and annotations to the Namespace document
+// target, reporting whether it added any; values target already has are kept
+// and differing ones reported
+func mergeNamespace(target *yaml.Node, def namespaceDef) ([]string, bool) {
+	var review []string
+	added := false
+	_, metadata := mappingEntry(def.doc, "metadata")
+	_, targetMetadata := mappingEntry(target, "metadata")
+	for _, field := range []string{"labels", "annotations"} {
+		_, from := mappingEntry(metadata, field)
+		if from == nil || from.Kind != yaml.MappingNode || len(from.Content) == 0 {
+			continue
+		}
+		_, to := mappingEntry(targetMetadata, field)
+		if to == nil || to.Kind != yaml.MappingNode {
+			to = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+			setMappingEntry(targetMetadata, field, to)
+		}
+		for i := 0; i+1 < len(from.Content); i += 2 {
+			k, val := from.Content[i], from.Content[i+1]
+			if _, have := mappingEntry(to, k.Value); have != nil {
+				if have.Value != val.Value {
+					review = append(review, fmt.Sprintf("%s: Namespace %q %s %s=%q conflicts with %q; kept %q", def.file, def.name, strings.TrimSuffix(field, "s"), k.Value, val.Value, have.Value, have.Value))
+				}
+				continue
+			}
+			to.Content = append(to.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: k.Value}, &yaml.Node{Kind: yaml.ScalarNode, Value: val.Value, Style: val.Style})
+			added = true
+		}
+	}
+
+	var extra []string
+	for i := 0; i+1 < len(def.doc.Content); i += 2 {
+		if k := def.doc.Content[i].Value; k != "apiVersion" && k != "kind" && k != "metadata" {
+			extra = append(extra, k)
+		}
+	}
+	if metadata != nil && metadata.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(metadata.Content); i += 2 {
+			if k := metadata.Content[i].Value; k != "name" && k != "labels" && k != "annotations" {
+				extra = append(extra, "metadata."+k)
+			}
+		}
+	}
+	if len(extra) > 0 {
+		review = append(review, fmt.Sprintf("%s: Namespace %q %s not carried over", def.file, def.name, strings.Join(extra, ", ")))
+	}
+	return review, added
+}
+
+// namespaceDefs returns the Namespace documents among chunks
+func namespaceDefs(file string, chunks []yamlChunk) ([]namespaceDef, error) {
+	var defs []namespaceDef
+	for i, c := range chunks {
+		if !strings.Contains(c.body, "Namespace") {
+			continue
+		}
+		var node yaml.Node
+		if err := yaml.Unmarshal([]byte(c.body), &node); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %v", file, err)
+		}
+		if len(node.Content) == 0 || node.Content[0].Kind != yaml.MappingNode {
+			continue
+		}
+		doc := node.Content[0]
+		_, kind := mappingEntry(doc, "kind")
+		_, metadata := mappingEntry(doc, "metadata")
+		_, name := mappingEntry(metadata, "name")
+		if kind == nil || kind.Value != "Namespace" || name == nil || name.Value == "" {
+			continue
+		}
+		defs = append(defs, namespaceDef{file: file, chunk: i, doc: doc, name: name.Value})
+	}
+	return defs, nil
+}
+
+func newNamespaceDoc(name string) *yaml.Node {
+	scalar := func(v string) *yaml.Node { return &yaml.Node{Kind: yaml.ScalarNode, Value: v} }
+	return &yaml.Node{Kind: yaml.MappingNode, Content: []*yaml.Node{
+		scalar("apiVersion"), scalar("v1"),
+		scalar("kind"), scalar("Namespace"),
+		scalar("metadata"), {Kind: yaml.MappingNode, Content: []*yaml.Node{scalar("name"), scalar(name)}},
+	}}
+}
+
+// setMappingEntry sets key in mapping, appending it when missing
+func setMappingEntry(mapping *yaml.Node, key string, value *yaml.Node) {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			mapping.Content[i+1] = value
+			return
+		}
+	}
+	mapping.Content = append(mapping.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: key}, value)
+}
+
+func encodeDocument(doc *yaml.Node) (string, error) {
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(doc); err != nil {
+		return "", err
+	}
+	if err := encoder.Close(); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// splitDocuments splits a YAML stream at its --- lines
+func splitDocuments(content string) []yamlChunk {
+	chunks := []yamlChunk{{}}
+	for _, line := range strings.SplitAfter(content, "\n") {
+		if line == "" {
+			continue
+		}
+		if trimmed := strings.TrimRight(line, "\r\n"); trimmed == "---" || strings.HasPrefix(trimmed, "--- ") {
+			chunks = append(chunks, yamlChunk{sep: line})
+			continue
+		}
+		chunks[len(chunks)-1].body += line
+	}
+	if chunks[0].body == "" && len(chunks) > 1 {
+		chunks = chunks[1:]
+	}
+	return chunks
+}
+
+func joinDocuments(chunks []yamlChunk) string {
+	var b strings.Builder
+	for i, c := range chunks {
+		sep := c.sep
+		if i > 0 && sep == "" {
+			sep = "---\n"
+		}
+		b.WriteString(sep)
+		b.WriteString(c.body)
+	}
+	return b.String()
+}
+
+// emptyDocument reports whether a document holds only comments and blanks
+func emptyDocument(body string) bool {
+	var node yaml.Node
+	if err := yaml.Unmarshal([]byte(body), &node); err != nil {
+		return false
+	}
+	return len(node.Content) == 0 || (node.Content[0].Kind == yaml.ScalarNode && node.Content[0].Tag == "!!null")
+}
+
+// pathCluster returns the first segment of a path naming a registered cluster
+func pathCluster(file string, registered func(string) bool) string {
+	for _, segment := range strings.Split(path.Dir(file), "/") {
+		if registered(segment) {
+			return segment
+		}
+	}
+	return ""
+}
+
+func hasAnyPrefix(s string, prefixes []string) bool {
+	for _, p := range prefixes {
+		if strings.HasPrefix(s, p) {
+			return true
+		}
+	}
+	return false
+}
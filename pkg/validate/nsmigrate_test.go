@@ -0,0 +1,110 @@
+package validate
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPlanNamespaceMigration(t *testing.T) {
+	repo := writeRepoFiles(t, map[string]string{
+		"clusters.yaml": "clusters: [erauner-home]\n",
+		"security/namespaces/base/kustomization.yaml":                  "resources:\n  - coder.yaml\n",
+		"security/namespaces/base/coder.yaml":                          "apiVersion: v1\nkind: Namespace\nmetadata:\n  name: coder\n  labels:\n    team: dev\n",
+		"security/namespaces/overlays/erauner-home/kustomization.yaml": "resources: [../../base]\n",
+		"infrastructure/namespaces/kustomization.yaml":                 "resources:\n  - coder.yaml\n  - monitoring.yaml\n",
+		"infrastructure/namespaces/coder.yaml":                         "apiVersion: v1\nkind: Namespace\nmetadata:\n  name: coder\n  labels:\n    team: platform\n    istio-injection: enabled\n",
+		"infrastructure/namespaces/monitoring.yaml":                    "apiVersion: v1\nkind: Namespace\nmetadata:\n  name: monitoring\n  annotations:\n    owner: ops\n",
+		"apps/grafana/base/kustomization.yaml":                         "resources: [namespace.yaml, deployment.yaml]\n",
+		"apps/grafana/base/namespace.yaml":                             "apiVersion: v1\nkind: Namespace\nmetadata:\n  name: monitoring\n  labels:\n    tier: obs\n",
+		"apps/grafana/base/deployment.yaml":                            "kind: Deployment\n",
+		"operators/vault/overlays/erauner-home/kustomization.yaml":     "resources:\n  - resources.yaml\n",
+		"operators/vault/overlays/erauner-home/resources.yaml":         "apiVersion: v1\nkind: Namespace\nmetadata:\n  name: vault\nspec:\n  finalizers: [kubernetes]\n---\nkind: ConfigMap\nmetadata:\n  name: vault\n",
+		"clusters/erauner-home/bootstrap/argocd.yaml":                  "apiVersion: v1\nkind: Namespace\nmetadata:\n  name: argocd\n",
+	})
+	v := NewClusterValidator(repo, false)
+
+	m, err := v.PlanNamespaceMigration()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var moves []string
+	for _, move := range m.Namespaces {
+		moves = append(moves, move.Name+": "+strings.Join(move.From, "+")+" => "+move.To)
+	}
+	wantMoves := "coder: infrastructure/namespaces/coder.yaml => security/namespaces/base/coder.yaml, " +
+		"monitoring: apps/grafana/base/namespace.yaml+infrastructure/namespaces/monitoring.yaml => security/namespaces/base/monitoring.yaml, " +
+		"vault: operators/vault/overlays/erauner-home/resources.yaml => security/namespaces/overlays/erauner-home/vault.yaml"
+	if got := strings.Join(moves, ", "); got != wantMoves {
+		t.Errorf("moves = %s\nwant %s", got, wantMoves)
+	}
+
+	review := strings.Join(m.Review, "\n")
+	for _, want := range []string{
+		`clusters/erauner-home/bootstrap/argocd.yaml: defines Namespace "argocd"`,
+		`Namespace "coder" label team="platform" conflicts with "dev"`,
+		`Namespace "vault" spec not carried over`,
+		"infrastructure/namespaces/kustomization.yaml: no resources left",
+	} {
+		if !strings.Contains(review, want) {
+			t.Errorf("review is missing %q:\n%s", want, review)
+		}
+	}
+
+	if err := m.Apply(); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"security/namespaces/base/kustomization.yaml":                  "resources:\n  - coder.yaml\n  - monitoring.yaml\n",
+		"security/namespaces/base/coder.yaml":                          "apiVersion: v1\nkind: Namespace\nmetadata:\n  name: coder\n  labels:\n    team: dev\n    istio-injection: enabled\n",
+		"security/namespaces/base/monitoring.yaml":                     "apiVersion: v1\nkind: Namespace\nmetadata:\n  name: monitoring\n  labels:\n    tier: obs\n  annotations:\n    owner: ops\n",
+		"security/namespaces/overlays/erauner-home/kustomization.yaml": "resources: [../../base, vault.yaml]\n",
+		"security/namespaces/overlays/erauner-home/vault.yaml":         "apiVersion: v1\nkind: Namespace\nmetadata:\n  name: vault\n",
+		"infrastructure/namespaces/kustomization.yaml":                 "resources:\n",
+		"apps/grafana/base/kustomization.yaml":                         "resources: [deployment.yaml]\n",
+		"operators/vault/overlays/erauner-home/resources.yaml":         "kind: ConfigMap\nmetadata:\n  name: vault\n",
+		"operators/vault/overlays/erauner-home/kustomization.yaml":     "resources:\n  - resources.yaml\n",
+		"clusters/erauner-home/bootstrap/argocd.yaml":                  "apiVersion: v1\nkind: Namespace\nmetadata:\n  name: argocd\n",
+	}
+	for path, content := range want {
+		if got := readRepoFile(t, repo, path); got != content {
+			t.Errorf("%s =\n%s\nwant\n%s", path, got, content)
+		}
+	}
+	for _, gone := range []string{"infrastructure/namespaces/coder.yaml", "infrastructure/namespaces/monitoring.yaml", "apps/grafana/base/namespace.yaml"} {
+		if fileExists(filepath.Join(repo, gone)) {
+			t.Errorf("%s should be deleted", gone)
+		}
+	}
+
+	m, err = v.PlanNamespaceMigration()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Namespaces) != 0 || len(m.Edits) != 0 {
+		t.Errorf("second run: %+v", m)
+	}
+}
+
+func TestPlanNamespaceMigration_CreatesBase(t *testing.T) {
+	repo := writeRepoFiles(t, map[string]string{
+		"apps/web/base/namespace.yaml": "---\napiVersion: v1\nkind: Namespace\nmetadata:\n  name: web\n",
+	})
+	m, err := NewClusterValidator(repo, false).PlanNamespaceMigration()
+	if err != nil {
+		t.Fatal(err)
+	}
+	diff := m.Diff()
+	for _, want := range []string{
+		"--- /dev/null\n+++ b/security/namespaces/base/kustomization.yaml\n",
+		"--- a/apps/web/base/namespace.yaml\n+++ /dev/null\n",
+		"+++ b/security/namespaces/base/web.yaml\n@@ -0,0 +1,4 @@\n+apiVersion: v1\n+kind: Namespace\n+metadata:\n+  name: web\n",
+	} {
+		if !strings.Contains(diff, want) {
+			t.Errorf("diff is missing %q:\n%s", want, diff)
+		}
+	}
+	if len(m.Review) != 1 || !strings.Contains(m.Review[0], "kustomization.yaml: created") {
+		t.Errorf("review = %v", m.Review)
+	}
+}
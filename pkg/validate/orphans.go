@@ -0,0 +1,207 @@
+package validate
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/erauner/homelab-shadow/pkg/flux"
+	"gopkg.in/yaml.v3"
+)
+
+// Orphan rules
+const (
+	RuleOrphanOverlay   = "orphan-overlay"
+	RuleUnreferencedApp = "unreferenced-app"
+)
+
+// ValidateOrphans cross-references app overlay directories with Application
+// source paths (including ApplicationSet-generated ones)
+//
+// Rules:
+//   - orphan-overlay: an overlay that no Application deploys, directly or through
+//     another referenced kustomization's resources/components (dead code)
+//   - unreferenced-app: an Application whose apps/ path is not an overlay
+//     (missing paths are left to argocd-app-path-missing)
+func (v *ClusterValidator) ValidateOrphans() []Result {
+	results := []Result{}
+
+	registry, err := v.clusterRegistry()
+	if err != nil {
+		return results // reported by ValidateClusterNames
+	}
+
+	overlays, err := v.discoverOverlays()
+	if err != nil {
+		results = append(results, Result{
+			Cluster:  "global",
+			Rule:     "app-discovery-error",
+			Path:     "apps/",
+			Message:  fmt.Sprintf("Failed to discover overlays: %v", err),
+			Severity: "error",
+		})
+		return results
+	}
+
+	apps, files, err := flux.LoadAllApplications(v.RepoPath)
+	if err != nil {
+		return results // reported by ValidateArgoCDSourcePaths
+	}
+
+	origin := normalizeRepoURL(gitOrigin(v.RepoPath))
+	var roots []string
+	for i, app := range apps {
+		relFile, _ := filepath.Rel(v.RepoPath, files[i])
+		relFile = filepath.ToSlash(relFile)
+
+		for _, source := range app.AllSources() {
+			if source.Path == "" {
+				continue
+			}
+			if origin != "" && source.RepoURL != "" && normalizeRepoURL(source.RepoURL) != origin {
+				continue
+			}
+			sourcePath := cleanRelPath(source.Path)
+			roots = append(roots, sourcePath)
+
+			if !strings.HasPrefix(sourcePath, "apps/") || overlays[sourcePath] {
+				continue
+			}
+			if info, err := os.Stat(filepath.Join(v.RepoPath, filepath.FromSlash(sourcePath))); err != nil || !info.IsDir() {
+				continue
+			}
+			results = append(results, Result{
+				Cluster:  "global",
+				Rule:     RuleUnreferencedApp,
+				Path:     relFile,
+				Message:  fmt.Sprintf("%s references %q, which is not an app overlay (apps/<app>/overlays/<cluster>/<env>)", app.KindName(), source.Path),
+				Severity: "warn",
+			})
+		}
+	}
+
+	referenced := v.reachableKustomizations(roots)
+
+	var orphans []string
+	for dir := range overlays {
+		if !referenced[dir] {
+			orphans = append(orphans, dir)
+		}
+	}
+	sort.Strings(orphans)
+
+	for _, dir := range orphans {
+		clusterName := "global"
+		if p, ok := registry.ParseAppPath(dir); ok && p.Cluster != "" {
+			clusterName = p.Cluster
+		}
+		results = append(results, Result{
+			Cluster:  clusterName,
+			Rule:     RuleOrphanOverlay,
+			Path:     dir,
+			Message:  "Overlay is not deployed by any ArgoCD Application",
+			Severity: "warn",
+		})
+	}
+
+	return results
+}
+
+// discoverOverlays returns every deployable app overlay directory
+// (apps/<app>/{overlays,stack,db/overlays}/[<cluster>/]<env>) with a kustomization
+func (v *ClusterValidator) discoverOverlays() (map[string]bool, error) {
+	registry, err := v.clusterRegistry()
+	if err != nil {
+		return nil, err
+	}
+	apps, err := v.discoverApps()
+	if err != nil {
+		return nil, err
+	}
+
+	overlays := make(map[string]bool)
+	for _, app := range apps {
+		for _, overlayRoot := range []string{"overlays", "stack", "db/overlays"} {
+			root := filepath.Join(v.RepoPath, "apps", app, filepath.FromSlash(overlayRoot))
+			if _, err := os.Stat(root); err != nil {
+				continue
+			}
+			err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+				if err != nil {
+					return err
+				}
+				if !d.IsDir() || !hasKustomization(path) {
+					return nil
+				}
+				rel, err := filepath.Rel(v.RepoPath, path)
+				if err != nil {
+					return err
+				}
+				rel = filepath.ToSlash(rel)
+				// A <cluster> directory holding <env> overlays is not itself deployable
+				if p, ok := registry.ParseAppPath(rel); ok && p.Environment != "" && !registry.Has(p.Environment) {
+					overlays[rel] = true
+				}
+				return nil
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return overlays, nil
+}
+
+// reachableKustomizations returns roots plus every local directory they
+// include through resources, bases, and components, transitively
+func (v *ClusterValidator) reachableKustomizations(roots []string) map[string]bool {
+	seen := make(map[string]bool)
+	queue := append([]string(nil), roots...)
+	for len(queue) > 0 {
+		dir := queue[0]
+		queue = queue[1:]
+		if seen[dir] {
+			continue
+		}
+		seen[dir] = true
+
+		data, err := readKustomization(filepath.Join(v.RepoPath, filepath.FromSlash(dir)))
+		if err != nil {
+			continue
+		}
+		var k KustomizationFile
+		if err := yaml.Unmarshal(data, &k); err != nil {
+			continue
+		}
+		refs := append(append(append([]string(nil), k.Resources...), k.Bases...), k.Components...)
+		for _, ref := range refs {
+			if strings.Contains(ref, "://") || strings.HasPrefix(ref, "github.com/") {
+				continue // remote resource
+			}
+			target := cleanRelPath(filepath.ToSlash(filepath.Join(dir, ref)))
+			if strings.HasPrefix(target, "../") {
+				continue
+			}
+			queue = append(queue, target)
+		}
+	}
+	return seen
+}
+
+// readKustomization reads the kustomization file in dir
+func readKustomization(dir string) ([]byte, error) {
+	for _, name := range []string{"kustomization.yaml", "kustomization.yml", "Kustomization"} {
+		if data, err := os.ReadFile(filepath.Join(dir, name)); err == nil {
+			return data, nil
+		}
+	}
+	return nil, fmt.Errorf("no kustomization in %s", dir)
+}
+
+// cleanRelPath normalizes a repo-relative path ("./apps/x/" -> "apps/x")
+func cleanRelPath(p string) string {
+	return filepath.ToSlash(filepath.Clean(strings.TrimPrefix(p, "./")))
+}
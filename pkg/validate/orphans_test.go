@@ -0,0 +1,36 @@
+package validate_test
+
+import (
+	"testing"
+
+	"github.com/erauner/homelab-shadow/pkg/validate"
+	"github.com/erauner/homelab-shadow/pkg/validate/validatetest"
+)
+
+func TestValidateOrphans(t *testing.T) {
+	root := validatetest.Build(t, validatetest.Repo{
+		Clusters: []string{"erauner-home"},
+		Kustomizations: map[string]validatetest.Kustomization{
+			"apps/coder/base": {},
+			"apps/coder/overlays/erauner-home/production": {Resources: []string{"../../../base"}},
+			"apps/coder/overlays/erauner-home/staging":    {Resources: []string{"../../../base"}},
+			"apps/media/overlays/erauner-home/production": {},
+			"apps/media/stack/erauner-home/production":    {Resources: []string{"../../../overlays/erauner-home/production"}},
+			"apps/legacy/overlays/production":             {},
+			"apps/direct/base":                            {},
+		},
+		Applications: []validatetest.Application{
+			{Name: "coder", Path: "apps/coder/overlays/erauner-home/production"},
+			{Name: "media", Path: "./apps/media/stack/erauner-home/production/"},
+			{Name: "direct", Path: "apps/direct/base"},
+			{Name: "typo", Path: "apps/codr/overlays/erauner-home/production"},
+		},
+	})
+
+	v := validate.NewClusterValidator(root, false)
+	validatetest.AssertFindings(t, v.ValidateOrphans(),
+		validatetest.Finding{Rule: validate.RuleOrphanOverlay, Path: "apps/coder/overlays/erauner-home/staging", Cluster: "erauner-home", Severity: "warn"},
+		validatetest.Finding{Rule: validate.RuleOrphanOverlay, Path: "apps/legacy/overlays/production", Cluster: "global"},
+		validatetest.Finding{Rule: validate.RuleUnreferencedApp, Path: "argocd-apps/applications/direct.yaml", Severity: "warn"},
+	)
+}
@@ -0,0 +1,60 @@
+package validate
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/erauner/homelab-shadow/pkg/config"
+)
+
+// UnownedGroup is the group name used for findings with no configured owner
+const UnownedGroup = "(unowned)"
+
+// AssignOwners sets Result.Owner from the owners mapping in .shadow.yaml
+// Cluster-scoped findings (e.g. "bootstrap") are relative to clusters/<cluster>/,
+// so those are also matched against the full clusters/ path
+func AssignOwners(results []Result, cfg *config.Config) {
+	if cfg == nil || len(cfg.Owners) == 0 {
+		return
+	}
+
+	for i := range results {
+		r := &results[i]
+
+		// Duplicate-style findings join several paths; the first is representative
+		path := strings.TrimSpace(strings.Split(r.Path, ",")[0])
+
+		owner := cfg.OwnerFor(path)
+		if owner == "" && r.Cluster != "" && r.Cluster != "global" {
+			owner = cfg.OwnerFor(filepath.Join("clusters", r.Cluster, path))
+		}
+		r.Owner = owner
+	}
+}
+
+// GroupByOwner groups results by owner, preserving result order within each group
+// Returns the sorted owner names (unowned last) and the grouped results
+func GroupByOwner(results []Result) ([]string, map[string][]Result) {
+	groups := make(map[string][]Result)
+	for _, r := range results {
+		owner := r.Owner
+		if owner == "" {
+			owner = UnownedGroup
+		}
+		groups[owner] = append(groups[owner], r)
+	}
+
+	owners := make([]string, 0, len(groups))
+	for owner := range groups {
+		if owner != UnownedGroup {
+			owners = append(owners, owner)
+		}
+	}
+	sort.Strings(owners)
+	if _, ok := groups[UnownedGroup]; ok {
+		owners = append(owners, UnownedGroup)
+	}
+
+	return owners, groups
+}
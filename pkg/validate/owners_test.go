@@ -0,0 +1,65 @@
+package validate
+
+import (
+	"testing"
+
+	"github.com/erauner/homelab-shadow/pkg/config"
+)
+
+func TestAssignOwners(t *testing.T) {
+	cfg := &config.Config{Owners: map[string]string{
+		"apps/coder":            "dev-tools",
+		"security":              "security",
+		"clusters/erauner-home": "platform",
+	}}
+
+	results := []Result{
+		{Cluster: "global", Rule: "app-overlay-legacy-flat", Path: "apps/coder/overlays/production"},
+		{Cluster: "global", Rule: "namespace-duplicate", Path: "security/namespaces/a.yaml, apps/x/ns.yaml"},
+		{Cluster: "erauner-home", Rule: "cluster-missing-dir", Path: "bootstrap"},
+		{Cluster: "global", Rule: "app-create-namespace", Path: "argocd-apps/applications/foo.yaml"},
+	}
+
+	AssignOwners(results, cfg)
+
+	want := []string{"dev-tools", "security", "platform", ""}
+	for i, w := range want {
+		if results[i].Owner != w {
+			t.Errorf("results[%d].Owner = %q, want %q (path %s)", i, results[i].Owner, w, results[i].Path)
+		}
+	}
+}
+
+func TestAssignOwners_NoConfig(t *testing.T) {
+	results := []Result{{Path: "apps/coder"}}
+	AssignOwners(results, nil)
+	AssignOwners(results, &config.Config{})
+	if results[0].Owner != "" {
+		t.Errorf("expected no owner, got %q", results[0].Owner)
+	}
+}
+
+func TestGroupByOwner(t *testing.T) {
+	results := []Result{
+		{Rule: "a", Owner: "zeta"},
+		{Rule: "b"},
+		{Rule: "c", Owner: "alpha"},
+		{Rule: "d", Owner: "zeta"},
+	}
+
+	owners, groups := GroupByOwner(results)
+
+	wantOrder := []string{"alpha", "zeta", UnownedGroup}
+	if len(owners) != len(wantOrder) {
+		t.Fatalf("GroupByOwner() owners = %v, want %v", owners, wantOrder)
+	}
+	for i, o := range wantOrder {
+		if owners[i] != o {
+			t.Errorf("owners[%d] = %q, want %q", i, owners[i], o)
+		}
+	}
+
+	if len(groups["zeta"]) != 2 || groups["zeta"][0].Rule != "a" || groups["zeta"][1].Rule != "d" {
+		t.Errorf("unexpected zeta group: %+v", groups["zeta"])
+	}
+}
@@ -0,0 +1,96 @@
+package validate
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/erauner/homelab-shadow/pkg/command"
+)
+
+// PluginRequest is written to an executable plugin's stdin
+type PluginRequest struct {
+	Repo              string   `json:"repo"`
+	Clusters          []string `json:"clusters"`
+	KubernetesVersion string   `json:"kubernetesVersion,omitempty"`
+}
+
+// LoadPlugin loads the rules of a plugin: a Go plugin (.so) exporting Rules,
+// or any other executable, which is run as one rule (see execRule)
+//
+// A path without a separator is looked up on PATH; relative paths are
+// resolved against the working directory.
+func LoadPlugin(path string) ([]Rule, error) {
+	if strings.HasSuffix(path, ".so") {
+		return loadGoPlugin(path)
+	}
+
+	resolved, err := exec.LookPath(path)
+	if err != nil {
+		return nil, fmt.Errorf("plugin %s: %w", path, err)
+	}
+	if resolved, err = filepath.Abs(resolved); err != nil {
+		return nil, fmt.Errorf("plugin %s: %w", path, err)
+	}
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	return []Rule{execRule{name: name, path: resolved}}, nil
+}
+
+// execRule runs an executable plugin in the repository root with a
+// PluginRequest on stdin; it prints its findings to stdout as a JSON array of
+// Results. Findings without a rule use the plugin's file name (without
+// extension), and a plugin that fails or prints anything else is reported as
+// an error finding of that rule.
+type execRule struct {
+	name string
+	path string
+}
+
+func (r execRule) Name() string     { return r.name }
+func (r execRule) Severity() string { return "error" }
+
+func (r execRule) Run(ctx RepoContext) []Result {
+	request, err := json.Marshal(PluginRequest{Repo: ctx.RepoPath, Clusters: ctx.Clusters, KubernetesVersion: ctx.KubernetesVersion})
+	if err != nil {
+		return r.failed(err)
+	}
+
+	cmd := command.Command(r.path)
+	cmd.Dir = ctx.RepoPath
+	cmd.Env = append(os.Environ(), "SHADOW_REPO="+ctx.RepoPath)
+	cmd.Stdin = bytes.NewReader(request)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			err = fmt.Errorf("%w: %s", err, strings.SplitN(msg, "\n", 2)[0])
+		}
+		return r.failed(err)
+	}
+
+	var results []Result
+	if err := json.Unmarshal(out, &results); err != nil {
+		return r.failed(fmt.Errorf("invalid output (want a JSON array of findings): %w", err))
+	}
+	for i := range results {
+		// Suppression comes from the repo's config, not the plugin
+		results[i].Suppressed, results[i].SuppressedBy = false, ""
+	}
+	return results
+}
+
+// failed reports a plugin that could not run as an error finding
+func (r execRule) failed(err error) []Result {
+	return []Result{{
+		Cluster:  "global",
+		Rule:     r.name,
+		Path:     r.path,
+		Message:  fmt.Sprintf("Plugin failed: %v", err),
+		Severity: "error",
+	}}
+}
@@ -0,0 +1,30 @@
+//go:build goplugin
+
+package validate
+
+import (
+	"fmt"
+	"plugin"
+)
+
+// loadGoPlugin opens a Go plugin built with -buildmode=plugin against the
+// same shadow version, returning its exported Rules (a []validate.Rule
+// variable or a func() []validate.Rule)
+func loadGoPlugin(path string) ([]Rule, error) {
+	p, err := plugin.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("plugin %s: %w", path, err)
+	}
+	sym, err := p.Lookup("Rules")
+	if err != nil {
+		return nil, fmt.Errorf("plugin %s: %w", path, err)
+	}
+	switch rules := sym.(type) {
+	case *[]Rule:
+		return *rules, nil
+	case func() []Rule:
+		return rules(), nil
+	default:
+		return nil, fmt.Errorf("plugin %s: Rules is %T, want []validate.Rule or func() []validate.Rule", path, sym)
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !goplugin
+
+package validate
+
+import "fmt"
+
+// loadGoPlugin is a stub used when the binary is built without -tags goplugin
+func loadGoPlugin(path string) ([]Rule, error) {
+	return nil, fmt.Errorf("plugin %s: Go plugins are not available in this build (rebuild with -tags goplugin, or use an executable plugin)", path)
+}
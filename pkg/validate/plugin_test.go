@@ -0,0 +1,85 @@
+package validate
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writePlugin writes an executable shell script plugin
+func writePlugin(t *testing.T, name, script string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadPlugin_Exec(t *testing.T) {
+	// The plugin echoes the request's repo and first cluster back in its findings
+	path := writePlugin(t, "homelab-labels.sh", `req=$(cat)
+repo=$(printf '%s' "$req" | sed 's/.*"repo":"\([^"]*\)".*/\1/')
+cluster=$(printf '%s' "$req" | sed 's/.*"clusters":\["\([^"]*\)".*/\1/')
+[ "$repo" = "$SHADOW_REPO" ] && [ "$repo" = "$(pwd)" ] || exit 3
+printf '[{"cluster":"%s","path":"apps/coder","message":"missing team label","suppressed":true},' "$cluster"
+printf '{"rule":"homelab-owner","path":"apps/web","message":"no owner","severity":"warn"}]'
+`)
+
+	rules, err := LoadPlugin(path)
+	if err != nil || len(rules) != 1 {
+		t.Fatalf("LoadPlugin() = %v, %v", rules, err)
+	}
+	if rules[0].Name() != "homelab-labels" {
+		t.Errorf("Name() = %q, want the file name without extension", rules[0].Name())
+	}
+
+	repo := t.TempDir()
+	got := RunRule(rules[0], RepoContext{RepoPath: repo, Clusters: []string{"erauner-home"}})
+	if len(got) != 2 {
+		t.Fatalf("Run() = %v, want 2 findings", got)
+	}
+	want := Result{Cluster: "erauner-home", Rule: "homelab-labels", Path: "apps/coder", Message: "missing team label", Severity: "error"}
+	if got[0] != want {
+		t.Errorf("finding = %+v, want %+v (defaults applied, suppression cleared)", got[0], want)
+	}
+	if got[1].Rule != "homelab-owner" || got[1].Severity != "warn" || got[1].Cluster != "global" {
+		t.Errorf("finding = %+v", got[1])
+	}
+}
+
+func TestLoadPlugin_Failures(t *testing.T) {
+	repo := t.TempDir()
+	tests := map[string]string{
+		"exit":    "echo 'cannot read config' >&2; exit 1\n",
+		"garbage": "echo 'not json'\n",
+	}
+	for name, script := range tests {
+		rules, err := LoadPlugin(writePlugin(t, name, script))
+		if err != nil {
+			t.Fatalf("LoadPlugin(%s) error = %v", name, err)
+		}
+		got := RunRule(rules[0], RepoContext{RepoPath: repo})
+		if len(got) != 1 || got[0].Rule != name || got[0].Severity != "error" || !strings.HasPrefix(got[0].Message, "Plugin failed") {
+			t.Errorf("%s: Run() = %+v, want one error finding", name, got)
+		}
+		if name == "exit" && !strings.Contains(got[0].Message, "cannot read config") {
+			t.Errorf("%s: message %q should include the plugin's stderr", name, got[0].Message)
+		}
+	}
+
+	if _, err := LoadPlugin(filepath.Join(repo, "missing")); err == nil {
+		t.Error("LoadPlugin() of a missing file should fail")
+	}
+	if !strings.Contains(errString(LoadPlugin(filepath.Join(repo, "checks.so"))), "plugin") {
+		t.Error("LoadPlugin() of a missing Go plugin should fail")
+	}
+}
+
+func errString(_ []Rule, err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
@@ -0,0 +1,135 @@
+package validate
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/erauner/homelab-shadow/pkg/argocd"
+)
+
+// AppProject rules
+const (
+	// RuleArgoCDProjectMissing flags an Application whose spec.project no
+	// AppProject in the repo defines
+	RuleArgoCDProjectMissing = "argocd-project-missing"
+	// RuleArgoCDProjectDefault flags an Application left in the default project
+	RuleArgoCDProjectDefault = "argocd-project-default"
+	// RuleArgoCDProjectSourceDenied flags a source repoURL its project's
+	// sourceRepos don't permit
+	RuleArgoCDProjectSourceDenied = "argocd-project-source-denied"
+	// RuleArgoCDProjectDestinationDenied flags a destination its project's
+	// destinations don't permit
+	RuleArgoCDProjectDestinationDenied = "argocd-project-destination-denied"
+)
+
+// ProjectRules lists every AppProject rule
+var ProjectRules = []string{"argocd-project-validation-error", RuleArgoCDProjectMissing, RuleArgoCDProjectDefault,
+	RuleArgoCDProjectSourceDenied, RuleArgoCDProjectDestinationDenied}
+
+// ValidateProjects checks ArgoCD Applications against the AppProjects defined
+// under argocd-apps/ and clusters/: each must name a defined project rather
+// than fall back to default, and use only source repos and destinations its
+// project permits. A project defined several times (once per cluster)
+// permits what any definition does; default permits everything unless the
+// repo defines it. Repos without AppProjects aren't checked
+func (v *ClusterValidator) ValidateProjects() []Result {
+	results := []Result{}
+
+	projects, _, err := argocd.LoadProjects(v.RepoPath)
+	if err != nil {
+		results = append(results, Result{
+			Cluster:  "global",
+			Rule:     "argocd-project-validation-error",
+			Path:     "argocd-apps/",
+			Message:  fmt.Sprintf("Failed to load AppProjects: %v", err),
+			Severity: "error",
+		})
+		return results
+	}
+	if len(projects) == 0 {
+		return results
+	}
+	byName := make(map[string][]*argocd.AppProject)
+	for _, p := range projects {
+		byName[p.Name] = append(byName[p.Name], p)
+	}
+
+	registry, err := v.clusterRegistry()
+	if err != nil {
+		return results // reported by ValidateClusterNames
+	}
+	apps, files, err := argocd.LoadApplications(v.RepoPath)
+	if err != nil {
+		return results // reported by ValidateArgoCDSourcePaths
+	}
+
+	for i, app := range apps {
+		relFile, _ := filepath.Rel(v.RepoPath, files[i])
+		relFile = filepath.ToSlash(relFile)
+		dest := app.Destination(registry)
+		result := func(rule, severity, message string) {
+			results = append(results, Result{
+				Cluster:  clusterOrGlobal(dest.Cluster),
+				Rule:     rule,
+				Path:     relFile,
+				Message:  message,
+				Severity: severity,
+			})
+		}
+
+		project := app.Project
+		switch {
+		case project == "":
+			result(RuleArgoCDProjectDefault, "warn", fmt.Sprintf("%s doesn't set spec.project; set it to one of the repo's AppProjects", app.KindName()))
+			project = argocd.DefaultProject
+		case project == argocd.DefaultProject:
+			result(RuleArgoCDProjectDefault, "warn", fmt.Sprintf("%s uses the default project, which the repo's AppProjects don't restrict unless it is redefined", app.KindName()))
+		}
+		defined := byName[project]
+		if len(defined) == 0 {
+			if project != argocd.DefaultProject {
+				result(RuleArgoCDProjectMissing, "error", fmt.Sprintf("%s references project %q, which no AppProject defines", app.KindName(), project))
+			}
+			continue
+		}
+
+		for _, source := range app.AllSources() {
+			if source.RepoURL == "" || anyProject(defined, func(p *argocd.AppProject) bool { return p.PermitsSource(source.RepoURL) }) {
+				continue
+			}
+			result(RuleArgoCDProjectSourceDenied, "error", fmt.Sprintf("%s uses source %s, which project %s's sourceRepos don't permit", app.KindName(), source.RepoURL, project))
+		}
+
+		var name string
+		if app.Cluster != app.Server {
+			name = app.Cluster
+		}
+		if !anyProject(defined, func(p *argocd.AppProject) bool { return p.PermitsDestination(dest.Server, name, dest.Namespace) }) {
+			result(RuleArgoCDProjectDestinationDenied, "error", fmt.Sprintf("%s deploys to %s, which project %s's destinations don't permit", app.KindName(), describeDestination(dest.Server, name, dest.Namespace), project))
+		}
+	}
+
+	return results
+}
+
+// anyProject reports whether any of projects satisfies permits
+func anyProject(projects []*argocd.AppProject, permits func(*argocd.AppProject) bool) bool {
+	for _, p := range projects {
+		if permits(p) {
+			return true
+		}
+	}
+	return false
+}
+
+// describeDestination names a destination for messages
+func describeDestination(server, name, namespace string) string {
+	cluster := name
+	if cluster == "" {
+		cluster = server
+	}
+	if cluster == "" {
+		cluster = "an unnamed cluster"
+	}
+	return fmt.Sprintf("namespace %q on %s", namespace, cluster)
+}
@@ -0,0 +1,69 @@
+package validate_test
+
+import (
+	"testing"
+
+	"github.com/erauner/homelab-shadow/pkg/validate"
+	"github.com/erauner/homelab-shadow/pkg/validate/validatetest"
+)
+
+const appProjects = `apiVersion: argoproj.io/v1alpha1
+kind: AppProject
+metadata:
+  name: apps
+  namespace: argocd
+spec:
+  sourceRepos:
+    - https://github.com/erauner/*
+  destinations:
+    - server: https://kubernetes.default.svc
+      namespace: "*"
+    - server: https://kubernetes.default.svc
+      namespace: "!kube-system"
+---
+apiVersion: argoproj.io/v1alpha1
+kind: AppProject
+metadata:
+  name: platform
+  namespace: argocd
+spec:
+  sourceRepos:
+    - git@github.com:erauner/homelab-k8s.git
+  destinations:
+    - name: erauner-home
+      namespace: "*"
+`
+
+func TestValidateProjects(t *testing.T) {
+	path := "apps/coder/overlays/erauner-home/production"
+	repo := validatetest.Repo{
+		Clusters: []string{"erauner-home"},
+		Files:    map[string]string{"argocd-apps/projects/projects.yaml": appProjects},
+		Applications: []validatetest.Application{
+			{Name: "platform", Path: path, Namespace: "coder", Project: "platform", Destination: "erauner-home"},
+			{Name: "unset", Path: path, Namespace: "coder"},
+			{Name: "default", Path: path, Namespace: "coder", Project: "default"},
+			{Name: "missing", Path: path, Namespace: "coder", Project: "nope"},
+			// apps only permits https://github.com/erauner/* repos
+			{Name: "source", Path: path, Namespace: "coder", Project: "apps", Server: "https://kubernetes.default.svc"},
+			{Name: "elsewhere", Path: path, Namespace: "coder", Project: "platform", Destination: "other"},
+		},
+	}
+	v := validate.NewClusterValidator(validatetest.Build(t, repo), false)
+
+	validatetest.AssertFindings(t, v.ValidateProjects(),
+		validatetest.Finding{Rule: validate.RuleArgoCDProjectDefault, Path: "argocd-apps/applications/unset.yaml", Severity: "warn"},
+		validatetest.Finding{Rule: validate.RuleArgoCDProjectDefault, Path: "argocd-apps/applications/default.yaml", Severity: "warn"},
+		validatetest.Finding{Rule: validate.RuleArgoCDProjectMissing, Path: "argocd-apps/applications/missing.yaml", Severity: "error"},
+		validatetest.Finding{Rule: validate.RuleArgoCDProjectSourceDenied, Path: "argocd-apps/applications/source.yaml", Severity: "error"},
+		validatetest.Finding{Rule: validate.RuleArgoCDProjectDestinationDenied, Path: "argocd-apps/applications/elsewhere.yaml", Severity: "error"},
+	)
+}
+
+func TestValidateProjects_NoProjects(t *testing.T) {
+	root := validatetest.Build(t, validatetest.Repo{
+		Applications: []validatetest.Application{{Name: "unset", Path: "apps/coder/base", Namespace: "coder"}},
+	})
+	v := validate.NewClusterValidator(root, false)
+	validatetest.AssertFindings(t, v.ValidateProjects())
+}
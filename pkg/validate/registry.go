@@ -0,0 +1,144 @@
+package validate
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/erauner/homelab-shadow/pkg/cluster"
+)
+
+// clusterRegistry loads the cluster registry once per validator
+func (v *ClusterValidator) clusterRegistry() (*cluster.Registry, error) {
+	if v.registry != nil {
+		return v.registry, nil
+	}
+	registry, err := cluster.Load(v.RepoPath)
+	if err != nil {
+		return nil, err
+	}
+	v.registry = registry
+	return registry, nil
+}
+
+// ValidateClusterNames checks app overlay directories against the cluster registry
+//
+// Rules:
+//   - cluster-name-collision: a registered cluster directory that also holds a
+//     kustomization directly (ambiguous with a legacy env overlay), or an env
+//     directory named after a cluster
+//   - cluster-unregistered-dir: a directory laid out like <cluster>/<env> whose
+//     name is not a registered cluster; sync does not render its overlays
+//     (near-misses of a cluster name are left to app-overlay-unknown-cluster)
+func (v *ClusterValidator) ValidateClusterNames() []Result {
+	results := []Result{}
+
+	registry, err := v.clusterRegistry()
+	if err != nil {
+		results = append(results, Result{
+			Cluster:  "global",
+			Rule:     "cluster-registry-error",
+			Path:     cluster.RegistryFile,
+			Message:  fmt.Sprintf("Failed to load cluster registry: %v", err),
+			Severity: "error",
+		})
+		return results
+	}
+	if registry.Empty() {
+		return results
+	}
+
+	apps, err := v.discoverApps()
+	if err != nil {
+		return results // reported by ValidateAppOverlayStructure
+	}
+
+	for _, app := range apps {
+		for _, overlayRoot := range []string{"overlays", "stack", "db/overlays"} {
+			relRoot := filepath.ToSlash(filepath.Join("apps", app, overlayRoot))
+			entries, err := os.ReadDir(filepath.Join(v.RepoPath, relRoot))
+			if err != nil {
+				continue
+			}
+
+			for _, entry := range entries {
+				if !entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+					continue
+				}
+				name := entry.Name()
+				relPath := relRoot + "/" + name
+				childPath := filepath.Join(v.RepoPath, relPath)
+
+				if !registry.Has(name) {
+					// Near-misses are reported as app-overlay-unknown-cluster
+					if registry.Suggest(name) != "" {
+						continue
+					}
+					if !fileExists(filepath.Join(childPath, "kustomization.yaml")) && v.looksLikeClusterDir(childPath) {
+						results = append(results, Result{
+							Cluster:  "global",
+							Rule:     "cluster-unregistered-dir",
+							Path:     relPath,
+							Message:  fmt.Sprintf("%q is laid out like a cluster directory but is not a registered cluster (%s); its overlays are not rendered", name, registry.Source),
+							Severity: "warn",
+						})
+					}
+					continue
+				}
+
+				if fileExists(filepath.Join(childPath, "kustomization.yaml")) {
+					results = append(results, Result{
+						Cluster:  name,
+						Rule:     "cluster-name-collision",
+						Path:     relPath,
+						Message:  fmt.Sprintf("Overlay directory %q is named after a cluster but holds a kustomization directly; it is treated as a cluster directory and not rendered", name),
+						Severity: "warn",
+					})
+				}
+
+				envEntries, _ := os.ReadDir(childPath)
+				for _, env := range envEntries {
+					if env.IsDir() && registry.Has(env.Name()) {
+						results = append(results, Result{
+							Cluster:  name,
+							Rule:     "cluster-name-collision",
+							Path:     relPath + "/" + env.Name(),
+							Message:  fmt.Sprintf("Environment directory %q has the same name as a cluster", env.Name()),
+							Severity: "warn",
+						})
+					}
+				}
+			}
+		}
+	}
+
+	return results
+}
+
+// unknownClusterOverlay flags an app overlay directory named like a typo of a
+// registered cluster (e.g. eraunerhome for erauner-home)
+func (v *ClusterValidator) unknownClusterOverlay(app, overlayRoot, name string) (Result, bool) {
+	registry, err := v.clusterRegistry()
+	if err != nil {
+		return Result{}, false
+	}
+	suggestion := registry.Suggest(name)
+	if suggestion == "" {
+		return Result{}, false
+	}
+
+	return Result{
+		Cluster:  "global",
+		Rule:     "app-overlay-unknown-cluster",
+		Path:     fmt.Sprintf("apps/%s/%s/%s", app, overlayRoot, name),
+		Message:  fmt.Sprintf("Overlay directory %q is not a known cluster - did you mean %q? (clusters: %s)", name, suggestion, strings.Join(registry.Names(), ", ")),
+		Severity: "error",
+	}, true
+}
+
+// fileExists reports whether path exists
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -0,0 +1,112 @@
+package validate_test
+
+import (
+	"testing"
+
+	"github.com/erauner/homelab-shadow/pkg/validate"
+	"github.com/erauner/homelab-shadow/pkg/validate/validatetest"
+)
+
+func TestValidateClusterNames(t *testing.T) {
+	tests := []struct {
+		name string
+		repo validatetest.Repo
+		want []validatetest.Finding
+	}{
+		{
+			name: "registered cluster layout is clean",
+			repo: validatetest.Repo{
+				Clusters: []string{"erauner-home"},
+				Kustomizations: map[string]validatetest.Kustomization{
+					"apps/coder/overlays/erauner-home/production": {Resources: []string{"../../../base"}},
+					"apps/coder/overlays/staging":                 {Resources: []string{"../../base"}},
+				},
+			},
+		},
+		{
+			name: "cluster directory holding a kustomization",
+			repo: validatetest.Repo{
+				Clusters: []string{"erauner-home"},
+				Kustomizations: map[string]validatetest.Kustomization{
+					"apps/coder/overlays/erauner-home": {Resources: []string{"../../base"}},
+				},
+			},
+			want: []validatetest.Finding{
+				{Rule: "cluster-name-collision", Path: "apps/coder/overlays/erauner-home", Cluster: "erauner-home", Severity: "warn"},
+			},
+		},
+		{
+			name: "env directory named after a cluster",
+			repo: validatetest.Repo{
+				Clusters: []string{"erauner-home", "erauner-cloud"},
+				Kustomizations: map[string]validatetest.Kustomization{
+					"apps/coder/stack/erauner-home/erauner-cloud": {},
+				},
+			},
+			want: []validatetest.Finding{
+				{Rule: "cluster-name-collision", Path: "apps/coder/stack/erauner-home/erauner-cloud"},
+			},
+		},
+		{
+			name: "unregistered cluster-like directory",
+			repo: validatetest.Repo{
+				Clusters: []string{"erauner-home"},
+				Kustomizations: map[string]validatetest.Kustomization{
+					"apps/coder/db/overlays/erauner-lab/production": {},
+				},
+			},
+			want: []validatetest.Finding{
+				{Rule: "cluster-unregistered-dir", Path: "apps/coder/db/overlays/erauner-lab", Severity: "warn"},
+			},
+		},
+		{
+			name: "typo of a cluster is left to app-overlay-unknown-cluster",
+			repo: validatetest.Repo{
+				Clusters: []string{"erauner-home"},
+				Kustomizations: map[string]validatetest.Kustomization{
+					"apps/coder/overlays/eraunerhome/production": {},
+				},
+			},
+		},
+		{
+			name: "clusters.yaml overrides clusters/",
+			repo: validatetest.Repo{
+				Clusters: []string{"erauner-home"},
+				Files:    map[string]string{"clusters.yaml": "clusters:\n  - erauner-lab\n"},
+				Kustomizations: map[string]validatetest.Kustomization{
+					"apps/coder/overlays/erauner-lab/production": {},
+				},
+			},
+		},
+		{
+			name: "no registry skips checks",
+			repo: validatetest.Repo{
+				Kustomizations: map[string]validatetest.Kustomization{
+					"apps/coder/overlays/erauner-lab/production": {},
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := validatetest.Build(t, tt.repo)
+			v := validate.NewClusterValidator(root, false)
+			validatetest.AssertFindings(t, v.ValidateClusterNames(), tt.want...)
+		})
+	}
+}
+
+func TestDiscoverClusters_RegistryFile(t *testing.T) {
+	root := validatetest.Build(t, validatetest.Repo{
+		Files: map[string]string{"clusters.yaml": "clusters:\n  - name: erauner-home\n  - name: erauner-cloud\n"},
+	})
+
+	clusters, err := validate.NewClusterValidator(root, false).DiscoverClusters()
+	if err != nil {
+		t.Fatalf("DiscoverClusters() error = %v", err)
+	}
+	if len(clusters) != 2 || clusters[0] != "erauner-cloud" || clusters[1] != "erauner-home" {
+		t.Errorf("DiscoverClusters() = %v", clusters)
+	}
+}
@@ -0,0 +1,99 @@
+package validate
+
+import "sort"
+
+// RepoContext is what a rule runs against
+type RepoContext struct {
+	// Validator is the validator of RepoPath, which caches what rules share
+	// (the cluster registry)
+	Validator *ClusterValidator
+
+	// RepoPath is the repository being validated
+	RepoPath string
+
+	// Clusters are the clusters being validated; a per-cluster rule gets one
+	Clusters []string
+
+	// BuildAppPaths also kustomize builds every ArgoCD Application source path
+	BuildAppPaths bool
+
+	// KubernetesVersion enables the API version rules when set
+	KubernetesVersion string
+}
+
+// Rule is a registered validator
+//
+// A rule whose findings use several rule names (the built-in checks) also
+// implements RuleNames() []string so --only and --skip can select it; other
+// rules are selected by Name. A rule implementing PerCluster() bool returning
+// true runs once for each validated cluster.
+type Rule interface {
+	// Name names the rule in --list-rules and while it runs
+	Name() string
+
+	// Severity is given to findings that don't set one
+	Severity() string
+
+	Run(ctx RepoContext) []Result
+}
+
+// rules are the registered rules, in the order they run
+var rules []Rule
+
+// Register adds a rule that runs after those already registered
+func Register(r Rule) {
+	rules = append(rules, r)
+}
+
+// Registered returns the registered rules in the order they run
+func Registered() []Rule {
+	return append([]Rule(nil), rules...)
+}
+
+// NamesOf returns the rule names r's findings use
+func NamesOf(r Rule) []string {
+	if multi, ok := r.(interface{ RuleNames() []string }); ok {
+		return multi.RuleNames()
+	}
+	return []string{r.Name()}
+}
+
+// PerCluster reports whether r runs once for each validated cluster
+func PerCluster(r Rule) bool {
+	c, ok := r.(interface{ PerCluster() bool })
+	return ok && c.PerCluster()
+}
+
+// RunRule runs r, giving findings without a severity, cluster, or rule r's
+// severity, "global", and r's name
+func RunRule(r Rule, ctx RepoContext) []Result {
+	results := r.Run(ctx)
+	for i := range results {
+		if results[i].Severity == "" {
+			results[i].Severity = r.Severity()
+		}
+		if results[i].Cluster == "" {
+			results[i].Cluster = "global"
+		}
+		if results[i].Rule == "" {
+			results[i].Rule = r.Name()
+		}
+	}
+	return results
+}
+
+// RuleNames returns the rule names of every one of rules, sorted
+func RuleNames(rules []Rule) []string {
+	seen := make(map[string]bool)
+	var names []string
+	for _, r := range rules {
+		for _, name := range NamesOf(r) {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
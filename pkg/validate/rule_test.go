@@ -0,0 +1,83 @@
+package validate_test
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/erauner/homelab-shadow/pkg/validate"
+	"github.com/erauner/homelab-shadow/pkg/validate/validatetest"
+)
+
+// TestRegistered_DeclareTheirRules keeps each built-in rule's RuleNames in
+// step with the rules its findings use, so --only and --skip can select them
+func TestRegistered_DeclareTheirRules(t *testing.T) {
+	repo := validatetest.Build(t, validatetest.Repo{
+		Clusters: []string{"erauner-home"},
+		Kustomizations: map[string]validatetest.Kustomization{
+			"apps/coder/overlays/production":               {Resources: []string{"../../base"}},
+			"apps/unused/overlays/erauner-home/dev":        {Resources: []string{"../../../base"}},
+			"infrastructure/traefik/overlays/erauner-home": {Resources: []string{"../../base"}},
+		},
+		Applications: []validatetest.Application{
+			{Name: "coder", Dir: "argocd-apps/applications", Path: "apps/coder/overlays/production", SyncOptions: []string{"CreateNamespace=true"}},
+		},
+		Files: map[string]string{
+			"apps/coder/base/namespace.yaml": "apiVersion: v1\nkind: Namespace\nmetadata:\n  name: coder\n",
+		},
+	})
+
+	v := validate.NewClusterValidator(repo, false)
+	ctx := validate.RepoContext{Validator: v, RepoPath: repo, Clusters: []string{"erauner-home"}, KubernetesVersion: "1.32"}
+	seen := 0
+	for _, rule := range validate.Registered() {
+		for _, r := range validate.RunRule(rule, ctx) {
+			seen++
+			if !slices.Contains(validate.NamesOf(rule), r.Rule) {
+				t.Errorf("rule %q reported %s, which is not in its RuleNames", rule.Name(), r.Rule)
+			}
+		}
+	}
+	if seen == 0 {
+		t.Fatal("fixture produced no findings")
+	}
+}
+
+func TestRuleNames(t *testing.T) {
+	names := validate.RuleNames(validate.Registered())
+	if !slices.IsSorted(names) || len(slices.Compact(slices.Clone(names))) != len(names) {
+		t.Errorf("RuleNames() = %v, want sorted unique names", names)
+	}
+	for _, want := range []string{"namespace-duplicate", validate.RuleOrphanOverlay, "cluster-missing-dir"} {
+		if !slices.Contains(names, want) {
+			t.Errorf("RuleNames() is missing %s", want)
+		}
+	}
+}
+
+// fakeRule reports canned findings
+type fakeRule struct {
+	name     string
+	findings []validate.Result
+}
+
+func (r fakeRule) Name() string                               { return r.name }
+func (r fakeRule) Severity() string                           { return "warn" }
+func (r fakeRule) Run(validate.RepoContext) []validate.Result { return r.findings }
+
+func TestRunRule_Defaults(t *testing.T) {
+	rule := fakeRule{name: "homelab-labels", findings: []validate.Result{
+		{Path: "apps/coder", Message: "missing team label"},
+		{Cluster: "erauner-home", Rule: "homelab-other", Path: "apps/web", Message: "x", Severity: "error"},
+	}}
+	got := validate.RunRule(rule, validate.RepoContext{})
+	validatetest.AssertFindings(t, got,
+		validatetest.Finding{Cluster: "global", Rule: "homelab-labels", Path: "apps/coder", Severity: "warn"},
+		validatetest.Finding{Cluster: "erauner-home", Rule: "homelab-other", Path: "apps/web", Severity: "error"})
+
+	if names := validate.NamesOf(rule); !slices.Equal(names, []string{"homelab-labels"}) {
+		t.Errorf("NamesOf() = %v, want the rule's name", names)
+	}
+	if validate.PerCluster(rule) {
+		t.Error("PerCluster() = true for a rule without a PerCluster method")
+	}
+}
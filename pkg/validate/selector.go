@@ -0,0 +1,76 @@
+package validate
+
+import (
+	"fmt"
+	"path"
+)
+
+// RuleSelector picks rules by name with glob patterns (path.Match syntax,
+// e.g. "namespace-*"); the zero value selects every rule
+type RuleSelector struct {
+	Only []string // select only rules matching one of these (default: all)
+	Skip []string // then drop rules matching one of these
+}
+
+// IsZero reports whether s selects every rule
+func (s RuleSelector) IsZero() bool {
+	return len(s.Only) == 0 && len(s.Skip) == 0
+}
+
+// Check rejects malformed patterns and patterns matching none of rules, which
+// are almost always typos that would silently select nothing
+func (s RuleSelector) Check(rules []string) error {
+	for _, pattern := range append(append([]string(nil), s.Only...), s.Skip...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid rule pattern %q: %w", pattern, err)
+		}
+		if !matchAny([]string{pattern}, rules...) {
+			return fmt.Errorf("rule pattern %q matches no rule", pattern)
+		}
+	}
+	return nil
+}
+
+// Selects reports whether s selects rule
+func (s RuleSelector) Selects(rule string) bool {
+	if len(s.Only) > 0 && !matchAny(s.Only, rule) {
+		return false
+	}
+	return !matchAny(s.Skip, rule)
+}
+
+// SelectsAny reports whether s selects at least one of rules
+func (s RuleSelector) SelectsAny(rules []string) bool {
+	for _, r := range rules {
+		if s.Selects(r) {
+			return true
+		}
+	}
+	return false
+}
+
+// Filter returns the results whose rule s selects
+func (s RuleSelector) Filter(results []Result) []Result {
+	if s.IsZero() {
+		return results
+	}
+	kept := []Result{}
+	for _, r := range results {
+		if s.Selects(r.Rule) {
+			kept = append(kept, r)
+		}
+	}
+	return kept
+}
+
+// matchAny reports whether any of patterns matches any of names
+func matchAny(patterns []string, names ...string) bool {
+	for _, p := range patterns {
+		for _, n := range names {
+			if ok, _ := path.Match(p, n); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,52 @@
+package validate
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRuleSelector(t *testing.T) {
+	s := RuleSelector{Only: []string{"namespace-*", "argocd-app-*"}, Skip: []string{"namespace-legacy-location"}}
+
+	tests := map[string]bool{
+		"namespace-duplicate":       true,
+		"namespace-legacy-location": false,
+		"argocd-app-legacy-path":    true,
+		"argocd-appset-expand-fail": false,
+		"cluster-missing-dir":       false,
+	}
+	for rule, want := range tests {
+		if got := s.Selects(rule); got != want {
+			t.Errorf("Selects(%s) = %v, want %v", rule, got, want)
+		}
+	}
+
+	if !(RuleSelector{}).Selects("anything") {
+		t.Error("the zero RuleSelector should select every rule")
+	}
+	if !(RuleSelector{Skip: []string{"kustomize-*"}}).Selects("cluster-missing-dir") {
+		t.Error("Skip alone should keep rules it doesn't match")
+	}
+	if s.SelectsAny([]string{"cluster-missing-dir", "kustomize-build-fail"}) {
+		t.Error("SelectsAny() of unselected rules = true")
+	}
+
+	results := []Result{{Rule: "namespace-duplicate"}, {Rule: "cluster-missing-dir"}}
+	if got := s.Filter(results); len(got) != 1 || got[0].Rule != "namespace-duplicate" {
+		t.Errorf("Filter() = %v", got)
+	}
+}
+
+func TestRuleSelector_Check(t *testing.T) {
+	rules := []string{"namespace-duplicate", "cluster-missing-dir"}
+
+	if err := (RuleSelector{Only: []string{"namespace-*"}, Skip: []string{"cluster-?issing-dir"}}).Check(rules); err != nil {
+		t.Errorf("Check() error = %v", err)
+	}
+	if err := (RuleSelector{Only: []string{"nmespace-*"}}).Check(rules); err == nil || !strings.Contains(err.Error(), "matches no rule") {
+		t.Errorf("Check() of a typo = %v", err)
+	}
+	if err := (RuleSelector{Skip: []string{"namespace-["}}).Check(rules); err == nil || !strings.Contains(err.Error(), "invalid rule pattern") {
+		t.Errorf("Check() of a malformed pattern = %v", err)
+	}
+}
@@ -0,0 +1,31 @@
+package validate
+
+import "github.com/erauner/homelab-shadow/pkg/config"
+
+// ApplySeverities applies per-rule severity overrides from .shadow.yaml
+// Findings for rules configured as "off" are dropped; findings for rules set to
+// "ignore" or matching a rule's ignore paths are kept but marked suppressed
+func ApplySeverities(results []Result, cfg *config.Config) []Result {
+	if cfg == nil || len(cfg.Rules) == 0 {
+		return results
+	}
+
+	filtered := []Result{} // Initialize to empty slice for consistent JSON output
+	for _, r := range results {
+		switch severity := cfg.SeverityFor(r.Rule, r.Severity); severity {
+		case config.SeverityOff:
+			continue
+		case config.SeverityIgnore:
+			r.Suppressed = true
+			r.SuppressedBy = "config: rules." + r.Rule
+		default:
+			r.Severity = severity
+		}
+		if pattern := cfg.IgnoredBy(r.Rule, r.Path); pattern != "" && !r.Suppressed {
+			r.Suppressed = true
+			r.SuppressedBy = "config: rules." + r.Rule + ".ignore " + pattern
+		}
+		filtered = append(filtered, r)
+	}
+	return filtered
+}
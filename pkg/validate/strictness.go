@@ -0,0 +1,109 @@
+package validate
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/erauner/homelab-shadow/pkg/command"
+	"github.com/erauner/homelab-shadow/pkg/config"
+)
+
+// ApplyStrictness re-rates findings on paths added since strictness.since
+// using strictness.rules, so new apps must meet rules that legacy apps only
+// warn on. A path is legacy if it existed in the commit named by since (or
+// the last commit before that date); everything else, including uncommitted
+// paths, is new. Run it after ApplySeverities; suppressed findings are left
+// alone. On git errors the results are returned unchanged with the error
+func ApplyStrictness(results []Result, cfg *config.Config, repoPath string) ([]Result, error) {
+	if cfg == nil || !cfg.Strictness.Enabled() {
+		return results, nil
+	}
+
+	legacy, err := LegacyPaths(repoPath, cfg.Strictness.Since)
+	if err != nil {
+		return results, err
+	}
+
+	filtered := []Result{}
+	for _, r := range results {
+		if r.Suppressed || r.Path == "" || legacy[strictnessPath(repoPath, r.Path)] {
+			filtered = append(filtered, r)
+			continue
+		}
+		switch severity := cfg.Strictness.SeverityFor(r.Rule, r.Severity); severity {
+		case config.SeverityOff:
+			continue
+		case config.SeverityIgnore:
+			r.Suppressed = true
+			r.SuppressedBy = "config: strictness.rules." + r.Rule
+		default:
+			r.Severity = severity
+		}
+		filtered = append(filtered, r)
+	}
+	return filtered, nil
+}
+
+// LegacyPaths returns every file and directory (repo-relative, slash-separated)
+// that existed at since: a git ref, or a date resolved to the last commit
+// before it. A date before the first commit yields no legacy paths
+func LegacyPaths(repoPath, since string) (map[string]bool, error) {
+	commit, err := strictnessBase(repoPath, since)
+	if err != nil {
+		return nil, err
+	}
+
+	paths := map[string]bool{".": true}
+	if commit == "" {
+		return paths, nil
+	}
+	out, err := command.Command("git", "-C", repoPath, "ls-tree", "-r", "-t", "-z", "--name-only", commit).Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list files at %s: %w", since, err)
+	}
+	for _, p := range strings.Split(string(out), "\x00") {
+		if p != "" {
+			paths[p] = true
+		}
+	}
+	return paths, nil
+}
+
+// strictnessBase resolves since to a commit, or "" for a date with no earlier commits
+func strictnessBase(repoPath, since string) (string, error) {
+	if out, err := command.Command("git", "-C", repoPath, "rev-parse", "--verify", "--quiet", since+"^{commit}").Output(); err == nil {
+		return strings.TrimSpace(string(out)), nil
+	}
+	if !isDate(since) {
+		return "", fmt.Errorf("strictness.since %q is neither a git ref nor a date", since)
+	}
+	out, err := command.Command("git", "-C", repoPath, "rev-list", "-1", "--before="+since, "HEAD").Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to find the last commit before %s: %w", since, err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// isDate reports whether s is a YYYY-MM-DD or RFC 3339 date
+func isDate(s string) bool {
+	for _, layout := range []string{"2006-01-02", time.RFC3339} {
+		if _, err := time.Parse(layout, s); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// strictnessPath normalizes a finding path to the form git ls-tree prints
+func strictnessPath(repoPath, p string) string {
+	if filepath.IsAbs(p) {
+		if root, err := filepath.Abs(repoPath); err == nil {
+			if rel, err := filepath.Rel(root, p); err == nil {
+				p = rel
+			}
+		}
+	}
+	return filepath.ToSlash(filepath.Clean(p))
+}
@@ -0,0 +1,90 @@
+package validate
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/erauner/homelab-shadow/pkg/config"
+)
+
+// strictnessRepo commits apps/legacy, tags it, then adds apps/fresh uncommitted
+func strictnessRepo(t *testing.T) string {
+	t.Helper()
+	for _, key := range []string{"GIT_AUTHOR_NAME", "GIT_COMMITTER_NAME"} {
+		t.Setenv(key, "test")
+	}
+	for _, key := range []string{"GIT_AUTHOR_EMAIL", "GIT_COMMITTER_EMAIL"} {
+		t.Setenv(key, "test@example.com")
+	}
+
+	repo := t.TempDir()
+	git := func(args ...string) {
+		t.Helper()
+		if out, err := exec.Command("git", append([]string{"-C", repo}, args...)...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	write := func(rel string) {
+		t.Helper()
+		if err := os.MkdirAll(filepath.Join(repo, filepath.Dir(rel)), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(repo, rel), []byte("resources: []\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	git("init", "-q")
+	write("apps/legacy/overlays/production/kustomization.yaml")
+	git("add", "-A")
+	git("commit", "-q", "-m", "legacy")
+	git("tag", "strict-base")
+	write("apps/fresh/overlays/production/kustomization.yaml")
+	return repo
+}
+
+func TestApplyStrictness(t *testing.T) {
+	repo := strictnessRepo(t)
+	cfg := &config.Config{Strictness: config.Strictness{
+		Since: "strict-base",
+		Rules: map[string]string{"*": config.SeverityError, RuleImageTagFloating: config.SeverityOff},
+	}}
+	results := []Result{
+		{Rule: RuleImageTagLatest, Path: "apps/legacy/overlays/production", Severity: "warn"},
+		{Rule: RuleImageTagLatest, Path: "apps/fresh/overlays/production", Severity: "warn"},
+		{Rule: RuleImageTagLatest, Path: filepath.Join(repo, "apps/fresh/overlays/production/kustomization.yaml"), Severity: "warn"},
+		{Rule: RuleImageTagFloating, Path: "apps/fresh/overlays/production", Severity: "warn"},
+		{Rule: RuleImageTagLatest, Path: "apps/fresh", Severity: "warn", Suppressed: true},
+	}
+
+	got, err := ApplyStrictness(results, cfg, repo)
+	if err != nil {
+		t.Fatalf("ApplyStrictness() error = %v", err)
+	}
+	if len(got) != 4 {
+		t.Fatalf("expected the off rule to be dropped for new paths, got %+v", got)
+	}
+	want := []string{"warn", "error", "error", "warn"}
+	for i, r := range got {
+		if r.Severity != want[i] {
+			t.Errorf("%s %s: severity = %q, want %q", r.Rule, r.Path, r.Severity, want[i])
+		}
+	}
+
+	// A date before the first commit makes every path new
+	cfg.Strictness.Since = "2000-01-01"
+	got, err = ApplyStrictness(results[:1], cfg, repo)
+	if err != nil {
+		t.Fatalf("ApplyStrictness() error = %v", err)
+	}
+	if got[0].Severity != "error" {
+		t.Errorf("expected legacy path to be new before the first commit, got %q", got[0].Severity)
+	}
+
+	cfg.Strictness.Since = "no-such-ref"
+	if _, err := ApplyStrictness(results, cfg, repo); err == nil {
+		t.Error("expected an error for an unknown ref")
+	}
+}
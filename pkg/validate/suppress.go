@@ -0,0 +1,102 @@
+package validate
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// ignoreComment matches inline suppressions such as
+//
+//	# shadow:ignore app-overlay-legacy-flat
+//	# shadow:ignore image-tag-latest, image-tag-floating -- pinned upstream
+var ignoreComment = regexp.MustCompile(`#\s*shadow:ignore\s+([^\n]*)`)
+
+// ApplyInlineSuppressions marks findings suppressed by `# shadow:ignore <rule>`
+// comments in the file a finding points at: the file itself for *.yaml paths,
+// otherwise <path>/kustomization.yaml
+func ApplyInlineSuppressions(results []Result, repoPath string) {
+	cache := make(map[string]map[string]bool)
+	for i := range results {
+		r := &results[i]
+		if r.Suppressed || r.Path == "" {
+			continue
+		}
+
+		file := suppressionFile(r.Path)
+		rules, ok := cache[file]
+		if !ok {
+			rules = readInlineSuppressions(filepath.Join(repoPath, file))
+			cache[file] = rules
+		}
+		if rules[r.Rule] {
+			r.Suppressed = true
+			r.SuppressedBy = "inline: " + file
+		}
+	}
+}
+
+// suppressionFile returns the file whose comments can suppress a finding at path
+func suppressionFile(path string) string {
+	path = strings.TrimSuffix(filepath.ToSlash(path), "/")
+	if strings.HasSuffix(path, ".yaml") || strings.HasSuffix(path, ".yml") {
+		return path
+	}
+	return path + "/kustomization.yaml"
+}
+
+// readInlineSuppressions returns the rules named in a file's shadow:ignore comments
+func readInlineSuppressions(path string) map[string]bool {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+
+	rules := make(map[string]bool)
+	for _, match := range ignoreComment.FindAllStringSubmatch(string(data), -1) {
+		list, _, _ := strings.Cut(match[1], "--") // anything after -- is a reason
+		for _, rule := range strings.FieldsFunc(list, func(r rune) bool { return r == ',' || r == ' ' || r == '\t' }) {
+			rules[rule] = true
+		}
+	}
+	return rules
+}
+
+// Unsuppressed returns the findings that are not suppressed
+func Unsuppressed(results []Result) []Result {
+	filtered := []Result{} // Initialize to empty slice for consistent JSON output
+	for _, r := range results {
+		if !r.Suppressed {
+			filtered = append(filtered, r)
+		}
+	}
+	return filtered
+}
+
+// CountSuppressed returns the number of suppressed findings
+func CountSuppressed(results []Result) int {
+	count := 0
+	for _, r := range results {
+		if r.Suppressed {
+			count++
+		}
+	}
+	return count
+}
+
+// Summary counts findings by outcome
+type Summary struct {
+	Errors     int `json:"errors"`
+	Warnings   int `json:"warnings"`
+	Suppressed int `json:"suppressed"`
+}
+
+// Summarize counts unsuppressed errors and warnings, and suppressed findings
+func Summarize(results []Result) Summary {
+	return Summary{
+		Errors:     CountErrors(results),
+		Warnings:   CountWarnings(results),
+		Suppressed: CountSuppressed(results),
+	}
+}
@@ -0,0 +1,92 @@
+package validate
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/erauner/homelab-shadow/pkg/config"
+)
+
+func TestApplySeverities_Suppression(t *testing.T) {
+	cfg := &config.Config{Rules: map[string]config.RuleConfig{
+		RuleImageTagLatest:   {Severity: config.SeverityIgnore},
+		RuleImageTagFloating: {Ignore: []string{"apps/legacy/**"}},
+	}}
+	results := []Result{
+		{Rule: RuleImageTagLatest, Path: "apps/web", Severity: "warn"},
+		{Rule: RuleImageTagFloating, Path: "apps/legacy/overlays/production", Severity: "warn"},
+		{Rule: RuleImageTagFloating, Path: "apps/web", Severity: "warn"},
+	}
+
+	got := ApplySeverities(results, cfg)
+	if len(got) != 3 {
+		t.Fatalf("expected suppressed findings to be kept, got %+v", got)
+	}
+	if !got[0].Suppressed || got[0].SuppressedBy != "config: rules."+RuleImageTagLatest {
+		t.Errorf("expected %s suppressed by config, got %+v", RuleImageTagLatest, got[0])
+	}
+	if !got[1].Suppressed || got[1].SuppressedBy != "config: rules."+RuleImageTagFloating+".ignore apps/legacy/**" {
+		t.Errorf("expected legacy path suppressed by ignore pattern, got %+v", got[1])
+	}
+	if got[2].Suppressed {
+		t.Errorf("expected apps/web finding to stay active, got %+v", got[2])
+	}
+}
+
+func TestApplyInlineSuppressions(t *testing.T) {
+	repo := t.TempDir()
+	dir := filepath.Join(repo, "apps", "legacy", "overlays", "production")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	kustomization := `# shadow:ignore app-overlay-legacy-flat -- migrating in #1300
+# shadow:ignore image-tag-latest, image-tag-floating
+apiVersion: kustomize.config.k8s.io/v1beta1
+kind: Kustomization
+`
+	if err := os.WriteFile(filepath.Join(dir, "kustomization.yaml"), []byte(kustomization), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	results := []Result{
+		{Rule: "app-overlay-legacy-flat", Path: "apps/legacy/overlays/production", Severity: "warn"},
+		{Rule: RuleImageTagFloating, Path: "apps/legacy/overlays/production", Severity: "error"},
+		{Rule: RuleImageTagMissing, Path: "apps/legacy/overlays/production", Severity: "error"},
+		{Rule: "app-overlay-legacy-flat", Path: "apps/web/overlays/production", Severity: "warn"},
+	}
+	ApplyInlineSuppressions(results, repo)
+
+	wantSuppressed := []bool{true, true, false, false}
+	for i, want := range wantSuppressed {
+		if results[i].Suppressed != want {
+			t.Errorf("results[%d] (%s %s) suppressed = %v, want %v", i, results[i].Rule, results[i].Path, results[i].Suppressed, want)
+		}
+	}
+	if want := "inline: apps/legacy/overlays/production/kustomization.yaml"; results[0].SuppressedBy != want {
+		t.Errorf("SuppressedBy = %q, want %q", results[0].SuppressedBy, want)
+	}
+
+	if CountErrors(results) != 1 || CountWarnings(results) != 1 {
+		t.Errorf("expected suppressed findings not to be counted, got %d error(s), %d warning(s)", CountErrors(results), CountWarnings(results))
+	}
+	if CountSuppressed(results) != 2 || len(Unsuppressed(results)) != 2 {
+		t.Errorf("expected 2 suppressed and 2 active findings")
+	}
+	if got := Summarize(results); got != (Summary{Errors: 1, Warnings: 1, Suppressed: 2}) {
+		t.Errorf("Summarize() = %+v", got)
+	}
+}
+
+func TestSuppressionFile(t *testing.T) {
+	tests := map[string]string{
+		"apps/web/overlays/production":  "apps/web/overlays/production/kustomization.yaml",
+		"apps/web/overlays/production/": "apps/web/overlays/production/kustomization.yaml",
+		"clusters/home/apps.yaml":       "clusters/home/apps.yaml",
+	}
+	for path, want := range tests {
+		if got := suppressionFile(path); got != want {
+			t.Errorf("suppressionFile(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
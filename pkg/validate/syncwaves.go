@@ -0,0 +1,424 @@
+package validate
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/erauner/homelab-shadow/pkg/argocd"
+	"github.com/erauner/homelab-shadow/pkg/flux"
+)
+
+// Sync-wave rules
+const (
+	// RuleSyncWaveInvalid flags a sync-wave annotation that isn't an integer
+	RuleSyncWaveInvalid = "sync-wave-invalid"
+	// RuleSyncWaveCRDOrder flags custom resources synced in an earlier wave
+	// than the CustomResourceDefinition defining them
+	RuleSyncWaveCRDOrder = "sync-wave-crd-order"
+	// RuleSyncWaveNamespaceOrder flags resources synced in an earlier wave
+	// than the Namespace they are created in
+	RuleSyncWaveNamespaceOrder = "sync-wave-namespace-order"
+	// RuleSyncWaveCycle flags Applications that need each other's CRDs or
+	// Namespaces, which no wave order can deploy
+	RuleSyncWaveCycle = "sync-wave-cycle"
+)
+
+// SyncWaveRules lists every sync-wave rule
+var SyncWaveRules = []string{RuleSyncWaveInvalid, RuleSyncWaveCRDOrder, RuleSyncWaveNamespaceOrder, RuleSyncWaveCycle}
+
+// waveResource is a rendered resource with its sync wave
+type waveResource struct {
+	path      string // source path it was rendered from
+	kind      string // group/Kind
+	namespace string
+	name      string
+	wave      int
+
+	// defines is the group/Kind a CustomResourceDefinition defines
+	defines string
+}
+
+func (r waveResource) String() string {
+	kind := r.kind[strings.Index(r.kind, "/")+1:]
+	return fmt.Sprintf("%s %s (wave %d)", kind, qualifiedName(r.namespace, r.name), r.wave)
+}
+
+// waveApp is an Application with its wave and rendered resources
+type waveApp struct {
+	app       *argocd.Application
+	file      string
+	cluster   string
+	wave      int
+	resources []waveResource
+}
+
+// waveDependency is a resource of one Application that needs a CRD or
+// Namespace another Application deploys
+type waveDependency struct {
+	from, to *waveApp
+	reason   string
+}
+
+// ValidateSyncWaves checks argocd.argoproj.io/sync-wave annotations: every
+// Application's must be an integer, and with render set (--build-app-paths)
+// so must every rendered resource's, and resources must not sync before the
+// CRDs defining them or the Namespaces holding them
+//
+// Ordering is checked within each Application's rendered sources and across
+// Applications deploying to the same cluster, which an app-of-apps orders by
+// their own sync-wave. Applications needing each other's CRDs or Namespaces
+// are reported as a cycle. Sources that fail to build are skipped
+func (v *ClusterValidator) ValidateSyncWaves(render bool) []Result {
+	results := []Result{}
+
+	registry, err := v.clusterRegistry()
+	if err != nil {
+		return results // reported by ValidateClusterNames
+	}
+	apps, files, err := flux.LoadAllApplications(v.RepoPath)
+	if err != nil {
+		return results // reported by ValidateArgoCDSourcePaths
+	}
+
+	var waveApps []*waveApp
+	for i, app := range apps {
+		relFile, _ := filepath.Rel(v.RepoPath, files[i])
+		wa := &waveApp{app: app, file: filepath.ToSlash(relFile)}
+		if dest := app.Destination(registry); dest.Cluster != "" {
+			wa.cluster = dest.Cluster
+		} else {
+			wa.cluster = app.Cluster
+		}
+		wave, err := parseSyncWave(app.SyncWave)
+		if err != nil {
+			results = append(results, Result{
+				Cluster:  "global",
+				Rule:     RuleSyncWaveInvalid,
+				Path:     wa.file,
+				Message:  fmt.Sprintf("%s has %s", app.KindName(), err),
+				Severity: "error",
+			})
+		}
+		wa.wave = wave
+		waveApps = append(waveApps, wa)
+	}
+	if !render {
+		return results
+	}
+
+	manifests := make(map[string]string)
+	if err := v.renderSourcePaths(func(sourcePath, manifest string) { manifests[sourcePath] = manifest }); err != nil {
+		return results
+	}
+
+	// Findings on a source path are reported once, however many Applications deploy it
+	seen := make(map[string]bool)
+	report := func(r Result) {
+		key := r.Rule + "\x00" + r.Path + "\x00" + r.Message
+		if !seen[key] {
+			seen[key] = true
+			results = append(results, r)
+		}
+	}
+
+	for _, wa := range waveApps {
+		for _, source := range wa.app.AllSources() {
+			if source.Path == "" {
+				continue
+			}
+			sourcePath := cleanRelPath(source.Path)
+			manifest, ok := manifests[sourcePath]
+			if !ok {
+				continue
+			}
+			docs, err := decodeDocuments(manifest)
+			if err != nil {
+				v.Log.Debugf("skipping sync-wave check of %s: %v", sourcePath, err)
+				continue
+			}
+			for _, doc := range docs {
+				r, err := newWaveResource(sourcePath, doc, wa.app.Namespace)
+				if err != nil {
+					report(Result{
+						Cluster:  "global",
+						Rule:     RuleSyncWaveInvalid,
+						Path:     sourcePath,
+						Message:  fmt.Sprintf("%s has %s", describeObject(doc), err),
+						Severity: "error",
+					})
+				}
+				wa.resources = append(wa.resources, r)
+			}
+		}
+		for _, r := range checkWaveOrder(wa.resources) {
+			report(r)
+		}
+	}
+
+	for _, r := range checkAppWaveOrder(waveApps) {
+		report(r)
+	}
+	return results
+}
+
+// parseSyncWave parses a sync-wave annotation; "" is wave 0
+func parseSyncWave(value string) (int, error) {
+	if strings.TrimSpace(value) == "" {
+		return 0, nil
+	}
+	wave, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q (must be an integer)", argocd.AnnotationSyncWave, value)
+	}
+	return wave, nil
+}
+
+// newWaveResource reads a rendered document; namespaced resources without a
+// namespace are deployed to defaultNamespace, the Application's destination.
+// An invalid wave is reported and treated as wave 0
+func newWaveResource(path string, doc map[string]interface{}, defaultNamespace string) (waveResource, error) {
+	apiVersion, _ := doc["apiVersion"].(string)
+	kind, _ := doc["kind"].(string)
+	metadata, _ := doc["metadata"].(map[string]interface{})
+	annotations, _ := metadata["annotations"].(map[string]interface{})
+	var wave string
+	if value, ok := annotations[argocd.AnnotationSyncWave]; ok && value != nil {
+		wave = fmt.Sprint(value)
+	}
+
+	r := waveResource{path: path, kind: apiGroup(apiVersion) + "/" + kind}
+	r.name, _ = metadata["name"].(string)
+	r.namespace, _ = metadata["namespace"].(string)
+	if r.namespace == "" && !clusterScopedKinds[kind] {
+		r.namespace = defaultNamespace
+	}
+	if kind == "CustomResourceDefinition" {
+		spec, _ := doc["spec"].(map[string]interface{})
+		group, _ := spec["group"].(string)
+		names, _ := spec["names"].(map[string]interface{})
+		defines, _ := names["kind"].(string)
+		if defines != "" {
+			r.defines = group + "/" + defines
+		}
+	}
+
+	var err error
+	r.wave, err = parseSyncWave(wave)
+	return r, err
+}
+
+// apiGroup returns the group of an apiVersion ("" for the core group)
+func apiGroup(apiVersion string) string {
+	if i := strings.LastIndex(apiVersion, "/"); i >= 0 {
+		return apiVersion[:i]
+	}
+	return ""
+}
+
+// clusterScopedKinds are built-in kinds that never have a namespace
+var clusterScopedKinds = map[string]bool{
+	"Namespace": true, "CustomResourceDefinition": true, "ClusterRole": true, "ClusterRoleBinding": true,
+	"PersistentVolume": true, "StorageClass": true, "PriorityClass": true, "IngressClass": true,
+	"ValidatingWebhookConfiguration": true, "MutatingWebhookConfiguration": true,
+	"APIService": true, "RuntimeClass": true, "CSIDriver": true, "VolumeSnapshotClass": true,
+}
+
+// checkWaveOrder flags resources synced before the CRD defining them or the
+// Namespace holding them, within one Application's resources
+func checkWaveOrder(resources []waveResource) []Result {
+	crds := make(map[string]waveResource)
+	namespaces := make(map[string]waveResource)
+	for _, r := range resources {
+		if r.defines != "" {
+			crds[r.defines] = r
+		}
+		if r.kind == "/Namespace" {
+			namespaces[r.name] = r
+		}
+	}
+
+	var results []Result
+	for _, r := range resources {
+		if crd, ok := crds[r.kind]; ok && crd.wave > r.wave {
+			results = append(results, Result{
+				Cluster:  "global",
+				Rule:     RuleSyncWaveCRDOrder,
+				Path:     r.path,
+				Message:  fmt.Sprintf("%s syncs before %s, which defines it", r, crd),
+				Severity: "error",
+			})
+		}
+		if ns, ok := namespaces[r.namespace]; ok && ns.wave > r.wave {
+			results = append(results, Result{
+				Cluster:  "global",
+				Rule:     RuleSyncWaveNamespaceOrder,
+				Path:     r.path,
+				Message:  fmt.Sprintf("%s syncs before %s", r, ns),
+				Severity: "error",
+			})
+		}
+	}
+	return results
+}
+
+// checkAppWaveOrder flags Applications that sync before the Applications
+// deploying the CRDs and Namespaces they need, among the Applications of each
+// cluster, and cycles of such dependencies
+func checkAppWaveOrder(apps []*waveApp) []Result {
+	byCluster := make(map[string][]*waveApp)
+	var clusters []string
+	for _, wa := range apps {
+		if byCluster[wa.cluster] == nil {
+			clusters = append(clusters, wa.cluster)
+		}
+		byCluster[wa.cluster] = append(byCluster[wa.cluster], wa)
+	}
+	sort.Strings(clusters)
+
+	var results []Result
+	for _, c := range clusters {
+		deps := appDependencies(byCluster[c])
+		for _, dep := range deps {
+			if dep.to.wave <= dep.from.wave {
+				continue
+			}
+			rule := RuleSyncWaveCRDOrder
+			if strings.HasPrefix(dep.reason, "Namespace") {
+				rule = RuleSyncWaveNamespaceOrder
+			}
+			results = append(results, Result{
+				Cluster: clusterOrGlobal(c),
+				Rule:    rule,
+				Path:    dep.from.file,
+				Message: fmt.Sprintf("%s (wave %d) needs %s from %s, which syncs later (wave %d)",
+					dep.from.app.KindName(), dep.from.wave, dep.reason, dep.to.app.KindName(), dep.to.wave),
+				Severity: "error",
+			})
+		}
+		for _, cycle := range dependencyCycles(deps) {
+			var names []string
+			for _, wa := range cycle {
+				names = append(names, wa.app.Name)
+			}
+			results = append(results, Result{
+				Cluster:  clusterOrGlobal(c),
+				Rule:     RuleSyncWaveCycle,
+				Path:     cycle[0].file,
+				Message:  fmt.Sprintf("Applications %s need each other's CRDs or Namespaces; no sync-wave order can deploy them", strings.Join(names, ", ")),
+				Severity: "error",
+			})
+		}
+	}
+	return results
+}
+
+// clusterOrGlobal returns c, or "global" for Applications without a cluster
+func clusterOrGlobal(c string) string {
+	if c == "" {
+		return "global"
+	}
+	return c
+}
+
+// appDependencies lists, once per pair and kind, the Applications whose resources need
+// a CRD or Namespace another of apps deploys
+func appDependencies(apps []*waveApp) []waveDependency {
+	crdOwner := make(map[string]*waveApp)
+	crdName := make(map[string]string)
+	nsOwner := make(map[string]*waveApp)
+	for _, wa := range apps {
+		for _, r := range wa.resources {
+			if r.defines != "" && crdOwner[r.defines] == nil {
+				crdOwner[r.defines], crdName[r.defines] = wa, r.name
+			}
+			if r.kind == "/Namespace" && nsOwner[r.name] == nil {
+				nsOwner[r.name] = wa
+			}
+		}
+	}
+
+	type pair struct {
+		from, to  *waveApp
+		namespace bool
+	}
+	var deps []waveDependency
+	seen := make(map[pair]bool)
+	for _, wa := range apps {
+		for _, r := range wa.resources {
+			if owner := crdOwner[r.kind]; owner != nil && owner != wa && !seen[pair{wa, owner, false}] {
+				seen[pair{wa, owner, false}] = true
+				deps = append(deps, waveDependency{from: wa, to: owner, reason: "CustomResourceDefinition " + crdName[r.kind]})
+			}
+			if owner := nsOwner[r.namespace]; owner != nil && owner != wa && !seen[pair{wa, owner, true}] {
+				seen[pair{wa, owner, true}] = true
+				deps = append(deps, waveDependency{from: wa, to: owner, reason: "Namespace " + r.namespace})
+			}
+		}
+	}
+	return deps
+}
+
+// dependencyCycles returns the groups of Applications that depend on each
+// other (strongly connected components of more than one Application), each
+// sorted by name
+func dependencyCycles(deps []waveDependency) [][]*waveApp {
+	edges := make(map[*waveApp][]*waveApp)
+	var nodes []*waveApp
+	for _, dep := range deps {
+		for _, wa := range []*waveApp{dep.from, dep.to} {
+			if _, ok := edges[wa]; !ok {
+				edges[wa] = nil
+				nodes = append(nodes, wa)
+			}
+		}
+		edges[dep.from] = append(edges[dep.from], dep.to)
+	}
+
+	// Tarjan's algorithm
+	index := make(map[*waveApp]int)
+	low := make(map[*waveApp]int)
+	onStack := make(map[*waveApp]bool)
+	var stack []*waveApp
+	var cycles [][]*waveApp
+	var visit func(wa *waveApp)
+	visit = func(wa *waveApp) {
+		index[wa] = len(index)
+		low[wa] = index[wa]
+		stack = append(stack, wa)
+		onStack[wa] = true
+		for _, next := range edges[wa] {
+			if _, ok := index[next]; !ok {
+				visit(next)
+				low[wa] = min(low[wa], low[next])
+			} else if onStack[next] {
+				low[wa] = min(low[wa], index[next])
+			}
+		}
+		if low[wa] != index[wa] {
+			return
+		}
+		var component []*waveApp
+		for {
+			top := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			onStack[top] = false
+			component = append(component, top)
+			if top == wa {
+				break
+			}
+		}
+		if len(component) > 1 {
+			sort.Slice(component, func(i, j int) bool { return component[i].app.Name < component[j].app.Name })
+			cycles = append(cycles, component)
+		}
+	}
+	for _, wa := range nodes {
+		if _, ok := index[wa]; !ok {
+			visit(wa)
+		}
+	}
+	return cycles
+}
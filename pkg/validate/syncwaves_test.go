@@ -0,0 +1,161 @@
+package validate_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/erauner/homelab-shadow/pkg/validate"
+	"github.com/erauner/homelab-shadow/pkg/validate/validatetest"
+)
+
+func TestValidateSyncWaves(t *testing.T) {
+	manifests := map[string]string{
+		// CRDs and a shared Namespace, synced late
+		"crds": `apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: widgets.example.com
+spec:
+  group: example.com
+  names:
+    kind: Widget
+---
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: tools
+`,
+		// Needs the crds app's Widget CRD and tools Namespace
+		"widgets": `apiVersion: example.com/v1
+kind: Widget
+metadata:
+  name: w
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: settings
+  namespace: tools
+`,
+		// Orders its own CRD and Namespace after what uses them
+		"inner": `apiVersion: v1
+kind: Namespace
+metadata:
+  name: inner
+  annotations:
+    argocd.argoproj.io/sync-wave: "2"
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: settings
+  namespace: inner
+  annotations:
+    argocd.argoproj.io/sync-wave: "-1"
+---
+apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: gadgets.example.com
+  annotations:
+    argocd.argoproj.io/sync-wave: "1"
+spec:
+  group: example.com
+  names:
+    kind: Gadget
+---
+apiVersion: example.com/v1
+kind: Gadget
+metadata:
+  name: g
+  namespace: gadgets
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: odd
+  namespace: gadgets
+  annotations:
+    argocd.argoproj.io/sync-wave: "late"
+`,
+		// alpha needs beta's Namespace, beta needs alpha's CRD
+		"alpha": `apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: foos.example.com
+spec:
+  group: example.com
+  names:
+    kind: Foo
+---
+apiVersion: v1
+kind: ServiceAccount
+metadata:
+  name: alpha
+  namespace: beta
+`,
+		"beta": `apiVersion: v1
+kind: Namespace
+metadata:
+  name: beta
+---
+apiVersion: example.com/v1
+kind: Foo
+metadata:
+  name: f
+  namespace: beta
+`,
+	}
+
+	repo := validatetest.Repo{
+		Kustomizations: map[string]validatetest.Kustomization{},
+		Files:          map[string]string{},
+		Applications: []validatetest.Application{
+			{Name: "crds", Path: "apps/crds/overlays/erauner-home/production", SyncWave: "5"},
+			{Name: "widgets", Path: "apps/widgets/overlays/erauner-home/production", Namespace: "widgets", SyncWave: "0"},
+			{Name: "inner", Path: "apps/inner/overlays/erauner-home/production", Namespace: "inner", SyncWave: "first"},
+			{Name: "alpha", Path: "apps/alpha/overlays/erauner-home/production", Namespace: "alpha"},
+			{Name: "beta", Path: "apps/beta/overlays/erauner-home/production", Namespace: "beta"},
+		},
+	}
+	for name, manifest := range manifests {
+		dir := "apps/" + name + "/overlays/erauner-home/production"
+		repo.Kustomizations[dir] = validatetest.Kustomization{}
+		repo.Files[dir+"/manifest.yaml"] = manifest
+	}
+	root := validatetest.Build(t, repo)
+
+	// Stub kustomize to print each overlay's manifest.yaml
+	bin := t.TempDir()
+	if err := os.WriteFile(filepath.Join(bin, "kustomize"), []byte("#!/bin/sh\ncat \"$2/manifest.yaml\"\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	v := validate.NewClusterValidator(root, false)
+	validatetest.AssertFindings(t, v.ValidateSyncWaves(false),
+		validatetest.Finding{Rule: validate.RuleSyncWaveInvalid, Path: "argocd-apps/applications/inner.yaml", Severity: "error"},
+	)
+
+	results := v.ValidateSyncWaves(true)
+	validatetest.AssertFindings(t, results,
+		validatetest.Finding{Rule: validate.RuleSyncWaveInvalid, Path: "argocd-apps/applications/inner.yaml"},
+		validatetest.Finding{Rule: validate.RuleSyncWaveInvalid, Path: "apps/inner/overlays/erauner-home/production"},
+		validatetest.Finding{Rule: validate.RuleSyncWaveNamespaceOrder, Path: "apps/inner/overlays/erauner-home/production"},
+		validatetest.Finding{Rule: validate.RuleSyncWaveCRDOrder, Path: "apps/inner/overlays/erauner-home/production"},
+		validatetest.Finding{Rule: validate.RuleSyncWaveCRDOrder, Path: "argocd-apps/applications/widgets.yaml"},
+		validatetest.Finding{Rule: validate.RuleSyncWaveNamespaceOrder, Path: "argocd-apps/applications/widgets.yaml"},
+		validatetest.Finding{Rule: validate.RuleSyncWaveCycle, Path: "argocd-apps/applications/alpha.yaml", Severity: "error"},
+	)
+	for _, r := range results {
+		if r.Rule == validate.RuleSyncWaveCycle && !strings.Contains(r.Message, "alpha, beta") {
+			t.Errorf("cycle message %q should name alpha and beta", r.Message)
+		}
+		if r.Rule == validate.RuleSyncWaveNamespaceOrder && r.Path == "apps/inner/overlays/erauner-home/production" &&
+			!strings.Contains(r.Message, "ConfigMap inner/settings (wave -1) syncs before Namespace inner (wave 2)") {
+			t.Errorf("unexpected message %q", r.Message)
+		}
+	}
+}
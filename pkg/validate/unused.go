@@ -0,0 +1,172 @@
+package validate
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/erauner/homelab-shadow/pkg/config"
+	"github.com/erauner/homelab-shadow/pkg/refs"
+	"gopkg.in/yaml.v3"
+)
+
+// RuleUnusedConfig flags a ConfigMap or Secret that nothing rendered for its
+// cluster references: no workload mounts or reads it, no Ingress or Gateway
+// serves it, no ServiceAccount lists it
+const RuleUnusedConfig = "unused-config"
+
+// AnnotationConsumedBy marks a ConfigMap or Secret as used by a consumer the
+// analysis can't see, e.g. shadow.erauner.dev/consumed-by: grafana sidecar
+const AnnotationConsumedBy = "shadow.erauner.dev/consumed-by"
+
+// builtinConsumed are Secret types and ConfigMaps that Kubernetes or Helm
+// consume on their own
+var builtinConsumed = map[string]bool{
+	"Secret type kubernetes.io/service-account-token": true,
+	"Secret type helm.sh/release.v1":                  true,
+	"ConfigMap kube-root-ca.crt":                      true,
+}
+
+// UnusedConfig is a ConfigMap or Secret no other resource in its cluster references
+type UnusedConfig struct {
+	Cluster   string     `json:"cluster"`
+	Resource  ResourceID `json:"resource"`
+	Directory string     `json:"directory"`
+}
+
+// Result converts the unused resource into a validation finding on its directory
+func (u UnusedConfig) Result() Result {
+	clusterName := u.Cluster
+	if clusterName == "" {
+		clusterName = "global"
+	}
+	return Result{
+		Cluster:  clusterName,
+		Rule:     RuleUnusedConfig,
+		Path:     u.Directory,
+		Message:  fmt.Sprintf("%s is not referenced by any resource rendered for the cluster", u.Resource),
+		Severity: "warn",
+	}
+}
+
+// configResource is a ConfigMap or Secret found in a rendered manifest
+type configResource struct {
+	id          ResourceID
+	secretType  string
+	labels      map[string]string
+	annotations map[string]string
+}
+
+// FindUnusedConfig indexes rendered manifests (keyed by directory) per cluster
+// and returns the ConfigMaps and Secrets no resource rendered for the same
+// cluster references (see refs.Rendered for the fields followed), sorted by
+// cluster then resource. Resources annotated with AnnotationConsumedBy, or
+// excluded by opts, are skipped. clusterOf maps a directory to its cluster
+// ("" when unknown; such directories are only checked against each other)
+func FindUnusedConfig(manifests map[string]string, clusterOf func(dir string) string, opts config.Unused) ([]UnusedConfig, error) {
+	byCluster := make(map[string]map[string]string)
+	for dir, manifest := range manifests {
+		clusterName := clusterOf(dir)
+		if byCluster[clusterName] == nil {
+			byCluster[clusterName] = make(map[string]string)
+		}
+		byCluster[clusterName][dir] = manifest
+	}
+
+	var unused []UnusedConfig
+	for clusterName, clusterManifests := range byCluster {
+		dirs := make([]string, 0, len(clusterManifests))
+		for dir := range clusterManifests {
+			dirs = append(dirs, dir)
+		}
+		sort.Strings(dirs)
+
+		for _, dir := range dirs {
+			candidates, err := configResources(clusterManifests[dir])
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", dir, err)
+			}
+			for _, c := range candidates {
+				if consumedOutside(c, opts) {
+					continue
+				}
+				found, err := refs.Rendered(clusterManifests, refs.Target{Kind: c.id.Kind, Namespace: c.id.Namespace, Name: c.id.Name})
+				if err != nil {
+					return nil, err
+				}
+				if !referenced(found) {
+					unused = append(unused, UnusedConfig{Cluster: clusterName, Resource: c.id, Directory: dir})
+				}
+			}
+		}
+	}
+
+	sort.Slice(unused, func(i, j int) bool {
+		if unused[i].Cluster != unused[j].Cluster {
+			return unused[i].Cluster < unused[j].Cluster
+		}
+		if a, b := unused[i].Resource.String(), unused[j].Resource.String(); a != b {
+			return a < b
+		}
+		return unused[i].Directory < unused[j].Directory
+	})
+	return unused, nil
+}
+
+// consumedOutside reports whether a resource is used in a way rendered
+// manifests don't show: built in, annotated, or excluded by config
+func consumedOutside(c configResource, opts config.Unused) bool {
+	if builtinConsumed[c.id.Kind+" type "+c.secretType] || builtinConsumed[c.id.Kind+" "+c.id.Name] {
+		return true
+	}
+	if _, ok := c.annotations[AnnotationConsumedBy]; ok {
+		return true
+	}
+	return opts.IgnoresResource(c.id.Namespace, c.id.Name) || opts.Consumed(c.labels, c.annotations)
+}
+
+// referenced reports whether any reference is a use rather than a definition
+func referenced(found []refs.Reference) bool {
+	for _, ref := range found {
+		if ref.Field != refs.FieldDefinition && ref.Field != refs.FieldGenerates {
+			return true
+		}
+	}
+	return false
+}
+
+// configResources lists the ConfigMaps and Secrets in a multi-document manifest
+func configResources(manifest string) ([]configResource, error) {
+	var found []configResource
+	decoder := yaml.NewDecoder(strings.NewReader(manifest))
+	for {
+		var doc struct {
+			Kind     string `yaml:"kind"`
+			Type     string `yaml:"type"`
+			Metadata struct {
+				Name        string            `yaml:"name"`
+				Namespace   string            `yaml:"namespace"`
+				Labels      map[string]string `yaml:"labels"`
+				Annotations map[string]string `yaml:"annotations"`
+			} `yaml:"metadata"`
+		}
+		if err := decoder.Decode(&doc); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, fmt.Errorf("failed to parse manifest: %w", err)
+		}
+		if (doc.Kind != "ConfigMap" && doc.Kind != "Secret") || doc.Metadata.Name == "" {
+			continue
+		}
+		found = append(found, configResource{
+			id:          ResourceID{Kind: doc.Kind, Namespace: doc.Metadata.Namespace, Name: doc.Metadata.Name},
+			secretType:  doc.Type,
+			labels:      doc.Metadata.Labels,
+			annotations: doc.Metadata.Annotations,
+		})
+	}
+	return found, nil
+}
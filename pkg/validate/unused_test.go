@@ -0,0 +1,130 @@
+package validate_test
+
+import (
+	"testing"
+
+	"github.com/erauner/homelab-shadow/pkg/config"
+	"github.com/erauner/homelab-shadow/pkg/validate"
+)
+
+func TestFindUnusedConfig(t *testing.T) {
+	manifests := map[string]string{
+		"apps/media/overlays/erauner-home/production": `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: jellyfin-config
+  namespace: media
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: leftover
+  namespace: media
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: db-creds
+  namespace: media
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: api-token
+  namespace: media
+  annotations:
+    shadow.erauner.dev/consumed-by: external controller
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: jellyfin-token
+  namespace: media
+type: kubernetes.io/service-account-token
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: dashboards
+  namespace: media
+  labels:
+    grafana_dashboard: "1"
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: jellyfin
+  namespace: media
+spec:
+  template:
+    spec:
+      volumes:
+        - name: config
+          configMap:
+            name: jellyfin-config
+`,
+		// Referenced from another directory of the same cluster
+		"apps/db/overlays/erauner-home/production": `apiVersion: apps/v1
+kind: StatefulSet
+metadata:
+  name: postgres
+  namespace: media
+spec:
+  template:
+    spec:
+      containers:
+        - name: postgres
+          envFrom:
+            - secretRef:
+                name: db-creds
+`,
+		// A reference on another cluster doesn't count
+		"apps/media/overlays/erauner-cloud/production": `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: jellyfin-config
+  namespace: media
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: other
+  namespace: media
+spec:
+  template:
+    spec:
+      containers:
+        - name: app
+          envFrom:
+            - configMapRef:
+                name: leftover
+`,
+	}
+
+	unused, err := validate.FindUnusedConfig(manifests, clusterOfTestDir, config.Unused{Consumers: []string{"grafana_dashboard"}})
+	if err != nil {
+		t.Fatalf("FindUnusedConfig failed: %v", err)
+	}
+	want := []validate.UnusedConfig{
+		{Cluster: "erauner-cloud", Resource: validate.ResourceID{Kind: "ConfigMap", Namespace: "media", Name: "jellyfin-config"}, Directory: "apps/media/overlays/erauner-cloud/production"},
+		{Cluster: "erauner-home", Resource: validate.ResourceID{Kind: "ConfigMap", Namespace: "media", Name: "leftover"}, Directory: "apps/media/overlays/erauner-home/production"},
+	}
+	if len(unused) != len(want) {
+		t.Fatalf("expected %d unused resources, got %+v", len(want), unused)
+	}
+	for i := range want {
+		if unused[i] != want[i] {
+			t.Errorf("unused[%d] = %+v, want %+v", i, unused[i], want[i])
+		}
+	}
+
+	result := unused[1].Result()
+	if result.Rule != validate.RuleUnusedConfig || result.Severity != "warn" || result.Cluster != "erauner-home" {
+		t.Errorf("unexpected result: %+v", result)
+	}
+
+	ignored, err := validate.FindUnusedConfig(manifests, clusterOfTestDir, config.Unused{Ignore: []string{"media/*"}, Consumers: []string{"grafana_dashboard"}})
+	if err != nil || len(ignored) != 0 {
+		t.Errorf("expected ignore patterns to exclude everything, got %+v, %v", ignored, err)
+	}
+}
@@ -0,0 +1,335 @@
+// Package validatetest provides fixture builders and assertions for testing
+// validation rules. Fixtures are declared as data (clusters, kustomizations,
+// ArgoCD Applications, raw files) and materialized into a temp repository.
+//
+// Tests that use this package must live in the external validate_test package
+// to avoid an import cycle.
+package validatetest
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/erauner/homelab-shadow/pkg/validate"
+	"gopkg.in/yaml.v3"
+)
+
+// Repo declares the contents of a fixture repository
+type Repo struct {
+	// Clusters creates clusters/<name>/ for each entry
+	Clusters []string
+
+	// Dirs creates empty directories (relative to the repo root)
+	Dirs []string
+
+	// Kustomizations writes <dir>/kustomization.yaml for each entry
+	Kustomizations map[string]Kustomization
+
+	// Applications writes ArgoCD Application manifests
+	Applications []Application
+
+	// Files writes raw files (path relative to repo root -> content)
+	Files map[string]string
+}
+
+// Kustomization declares a kustomization.yaml
+type Kustomization struct {
+	Resources  []string
+	Bases      []string
+	Components []string
+	Generators []string
+	HelmCharts []string // chart names
+}
+
+// Application declares an ArgoCD Application manifest
+type Application struct {
+	Name string
+
+	// Dir is the directory under argocd-apps/ (default: "applications")
+	Dir string
+
+	// Path is a single-source spec.source.path
+	Path string
+
+	// Paths creates a multi-source spec.sources list with one path per entry
+	Paths []string
+
+	// Namespace is spec.destination.namespace
+	Namespace string
+
+	// Destination and Server are spec.destination.name and .server
+	Destination string
+	Server      string
+
+	// SyncOptions is spec.syncPolicy.syncOptions
+	SyncOptions []string
+
+	// SyncWave is the argocd.argoproj.io/sync-wave annotation
+	SyncWave string
+
+	// Project is spec.project
+	Project string
+}
+
+// Build materializes the fixture into a temp directory and returns its path
+func Build(t testing.TB, spec Repo) string {
+	t.Helper()
+
+	root := t.TempDir()
+
+	for _, cluster := range spec.Clusters {
+		mkdir(t, filepath.Join(root, "clusters", cluster))
+	}
+
+	for _, dir := range spec.Dirs {
+		mkdir(t, filepath.Join(root, dir))
+	}
+
+	for dir, k := range spec.Kustomizations {
+		writeFile(t, filepath.Join(root, dir, "kustomization.yaml"), k.YAML())
+	}
+
+	for _, app := range spec.Applications {
+		dir := app.Dir
+		if dir == "" {
+			dir = "applications"
+		}
+		writeFile(t, filepath.Join(root, "argocd-apps", dir, app.Name+".yaml"), app.YAML())
+	}
+
+	for path, content := range spec.Files {
+		writeFile(t, filepath.Join(root, path), content)
+	}
+
+	return root
+}
+
+// YAML renders the kustomization as kustomization.yaml content
+func (k Kustomization) YAML() string {
+	doc := map[string]interface{}{
+		"apiVersion": "kustomize.config.k8s.io/v1beta1",
+		"kind":       "Kustomization",
+	}
+	if len(k.Resources) > 0 {
+		doc["resources"] = k.Resources
+	}
+	if len(k.Bases) > 0 {
+		doc["bases"] = k.Bases
+	}
+	if len(k.Components) > 0 {
+		doc["components"] = k.Components
+	}
+	if len(k.Generators) > 0 {
+		doc["generators"] = k.Generators
+	}
+	if len(k.HelmCharts) > 0 {
+		var charts []map[string]string
+		for _, name := range k.HelmCharts {
+			charts = append(charts, map[string]string{"name": name})
+		}
+		doc["helmCharts"] = charts
+	}
+	return marshal(doc)
+}
+
+// YAML renders the Application as a manifest
+func (a Application) YAML() string {
+	destination := map[string]string{"namespace": a.Namespace}
+	if a.Destination != "" {
+		destination["name"] = a.Destination
+	}
+	if a.Server != "" {
+		destination["server"] = a.Server
+	}
+	spec := map[string]interface{}{
+		"destination": destination,
+	}
+	if a.Path != "" {
+		spec["source"] = map[string]string{
+			"repoURL":        "git@github.com:erauner/homelab-k8s.git",
+			"targetRevision": "master",
+			"path":           a.Path,
+		}
+	}
+	if len(a.Paths) > 0 {
+		var sources []map[string]string
+		for _, p := range a.Paths {
+			sources = append(sources, map[string]string{
+				"repoURL":        "git@github.com:erauner/homelab-k8s.git",
+				"targetRevision": "master",
+				"path":           p,
+			})
+		}
+		spec["sources"] = sources
+	}
+	if a.Project != "" {
+		spec["project"] = a.Project
+	}
+	if len(a.SyncOptions) > 0 {
+		spec["syncPolicy"] = map[string]interface{}{"syncOptions": a.SyncOptions}
+	}
+
+	metadata := map[string]interface{}{
+		"name":      a.Name,
+		"namespace": "argocd",
+	}
+	if a.SyncWave != "" {
+		metadata["annotations"] = map[string]string{"argocd.argoproj.io/sync-wave": a.SyncWave}
+	}
+
+	return marshal(map[string]interface{}{
+		"apiVersion": "argoproj.io/v1alpha1",
+		"kind":       "Application",
+		"metadata":   metadata,
+		"spec":       spec,
+	})
+}
+
+// Finding describes an expected validation result
+// Empty fields match any value
+type Finding struct {
+	Rule     string
+	Path     string
+	Cluster  string
+	Severity string
+}
+
+// Matches reports whether a result satisfies the expected finding
+func (f Finding) Matches(r validate.Result) bool {
+	return (f.Rule == "" || f.Rule == r.Rule) &&
+		(f.Path == "" || f.Path == r.Path) &&
+		(f.Cluster == "" || f.Cluster == r.Cluster) &&
+		(f.Severity == "" || f.Severity == r.Severity)
+}
+
+func (f Finding) String() string {
+	var parts []string
+	if f.Rule != "" {
+		parts = append(parts, "rule="+f.Rule)
+	}
+	if f.Path != "" {
+		parts = append(parts, "path="+f.Path)
+	}
+	if f.Cluster != "" {
+		parts = append(parts, "cluster="+f.Cluster)
+	}
+	if f.Severity != "" {
+		parts = append(parts, "severity="+f.Severity)
+	}
+	return "{" + strings.Join(parts, " ") + "}"
+}
+
+// AssertFindings checks that results match want exactly: every expected finding
+// matches a distinct result, and no results are left over
+func AssertFindings(t testing.TB, results []validate.Result, want ...Finding) {
+	t.Helper()
+
+	remaining := append([]validate.Result(nil), results...)
+	for _, f := range want {
+		idx := -1
+		for i, r := range remaining {
+			if f.Matches(r) {
+				idx = i
+				break
+			}
+		}
+		if idx < 0 {
+			t.Errorf("missing expected finding %s", f)
+			continue
+		}
+		remaining = append(remaining[:idx], remaining[idx+1:]...)
+	}
+
+	for _, r := range remaining {
+		t.Errorf("unexpected finding: %s", FormatResult(r))
+	}
+}
+
+// AssertContains checks that each expected finding matches at least one result
+// Other results are ignored
+func AssertContains(t testing.TB, results []validate.Result, want ...Finding) {
+	t.Helper()
+
+	for _, f := range want {
+		found := false
+		for _, r := range results {
+			if f.Matches(r) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			t.Errorf("missing expected finding %s; got:\n%s", f, FormatResults(results))
+		}
+	}
+}
+
+// AssertNoRule checks that none of the given rules produced a finding
+func AssertNoRule(t testing.TB, results []validate.Result, rules ...string) {
+	t.Helper()
+
+	for _, r := range results {
+		for _, rule := range rules {
+			if r.Rule == rule {
+				t.Errorf("unexpected finding: %s", FormatResult(r))
+			}
+		}
+	}
+}
+
+// FilterRule returns only results for the given rules
+func FilterRule(results []validate.Result, rules ...string) []validate.Result {
+	var filtered []validate.Result
+	for _, r := range results {
+		for _, rule := range rules {
+			if r.Rule == rule {
+				filtered = append(filtered, r)
+				break
+			}
+		}
+	}
+	return filtered
+}
+
+// FormatResult formats a single result for test failure messages
+func FormatResult(r validate.Result) string {
+	return fmt.Sprintf("[%s] %s cluster=%s path=%s: %s", r.Severity, r.Rule, r.Cluster, r.Path, r.Message)
+}
+
+// FormatResults formats results one per line, sorted for stable output
+func FormatResults(results []validate.Result) string {
+	lines := make([]string, 0, len(results))
+	for _, r := range results {
+		lines = append(lines, "  "+FormatResult(r))
+	}
+	sort.Strings(lines)
+	return strings.Join(lines, "\n")
+}
+
+func mkdir(t testing.TB, path string) {
+	t.Helper()
+	if err := os.MkdirAll(path, 0755); err != nil {
+		t.Fatalf("failed to create directory %s: %v", path, err)
+	}
+}
+
+func writeFile(t testing.TB, path, content string) {
+	t.Helper()
+	mkdir(t, filepath.Dir(path))
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write file %s: %v", path, err)
+	}
+}
+
+func marshal(doc interface{}) string {
+	out, err := yaml.Marshal(doc)
+	if err != nil {
+		// Fixture values are plain maps/slices of strings; this cannot fail
+		panic(fmt.Sprintf("validatetest: failed to marshal fixture: %v", err))
+	}
+	return string(out)
+}
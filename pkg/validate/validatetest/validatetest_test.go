@@ -0,0 +1,78 @@
+package validatetest
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/erauner/homelab-shadow/pkg/validate"
+)
+
+func TestBuild(t *testing.T) {
+	root := Build(t, Repo{
+		Clusters: []string{"erauner-home"},
+		Dirs:     []string{"apps/coder/base"},
+		Kustomizations: map[string]Kustomization{
+			"apps/coder/overlays/erauner-home/production": {Resources: []string{"../../../base"}},
+		},
+		Applications: []Application{
+			{Name: "coder", Path: "apps/coder/overlays/erauner-home/production", Namespace: "coder"},
+		},
+		Files: map[string]string{"README.md": "fixture"},
+	})
+
+	for _, path := range []string{
+		"clusters/erauner-home",
+		"apps/coder/base",
+		"apps/coder/overlays/erauner-home/production/kustomization.yaml",
+		"argocd-apps/applications/coder.yaml",
+		"README.md",
+	} {
+		if _, err := os.Stat(filepath.Join(root, path)); err != nil {
+			t.Errorf("expected %s to exist: %v", path, err)
+		}
+	}
+
+	data, err := os.ReadFile(filepath.Join(root, "argocd-apps/applications/coder.yaml"))
+	if err != nil {
+		t.Fatalf("failed to read application: %v", err)
+	}
+	if !strings.Contains(string(data), "kind: Application") || !strings.Contains(string(data), "path: apps/coder/overlays/erauner-home/production") {
+		t.Errorf("unexpected application manifest:\n%s", data)
+	}
+}
+
+func TestKustomizationYAML(t *testing.T) {
+	out := Kustomization{
+		Resources:  []string{"../../base"},
+		HelmCharts: []string{"oauth2-proxy"},
+	}.YAML()
+
+	for _, want := range []string{"kind: Kustomization", "- ../../base", "name: oauth2-proxy"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in:\n%s", want, out)
+		}
+	}
+}
+
+func TestFindingMatches(t *testing.T) {
+	r := validate.Result{Rule: "r1", Path: "p", Cluster: "c", Severity: "warn"}
+
+	if !(Finding{}).Matches(r) {
+		t.Error("empty finding should match anything")
+	}
+	if !(Finding{Rule: "r1", Severity: "warn"}).Matches(r) {
+		t.Error("expected partial finding to match")
+	}
+	if (Finding{Rule: "r1", Severity: "error"}).Matches(r) {
+		t.Error("expected severity mismatch to fail")
+	}
+}
+
+func TestFilterRule(t *testing.T) {
+	results := []validate.Result{{Rule: "a"}, {Rule: "b"}, {Rule: "a"}}
+	if got := FilterRule(results, "a"); len(got) != 2 {
+		t.Errorf("FilterRule() returned %d results, want 2", len(got))
+	}
+}
@@ -0,0 +1,172 @@
+package validate
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Workload rules, evaluated against the containers of rendered Deployments,
+// StatefulSets, DaemonSets, and CronJobs. Each is a warning by default and can
+// be raised or turned off on its own in .shadow.yaml
+const (
+	RuleWorkloadRequestsMissing  = "workload-requests-missing"
+	RuleWorkloadLimitsMissing    = "workload-limits-missing"
+	RuleWorkloadLivenessMissing  = "workload-liveness-probe-missing"
+	RuleWorkloadReadinessMissing = "workload-readiness-probe-missing"
+	RuleWorkloadRunsAsRoot       = "workload-runs-as-root"
+)
+
+// WorkloadRules lists every workload rule
+var WorkloadRules = []string{
+	RuleWorkloadRequestsMissing,
+	RuleWorkloadLimitsMissing,
+	RuleWorkloadLivenessMissing,
+	RuleWorkloadReadinessMissing,
+	RuleWorkloadRunsAsRoot,
+}
+
+// workloadPodSpecs maps workload kinds to the path of their pod spec
+var workloadPodSpecs = map[string][]string{
+	"Deployment":  {"spec", "template", "spec"},
+	"StatefulSet": {"spec", "template", "spec"},
+	"DaemonSet":   {"spec", "template", "spec"},
+	"CronJob":     {"spec", "jobTemplate", "spec", "template", "spec"},
+}
+
+// ValidateWorkloads checks the containers of every workload in a rendered
+// manifest for resource requests and memory limits, liveness and readiness
+// probes, and a non-root user. CPU limits are not required (they throttle
+// bursty workloads), and CronJob and init containers need no probes
+func ValidateWorkloads(cluster, path, manifest string) []Result {
+	results := []Result{} // Initialize to empty slice for consistent JSON output
+
+	dec := yaml.NewDecoder(strings.NewReader(manifest))
+	for {
+		var doc map[string]interface{}
+		err := dec.Decode(&doc)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return append(results, Result{
+				Cluster:  cluster,
+				Rule:     "manifest-parse-fail",
+				Path:     path,
+				Message:  fmt.Sprintf("failed to parse manifest: %v", err),
+				Severity: "error",
+			})
+		}
+
+		kind, _ := doc["kind"].(string)
+		specPath, ok := workloadPodSpecs[kind]
+		if !ok {
+			continue
+		}
+		name := ""
+		if metadata, ok := doc["metadata"].(map[string]interface{}); ok {
+			name, _ = metadata["name"].(string)
+		}
+		podSpec, ok := lookupMap(doc, specPath...)
+		if !ok {
+			continue
+		}
+
+		add := func(rule, container, problem string) {
+			results = append(results, Result{
+				Cluster:  cluster,
+				Rule:     rule,
+				Path:     path,
+				Message:  fmt.Sprintf("%s/%s container %q %s", kind, name, container, problem),
+				Severity: "warn",
+			})
+		}
+
+		podSecurity, _ := podSpec["securityContext"].(map[string]interface{})
+		for _, list := range []string{"initContainers", "containers"} {
+			containers, _ := podSpec[list].([]interface{})
+			for _, c := range containers {
+				container, ok := c.(map[string]interface{})
+				if !ok {
+					continue
+				}
+				cname, _ := container["name"].(string)
+
+				requests, _ := lookupMap(container, "resources", "requests")
+				if missing := missingKeys(requests, "cpu", "memory"); len(missing) > 0 {
+					add(RuleWorkloadRequestsMissing, cname, fmt.Sprintf("has no %s request", strings.Join(missing, " or ")))
+				}
+				limits, _ := lookupMap(container, "resources", "limits")
+				if len(missingKeys(limits, "memory")) > 0 {
+					add(RuleWorkloadLimitsMissing, cname, "has no memory limit")
+				}
+
+				if list == "containers" && kind != "CronJob" {
+					if container["livenessProbe"] == nil {
+						add(RuleWorkloadLivenessMissing, cname, "has no liveness probe")
+					}
+					if container["readinessProbe"] == nil {
+						add(RuleWorkloadReadinessMissing, cname, "has no readiness probe")
+					}
+				}
+
+				containerSecurity, _ := container["securityContext"].(map[string]interface{})
+				if problem := rootProblem(podSecurity, containerSecurity); problem != "" {
+					add(RuleWorkloadRunsAsRoot, cname, problem)
+				}
+			}
+		}
+	}
+
+	return results
+}
+
+// rootProblem describes how a container may run as root, or returns "" when
+// its security context (falling back to the pod's) rules it out
+func rootProblem(pod, container map[string]interface{}) string {
+	runAsUser, hasUser := container["runAsUser"]
+	if !hasUser {
+		runAsUser, hasUser = pod["runAsUser"]
+	}
+	if hasUser {
+		if uid, ok := runAsUser.(int); ok && uid == 0 {
+			return "runs as root (runAsUser: 0)"
+		}
+		return ""
+	}
+
+	nonRoot, set := container["runAsNonRoot"].(bool)
+	if !set {
+		nonRoot, _ = pod["runAsNonRoot"].(bool)
+	}
+	if nonRoot {
+		return ""
+	}
+	return "may run as root (set runAsNonRoot: true or a non-zero runAsUser)"
+}
+
+// lookupMap follows keys through nested mappings
+func lookupMap(node map[string]interface{}, keys ...string) (map[string]interface{}, bool) {
+	for _, key := range keys {
+		next, ok := node[key].(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		node = next
+	}
+	return node, true
+}
+
+// missingKeys returns the keys absent from m
+func missingKeys(m map[string]interface{}, keys ...string) []string {
+	var missing []string
+	for _, key := range keys {
+		if _, ok := m[key]; !ok {
+			missing = append(missing, key)
+		}
+	}
+	return missing
+}
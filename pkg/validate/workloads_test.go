@@ -0,0 +1,121 @@
+package validate
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestValidateWorkloads(t *testing.T) {
+	manifest := `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  template:
+    spec:
+      securityContext:
+        runAsNonRoot: true
+      initContainers:
+      - name: migrate
+        image: web:1.0
+        resources:
+          requests: {cpu: 10m, memory: 64Mi}
+          limits: {memory: 64Mi}
+      containers:
+      - name: app
+        image: web:1.0
+        resources:
+          requests: {cpu: 100m, memory: 128Mi}
+          limits: {memory: 256Mi}
+        livenessProbe: {httpGet: {path: /healthz, port: 8080}}
+        readinessProbe: {httpGet: {path: /ready, port: 8080}}
+      - name: sidecar
+        image: proxy:1.0
+        resources:
+          requests: {memory: 32Mi}
+        securityContext:
+          runAsUser: 0
+---
+apiVersion: batch/v1
+kind: CronJob
+metadata:
+  name: backup
+spec:
+  jobTemplate:
+    spec:
+      template:
+        spec:
+          containers:
+          - name: backup
+            image: restic:0.16
+            resources:
+              requests: {cpu: 10m, memory: 64Mi}
+              limits: {memory: 64Mi}
+            securityContext:
+              runAsUser: 1000
+---
+apiVersion: v1
+kind: Pod
+metadata:
+  name: ignored
+spec:
+  containers:
+  - name: ignored
+    image: busybox:1.36
+`
+
+	var got []string
+	for _, r := range ValidateWorkloads("home", "apps/web/overlays/home", manifest) {
+		if r.Cluster != "home" || r.Path != "apps/web/overlays/home" || r.Severity != "warn" {
+			t.Errorf("unexpected result %+v", r)
+		}
+		got = append(got, r.Rule+": "+r.Message)
+	}
+	want := []string{
+		RuleWorkloadRequestsMissing + `: Deployment/web container "sidecar" has no cpu request`,
+		RuleWorkloadLimitsMissing + `: Deployment/web container "sidecar" has no memory limit`,
+		RuleWorkloadLivenessMissing + `: Deployment/web container "sidecar" has no liveness probe`,
+		RuleWorkloadReadinessMissing + `: Deployment/web container "sidecar" has no readiness probe`,
+		RuleWorkloadRunsAsRoot + `: Deployment/web container "sidecar" runs as root (runAsUser: 0)`,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("results:\n%v\nwant:\n%v", got, want)
+	}
+}
+
+func TestValidateWorkloads_MaybeRoot(t *testing.T) {
+	manifest := `apiVersion: apps/v1
+kind: DaemonSet
+metadata:
+  name: agent
+spec:
+  template:
+    spec:
+      securityContext:
+        runAsNonRoot: true
+      containers:
+      - name: agent
+        image: agent:1.0
+        securityContext:
+          runAsNonRoot: false
+`
+	rules := make(map[string]bool)
+	for _, r := range ValidateWorkloads("home", "infrastructure/agent/overlays/home", manifest) {
+		rules[r.Rule] = true
+		if r.Rule == RuleWorkloadRunsAsRoot && r.Message != `DaemonSet/agent container "agent" may run as root (set runAsNonRoot: true or a non-zero runAsUser)` {
+			t.Errorf("message = %q", r.Message)
+		}
+	}
+	for _, rule := range WorkloadRules {
+		if !rules[rule] {
+			t.Errorf("expected %s for a bare container", rule)
+		}
+	}
+}
+
+func TestValidateWorkloads_InvalidManifest(t *testing.T) {
+	results := ValidateWorkloads("home", "apps/web", "kind: [")
+	if len(results) != 1 || results[0].Rule != "manifest-parse-fail" {
+		t.Errorf("expected manifest-parse-fail, got %+v", results)
+	}
+}